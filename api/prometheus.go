@@ -0,0 +1,169 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"go.k6.io/k6/api/common"
+	"go.k6.io/k6/stats"
+)
+
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// prometheusQuantiles are the quantiles of the summaries of the Trends.
+//
+//nolint:gochecknoglobals
+var prometheusQuantiles = []float64{0.5, 0.9, 0.95, 0.99}
+
+// prometheusHelpEscaper escapes the backslashes and the line feeds of the help texts.
+//
+//nolint:gochecknoglobals
+var prometheusHelpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+// handlePrometheusMetrics serves the metrics of the test, aggregated since its start,
+// in the text exposition format of Prometheus.
+func handlePrometheusMetrics(logger logrus.FieldLogger) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		engine := common.GetEngine(r.Context())
+
+		var b bytes.Buffer
+		engine.MetricsLock.Lock()
+		writePrometheusMetrics(&b, engine.Metrics)
+		engine.MetricsLock.Unlock()
+
+		rw.Header().Set("Content-Type", prometheusContentType)
+		if _, err := rw.Write(b.Bytes()); err != nil {
+			logger.WithError(err).Error("Error while writing the Prometheus metrics")
+		}
+	})
+}
+
+// prometheusName returns the name of the metric with the k6_ prefix, and the characters
+// that aren't allowed in the names of Prometheus replaced by underscores.
+func prometheusName(name string) string {
+	return "k6_" + prometheusSanitize(name)
+}
+
+func prometheusSanitize(name string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' || r == ':' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+func formatPrometheusValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// writePrometheusHeader writes the HELP and TYPE lines of the metric, whose help text is its description
+// when it has one.
+func writePrometheusHeader(w io.Writer, promName string, m *stats.Metric, promType string) {
+	help := fmt.Sprintf("The %s %s of k6.", m.Name, m.Type)
+	if m.Description != "" {
+		help = prometheusHelpEscaper.Replace(m.Description)
+	}
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", promName, help, promName, promType)
+}
+
+// writePrometheusMetrics writes the metrics sorted by name. Counters are counters with the _total suffix,
+// Gauges and Rates are gauges, Trends are summaries and Histograms are histograms. The times are in seconds,
+// with the _seconds suffix, the data is in bytes, with the _bytes suffix, and the units of the other metrics
+// are their suffix.
+// The submetrics of the thresholds are left out, since their samples are already counted in their parents.
+func writePrometheusMetrics(w io.Writer, metrics map[string]*stats.Metric) {
+	names := make([]string, 0, len(metrics))
+	for name, m := range metrics {
+		if m.Sub.Name != "" {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		m := metrics[name]
+		promName := prometheusName(name)
+		scale := 1.0
+		switch m.Contains {
+		case stats.Time:
+			// the times are in milliseconds
+			promName += "_seconds"
+			scale = 0.001
+		case stats.Data:
+			promName += "_bytes"
+		case stats.Default:
+			// the durations in seconds are already in the base unit of Prometheus
+			switch m.Unit {
+			case "":
+			case stats.UnitSeconds:
+				promName += "_seconds"
+			default:
+				promName += "_" + prometheusSanitize(m.Unit)
+			}
+		}
+
+		switch sink := m.Sink.(type) {
+		case *stats.CounterSink:
+			promName += "_total"
+			writePrometheusHeader(w, promName, m, "counter")
+			fmt.Fprintf(w, "%s %s\n", promName, formatPrometheusValue(sink.Value*scale))
+		case *stats.GaugeSink:
+			writePrometheusHeader(w, promName, m, "gauge")
+			fmt.Fprintf(w, "%s %s\n", promName, formatPrometheusValue(sink.Value*scale))
+		case *stats.RateSink:
+			rate := 0.0
+			if sink.Total > 0 {
+				rate = float64(sink.Trues) / float64(sink.Total)
+			}
+			writePrometheusHeader(w, promName, m, "gauge")
+			fmt.Fprintf(w, "%s %s\n", promName, formatPrometheusValue(rate))
+		case *stats.TrendSink:
+			writePrometheusHeader(w, promName, m, "summary")
+			for _, q := range prometheusQuantiles {
+				fmt.Fprintf(w, "%s{quantile=\"%s\"} %s\n",
+					promName, formatPrometheusValue(q), formatPrometheusValue(sink.P(q)*scale))
+			}
+			fmt.Fprintf(w, "%s_sum %s\n", promName, formatPrometheusValue(sink.Sum*scale))
+			fmt.Fprintf(w, "%s_count %d\n", promName, sink.Count)
+		case *stats.HistogramSink:
+			writePrometheusHeader(w, promName, m, "histogram")
+			var cumulative uint64
+			for i, bound := range sink.Bounds {
+				cumulative += sink.Counts[i]
+				fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", promName, formatPrometheusValue(bound*scale), cumulative)
+			}
+			fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", promName, sink.Count)
+			fmt.Fprintf(w, "%s_sum %s\n", promName, formatPrometheusValue(sink.Sum*scale))
+			fmt.Fprintf(w, "%s_count %d\n", promName, sink.Count)
+		}
+	}
+}
@@ -21,7 +21,10 @@
 package api
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 
 	"github.com/sirupsen/logrus"
@@ -31,17 +34,21 @@ import (
 	"go.k6.io/k6/core"
 )
 
-func newHandler(logger logrus.FieldLogger) http.Handler {
+func newHandler(logger logrus.FieldLogger, prometheusMetrics bool) http.Handler {
 	mux := http.NewServeMux()
 	mux.Handle("/v1/", v1.NewHandler())
+	if prometheusMetrics {
+		mux.Handle("/metrics", handlePrometheusMetrics(logger))
+	}
 	mux.Handle("/ping", handlePing(logger))
 	mux.Handle("/", handlePing(logger))
 	return mux
 }
 
-// ListenAndServe is analogous to the stdlib one but also takes a core.Engine and logrus.FieldLogger
-func ListenAndServe(addr string, engine *core.Engine, logger logrus.FieldLogger) error {
-	mux := newHandler(logger)
+// ListenAndServe is analogous to the stdlib one but also takes a core.Engine and logrus.FieldLogger,
+// the metrics are served in the Prometheus format on /metrics if prometheusMetrics is enabled.
+func ListenAndServe(addr string, engine *core.Engine, logger logrus.FieldLogger, prometheusMetrics bool) error {
+	mux := newHandler(logger, prometheusMetrics)
 
 	return http.ListenAndServe(addr, withEngine(engine, newLogger(logger, mux)))
 }
@@ -56,6 +63,22 @@ func (w wrappedResponseWriter) WriteHeader(status int) {
 	w.ResponseWriter.WriteHeader(status)
 }
 
+// Flush implements http.Flusher, for the streamed responses.
+func (w wrappedResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker, for the WebSocket connections.
+func (w wrappedResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("the response writer doesn't support hijacking")
+	}
+	return h.Hijack()
+}
+
 // newLogger returns the middleware which logs response status for request.
 func newLogger(l logrus.FieldLogger, next http.Handler) http.HandlerFunc {
 	return func(rw http.ResponseWriter, r *http.Request) {
@@ -38,6 +38,7 @@ import (
 	"go.k6.io/k6/lib/metrics"
 	"go.k6.io/k6/lib/testutils"
 	"go.k6.io/k6/lib/testutils/minirunner"
+	"go.k6.io/k6/stats"
 )
 
 func testHTTPHandler(rw http.ResponseWriter, r *http.Request) {
@@ -97,7 +98,7 @@ func TestWithEngine(t *testing.T) {
 func TestPing(t *testing.T) {
 	logger := logrus.New()
 	logger.SetOutput(testutils.NewTestOutput(t))
-	mux := newHandler(logger)
+	mux := newHandler(logger, false)
 
 	rw := httptest.NewRecorder()
 	r := httptest.NewRequest("GET", "/ping", nil)
@@ -107,3 +108,100 @@ func TestPing(t *testing.T) {
 	assert.Equal(t, http.StatusOK, res.StatusCode)
 	assert.Equal(t, []byte{'o', 'k'}, rw.Body.Bytes())
 }
+
+func TestPrometheusMetrics(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(testutils.NewTestOutput(t))
+	execScheduler, err := local.NewExecutionScheduler(&minirunner.MiniRunner{}, logger)
+	require.NoError(t, err)
+	registry := metrics.NewRegistry()
+	builtinMetrics := metrics.RegisterBuiltinMetrics(registry)
+	engine, err := core.NewEngine(execScheduler, lib.Options{}, lib.RuntimeOptions{}, nil, logger, builtinMetrics)
+	require.NoError(t, err)
+
+	reqs := stats.New("http_reqs", stats.Counter)
+	reqs.Sink.Add(stats.Sample{Value: 3})
+	dataSent := stats.New("data_sent", stats.Counter, stats.Data)
+	dataSent.Sink.Add(stats.Sample{Value: 1024})
+	vus := stats.New("vus", stats.Gauge)
+	vus.Sink.Add(stats.Sample{Value: 10})
+	checks := stats.New("checks", stats.Rate)
+	checks.Sink.Add(stats.Sample{Value: 1})
+	checks.Sink.Add(stats.Sample{Value: 0})
+	duration := stats.New("http_req_duration", stats.Trend, stats.Time)
+	for _, v := range []float64{100, 200, 300, 400, 500} {
+		duration.Sink.Add(stats.Sample{Value: v})
+	}
+	orders := stats.New("orders", stats.Counter)
+	orders.Unit = "orders"
+	orders.Description = "The placed orders,\nwith a \\ and a line feed."
+	orders.Sink.Add(stats.Sample{Value: 2})
+	size := stats.New("size", stats.Histogram, stats.Data)
+	size.Buckets = []float64{1024, 4096}
+	size.Sink = stats.NewHistogramSink(size.Buckets)
+	for _, v := range []float64{512, 2048, 8192} {
+		size.Sink.Add(stats.Sample{Value: v})
+	}
+	wait := stats.New("wait", stats.Gauge)
+	wait.Unit = stats.UnitSeconds
+	wait.Sink.Add(stats.Sample{Value: 1.5})
+	_, sub := stats.NewSubmetric("http_req_duration{status:200}")
+	sub.Metric = stats.New(sub.Name, stats.Trend, stats.Time)
+	sub.Metric.Sub = *sub
+	engine.Metrics = map[string]*stats.Metric{
+		reqs.Name: reqs, dataSent.Name: dataSent, vus.Name: vus, checks.Name: checks,
+		duration.Name: duration, sub.Name: sub.Metric, orders.Name: orders, size.Name: size, wait.Name: wait,
+	}
+
+	t.Run("enabled", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/metrics", nil)
+		withEngine(engine, newHandler(logger, true))(rw, r)
+
+		res := rw.Result()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", res.Header.Get("Content-Type"))
+		assert.Equal(t, `# HELP k6_checks The checks rate of k6.
+# TYPE k6_checks gauge
+k6_checks 0.5
+# HELP k6_data_sent_bytes_total The data_sent counter of k6.
+# TYPE k6_data_sent_bytes_total counter
+k6_data_sent_bytes_total 1024
+# HELP k6_http_req_duration_seconds The http_req_duration trend of k6.
+# TYPE k6_http_req_duration_seconds summary
+k6_http_req_duration_seconds{quantile="0.5"} 0.3
+k6_http_req_duration_seconds{quantile="0.9"} 0.46
+k6_http_req_duration_seconds{quantile="0.95"} 0.48
+k6_http_req_duration_seconds{quantile="0.99"} 0.496
+k6_http_req_duration_seconds_sum 1.5
+k6_http_req_duration_seconds_count 5
+# HELP k6_http_reqs_total The http_reqs counter of k6.
+# TYPE k6_http_reqs_total counter
+k6_http_reqs_total 3
+# HELP k6_orders_orders_total The placed orders,\nwith a \\ and a line feed.
+# TYPE k6_orders_orders_total counter
+k6_orders_orders_total 2
+# HELP k6_size_bytes The size histogram of k6.
+# TYPE k6_size_bytes histogram
+k6_size_bytes_bucket{le="1024"} 1
+k6_size_bytes_bucket{le="4096"} 2
+k6_size_bytes_bucket{le="+Inf"} 3
+k6_size_bytes_sum 10752
+k6_size_bytes_count 3
+# HELP k6_vus The vus gauge of k6.
+# TYPE k6_vus gauge
+k6_vus 10
+# HELP k6_wait_seconds The wait gauge of k6.
+# TYPE k6_wait_seconds gauge
+k6_wait_seconds 1.5
+`, rw.Body.String())
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/metrics", nil)
+		withEngine(engine, newHandler(logger, false))(rw, r)
+		// the catch-all ping handler
+		assert.Equal(t, "ok", rw.Body.String())
+	})
+}
@@ -0,0 +1,53 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	v1 "go.k6.io/k6/api/v1"
+)
+
+// Scenarios returns the current state of all scenarios.
+func (c *Client) Scenarios(ctx context.Context) (ret []v1.Scenario, err error) {
+	var resp v1.ScenariosJSONAPI
+
+	if err = c.CallAPI(ctx, http.MethodGet, &url.URL{Path: "/v1/scenarios"}, nil, &resp); err != nil {
+		return ret, err
+	}
+
+	return resp.Scenarios(), nil
+}
+
+// SetScenario tries to pause, resume or scale the given scenario and returns
+// its new state if it was successful.
+func (c *Client) SetScenario(ctx context.Context, name string, patch v1.Scenario) (ret v1.Scenario, err error) {
+	var resp v1.ScenarioJSONAPI
+
+	apiURL := &url.URL{Path: "/v1/scenarios/" + name}
+	if err = c.CallAPI(ctx, http.MethodPatch, apiURL, v1.NewScenarioJSONAPI(patch), &resp); err != nil {
+		return ret, err
+	}
+
+	return resp.Scenario(), nil
+}
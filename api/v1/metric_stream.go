@@ -0,0 +1,223 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"go.k6.io/k6/api/common"
+	"go.k6.io/k6/core"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/stats"
+)
+
+const defaultStreamInterval = time.Second
+
+// MetricsSnapshotJSONAPI is the JSON API document of the snapshots of the metrics that are pushed by
+// /v1/metrics/stream, with the same data as the one of /v1/metrics.
+type MetricsSnapshotJSONAPI struct {
+	Data []metricData        `json:"data"`
+	Meta MetricsSnapshotMeta `json:"meta"`
+}
+
+// MetricsSnapshotMeta has the changes of the metrics since the previous snapshot,
+// or since the start of the test run for the first one.
+type MetricsSnapshotMeta struct {
+	Time time.Time `json:"time"`
+	// Deltas are the changes of the Counters, with their rates over the interval, of the counts of the Trends,
+	// and of the passes and fails of the Rates, with their rates over the interval, by metric.
+	Deltas map[string]map[string]float64 `json:"deltas"`
+}
+
+// metricCounts are the totals of a metric that the deltas are computed from.
+type metricCounts struct {
+	// count is the value of a Counter or the count of a Trend
+	count        float64
+	trues, total int64
+}
+
+// snapshotter keeps the totals of the previous snapshot of a stream.
+type snapshotter struct {
+	previous         map[string]metricCounts
+	previousDuration time.Duration
+}
+
+func (s *snapshotter) snapshot(metrics map[string]*stats.Metric, t time.Duration) MetricsSnapshotJSONAPI {
+	elapsed := (t - s.previousDuration).Seconds()
+	perSecond := func(delta float64) float64 {
+		if elapsed <= 0 {
+			return 0
+		}
+		return delta / elapsed
+	}
+
+	current := make(map[string]metricCounts, len(metrics))
+	deltas := make(map[string]map[string]float64, len(metrics))
+	for name, m := range metrics {
+		prev := s.previous[name]
+		switch sink := m.Sink.(type) {
+		case *stats.CounterSink:
+			current[name] = metricCounts{count: sink.Value}
+			delta := sink.Value - prev.count
+			deltas[name] = map[string]float64{"count": delta, "rate": perSecond(delta)}
+		case stats.TrendStatsSink:
+			count := float64(sink.TrendValues().Count)
+			current[name] = metricCounts{count: count}
+			deltas[name] = map[string]float64{"count": count - prev.count}
+		case *stats.RateSink:
+			current[name] = metricCounts{trues: sink.Trues, total: sink.Total}
+			passes, total := sink.Trues-prev.trues, sink.Total-prev.total
+			rate := 0.0
+			if total > 0 {
+				rate = float64(passes) / float64(total)
+			}
+			deltas[name] = map[string]float64{"passes": float64(passes), "fails": float64(total - passes), "rate": rate}
+		}
+	}
+	s.previous, s.previousDuration = current, t
+
+	data := newMetricsJSONAPI(metrics, t).Data
+	for _, d := range data {
+		// JSON can't encode them, e.g. the rates of the Counters at the start of the test run
+		for k, v := range d.Attributes.Sample {
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				delete(d.Attributes.Sample, k)
+			}
+		}
+	}
+	return MetricsSnapshotJSONAPI{
+		Data: data,
+		Meta: MetricsSnapshotMeta{Time: time.Now(), Deltas: deltas},
+	}
+}
+
+func (s *snapshotter) marshalSnapshot(engine *core.Engine) ([]byte, error) {
+	var t time.Duration
+	if engine.ExecutionScheduler != nil {
+		t = engine.ExecutionScheduler.GetState().GetCurrentTestRunDuration()
+	}
+
+	engine.MetricsLock.Lock()
+	snapshot := s.snapshot(engine.Metrics, t)
+	engine.MetricsLock.Unlock()
+
+	return json.Marshal(snapshot)
+}
+
+// handleStreamMetrics pushes a snapshot of the metrics at every interval, which is set with the interval parameter,
+// e.g. ?interval=5s, and is a second by default. The snapshots are sent as the messages of a WebSocket if the
+// request is an upgrade to one, or as server-sent events otherwise, until the client disconnects.
+func handleStreamMetrics(rw http.ResponseWriter, r *http.Request) {
+	engine := common.GetEngine(r.Context())
+
+	interval := defaultStreamInterval
+	if v := r.URL.Query().Get("interval"); v != "" {
+		var err error
+		if interval, err = types.ParseExtendedDuration(v); err != nil || interval <= 0 {
+			apiError(rw, "Invalid interval", fmt.Sprintf("'%s' isn't a positive duration", v), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if websocket.IsWebSocketUpgrade(r) {
+		streamMetricsWebSocket(rw, r, engine, interval)
+		return
+	}
+	streamMetricsEvents(rw, r, engine, interval)
+}
+
+func streamMetricsEvents(rw http.ResponseWriter, r *http.Request, engine *core.Engine, interval time.Duration) {
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		apiError(rw, "Streaming unsupported", "The response can't be streamed", http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.WriteHeader(http.StatusOK)
+
+	s := &snapshotter{}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		data, err := s.marshalSnapshot(engine)
+		if err != nil {
+			return
+		}
+		if _, err = fmt.Fprintf(rw, "event: metrics\ndata: %s\n\n", data); err != nil {
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-ticker.C:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func streamMetricsWebSocket(rw http.ResponseWriter, r *http.Request, engine *core.Engine, interval time.Duration) {
+	conn, err := (&websocket.Upgrader{}).Upgrade(rw, r, nil)
+	if err != nil {
+		// the upgrader has already responded with the error
+		return
+	}
+	defer func() { _ = conn.Close() }()
+
+	// the messages of the client are discarded, the reads only detect when it closes the connection
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	s := &snapshotter{}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		data, err := s.marshalSnapshot(engine)
+		if err != nil {
+			return
+		}
+		if err = conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			return
+		}
+
+		select {
+		case <-ticker.C:
+		case <-closed:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
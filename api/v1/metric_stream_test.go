@@ -0,0 +1,157 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package v1
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/api/common"
+	"go.k6.io/k6/core"
+	"go.k6.io/k6/core/local"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/lib/testutils/minirunner"
+	"go.k6.io/k6/stats"
+)
+
+func TestMetricsSnapshot(t *testing.T) {
+	t.Parallel()
+
+	counter := stats.New("my_counter", stats.Counter)
+	trend := stats.New("my_trend", stats.Trend, stats.Time)
+	rate := stats.New("my_rate", stats.Rate)
+	gauge := stats.New("my_gauge", stats.Gauge)
+	list := map[string]*stats.Metric{"my_counter": counter, "my_trend": trend, "my_rate": rate, "my_gauge": gauge}
+	add := func(m *stats.Metric, values ...float64) {
+		for _, v := range values {
+			m.Sink.Add(stats.Sample{Metric: m, Value: v})
+		}
+	}
+
+	s := &snapshotter{}
+	add(counter, 4)
+	add(trend, 10, 20)
+	add(rate, 1, 0)
+	add(gauge, 3)
+	snapshot := s.snapshot(list, 2*time.Second)
+	assert.Len(t, snapshot.Data, 4)
+	assert.Equal(t, map[string]map[string]float64{
+		"my_counter": {"count": 4, "rate": 2},
+		"my_trend":   {"count": 2},
+		"my_rate":    {"passes": 1, "fails": 1, "rate": 0.5},
+	}, snapshot.Meta.Deltas)
+
+	add(counter, 1)
+	add(rate, 1)
+	snapshot = s.snapshot(list, 3*time.Second)
+	assert.Equal(t, map[string]map[string]float64{
+		"my_counter": {"count": 1, "rate": 1},
+		"my_trend":   {"count": 0},
+		"my_rate":    {"passes": 1, "fails": 0, "rate": 1},
+	}, snapshot.Meta.Deltas)
+}
+
+func newStreamServer(t *testing.T) *httptest.Server {
+	logger := logrus.New()
+	logger.SetOutput(testutils.NewTestOutput(t))
+	execScheduler, err := local.NewExecutionScheduler(&minirunner.MiniRunner{}, logger)
+	require.NoError(t, err)
+	registry := metrics.NewRegistry()
+	builtinMetrics := metrics.RegisterBuiltinMetrics(registry)
+	engine, err := core.NewEngine(execScheduler, lib.Options{}, lib.RuntimeOptions{}, nil, logger, builtinMetrics)
+	require.NoError(t, err)
+	engine.Metrics = map[string]*stats.Metric{"my_counter": stats.New("my_counter", stats.Counter)}
+
+	handler := NewHandler()
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(rw, r.WithContext(common.WithEngine(r.Context(), engine)))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestStreamMetrics(t *testing.T) {
+	t.Parallel()
+
+	t.Run("events", func(t *testing.T) {
+		t.Parallel()
+		srv := newStreamServer(t)
+		res, err := http.Get(srv.URL + "/v1/metrics/stream?interval=10ms") //nolint:noctx
+		require.NoError(t, err)
+		defer func() { _ = res.Body.Close() }()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+		scanner := bufio.NewScanner(res.Body)
+		var events int
+		for events < 2 && scanner.Scan() {
+			line := scanner.Text()
+			if !strings.HasPrefix(line, "data: ") {
+				continue
+			}
+			var snapshot MetricsSnapshotJSONAPI
+			require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &snapshot))
+			require.Len(t, snapshot.Data, 1)
+			assert.Equal(t, "my_counter", snapshot.Data[0].ID)
+			assert.Contains(t, snapshot.Meta.Deltas, "my_counter")
+			events++
+		}
+		assert.Equal(t, 2, events)
+	})
+
+	t.Run("websocket", func(t *testing.T) {
+		t.Parallel()
+		srv := newStreamServer(t)
+		conn, res, err := websocket.DefaultDialer.Dial(
+			"ws"+strings.TrimPrefix(srv.URL, "http")+"/v1/metrics/stream?interval=10ms", nil)
+		require.NoError(t, err)
+		defer func() { _ = conn.Close() }()
+		_ = res.Body.Close()
+
+		for i := 0; i < 2; i++ {
+			var snapshot MetricsSnapshotJSONAPI
+			require.NoError(t, conn.ReadJSON(&snapshot))
+			require.Len(t, snapshot.Data, 1)
+			assert.Equal(t, "my_counter", snapshot.Data[0].ID)
+		}
+	})
+
+	t.Run("invalid interval", func(t *testing.T) {
+		t.Parallel()
+		srv := newStreamServer(t)
+		res, err := http.Get(srv.URL + "/v1/metrics/stream?interval=-1s") //nolint:noctx
+		require.NoError(t, err)
+		_ = res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+}
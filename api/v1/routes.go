@@ -47,6 +47,14 @@ func NewHandler() http.Handler {
 		handleGetMetrics(rw, r)
 	})
 
+	mux.HandleFunc("/v1/metrics/stream", func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			rw.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		handleStreamMetrics(rw, r)
+	})
+
 	mux.HandleFunc("/v1/metrics/", func(rw http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			rw.WriteHeader(http.StatusMethodNotAllowed)
@@ -76,6 +84,27 @@ func NewHandler() http.Handler {
 		handleGetGroup(rw, r, id)
 	})
 
+	mux.HandleFunc("/v1/scenarios", func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			rw.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		handleGetScenarios(rw, r)
+	})
+
+	mux.HandleFunc("/v1/scenarios/", func(rw http.ResponseWriter, r *http.Request) {
+		name := r.URL.Path[len("/v1/scenarios/"):]
+		switch r.Method {
+		case http.MethodGet:
+			handleGetScenario(rw, r, name)
+		case http.MethodPatch:
+			handlePatchScenario(rw, r, name)
+		default:
+			rw.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+
 	mux.HandleFunc("/v1/setup", func(rw http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodPost:
@@ -0,0 +1,58 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package v1
+
+import (
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/executor"
+)
+
+// Scenario is the state of a single scenario of the test. It can be paused
+// and resumed on its own, and the VUs or the iteration rate of the executors
+// which support that can be changed.
+type Scenario struct {
+	Name     string     `json:"name" yaml:"name"`
+	Executor string     `json:"executor" yaml:"executor"`
+	Paused   null.Bool  `json:"paused" yaml:"paused"`
+	VUs      null.Int   `json:"vus" yaml:"vus"`
+	VUsMax   null.Int   `json:"vus-max" yaml:"vus-max"`
+	Rate     null.Float `json:"rate" yaml:"rate"`
+}
+
+// NewScenario returns the current state of the scenario of the given executor.
+func NewScenario(executionState *lib.ExecutionState, exec lib.Executor) Scenario {
+	config := exec.GetConfig()
+	scenario := Scenario{
+		Name:     config.GetName(),
+		Executor: config.GetType(),
+		Paused:   null.BoolFrom(executionState.IsScenarioPaused(config.GetName())),
+	}
+	switch e := exec.(type) {
+	case *executor.ExternallyControlled:
+		params := e.GetCurrentConfig().ExternallyControlledConfigParams
+		scenario.VUs, scenario.VUsMax = params.VUs, params.MaxVUs
+	case *executor.ExternallyControlledArrivalRate:
+		scenario.Rate = null.FloatFrom(e.GetRate())
+	}
+	return scenario
+}
@@ -0,0 +1,74 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package v1
+
+// ScenarioJSONAPI is the JSON API envelop for a scenario
+type ScenarioJSONAPI struct {
+	Data scenarioData `json:"data"`
+}
+
+// ScenariosJSONAPI is the JSON API envelop for a list of scenarios
+type ScenariosJSONAPI struct {
+	Data []scenarioData `json:"data"`
+}
+
+type scenarioData struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"`
+	Attributes Scenario `json:"attributes"`
+}
+
+// NewScenarioJSONAPI creates the JSON API scenario envelop
+func NewScenarioJSONAPI(s Scenario) ScenarioJSONAPI {
+	return ScenarioJSONAPI{Data: newScenarioData(s)}
+}
+
+func newScenariosJSONAPI(scenarios []Scenario) ScenariosJSONAPI {
+	envelop := ScenariosJSONAPI{
+		Data: make([]scenarioData, 0, len(scenarios)),
+	}
+	for _, s := range scenarios {
+		envelop.Data = append(envelop.Data, newScenarioData(s))
+	}
+	return envelop
+}
+
+func newScenarioData(s Scenario) scenarioData {
+	return scenarioData{
+		Type:       "scenarios",
+		ID:         s.Name,
+		Attributes: s,
+	}
+}
+
+// Scenario extracts the v1.Scenario from the JSON API envelop
+func (s ScenarioJSONAPI) Scenario() Scenario {
+	return s.Data.Attributes
+}
+
+// Scenarios extracts the v1.Scenario list from the JSON API envelop
+func (s ScenariosJSONAPI) Scenarios() []Scenario {
+	scenarios := make([]Scenario, 0, len(s.Data))
+	for _, data := range s.Data {
+		scenarios = append(scenarios, data.Attributes)
+	}
+	return scenarios
+}
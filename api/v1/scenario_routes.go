@@ -0,0 +1,127 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package v1
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"go.k6.io/k6/api/common"
+	"go.k6.io/k6/core"
+	"go.k6.io/k6/lib"
+)
+
+func getScenarioExecutor(engine *core.Engine, name string) lib.Executor {
+	for _, exec := range engine.ExecutionScheduler.GetExecutors() {
+		if exec.GetConfig().GetName() == name {
+			return exec
+		}
+	}
+	return nil
+}
+
+func handleGetScenarios(rw http.ResponseWriter, r *http.Request) {
+	engine := common.GetEngine(r.Context())
+
+	executionState := engine.ExecutionScheduler.GetState()
+	executors := engine.ExecutionScheduler.GetExecutors()
+	scenarios := make([]Scenario, 0, len(executors))
+	for _, exec := range executors {
+		scenarios = append(scenarios, NewScenario(executionState, exec))
+	}
+
+	data, err := json.Marshal(newScenariosJSONAPI(scenarios))
+	if err != nil {
+		apiError(rw, "Encoding error", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_, _ = rw.Write(data)
+}
+
+func handleGetScenario(rw http.ResponseWriter, r *http.Request, name string) {
+	engine := common.GetEngine(r.Context())
+
+	exec := getScenarioExecutor(engine, name)
+	if exec == nil {
+		apiError(rw, "Not Found", "No scenario with that name was found", http.StatusNotFound)
+		return
+	}
+
+	data, err := json.Marshal(NewScenarioJSONAPI(NewScenario(engine.ExecutionScheduler.GetState(), exec)))
+	if err != nil {
+		apiError(rw, "Encoding error", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_, _ = rw.Write(data)
+}
+
+func handlePatchScenario(rw http.ResponseWriter, r *http.Request, name string) {
+	engine := common.GetEngine(r.Context())
+
+	exec := getScenarioExecutor(engine, name)
+	if exec == nil {
+		apiError(rw, "Not Found", "No scenario with that name was found", http.StatusNotFound)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		apiError(rw, "Couldn't read request", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var scenarioEnvelop ScenarioJSONAPI
+	if err = json.Unmarshal(body, &scenarioEnvelop); err != nil {
+		apiError(rw, "Invalid data", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	scenario := scenarioEnvelop.Scenario()
+	executionState := engine.ExecutionScheduler.GetState()
+
+	if scenario.Paused.Valid && scenario.Paused.Bool != executionState.IsScenarioPaused(name) {
+		if scenario.Paused.Bool {
+			err = executionState.PauseScenario(name)
+		} else {
+			err = executionState.ResumeScenario(name)
+		}
+		if err != nil {
+			apiError(rw, "Pause error", err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if scenario.VUs.Valid || scenario.VUsMax.Valid || scenario.Rate.Valid {
+		scale := lib.ScenarioScale{VUs: scenario.VUs, MaxVUs: scenario.VUsMax, Rate: scenario.Rate}
+		if err = executionState.ScaleScenario(r.Context(), name, scale); err != nil {
+			apiError(rw, "Scale error", err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	data, err := json.Marshal(NewScenarioJSONAPI(NewScenario(executionState, exec)))
+	if err != nil {
+		apiError(rw, "Encoding error", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_, _ = rw.Write(data)
+}
@@ -0,0 +1,176 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/core"
+	"go.k6.io/k6/core/local"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/lib/testutils/minirunner"
+)
+
+func newScenariosTestEngine(t *testing.T) *core.Engine {
+	logger := logrus.New()
+	logger.SetOutput(testutils.NewTestOutput(t))
+
+	scenarios := lib.ScenarioConfigs{}
+	err := json.Unmarshal([]byte(`{
+		"constant": {"executor": "constant-vus", "vus": 1, "duration": "1s"},
+		"arrival": {"executor": "externally-controlled-arrival-rate",
+			"rate": 10, "maxRate": 100, "preAllocatedVUs": 1, "duration": "1s"}
+	}`), &scenarios)
+	require.NoError(t, err)
+	options := lib.Options{Scenarios: scenarios}
+	registry := metrics.NewRegistry()
+	builtinMetrics := metrics.RegisterBuiltinMetrics(registry)
+
+	execScheduler, err := local.NewExecutionScheduler(&minirunner.MiniRunner{Options: options}, logger)
+	require.NoError(t, err)
+	engine, err := core.NewEngine(execScheduler, options, lib.RuntimeOptions{}, nil, logger, builtinMetrics)
+	require.NoError(t, err)
+	return engine
+}
+
+func TestGetScenarios(t *testing.T) {
+	t.Parallel()
+
+	engine := newScenariosTestEngine(t)
+
+	rw := httptest.NewRecorder()
+	NewHandler().ServeHTTP(rw, newRequestWithEngine(engine, "GET", "/v1/scenarios", nil))
+	res := rw.Result()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	var doc ScenariosJSONAPI
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &doc))
+	require.Len(t, doc.Data, 2)
+	assert.Equal(t, "scenarios", doc.Data[0].Type)
+	assert.ElementsMatch(t, []Scenario{
+		{
+			Name: "arrival", Executor: "externally-controlled-arrival-rate",
+			Paused: null.BoolFrom(false), Rate: null.FloatFrom(10),
+		},
+		{Name: "constant", Executor: "constant-vus", Paused: null.BoolFrom(false)},
+	}, doc.Scenarios())
+
+	rw = httptest.NewRecorder()
+	NewHandler().ServeHTTP(rw, newRequestWithEngine(engine, "GET", "/v1/scenarios/constant", nil))
+	require.Equal(t, http.StatusOK, rw.Result().StatusCode)
+	var scenarioDoc ScenarioJSONAPI
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &scenarioDoc))
+	assert.Equal(t, "constant", scenarioDoc.Data.ID)
+	assert.Equal(t, "constant-vus", scenarioDoc.Scenario().Executor)
+
+	rw = httptest.NewRecorder()
+	NewHandler().ServeHTTP(rw, newRequestWithEngine(engine, "GET", "/v1/scenarios/other", nil))
+	assert.Equal(t, http.StatusNotFound, rw.Result().StatusCode)
+}
+
+func TestPatchScenario(t *testing.T) {
+	t.Parallel()
+
+	testData := map[string]struct {
+		Name               string
+		Patch              Scenario
+		ExpectedStatusCode int
+		ExpectedScenario   Scenario
+	}{
+		"pause": {
+			Name:               "constant",
+			Patch:              Scenario{Paused: null.BoolFrom(true)},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedScenario:   Scenario{Name: "constant", Executor: "constant-vus", Paused: null.BoolFrom(true)},
+		},
+		"resume without a pause": {
+			Name:               "constant",
+			Patch:              Scenario{Paused: null.BoolFrom(false)},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedScenario:   Scenario{Name: "constant", Executor: "constant-vus", Paused: null.BoolFrom(false)},
+		},
+		"rate": {
+			Name:               "arrival",
+			Patch:              Scenario{Paused: null.BoolFrom(true), Rate: null.FloatFrom(50)},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedScenario: Scenario{
+				Name: "arrival", Executor: "externally-controlled-arrival-rate",
+				Paused: null.BoolFrom(true), Rate: null.FloatFrom(50),
+			},
+		},
+		"above max rate": {
+			Name:               "arrival",
+			Patch:              Scenario{Rate: null.FloatFrom(101)},
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+		"vus of an arrival rate executor": {
+			Name:               "arrival",
+			Patch:              Scenario{VUs: null.IntFrom(5)},
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+		"vus of an executor without scaling": {
+			Name:               "constant",
+			Patch:              Scenario{VUs: null.IntFrom(5)},
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+		"unknown scenario": {
+			Name:               "other",
+			Patch:              Scenario{Paused: null.BoolFrom(true)},
+			ExpectedStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for name, testCase := range testData {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			engine := newScenariosTestEngine(t)
+			payload, err := json.Marshal(NewScenarioJSONAPI(testCase.Patch))
+			require.NoError(t, err)
+
+			rw := httptest.NewRecorder()
+			req := newRequestWithEngine(engine, "PATCH", "/v1/scenarios/"+testCase.Name, bytes.NewReader(payload))
+			NewHandler().ServeHTTP(rw, req)
+			require.Equal(t, testCase.ExpectedStatusCode, rw.Result().StatusCode)
+			if testCase.ExpectedStatusCode != http.StatusOK {
+				return
+			}
+
+			var doc ScenarioJSONAPI
+			require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &doc))
+			assert.Equal(t, testCase.ExpectedScenario, doc.Scenario())
+			assert.Equal(t, testCase.ExpectedScenario.Paused.Bool,
+				engine.ExecutionScheduler.GetState().IsScenarioPaused(testCase.Name))
+		})
+	}
+}
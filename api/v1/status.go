@@ -30,16 +30,21 @@ import (
 type Status struct {
 	Status lib.ExecutionStatus `json:"status" yaml:"status"`
 
-	Paused  null.Bool `json:"paused" yaml:"paused"`
-	VUs     null.Int  `json:"vus" yaml:"vus"`
-	VUsMax  null.Int  `json:"vus-max" yaml:"vus-max"`
-	Stopped bool      `json:"stopped" yaml:"stopped"`
-	Running bool      `json:"running" yaml:"running"`
-	Tainted bool      `json:"tainted" yaml:"tainted"`
+	Paused  null.Bool  `json:"paused" yaml:"paused"`
+	VUs     null.Int   `json:"vus" yaml:"vus"`
+	VUsMax  null.Int   `json:"vus-max" yaml:"vus-max"`
+	Rate    null.Float `json:"rate" yaml:"rate"` // of the first externally-controlled-arrival-rate executor
+	Stopped bool       `json:"stopped" yaml:"stopped"`
+	Running bool       `json:"running" yaml:"running"`
+	Tainted bool       `json:"tainted" yaml:"tainted"`
 }
 
 func NewStatus(engine *core.Engine) Status {
 	executionState := engine.ExecutionScheduler.GetState()
+	var rate null.Float
+	if executor, err := getFirstExternallyControlledArrivalRateExecutor(engine.ExecutionScheduler); err == nil {
+		rate = null.FloatFrom(executor.GetRate())
+	}
 	return Status{
 		Status:  executionState.GetCurrentExecutionStatus(),
 		Running: executionState.HasStarted() && !executionState.HasEnded(),
@@ -48,5 +53,6 @@ func NewStatus(engine *core.Engine) Status {
 		VUs:     null.IntFrom(executionState.GetCurrentlyActiveVUsCount()),
 		VUsMax:  null.IntFrom(executionState.GetInitializedVUsCount()),
 		Tainted: engine.IsTainted(),
+		Rate:    rate,
 	}
 }
@@ -55,6 +55,18 @@ func getFirstExternallyControlledExecutor(
 	return nil, errors.New("an externally-controlled executor needs to be configured for live configuration updates")
 }
 
+func getFirstExternallyControlledArrivalRateExecutor(
+	execScheduler lib.ExecutionScheduler,
+) (*executor.ExternallyControlledArrivalRate, error) {
+	executors := execScheduler.GetExecutors()
+	for _, s := range executors {
+		if ecar, ok := s.(*executor.ExternallyControlledArrivalRate); ok {
+			return ecar, nil
+		}
+	}
+	return nil, errors.New("an externally-controlled-arrival-rate executor needs to be configured for rate updates")
+}
+
 func handlePatchStatus(rw http.ResponseWriter, r *http.Request) {
 	engine := common.GetEngine(r.Context())
 
@@ -103,6 +115,18 @@ func handlePatchStatus(rw http.ResponseWriter, r *http.Request) {
 				return
 			}
 		}
+
+		if status.Rate.Valid {
+			executor, updateErr := getFirstExternallyControlledArrivalRateExecutor(engine.ExecutionScheduler)
+			if updateErr != nil {
+				apiError(rw, "Execution config error", updateErr.Error(), http.StatusInternalServerError)
+				return
+			}
+			if updateErr := executor.SetRate(status.Rate.Float64); updateErr != nil {
+				apiError(rw, "Rate update error", updateErr.Error(), http.StatusBadRequest)
+				return
+			}
+		}
 	}
 
 	data, err := json.Marshal(newStatusJSONAPIFromEngine(engine))
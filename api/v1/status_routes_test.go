@@ -24,6 +24,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -123,6 +124,10 @@ func TestPatchStatus(t *testing.T) {
 			ExpectedStatus:     Status{VUs: null.IntFrom(10), VUsMax: null.IntFrom(10)},
 			Payload:            []byte(`{"data":{"type":"status","id":"default","attributes":{"status":0,"paused":null,"vus":10,"vus-max":10,"stopped":false,"running":false,"tainted":false}}}`),
 		},
+		"rate without an arrival rate executor": {
+			ExpectedStatusCode: 500,
+			Payload:            []byte(`{"data":{"type":"status","id":"default","attributes":{"status":0,"paused":null,"vus":null,"vus-max":null,"rate":5,"stopped":false,"running":false,"tainted":false}}}`),
+		},
 	}
 	logger := logrus.New()
 	logger.SetOutput(testutils.NewTestOutput(t))
@@ -176,3 +181,64 @@ func TestPatchStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestPatchStatusRate(t *testing.T) {
+	t.Parallel()
+
+	testData := map[string]struct {
+		ExpectedStatusCode int
+		Rate               float64
+	}{
+		"rate":           {ExpectedStatusCode: 200, Rate: 50},
+		"paused":         {ExpectedStatusCode: 200, Rate: 0},
+		"above max rate": {ExpectedStatusCode: 400, Rate: 101},
+		"negative rate":  {ExpectedStatusCode: 400, Rate: -1},
+	}
+	logger := logrus.New()
+	logger.SetOutput(testutils.NewTestOutput(t))
+
+	scenarios := lib.ScenarioConfigs{}
+	err := json.Unmarshal([]byte(`
+			{"external": {"executor": "externally-controlled-arrival-rate",
+			"rate": 10, "maxRate": 100, "preAllocatedVUs": 1, "duration": "1s"}}`), &scenarios)
+	require.NoError(t, err)
+	options := lib.Options{Scenarios: scenarios}
+	registry := metrics.NewRegistry()
+	builtinMetrics := metrics.RegisterBuiltinMetrics(registry)
+
+	for name, testCase := range testData {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			execScheduler, err := local.NewExecutionScheduler(&minirunner.MiniRunner{Options: options}, logger)
+			require.NoError(t, err)
+			engine, err := core.NewEngine(execScheduler, options, lib.RuntimeOptions{}, nil, logger, builtinMetrics)
+			require.NoError(t, err)
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			run, _, err := engine.Init(ctx, ctx)
+			require.NoError(t, err)
+			assert.Equal(t, null.FloatFrom(10), NewStatus(engine).Rate)
+
+			go func() { _ = run() }()
+			time.Sleep(100 * time.Millisecond)
+
+			payload := fmt.Sprintf(`{"data":{"type":"status","id":"default","attributes":{"rate":%g}}}`, testCase.Rate)
+			rw := httptest.NewRecorder()
+			req := newRequestWithEngine(engine, "PATCH", "/v1/status", bytes.NewReader([]byte(payload)))
+			NewHandler().ServeHTTP(rw, req)
+			res := rw.Result()
+
+			require.Equal(t, testCase.ExpectedStatusCode, res.StatusCode)
+			if testCase.ExpectedStatusCode != 200 {
+				assert.Equal(t, null.FloatFrom(10), NewStatus(engine).Rate)
+				return
+			}
+
+			var statusEnvelop StatusJSONAPI
+			require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &statusEnvelop))
+			assert.Equal(t, null.FloatFrom(testCase.Rate), statusEnvelop.Status().Rate)
+		})
+	}
+}
@@ -0,0 +1,173 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/core"
+	"go.k6.io/k6/core/distributed"
+	"go.k6.io/k6/core/local"
+	"go.k6.io/k6/errext"
+	"go.k6.io/k6/errext/exitcodes"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/loader"
+	"go.k6.io/k6/output"
+)
+
+// agentFlags are the flags specific to the agent command.
+type agentFlags struct {
+	coordinatorAddress string
+	name               string
+}
+
+//nolint:funlen
+func getAgentCmd(ctx context.Context, logger *logrus.Logger) *cobra.Command {
+	flags := &agentFlags{coordinatorAddress: "localhost:6566"}
+	if hostname, err := os.Hostname(); err == nil {
+		flags.name = hostname
+	}
+	agentCmd := &cobra.Command{
+		Use:   "agent",
+		Short: "Run a part of a distributed test",
+		Long: `Run a part of a distributed test.
+
+The agent connects to a k6 coordinator and runs the execution segment of the
+test that the coordinator gives it. Its metrics are sent to the coordinator,
+which evaluates the thresholds and shows the end-of-test summary.`,
+		Example: `
+  # Run a part of the test of the coordinator listening on host:6566.
+  k6 agent --coordinator-address host:6566`[1:],
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			logger.Debugf("Connecting to the coordinator at %s...", flags.coordinatorAddress)
+			agent, err := distributed.NewAgent(ctx, flags.coordinatorAddress, flags.name)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = agent.Close() }()
+			defer func() {
+				if derr := agent.Done(err); derr != nil {
+					logger.WithError(derr).Error("Couldn't notify the coordinator that the test run has finished")
+				}
+			}()
+			logger.Infof("Running the execution segment %s of the test", agent.ExecutionSegment)
+
+			// The thresholds and the summary are handled by the coordinator.
+			runtimeOptions := lib.RuntimeOptions{
+				NoThresholds: null.BoolFrom(true),
+				NoSummary:    null.BoolFrom(true),
+			}
+			registry := metrics.NewRegistry()
+			builtinMetrics := metrics.RegisterBuiltinMetrics(registry)
+			src := &loader.SourceData{URL: &url.URL{Path: "/archive.tar"}, Data: agent.Archive}
+			runner, err := newRunner(logger, src, typeArchive, nil, runtimeOptions, builtinMetrics, registry)
+			if err != nil {
+				return common.UnwrapGojaInterruptedError(err)
+			}
+			// The options of the archive are consolidated with the defaults, like
+			// when the archive is executed with k6 run.
+			cliOpts, err := getOptions(optionFlagSet())
+			if err != nil {
+				return err
+			}
+			conf := applyDefault(Config{Options: cliOpts}.Apply(Config{Options: runner.GetOptions()}))
+			options := conf.Options
+			options.ExecutionSegment = agent.ExecutionSegment
+			options.ExecutionSegmentSequence = &agent.ExecutionSegmentSequence
+			if err = runner.SetOptions(options); err != nil {
+				return err
+			}
+
+			execScheduler, err := local.NewExecutionScheduler(runner, logger)
+			if err != nil {
+				return err
+			}
+			execScheduler.SetController(agent)
+
+			globalCtx, globalCancel := context.WithCancel(ctx)
+			defer globalCancel()
+			runCtx, runCancel := context.WithCancel(globalCtx)
+			defer runCancel()
+
+			outputs := []output.Output{agent.NewOutput(execScheduler.GetState(), logger)}
+			engine, err := core.NewEngine(execScheduler, options, runtimeOptions, outputs, logger, builtinMetrics)
+			if err != nil {
+				return err
+			}
+			if err = engine.StartOutputs(); err != nil {
+				return err
+			}
+			defer engine.StopOutputs()
+
+			sigC := make(chan os.Signal, 1)
+			signal.Notify(sigC, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+			defer signal.Stop(sigC)
+			go func() {
+				sig := <-sigC
+				logger.WithField("sig", sig).Debug("Stopping the agent in response to signal...")
+				runCancel()
+				sig = <-sigC
+				logger.WithField("sig", sig).Error("Aborting k6 in response to signal")
+				os.Exit(int(exitcodes.ExternalAbort))
+			}()
+
+			engineRun, engineWait, err := engine.Init(globalCtx, runCtx)
+			if err != nil {
+				return errext.WithExitCodeIfNone(common.UnwrapGojaInterruptedError(err), exitcodes.GenericEngine)
+			}
+			err = engineRun()
+			runCancel()
+			globalCancel()
+			engineWait()
+			if err != nil && !common.IsInterruptError(common.UnwrapGojaInterruptedError(err)) {
+				return errext.WithExitCodeIfNone(common.UnwrapGojaInterruptedError(err), exitcodes.GenericEngine)
+			}
+			logger.Info("The test run has finished")
+			return nil
+		},
+	}
+
+	agentCmd.Flags().SortFlags = false
+	agentCmd.Flags().AddFlagSet(agentCmdFlagSet(flags))
+
+	return agentCmd
+}
+
+func agentCmdFlagSet(flags *agentFlags) *pflag.FlagSet {
+	flagSet := pflag.NewFlagSet("", pflag.ContinueOnError)
+	flagSet.SortFlags = false
+	flagSet.StringVar(&flags.coordinatorAddress, "coordinator-address", flags.coordinatorAddress,
+		"address of the coordinator")
+	flagSet.StringVar(&flags.name, "name", flags.name, "name of the agent, shown by the coordinator")
+	return flagSet
+}
@@ -54,7 +54,11 @@ An archive is a fully self-contained test run, and can be executed identically e
 				return err
 			}
 
-			runtimeOptions, err := getRuntimeOptions(cmd.Flags(), buildEnvMap(os.Environ()))
+			profile, err := getProfile(afero.NewOsFs(), globalFlags)
+			if err != nil {
+				return err
+			}
+			runtimeOptions, err := getRuntimeOptions(cmd.Flags(), buildEnvMap(os.Environ()), profile)
 			if err != nil {
 				return err
 			}
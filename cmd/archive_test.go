@@ -43,7 +43,7 @@ func TestArchiveThresholds(t *testing.T) {
 			cmd := getArchiveCmd(testutils.NewLogger(t), newCommandFlags())
 			filename, err := filepath.Abs(testCase.testFilename)
 			require.NoError(t, err)
-			args := []string{filename}
+			args := []string{filename, "--archive-out", filepath.Join(t.TempDir(), "archive.tar")}
 			if testCase.noThresholds {
 				args = append(args, "--no-thresholds")
 			}
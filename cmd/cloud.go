@@ -89,8 +89,12 @@ This will execute the test on the k6 cloud service. Use "k6 login cloud" to auth
 				return err
 			}
 
+			profile, err := getProfile(afero.NewOsFs(), globalFlags)
+			if err != nil {
+				return err
+			}
 			osEnvironment := buildEnvMap(os.Environ())
-			runtimeOptions, err := getRuntimeOptions(cmd.Flags(), osEnvironment)
+			runtimeOptions, err := getRuntimeOptions(cmd.Flags(), osEnvironment, profile)
 			if err != nil {
 				return err
 			}
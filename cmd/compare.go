@@ -0,0 +1,546 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+
+	"go.k6.io/k6/errext"
+	"go.k6.io/k6/errext/exitcodes"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/stats"
+)
+
+// comparedRun has the values of the metrics of a test run, with their samples when they are known,
+// i.e. when it's the JSON output of the run and not its summary.
+type comparedRun struct {
+	types  map[string]stats.MetricType
+	values lib.Baseline
+	trends map[string]*stats.TrendSink
+	rates  map[string]*stats.RateSink
+}
+
+// comparison is the comparison of a value of a metric in two test runs.
+type comparison struct {
+	metric, stat string
+	a, b         float64
+	// significant is whether the difference is statistically significant, it's nil when it isn't known
+	significant *bool
+	pValue      float64 // NaN when the significance isn't from a p-value
+	regression  bool
+	improvement bool
+}
+
+// compareOptions are the options of the comparisons of the test runs.
+type compareOptions struct {
+	stats      []string
+	metrics    []string
+	tolerance  float64
+	confidence float64
+}
+
+//nolint:funlen
+func getCompareCmd(globalFlags *commandFlags) *cobra.Command {
+	opts := compareOptions{stats: []string{"avg", "p(90)", "p(95)"}, tolerance: 5, confidence: 0.95}
+	compareCmd := &cobra.Command{
+		Use:   "compare",
+		Short: "Compare the results of two test runs",
+		Long: `Compare the results of two test runs.
+
+The runs are their JSON outputs, from --out json, optionally compressed with
+gzip or zstd, or their summaries, from --summary-export. For each metric of
+both runs, the change of its values from the first run to the second one is
+shown, which are the trend stats of the trends, the rates of the rates and the
+values of the counters and gauges.
+
+With the JSON outputs, the statistical significance of the changes is tested
+too, with a t-test for the averages of the trends, the confidence intervals of
+their medians and percentiles, and a z-test for the rates.
+
+A regression is a change to a worse trend stat or rate by more than the
+tolerance, which is statistically significant when it can be tested. The
+rates of the metrics which have "fail" or "error" in their names, like
+http_req_failed, are worse when they are higher, and the others, like checks,
+when they are lower. k6 compare exits with an error if there are regressions.`,
+		Example: `
+  # Compare two test runs.
+  k6 run --out json=before.json script.js
+  k6 run --out json=after.json script.js
+  k6 compare before.json after.json
+
+  # Fail on regressions of the p(99) of the trends by more than 10%.
+  k6 compare --stat p(99) --tolerance 10 before.json after.json`[1:],
+		Args: exactArgsWithMsg(2, "args should be the results of the two test runs"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := stats.GetResolversForTrendColumns(opts.stats); err != nil {
+				return errext.WithExitCodeIfNone(err, exitcodes.InvalidConfig)
+			}
+			if opts.confidence <= 0 || opts.confidence >= 1 {
+				return errext.WithExitCodeIfNone(
+					fmt.Errorf("the confidence needs to be between 0 and 1"), exitcodes.InvalidConfig)
+			}
+
+			fs := afero.NewOsFs()
+			a, err := readComparedRun(fs, args[0], opts.stats)
+			if err != nil {
+				return err
+			}
+			b, err := readComparedRun(fs, args[1], opts.stats)
+			if err != nil {
+				return err
+			}
+
+			comparisons, onlyA, onlyB := compareRuns(a, b, opts)
+			regressions := printComparisons(globalFlags.stdout, comparisons, opts)
+			if len(onlyA) > 0 {
+				fprintf(globalFlags.stdout, "\nOnly in %s: %s\n", args[0], strings.Join(onlyA, ", "))
+			}
+			if len(onlyB) > 0 {
+				fprintf(globalFlags.stdout, "\nOnly in %s: %s\n", args[1], strings.Join(onlyB, ", "))
+			}
+			if regressions > 0 {
+				return errext.WithExitCodeIfNone(
+					fmt.Errorf("%d regressions above the tolerance of %g%%", regressions, opts.tolerance),
+					exitcodes.ComparisonFailed)
+			}
+			return nil
+		},
+	}
+
+	compareCmd.Flags().SortFlags = false
+	compareCmd.Flags().StringSliceVar(&opts.stats, "stat", opts.stats, "compared `stat` of the trends")
+	compareCmd.Flags().StringSliceVar(&opts.metrics, "metric", nil, "compare only the `metric`, all of them by default")
+	compareCmd.Flags().Float64Var(&opts.tolerance, "tolerance", opts.tolerance,
+		"percentage by which a value can get worse without being a regression")
+	compareCmd.Flags().Float64Var(&opts.confidence, "confidence", opts.confidence,
+		"confidence level of the tests of the statistical significance")
+
+	return compareCmd
+}
+
+// openResults opens the results of a test run, which are decompressed when they are compressed with gzip
+// or zstd, like the JSON outputs can be. The returned function closes them.
+func openResults(fs afero.Fs, filename string) (io.Reader, func(), error) {
+	f, err := fs.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch {
+	case strings.HasSuffix(filename, ".gz"):
+		gr, err := gzip.NewReader(f)
+		if err != nil {
+			_ = f.Close()
+			return nil, nil, err
+		}
+		return gr, func() { _ = gr.Close(); _ = f.Close() }, nil
+	case strings.HasSuffix(filename, ".zst"), strings.HasSuffix(filename, ".zstd"):
+		zr, err := zstd.NewReader(f)
+		if err != nil {
+			_ = f.Close()
+			return nil, nil, err
+		}
+		return zr, func() { zr.Close(); _ = f.Close() }, nil
+	default:
+		return f, func() { _ = f.Close() }, nil
+	}
+}
+
+// readComparedRun reads the JSON output or the summary of a test run.
+func readComparedRun(fs afero.Fs, filename string, trendStats []string) (*comparedRun, error) {
+	r, closeResults, err := openResults(fs, filename)
+	if err != nil {
+		return nil, err
+	}
+	defer closeResults()
+
+	br := bufio.NewReader(r)
+	// the lines of the JSON outputs are envelopes with a type, the summaries are objects of the metrics
+	firstLine, err := br.ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	var envelope struct {
+		Type string `json:"type"`
+	}
+	if json.Unmarshal(firstLine, &envelope) == nil && envelope.Type != "" {
+		run, err := readJSONOutput(io.MultiReader(bytes.NewReader(firstLine), br), trendStats)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read the JSON output %s: %w", filename, err)
+		}
+		return run, nil
+	}
+
+	rest, err := io.ReadAll(br)
+	if err != nil {
+		return nil, err
+	}
+	baseline, err := lib.ParseBaseline(append(firstLine, rest...))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read the summary %s: %w", filename, err)
+	}
+	run := &comparedRun{types: make(map[string]stats.MetricType, len(baseline)), values: baseline}
+	for name, values := range baseline {
+		_, hasPasses := values["passes"]
+		_, hasCount := values["count"]
+		_, hasValue := values["value"]
+		switch {
+		case hasPasses:
+			run.types[name] = stats.Rate
+		case hasCount && len(values) == 2:
+			run.types[name] = stats.Counter
+		case hasValue:
+			run.types[name] = stats.Gauge
+		default:
+			run.types[name] = stats.Trend
+		}
+	}
+	return run, nil
+}
+
+// readJSONOutput reads the samples of a JSON output, and returns the values of their metrics.
+func readJSONOutput(r io.Reader, trendStats []string) (*comparedRun, error) {
+	run := &comparedRun{
+		types:  make(map[string]stats.MetricType),
+		values: make(lib.Baseline),
+		trends: make(map[string]*stats.TrendSink),
+		rates:  make(map[string]*stats.RateSink),
+	}
+	sinks := make(map[string]stats.Sink)
+	var first, last time.Time
+	decoder := json.NewDecoder(r)
+	for {
+		var envelope struct {
+			Type   string          `json:"type"`
+			Metric string          `json:"metric"`
+			Data   json.RawMessage `json:"data"`
+		}
+		if err := decoder.Decode(&envelope); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch envelope.Type {
+		case "Metric":
+			var metric struct {
+				Type stats.MetricType `json:"type"`
+			}
+			if err := json.Unmarshal(envelope.Data, &metric); err != nil {
+				return nil, err
+			}
+			if _, ok := sinks[envelope.Metric]; ok {
+				continue
+			}
+			run.types[envelope.Metric] = metric.Type
+			switch metric.Type {
+			case stats.Trend, stats.Histogram:
+				// the values of the histograms are compared like the ones of the trends
+				run.trends[envelope.Metric] = &stats.TrendSink{}
+				sinks[envelope.Metric] = run.trends[envelope.Metric]
+			case stats.Rate:
+				run.rates[envelope.Metric] = &stats.RateSink{}
+				sinks[envelope.Metric] = run.rates[envelope.Metric]
+			default:
+				sinks[envelope.Metric] = stats.New(envelope.Metric, metric.Type).Sink
+			}
+		case "Point":
+			var sample struct {
+				Time  time.Time `json:"time"`
+				Value float64   `json:"value"`
+			}
+			if err := json.Unmarshal(envelope.Data, &sample); err != nil {
+				return nil, err
+			}
+			sink, ok := sinks[envelope.Metric]
+			if !ok {
+				return nil, fmt.Errorf("the sample of the metric %s is before its definition", envelope.Metric)
+			}
+			sink.Add(stats.Sample{Time: sample.Time, Value: sample.Value})
+			if first.IsZero() || sample.Time.Before(first) {
+				first = sample.Time
+			}
+			if sample.Time.After(last) {
+				last = sample.Time
+			}
+		}
+	}
+
+	resolvers, err := stats.GetResolversForTrendColumns(trendStats)
+	if err != nil {
+		return nil, err
+	}
+	duration := last.Sub(first).Seconds()
+	for name, sink := range sinks {
+		sink.Calc()
+		values := make(map[string]float64)
+		switch sink := sink.(type) {
+		case *stats.TrendSink:
+			for stat, resolve := range resolvers {
+				values[stat] = resolve(sink)
+			}
+		case *stats.RateSink:
+			if sink.Total > 0 {
+				values["rate"] = float64(sink.Trues) / float64(sink.Total)
+			}
+		case *stats.CounterSink:
+			values["count"] = sink.Value
+			if duration > 0 {
+				values["rate"] = sink.Value / duration
+			}
+		case *stats.GaugeSink:
+			values["value"], values["min"], values["max"] = sink.Value, sink.Min, sink.Max
+		}
+		run.values[name] = values
+	}
+	return run, nil
+}
+
+// compareRuns compares the values of the metrics of both runs, and returns the names of the metrics
+// which are only in one of them.
+func compareRuns(a, b *comparedRun, opts compareOptions) (comparisons []comparison, onlyA, onlyB []string) {
+	selected := func(name string) bool {
+		if len(opts.metrics) == 0 {
+			return true
+		}
+		for _, m := range opts.metrics {
+			if m == name {
+				return true
+			}
+		}
+		return false
+	}
+	var names []string
+	for name := range a.values {
+		if !selected(name) {
+			continue
+		}
+		if _, ok := b.values[name]; ok {
+			names = append(names, name)
+		} else {
+			onlyA = append(onlyA, name)
+		}
+	}
+	for name := range b.values {
+		if _, ok := a.values[name]; !ok && selected(name) {
+			onlyB = append(onlyB, name)
+		}
+	}
+	sort.Strings(names)
+	sort.Strings(onlyA)
+	sort.Strings(onlyB)
+
+	z := math.Sqrt2 * math.Erfinv(opts.confidence)
+	for _, name := range names {
+		var compared []string
+		switch a.types[name] {
+		case stats.Trend, stats.Histogram:
+			compared = opts.stats
+		case stats.Rate:
+			compared = []string{"rate"}
+		case stats.Counter:
+			compared = []string{"count", "rate"}
+		case stats.Gauge:
+			compared = []string{"value"}
+		}
+		for _, stat := range compared {
+			va, okA := a.values[name][stat]
+			vb, okB := b.values[name][stat]
+			if !okA || !okB {
+				continue
+			}
+			c := comparison{metric: name, stat: stat, a: va, b: vb, pValue: math.NaN()}
+			c.significant, c.pValue = significance(a, b, name, stat, z)
+			c.regression, c.improvement = classifyChange(c, a.types[name], opts.tolerance)
+			comparisons = append(comparisons, c)
+		}
+	}
+	return comparisons, onlyA, onlyB
+}
+
+// significance tests whether the difference of a value in the runs is statistically significant,
+// when their samples are known. The p-value is NaN for the tests of the confidence intervals.
+func significance(a, b *comparedRun, name, stat string, z float64) (*bool, float64) {
+	if ta, tb := a.trends[name], b.trends[name]; ta != nil && tb != nil && ta.Count > 1 && tb.Count > 1 {
+		switch stat {
+		case "avg":
+			va, vb := variance(ta), variance(tb)
+			se := math.Sqrt(va/float64(ta.Count) + vb/float64(tb.Count))
+			return pValueSignificance(ta.Avg, tb.Avg, se, z)
+		case "med":
+			return percentileSignificance(ta, tb, 0.5, z), math.NaN()
+		default:
+			if strings.HasPrefix(stat, "p(") {
+				var p float64
+				if _, err := fmt.Sscanf(stat, "p(%g)", &p); err == nil {
+					return percentileSignificance(ta, tb, p/100, z), math.NaN()
+				}
+			}
+		}
+	}
+	ra, rb := a.rates[name], b.rates[name]
+	if stat == "rate" && ra != nil && rb != nil && ra.Total > 0 && rb.Total > 0 {
+		pa, pb := float64(ra.Trues)/float64(ra.Total), float64(rb.Trues)/float64(rb.Total)
+		pooled := float64(ra.Trues+rb.Trues) / float64(ra.Total+rb.Total)
+		se := math.Sqrt(pooled * (1 - pooled) * (1/float64(ra.Total) + 1/float64(rb.Total)))
+		return pValueSignificance(pa, pb, se, z)
+	}
+	return nil, math.NaN()
+}
+
+// pValueSignificance returns whether the difference of the values is significant with a two-sided z-test.
+func pValueSignificance(a, b, se, z float64) (*bool, float64) {
+	if se == 0 {
+		significant := a != b
+		if significant {
+			return &significant, 0
+		}
+		return &significant, 1
+	}
+	pValue := math.Erfc(math.Abs(b-a) / se / math.Sqrt2)
+	significant := math.Abs(b-a)/se > z
+	return &significant, pValue
+}
+
+// percentileSignificance returns whether the distribution-free confidence intervals of the percentile of the
+// trends, from the ranks of their values, don't overlap.
+func percentileSignificance(a, b *stats.TrendSink, p, z float64) *bool {
+	interval := func(t *stats.TrendSink) (float64, float64) {
+		n := float64(t.Count)
+		margin := z * math.Sqrt(n*p*(1-p))
+		lower := int(math.Max(0, math.Floor(n*p-margin)))
+		upper := int(math.Min(n-1, math.Ceil(n*p+margin)))
+		return t.Values[lower], t.Values[upper]
+	}
+	lowerA, upperA := interval(a)
+	lowerB, upperB := interval(b)
+	significant := upperA < lowerB || upperB < lowerA
+	return &significant
+}
+
+func variance(t *stats.TrendSink) float64 {
+	var sum float64
+	for _, v := range t.Values {
+		sum += (v - t.Avg) * (v - t.Avg)
+	}
+	return sum / float64(t.Count-1)
+}
+
+// classifyChange returns whether the change of a value is a regression or an improvement, which are changes
+// of the trend stats and the rates by more than the tolerance, which aren't known to be insignificant.
+func classifyChange(c comparison, typ stats.MetricType, tolerance float64) (regression, improvement bool) {
+	var higherIsWorse bool
+	switch typ {
+	case stats.Trend, stats.Histogram:
+		if c.stat == "count" {
+			return false, false
+		}
+		higherIsWorse = true
+	case stats.Rate:
+		name := strings.ToLower(c.metric)
+		higherIsWorse = strings.Contains(name, "fail") || strings.Contains(name, "error")
+	default:
+		return false, false
+	}
+	if c.a == c.b || (c.significant != nil && !*c.significant) {
+		return false, false
+	}
+	change := math.Inf(1)
+	if c.a != 0 {
+		change = math.Abs(c.b-c.a) / math.Abs(c.a) * 100
+	}
+	if change <= tolerance {
+		return false, false
+	}
+	worse := (c.b > c.a) == higherIsWorse
+	return worse, !worse
+}
+
+// printComparisons prints the comparisons as a table, and returns the count of the regressions.
+func printComparisons(w io.Writer, comparisons []comparison, opts compareOptions) (regressions int) {
+	rows := [][]string{{"METRIC", "STAT", "A", "B", "CHANGE", "SIGNIFICANT", ""}}
+	for _, c := range comparisons {
+		change := "-"
+		if c.a != 0 {
+			change = fmt.Sprintf("%+.2f%%", (c.b-c.a)/math.Abs(c.a)*100)
+		} else if c.b == 0 {
+			change = "+0.00%"
+		}
+		significant := "-"
+		if c.significant != nil {
+			significant = map[bool]string{true: "yes", false: "no"}[*c.significant]
+			if !math.IsNaN(c.pValue) {
+				significant += fmt.Sprintf(" (p=%.3f)", c.pValue)
+			}
+		}
+		result := ""
+		switch {
+		case c.regression:
+			result = "regression"
+			regressions++
+		case c.improvement:
+			result = "improvement"
+		}
+		rows = append(rows, []string{
+			c.metric, c.stat, formatComparedValue(c.a), formatComparedValue(c.b), change, significant, result,
+		})
+	}
+
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, cell := range row {
+			if len(cell) > widths[i] {
+				widths[i] = len(cell)
+			}
+		}
+	}
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = cell + strings.Repeat(" ", widths[i]-len(cell))
+		}
+		fprintf(w, "%s\n", strings.TrimRight(strings.Join(cells, "  "), " "))
+	}
+
+	if regressions > 0 {
+		fprintf(w, "\n%d regressions above the tolerance of %g%%\n", regressions, opts.tolerance)
+	} else {
+		fprintf(w, "\nNo regressions above the tolerance of %g%%\n", opts.tolerance)
+	}
+	return regressions
+}
+
+func formatComparedValue(v float64) string {
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.4f", v), "0"), ".")
+}
@@ -0,0 +1,171 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// jsonOutput returns a JSON output of a test run with a trend, a failure rate and a counter.
+func jsonOutput(durations []float64, failed []bool) string {
+	var buf bytes.Buffer
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	buf.WriteString(`{"type":"Metric","data":{"name":"http_req_duration","type":"trend","contains":"time"},` +
+		`"metric":"http_req_duration"}` + "\n")
+	buf.WriteString(`{"type":"Metric","data":{"name":"http_req_failed","type":"rate","contains":"default"},` +
+		`"metric":"http_req_failed"}` + "\n")
+	buf.WriteString(`{"type":"Metric","data":{"name":"iterations","type":"counter","contains":"default"},` +
+		`"metric":"iterations"}` + "\n")
+	for i, d := range durations {
+		ts := start.Add(time.Duration(i) * time.Second).Format(time.RFC3339Nano)
+		fail := 0
+		if failed[i] {
+			fail = 1
+		}
+		point := `{"type":"Point","data":{"time":%q,"value":%g,"tags":null},"metric":%q}` + "\n"
+		fmt.Fprintf(&buf, point, ts, d, "http_req_duration")
+		fmt.Fprintf(&buf, point, ts, float64(fail), "http_req_failed")
+		fmt.Fprintf(&buf, point, ts, 1.0, "iterations")
+	}
+	return buf.String()
+}
+
+func TestCompareRuns(t *testing.T) {
+	t.Parallel()
+
+	const n = 200
+	fast, slow, noisy := make([]float64, n), make([]float64, n), make([]float64, n)
+	succeeded, failed := make([]bool, n), make([]bool, n)
+	for i := 0; i < n; i++ {
+		fast[i] = 100 + float64(i%20)
+		slow[i] = 150 + float64(i%20)
+		noisy[i] = float64(i%20)*20 - 74
+		failed[i] = i%4 == 0
+	}
+
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "a.json", []byte(jsonOutput(fast, succeeded)), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "b.json", []byte(jsonOutput(slow, failed)), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "c.json", []byte(jsonOutput(noisy, succeeded)), 0o644))
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	_, err := gw.Write([]byte(jsonOutput(slow, failed)))
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+	require.NoError(t, afero.WriteFile(fs, "b.json.gz", gz.Bytes(), 0o644))
+
+	opts := compareOptions{stats: []string{"avg", "p(95)"}, tolerance: 5, confidence: 0.95}
+	read := func(filename string) *comparedRun {
+		run, err := readComparedRun(fs, filename, opts.stats)
+		require.NoError(t, err)
+		return run
+	}
+
+	t.Run("regressions", func(t *testing.T) {
+		t.Parallel()
+		for _, b := range []string{"b.json", "b.json.gz"} {
+			comparisons, onlyA, onlyB := compareRuns(read("a.json"), read(b), opts)
+			assert.Empty(t, onlyA)
+			assert.Empty(t, onlyB)
+			byStat := make(map[string]comparison)
+			for _, c := range comparisons {
+				byStat[c.metric+" "+c.stat] = c
+			}
+			require.Len(t, byStat, 5)
+
+			avg := byStat["http_req_duration avg"]
+			assert.InDelta(t, 109.5, avg.a, 0.001)
+			assert.InDelta(t, 159.5, avg.b, 0.001)
+			require.NotNil(t, avg.significant)
+			assert.True(t, *avg.significant)
+			assert.True(t, avg.regression)
+			assert.True(t, byStat["http_req_duration p(95)"].regression)
+
+			rate := byStat["http_req_failed rate"]
+			assert.Equal(t, 0.25, rate.b)
+			assert.True(t, rate.regression)
+
+			// the counters aren't regressions
+			count := byStat["iterations count"]
+			assert.Equal(t, float64(n), count.b)
+			assert.Nil(t, count.significant)
+			assert.False(t, count.regression)
+
+			var buf bytes.Buffer
+			assert.Equal(t, 3, printComparisons(&buf, comparisons, opts))
+			assert.Contains(t, buf.String(), "+45.66%")
+			assert.Contains(t, buf.String(), "3 regressions above the tolerance of 5%")
+		}
+	})
+
+	t.Run("improvements", func(t *testing.T) {
+		t.Parallel()
+		comparisons, _, _ := compareRuns(read("b.json"), read("a.json"), opts)
+		var buf bytes.Buffer
+		assert.Equal(t, 0, printComparisons(&buf, comparisons, opts))
+		assert.Contains(t, buf.String(), "improvement")
+		assert.Contains(t, buf.String(), "No regressions above the tolerance of 5%")
+	})
+
+	t.Run("insignificant", func(t *testing.T) {
+		t.Parallel()
+		// the values of c are more spread, with an average which is higher by 6%
+		comparisons, _, _ := compareRuns(read("a.json"), read("c.json"), compareOptions{
+			stats: []string{"avg"}, metrics: []string{"http_req_duration"}, tolerance: 5, confidence: 0.99,
+		})
+		require.Len(t, comparisons, 1)
+		require.NotNil(t, comparisons[0].significant)
+		assert.False(t, *comparisons[0].significant)
+		assert.False(t, comparisons[0].regression)
+	})
+
+	t.Run("summaries", func(t *testing.T) {
+		t.Parallel()
+		require.NoError(t, afero.WriteFile(fs, "a-summary.json", []byte(`{"metrics": {
+			"http_req_duration": {"avg": 100, "p(95)": 200},
+			"checks": {"passes": 99, "fails": 1, "value": 0.99},
+			"vus": {"value": 10, "min": 1, "max": 10}
+		}}`), 0o644))
+		require.NoError(t, afero.WriteFile(fs, "b-summary.json", []byte(`{"metrics": {
+			"http_req_duration": {"avg": 104, "p(95)": 180},
+			"checks": {"passes": 90, "fails": 10, "value": 0.9},
+			"data_sent": {"count": 1000, "rate": 100}
+		}}`), 0o644))
+		comparisons, onlyA, onlyB := compareRuns(read("a-summary.json"), read("b-summary.json"), opts)
+		assert.Equal(t, []string{"vus"}, onlyA)
+		assert.Equal(t, []string{"data_sent"}, onlyB)
+		require.Len(t, comparisons, 3)
+		// checks are worse when they are lower, and the significance of the summaries isn't known
+		assert.Equal(t, "checks", comparisons[0].metric)
+		assert.Nil(t, comparisons[0].significant)
+		assert.True(t, comparisons[0].regression)
+		assert.False(t, comparisons[1].regression)
+		assert.True(t, comparisons[2].improvement)
+	})
+}
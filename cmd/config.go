@@ -62,6 +62,23 @@ type Config struct {
 
 	// TODO: deprecate
 	Collectors map[string]json.RawMessage `json:"collectors"`
+
+	// Outputs are created along with the ones of Out, each with its own config.
+	Outputs []OutputConfig `json:"outputs"`
+
+	// Profiles are the named profiles of the config file, which are selected with --profile.
+	Profiles map[string]Profile `json:"profiles,omitempty"`
+}
+
+// OutputConfig is a named output of the config file, so several outputs of the same type can have different configs,
+// e.g. {"type": "json", "name": "errors", "arg": "errors.json", "config": {"includeMetrics": ["http_req_failed"]}}.
+// Its config replaces the one in the collectors and its arg is the one of --out type=arg, the env vars of the outputs
+// still apply to all the outputs of their type.
+type OutputConfig struct {
+	Type   string          `json:"type"`
+	Name   string          `json:"name"`
+	Arg    string          `json:"arg,omitempty"`
+	Config json.RawMessage `json:"config,omitempty"`
 }
 
 // Validate checks if all of the specified options make sense
@@ -88,6 +105,9 @@ func (c Config) Apply(cfg Config) Config {
 	if len(cfg.Collectors) > 0 {
 		c.Collectors = cfg.Collectors
 	}
+	if len(cfg.Outputs) > 0 {
+		c.Outputs = cfg.Outputs
+	}
 	return c
 }
 
@@ -171,6 +191,7 @@ func readEnvConfig(envMap map[string]string) (Config, error) {
 // - start with the CLI-provided options to get shadowed (non-Valid) defaults in there
 // - add the global file config options
 // - add the Runner-provided options (they may come from Bundle too if applicable)
+// - add the options of the profile selected with --profile, and its overrides of the scenarios
 // - add the environment variables
 // - merge the user-supplied CLI flags back in on top, to give them the greatest priority
 // - set some defaults if they weren't previously specified
@@ -185,6 +206,10 @@ func getConsolidatedConfig(
 	if err != nil {
 		return conf, err
 	}
+	profile, err := getProfile(fs, globalFlags)
+	if err != nil {
+		return conf, err
+	}
 	envConf, err := readEnvConfig(envMap)
 	if err != nil {
 		return conf, err
@@ -194,6 +219,11 @@ func getConsolidatedConfig(
 
 	conf = conf.Apply(Config{Options: runnerOpts})
 
+	conf = conf.Apply(profile.Config)
+	if conf.Scenarios, err = overrideScenarios(conf.Scenarios, profile.Scenarios); err != nil {
+		return conf, err
+	}
+
 	conf = conf.Apply(envConf).Apply(cliConf)
 	conf = applyDefault(conf)
 
@@ -205,6 +235,11 @@ func getConsolidatedConfig(
 	if _, err = stats.GetResolversForTrendColumns(conf.SummaryTrendStats); err != nil {
 		return conf, err
 	}
+	for name, trendStats := range conf.SummaryTrendStatsPerMetric {
+		if _, err = stats.GetResolversForTrendColumns(trendStats); err != nil {
+			return conf, fmt.Errorf("invalid summary trend stats of the metric %s: %w", name, err)
+		}
+	}
 
 	return conf, nil
 }
@@ -271,9 +306,20 @@ func consolidateErrorMessage(errList []error, title string) error {
 }
 
 func validateScenarioConfig(conf lib.ExecutorConfig, isExecutable func(string) bool) error {
-	execFn := conf.GetExec()
-	if !isExecutable(execFn) {
+	if weights := conf.GetExecWeights(); len(weights) > 0 {
+		for execFn := range weights {
+			if !isExecutable(execFn) {
+				return fmt.Errorf("executor %s: function '%s' not found in exports", conf.GetName(), execFn)
+			}
+		}
+	} else if execFn := conf.GetExec(); !isExecutable(execFn) {
 		return fmt.Errorf("executor %s: function '%s' not found in exports", conf.GetName(), execFn)
 	}
+	if setupFn, _ := conf.GetSetup(); setupFn != "" && !isExecutable(setupFn) {
+		return fmt.Errorf("executor %s: setup function '%s' not found in exports", conf.GetName(), setupFn)
+	}
+	if teardownFn, _ := conf.GetTeardown(); teardownFn != "" && !isExecutable(teardownFn) {
+		return fmt.Errorf("executor %s: teardown function '%s' not found in exports", conf.GetName(), teardownFn)
+	}
 	return nil
 }
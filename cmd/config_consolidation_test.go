@@ -285,6 +285,29 @@ func getConfigConsolidationTestCases() []configConsolidationTestCase {
 		{opts{fs: defaultConfig(`wrong-json`)}, exp{consolidationError: true}, nil},
 		{opts{fs: getFS(nil), cli: []string{"--config", "/my/config.file"}}, exp{consolidationError: true}, nil},
 
+		// Test if the profiles are applied on top of the config file and of the script options
+		{
+			opts{
+				fs: getFS([]file{{"/my/config.file", `{"vus": 2, "profiles": {
+					"base": {"vus": 8, "duration": "2m"}, "staging": {"extends": "base", "vus": 9}}}`}}),
+				runner: &lib.Options{VUs: null.IntFrom(5)},
+				cli:    []string{"--config", "/my/config.file", "--profile", "staging"},
+			}, exp{}, verifyConstLoopingVUs(I(9), 120*time.Second),
+		},
+		{
+			opts{
+				fs:  getFS([]file{{"/my/config.file", `{"profiles": {"staging": {"vus": 9, "duration": "2m"}}}`}}),
+				env: []string{"K6_VUS=11"},
+				cli: []string{"--config", "/my/config.file", "--profile", "staging"},
+			}, exp{}, verifyConstLoopingVUs(I(11), 120*time.Second),
+		},
+		{
+			opts{
+				fs:  getFS([]file{{"/my/config.file", `{"profiles": {"staging": {"vus": 9}}}`}}),
+				cli: []string{"--config", "/my/config.file", "--profile", "production"},
+			}, exp{consolidationError: true}, nil,
+		},
+
 		// Test combinations between options and levels
 		{opts{cli: []string{"--vus", "1"}}, exp{}, verifyOneIterPerOneVU},
 		{opts{cli: []string{"--vus", "10"}}, exp{logWarning: true}, verifyOneIterPerOneVU},
@@ -434,6 +457,19 @@ func getConfigConsolidationTestCases() []configConsolidationTestCase {
 				assert.Equal(t, []string{"avg", "p(90)", "count"}, c.Options.SummaryTrendStats)
 			},
 		},
+		{
+			opts{runner: &lib.Options{SummaryTrendStatsPerMetric: map[string][]string{"my_trend": {"p(99.9)"}}}},
+			exp{},
+			func(t *testing.T, c Config) {
+				assert.Equal(t, []string{"p(99.9)"}, c.Options.GetSummaryTrendStats("my_trend"))
+				assert.Equal(t, lib.DefaultSummaryTrendStats, c.Options.GetSummaryTrendStats("other_trend"))
+			},
+		},
+		{
+			opts{runner: &lib.Options{SummaryTrendStatsPerMetric: map[string][]string{"my_trend": {"p(101)"}}}},
+			exp{consolidationError: true},
+			nil,
+		},
 		{opts{cli: []string{}}, exp{}, func(t *testing.T, c Config) {
 			assert.Equal(t, types.DNSConfig{
 				TTL:    null.NewString("5m", false),
@@ -173,7 +173,8 @@ func TestDeriveAndValidateConfig(t *testing.T) {
 			"nonDefaultOK", Config{Options: lib.Options{Scenarios: lib.ScenarioConfigs{
 				"per_vu_iters": executor.PerVUIterationsConfig{
 					BaseConfig: executor.BaseConfig{
-						Name: "per_vu_iters", Type: "per-vu-iterations", Exec: null.StringFrom("nonDefault"),
+						Name: "per_vu_iters", Type: "per-vu-iterations",
+						Exec: executor.ExecFunctions{Name: null.StringFrom("nonDefault")},
 					},
 					VUs:         null.IntFrom(1),
 					Iterations:  null.IntFrom(1),
@@ -186,7 +187,8 @@ func TestDeriveAndValidateConfig(t *testing.T) {
 			Config{Options: lib.Options{Scenarios: lib.ScenarioConfigs{
 				"per_vu_iters": executor.PerVUIterationsConfig{
 					BaseConfig: executor.BaseConfig{
-						Name: "per_vu_iters", Type: "per-vu-iterations", Exec: null.StringFrom("nonDefaultErr"),
+						Name: "per_vu_iters", Type: "per-vu-iterations",
+						Exec: executor.ExecFunctions{Name: null.StringFrom("nonDefaultErr")},
 					},
 					VUs:         null.IntFrom(1),
 					Iterations:  null.IntFrom(1),
@@ -21,7 +21,9 @@
 package cmd
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"path/filepath"
@@ -31,6 +33,7 @@ import (
 	"gopkg.in/guregu/null.v3"
 
 	"go.k6.io/k6/converter/har"
+	"go.k6.io/k6/converter/postman"
 	"go.k6.io/k6/lib"
 )
 
@@ -48,15 +51,24 @@ func getConvertCmd(defaultFs afero.Fs, defaultWriter io.Writer) *cobra.Command {
 		nobatch             bool
 		only                []string
 		skip                []string
+		environmentPath     string
 	)
 	convertCmd := &cobra.Command{
 		Use:   "convert",
-		Short: "Convert a HAR file to a k6 script",
-		Long:  "Convert a HAR (HTTP Archive) file to a k6 script",
+		Short: "Convert a HAR file or a Postman collection to a k6 script",
+		Long: `Convert a HAR (HTTP Archive) file or a Postman collection to a k6 script.
+
+The Postman collections need to be in the v2.1 format. Their folders are converted to groups, and their
+pre-request and test scripts are translated where they can be, the code that can't be translated is kept
+in comments. The variables of the collection, and of its environment, can be overridden with the
+environment variables of the same names.`,
 		Example: `
   # Convert a HAR file to a k6 script.
   k6 convert -O har-session.js session.har
 
+  # Convert a Postman collection, with the variables of an environment, to a k6 script.
+  k6 convert -O collection.js --environment staging.postman_environment.json collection.json
+
   # Convert a HAR file to a k6 script creating requests only for the given domain/s.
   k6 convert -O har-session.js --only yourdomain.com,additionaldomain.com session.har
 
@@ -67,20 +79,34 @@ func getConvertCmd(defaultFs afero.Fs, defaultWriter io.Writer) *cobra.Command {
   k6 run har-session.js`[1:],
 		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			// Parse the HAR file
 			filePath, err := filepath.Abs(args[0])
 			if err != nil {
 				return err
 			}
-			r, err := defaultFs.Open(filePath)
+			data, err := afero.ReadFile(defaultFs, filePath)
 			if err != nil {
 				return err
 			}
-			h, err := har.Decode(r)
-			if err != nil {
-				return err
+			if postman.IsCollection(data) {
+				for _, name := range harFlags {
+					if cmd.Flags().Changed(name) {
+						return fmt.Errorf("the --%s flag is only for the HAR files, not for the Postman collections",
+							name)
+					}
+				}
+				script, err := convertPostman(defaultFs, data, environmentPath)
+				if err != nil {
+					return err
+				}
+				return writeConvertedScript(defaultFs, defaultWriter, convertOutput, script)
+			}
+			if environmentPath != "" {
+				return fmt.Errorf("the --environment flag is only for the Postman collections, not for the HAR files")
 			}
-			if err = r.Close(); err != nil {
+
+			// Parse the HAR file
+			h, err := har.Decode(bytes.NewReader(data))
+			if err != nil {
 				return err
 			}
 
@@ -106,27 +132,7 @@ func getConvertCmd(defaultFs afero.Fs, defaultWriter io.Writer) *cobra.Command {
 				return err
 			}
 
-			// Write script content to stdout or file
-			if convertOutput == "" || convertOutput == "-" { //nolint:nestif
-				if _, err := io.WriteString(defaultWriter, script); err != nil {
-					return err
-				}
-			} else {
-				f, err := defaultFs.Create(convertOutput)
-				if err != nil {
-					return err
-				}
-				if _, err := f.WriteString(script); err != nil {
-					return err
-				}
-				if err := f.Sync(); err != nil {
-					return err
-				}
-				if err := f.Close(); err != nil {
-					return err
-				}
-			}
-			return nil
+			return writeConvertedScript(defaultFs, defaultWriter, convertOutput, script)
 		},
 	}
 
@@ -139,6 +145,10 @@ func getConvertCmd(defaultFs afero.Fs, defaultWriter io.Writer) *cobra.Command {
 		&optionsFilePath, "options", "", optionsFilePath,
 		"path to a JSON file with options that would be injected in the output script",
 	)
+	convertCmd.Flags().StringVarP(
+		&environmentPath, "environment", "", environmentPath,
+		"path to a Postman environment with the variables of the collection",
+	)
 	convertCmd.Flags().StringSliceVarP(&only, "only", "", []string{}, "include only requests from the given domains")
 	convertCmd.Flags().StringSliceVarP(&skip, "skip", "", []string{}, "skip requests from the given domains")
 	convertCmd.Flags().UintVarP(&threshold, "batch-threshold", "", 500, "batch request idle time threshold (see example)")
@@ -150,3 +160,49 @@ func getConvertCmd(defaultFs afero.Fs, defaultWriter io.Writer) *cobra.Command {
 	convertCmd.Flags().UintVarP(&maxSleep, "max-sleep", "", 40, "the maximum amount of seconds to sleep after each iteration")                                                                                    //nolint:lll
 	return convertCmd
 }
+
+// harFlags are the flags of the convert command which are only for the HAR files.
+var harFlags = []string{ //nolint:gochecknoglobals
+	"options", "only", "skip", "batch-threshold", "no-batch", "enable-status-code-checks", "return-on-failed-check",
+	"correlate", "min-sleep", "max-sleep",
+}
+
+// convertPostman converts the Postman collection in the data, with the variables of the environment in the
+// file, if there's one.
+func convertPostman(fs afero.Fs, data []byte, environmentPath string) (string, error) {
+	c, err := postman.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	var env *postman.Environment
+	if environmentPath != "" {
+		f, err := fs.Open(environmentPath)
+		if err != nil {
+			return "", err
+		}
+		defer func() { _ = f.Close() }()
+		if env, err = postman.DecodeEnvironment(f); err != nil {
+			return "", fmt.Errorf("couldn't decode the Postman environment: %w", err)
+		}
+	}
+	return postman.Convert(c, env)
+}
+
+// writeConvertedScript writes the script to the output file, or to stdout when there's none.
+func writeConvertedScript(fs afero.Fs, stdout io.Writer, output, script string) error {
+	if output == "" || output == "-" {
+		_, err := io.WriteString(stdout, script)
+		return err
+	}
+	f, err := fs.Create(output)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(script); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	return f.Close()
+}
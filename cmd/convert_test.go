@@ -210,6 +210,31 @@ func TestIntegrationConvertCmd(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, testHARConvertResult, string(output))
 	})
+	t.Run("Postman collection", func(t *testing.T) {
+		t.Parallel()
+		collection := `{
+			"info": {"name": "API", "schema": "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"},
+			"variable": [{"key": "baseUrl", "value": "https://test.k6.io"}],
+			"item": [{"name": "Home", "request": {"method": "GET", "url": "{{baseUrl}}/"}}]
+		}`
+		environment := `{"name": "Staging", "values": [{"key": "baseUrl", "value": "https://staging.k6.io"}]}`
+		defaultFs := afero.NewMemMapFs()
+		require.NoError(t, afero.WriteFile(defaultFs, "/collection.json", []byte(collection), 0o644))
+		require.NoError(t, afero.WriteFile(defaultFs, "/environment.json", []byte(environment), 0o644))
+
+		buf := &bytes.Buffer{}
+		convertCmd := getConvertCmd(defaultFs, buf)
+		require.NoError(t, convertCmd.Flags().Set("environment", "/environment.json"))
+		require.NoError(t, convertCmd.RunE(convertCmd, []string{"/collection.json"}))
+		assert.Contains(t, buf.String(), "baseUrl: __ENV.baseUrl || 'https://staging.k6.io',\n")
+		assert.Contains(t, buf.String(), "\t// Home\n\tres = http.get(`${vars.baseUrl}/`);\n")
+
+		convertCmd = getConvertCmd(defaultFs, buf)
+		require.NoError(t, convertCmd.Flags().Set("no-batch", "true"))
+		err := convertCmd.RunE(convertCmd, []string{"/collection.json"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "the --no-batch flag is only for the HAR files")
+	})
 	// TODO: test options injection; right now that's difficult because when there are multiple
 	// options, they can be emitted in different order in the JSON
 }
@@ -0,0 +1,278 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"go.k6.io/k6/core"
+	"go.k6.io/k6/core/distributed"
+	"go.k6.io/k6/errext"
+	"go.k6.io/k6/errext/exitcodes"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/stats"
+)
+
+// coordinatorFlags are the flags specific to the coordinator command.
+type coordinatorFlags struct {
+	instances        int
+	listen           string
+	agentTimeout     time.Duration
+	onAgentFailure   string
+	aggregateMetrics bool
+}
+
+//nolint:funlen,gocognit,cyclop
+func getCoordinatorCmd(ctx context.Context, logger *logrus.Logger, globalFlags *commandFlags) *cobra.Command {
+	flags := &coordinatorFlags{
+		instances:      1,
+		listen:         "localhost:6566",
+		agentTimeout:   10 * time.Second,
+		onAgentFailure: string(distributed.FailurePolicyAbort),
+	}
+	coordinatorCmd := &cobra.Command{
+		Use:   "coordinator",
+		Short: "Coordinate a distributed test run",
+		Long: `Coordinate a distributed test run.
+
+The coordinator splits the test into equal execution segments, one for each
+instance, and waits for that many k6 agents to connect to it. It sends the test
+archive and an execution segment to each agent, synchronizes the start and the
+end of the test between them and aggregates all of their metrics, so that the
+thresholds, the outputs and the end-of-test summary cover the whole test run.
+
+An agent which finishes with an error, or doesn't send a heartbeat within the
+agent timeout, is failed. With the "abort" failure policy, the test run is then
+aborted on all agents. With "continue", the other agents continue the test run,
+and if it hasn't started yet, the execution segment of the failed agent is
+given to the next agent which connects.
+
+The thresholds and the summary are evaluated by the coordinator, over the
+metric samples of all agents, so percentiles are exact for the whole test run.
+With --aggregate-metrics, the agents send only a few samples for each counter,
+gauge and rate with the same tags every second. The thresholds and the summary
+stay the same, only the outputs of the coordinator receive fewer samples.`,
+		Example: `
+  # Run the test with 2 agents, started with "k6 agent --coordinator-address host:6566"
+  k6 coordinator --instances 2 --listen 0.0.0.0:6566 script.js`[1:],
+		Args: exactArgsWithMsg(1, "arg should be a path to a script file or an archive"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			src, filesystems, err := readSource(args[0], logger)
+			if err != nil {
+				return err
+			}
+
+			profile, err := getProfile(afero.NewOsFs(), globalFlags)
+			if err != nil {
+				return err
+			}
+			osEnvironment := buildEnvMap(os.Environ())
+			runtimeOptions, err := getRuntimeOptions(cmd.Flags(), osEnvironment, profile)
+			if err != nil {
+				return err
+			}
+
+			registry := metrics.NewRegistry()
+			builtinMetrics := metrics.RegisterBuiltinMetrics(registry)
+			initRunner, err := newRunner(
+				logger, src, globalFlags.runType, filesystems, runtimeOptions, builtinMetrics, registry)
+			if err != nil {
+				return common.UnwrapGojaInterruptedError(err)
+			}
+
+			cliConf, err := getConfig(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			conf, err := getConsolidatedConfig(
+				afero.NewOsFs(), cliConf, initRunner.GetOptions(), osEnvironment, globalFlags)
+			if err != nil {
+				return err
+			}
+			if !runtimeOptions.NoThresholds.Bool {
+				for _, thresholds := range conf.Options.Thresholds {
+					if err = thresholds.Parse(); err != nil {
+						return errext.WithExitCodeIfNone(err, exitcodes.InvalidConfig)
+					}
+				}
+			}
+			if conf.ExecutionSegment != nil || conf.ExecutionSegmentSequence != nil {
+				return errext.WithExitCodeIfNone(
+					errors.New("the execution segments of a distributed test are set by the coordinator"),
+					exitcodes.InvalidConfig)
+			}
+			conf, err = deriveAndValidateConfig(conf, initRunner.IsExecutable, logger)
+			if err != nil {
+				return err
+			}
+			if err = initRunner.SetOptions(conf.Options); err != nil {
+				return err
+			}
+
+			archive := &bytes.Buffer{}
+			if err = initRunner.MakeArchive().Write(archive); err != nil {
+				return err
+			}
+			coordinator, err := distributed.NewCoordinator(archive.Bytes(), distributed.CoordinatorConfig{
+				Instances:        flags.instances,
+				AgentTimeout:     flags.agentTimeout,
+				FailurePolicy:    distributed.FailurePolicy(flags.onAgentFailure),
+				AggregateMetrics: flags.aggregateMetrics,
+			}, registry, logger)
+			if err != nil {
+				return errext.WithExitCodeIfNone(err, exitcodes.InvalidConfig)
+			}
+			execScheduler, err := distributed.NewExecutionScheduler(coordinator, initRunner, logger)
+			if err != nil {
+				return err
+			}
+
+			globalCtx, globalCancel := context.WithCancel(ctx)
+			defer globalCancel()
+			runCtx, runCancel := context.WithCancel(globalCtx)
+			defer runCancel()
+
+			executionPlan := execScheduler.GetExecutionPlan()
+			outputs, err := createOutputs(conf.Out, src, conf, runtimeOptions, executionPlan, osEnvironment,
+				afero.NewOsFs(), logger, globalFlags)
+			if err != nil {
+				return err
+			}
+			engine, err := core.NewEngine(execScheduler, conf.Options, runtimeOptions, outputs, logger, builtinMetrics)
+			if err != nil {
+				return err
+			}
+			if err = engine.StartOutputs(); err != nil {
+				return err
+			}
+			defer engine.StopOutputs()
+
+			listener, err := net.Listen("tcp", flags.listen)
+			if err != nil {
+				return err
+			}
+			go func() {
+				if serr := coordinator.Serve(listener); serr != nil {
+					logger.WithError(serr).Error("Error from the coordinator server")
+				}
+			}()
+			defer coordinator.Stop()
+
+			et, err := lib.NewExecutionTuple(nil, nil)
+			if err != nil {
+				return err
+			}
+			printExecutionDescription(
+				"distributed", args[0], "", conf, et, executionPlan, outputs,
+				globalFlags.noColor || !globalFlags.stdoutTTY, globalFlags)
+			logger.Infof("Waiting for %d agents to connect to %s...", flags.instances, listener.Addr())
+
+			// The first signal aborts the test run on all agents, the second one
+			// exits immediately.
+			sigC := make(chan os.Signal, 1)
+			signal.Notify(sigC, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+			defer signal.Stop(sigC)
+			go func() {
+				sig := <-sigC
+				logger.WithField("sig", sig).Debug("Stopping the distributed test run in response to signal...")
+				runCancel()
+				sig = <-sigC
+				logger.WithField("sig", sig).Error("Aborting k6 in response to signal")
+				globalCancel()
+				os.Exit(int(exitcodes.ExternalAbort))
+			}()
+
+			engineRun, engineWait, err := engine.Init(globalCtx, runCtx)
+			if err != nil {
+				return errext.WithExitCodeIfNone(err, exitcodes.GenericEngine)
+			}
+			runErr := engineRun()
+			runCancel()
+
+			if !runtimeOptions.NoSummary.Bool {
+				summaryResult, err := initRunner.HandleSummary(globalCtx, &lib.Summary{
+					Metrics:         engine.Metrics,
+					RootGroup:       initRunner.GetDefaultGroup(),
+					TestRunDuration: execScheduler.GetState().GetCurrentTestRunDuration(),
+					NoColor:         globalFlags.noColor,
+					UIState: lib.UIState{
+						IsStdOutTTY: globalFlags.stdoutTTY,
+						IsStdErrTTY: globalFlags.stderrTTY,
+					},
+					TagCardinalityOffenders: engine.TagCardinalityOffenders(),
+				})
+				if err == nil {
+					err = handleSummaryResult(afero.NewOsFs(), globalFlags.stdout, globalFlags.stderr, summaryResult)
+				}
+				if err != nil {
+					logger.WithError(err).Error("failed to handle the end-of-test summary")
+				}
+			}
+
+			globalCancel()
+			engineWait()
+			if runErr != nil {
+				return errext.WithExitCodeIfNone(runErr, exitcodes.GenericEngine)
+			}
+			if warnings := engine.FailedWarnThresholds(); len(warnings) > 0 {
+				logger.Warnf("some thresholds with the %s severity have failed: %s",
+					stats.ThresholdSeverityWarn, strings.Join(warnings, ", "))
+			}
+			if engine.IsTainted() {
+				return errext.WithExitCodeIfNone(errors.New("some thresholds have failed"), engine.ThresholdsExitCode())
+			}
+			return nil
+		},
+	}
+
+	coordinatorCmd.Flags().SortFlags = false
+	coordinatorCmd.Flags().AddFlagSet(coordinatorCmdFlagSet(flags, globalFlags))
+
+	return coordinatorCmd
+}
+
+func coordinatorCmdFlagSet(flags *coordinatorFlags, globalFlags *commandFlags) *pflag.FlagSet {
+	flagSet := runCmdFlagSet(globalFlags)
+	flagSet.IntVar(&flags.instances, "instances", flags.instances, "number of agents that run the test")
+	flagSet.StringVar(&flags.listen, "listen", flags.listen, "address on which the coordinator listens for agents")
+	flagSet.DurationVar(&flags.agentTimeout, "agent-timeout", flags.agentTimeout,
+		"time without a heartbeat after which an agent is considered lost")
+	flagSet.StringVar(&flags.onAgentFailure, "on-agent-failure", flags.onAgentFailure,
+		"what to do when an agent fails, \"abort\" or \"continue\" the test run")
+	flagSet.BoolVar(&flags.aggregateMetrics, "aggregate-metrics", flags.aggregateMetrics,
+		"make the agents aggregate the samples of counters, gauges and rates before sending them")
+	return flagSet
+}
@@ -0,0 +1,96 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+
+	"go.k6.io/k6/converter/openapi"
+)
+
+func getGenerateCmd() *cobra.Command {
+	generateCmd := &cobra.Command{
+		Use:   "generate",
+		Short: "Generate a k6 script",
+		Long: `Generate a k6 script.
+
+The scripts are starting points for the tests, which are generated from the
+descriptions of the tested services.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Usage()
+		},
+	}
+	return generateCmd
+}
+
+func getGenerateOpenAPICmd(globalFlags *commandFlags) *cobra.Command {
+	var output string
+	var opts openapi.Options
+	generateOpenAPICmd := &cobra.Command{
+		Use:   "openapi",
+		Short: "Generate a k6 script from an OpenAPI specification",
+		Long: `Generate a k6 script from an OpenAPI specification.
+
+The specification is an OpenAPI 3 or a Swagger 2 one, in YAML or JSON. The
+script has a group for each operation, which makes its request with:
+
+  - the examples of its parameters, or values of their types, in variables
+  - the example of its body, or one made from its schema
+  - the credentials of its security scheme, from the environment variables
+  - a tag with its path, for the metrics of the paths with parameters
+  - a check of its successful status
+
+The base URL of the requests is the one of the specification, which can be
+changed with the BASE_URL environment variable.`,
+		Example: `
+  # Generate a script from a specification.
+  k6 generate openapi -O script.js openapi.yaml
+
+  # Generate the requests of the operations with a tag, and run them on another server.
+  k6 generate openapi --tag pets -O script.js openapi.yaml
+  k6 run -e BASE_URL=https://staging.example.com script.js`[1:],
+		Args: exactArgsWithMsg(1, "arg should be an OpenAPI specification file"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fs := afero.NewOsFs()
+			data, err := afero.ReadFile(fs, args[0])
+			if err != nil {
+				return err
+			}
+			script, err := openapi.Generate(data, opts)
+			if err != nil {
+				return err
+			}
+			if output == "" || output == "-" {
+				fprintf(globalFlags.stdout, "%s", script)
+				return nil
+			}
+			return afero.WriteFile(fs, output, []byte(script), 0o644)
+		},
+	}
+
+	generateOpenAPICmd.Flags().SortFlags = false
+	generateOpenAPICmd.Flags().StringVarP(&output, "output", "O", "", "k6 script output filename (stdout by default)")
+	generateOpenAPICmd.Flags().StringSliceVar(&opts.Tags, "tag", nil,
+		"generate only the requests of the operations with the given tags")
+
+	return generateOpenAPICmd
+}
@@ -52,7 +52,11 @@ func getInspectCmd(logger *logrus.Logger, globalFlags *commandFlags) *cobra.Comm
 				return err
 			}
 
-			runtimeOptions, err := getRuntimeOptions(cmd.Flags(), buildEnvMap(os.Environ()))
+			profile, err := getProfile(afero.NewOsFs(), globalFlags)
+			if err != nil {
+				return err
+			}
+			runtimeOptions, err := getRuntimeOptions(cmd.Flags(), buildEnvMap(os.Environ()), profile)
 			if err != nil {
 				return err
 			}
@@ -0,0 +1,282 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"go.k6.io/k6/errext"
+	"go.k6.io/k6/errext/exitcodes"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/compiler"
+	"go.k6.io/k6/js/lint"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/fsext"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/stats"
+)
+
+func getLintCmd(globalFlags *commandFlags) *cobra.Command {
+	lintCmd := &cobra.Command{
+		Use:   "lint",
+		Short: "Find problems in a script without running it",
+		Long: `Find problems in a script without running it.
+
+The script is loaded like with k6 run, which resolves its imports and runs its
+init context, but no VU code is run, so no requests are made. Then k6 lint
+reports:
+
+  - the errors of the script, of its imports and of its options, which are
+    consolidated with the same flags, environment variables and config file
+    as k6 run, with the warnings about the unknown options
+  - the thresholds on metrics which are neither built-in metrics nor declared
+    in the init context, or which refer to such metrics
+  - the blocking calls, like sleep() or http.get(), in the callbacks which are
+    run asynchronously, like the ones of setTimeout() or of the promises
+  - the queries of metrics which don't exist
+
+k6 lint exits with an error if any errors are found, the warnings don't fail it.`,
+		Example: `
+  # Check a script before running it.
+  k6 lint script.js
+
+  # Check it with the options of its run.
+  k6 lint --vus 10 --duration 30s script.js`[1:],
+		Args: exactArgsWithMsg(1,
+			"arg should either be \"-\", if reading script from stdin, or a path to a script or archive file"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			problems := lintScript(cmd.Flags(), args[0], globalFlags)
+			if errors := printLintProblems(globalFlags.stdout, problems); errors > 0 {
+				return errext.WithExitCodeIfNone(
+					fmt.Errorf("found %d errors in the script", errors), exitcodes.ScriptLintFailed)
+			}
+			return nil
+		},
+	}
+
+	lintCmd.Flags().SortFlags = false
+	lintCmd.Flags().AddFlagSet(runCmdFlagSet(globalFlags))
+
+	return lintCmd
+}
+
+// lintLogHook collects the warnings which are logged while a script is loaded, e.g. about its unknown options.
+type lintLogHook struct {
+	filename string
+	mu       sync.Mutex
+	problems []lint.Problem
+}
+
+func (h *lintLogHook) Levels() []logrus.Level {
+	return []logrus.Level{logrus.WarnLevel}
+}
+
+func (h *lintLogHook) Fire(e *logrus.Entry) error {
+	if e.Data["source"] == "console" {
+		return nil
+	}
+	message := e.Message
+	if err, ok := e.Data[logrus.ErrorKey].(error); ok {
+		message += ": " + err.Error()
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.problems = append(h.problems, lint.Problem{
+		Filename: h.filename, Severity: lint.SeverityWarning, Message: message,
+	})
+	return nil
+}
+
+// lintScript loads the script and returns its problems, it stops at the first error which prevents
+// the rest of the checks.
+func lintScript(flags *pflag.FlagSet, filename string, globalFlags *commandFlags) []lint.Problem {
+	hook := &lintLogHook{filename: filename}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	logger.SetLevel(logrus.WarnLevel)
+	logger.AddHook(hook)
+	problems := func(err error) []lint.Problem {
+		hook.mu.Lock()
+		defer hook.mu.Unlock()
+		if err == nil {
+			return hook.problems
+		}
+		return append(hook.problems, lint.Problem{
+			Filename: filename, Severity: lint.SeverityError, Message: strings.TrimSpace(err.Error()),
+		})
+	}
+
+	src, filesystems, err := readSource(filename, logger)
+	if err != nil {
+		return problems(err)
+	}
+	profile, err := getProfile(afero.NewOsFs(), globalFlags)
+	if err != nil {
+		return problems(err)
+	}
+	osEnvironment := buildEnvMap(os.Environ())
+	runtimeOptions, err := getRuntimeOptions(flags, osEnvironment, profile)
+	if err != nil {
+		return problems(err)
+	}
+	registry := metrics.NewRegistry()
+	builtinMetrics := metrics.RegisterBuiltinMetrics(registry)
+	runner, err := newRunner(logger, src, globalFlags.runType, filesystems, runtimeOptions, builtinMetrics, registry)
+	if err != nil {
+		return problems(common.UnwrapGojaInterruptedError(err))
+	}
+
+	cliConf, err := getConfig(flags)
+	if err != nil {
+		return problems(err)
+	}
+	conf, err := getConsolidatedConfig(afero.NewOsFs(), cliConf, runner.GetOptions(), osEnvironment, globalFlags)
+	if err != nil {
+		return problems(err)
+	}
+	if _, err = deriveAndValidateConfig(conf, runner.IsExecutable, logger); err != nil {
+		return problems(err)
+	}
+
+	result := problems(nil)
+	if !runtimeOptions.NoThresholds.Bool {
+		result = append(result, lintThresholds(filename, conf.Thresholds, registry)...)
+	}
+	return append(result, lintScriptFiles(filename, runner.MakeArchive(), registry, logger)...)
+}
+
+// lintThresholds returns the errors of the thresholds, and the thresholds on metrics which don't exist or
+// which refer to metrics which don't exist.
+func lintThresholds(
+	filename string, thresholds map[string]stats.Thresholds, registry *metrics.Registry,
+) []lint.Problem {
+	names := make([]string, 0, len(thresholds))
+	for name := range thresholds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var problems []lint.Problem
+	addProblem := func(format string, a ...interface{}) {
+		problems = append(problems, lint.Problem{
+			Filename: filename, Severity: lint.SeverityError, Message: fmt.Sprintf(format, a...),
+		})
+	}
+	for _, name := range names {
+		ths := thresholds[name]
+		if err := ths.Parse(); err != nil {
+			addProblem("%s", err)
+			continue
+		}
+		if parent, _ := stats.NewSubmetric(name); registry.Get(parent) == nil {
+			addProblem("the thresholds on '%s' are for the metric '%s', "+
+				"which is neither a built-in metric nor declared in the init context", name, parent)
+		}
+		for _, ref := range ths.References() {
+			if parent, _ := stats.NewSubmetric(ref); registry.Get(parent) == nil {
+				addProblem("the thresholds on '%s' refer to the metric '%s', "+
+					"which is neither a built-in metric nor declared in the init context", name, parent)
+			}
+		}
+	}
+	return problems
+}
+
+// lintScriptFiles analyzes the sources of the script and of its local imports.
+func lintScriptFiles(
+	filename string, arc *lib.Archive, registry *metrics.Registry, logger logrus.FieldLogger,
+) []lint.Problem {
+	c := compiler.New(logger)
+	metricExists := func(name string) bool { return registry.Get(name) != nil }
+	var problems []lint.Problem
+	analyze := func(name string, data []byte) {
+		fileProblems, err := lint.Analyze(c, name, string(data), metricExists)
+		if err != nil {
+			fileProblems = []lint.Problem{{Filename: name, Severity: lint.SeverityError, Message: err.Error()}}
+		}
+		sort.SliceStable(fileProblems, func(i, j int) bool { return fileProblems[i].Line < fileProblems[j].Line })
+		problems = append(problems, fileProblems...)
+	}
+	pwd, _ := os.Getwd()
+	relativeName := func(path string) string {
+		if rel, err := filepath.Rel(pwd, filepath.FromSlash(path)); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+		return path
+	}
+	// the lines of the main script of an archive are the ones of the script in it
+	if arc.FilenameURL.Scheme == "file" && filename != "-" {
+		filename = relativeName(arc.FilenameURL.Path)
+	}
+	analyze(filename, arc.Data)
+
+	fs, ok := arc.Filesystems["file"]
+	if !ok {
+		return problems
+	}
+	if cachedFs, ok := fs.(fsext.CacheLayerGetter); ok {
+		fs = cachedFs.GetCachingFs()
+	}
+	_ = fsext.Walk(fs, afero.FilePathSeparator, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.ToSlash(path) == arc.FilenameURL.Path {
+			return nil
+		}
+		switch filepath.Ext(path) {
+		case ".js", ".mjs", ".cjs":
+		default:
+			return nil
+		}
+		data, err := afero.ReadFile(fs, path)
+		if err != nil {
+			return nil //nolint:nilerr // the files which were loaded can be read
+		}
+		analyze(relativeName(path), data)
+		return nil
+	})
+	return problems
+}
+
+// printLintProblems prints the problems and their counts, and returns the count of the errors.
+func printLintProblems(w io.Writer, problems []lint.Problem) (errors int) {
+	for _, p := range problems {
+		fprintf(w, "%s\n", p)
+		if p.Severity == lint.SeverityError {
+			errors++
+		}
+	}
+	if len(problems) == 0 {
+		fprintf(w, "No problems found\n")
+		return 0
+	}
+	fprintf(w, "\n%d problems (%d errors, %d warnings)\n", len(problems), errors, len(problems)-errors)
+	return errors
+}
@@ -0,0 +1,115 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/js/lint"
+)
+
+func TestLintScript(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	script := filepath.Join(dir, "script.js")
+	require.NoError(t, os.WriteFile(script, []byte(`
+import { sleep } from "k6";
+import { Trend } from "k6/metrics";
+import { later } from "./lib.js";
+const trend = new Trend("my_trend");
+export const options = {
+	iterations: 1,
+	unknown: 1,
+	thresholds: {
+		"my_trend{status:200}": ["p(95)<100", "avg < other_trend.avg"],
+		"missing": ["count>1"],
+	},
+};
+export default function() {
+	later();
+	Promise.resolve().then(() => sleep(1));
+}`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "lib.js"), []byte(`
+import http from "k6/http";
+export function later() {
+	setTimeout(() => http.get("https://test.k6.io"), 10);
+}`), 0o600))
+
+	globalFlags := &commandFlags{}
+	flags := runCmdFlagSet(globalFlags)
+	require.NoError(t, flags.Parse(nil))
+	problems := lintScript(flags, script, globalFlags)
+
+	lines := make([]string, 0, len(problems))
+	for _, p := range problems {
+		lines = append(lines, p.String())
+	}
+	assert.Equal(t, []string{
+		script + `: warning: There were unknown fields in the options exported in the script: ` +
+			`json: unknown field "unknown"`,
+		script + `: error: the thresholds on 'missing' are for the metric 'missing', ` +
+			`which is neither a built-in metric nor declared in the init context`,
+		script + `: error: the thresholds on 'my_trend{status:200}' refer to the metric 'other_trend', ` +
+			`which is neither a built-in metric nor declared in the init context`,
+		script + `:16: warning: sleep() blocks the event loop in the callback of .then(), which runs asynchronously`,
+		filepath.Join(dir, "lib.js") + `:4: warning: http.get() blocks the event loop in the callback of ` +
+			`setTimeout(), which runs asynchronously`,
+	}, lines)
+
+	var out bytes.Buffer
+	assert.Equal(t, 2, printLintProblems(&out, problems))
+	assert.Contains(t, out.String(), "\n5 problems (2 errors, 3 warnings)\n")
+}
+
+func TestLintScriptErrors(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	script := filepath.Join(dir, "script.js")
+	require.NoError(t, os.WriteFile(script, []byte(`
+import "./missing.js";
+export default function() {}`), 0o600))
+
+	globalFlags := &commandFlags{}
+	flags := runCmdFlagSet(globalFlags)
+	require.NoError(t, flags.Parse([]string{"--vus", "0", "--duration", "1s"}))
+	problems := lintScript(flags, script, globalFlags)
+	require.Len(t, problems, 1)
+	assert.Equal(t, lint.SeverityError, problems[0].Severity)
+	assert.Contains(t, problems[0].Message, `The moduleSpecifier "./missing.js" couldn't be found on local disk`)
+
+	require.NoError(t, os.WriteFile(script, []byte(`export default function() {}`), 0o600))
+	problems = lintScript(flags, script, globalFlags)
+	require.Len(t, problems, 1)
+	assert.Equal(t, lint.SeverityError, problems[0].Severity)
+	assert.Contains(t, problems[0].Message, "There were problems with the specified script configuration")
+
+	var out bytes.Buffer
+	assert.Equal(t, 0, printLintProblems(&out, nil))
+	assert.Equal(t, "No problems found\n", out.String())
+}
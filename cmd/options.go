@@ -23,6 +23,7 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"path"
 	"strings"
 	"time"
 
@@ -61,6 +62,9 @@ func optionFlagSet() *pflag.FlagSet {
 	flags.Int64("max-redirects", 10, "follow at most n redirects")
 	flags.Int64("batch", 20, "max parallel batch reqs")
 	flags.Int64("batch-per-host", 6, "max parallel batch reqs per host")
+	flags.Int64("max-conns-per-host", 0, "max connections per host of every VU, 0 for no limit")
+	flags.Int64("max-idle-conns", 0, "max idle connections kept open by every VU, the batch limit if not set")
+	flags.Int64("max-requests-per-conn", 0, "close connections after this many requests, 0 for no limit")
 	flags.Int64("rps", 0, "limit requests per second")
 	flags.String("user-agent", fmt.Sprintf("k6/%s (https://k6.io/)", consts.Version), "user agent for http requests")
 	flags.String("http-debug", "", "log all HTTP requests and responses. Excludes body by default. To include body use '--http-debug=full'") //nolint:lll
@@ -70,9 +74,13 @@ func optionFlagSet() *pflag.FlagSet {
 	flags.Bool("no-vu-connection-reuse", false, "don't reuse connections between iterations")
 	flags.Duration("min-iteration-duration", 0, "minimum amount of time k6 will take executing a single iteration")
 	flags.BoolP("throw", "w", false, "throw warnings (like failed http requests) as errors")
+	flags.Bool("thresholds-per-scenario", false, "evaluate the thresholds separately for every scenario")
+	flags.String("thresholds-webhook", "", "`url` to which the thresholds are posted when they start failing")
 	flags.StringSlice("blacklist-ip", nil, "blacklist an `ip range` from being called")
 	flags.StringSlice("block-hostnames", nil, "block a case-insensitive hostname `pattern`,"+
 		" with optional leading wildcard, from being called")
+	flags.StringSlice("unix-socket", nil, "allow only the unix sockets whose paths match a `pattern`"+
+		" to be called")
 
 	// The comment about system-tags also applies for summary-trend-stats. The default values
 	// are set in applyDefault().
@@ -81,6 +89,8 @@ func optionFlagSet() *pflag.FlagSet {
 		strings.Join(lib.DefaultSummaryTrendStats, ","),
 	)
 	flags.StringSlice("summary-trend-stats", nil, sumTrendStatsHelp)
+	flags.Float64("trend-relative-error", 0.01, "relative `error` of the percentiles of the trend metrics, "+
+		"which keep all their values when it's 0")
 	flags.String("summary-time-unit", "", "define the time unit used to display the trend stats. Possible units are: 's', 'ms' and 'us'") //nolint:lll
 	// system-tags must have a default value, but we can't specify it here, otherwiese, it will always override others.
 	// set it to nil here, and add the default in applyDefault() instead.
@@ -90,6 +100,11 @@ func optionFlagSet() *pflag.FlagSet {
 	)
 	flags.StringSlice("system-tags", nil, systemTagsCliHelpText)
 	flags.StringSlice("tag", nil, "add a `tag` to be applied to all samples, as `[name]=[value]`")
+	flags.Int64("tag-cardinality-limit", 1000, "unique `values` of a tag of a metric above which k6 warns, "+
+		"and takes the tag cardinality action; 0 disables it")
+	flags.String("tag-cardinality-action", lib.TagCardinalityWarn, "`action` for the values of a tag above "+
+		"the tag cardinality limit, besides warning: 'warn' keeps them, 'drop' removes the tag and 'hash' "+
+		"replaces them with one of as many hashes as the limit")
 	flags.String("console-output", "", "redirects the console logging to the provided output file")
 	flags.Bool("discard-response-bodies", false, "Read but don't process or save HTTP response bodies")
 	flags.String("local-ips", "", "Client IP Ranges and/or CIDRs from which each VU will be making requests, "+
@@ -114,6 +129,9 @@ func getOptions(flags *pflag.FlagSet) (lib.Options, error) {
 		MaxRedirects:          getNullInt64(flags, "max-redirects"),
 		Batch:                 getNullInt64(flags, "batch"),
 		BatchPerHost:          getNullInt64(flags, "batch-per-host"),
+		MaxConnsPerHost:       getNullInt64(flags, "max-conns-per-host"),
+		MaxIdleConns:          getNullInt64(flags, "max-idle-conns"),
+		MaxRequestsPerConn:    getNullInt64(flags, "max-requests-per-conn"),
 		RPS:                   getNullInt64(flags, "rps"),
 		UserAgent:             getNullString(flags, "user-agent"),
 		HTTPDebug:             getNullString(flags, "http-debug"),
@@ -122,7 +140,12 @@ func getOptions(flags *pflag.FlagSet) (lib.Options, error) {
 		NoVUConnectionReuse:   getNullBool(flags, "no-vu-connection-reuse"),
 		MinIterationDuration:  getNullDuration(flags, "min-iteration-duration"),
 		Throw:                 getNullBool(flags, "throw"),
+		ThresholdsPerScenario: getNullBool(flags, "thresholds-per-scenario"),
+		ThresholdsWebhook:     getNullString(flags, "thresholds-webhook"),
 		DiscardResponseBodies: getNullBool(flags, "discard-response-bodies"),
+		TrendRelativeError:    getNullFloat64(flags, "trend-relative-error"),
+		TagCardinalityLimit:   getNullInt64(flags, "tag-cardinality-limit"),
+		TagCardinalityAction:  getNullString(flags, "tag-cardinality-action"),
 		// Default values for options without CLI flags:
 		// TODO: find a saner and more dev-friendly and error-proof way to handle options
 		SetupTimeout:    types.NullDuration{Duration: types.Duration(60 * time.Second), Valid: false},
@@ -207,6 +230,18 @@ func getOptions(flags *pflag.FlagSet) (lib.Options, error) {
 		}
 	}
 
+	if flags.Changed("unix-socket") {
+		opts.UnixSockets, err = flags.GetStringSlice("unix-socket")
+		if err != nil {
+			return opts, err
+		}
+		for _, pattern := range opts.UnixSockets {
+			if _, err = path.Match(pattern, ""); err != nil {
+				return opts, fmt.Errorf("error parsing unix-socket '%s': %w", pattern, err)
+			}
+		}
+	}
+
 	localIpsString, err := flags.GetString("local-ips")
 	if err != nil {
 		return opts, err
@@ -32,11 +32,17 @@ import (
 	"go.k6.io/k6/lib"
 	"go.k6.io/k6/loader"
 	"go.k6.io/k6/output"
+	"go.k6.io/k6/output/clickhouse"
 	"go.k6.io/k6/output/cloud"
 	"go.k6.io/k6/output/csv"
 	"go.k6.io/k6/output/influxdb"
 	"go.k6.io/k6/output/json"
+	"go.k6.io/k6/output/kafka"
+	"go.k6.io/k6/output/otel"
+	"go.k6.io/k6/output/parquet"
+	"go.k6.io/k6/output/plugin"
 	"go.k6.io/k6/output/statsd"
+	"go.k6.io/k6/output/timescaledb"
 )
 
 // TODO: move this to an output sub-module after we get rid of the old collectors?
@@ -46,16 +52,18 @@ func getAllOutputConstructors() (map[string]func(output.Params) (output.Output,
 		"json":     json.New,
 		"cloud":    cloud.New,
 		"influxdb": influxdb.New,
-		"kafka": func(params output.Params) (output.Output, error) {
-			return nil, errors.New("the kafka output was deprecated in k6 v0.32.0 and removed in k6 v0.34.0, " +
-				"please use the new xk6 kafka output extension instead - https://github.com/k6io/xk6-output-kafka")
-		},
-		"statsd": statsd.New,
+		"kafka":    kafka.New,
+		"statsd":   statsd.New,
 		"datadog": func(params output.Params) (output.Output, error) {
 			return nil, errors.New("the datadog output was deprecated in k6 v0.32.0 and removed in k6 v0.34.0, " +
 				"please use the statsd output with env. variable K6_STATSD_ENABLE_TAGS=true instead")
 		},
-		"csv": csv.New,
+		"csv":         csv.New,
+		"otel":        otel.New,
+		"parquet":     parquet.New,
+		"clickhouse":  clickhouse.New,
+		"timescaledb": timescaledb.New,
+		"plugin":      plugin.New,
 	}
 
 	exts := output.GetExtensions()
@@ -72,7 +80,7 @@ func getAllOutputConstructors() (map[string]func(output.Params) (output.Output,
 func getPossibleIDList(constrs map[string]func(output.Params) (output.Output, error)) string {
 	res := make([]string, 0, len(constrs))
 	for k := range constrs {
-		if k == "kafka" || k == "datadog" {
+		if k == "datadog" {
 			continue
 		}
 		res = append(res, k)
@@ -83,7 +91,7 @@ func getPossibleIDList(constrs map[string]func(output.Params) (output.Output, er
 
 func createOutputs(
 	outputFullArguments []string, src *loader.SourceData, conf Config, rtOpts lib.RuntimeOptions,
-	executionPlan []lib.ExecutionStep, osEnvironment map[string]string, logger logrus.FieldLogger,
+	executionPlan []lib.ExecutionStep, osEnvironment map[string]string, fs afero.Fs, logger logrus.FieldLogger,
 	globalFlags *commandFlags,
 ) ([]output.Output, error) {
 	outputConstructors, err := getAllOutputConstructors()
@@ -96,33 +104,71 @@ func createOutputs(
 		Environment:    osEnvironment,
 		StdOut:         globalFlags.stdout,
 		StdErr:         globalFlags.stderr,
-		FS:             afero.NewOsFs(),
+		FS:             fs,
 		ScriptOptions:  conf.Options,
 		RuntimeOptions: rtOpts,
 		ExecutionPlan:  executionPlan,
 	}
-	result := make([]output.Output, 0, len(outputFullArguments))
-
+	instances := make([]OutputConfig, 0, len(outputFullArguments)+len(conf.Outputs))
 	for _, outputFullArg := range outputFullArguments {
 		outputType, outputArg := parseOutputArgument(outputFullArg)
-		outputConstructor, ok := outputConstructors[outputType]
+		instances = append(instances, OutputConfig{
+			Type: outputType, Name: outputType, Arg: outputArg, Config: conf.Collectors[outputType],
+		})
+	}
+	names := make(map[string]bool, len(conf.Outputs))
+	for _, instance := range conf.Outputs {
+		switch {
+		case instance.Type == "" || instance.Name == "":
+			return nil, errors.New("the outputs of the config need a type and a name")
+		case names[instance.Name]:
+			return nil, fmt.Errorf("there are several outputs named '%s' in the config", instance.Name)
+		}
+		names[instance.Name] = true
+		instances = append(instances, instance)
+	}
+
+	result := make([]output.Output, 0, len(instances))
+	for _, instance := range instances {
+		outputConstructor, ok := outputConstructors[instance.Type]
 		if !ok {
 			return nil, fmt.Errorf(
 				"invalid output type '%s', available types are: %s",
-				outputType, getPossibleIDList(outputConstructors),
+				instance.Type, getPossibleIDList(outputConstructors),
 			)
 		}
 
 		params := baseParams
-		params.OutputType = outputType
-		params.ConfigArgument = outputArg
-		params.JSONConfig = conf.Collectors[outputType]
+		params.OutputType = instance.Type
+		params.ConfigArgument = instance.Arg
+		params.JSONConfig = instance.Config
+
+		out, err := outputConstructor(params)
+		if err != nil {
+			return nil, fmt.Errorf("could not create the '%s' output: %w", instance.Name, err)
+		}
 
-		output, err := outputConstructor(params)
+		limit, err := output.GetBufferLimit(instance.Type, params.JSONConfig, osEnvironment)
 		if err != nil {
-			return nil, fmt.Errorf("could not create the '%s' output: %w", outputType, err)
+			return nil, fmt.Errorf("invalid buffer limit of the '%s' output: %w", instance.Name, err)
+		}
+		if limit.MaxSamples > 0 {
+			limited, ok := out.(output.WithBufferLimit)
+			if !ok {
+				return nil, fmt.Errorf("the buffer of the '%s' output can't be limited", instance.Name)
+			}
+			limit.Output = instance.Name
+			limited.SetBufferLimit(limit)
+		}
+
+		filter, err := output.GetFilterConfig(instance.Type, params.JSONConfig, osEnvironment)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter of the '%s' output: %w", instance.Name, err)
+		}
+		if !filter.IsEmpty() {
+			out = output.NewFilteredOutput(out, filter)
 		}
-		result = append(result, output)
+		result = append(result, out)
 	}
 
 	return result, nil
@@ -0,0 +1,82 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/loader"
+	"go.k6.io/k6/output"
+)
+
+func TestCreateNamedOutputs(t *testing.T) {
+	t.Parallel()
+	src := &loader.SourceData{URL: &url.URL{Path: "/script.js"}}
+
+	create := func(conf Config) ([]output.Output, error) {
+		return createOutputs(conf.Out, src, conf, lib.RuntimeOptions{}, nil, nil,
+			afero.NewMemMapFs(), testutils.NewLogger(t), newCommandFlags())
+	}
+
+	outputs, err := create(Config{
+		Out:        []string{"json=all.json"},
+		Collectors: map[string]json.RawMessage{"json": json.RawMessage(`{"fileName":"ignored.json"}`)},
+		Outputs: []OutputConfig{
+			{Type: "json", Name: "errors", Config: json.RawMessage(`{"fileName":"errors.json"}`)},
+			{
+				Type: "json", Name: "checks", Arg: "checks.json",
+				Config: json.RawMessage(`{"includeMetrics":["checks"],"maxBufferedSamples":100,"overflow":"drop"}`),
+			},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, outputs, 3)
+	assert.Equal(t, "json (all.json)", outputs[0].Description())
+	assert.Equal(t, "json (errors.json)", outputs[1].Description())
+
+	filtered, ok := outputs[2].(*output.FilteredOutput)
+	require.True(t, ok)
+	assert.Equal(t, "json (checks.json)", filtered.Description())
+	assert.Equal(t, output.BufferLimit{Output: "checks", MaxSamples: 100, Overflow: output.OverflowDrop},
+		filtered.BufferStatus().Limit)
+
+	_, err = create(Config{Outputs: []OutputConfig{{Type: "json"}}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "need a type and a name")
+
+	_, err = create(Config{Outputs: []OutputConfig{
+		{Type: "json", Name: "results", Arg: "a.json"}, {Type: "csv", Name: "results", Arg: "a.csv"},
+	}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "several outputs named 'results'")
+
+	_, err = create(Config{Outputs: []OutputConfig{{Type: "unknown", Name: "results"}}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid output type 'unknown'")
+}
@@ -43,6 +43,13 @@ func getPauseCmd(ctx context.Context, globalFlags *commandFlags) *cobra.Command
 			if err != nil {
 				return err
 			}
+			if scenario, _ := cmd.Flags().GetString("scenario"); scenario != "" {
+				s, err := c.SetScenario(ctx, scenario, v1.Scenario{Paused: null.BoolFrom(true)})
+				if err != nil {
+					return err
+				}
+				return yamlPrint(globalFlags.stdout, s)
+			}
 			status, err := c.SetStatus(ctx, v1.Status{
 				Paused: null.BoolFrom(true),
 			})
@@ -52,5 +59,6 @@ func getPauseCmd(ctx context.Context, globalFlags *commandFlags) *cobra.Command
 			return yamlPrint(globalFlags.stdout, status)
 		},
 	}
+	pauseCmd.Flags().String("scenario", "", "only pause the scenario with this name")
 	return pauseCmd
 }
@@ -0,0 +1,217 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/afero"
+
+	"go.k6.io/k6/lib"
+)
+
+// Profile is a named profile of the config file, which is selected with --profile or K6_PROFILE. Its config is
+// applied on top of the rest of the config file and of the options of the script, and under the environment
+// variables and the flags, e.g. with
+//
+//	{"profiles": {
+//	  "base": {"thresholds": {"http_req_failed": ["rate<0.01"]}},
+//	  "staging": {"extends": "base", "env": {"BASE_URL": "https://staging.example.com"}, "out": ["json=staging.json"],
+//	              "scenarios": {"browse": {"vus": 20}}}
+//	}}
+//
+// k6 run --profile staging script.js has the threshold of the base profile, the BASE_URL of the staging one, and
+// the scenario browse of the script with 20 VUs.
+type Profile struct {
+	Config
+
+	// Extends are the profiles under the profile, which are applied in order, it's a name or an array of names.
+	Extends profileNames `json:"extends,omitempty"`
+	// Env are environment variables of the script, which the ones of --env override.
+	Env map[string]string `json:"env,omitempty"`
+	// Scenarios are overrides of the scenarios of the script, which are applied on top of their options instead of
+	// replacing them, the scenarios which aren't in the script need their executor.
+	Scenarios map[string]json.RawMessage `json:"scenarios,omitempty"`
+}
+
+// profileNames are the names of the extended profiles, which can be a name or an array of names in the config file.
+type profileNames []string
+
+// UnmarshalJSON unmarshals the names from a name or an array of names.
+func (n *profileNames) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*n = profileNames{name}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(n))
+}
+
+// Apply applies the profile on top of the current one, returning a new one. The environment variables and the
+// overrides of the scenarios of the provided profile have priority, and are merged with the current ones.
+func (p Profile) Apply(profile Profile) Profile {
+	p.Config = p.Config.Apply(profile.Config)
+	if len(profile.Env) > 0 {
+		env := make(map[string]string, len(p.Env)+len(profile.Env))
+		for k, v := range p.Env {
+			env[k] = v
+		}
+		for k, v := range profile.Env {
+			env[k] = v
+		}
+		p.Env = env
+	}
+	if len(profile.Scenarios) > 0 {
+		scenarios := make(map[string]json.RawMessage, len(p.Scenarios)+len(profile.Scenarios))
+		for name, override := range p.Scenarios {
+			scenarios[name] = override
+		}
+		for name, override := range profile.Scenarios {
+			if current, ok := scenarios[name]; ok {
+				merged, err := mergeJSONObjects(current, override)
+				if err == nil {
+					override = merged
+				}
+			}
+			scenarios[name] = override
+		}
+		p.Scenarios = scenarios
+	}
+	return p
+}
+
+// getProfile returns the profile of the config file which is selected with --profile, with the profiles which it
+// extends applied under it, or an empty profile if none is selected.
+func getProfile(fs afero.Fs, globalFlags *commandFlags) (Profile, error) {
+	if globalFlags.profile == "" {
+		return Profile{}, nil
+	}
+	conf, configPath, err := readDiskConfig(fs, globalFlags)
+	if err != nil {
+		return Profile{}, err
+	}
+	profile, err := resolveProfile(conf.Profiles, globalFlags.profile, nil)
+	if err != nil {
+		return Profile{}, fmt.Errorf("invalid profile in the config file %s: %w", configPath, err)
+	}
+	for k := range profile.Env {
+		if !userEnvVarName.MatchString(k) {
+			return Profile{}, fmt.Errorf("invalid environment variable name '%s' in the profile '%s'", k,
+				globalFlags.profile)
+		}
+	}
+	return profile, nil
+}
+
+// resolveProfile returns the profile with the name, with the profiles which it extends applied under it. The
+// extending profiles are the ones which extend the profile, to find the cycles.
+func resolveProfile(profiles map[string]Profile, name string, extending []string) (Profile, error) {
+	for _, e := range extending {
+		if e == name {
+			return Profile{}, fmt.Errorf("the profile '%s' extends itself", name)
+		}
+	}
+	profile, ok := profiles[name]
+	if !ok {
+		if len(extending) > 0 {
+			return Profile{}, fmt.Errorf("the profile '%s' extends the unknown profile '%s'",
+				extending[len(extending)-1], name)
+		}
+		return Profile{}, fmt.Errorf("the profile '%s' isn't in the config file", name)
+	}
+	var resolved Profile
+	for _, base := range profile.Extends {
+		baseProfile, err := resolveProfile(profiles, base, append(extending, name))
+		if err != nil {
+			return Profile{}, err
+		}
+		resolved = resolved.Apply(baseProfile)
+	}
+	profile.Extends = nil
+	return resolved.Apply(profile), nil
+}
+
+// overrideScenarios applies the overrides of the scenarios on top of the options of the scenarios, the ones which
+// change the executor replace the scenario.
+func overrideScenarios(
+	scenarios lib.ScenarioConfigs, overrides map[string]json.RawMessage,
+) (lib.ScenarioConfigs, error) {
+	if len(overrides) == 0 {
+		return scenarios, nil
+	}
+	merged := make(map[string]json.RawMessage, len(scenarios)+len(overrides))
+	for name, scenario := range scenarios {
+		data, err := json.Marshal(scenario)
+		if err != nil {
+			return nil, err
+		}
+		merged[name] = data
+	}
+	for name, override := range overrides {
+		var executor struct {
+			Type string `json:"executor"`
+		}
+		if err := json.Unmarshal(override, &executor); err != nil {
+			return nil, fmt.Errorf("the override of the scenario '%s' isn't an object: %w", name, err)
+		}
+		current, ok := scenarios[name]
+		switch {
+		case !ok && executor.Type == "":
+			return nil, fmt.Errorf("the scenario '%s' of the profile isn't in the options and has no executor", name)
+		case ok && (executor.Type == "" || executor.Type == current.GetType()):
+			data, err := mergeJSONObjects(merged[name], override)
+			if err != nil {
+				return nil, err
+			}
+			merged[name] = data
+		default:
+			merged[name] = override
+		}
+	}
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	var result lib.ScenarioConfigs
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("invalid scenarios of the profile: %w", err)
+	}
+	return result, nil
+}
+
+// mergeJSONObjects returns the object with the keys of the override replacing the ones of the current object.
+func mergeJSONObjects(current, override json.RawMessage) (json.RawMessage, error) {
+	var c, o map[string]json.RawMessage
+	if err := json.Unmarshal(current, &c); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(override, &o); err != nil {
+		return nil, err
+	}
+	if c == nil {
+		c = make(map[string]json.RawMessage, len(o))
+	}
+	for k, v := range o {
+		c[k] = v
+	}
+	return json.Marshal(c)
+}
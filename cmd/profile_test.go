@@ -0,0 +1,107 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/executor"
+	"go.k6.io/k6/lib/types"
+)
+
+func TestResolveProfile(t *testing.T) {
+	t.Parallel()
+	var conf Config
+	require.NoError(t, json.Unmarshal([]byte(`{"profiles": {
+		"base": {"vus": 1, "out": ["json=base.json"], "env": {"A": "base", "B": "base"},
+			"scenarios": {"browse": {"vus": 2, "duration": "1m"}}},
+		"eu": {"env": {"REGION": "eu"}},
+		"staging": {"extends": ["base", "eu"], "vus": 3, "env": {"B": "staging"}, "scenarios": {"browse": {"vus": 4}}},
+		"loop": {"extends": "cycle"},
+		"cycle": {"extends": "loop"},
+		"broken": {"extends": "missing"}
+	}}`), &conf))
+
+	profile, err := resolveProfile(conf.Profiles, "staging", nil)
+	require.NoError(t, err)
+	assert.Equal(t, null.IntFrom(3), profile.VUs)
+	assert.Equal(t, []string{"json=base.json"}, profile.Out)
+	assert.Equal(t, map[string]string{"A": "base", "B": "staging", "REGION": "eu"}, profile.Env)
+	assert.JSONEq(t, `{"vus": 4, "duration": "1m"}`, string(profile.Scenarios["browse"]))
+	assert.Empty(t, profile.Extends)
+
+	_, err = resolveProfile(conf.Profiles, "loop", nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "the profile 'loop' extends itself")
+
+	_, err = resolveProfile(conf.Profiles, "broken", nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "the profile 'broken' extends the unknown profile 'missing'")
+
+	_, err = resolveProfile(conf.Profiles, "production", nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "the profile 'production' isn't in the config file")
+}
+
+func TestOverrideScenarios(t *testing.T) {
+	t.Parallel()
+	browse := executor.NewConstantVUsConfig("browse")
+	browse.VUs = null.IntFrom(10)
+	browse.Duration = types.NullDurationFrom(time.Minute)
+	browse.GracefulStop = types.NullDurationFrom(5 * time.Second)
+	scenarios := lib.ScenarioConfigs{"browse": browse}
+
+	overridden, err := overrideScenarios(scenarios, map[string]json.RawMessage{
+		"browse": json.RawMessage(`{"vus": 20}`),
+		"api":    json.RawMessage(`{"executor": "shared-iterations", "iterations": 5}`),
+	})
+	require.NoError(t, err)
+	require.Len(t, overridden, 2)
+	b, ok := overridden["browse"].(executor.ConstantVUsConfig)
+	require.True(t, ok)
+	assert.Equal(t, null.IntFrom(20), b.VUs)
+	assert.Equal(t, types.NullDurationFrom(time.Minute), b.Duration)
+	assert.Equal(t, types.NullDurationFrom(5*time.Second), b.GracefulStop)
+	a, ok := overridden["api"].(executor.SharedIterationsConfig)
+	require.True(t, ok)
+	assert.Equal(t, null.IntFrom(5), a.Iterations)
+
+	// the scenarios whose executor changes are replaced
+	overridden, err = overrideScenarios(scenarios, map[string]json.RawMessage{
+		"browse": json.RawMessage(`{"executor": "per-vu-iterations", "vus": 3}`),
+	})
+	require.NoError(t, err)
+	p, ok := overridden["browse"].(executor.PerVUIterationsConfig)
+	require.True(t, ok)
+	assert.Equal(t, null.IntFrom(3), p.VUs)
+	assert.False(t, p.GracefulStop.Valid)
+
+	_, err = overrideScenarios(scenarios, map[string]json.RawMessage{"other": json.RawMessage(`{"vus": 1}`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "the scenario 'other' of the profile isn't in the options and has no executor")
+}
@@ -0,0 +1,215 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"go.k6.io/k6/converter/recorder"
+)
+
+// recordFlags are the flags specific to the record command.
+type recordFlags struct {
+	listen         string
+	output         string
+	harOutput      string
+	caCert         string
+	caKey          string
+	groupThreshold time.Duration
+	sleepThreshold time.Duration
+	correlate      bool
+	only           []string
+	skip           []string
+}
+
+//nolint:funlen
+func getRecordCmd(ctx context.Context, logger *logrus.Logger, globalFlags *commandFlags) *cobra.Command {
+	flags := &recordFlags{
+		listen:         "localhost:8888",
+		caCert:         "k6-record-ca.crt",
+		caKey:          "k6-record-ca.key",
+		groupThreshold: 5 * time.Second,
+		sleepThreshold: 500 * time.Millisecond,
+		correlate:      true,
+	}
+	recordCmd := &cobra.Command{
+		Use:   "record",
+		Short: "Record a k6 script through an HTTP proxy",
+		Long: `Record a k6 script through an HTTP proxy.
+
+The requests of the browsers and of the other clients which use the proxy are
+recorded until k6 record is stopped with Ctrl+C, then the script which makes
+them is generated:
+
+  - the requests are grouped by pages, a page starts with the request of a
+    document, or after no requests were made for the group threshold
+  - the times between the requests which are longer than the sleep threshold
+    are kept as sleeps
+  - the values of the responses which are sent by the next requests, like the
+    tokens of JSON responses, the hidden inputs of forms and the redirections,
+    are taken from the responses of the test
+
+The HTTPS requests are intercepted, so the certificate of the certificate
+authority of k6 record needs to be trusted by the clients. It's created when
+its files don't exist, and they are reused by the next recordings.`,
+		Example: `
+  # Record a script, with the proxy of the browser set to localhost:8888.
+  k6 record -O script.js
+
+  # Record only the requests to a domain, and keep the recording for k6 convert.
+  k6 record --only test.k6.io --har recording.har -O script.js`[1:],
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fs := afero.NewOsFs()
+			caCert, caKey, created, err := loadRecorderCA(fs, flags.caCert, flags.caKey)
+			if err != nil {
+				return err
+			}
+			if created {
+				logger.Infof("Created the certificate authority %s, which needs to be trusted by the clients",
+					flags.caCert)
+			}
+			rec, err := recorder.New(caCert, caKey, logger)
+			if err != nil {
+				return err
+			}
+
+			listener, err := net.Listen("tcp", flags.listen)
+			if err != nil {
+				return err
+			}
+			server := &http.Server{Handler: rec} //nolint:gosec
+			go func() {
+				if serr := server.Serve(listener); serr != nil && !errors.Is(serr, http.ErrServerClosed) {
+					logger.WithError(serr).Error("The recording proxy failed")
+				}
+			}()
+			fprintf(globalFlags.stderr,
+				"Recording through the HTTP proxy at %s, press Ctrl+C to stop and generate the script...\n",
+				listener.Addr())
+
+			sigC := make(chan os.Signal, 1)
+			signal.Notify(sigC, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+			defer signal.Stop(sigC)
+			select {
+			case sig := <-sigC:
+				logger.WithField("sig", sig).Debug("Stopping the recording in response to signal...")
+			case <-ctx.Done():
+			}
+			shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), time.Second)
+			defer shutdownCancel()
+			_ = server.Shutdown(shutdownCtx)
+
+			h := rec.HAR(flags.groupThreshold)
+			if flags.harOutput != "" {
+				data, err := json.MarshalIndent(h, "", "  ")
+				if err != nil {
+					return err
+				}
+				if err = afero.WriteFile(fs, flags.harOutput, data, 0o644); err != nil {
+					return err
+				}
+			}
+			script, err := recorder.GenerateScript(h, recorder.ScriptOptions{
+				SleepThreshold: flags.sleepThreshold,
+				Correlate:      flags.correlate,
+				Only:           flags.only,
+				Skip:           flags.skip,
+			})
+			if err != nil {
+				return err
+			}
+			if flags.output == "" || flags.output == "-" {
+				fprintf(globalFlags.stdout, "%s", script)
+			} else if err = afero.WriteFile(fs, flags.output, []byte(script), 0o644); err != nil {
+				return err
+			}
+			fprintf(globalFlags.stderr, "Recorded %d requests in %d pages\n", len(h.Log.Entries), len(h.Log.Pages))
+			return nil
+		},
+	}
+
+	recordCmd.Flags().SortFlags = false
+	recordCmd.Flags().AddFlagSet(recordCmdFlagSet(flags))
+
+	return recordCmd
+}
+
+func recordCmdFlagSet(flags *recordFlags) *pflag.FlagSet {
+	flagSet := pflag.NewFlagSet("", pflag.ContinueOnError)
+	flagSet.SortFlags = false
+	flagSet.StringVarP(&flags.listen, "listen", "l", flags.listen, "address on which the proxy listens")
+	flagSet.StringVarP(&flags.output, "output", "O", flags.output, "k6 script output filename (stdout by default)")
+	flagSet.StringVar(&flags.harOutput, "har", flags.harOutput, "HAR file to which the recording is saved too")
+	flagSet.StringVar(&flags.caCert, "ca-cert", flags.caCert, "certificate file of the certificate authority")
+	flagSet.StringVar(&flags.caKey, "ca-key", flags.caKey, "private key file of the certificate authority")
+	flagSet.DurationVar(&flags.groupThreshold, "group-threshold", flags.groupThreshold,
+		"time without requests after which the next request starts a new page")
+	flagSet.DurationVar(&flags.sleepThreshold, "sleep-threshold", flags.sleepThreshold,
+		"shortest time between requests which is kept as a sleep")
+	flagSet.BoolVar(&flags.correlate, "correlate", flags.correlate,
+		"take the values of the responses which are sent by the next requests from the responses of the test")
+	flagSet.StringSliceVar(&flags.only, "only", nil, "include only requests from the given domains")
+	flagSet.StringSliceVar(&flags.skip, "skip", nil, "skip requests from the given domains")
+	return flagSet
+}
+
+// loadRecorderCA returns the certificate and the key of the certificate authority of the recorder,
+// they are created if neither of their files exists.
+func loadRecorderCA(fs afero.Fs, certPath, keyPath string) (cert, key []byte, created bool, err error) {
+	cert, certErr := afero.ReadFile(fs, certPath)
+	key, keyErr := afero.ReadFile(fs, keyPath)
+	switch {
+	case certErr == nil && keyErr == nil:
+		return cert, key, false, nil
+	case !os.IsNotExist(certErr) && certErr != nil:
+		return nil, nil, false, certErr
+	case !os.IsNotExist(keyErr) && keyErr != nil:
+		return nil, nil, false, keyErr
+	case certErr == nil || keyErr == nil:
+		return nil, nil, false, fmt.Errorf("the certificate authority needs both %s and %s", certPath, keyPath)
+	}
+
+	if cert, key, err = recorder.NewCA(); err != nil {
+		return nil, nil, false, err
+	}
+	if err = afero.WriteFile(fs, keyPath, key, 0o600); err != nil {
+		return nil, nil, false, err
+	}
+	if err = afero.WriteFile(fs, certPath, cert, 0o644); err != nil {
+		return nil, nil, false, err
+	}
+	return cert, key, true, nil
+}
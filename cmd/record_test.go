@@ -0,0 +1,55 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/converter/recorder"
+)
+
+func TestLoadRecorderCA(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewMemMapFs()
+	cert, key, created, err := loadRecorderCA(fs, "ca.crt", "ca.key")
+	require.NoError(t, err)
+	assert.True(t, created)
+	_, err = recorder.New(cert, key, logrus.New())
+	require.NoError(t, err)
+
+	// the next recordings reuse it
+	reloadedCert, reloadedKey, created, err := loadRecorderCA(fs, "ca.crt", "ca.key")
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, cert, reloadedCert)
+	assert.Equal(t, key, reloadedKey)
+
+	require.NoError(t, fs.Remove("ca.key"))
+	_, _, _, err = loadRecorderCA(fs, "ca.crt", "ca.key")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "the certificate authority needs both ca.crt and ca.key")
+}
@@ -0,0 +1,91 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"path/filepath"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+
+	"go.k6.io/k6/ui/report"
+)
+
+func getReportCmd(globalFlags *commandFlags) *cobra.Command {
+	var output, title string
+	reportCmd := &cobra.Command{
+		Use:   "report",
+		Short: "Generate an HTML report of a test run",
+		Long: `Generate an HTML report of a test run.
+
+The test run is its JSON output, from --out json, optionally compressed with
+gzip or zstd, its CSV output, from --out csv, or its summary, from
+--summary-export or the JSON of the data of handleSummary().
+
+The report is a standalone HTML page, without external resources, which has
+the metrics, the checks and the thresholds of the test run. With the outputs,
+it has charts of the requests per second, the percentiles of the request
+durations and the virtual users over time too, and the thresholds of the
+metrics are evaluated from their samples.`,
+		Example: `
+  # Generate the report of a test run.
+  k6 run --out json=results.json script.js
+  k6 report -O report.html results.json
+
+  # Generate the report of the summary of a test run.
+  k6 run --summary-export summary.json script.js
+  k6 report --title "Nightly test" -O report.html summary.json`[1:],
+		Args: exactArgsWithMsg(1, "arg should either be the output or the summary of a test run"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fs := afero.NewOsFs()
+			r, closeResults, err := openResults(fs, args[0])
+			if err != nil {
+				return err
+			}
+			defer closeResults()
+
+			if title == "" {
+				title = "k6 report: " + filepath.Base(args[0])
+			}
+			rep, err := report.Read(r, title)
+			if err != nil {
+				return err
+			}
+			var buf bytes.Buffer
+			if err = report.WriteHTML(&buf, rep); err != nil {
+				return err
+			}
+			if output == "" || output == "-" {
+				_, err = globalFlags.stdout.Write(buf.Bytes())
+				return err
+			}
+			return afero.WriteFile(fs, output, buf.Bytes(), 0o644)
+		},
+	}
+
+	reportCmd.Flags().SortFlags = false
+	reportCmd.Flags().StringVarP(&output, "output", "O", "", "HTML report output filename (stdout by default)")
+	reportCmd.Flags().StringVar(&title, "title", "",
+		"title of the report, from the filename of the test run by default")
+
+	return reportCmd
+}
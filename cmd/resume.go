@@ -43,6 +43,13 @@ func getResumeCmd(ctx context.Context, globalFlags *commandFlags) *cobra.Command
 			if err != nil {
 				return err
 			}
+			if scenario, _ := cmd.Flags().GetString("scenario"); scenario != "" {
+				s, err := c.SetScenario(ctx, scenario, v1.Scenario{Paused: null.BoolFrom(false)})
+				if err != nil {
+					return err
+				}
+				return yamlPrint(globalFlags.stdout, s)
+			}
 			status, err := c.SetStatus(ctx, v1.Status{
 				Paused: null.BoolFrom(false),
 			})
@@ -53,5 +60,6 @@ func getResumeCmd(ctx context.Context, globalFlags *commandFlags) *cobra.Command
 			return yamlPrint(globalFlags.stdout, status)
 		},
 	}
+	resumeCmd.Flags().String("scenario", "", "only resume the scenario with this name")
 	return resumeCmd
 }
@@ -54,6 +54,7 @@ const (
 type commandFlags struct {
 	defaultConfigFilePath string
 	configFilePath        string
+	profile               string
 	exitOnRunning         bool
 	showCloudLogs         bool
 	runType               string
@@ -61,6 +62,7 @@ type commandFlags struct {
 	quiet                 bool
 	noColor               bool
 	address               string
+	prometheusMetrics     bool
 	outMutex              *sync.Mutex
 	stdoutTTY, stderrTTY  bool
 	stdout, stderr        *consoleWriter
@@ -86,6 +88,7 @@ func newCommandFlags() *commandFlags {
 	return &commandFlags{
 		defaultConfigFilePath: defaultConfigFilePath,  // Updated with the user's config folder in the init() function below
 		configFilePath:        os.Getenv("K6_CONFIG"), // Overridden by `-c`/`--config` flag!
+		profile:               os.Getenv("K6_PROFILE"),
 		exitOnRunning:         os.Getenv("K6_EXIT_ON_RUNNING") != "",
 		showCloudLogs:         true,
 		runType:               os.Getenv("K6_TYPE"),
@@ -181,18 +184,28 @@ func Execute() {
 		getLoginCloudCommand(logger, c.commandFlags),
 		getLoginInfluxDBCommand(logger, c.commandFlags),
 	)
+	generateCmd := getGenerateCmd()
+	generateCmd.AddCommand(getGenerateOpenAPICmd(c.commandFlags))
 	c.cmd.AddCommand(
+		getAgentCmd(ctx, logger),
 		getArchiveCmd(logger, c.commandFlags),
 		getCloudCmd(ctx, logger, c.commandFlags),
+		getCompareCmd(c.commandFlags),
 		getConvertCmd(afero.NewOsFs(), c.commandFlags.stdout),
+		getCoordinatorCmd(ctx, logger, c.commandFlags),
+		generateCmd,
 		getInspectCmd(logger, c.commandFlags),
+		getLintCmd(c.commandFlags),
 		loginCmd,
 		getPauseCmd(ctx, c.commandFlags),
+		getRecordCmd(ctx, logger, c.commandFlags),
+		getReportCmd(c.commandFlags),
 		getResumeCmd(ctx, c.commandFlags),
 		getScaleCmd(ctx, c.commandFlags),
 		getRunCmd(ctx, logger, c.commandFlags),
 		getStatsCmd(ctx, c.commandFlags),
 		getStatusCmd(ctx, c.commandFlags),
+		getSuiteCmd(ctx, logger, c.commandFlags),
 		getVersionCmd(),
 	)
 
@@ -249,6 +262,8 @@ func (c *rootCommand) rootCmdPersistentFlagSet() *pflag.FlagSet {
 		"change the output for k6 logs, possible values are stderr,stdout,none,loki[=host:port],file[=./path.fileformat]")
 	flags.StringVar(&c.logFmt, "logformat", "", "log output format") // TODO rename to log-format and warn on old usage
 	flags.StringVarP(&c.commandFlags.address, "address", "a", "localhost:6565", "address for the api server")
+	flags.BoolVar(&c.commandFlags.prometheusMetrics, "prometheus-metrics", false,
+		"serve the metrics in the Prometheus exposition format on /metrics of the api server")
 
 	// TODO: Fix... This default value needed, so both CLI flags and environment variables work
 	flags.StringVarP(&c.commandFlags.configFilePath, "config", "c", c.commandFlags.configFilePath, "JSON config file")
@@ -256,6 +271,8 @@ func (c *rootCommand) rootCmdPersistentFlagSet() *pflag.FlagSet {
 	// like `K6_CONFIG="blah" k6 run -h` don't produce a weird usage message
 	flags.Lookup("config").DefValue = c.commandFlags.defaultConfigFilePath
 	must(cobra.MarkFlagFilename(flags, "config"))
+	flags.StringVar(&c.commandFlags.profile, "profile", c.commandFlags.profile,
+		"named profile of the config file to apply on top of it and of the script options")
 	return flags
 }
 
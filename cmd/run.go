@@ -31,6 +31,7 @@ import (
 	"os"
 	"os/signal"
 	"runtime"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -50,7 +51,9 @@ import (
 	"go.k6.io/k6/lib"
 	"go.k6.io/k6/lib/consts"
 	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/lib/netext/har"
 	"go.k6.io/k6/loader"
+	"go.k6.io/k6/stats"
 	"go.k6.io/k6/ui/pb"
 )
 
@@ -86,9 +89,16 @@ a commandline interface for interacting with it.`,
   k6 run -u 0 -s 10s:100 -s 60s -s 10s:0
 
   # Send metrics to an influxdb server
-  k6 run -o influxdb=http://1.2.3.4:8086/k6`[1:],
+  k6 run -o influxdb=http://1.2.3.4:8086/k6
+
+  # Run a single iteration every time that the script or its imports change.
+  k6 run --watch script.js`[1:],
 		Args: exactArgsWithMsg(1, "arg should either be \"-\", if reading script from stdin, or a path to a script file"),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if watch, _ := cmd.Flags().GetBool("watch"); watch {
+				return runWatch(ctx, cmd.Flags(), args, logger, globalFlags)
+			}
+
 			// TODO: disable in quiet mode?
 			_, _ = fmt.Fprintf(globalFlags.stdout, "\n%s\n\n", getBanner(globalFlags.noColor || !globalFlags.stdoutTTY))
 
@@ -100,8 +110,12 @@ a commandline interface for interacting with it.`,
 				return err
 			}
 
+			profile, err := getProfile(afero.NewOsFs(), globalFlags)
+			if err != nil {
+				return err
+			}
 			osEnvironment := buildEnvMap(os.Environ())
-			runtimeOptions, err := getRuntimeOptions(cmd.Flags(), osEnvironment)
+			runtimeOptions, err := getRuntimeOptions(cmd.Flags(), osEnvironment, profile)
 			if err != nil {
 				return err
 			}
@@ -191,7 +205,17 @@ a commandline interface for interacting with it.`,
 
 			// Create all outputs.
 			executionPlan := execScheduler.GetExecutionPlan()
-			outputs, err := createOutputs(conf.Out, src, conf, runtimeOptions, executionPlan, osEnvironment, logger, globalFlags)
+			// The files that are created by the outputs, the summary and the HAR recording are recorded,
+			// to be uploaded at the end of the test, if it's enabled.
+			resultsFs := newRecordingFs(afero.NewOsFs())
+			var uploader *resultsUploader
+			if runtimeOptions.UploadResults.String != "" {
+				if uploader, err = newResultsUploader(runtimeOptions.UploadResults.String, osEnvironment); err != nil {
+					return errext.WithExitCodeIfNone(err, exitcodes.InvalidConfig)
+				}
+			}
+			outputs, err := createOutputs(
+				conf.Out, src, conf, runtimeOptions, executionPlan, osEnvironment, resultsFs, logger, globalFlags)
 			if err != nil {
 				return err
 			}
@@ -202,13 +226,24 @@ a commandline interface for interacting with it.`,
 			if err != nil {
 				return err
 			}
+			engine.LiveMetrics = registry.LiveMetrics()
+			engine.LiveMetrics.SetTrendRelativeError(conf.TrendRelativeError.Float64)
+			execScheduler.GetState().LiveMetrics = engine.LiveMetrics
+			if runtimeOptions.Baseline.String != "" && !runtimeOptions.NoThresholds.Bool {
+				baseline, berr := loadBaseline(afero.NewOsFs(), runtimeOptions.Baseline.String)
+				if berr != nil {
+					return errext.WithExitCodeIfNone(berr, exitcodes.InvalidConfig)
+				}
+				engine.SetBaseline(baseline)
+			}
 
 			// Spin up the REST API server, if not disabled.
 			if globalFlags.address != "" {
 				initBar.Modify(pb.WithConstProgress(0, "Init API server"))
 				go func() {
 					logger.Debugf("Starting the REST API server on %s", globalFlags.address)
-					if aerr := api.ListenAndServe(globalFlags.address, engine, logger); aerr != nil {
+					aerr := api.ListenAndServe(globalFlags.address, engine, logger, globalFlags.prometheusMetrics)
+					if aerr != nil {
 						// Only exit k6 if the user has explicitly set the REST API address
 						if cmd.Flags().Lookup("address").Changed {
 							logger.WithError(aerr).Error("Error from API server")
@@ -226,6 +261,12 @@ a commandline interface for interacting with it.`,
 			if err != nil {
 				return err
 			}
+			if uploader != nil {
+				// this is deferred before stopping the outputs, so it runs after they are stopped
+				defer func() {
+					uploader.uploadAll(ctx, logger, resultsFs, resultsFs.Files())
+				}()
+			}
 			defer engine.StopOutputs()
 
 			printExecutionDescription(
@@ -302,6 +343,12 @@ a commandline interface for interacting with it.`,
 				logger.Warn("No script iterations finished, consider making the test duration longer")
 			}
 
+			if jsRunner, ok := initRunner.(*js.Runner); ok && runtimeOptions.HAROut.String != "" {
+				if err := writeHAR(resultsFs, runtimeOptions.HAROut.String, jsRunner.HAR()); err != nil {
+					logger.WithError(err).Error("failed to write the HAR file")
+				}
+			}
+
 			// Handle the end-of-test summary.
 			if !runtimeOptions.NoSummary.Bool {
 				summaryResult, err := initRunner.HandleSummary(globalCtx, &lib.Summary{
@@ -313,9 +360,10 @@ a commandline interface for interacting with it.`,
 						IsStdOutTTY: globalFlags.stdoutTTY,
 						IsStdErrTTY: globalFlags.stderrTTY,
 					},
+					TagCardinalityOffenders: engine.TagCardinalityOffenders(),
 				})
 				if err == nil {
-					err = handleSummaryResult(afero.NewOsFs(), globalFlags.stdout, globalFlags.stderr, summaryResult)
+					err = handleSummaryResult(resultsFs, globalFlags.stdout, globalFlags.stderr, summaryResult)
 				}
 				if err != nil {
 					logger.WithError(err).Error("failed to handle the end-of-test summary")
@@ -340,8 +388,12 @@ a commandline interface for interacting with it.`,
 			if interrupt != nil {
 				return interrupt
 			}
+			if warnings := engine.FailedWarnThresholds(); len(warnings) > 0 {
+				logger.Warnf("some thresholds with the %s severity have failed: %s",
+					stats.ThresholdSeverityWarn, strings.Join(warnings, ", "))
+			}
 			if engine.IsTainted() {
-				return errext.WithExitCodeIfNone(errors.New("some thresholds have failed"), exitcodes.ThresholdsHaveFailed)
+				return errext.WithExitCodeIfNone(errors.New("some thresholds have failed"), engine.ThresholdsExitCode())
 			}
 			return nil
 		},
@@ -349,6 +401,8 @@ a commandline interface for interacting with it.`,
 
 	runCmd.Flags().SortFlags = false
 	runCmd.Flags().AddFlagSet(runCmdFlagSet(globalFlags))
+	runCmd.Flags().Bool("watch", false,
+		"re-run the script, with a single iteration by default, whenever it or its imports change")
 
 	return runCmd
 }
@@ -432,6 +486,24 @@ func newRunner(
 	return runner, err
 }
 
+// writeHAR writes the recorded HTTP requests to a HAR file.
+func writeHAR(fs afero.Fs, path string, recorded *har.HAR) error {
+	data, err := json.MarshalIndent(recorded, "", "  ")
+	if err != nil {
+		return err
+	}
+	return afero.WriteFile(fs, path, data, 0o666)
+}
+
+// loadBaseline reads the baseline of the thresholds from a summary export.
+func loadBaseline(fs afero.Fs, path string) (lib.Baseline, error) {
+	data, err := afero.ReadFile(fs, path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read the baseline: %w", err)
+	}
+	return lib.ParseBaseline(data)
+}
+
 func handleSummaryResult(fs afero.Fs, stdOut, stdErr io.Writer, result map[string]io.Reader) error {
 	var errs []error
 
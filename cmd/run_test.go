@@ -41,6 +41,7 @@ import (
 	"go.k6.io/k6/errext"
 	"go.k6.io/k6/errext/exitcodes"
 	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/lib"
 	"go.k6.io/k6/lib/fsext"
 	"go.k6.io/k6/lib/testutils"
 )
@@ -108,6 +109,19 @@ func TestHandleSummaryResultSimple(t *testing.T) {
 	require.Empty(t, stderr.Bytes())
 }
 
+func TestLoadBaseline(t *testing.T) {
+	t.Parallel()
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/baseline.json", []byte(`{"metrics":{"vus":{"value":10}}}`), 0o644))
+
+	baseline, err := loadBaseline(fs, "/baseline.json")
+	require.NoError(t, err)
+	assert.Equal(t, lib.Baseline{"vus": {"value": 10}}, baseline)
+
+	_, err = loadBaseline(fs, "/missing.json")
+	assert.Error(t, err)
+}
+
 func TestHandleSummaryResultError(t *testing.T) {
 	t.Parallel()
 	content, _, stderr, fs := initVars()
@@ -72,6 +72,12 @@ extended: base + Babel with parts of ES2015 preset
 		"",
 		"output the end-of-test summary report to JSON file",
 	)
+	flags.String("baseline", "", "compare the thresholds with `baseline` to the summary export of a previous run "+
+		"in the file")
+	flags.String("har-out", "", "record all HTTP requests and responses to a HAR `file`")
+	flags.String("upload-results", "",
+		"upload the files of the summary, the HAR file and the outputs to an s3://bucket/prefix or gs://bucket/prefix `url`")
+	flags.Bool("no-global-timers", false, "don't define setTimeout, setInterval, setImmediate and their clear functions as globals")
 	return flags
 }
 
@@ -91,7 +97,11 @@ func saveBoolFromEnv(env map[string]string, varName string, placeholder *null.Bo
 	return nil
 }
 
-func getRuntimeOptions(flags *pflag.FlagSet, environment map[string]string) (lib.RuntimeOptions, error) {
+// getRuntimeOptions returns the runtime options of the flags and of the environment, the environment variables of
+// the script are the ones of the profile, if there's one, overridden by the ones of --env.
+func getRuntimeOptions(
+	flags *pflag.FlagSet, environment map[string]string, profile Profile,
+) (lib.RuntimeOptions, error) {
 	// TODO: refactor with composable helpers as a part of #883, to reduce copy-paste
 	// TODO: get these options out of the JSON config file as well?
 	opts := lib.RuntimeOptions{
@@ -100,6 +110,10 @@ func getRuntimeOptions(flags *pflag.FlagSet, environment map[string]string) (lib
 		NoThresholds:         getNullBool(flags, "no-thresholds"),
 		NoSummary:            getNullBool(flags, "no-summary"),
 		SummaryExport:        getNullString(flags, "summary-export"),
+		Baseline:             getNullString(flags, "baseline"),
+		HAROut:               getNullString(flags, "har-out"),
+		UploadResults:        getNullString(flags, "upload-results"),
+		NoGlobalTimers:       getNullBool(flags, "no-global-timers"),
 		Env:                  make(map[string]string),
 	}
 
@@ -123,6 +137,9 @@ func getRuntimeOptions(flags *pflag.FlagSet, environment map[string]string) (lib
 	if err := saveBoolFromEnv(environment, "K6_NO_SUMMARY", &opts.NoSummary); err != nil {
 		return opts, err
 	}
+	if err := saveBoolFromEnv(environment, "K6_NO_GLOBAL_TIMERS", &opts.NoGlobalTimers); err != nil {
+		return opts, err
+	}
 
 	if envVar, ok := environment["K6_SUMMARY_EXPORT"]; ok {
 		if !opts.SummaryExport.Valid {
@@ -130,9 +147,38 @@ func getRuntimeOptions(flags *pflag.FlagSet, environment map[string]string) (lib
 		}
 	}
 
+	if envVar, ok := environment["K6_BASELINE"]; ok {
+		if !opts.Baseline.Valid {
+			opts.Baseline = null.StringFrom(envVar)
+		}
+	}
+
+	if envVar, ok := environment["K6_HAR_OUT"]; ok {
+		if !opts.HAROut.Valid {
+			opts.HAROut = null.StringFrom(envVar)
+		}
+	}
+
+	if envVar, ok := environment["K6_UPLOAD_RESULTS"]; ok {
+		if !opts.UploadResults.Valid {
+			opts.UploadResults = null.StringFrom(envVar)
+		}
+	}
+
 	if opts.IncludeSystemEnvVars.Bool { // If enabled, gather the actual system environment variables
 		opts.Env = environment
 	}
+	if len(profile.Env) > 0 {
+		// the system environment variables are copied, so the ones of the profile aren't seen as K6_ options
+		env := make(map[string]string, len(opts.Env)+len(profile.Env))
+		for k, v := range opts.Env {
+			env[k] = v
+		}
+		for k, v := range profile.Env {
+			env[k] = v
+		}
+		opts.Env = env
+	}
 
 	// Set/overwrite environment variables with custom user-supplied values
 	envVars, err := flags.GetStringArray("env")
@@ -50,7 +50,7 @@ func testRuntimeOptionsCase(t *testing.T, tc runtimeOptionsTestCase) {
 	flags := runtimeOptionFlagSet(tc.useSysEnv)
 	require.NoError(t, flags.Parse(tc.cliFlags))
 
-	rtOpts, err := getRuntimeOptions(flags, tc.systemEnv)
+	rtOpts, err := getRuntimeOptions(flags, tc.systemEnv, Profile{})
 	if tc.expErr {
 		require.Error(t, err)
 		return
@@ -349,6 +349,59 @@ func TestRuntimeOptions(t *testing.T) {
 				SummaryExport:        null.NewString("bar", true),
 			},
 		},
+		"har out from env": {
+			useSysEnv: false,
+			systemEnv: map[string]string{"K6_HAR_OUT": "foo.har"},
+			expRTOpts: lib.RuntimeOptions{
+				IncludeSystemEnvVars: null.NewBool(false, false),
+				CompatibilityMode:    defaultCompatMode,
+				Env:                  map[string]string{},
+				HAROut:               null.NewString("foo.har", true),
+			},
+		},
+		"har out from env overwritten by CLI": {
+			useSysEnv: false,
+			systemEnv: map[string]string{"K6_HAR_OUT": "foo.har"},
+			cliFlags:  []string{"--har-out", "bar.har"},
+			expRTOpts: lib.RuntimeOptions{
+				IncludeSystemEnvVars: null.NewBool(false, false),
+				CompatibilityMode:    defaultCompatMode,
+				Env:                  map[string]string{},
+				HAROut:               null.NewString("bar.har", true),
+			},
+		},
+		"upload results from env overwritten by CLI": {
+			useSysEnv: false,
+			systemEnv: map[string]string{"K6_UPLOAD_RESULTS": "s3://env/k6"},
+			cliFlags:  []string{"--upload-results", "gs://cli/k6"},
+			expRTOpts: lib.RuntimeOptions{
+				IncludeSystemEnvVars: null.NewBool(false, false),
+				CompatibilityMode:    defaultCompatMode,
+				Env:                  map[string]string{},
+				UploadResults:        null.NewString("gs://cli/k6", true),
+			},
+		},
+		"global timers disabled from env": {
+			useSysEnv: false,
+			systemEnv: map[string]string{"K6_NO_GLOBAL_TIMERS": "true"},
+			expRTOpts: lib.RuntimeOptions{
+				IncludeSystemEnvVars: null.NewBool(false, false),
+				CompatibilityMode:    defaultCompatMode,
+				Env:                  map[string]string{},
+				NoGlobalTimers:       null.NewBool(true, true),
+			},
+		},
+		"global timers from env overwritten by CLI": {
+			useSysEnv: false,
+			systemEnv: map[string]string{"K6_NO_GLOBAL_TIMERS": "true"},
+			cliFlags:  []string{"--no-global-timers=false"},
+			expRTOpts: lib.RuntimeOptions{
+				IncludeSystemEnvVars: null.NewBool(false, false),
+				CompatibilityMode:    defaultCompatMode,
+				Env:                  map[string]string{},
+				NoGlobalTimers:       null.NewBool(false, true),
+			},
+		},
 		"env var error detected even when CLI flags overwrite 1": {
 			useSysEnv: false,
 			systemEnv: map[string]string{"K6_NO_THRESHOLDS": "boo"},
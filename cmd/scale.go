@@ -41,15 +41,23 @@ func getScaleCmd(ctx context.Context, globalFlags *commandFlags) *cobra.Command
 		RunE: func(cmd *cobra.Command, _ []string) error {
 			vus := getNullInt64(cmd.Flags(), "vus")
 			max := getNullInt64(cmd.Flags(), "max")
-			if !vus.Valid && !max.Valid {
-				return errors.New("Specify either -u/--vus or -m/--max") //nolint:golint,stylecheck
+			rate := getNullFloat64(cmd.Flags(), "rate")
+			if !vus.Valid && !max.Valid && !rate.Valid {
+				return errors.New("Specify either -u/--vus, -m/--max or -r/--rate") //nolint:golint,stylecheck
 			}
 
 			c, err := client.New(globalFlags.address)
 			if err != nil {
 				return err
 			}
-			status, err := c.SetStatus(ctx, v1.Status{VUs: vus, VUsMax: max})
+			if scenario, _ := cmd.Flags().GetString("scenario"); scenario != "" {
+				s, err := c.SetScenario(ctx, scenario, v1.Scenario{VUs: vus, VUsMax: max, Rate: rate})
+				if err != nil {
+					return err
+				}
+				return yamlPrint(globalFlags.stdout, s)
+			}
+			status, err := c.SetStatus(ctx, v1.Status{VUs: vus, VUsMax: max, Rate: rate})
 			if err != nil {
 				return err
 			}
@@ -60,6 +68,8 @@ func getScaleCmd(ctx context.Context, globalFlags *commandFlags) *cobra.Command
 
 	scaleCmd.Flags().Int64P("vus", "u", 1, "number of virtual users")
 	scaleCmd.Flags().Int64P("max", "m", 0, "max available virtual users")
+	scaleCmd.Flags().Float64P("rate", "r", 0, "iteration rate of the externally-controlled-arrival-rate executor")
+	scaleCmd.Flags().String("scenario", "", "only scale the scenario with this name")
 
 	return scaleCmd
 }
@@ -0,0 +1,437 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+
+	"go.k6.io/k6/errext"
+	"go.k6.io/k6/errext/exitcodes"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/types"
+)
+
+// testSuite is the configuration of a suite file, with the scripts which are
+// run one after the other, or all at once if parallel is set, and the k6 run
+// arguments and environment variables shared by all of them.
+type testSuite struct {
+	Parallel bool              `json:"parallel"`
+	Args     []string          `json:"args"`
+	Env      map[string]string `json:"env"`
+	Scripts  []suiteScript     `json:"scripts"`
+}
+
+// suiteScript is a script of a suite, with its own k6 run arguments and
+// environment variables, which override the shared ones. It's either only the
+// path of the script, or an object with it.
+type suiteScript struct {
+	Name string            `json:"name"`
+	Path string            `json:"path"`
+	Args []string          `json:"args"`
+	Env  map[string]string `json:"env"`
+}
+
+type rawSuiteScript suiteScript
+
+// UnmarshalJSON accepts either the path of the script or an object with it.
+func (s *suiteScript) UnmarshalJSON(data []byte) error {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		return lib.StrictJSONUnmarshal(trimmed, (*rawSuiteScript)(s))
+	}
+	*s = suiteScript{}
+	return json.Unmarshal(data, &s.Path)
+}
+
+// suiteResult is the outcome of a script of a suite, with the metrics of its
+// summary export.
+type suiteResult struct {
+	Name     string         `json:"name"`
+	Path     string         `json:"path"`
+	ExitCode int            `json:"exitCode"`
+	Skipped  bool           `json:"skipped"`
+	Error    string         `json:"error,omitempty"`
+	Duration types.Duration `json:"duration"`
+	Metrics  lib.Baseline   `json:"metrics"`
+}
+
+// suiteCommandRunner runs k6 with the given arguments and returns its exit
+// code, or an error if it couldn't be run at all.
+type suiteCommandRunner func(args []string, stdout, stderr io.Writer) (int, error)
+
+// runK6Command runs the given arguments with the current k6 executable.
+func runK6Command(args []string, stdout, stderr io.Writer) (int, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return -1, err
+	}
+	// Not a CommandContext, since the scripts handle a Ctrl+C on their own,
+	// with their summaries and teardowns, and shouldn't be killed.
+	cmd := exec.Command(executable, args...) //nolint:gosec
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return -1, err
+	}
+	return 0, nil
+}
+
+// loadTestSuite reads and validates a suite file. The paths of its scripts
+// are relative to the directory of the file.
+func loadTestSuite(fs afero.Fs, path string) (*testSuite, error) {
+	data, err := afero.ReadFile(fs, path)
+	if err != nil {
+		return nil, err
+	}
+	suite := &testSuite{}
+	if err = lib.StrictJSONUnmarshal(data, suite); err != nil {
+		return nil, fmt.Errorf("couldn't parse the suite %s: %w", path, err)
+	}
+	if len(suite.Scripts) == 0 {
+		return nil, fmt.Errorf("the suite %s has no scripts", path)
+	}
+
+	dir := filepath.Dir(path)
+	names := make(map[string]bool, len(suite.Scripts))
+	for i := range suite.Scripts {
+		script := &suite.Scripts[i]
+		if script.Path == "" {
+			return nil, fmt.Errorf("the script #%d of the suite %s has no path", i+1, path)
+		}
+		if script.Name == "" {
+			script.Name = script.Path
+		}
+		if names[script.Name] {
+			return nil, fmt.Errorf("the suite %s has multiple scripts named '%s'", path, script.Name)
+		}
+		names[script.Name] = true
+		if !filepath.IsAbs(script.Path) {
+			script.Path = filepath.Join(dir, script.Path)
+		}
+	}
+	return suite, nil
+}
+
+// getEnvArgs returns the --env flags of k6 run for the given variables, in
+// the order of their names.
+func getEnvArgs(env map[string]string) []string {
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	args := make([]string, 0, 2*len(keys))
+	for _, key := range keys {
+		args = append(args, "--env", key+"="+env[key])
+	}
+	return args
+}
+
+// getRunArgs returns the arguments of the k6 run command of a script, whose
+// own arguments come after the shared ones, so they take precedence.
+func (ts *testSuite) getRunArgs(script suiteScript, summaryExport string) []string {
+	args := []string{"run"}
+	args = append(args, ts.Args...)
+	args = append(args, getEnvArgs(ts.Env)...)
+	args = append(args, script.Args...)
+	args = append(args, getEnvArgs(script.Env)...)
+	args = append(args, "--summary-export", summaryExport)
+	if ts.Parallel {
+		// the scripts can't all listen on the default address of the REST API
+		args = append(args, "--address=")
+	}
+	return append(args, script.Path)
+}
+
+// suiteRunner runs the scripts of a suite and collects their results.
+type suiteRunner struct {
+	suite          *testSuite
+	runCommand     suiteCommandRunner
+	stdout, stderr io.Writer
+	outMutex       sync.Mutex
+	logger         logrus.FieldLogger
+	summaryDir     string
+}
+
+func (sr *suiteRunner) runScript(ctx context.Context, i int) suiteResult {
+	script := sr.suite.Scripts[i]
+	result := suiteResult{Name: script.Name, Path: script.Path}
+	if ctx.Err() != nil {
+		result.Skipped = true
+		return result
+	}
+
+	summaryExport := filepath.Join(sr.summaryDir, fmt.Sprintf("%d.json", i))
+	args := sr.suite.getRunArgs(script, summaryExport)
+	sr.logger.WithField("args", args).Debugf("Running script '%s' of the suite", script.Name)
+
+	start := time.Now()
+	if sr.suite.Parallel {
+		// the outputs of the parallel scripts are shown one after the other
+		var stdout, stderr bytes.Buffer
+		result.ExitCode, result.Error = sr.runCommandWithError(args, &stdout, &stderr)
+		sr.outMutex.Lock()
+		fprintf(sr.stdout, "\n=== %s (%s) ===\n", script.Name, script.Path)
+		_, _ = io.Copy(sr.stdout, &stdout)
+		_, _ = io.Copy(sr.stderr, &stderr)
+		sr.outMutex.Unlock()
+	} else {
+		fprintf(sr.stdout, "\n=== %s (%s) ===\n", script.Name, script.Path)
+		result.ExitCode, result.Error = sr.runCommandWithError(args, sr.stdout, sr.stderr)
+	}
+	result.Duration = types.Duration(time.Since(start).Round(time.Millisecond))
+
+	if data, err := afero.ReadFile(afero.NewOsFs(), summaryExport); err == nil {
+		if result.Metrics, err = lib.ParseBaseline(data); err != nil {
+			sr.logger.WithError(err).Warnf("Couldn't parse the summary of script '%s'", script.Name)
+		}
+	}
+	return result
+}
+
+func (sr *suiteRunner) runCommandWithError(args []string, stdout, stderr io.Writer) (int, string) {
+	exitCode, err := sr.runCommand(args, stdout, stderr)
+	if err != nil {
+		return exitCode, err.Error()
+	}
+	return exitCode, ""
+}
+
+// run runs all scripts of the suite, and skips the ones which haven't started
+// when the context is done.
+func (sr *suiteRunner) run(ctx context.Context) ([]suiteResult, error) {
+	summaryDir, err := os.MkdirTemp("", "k6-suite-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(summaryDir) }()
+	sr.summaryDir = summaryDir
+
+	results := make([]suiteResult, len(sr.suite.Scripts))
+	if !sr.suite.Parallel {
+		for i := range sr.suite.Scripts {
+			results[i] = sr.runScript(ctx, i)
+		}
+		return results, nil
+	}
+
+	wg := sync.WaitGroup{}
+	for i := range sr.suite.Scripts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = sr.runScript(ctx, i)
+		}(i)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// getSuiteError returns an error with the exit code of the first failed
+// script, if any of them failed.
+func getSuiteError(results []suiteResult) error {
+	var failed, skipped int
+	exitCode := errext.ExitCode(0)
+	for _, result := range results {
+		switch {
+		case result.Skipped:
+			skipped++
+		case result.ExitCode != 0 || result.Error != "":
+			if failed == 0 {
+				exitCode = errext.ExitCode(result.ExitCode)
+			}
+			failed++
+		}
+	}
+	switch {
+	case failed > 0:
+		return errext.WithExitCodeIfNone(
+			fmt.Errorf("%d of the %d scripts of the suite failed", failed, len(results)), exitCode)
+	case skipped > 0:
+		return errext.WithExitCodeIfNone(
+			fmt.Errorf("%d of the %d scripts of the suite were skipped", skipped, len(results)),
+			exitcodes.ExternalAbort)
+	default:
+		return nil
+	}
+}
+
+func getSuiteStatus(result suiteResult) string {
+	switch {
+	case result.Skipped:
+		return "- skipped"
+	case result.Error != "":
+		return "✗ couldn't run"
+	case result.ExitCode == int(exitcodes.ThresholdsHaveFailed):
+		return "✗ thresholds failed"
+	case result.ExitCode != 0:
+		return fmt.Sprintf("✗ failed (exit code %d)", result.ExitCode)
+	default:
+		return "✓ passed"
+	}
+}
+
+// printSuiteSummary prints a table with the status and the main metrics of
+// every script of the suite.
+func printSuiteSummary(w io.Writer, results []suiteResult) error {
+	metricValue := func(result suiteResult, metric, method string, format func(float64) string) string {
+		if value, ok := result.Metrics[metric][method]; ok {
+			return format(value)
+		}
+		return "-"
+	}
+	percent := func(v float64) string { return fmt.Sprintf("%.2f%%", v*100) }
+	count := func(v float64) string { return fmt.Sprintf("%.0f", v) }
+	duration := func(v float64) string {
+		return types.Duration(v * float64(time.Millisecond)).String()
+	}
+
+	fprintf(w, "\n     suite summary:\n\n")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fprintf(tw, "     script\tstatus\tduration\titerations\tchecks\thttp_req_failed\thttp_req_duration p(95)\n")
+	for _, result := range results {
+		fprintf(tw, "     %s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			result.Name, getSuiteStatus(result), result.Duration,
+			metricValue(result, "iterations", "count", count),
+			metricValue(result, "checks", "rate", percent),
+			metricValue(result, "http_req_failed", "rate", percent),
+			metricValue(result, "http_req_duration", "p(95)", duration),
+		)
+	}
+	fprintf(tw, "\n")
+	return tw.Flush()
+}
+
+func getSuiteCmd(ctx context.Context, logger *logrus.Logger, globalFlags *commandFlags) *cobra.Command {
+	var summaryExport string
+	var parallel bool
+	suiteCmd := &cobra.Command{
+		Use:   "suite",
+		Short: "Run a suite of load tests",
+		Long: `Run a suite of load tests.
+
+The suite file is a JSON file with the scripts, which are run one after the
+other with k6 run, or all at once with "parallel": true. Its "args" and "env"
+are the k6 run arguments and environment variables shared by all scripts, and
+each script can have its own ones, which take precedence. The paths of the
+scripts are relative to the suite file.
+
+After all scripts are done, a combined summary of them is shown, and k6 exits
+with the exit code of the first script which failed, if any did.`,
+		Example: `
+  # Run the scripts of the suite one after the other.
+  k6 suite suite.json
+
+  # Where suite.json is:
+  {
+    "args": ["--vus", "10"],
+    "env": {"BASE_URL": "https://test.k6.io"},
+    "scripts": [
+      "login.js",
+      {"name": "checkout", "path": "checkout.js", "args": ["--duration", "1m"]}
+    ]
+  }
+
+  # Run all scripts at once and export the combined results.
+  k6 suite --parallel --summary-export results.json suite.json`[1:],
+		Args: exactArgsWithMsg(1, "arg should be the path to a suite file"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			suite, err := loadTestSuite(afero.NewOsFs(), args[0])
+			if err != nil {
+				return errext.WithExitCodeIfNone(err, exitcodes.InvalidConfig)
+			}
+			if cmd.Flags().Changed("parallel") {
+				suite.Parallel = parallel
+			}
+
+			// The scripts get the Ctrl+C too and stop on their own, the
+			// suite only doesn't start any more of them.
+			runCtx, runCancel := context.WithCancel(ctx)
+			defer runCancel()
+			sigC := make(chan os.Signal, 1)
+			signal.Notify(sigC, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+			defer signal.Stop(sigC)
+			go func() {
+				select {
+				case sig := <-sigC:
+					logger.WithField("sig", sig).Debug("Stopping the suite in response to signal...")
+					runCancel()
+				case <-runCtx.Done():
+				}
+			}()
+
+			runner := &suiteRunner{
+				suite:      suite,
+				runCommand: runK6Command,
+				stdout:     os.Stdout,
+				stderr:     os.Stderr,
+				logger:     logger,
+			}
+			results, err := runner.run(runCtx)
+			if err != nil {
+				return err
+			}
+
+			if err = printSuiteSummary(globalFlags.stdout, results); err != nil {
+				return err
+			}
+			if summaryExport != "" {
+				data, err := json.MarshalIndent(map[string]interface{}{"scripts": results}, "", "    ")
+				if err != nil {
+					return err
+				}
+				if err = afero.WriteFile(afero.NewOsFs(), summaryExport, data, 0o644); err != nil {
+					return err
+				}
+			}
+			return getSuiteError(results)
+		},
+	}
+
+	suiteCmd.Flags().BoolVar(&parallel, "parallel", false, "run all scripts at once, instead of one after the other")
+	suiteCmd.Flags().StringVar(&summaryExport, "summary-export", "",
+		"output the combined results of all scripts to the given JSON file")
+
+	return suiteCmd
+}
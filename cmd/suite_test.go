@@ -0,0 +1,200 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/errext"
+	"go.k6.io/k6/errext/exitcodes"
+	"go.k6.io/k6/lib/testutils"
+)
+
+func TestLoadTestSuite(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, filepath.FromSlash("/suites/suite.json"), []byte(`{
+		"parallel": true,
+		"args": ["--vus", "10"],
+		"env": {"BASE_URL": "https://test.k6.io"},
+		"scripts": [
+			"login.js",
+			{"name": "checkout", "path": "/scripts/checkout.js", "args": ["--duration", "1m"], "env": {"USER": "admin"}}
+		]
+	}`), 0o644))
+
+	suite, err := loadTestSuite(fs, filepath.FromSlash("/suites/suite.json"))
+	require.NoError(t, err)
+	assert.True(t, suite.Parallel)
+	require.Len(t, suite.Scripts, 2)
+	assert.Equal(t, "login.js", suite.Scripts[0].Name)
+	assert.Equal(t, filepath.FromSlash("/suites/login.js"), suite.Scripts[0].Path)
+	assert.Equal(t, "checkout", suite.Scripts[1].Name)
+
+	assert.Equal(t, []string{
+		"run", "--vus", "10", "--env", "BASE_URL=https://test.k6.io",
+		"--duration", "1m", "--env", "USER=admin",
+		"--summary-export", "summary.json", "--address=", suite.Scripts[1].Path,
+	}, suite.getRunArgs(suite.Scripts[1], "summary.json"))
+
+	invalidSuites := map[string]string{
+		"no scripts":           `{"scripts": []}`,
+		"no path":              `{"scripts": [{"name": "login"}]}`,
+		"duplicate names":      `{"scripts": ["login.js", {"name": "login.js", "path": "other.js"}]}`,
+		"unknown field":        `{"scripts": ["login.js"], "vus": 10}`,
+		"unknown script field": `{"scripts": [{"path": "login.js", "vus": 10}]}`,
+	}
+	for name, data := range invalidSuites {
+		require.NoError(t, afero.WriteFile(fs, "invalid.json", []byte(data), 0o644))
+		_, err := loadTestSuite(fs, "invalid.json")
+		assert.Error(t, err, name)
+	}
+}
+
+// fakeK6Run writes a summary export with the number of iterations, which is
+// the exit code of the script, since the test scripts are named after it.
+func fakeK6Run(t *testing.T, calls *[]string, mx *sync.Mutex) suiteCommandRunner {
+	return func(args []string, stdout, _ io.Writer) (int, error) {
+		script := filepath.Base(args[len(args)-1])
+		mx.Lock()
+		*calls = append(*calls, script)
+		mx.Unlock()
+		if script == "missing.js" {
+			return -1, errors.New("couldn't start k6")
+		}
+		var summaryExport string
+		for i, arg := range args {
+			if arg == "--summary-export" {
+				summaryExport = args[i+1]
+			}
+		}
+		require.NoError(t, os.WriteFile(summaryExport, []byte(`{"metrics": {
+			"iterations": {"count": 42, "rate": 4.2},
+			"checks": {"passes": 3, "fails": 1, "value": 0.75},
+			"http_req_duration": {"avg": 100, "p(95)": 250.5}
+		}}`), 0o600))
+		_, _ = stdout.Write([]byte("output of " + script + "\n"))
+		if script == "thresholds.js" {
+			return int(exitcodes.ThresholdsHaveFailed), nil
+		}
+		return 0, nil
+	}
+}
+
+func TestRunTestSuite(t *testing.T) {
+	t.Parallel()
+
+	for _, parallel := range []bool{false, true} {
+		parallel := parallel
+		t.Run(map[bool]string{false: "sequential", true: "parallel"}[parallel], func(t *testing.T) {
+			t.Parallel()
+
+			suite := &testSuite{
+				Parallel: parallel,
+				Scripts: []suiteScript{
+					{Name: "passed", Path: "passed.js"},
+					{Name: "thresholds", Path: "thresholds.js"},
+					{Name: "missing", Path: "missing.js"},
+				},
+			}
+			var calls []string
+			stdout := &bytes.Buffer{}
+			runner := &suiteRunner{
+				suite:      suite,
+				runCommand: fakeK6Run(t, &calls, &sync.Mutex{}),
+				stdout:     stdout,
+				stderr:     io.Discard,
+				logger:     testutils.NewLogger(t),
+			}
+			results, err := runner.run(context.Background())
+			require.NoError(t, err)
+			assert.ElementsMatch(t, []string{"passed.js", "thresholds.js", "missing.js"}, calls)
+			assert.Contains(t, stdout.String(), "=== thresholds (thresholds.js) ===\noutput of thresholds.js\n")
+
+			require.Len(t, results, 3)
+			assert.Equal(t, 0, results[0].ExitCode)
+			assert.Equal(t, 0.75, results[0].Metrics["checks"]["rate"])
+			assert.Equal(t, int(exitcodes.ThresholdsHaveFailed), results[1].ExitCode)
+			assert.Equal(t, "couldn't start k6", results[2].Error)
+
+			err = getSuiteError(results)
+			require.Error(t, err)
+			assert.Equal(t, "2 of the 3 scripts of the suite failed", err.Error())
+			var ecerr errext.HasExitCode
+			require.True(t, errors.As(err, &ecerr))
+			assert.Equal(t, exitcodes.ThresholdsHaveFailed, ecerr.ExitCode())
+
+			summary := &bytes.Buffer{}
+			require.NoError(t, printSuiteSummary(summary, results))
+			lines := strings.Split(summary.String(), "\n")
+			require.Len(t, lines, 9)
+			assert.Regexp(t, `^\s+passed\s+✓ passed\s+\S+\s+42\s+75\.00%\s+-\s+250\.5ms$`, lines[4])
+			assert.Regexp(t, `^\s+thresholds\s+✗ thresholds failed\s`, lines[5])
+			assert.Regexp(t, `^\s+missing\s+✗ couldn't run\s+\S+\s+-\s+-\s+-\s+-$`, lines[6])
+		})
+	}
+}
+
+func TestRunTestSuiteStopped(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls []string
+	fakeRun := fakeK6Run(t, &calls, &sync.Mutex{})
+	runner := &suiteRunner{
+		suite: &testSuite{Scripts: []suiteScript{
+			{Name: "first", Path: "first.js"},
+			{Name: "second", Path: "second.js"},
+		}},
+		runCommand: func(args []string, stdout, stderr io.Writer) (int, error) {
+			cancel() // like a Ctrl+C during the first script
+			return fakeRun(args, stdout, stderr)
+		},
+		stdout: io.Discard,
+		stderr: io.Discard,
+		logger: logrus.New(),
+	}
+	results, err := runner.run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first.js"}, calls)
+	assert.False(t, results[0].Skipped)
+	assert.True(t, results[1].Skipped)
+
+	err = getSuiteError(results)
+	require.Error(t, err)
+	var ecerr errext.HasExitCode
+	require.True(t, errors.As(err, &ecerr))
+	assert.Equal(t, exitcodes.ExternalAbort, ecerr.ExitCode())
+}
@@ -0,0 +1,229 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+
+	"go.k6.io/k6/lib/netext/httpext"
+)
+
+// recordingFs records the files that are created through it, so they can be uploaded at the end of the test.
+type recordingFs struct {
+	afero.Fs
+
+	mu    sync.Mutex
+	files []string
+}
+
+func newRecordingFs(fs afero.Fs) *recordingFs {
+	return &recordingFs{Fs: fs}
+}
+
+func (fs *recordingFs) record(name string) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	for _, f := range fs.files {
+		if f == name {
+			return
+		}
+	}
+	fs.files = append(fs.files, name)
+}
+
+// Create creates the file and records it.
+func (fs *recordingFs) Create(name string) (afero.File, error) {
+	f, err := fs.Fs.Create(name)
+	if err == nil {
+		fs.record(name)
+	}
+	return f, err
+}
+
+// OpenFile opens the file and records it, if it's opened to be created.
+func (fs *recordingFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	f, err := fs.Fs.OpenFile(name, flag, perm)
+	if err == nil && flag&os.O_CREATE != 0 {
+		fs.record(name)
+	}
+	return f, err
+}
+
+// Files returns the names of the created files, in the order they were created.
+func (fs *recordingFs) Files() []string {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return append([]string{}, fs.files...)
+}
+
+// resultsUploader uploads the result files of a test run to an S3 or a GCS bucket, under a prefix with the ID
+// of the run. GCS is used through its XML API, which accepts the same signatures as S3 with its HMAC keys.
+type resultsUploader struct {
+	client  *http.Client
+	signer  *httpext.AWSv4Signer
+	bucket  string // the URL of the bucket, to which the escaped keys are appended
+	prefix  string
+	timeout time.Duration
+}
+
+// newResultsUploader parses the s3://bucket/prefix or gs://bucket/prefix target. The credentials are
+// the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN env vars for S3 and the GCS_HMAC_ACCESS_KEY_ID
+// and GCS_HMAC_SECRET ones for GCS. K6_UPLOAD_ENDPOINT overrides the endpoint of the object store, for ones
+// compatible with S3, and K6_RUN_ID the ID of the run, which is otherwise made from the current time.
+func newResultsUploader(target string, env map[string]string) (*resultsUploader, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upload URL %q: %w", target, err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("the upload URL %q has no bucket", target)
+	}
+
+	signer := &httpext.AWSv4Signer{Service: "s3"}
+	var bucket string
+	switch u.Scheme {
+	case "s3":
+		signer.AccessKeyID = env["AWS_ACCESS_KEY_ID"]
+		signer.SecretAccessKey = env["AWS_SECRET_ACCESS_KEY"]
+		signer.SessionToken = env["AWS_SESSION_TOKEN"]
+		signer.Region = env["AWS_REGION"]
+		if signer.Region == "" {
+			signer.Region = env["AWS_DEFAULT_REGION"]
+		}
+		if signer.Region == "" {
+			signer.Region = "us-east-1"
+		}
+		bucket = fmt.Sprintf("https://%s.s3.%s.amazonaws.com", u.Host, signer.Region)
+	case "gs":
+		signer.AccessKeyID = env["GCS_HMAC_ACCESS_KEY_ID"]
+		signer.SecretAccessKey = env["GCS_HMAC_SECRET"]
+		signer.Region = "auto"
+		bucket = "https://storage.googleapis.com/" + u.Host
+	default:
+		return nil, fmt.Errorf("unsupported upload URL %q, only s3:// and gs:// ones are supported", target)
+	}
+	if signer.AccessKeyID == "" || signer.SecretAccessKey == "" {
+		return nil, fmt.Errorf("no credentials were set for uploading to %s://", u.Scheme)
+	}
+	if endpoint := env["K6_UPLOAD_ENDPOINT"]; endpoint != "" {
+		// the custom endpoints are addressed with the bucket in the path, which all of them support
+		bucket = strings.TrimSuffix(endpoint, "/") + "/" + u.Host
+	}
+
+	runID := env["K6_RUN_ID"]
+	if runID == "" {
+		suffix := make([]byte, 3)
+		if _, err := rand.Read(suffix); err != nil {
+			return nil, err
+		}
+		runID = time.Now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix)
+	}
+
+	return &resultsUploader{
+		client:  &http.Client{},
+		signer:  signer,
+		bucket:  bucket,
+		prefix:  path.Join(strings.Trim(u.Path, "/"), runID),
+		timeout: time.Minute,
+	}, nil
+}
+
+// upload puts the file in the bucket, under the prefix, with its base name, and returns its URL.
+func (u *resultsUploader) upload(ctx context.Context, fs afero.Fs, name string) (string, error) {
+	info, err := fs.Stat(name)
+	if err != nil {
+		return "", err
+	}
+	key := path.Join(u.prefix, filepath.Base(name))
+	escaped := make([]string, 0, strings.Count(key, "/")+1)
+	for _, segment := range strings.Split(key, "/") {
+		escaped = append(escaped, url.PathEscape(segment))
+	}
+	objectURL := u.bucket + "/" + strings.Join(escaped, "/")
+
+	ctx, cancel := context.WithTimeout(ctx, u.timeout)
+	defer cancel()
+	// the file is opened again by GetBody when the signer hashes it, so it's never entirely in memory
+	getBody := func() (io.ReadCloser, error) {
+		return fs.Open(name)
+	}
+	body, err := getBody()
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, objectURL, body)
+	if err != nil {
+		_ = body.Close()
+		return "", err
+	}
+	req.GetBody = getBody
+	req.ContentLength = info.Size()
+	contentType := mime.TypeByExtension(filepath.Ext(name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	req.Header.Set("Content-Type", contentType)
+	if err = u.signer.Sign(req); err != nil {
+		_ = body.Close()
+		return "", err
+	}
+
+	res, err := u.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+	if res.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1<<10))
+		return "", fmt.Errorf("the object store responded with %s: %s", res.Status, strings.TrimSpace(string(msg)))
+	}
+	return objectURL, nil
+}
+
+// uploadAll uploads the files, logging the errors, since they don't affect the result of the test.
+func (u *resultsUploader) uploadAll(ctx context.Context, logger logrus.FieldLogger, fs afero.Fs, files []string) {
+	for _, name := range files {
+		objectURL, err := u.upload(ctx, fs, name)
+		if err != nil {
+			logger.WithError(err).WithField("file", name).Error("failed to upload the result file")
+			continue
+		}
+		logger.WithField("url", objectURL).Infof("Uploaded %s", name)
+	}
+}
@@ -0,0 +1,129 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/lib/testutils"
+)
+
+func TestRecordingFs(t *testing.T) {
+	t.Parallel()
+	fs := newRecordingFs(afero.NewMemMapFs())
+
+	_, err := fs.Create("results.json")
+	require.NoError(t, err)
+	require.NoError(t, afero.WriteFile(fs, "summary.html", []byte("<html>"), 0o666))
+	_, err = fs.OpenFile("results.json", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o666)
+	require.NoError(t, err)
+	_, err = fs.Open("summary.html")
+	require.NoError(t, err)
+	_, err = fs.OpenFile("summary.html", os.O_RDONLY, 0)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"results.json", "summary.html"}, fs.Files())
+}
+
+func TestNewResultsUploader(t *testing.T) {
+	t.Parallel()
+	awsEnv := map[string]string{"AWS_ACCESS_KEY_ID": "id", "AWS_SECRET_ACCESS_KEY": "secret", "K6_RUN_ID": "run"}
+
+	u, err := newResultsUploader("s3://bucket/ci/k6/", awsEnv)
+	require.NoError(t, err)
+	assert.Equal(t, "https://bucket.s3.us-east-1.amazonaws.com", u.bucket)
+	assert.Equal(t, "ci/k6/run", u.prefix)
+	assert.Equal(t, "us-east-1", u.signer.Region)
+
+	u, err = newResultsUploader("gs://bucket", map[string]string{
+		"GCS_HMAC_ACCESS_KEY_ID": "id", "GCS_HMAC_SECRET": "secret",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "https://storage.googleapis.com/bucket", u.bucket)
+	assert.Equal(t, "auto", u.signer.Region)
+	assert.Regexp(t, `^\d{8}T\d{6}Z-[0-9a-f]{6}$`, u.prefix)
+
+	_, err = newResultsUploader("gs://bucket", awsEnv)
+	assert.EqualError(t, err, "no credentials were set for uploading to gs://")
+	_, err = newResultsUploader("https://bucket", awsEnv)
+	assert.EqualError(t, err, `unsupported upload URL "https://bucket", only s3:// and gs:// ones are supported`)
+	_, err = newResultsUploader("s3:///prefix", awsEnv)
+	assert.EqualError(t, err, `the upload URL "s3:///prefix" has no bucket`)
+}
+
+func TestResultsUploader(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	uploaded := map[string]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"),
+			"AWS4-HMAC-SHA256 Credential=id/"), r.Header.Get("Authorization"))
+		assert.NotEmpty(t, r.Header.Get("X-Amz-Content-Sha256"))
+		if strings.Contains(r.URL.Path, "fail") {
+			rw.WriteHeader(http.StatusForbidden)
+			_, _ = rw.Write([]byte("AccessDenied"))
+			return
+		}
+		mu.Lock()
+		uploaded[r.URL.Path] = r.Header.Get("Content-Type") + " " + string(body)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	u, err := newResultsUploader("s3://bucket/ci", map[string]string{
+		"AWS_ACCESS_KEY_ID": "id", "AWS_SECRET_ACCESS_KEY": "secret", "AWS_REGION": "eu-west-1",
+		"K6_UPLOAD_ENDPOINT": srv.URL + "/", "K6_RUN_ID": "run 1",
+	})
+	require.NoError(t, err)
+
+	fs := newRecordingFs(afero.NewMemMapFs())
+	require.NoError(t, afero.WriteFile(fs, "out/results.json", []byte(`{"metric":"vus"}`), 0o666))
+	require.NoError(t, afero.WriteFile(fs, "summary.html", []byte("<html>"), 0o666))
+	require.NoError(t, afero.WriteFile(fs, "fail.csv", []byte("a,b"), 0o666))
+
+	objectURL, err := u.upload(context.Background(), fs, "out/results.json")
+	require.NoError(t, err)
+	assert.Equal(t, srv.URL+"/bucket/ci/run%201/results.json", objectURL)
+
+	_, err = u.upload(context.Background(), fs, "fail.csv")
+	assert.EqualError(t, err, "the object store responded with 403 Forbidden: AccessDenied")
+
+	u.uploadAll(context.Background(), testutils.NewLogger(t), fs, fs.Files())
+	assert.Equal(t, map[string]string{
+		"/bucket/ci/run 1/results.json": `application/json {"metric":"vus"}`,
+		"/bucket/ci/run 1/summary.html": "text/html; charset=utf-8 <html>",
+	}, uploaded)
+}
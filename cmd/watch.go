@@ -0,0 +1,319 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+	"github.com/spf13/pflag"
+
+	"go.k6.io/k6/errext"
+	"go.k6.io/k6/errext/exitcodes"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/fsext"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/lib/types"
+)
+
+// watchInterval is how often the watched files are checked for changes.
+const watchInterval = 500 * time.Millisecond
+
+// watchSmokeArgs run a single iteration, unless the execution is configured by flags.
+var watchSmokeArgs = []string{"--vus", "1", "--iterations", "1"} //nolint:gochecknoglobals
+
+// getWatchRunArgs returns the arguments of the k6 run commands of the watch
+// mode, from the arguments of k6 itself without the --watch flag.
+func getWatchRunArgs(osArgs []string, flags *pflag.FlagSet, summaryExport string) []string {
+	args := make([]string, 0, len(osArgs)+len(watchSmokeArgs)+2)
+	for _, arg := range osArgs {
+		if arg == "--watch" || arg == "--watch=true" || arg == "--watch=false" {
+			continue
+		}
+		args = append(args, arg)
+	}
+	profiled := false
+	for _, name := range []string{"vus", "iterations", "duration", "stage"} {
+		profiled = profiled || flags.Changed(name)
+	}
+	if !profiled {
+		args = append(args, watchSmokeArgs...)
+	}
+	return append(args, "--summary-export", summaryExport)
+}
+
+// getScriptFiles returns the paths of the script and of all local files that
+// it imports or opens in the init context, by loading it.
+func getScriptFiles(filename, runType string, rtOpts lib.RuntimeOptions) []string {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	files := []string{}
+	if abs, err := filepath.Abs(filename); err == nil {
+		files = append(files, abs)
+	}
+
+	src, filesystems, err := readSource(filename, logger)
+	if err != nil {
+		return files
+	}
+	registry := metrics.NewRegistry()
+	// even if the script fails to load, the files read until then are watched
+	_, _ = newRunner(logger, src, runType, filesystems, rtOpts, metrics.RegisterBuiltinMetrics(registry), registry)
+	cachedFs, ok := filesystems["file"].(*fsext.CacheOnReadFs)
+	if !ok {
+		return files
+	}
+	_ = afero.Walk(cachedFs.GetCachingFs(), "/", func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && path != files[0] {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files
+}
+
+// fileStamp identifies a version of a file.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func getFileStamps(files []string) map[string]fileStamp {
+	stamps := make(map[string]fileStamp, len(files))
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			stamps[file] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+		} else {
+			stamps[file] = fileStamp{}
+		}
+	}
+	return stamps
+}
+
+func stampsChanged(previous map[string]fileStamp) bool {
+	files := make([]string, 0, len(previous))
+	for file := range previous {
+		files = append(files, file)
+	}
+	for file, stamp := range getFileStamps(files) {
+		if stamp != previous[file] {
+			return true
+		}
+	}
+	return false
+}
+
+// getMainValue returns the value of a metric of a summary that is shown in
+// the changes from the previous run.
+func getMainValue(values map[string]float64) (string, float64, bool) {
+	for _, method := range []string{"p(95)", "count", "rate", "value"} {
+		if v, ok := values[method]; ok {
+			return method, v, true
+		}
+	}
+	return "", 0, false
+}
+
+// printSummaryChanges prints the main values of the metrics which changed
+// between the summaries of two runs.
+func printSummaryChanges(w io.Writer, previous, current lib.Baseline) {
+	names := make([]string, 0, len(current))
+	for name := range current {
+		names = append(names, name)
+	}
+	for name := range previous {
+		if _, ok := current[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	lines := []string{}
+	for _, name := range names {
+		method, before, hadBefore := getMainValue(previous[name])
+		if _, ok := current[name]; !ok {
+			lines = append(lines, fmt.Sprintf("%s: removed", name))
+			continue
+		}
+		currentMethod, after, _ := getMainValue(current[name])
+		switch {
+		case !hadBefore:
+			lines = append(lines, fmt.Sprintf("%s %s: new, %.6g", name, currentMethod, after))
+		case before != after:
+			change := ""
+			if before != 0 {
+				change = fmt.Sprintf(" (%+.2f%%)", (after-before)/before*100)
+			}
+			lines = append(lines, fmt.Sprintf("%s %s: %.6g -> %.6g%s", name, method, before, after, change))
+		}
+	}
+
+	if len(lines) == 0 {
+		fprintf(w, "\n     no metric changes from the previous run\n")
+		return
+	}
+	fprintf(w, "\n     changes from the previous run:\n")
+	for _, line := range lines {
+		fprintf(w, "     %s\n", line)
+	}
+}
+
+// watchRunner runs a script with k6 run every time that it or its imports
+// change, and shows how the metrics changed from the previous run.
+type watchRunner struct {
+	runCommand     suiteCommandRunner
+	getArgs        func(summaryExport string) []string
+	getFiles       func() []string
+	stdout, stderr io.Writer
+	logger         logrus.FieldLogger
+	interval       time.Duration
+
+	runs     int
+	previous lib.Baseline
+}
+
+func (wr *watchRunner) runOnce(summaryDir string) {
+	wr.runs++
+	summaryExport := filepath.Join(summaryDir, fmt.Sprintf("%d.json", wr.runs))
+	fprintf(wr.stdout, "\n=== run %d at %s ===\n", wr.runs, time.Now().Format("15:04:05"))
+
+	start := time.Now()
+	result := suiteResult{}
+	exitCode, err := wr.runCommand(wr.getArgs(summaryExport), wr.stdout, wr.stderr)
+	result.ExitCode = exitCode
+	if err != nil {
+		result.Error = err.Error()
+	}
+	result.Duration = types.Duration(time.Since(start).Round(time.Millisecond))
+	fprintf(wr.stdout, "\n     %s in %s\n", getSuiteStatus(result), result.Duration)
+
+	data, err := afero.ReadFile(afero.NewOsFs(), summaryExport)
+	if err != nil {
+		return // the run didn't get to the summary
+	}
+	current, err := lib.ParseBaseline(data)
+	if err != nil {
+		wr.logger.WithError(err).Warn("Couldn't parse the summary of the run")
+		return
+	}
+	if wr.previous != nil {
+		printSummaryChanges(wr.stdout, wr.previous, current)
+	}
+	wr.previous = current
+}
+
+// run runs the script, and again after every change of the watched files,
+// until the context is done.
+func (wr *watchRunner) run(ctx context.Context) error {
+	summaryDir, err := os.MkdirTemp("", "k6-watch-")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(summaryDir) }()
+
+	ticker := time.NewTicker(wr.interval)
+	defer ticker.Stop()
+	for {
+		// the files are stamped before the run, so no changes during it are missed
+		files := wr.getFiles()
+		stamps := getFileStamps(files)
+		wr.runOnce(summaryDir)
+		if ctx.Err() != nil {
+			return nil
+		}
+		fprintf(wr.stdout, "\nWatching %d files for changes, press Ctrl+C to stop...\n", len(files))
+
+		for changed := false; !changed; {
+			select {
+			case <-ticker.C:
+				changed = stampsChanged(stamps)
+			case <-ctx.Done():
+				return nil
+			}
+		}
+		// wait for the editor to finish writing all of the changes
+		for stampsChanged(stamps) {
+			stamps = getFileStamps(files)
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+}
+
+// runWatch is k6 run --watch, which re-runs the script, with a single
+// iteration by default, every time that it or its imports change.
+func runWatch(
+	ctx context.Context, flags *pflag.FlagSet, args []string, logger *logrus.Logger, globalFlags *commandFlags,
+) error {
+	if args[0] == "-" {
+		return errext.WithExitCodeIfNone(
+			fmt.Errorf("a script from the standard input can't be watched"), exitcodes.InvalidConfig)
+	}
+	profile, err := getProfile(afero.NewOsFs(), globalFlags)
+	if err != nil {
+		return err
+	}
+	rtOpts, err := getRuntimeOptions(flags, buildEnvMap(os.Environ()), profile)
+	if err != nil {
+		return err
+	}
+
+	runCtx, runCancel := context.WithCancel(ctx)
+	defer runCancel()
+	sigC := make(chan os.Signal, 1)
+	signal.Notify(sigC, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigC)
+	go func() {
+		select {
+		case sig := <-sigC:
+			logger.WithField("sig", sig).Debug("Stopping the watch mode in response to signal...")
+			runCancel()
+		case <-runCtx.Done():
+		}
+	}()
+
+	runner := &watchRunner{
+		runCommand: runK6Command,
+		getArgs: func(summaryExport string) []string {
+			return getWatchRunArgs(os.Args[1:], flags, summaryExport)
+		},
+		getFiles: func() []string {
+			return getScriptFiles(args[0], globalFlags.runType, rtOpts)
+		},
+		stdout:   globalFlags.stdout,
+		stderr:   globalFlags.stderr,
+		logger:   logger,
+		interval: watchInterval,
+	}
+	return runner.run(runCtx)
+}
@@ -0,0 +1,154 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/testutils"
+)
+
+func TestGetWatchRunArgs(t *testing.T) {
+	t.Parallel()
+
+	flags := runCmdFlagSet(newCommandFlags())
+	require.NoError(t, flags.Parse([]string{"script.js"}))
+	assert.Equal(t,
+		[]string{"run", "script.js", "--vus", "1", "--iterations", "1", "--summary-export", "summary.json"},
+		getWatchRunArgs([]string{"run", "--watch", "script.js"}, flags, "summary.json"))
+
+	// the execution flags make their own smoke profile
+	flags = runCmdFlagSet(newCommandFlags())
+	require.NoError(t, flags.Parse([]string{"--vus", "2", "--duration", "5s", "script.js"}))
+	assert.Equal(t,
+		[]string{"-v", "run", "--vus", "2", "--duration", "5s", "script.js", "--summary-export", "summary.json"},
+		getWatchRunArgs([]string{"-v", "run", "--vus", "2", "--watch=true", "--duration", "5s", "script.js"},
+			flags, "summary.json"))
+}
+
+func TestGetScriptFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	script := filepath.Join(dir, "script.js")
+	require.NoError(t, os.WriteFile(script, []byte(`
+		import { x } from "./lib/x.js";
+		const data = open("./data.txt");
+		export default function () {}
+	`), 0o600))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "lib"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "lib", "x.js"), []byte(`export const x = 1;`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "data.txt"), []byte(`data`), 0o600))
+
+	files := getScriptFiles(script, "", lib.RuntimeOptions{})
+	assert.ElementsMatch(t, []string{
+		script, filepath.Join(dir, "lib", "x.js"), filepath.Join(dir, "data.txt"),
+	}, files)
+	assert.Equal(t, script, files[0])
+}
+
+func TestPrintSummaryChanges(t *testing.T) {
+	t.Parallel()
+
+	previous := lib.Baseline{
+		"http_req_duration": {"avg": 100, "p(95)": 200},
+		"checks":            {"rate": 1},
+		"iterations":        {"count": 10, "rate": 1},
+		"old":               {"value": 1},
+	}
+	current := lib.Baseline{
+		"http_req_duration": {"avg": 90, "p(95)": 150},
+		"checks":            {"rate": 0.5},
+		"iterations":        {"count": 10, "rate": 2},
+		"new":               {"value": 3},
+	}
+	out := &bytes.Buffer{}
+	printSummaryChanges(out, previous, current)
+	assert.Equal(t, `
+     changes from the previous run:
+     checks rate: 1 -> 0.5 (-50.00%)
+     http_req_duration p(95): 200 -> 150 (-25.00%)
+     new value: new, 3
+     old: removed
+`, out.String())
+
+	out.Reset()
+	printSummaryChanges(out, current, current)
+	assert.Equal(t, "\n     no metric changes from the previous run\n", out.String())
+}
+
+func TestWatchRunner(t *testing.T) {
+	t.Parallel()
+
+	script := filepath.Join(t.TempDir(), "script.js")
+	require.NoError(t, os.WriteFile(script, []byte(`1`), 0o600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runs := make(chan []string)
+	var count int
+	runner := &watchRunner{
+		runCommand: func(args []string, stdout, stderr io.Writer) (int, error) {
+			count++
+			summary := `{"metrics": {"iterations": {"count": ` + strings.Repeat("1", count) + `}}}`
+			assert.NoError(t, os.WriteFile(args[len(args)-1], []byte(summary), 0o600))
+			runs <- args
+			return 0, nil
+		},
+		getArgs:  func(summaryExport string) []string { return []string{"run", summaryExport} },
+		getFiles: func() []string { return []string{script} },
+		stdout:   &bytes.Buffer{},
+		stderr:   io.Discard,
+		logger:   testutils.NewLogger(t),
+		interval: 10 * time.Millisecond,
+	}
+	done := make(chan error)
+	go func() { done <- runner.run(ctx) }()
+
+	<-runs
+	select {
+	case <-runs:
+		t.Fatal("the script shouldn't run again without changes")
+	case <-time.After(100 * time.Millisecond):
+	}
+	require.NoError(t, os.WriteFile(script, []byte(`22`), 0o600))
+	<-runs
+
+	// the run in progress is finished before the runner stops
+	cancel()
+	require.NoError(t, <-done)
+	output := runner.stdout.(*bytes.Buffer).String()
+	assert.Contains(t, output, "Watching 1 files for changes")
+	assert.Contains(t, output, "=== run 2 at ")
+	assert.Contains(t, output, "✓ passed in ")
+	assert.Contains(t, output, "iterations count: 1 -> 11 (+1000.00%)")
+}
@@ -0,0 +1,838 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"go.k6.io/k6/lib/consts"
+)
+
+// methods are the methods of the operations of the paths, in the order of the specification.
+var methods = map[string]bool{ //nolint:gochecknoglobals
+	"get": true, "put": true, "post": true, "delete": true, "options": true, "head": true, "patch": true, "trace": true,
+}
+
+// formatExamples are the examples of the strings of the formats which have some.
+var formatExamples = map[string]string{ //nolint:gochecknoglobals
+	"date":      "2022-01-01",
+	"date-time": "2022-01-01T00:00:00Z",
+	"email":     "user@example.com",
+	"hostname":  "example.com",
+	"ipv4":      "127.0.0.1",
+	"ipv6":      "::1",
+	"uri":       "https://example.com",
+	"url":       "https://example.com",
+	"uuid":      "00000000-0000-0000-0000-000000000000",
+	"byte":      "ZXhhbXBsZQ==",
+	"password":  "password",
+}
+
+var ( //nolint:gochecknoglobals
+	identifierRe     = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+	pathParamRe      = regexp.MustCompile(`{([^}]+)}`)
+	reservedIdents   = map[string]bool{"res": true, "body": true, "BASE_URL": true}
+	reservedJSIdents = strings.Fields(`break case catch class const continue debugger default delete do else
+		export extends false finally for function if import in instanceof new null return super switch this
+		throw true try typeof var void while with yield let static enum await implements package protected
+		interface private public check group http encoding`)
+)
+
+// Options are the options of the scripts generated from the specifications.
+type Options struct {
+	// Tags, if set, are the tags of the operations for which requests are generated
+	Tags []string
+}
+
+// securityScheme is a security scheme of the operations, the credentials of which are
+// in the constants of the script.
+type securityScheme struct {
+	ident string // the name of the constant, or the prefix of the ones of the basic authentication
+	kind  string // apiKey, basic or a scheme of the Authorization header, like Bearer
+	in    string // header, query or cookie, for the API keys
+	param string // the name of the header, query parameter or cookie of the API keys
+}
+
+// parameter is a parameter of an operation, with the variable of its value.
+type parameter struct {
+	name, in, variable string
+	value              interface{}
+}
+
+type generator struct {
+	spec    *object
+	swagger bool
+	// schemes are the security schemes by name, and used the ones which the operations use in their order
+	schemes map[string]*securityScheme
+	used    []*securityScheme
+}
+
+// Generate returns a script with a group for each operation of the OpenAPI 3 or Swagger 2 specification,
+// in YAML or JSON, which makes its request with examples of its parameters and body.
+//nolint:funlen
+func Generate(data []byte, opts Options) (string, error) {
+	spec, err := parse(data)
+	if err != nil {
+		return "", fmt.Errorf("couldn't parse the specification: %w", err)
+	}
+	g := &generator{spec: spec, schemes: make(map[string]*securityScheme)}
+	// the versions can be numbers, when they aren't quoted in YAML
+	switch swagger := fmt.Sprint(spec.get("swagger")); {
+	case strings.HasPrefix(fmt.Sprint(spec.get("openapi")), "3"):
+	case swagger == "2" || swagger == "2.0":
+		g.swagger = true
+	default:
+		return "", fmt.Errorf("the specification isn't an OpenAPI 3 or a Swagger 2 one")
+	}
+
+	var groups strings.Builder
+	operations := 0
+	paths := spec.object("paths")
+	if paths == nil {
+		return "", fmt.Errorf("the specification has no paths")
+	}
+	for _, path := range paths.keys {
+		item, err := resolve(spec, paths.object(path))
+		if err != nil {
+			return "", err
+		}
+		for _, method := range item.keys {
+			op := item.object(method)
+			if !methods[method] || op == nil || !hasTag(op, opts.Tags) {
+				continue
+			}
+			code, err := g.operation(path, method, item, op)
+			if err != nil {
+				return "", fmt.Errorf("couldn't generate the request of %s %s: %w", strings.ToUpper(method), path, err)
+			}
+			groups.WriteString(code)
+			operations++
+		}
+	}
+	if operations == 0 {
+		return "", fmt.Errorf("the specification has no operations")
+	}
+
+	var w strings.Builder
+	w.WriteString("import { check, group } from 'k6';\n")
+	for _, s := range g.used {
+		if s.kind == "basic" {
+			w.WriteString("import encoding from 'k6/encoding';\n")
+			break
+		}
+	}
+	w.WriteString("import http from 'k6/http';\n\n")
+	info := spec.object("info")
+	title := oneLine(info.string("title"))
+	if version := info.get("version"); version != nil {
+		title = strings.TrimSpace(title + " " + oneLine(fmt.Sprint(version)))
+	}
+	if title == "" {
+		title = "the specification"
+	}
+	fmt.Fprintf(&w, "// Generated by k6 generate openapi v%s from %s\n\n", consts.Version, title)
+	fmt.Fprintf(&w, "const BASE_URL = __ENV.BASE_URL || %s;\n", literal(g.baseURL(), ""))
+	if len(g.used) > 0 {
+		w.WriteString("\n// the credentials of the security schemes, from the environment variables\n")
+		for _, s := range g.used {
+			if s.kind == "basic" {
+				fmt.Fprintf(&w, "const %[1]s_USERNAME = __ENV.%[1]s_USERNAME;\n", s.ident)
+				fmt.Fprintf(&w, "const %[1]s_PASSWORD = __ENV.%[1]s_PASSWORD;\n", s.ident)
+			} else {
+				fmt.Fprintf(&w, "const %[1]s = __ENV.%[1]s;\n", s.ident)
+			}
+		}
+	}
+	w.WriteString("\nexport let options = {\n\tthresholds: {\n")
+	w.WriteString("\t\thttp_req_failed: ['rate<0.01'],\n\t\tchecks: ['rate>0.99'],\n\t},\n};\n\n")
+	w.WriteString("export default function() {\n\tlet res;\n")
+	w.WriteString(groups.String())
+	w.WriteString("}\n")
+	return w.String(), nil
+}
+
+func hasTag(op *object, tags []string) bool {
+	if len(tags) == 0 {
+		return true
+	}
+	for _, t := range op.array("tags") {
+		for _, tag := range tags {
+			if t == tag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// baseURL returns the URL of the first server of the specification, with the default values of its variables.
+// The relative ones and the missing one are relative to http://localhost.
+func (g *generator) baseURL() string {
+	var base string
+	if g.swagger {
+		scheme := "http"
+		if schemes := g.spec.array("schemes"); len(schemes) > 0 {
+			scheme = fmt.Sprint(schemes[0])
+		}
+		host := g.spec.string("host")
+		if host == "" {
+			host = "localhost"
+		}
+		base = scheme + "://" + host + g.spec.string("basePath")
+	} else if servers := g.spec.array("servers"); len(servers) > 0 {
+		server, _ := servers[0].(*object)
+		base = server.string("url")
+		variables := server.object("variables")
+		if variables != nil {
+			for _, name := range variables.keys {
+				value := fmt.Sprint(variables.object(name).get("default"))
+				base = strings.ReplaceAll(base, "{"+name+"}", value)
+			}
+		}
+	}
+	if u, err := url.Parse(base); err != nil || !u.IsAbs() {
+		base = "http://localhost" + base
+	}
+	return strings.TrimSuffix(base, "/")
+}
+
+// operation returns the group of the operation.
+//nolint:funlen,gocognit,cyclop
+func (g *generator) operation(path, method string, item, op *object) (string, error) {
+	params, err := g.parameters(item, op)
+	if err != nil {
+		return "", err
+	}
+	schemes, err := g.security(op)
+	if err != nil {
+		return "", err
+	}
+
+	var w strings.Builder
+	title := strings.ToUpper(method) + " " + path
+	if summary := oneLine(op.string("summary")); summary != "" {
+		title += " - " + summary
+	}
+	fmt.Fprintf(&w, "\n\tgroup(%s, function() {\n", literal(strings.ReplaceAll(title, "::", ":"), ""))
+	variables := make(map[string]string)
+	for _, p := range params {
+		if p.in == "body" || p.in == "formData" {
+			continue
+		}
+		fmt.Fprintf(&w, "\t\tlet %s = %s;\n", p.variable, literal(p.value, "\t\t"))
+		variables[p.in+":"+p.name] = p.variable
+	}
+
+	body, contentType, err := g.body(op, params)
+	if err != nil {
+		return "", err
+	}
+	bodyArg := "null"
+	if body != nil {
+		if strings.HasPrefix(contentType, "multipart/") {
+			w.WriteString("\t\t// the files of multipart/form-data bodies are http.file() values\n")
+		}
+		fmt.Fprintf(&w, "\t\tlet body = %s;\n", literal(body.value, "\t\t"))
+		bodyArg = "body"
+		if isJSON(contentType) {
+			bodyArg = "JSON.stringify(body)"
+		}
+	}
+
+	// the URL, with the path parameters and the query ones
+	u := "`${BASE_URL}" + templateText(pathParamRe.ReplaceAllStringFunc(path, func(m string) string {
+		if v, ok := variables["path:"+m[1:len(m)-1]]; ok {
+			return "\x00" + v + "\x00"
+		}
+		return m
+	})) + "`"
+	var query []string
+	for _, p := range params {
+		if p.in == "query" {
+			query = append(query, url.QueryEscape(p.name)+"=${"+p.variable+"}")
+		}
+	}
+	var headers, cookies []string
+	if isJSON(contentType) || (contentType != "" && !strings.HasPrefix(contentType, "multipart/") &&
+		contentType != "application/x-www-form-urlencoded") {
+		headers = append(headers, fmt.Sprintf("%s: %s", literal("Content-Type", ""), literal(contentType, "")))
+	}
+	for _, p := range params {
+		switch p.in {
+		case "header":
+			headers = append(headers, fmt.Sprintf("%s: %s", literal(p.name, ""), p.variable))
+		case "cookie":
+			cookies = append(cookies, fmt.Sprintf("%s: %s", literal(p.name, ""), p.variable))
+		}
+	}
+	for _, s := range schemes {
+		switch {
+		case s.kind == "apiKey" && s.in == "query":
+			query = append(query, url.QueryEscape(s.param)+"=${encodeURIComponent("+s.ident+")}")
+		case s.kind == "apiKey" && s.in == "cookie":
+			cookies = append(cookies, fmt.Sprintf("%s: %s", literal(s.param, ""), s.ident))
+		case s.kind == "apiKey":
+			headers = append(headers, fmt.Sprintf("%s: %s", literal(s.param, ""), s.ident))
+		case s.kind == "basic":
+			headers = append(headers, fmt.Sprintf(
+				"Authorization: `Basic ${encoding.b64encode(`${%[1]s_USERNAME}:${%[1]s_PASSWORD}`)}`", s.ident))
+		default:
+			headers = append(headers, fmt.Sprintf("Authorization: `%s ${%s}`", s.kind, s.ident))
+		}
+	}
+	name := ""
+	if len(query) > 0 {
+		name = u
+		u = u[:len(u)-1] + "?" + strings.Join(query, "&") + "`"
+	}
+	// the requests of the paths with parameters are tagged with the path, so that their metrics are grouped
+	if strings.Contains(path, "{") {
+		name = "`${BASE_URL}" + templateText(path) + "`"
+	}
+
+	var paramsFields []string
+	if len(headers) > 0 {
+		paramsFields = append(paramsFields, "headers: { "+strings.Join(headers, ", ")+" }")
+	}
+	if len(cookies) > 0 {
+		paramsFields = append(paramsFields, "cookies: { "+strings.Join(cookies, ", ")+" }")
+	}
+	if name != "" {
+		paramsFields = append(paramsFields, "tags: { name: "+name+" }")
+	}
+	paramsArg := ""
+	if len(paramsFields) > 0 {
+		paramsArg = "{\n\t\t\t" + strings.Join(paramsFields, ",\n\t\t\t") + ",\n\t\t}"
+	}
+	switch {
+	case method == "get" && paramsArg == "":
+		fmt.Fprintf(&w, "\t\tres = http.get(%s);\n", u)
+	case method == "get":
+		fmt.Fprintf(&w, "\t\tres = http.get(%s, %s);\n", u, paramsArg)
+	case paramsArg == "":
+		fmt.Fprintf(&w, "\t\tres = http.request(%q, %s, %s);\n", strings.ToUpper(method), u, bodyArg)
+	default:
+		fmt.Fprintf(&w, "\t\tres = http.request(%q, %s, %s, %s);\n", strings.ToUpper(method), u, bodyArg, paramsArg)
+	}
+
+	status := expectedStatus(op)
+	if code, err := strconv.Atoi(status); err == nil {
+		fmt.Fprintf(&w, "\t\tcheck(res, { \"status is %d\": (r) => r.status === %d });\n", code, code)
+	} else {
+		w.WriteString("\t\tcheck(res, { \"status is 2xx\": (r) => r.status >= 200 && r.status < 300 });\n")
+	}
+	w.WriteString("\t});\n")
+	return w.String(), nil
+}
+
+// parameters returns the parameters of the operation and of its path, with the variables of their examples.
+func (g *generator) parameters(item, op *object) ([]*parameter, error) {
+	var params []*parameter
+	byKey := make(map[string]int)
+	for _, list := range [][]interface{}{item.array("parameters"), op.array("parameters")} {
+		for _, v := range list {
+			o, _ := v.(*object)
+			o, err := resolve(g.spec, o)
+			if err != nil {
+				return nil, err
+			}
+			if o == nil {
+				continue
+			}
+			p := &parameter{name: o.string("name"), in: o.string("in")}
+			switch {
+			case p.in == "body":
+				if p.value, err = g.example(o.object("schema"), nil); err != nil {
+					return nil, err
+				}
+			case g.swagger:
+				// the parameters of Swagger 2 are their own schemas
+				if p.value, err = g.example(o, nil); err != nil {
+					return nil, err
+				}
+			default:
+				if p.value, err = g.mediaExample(o); err != nil {
+					return nil, err
+				}
+			}
+			key := p.in + ":" + p.name
+			if i, ok := byKey[key]; ok {
+				params[i] = p // the ones of the operation override the ones of the path
+				continue
+			}
+			byKey[key] = len(params)
+			params = append(params, p)
+		}
+	}
+
+	used := make(map[string]bool)
+	for _, p := range params {
+		if p.in != "body" && p.in != "formData" {
+			p.variable = identifier(p.name, used)
+		}
+	}
+	return params, nil
+}
+
+// body returns the example of the body of the operation, with its content type. The bodies of Swagger 2
+// are its body parameter or its form data parameters.
+func (g *generator) body(op *object, params []*parameter) (*parameter, string, error) {
+	if g.swagger {
+		consumes := op.array("consumes")
+		if consumes == nil {
+			consumes = g.spec.array("consumes")
+		}
+		form := &object{values: make(map[string]interface{})}
+		for _, p := range params {
+			switch p.in {
+			case "body":
+				contentType := "application/json"
+				for _, c := range consumes {
+					if isJSON(fmt.Sprint(c)) {
+						contentType = fmt.Sprint(c)
+						break
+					}
+				}
+				return p, contentType, nil
+			case "formData":
+				form.keys = append(form.keys, p.name)
+				form.values[p.name] = p.value
+			}
+		}
+		if len(form.keys) == 0 {
+			return nil, "", nil
+		}
+		contentType := "application/x-www-form-urlencoded"
+		for _, c := range consumes {
+			if c == "multipart/form-data" {
+				contentType = "multipart/form-data"
+			}
+		}
+		return &parameter{value: form}, contentType, nil
+	}
+
+	requestBody, err := resolve(g.spec, op.object("requestBody"))
+	if err != nil || requestBody == nil {
+		return nil, "", err
+	}
+	content := requestBody.object("content")
+	if content == nil || len(content.keys) == 0 {
+		return nil, "", nil
+	}
+	contentType := content.keys[0]
+	for _, preferred := range []func(string) bool{
+		isJSON,
+		func(t string) bool { return t == "application/x-www-form-urlencoded" },
+		func(t string) bool { return t == "multipart/form-data" },
+	} {
+		if i := indexOf(content.keys, preferred); i >= 0 {
+			contentType = content.keys[i]
+			break
+		}
+	}
+	value, err := g.mediaExample(content.object(contentType))
+	if err != nil {
+		return nil, "", err
+	}
+	if !isJSON(contentType) && !strings.HasSuffix(contentType, "form-urlencoded") &&
+		!strings.HasPrefix(contentType, "multipart/") {
+		// the other bodies, like the XML and the text ones, are strings
+		if _, ok := value.(string); !ok {
+			value = ""
+		}
+	}
+	return &parameter{value: value}, contentType, nil
+}
+
+func indexOf(values []string, f func(string) bool) int {
+	for i, v := range values {
+		if f(v) {
+			return i
+		}
+	}
+	return -1
+}
+
+func isJSON(contentType string) bool {
+	return contentType == "application/json" || strings.HasSuffix(contentType, "+json")
+}
+
+// mediaExample returns the example of a parameter or a media type of OpenAPI 3, which is its example,
+// the first of its examples, or the example of its schema.
+func (g *generator) mediaExample(o *object) (interface{}, error) {
+	if v := o.get("example"); v != nil {
+		return v, nil
+	}
+	if examples := o.object("examples"); examples != nil && len(examples.keys) > 0 {
+		example, err := resolve(g.spec, examples.object(examples.keys[0]))
+		if err != nil {
+			return nil, err
+		}
+		if v := example.get("value"); v != nil {
+			return v, nil
+		}
+	}
+	return g.example(o.object("schema"), nil)
+}
+
+// example returns an example of the schema, which is its example, its default or its first allowed value,
+// or one made from its type. The refs are the references of the schemas of which it's a part of the example,
+// the ones which refer to themselves have no examples in their own examples.
+//nolint:funlen,gocognit,cyclop
+func (g *generator) example(schema *object, refs []string) (interface{}, error) {
+	if ref := schema.string("$ref"); ref != "" {
+		for _, r := range refs {
+			if r == ref {
+				return nil, nil
+			}
+		}
+		refs = append(refs[:len(refs):len(refs)], ref)
+	}
+	schema, err := resolve(g.spec, schema)
+	if err != nil || schema == nil {
+		return nil, err
+	}
+	for _, key := range []string{"example", "default"} {
+		if v := schema.get(key); v != nil {
+			return v, nil
+		}
+	}
+	if enum := schema.array("enum"); len(enum) > 0 {
+		return enum[0], nil
+	}
+	if allOf := schema.array("allOf"); len(allOf) > 0 {
+		merged := &object{values: make(map[string]interface{})}
+		for _, s := range allOf {
+			s, _ := s.(*object)
+			v, err := g.example(s, refs)
+			if err != nil {
+				return nil, err
+			}
+			if o, ok := v.(*object); ok {
+				for _, key := range o.keys {
+					if _, ok := merged.values[key]; !ok {
+						merged.keys = append(merged.keys, key)
+					}
+					merged.values[key] = o.values[key]
+				}
+			}
+		}
+		return merged, nil
+	}
+	for _, key := range []string{"oneOf", "anyOf"} {
+		if alternatives := schema.array(key); len(alternatives) > 0 {
+			first, _ := alternatives[0].(*object)
+			return g.example(first, refs)
+		}
+	}
+
+	typ := schema.string("type")
+	if types := schema.array("type"); len(types) > 0 {
+		// the types of OpenAPI 3.1 can be arrays, with null
+		typ = fmt.Sprint(types[0])
+		if typ == "null" && len(types) > 1 {
+			typ = fmt.Sprint(types[1])
+		}
+	}
+	if typ == "" {
+		switch {
+		case schema.object("properties") != nil:
+			typ = "object"
+		case schema.get("items") != nil:
+			typ = "array"
+		}
+	}
+	switch typ {
+	case "object":
+		o := &object{values: make(map[string]interface{})}
+		properties := schema.object("properties")
+		if properties == nil {
+			return o, nil
+		}
+		for _, name := range properties.keys {
+			property, err := resolve(g.spec, properties.object(name))
+			if err != nil {
+				return nil, err
+			}
+			if property.get("readOnly") == true {
+				continue // they're only in the responses
+			}
+			v, err := g.example(properties.object(name), refs)
+			if err != nil {
+				return nil, err
+			}
+			if v == nil {
+				continue
+			}
+			o.keys = append(o.keys, name)
+			o.values[name] = v
+		}
+		return o, nil
+	case "array":
+		v, err := g.example(schema.object("items"), refs)
+		if err != nil || v == nil {
+			return []interface{}{}, err
+		}
+		return []interface{}{v}, nil
+	case "integer", "number":
+		if minimum := schema.get("minimum"); minimum != nil {
+			return minimum, nil
+		}
+		return 0, nil
+	case "boolean":
+		return false, nil
+	case "string":
+		if v, ok := formatExamples[schema.string("format")]; ok {
+			return v, nil
+		}
+		return "string", nil
+	case "file":
+		return "", nil
+	default:
+		return nil, nil
+	}
+}
+
+// security returns the security schemes of the first security requirement of the operation, or of the
+// specification if the operation doesn't have its own.
+func (g *generator) security(op *object) ([]*securityScheme, error) {
+	requirements, ok := op.get("security").([]interface{})
+	if !ok {
+		requirements = g.spec.array("security")
+	}
+	if len(requirements) == 0 {
+		return nil, nil
+	}
+	requirement, _ := requirements[0].(*object)
+	if requirement == nil {
+		return nil, nil
+	}
+	definitions := g.spec.object("components").object("securitySchemes")
+	if g.swagger {
+		definitions = g.spec.object("securityDefinitions")
+	}
+
+	var schemes []*securityScheme
+	for _, name := range requirement.keys {
+		if s, ok := g.schemes[name]; ok {
+			schemes = append(schemes, s)
+			continue
+		}
+		definition, err := resolve(g.spec, definitions.object(name))
+		if err != nil {
+			return nil, err
+		}
+		if definition == nil {
+			return nil, fmt.Errorf("the security scheme %q isn't defined", name)
+		}
+		s := &securityScheme{ident: constantName(name), kind: "Bearer"}
+		switch definition.string("type") {
+		case "apiKey":
+			s.kind, s.in, s.param = "apiKey", definition.string("in"), definition.string("name")
+		case "basic":
+			s.kind = "basic"
+		case "http":
+			switch scheme := strings.ToLower(definition.string("scheme")); scheme {
+			case "basic":
+				s.kind = "basic"
+			case "bearer", "":
+			default:
+				s.kind = strings.ToUpper(scheme[:1]) + scheme[1:]
+			}
+		}
+		for _, other := range g.used {
+			if other.ident == s.ident {
+				s.ident += "_" + strconv.Itoa(len(g.used))
+			}
+		}
+		g.schemes[name] = s
+		g.used = append(g.used, s)
+		schemes = append(schemes, s)
+	}
+	return schemes, nil
+}
+
+// expectedStatus returns the first successful status of the responses of the operation, which is "2XX"
+// if it isn't a specific one.
+func expectedStatus(op *object) string {
+	responses := op.object("responses")
+	var codes []string
+	if responses != nil {
+		for _, code := range responses.keys {
+			if strings.HasPrefix(code, "2") {
+				codes = append(codes, code)
+			}
+		}
+	}
+	sort.Strings(codes)
+	if len(codes) == 0 {
+		return "2XX"
+	}
+	return strings.ToUpper(codes[0])
+}
+
+// identifier returns a JS identifier for the name, which isn't one of the used ones.
+func identifier(name string, used map[string]bool) string {
+	var b strings.Builder
+	upper := false
+	for _, r := range name {
+		switch {
+		case r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r):
+			if upper {
+				r = unicode.ToUpper(r)
+			}
+			b.WriteRune(r)
+			upper = false
+		default:
+			upper = b.Len() > 0
+		}
+	}
+	ident := b.String()
+	if ident == "" || unicode.IsDigit(rune(ident[0])) {
+		ident = "_" + ident
+	}
+	for _, reserved := range reservedJSIdents {
+		if ident == reserved {
+			ident += "_"
+		}
+	}
+	candidate := ident
+	for i := 2; used[candidate] || reservedIdents[candidate]; i++ {
+		candidate = ident + strconv.Itoa(i)
+	}
+	used[candidate] = true
+	return candidate
+}
+
+// constantName returns the name of the constant of a security scheme, like API_KEY for apiKey.
+func constantName(name string) string {
+	var b strings.Builder
+	var previous rune
+	for _, r := range name {
+		switch {
+		case unicode.IsUpper(r) && (unicode.IsLower(previous) || unicode.IsDigit(previous)):
+			b.WriteByte('_')
+			b.WriteRune(r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(unicode.ToUpper(r))
+		default:
+			if b.Len() > 0 && !strings.HasSuffix(b.String(), "_") {
+				b.WriteByte('_')
+			}
+		}
+		previous = r
+	}
+	constant := strings.Trim(b.String(), "_")
+	if constant == "" || unicode.IsDigit(rune(constant[0])) || reservedIdents[constant] {
+		constant = "AUTH_" + constant
+	}
+	return constant
+}
+
+// templateText escapes the text of a template literal, in which the variables are between NUL characters.
+func templateText(s string) string {
+	var b strings.Builder
+	inVariable := false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == 0:
+			if inVariable {
+				b.WriteByte('}')
+			} else {
+				b.WriteString("${")
+			}
+			inVariable = !inVariable
+		case inVariable:
+			b.WriteByte(c)
+		case c == '`' || c == '\\' || (c == '$' && i+1 < len(s) && s[i+1] == '{'):
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// literal returns the JS literal of the value, with its nested lines indented after the indent.
+func literal(v interface{}, indent string) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case *object:
+		if len(v.keys) == 0 {
+			return "{}"
+		}
+		var b strings.Builder
+		b.WriteString("{\n")
+		for _, key := range v.keys {
+			name := key
+			if !identifierRe.MatchString(key) {
+				name = literal(key, "")
+			}
+			fmt.Fprintf(&b, "%s\t%s: %s,\n", indent, name, literal(v.values[key], indent+"\t"))
+		}
+		b.WriteString(indent + "}")
+		return b.String()
+	case []interface{}:
+		elements := make([]string, len(v))
+		multiline := false
+		for i, e := range v {
+			elements[i] = literal(e, indent+"\t")
+			switch e.(type) {
+			case *object, []interface{}:
+				multiline = true
+			}
+		}
+		if !multiline {
+			return "[" + strings.Join(elements, ", ") + "]"
+		}
+		return "[\n" + indent + "\t" + strings.Join(elements, ",\n"+indent+"\t") + ",\n" + indent + "]"
+	case time.Time:
+		if v.Equal(v.Truncate(24 * time.Hour)) {
+			return literal(v.Format("2006-01-02"), indent)
+		}
+		return literal(v.Format(time.RFC3339Nano), indent)
+	case string:
+		var b bytes.Buffer
+		encoder := json.NewEncoder(&b)
+		encoder.SetEscapeHTML(false)
+		_ = encoder.Encode(v)
+		return strings.TrimSuffix(b.String(), "\n")
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
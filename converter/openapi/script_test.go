@@ -0,0 +1,292 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package openapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/lib/consts"
+)
+
+//nolint:lll
+const petstore = `
+openapi: 3.0.0
+info:
+  title: Petstore
+  version: 1.0.0
+servers:
+  - url: https://{host}/v1
+    variables:
+      host: {default: petstore.example.com}
+security:
+  - bearerAuth: []
+paths:
+  /pets:
+    get:
+      summary: List all pets
+      tags: [pets]
+      parameters:
+        - {name: limit, in: query, schema: {type: integer, minimum: 1}}
+        - {name: X-Request-ID, in: header, schema: {type: string, format: uuid}}
+      responses:
+        '200': {description: the pets}
+        default: {description: an error}
+    post:
+      tags: [pets]
+      requestBody:
+        content:
+          application/json:
+            schema: {$ref: '#/components/schemas/Pet'}
+      responses:
+        '201': {description: created}
+  /pets/{petId}:
+    parameters:
+      - $ref: '#/components/parameters/PetId'
+    get:
+      security:
+        - apiKey: []
+      responses:
+        2XX: {description: the pet}
+    delete:
+      security: []
+      parameters:
+        - {name: petId, in: path, required: true, schema: {type: integer}}
+      responses:
+        default: {description: deleted}
+  /login:
+    post:
+      tags: [auth]
+      security:
+        - basicAuth: []
+      requestBody:
+        content:
+          application/x-www-form-urlencoded:
+            schema:
+              properties:
+                username: {type: string, example: admin}
+                password: {type: string, format: password}
+      responses:
+        '200': {description: logged in}
+components:
+  parameters:
+    PetId: {name: petId, in: path, required: true, schema: {type: string}, example: "42"}
+  securitySchemes:
+    bearerAuth: {type: http, scheme: bearer}
+    apiKey: {type: apiKey, in: query, name: api_key}
+    basicAuth: {type: http, scheme: basic}
+  schemas:
+    Pet:
+      type: object
+      properties:
+        id: {type: integer, readOnly: true}
+        name: {type: string}
+        kind: {type: string, enum: [dog, cat]}
+        owner:
+          allOf:
+            - properties: {email: {type: string, format: email}}
+            - properties: {first-name: {type: string, example: Jane}}
+        friends: {type: array, items: {$ref: '#/components/schemas/Pet'}}
+`
+
+//nolint:lll
+func TestGenerate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("OpenAPI 3", func(t *testing.T) {
+		t.Parallel()
+		script, err := Generate([]byte(petstore), Options{})
+		require.NoError(t, err)
+		assert.Equal(t, `import { check, group } from 'k6';
+import encoding from 'k6/encoding';
+import http from 'k6/http';
+
+// Generated by k6 generate openapi v`+consts.Version+` from Petstore 1.0.0
+
+const BASE_URL = __ENV.BASE_URL || "https://petstore.example.com/v1";
+
+// the credentials of the security schemes, from the environment variables
+const BEARER_AUTH = __ENV.BEARER_AUTH;
+const API_KEY = __ENV.API_KEY;
+const BASIC_AUTH_USERNAME = __ENV.BASIC_AUTH_USERNAME;
+const BASIC_AUTH_PASSWORD = __ENV.BASIC_AUTH_PASSWORD;
+
+export let options = {
+	thresholds: {
+		http_req_failed: ['rate<0.01'],
+		checks: ['rate>0.99'],
+	},
+};
+
+export default function() {
+	let res;
+
+	group("GET /pets - List all pets", function() {
+		let limit = 1;
+		let XRequestID = "00000000-0000-0000-0000-000000000000";
+		res = http.get(`+"`${BASE_URL}/pets?limit=${limit}`"+`, {
+			headers: { "X-Request-ID": XRequestID, Authorization: `+"`Bearer ${BEARER_AUTH}`"+` },
+			tags: { name: `+"`${BASE_URL}/pets`"+` },
+		});
+		check(res, { "status is 200": (r) => r.status === 200 });
+	});
+
+	group("POST /pets", function() {
+		let body = {
+			name: "string",
+			kind: "dog",
+			owner: {
+				email: "user@example.com",
+				"first-name": "Jane",
+			},
+			friends: [],
+		};
+		res = http.request("POST", `+"`${BASE_URL}/pets`"+`, JSON.stringify(body), {
+			headers: { "Content-Type": "application/json", Authorization: `+"`Bearer ${BEARER_AUTH}`"+` },
+		});
+		check(res, { "status is 201": (r) => r.status === 201 });
+	});
+
+	group("GET /pets/{petId}", function() {
+		let petId = "42";
+		res = http.get(`+"`${BASE_URL}/pets/${petId}?api_key=${encodeURIComponent(API_KEY)}`"+`, {
+			tags: { name: `+"`${BASE_URL}/pets/{petId}`"+` },
+		});
+		check(res, { "status is 2xx": (r) => r.status >= 200 && r.status < 300 });
+	});
+
+	group("DELETE /pets/{petId}", function() {
+		let petId = 0;
+		res = http.request("DELETE", `+"`${BASE_URL}/pets/${petId}`"+`, null, {
+			tags: { name: `+"`${BASE_URL}/pets/{petId}`"+` },
+		});
+		check(res, { "status is 2xx": (r) => r.status >= 200 && r.status < 300 });
+	});
+
+	group("POST /login", function() {
+		let body = {
+			username: "admin",
+			password: "password",
+		};
+		res = http.request("POST", `+"`${BASE_URL}/login`"+`, body, {
+			headers: { Authorization: `+"`Basic ${encoding.b64encode(`${BASIC_AUTH_USERNAME}:${BASIC_AUTH_PASSWORD}`)}`"+` },
+		});
+		check(res, { "status is 200": (r) => r.status === 200 });
+	});
+}
+`, script)
+	})
+
+	t.Run("tags", func(t *testing.T) {
+		t.Parallel()
+		script, err := Generate([]byte(petstore), Options{Tags: []string{"auth"}})
+		require.NoError(t, err)
+		assert.Contains(t, script, `group("POST /login"`)
+		assert.NotContains(t, script, `group("GET /pets`)
+		assert.NotContains(t, script, `BEARER_AUTH`)
+
+		_, err = Generate([]byte(petstore), Options{Tags: []string{"none"}})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no operations")
+	})
+
+	t.Run("Swagger 2", func(t *testing.T) {
+		t.Parallel()
+		script, err := Generate([]byte(`{
+			"swagger": "2.0",
+			"info": {"title": "Store", "version": "2"},
+			"host": "api.example.com",
+			"basePath": "/v2",
+			"securityDefinitions": {"store-key": {"type": "apiKey", "in": "header", "name": "X-API-Key"}},
+			"paths": {
+				"/orders/{order-id}": {"put": {
+					"parameters": [
+						{"name": "order-id", "in": "path", "type": "integer", "minimum": 1, "required": true},
+						{"name": "order", "in": "body", "schema": {"$ref": "#/definitions/Order"}}
+					],
+					"security": [{"store-key": []}],
+					"responses": {"204": {"description": "updated"}}
+				}},
+				"/notes": {"post": {
+					"consumes": ["application/x-www-form-urlencoded"],
+					"parameters": [{"name": "text", "in": "formData", "type": "string", "default": "hi"}],
+					"responses": {"200": {"description": "created"}}
+				}}
+			},
+			"definitions": {"Order": {"properties": {"qty": {"type": "integer"}, "items": {"items": {"type": "string"}}}}}
+		}`), Options{})
+		require.NoError(t, err)
+		assert.Contains(t, script, `const BASE_URL = __ENV.BASE_URL || "http://api.example.com/v2";`)
+		assert.Contains(t, script, "const STORE_KEY = __ENV.STORE_KEY;\n")
+		assert.Contains(t, script, `		let orderId = 1;
+		let body = {
+			qty: 0,
+			items: ["string"],
+		};
+		res = http.request("PUT", `+"`${BASE_URL}/orders/${orderId}`"+`, JSON.stringify(body), {
+			headers: { "Content-Type": "application/json", "X-API-Key": STORE_KEY },
+			tags: { name: `+"`${BASE_URL}/orders/{order-id}`"+` },
+		});
+		check(res, { "status is 204": (r) => r.status === 204 });`)
+		assert.Contains(t, script, `		let body = {
+			text: "hi",
+		};
+		res = http.request("POST", `+"`${BASE_URL}/notes`"+`, body);`)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		t.Parallel()
+		for spec, expected := range map[string]string{
+			`{"openapi": "3.0.0", "paths": {}`: "couldn't parse the specification",
+			`openapi: 1.0`:                     "isn't an OpenAPI 3 or a Swagger 2 one",
+			`openapi: 3.0.0`:                   "has no paths",
+			`{"openapi": "3.0.0", "paths": {"/a": {"get": {"parameters": [{"$ref": "#/components/parameters/A"}]}}}}`: "the reference \"#/components/parameters/A\" isn't in the specification",
+			`{"openapi": "3.0.0", "paths": {"/a": {"get": {"security": [{"key": []}]}}}}`:                             "the security scheme \"key\" isn't defined",
+			`{"openapi": "3.0.0", "paths": {"/a": {"get": {"parameters": [{"$ref": "other.yaml#/A"}]}}}}`:             "isn't local",
+		} {
+			_, err := Generate([]byte(spec), Options{})
+			require.Error(t, err, spec)
+			assert.Contains(t, err.Error(), expected, spec)
+		}
+	})
+}
+
+func TestIdentifiers(t *testing.T) {
+	t.Parallel()
+
+	used := make(map[string]bool)
+	for name, expected := range map[string]string{
+		"limit": "limit", "X-Request-ID": "XRequestID", "page[size]": "pageSize", "1st": "_1st",
+		"body": "body2", "delete": "delete_", "": "_",
+	} {
+		assert.Equal(t, expected, identifier(name, used), name)
+	}
+	assert.Equal(t, "limit2", identifier("limit", used))
+
+	for name, expected := range map[string]string{
+		"bearerAuth": "BEARER_AUTH", "api_key": "API_KEY", "petstore-auth": "PETSTORE_AUTH", "OAuth2": "OAUTH2",
+		"123": "AUTH_123",
+	} {
+		assert.Equal(t, expected, constantName(name), name)
+	}
+}
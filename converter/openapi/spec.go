@@ -0,0 +1,144 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package openapi generates k6 scripts from the OpenAPI 3 and Swagger 2 specifications of APIs.
+package openapi
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// object is an object of a specification, which keeps the order of its keys, so that the generated
+// scripts have the operations and the properties of the payloads in the order of the specification.
+type object struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// get returns the value of the key, or nil if the object is nil or doesn't have it.
+func (o *object) get(key string) interface{} {
+	if o == nil {
+		return nil
+	}
+	return o.values[key]
+}
+
+func (o *object) object(key string) *object {
+	v, _ := o.get(key).(*object)
+	return v
+}
+
+func (o *object) array(key string) []interface{} {
+	v, _ := o.get(key).([]interface{})
+	return v
+}
+
+func (o *object) string(key string) string {
+	v, _ := o.get(key).(string)
+	return v
+}
+
+// parse parses a specification in YAML or JSON, which is a subset of YAML.
+func parse(data []byte) (*object, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	v, err := nodeValue(&doc)
+	if err != nil {
+		return nil, err
+	}
+	spec, ok := v.(*object)
+	if !ok {
+		return nil, fmt.Errorf("the specification isn't an object")
+	}
+	return spec, nil
+}
+
+func nodeValue(n *yaml.Node) (interface{}, error) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return nil, nil
+		}
+		return nodeValue(n.Content[0])
+	case yaml.AliasNode:
+		return nodeValue(n.Alias)
+	case yaml.MappingNode:
+		o := &object{values: make(map[string]interface{}, len(n.Content)/2)}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key := n.Content[i].Value
+			value, err := nodeValue(n.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := o.values[key]; !ok {
+				o.keys = append(o.keys, key)
+			}
+			o.values[key] = value
+		}
+		return o, nil
+	case yaml.SequenceNode:
+		a := make([]interface{}, len(n.Content))
+		for i, c := range n.Content {
+			var err error
+			if a[i], err = nodeValue(c); err != nil {
+				return nil, err
+			}
+		}
+		return a, nil
+	default:
+		var v interface{}
+		if err := n.Decode(&v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+}
+
+// resolve returns the object to which the object refers with its $ref, if it has one, which needs to be
+// a local reference. The references of the references are resolved too, up to a depth.
+func resolve(spec, o *object) (*object, error) {
+	for depth := 0; o != nil; depth++ {
+		ref := o.string("$ref")
+		if ref == "" {
+			return o, nil
+		}
+		if depth > 10 {
+			return nil, fmt.Errorf("the reference %q is circular", ref)
+		}
+		if !strings.HasPrefix(ref, "#/") {
+			return nil, fmt.Errorf("the reference %q isn't local, only the ones within the specification are supported",
+				ref)
+		}
+		target := spec
+		for _, token := range strings.Split(ref[2:], "/") {
+			token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+			if target = target.object(token); target == nil {
+				return nil, fmt.Errorf("the reference %q isn't in the specification", ref)
+			}
+		}
+		o = target
+	}
+	return nil, nil
+}
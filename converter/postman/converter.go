@@ -0,0 +1,617 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package postman
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.k6.io/k6/lib/consts"
+)
+
+var (
+	identifierRe = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`) //nolint:gochecknoglobals
+	variableRe   = regexp.MustCompile(`\{\{([^{}]+)\}\}`)           //nolint:gochecknoglobals
+)
+
+// dynamicVariables are the code of the dynamic variables of Postman which can be translated.
+var dynamicVariables = map[string]string{ //nolint:gochecknoglobals
+	"$guid":         "uuidv4()",
+	"$randomUUID":   "uuidv4()",
+	"$timestamp":    "Math.floor(Date.now() / 1000)",
+	"$isoTimestamp": "new Date().toISOString()",
+	"$randomInt":    "Math.floor(Math.random() * 1001)",
+}
+
+// methodFunctions are the functions of the k6/http module for the methods, and whether they take a body.
+var methodFunctions = map[string]struct { //nolint:gochecknoglobals
+	name string
+	body bool
+}{
+	"GET": {"get", false}, "HEAD": {"head", false}, "POST": {"post", true}, "PUT": {"put", true},
+	"PATCH": {"patch", true}, "DELETE": {"del", true}, "OPTIONS": {"options", true},
+}
+
+// contentTypes are the content types which Postman sends for the languages of the raw bodies.
+var contentTypes = map[string]string{ //nolint:gochecknoglobals
+	"json": "application/json", "xml": "application/xml", "text": "text/plain", "html": "text/html",
+	"javascript": "application/javascript",
+}
+
+type converter struct {
+	w io.Writer
+	// variables are the referenced variables
+	variables map[string]bool
+	files     []string
+	requests  int
+	basicAuth bool
+	uuid      bool
+	groups    bool
+	checks    bool
+}
+
+// Convert returns a script which makes the requests of the collection in order, in groups for its folders,
+// with its pre-request and test scripts translated where they can be. The variables of the collection are
+// overridden by the ones of the environment, which can be nil.
+//nolint:funlen
+func Convert(c *Collection, env *Environment) (string, error) {
+	var body bytes.Buffer
+	cv := &converter{w: &body, variables: make(map[string]bool)}
+	cv.items(c.Item, []*Auth{c.Auth}, [][]*Event{c.Event}, "\t")
+	if cv.requests == 0 {
+		return "", errors.New("the collection has no requests")
+	}
+
+	var keys []string
+	values := make(map[string]interface{})
+	for _, v := range c.Variable {
+		if v.Disabled || v.Key == "" {
+			continue
+		}
+		if _, ok := values[v.Key]; !ok {
+			keys = append(keys, v.Key)
+		}
+		values[v.Key] = v.Value
+	}
+	if env != nil {
+		for _, v := range env.Values {
+			if (v.Enabled != nil && !*v.Enabled) || v.Key == "" {
+				continue
+			}
+			if _, ok := values[v.Key]; !ok {
+				keys = append(keys, v.Key)
+			}
+			values[v.Key] = v.Value
+		}
+	}
+	var undefined []string
+	for name := range cv.variables {
+		if _, ok := values[name]; !ok {
+			undefined = append(undefined, name)
+		}
+	}
+	sort.Strings(undefined)
+
+	var b bytes.Buffer
+	w := &b
+	switch {
+	case cv.checks && cv.groups:
+		fprint(w, "import { check, group } from 'k6';\n")
+	case cv.checks:
+		fprint(w, "import { check } from 'k6';\n")
+	case cv.groups:
+		fprint(w, "import { group } from 'k6';\n")
+	}
+	if cv.basicAuth {
+		fprint(w, "import encoding from 'k6/encoding';\n")
+	}
+	fprint(w, "import http from 'k6/http';\n\n")
+	name := oneLine(c.Info.Name)
+	if name == "" {
+		name = "Postman collection"
+	}
+	fprintf(w, "// Converted by k6 convert v%s from %s\n\n", consts.Version, name)
+
+	fprint(w, "// the variables of the collection and of the environment, which can be overridden with the\n")
+	fprint(w, "// environment variables of the same names, like with k6 run -e name=value\n")
+	fprint(w, "const vars = {\n")
+	for _, key := range keys {
+		fprintf(w, "\t%s: %s || %s,\n", propertyName(key), envVariable(key), value(values[key]))
+	}
+	for _, key := range undefined {
+		fprintf(w, "\t%s: %s,\n", propertyName(key), envVariable(key))
+	}
+	fprint(w, "};\n")
+	if len(cv.files) > 0 {
+		fprint(w, "\n// the files uploaded by the requests\n")
+		for i, f := range cv.files {
+			fprintf(w, "const file%d = open(%s, 'b');\n", i+1, quote(f))
+		}
+	}
+	if cv.uuid {
+		fprint(w, "\nfunction uuidv4() {\n")
+		fprint(w, "\treturn 'xxxxxxxx-xxxx-4xxx-yxxx-xxxxxxxxxxxx'.replace(/[xy]/g, (c) => {\n")
+		fprint(w, "\t\tconst r = (Math.random() * 16) | 0;\n")
+		fprint(w, "\t\treturn (c === 'x' ? r : (r & 0x3) | 0x8).toString(16);\n")
+		fprint(w, "\t});\n}\n")
+	}
+	fprint(w, "\nexport default function () {\n\tlet res;\n")
+	fprint(w, body.String())
+	fprint(w, "}\n")
+	return b.String(), nil
+}
+
+// fprint panics on the errors, which can only be the out of memory ones of the buffers.
+func fprint(w io.Writer, a ...interface{}) {
+	if _, err := fmt.Fprint(w, a...); err != nil {
+		panic(err.Error())
+	}
+}
+
+// fprintf panics on the errors, which can only be the out of memory ones of the buffers.
+func fprintf(w io.Writer, format string, a ...interface{}) {
+	if _, err := fmt.Fprintf(w, format, a...); err != nil {
+		panic(err.Error())
+	}
+}
+
+// items converts the items of a folder, with the authentications and the events of the folder and of its
+// parents, from the collection.
+func (cv *converter) items(items []*Item, auths []*Auth, events [][]*Event, indent string) {
+	for _, item := range items {
+		switch {
+		case item.Request != nil:
+			fprint(cv.w, "\n")
+			cv.request(item, auths, events, indent)
+		case len(item.Item) > 0:
+			cv.groups = true
+			fprintf(cv.w, "\n%sgroup(%s, () => {", indent, quote(item.Name))
+			cv.items(item.Item, append(auths, item.Auth), append(events, item.Event), indent+"\t")
+			fprintf(cv.w, "%s});\n", indent)
+		}
+	}
+}
+
+//nolint:funlen
+func (cv *converter) request(item *Item, auths []*Auth, events [][]*Event, indent string) {
+	cv.requests++
+	req := item.Request
+	if name := oneLine(item.Name); name != "" {
+		fprintf(cv.w, "%s// %s\n", indent, name)
+	}
+	events = append(events, item.Event)
+	cv.scripts(events, "prerequest", "", indent)
+
+	var headers []string
+	hasContentType := false
+	for _, h := range req.Header {
+		if h.Disabled || h.Key == "" {
+			continue
+		}
+		if strings.EqualFold(h.Key, "Content-Type") {
+			if req.Body != nil && req.Body.Mode == "formdata" {
+				// the boundary of the multipart bodies is set by k6
+				continue
+			}
+			hasContentType = true
+		}
+		headers = append(headers, propertyName(h.Key)+": "+cv.text(h.Value))
+	}
+
+	url := req.URL.String()
+	auth := req.Auth
+	for i := len(auths) - 1; i >= 0 && (auth == nil || auth.Type == "inherit"); i-- {
+		auth = auths[i]
+	}
+	var unsupportedAuth string
+	if auth != nil {
+		var name, value string
+		name, value, url, unsupportedAuth = cv.auth(auth, url)
+		if name != "" {
+			headers = append(headers, propertyName(name)+": "+value)
+		}
+	}
+
+	body, contentType := cv.body(req.Body, indent)
+	if contentType != "" && !hasContentType {
+		headers = append([]string{"'Content-Type': " + quote(contentType)}, headers...)
+	}
+
+	if unsupportedAuth != "" {
+		fprintf(cv.w, "%s// TODO: the %s authentication of Postman isn't supported\n", indent, unsupportedAuth)
+	}
+	method := strings.ToUpper(req.Method)
+	if method == "" {
+		method = "GET"
+	}
+	args := []string{cv.text(url)}
+	f, ok := methodFunctions[method]
+	switch {
+	case ok && (f.body || body == ""):
+		fprintf(cv.w, "%sres = http.%s(", indent, f.name)
+		if f.body && (body != "" || len(headers) > 0) {
+			if body == "" {
+				body = "null"
+			}
+			args = append(args, body)
+		}
+	default:
+		fprintf(cv.w, "%sres = http.request(", indent)
+		if body == "" {
+			body = "null"
+		}
+		args = []string{quote(method), args[0], body}
+	}
+	if len(headers) > 0 {
+		args = append(args, "{\n"+indent+"\theaders: {\n"+indent+"\t\t"+strings.Join(headers, ",\n"+indent+"\t\t")+
+			",\n"+indent+"\t},\n"+indent+"}")
+	}
+	fprintf(cv.w, "%s);\n", strings.Join(args, ", "))
+
+	cv.scripts(events, "test", "res", indent)
+}
+
+// scripts converts the scripts of the events which listen to the event, from the collection to the request.
+func (cv *converter) scripts(events [][]*Event, listen, res, indent string) {
+	for _, list := range events {
+		for _, e := range list {
+			source := strings.Join(e.Script.Exec, "\n")
+			if e.Disabled || e.Listen != listen || strings.TrimSpace(source) == "" {
+				continue
+			}
+			for _, line := range translateScript(source, res) {
+				if strings.HasPrefix(strings.TrimSpace(line), "check(") {
+					cv.checks = true
+				}
+				if line == "" {
+					fprint(cv.w, "\n")
+				} else {
+					fprintf(cv.w, "%s%s\n", indent, line)
+				}
+			}
+		}
+	}
+}
+
+// auth returns the name and the value of the header of the authentication, the URL with its query
+// parameter, or the type of the authentication when it isn't supported.
+//nolint:cyclop
+func (cv *converter) auth(auth *Auth, url string) (string, string, string, string) {
+	var list []AuthAttribute
+	switch auth.Type {
+	case "noauth", "":
+		return "", "", url, ""
+	case "bearer":
+		list = auth.Bearer
+	case "basic":
+		list = auth.Basic
+	case "apikey":
+		list = auth.APIKey
+	case "oauth2":
+		list = auth.OAuth2
+	default:
+		return "", "", url, auth.Type
+	}
+	attributes := make(map[string]string)
+	for _, a := range list {
+		if a.Value != nil {
+			attributes[a.Key] = fmt.Sprint(a.Value)
+		}
+	}
+
+	switch auth.Type {
+	case "bearer":
+		return "Authorization", cv.text("Bearer " + attributes["token"]), url, ""
+	case "basic":
+		cv.basicAuth = true
+		credentials := cv.text(attributes["username"] + ":" + attributes["password"])
+		return "Authorization", "`Basic ${encoding.b64encode(" + credentials + ")}`", url, ""
+	case "apikey":
+		if attributes["in"] == "query" {
+			return "", "", addQueryParameter(url, attributes["key"], attributes["value"]), ""
+		}
+		return attributes["key"], cv.text(attributes["value"]), url, ""
+	default:
+		if attributes["addTokenTo"] == "queryParams" {
+			return "", "", addQueryParameter(url, "access_token", attributes["accessToken"]), ""
+		}
+		prefix, ok := attributes["headerPrefix"]
+		if !ok {
+			prefix = "Bearer"
+		}
+		return "Authorization", cv.text(strings.TrimSpace(prefix + " " + attributes["accessToken"])), url, ""
+	}
+}
+
+func addQueryParameter(url, key, value string) string {
+	if strings.Contains(url, "?") {
+		return url + "&" + key + "=" + value
+	}
+	return url + "?" + key + "=" + value
+}
+
+// body returns the code of the body, or an empty string when there's none, and the content type which
+// Postman sends for it, when k6 doesn't set it.
+//nolint:funlen,cyclop
+func (cv *converter) body(body *Body, indent string) (string, string) {
+	if body == nil || body.Disabled {
+		return "", ""
+	}
+	switch body.Mode {
+	case "raw":
+		if body.Raw == "" {
+			return "", ""
+		}
+		contentType := contentTypes[body.Options.Raw.Language]
+		if body.Options.Raw.Language == "json" {
+			if code, err := cv.json(body.Raw, indent); err == nil {
+				return "JSON.stringify(" + code + ")", contentType
+			}
+		}
+		return cv.text(body.Raw), contentType
+	case "urlencoded", "formdata":
+		fields := body.URLEncoded
+		if body.Mode == "formdata" {
+			fields = body.FormData
+		}
+		var properties []string
+		for _, f := range fields {
+			if f.Disabled || f.Key == "" {
+				continue
+			}
+			if f.Type != "file" {
+				properties = append(properties, propertyName(f.Key)+": "+cv.text(f.Value))
+				continue
+			}
+			src := ""
+			switch s := f.Src.(type) {
+			case string:
+				src = s
+			case []interface{}:
+				if len(s) > 0 {
+					src, _ = s[0].(string)
+				}
+			}
+			if src == "" {
+				continue
+			}
+			properties = append(properties, fmt.Sprintf("%s: http.file(%s, %s)",
+				propertyName(f.Key), cv.file(src), quote(path.Base(strings.ReplaceAll(src, "\\", "/")))))
+		}
+		if len(properties) == 0 {
+			return "", ""
+		}
+		return "{\n" + indent + "\t" + strings.Join(properties, ",\n"+indent+"\t") + ",\n" + indent + "}", ""
+	case "file":
+		if body.File == nil || body.File.Src == "" {
+			return "", ""
+		}
+		return cv.file(body.File.Src), ""
+	case "graphql":
+		if body.GraphQL == nil {
+			return "", ""
+		}
+		properties := []string{"query: " + cv.text(body.GraphQL.Query)}
+		if strings.TrimSpace(body.GraphQL.Variables) != "" {
+			variables, err := cv.json(body.GraphQL.Variables, indent+"\t")
+			if err != nil {
+				variables = "JSON.parse(" + cv.text(body.GraphQL.Variables) + ")"
+			}
+			properties = append(properties, "variables: "+variables)
+		}
+		return "JSON.stringify({\n" + indent + "\t" + strings.Join(properties, ",\n"+indent+"\t") + ",\n" + indent +
+			"})", "application/json"
+	default:
+		return "", ""
+	}
+}
+
+// file returns the constant of the file with the path, which is opened in the init context.
+func (cv *converter) file(src string) string {
+	for i, f := range cv.files {
+		if f == src {
+			return "file" + strconv.Itoa(i+1)
+		}
+	}
+	cv.files = append(cv.files, src)
+	return "file" + strconv.Itoa(len(cv.files))
+}
+
+// json returns the JS literal of the JSON, whose strings can have variables. It's an error when the JSON
+// isn't valid, like when it has variables out of its strings.
+func (cv *converter) json(data, indent string) (string, error) {
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+	code, err := cv.jsonValue(decoder, indent)
+	if err != nil {
+		return "", err
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("the JSON has data after its value")
+	}
+	return code, nil
+}
+
+func (cv *converter) jsonValue(decoder *json.Decoder, indent string) (string, error) {
+	t, err := decoder.Token()
+	if err != nil {
+		return "", err
+	}
+	switch t := t.(type) {
+	case json.Delim:
+		var elements []string
+		multiline := false
+		for decoder.More() {
+			element := ""
+			if t == '{' {
+				key, err := decoder.Token()
+				if err != nil {
+					return "", err
+				}
+				element = propertyName(key.(string)) + ": " //nolint:forcetypeassert
+			}
+			value, err := cv.jsonValue(decoder, indent+"\t")
+			if err != nil {
+				return "", err
+			}
+			multiline = multiline || strings.HasSuffix(value, "}") || strings.HasSuffix(value, "]")
+			elements = append(elements, element+value)
+		}
+		if _, err := decoder.Token(); err != nil {
+			return "", err
+		}
+		closing := "]"
+		if t == '{' {
+			closing = "}"
+		}
+		if len(elements) == 0 {
+			return string(t) + closing, nil
+		}
+		if t == '[' && !multiline {
+			return "[" + strings.Join(elements, ", ") + "]", nil
+		}
+		return string(t) + "\n" + indent + "\t" + strings.Join(elements, ",\n"+indent+"\t") + ",\n" + indent +
+			closing, nil
+	case string:
+		return cv.text(t), nil
+	case nil:
+		return "null", nil
+	default:
+		return fmt.Sprint(t), nil
+	}
+}
+
+// text returns the code of a string which can have variables, in a template literal when it has some.
+func (cv *converter) text(s string) string {
+	if !variableRe.MatchString(s) {
+		return quote(s)
+	}
+	var b strings.Builder
+	b.WriteByte('`')
+	last := 0
+	for _, m := range variableRe.FindAllStringSubmatchIndex(s, -1) {
+		b.WriteString(templateText(s[last:m[0]]))
+		name := strings.TrimSpace(s[m[2]:m[3]])
+		switch code, ok := dynamicVariables[name]; {
+		case ok:
+			cv.uuid = cv.uuid || code == "uuidv4()"
+			b.WriteString("${" + code + "}")
+		case strings.HasPrefix(name, "$"):
+			// the other dynamic variables are kept as they are
+			b.WriteString(templateText(s[m[0]:m[1]]))
+		default:
+			cv.variables[name] = true
+			if identifierRe.MatchString(name) {
+				b.WriteString("${vars." + name + "}")
+			} else {
+				b.WriteString("${vars[" + quote(name) + "]}")
+			}
+		}
+		last = m[1]
+	}
+	b.WriteString(templateText(s[last:]))
+	b.WriteByte('`')
+	return b.String()
+}
+
+// templateText escapes the text of a template literal.
+func templateText(s string) string {
+	s = strings.ReplaceAll(strings.ReplaceAll(s, "\\", "\\\\"), "`", "\\`")
+	return strings.ReplaceAll(s, "${", "\\${")
+}
+
+// propertyName returns the name of a property in an object literal, which is quoted when it isn't an
+// identifier.
+func propertyName(name string) string {
+	if identifierRe.MatchString(name) {
+		return name
+	}
+	return quote(name)
+}
+
+// envVariable returns the environment variable with the name.
+func envVariable(name string) string {
+	if identifierRe.MatchString(name) {
+		return "__ENV." + name
+	}
+	return "__ENV[" + quote(name) + "]"
+}
+
+// value returns the literal of the value of a variable.
+func value(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "''"
+	case string:
+		return quote(v)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "''"
+		}
+		return quote(string(data))
+	}
+}
+
+// quote returns the single-quoted string literal of the string.
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('\'')
+	for _, r := range s {
+		switch r {
+		case '\'', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\u2028', '\u2029':
+			fmt.Fprintf(&b, `\u%04x`, r)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(&b, `\x%02x`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
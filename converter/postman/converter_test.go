@@ -0,0 +1,236 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package postman
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/lib/consts"
+)
+
+//nolint:lll
+const petstore = `{
+	"info": {"name": "Petstore", "schema": "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"},
+	"auth": {"type": "bearer", "bearer": [{"key": "token", "value": "{{token}}", "type": "string"}]},
+	"variable": [{"key": "baseUrl", "value": "https://petstore.example.com"}, {"key": "limit", "value": 10}],
+	"item": [
+		{
+			"name": "Login",
+			"request": {
+				"method": "POST",
+				"auth": {"type": "basic", "basic": [{"key": "username", "value": "{{user}}"}, {"key": "password", "value": "secret"}]},
+				"url": {"raw": "{{baseUrl}}/login", "host": ["{{baseUrl}}"], "path": ["login"]}
+			},
+			"event": [{"listen": "test", "script": {"exec": ["pm.environment.set('token', pm.response.json().token);"]}}]
+		},
+		{
+			"name": "Pets",
+			"event": [{"listen": "test", "script": {"exec": ["pm.test('Status code is 200', function () {", "    pm.response.to.have.status(200);", "});"]}}],
+			"item": [
+				{
+					"name": "List pets",
+					"request": {
+						"method": "GET",
+						"header": [{"key": "Accept", "value": "application/json"}, {"key": "X-Debug", "value": "1", "disabled": true}],
+						"url": "{{baseUrl}}/pets?limit={{limit}}"
+					}
+				},
+				{
+					"name": "Add pet",
+					"event": [{"listen": "prerequest", "script": {"exec": ["pm.variables.set('petName', 'Rex');"]}}],
+					"request": {
+						"method": "POST",
+						"body": {"mode": "raw", "raw": "{\"id\": \"{{$guid}}\", \"name\": \"{{petName}}\", \"tags\": [\"dog\"]}", "options": {"raw": {"language": "json"}}},
+						"url": "{{baseUrl}}/pets"
+					}
+				},
+				{
+					"name": "Upload photo",
+					"request": {
+						"method": "PUT",
+						"auth": {"type": "apikey", "apikey": [{"key": "key", "value": "X-API-Key"}, {"key": "value", "value": "{{apiKey}}"}]},
+						"header": [{"key": "Content-Type", "value": "multipart/form-data"}],
+						"body": {"mode": "formdata", "formdata": [{"key": "caption", "value": "Rex", "type": "text"}, {"key": "photo", "type": "file", "src": "/photos/rex.png"}]},
+						"url": "{{baseUrl}}/pets/1/photo"
+					}
+				},
+				{
+					"name": "Search",
+					"request": {
+						"method": "SEARCH",
+						"auth": {"type": "noauth"},
+						"body": {"mode": "urlencoded", "urlencoded": [{"key": "q", "value": "rex"}]},
+						"url": "{{baseUrl}}/pets"
+					}
+				}
+			]
+		}
+	]
+}`
+
+func TestConvert(t *testing.T) {
+	t.Parallel()
+
+	t.Run("collection", func(t *testing.T) {
+		t.Parallel()
+		c, err := Decode(strings.NewReader(petstore))
+		require.NoError(t, err)
+		script, err := Convert(c, nil)
+		require.NoError(t, err)
+		assert.Equal(t, `import { check, group } from 'k6';
+import encoding from 'k6/encoding';
+import http from 'k6/http';
+
+// Converted by k6 convert v`+consts.Version+` from Petstore
+
+// the variables of the collection and of the environment, which can be overridden with the
+// environment variables of the same names, like with k6 run -e name=value
+const vars = {
+	baseUrl: __ENV.baseUrl || 'https://petstore.example.com',
+	limit: __ENV.limit || 10,
+	apiKey: __ENV.apiKey,
+	petName: __ENV.petName,
+	token: __ENV.token,
+	user: __ENV.user,
+};
+
+// the files uploaded by the requests
+const file1 = open('/photos/rex.png', 'b');
+
+function uuidv4() {
+	return 'xxxxxxxx-xxxx-4xxx-yxxx-xxxxxxxxxxxx'.replace(/[xy]/g, (c) => {
+		const r = (Math.random() * 16) | 0;
+		return (c === 'x' ? r : (r & 0x3) | 0x8).toString(16);
+	});
+}
+
+export default function () {
+	let res;
+
+	// Login
+	res = http.post(`+"`${vars.baseUrl}/login`"+`, null, {
+		headers: {
+			Authorization: `+"`Basic ${encoding.b64encode(`${vars.user}:secret`)}`"+`,
+		},
+	});
+	vars.token = res.json().token;
+
+	group('Pets', () => {
+		// List pets
+		res = http.get(`+"`${vars.baseUrl}/pets?limit=${vars.limit}`"+`, {
+			headers: {
+				Accept: 'application/json',
+				Authorization: `+"`Bearer ${vars.token}`"+`,
+			},
+		});
+		check(res, {
+			'Status code is 200': (r) => r.status === 200,
+		});
+
+		// Add pet
+		vars.petName = 'Rex';
+		res = http.post(`+"`${vars.baseUrl}/pets`"+`, JSON.stringify({
+			id: `+"`${uuidv4()}`"+`,
+			name: `+"`${vars.petName}`"+`,
+			tags: ['dog'],
+		}), {
+			headers: {
+				'Content-Type': 'application/json',
+				Authorization: `+"`Bearer ${vars.token}`"+`,
+			},
+		});
+		check(res, {
+			'Status code is 200': (r) => r.status === 200,
+		});
+
+		// Upload photo
+		res = http.put(`+"`${vars.baseUrl}/pets/1/photo`"+`, {
+			caption: 'Rex',
+			photo: http.file(file1, 'rex.png'),
+		}, {
+			headers: {
+				'X-API-Key': `+"`${vars.apiKey}`"+`,
+			},
+		});
+		check(res, {
+			'Status code is 200': (r) => r.status === 200,
+		});
+
+		// Search
+		res = http.request('SEARCH', `+"`${vars.baseUrl}/pets`"+`, {
+			q: 'rex',
+		});
+		check(res, {
+			'Status code is 200': (r) => r.status === 200,
+		});
+	});
+}
+`, script)
+	})
+
+	t.Run("environment", func(t *testing.T) {
+		t.Parallel()
+		c, err := Decode(strings.NewReader(petstore))
+		require.NoError(t, err)
+		env, err := DecodeEnvironment(strings.NewReader(`{"name": "Staging", "values": [
+			{"key": "baseUrl", "value": "https://staging.example.com", "enabled": true},
+			{"key": "user", "value": "admin"},
+			{"key": "limit", "value": "1", "enabled": false}
+		]}`))
+		require.NoError(t, err)
+		script, err := Convert(c, env)
+		require.NoError(t, err)
+		assert.Contains(t, script, `
+const vars = {
+	baseUrl: __ENV.baseUrl || 'https://staging.example.com',
+	limit: __ENV.limit || 10,
+	user: __ENV.user || 'admin',
+	apiKey: __ENV.apiKey,
+`)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		t.Parallel()
+		_, err := Decode(strings.NewReader(
+			`{"info": {"schema": "https://schema.getpostman.com/json/collection/v1.0.0/collection.json"}}`))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "isn't of the Postman v2.1 format")
+
+		c, err := Decode(strings.NewReader(`{"info": {"name": "Empty",
+			"schema": "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"},
+			"item": [{"name": "Folder", "item": []}]}`))
+		require.NoError(t, err)
+		_, err = Convert(c, nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "the collection has no requests")
+	})
+}
+
+func TestIsCollection(t *testing.T) {
+	t.Parallel()
+	assert.True(t, IsCollection([]byte(petstore)))
+	assert.False(t, IsCollection([]byte(`{"log": {"version": "1.2"}}`)))
+	assert.False(t, IsCollection([]byte(`not JSON`)))
+}
@@ -0,0 +1,942 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package postman
+
+import (
+	"fmt"
+	"net/textproto"
+	"strings"
+
+	"github.com/dop251/goja/ast"
+	"github.com/dop251/goja/file"
+	"github.com/dop251/goja/parser"
+	"github.com/dop251/goja/token"
+)
+
+// The precedences of the JS expressions, the ones of the binary operators are in binaryPrecedences.
+const (
+	precedenceAssign      = 2
+	precedenceConditional = 3
+	precedenceUnary       = 15
+	precedencePostfix     = 16
+	precedenceMember      = 18
+	precedencePrimary     = 20
+)
+
+var binaryPrecedences = map[token.Token]int{ //nolint:gochecknoglobals
+	token.LOGICAL_OR: 4, token.LOGICAL_AND: 5, token.OR: 6, token.EXCLUSIVE_OR: 7, token.AND: 8,
+	token.EQUAL: 9, token.NOT_EQUAL: 9, token.STRICT_EQUAL: 9, token.STRICT_NOT_EQUAL: 9,
+	token.LESS: 10, token.GREATER: 10, token.LESS_OR_EQUAL: 10, token.GREATER_OR_EQUAL: 10,
+	token.INSTANCEOF: 10, token.IN: 10,
+	token.SHIFT_LEFT: 11, token.SHIFT_RIGHT: 11, token.UNSIGNED_SHIFT_RIGHT: 11,
+	token.PLUS: 12, token.MINUS: 12, token.MULTIPLY: 13, token.SLASH: 13, token.REMAINDER: 13,
+}
+
+// postmanGlobals are the globals of the Postman sandbox, which don't exist in k6, the ones which can be
+// translated are handled by the translator.
+var postmanGlobals = map[string]bool{ //nolint:gochecknoglobals
+	"pm": true, "postman": true, "responseBody": true, "responseCode": true, "responseHeaders": true,
+	"responseTime": true, "responseCookies": true, "request": true, "tests": true, "globals": true,
+	"environment": true, "data": true, "iteration": true, "require": true, "_": true, "CryptoJS": true,
+	"cheerio": true, "tv4": true, "xml2Json": true, "atob": true, "btoa": true,
+}
+
+// scriptNames are the names used by the converted scripts, which the variables of the Postman scripts are
+// renamed from.
+var scriptNames = map[string]bool{ //nolint:gochecknoglobals
+	"res": true, "r": true, "vars": true, "http": true, "check": true, "group": true, "encoding": true,
+}
+
+// variableStores are the variable scopes of the pm API, which are all stored in the vars object.
+var variableStores = map[string]bool{ //nolint:gochecknoglobals
+	"pm.environment": true, "pm.collectionVariables": true, "pm.variables": true, "pm.globals": true,
+}
+
+// languageChains are the words of the chains of the assertions which don't change them.
+var languageChains = map[string]bool{ //nolint:gochecknoglobals
+	"to": true, "be": true, "been": true, "is": true, "that": true, "which": true, "and": true, "has": true,
+	"have": true, "with": true, "at": true, "of": true, "same": true, "does": true, "still": true, "also": true,
+	"deep": true,
+}
+
+// responseAssertions are the conditions of the assertions of the responses without arguments, like
+// pm.response.to.be.ok, in which %[1]s is the response.
+var responseAssertions = map[string]string{ //nolint:gochecknoglobals
+	"ok":           "%[1]s.status === 200",
+	"success":      "%[1]s.status >= 200 && %[1]s.status < 300",
+	"info":         "%[1]s.status >= 100 && %[1]s.status < 200",
+	"redirection":  "%[1]s.status >= 300 && %[1]s.status < 400",
+	"clientError":  "%[1]s.status >= 400 && %[1]s.status < 500",
+	"serverError":  "%[1]s.status >= 500",
+	"error":        "%[1]s.status >= 400",
+	"accepted":     "%[1]s.status === 202",
+	"badRequest":   "%[1]s.status === 400",
+	"unauthorized": "%[1]s.status === 401",
+	"forbidden":    "%[1]s.status === 403",
+	"notFound":     "%[1]s.status === 404",
+	"rateLimited":  "%[1]s.status === 429",
+}
+
+// check is a check of a translated test, whose lines are the ones of the body of its function if it has
+// declarations, and whose condition is returned by the function.
+type check struct {
+	name      string
+	lines     []string
+	condition string
+}
+
+// translator translates a pre-request or a test script of Postman, which uses the pm API, to k6 code.
+// The statements that can't be translated are kept as comments.
+type translator struct {
+	source string
+	// res is the variable of the response, which is empty in the pre-request scripts.
+	res     string
+	renamed map[string]string
+	checks  []check
+	lines   []string
+}
+
+// translateScript returns the lines of the k6 code of the script, with the response in the variable res.
+// They are in a block when the script declares variables, since each Postman script has its own scope.
+func translateScript(source, res string) []string {
+	program, err := parser.ParseFile(nil, "", source, 0, parser.WithDisableSourceMaps)
+	if err != nil {
+		lines := []string{"// TODO: translate this Postman script, which couldn't be parsed"}
+		return append(lines, commented(source)...)
+	}
+	t := &translator{source: source, res: res, renamed: make(map[string]string)}
+	t.statements(program.Body, file.Idx(len(source)+1), "")
+	t.flushChecks("")
+	for _, s := range program.Body {
+		switch s.(type) {
+		case *ast.VariableStatement, *ast.LexicalDeclaration:
+			lines := make([]string, 0, len(t.lines)+2)
+			lines = append(lines, "{")
+			for _, line := range t.lines {
+				lines = append(lines, "\t"+line)
+			}
+			return append(lines, "}")
+		}
+	}
+	return t.lines
+}
+
+func commented(source string) []string {
+	lines := strings.Split(strings.TrimSpace(source), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight("// "+strings.TrimRight(line, " \t\r"), " ")
+	}
+	return lines
+}
+
+// statements translates the statements, which end before the end index.
+func (t *translator) statements(list []ast.Statement, end file.Idx, indent string) {
+	for i, s := range list {
+		next := end
+		if i+1 < len(list) {
+			next = list[i+1].Idx0()
+		}
+		if err := t.statement(s, indent); err != nil {
+			t.flushChecks(indent)
+			source := t.source[int(s.Idx0())-1 : int(next)-1]
+			if strings.HasSuffix(strings.TrimSpace(source), "}") {
+				// the end of the block which has the statement
+				source = strings.TrimSuffix(strings.TrimSpace(source), "}")
+			}
+			t.add(indent, fmt.Sprintf("// TODO: translate this code of the Postman script, %s", err))
+			for _, line := range commented(source) {
+				t.add(indent, line)
+			}
+		}
+	}
+}
+
+func (t *translator) add(indent, line string) {
+	t.lines = append(t.lines, indent+line)
+}
+
+// flushChecks adds the check of the pending checks of the tests.
+func (t *translator) flushChecks(indent string) {
+	if len(t.checks) == 0 {
+		return
+	}
+	t.add(indent, "check("+t.res+", {")
+	for _, c := range t.checks {
+		if len(c.lines) == 0 {
+			t.add(indent, fmt.Sprintf("\t%s: (r) => %s,", quote(c.name), c.condition))
+			continue
+		}
+		t.add(indent, fmt.Sprintf("\t%s: (r) => {", quote(c.name)))
+		for _, line := range c.lines {
+			t.add(indent, "\t\t"+line)
+		}
+		t.add(indent, fmt.Sprintf("\t\treturn %s;", c.condition))
+		t.add(indent, "\t},")
+	}
+	t.add(indent, "});")
+	t.checks = nil
+}
+
+func (t *translator) addCheck(c check, indent string) {
+	for _, pending := range t.checks {
+		if pending.name == c.name {
+			t.flushChecks(indent)
+			break
+		}
+	}
+	t.checks = append(t.checks, c)
+}
+
+//nolint:cyclop
+func (t *translator) statement(s ast.Statement, indent string) error {
+	switch s := s.(type) {
+	case *ast.EmptyStatement:
+		return nil
+	case *ast.ExpressionStatement:
+		if c, ok, err := t.test(s.Expression); ok {
+			if err != nil {
+				return err
+			}
+			t.addCheck(c, indent)
+			return nil
+		}
+		code, err := t.expressionStatement(s.Expression, t.res)
+		if err != nil {
+			return err
+		}
+		t.flushChecks(indent)
+		t.add(indent, code+";")
+		return nil
+	case *ast.VariableStatement, *ast.LexicalDeclaration:
+		code, err := t.declaration(s, t.res)
+		if err != nil {
+			return err
+		}
+		t.flushChecks(indent)
+		t.add(indent, code)
+		return nil
+	case *ast.IfStatement:
+		return t.ifStatement(s, indent)
+	default:
+		return fmt.Errorf("its statements aren't supported")
+	}
+}
+
+func (t *translator) ifStatement(s *ast.IfStatement, indent string) error {
+	test, _, err := t.expression(s.Test, t.res)
+	if err != nil {
+		return err
+	}
+	t.flushChecks(indent)
+	t.add(indent, "if ("+test+") {")
+	t.block(s.Consequent, indent)
+	switch alternate := s.Alternate.(type) {
+	case nil:
+		t.add(indent, "}")
+	case *ast.IfStatement:
+		// an else if, which is translated in the else block to keep the translation simple
+		t.add(indent, "} else {")
+		if err := t.ifStatement(alternate, indent+"\t"); err != nil {
+			t.statements([]ast.Statement{alternate}, alternate.Idx1(), indent+"\t")
+		}
+		t.add(indent, "}")
+	default:
+		t.add(indent, "} else {")
+		t.block(alternate, indent)
+		t.add(indent, "}")
+	}
+	return nil
+}
+
+// block translates the statements of a block, or the statement which isn't in a block.
+func (t *translator) block(s ast.Statement, indent string) {
+	if b, ok := s.(*ast.BlockStatement); ok {
+		t.statements(b.List, b.RightBrace, indent+"\t")
+	} else {
+		t.statements([]ast.Statement{s}, s.Idx1(), indent+"\t")
+	}
+	t.flushChecks(indent + "\t")
+}
+
+func (t *translator) declaration(s ast.Statement, res string) (string, error) {
+	keyword, list := "let", []*ast.Binding(nil)
+	switch s := s.(type) {
+	case *ast.VariableStatement:
+		list = s.List
+	case *ast.LexicalDeclaration:
+		list = s.List
+		if s.Token == token.CONST {
+			keyword = "const"
+		}
+	}
+	declarations := make([]string, len(list))
+	for i, b := range list {
+		id, ok := b.Target.(*ast.Identifier)
+		if !ok {
+			return "", fmt.Errorf("its destructuring assignments aren't supported")
+		}
+		name := id.Name.String()
+		if postmanGlobals[name] {
+			return "", fmt.Errorf("%s is a global of Postman", name)
+		}
+		if scriptNames[name] {
+			t.renamed[name] = name + "_"
+		}
+		declarations[i] = t.identifier(name)
+		if b.Initializer != nil {
+			value, _, err := t.expression(b.Initializer, res)
+			if err != nil {
+				return "", err
+			}
+			declarations[i] += " = " + value
+		}
+	}
+	return keyword + " " + strings.Join(declarations, ", ") + ";", nil
+}
+
+func (t *translator) identifier(name string) string {
+	if renamed, ok := t.renamed[name]; ok {
+		return renamed
+	}
+	return name
+}
+
+// expressionStatement translates an expression statement, like the ones which set the variables.
+func (t *translator) expressionStatement(e ast.Expression, res string) (string, error) {
+	if assign, ok := e.(*ast.AssignExpression); ok {
+		if b, ok := assign.Left.(*ast.BracketExpression); ok && dottedName(b.Left) == "tests" {
+			return "", fmt.Errorf("the tests which aren't constant strings aren't supported")
+		}
+	}
+	call, ok := e.(*ast.CallExpression)
+	if !ok {
+		code, _, err := t.expression(e, res)
+		return code, err
+	}
+	callee := dottedName(call.Callee)
+	store, method := callee, ""
+	if i := strings.LastIndexByte(callee, '.'); i > 0 {
+		store, method = callee[:i], callee[i+1:]
+	}
+	switch {
+	case variableStores[store] && method == "set", callee == "postman.setEnvironmentVariable",
+		callee == "postman.setGlobalVariable":
+		if len(call.ArgumentList) != 2 {
+			return "", fmt.Errorf("%s needs a name and a value", callee)
+		}
+		variable, err := t.variable(call.ArgumentList[0], res)
+		if err != nil {
+			return "", err
+		}
+		value, _, err := t.expression(call.ArgumentList[1], res)
+		if err != nil {
+			return "", err
+		}
+		return variable + " = " + value, nil
+	case variableStores[store] && method == "unset", callee == "postman.clearEnvironmentVariable",
+		callee == "postman.clearGlobalVariable":
+		if len(call.ArgumentList) != 1 {
+			return "", fmt.Errorf("%s needs a name", callee)
+		}
+		variable, err := t.variable(call.ArgumentList[0], res)
+		if err != nil {
+			return "", err
+		}
+		return "delete " + variable, nil
+	}
+	code, _, err := t.expression(e, res)
+	return code, err
+}
+
+// variable returns the member of the vars object of the variable with the name.
+func (t *translator) variable(name ast.Expression, res string) (string, error) {
+	if s, ok := name.(*ast.StringLiteral); ok && identifierRe.MatchString(s.Value.String()) {
+		return "vars." + s.Value.String(), nil
+	}
+	code, _, err := t.expression(name, res)
+	if err != nil {
+		return "", err
+	}
+	return "vars[" + code + "]", nil
+}
+
+// test returns the check of the test, whether the expression is a test, and the error of its translation.
+// The tests are pm.test calls and the assignments of the legacy tests object.
+func (t *translator) test(e ast.Expression) (check, bool, error) {
+	if assign, ok := e.(*ast.AssignExpression); ok && assign.Operator == token.ASSIGN {
+		b, ok := assign.Left.(*ast.BracketExpression)
+		if !ok || dottedName(b.Left) != "tests" {
+			return check{}, false, nil
+		}
+		name, ok := b.Member.(*ast.StringLiteral)
+		if !ok || t.res == "" {
+			return check{}, false, nil
+		}
+		condition, _, err := t.expression(assign.Right, "r")
+		return check{name: name.Value.String(), condition: condition}, true, err
+	}
+	call, ok := e.(*ast.CallExpression)
+	if !ok || dottedName(call.Callee) != "pm.test" {
+		return check{}, false, nil
+	}
+	if t.res == "" {
+		return check{}, true, fmt.Errorf("the tests of the pre-request scripts aren't supported")
+	}
+	if len(call.ArgumentList) != 2 {
+		return check{}, true, fmt.Errorf("pm.test needs a name and a function")
+	}
+	name, ok := call.ArgumentList[0].(*ast.StringLiteral)
+	if !ok {
+		return check{}, true, fmt.Errorf("the names of the tests which aren't strings aren't supported")
+	}
+	c := check{name: name.Value.String()}
+	var body []ast.Statement
+	switch fn := call.ArgumentList[1].(type) {
+	case *ast.FunctionLiteral:
+		body = fn.Body.List
+	case *ast.ArrowFunctionLiteral:
+		switch b := fn.Body.(type) {
+		case *ast.BlockStatement:
+			body = b.List
+		case *ast.ExpressionBody:
+			body = []ast.Statement{&ast.ExpressionStatement{Expression: b.Expression}}
+		}
+	default:
+		return check{}, true, fmt.Errorf("the functions of the tests which aren't literals aren't supported")
+	}
+	var conditions []string
+	var precedences []int
+	for _, s := range body {
+		switch s := s.(type) {
+		case *ast.EmptyStatement:
+		case *ast.VariableStatement, *ast.LexicalDeclaration:
+			code, err := t.declaration(s, "r")
+			if err != nil {
+				return check{}, true, err
+			}
+			c.lines = append(c.lines, code)
+		case *ast.ExpressionStatement:
+			condition, precedence, err := t.assertion(s.Expression)
+			if err != nil {
+				return check{}, true, err
+			}
+			conditions = append(conditions, condition)
+			precedences = append(precedences, precedence)
+		default:
+			return check{}, true, fmt.Errorf("the tests with statements which aren't assertions aren't supported")
+		}
+	}
+	if len(conditions) == 0 {
+		return check{}, true, fmt.Errorf("the tests without assertions aren't supported")
+	}
+	if len(conditions) > 1 {
+		for i, precedence := range precedences {
+			conditions[i] = parenthesize(conditions[i], precedence, binaryPrecedences[token.LOGICAL_AND]+1)
+		}
+	}
+	c.condition = strings.Join(conditions, " && ")
+	return c, true, nil
+}
+
+// assertion returns the condition of a pm.expect or a pm.response.to assertion, and its precedence.
+//nolint:funlen,gocognit,cyclop
+func (t *translator) assertion(e ast.Expression) (string, int, error) {
+	var words []string
+	var args []ast.Expression
+	if call, ok := e.(*ast.CallExpression); ok {
+		args, e = call.ArgumentList, call.Callee
+	}
+	subject, precedence := "", 0
+loop:
+	for {
+		switch c := e.(type) {
+		case *ast.DotExpression:
+			if dottedName(c) == "pm.response" {
+				break loop
+			}
+			words = append([]string{c.Identifier.Name.String()}, words...)
+			e = c.Left
+		case *ast.CallExpression:
+			if dottedName(c.Callee) != "pm.expect" || len(c.ArgumentList) != 1 {
+				return "", 0, fmt.Errorf("the assertions which aren't chains of pm.expect aren't supported")
+			}
+			var err error
+			if subject, precedence, err = t.expression(c.ArgumentList[0], "r"); err != nil {
+				return "", 0, err
+			}
+			break loop
+		default:
+			return "", 0, fmt.Errorf("the assertions which aren't pm.expect or pm.response.to aren't supported")
+		}
+	}
+	negate, name := false, ""
+	for _, word := range words {
+		switch {
+		case word == "not":
+			negate = !negate
+		case languageChains[word]:
+		case name != "":
+			return "", 0, fmt.Errorf("the chained assertion %s.%s isn't supported", name, word)
+		default:
+			name = word
+		}
+	}
+	values := make([]string, len(args))
+	for i, arg := range args {
+		var err error
+		if values[i], _, err = t.expression(arg, "r"); err != nil {
+			return "", 0, err
+		}
+	}
+	unsupported := fmt.Errorf("the assertion %s with %d arguments isn't supported", name, len(args))
+
+	condition, conditionPrecedence := "", binaryPrecedences[token.STRICT_EQUAL]
+	if subject == "" {
+		switch {
+		case responseAssertions[name] != "" && len(args) == 0:
+			condition = fmt.Sprintf(responseAssertions[name], "r")
+			if strings.Contains(condition, "&&") {
+				conditionPrecedence = binaryPrecedences[token.LOGICAL_AND]
+			} else if strings.Contains(condition, ">") {
+				conditionPrecedence = binaryPrecedences[token.GREATER]
+			}
+		case name == "status" && len(args) == 1:
+			condition = "r.status " + equality(negate) + " " + values[0]
+			negate = false
+		case name == "header" && (len(args) == 1 || len(args) == 2):
+			header := "r.headers[" + values[0] + "]"
+			if s, ok := args[0].(*ast.StringLiteral); ok {
+				header = "r.headers[" + quote(textproto.CanonicalMIMEHeaderKey(s.Value.String())) + "]"
+			}
+			if len(args) == 1 {
+				condition = header + " !== undefined"
+			} else {
+				condition = header + " === " + values[1]
+			}
+		case name == "body" && len(args) == 1:
+			condition = "r.body " + equality(negate) + " " + values[0]
+			negate = false
+		default:
+			return "", 0, unsupported
+		}
+	} else {
+		member := parenthesize(subject, precedence, precedenceMember)
+		operand := parenthesize(subject, precedence, binaryPrecedences[token.LESS]+1)
+		switch {
+		case oneOf(name, "equal", "equals", "eq", "eql", "eqls") && len(args) == 1:
+			switch args[0].(type) {
+			case *ast.ObjectLiteral, *ast.ArrayLiteral:
+				condition = "JSON.stringify(" + subject + ") " + equality(negate) + " JSON.stringify(" + values[0] + ")"
+			default:
+				condition = parenthesize(subject, precedence, conditionPrecedence+1) + " " + equality(negate) + " " +
+					parenthesize(values[0], expressionPrecedence(args[0]), conditionPrecedence+1)
+			}
+			negate = false
+		case oneOf(name, "include", "includes", "contain", "contains") && len(args) == 1:
+			condition, conditionPrecedence = member+".includes("+values[0]+")", precedenceMember
+		case oneOf(name, "oneOf") && len(args) == 1:
+			condition = parenthesize(values[0], expressionPrecedence(args[0]), precedenceMember) +
+				".includes(" + subject + ")"
+			conditionPrecedence = precedenceMember
+		case oneOf(name, "below", "lt", "lessThan", "above", "gt", "greaterThan", "least", "gte", "most", "lte") &&
+			len(args) == 1:
+			operator := map[string]string{
+				"below": "<", "lt": "<", "lessThan": "<", "above": ">", "gt": ">", "greaterThan": ">",
+				"least": ">=", "gte": ">=", "most": "<=", "lte": "<=",
+			}[name]
+			condition = operand + " " + operator + " " +
+				parenthesize(values[0], expressionPrecedence(args[0]), binaryPrecedences[token.LESS]+1)
+			conditionPrecedence = binaryPrecedences[token.LESS]
+		case oneOf(name, "property") && (len(args) == 1 || len(args) == 2):
+			property := member + "[" + values[0] + "]"
+			if s, ok := args[0].(*ast.StringLiteral); ok && identifierRe.MatchString(s.Value.String()) {
+				property = member + "." + s.Value.String()
+			}
+			if len(args) == 1 {
+				condition = property + " !== undefined"
+			} else {
+				condition = property + " " + equality(negate) + " " + values[1]
+				negate = false
+			}
+		case oneOf(name, "lengthOf", "length") && len(args) == 1:
+			condition = member + ".length " + equality(negate) + " " + values[0]
+			negate = false
+		case oneOf(name, "match") && len(args) == 1:
+			condition = parenthesize(values[0], expressionPrecedence(args[0]), precedenceMember) +
+				".test(" + subject + ")"
+			conditionPrecedence = precedenceMember
+		case oneOf(name, "a", "an") && len(args) == 1:
+			kind, ok := args[0].(*ast.StringLiteral)
+			switch {
+			case !ok:
+				return "", 0, unsupported
+			case strings.ToLower(kind.Value.String()) == "array":
+				condition, conditionPrecedence = "Array.isArray("+subject+")", precedenceMember
+			case strings.ToLower(kind.Value.String()) == "null":
+				condition = operand + " " + equality(negate) + " null"
+				negate = false
+			default:
+				condition = "typeof " + parenthesize(subject, precedence, precedenceUnary) + " " + equality(negate) +
+					" " + quote(strings.ToLower(kind.Value.String()))
+				negate = false
+			}
+		case oneOf(name, "true", "false", "null", "undefined") && len(args) == 0:
+			condition = parenthesize(subject, precedence, conditionPrecedence+1) + " " + equality(negate) + " " + name
+			negate = false
+		case oneOf(name, "ok") && len(args) == 0:
+			condition, conditionPrecedence = "!!"+parenthesize(subject, precedence, precedenceUnary), precedenceUnary
+		case oneOf(name, "exist", "exists") && len(args) == 0:
+			condition = parenthesize(subject, precedence, conditionPrecedence+1) + " != null"
+			if negate {
+				condition = parenthesize(subject, precedence, conditionPrecedence+1) + " == null"
+				negate = false
+			}
+		case oneOf(name, "empty") && len(args) == 0:
+			condition = member + ".length " + equality(negate) + " 0"
+			negate = false
+		default:
+			return "", 0, unsupported
+		}
+	}
+	if negate {
+		return "!" + parenthesize(condition, conditionPrecedence, precedenceUnary), precedenceUnary, nil
+	}
+	return condition, conditionPrecedence, nil
+}
+
+func equality(negate bool) string {
+	if negate {
+		return "!=="
+	}
+	return "==="
+}
+
+func oneOf(name string, names ...string) bool {
+	for _, n := range names {
+		if name == n {
+			return true
+		}
+	}
+	return false
+}
+
+// parenthesize returns the code of an expression with the precedence in parentheses, if the expression
+// in which it is needs a higher precedence.
+func parenthesize(code string, precedence, needed int) string {
+	if precedence < needed {
+		return "(" + code + ")"
+	}
+	return code
+}
+
+// expressionPrecedence returns the precedence of a translated expression, which is the one of the
+// original expression except for the translations of the pm API, which are all member expressions.
+func expressionPrecedence(e ast.Expression) int {
+	switch e := e.(type) {
+	case *ast.AssignExpression, *ast.ArrowFunctionLiteral:
+		return precedenceAssign
+	case *ast.ConditionalExpression:
+		return precedenceConditional
+	case *ast.BinaryExpression:
+		return binaryPrecedences[e.Operator]
+	case *ast.UnaryExpression:
+		if e.Postfix {
+			return precedencePostfix
+		}
+		return precedenceUnary
+	case *ast.DotExpression, *ast.BracketExpression, *ast.CallExpression, *ast.NewExpression:
+		return precedenceMember
+	default:
+		return precedencePrimary
+	}
+}
+
+// dottedName returns the name of an identifier or of the chain of the properties of an identifier, like
+// pm.response.code, or an empty string for the other expressions.
+func dottedName(e ast.Expression) string {
+	switch e := e.(type) {
+	case *ast.Identifier:
+		return e.Name.String()
+	case *ast.DotExpression:
+		if left := dottedName(e.Left); left != "" {
+			return left + "." + e.Identifier.Name.String()
+		}
+	}
+	return ""
+}
+
+// pmMember translates the members of the pm API and of the legacy globals, whose name is the dotted name.
+func (t *translator) pmMember(name, res string) (string, int, error) {
+	code := map[string]string{
+		"pm.response.code":         ".status",
+		"responseCode.code":        ".status",
+		"pm.response.status":       ".status_text",
+		"pm.response.responseTime": ".timings.duration",
+		"responseTime":             ".timings.duration",
+		"responseBody":             ".body",
+	}[name]
+	switch {
+	case oneOf(name, "environment", "globals"):
+		return "vars", precedencePrimary, nil
+	case strings.HasPrefix(name, "environment.") || strings.HasPrefix(name, "globals."):
+		return "vars." + strings.SplitN(name, ".", 2)[1], precedenceMember, nil
+	case code == "":
+		return "", 0, fmt.Errorf("%s isn't supported", name)
+	case res == "":
+		return "", 0, fmt.Errorf("%s isn't available in the pre-request scripts", name)
+	default:
+		return res + code, precedenceMember, nil
+	}
+}
+
+// pmCall translates the calls of the methods of the pm API and of the legacy postman object.
+func (t *translator) pmCall(name string, args []ast.Expression, res string) (string, int, error) {
+	store, method := name, ""
+	if i := strings.LastIndexByte(name, '.'); i > 0 {
+		store, method = name[:i], name[i+1:]
+	}
+	switch {
+	case (variableStores[store] && method == "get") || oneOf(name, "postman.getEnvironmentVariable",
+		"postman.getGlobalVariable"):
+		if len(args) != 1 {
+			return "", 0, fmt.Errorf("%s needs a name", name)
+		}
+		code, err := t.variable(args[0], res)
+		return code, precedenceMember, err
+	case variableStores[store] && method == "has" && len(args) == 1:
+		code, err := t.variable(args[0], res)
+		return code + " !== undefined", binaryPrecedences[token.STRICT_NOT_EQUAL], err
+	case !oneOf(name, "pm.response.json", "pm.response.text", "pm.response.headers.get"):
+		return "", 0, fmt.Errorf("%s isn't supported", name)
+	case res == "":
+		return "", 0, fmt.Errorf("%s isn't available in the pre-request scripts", name)
+	case name == "pm.response.json" && len(args) == 0:
+		return res + ".json()", precedenceMember, nil
+	case name == "pm.response.text" && len(args) == 0:
+		return res + ".body", precedenceMember, nil
+	case name == "pm.response.headers.get" && len(args) == 1:
+		if s, ok := args[0].(*ast.StringLiteral); ok {
+			return res + ".headers[" + quote(textproto.CanonicalMIMEHeaderKey(s.Value.String())) + "]",
+				precedenceMember, nil
+		}
+		header, _, err := t.expression(args[0], res)
+		return res + ".headers[" + header + "]", precedenceMember, err
+	default:
+		return "", 0, fmt.Errorf("%s with %d arguments isn't supported", name, len(args))
+	}
+}
+
+// expression translates an expression, in which the response is in the variable res, and returns its
+// precedence. The pm API is translated, and the expressions which can't be are errors.
+//nolint:funlen,gocognit,cyclop
+func (t *translator) expression(e ast.Expression, res string) (string, int, error) {
+	if name := dottedName(e); name != "" && postmanGlobals[strings.SplitN(name, ".", 2)[0]] {
+		return t.pmMember(name, res)
+	}
+	switch e := e.(type) {
+	case *ast.Identifier:
+		return t.identifier(e.Name.String()), precedencePrimary, nil
+	case *ast.StringLiteral:
+		return e.Literal, precedencePrimary, nil
+	case *ast.NumberLiteral:
+		return e.Literal, precedencePrimary, nil
+	case *ast.BooleanLiteral:
+		return e.Literal, precedencePrimary, nil
+	case *ast.NullLiteral:
+		return "null", precedencePrimary, nil
+	case *ast.RegExpLiteral:
+		return e.Literal, precedencePrimary, nil
+	case *ast.TemplateLiteral:
+		if e.Tag != nil {
+			return "", 0, fmt.Errorf("its tagged templates aren't supported")
+		}
+		var b strings.Builder
+		b.WriteByte('`')
+		for i, element := range e.Elements {
+			b.WriteString(element.Literal)
+			if i < len(e.Expressions) {
+				code, _, err := t.expression(e.Expressions[i], res)
+				if err != nil {
+					return "", 0, err
+				}
+				b.WriteString("${" + code + "}")
+			}
+		}
+		b.WriteByte('`')
+		return b.String(), precedencePrimary, nil
+	case *ast.ArrayLiteral:
+		elements, err := t.expressions(e.Value, res)
+		return "[" + strings.Join(elements, ", ") + "]", precedencePrimary, err
+	case *ast.ObjectLiteral:
+		properties := make([]string, len(e.Value))
+		for i, p := range e.Value {
+			switch p := p.(type) {
+			case *ast.PropertyShort:
+				name := p.Name.Name.String()
+				if p.Initializer != nil || postmanGlobals[name] {
+					return "", 0, fmt.Errorf("its object literals with the property %s aren't supported", name)
+				}
+				if renamed := t.identifier(name); renamed != name {
+					name += ": " + renamed
+				}
+				properties[i] = name
+			case *ast.PropertyKeyed:
+				if p.Kind != ast.PropertyKindValue {
+					return "", 0, fmt.Errorf("its object literals with methods aren't supported")
+				}
+				key := ""
+				if k, ok := p.Key.(*ast.StringLiteral); ok && !p.Computed {
+					key = k.Literal
+				} else {
+					code, _, err := t.expression(p.Key, res)
+					if err != nil {
+						return "", 0, err
+					}
+					key = "[" + code + "]"
+				}
+				value, _, err := t.expression(p.Value, res)
+				if err != nil {
+					return "", 0, err
+				}
+				properties[i] = key + ": " + value
+			case *ast.SpreadElement:
+				code, _, err := t.expression(p.Expression, res)
+				if err != nil {
+					return "", 0, err
+				}
+				properties[i] = "..." + code
+			}
+		}
+		if len(properties) == 0 {
+			return "{}", precedencePrimary, nil
+		}
+		return "{ " + strings.Join(properties, ", ") + " }", precedencePrimary, nil
+	case *ast.SpreadElement:
+		code, _, err := t.expression(e.Expression, res)
+		return "..." + code, precedenceAssign, err
+	case *ast.DotExpression:
+		left, precedence, err := t.expression(e.Left, res)
+		if _, ok := e.Left.(*ast.NumberLiteral); ok {
+			precedence = 0
+		}
+		return parenthesize(left, precedence, precedenceMember) + "." + e.Identifier.Name.String(),
+			precedenceMember, err
+	case *ast.BracketExpression:
+		left, precedence, err := t.expression(e.Left, res)
+		if err != nil {
+			return "", 0, err
+		}
+		member, _, err := t.expression(e.Member, res)
+		return parenthesize(left, precedence, precedenceMember) + "[" + member + "]", precedenceMember, err
+	case *ast.CallExpression:
+		if name := dottedName(e.Callee); name != "" && postmanGlobals[strings.SplitN(name, ".", 2)[0]] {
+			return t.pmCall(name, e.ArgumentList, res)
+		}
+		callee, precedence, err := t.expression(e.Callee, res)
+		if err != nil {
+			return "", 0, err
+		}
+		args, err := t.expressions(e.ArgumentList, res)
+		return parenthesize(callee, precedence, precedenceMember) + "(" + strings.Join(args, ", ") + ")",
+			precedenceMember, err
+	case *ast.NewExpression:
+		callee, precedence, err := t.expression(e.Callee, res)
+		if err != nil {
+			return "", 0, err
+		}
+		args, err := t.expressions(e.ArgumentList, res)
+		return "new " + parenthesize(callee, precedence, precedencePrimary) + "(" + strings.Join(args, ", ") + ")",
+			precedenceMember, err
+	case *ast.UnaryExpression:
+		operand, precedence, err := t.expression(e.Operand, res)
+		if err != nil {
+			return "", 0, err
+		}
+		if e.Postfix {
+			return parenthesize(operand, precedence, precedencePostfix) + e.Operator.String(), precedencePostfix, nil
+		}
+		operator := e.Operator.String()
+		if oneOf(operator, "typeof", "delete", "void") {
+			operator += " "
+		}
+		return operator + parenthesize(operand, precedence, precedenceUnary), precedenceUnary, nil
+	case *ast.BinaryExpression:
+		operatorPrecedence, ok := binaryPrecedences[e.Operator]
+		if !ok {
+			return "", 0, fmt.Errorf("its operator %s isn't supported", e.Operator)
+		}
+		left, leftPrecedence, err := t.expression(e.Left, res)
+		if err != nil {
+			return "", 0, err
+		}
+		right, rightPrecedence, err := t.expression(e.Right, res)
+		if err != nil {
+			return "", 0, err
+		}
+		return parenthesize(left, leftPrecedence, operatorPrecedence) + " " + e.Operator.String() + " " +
+			parenthesize(right, rightPrecedence, operatorPrecedence+1), operatorPrecedence, nil
+	case *ast.ConditionalExpression:
+		expressions, err := t.expressions([]ast.Expression{e.Test, e.Consequent, e.Alternate}, res)
+		if err != nil {
+			return "", 0, err
+		}
+		test := parenthesize(expressions[0], expressionPrecedence(e.Test), precedenceConditional+1)
+		return test + " ? " + expressions[1] + " : " + expressions[2], precedenceConditional, nil
+	case *ast.AssignExpression:
+		left, _, err := t.expression(e.Left, res)
+		if err != nil {
+			return "", 0, err
+		}
+		right, _, err := t.expression(e.Right, res)
+		return left + " " + e.Operator.String() + " " + right, precedenceAssign, err
+	case *ast.ArrowFunctionLiteral:
+		body, ok := e.Body.(*ast.ExpressionBody)
+		if !ok || e.ParameterList.Rest != nil {
+			return "", 0, fmt.Errorf("its functions which aren't arrow functions of expressions aren't supported")
+		}
+		params := make([]string, len(e.ParameterList.List))
+		for i, p := range e.ParameterList.List {
+			id, ok := p.Target.(*ast.Identifier)
+			if !ok || p.Initializer != nil {
+				return "", 0, fmt.Errorf("its functions with destructured parameters aren't supported")
+			}
+			params[i] = t.identifier(id.Name.String())
+		}
+		code, _, err := t.expression(body.Expression, res)
+		return "(" + strings.Join(params, ", ") + ") => " + code, precedenceAssign, err
+	default:
+		return "", 0, fmt.Errorf("its %T expressions aren't supported", e)
+	}
+}
+
+func (t *translator) expressions(list []ast.Expression, res string) ([]string, error) {
+	codes := make([]string, len(list))
+	for i, e := range list {
+		if e == nil {
+			continue
+		}
+		var err error
+		if codes[i], _, err = t.expression(e, res); err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
@@ -0,0 +1,144 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package postman
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranslateScript(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name, source, res, expected string
+	}{
+		{
+			name: "variables",
+			source: "pm.environment.set('token', pm.response.json().token);\n" +
+				"pm.collectionVariables.unset(\"old-id\");",
+			res:      "res",
+			expected: "vars.token = res.json().token;\ndelete vars[\"old-id\"];",
+		},
+		{
+			name: "legacy variables",
+			source: "postman.setEnvironmentVariable('id', JSON.parse(responseBody).id + 1);\n" +
+				"var u = environment.url;",
+			res:      "res",
+			expected: "{\n\tvars.id = JSON.parse(res.body).id + 1;\n\tlet u = vars.url;\n}",
+		},
+		{
+			name: "tests",
+			source: `pm.test("Status code is 200", function () {
+    pm.response.to.have.status(200);
+});
+pm.test("Body", () => {
+    const body = pm.response.json();
+    pm.expect(body.items).to.have.lengthOf(2);
+    pm.expect(body.name).to.not.equal("x");
+    pm.expect(pm.response.text()).to.include("items");
+});
+tests["Fast"] = responseTime < 200;`,
+			res: "res",
+			expected: `check(res, {
+	'Status code is 200': (r) => r.status === 200,
+	'Body': (r) => {
+		const body = r.json();
+		return body.items.length === 2 && body.name !== "x" && r.body.includes("items");
+	},
+	'Fast': (r) => r.timings.duration < 200,
+});`,
+		},
+		{
+			name: "assertions",
+			source: `pm.test("a", () => pm.response.to.be.success);
+pm.test("b", () => pm.expect(pm.response.headers.get("content-type")).to.be.a("string"));
+pm.test("c", () => pm.expect(pm.response.json().tags).to.eql(["x"]));
+pm.test("d", () => pm.expect(pm.response.code).to.be.oneOf([200, 201]));
+pm.test("e", () => pm.expect(pm.response.json().a + 1).to.be.at.least(2));
+pm.test("f", () => pm.expect(pm.response.json().ok).to.not.be.true);`,
+			res: "res",
+			expected: `check(res, {
+	'a': (r) => r.status >= 200 && r.status < 300,
+	'b': (r) => typeof r.headers['Content-Type'] === 'string',
+	'c': (r) => JSON.stringify(r.json().tags) === JSON.stringify(["x"]),
+	'd': (r) => [200, 201].includes(r.status),
+	'e': (r) => r.json().a + 1 >= 2,
+	'f': (r) => r.json().ok !== true,
+});`,
+		},
+		{
+			name: "conditions",
+			source: `if (pm.response.code === 201) {
+    pm.variables.set("id", pm.response.json().id);
+} else {
+    pm.test("Not created", () => pm.response.to.have.status(200));
+}`,
+			res: "res",
+			expected: `if (res.status === 201) {
+	vars.id = res.json().id;
+} else {
+	check(res, {
+		'Not created': (r) => r.status === 200,
+	});
+}`,
+		},
+		{
+			name: "untranslatable",
+			source: "pm.sendRequest('https://example.com', (err, res) => {\n    console.log(res);\n});\n" +
+				"console.log(pm.info.requestName);",
+			res: "res",
+			expected: `// TODO: translate this code of the Postman script, pm.sendRequest isn't supported
+// pm.sendRequest('https://example.com', (err, res) => {
+//     console.log(res);
+// });
+// TODO: translate this code of the Postman script, pm.info.requestName isn't supported
+// console.log(pm.info.requestName);`,
+		},
+		{
+			name:   "pre-request",
+			source: "pm.variables.set('now', Date.now());\npm.test('x', () => pm.response.to.be.ok);",
+			expected: `vars.now = Date.now();
+// TODO: translate this code of the Postman script, the tests of the pre-request scripts aren't supported
+// pm.test('x', () => pm.response.to.be.ok);`,
+		},
+		{
+			name:     "renamed variables",
+			source:   "let res = pm.response.json();\nconsole.log(res.id);",
+			res:      "res",
+			expected: "{\n\tlet res_ = res.json();\n\tconsole.log(res_.id);\n}",
+		},
+		{
+			name:     "syntax error",
+			source:   "pm.test('x', () => {",
+			res:      "res",
+			expected: "// TODO: translate this Postman script, which couldn't be parsed\n// pm.test('x', () => {",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.expected, strings.Join(translateScript(test.source, test.res), "\n"))
+		})
+	}
+}
@@ -0,0 +1,234 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package postman converts Postman collections to k6 scripts.
+package postman
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Collection is a collection of the Postman v2.1 format, or of the compatible v2.0 one.
+type Collection struct {
+	Info     Info       `json:"info"`
+	Item     []*Item    `json:"item"`
+	Auth     *Auth      `json:"auth"`
+	Event    []*Event   `json:"event"`
+	Variable []Variable `json:"variable"`
+}
+
+// Info is the information about a collection.
+type Info struct {
+	Name   string `json:"name"`
+	Schema string `json:"schema"`
+}
+
+// Item is a folder, which has items, or a request.
+type Item struct {
+	Name    string   `json:"name"`
+	Item    []*Item  `json:"item"`
+	Request *Request `json:"request"`
+	Auth    *Auth    `json:"auth"`
+	Event   []*Event `json:"event"`
+}
+
+// Request is the request of an item.
+type Request struct {
+	Method string     `json:"method"`
+	URL    URL        `json:"url"`
+	Header []KeyValue `json:"header"`
+	Body   *Body      `json:"body"`
+	Auth   *Auth      `json:"auth"`
+}
+
+// URL is the URL of a request, which can be a string or an object in the collections.
+type URL struct {
+	Raw      string      `json:"raw"`
+	Protocol string      `json:"protocol"`
+	Host     stringParts `json:"host"`
+	Port     string      `json:"port"`
+	Path     stringParts `json:"path"`
+	Query    []KeyValue  `json:"query"`
+}
+
+// UnmarshalJSON unmarshals the URL from a string or an object.
+func (u *URL) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		*u = URL{}
+		return json.Unmarshal(data, &u.Raw)
+	}
+	type plain URL
+	return json.Unmarshal(data, (*plain)(u))
+}
+
+// String returns the raw URL, or the URL made of its parts when the raw one is missing.
+func (u URL) String() string {
+	if u.Raw != "" {
+		return u.Raw
+	}
+	var b strings.Builder
+	if u.Protocol != "" {
+		b.WriteString(u.Protocol + "://")
+	}
+	b.WriteString(strings.Join(u.Host, "."))
+	if u.Port != "" {
+		b.WriteString(":" + u.Port)
+	}
+	if len(u.Path) > 0 {
+		b.WriteString("/" + strings.Join(u.Path, "/"))
+	}
+	separator := "?"
+	for _, q := range u.Query {
+		if !q.Disabled {
+			b.WriteString(separator + q.Key + "=" + q.Value)
+			separator = "&"
+		}
+	}
+	return b.String()
+}
+
+// stringParts are the parts of a host or of a path, which can be a string or an array in the collections.
+type stringParts []string
+
+// UnmarshalJSON unmarshals the parts from a string or an array of strings.
+func (p *stringParts) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*p = stringParts{s}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(p))
+}
+
+// KeyValue is a header, a query parameter or a field of a form.
+type KeyValue struct {
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	Disabled bool   `json:"disabled"`
+	// Type is text or file, for the fields of the multipart forms.
+	Type string `json:"type"`
+	// Src is the path of the file, for the file fields of the multipart forms.
+	Src interface{} `json:"src"`
+}
+
+// Body is the body of a request.
+type Body struct {
+	// Mode is raw, urlencoded, formdata, file or graphql.
+	Mode       string     `json:"mode"`
+	Raw        string     `json:"raw"`
+	URLEncoded []KeyValue `json:"urlencoded"`
+	FormData   []KeyValue `json:"formdata"`
+	File       *struct {
+		Src string `json:"src"`
+	} `json:"file"`
+	GraphQL *struct {
+		Query     string `json:"query"`
+		Variables string `json:"variables"`
+	} `json:"graphql"`
+	Options struct {
+		Raw struct {
+			Language string `json:"language"`
+		} `json:"raw"`
+	} `json:"options"`
+	Disabled bool `json:"disabled"`
+}
+
+// Auth is the authentication of a collection, a folder or a request, whose attributes are in the
+// field of its type.
+type Auth struct {
+	Type   string          `json:"type"`
+	Bearer []AuthAttribute `json:"bearer"`
+	Basic  []AuthAttribute `json:"basic"`
+	APIKey []AuthAttribute `json:"apikey"`
+	OAuth2 []AuthAttribute `json:"oauth2"`
+}
+
+// AuthAttribute is an attribute of an authentication.
+type AuthAttribute struct {
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+// Event is a pre-request or a test script.
+type Event struct {
+	// Listen is prerequest or test.
+	Listen   string `json:"listen"`
+	Script   Script `json:"script"`
+	Disabled bool   `json:"disabled"`
+}
+
+// Script is the code of an event.
+type Script struct {
+	Exec stringParts `json:"exec"`
+}
+
+// Variable is a variable of a collection.
+type Variable struct {
+	Key      string      `json:"key"`
+	Value    interface{} `json:"value"`
+	Disabled bool        `json:"disabled"`
+}
+
+// Environment is a Postman environment, whose variables override the ones of the collections.
+type Environment struct {
+	Name   string `json:"name"`
+	Values []struct {
+		Key     string      `json:"key"`
+		Value   interface{} `json:"value"`
+		Enabled *bool       `json:"enabled"`
+	} `json:"values"`
+}
+
+// IsCollection returns whether the data is a Postman collection, from the schema of its information.
+func IsCollection(data []byte) bool {
+	var c struct {
+		Info struct {
+			Schema string `json:"schema"`
+		} `json:"info"`
+	}
+	return json.Unmarshal(data, &c) == nil && strings.Contains(c.Info.Schema, "getpostman.com/json/collection/")
+}
+
+// Decode decodes a collection, which needs to be of the v2.1 or v2.0 format.
+func Decode(r io.Reader) (*Collection, error) {
+	var c Collection
+	if err := json.NewDecoder(r).Decode(&c); err != nil {
+		return nil, err
+	}
+	if !strings.Contains(c.Info.Schema, "/collection/v2.1.") && !strings.Contains(c.Info.Schema, "/collection/v2.0.") {
+		return nil, fmt.Errorf("the collection isn't of the Postman v2.1 format, export it in this format")
+	}
+	return &c, nil
+}
+
+// DecodeEnvironment decodes an environment.
+func DecodeEnvironment(r io.Reader) (*Environment, error) {
+	var env Environment
+	if err := json.NewDecoder(r).Decode(&env); err != nil {
+		return nil, err
+	}
+	return &env, nil
+}
@@ -0,0 +1,409 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package recorder records the requests made by browsers and other clients through an HTTP(S) proxy, and
+// generates k6 scripts from the recordings.
+package recorder
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.k6.io/k6/converter/har"
+	"go.k6.io/k6/lib/consts"
+)
+
+// maxRecordedBodySize is the size after which the bodies aren't recorded, they are still proxied.
+const maxRecordedBodySize = 1 << 20
+
+// hopByHopHeaders are the headers which are only for the connections to the proxy.
+var hopByHopHeaders = []string{ //nolint:gochecknoglobals
+	"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization", "Proxy-Connection",
+	"Te", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+// Recorder is an HTTP proxy which records the requests which go through it, with their responses.
+// The HTTPS requests are intercepted with certificates signed by its certificate authority.
+type Recorder struct {
+	ca        *tls.Certificate
+	transport http.RoundTripper
+	logger    logrus.FieldLogger
+
+	certsMu sync.Mutex
+	certs   map[string]*tls.Certificate
+
+	entriesMu sync.Mutex
+	entries   []*har.Entry
+}
+
+// New returns a recorder whose certificate authority has the PEM encoded certificate and key.
+func New(caCertPEM, caKeyPEM []byte, logger logrus.FieldLogger) (*Recorder, error) {
+	ca, err := tls.X509KeyPair(caCertPEM, caKeyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate authority: %w", err)
+	}
+	if ca.Leaf, err = x509.ParseCertificate(ca.Certificate[0]); err != nil {
+		return nil, fmt.Errorf("invalid certificate authority: %w", err)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone() //nolint:forcetypeassert
+	transport.Proxy = nil
+	return &Recorder{
+		ca:        &ca,
+		transport: transport,
+		logger:    logger,
+		certs:     make(map[string]*tls.Certificate),
+	}, nil
+}
+
+// ServeHTTP proxies and records the requests, and intercepts the HTTPS connections.
+func (r *Recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodConnect {
+		r.intercept(w, req)
+		return
+	}
+	if !req.URL.IsAbs() {
+		http.Error(w, "k6 record is an HTTP proxy, the URLs of the requests need to be absolute", http.StatusBadRequest)
+		return
+	}
+	resp, body, err := r.roundTrip(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer func() { _ = body.Close() }()
+	for name, values := range resp.Header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(w, body)
+}
+
+// intercept serves the requests of a CONNECT tunnel over TLS, with a certificate for its host.
+func (r *Recorder) intercept(w http.ResponseWriter, req *http.Request) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "the connection can't be intercepted", http.StatusInternalServerError)
+		return
+	}
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		r.logger.WithError(err).Warn("Couldn't intercept a connection")
+		return
+	}
+	defer func() { _ = conn.Close() }()
+	if _, err = io.WriteString(conn, "HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+		return
+	}
+
+	host, _, err := net.SplitHostPort(req.Host)
+	if err != nil {
+		host = req.Host
+	}
+	tlsConn := tls.Server(conn, &tls.Config{ //nolint:gosec
+		NextProtos: []string{"http/1.1"},
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			if hello.ServerName != "" {
+				return r.certificate(hello.ServerName)
+			}
+			return r.certificate(host)
+		},
+	})
+	reader := bufio.NewReader(tlsConn)
+	for {
+		tunneled, err := http.ReadRequest(reader)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				r.logger.WithError(err).Debugf("Couldn't read a request to %s", req.Host)
+			}
+			return
+		}
+		tunneled.URL.Scheme, tunneled.URL.Host = "https", req.Host
+		if !r.serveTunneled(tlsConn, tunneled) {
+			return
+		}
+	}
+}
+
+// serveTunneled proxies a request of a tunnel, and returns whether the connection is kept alive.
+func (r *Recorder) serveTunneled(conn io.Writer, req *http.Request) bool {
+	resp, body, err := r.roundTrip(req)
+	if err != nil {
+		resp = &http.Response{
+			StatusCode: http.StatusBadGateway,
+			Header:     http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+			Close:      true,
+		}
+		body = ioutil.NopCloser(strings.NewReader(err.Error()))
+		resp.ContentLength = int64(len(err.Error()))
+	}
+	defer func() { _ = body.Close() }()
+	resp.ProtoMajor, resp.ProtoMinor, resp.Request = 1, 1, req
+	resp.Body, resp.Uncompressed = body, false
+	if resp.ContentLength < 0 {
+		resp.TransferEncoding = []string{"chunked"}
+	}
+	if err = resp.Write(conn); err != nil {
+		return false
+	}
+	return !resp.Close && !req.Close
+}
+
+// roundTrip makes the request and records it, the response is recorded as its body is read.
+func (r *Recorder) roundTrip(req *http.Request) (*http.Response, io.ReadCloser, error) {
+	start := time.Now()
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		if reqBody, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	out := req.Clone(req.Context())
+	out.RequestURI = ""
+	out.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+	out.ContentLength = int64(len(reqBody))
+	removeHopByHopHeaders(out.Header)
+	// the responses are decompressed by the transport, so they can be recorded
+	out.Header.Del("Accept-Encoding")
+
+	entry := &har.Entry{
+		StartedDateTime: start,
+		Request:         newRequest(req, reqBody),
+		Cache:           &har.Cache{},
+		Timings:         &har.Timings{},
+	}
+	resp, err := r.transport.RoundTrip(out)
+	if err != nil {
+		r.logger.WithError(err).Debugf("Request %s %s failed", req.Method, req.URL)
+		entry.Time = float32(time.Since(start).Seconds() * 1000)
+		r.addEntry(entry)
+		return nil, nil, err
+	}
+	removeHopByHopHeaders(resp.Header)
+	entry.Response = newResponse(resp)
+	body := &recordedBody{ReadCloser: resp.Body, done: func(b *recordedBody) {
+		entry.Time = float32(b.end.Sub(start).Seconds() * 1000)
+		entry.Timings.Wait = entry.Time
+		entry.Response.BodySize = b.size
+		entry.Response.Content.Size = b.size
+		if !b.truncated {
+			entry.Response.Content.Text = b.buf.String()
+		}
+		r.addEntry(entry)
+	}}
+	return resp, body, nil
+}
+
+func (r *Recorder) addEntry(entry *har.Entry) {
+	r.entriesMu.Lock()
+	defer r.entriesMu.Unlock()
+	r.entries = append(r.entries, entry)
+}
+
+func (r *Recorder) certificate(host string) (*tls.Certificate, error) {
+	r.certsMu.Lock()
+	defer r.certsMu.Unlock()
+	if cert, ok := r.certs[host]; ok {
+		return cert, nil
+	}
+	cert, err := newHostCertificate(r.ca, host)
+	if err != nil {
+		return nil, err
+	}
+	r.certs[host] = cert
+	return cert, nil
+}
+
+// HAR returns the recording, in which a request starts a new page if it's the request of a document,
+// like the ones of the navigations of the browsers, or if it's made after no requests were made for the
+// group threshold.
+func (r *Recorder) HAR(groupThreshold time.Duration) har.HAR {
+	r.entriesMu.Lock()
+	entries := append([]*har.Entry{}, r.entries...)
+	r.entriesMu.Unlock()
+	sort.Sort(har.EntryByStarted(entries))
+
+	var pages []har.Page
+	var lastEnd time.Time
+	for _, e := range entries {
+		if len(pages) == 0 || isDocumentRequest(e.Request) || e.StartedDateTime.Sub(lastEnd) > groupThreshold {
+			pages = append(pages, har.Page{
+				StartedDateTime: e.StartedDateTime,
+				ID:              fmt.Sprintf("page_%d", len(pages)+1),
+				Title:           strings.SplitN(e.Request.URL, "?", 2)[0],
+			})
+		}
+		e.Pageref = pages[len(pages)-1].ID
+		if end := e.StartedDateTime.Add(time.Duration(e.Time) * time.Millisecond); end.After(lastEnd) {
+			lastEnd = end
+		}
+	}
+
+	return har.HAR{Log: &har.Log{
+		Version: "1.2",
+		Creator: &har.Creator{Name: "k6 record", Version: consts.Version},
+		Pages:   pages,
+		Entries: entries,
+	}}
+}
+
+// isDocumentRequest returns whether the request is for a document, and not for one of its resources.
+func isDocumentRequest(req *har.Request) bool {
+	if req.Method != http.MethodGet {
+		return false
+	}
+	for _, h := range req.Headers {
+		switch {
+		case strings.EqualFold(h.Name, "Sec-Fetch-Dest"):
+			return h.Value == "document"
+		case strings.EqualFold(h.Name, "Accept"):
+			if strings.HasPrefix(h.Value, "text/html") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func removeHopByHopHeaders(header http.Header) {
+	for _, name := range header.Values("Connection") {
+		for _, n := range strings.Split(name, ",") {
+			header.Del(strings.TrimSpace(n))
+		}
+	}
+	for _, name := range hopByHopHeaders {
+		header.Del(name)
+	}
+}
+
+func newRequest(req *http.Request, body []byte) *har.Request {
+	r := &har.Request{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		HTTPVersion: req.Proto,
+		Headers:     newHeaders(req.Header),
+		HeadersSize: -1,
+		BodySize:    int64(len(body)),
+	}
+	if req.Host != "" && req.Host != req.URL.Host {
+		r.Headers = append([]har.Header{{Name: "Host", Value: req.Host}}, r.Headers...)
+	}
+	for name, values := range req.URL.Query() {
+		for _, value := range values {
+			r.QueryString = append(r.QueryString, har.QueryString{Name: name, Value: value})
+		}
+	}
+	for _, c := range req.Cookies() {
+		r.Cookies = append(r.Cookies, har.Cookie{Name: c.Name, Value: c.Value})
+	}
+	if len(body) > 0 {
+		r.PostData = &har.PostData{MimeType: req.Header.Get("Content-Type"), Text: string(body)}
+	}
+	return r
+}
+
+func newResponse(resp *http.Response) *har.Response {
+	r := &har.Response{
+		Status:      resp.StatusCode,
+		StatusText:  strings.TrimSpace(strings.TrimPrefix(resp.Status, fmt.Sprint(resp.StatusCode))),
+		HTTPVersion: resp.Proto,
+		Headers:     newHeaders(resp.Header),
+		Content:     &har.Content{MimeType: resp.Header.Get("Content-Type")},
+		RedirectURL: resp.Header.Get("Location"),
+		HeadersSize: -1,
+	}
+	for _, c := range resp.Cookies() {
+		r.Cookies = append(r.Cookies, har.Cookie{
+			Name: c.Name, Value: c.Value, Path: c.Path, Domain: c.Domain, HTTPOnly: c.HttpOnly, Secure: c.Secure,
+		})
+	}
+	return r
+}
+
+// newHeaders returns the headers sorted by their names.
+func newHeaders(header http.Header) []har.Header {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	headers := make([]har.Header, 0, len(header))
+	for _, name := range names {
+		for _, value := range header[name] {
+			headers = append(headers, har.Header{Name: name, Value: value})
+		}
+	}
+	return headers
+}
+
+// recordedBody records a response body as it's read, up to maxRecordedBodySize,
+// and calls done once it's closed.
+type recordedBody struct {
+	io.ReadCloser
+	end       time.Time // when the body was read, or closed before that
+	buf       bytes.Buffer
+	size      int64
+	truncated bool
+	done      func(*recordedBody)
+	once      sync.Once
+}
+
+func (b *recordedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.size += int64(n)
+	if !b.truncated {
+		if b.buf.Len()+n > maxRecordedBodySize {
+			b.truncated = true
+			b.buf.Reset()
+		} else {
+			b.buf.Write(p[:n])
+		}
+	}
+	if err != nil && b.end.IsZero() {
+		b.end = time.Now()
+	}
+	return n, err
+}
+
+func (b *recordedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		if b.end.IsZero() {
+			b.end = time.Now()
+		}
+		b.done(b)
+	})
+	return err
+}
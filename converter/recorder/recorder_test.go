@@ -0,0 +1,131 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package recorder
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/converter/har"
+)
+
+func TestRecorder(t *testing.T) {
+	t.Parallel()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Csrf-Token", "csrf-"+r.Method)
+		_, _ = fmt.Fprintf(w, `{"method": %q, "body": %q}`, r.Method, body)
+	})
+	httpServer := httptest.NewServer(handler)
+	defer httpServer.Close()
+	httpsServer := httptest.NewTLSServer(handler)
+	defer httpsServer.Close()
+
+	caCert, caKey, err := NewCA()
+	require.NoError(t, err)
+	recorder, err := New(caCert, caKey, logrus.New())
+	require.NoError(t, err)
+	// the certificate of the test server is self-signed
+	recorder.transport = httpsServer.Client().Transport
+	proxy := httptest.NewServer(recorder)
+	defer proxy.Close()
+
+	block, _ := pem.Decode(caCert)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	proxyURL, err := url.Parse(proxy.URL)
+	require.NoError(t, err)
+	client := &http.Client{Transport: &http.Transport{
+		Proxy:           http.ProxyURL(proxyURL),
+		TLSClientConfig: httpsServer.Client().Transport.(*http.Transport).TLSClientConfig.Clone(),
+	}}
+	client.Transport.(*http.Transport).TLSClientConfig.RootCAs = roots
+
+	req, err := http.NewRequest(http.MethodGet, httpServer.URL+"/page?a=b", nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", "text/html")
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, `{"method": "GET", "body": ""}`, string(body))
+
+	// both requests go through the same intercepted connection
+	for i := 0; i < 2; i++ {
+		resp, err = client.Post(httpsServer.URL+"/api", "application/json", strings.NewReader(`{"a": 1}`))
+		require.NoError(t, err)
+		body, err = ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		assert.Equal(t, `{"method": "POST", "body": "{\"a\": 1}"}`, string(body))
+	}
+
+	h := recorder.HAR(time.Minute)
+	require.Len(t, h.Log.Entries, 3)
+	require.Len(t, h.Log.Pages, 1)
+	assert.Equal(t, "page_1", h.Log.Pages[0].ID)
+	assert.Equal(t, httpServer.URL+"/page", h.Log.Pages[0].Title)
+
+	get := h.Log.Entries[0]
+	assert.Equal(t, "page_1", get.Pageref)
+	assert.Equal(t, "GET", get.Request.Method)
+	assert.Equal(t, httpServer.URL+"/page?a=b", get.Request.URL)
+	assert.Equal(t, "b", get.Request.QueryString[0].Value)
+	assert.Equal(t, 200, get.Response.Status)
+	assert.Equal(t, `{"method": "GET", "body": ""}`, get.Response.Content.Text)
+
+	post := h.Log.Entries[2]
+	assert.Equal(t, "page_1", post.Pageref)
+	assert.Equal(t, "POST", post.Request.Method)
+	assert.Equal(t, httpsServer.URL+"/api", post.Request.URL)
+	assert.Equal(t, `{"a": 1}`, post.Request.PostData.Text)
+	assert.Equal(t, "application/json", post.Response.Content.MimeType)
+	assert.Contains(t, newHeadersMap(post.Response.Headers), "X-Csrf-Token")
+
+	// a document request starts a new page, and so does a request after the group threshold
+	h = recorder.HAR(0)
+	require.Len(t, h.Log.Pages, 3)
+}
+
+func newHeadersMap(headers []har.Header) map[string]string {
+	m := make(map[string]string, len(headers))
+	for _, h := range headers {
+		m[h.Name] = h.Value
+	}
+	return m
+}
@@ -0,0 +1,421 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package recorder
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"go.k6.io/k6/converter/har"
+)
+
+// minCorrelatedLength is the length of the shortest values of the responses which are correlated,
+// the shorter ones are too likely to be in the requests by chance.
+const minCorrelatedLength = 8
+
+// ignoredHeaders are the headers of the requests which are set by k6, or which depend on its cache.
+var ignoredHeaders = map[string]bool{ //nolint:gochecknoglobals
+	"Accept-Encoding": true, "Connection": true, "Content-Length": true, "Cookie": true, "Host": true,
+	"If-Modified-Since": true, "If-None-Match": true, "Proxy-Connection": true,
+}
+
+//nolint:gochecknoglobals
+var (
+	inputRegexp     = regexp.MustCompile(`(?i)<input\s[^>]*>`)
+	attributeRegexp = regexp.MustCompile(`([\w-]+)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+	headerRegexp    = regexp.MustCompile(`(?i)token|csrf|xsrf|session`)
+	nameRegexp      = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+)
+
+// ScriptOptions are the options of the scripts generated from the recordings.
+type ScriptOptions struct {
+	// SleepThreshold is the shortest time between two requests which is kept as a sleep.
+	SleepThreshold time.Duration
+	// Correlate replaces the values in the requests which were returned by the previous
+	// responses with the ones of the responses of the test.
+	Correlate bool
+	// Only and Skip filter the requests by their hosts, like in k6 convert.
+	Only, Skip []string
+}
+
+// correlation is a value of a response, and the expression which gets it from the response.
+type correlation struct {
+	value, name, expr string
+	used              bool
+}
+
+// scriptEntry is the code of the request of an entry, and the values which its response returns.
+type scriptEntry struct {
+	code       string
+	extracted  []*correlation
+	start, end time.Time
+}
+
+type generator struct {
+	opts ScriptOptions
+
+	correlations map[string]*correlation
+	names        int
+	// the values of the previous requests, which aren't correlated when the responses return them
+	sent strings.Builder
+	// the cookies which were set by the responses, and are in the cookie jar of the VUs
+	setCookies map[string]bool
+}
+
+// GenerateScript returns a script which makes the requests of the recording, in groups for its pages,
+// with sleeps for the times between them.
+func GenerateScript(h har.HAR, opts ScriptOptions) (string, error) {
+	if h.Log == nil {
+		return "", fmt.Errorf("invalid recording, the 'log' property is missing")
+	}
+	g := &generator{opts: opts, correlations: make(map[string]*correlation), setCookies: make(map[string]bool)}
+
+	entries := append([]*har.Entry{}, h.Log.Entries...)
+	sort.Sort(har.EntryByStarted(entries))
+	pages := append([]har.Page{}, h.Log.Pages...)
+	sort.Sort(har.PageByStarted(pages))
+	pageIndexes := make(map[string]int, len(pages))
+	for i, page := range pages {
+		pageIndexes[page.ID] = i
+	}
+	groups := make([][]*scriptEntry, len(pages)+1) // the last one is for the entries without pages
+	for _, e := range entries {
+		u, err := url.Parse(e.Request.URL)
+		if err != nil {
+			return "", err
+		}
+		if !har.IsAllowedURL(u.Host, opts.Only, opts.Skip) {
+			continue
+		}
+		i, ok := pageIndexes[e.Pageref]
+		if !ok {
+			i = len(pages)
+		}
+		groups[i] = append(groups[i], g.entry(e))
+	}
+
+	var w strings.Builder
+	w.WriteString("import { group, sleep } from 'k6';\nimport http from 'k6/http';\n\n")
+	fmt.Fprintf(&w, "// Recorded by %s\n\n", creatorName(h.Log))
+	w.WriteString("export let options = {\n")
+	w.WriteString("\t// the redirects were recorded as separate requests\n\tmaxRedirects: 0,\n};\n\n")
+	w.WriteString("export default function() {\n\tlet res;\n")
+	var names []string
+	for _, group := range groups {
+		for _, se := range group {
+			for _, c := range se.extracted {
+				if c.used {
+					names = append(names, c.name)
+				}
+			}
+		}
+	}
+	if len(names) > 0 {
+		fmt.Fprintf(&w, "\t// the values of the responses, which are sent by the next requests\n\tlet %s;\n",
+			strings.Join(names, ", "))
+	}
+
+	var previous *scriptEntry
+	for i, group := range groups {
+		if len(group) == 0 {
+			continue
+		}
+		g.writeSleep(&w, "\t", previous, group[0])
+		title := "Recording"
+		if i < len(pages) {
+			title = pages[i].ID + " - " + pages[i].Title
+		}
+		fmt.Fprintf(&w, "\n\tgroup(%q, function() {\n", title)
+		for j, se := range group {
+			if j > 0 {
+				g.writeSleep(&w, "\t\t", group[j-1], se)
+			}
+			w.WriteString(se.code)
+			for _, c := range se.extracted {
+				if c.used {
+					fmt.Fprintf(&w, "\t\t%s = %s;\n", c.name, c.expr)
+				}
+			}
+		}
+		w.WriteString("\t});\n")
+		previous = group[len(group)-1]
+	}
+	w.WriteString("}\n")
+	return w.String(), nil
+}
+
+func creatorName(log *har.Log) string {
+	if log.Creator == nil {
+		return "k6 record"
+	}
+	return strings.TrimSpace(log.Creator.Name + " " + log.Creator.Version)
+}
+
+// writeSleep writes the sleep for the time between two requests, if it's long enough.
+func (g *generator) writeSleep(w *strings.Builder, indent string, previous, next *scriptEntry) {
+	if previous == nil {
+		return
+	}
+	if gap := next.start.Sub(previous.end); gap >= g.opts.SleepThreshold && gap > 0 {
+		fmt.Fprintf(w, "%ssleep(%.2f);\n", indent, gap.Seconds())
+	}
+}
+
+// entry returns the code of the request of the entry, and adds the values of its response
+// to the correlations.
+func (g *generator) entry(e *har.Entry) *scriptEntry {
+	se := &scriptEntry{
+		start: e.StartedDateTime,
+		end:   e.StartedDateTime.Add(time.Duration(float64(e.Time) * float64(time.Millisecond))),
+	}
+	req := e.Request
+	if req.PostData != nil && !utf8.ValidString(req.PostData.Text) {
+		se.code = fmt.Sprintf("\t\t// the request %s %s was skipped, its body is binary\n", req.Method, req.URL)
+		return se
+	}
+
+	var code strings.Builder
+	urlString := g.literal(req.URL)
+	if req.Method == http.MethodGet {
+		fmt.Fprintf(&code, "\t\tres = http.get(%s", urlString)
+	} else {
+		body := "null"
+		if req.PostData != nil {
+			body = g.literal(req.PostData.Text)
+		}
+		fmt.Fprintf(&code, "\t\tres = http.request(%q, %s, %s", req.Method, urlString, body)
+	}
+	var params []string
+	if headers := g.headers(req); len(headers) > 0 {
+		params = append(params, "\t\t\theaders: {\n"+strings.Join(headers, "")+"\t\t\t},\n")
+	}
+	if cookies := g.cookies(req); len(cookies) > 0 {
+		params = append(params, "\t\t\tcookies: {\n"+strings.Join(cookies, "")+"\t\t\t},\n")
+	}
+	if len(params) > 0 {
+		code.WriteString(", {\n" + strings.Join(params, "") + "\t\t}")
+	}
+	code.WriteString(");\n")
+	se.code = code.String()
+
+	g.sent.WriteString(req.URL)
+	if req.PostData != nil {
+		g.sent.WriteString(req.PostData.Text)
+	}
+	for _, h := range req.Headers {
+		g.sent.WriteString(h.Value)
+	}
+	if e.Response != nil {
+		for _, c := range e.Response.Cookies {
+			g.setCookies[c.Name] = true
+		}
+		if g.opts.Correlate {
+			se.extracted = g.extract(e.Response)
+		}
+	}
+	return se
+}
+
+func (g *generator) headers(req *har.Request) []string {
+	var headers []string
+	seen := make(map[string]bool)
+	for _, h := range req.Headers {
+		name := http.CanonicalHeaderKey(h.Name)
+		if strings.HasPrefix(h.Name, ":") || ignoredHeaders[name] || seen[name] {
+			continue
+		}
+		seen[name] = true
+		headers = append(headers, fmt.Sprintf("\t\t\t\t%q: %s,\n", name, g.literal(h.Value)))
+	}
+	return headers
+}
+
+// cookies returns the cookies of the request which weren't set by the previous responses,
+// like the ones set by the scripts of the browsers.
+func (g *generator) cookies(req *har.Request) []string {
+	var cookies []string
+	for _, c := range req.Cookies {
+		if !g.setCookies[c.Name] {
+			cookies = append(cookies, fmt.Sprintf("\t\t\t\t%q: %s,\n", c.Name, g.literal(c.Value)))
+		}
+	}
+	return cookies
+}
+
+// extract returns the values of the response which can be correlated, which are the strings of the JSON
+// responses, the hidden inputs of the HTML ones, the headers of tokens and sessions, and the redirections.
+func (g *generator) extract(resp *har.Response) []*correlation {
+	var extracted []*correlation
+	add := func(value, name, expr string) {
+		if len(value) < minCorrelatedLength || strings.IndexFunc(value, unicode.IsSpace) >= 0 ||
+			strings.Contains(g.sent.String(), value) {
+			return
+		}
+		g.names++
+		name = strings.Trim(nameRegexp.ReplaceAllString(name, "_"), "_")
+		if name == "" {
+			name = "value"
+		}
+		c := &correlation{value: value, name: fmt.Sprintf("%s_%d", strings.ToLower(name), g.names), expr: expr}
+		g.correlations[value] = c
+		extracted = append(extracted, c)
+	}
+
+	for _, h := range resp.Headers {
+		name := http.CanonicalHeaderKey(h.Name)
+		switch {
+		case name == "Location":
+			if u, err := url.Parse(h.Value); err == nil && u.IsAbs() {
+				add(h.Value, "location", `res.headers["Location"]`)
+			}
+		case name != "Set-Cookie" && headerRegexp.MatchString(name):
+			add(h.Value, name, fmt.Sprintf("res.headers[%q]", name))
+		}
+	}
+	if resp.Content == nil {
+		return extracted
+	}
+	switch mimeType := strings.ToLower(resp.Content.MimeType); {
+	case strings.Contains(mimeType, "json"):
+		var v interface{}
+		if json.Unmarshal([]byte(resp.Content.Text), &v) == nil {
+			jsonStrings(v, "", "", func(path, key, value string) {
+				add(value, key, fmt.Sprintf("res.json(%q)", path))
+			})
+		}
+	case strings.Contains(mimeType, "html"):
+		for _, input := range inputRegexp.FindAllString(resp.Content.Text, -1) {
+			attributes := make(map[string]string)
+			for _, m := range attributeRegexp.FindAllStringSubmatch(input, -1) {
+				attributes[strings.ToLower(m[1])] = html.UnescapeString(m[2] + m[3])
+			}
+			name := attributes["name"]
+			if !strings.EqualFold(attributes["type"], "hidden") || name == "" || strings.ContainsAny(name, `"\`) {
+				continue
+			}
+			add(attributes["value"], name,
+				fmt.Sprintf(`res.html().find('input[name="%s"]').first().attr("value")`, name))
+		}
+	}
+	return extracted
+}
+
+// jsonStrings calls add with the path, the key and the value of the strings in a JSON value, whose
+// paths are the ones of res.json(), so the keys with the special characters of the paths are skipped.
+func jsonStrings(v interface{}, path, key string, add func(path, key, value string)) {
+	join := func(name string) string {
+		if path == "" {
+			return name
+		}
+		return path + "." + name
+	}
+	switch v := v.(type) {
+	case string:
+		add(path, key, v)
+	case []interface{}:
+		for i, item := range v {
+			jsonStrings(item, join(fmt.Sprint(i)), key, add)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			if k != "" && !strings.ContainsAny(k, `.*?|#@\`) {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			jsonStrings(v[k], join(k), k, add)
+		}
+	}
+}
+
+// literal returns a string literal for the value, which is a template literal with the variables of
+// the values of the previous responses in it, if there are any of them in the value.
+func (g *generator) literal(s string) string {
+	type match struct {
+		c       *correlation
+		value   string
+		escaped bool
+	}
+	var matches []match
+	for value, c := range g.correlations {
+		if strings.Contains(s, value) {
+			matches = append(matches, match{c: c, value: value})
+		}
+		if escaped := url.QueryEscape(value); escaped != value && strings.Contains(s, escaped) {
+			matches = append(matches, match{c: c, value: escaped, escaped: true})
+		}
+	}
+	if len(matches) == 0 {
+		return fmt.Sprintf("%q", s)
+	}
+	// the longest values first, so they are replaced instead of the ones which they contain
+	sort.Slice(matches, func(i, j int) bool {
+		if len(matches[i].value) != len(matches[j].value) {
+			return len(matches[i].value) > len(matches[j].value)
+		}
+		return matches[i].c.name < matches[j].c.name
+	})
+
+	var b strings.Builder
+	b.WriteByte('`')
+	for i := 0; i < len(s); {
+		found := false
+		for _, m := range matches {
+			if strings.HasPrefix(s[i:], m.value) {
+				m.c.used = true
+				if m.escaped {
+					fmt.Fprintf(&b, "${encodeURIComponent(%s)}", m.c.name)
+				} else {
+					fmt.Fprintf(&b, "${%s}", m.c.name)
+				}
+				i += len(m.value)
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+		switch s[i] {
+		case '`', '\\':
+			b.WriteByte('\\')
+		case '$':
+			if strings.HasPrefix(s[i:], "${") {
+				b.WriteByte('\\')
+			}
+		}
+		b.WriteByte(s[i])
+		i++
+	}
+	b.WriteByte('`')
+	return b.String()
+}
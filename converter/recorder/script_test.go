@@ -0,0 +1,172 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package recorder
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/converter/har"
+)
+
+//nolint:lll
+func TestGenerateScript(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+	h := har.HAR{Log: &har.Log{
+		Creator: &har.Creator{Name: "k6 record", Version: "0.36.0"},
+		Pages: []har.Page{
+			{ID: "page_2", Title: "https://test.k6.io/my_messages.php", StartedDateTime: at(5000)},
+			{ID: "page_1", Title: "https://test.k6.io/login.php", StartedDateTime: at(0)},
+		},
+		Entries: []*har.Entry{
+			{
+				Pageref: "page_1", StartedDateTime: at(0), Time: 100,
+				Request: &har.Request{
+					Method: "GET", URL: "https://test.k6.io/login.php",
+					Headers: []har.Header{
+						{Name: "Accept", Value: "text/html"}, {Name: "Accept-Encoding", Value: "gzip"},
+					},
+					Cookies: []har.Cookie{{Name: "consent", Value: "yes"}},
+				},
+				Response: &har.Response{
+					Status:  200,
+					Cookies: []har.Cookie{{Name: "sid", Value: "s3cr3t"}},
+					Content: &har.Content{MimeType: "text/html", Text: `<form>` +
+						`<input type="hidden" name="csrftoken" value="a1b2c3d4e5+/">` +
+						`<input type="text" name="login" value="default-login"></form>`},
+				},
+			},
+			{
+				Pageref: "page_1", StartedDateTime: at(1600), Time: 200,
+				Request: &har.Request{
+					Method: "POST", URL: "https://test.k6.io/login.php",
+					Headers: []har.Header{
+						{Name: "Content-Type", Value: "application/x-www-form-urlencoded"},
+						{Name: "Cookie", Value: "sid=s3cr3t; consent=yes"},
+					},
+					Cookies:  []har.Cookie{{Name: "sid", Value: "s3cr3t"}, {Name: "consent", Value: "yes"}},
+					PostData: &har.PostData{Text: "login=default-login&csrftoken=a1b2c3d4e5%2B%2F"},
+				},
+				Response: &har.Response{
+					Status: 302, Headers: []har.Header{{Name: "Location", Value: "https://test.k6.io/api/session?x=1"}},
+				},
+			},
+			{
+				Pageref: "page_1", StartedDateTime: at(1850), Time: 100,
+				Request: &har.Request{Method: "GET", URL: "https://test.k6.io/api/session?x=1"},
+				Response: &har.Response{
+					Status: 200,
+					Content: &har.Content{
+						MimeType: "application/json",
+						Text:     `{"user": {"token": "tok-123456789"}, "items": ["short", "$e` + "`" + `ret-ish"]}`,
+					},
+				},
+			},
+			{
+				Pageref: "page_1", StartedDateTime: at(2000), Time: 10,
+				Request: &har.Request{Method: "GET", URL: "https://cdn.example.com/app.js"},
+			},
+			{
+				Pageref: "page_2", StartedDateTime: at(5000), Time: 100,
+				Request: &har.Request{
+					Method: "GET", URL: "https://test.k6.io/my_messages.php",
+					Headers: []har.Header{{Name: "Authorization", Value: "Bearer tok-123456789"}},
+				},
+			},
+			{
+				Pageref: "page_2", StartedDateTime: at(5200), Time: 100,
+				Request: &har.Request{
+					Method: "PUT", URL: "https://test.k6.io/api/items",
+					PostData: &har.PostData{Text: `{"item": "$e` + "`" + `ret-ish", "template": "${x}"}`},
+				},
+			},
+		},
+	}}
+
+	script, err := GenerateScript(h, ScriptOptions{
+		SleepThreshold: time.Second, Correlate: true, Skip: []string{"cdn.example.com"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `import { group, sleep } from 'k6';
+import http from 'k6/http';
+
+// Recorded by k6 record 0.36.0
+
+export let options = {
+	// the redirects were recorded as separate requests
+	maxRedirects: 0,
+};
+
+export default function() {
+	let res;
+	// the values of the responses, which are sent by the next requests
+	let csrftoken_1, location_2, items_3, token_4;
+
+	group("page_1 - https://test.k6.io/login.php", function() {
+		res = http.get("https://test.k6.io/login.php", {
+			headers: {
+				"Accept": "text/html",
+			},
+			cookies: {
+				"consent": "yes",
+			},
+		});
+		csrftoken_1 = res.html().find('input[name="csrftoken"]').first().attr("value");
+		sleep(1.50);
+		res = http.request("POST", "https://test.k6.io/login.php", `+"`"+`login=default-login&csrftoken=${encodeURIComponent(csrftoken_1)}`+"`"+`, {
+			headers: {
+				"Content-Type": "application/x-www-form-urlencoded",
+			},
+			cookies: {
+				"consent": "yes",
+			},
+		});
+		location_2 = res.headers["Location"];
+		res = http.get(`+"`${location_2}`"+`);
+		items_3 = res.json("items.1");
+		token_4 = res.json("user.token");
+	});
+	sleep(3.05);
+
+	group("page_2 - https://test.k6.io/my_messages.php", function() {
+		res = http.get("https://test.k6.io/my_messages.php", {
+			headers: {
+				"Authorization": `+"`Bearer ${token_4}`"+`,
+			},
+		});
+		res = http.request("PUT", "https://test.k6.io/api/items", `+"`"+`{"item": "${items_3}", "template": "\${x}"}`+"`"+`);
+	});
+}
+`, script)
+
+	script, err = GenerateScript(h, ScriptOptions{SleepThreshold: time.Minute})
+	require.NoError(t, err)
+	assert.NotContains(t, script, "sleep(")
+	assert.NotContains(t, script, "csrftoken_1")
+	assert.Contains(t, script, `res = http.get("https://test.k6.io/api/session?x=1");`)
+	assert.Contains(t, script, `res = http.get("https://cdn.example.com/app.js");`)
+}
@@ -0,0 +1,205 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package core
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/stats"
+)
+
+// tagCardinality tracks the unique values of the tags of every metric, up to the limit. When a tag of a metric
+// has more values, it warns about it once and takes the action of the options for the samples with the new values.
+type tagCardinality struct {
+	limit  int
+	action string
+	logger logrus.FieldLogger
+
+	// values are the tracked values of the tags of the metrics, by metric and tag
+	values map[string]map[string]map[string]struct{}
+	// overLimit are the counts of the samples above the limit, by metric and tag
+	overLimit map[string]map[string]uint64
+}
+
+func newTagCardinality(limit int, action string, logger logrus.FieldLogger) *tagCardinality {
+	if action == "" {
+		action = lib.TagCardinalityWarn
+	}
+	return &tagCardinality{
+		limit:     limit,
+		action:    action,
+		logger:    logger,
+		values:    make(map[string]map[string]map[string]struct{}),
+		overLimit: make(map[string]map[string]uint64),
+	}
+}
+
+// tagsResult is the result of the check of the tags of a metric, cached for the samples with the same ones.
+type tagsResult struct {
+	tags      *stats.SampleTags
+	overLimit []string
+}
+
+type tagsKey struct {
+	metric string
+	tags   *stats.SampleTags
+}
+
+func (tc *tagCardinality) hash(value string) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(value))
+	return "hash_" + strconv.FormatUint(uint64(h.Sum32())%uint64(tc.limit), 10)
+}
+
+// check tracks the values of the tags of the metric and returns the tags of the samples, which are
+// different from the given ones if some of their values are above the limit and the action isn't warn.
+func (tc *tagCardinality) check(metric string, tags *stats.SampleTags) tagsResult {
+	result := tagsResult{tags: tags}
+	if tags.IsEmpty() {
+		return result
+	}
+	metricValues, ok := tc.values[metric]
+	if !ok {
+		metricValues = make(map[string]map[string]struct{})
+		tc.values[metric] = metricValues
+	}
+
+	tagsMap := tags.CloneTags()
+	for tag, value := range tagsMap {
+		values, ok := metricValues[tag]
+		if !ok {
+			values = make(map[string]struct{})
+			metricValues[tag] = values
+		}
+		if _, ok := values[value]; ok {
+			continue
+		}
+		if len(values) < tc.limit {
+			values[value] = struct{}{}
+			continue
+		}
+		result.overLimit = append(result.overLimit, tag)
+	}
+	if len(result.overLimit) == 0 || tc.action == lib.TagCardinalityWarn {
+		return result
+	}
+
+	for _, tag := range result.overLimit {
+		if tc.action == lib.TagCardinalityDrop {
+			delete(tagsMap, tag)
+		} else {
+			tagsMap[tag] = tc.hash(tagsMap[tag])
+		}
+	}
+	result.tags = stats.IntoSampleTags(&tagsMap)
+	return result
+}
+
+func (tc *tagCardinality) countOverLimit(metric string, overLimit []string) {
+	counts, ok := tc.overLimit[metric]
+	if !ok {
+		counts = make(map[string]uint64)
+		tc.overLimit[metric] = counts
+	}
+	for _, tag := range overLimit {
+		if counts[tag] == 0 {
+			tc.logger.Warnf("The tag '%s' of the metric '%s' has more than %d unique values, which can overload "+
+				"the outputs, consider making its values more generic, e.g. with the name tag for the URLs. "+
+				"The tag cardinality action for the new ones is '%s'", tag, metric, tc.limit, tc.action)
+		}
+		counts[tag]++
+	}
+}
+
+// process checks the samples, and returns them with their tags changed by the action if they have values above
+// the limit.
+func (tc *tagCardinality) process(containers []stats.SampleContainer) []stats.SampleContainer {
+	cache := make(map[tagsKey]tagsResult)
+	checkTags := func(metric string, tags *stats.SampleTags) tagsResult {
+		key := tagsKey{metric: metric, tags: tags}
+		result, ok := cache[key]
+		if !ok {
+			result = tc.check(metric, tags)
+			cache[key] = result
+		}
+		return result
+	}
+
+	for i, container := range containers {
+		samples := container.GetSamples()
+		var changed []stats.Sample
+		for j, sample := range samples {
+			result := checkTags(sample.Metric.Name, sample.Tags)
+			if len(result.overLimit) == 0 {
+				continue
+			}
+			tc.countOverLimit(sample.Metric.Name, result.overLimit)
+			if result.tags == sample.Tags {
+				continue
+			}
+			if changed == nil {
+				changed = make([]stats.Sample, len(samples))
+				copy(changed, samples)
+			}
+			changed[j].Tags = result.tags
+		}
+		if changed == nil {
+			continue
+		}
+
+		switch c := container.(type) {
+		case stats.Sample:
+			containers[i] = changed[0]
+		case stats.ConnectedSampleContainer:
+			// the tags of the container are usually the ones of its samples
+			tags := c.GetTags()
+			if tags == samples[0].Tags {
+				tags = changed[0].Tags
+			}
+			containers[i] = stats.ConnectedSamples{Samples: changed, Tags: tags, Time: c.GetTime()}
+		default:
+			containers[i] = stats.Samples(changed)
+		}
+	}
+	return containers
+}
+
+// offenders returns the tags of the metrics which had values above the limit, sorted by metric and tag.
+func (tc *tagCardinality) offenders() []lib.TagCardinalityOffender {
+	var result []lib.TagCardinalityOffender
+	for metric, counts := range tc.overLimit {
+		for tag, count := range counts {
+			result = append(result, lib.TagCardinalityOffender{Metric: metric, Tag: tag, OverLimitSamples: count})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Metric != result[j].Metric {
+			return result[i].Metric < result[j].Metric
+		}
+		return result[i].Tag < result[j].Tag
+	})
+	return result
+}
@@ -0,0 +1,122 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package core
+
+import (
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/stats"
+)
+
+func TestTagCardinality(t *testing.T) {
+	t.Parallel()
+
+	metric := stats.New("my_metric", stats.Counter)
+	other := stats.New("other_metric", stats.Counter)
+	urlSamples := func(from, to int) []stats.SampleContainer {
+		containers := make([]stats.SampleContainer, 0, to-from)
+		for i := from; i < to; i++ {
+			tags := stats.IntoSampleTags(&map[string]string{"url": fmt.Sprintf("/%d", i), "method": "GET"})
+			containers = append(containers, stats.Sample{Metric: metric, Tags: tags, Value: 1})
+		}
+		return containers
+	}
+	newTestTagCardinality := func(action string) (*tagCardinality, *testutils.SimpleLogrusHook) {
+		hook := &testutils.SimpleLogrusHook{HookedLevels: []logrus.Level{logrus.WarnLevel}}
+		logger := logrus.New()
+		logger.AddHook(hook)
+		logger.SetOutput(ioutil.Discard)
+		return newTagCardinality(3, action, logger), hook
+	}
+
+	t.Run("warn", func(t *testing.T) {
+		t.Parallel()
+		tc, hook := newTestTagCardinality("")
+		containers := urlSamples(0, 5)
+		result := tc.process(urlSamples(0, 5))
+		assert.Equal(t, containers, result)
+		tc.process(urlSamples(5, 6))
+
+		entries := hook.Drain()
+		require.Len(t, entries, 1)
+		assert.Contains(t, entries[0].Message, "The tag 'url' of the metric 'my_metric' has more than 3 unique values")
+		assert.Equal(t, []lib.TagCardinalityOffender{{Metric: "my_metric", Tag: "url", OverLimitSamples: 3}},
+			tc.offenders())
+	})
+	t.Run("drop", func(t *testing.T) {
+		t.Parallel()
+		tc, _ := newTestTagCardinality(lib.TagCardinalityDrop)
+		result := tc.process(urlSamples(0, 5))
+		require.Len(t, result, 5)
+		for i, container := range result {
+			sample, ok := container.(stats.Sample)
+			require.True(t, ok)
+			url, hasURL := sample.Tags.Get("url")
+			assert.Equal(t, i < 3, hasURL)
+			if hasURL {
+				assert.Equal(t, fmt.Sprintf("/%d", i), url)
+			}
+			method, _ := sample.Tags.Get("method")
+			assert.Equal(t, "GET", method)
+		}
+
+		// the values are per metric and the ones below the limit are kept
+		tags := stats.IntoSampleTags(&map[string]string{"url": "/4"})
+		result = tc.process([]stats.SampleContainer{
+			stats.Samples{{Metric: other, Tags: tags}, {Metric: metric, Tags: tags}},
+			stats.Sample{Metric: metric, Tags: stats.IntoSampleTags(&map[string]string{"url": "/1"})},
+		})
+		require.Len(t, result, 2)
+		samples := result[0].GetSamples()
+		assert.Equal(t, tags, samples[0].Tags)
+		assert.True(t, samples[1].Tags.IsEmpty())
+		assert.Equal(t, "/1", result[1].GetSamples()[0].Tags.CloneTags()["url"])
+	})
+	t.Run("hash", func(t *testing.T) {
+		t.Parallel()
+		tc, _ := newTestTagCardinality(lib.TagCardinalityHash)
+		now := time.Now()
+		tags := stats.IntoSampleTags(&map[string]string{"url": "/1000"})
+		tc.process(urlSamples(0, 3))
+		result := tc.process([]stats.SampleContainer{stats.ConnectedSamples{
+			Samples: []stats.Sample{{Metric: metric, Tags: tags, Time: now}, {Metric: other, Tags: tags, Time: now}},
+			Tags:    tags,
+			Time:    now,
+		}})
+		require.Len(t, result, 1)
+		connected, ok := result[0].(stats.ConnectedSamples)
+		require.True(t, ok)
+		url, _ := connected.Samples[0].Tags.Get("url")
+		assert.Regexp(t, `^hash_[0-2]$`, url)
+		assert.Equal(t, connected.Samples[0].Tags, connected.Tags)
+		assert.Equal(t, tags, connected.Samples[1].Tags)
+		assert.Equal(t, now, connected.Time)
+	})
+}
@@ -0,0 +1,244 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package distributed
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/output"
+	"go.k6.io/k6/stats"
+)
+
+// heartbeatInterval is how often the agents send heartbeats to the coordinator.
+const heartbeatInterval = time.Second
+
+// Agent is the client side of a distributed test run. It runs the execution
+// segment that the coordinator gave it and, as a lib.ExecutionController,
+// synchronizes the local test run with the other agents.
+type Agent struct {
+	conn      *grpc.ClientConn
+	ctx       context.Context
+	stop      chan struct{}
+	closeOnce sync.Once
+
+	// The test run that the coordinator assigned to this agent.
+	InstanceID               int
+	Archive                  []byte
+	ExecutionSegment         *lib.ExecutionSegment
+	ExecutionSegmentSequence lib.ExecutionSegmentSequence
+	AggregateMetrics         bool
+}
+
+var _ lib.ExecutionController = &Agent{}
+
+// NewAgent connects to the coordinator at the given address and registers with
+// it. It blocks until the connection is established or the context is done.
+// Until the agent is closed, it sends heartbeats to the coordinator.
+func NewAgent(ctx context.Context, address, name string) (*Agent, error) {
+	conn, err := grpc.DialContext(ctx, address, grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		return nil, err
+	}
+	resp := &RegisterResponse{}
+	if err = invoke(ctx, conn, "Register", &RegisterRequest{Name: name}, resp); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	agent := &Agent{
+		conn: conn, ctx: ctx, stop: make(chan struct{}),
+		InstanceID: resp.InstanceID, Archive: resp.Archive, AggregateMetrics: resp.AggregateMetrics,
+	}
+	if agent.ExecutionSegment, err = lib.NewExecutionSegmentFromString(resp.ExecutionSegment); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	agent.ExecutionSegmentSequence, err = lib.NewExecutionSegmentSequenceFromString(resp.ExecutionSegmentSequence)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	go agent.sendHeartbeats()
+	return agent, nil
+}
+
+func (a *Agent) sendHeartbeats() {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), heartbeatInterval)
+			_ = invoke(ctx, a.conn, "Heartbeat", &HeartbeatRequest{InstanceID: a.InstanceID}, &Empty{})
+			cancel()
+		case <-a.stop:
+			return
+		}
+	}
+}
+
+// GetOrCreateData returns the data with the given ID from the coordinator. If
+// this agent is the first one to ask for it, it creates the data with the
+// callback and sends it to the coordinator.
+func (a *Agent) GetOrCreateData(id string, callback func() ([]byte, error)) ([]byte, error) {
+	resp := &DataResponse{}
+	if err := invoke(a.ctx, a.conn, "GetData", &DataRequest{InstanceID: a.InstanceID, ID: id}, resp); err != nil {
+		return nil, err
+	}
+	if !resp.Create {
+		if resp.Error != "" {
+			return nil, errors.New(resp.Error)
+		}
+		return resp.Data, nil
+	}
+
+	data, cerr := callback()
+	req := &SetDataRequest{InstanceID: a.InstanceID, ID: id, Data: data}
+	if cerr != nil {
+		req.Error = cerr.Error()
+	}
+	if err := invoke(a.ctx, a.conn, "SetData", req, &Empty{}); err != nil {
+		return nil, err
+	}
+	return data, cerr
+}
+
+// SignalAndWait blocks until all agents have reached the given event.
+func (a *Agent) SignalAndWait(eventID string) error {
+	return invoke(a.ctx, a.conn, "Sync", &SyncRequest{InstanceID: a.InstanceID, EventID: eventID}, &Empty{})
+}
+
+// Done tells the coordinator that this agent has finished, and with what error.
+func (a *Agent) Done(runErr error) error {
+	req := &DoneRequest{InstanceID: a.InstanceID}
+	if runErr != nil {
+		req.Error = runErr.Error()
+	}
+	// the agent's context may already be cancelled, but the coordinator
+	// still has to know that this agent has finished
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return invoke(ctx, a.conn, "Done", req, &Empty{})
+}
+
+// Close stops the heartbeats and closes the connection to the coordinator.
+func (a *Agent) Close() error {
+	a.closeOnce.Do(func() { close(a.stop) })
+	return a.conn.Close()
+}
+
+// NewOutput returns an output that sends all metric samples of the agent, and
+// its VU counts from the given execution state, to the coordinator.
+func (a *Agent) NewOutput(state *lib.ExecutionState, logger logrus.FieldLogger) output.Output {
+	return &agentOutput{agent: a, state: state, logger: logger.WithField("component", "agent-output")}
+}
+
+// agentOutput sends the metric samples of an agent to the coordinator.
+type agentOutput struct {
+	output.SampleBuffer
+
+	agent   *Agent
+	state   *lib.ExecutionState
+	logger  logrus.FieldLogger
+	flusher *output.PeriodicFlusher
+
+	stopOnce     sync.Once
+	testRunStopF func(error)
+}
+
+var _ output.WithTestRunStop = &agentOutput{}
+
+func (o *agentOutput) Description() string {
+	return "coordinator"
+}
+
+func (o *agentOutput) SetTestRunStopCallback(f func(error)) {
+	o.testRunStopF = f
+}
+
+func (o *agentOutput) Start() error {
+	flusher, err := output.NewPeriodicFlusher(time.Second, o.flush)
+	if err != nil {
+		return err
+	}
+	o.flusher = flusher
+	return nil
+}
+
+func (o *agentOutput) Stop() error {
+	o.flusher.Stop()
+	return nil
+}
+
+func (o *agentOutput) flush() {
+	req := &MetricsRequest{
+		InstanceID:     o.agent.InstanceID,
+		ActiveVUs:      o.state.GetCurrentlyActiveVUsCount(),
+		InitializedVUs: o.state.GetInitializedVUsCount(),
+	}
+	var samples []stats.Sample
+	for _, sc := range o.GetBufferedSamples() {
+		samples = append(samples, sc.GetSamples()...)
+	}
+	if o.agent.AggregateMetrics {
+		req.Samples = aggregateSamples(samples)
+	} else {
+		req.Samples = make([]MetricSample, len(samples))
+		for i, s := range samples {
+			req.Samples[i] = newMetricSample(s)
+		}
+	}
+
+	resp := &MetricsResponse{}
+	// the metrics are sent even after the agent's context is cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := invoke(ctx, o.agent.conn, "SendMetrics", req, resp); err != nil {
+		o.logger.WithError(err).Error("Couldn't send the metrics to the coordinator")
+		return
+	}
+	if resp.Abort && o.testRunStopF != nil {
+		o.stopOnce.Do(func() {
+			o.testRunStopF(errors.New("the test run was aborted by the coordinator"))
+		})
+	}
+}
+
+func newMetricSample(s stats.Sample) MetricSample {
+	ms := MetricSample{
+		Metric:   s.Metric.Name,
+		Type:     s.Metric.Type,
+		Contains: s.Metric.Contains,
+		Time:     s.Time,
+		Value:    s.Value,
+	}
+	if s.Tags != nil {
+		ms.Tags = s.Tags.CloneTags()
+	}
+	return ms
+}
@@ -0,0 +1,128 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package distributed
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.k6.io/k6/stats"
+)
+
+// gaugeAggregate is what's left of the samples of a gauge with one tag set.
+type gaugeAggregate struct {
+	min, max, last MetricSample
+	lastPosition   int
+}
+
+// aggregateSamples reduces the samples of the counters, gauges and rates to a
+// few samples for each metric and tag set, from which the coordinator gets the
+// same sinks and threshold results as from all of them. The aggregated samples
+// have the time of the first sample of their metric and tag set. The samples of trends
+// and histograms are kept as they are, so their percentiles stay exact.
+func aggregateSamples(samples []stats.Sample) []MetricSample {
+	result := make([]MetricSample, 0, len(samples))
+	positions := make(map[string]int)
+	var gaugeKeys []string
+	gauges := make(map[string]*gaugeAggregate)
+
+	for position, s := range samples {
+		ms := newMetricSample(s)
+		switch s.Metric.Type {
+		case stats.Counter:
+			key := aggregationKey(ms, "")
+			if i, ok := positions[key]; ok {
+				result[i].Value += ms.Value
+				continue
+			}
+			positions[key] = len(result)
+		case stats.Rate:
+			// the trues and the falses are counted separately
+			key := aggregationKey(ms, strconv.FormatBool(ms.Value != 0))
+			if i, ok := positions[key]; ok {
+				result[i].Count++
+				continue
+			}
+			ms.Count = 1
+			positions[key] = len(result)
+		case stats.Gauge:
+			key := aggregationKey(ms, "")
+			g, ok := gauges[key]
+			if !ok {
+				gauges[key] = &gaugeAggregate{min: ms, max: ms, last: ms, lastPosition: position}
+				gaugeKeys = append(gaugeKeys, key)
+				continue
+			}
+			if ms.Value < g.min.Value {
+				g.min = ms
+			}
+			if ms.Value > g.max.Value {
+				g.max = ms
+			}
+			g.last, g.lastPosition = ms, position
+			continue
+		case stats.Trend, stats.Histogram:
+		}
+		result = append(result, ms)
+	}
+
+	// the last values of the gauges come after all minimums and maximums, in
+	// their original order, so the gauges end up with the same last value
+	for _, key := range gaugeKeys {
+		g := gauges[key]
+		if g.min.Value != g.last.Value {
+			result = append(result, g.min)
+		}
+		if g.max.Value != g.last.Value {
+			result = append(result, g.max)
+		}
+	}
+	sort.Slice(gaugeKeys, func(i, j int) bool {
+		return gauges[gaugeKeys[i]].lastPosition < gauges[gaugeKeys[j]].lastPosition
+	})
+	for _, key := range gaugeKeys {
+		result = append(result, gauges[key].last)
+	}
+	return result
+}
+
+// aggregationKey returns a key that identifies the metric and the tag set of
+// the given sample, with an optional suffix.
+func aggregationKey(ms MetricSample, suffix string) string {
+	keys := make([]string, 0, len(ms.Tags))
+	for k := range ms.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	b := strings.Builder{}
+	b.WriteString(ms.Metric)
+	for _, k := range keys {
+		b.WriteByte(0)
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(ms.Tags[k])
+	}
+	b.WriteByte(0)
+	b.WriteString(suffix)
+	return b.String()
+}
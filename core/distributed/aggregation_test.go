@@ -0,0 +1,89 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package distributed
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/stats"
+)
+
+func TestAggregateSamples(t *testing.T) {
+	t.Parallel()
+	counter := stats.New("counter", stats.Counter)
+	gauge := stats.New("gauge", stats.Gauge)
+	rate := stats.New("rate", stats.Rate)
+	trend := stats.New("trend", stats.Trend)
+	metrics := []*stats.Metric{counter, gauge, rate, trend}
+
+	now := time.Now()
+	tagsA := stats.NewSampleTags(map[string]string{"a": "1", "b": "2"})
+	tagsB := stats.NewSampleTags(map[string]string{"a": "2"})
+	var samples []stats.Sample
+	for i, v := range []float64{3, 1, 0, 5, 2, 0, 4} {
+		tags := tagsA
+		if i%3 == 0 {
+			tags = tagsB
+		}
+		for _, m := range metrics {
+			samples = append(samples, stats.Sample{
+				Metric: m, Time: now.Add(time.Duration(i) * time.Second), Tags: tags, Value: v,
+			})
+		}
+	}
+
+	aggregated := aggregateSamples(samples)
+	// a counter and a gauge with its minimum, maximum and last value for each
+	// tag set, the trues and falses of the rate and all samples of the trend
+	require.Len(t, aggregated, 2+5+3+7)
+
+	// the sinks of the metrics are the same as the ones of all samples
+	sinks := func(samples []stats.Sample) map[string]stats.Sink {
+		result := make(map[string]stats.Sink)
+		for _, m := range metrics {
+			result[m.Name] = stats.New(m.Name, m.Type).Sink
+		}
+		for _, s := range samples {
+			result[s.Metric.Name].Add(s)
+		}
+		return result
+	}
+	var expanded []stats.Sample
+	for _, ms := range aggregated {
+		m := stats.New(ms.Metric, ms.Type)
+		assert.Contains(t, []string{`{"a":"1","b":"2"}`, `{"a":"2"}`}, tagsJSON(t, ms.Tags))
+		for i := int64(0); i < ms.Count || i == 0; i++ {
+			expanded = append(expanded, stats.Sample{Metric: m, Time: ms.Time, Value: ms.Value})
+		}
+	}
+	assert.Equal(t, sinks(samples), sinks(expanded))
+}
+
+func tagsJSON(t *testing.T, tags map[string]string) string {
+	t.Helper()
+	data, err := stats.NewSampleTags(tags).MarshalJSON()
+	require.NoError(t, err)
+	return string(data)
+}
@@ -0,0 +1,572 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package distributed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/stats"
+)
+
+// The VU metrics of the agents aren't aggregated as they are, since the
+// coordinator emits them itself, based on the VU counts of all agents.
+//nolint:gochecknoglobals
+var droppedMetrics = map[string]bool{"vus": true, "vus_max": true}
+
+// FailurePolicy is what the coordinator does when an agent fails or is lost.
+type FailurePolicy string
+
+const (
+	// FailurePolicyAbort aborts the test run of all other agents.
+	FailurePolicyAbort FailurePolicy = "abort"
+
+	// FailurePolicyContinue lets the other agents continue the test run. If
+	// the test hasn't started yet, the execution segment of the failed agent
+	// is given to the next agent that registers, otherwise the test run
+	// continues without its share of the load.
+	FailurePolicyContinue FailurePolicy = "continue"
+)
+
+// CoordinatorConfig is the configuration of a Coordinator.
+type CoordinatorConfig struct {
+	// The number of agents between which the test is split.
+	Instances int
+	// An agent is considered lost if it doesn't send a heartbeat for so long.
+	AgentTimeout time.Duration
+	// What to do when an agent fails or is lost.
+	FailurePolicy FailurePolicy
+	// Whether the agents aggregate the samples of their counters, gauges
+	// and rates before sending them.
+	AggregateMetrics bool
+}
+
+// Validate checks the coordinator configuration.
+func (cc CoordinatorConfig) Validate() error {
+	if cc.AgentTimeout < 2*heartbeatInterval {
+		return fmt.Errorf("the agent timeout should be at least %s, but was %s", 2*heartbeatInterval, cc.AgentTimeout)
+	}
+	switch cc.FailurePolicy {
+	case FailurePolicyAbort, FailurePolicyContinue:
+		return nil
+	default:
+		return fmt.Errorf("invalid agent failure policy '%s', it should be '%s' or '%s'",
+			cc.FailurePolicy, FailurePolicyAbort, FailurePolicyContinue)
+	}
+}
+
+type instanceVUs struct {
+	active, initialized int64
+}
+
+// instance is an agent that registered with the coordinator.
+type instance struct {
+	name     string
+	segment  int
+	lastSeen time.Time
+	vus      instanceVUs
+	finished bool
+	lost     bool
+}
+
+// active returns whether the agent still takes part in the test run.
+func (i *instance) active() bool {
+	return !i.finished && !i.lost
+}
+
+type barrier struct {
+	arrived map[int]bool
+	done    chan struct{}
+}
+
+type sharedData struct {
+	creator int
+	done    chan struct{}
+	data    []byte
+	err     string
+}
+
+// Coordinator is the server side of a distributed test run. It gives each
+// agent that registers with it the test archive and an execution segment,
+// synchronizes the agents and collects all of their metric samples.
+type Coordinator struct {
+	config   CoordinatorConfig
+	archive  []byte
+	segments lib.ExecutionSegmentSequence
+	registry *metrics.Registry
+	logger   logrus.FieldLogger
+	server   *grpc.Server
+
+	mu           sync.Mutex
+	instances    []*instance // indexed by the instance IDs
+	freeSegments []int       // the indexes of the segments without an agent
+	started      bool        // whether all agents have started the test run
+	barriers     map[string]*barrier
+	data         map[string]*sharedData
+	state        *lib.ExecutionState
+	err          error
+	failures     []string
+
+	allRegistered chan struct{}
+	allDone       chan struct{}
+	failed        chan struct{}
+	stopped       chan struct{}
+	samples       chan stats.SampleContainer
+	aborted       uint32
+}
+
+var _ coordinatorService = &Coordinator{}
+
+// NewCoordinator returns a new Coordinator that splits the test in the given
+// archive between the configured number of agent instances.
+func NewCoordinator(
+	archive []byte, config CoordinatorConfig, registry *metrics.Registry, logger logrus.FieldLogger,
+) (*Coordinator, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	segments, err := evenSegments(config.Instances)
+	if err != nil {
+		return nil, err
+	}
+	freeSegments := make([]int, len(segments))
+	for i := range freeSegments {
+		freeSegments[i] = i
+	}
+	return &Coordinator{
+		config:        config,
+		archive:       archive,
+		segments:      segments,
+		registry:      registry,
+		logger:        logger.WithField("component", "coordinator"),
+		freeSegments:  freeSegments,
+		barriers:      make(map[string]*barrier),
+		data:          make(map[string]*sharedData),
+		allRegistered: make(chan struct{}),
+		allDone:       make(chan struct{}),
+		failed:        make(chan struct{}),
+		stopped:       make(chan struct{}),
+		samples:       make(chan stats.SampleContainer),
+	}, nil
+}
+
+// evenSegments returns a sequence of the given number of equal segments.
+func evenSegments(instances int) (lib.ExecutionSegmentSequence, error) {
+	if instances < 1 {
+		return nil, fmt.Errorf("the number of instances should be at least 1, but was %d", instances)
+	}
+	points := make([]string, 0, instances+1)
+	points = append(points, "0")
+	for i := 1; i < instances; i++ {
+		points = append(points, fmt.Sprintf("%d/%d", i, instances))
+	}
+	points = append(points, "1")
+	return lib.NewExecutionSegmentSequenceFromString(strings.Join(points, ","))
+}
+
+// Instances returns the number of agents that take part in the test run.
+func (c *Coordinator) Instances() int {
+	return len(c.segments)
+}
+
+// Serve accepts the connections of the agents on the given listener and
+// watches their heartbeats. It blocks until Stop() is called.
+func (c *Coordinator) Serve(listener net.Listener) error {
+	c.mu.Lock()
+	c.server = grpc.NewServer()
+	c.server.RegisterService(&serviceDesc, c)
+	c.mu.Unlock()
+	go c.watchHeartbeats()
+	return c.server.Serve(listener)
+}
+
+// watchHeartbeats marks the agents which haven't sent a heartbeat within the
+// agent timeout as lost.
+func (c *Coordinator) watchHeartbeats() {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			c.mu.Lock()
+			for id, inst := range c.instances {
+				if inst.active() && now.Sub(inst.lastSeen) > c.config.AgentTimeout {
+					inst.lost = true
+					c.fail(id, fmt.Sprintf("no heartbeat for more than %s", c.config.AgentTimeout))
+				}
+			}
+			c.mu.Unlock()
+		case <-c.stopped:
+			return
+		}
+	}
+}
+
+// Stop stops the gRPC server, after the in-progress calls have finished.
+func (c *Coordinator) Stop() {
+	c.mu.Lock()
+	server := c.server
+	c.mu.Unlock()
+	if server != nil {
+		close(c.stopped)
+		server.GracefulStop()
+	}
+}
+
+// Abort makes all agents abort their test runs.
+func (c *Coordinator) Abort() {
+	atomic.StoreUint32(&c.aborted, 1)
+}
+
+// Err returns the error that aborted the test run, if an agent failed and the
+// failure policy is to abort.
+func (c *Coordinator) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Failures returns the descriptions of all agent failures.
+func (c *Coordinator) Failures() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string{}, c.failures...)
+}
+
+func (c *Coordinator) setState(state *lib.ExecutionState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.state = state
+}
+
+// getBarrier returns the barrier for the given event, creating it if needed.
+// It has to be called with the lock held.
+func (c *Coordinator) getBarrier(eventID string) *barrier {
+	b, ok := c.barriers[eventID]
+	if !ok {
+		b = &barrier{arrived: make(map[int]bool), done: make(chan struct{})}
+		c.barriers[eventID] = b
+	}
+	return b
+}
+
+// eventDone returns a channel that is closed when all agents have reached the
+// event with the given ID.
+func (c *Coordinator) eventDone(eventID string) <-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.getBarrier(eventID).done
+}
+
+// wait blocks until the done channel is closed, or until the test run is
+// aborted because an agent failed, which takes precedence.
+func (c *Coordinator) wait(ctx context.Context, done <-chan struct{}) error {
+	select {
+	case <-done:
+		select {
+		case <-c.failed:
+			return c.Err()
+		default:
+			return nil
+		}
+	case <-c.failed:
+		return c.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// getInstance returns the agent with the given ID, if it still takes part in
+// the test run. It has to be called with the lock held.
+func (c *Coordinator) getInstance(id int) (*instance, error) {
+	if id < 0 || id >= len(c.instances) {
+		return nil, fmt.Errorf("there is no instance %d", id)
+	}
+	inst := c.instances[id]
+	if inst.lost {
+		return nil, fmt.Errorf("instance %d was lost and isn't a part of the test run anymore", id)
+	}
+	inst.lastSeen = time.Now()
+	return inst, nil
+}
+
+// update completes the barriers which all active agents have reached and
+// checks whether the test run is done. It has to be called with the lock held.
+func (c *Coordinator) update() {
+	if len(c.freeSegments) > 0 {
+		return // some execution segments are still waiting for an agent
+	}
+	select {
+	case <-c.allRegistered:
+	default:
+		close(c.allRegistered)
+	}
+
+	for eventID, b := range c.barriers {
+		select {
+		case <-b.done:
+			continue
+		default:
+		}
+		complete := true
+		for id, inst := range c.instances {
+			if inst.active() && !b.arrived[id] {
+				complete = false
+				break
+			}
+		}
+		if complete {
+			close(b.done)
+			if eventID == "test-start" {
+				c.started = true
+			}
+		}
+	}
+
+	for _, inst := range c.instances {
+		if inst.active() {
+			return
+		}
+	}
+	select {
+	case <-c.allDone:
+	default:
+		close(c.allDone)
+	}
+}
+
+// fail handles the failure of an agent according to the failure policy. It
+// has to be called with the lock held.
+func (c *Coordinator) fail(id int, reason string) {
+	inst := c.instances[id]
+	c.setVUs(inst, instanceVUs{})
+	failure := fmt.Sprintf("instance %d (%s) with the execution segment %s failed: %s",
+		id, inst.name, c.segments[inst.segment], reason)
+	c.failures = append(c.failures, failure)
+	logger := c.logger.WithFields(logrus.Fields{"instance": id, "name": inst.name})
+	logger.Errorf("Instance failed: %s", reason)
+
+	if c.config.FailurePolicy == FailurePolicyAbort {
+		if c.err == nil {
+			c.err = errors.New(failure)
+			close(c.failed)
+		}
+		return
+	}
+
+	if c.started {
+		logger.Warnf("The test run continues without the load of the execution segment %s",
+			c.segments[inst.segment])
+	} else {
+		logger.Warnf("The execution segment %s will be given to the next agent that registers",
+			c.segments[inst.segment])
+		c.freeSegments = append(c.freeSegments, inst.segment)
+	}
+	// the data that the agent was creating will never come
+	for dataID, d := range c.data {
+		select {
+		case <-d.done:
+		default:
+			if d.creator == id {
+				d.err = fmt.Sprintf("instance %d, which was creating the data '%s', failed", id, dataID)
+				close(d.done)
+			}
+		}
+	}
+	c.update()
+}
+
+// Register gives the next execution segment to a new agent.
+func (c *Coordinator) Register(_ context.Context, req *RegisterRequest) (*RegisterResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.freeSegments) == 0 {
+		return nil, fmt.Errorf("all %d execution segments have already been given to agents", len(c.segments))
+	}
+	segment := c.freeSegments[0]
+	c.freeSegments = c.freeSegments[1:]
+	id := len(c.instances)
+	c.instances = append(c.instances, &instance{name: req.Name, segment: segment, lastSeen: time.Now()})
+	c.logger.WithField("name", req.Name).Infof(
+		"Instance %d registered for the execution segment %s", id, c.segments[segment])
+	c.update()
+	return &RegisterResponse{
+		InstanceID:               id,
+		Archive:                  c.archive,
+		ExecutionSegment:         c.segments[segment].String(),
+		ExecutionSegmentSequence: c.segments.String(),
+		AggregateMetrics:         c.config.AggregateMetrics,
+	}, nil
+}
+
+// Heartbeat records that an agent is still alive.
+func (c *Coordinator) Heartbeat(_ context.Context, req *HeartbeatRequest) (*Empty, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := c.getInstance(req.InstanceID); err != nil {
+		return nil, err
+	}
+	return &Empty{}, nil
+}
+
+// Sync blocks until all agents have reached the requested event.
+func (c *Coordinator) Sync(ctx context.Context, req *SyncRequest) (*Empty, error) {
+	c.mu.Lock()
+	if _, err := c.getInstance(req.InstanceID); err != nil {
+		c.mu.Unlock()
+		return nil, err
+	}
+	b := c.getBarrier(req.EventID)
+	b.arrived[req.InstanceID] = true
+	c.update()
+	c.mu.Unlock()
+
+	if err := c.wait(ctx, b.done); err != nil {
+		return nil, err
+	}
+	return &Empty{}, nil
+}
+
+// GetData returns the requested data, once it's created by the first agent
+// that asked for it.
+func (c *Coordinator) GetData(ctx context.Context, req *DataRequest) (*DataResponse, error) {
+	c.mu.Lock()
+	if _, err := c.getInstance(req.InstanceID); err != nil {
+		c.mu.Unlock()
+		return nil, err
+	}
+	d, ok := c.data[req.ID]
+	if !ok {
+		c.data[req.ID] = &sharedData{creator: req.InstanceID, done: make(chan struct{})}
+		c.mu.Unlock()
+		return &DataResponse{Create: true}, nil
+	}
+	c.mu.Unlock()
+
+	if err := c.wait(ctx, d.done); err != nil {
+		return nil, err
+	}
+	return &DataResponse{Data: d.data, Error: d.err}, nil
+}
+
+// SetData stores the data that an agent created after a GetData call.
+func (c *Coordinator) SetData(_ context.Context, req *SetDataRequest) (*Empty, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := c.getInstance(req.InstanceID); err != nil {
+		return nil, err
+	}
+	d, ok := c.data[req.ID]
+	if !ok {
+		return nil, fmt.Errorf("the data '%s' wasn't requested", req.ID)
+	}
+	select {
+	case <-d.done:
+		return nil, fmt.Errorf("the data '%s' was already set", req.ID)
+	default:
+	}
+	d.data, d.err = req.Data, req.Error
+	close(d.done)
+	return &Empty{}, nil
+}
+
+// SendMetrics passes the metric samples of an agent to the coordinator. A lost
+// agent is told to abort its test run.
+func (c *Coordinator) SendMetrics(ctx context.Context, req *MetricsRequest) (*MetricsResponse, error) {
+	c.mu.Lock()
+	inst, err := c.getInstance(req.InstanceID)
+	if err != nil {
+		c.mu.Unlock()
+		return &MetricsResponse{Abort: true}, nil //nolint:nilerr
+	}
+	c.setVUs(inst, instanceVUs{active: req.ActiveVUs, initialized: req.InitializedVUs})
+	c.mu.Unlock()
+
+	samples := make(stats.Samples, 0, len(req.Samples))
+	for _, s := range req.Samples {
+		if droppedMetrics[s.Metric] {
+			continue
+		}
+		metric, err := c.registry.NewMetric(s.Metric, s.Type, s.Contains)
+		if err != nil {
+			return nil, err
+		}
+		sample := stats.Sample{
+			Metric: metric,
+			Time:   s.Time,
+			Tags:   stats.IntoSampleTags(&s.Tags),
+			Value:  s.Value,
+		}
+		for i := int64(0); i < s.Count || i == 0; i++ {
+			samples = append(samples, sample)
+		}
+	}
+	if len(samples) > 0 {
+		select {
+		case c.samples <- samples:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return &MetricsResponse{Abort: atomic.LoadUint32(&c.aborted) == 1}, nil
+}
+
+// setVUs updates the VU counts in the execution state of the coordinator with
+// the changes of the VU counts of the given agent. It has to be called with
+// the lock held.
+func (c *Coordinator) setVUs(inst *instance, vus instanceVUs) {
+	previous := inst.vus
+	inst.vus = vus
+	if c.state != nil {
+		c.state.ModCurrentlyActiveVUsCount(vus.active - previous.active)
+		c.state.ModInitializedVUsCount(vus.initialized - previous.initialized)
+	}
+}
+
+// Done records that an agent has finished its part of the test, and handles
+// its failure if it finished with an error.
+func (c *Coordinator) Done(_ context.Context, req *DoneRequest) (*Empty, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	inst, err := c.getInstance(req.InstanceID)
+	if err != nil {
+		return nil, err
+	}
+	inst.finished = true
+	if req.Error != "" {
+		c.fail(req.InstanceID, req.Error)
+	} else {
+		c.setVUs(inst, instanceVUs{})
+	}
+	c.update()
+	return &Empty{}, nil
+}
@@ -0,0 +1,291 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package distributed
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/stats"
+)
+
+func testConfig(instances int, policy FailurePolicy) CoordinatorConfig {
+	return CoordinatorConfig{Instances: instances, AgentTimeout: 2 * heartbeatInterval, FailurePolicy: policy}
+}
+
+func newTestCoordinator(t *testing.T, config CoordinatorConfig) (*Coordinator, string) {
+	t.Helper()
+	logger := testutils.NewLogger(t)
+	logger.SetLevel(logrus.DebugLevel)
+	coordinator, err := NewCoordinator([]byte("archive"), config, metrics.NewRegistry(), logger)
+	require.NoError(t, err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = coordinator.Serve(listener) }()
+	t.Cleanup(coordinator.Stop)
+	return coordinator, listener.Addr().String()
+}
+
+func newTestAgents(t *testing.T, address string, count int) []*Agent {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	agents := make([]*Agent, count)
+	for i := range agents {
+		agent, err := NewAgent(ctx, address, "agent")
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = agent.Close() })
+		agents[i] = agent
+	}
+	return agents
+}
+
+func newTestExecutionState(t *testing.T) *lib.ExecutionState {
+	t.Helper()
+	et, err := lib.NewExecutionTuple(nil, nil)
+	require.NoError(t, err)
+	return lib.NewExecutionState(lib.Options{}, et, 0, 0)
+}
+
+func TestEvenSegments(t *testing.T) {
+	t.Parallel()
+	segments, err := evenSegments(3)
+	require.NoError(t, err)
+	assert.Equal(t, "0,1/3,2/3,1", segments.String())
+
+	_, err = evenSegments(0)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "should be at least 1")
+}
+
+func TestCoordinatorRegister(t *testing.T) {
+	t.Parallel()
+	coordinator, address := newTestCoordinator(t, testConfig(2, FailurePolicyAbort))
+	agents := newTestAgents(t, address, 2)
+
+	assert.Equal(t, "0:1/2", agents[0].ExecutionSegment.String())
+	assert.Equal(t, "1/2:1", agents[1].ExecutionSegment.String())
+	assert.Equal(t, "0,1/2,1", agents[1].ExecutionSegmentSequence.String())
+	assert.Equal(t, []byte("archive"), agents[1].Archive)
+	select {
+	case <-coordinator.allRegistered:
+	default:
+		t.Fatal("the coordinator should be waiting for no more agents")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := NewAgent(ctx, address, "extra")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "all 2 execution segments have already been given to agents")
+}
+
+func TestAgentSynchronization(t *testing.T) {
+	t.Parallel()
+	_, address := newTestCoordinator(t, testConfig(3, FailurePolicyAbort))
+	agents := newTestAgents(t, address, 3)
+
+	var created, arrived int64
+	wg := sync.WaitGroup{}
+	for _, agent := range agents {
+		wg.Add(1)
+		go func(agent *Agent) {
+			defer wg.Done()
+			data, err := agent.GetOrCreateData("setup", func() ([]byte, error) {
+				atomic.AddInt64(&created, 1)
+				return []byte("data"), nil
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("data"), data)
+
+			atomic.AddInt64(&arrived, 1)
+			assert.NoError(t, agent.SignalAndWait("test-done"))
+			assert.Equal(t, int64(3), atomic.LoadInt64(&arrived))
+
+			_, err = agent.GetOrCreateData("teardown", func() ([]byte, error) {
+				return nil, errors.New("teardown error")
+			})
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), "teardown error")
+			}
+		}(agent)
+	}
+	wg.Wait()
+	assert.Equal(t, int64(1), created)
+}
+
+func TestAgentFailure(t *testing.T) {
+	t.Parallel()
+	coordinator, address := newTestCoordinator(t, testConfig(2, FailurePolicyAbort))
+	agents := newTestAgents(t, address, 2)
+
+	waitErr := make(chan error)
+	go func() { waitErr <- agents[0].SignalAndWait("test-start") }()
+	require.NoError(t, agents[1].Done(errors.New("init error")))
+
+	select {
+	case err := <-waitErr:
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "instance 1 (agent) with the execution segment 1/2:1 failed: init error")
+	case <-time.After(10 * time.Second):
+		t.Fatal("the wait wasn't released by the failed agent")
+	}
+	require.Error(t, coordinator.Err())
+}
+
+func waitForError(t *testing.T, errC <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-errC:
+		return err
+	case <-time.After(10 * time.Second):
+		t.Fatal("the wait wasn't released")
+		return nil
+	}
+}
+
+func TestAgentLost(t *testing.T) {
+	t.Parallel()
+	t.Run("Abort", func(t *testing.T) {
+		t.Parallel()
+		_, address := newTestCoordinator(t, testConfig(2, FailurePolicyAbort))
+		agents := newTestAgents(t, address, 2)
+
+		waitErr := make(chan error)
+		go func() { waitErr <- agents[0].SignalAndWait("test-start") }()
+		require.NoError(t, agents[1].Close())
+
+		err := waitForError(t, waitErr)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "instance 1 (agent) with the execution segment 1/2:1 failed: no heartbeat")
+	})
+	t.Run("Continue", func(t *testing.T) {
+		t.Parallel()
+		coordinator, address := newTestCoordinator(t, testConfig(2, FailurePolicyContinue))
+		agents := newTestAgents(t, address, 2)
+
+		// before the start, the execution segment of the lost agent is given to a new one
+		waitErr := make(chan error)
+		go func() { waitErr <- agents[0].SignalAndWait("test-start") }()
+		require.NoError(t, agents[1].Close())
+		require.Eventually(t, func() bool {
+			return len(coordinator.Failures()) == 1
+		}, 10*time.Second, 10*time.Millisecond)
+		replacement := newTestAgents(t, address, 1)[0]
+		assert.Equal(t, "1/2:1", replacement.ExecutionSegment.String())
+		require.NoError(t, replacement.SignalAndWait("test-start"))
+		require.NoError(t, waitForError(t, waitErr))
+
+		// after the start, the test run continues without it
+		go func() { waitErr <- agents[0].SignalAndWait("test-done") }()
+		require.NoError(t, replacement.Close())
+		require.NoError(t, waitForError(t, waitErr))
+		require.NoError(t, agents[0].Done(nil))
+		<-coordinator.allDone
+		assert.NoError(t, coordinator.Err())
+		assert.Len(t, coordinator.Failures(), 2)
+	})
+}
+
+func TestAgentMetrics(t *testing.T) {
+	t.Parallel()
+	coordinator, address := newTestCoordinator(t, testConfig(1, FailurePolicyAbort))
+	agent := newTestAgents(t, address, 1)[0]
+
+	registry := metrics.NewRegistry()
+	builtinMetrics := metrics.RegisterBuiltinMetrics(registry)
+	now := time.Now()
+	received := make(chan stats.SampleContainer, 1)
+	go func() { received <- <-coordinator.samples }()
+
+	out := &agentOutput{agent: agent, logger: testutils.NewLogger(t)}
+	out.state = newTestExecutionState(t)
+	stopped := make(chan error, 1)
+	out.SetTestRunStopCallback(func(err error) { stopped <- err })
+	out.AddMetricSamples([]stats.SampleContainer{stats.Samples{
+		{
+			Metric: builtinMetrics.Iterations, Time: now, Value: 1,
+			Tags: stats.NewSampleTags(map[string]string{"a": "b"}),
+		},
+		{Metric: builtinMetrics.VUs, Time: now, Value: 10},
+	}})
+	coordinator.Abort()
+	out.flush()
+
+	samples := (<-received).GetSamples()
+	require.Len(t, samples, 1)
+	assert.Equal(t, "iterations", samples[0].Metric.Name)
+	assert.Equal(t, stats.Counter, samples[0].Metric.Type)
+	assert.Equal(t, map[string]string{"a": "b"}, samples[0].Tags.CloneTags())
+	assert.Equal(t, float64(1), samples[0].Value)
+	assert.True(t, now.Equal(samples[0].Time))
+
+	select {
+	case err := <-stopped:
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "aborted by the coordinator")
+	default:
+		t.Fatal("the test run should have been stopped")
+	}
+}
+
+func TestAgentAggregatedMetrics(t *testing.T) {
+	t.Parallel()
+	config := testConfig(1, FailurePolicyAbort)
+	config.AggregateMetrics = true
+	coordinator, address := newTestCoordinator(t, config)
+	agent := newTestAgents(t, address, 1)[0]
+	require.True(t, agent.AggregateMetrics)
+
+	registry := metrics.NewRegistry()
+	builtinMetrics := metrics.RegisterBuiltinMetrics(registry)
+	received := make(chan stats.SampleContainer, 1)
+	go func() { received <- <-coordinator.samples }()
+
+	out := &agentOutput{agent: agent, logger: testutils.NewLogger(t), state: newTestExecutionState(t)}
+	now := time.Now()
+	out.AddMetricSamples([]stats.SampleContainer{stats.Samples{
+		{Metric: builtinMetrics.Checks, Time: now, Value: 1},
+		{Metric: builtinMetrics.Checks, Time: now, Value: 0},
+		{Metric: builtinMetrics.Checks, Time: now, Value: 1},
+	}})
+	out.flush()
+
+	sink := &stats.RateSink{}
+	for _, s := range (<-received).GetSamples() {
+		sink.Add(s)
+	}
+	assert.Equal(t, int64(2), sink.Trues)
+	assert.Equal(t, int64(3), sink.Total)
+}
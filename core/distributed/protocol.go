@@ -0,0 +1,225 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package distributed implements the running of a single test by multiple k6
+// instances. A coordinator splits the test into execution segments and sends
+// them, together with the test archive, to the agents that register with it.
+// It then synchronizes the phases of the test run between the agents and
+// aggregates all of their metrics, so that it can evaluate the thresholds and
+// produce the end-of-test summary centrally.
+package distributed
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+
+	"go.k6.io/k6/stats"
+)
+
+// The agents and the coordinator talk gRPC, but with JSON-encoded messages, so
+// no code generation from protobuf definitions is needed.
+const (
+	serviceName = "k6.distributed.Coordinator"
+	codecName   = "json"
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) Name() string {
+	return codecName
+}
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+// RegisterRequest is sent by an agent when it connects to the coordinator.
+type RegisterRequest struct {
+	Name string `json:"name"`
+}
+
+// RegisterResponse gives an agent everything it needs to run its part of the test.
+type RegisterResponse struct {
+	InstanceID               int    `json:"instanceID"`
+	Archive                  []byte `json:"archive"`
+	ExecutionSegment         string `json:"executionSegment"`
+	ExecutionSegmentSequence string `json:"executionSegmentSequence"`
+	AggregateMetrics         bool   `json:"aggregateMetrics"`
+}
+
+// HeartbeatRequest tells the coordinator that an agent is still alive.
+type HeartbeatRequest struct {
+	InstanceID int `json:"instanceID"`
+}
+
+// SyncRequest signals that an agent has reached the given event.
+type SyncRequest struct {
+	InstanceID int    `json:"instanceID"`
+	EventID    string `json:"eventID"`
+}
+
+// DataRequest asks for the data with the given ID.
+type DataRequest struct {
+	InstanceID int    `json:"instanceID"`
+	ID         string `json:"id"`
+}
+
+// DataResponse either contains the requested data, or tells the agent that it
+// was chosen to create the data and has to send it back with SetData.
+type DataResponse struct {
+	Create bool   `json:"create"`
+	Data   []byte `json:"data"`
+	Error  string `json:"error"`
+}
+
+// SetDataRequest sends the data with the given ID, or the error that happened
+// while creating it, to the coordinator.
+type SetDataRequest struct {
+	InstanceID int    `json:"instanceID"`
+	ID         string `json:"id"`
+	Data       []byte `json:"data"`
+	Error      string `json:"error"`
+}
+
+// MetricSample is a metric sample emitted by an agent. With the aggregation of
+// the metrics, it can stand for the given count of identical samples.
+type MetricSample struct {
+	Metric   string            `json:"metric"`
+	Type     stats.MetricType  `json:"type"`
+	Contains stats.ValueType   `json:"contains"`
+	Time     time.Time         `json:"time"`
+	Tags     map[string]string `json:"tags"`
+	Value    float64           `json:"value"`
+	Count    int64             `json:"count,omitempty"`
+}
+
+// MetricsRequest sends a batch of metric samples and the current VU counts of
+// an agent to the coordinator.
+type MetricsRequest struct {
+	InstanceID     int            `json:"instanceID"`
+	Samples        []MetricSample `json:"samples"`
+	ActiveVUs      int64          `json:"activeVUs"`
+	InitializedVUs int64          `json:"initializedVUs"`
+}
+
+// MetricsResponse tells an agent whether the test run should be aborted,
+// for example because a threshold with abortOnFail has failed.
+type MetricsResponse struct {
+	Abort bool `json:"abort"`
+}
+
+// DoneRequest is sent by an agent when it has finished its part of the test.
+type DoneRequest struct {
+	InstanceID int    `json:"instanceID"`
+	Error      string `json:"error"`
+}
+
+// Empty is the response of the calls that don't return anything.
+type Empty struct{}
+
+// coordinatorService is implemented by the Coordinator and served over gRPC.
+type coordinatorService interface {
+	Register(context.Context, *RegisterRequest) (*RegisterResponse, error)
+	Heartbeat(context.Context, *HeartbeatRequest) (*Empty, error)
+	Sync(context.Context, *SyncRequest) (*Empty, error)
+	GetData(context.Context, *DataRequest) (*DataResponse, error)
+	SetData(context.Context, *SetDataRequest) (*Empty, error)
+	SendMetrics(context.Context, *MetricsRequest) (*MetricsResponse, error)
+	Done(context.Context, *DoneRequest) (*Empty, error)
+}
+
+func methodDesc(
+	name string, newReq func() interface{},
+	call func(srv coordinatorService, ctx context.Context, req interface{}) (interface{}, error),
+) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(
+			srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor,
+		) (interface{}, error) {
+			req := newReq()
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				return call(srv.(coordinatorService), ctx, req)
+			}
+			if interceptor == nil {
+				return handler(ctx, req)
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + serviceName + "/" + name}
+			return interceptor(ctx, req, info, handler)
+		},
+	}
+}
+
+//nolint:forcetypeassert,gochecknoglobals
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*coordinatorService)(nil),
+	Methods: []grpc.MethodDesc{
+		methodDesc("Register", func() interface{} { return &RegisterRequest{} },
+			func(srv coordinatorService, ctx context.Context, req interface{}) (interface{}, error) {
+				return srv.Register(ctx, req.(*RegisterRequest))
+			}),
+		methodDesc("Heartbeat", func() interface{} { return &HeartbeatRequest{} },
+			func(srv coordinatorService, ctx context.Context, req interface{}) (interface{}, error) {
+				return srv.Heartbeat(ctx, req.(*HeartbeatRequest))
+			}),
+		methodDesc("Sync", func() interface{} { return &SyncRequest{} },
+			func(srv coordinatorService, ctx context.Context, req interface{}) (interface{}, error) {
+				return srv.Sync(ctx, req.(*SyncRequest))
+			}),
+		methodDesc("GetData", func() interface{} { return &DataRequest{} },
+			func(srv coordinatorService, ctx context.Context, req interface{}) (interface{}, error) {
+				return srv.GetData(ctx, req.(*DataRequest))
+			}),
+		methodDesc("SetData", func() interface{} { return &SetDataRequest{} },
+			func(srv coordinatorService, ctx context.Context, req interface{}) (interface{}, error) {
+				return srv.SetData(ctx, req.(*SetDataRequest))
+			}),
+		methodDesc("SendMetrics", func() interface{} { return &MetricsRequest{} },
+			func(srv coordinatorService, ctx context.Context, req interface{}) (interface{}, error) {
+				return srv.SendMetrics(ctx, req.(*MetricsRequest))
+			}),
+		methodDesc("Done", func() interface{} { return &DoneRequest{} },
+			func(srv coordinatorService, ctx context.Context, req interface{}) (interface{}, error) {
+				return srv.Done(ctx, req.(*DoneRequest))
+			}),
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+// invoke calls a method of the coordinator service on the given connection.
+func invoke(ctx context.Context, conn *grpc.ClientConn, method string, req, resp interface{}) error {
+	return conn.Invoke(ctx, "/"+serviceName+"/"+method, req, resp, grpc.CallContentSubtype(codecName))
+}
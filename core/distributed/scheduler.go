@@ -0,0 +1,157 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package distributed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/stats"
+	"go.k6.io/k6/ui/pb"
+)
+
+// ExecutionScheduler is the lib.ExecutionScheduler of the coordinator. It
+// doesn't run any VUs itself, it waits for the agents to run the test and
+// funnels their metric samples to the Engine, so that the thresholds and the
+// end-of-test summary cover the whole distributed test run.
+type ExecutionScheduler struct {
+	coordinator   *Coordinator
+	runner        lib.Runner
+	logger        logrus.FieldLogger
+	executionPlan []lib.ExecutionStep
+	state         *lib.ExecutionState
+	initProgress  *pb.ProgressBar
+}
+
+var _ lib.ExecutionScheduler = &ExecutionScheduler{}
+
+// NewExecutionScheduler returns a new ExecutionScheduler for the test of the
+// given runner, which is executed by the agents of the given coordinator.
+func NewExecutionScheduler(
+	coordinator *Coordinator, runner lib.Runner, logger logrus.FieldLogger,
+) (*ExecutionScheduler, error) {
+	options := runner.GetOptions()
+	et, err := lib.NewExecutionTuple(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	executionPlan := options.Scenarios.GetFullExecutionRequirements(et)
+	maxPlannedVUs := lib.GetMaxPlannedVUs(executionPlan)
+	maxPossibleVUs := lib.GetMaxPossibleVUs(executionPlan)
+	state := lib.NewExecutionState(options, et, maxPlannedVUs, maxPossibleVUs)
+	coordinator.setState(state)
+
+	return &ExecutionScheduler{
+		coordinator:   coordinator,
+		runner:        runner,
+		logger:        logger.WithField("component", "distributed-execution-scheduler"),
+		executionPlan: executionPlan,
+		state:         state,
+		initProgress:  pb.New(pb.WithConstLeft("Init")),
+	}, nil
+}
+
+// GetRunner returns the wrapped lib.Runner instance.
+func (e *ExecutionScheduler) GetRunner() lib.Runner {
+	return e.runner
+}
+
+// GetState returns the execution state, with the VU counts of all agents.
+func (e *ExecutionScheduler) GetState() *lib.ExecutionState {
+	return e.state
+}
+
+// GetExecutors returns nil, since the executors are run by the agents.
+func (e *ExecutionScheduler) GetExecutors() []lib.Executor {
+	return nil
+}
+
+// GetExecutionPlan returns the execution plan of the whole test.
+func (e *ExecutionScheduler) GetExecutionPlan() []lib.ExecutionStep {
+	return e.executionPlan
+}
+
+// GetInitProgressBar returns the progress bar of the coordinator.
+func (e *ExecutionScheduler) GetInitProgressBar() *pb.ProgressBar {
+	return e.initProgress
+}
+
+// Init waits for all agents to register with the coordinator.
+func (e *ExecutionScheduler) Init(ctx context.Context, _ chan<- stats.SampleContainer) error {
+	e.state.SetExecutionStatus(lib.ExecutionStatusInitVUs)
+	e.initProgress.Modify(pb.WithConstProgress(0, fmt.Sprintf(
+		"waiting for %d instances", e.coordinator.Instances())))
+	e.logger.Debugf("Waiting for %d instances to register...", e.coordinator.Instances())
+	select {
+	case <-e.coordinator.allRegistered:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	e.state.SetExecutionStatus(lib.ExecutionStatusInitDone)
+	return nil
+}
+
+// Run funnels the metric samples of the agents through the supplied out
+// channel, until all agents have finished. If the runCtx is cancelled, the
+// agents are told to abort the test run.
+func (e *ExecutionScheduler) Run(
+	globalCtx, runCtx context.Context, samplesOut chan<- stats.SampleContainer, _ *metrics.BuiltinMetrics,
+) error {
+	defer e.state.MarkEnded()
+	e.initProgress.Modify(pb.WithConstProgress(1, "running"))
+
+	started := e.coordinator.eventDone("test-start")
+	runDone := runCtx.Done()
+	for {
+		select {
+		case <-started:
+			e.state.MarkStarted()
+			e.state.SetExecutionStatus(lib.ExecutionStatusRunning)
+			started = nil
+		case samples := <-e.coordinator.samples:
+			samplesOut <- samples
+		case <-runDone:
+			e.logger.Debug("Test run aborted, stopping the instances...")
+			e.coordinator.Abort()
+			runDone = nil
+		case <-e.coordinator.allDone:
+			err := e.coordinator.Err()
+			if failures := e.coordinator.Failures(); err == nil && len(failures) > 0 {
+				e.logger.Warnf("%d instances failed during the test run: %s",
+					len(failures), strings.Join(failures, "; "))
+			}
+			return err
+		case <-globalCtx.Done():
+			return errors.New("the test run was interrupted before all instances finished")
+		}
+	}
+}
+
+// SetPaused isn't supported in distributed test runs.
+func (e *ExecutionScheduler) SetPaused(bool) error {
+	return errors.New("distributed test runs can't be paused")
+}
@@ -23,6 +23,7 @@ package core
 import (
 	"context"
 	"errors"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -31,8 +32,10 @@ import (
 	"gopkg.in/guregu/null.v3"
 
 	"go.k6.io/k6/errext"
+	"go.k6.io/k6/errext/exitcodes"
 	"go.k6.io/k6/js/common"
 	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/executor"
 	"go.k6.io/k6/lib/metrics"
 	"go.k6.io/k6/output"
 	"go.k6.io/k6/stats"
@@ -71,12 +74,24 @@ type Engine struct {
 	builtinMetrics *metrics.BuiltinMetrics
 	Samples        chan stats.SampleContainer
 
+	// LiveMetrics, when set, are fed with all the samples, so the scripts can query them during the test
+	LiveMetrics *metrics.LiveMetrics
+
 	// Assigned to metrics upon first received sample.
 	thresholds map[string]stats.Thresholds
 	submetrics map[string][]*stats.Submetric
 
 	// Are thresholds tainted?
 	thresholdsTainted bool
+
+	// the dropped samples of the outputs with a limited buffer, at their last emission
+	emittedDroppedSamples map[int]int64
+
+	// tagCardinality is nil when the TagCardinalityLimit option isn't set
+	tagCardinality *tagCardinality
+
+	// thresholdsWebhook is nil when the ThresholdsWebhook option isn't set
+	thresholdsWebhook *thresholdsWebhook
 }
 
 // NewEngine instantiates a new Engine, without doing any heavy initialization.
@@ -100,9 +115,27 @@ func NewEngine(
 		stopChan:       make(chan struct{}),
 		logger:         logger.WithField("component", "engine"),
 		builtinMetrics: builtinMetrics,
+
+		emittedDroppedSamples: make(map[int]int64),
+	}
+
+	if opts.TagCardinalityLimit.Int64 > 0 {
+		e.tagCardinality = newTagCardinality(
+			int(opts.TagCardinalityLimit.Int64), opts.TagCardinalityAction.String, e.logger)
+	}
+	if opts.ThresholdsWebhook.String != "" {
+		e.thresholdsWebhook = newThresholdsWebhook(opts.ThresholdsWebhook.String, e.logger)
 	}
 
 	e.thresholds = opts.Thresholds
+	if opts.ThresholdsPerScenario.Bool {
+		e.thresholds = thresholdsPerScenario(opts.Thresholds, opts.Scenarios)
+	}
+	rampUp := executor.GetRampUpDuration(opts.Scenarios)
+	for _, thresholds := range e.thresholds {
+		thresholds.SetRampUpDuration(rampUp)
+		thresholds.SetTrendRelativeError(opts.TrendRelativeError.Float64)
+	}
 	e.submetrics = make(map[string][]*stats.Submetric)
 	for name := range e.thresholds {
 		if !strings.Contains(name, "{") {
@@ -113,6 +146,15 @@ func NewEngine(
 		e.submetrics[parent] = append(e.submetrics[parent], sm)
 	}
 
+	// the submetrics to which the composite thresholds of other metrics refer are tracked too
+	for _, name := range e.thresholdsReferences() {
+		if _, ok := e.thresholds[name]; ok || !strings.Contains(name, "{") {
+			continue
+		}
+		parent, sm := stats.NewSubmetric(name)
+		e.submetrics[parent] = append(e.submetrics[parent], sm)
+	}
+
 	// TODO: refactor this out of here when https://github.com/k6io/k6/issues/1832 lands and
 	// there is a better way to enable a metric with tag
 	if opts.SystemTags.Has(stats.TagExpectedResponse) {
@@ -174,6 +216,12 @@ func (e *Engine) stopOutputs(upToID int) {
 		if err := e.outputs[i].Stop(); err != nil {
 			e.logger.WithError(err).Errorf("Stopping output %d failed", i)
 		}
+		if limited, ok := e.outputs[i].(output.WithBufferLimit); ok {
+			if status := limited.BufferStatus(); status.Dropped > 0 {
+				e.logger.Warnf("The %s output dropped %d samples because its buffer was full",
+					status.Limit.Output, status.Dropped)
+			}
+		}
 	}
 }
 
@@ -313,7 +361,13 @@ func (e *Engine) startBackgroundProcesses(
 		}()
 	}
 
-	return processes.Wait
+	return func() {
+		processes.Wait()
+		// the last failed thresholds are still posted after the test run
+		if e.thresholdsWebhook != nil {
+			e.thresholdsWebhook.wait()
+		}
+	}
 }
 
 func (e *Engine) processMetrics(globalCtx context.Context, processMetricsAfterRun chan struct{}) {
@@ -389,6 +443,49 @@ func (e *Engine) IsTainted() bool {
 	return e.thresholdsTainted
 }
 
+// ThresholdsExitCode returns the exit code of the test run for its failed thresholds, which is the one
+// of the failed threshold with the highest severity, or exitcodes.ThresholdsHaveFailed if it has none.
+func (e *Engine) ThresholdsExitCode() errext.ExitCode {
+	e.MetricsLock.Lock()
+	defer e.MetricsLock.Unlock()
+
+	var failed []*stats.Threshold
+	for _, name := range e.sortedMetricNames() {
+		failed = append(failed, e.Metrics[name].Thresholds.Thresholds...)
+	}
+	if t := stats.MostSevereFailedThreshold(failed); t != nil && t.ExitCode != 0 {
+		return t.ExitCode
+	}
+	return exitcodes.ThresholdsHaveFailed
+}
+
+// FailedWarnThresholds returns the failed thresholds with the warn severity, as "metric: threshold",
+// which don't fail the test run.
+func (e *Engine) FailedWarnThresholds() []string {
+	e.MetricsLock.Lock()
+	defer e.MetricsLock.Unlock()
+
+	var failed []string
+	for _, name := range e.sortedMetricNames() {
+		for _, t := range e.Metrics[name].Thresholds.Thresholds {
+			if t.LastFailed && t.Severity == stats.ThresholdSeverityWarn {
+				failed = append(failed, name+": "+t.Source)
+			}
+		}
+	}
+	return failed
+}
+
+// sortedMetricNames returns the names of the metrics in order, e.MetricsLock has to be held.
+func (e *Engine) sortedMetricNames() []string {
+	names := make([]string, 0, len(e.Metrics))
+	for name := range e.Metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // Stop closes a signal channel, forcing a running Engine to return
 func (e *Engine) Stop() {
 	e.stopOnce.Do(func() {
@@ -423,7 +520,7 @@ func (e *Engine) emitMetrics() {
 
 	executionState := e.ExecutionScheduler.GetState()
 	// TODO: optimize and move this, it shouldn't call processSamples() directly
-	e.processSamples([]stats.SampleContainer{stats.ConnectedSamples{
+	e.processSamples(append([]stats.SampleContainer{stats.ConnectedSamples{
 		Samples: []stats.Sample{
 			{
 				Time:   t,
@@ -439,7 +536,37 @@ func (e *Engine) emitMetrics() {
 		},
 		Tags: e.Options.RunTags,
 		Time: t,
-	}})
+	}}, e.outputBufferSamples(t)...))
+}
+
+// outputBufferSamples returns the samples of the buffered and dropped samples of the outputs with a limited buffer.
+func (e *Engine) outputBufferSamples(t time.Time) []stats.SampleContainer {
+	var containers []stats.SampleContainer
+	for i, out := range e.outputs {
+		limited, ok := out.(output.WithBufferLimit)
+		if !ok {
+			continue
+		}
+		status := limited.BufferStatus()
+		if status.Limit.MaxSamples <= 0 {
+			continue
+		}
+
+		tags := e.Options.RunTags.CloneTags()
+		tags["output"] = status.Limit.Output
+		sampleTags := stats.IntoSampleTags(&tags)
+		dropped := status.Dropped - e.emittedDroppedSamples[i]
+		e.emittedDroppedSamples[i] = status.Dropped
+		containers = append(containers, stats.ConnectedSamples{
+			Samples: []stats.Sample{
+				{Time: t, Metric: e.builtinMetrics.OutputBufferedSamples, Value: float64(status.Buffered), Tags: sampleTags},
+				{Time: t, Metric: e.builtinMetrics.OutputDroppedSamples, Value: float64(dropped), Tags: sampleTags},
+			},
+			Tags: sampleTags,
+			Time: t,
+		})
+	}
+	return containers
 }
 
 func (e *Engine) processThresholds() (shouldAbort bool) {
@@ -456,11 +583,25 @@ func (e *Engine) processThresholds() (shouldAbort bool) {
 		m.Tainted = null.BoolFrom(false)
 
 		e.logger.WithField("m", m.Name).Debug("running thresholds")
-		succ, err := m.Thresholds.Run(m.Sink, t)
+		var lastFailed []bool
+		if e.thresholdsWebhook != nil {
+			lastFailed = make([]bool, len(m.Thresholds.Thresholds))
+			for i, threshold := range m.Thresholds.Thresholds {
+				lastFailed[i] = threshold.LastFailed
+			}
+		}
+		succ, err := m.Thresholds.RunWithMetrics(m.Sink, t, e.Metrics)
 		if err != nil {
 			e.logger.WithField("m", m.Name).WithError(err).Error("Threshold error")
 			continue
 		}
+		if e.thresholdsWebhook != nil {
+			for i, threshold := range m.Thresholds.Thresholds {
+				if threshold.LastFailed && !lastFailed[i] {
+					e.thresholdsWebhook.notify(newThresholdBreach(m.Name, threshold, t))
+				}
+			}
+		}
 		if !succ {
 			e.logger.WithField("m", m.Name).Debug("Thresholds failed")
 			m.Tainted = null.BoolFrom(true)
@@ -474,7 +615,88 @@ func (e *Engine) processThresholds() (shouldAbort bool) {
 	return shouldAbort
 }
 
+// SetBaseline sets the baseline of a previous test run to which the thresholds can compare the values
+// of the metrics. It has to be called before the engine is initialized.
+func (e *Engine) SetBaseline(baseline lib.Baseline) {
+	thresholds := make(map[string]stats.Thresholds, len(e.thresholds))
+	for name, ths := range e.thresholds {
+		ths.SetBaseline(name, baseline)
+		thresholds[name] = ths
+	}
+	e.thresholds = thresholds
+}
+
+// thresholdsPerScenario returns copies of the thresholds for the submetrics of every scenario, with its
+// scenario tag. The thresholds which already select a scenario are kept as they are.
+func thresholdsPerScenario(
+	thresholds map[string]stats.Thresholds, scenarios lib.ScenarioConfigs,
+) map[string]stats.Thresholds {
+	result := make(map[string]stats.Thresholds, len(thresholds)*len(scenarios))
+	for name, ths := range thresholds {
+		_, sm := stats.NewSubmetric(name)
+		if _, ok := sm.Tags.Get(stats.TagScenario.String()); ok {
+			result[name] = ths
+			continue
+		}
+		for scenario := range scenarios {
+			tag := stats.TagScenario.String() + ":" + scenario
+			scoped := sm.Name + "{" + tag + "}"
+			if strings.TrimSpace(sm.Suffix) != "" {
+				scoped = sm.Parent + "{" + sm.Suffix + "," + tag + "}"
+			} else if sm.Parent != "" {
+				scoped = sm.Parent + "{" + tag + "}"
+			}
+			result[scoped] = ths.Copy()
+		}
+	}
+	return result
+}
+
+// thresholdsReferences returns the sorted names of the metrics to which the thresholds refer.
+func (e *Engine) thresholdsReferences() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, thresholds := range e.thresholds {
+		for _, name := range thresholds.References() {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TagCardinalityOffenders returns the tags of the metrics with more unique values than the
+// TagCardinalityLimit option.
+func (e *Engine) TagCardinalityOffenders() []lib.TagCardinalityOffender {
+	if e.tagCardinality == nil {
+		return nil
+	}
+	e.MetricsLock.Lock()
+	defer e.MetricsLock.Unlock()
+	return e.tagCardinality.offenders()
+}
+
+// newMetric creates a metric like the one of a sample, whose sink, for the trends, has the relative error
+// of the options, and for the histograms, the buckets of the metric.
+func (e *Engine) newMetric(name string, sampleMetric *stats.Metric) *stats.Metric {
+	m := stats.New(name, sampleMetric.Type, sampleMetric.Contains)
+	m.Unit = sampleMetric.Unit
+	m.Description, m.ID = sampleMetric.Description, sampleMetric.ID
+	switch m.Type {
+	case stats.Trend:
+		m.Sink = stats.NewTrendSink(e.Options.TrendRelativeError.Float64)
+	case stats.Histogram:
+		m.Buckets = sampleMetric.Buckets
+		m.Sink = stats.NewHistogramSink(m.Buckets)
+	}
+	return m
+}
+
 func (e *Engine) processSamplesForMetrics(sampleContainers []stats.SampleContainer) {
+	elapsed := e.executionState.GetCurrentTestRunDuration()
 	for _, sampleContainer := range sampleContainers {
 		samples := sampleContainer.GetSamples()
 
@@ -483,27 +705,32 @@ func (e *Engine) processSamplesForMetrics(sampleContainers []stats.SampleContain
 		}
 
 		for _, sample := range samples {
+			if _, isWarmup := sample.Tags.Get(lib.WarmupTagName); isWarmup {
+				continue // the warm-up of scenarios is excluded from the thresholds and summary
+			}
 			m, ok := e.Metrics[sample.Metric.Name]
 			if !ok {
-				m = stats.New(sample.Metric.Name, sample.Metric.Type, sample.Metric.Contains)
+				m = e.newMetric(sample.Metric.Name, sample.Metric)
 				m.Thresholds = e.thresholds[m.Name]
 				m.Submetrics = e.submetrics[m.Name]
 				e.Metrics[m.Name] = m
 			}
 			m.Sink.Add(sample)
+			m.Thresholds.AddSample(sample, elapsed)
 
 			for _, sm := range m.Submetrics {
-				if !sample.Tags.Contains(sm.Tags) {
+				if !sm.Matches(sample.Tags) {
 					continue
 				}
 
 				if sm.Metric == nil {
-					sm.Metric = stats.New(sm.Name, sample.Metric.Type, sample.Metric.Contains)
+					sm.Metric = e.newMetric(sm.Name, sample.Metric)
 					sm.Metric.Sub = *sm
 					sm.Metric.Thresholds = e.thresholds[sm.Name]
 					e.Metrics[sm.Name] = sm.Metric
 				}
 				sm.Metric.Sink.Add(sample)
+				sm.Metric.Thresholds.AddSample(sample, elapsed)
 			}
 		}
 	}
@@ -518,10 +745,17 @@ func (e *Engine) processSamples(sampleContainers []stats.SampleContainer) {
 	e.MetricsLock.Lock()
 	defer e.MetricsLock.Unlock()
 
+	if e.tagCardinality != nil {
+		sampleContainers = e.tagCardinality.process(sampleContainers)
+	}
+
 	// TODO: run this and the below code in goroutines?
 	if !(e.runtimeOptions.NoSummary.Bool && e.runtimeOptions.NoThresholds.Bool) {
 		e.processSamplesForMetrics(sampleContainers)
 	}
+	if e.LiveMetrics != nil {
+		e.LiveMetrics.Add(sampleContainers)
+	}
 
 	for _, out := range e.outputs {
 		out.AddMetricSamples(sampleContainers)
@@ -22,6 +22,7 @@ package core
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/url"
 	"runtime"
@@ -286,6 +287,146 @@ func TestEngine_processSamples(t *testing.T) {
 		assert.IsType(t, &stats.GaugeSink{}, e.Metrics["my_metric"].Sink)
 		assert.IsType(t, &stats.GaugeSink{}, e.Metrics["my_metric{a:1}"].Sink)
 	})
+	t.Run("submetric with a regular expression", func(t *testing.T) {
+		t.Parallel()
+		ths := stats.NewThresholds([]string{`count<2`})
+		require.NoError(t, ths.Parse())
+
+		name := `my_counter{name=~"/api/v1/.*"}`
+		e, _, wait := newTestEngine(t, nil, nil, nil, lib.Options{
+			Thresholds: map[string]stats.Thresholds{name: ths},
+		})
+		defer wait()
+
+		counter := stats.New("my_counter", stats.Counter)
+		for _, v := range []string{"/api/v1/users", "/api/v2/users", "/api/v1/orders"} {
+			e.processSamples([]stats.SampleContainer{stats.Sample{
+				Metric: counter, Value: 1, Tags: stats.IntoSampleTags(&map[string]string{"name": v}),
+			}})
+		}
+
+		sink, ok := e.Metrics[name].Sink.(*stats.CounterSink)
+		require.True(t, ok)
+		assert.Equal(t, 2.0, sink.Value)
+		assert.False(t, e.processThresholds())
+		assert.True(t, e.Metrics[name].Tainted.Bool)
+	})
+	t.Run("trend relative error", func(t *testing.T) {
+		t.Parallel()
+		e, _, wait := newTestEngine(t, nil, nil, nil, lib.Options{TrendRelativeError: null.FloatFrom(0.01)})
+		defer wait()
+
+		trend := stats.New("my_trend", stats.Trend)
+		for i := 1; i <= 100; i++ {
+			e.processSamples([]stats.SampleContainer{stats.Sample{Metric: trend, Value: float64(i)}})
+		}
+
+		sink, ok := e.Metrics["my_trend"].Sink.(*stats.TrendSink)
+		require.True(t, ok)
+		assert.Nil(t, sink.Values)
+		assert.InDelta(t, 95.05, sink.P(0.95), 95.05*0.01)
+	})
+	t.Run("histogram", func(t *testing.T) {
+		t.Parallel()
+		e, _, wait := newTestEngine(t, nil, nil, nil, lib.Options{})
+		defer wait()
+
+		histogram := stats.New("my_histogram", stats.Histogram)
+		histogram.Buckets = []float64{1, 10}
+		e.processSamples([]stats.SampleContainer{stats.Sample{Metric: histogram, Value: 5}})
+
+		sink, ok := e.Metrics["my_histogram"].Sink.(*stats.HistogramSink)
+		require.True(t, ok)
+		assert.Equal(t, []float64{1, 10}, sink.Bounds)
+		assert.Equal(t, []uint64{0, 1, 0}, sink.Counts)
+	})
+	t.Run("tag cardinality", func(t *testing.T) {
+		t.Parallel()
+		e, _, wait := newTestEngine(t, nil, nil, nil, lib.Options{
+			TagCardinalityLimit:  null.IntFrom(1),
+			TagCardinalityAction: null.StringFrom(lib.TagCardinalityDrop),
+		})
+		defer wait()
+
+		for _, v := range []string{"1", "2"} {
+			e.processSamples([]stats.SampleContainer{
+				stats.Sample{Metric: metric, Value: 1, Tags: stats.IntoSampleTags(&map[string]string{"a": v})},
+			})
+		}
+
+		assert.Equal(t, []lib.TagCardinalityOffender{{Metric: "my_metric", Tag: "a", OverLimitSamples: 1}},
+			e.TagCardinalityOffenders())
+	})
+	t.Run("live metrics", func(t *testing.T) {
+		t.Parallel()
+		e, _, wait := newTestEngine(t, nil, nil, nil, lib.Options{})
+		defer wait()
+		e.LiveMetrics = metrics.NewLiveMetrics()
+
+		e.processSamples([]stats.SampleContainer{stats.Sample{Metric: metric, Time: time.Now(), Value: 1.25}})
+
+		values, ok := e.LiveMetrics.Query("my_metric", time.Minute)
+		require.True(t, ok)
+		assert.Equal(t, 1.25, values["value"])
+	})
+	t.Run("warmup", func(t *testing.T) {
+		t.Parallel()
+		counter := stats.New("my_counter", stats.Counter)
+		mockOutput := mockoutput.New()
+		e, _, wait := newTestEngine(t, nil, nil, []output.Output{mockOutput}, lib.Options{})
+		defer wait()
+
+		warmupTags := stats.IntoSampleTags(&map[string]string{lib.WarmupTagName: "true"})
+		e.processSamples([]stats.SampleContainer{
+			stats.Sample{Metric: counter, Time: time.Now(), Value: 10, Tags: warmupTags},
+			stats.Sample{Metric: counter, Time: time.Now(), Value: 1},
+		})
+
+		assert.Equal(t, 1.0, e.Metrics["my_counter"].Sink.(*stats.CounterSink).Value)
+		assert.Len(t, mockOutput.Samples, 2)
+	})
+}
+
+type limitedOutput struct {
+	output.SampleBuffer
+}
+
+func (o *limitedOutput) Description() string { return "limited" }
+func (o *limitedOutput) Start() error        { return nil }
+func (o *limitedOutput) Stop() error         { return nil }
+
+func TestEngineOutputBufferSamples(t *testing.T) {
+	t.Parallel()
+	out := &limitedOutput{}
+	out.SetBufferLimit(output.BufferLimit{Output: "limited", MaxSamples: 2, Overflow: output.OverflowDrop})
+	e, _, wait := newTestEngine(t, nil, nil, []output.Output{out, mockoutput.New()}, lib.Options{})
+	defer wait()
+
+	metric := stats.New("my_metric", stats.Counter)
+	sample := stats.Sample{Metric: metric, Value: 1, Tags: stats.NewSampleTags(nil)}
+	out.AddMetricSamples([]stats.SampleContainer{sample, sample, sample})
+
+	values := func(containers []stats.SampleContainer) map[string]float64 {
+		res := map[string]float64{}
+		for _, c := range containers {
+			for _, s := range c.GetSamples() {
+				output, _ := s.Tags.Get("output")
+				res[s.Metric.Name+"{"+output+"}"] = s.Value
+			}
+		}
+		return res
+	}
+	assert.Equal(t, map[string]float64{
+		"output_buffered_samples{limited}": 2,
+		"output_dropped_samples{limited}":  1,
+	}, values(e.outputBufferSamples(time.Now())))
+
+	// the dropped samples are only emitted once
+	out.GetBufferedSamples()
+	assert.Equal(t, map[string]float64{
+		"output_buffered_samples{limited}": 0,
+		"output_dropped_samples{limited}":  0,
+	}, values(e.outputBufferSamples(time.Now())))
 }
 
 func TestEngineThresholdsWillAbort(t *testing.T) {
@@ -396,6 +537,185 @@ func TestEngine_processThresholds(t *testing.T) {
 	}
 }
 
+func TestEngine_processThresholdsWindow(t *testing.T) {
+	t.Parallel()
+	rate := stats.New("my_rate", stats.Rate)
+
+	var ths stats.Thresholds
+	require.NoError(t, json.Unmarshal([]byte(`[{"threshold":"rate<0.5","abortOnFail":true,"window":"1m"}]`), &ths))
+	require.NoError(t, ths.Parse())
+	e, _, wait := newTestEngine(t, nil, nil, nil, lib.Options{
+		Thresholds: map[string]stats.Thresholds{"my_rate": ths, "my_rate{a:1}": ths},
+	})
+	defer wait()
+
+	// only the samples of the last minute fail
+	now := time.Now()
+	for i := 0; i < 10; i++ {
+		e.processSamples([]stats.SampleContainer{stats.Sample{
+			Metric: rate, Time: now.Add(time.Duration(i-10) * time.Minute), Value: 0,
+			Tags: stats.IntoSampleTags(&map[string]string{"a": "1"}),
+		}})
+	}
+	assert.False(t, e.processThresholds())
+	assert.False(t, e.IsTainted())
+
+	e.processSamples([]stats.SampleContainer{stats.Sample{
+		Metric: rate, Time: now, Value: 1, Tags: stats.IntoSampleTags(&map[string]string{"a": "1"}),
+	}})
+	assert.True(t, e.processThresholds())
+	assert.True(t, e.IsTainted())
+	assert.True(t, e.Metrics["my_rate{a:1}"].Tainted.Bool)
+}
+
+func TestEngine_processThresholdsSeverity(t *testing.T) {
+	t.Parallel()
+	rate := stats.New("my_rate", stats.Rate)
+
+	thresholds := make(map[string]stats.Thresholds)
+	for name, data := range map[string]string{
+		"my_rate":      `[{"threshold":"rate<0.5","severity":"warn"}]`,
+		"my_rate{a:1}": `[{"threshold":"rate<0.9","exitCode":42},{"threshold":"rate<0.8","severity":"fail"}]`,
+		"my_rate{a:2}": `[{"threshold":"rate<0.5","severity":"critical","exitCode":43}]`,
+	} {
+		var ths stats.Thresholds
+		require.NoError(t, json.Unmarshal([]byte(data), &ths))
+		require.NoError(t, ths.Parse())
+		thresholds[name] = ths
+	}
+	e, _, wait := newTestEngine(t, nil, nil, nil, lib.Options{Thresholds: thresholds})
+	defer wait()
+
+	// only the threshold with the warn severity fails
+	e.processSamples([]stats.SampleContainer{stats.Sample{
+		Metric: rate, Time: time.Now(), Value: 1, Tags: stats.IntoSampleTags(&map[string]string{"a": "3"}),
+	}})
+	assert.False(t, e.processThresholds())
+	assert.False(t, e.IsTainted())
+	assert.Equal(t, []string{"my_rate: rate<0.5"}, e.FailedWarnThresholds())
+
+	// the first failed threshold with the highest severity sets the exit code
+	e.processSamples([]stats.SampleContainer{stats.Sample{
+		Metric: rate, Time: time.Now(), Value: 1, Tags: stats.IntoSampleTags(&map[string]string{"a": "1"}),
+	}})
+	e.processThresholds()
+	assert.True(t, e.IsTainted())
+	assert.Equal(t, errext.ExitCode(42), e.ThresholdsExitCode())
+
+	e.processSamples([]stats.SampleContainer{stats.Sample{
+		Metric: rate, Time: time.Now(), Value: 1, Tags: stats.IntoSampleTags(&map[string]string{"a": "2"}),
+	}})
+	e.processThresholds()
+	assert.Equal(t, errext.ExitCode(43), e.ThresholdsExitCode())
+	assert.Equal(t, []string{"my_rate: rate<0.5"}, e.FailedWarnThresholds())
+}
+
+func TestEngine_processThresholdsReferences(t *testing.T) {
+	t.Parallel()
+	trend := stats.New("my_trend", stats.Trend)
+
+	ths := stats.NewThresholds([]string{"my_trend{group:a}.p(99) < 2 * my_trend{group:b}.p(99)"})
+	require.NoError(t, ths.Parse())
+	e, _, wait := newTestEngine(t, nil, nil, nil, lib.Options{
+		Thresholds: map[string]stats.Thresholds{"my_trend": ths},
+	})
+	defer wait()
+
+	add := func(group string, value float64) {
+		e.processSamples([]stats.SampleContainer{stats.Sample{
+			Metric: trend, Time: time.Now(), Value: value,
+			Tags: stats.IntoSampleTags(&map[string]string{"group": group}),
+		}})
+	}
+
+	// the threshold passes until both submetrics have samples
+	add("a", 300)
+	assert.False(t, e.processThresholds())
+	assert.False(t, e.IsTainted())
+
+	add("b", 200)
+	assert.False(t, e.processThresholds())
+	assert.False(t, e.IsTainted())
+
+	add("a", 500)
+	assert.False(t, e.processThresholds())
+	assert.True(t, e.IsTainted())
+	assert.True(t, e.Metrics["my_trend"].Tainted.Bool)
+}
+
+func TestEngine_processThresholdsBaseline(t *testing.T) {
+	t.Parallel()
+	trend := stats.New("my_trend", stats.Trend)
+
+	ths := stats.NewThresholds([]string{"max < baseline * 1.1"})
+	require.NoError(t, ths.Parse())
+	e, _, wait := newTestEngine(t, nil, nil, nil, lib.Options{
+		Thresholds: map[string]stats.Thresholds{"my_trend": ths},
+	})
+	defer wait()
+	e.SetBaseline(lib.Baseline{"my_trend": {"max": 200}})
+
+	e.processSamples([]stats.SampleContainer{stats.Sample{Metric: trend, Time: time.Now(), Value: 210}})
+	assert.False(t, e.processThresholds())
+	assert.False(t, e.IsTainted())
+
+	e.processSamples([]stats.SampleContainer{stats.Sample{Metric: trend, Time: time.Now(), Value: 230}})
+	assert.False(t, e.processThresholds())
+	assert.True(t, e.IsTainted())
+}
+
+func TestEngine_processThresholdsPerScenario(t *testing.T) {
+	t.Parallel()
+	counter := stats.New("my_counter", stats.Counter)
+
+	ths := stats.NewThresholds([]string{"count<2"})
+	require.NoError(t, ths.Parse())
+	scoped := stats.NewThresholds([]string{"count<10"})
+	require.NoError(t, scoped.Parse())
+	newScenario := func(name string) lib.ExecutorConfig {
+		config := executor.NewConstantVUsConfig(name)
+		config.Duration = types.NullDurationFrom(time.Second)
+		return config
+	}
+	e, _, wait := newTestEngine(t, nil, nil, nil, lib.Options{
+		Scenarios:             lib.ScenarioConfigs{"a": newScenario("a"), "b": newScenario("b")},
+		ThresholdsPerScenario: null.BoolFrom(true),
+		Thresholds: map[string]stats.Thresholds{
+			"my_counter":               ths,
+			"my_counter{status:200}":   ths,
+			"my_counter{scenario:a}":   scoped,
+			"iteration_duration{}":     ths,
+			"my_counter{ scenario:b }": scoped,
+		},
+	})
+	defer wait()
+
+	names := make([]string, 0, len(e.thresholds))
+	for name := range e.thresholds {
+		names = append(names, name)
+	}
+	assert.ElementsMatch(t, []string{
+		"my_counter{scenario:a}", "my_counter{scenario:b}",
+		"my_counter{status:200,scenario:a}", "my_counter{status:200,scenario:b}",
+		"iteration_duration{scenario:a}", "iteration_duration{scenario:b}",
+		"my_counter{ scenario:b }",
+	}, names)
+
+	for _, scenario := range []string{"a", "b", "b"} {
+		e.processSamples([]stats.SampleContainer{stats.Sample{
+			Metric: counter, Time: time.Now(), Value: 1,
+			Tags: stats.IntoSampleTags(&map[string]string{"scenario": scenario, "status": "200"}),
+		}})
+	}
+	assert.False(t, e.processThresholds())
+	assert.True(t, e.IsTainted())
+	assert.False(t, e.Metrics["my_counter{status:200,scenario:a}"].Tainted.Bool)
+	assert.True(t, e.Metrics["my_counter{status:200,scenario:b}"].Tainted.Bool)
+	assert.False(t, e.Metrics["my_counter{ scenario:b }"].Tainted.Bool)
+	assert.False(t, e.thresholds["my_counter{status:200,scenario:a}"].Thresholds[0].LastFailed)
+	assert.True(t, e.thresholds["my_counter{status:200,scenario:b}"].Thresholds[0].LastFailed)
+}
+
 func getMetricSum(mo *mockoutput.MockOutput, name string) (result float64) {
 	for _, sc := range mo.SampleContainers {
 		for _, s := range sc.GetSamples() {
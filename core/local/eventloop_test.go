@@ -147,7 +147,11 @@ export default function() {
 		for i, entry := range entries {
 			msgs[i] = entry.Message
 		}
-		require.Equal(t, []string{"second"}, msgs)
+		require.Equal(t, []string{
+			"The iteration ended with 1 timer that will never run, created by:" +
+				"\n\tsetTimeout 1\n\t\tat /script.js:24:1(10)\n\t\tat native",
+			"second",
+		}, msgs)
 	})
 }
 
@@ -178,6 +182,11 @@ export default function() {
 		for i, entry := range entries {
 			msgs[i] = entry.Message
 		}
-		require.Equal(t, []string{"just error\n\tat /script.js:13:4(15)\n\tat native\n", "1"}, msgs)
+		require.Equal(t, []string{
+			"The iteration ended with 1 timer that will never run, created by:" +
+				"\n\tsetTimeout 1\n\t\tat /script.js:11:1(7)\n\t\tat native",
+			"just error\n\tat /script.js:13:4(15)\n\tat native\n",
+			"1",
+		}, msgs)
 	})
 }
@@ -48,9 +48,14 @@ type ExecutionScheduler struct {
 	executorConfigs []lib.ExecutorConfig // sorted by (startTime, ID)
 	executors       []lib.Executor       // sorted by (startTime, ID), excludes executors with no work
 	executionPlan   []lib.ExecutionStep
-	maxDuration     time.Duration // cached value derived from the execution plan
-	maxPossibleVUs  uint64        // cached value derived from the execution plan
+	maxDuration     time.Duration            // cached value derived from the execution plan
+	maxPossibleVUs  uint64                   // cached value derived from the execution plan
+	startTimes      map[string]time.Duration // planned start times, accounting for startAfter
+	scheduledStarts map[string]time.Time     // wall-clock start times, from the start of the test
 	state           *lib.ExecutionState
+
+	executorsDone map[string]chan struct{} // closed when the executor of the scenario finishes
+	controller    lib.ExecutionController  // optional, coordinates the run with other instances
 }
 
 // Check to see if we implement the lib.ExecutionScheduler interface
@@ -75,6 +80,15 @@ func NewExecutionScheduler(runner lib.Runner, logger *logrus.Logger) (*Execution
 
 	executorConfigs := options.Scenarios.GetSortedConfigs()
 	executors := make([]lib.Executor, 0, len(executorConfigs))
+	// The schedules are resolved only once, so a slow init or setup() can't
+	// push a scenario with startAt or startCron to its next occurrence.
+	testStart := time.Now()
+	scheduledStarts := make(map[string]time.Time)
+	for _, sc := range executorConfigs {
+		if at, ok := sc.GetScheduledStart(testStart); ok {
+			scheduledStarts[sc.GetName()] = at
+		}
+	}
 	// Only take executors which have work.
 	for _, sc := range executorConfigs {
 		if !sc.HasWork(et) {
@@ -92,6 +106,9 @@ func NewExecutionScheduler(runner lib.Runner, logger *logrus.Logger) (*Execution
 			return nil, err
 		}
 		executors = append(executors, s)
+		if scaler, ok := s.(lib.ScalableExecutor); ok {
+			executionState.SetScenarioScaler(sc.GetName(), scaler)
+		}
 	}
 
 	if options.Paused.Bool {
@@ -111,6 +128,8 @@ func NewExecutionScheduler(runner lib.Runner, logger *logrus.Logger) (*Execution
 		executionPlan:   executionPlan,
 		maxDuration:     maxDuration,
 		maxPossibleVUs:  maxPossibleVUs,
+		startTimes:      options.Scenarios.GetStartTimes(),
+		scheduledStarts: scheduledStarts,
 		state:           executionState,
 	}, nil
 }
@@ -120,6 +139,12 @@ func (e *ExecutionScheduler) GetRunner() lib.Runner {
 	return e.runner
 }
 
+// SetController sets the lib.ExecutionController that is used to coordinate
+// the test run with the other k6 instances in a distributed execution.
+func (e *ExecutionScheduler) SetController(controller lib.ExecutionController) {
+	e.controller = controller
+}
+
 // GetState returns a pointer to the execution state struct for the local
 // execution scheduler. It's guaranteed to be initialized and present, though
 // see the documentation in lib/execution.go for caveats about its usage. The
@@ -289,21 +314,56 @@ func (e *ExecutionScheduler) Init(ctx context.Context, samplesOut chan<- stats.S
 
 // runExecutor gets called by the public Run() method once per configured
 // executor, each time in a new goroutine. It is responsible for waiting out the
-// configured startTime for the specific executor and then running its Run()
-// method.
+// configured startTime for the specific executor, and the end of the scenarios
+// it starts after, and then running its Run() method, between the setup and
+// teardown of the scenario, if it has them.
 func (e *ExecutionScheduler) runExecutor(
-	runCtx context.Context, runResults chan<- error, engineOut chan<- stats.SampleContainer, executor lib.Executor,
-	builtinMetrics *metrics.BuiltinMetrics,
+	globalCtx, runCtx context.Context, runResults chan<- error, engineOut chan<- stats.SampleContainer,
+	executor lib.Executor, builtinMetrics *metrics.BuiltinMetrics,
 ) {
 	executorConfig := executor.GetConfig()
-	executorStartTime := executorConfig.GetStartTime()
+	name := executorConfig.GetName()
+	outcome := lib.ScenarioSkipped
+	defer func() {
+		e.state.SetScenarioOutcome(name, outcome)
+		close(e.executorsDone[name])
+	}()
+	executorStartTime := e.startTimes[name]
+	startAfter, successfully := executorConfig.GetStartAfter()
+	if len(startAfter) > 0 {
+		// the planned start is after the maxDuration of the startAfter scenarios,
+		// the actual one is the startTime after their actual end, which can be earlier
+		executorStartTime = executorConfig.GetStartTime()
+	}
+	if at, ok := e.scheduledStarts[name]; ok {
+		// the wall-clock schedule is followed, regardless of how long the init and setup() took
+		executorStartTime = time.Until(at)
+	}
 	executorLogger := e.logger.WithFields(logrus.Fields{
-		"executor":  executorConfig.GetName(),
+		"executor":  name,
 		"type":      executorConfig.GetType(),
 		"startTime": executorStartTime,
 	})
 	executorProgress := executor.GetProgress()
 
+	for _, scenario := range startAfter {
+		executorLogger.Debugf("Waiting for the end of scenario %s...", scenario)
+		executorProgress.Modify(pb.WithStatus(pb.Waiting), pb.WithConstProgress(0, "waiting for "+scenario))
+		select {
+		case <-runCtx.Done():
+			runResults <- nil
+			return
+		case <-e.executorsDone[scenario]:
+			// continue
+		}
+		if successfully && e.state.GetScenarioOutcome(scenario) != lib.ScenarioSucceeded {
+			executorLogger.Warnf("Skipping the scenario, since scenario %s didn't complete successfully", scenario)
+			executorProgress.Modify(pb.WithConstProgress(0, "skipped"))
+			runResults <- nil
+			return
+		}
+	}
+
 	// Check if we have to wait before starting the actual executor execution
 	if executorStartTime > 0 {
 		startTime := time.Now()
@@ -325,6 +385,17 @@ func (e *ExecutionScheduler) runExecutor(
 		}
 	}
 
+	if setupFn, _ := executorConfig.GetSetup(); setupFn != "" && !e.options.NoSetup.Bool {
+		executorLogger.Debugf("Running %s()", setupFn)
+		executorProgress.Modify(pb.WithConstProgress(0, setupFn+"()"))
+		if err := e.runner.ScenarioSetup(runCtx, engineOut, name); err != nil {
+			executorLogger.WithField("error", err).Debugf("%s() aborted by error", setupFn)
+			outcome = lib.ScenarioFailed
+			runResults <- err
+			return
+		}
+	}
+
 	executorProgress.Modify(
 		pb.WithStatus(pb.Running),
 		pb.WithConstProgress(0, "started"),
@@ -336,6 +407,22 @@ func (e *ExecutionScheduler) runExecutor(
 	} else {
 		executorLogger.WithField("error", err).Errorf("Executor error")
 	}
+
+	if teardownFn, _ := executorConfig.GetTeardown(); teardownFn != "" && !e.options.NoTeardown.Bool {
+		executorLogger.Debugf("Running %s()", teardownFn)
+		executorProgress.Modify(pb.WithConstProgress(1, teardownFn+"()"))
+		// Like teardown(), it's run with the global context, so aborts don't interrupt it
+		if terr := e.runner.ScenarioTeardown(globalCtx, engineOut, name); terr != nil {
+			executorLogger.WithField("error", terr).Debugf("%s() aborted by error", teardownFn)
+			if err == nil {
+				err = terr
+			}
+		}
+	}
+	outcome = lib.ScenarioSucceeded
+	if err != nil || e.state.GetFailedIterationCount(name) > 0 {
+		outcome = lib.ScenarioFailed
+	}
 	runResults <- err
 }
 
@@ -368,6 +455,14 @@ func (e *ExecutionScheduler) Run(
 		}
 	}
 
+	if e.controller != nil {
+		logger.Debug("Waiting for the other instances to be ready...")
+		e.initProgress.Modify(pb.WithConstProgress(1, "waiting for the other instances"))
+		if err := e.controller.SignalAndWait("test-start"); err != nil {
+			return err
+		}
+	}
+
 	e.state.MarkStarted()
 	e.initProgress.Modify(pb.WithConstProgress(1, "running"))
 
@@ -384,7 +479,7 @@ func (e *ExecutionScheduler) Run(
 		logger.Debug("Running setup()")
 		e.state.SetExecutionStatus(lib.ExecutionStatusSetup)
 		e.initProgress.Modify(pb.WithConstProgress(1, "setup()"))
-		if err := e.runner.Setup(runSubCtx, engineOut); err != nil {
+		if err := e.runSetup(runSubCtx, engineOut); err != nil {
 			logger.WithField("error", err).Debug("setup() aborted by error")
 			return err
 		}
@@ -400,8 +495,22 @@ func (e *ExecutionScheduler) Run(
 	//
 	// This is for addressing test.abort().
 	execCtx := executor.Context(runSubCtx)
+	running := make(map[string]bool, len(e.executors))
+	for _, exec := range e.executors {
+		running[exec.GetConfig().GetName()] = true
+	}
+	e.executorsDone = make(map[string]chan struct{}, len(e.executorConfigs))
+	for _, config := range e.executorConfigs {
+		done := make(chan struct{})
+		if !running[config.GetName()] {
+			// the scenarios without work are done from the start, without any failed iterations
+			e.state.SetScenarioOutcome(config.GetName(), lib.ScenarioSucceeded)
+			close(done)
+		}
+		e.executorsDone[config.GetName()] = done
+	}
 	for _, exec := range e.executors {
-		go e.runExecutor(execCtx, runResults, engineOut, exec, builtinMetrics)
+		go e.runExecutor(globalCtx, execCtx, runResults, engineOut, exec, builtinMetrics)
 	}
 
 	// Wait for all executors to finish
@@ -415,6 +524,13 @@ func (e *ExecutionScheduler) Run(
 		}
 	}
 
+	if e.controller != nil {
+		logger.Debug("Waiting for the other instances to finish...")
+		if err := e.controller.SignalAndWait("test-done"); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
 	// Run teardown() after all executors are done, if it's not disabled
 	if !e.options.NoTeardown.Bool {
 		logger.Debug("Running teardown()")
@@ -423,7 +539,7 @@ func (e *ExecutionScheduler) Run(
 
 		// We run teardown() with the global context, so it isn't interrupted by
 		// aborts caused by thresholds or even Ctrl+C (unless used twice).
-		if err := e.runner.Teardown(globalCtx, engineOut); err != nil {
+		if err := e.runTeardown(globalCtx, engineOut); err != nil {
 			logger.WithField("error", err).Debug("teardown() aborted by error")
 			return err
 		}
@@ -435,6 +551,37 @@ func (e *ExecutionScheduler) Run(
 	return firstErr
 }
 
+// runSetup runs the setup() function. In a distributed execution, only one of
+// the instances runs it and the returned data is shared with all of them.
+func (e *ExecutionScheduler) runSetup(ctx context.Context, out chan<- stats.SampleContainer) error {
+	if e.controller == nil {
+		return e.runner.Setup(ctx, out)
+	}
+	data, err := e.controller.GetOrCreateData("setup", func() ([]byte, error) {
+		if err := e.runner.Setup(ctx, out); err != nil {
+			return nil, err
+		}
+		return e.runner.GetSetupData(), nil
+	})
+	if err != nil {
+		return err
+	}
+	e.runner.SetSetupData(data)
+	return nil
+}
+
+// runTeardown runs the teardown() function, only on one of the instances in a
+// distributed execution.
+func (e *ExecutionScheduler) runTeardown(ctx context.Context, out chan<- stats.SampleContainer) error {
+	if e.controller == nil {
+		return e.runner.Teardown(ctx, out)
+	}
+	_, err := e.controller.GetOrCreateData("teardown", func() ([]byte, error) {
+		return nil, e.runner.Teardown(ctx, out)
+	})
+	return err
+}
+
 // SetPaused pauses a test, if called with true. And if called with false, tries
 // to start/resume it. See the lib.ExecutionScheduler interface documentation of
 // the methods for the various caveats about its usage.
@@ -29,6 +29,7 @@ import (
 	"net/url"
 	"reflect"
 	"runtime"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -651,7 +652,13 @@ func TestExecutionSchedulerRunCustomConfigNoCrossover(t *testing.T) {
 		{"testtag3": "scenario3"},
 		{"testtag3": "scenario3", "wstag": "scenario3"},
 	}
+	expectedConnSampleMetrics := []string{
+		metrics.WSSessionsName, metrics.WSConnectingName, metrics.WSSessionDurationName,
+		metrics.WSMessagesSentName, metrics.WSMessagesDataSentName, metrics.WSWireDataSentName,
+		metrics.WSMessagesReceivedName, metrics.WSMessagesDataReceivedName, metrics.WSWireDataReceivedName,
+	}
 	var gotSampleTags int
+	var gotConnSampleMetrics []string
 	for sample := range samples {
 		switch s := sample.(type) {
 		case stats.Sample:
@@ -684,12 +691,14 @@ func TestExecutionSchedulerRunCustomConfigNoCrossover(t *testing.T) {
 			for _, sm := range s.Samples {
 				tags := sm.Tags.CloneTags()
 				if reflect.DeepEqual(expectedConnSampleTags, tags) {
-					gotSampleTags++
+					gotConnSampleMetrics = append(gotConnSampleMetrics, sm.Metric.Name)
 				}
 			}
 		}
 	}
-	require.Equal(t, 8, gotSampleTags, "received wrong amount of samples with expected tags")
+	require.Equal(t, 6, gotSampleTags, "received wrong amount of samples with expected tags")
+	assert.ElementsMatch(t, expectedConnSampleMetrics, gotConnSampleMetrics,
+		"received wrong connected samples with expected tags")
 }
 
 func TestExecutionSchedulerSetupTeardownRun(t *testing.T) {
@@ -787,6 +796,122 @@ func TestExecutionSchedulerSetupTeardownRun(t *testing.T) {
 	})
 }
 
+// testController is a lib.ExecutionController for a single instance, which
+// records the events and gives the setup data of another instance.
+type testController struct {
+	events    []string
+	setupData []byte
+}
+
+func (c *testController) GetOrCreateData(id string, callback func() ([]byte, error)) ([]byte, error) {
+	c.events = append(c.events, "data:"+id)
+	if id == "setup" {
+		return c.setupData, nil
+	}
+	return callback()
+}
+
+func (c *testController) SignalAndWait(eventID string) error {
+	c.events = append(c.events, "event:"+eventID)
+	return nil
+}
+
+func TestExecutionSchedulerRunWithController(t *testing.T) {
+	t.Parallel()
+	registry := metrics.NewRegistry()
+	builtinMetrics := metrics.RegisterBuiltinMetrics(registry)
+	var teardownData []byte
+	runner := &minirunner.MiniRunner{
+		SetupFn: func(ctx context.Context, out chan<- stats.SampleContainer) ([]byte, error) {
+			return nil, errors.New("setup() should run on the other instance")
+		},
+	}
+	runner.TeardownFn = func(ctx context.Context, out chan<- stats.SampleContainer) error {
+		teardownData = runner.GetSetupData()
+		return nil
+	}
+	ctx, cancel, execScheduler, samples := newTestExecutionScheduler(t, runner, nil, lib.Options{
+		VUs:        null.IntFrom(1),
+		Iterations: null.IntFrom(1),
+	})
+	defer cancel()
+	controller := &testController{setupData: []byte(`{"a":1}`)}
+	execScheduler.SetController(controller)
+
+	require.NoError(t, execScheduler.Run(ctx, ctx, samples, builtinMetrics))
+	assert.Equal(t, []string{"event:test-start", "data:setup", "event:test-done", "data:teardown"}, controller.events)
+	assert.Equal(t, []byte(`{"a":1}`), teardownData)
+}
+
+func TestExecutionSchedulerScenarioSetupTeardownRun(t *testing.T) {
+	t.Parallel()
+	registry := metrics.NewRegistry()
+	builtinMetrics := metrics.RegisterBuiltinMetrics(registry)
+
+	newRunner := func(setupErr error, events *[]string) *minirunner.MiniRunner {
+		seeded := executor.NewPerVUIterationsConfig("seeded")
+		seeded.Setup = null.StringFrom("seed")
+		seeded.Teardown = null.StringFrom("unseed")
+		plain := executor.NewPerVUIterationsConfig("plain")
+		plain.StartTime = types.NullDurationFrom(time.Second)
+
+		var mx sync.Mutex
+		event := func(e string) {
+			mx.Lock()
+			defer mx.Unlock()
+			*events = append(*events, e)
+		}
+		return &minirunner.MiniRunner{
+			Fn: func(ctx context.Context, _ *lib.State, _ chan<- stats.SampleContainer) error {
+				event("iteration " + lib.GetScenarioState(ctx).Name)
+				return nil
+			},
+			ScenarioSetupFn: func(_ context.Context, _ chan<- stats.SampleContainer, scenario string) error {
+				event("setup " + scenario)
+				return setupErr
+			},
+			ScenarioTeardownFn: func(_ context.Context, _ chan<- stats.SampleContainer, scenario string) error {
+				event("teardown " + scenario)
+				return nil
+			},
+			Options: lib.Options{
+				Scenarios: lib.ScenarioConfigs{seeded.GetName(): seeded, plain.GetName(): plain},
+			},
+		}
+	}
+
+	t.Run("Normal", func(t *testing.T) {
+		t.Parallel()
+		var events []string
+		ctx, cancel, execScheduler, samples := newTestExecutionScheduler(t, newRunner(nil, &events), nil, lib.Options{})
+		defer cancel()
+		require.NoError(t, execScheduler.Run(ctx, ctx, samples, builtinMetrics))
+		assert.Equal(t, []string{
+			"setup seeded", "iteration seeded", "teardown seeded", "iteration plain",
+		}, events)
+	})
+	t.Run("Setup Error", func(t *testing.T) {
+		t.Parallel()
+		var events []string
+		runner := newRunner(errors.New("seed error"), &events)
+		ctx, cancel, execScheduler, samples := newTestExecutionScheduler(t, runner, nil, lib.Options{})
+		defer cancel()
+		assert.EqualError(t, execScheduler.Run(ctx, ctx, samples, builtinMetrics), "seed error")
+		assert.Equal(t, []string{"setup seeded"}, events)
+	})
+	t.Run("Don't Run Setup and Teardown", func(t *testing.T) {
+		t.Parallel()
+		var events []string
+		ctx, cancel, execScheduler, samples := newTestExecutionScheduler(t, newRunner(nil, &events), nil, lib.Options{
+			NoSetup:    null.BoolFrom(true),
+			NoTeardown: null.BoolFrom(true),
+		})
+		defer cancel()
+		require.NoError(t, execScheduler.Run(ctx, ctx, samples, builtinMetrics))
+		assert.Equal(t, []string{"iteration seeded", "iteration plain"}, events)
+	})
+}
+
 func TestExecutionSchedulerStages(t *testing.T) {
 	t.Parallel()
 	testdata := map[string]struct {
@@ -984,6 +1109,212 @@ func TestExecutionSchedulerEndIterations(t *testing.T) {
 	}
 }
 
+func TestExecutionSchedulerScheduledStart(t *testing.T) {
+	t.Parallel()
+
+	startAt := time.Now().Add(1500 * time.Millisecond)
+	scheduled := executor.NewPerVUIterationsConfig("scheduled")
+	scheduled.MaxDuration = types.NullDurationFrom(1 * time.Second)
+	scheduled.GracefulStop = types.NullDurationFrom(0)
+	scheduled.StartAt = null.StringFrom(startAt.Format(time.RFC3339Nano))
+
+	var iterStart int64
+	runner := &minirunner.MiniRunner{
+		Fn: func(ctx context.Context, _ *lib.State, _ chan<- stats.SampleContainer) error {
+			atomic.StoreInt64(&iterStart, time.Now().UnixNano())
+			return nil
+		},
+		Options: lib.Options{
+			Scenarios: lib.ScenarioConfigs{scheduled.GetName(): scheduled},
+		},
+	}
+	ctx, cancel, execScheduler, samples := newTestExecutionScheduler(t, runner, nil, lib.Options{})
+	defer cancel()
+
+	endTime, _ := lib.GetEndOffset(execScheduler.GetExecutionPlan())
+	assert.InDelta(t, 2500*time.Millisecond, endTime, float64(500*time.Millisecond))
+
+	registry := metrics.NewRegistry()
+	builtinMetrics := metrics.RegisterBuiltinMetrics(registry)
+	require.NoError(t, execScheduler.Run(ctx, ctx, samples, builtinMetrics))
+
+	started := time.Unix(0, atomic.LoadInt64(&iterStart))
+	assert.False(t, started.Before(startAt), "the scenario started at %s, before %s", started, startAt)
+	assert.True(t, started.Before(startAt.Add(500*time.Millisecond)))
+}
+
+func TestExecutionSchedulerScheduledStartSlowSetup(t *testing.T) {
+	t.Parallel()
+
+	// the time of day is in the past after setup(), which shouldn't move it to the next day
+	startAt := time.Now().Add(2 * time.Second).Truncate(time.Second)
+	scheduled := executor.NewPerVUIterationsConfig("scheduled")
+	scheduled.MaxDuration = types.NullDurationFrom(1 * time.Second)
+	scheduled.GracefulStop = types.NullDurationFrom(0)
+	scheduled.StartAt = null.StringFrom(startAt.Format("15:04:05"))
+
+	var setupEnd, iterStart int64
+	runner := &minirunner.MiniRunner{
+		SetupFn: func(ctx context.Context, _ chan<- stats.SampleContainer) ([]byte, error) {
+			time.Sleep(time.Until(startAt.Add(1 * time.Second)))
+			atomic.StoreInt64(&setupEnd, time.Now().UnixNano())
+			return nil, nil
+		},
+		Fn: func(ctx context.Context, _ *lib.State, _ chan<- stats.SampleContainer) error {
+			atomic.StoreInt64(&iterStart, time.Now().UnixNano())
+			return nil
+		},
+		Options: lib.Options{
+			Scenarios: lib.ScenarioConfigs{scheduled.GetName(): scheduled},
+		},
+	}
+	ctx, cancel, execScheduler, samples := newTestExecutionScheduler(t, runner, nil, lib.Options{})
+	defer cancel()
+	ctx, timeoutCancel := context.WithTimeout(ctx, 10*time.Second)
+	defer timeoutCancel()
+
+	registry := metrics.NewRegistry()
+	builtinMetrics := metrics.RegisterBuiltinMetrics(registry)
+	require.NoError(t, execScheduler.Run(ctx, ctx, samples, builtinMetrics))
+
+	require.NotZero(t, atomic.LoadInt64(&iterStart), "the scenario didn't start")
+	started := time.Unix(0, atomic.LoadInt64(&iterStart))
+	assert.True(t, started.Before(time.Unix(0, atomic.LoadInt64(&setupEnd)).Add(500*time.Millisecond)))
+}
+
+func TestExecutionSchedulerStartAfter(t *testing.T) {
+	t.Parallel()
+
+	for _, seedFails := range []bool{false, true} {
+		seedFails := seedFails
+		t.Run(fmt.Sprintf("seedFails=%t", seedFails), func(t *testing.T) {
+			t.Parallel()
+
+			seed := executor.NewPerVUIterationsConfig("seed")
+			seed.Iterations = null.IntFrom(1)
+			seed.MaxDuration = types.NullDurationFrom(1 * time.Second)
+			seed.GracefulStop = types.NullDurationFrom(0)
+			load := executor.NewPerVUIterationsConfig("load")
+			load.VUs = null.IntFrom(2)
+			load.Iterations = null.IntFrom(5)
+			load.MaxDuration = types.NullDurationFrom(1 * time.Second)
+			load.GracefulStop = types.NullDurationFrom(0)
+			load.StartAfter = []string{"seed"}
+			load.StartAfterSuccess = null.BoolFrom(true)
+
+			var seedIters, loadIters int64
+			runner := &minirunner.MiniRunner{
+				Fn: func(ctx context.Context, _ *lib.State, _ chan<- stats.SampleContainer) error {
+					if lib.GetScenarioState(ctx).Name == "load" {
+						assert.Equal(t, int64(1), atomic.LoadInt64(&seedIters))
+						atomic.AddInt64(&loadIters, 1)
+						return nil
+					}
+					atomic.AddInt64(&seedIters, 1)
+					if seedFails {
+						return errors.New("no seed")
+					}
+					return nil
+				},
+				Options: lib.Options{
+					Scenarios: lib.ScenarioConfigs{seed.GetName(): seed, load.GetName(): load},
+				},
+			}
+			logger, hook := logtest.NewNullLogger()
+			ctx, cancel, execScheduler, samples := newTestExecutionScheduler(t, runner, logger, lib.Options{})
+			defer cancel()
+
+			endTime, isFinal := lib.GetEndOffset(execScheduler.GetExecutionPlan())
+			assert.Equal(t, 2*time.Second, endTime) // load starts at the 1s end of seed
+			assert.True(t, isFinal)
+
+			registry := metrics.NewRegistry()
+			builtinMetrics := metrics.RegisterBuiltinMetrics(registry)
+			startTime := time.Now()
+			require.NoError(t, execScheduler.Run(ctx, ctx, samples, builtinMetrics))
+			// load starts at the actual end of seed, not at the end of its maxDuration
+			assert.True(t, time.Since(startTime) < 1*time.Second, "load waited for the maxDuration of seed")
+
+			assert.Equal(t, int64(1), atomic.LoadInt64(&seedIters))
+			assert.Equal(t, uint64(0), execScheduler.GetState().GetFailedIterationCount("load"))
+			if !seedFails {
+				assert.Equal(t, int64(10), atomic.LoadInt64(&loadIters))
+				assert.Equal(t, uint64(0), execScheduler.GetState().GetFailedIterationCount("seed"))
+				return
+			}
+			assert.Equal(t, int64(0), atomic.LoadInt64(&loadIters))
+			assert.Equal(t, uint64(1), execScheduler.GetState().GetFailedIterationCount("seed"))
+			var skipped bool
+			for _, e := range hook.AllEntries() {
+				if e.Level == logrus.WarnLevel {
+					skipped = assert.Equal(t, "Skipping the scenario, since scenario seed didn't complete successfully", e.Message)
+				}
+			}
+			assert.True(t, skipped)
+		})
+	}
+}
+
+func TestExecutionSchedulerStartAfterChain(t *testing.T) {
+	t.Parallel()
+
+	// seed fails, with an error or by being interrupted at its maxDuration, so load and
+	// verify, which start after the success of the previous one, are both skipped
+	for _, interrupted := range []bool{false, true} {
+		interrupted := interrupted
+		t.Run(fmt.Sprintf("interrupted=%t", interrupted), func(t *testing.T) {
+			t.Parallel()
+
+			seed := executor.NewPerVUIterationsConfig("seed")
+			seed.Iterations = null.IntFrom(1)
+			seed.MaxDuration = types.NullDurationFrom(1 * time.Second)
+			seed.GracefulStop = types.NullDurationFrom(0)
+			load := executor.NewPerVUIterationsConfig("load")
+			load.StartAfter = []string{"seed"}
+			load.StartAfterSuccess = null.BoolFrom(true)
+			verify := executor.NewPerVUIterationsConfig("verify")
+			verify.StartAfter = []string{"load"}
+			verify.StartAfterSuccess = null.BoolFrom(true)
+
+			var iters sync.Map
+			runner := &minirunner.MiniRunner{
+				Fn: func(ctx context.Context, _ *lib.State, _ chan<- stats.SampleContainer) error {
+					name := lib.GetScenarioState(ctx).Name
+					iters.Store(name, true)
+					if name != "seed" {
+						return nil
+					}
+					if interrupted {
+						<-ctx.Done()
+						return nil
+					}
+					return errors.New("no seed")
+				},
+				Options: lib.Options{
+					Scenarios: lib.ScenarioConfigs{
+						seed.GetName(): seed, load.GetName(): load, verify.GetName(): verify,
+					},
+				},
+			}
+			ctx, cancel, execScheduler, samples := newTestExecutionScheduler(t, runner, nil, lib.Options{})
+			defer cancel()
+
+			registry := metrics.NewRegistry()
+			builtinMetrics := metrics.RegisterBuiltinMetrics(registry)
+			require.NoError(t, execScheduler.Run(ctx, ctx, samples, builtinMetrics))
+
+			state := execScheduler.GetState()
+			assert.Equal(t, lib.ScenarioFailed, state.GetScenarioOutcome("seed"))
+			assert.Equal(t, lib.ScenarioSkipped, state.GetScenarioOutcome("load"))
+			assert.Equal(t, lib.ScenarioSkipped, state.GetScenarioOutcome("verify"))
+			_, loadRan := iters.Load("load")
+			_, verifyRan := iters.Load("verify")
+			assert.False(t, loadRan)
+			assert.False(t, verifyRan)
+		})
+	}
+}
+
 func TestExecutionSchedulerIsRunning(t *testing.T) {
 	t.Parallel()
 	runner := &minirunner.MiniRunner{
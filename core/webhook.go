@@ -0,0 +1,125 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package core
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.k6.io/k6/lib/consts"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/stats"
+)
+
+const thresholdsWebhookTimeout = 10 * time.Second
+
+// thresholdBreach is what is posted to the thresholds webhook when a threshold starts failing.
+type thresholdBreach struct {
+	// Metric is the name of the metric of the threshold, without its tags
+	Metric string `json:"metric"`
+	// Selector are the tags of the submetric of the threshold, like `{status:500}`, if it has any
+	Selector  string                  `json:"selector,omitempty"`
+	Threshold string                  `json:"threshold"`
+	Severity  stats.ThresholdSeverity `json:"severity,omitempty"`
+	// Values are the current values of the aggregations of the threshold
+	Values  map[string]float64 `json:"values"`
+	Time    time.Time          `json:"time"`
+	Elapsed types.Duration     `json:"elapsed"`
+}
+
+func newThresholdBreach(name string, t *stats.Threshold, elapsed time.Duration) thresholdBreach {
+	metric, selector := name, ""
+	if parent, _, err := stats.ParseSubmetric(name); err == nil && parent != name {
+		metric, selector = parent, name[len(parent):]
+	}
+	return thresholdBreach{
+		Metric:    metric,
+		Selector:  selector,
+		Threshold: t.Source,
+		Severity:  t.Severity,
+		Values:    t.Values(),
+		Time:      time.Now(),
+		Elapsed:   types.Duration(elapsed),
+	}
+}
+
+// thresholdsWebhook posts the thresholds which start failing to a URL, in the background, so the test run
+// isn't slowed down by it. The errors are only logged.
+type thresholdsWebhook struct {
+	url    string
+	client *http.Client
+	logger logrus.FieldLogger
+	wg     sync.WaitGroup
+}
+
+func newThresholdsWebhook(url string, logger logrus.FieldLogger) *thresholdsWebhook {
+	return &thresholdsWebhook{
+		url:    url,
+		client: &http.Client{Timeout: thresholdsWebhookTimeout},
+		logger: logger,
+	}
+}
+
+// notify posts the breach in the background.
+func (w *thresholdsWebhook) notify(breach thresholdBreach) {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		if err := w.post(breach); err != nil {
+			w.logger.WithError(err).Warnf("Couldn't post the failure of the threshold %s of %s%s to the webhook",
+				breach.Threshold, breach.Metric, breach.Selector)
+		}
+	}()
+}
+
+func (w *thresholdsWebhook) post(breach thresholdBreach) error {
+	body, err := stats.MarshalJSONWithoutHTMLEscape(breach)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "k6/"+consts.Version)
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("the webhook responded with the status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// wait waits for the breaches being posted.
+func (w *thresholdsWebhook) wait() {
+	w.wg.Wait()
+}
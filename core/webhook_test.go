@@ -0,0 +1,103 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/stats"
+)
+
+func TestThresholdsWebhook(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var breaches []thresholdBreach
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var breach thresholdBreach
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&breach))
+		mu.Lock()
+		breaches = append(breaches, breach)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	rate := stats.New("my_rate", stats.Rate)
+	ths := stats.NewThresholds([]string{"rate<0.5", "rate<0.7"})
+	ths.Thresholds[0].Severity = stats.ThresholdSeverityWarn
+	require.NoError(t, ths.Parse())
+	e, _, wait := newTestEngine(t, nil, nil, nil, lib.Options{
+		Thresholds:        map[string]stats.Thresholds{"my_rate{a:1}": ths},
+		ThresholdsWebhook: null.StringFrom(srv.URL),
+	})
+	defer wait()
+
+	add := func(value float64) {
+		e.processSamples([]stats.SampleContainer{stats.Sample{
+			Metric: rate, Time: time.Now(), Value: value, Tags: stats.IntoSampleTags(&map[string]string{"a": "1"}),
+		}})
+		e.processThresholds()
+		e.thresholdsWebhook.wait()
+	}
+
+	// only the thresholds which start failing are posted
+	add(0)
+	add(1)
+	add(1)
+	mu.Lock()
+	require.Len(t, breaches, 1)
+	assert.Equal(t, "my_rate", breaches[0].Metric)
+	assert.Equal(t, "{a:1}", breaches[0].Selector)
+	assert.Equal(t, "rate<0.5", breaches[0].Threshold)
+	assert.Equal(t, stats.ThresholdSeverityWarn, breaches[0].Severity)
+	assert.Equal(t, map[string]float64{"rate": 0.5}, breaches[0].Values)
+	mu.Unlock()
+
+	add(1)
+	mu.Lock()
+	require.Len(t, breaches, 2)
+	assert.Equal(t, "rate<0.7", breaches[1].Threshold)
+	assert.Equal(t, map[string]float64{"rate": 0.75}, breaches[1].Values)
+	mu.Unlock()
+
+	// and again when they fail after passing
+	for i := 0; i < 4; i++ {
+		add(0)
+	}
+	add(1)
+	add(1)
+	mu.Lock()
+	require.Len(t, breaches, 3)
+	assert.Equal(t, "rate<0.5", breaches[2].Threshold)
+	assert.Equal(t, map[string]float64{"rate": 0.5}, breaches[2].Values)
+	mu.Unlock()
+}
@@ -37,4 +37,6 @@ const (
 	CannotStartRESTAPI       errext.ExitCode = 106
 	ScriptException          errext.ExitCode = 107
 	ScriptAborted            errext.ExitCode = 108
+	ScriptLintFailed         errext.ExitCode = 109
+	ComparisonFailed         errext.ExitCode = 110
 )
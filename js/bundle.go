@@ -36,6 +36,8 @@ import (
 
 	"go.k6.io/k6/js/common"
 	"go.k6.io/k6/js/compiler"
+	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/js/modules/k6/experimental"
 	"go.k6.io/k6/lib"
 	"go.k6.io/k6/lib/consts"
 	"go.k6.io/k6/lib/metrics"
@@ -315,6 +317,28 @@ func (b *Bundle) instantiate(logger logrus.FieldLogger, rt *goja.Runtime, init *
 	rt.Set("__ENV", env)
 	rt.Set("__VU", vuID)
 	_ = rt.Set("console", newConsole(logger))
+	_ = rt.Set("performance", newPerformance(init.moduleVUImpl))
+
+	// these are globals on the web platform and a lot of third-party code depends on them,
+	// so they are also available without importing k6/experimental, sharing its timers
+	eventsModule := init.modules["k6/experimental"].(modules.Module) //nolint:forcetypeassert
+	eventsInstance := eventsModule.NewModuleInstance(init.moduleVUImpl)
+	init.moduleVUImpl.events, _ = eventsInstance.(*experimental.ModuleInstance)
+	events := eventsInstance.Exports().Named
+	globals := []string{"queueMicrotask", "AbortController", "AbortSignal"}
+	if !b.RuntimeOptions.NoGlobalTimers.Bool {
+		globals = append(globals, "setTimeout", "clearTimeout", "setInterval", "clearInterval",
+			"setImmediate", "clearImmediate")
+	}
+	for _, name := range globals {
+		_ = rt.Set(name, events[name])
+	}
+	// fetch and its classes are globals for the same reason
+	httpModule := init.modules["k6/http"].(modules.Module) //nolint:forcetypeassert
+	httpExports := httpModule.NewModuleInstance(init.moduleVUImpl).Exports().Default.(*goja.Object) //nolint:forcetypeassert
+	for _, name := range []string{"fetch", "Headers", "Request", "Response"} {
+		_ = rt.Set(name, httpExports.Get(name))
+	}
 
 	if init.compatibilityMode == lib.CompatibilityModeExtended {
 		rt.Set("global", rt.GlobalObject())
@@ -0,0 +1,122 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package js
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var errStoppingCriticalSection = errors.New("a critical section can't be started, since the iteration is being stopped")
+
+// criticalSection keeps the critical sections of the iterations of an activated
+// VU from being interrupted when the VU is stopped, at the end of the
+// gracefulStop or when the test is stopped, until they end or the criticalStop
+// of the scenario expires.
+type criticalSection struct {
+	mu       sync.Mutex
+	depth    int
+	exited   chan struct{} // closed when the outermost running critical section exits
+	stopping bool
+	hardStop chan struct{} // closed when the VU is stopped and no critical section runs anymore
+}
+
+func newCriticalSection() *criticalSection {
+	return &criticalSection{hardStop: make(chan struct{})}
+}
+
+// run runs the function as a critical section. Meanwhile, the context of the
+// modules, which e.g. HTTP requests use, is done only at the hard stop.
+func (cs *criticalSection) run(ctxPtr *context.Context, fn func() error) error {
+	cs.mu.Lock()
+	if cs.stopping {
+		cs.mu.Unlock()
+		return errStoppingCriticalSection
+	}
+	if cs.depth == 0 {
+		cs.exited = make(chan struct{})
+	}
+	cs.depth++
+	cs.mu.Unlock()
+
+	parentCtx := *ctxPtr
+	*ctxPtr = detachedContext{Context: parentCtx, done: cs.hardStop}
+	defer func() {
+		*ctxPtr = parentCtx
+		cs.mu.Lock()
+		cs.depth--
+		if cs.depth == 0 {
+			close(cs.exited)
+		}
+		cs.mu.Unlock()
+	}()
+
+	return fn()
+}
+
+// stop prevents new critical sections from starting, and waits for the running
+// one, if any, to end for up to the timeout. It returns false if it didn't.
+func (cs *criticalSection) stop(timeout time.Duration) bool {
+	defer close(cs.hardStop)
+
+	cs.mu.Lock()
+	cs.stopping = true
+	running, exited := cs.depth > 0, cs.exited
+	cs.mu.Unlock()
+	if !running {
+		return true
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-exited:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// detachedContext keeps the values of its parent, but it's done only when its
+// own done channel is closed, instead of when the parent is.
+type detachedContext struct {
+	context.Context
+	done <-chan struct{}
+}
+
+func (dc detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (dc detachedContext) Done() <-chan struct{} {
+	return dc.done
+}
+
+func (dc detachedContext) Err() error {
+	select {
+	case <-dc.done:
+		return context.Canceled
+	default:
+		return nil
+	}
+}
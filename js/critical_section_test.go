@@ -0,0 +1,102 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package js
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCriticalSection(t *testing.T) {
+	t.Parallel()
+
+	t.Run("stop waits for the end", func(t *testing.T) {
+		t.Parallel()
+		cs := newCriticalSection()
+		iterCtx, cancel := context.WithCancel(context.Background())
+		ctx := iterCtx
+
+		entered, stopped := make(chan struct{}), make(chan bool)
+		go func() {
+			assert.NoError(t, cs.run(&ctx, func() error {
+				close(entered)
+				cancel() // the VU is stopped
+				go func() { stopped <- cs.stop(time.Minute) }()
+				time.Sleep(100 * time.Millisecond)
+				assert.NoError(t, ctx.Err(), "the context of the critical section was canceled")
+				return nil
+			}))
+		}()
+		<-entered
+		assert.True(t, <-stopped)
+		assert.Equal(t, iterCtx, ctx)
+		assert.ErrorIs(t, cs.run(&ctx, func() error { return nil }), errStoppingCriticalSection)
+	})
+
+	t.Run("stop times out", func(t *testing.T) {
+		t.Parallel()
+		cs := newCriticalSection()
+		ctx := context.Background()
+
+		entered, released := make(chan struct{}), make(chan struct{})
+		go func() {
+			_ = cs.run(&ctx, func() error {
+				close(entered)
+				<-released
+				return nil
+			})
+		}()
+		<-entered
+		start := time.Now()
+		assert.False(t, cs.stop(100*time.Millisecond))
+		assert.GreaterOrEqual(t, int64(time.Since(start)), int64(100*time.Millisecond))
+		close(released)
+	})
+
+	t.Run("hard stop cancels the context", func(t *testing.T) {
+		t.Parallel()
+		cs := newCriticalSection()
+		ctx := context.Background()
+
+		require.NoError(t, cs.run(&ctx, func() error {
+			assert.NoError(t, ctx.Err())
+			_, hasDeadline := ctx.Deadline()
+			assert.False(t, hasDeadline)
+			close(cs.hardStop)
+			assert.ErrorIs(t, ctx.Err(), context.Canceled)
+			return nil
+		}))
+	})
+
+	t.Run("nested", func(t *testing.T) {
+		t.Parallel()
+		cs := newCriticalSection()
+		ctx := context.Background()
+		require.NoError(t, cs.run(&ctx, func() error {
+			return cs.run(&ctx, func() error { return nil })
+		}))
+		assert.True(t, cs.stop(time.Millisecond))
+	})
+}
@@ -3,6 +3,7 @@ package js
 import (
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/dop251/goja"
 	"go.k6.io/k6/js/modules"
@@ -27,6 +28,11 @@ type eventLoop struct {
 	registeredCallbacks int
 	vu                  modules.VU
 
+	// queuedAt is when the first of the currently queued functions was queued and lag is
+	// how long the functions that were popped last had waited, both are only used for stats
+	queuedAt time.Time
+	lag      time.Duration
+
 	// pendingPromiseRejections are rejected promises with no handler,
 	// if there is something in this map at an end of an event loop then it will exit with an error.
 	// It's similar to what Deno and Node do.
@@ -64,6 +70,9 @@ func (e *eventLoop) registerCallback() func(func() error) {
 
 	return func(f func() error) {
 		e.lock.Lock()
+		if len(e.queue) == 0 {
+			e.queuedAt = time.Now()
+		}
 		e.queue = append(e.queue, f)
 		e.registeredCallbacks--
 		e.lock.Unlock()
@@ -86,10 +95,25 @@ func (e *eventLoop) popAll() (queue []func() error, awaiting bool) {
 	queue = e.queue
 	e.queue = make([]func() error, 0, len(queue))
 	awaiting = e.registeredCallbacks != 0
+	if len(queue) > 0 && !e.queuedAt.IsZero() {
+		e.lag = time.Since(e.queuedAt)
+	}
+	e.queuedAt = time.Time{}
 	e.lock.Unlock()
 	return
 }
 
+// stats returns a snapshot of the state of the event loop, it can be called from anywhere.
+func (e *eventLoop) stats() modules.EventLoopStats {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return modules.EventLoopStats{
+		PendingCallbacks: e.registeredCallbacks,
+		QueuedCallbacks:  len(e.queue),
+		Lag:              e.lag,
+	}
+}
+
 // start will run the event loop until it's empty and there are no uninvoked registered callbacks
 // or a queued function returns an error. The provided firstCallback will be the first thing executed.
 // After start returns the event loop can be reused as long as waitOnRegistered is called.
@@ -1,14 +1,25 @@
 package js
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"runtime"
 	"testing"
 	"time"
 
 	"github.com/dop251/goja"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v3"
+
 	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/stats"
 )
 
 func TestBasicEventLoop(t *testing.T) {
@@ -116,3 +127,290 @@ func TestEventLoopReuse(t *testing.T) {
 		require.Greater(t, sleepTime+time.Millisecond*100, took2)
 	}
 }
+
+func runIterationForTest(t *testing.T, script string) error {
+	t.Helper()
+	r, err := getSimpleRunner(t, "/script.js", script)
+	require.NoError(t, err)
+
+	initVU, err := r.NewVU(1, 1, make(chan stats.SampleContainer, 100))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	vu := initVU.Activate(&lib.VUActivationParams{RunContext: ctx})
+	return vu.RunOnce()
+}
+
+func TestEventLoopTimers(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"order": `
+			var timers = require("k6/experimental");
+			exports.default = function() {
+				var log = [];
+				timers.setTimeout(function() { log.push(3); }, 30);
+				timers.setTimeout(function(a, b) { log.push(a + b); }, 10, 1, 1);
+				timers.setTimeout(function() { log.push(1); }, 0);
+				timers.setTimeout(function() {
+					log.push(4);
+					if (log.join(",") !== "1,2,3,4") {
+						throw new Error("wrong order " + log.join(","));
+					}
+				}, 30);
+			}`,
+		"clearTimeout": `
+			var timers = require("k6/experimental");
+			exports.default = function() {
+				var id = timers.setTimeout(function() { throw new Error("should've been cleared"); }, 60000);
+				timers.setTimeout(function() { timers.clearTimeout(id); }, 0);
+				timers.clearTimeout(12345);
+			}`,
+		"interval": `
+			var timers = require("k6/experimental");
+			exports.default = function() {
+				var count = 0;
+				var id = timers.setInterval(function(step) {
+					count += step;
+					if (count == 5) {
+						timers.clearInterval(id);
+					}
+					if (count > 5) {
+						throw new Error("interval wasn't cleared");
+					}
+				}, 1, 1);
+			}`,
+		"intervalSkippedTicks": `
+			var timers = require("k6/experimental");
+			exports.default = function() {
+				var calls = [];
+				var id = timers.setInterval(function(skipped) {
+					calls.push(skipped);
+					if (calls.length == 1) {
+						var start = Date.now();
+						while (Date.now() - start < 55) {} // block for more than 5 ticks
+					} else {
+						timers.clearInterval(id);
+						if (calls[1] < 4) {
+							throw new Error("expected at least 4 skipped ticks, got " + calls[1]);
+						}
+					}
+				}, 10);
+			}`,
+		"globals": `
+			exports.default = function() {
+				var fired = false;
+				var id = setInterval(function() {
+					clearInterval(id);
+					setTimeout(function() { fired = true; }, 1);
+					var cleared = setTimeout(function() { throw new Error("should've been cleared"); }, 1);
+					clearTimeout(cleared);
+				}, 1);
+				setTimeout(function() {
+					if (!fired) {
+						throw new Error("the global setTimeout didn't fire");
+					}
+				}, 20);
+			}`,
+		"immediate": `
+			exports.default = function() {
+				var log = [];
+				setTimeout(function() {
+					log.push("timeout");
+					if (log.join(",") !== "sync,immediate 1,immediate 2,nested immediate,timeout") {
+						throw new Error("wrong order " + log.join(","));
+					}
+				}, 10);
+				setImmediate(function(a) {
+					log.push("immediate " + a);
+					setImmediate(function() { log.push("nested immediate"); });
+				}, 1);
+				var cleared = setImmediate(function() { throw new Error("should've been cleared"); });
+				setImmediate(function() { log.push("immediate 2"); });
+				clearImmediate(cleared);
+				log.push("sync");
+			}`,
+		"delay": `
+			var experimental = require("k6/experimental");
+			exports.default = function() {
+				var log = [];
+				var start = Date.now();
+				experimental.delay(20).then(function() {
+					log.push("delay");
+					if (Date.now() - start < 20) {
+						throw new Error("resolved too early");
+					}
+				});
+				// the listener of the timeout signal mustn't keep the iteration running for a minute
+				experimental.delay(1, { signal: AbortSignal.timeout(60000) }).then(function() { log.push("signal"); });
+				setTimeout(function() {
+					if (log.join(",") !== "signal,delay") {
+						throw new Error("wrong order " + log.join(","));
+					}
+				}, 30);
+			}`,
+		"delayAbort": `
+			var experimental = require("k6/experimental");
+			exports.default = function() {
+				var controller = new AbortController();
+				var rejected = [];
+				experimental.delay(60000, { signal: controller.signal }).then(function() {
+					throw new Error("shouldn't have been resolved");
+				}, function(e) { rejected.push(e); });
+				experimental.delay(60000, { signal: AbortSignal.abort("already") }).catch(function(e) { rejected.push(e); });
+				experimental.delay(60000, { signal: AbortSignal.timeout(5) }).catch(function(e) { rejected.push(e); });
+				setTimeout(function() { controller.abort("aborted"); }, 1);
+				setTimeout(function() {
+					if (rejected.length !== 3 || rejected[0] !== "already" || rejected[1] !== "aborted" ||
+						rejected[2].name !== "TimeoutError") {
+						throw new Error("wrong rejections " + JSON.stringify(rejected));
+					}
+				}, 20);
+			}`,
+		"eventLoopStats": `
+			var experimental = require("k6/experimental");
+			exports.default = function() {
+				var stats = experimental.getEventLoopStats();
+				if (stats.pendingTimers !== 0 || stats.pendingImmediates !== 0 || stats.pendingCallbacks !== 0) {
+					throw new Error("nothing should be pending: " + JSON.stringify(stats));
+				}
+				setTimeout(function() {}, 60000);
+				setTimeout(function() {}, 50);
+				var id = setTimeout(function() {}, 10);
+				clearTimeout(id);
+				setImmediate(function() {
+					stats = experimental.getEventLoopStats();
+					if (stats.pendingTimers !== 2 || stats.pendingImmediates !== 0 || stats.lag < 0) {
+						throw new Error("wrong stats in the immediate: " + JSON.stringify(stats));
+					}
+					clearTimeout(1);
+				});
+				stats = experimental.getEventLoopStats();
+				if (stats.pendingTimers !== 2 || stats.pendingImmediates !== 1 ||
+					stats.pendingCallbacks !== 1 || stats.queuedCallbacks !== 1) {
+					throw new Error("wrong stats: " + JSON.stringify(stats));
+				}
+			}`,
+		"many": `
+			var timers = require("k6/experimental");
+			exports.default = function() {
+				var fired = 0;
+				for (var i = 0; i < 10000; i++) {
+					timers.setTimeout(function() { fired++; }, i % 20);
+				}
+				timers.setTimeout(function() {
+					if (fired !== 10000) {
+						throw new Error("only " + fired + " timers fired");
+					}
+				}, 25);
+			}`,
+	}
+
+	for name, script := range tests {
+		script := script
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			require.NoError(t, runIterationForTest(t, script))
+		})
+	}
+}
+
+// this isn't parallel as it counts the running goroutines
+// nolint:paralleltest
+func TestEventLoopTimersDontSpawnGoroutinePerTimer(t *testing.T) {
+	r, err := getSimpleRunner(t, "/script.js", `
+		var timers = require("k6/experimental");
+		exports.default = function() {
+			for (var i = 0; i < 1000; i++) {
+				timers.setTimeout(function() {}, 200);
+			}
+		}`)
+	require.NoError(t, err)
+
+	initVU, err := r.NewVU(1, 1, make(chan stats.SampleContainer, 100))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	vu := initVU.Activate(&lib.VUActivationParams{RunContext: ctx})
+	before := runtime.NumGoroutine()
+	errC := make(chan error, 1)
+	go func() { errC <- vu.RunOnce() }()
+	time.Sleep(100 * time.Millisecond)
+	require.Less(t, runtime.NumGoroutine()-before, 100)
+	require.NoError(t, <-errC)
+}
+
+func TestEventLoopNoGlobalTimers(t *testing.T) {
+	t.Parallel()
+	r, err := getSimpleRunner(t, "/script.js", `
+		if (typeof setTimeout !== "undefined" || typeof clearInterval !== "undefined" ||
+			typeof setImmediate !== "undefined") {
+			throw new Error("timers shouldn't be globals");
+		}
+		if (typeof queueMicrotask !== "function") {
+			throw new Error("queueMicrotask should still be a global");
+		}
+		exports.default = function() {}`,
+		lib.RuntimeOptions{
+			CompatibilityMode: null.StringFrom("base"),
+			NoGlobalTimers:    null.BoolFrom(true),
+		})
+	require.NoError(t, err)
+	_, err = r.NewVU(1, 1, make(chan stats.SampleContainer, 100))
+	require.NoError(t, err)
+}
+
+func TestEventLoopLeakedTimersReport(t *testing.T) {
+	t.Parallel()
+	logger := logrus.New()
+	logger.SetLevel(logrus.WarnLevel)
+	logger.Out = ioutil.Discard
+	hook := testutils.SimpleLogrusHook{HookedLevels: []logrus.Level{logrus.WarnLevel}}
+	logger.AddHook(&hook)
+
+	r, err := getSimpleRunner(t, "/script.js", `
+		exports.default = function() {
+			setInterval(function() {}, 60000);
+			var cleared = setTimeout(function() {}, 60000);
+			clearTimeout(cleared);
+			setTimeout(function() {
+				setImmediate(function() {});
+				throw new Error("the end");
+			}, 0);
+		}`, logger)
+	require.NoError(t, err)
+
+	samples := make(chan stats.SampleContainer, 100)
+	initVU, err := r.NewVU(1, 1, samples)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	vu := initVU.Activate(&lib.VUActivationParams{RunContext: ctx})
+	err = vu.RunOnce()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "the end")
+
+	entries := hook.Drain()
+	require.Len(t, entries, 1)
+	assert.Equal(t, 2, entries[0].Data["leaked_timers"])
+	assert.Contains(t, entries[0].Message, "setInterval 1\n\t\tat file:///script.js:3:4")
+	assert.Contains(t, entries[0].Message, "setImmediate 4\n\t\tat file:///script.js:7:5")
+	assert.NotContains(t, entries[0].Message, "setTimeout")
+
+	var leaked float64
+	for _, s := range stats.GetBufferedSamples(samples) {
+		for _, sample := range s.GetSamples() {
+			if sample.Metric.Name == metrics.LeakedTimersName {
+				leaked += sample.Value
+			}
+		}
+	}
+	assert.Equal(t, 2.0, leaked)
+
+	// the leaked timers aren't carried over to the next iteration
+	require.Error(t, vu.RunOnce())
+	require.Len(t, hook.Drain(), 1)
+}
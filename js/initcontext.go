@@ -44,9 +44,22 @@ import (
 	"go.k6.io/k6/js/modules/k6/encoding"
 	"go.k6.io/k6/js/modules/k6/execution"
 	"go.k6.io/k6/js/modules/k6/experimental"
+	"go.k6.io/k6/js/modules/k6/experimental/amqp"
+	"go.k6.io/k6/js/modules/k6/experimental/dns"
+	"go.k6.io/k6/js/modules/k6/experimental/graphql"
+	"go.k6.io/k6/js/modules/k6/experimental/kafka"
+	"go.k6.io/k6/js/modules/k6/experimental/mqtt"
+	"go.k6.io/k6/js/modules/k6/experimental/nats"
+	"go.k6.io/k6/js/modules/k6/experimental/sql"
+	"go.k6.io/k6/js/modules/k6/experimental/sse"
+	"go.k6.io/k6/js/modules/k6/experimental/ssh"
+	"go.k6.io/k6/js/modules/k6/experimental/tcp"
+	"go.k6.io/k6/js/modules/k6/experimental/thrift"
+	"go.k6.io/k6/js/modules/k6/experimental/udp"
 	"go.k6.io/k6/js/modules/k6/grpc"
 	"go.k6.io/k6/js/modules/k6/html"
 	"go.k6.io/k6/js/modules/k6/http"
+	"go.k6.io/k6/js/modules/k6/http/oauth2"
 	"go.k6.io/k6/js/modules/k6/metrics"
 	"go.k6.io/k6/js/modules/k6/ws"
 	"go.k6.io/k6/lib"
@@ -157,6 +170,9 @@ type moduleVUImpl struct {
 	state     *lib.State
 	runtime   *goja.Runtime
 	eventLoop *eventLoop
+
+	// events is the instance of k6/experimental that the timer globals come from
+	events *experimental.ModuleInstance
 }
 
 func newModuleVUImpl() *moduleVUImpl {
@@ -185,6 +201,13 @@ func (m *moduleVUImpl) RegisterCallback() func(func() error) {
 	return m.eventLoop.registerCallback()
 }
 
+func (m *moduleVUImpl) EventLoopStats() modules.EventLoopStats {
+	if m.eventLoop == nil {
+		return modules.EventLoopStats{}
+	}
+	return m.eventLoop.stats()
+}
+
 /* This is here to illustrate how to use RegisterCallback to get a promise to work with the event loop
 // TODO move this to a common function or remove before merging
 
@@ -384,18 +407,31 @@ func (i *InitContext) allowOnlyOpenedFiles() {
 
 func getInternalJSModules() map[string]interface{} {
 	return map[string]interface{}{
-		"k6":              k6.New(),
-		"k6/crypto":       crypto.New(),
-		"k6/crypto/x509":  x509.New(),
-		"k6/data":         data.New(),
-		"k6/encoding":     encoding.New(),
-		"k6/execution":    execution.New(),
-		"k6/net/grpc":     grpc.New(),
-		"k6/html":         html.New(),
-		"k6/http":         http.New(),
-		"k6/metrics":      metrics.New(),
-		"k6/ws":           ws.New(),
-		"k6/experimental": experimental.New(),
+		"k6":                      k6.New(),
+		"k6/crypto":               crypto.New(),
+		"k6/crypto/x509":          x509.New(),
+		"k6/data":                 data.New(),
+		"k6/encoding":             encoding.New(),
+		"k6/execution":            execution.New(),
+		"k6/net/grpc":             grpc.New(),
+		"k6/html":                 html.New(),
+		"k6/http":                 http.New(),
+		"k6/http/oauth2":          oauth2.New(),
+		"k6/metrics":              metrics.New(),
+		"k6/ws":                   ws.New(),
+		"k6/experimental":         experimental.New(),
+		"k6/experimental/amqp":    amqp.New(),
+		"k6/experimental/dns":     dns.New(),
+		"k6/experimental/graphql": graphql.New(),
+		"k6/experimental/kafka":   kafka.New(),
+		"k6/experimental/mqtt":    mqtt.New(),
+		"k6/experimental/nats":    nats.New(),
+		"k6/experimental/sql":     sql.New(),
+		"k6/experimental/sse":     sse.New(),
+		"k6/experimental/ssh":     ssh.New(),
+		"k6/experimental/tcp":     tcp.New(),
+		"k6/experimental/thrift":  thrift.New(),
+		"k6/experimental/udp":     udp.New(),
 	}
 }
 
@@ -0,0 +1,312 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package lint finds problems in the k6 scripts without running them.
+package lint
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"strings"
+
+	"github.com/dop251/goja/ast"
+	"github.com/dop251/goja/file"
+	"github.com/dop251/goja/parser"
+
+	"go.k6.io/k6/js/compiler"
+)
+
+// Severity is how serious a problem is, the errors are the ones that would make the test fail.
+type Severity string
+
+// The severities of the problems.
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Problem is a problem found in a script.
+type Problem struct {
+	Filename string
+	Line     int // 0 when the problem isn't on a line of the file
+	Severity Severity
+	Message  string
+}
+
+// String returns the problem as "file:line: severity: message".
+func (p Problem) String() string {
+	if p.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s: %s", p.Filename, p.Line, p.Severity, p.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", p.Filename, p.Severity, p.Message)
+}
+
+// blockingCalls are the members of the modules which block the VU until they are done.
+var blockingCalls = map[string][]string{ //nolint:gochecknoglobals
+	"k6":      {"sleep"},
+	"k6/http": {"get", "head", "post", "put", "patch", "del", "options", "request", "batch"},
+	"k6/ws":   {"connect"},
+}
+
+// callbackFunctions are the functions whose callbacks are run asynchronously by the event loop,
+// they are globals and members of k6/experimental.
+var callbackFunctions = []string{ //nolint:gochecknoglobals
+	"setTimeout", "setInterval", "setImmediate", "queueMicrotask",
+}
+
+// promiseMethods are the methods of the promises whose callbacks are run asynchronously.
+var promiseMethods = []string{"then", "catch", "finally"} //nolint:gochecknoglobals
+
+// moduleMember is what an expression refers to in a module, e.g. "get" in "k6/http".
+// The module is empty for the globals.
+type moduleMember struct {
+	module, member string
+}
+
+// Analyze finds the problems in the source of a script or module, which are
+// the blocking calls in the callbacks run by the event loop and the queries of
+// the metrics for which metricExists returns false. The ES6 sources are
+// transformed by the compiler, which keeps their lines.
+func Analyze(c *compiler.Compiler, filename, src string, metricExists func(name string) bool) ([]Problem, error) {
+	prg, err := parser.ParseFile(nil, filename, src, 0, parser.WithDisableSourceMaps)
+	if err != nil {
+		code, _, terr := c.Transform(src, filename, nil)
+		if terr != nil {
+			return nil, terr
+		}
+		if prg, err = parser.ParseFile(nil, filename, code, 0, parser.WithDisableSourceMaps); err != nil {
+			return nil, err
+		}
+	}
+
+	a := &analyzer{
+		filename:     filename,
+		file:         prg.File,
+		metricExists: metricExists,
+		bindings:     make(map[string]moduleMember),
+		functions:    make(map[string]*ast.FunctionLiteral),
+		walking:      make(map[*ast.FunctionLiteral]bool),
+	}
+	walk(reflect.ValueOf(prg), a.declare)
+	walk(reflect.ValueOf(prg), func(n ast.Node) bool { return a.check(n, "") })
+	return a.problems, nil
+}
+
+type analyzer struct {
+	filename     string
+	file         *file.File
+	metricExists func(name string) bool
+
+	// the variables to which modules or their members are assigned, like the ones of the
+	// imports transformed by babel, and the declared functions
+	bindings  map[string]moduleMember
+	functions map[string]*ast.FunctionLiteral
+	// the functions which are being checked as callbacks, for the recursive ones
+	walking map[*ast.FunctionLiteral]bool
+
+	problems []Problem
+}
+
+func (a *analyzer) declare(n ast.Node) bool {
+	switch n := n.(type) {
+	case *ast.Binding:
+		if id, ok := n.Target.(*ast.Identifier); ok && n.Initializer != nil {
+			if mm, ok := a.resolve(n.Initializer); ok && mm.module != "" {
+				a.bindings[id.Name.String()] = mm
+			}
+		}
+	case *ast.FunctionDeclaration:
+		if n.Function.Name != nil {
+			a.functions[n.Function.Name.Name.String()] = n.Function
+		}
+	}
+	return true
+}
+
+// resolve returns the module member to which an expression refers, like
+// require("k6/http").get or (0, _http.get) in the transformed imports.
+func (a *analyzer) resolve(e ast.Expression) (moduleMember, bool) {
+	switch e := e.(type) {
+	case *ast.Identifier:
+		if mm, ok := a.bindings[e.Name.String()]; ok {
+			return mm, true
+		}
+		return moduleMember{member: e.Name.String()}, true
+	case *ast.DotExpression:
+		mm, ok := a.resolve(e.Left)
+		if !ok || mm.module == "" {
+			return moduleMember{}, false
+		}
+		name := e.Identifier.Name.String()
+		switch {
+		case name == "default" && mm.member == "":
+		case mm.member == "":
+			mm.member = name
+		default:
+			mm.member += "." + name
+		}
+		return mm, true
+	case *ast.SequenceExpression:
+		if len(e.Sequence) > 0 {
+			return a.resolve(e.Sequence[len(e.Sequence)-1])
+		}
+	case *ast.CallExpression:
+		callee, ok := e.Callee.(*ast.Identifier)
+		if !ok || len(e.ArgumentList) != 1 {
+			return moduleMember{}, false
+		}
+		switch name := callee.Name.String(); {
+		case name == "require":
+			if lit, ok := e.ArgumentList[0].(*ast.StringLiteral); ok {
+				return moduleMember{module: lit.Value.String()}, true
+			}
+		case strings.HasPrefix(name, "_interopRequire"):
+			return a.resolve(e.ArgumentList[0])
+		}
+	}
+	return moduleMember{}, false
+}
+
+// check adds the problems of a node, callback is the name of the function which runs the code of the node
+// asynchronously, if it does.
+func (a *analyzer) check(n ast.Node, callback string) bool {
+	call, ok := n.(*ast.CallExpression)
+	if !ok {
+		return true
+	}
+	mm, _ := a.resolve(call.Callee)
+	if callback != "" && isBlocking(mm) {
+		name := mm.member
+		if mm.module != "k6" {
+			name = path.Base(mm.module) + "." + name
+		}
+		a.addProblem(call, SeverityWarning, fmt.Sprintf(
+			"%s() blocks the event loop in the callback of %s, which runs asynchronously", name, callback))
+	}
+	if mm.module == "k6/metrics" && mm.member == "query" && len(call.ArgumentList) > 0 && a.metricExists != nil {
+		if lit, ok := call.ArgumentList[0].(*ast.StringLiteral); ok && !a.metricExists(lit.Value.String()) {
+			a.addProblem(call, SeverityWarning, fmt.Sprintf(
+				"the queried metric '%s' is neither a built-in metric nor declared in the init context", lit.Value))
+		}
+	}
+
+	async := asyncCallback(call, mm)
+	if async == "" {
+		return true
+	}
+	walk(reflect.ValueOf(call.Callee), func(n ast.Node) bool { return a.check(n, callback) })
+	for _, arg := range call.ArgumentList {
+		a.checkCallback(arg, async, callback)
+	}
+	return false
+}
+
+// checkCallback checks an argument of a call which runs its callbacks asynchronously, the functions
+// are checked as run by async and the rest of the expressions as run by the callback of the call.
+func (a *analyzer) checkCallback(arg ast.Expression, async, callback string) {
+	var fn ast.Node = arg
+	if id, ok := arg.(*ast.Identifier); ok && a.functions[id.Name.String()] != nil {
+		f := a.functions[id.Name.String()]
+		if a.walking[f] {
+			return
+		}
+		a.walking[f] = true
+		defer delete(a.walking, f)
+		fn = f
+	}
+	switch fn.(type) {
+	case *ast.FunctionLiteral, *ast.ArrowFunctionLiteral:
+		walk(reflect.ValueOf(fn), func(n ast.Node) bool { return a.check(n, async) })
+	default:
+		walk(reflect.ValueOf(fn), func(n ast.Node) bool { return a.check(n, callback) })
+	}
+}
+
+func (a *analyzer) addProblem(n ast.Node, severity Severity, message string) {
+	a.problems = append(a.problems, Problem{
+		Filename: a.filename,
+		Line:     a.file.Position(int(n.Idx0()) - a.file.Base()).Line,
+		Severity: severity,
+		Message:  message,
+	})
+}
+
+func isBlocking(mm moduleMember) bool {
+	for _, member := range blockingCalls[mm.module] {
+		if mm.member == member {
+			return true
+		}
+	}
+	return false
+}
+
+// asyncCallback returns the name of the function of the call, if its callbacks are run asynchronously.
+func asyncCallback(call *ast.CallExpression, mm moduleMember) string {
+	if mm.module == "" || mm.module == "k6/experimental" {
+		for _, name := range callbackFunctions {
+			if mm.member == name {
+				return name + "()"
+			}
+		}
+	}
+	if dot, ok := call.Callee.(*ast.DotExpression); ok {
+		for _, name := range promiseMethods {
+			if dot.Identifier.Name.String() == name {
+				return "." + name + "()"
+			}
+		}
+	}
+	return ""
+}
+
+var ( //nolint:gochecknoglobals
+	nodeType = reflect.TypeOf((*ast.Node)(nil)).Elem()
+	fileType = reflect.TypeOf((*file.File)(nil))
+)
+
+// walk calls visit with the nodes in the value, depth-first, and with their children if it returns true.
+func walk(v reflect.Value, visit func(ast.Node) bool) {
+	switch v.Kind() { //nolint:exhaustive
+	case reflect.Interface:
+		if !v.IsNil() {
+			walk(v.Elem(), visit)
+		}
+	case reflect.Ptr:
+		if v.IsNil() || v.Type() == fileType {
+			return
+		}
+		if v.Type().Implements(nodeType) && !visit(v.Interface().(ast.Node)) {
+			return
+		}
+		walk(v.Elem(), visit)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			// the declaration lists repeat the declarations of the bodies
+			if v.Type().Field(i).Name != "DeclarationList" {
+				walk(v.Field(i), visit)
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			walk(v.Index(i), visit)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lint
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/js/compiler"
+)
+
+func TestAnalyze(t *testing.T) {
+	t.Parallel()
+	metricExists := func(name string) bool { return name == "http_req_duration" || name == "my_trend" }
+	testCases := []struct {
+		name, src string
+		problems  []string
+	}{
+		{
+			name: "sync calls",
+			src: `
+import http from "k6/http";
+import { sleep } from "k6";
+export default function() {
+	http.get("https://test.k6.io");
+	sleep(1);
+}`,
+		},
+		{
+			name: "es6 imports",
+			src: `
+import http from "k6/http";
+import { sleep } from "k6";
+import * as k6 from "k6";
+export default function() {
+	setTimeout(() => {
+		http.get("https://test.k6.io");
+		sleep(1);
+	}, 10);
+	Promise.resolve().then(function() {
+		k6.sleep(1);
+	});
+}`,
+			problems: []string{
+				"script.js:7: warning: http.get() blocks the event loop in the callback of setTimeout(), " +
+					"which runs asynchronously",
+				"script.js:8: warning: sleep() blocks the event loop in the callback of setTimeout(), " +
+					"which runs asynchronously",
+				"script.js:11: warning: sleep() blocks the event loop in the callback of .then(), " +
+					"which runs asynchronously",
+			},
+		},
+		{
+			name: "es5 requires",
+			src: `
+var http = require("k6/http");
+var sleep = require("k6").sleep;
+var experimental = require("k6/experimental");
+function work() {
+	http.batch([]);
+	work();
+}
+exports.default = function() {
+	experimental.setInterval(work, 100);
+	queueMicrotask(function() { sleep(1); });
+	sleep(1);
+};`,
+			problems: []string{
+				"script.js:6: warning: http.batch() blocks the event loop in the callback of setInterval(), " +
+					"which runs asynchronously",
+				"script.js:11: warning: sleep() blocks the event loop in the callback of queueMicrotask(), " +
+					"which runs asynchronously",
+			},
+		},
+		{
+			name: "unrelated functions",
+			src: `
+function sleep() {}
+var http = { get: function() {} };
+export default function() {
+	setTimeout(function() { sleep(); http.get(); });
+}`,
+		},
+		{
+			name: "metric queries",
+			src: `
+import { query, Trend } from "k6/metrics";
+const trend = new Trend("my_trend");
+export default function() {
+	query("http_req_duration");
+	query("my_trend");
+	query("my_counter");
+}`,
+			problems: []string{
+				"script.js:7: warning: the queried metric 'my_counter' is neither a built-in metric " +
+					"nor declared in the init context",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			problems, err := Analyze(compiler.New(logrus.New()), "script.js", tc.src, metricExists)
+			require.NoError(t, err)
+			lines := make([]string, 0, len(problems))
+			for _, p := range problems {
+				lines = append(lines, p.String())
+			}
+			assert.Equal(t, len(tc.problems), len(lines), lines)
+			if len(tc.problems) > 0 {
+				assert.Equal(t, tc.problems, lines)
+			}
+		})
+	}
+
+	t.Run("syntax error", func(t *testing.T) {
+		t.Parallel()
+		_, err := Analyze(compiler.New(logrus.New()), "script.js", "export default function( {}", nil)
+		require.Error(t, err)
+	})
+}
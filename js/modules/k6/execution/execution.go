@@ -27,6 +27,7 @@ import (
 	"time"
 
 	"github.com/dop251/goja"
+	"gopkg.in/guregu/null.v3"
 
 	"go.k6.io/k6/js/common"
 	"go.k6.io/k6/js/modules"
@@ -75,6 +76,7 @@ func (*RootModule) NewModuleInstance(vu modules.VU) modules.Instance {
 	}
 	defProp("instance", mi.newInstanceInfo)
 	defProp("scenario", mi.newScenarioInfo)
+	defProp("scenarios", mi.newScenariosControl)
 	defProp("test", mi.newTestInfo)
 	defProp("vu", mi.newVUInfo)
 
@@ -134,6 +136,66 @@ func (mi *ModuleInstance) newScenarioInfo() (*goja.Object, error) {
 	return newInfoObj(rt, si)
 }
 
+// newScenariosControl returns a goja.Object with functions to pause, resume
+// and scale the scenarios of the test by their names.
+func (mi *ModuleInstance) newScenariosControl() (*goja.Object, error) {
+	es := lib.GetExecutionState(mi.vu.Context())
+	if es == nil {
+		return nil, errors.New("controlling scenarios in the init context is not supported")
+	}
+	rt := mi.vu.Runtime()
+	throwIfErr := func(err error) {
+		if err != nil {
+			common.Throw(rt, err)
+		}
+	}
+
+	sc := map[string]func() interface{}{
+		"pause": func() interface{} {
+			return func(name string) { throwIfErr(es.PauseScenario(name)) }
+		},
+		"resume": func() interface{} {
+			return func(name string) { throwIfErr(es.ResumeScenario(name)) }
+		},
+		"isPaused": func() interface{} {
+			return es.IsScenarioPaused
+		},
+		"scale": func() interface{} {
+			return func(name string, params goja.Value) {
+				scale, err := parseScenarioScale(rt, params)
+				throwIfErr(err)
+				throwIfErr(es.ScaleScenario(mi.vu.Context(), name, scale))
+			}
+		},
+	}
+
+	return newInfoObj(rt, sc)
+}
+
+// parseScenarioScale returns the changes of the vus, maxVUs and rate properties
+// of the given object.
+func parseScenarioScale(rt *goja.Runtime, params goja.Value) (lib.ScenarioScale, error) {
+	var scale lib.ScenarioScale
+	if params == nil || goja.IsUndefined(params) || goja.IsNull(params) {
+		return scale, errors.New("the vus, maxVUs or rate to scale the scenario to should be specified")
+	}
+	obj := params.ToObject(rt)
+	for _, key := range obj.Keys() {
+		val := obj.Get(key)
+		switch key {
+		case "vus":
+			scale.VUs = null.IntFrom(val.ToInteger())
+		case "maxVUs":
+			scale.MaxVUs = null.IntFrom(val.ToInteger())
+		case "rate":
+			scale.Rate = null.FloatFrom(val.ToFloat())
+		default:
+			return scale, fmt.Errorf("unknown scenario scale option '%s'", key)
+		}
+	}
+	return scale, nil
+}
+
 // newInstanceInfo returns a goja.Object with property accessors to retrieve
 // information about the local instance stats.
 func (mi *ModuleInstance) newInstanceInfo() (*goja.Object, error) {
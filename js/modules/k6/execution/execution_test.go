@@ -33,6 +33,7 @@ import (
 	"go.k6.io/k6/js/common"
 	"go.k6.io/k6/js/modulestest"
 	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/executor"
 	"go.k6.io/k6/lib/testutils"
 	"go.k6.io/k6/stats"
 	"gopkg.in/guregu/null.v3"
@@ -223,3 +224,92 @@ func TestAbortTest(t *testing.T) { //nolint: tparallel
 		prove(t, `exec.test.abort("mayday")`, fmt.Sprintf("%s: mayday", common.AbortTest))
 	})
 }
+
+type scenarioScaler struct {
+	scales []lib.ScenarioScale
+}
+
+func (s *scenarioScaler) Scale(_ context.Context, scale lib.ScenarioScale) error {
+	s.scales = append(s.scales, scale)
+	return nil
+}
+
+func TestScenariosControl(t *testing.T) {
+	t.Parallel()
+
+	et, err := lib.NewExecutionTuple(nil, nil)
+	require.NoError(t, err)
+	options := lib.Options{Scenarios: lib.ScenarioConfigs{
+		"one": executor.NewConstantVUsConfig("one"),
+		"two": executor.NewConstantVUsConfig("two"),
+	}}
+	es := lib.NewExecutionState(options, et, 1, 1)
+	scaler := &scenarioScaler{}
+	es.SetScenarioScaler("two", scaler)
+
+	rt := goja.New()
+	m, ok := New().NewModuleInstance(
+		&modulestest.VU{
+			RuntimeField: rt,
+			InitEnvField: &common.InitEnvironment{},
+			CtxField:     lib.WithExecutionState(context.Background(), es),
+			StateField:   &lib.State{},
+		},
+	).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, rt.Set("exec", m.Exports().Default))
+
+	_, err = rt.RunString(`
+		exec.scenarios.pause("one");
+		if (!exec.scenarios.isPaused("one") || exec.scenarios.isPaused("two")) {
+			throw new Error("only scenario one should be paused");
+		}
+	`)
+	require.NoError(t, err)
+	assert.True(t, es.IsScenarioPaused("one"))
+
+	_, err = rt.RunString(`exec.scenarios.pause("one")`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "scenario 'one' was already paused")
+
+	_, err = rt.RunString(`exec.scenarios.resume("one")`)
+	require.NoError(t, err)
+	assert.False(t, es.IsScenarioPaused("one"))
+
+	_, err = rt.RunString(`exec.scenarios.pause("three")`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "there is no scenario 'three'")
+
+	_, err = rt.RunString(`exec.scenarios.scale("two", { vus: 5, maxVUs: 10, rate: 2.5 })`)
+	require.NoError(t, err)
+	assert.Equal(t, []lib.ScenarioScale{
+		{VUs: null.IntFrom(5), MaxVUs: null.IntFrom(10), Rate: null.FloatFrom(2.5)},
+	}, scaler.scales)
+
+	_, err = rt.RunString(`exec.scenarios.scale("two", { users: 5 })`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown scenario scale option 'users'")
+
+	_, err = rt.RunString(`exec.scenarios.scale("one", { vus: 5 })`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "the executor of scenario 'one' doesn't support scaling")
+}
+
+func TestScenariosControlInitContext(t *testing.T) {
+	t.Parallel()
+
+	rt := goja.New()
+	m, ok := New().NewModuleInstance(
+		&modulestest.VU{
+			RuntimeField: rt,
+			InitEnvField: &common.InitEnvironment{},
+			CtxField:     context.Background(),
+		},
+	).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, rt.Set("exec", m.Exports().Default))
+
+	_, err := rt.RunString(`exec.scenarios.pause("one")`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "controlling scenarios in the init context is not supported")
+}
@@ -0,0 +1,300 @@
+package experimental
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modules"
+)
+
+// AbortSignal is the Go side of the JS AbortSignal. Modules that support cancelling their operations get it from
+// the `signal` parameter of the operation and should stop once Done is closed.
+//
+// The asynchronous operations, like fetch() and the k6/ws connections, can be aborted at any time. The synchronous
+// requests of k6/http, like http.get(), block the event loop until they end, so an AbortController can only abort
+// them before they are sent: a setTimeout() that calls abort() runs after the request. While they are in flight,
+// only the signals returned by AbortSignal.timeout() can abort them, as their deadline is handled by a Go timer.
+type AbortSignal struct {
+	vu     modules.VU
+	ctx    context.Context
+	cancel context.CancelFunc
+	// timeout is set for the signals returned by AbortSignal.timeout(),
+	// which get aborted when their context deadline is exceeded
+	timeout bool
+
+	// everything below is only accessed from the event loop
+	reason     goja.Value
+	dispatched bool
+	watching   bool
+	unwatch    chan struct{}
+	onabort    goja.Value
+	listeners  []goja.Value
+	methods    map[string]goja.Value
+	obj        *goja.Object
+}
+
+var _ goja.DynamicObject = &AbortSignal{}
+
+func newAbortSignal(vu modules.VU) *AbortSignal {
+	ctx, cancel := context.WithCancel(context.Background())
+	return initAbortSignal(&AbortSignal{vu: vu, ctx: ctx, cancel: cancel})
+}
+
+func newTimeoutAbortSignal(vu modules.VU, timeout time.Duration) *AbortSignal {
+	// the timeout is bound to the current context, so that its timer doesn't outlive the iteration
+	parent := vu.Context()
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	return initAbortSignal(&AbortSignal{vu: vu, ctx: ctx, cancel: cancel, timeout: true})
+}
+
+func initAbortSignal(s *AbortSignal) *AbortSignal {
+	rt := s.vu.Runtime()
+	s.onabort = goja.Null()
+	s.methods = map[string]goja.Value{
+		"addEventListener":    rt.ToValue(s.addEventListener),
+		"removeEventListener": rt.ToValue(s.removeEventListener),
+		"throwIfAborted":      rt.ToValue(s.throwIfAborted),
+	}
+	s.obj = rt.NewDynamicObject(s)
+	return s
+}
+
+// Done returns a channel that is closed once the signal is aborted.
+//
+// For timeout signals it's also closed at the end of the iteration,
+// but by then nothing should be waiting on it anyway.
+func (s *AbortSignal) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+// Aborted returns whether the signal has been aborted, it's safe to call from any goroutine.
+func (s *AbortSignal) Aborted() bool {
+	if s.timeout {
+		return errors.Is(s.ctx.Err(), context.DeadlineExceeded)
+	}
+	return s.ctx.Err() != nil
+}
+
+// Reason returns the reason the signal was aborted with, or undefined if it hasn't been.
+// It must only be called from the event loop.
+func (s *AbortSignal) Reason() goja.Value {
+	if s.reason == nil && s.Aborted() {
+		// the deadline of a timeout signal was exceeded
+		s.reason = newDOMException(s.vu.Runtime(), "TimeoutError", "signal timed out")
+	}
+	if s.reason == nil {
+		return goja.Undefined()
+	}
+	return s.reason
+}
+
+// abort aborts the signal, it does nothing if it's already aborted.
+func (s *AbortSignal) abort(reason goja.Value) error {
+	if s.Aborted() {
+		return nil
+	}
+	if reason == nil || goja.IsUndefined(reason) {
+		reason = newDOMException(s.vu.Runtime(), "AbortError", "signal is aborted without reason")
+	}
+	s.reason = reason
+	s.cancel()
+	return s.dispatch()
+}
+
+// dispatch calls the abort event handlers, at most once.
+func (s *AbortSignal) dispatch() error {
+	if s.dispatched {
+		return nil
+	}
+	s.dispatched = true
+	event := s.vu.Runtime().NewObject()
+	_ = event.Set("type", "abort")
+	_ = event.Set("target", s.obj)
+
+	listeners := s.listeners
+	if fn, ok := goja.AssertFunction(s.onabort); ok {
+		if _, err := fn(s.obj, event); err != nil {
+			return err
+		}
+	}
+	for _, listener := range listeners {
+		fn, _ := goja.AssertFunction(listener)
+		if _, err := fn(s.obj, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stopWatching lets the event loop finish before a timeout signal times out,
+// once there is nothing left to call when it does.
+func (s *AbortSignal) stopWatching() {
+	if !s.watching || len(s.listeners) > 0 {
+		return
+	}
+	if _, ok := goja.AssertFunction(s.onabort); ok {
+		return
+	}
+	s.watching = false
+	close(s.unwatch)
+}
+
+// watch makes certain that the abort event handlers of timeout signals will be called.
+// This keeps the event loop running until the signal times out, similar to a setTimeout.
+func (s *AbortSignal) watch() {
+	if !s.timeout || s.watching || s.Aborted() {
+		return
+	}
+	s.watching = true
+	s.unwatch = make(chan struct{})
+	runOnLoop, unwatch := s.vu.RegisterCallback(), s.unwatch
+	go func() {
+		select {
+		case <-s.ctx.Done():
+		case <-unwatch:
+			runOnLoop(func() error { return nil })
+			return
+		}
+		runOnLoop(func() error {
+			if !s.Aborted() {
+				return nil // the iteration has ended
+			}
+			s.Reason()
+			return s.dispatch()
+		})
+	}()
+}
+
+func (s *AbortSignal) addEventListener(event string, listener goja.Value) {
+	if event != "abort" || s.Aborted() {
+		return
+	}
+	if _, ok := goja.AssertFunction(listener); !ok {
+		return
+	}
+	for _, l := range s.listeners {
+		if l.StrictEquals(listener) {
+			return
+		}
+	}
+	s.listeners = append(s.listeners, listener)
+	s.watch()
+}
+
+func (s *AbortSignal) removeEventListener(event string, listener goja.Value) {
+	if event != "abort" {
+		return
+	}
+	for i, l := range s.listeners {
+		if l.StrictEquals(listener) {
+			s.listeners = append(s.listeners[:i:i], s.listeners[i+1:]...)
+			s.stopWatching()
+			return
+		}
+	}
+}
+
+func (s *AbortSignal) throwIfAborted() {
+	if s.Aborted() {
+		panic(s.Reason())
+	}
+}
+
+// Get implements goja.DynamicObject.
+func (s *AbortSignal) Get(key string) goja.Value {
+	switch key {
+	case "aborted":
+		return s.vu.Runtime().ToValue(s.Aborted())
+	case "reason":
+		return s.Reason()
+	case "onabort":
+		return s.onabort
+	default:
+		return s.methods[key]
+	}
+}
+
+// Set implements goja.DynamicObject, only onabort can be set.
+func (s *AbortSignal) Set(key string, val goja.Value) bool {
+	if key != "onabort" {
+		return false
+	}
+	s.onabort = val
+	if _, ok := goja.AssertFunction(val); ok {
+		s.watch()
+	} else {
+		s.stopWatching()
+	}
+	return true
+}
+
+// Has implements goja.DynamicObject.
+func (s *AbortSignal) Has(key string) bool {
+	switch key {
+	case "aborted", "reason", "onabort":
+		return true
+	}
+	_, ok := s.methods[key]
+	return ok
+}
+
+// Delete implements goja.DynamicObject, none of the properties can be deleted.
+func (s *AbortSignal) Delete(string) bool {
+	return false
+}
+
+// Keys implements goja.DynamicObject.
+func (s *AbortSignal) Keys() []string {
+	return []string{"aborted", "reason", "onabort"}
+}
+
+// newAbortController implements the AbortController constructor.
+func (mi *ModuleInstance) newAbortController(goja.ConstructorCall) *goja.Object {
+	rt := mi.vu.Runtime()
+	signal := newAbortSignal(mi.vu)
+	o := rt.NewObject()
+	must(rt, o.DefineDataProperty("signal", signal.obj,
+		goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_TRUE))
+	must(rt, o.Set("abort", func(reason goja.Value) {
+		must(rt, signal.abort(reason))
+	}))
+	return o
+}
+
+// newAbortSignalObject returns the AbortSignal global, which can't be constructed,
+// but has the static abort and timeout methods.
+func (mi *ModuleInstance) newAbortSignalObject() *goja.Object {
+	rt := mi.vu.Runtime()
+	o := rt.ToValue(func(goja.ConstructorCall) *goja.Object {
+		panic(rt.NewTypeError("Illegal constructor"))
+	}).ToObject(rt)
+	must(rt, o.Set("abort", func(reason goja.Value) goja.Value {
+		signal := newAbortSignal(mi.vu)
+		must(rt, signal.abort(reason))
+		return signal.obj
+	}))
+	must(rt, o.Set("timeout", func(ms float64) goja.Value {
+		return newTimeoutAbortSignal(mi.vu, toDuration(ms)).obj
+	}))
+	return o
+}
+
+// newDOMException returns an Error with the given name, as there are no DOMExceptions in goja.
+func newDOMException(rt *goja.Runtime, name, message string) goja.Value {
+	e, err := rt.New(rt.Get("Error"), rt.ToValue(message))
+	must(rt, err)
+	must(rt, e.Set("name", name))
+	return e
+}
+
+func must(rt *goja.Runtime, err error) {
+	if err != nil {
+		common.Throw(rt, err)
+	}
+}
@@ -0,0 +1,893 @@
+// Package amqp implements the k6/experimental/amqp module, an AMQP 0.9.1 client for RabbitMQ and the other
+// brokers of this version of the protocol.
+package amqp
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/stats"
+)
+
+type (
+	// RootModule is the global module instance that will create module
+	// instances for each VU.
+	RootModule struct{}
+
+	// ModuleInstance represents an instance of the amqp module for every VU.
+	ModuleInstance struct {
+		vu modules.VU
+	}
+)
+
+var (
+	_ modules.Module   = &RootModule{}
+	_ modules.Instance = &ModuleInstance{}
+)
+
+// New returns a pointer to a new RootModule instance.
+func New() *RootModule {
+	return &RootModule{}
+}
+
+// NewModuleInstance implements the modules.Module interface to return
+// a new instance for each VU.
+func (*RootModule) NewModuleInstance(vu modules.VU) modules.Instance {
+	return &ModuleInstance{vu: vu}
+}
+
+// Exports returns the exports of the amqp module.
+func (mi *ModuleInstance) Exports() modules.Exports {
+	return modules.Exports{
+		Named: map[string]interface{}{
+			"Client": mi.NewClient,
+		},
+	}
+}
+
+// NewClient is the JS constructor for the amqp Client.
+func (mi *ModuleInstance) NewClient(call goja.ConstructorCall) *goja.Object {
+	rt := mi.vu.Runtime()
+	return rt.ToValue(&Client{vu: mi.vu}).ToObject(rt)
+}
+
+const (
+	defaultTimeout  = 10 * time.Second
+	defaultMaxWait  = time.Second
+	defaultFrameMax = 128 * 1024
+)
+
+var (
+	errConnectInInitContext = common.NewInitContextError("connecting to an AMQP broker in the init context is not supported")
+	errNotConnected         = errors.New("the AMQP client isn't connected")
+	errClosed               = errors.New("the AMQP connection was closed")
+	errTimeout              = errors.New("timed out waiting for the AMQP broker")
+)
+
+// Client is an AMQP client, which can be connected once. It uses a single channel, which is opened again
+// after the broker closes it because of an error, and its connection is closed at the latest when the
+// iteration ends.
+type Client struct {
+	vu modules.VU
+
+	// set by Connect
+	conn     net.Conn
+	tags     map[string]string
+	timeout  time.Duration
+	confirm  bool
+	frameMax int
+	// ctx is cancelled when the connection is closed, after err is set
+	ctx    context.Context
+	cancel context.CancelFunc
+	// frames are the frames of the channels, the ones of the connection are handled by read
+	frames chan *frame
+
+	writeMu sync.Mutex
+
+	mu  sync.Mutex
+	err error
+
+	// only accessed from the VU goroutine
+	channel     uint16 // the open channel, 0 if it has to be opened
+	nextChannel uint16
+	publishSeq  uint64
+}
+
+// connectParams are the params of connect().
+type connectParams struct {
+	Confirm bool
+	Timeout time.Duration
+	Tags    map[string]string
+}
+
+func parseConnectParams(raw map[string]interface{}) (connectParams, error) {
+	p := connectParams{Confirm: true, Timeout: defaultTimeout}
+	var err error
+	for k, v := range raw {
+		switch k {
+		case "confirm":
+			var ok bool
+			if p.Confirm, ok = v.(bool); !ok {
+				return p, errors.New("the confirm param needs to be a boolean")
+			}
+		case "timeout":
+			p.Timeout, err = types.GetDurationValue(v)
+			if err != nil || p.Timeout <= 0 {
+				return p, fmt.Errorf("invalid timeout value '%#v'", v)
+			}
+		case "tags":
+			tags, ok := v.(map[string]interface{})
+			if !ok {
+				return p, fmt.Errorf("metric tags must be an object of string values, got '%#v'", v)
+			}
+			p.Tags = make(map[string]string, len(tags))
+			for name, tag := range tags {
+				p.Tags[name] = fmt.Sprint(tag)
+			}
+		default:
+			return p, fmt.Errorf("unknown connect param: %q", k)
+		}
+	}
+	return p, nil
+}
+
+// broker is the address of a broker, with the credentials and the virtual host of its URL.
+type broker struct {
+	url        *url.URL
+	addr       string
+	tls        bool
+	user, pass string
+	vhost      string
+}
+
+// parseBrokerURL parses the URL of a broker, whose credentials are guest:guest and virtual host is / by default.
+func parseBrokerURL(rawURL string) (*broker, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid AMQP broker URL %q: %w", rawURL, err)
+	}
+	b := &broker{url: u, user: "guest", pass: "guest", vhost: "/"}
+	port := "5672"
+	switch u.Scheme {
+	case "amqp":
+	case "amqps":
+		b.tls, port = true, "5671"
+	default:
+		return nil, fmt.Errorf("invalid AMQP broker URL %q, the scheme needs to be amqp or amqps", rawURL)
+	}
+	if u.Port() != "" {
+		port = u.Port()
+	}
+	b.addr = net.JoinHostPort(u.Hostname(), port)
+	if u.User != nil {
+		b.user = u.User.Username()
+		b.pass, _ = u.User.Password()
+	}
+	if len(u.Path) > 1 {
+		b.vhost = u.Path[1:]
+	}
+	// the credentials aren't part of the url tag
+	u.User = nil
+	return b, nil
+}
+
+// Connect connects to a broker, with the credentials and the virtual host of its URL.
+func (c *Client) Connect(brokerURL string, params map[string]interface{}) error {
+	state := c.vu.State()
+	if state == nil {
+		return errConnectInInitContext
+	}
+	if c.conn != nil {
+		return errors.New("the AMQP client was already connected, a new client is needed to connect again")
+	}
+	p, err := parseConnectParams(params)
+	if err != nil {
+		return err
+	}
+	b, err := parseBrokerURL(brokerURL)
+	if err != nil {
+		return err
+	}
+
+	c.tags = state.CloneTags()
+	if state.Options.SystemTags.Has(stats.TagURL) {
+		c.tags["url"] = b.url.String()
+	}
+	for k, v := range p.Tags {
+		c.tags[k] = v
+	}
+
+	ctx, cancel := context.WithTimeout(c.vu.Context(), p.Timeout)
+	defer cancel()
+	conn, err := state.Dialer.DialContext(ctx, "tcp", b.addr)
+	if err != nil {
+		return err
+	}
+	if b.tls {
+		tlsConfig := &tls.Config{} //nolint:gosec
+		if state.TLSConfig != nil {
+			tlsConfig = state.TLSConfig.Clone()
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = b.url.Hostname()
+		}
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			_ = conn.Close()
+			return err
+		}
+		conn = tlsConn
+	}
+
+	r := bufio.NewReader(conn)
+	deadline, _ := ctx.Deadline()
+	if err = conn.SetDeadline(deadline); err == nil {
+		c.frameMax, err = handshake(conn, r, b)
+	}
+	if err == nil {
+		err = conn.SetDeadline(time.Time{})
+	}
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+
+	c.conn, c.timeout, c.confirm = conn, p.Timeout, p.Confirm
+	c.ctx, c.cancel = context.WithCancel(c.vu.Context())
+	c.frames = make(chan *frame, 64)
+	go c.read(r)
+	go func() {
+		// the connection doesn't outlive the iteration
+		<-c.ctx.Done()
+		c.closeWith(errClosed)
+	}()
+	return nil
+}
+
+// handshake opens the connection to a virtual host, it returns the max size of the frames.
+func handshake(conn net.Conn, r *bufio.Reader, b *broker) (int, error) {
+	if _, err := conn.Write(protocolHeader); err != nil {
+		return 0, err
+	}
+	next := func(expected uint32) (*decoder, error) {
+		f, err := readFrame(r)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("the broker closed the connection during the handshake, "+
+				"the credentials or the virtual host may be invalid: %w", err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		method, d := f.method()
+		if method == methodConnectionClose {
+			return nil, closeError("the broker refused the connection", d)
+		}
+		if method != expected {
+			return nil, fmt.Errorf("unexpected AMQP method %d.%d during the handshake", method>>16, method&0xffff)
+		}
+		return d, nil
+	}
+
+	d, err := next(methodConnectionStart)
+	if err != nil {
+		return 0, err
+	}
+	_, _, _ = d.octet(), d.octet(), d.table()
+	if mechanisms := d.longString(); !strings.Contains(" "+mechanisms+" ", " PLAIN ") {
+		return 0, fmt.Errorf("the broker doesn't support the PLAIN authentication, only %s", mechanisms)
+	}
+	e := newMethod(methodConnectionStartOk)
+	e.table(map[string]interface{}{"product": "k6"})
+	e.shortString("PLAIN")
+	e.longString("\x00" + b.user + "\x00" + b.pass)
+	e.shortString("en_US")
+	if _, err = conn.Write(appendFrame(nil, frameMethod, 0, e.b)); err != nil {
+		return 0, err
+	}
+
+	if d, err = next(methodConnectionTune); err != nil {
+		return 0, err
+	}
+	channelMax, frameMax := d.uint16(), int(d.uint32())
+	if frameMax == 0 || frameMax > defaultFrameMax {
+		frameMax = defaultFrameMax
+	}
+	e = newMethod(methodConnectionTuneOk)
+	e.uint16(channelMax)
+	e.uint32(uint32(frameMax))
+	e.uint16(0) // no heartbeats
+	frames := appendFrame(nil, frameMethod, 0, e.b)
+	e = newMethod(methodConnectionOpen)
+	e.shortString(b.vhost)
+	e.shortString("")
+	e.bit(false)
+	if _, err = conn.Write(appendFrame(frames, frameMethod, 0, e.b)); err != nil {
+		return 0, err
+	}
+	if _, err = next(methodConnectionOpenOk); err != nil {
+		return 0, err
+	}
+	return frameMax, nil
+}
+
+// closeError returns the error of a connection.close or channel.close method.
+func closeError(msg string, d *decoder) error {
+	code, text := d.uint16(), d.shortString()
+	return fmt.Errorf("%s: %d %s", msg, code, text)
+}
+
+// read reads the frames of the connection until it's closed.
+func (c *Client) read(r *bufio.Reader) {
+	for {
+		f, err := readFrame(r)
+		if err != nil {
+			c.closeWith(err)
+			return
+		}
+		if f.channel != 0 {
+			select {
+			case c.frames <- f:
+			case <-c.ctx.Done():
+				return
+			}
+			continue
+		}
+		switch method, d := f.method(); method {
+		case methodConnectionClose:
+			_ = c.write(appendFrame(nil, frameMethod, 0, newMethod(methodConnectionCloseOk).b))
+			c.closeWith(closeError("the broker closed the connection", d))
+			return
+		case methodConnectionCloseOk:
+			c.closeWith(errClosed)
+			return
+		}
+	}
+}
+
+func (c *Client) write(b []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := c.conn.SetWriteDeadline(time.Now().Add(c.timeout)); err != nil {
+		return err
+	}
+	_, err := c.conn.Write(b)
+	return err
+}
+
+// closeWith closes the connection, err being the error of the operations that are still waiting.
+func (c *Client) closeWith(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	c.err = err
+	_ = c.conn.Close()
+	c.cancel()
+}
+
+func (c *Client) closedErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// next returns the next frame of the open channel, skipping the frames of the channels closed before.
+// A channel.close of the broker is answered and returned as an error.
+func (c *Client) next(deadline time.Time) (*frame, error) {
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	for {
+		select {
+		case f := <-c.frames:
+			if f.channel != c.channel {
+				continue
+			}
+			if method, d := f.method(); method == methodChannelClose {
+				_ = c.write(appendFrame(nil, frameMethod, f.channel, newMethod(methodChannelCloseOk).b))
+				c.channel = 0
+				return nil, closeError("the broker closed the channel", d)
+			}
+			return f, nil
+		case <-c.ctx.Done():
+			return nil, c.closedErr()
+		case <-timer.C:
+			return nil, errTimeout
+		}
+	}
+}
+
+// call sends a method on the channel and waits for the reply method, it skips the other frames.
+func (c *Client) call(e *encoder, reply uint32) (*decoder, error) {
+	if err := c.write(appendFrame(nil, frameMethod, c.channel, e.b)); err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(c.timeout)
+	for {
+		f, err := c.next(deadline)
+		if err != nil {
+			return nil, err
+		}
+		if method, d := f.method(); method == reply {
+			return d, nil
+		}
+	}
+}
+
+// open checks that the client is connected, and opens the channel if it isn't.
+func (c *Client) open() error {
+	if c.conn == nil {
+		return errNotConnected
+	}
+	if err := c.closedErr(); err != nil {
+		return err
+	}
+	if c.channel != 0 {
+		return nil
+	}
+	c.nextChannel++
+	c.channel = c.nextChannel
+	e := newMethod(methodChannelOpen)
+	e.shortString("")
+	_, err := c.call(e, methodChannelOpenOk)
+	if err == nil && c.confirm {
+		e = newMethod(methodConfirmSelect)
+		e.bit(false)
+		_, err = c.call(e, methodConfirmSelectOk)
+	}
+	if err != nil {
+		c.channel = 0
+		return err
+	}
+	c.publishSeq = 0
+	return nil
+}
+
+// boolParams returns the values of the boolean params, which are false by default.
+func boolParams(raw map[string]interface{}, names ...string) (map[string]bool, error) {
+	values := make(map[string]bool, len(names))
+	for k, v := range raw {
+		found := false
+		for _, name := range names {
+			found = found || name == k
+		}
+		b, ok := v.(bool)
+		if !found || !ok {
+			return nil, fmt.Errorf("invalid param %q, the params are the booleans %s", k, strings.Join(names, ", "))
+		}
+		values[k] = b
+	}
+	return values, nil
+}
+
+// ExchangeDeclare declares an exchange, of the direct type unless the type param is set.
+func (c *Client) ExchangeDeclare(name string, params map[string]interface{}) error {
+	kind := "direct"
+	if v, ok := params["type"]; ok {
+		kind = fmt.Sprint(v)
+		delete(params, "type")
+	}
+	flags, err := boolParams(params, "passive", "durable", "autoDelete", "internal")
+	if err != nil {
+		return err
+	}
+	if err = c.open(); err != nil {
+		return err
+	}
+	e := newMethod(methodExchangeDeclare)
+	e.uint16(0)
+	e.shortString(name)
+	e.shortString(kind)
+	e.bit(flags["passive"])
+	e.bit(flags["durable"])
+	e.bit(flags["autoDelete"])
+	e.bit(flags["internal"])
+	e.bit(false) // no-wait
+	e.table(nil)
+	_, err = c.call(e, methodExchangeDeclareOk)
+	return err
+}
+
+// QueueDeclare declares a queue, the broker generates its name if it's empty. It returns the name of the
+// queue and its counts of messages and consumers.
+func (c *Client) QueueDeclare(name string, params map[string]interface{}) (map[string]interface{}, error) {
+	flags, err := boolParams(params, "passive", "durable", "exclusive", "autoDelete")
+	if err != nil {
+		return nil, err
+	}
+	if err = c.open(); err != nil {
+		return nil, err
+	}
+	e := newMethod(methodQueueDeclare)
+	e.uint16(0)
+	e.shortString(name)
+	e.bit(flags["passive"])
+	e.bit(flags["durable"])
+	e.bit(flags["exclusive"])
+	e.bit(flags["autoDelete"])
+	e.bit(false) // no-wait
+	e.table(nil)
+	d, err := c.call(e, methodQueueDeclareOk)
+	if err != nil {
+		return nil, err
+	}
+	queue := map[string]interface{}{"name": d.shortString(), "messageCount": d.uint32(), "consumerCount": d.uint32()}
+	return queue, d.err
+}
+
+// QueueBind binds a queue to an exchange, with a routing key.
+func (c *Client) QueueBind(queue, exchange, routingKey string) error {
+	if err := c.open(); err != nil {
+		return err
+	}
+	e := newMethod(methodQueueBind)
+	e.uint16(0)
+	e.shortString(queue)
+	e.shortString(exchange)
+	e.shortString(routingKey)
+	e.bit(false) // no-wait
+	e.table(nil)
+	_, err := c.call(e, methodQueueBindOk)
+	return err
+}
+
+// publishParams are the params of publish().
+type publishParams struct {
+	properties
+	Tags map[string]string
+}
+
+func parsePublishParams(raw map[string]interface{}) (publishParams, error) {
+	var p publishParams
+	for k, v := range raw {
+		switch k {
+		case "contentType":
+			p.ContentType = fmt.Sprint(v)
+		case "contentEncoding":
+			p.ContentEncoding = fmt.Sprint(v)
+		case "headers":
+			var ok bool
+			if p.Headers, ok = v.(map[string]interface{}); !ok {
+				return p, fmt.Errorf("the headers param needs to be an object, got '%#v'", v)
+			}
+		case "persistent":
+			persistent, ok := v.(bool)
+			if !ok {
+				return p, errors.New("the persistent param needs to be a boolean")
+			}
+			p.DeliveryMode = 1
+			if persistent {
+				p.DeliveryMode = 2
+			}
+		case "priority":
+			priority, ok := v.(int64)
+			if !ok || priority < 0 || priority > 9 {
+				return p, fmt.Errorf("invalid priority '%#v', it needs to be from 0 to 9", v)
+			}
+			p.Priority = byte(priority)
+		case "correlationId":
+			p.CorrelationID = fmt.Sprint(v)
+		case "replyTo":
+			p.ReplyTo = fmt.Sprint(v)
+		case "expiration":
+			p.Expiration = fmt.Sprint(v)
+		case "messageId":
+			p.MessageID = fmt.Sprint(v)
+		case "type":
+			p.Type = fmt.Sprint(v)
+		case "appId":
+			p.AppID = fmt.Sprint(v)
+		case "tags":
+			tags, ok := v.(map[string]interface{})
+			if !ok {
+				return p, fmt.Errorf("metric tags must be an object of string values, got '%#v'", v)
+			}
+			p.Tags = make(map[string]string, len(tags))
+			for name, tag := range tags {
+				p.Tags[name] = fmt.Sprint(tag)
+			}
+		default:
+			return p, fmt.Errorf("unknown publish param: %q", k)
+		}
+	}
+	return p, nil
+}
+
+// Publish publishes a message to an exchange, the default one being "". With the confirms of the connection,
+// it waits for the broker to confirm it.
+func (c *Client) Publish(exchange, routingKey string, body interface{}, params map[string]interface{}) error {
+	data, err := common.ToBytes(body)
+	if err != nil {
+		return err
+	}
+	p, err := parsePublishParams(params)
+	if err != nil {
+		return err
+	}
+	if err = c.open(); err != nil {
+		return err
+	}
+	p.Timestamp = time.Now()
+
+	e := newMethod(methodBasicPublish)
+	e.uint16(0)
+	e.shortString(exchange)
+	e.shortString(routingKey)
+	e.bit(false) // mandatory
+	e.bit(false) // immediate
+	b := appendFrame(nil, frameMethod, c.channel, e.b)
+	b = appendFrame(b, frameHeader, c.channel, encodeHeader(len(data), &p.properties))
+	// the body frames have to fit in the max size of the frames, with their header and end
+	for maxBody := c.frameMax - 8; len(data) > 0; {
+		n := len(data)
+		if n > maxBody {
+			n = maxBody
+		}
+		b = appendFrame(b, frameBody, c.channel, data[:n])
+		data = data[n:]
+	}
+
+	tags := c.metricTags(p.Tags, "exchange", exchange)
+	start := time.Now()
+	if err = c.write(b); err != nil {
+		return err
+	}
+	builtinMetrics := c.vu.State().BuiltinMetrics
+	if c.confirm {
+		c.publishSeq++
+		if err = c.waitConfirm(c.publishSeq); err != nil {
+			return err
+		}
+		c.push(builtinMetrics.AMQPPublishConfirmDuration, stats.D(time.Since(start)), tags)
+	}
+	c.push(builtinMetrics.AMQPMessagesPublished, 1, tags)
+	return nil
+}
+
+// waitConfirm waits for the broker to acknowledge the message of a sequence number.
+func (c *Client) waitConfirm(seq uint64) error {
+	deadline := time.Now().Add(c.timeout)
+	for {
+		f, err := c.next(deadline)
+		if err != nil {
+			return err
+		}
+		method, d := f.method()
+		if method != methodBasicAck && method != methodBasicNack {
+			continue
+		}
+		tag, multiple := d.uint64(), d.bit()
+		if tag != seq && !(multiple && tag > seq) {
+			continue
+		}
+		if method == methodBasicNack {
+			return errors.New("the broker rejected the message")
+		}
+		return nil
+	}
+}
+
+// Consume consumes the messages of a queue, it waits for them until it has the limit of messages in its params,
+// 1 by default, or for its maxWait, 1s by default. The messages are acknowledged before they're returned.
+func (c *Client) Consume(queue string, params map[string]interface{}) ([]map[string]interface{}, error) {
+	limit, maxWait := int64(1), defaultMaxWait
+	var tags map[string]string
+	var err error
+	for k, v := range params {
+		switch k {
+		case "limit":
+			var ok bool
+			if limit, ok = v.(int64); !ok || limit <= 0 || limit > 0xffff {
+				return nil, fmt.Errorf("invalid limit '%#v'", v)
+			}
+		case "maxWait":
+			if maxWait, err = types.GetDurationValue(v); err != nil || maxWait <= 0 {
+				return nil, fmt.Errorf("invalid maxWait value '%#v'", v)
+			}
+		case "tags":
+			rawTags, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("metric tags must be an object of string values, got '%#v'", v)
+			}
+			tags = make(map[string]string, len(rawTags))
+			for name, tag := range rawTags {
+				tags[name] = fmt.Sprint(tag)
+			}
+		default:
+			return nil, fmt.Errorf("unknown consume param: %q", k)
+		}
+	}
+	if err = c.open(); err != nil {
+		return nil, err
+	}
+
+	// the broker delivers at most limit messages, as they aren't acknowledged until the consumer is cancelled
+	e := newMethod(methodBasicQos)
+	e.uint32(0)
+	e.uint16(uint16(limit))
+	e.bit(false)
+	if _, err = c.call(e, methodBasicQosOk); err != nil {
+		return nil, err
+	}
+	e = newMethod(methodBasicConsume)
+	e.uint16(0)
+	e.shortString(queue)
+	e.shortString("")
+	e.bit(false) // no-local
+	e.bit(false) // no-ack
+	e.bit(false) // exclusive
+	e.bit(false) // no-wait
+	e.table(nil)
+	d, err := c.call(e, methodBasicConsumeOk)
+	if err != nil {
+		return nil, err
+	}
+	consumerTag := d.shortString()
+
+	var messages []map[string]interface{}
+	var lastTag uint64
+	deliver := func(deadline time.Time) (bool, error) {
+		m, tag, err := c.delivery(deadline)
+		if m != nil {
+			messages = append(messages, m)
+			lastTag = tag
+		}
+		return m != nil, err
+	}
+	for deadline := time.Now().Add(maxWait); len(messages) < int(limit); {
+		if _, err = deliver(deadline); err != nil {
+			break
+		}
+	}
+	if !errors.Is(err, errTimeout) && err != nil {
+		return nil, err
+	}
+
+	// the messages delivered before the cancellation are kept, the prefetch limit doesn't allow any more
+	e = newMethod(methodBasicCancel)
+	e.shortString(consumerTag)
+	e.bit(false)
+	if err = c.write(appendFrame(nil, frameMethod, c.channel, e.b)); err != nil {
+		return nil, err
+	}
+	for deadline := time.Now().Add(c.timeout); ; {
+		var ok bool
+		if ok, err = deliver(deadline); err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+	}
+	if lastTag != 0 {
+		e = newMethod(methodBasicAck)
+		e.uint64(lastTag)
+		e.bit(true) // multiple
+		if err = c.write(appendFrame(nil, frameMethod, c.channel, e.b)); err != nil {
+			return nil, err
+		}
+		c.push(c.vu.State().BuiltinMetrics.AMQPMessagesConsumed, float64(len(messages)),
+			c.metricTags(tags, "queue", queue))
+	}
+	return messages, nil
+}
+
+// delivery returns the next delivered message with its delivery tag, or nil after the basic.cancel-ok.
+func (c *Client) delivery(deadline time.Time) (map[string]interface{}, uint64, error) {
+	for {
+		f, err := c.next(deadline)
+		if err != nil {
+			return nil, 0, err
+		}
+		method, d := f.method()
+		if method == methodBasicCancelOk {
+			return nil, 0, nil
+		}
+		if method != methodBasicDeliver {
+			continue
+		}
+		_ = d.shortString() // consumer tag
+		tag, redelivered := d.uint64(), d.bit()
+		exchange, routingKey := d.shortString(), d.shortString()
+		if d.err != nil {
+			return nil, 0, d.err
+		}
+
+		if f, err = c.next(deadline); err != nil {
+			return nil, 0, err
+		}
+		if f.kind != frameHeader {
+			return nil, 0, errMalformedFrame
+		}
+		size, p, err := decodeHeader(f.payload)
+		if err != nil {
+			return nil, 0, err
+		}
+		body := make([]byte, 0, size)
+		for uint64(len(body)) < size {
+			if f, err = c.next(deadline); err != nil {
+				return nil, 0, err
+			}
+			if f.kind != frameBody {
+				return nil, 0, errMalformedFrame
+			}
+			body = append(body, f.payload...)
+		}
+
+		m := map[string]interface{}{
+			"body":          string(body),
+			"exchange":      exchange,
+			"routingKey":    routingKey,
+			"redelivered":   redelivered,
+			"contentType":   p.ContentType,
+			"correlationId": p.CorrelationID,
+			"replyTo":       p.ReplyTo,
+			"messageId":     p.MessageID,
+			"type":          p.Type,
+			"appId":         p.AppID,
+			"headers":       p.Headers,
+		}
+		if p.Headers == nil {
+			m["headers"] = map[string]interface{}{}
+		}
+		if !p.Timestamp.IsZero() {
+			m["timestamp"] = p.Timestamp.UnixMilli()
+		}
+		return m, tag, nil
+	}
+}
+
+// Close closes the connection gracefully.
+func (c *Client) Close() error {
+	if c.conn == nil || c.closedErr() != nil {
+		return nil
+	}
+	e := newMethod(methodConnectionClose)
+	e.uint16(200)
+	e.shortString("")
+	e.uint16(0)
+	e.uint16(0)
+	err := c.write(appendFrame(nil, frameMethod, 0, e.b))
+	// the connection is closed by the broker, after its connection.close-ok
+	select {
+	case <-c.ctx.Done():
+	case <-time.After(c.timeout):
+	}
+	c.closeWith(errClosed)
+	return err
+}
+
+func (c *Client) metricTags(extra map[string]string, name, value string) map[string]string {
+	tags := make(map[string]string, len(c.tags)+len(extra)+1)
+	for k, v := range c.tags {
+		tags[k] = v
+	}
+	tags[name] = value
+	for k, v := range extra {
+		tags[k] = v
+	}
+	return tags
+}
+
+func (c *Client) push(metric *stats.Metric, value float64, tags map[string]string) {
+	stats.PushIfNotDone(c.vu.Context(), c.vu.State().Samples, stats.Sample{
+		Metric: metric,
+		Tags:   stats.IntoSampleTags(&tags),
+		Value:  value,
+		Time:   time.Now(),
+	})
+}
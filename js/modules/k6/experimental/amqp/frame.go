@@ -0,0 +1,484 @@
+package amqp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+// The types of the frames, see https://www.rabbitmq.com/resources/specs/amqp0-9-1.pdf
+const (
+	frameMethod    byte = 1
+	frameHeader    byte = 2
+	frameBody      byte = 3
+	frameHeartbeat byte = 8
+
+	frameEnd byte = 0xce
+)
+
+// The class and method IDs of the methods that are used, as a class<<16 | method.
+const (
+	methodConnectionStart   uint32 = 10<<16 | 10
+	methodConnectionStartOk uint32 = 10<<16 | 11
+	methodConnectionTune    uint32 = 10<<16 | 30
+	methodConnectionTuneOk  uint32 = 10<<16 | 31
+	methodConnectionOpen    uint32 = 10<<16 | 40
+	methodConnectionOpenOk  uint32 = 10<<16 | 41
+	methodConnectionClose   uint32 = 10<<16 | 50
+	methodConnectionCloseOk uint32 = 10<<16 | 51
+
+	methodChannelOpen    uint32 = 20<<16 | 10
+	methodChannelOpenOk  uint32 = 20<<16 | 11
+	methodChannelClose   uint32 = 20<<16 | 40
+	methodChannelCloseOk uint32 = 20<<16 | 41
+
+	methodExchangeDeclare   uint32 = 40<<16 | 10
+	methodExchangeDeclareOk uint32 = 40<<16 | 11
+
+	methodQueueDeclare   uint32 = 50<<16 | 10
+	methodQueueDeclareOk uint32 = 50<<16 | 11
+	methodQueueBind      uint32 = 50<<16 | 20
+	methodQueueBindOk    uint32 = 50<<16 | 21
+
+	methodBasicQos       uint32 = 60<<16 | 10
+	methodBasicQosOk     uint32 = 60<<16 | 11
+	methodBasicConsume   uint32 = 60<<16 | 20
+	methodBasicConsumeOk uint32 = 60<<16 | 21
+	methodBasicCancel    uint32 = 60<<16 | 30
+	methodBasicCancelOk  uint32 = 60<<16 | 31
+	methodBasicPublish   uint32 = 60<<16 | 40
+	methodBasicDeliver   uint32 = 60<<16 | 60
+	methodBasicAck       uint32 = 60<<16 | 80
+	methodBasicNack      uint32 = 60<<16 | 120
+
+	methodConfirmSelect   uint32 = 85<<16 | 10
+	methodConfirmSelectOk uint32 = 85<<16 | 11
+
+	classBasic uint16 = 60
+)
+
+var protocolHeader = []byte("AMQP\x00\x00\x09\x01") //nolint:gochecknoglobals
+
+var errMalformedFrame = errors.New("malformed AMQP frame")
+
+// frame is a frame of the protocol, with the payload that follows its header.
+type frame struct {
+	kind    byte
+	channel uint16
+	payload []byte
+}
+
+// method returns the method of a method frame, with the decoder of its arguments.
+func (f *frame) method() (uint32, *decoder) {
+	if f.kind != frameMethod || len(f.payload) < 4 {
+		return 0, nil
+	}
+	return binary.BigEndian.Uint32(f.payload), &decoder{b: f.payload[4:]}
+}
+
+func readFrame(r *bufio.Reader) (*frame, error) {
+	var header [7]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	f := &frame{kind: header[0], channel: binary.BigEndian.Uint16(header[1:])}
+	f.payload = make([]byte, binary.BigEndian.Uint32(header[3:])+1)
+	if _, err := io.ReadFull(r, f.payload); err != nil {
+		return nil, err
+	}
+	if f.payload[len(f.payload)-1] != frameEnd {
+		return nil, errMalformedFrame
+	}
+	f.payload = f.payload[:len(f.payload)-1]
+	return f, nil
+}
+
+// appendFrame appends the encoding of a frame to b.
+func appendFrame(b []byte, kind byte, channel uint16, payload []byte) []byte {
+	b = append(b, kind, byte(channel>>8), byte(channel))
+	b = append(b, byte(len(payload)>>24), byte(len(payload)>>16), byte(len(payload)>>8), byte(len(payload)))
+	b = append(b, payload...)
+	return append(b, frameEnd)
+}
+
+// encoder builds the arguments of a method.
+type encoder struct {
+	b []byte
+	// bits is the index of the octet of the last bit, the bits that follow each other are packed in octets
+	bits, nbits int
+}
+
+func newMethod(method uint32) *encoder {
+	e := &encoder{}
+	e.uint32(method)
+	return e
+}
+
+func (e *encoder) octet(v byte) {
+	e.nbits = 0
+	e.b = append(e.b, v)
+}
+
+func (e *encoder) uint16(v uint16) {
+	e.nbits = 0
+	e.b = append(e.b, byte(v>>8), byte(v))
+}
+
+func (e *encoder) uint32(v uint32) {
+	e.nbits = 0
+	e.b = append(e.b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func (e *encoder) uint64(v uint64) {
+	e.uint32(uint32(v >> 32))
+	e.uint32(uint32(v))
+}
+
+func (e *encoder) bit(v bool) {
+	if e.nbits == 0 || e.nbits == 8 {
+		e.b = append(e.b, 0)
+		e.bits, e.nbits = len(e.b)-1, 0
+	}
+	if v {
+		e.b[e.bits] |= 1 << e.nbits
+	}
+	e.nbits++
+}
+
+func (e *encoder) shortString(s string) {
+	e.octet(byte(len(s)))
+	e.b = append(e.b, s[:len(s)&0xff]...)
+}
+
+func (e *encoder) longString(s string) {
+	e.uint32(uint32(len(s)))
+	e.b = append(e.b, s...)
+}
+
+// table writes a field table, with its fields sorted by name.
+func (e *encoder) table(t map[string]interface{}) {
+	var te encoder
+	names := make([]string, 0, len(t))
+	for name := range t {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		te.shortString(name)
+		te.field(t[name])
+	}
+	e.longString(string(te.b))
+}
+
+func (e *encoder) field(v interface{}) {
+	switch v := v.(type) {
+	case nil:
+		e.octet('V')
+	case bool:
+		e.octet('t')
+		if v {
+			e.octet(1)
+		} else {
+			e.octet(0)
+		}
+	case int64:
+		e.octet('l')
+		e.uint64(uint64(v))
+	case int:
+		e.field(int64(v))
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			e.field(int64(v))
+			return
+		}
+		e.octet('d')
+		e.uint64(math.Float64bits(v))
+	case map[string]interface{}:
+		e.octet('F')
+		e.table(v)
+	case []interface{}:
+		var ae encoder
+		for _, item := range v {
+			ae.field(item)
+		}
+		e.octet('A')
+		e.longString(string(ae.b))
+	default:
+		e.octet('S')
+		e.longString(fmt.Sprint(v))
+	}
+}
+
+// decoder reads the arguments of a method, a read past the end sets err and returns zero values.
+type decoder struct {
+	b   []byte
+	err error
+	// bits is the octet of the bits being read, with nbits of them already read
+	bits, nbits byte
+}
+
+func (d *decoder) take(n int) []byte {
+	d.nbits = 0
+	if d.err != nil || n < 0 || len(d.b) < n {
+		d.err = errMalformedFrame
+		return nil
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) octet() byte {
+	if b := d.take(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *decoder) uint16() uint16 {
+	if b := d.take(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (d *decoder) uint32() uint32 {
+	if b := d.take(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (d *decoder) uint64() uint64 {
+	if b := d.take(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (d *decoder) bit() bool {
+	if d.nbits == 0 || d.nbits == 8 {
+		d.bits = d.octet()
+	}
+	v := d.bits&(1<<d.nbits) != 0
+	d.nbits++
+	return v
+}
+
+func (d *decoder) shortString() string {
+	return string(d.take(int(d.octet())))
+}
+
+func (d *decoder) longString() string {
+	return string(d.take(int(d.uint32())))
+}
+
+func (d *decoder) table() map[string]interface{} {
+	td := decoder{b: d.take(int(d.uint32()))}
+	t := make(map[string]interface{})
+	for len(td.b) > 0 && td.err == nil {
+		name := td.shortString()
+		t[name] = td.field()
+	}
+	if td.err != nil {
+		d.err = td.err
+	}
+	return t
+}
+
+func (d *decoder) field() interface{} {
+	switch kind := d.octet(); kind {
+	case 't':
+		return d.octet() != 0
+	case 'b':
+		return int64(int8(d.octet()))
+	case 'B':
+		return int64(d.octet())
+	case 's':
+		return int64(int16(d.uint16()))
+	case 'u':
+		return int64(d.uint16())
+	case 'I':
+		return int64(int32(d.uint32()))
+	case 'i':
+		return int64(d.uint32())
+	case 'l':
+		return int64(d.uint64())
+	case 'f':
+		return float64(math.Float32frombits(d.uint32()))
+	case 'd':
+		return math.Float64frombits(d.uint64())
+	case 'D':
+		scale, v := d.octet(), int32(d.uint32())
+		return float64(v) / math.Pow10(int(scale))
+	case 'S', 'x':
+		return d.longString()
+	case 'T':
+		return time.Unix(int64(d.uint64()), 0)
+	case 'F':
+		return d.table()
+	case 'A':
+		ad := decoder{b: d.take(int(d.uint32()))}
+		var a []interface{}
+		for len(ad.b) > 0 && ad.err == nil {
+			a = append(a, ad.field())
+		}
+		if ad.err != nil {
+			d.err = ad.err
+		}
+		return a
+	case 'V':
+		return nil
+	default:
+		if d.err == nil {
+			d.err = fmt.Errorf("%w: unknown field type %q", errMalformedFrame, kind)
+		}
+		return nil
+	}
+}
+
+// properties are the basic properties of a message, in its content header.
+type properties struct {
+	ContentType     string
+	ContentEncoding string
+	Headers         map[string]interface{}
+	DeliveryMode    byte
+	Priority        byte
+	CorrelationID   string
+	ReplyTo         string
+	Expiration      string
+	MessageID       string
+	Timestamp       time.Time
+	Type            string
+	UserID          string
+	AppID           string
+}
+
+// The flags of the properties that are set, in the order of the properties.
+const (
+	flagContentType uint16 = 1 << (15 - iota)
+	flagContentEncoding
+	flagHeaders
+	flagDeliveryMode
+	flagPriority
+	flagCorrelationID
+	flagReplyTo
+	flagExpiration
+	flagMessageID
+	flagTimestamp
+	flagType
+	flagUserID
+	flagAppID
+)
+
+// encodeHeader encodes the payload of the content header of a message.
+func encodeHeader(size int, p *properties) []byte {
+	var flags uint16
+	var e encoder
+	shortStrings := []struct {
+		flag uint16
+		v    string
+	}{
+		{flagContentType, p.ContentType},
+		{flagContentEncoding, p.ContentEncoding},
+	}
+	for _, s := range shortStrings {
+		if s.v != "" {
+			flags |= s.flag
+			e.shortString(s.v)
+		}
+	}
+	if len(p.Headers) > 0 {
+		flags |= flagHeaders
+		e.table(p.Headers)
+	}
+	if p.DeliveryMode != 0 {
+		flags |= flagDeliveryMode
+		e.octet(p.DeliveryMode)
+	}
+	if p.Priority != 0 {
+		flags |= flagPriority
+		e.octet(p.Priority)
+	}
+	shortStrings = []struct {
+		flag uint16
+		v    string
+	}{
+		{flagCorrelationID, p.CorrelationID},
+		{flagReplyTo, p.ReplyTo},
+		{flagExpiration, p.Expiration},
+		{flagMessageID, p.MessageID},
+	}
+	for _, s := range shortStrings {
+		if s.v != "" {
+			flags |= s.flag
+			e.shortString(s.v)
+		}
+	}
+	if !p.Timestamp.IsZero() {
+		flags |= flagTimestamp
+		e.uint64(uint64(p.Timestamp.Unix()))
+	}
+	shortStrings = []struct {
+		flag uint16
+		v    string
+	}{
+		{flagType, p.Type},
+		{flagUserID, p.UserID},
+		{flagAppID, p.AppID},
+	}
+	for _, s := range shortStrings {
+		if s.v != "" {
+			flags |= s.flag
+			e.shortString(s.v)
+		}
+	}
+
+	header := &encoder{}
+	header.uint16(classBasic)
+	header.uint16(0) // weight
+	header.uint64(uint64(size))
+	header.uint16(flags)
+	header.b = append(header.b, e.b...)
+	return header.b
+}
+
+// decodeHeader decodes the payload of the content header of a message, it returns the size of its body.
+func decodeHeader(b []byte) (uint64, *properties, error) {
+	d := decoder{b: b}
+	_, _ = d.uint16(), d.uint16() // class and weight
+	size, flags := d.uint64(), d.uint16()
+	p := &properties{}
+	shortString := func(flag uint16, v *string) {
+		if flags&flag != 0 {
+			*v = d.shortString()
+		}
+	}
+	shortString(flagContentType, &p.ContentType)
+	shortString(flagContentEncoding, &p.ContentEncoding)
+	if flags&flagHeaders != 0 {
+		p.Headers = d.table()
+	}
+	if flags&flagDeliveryMode != 0 {
+		p.DeliveryMode = d.octet()
+	}
+	if flags&flagPriority != 0 {
+		p.Priority = d.octet()
+	}
+	shortString(flagCorrelationID, &p.CorrelationID)
+	shortString(flagReplyTo, &p.ReplyTo)
+	shortString(flagExpiration, &p.Expiration)
+	shortString(flagMessageID, &p.MessageID)
+	if flags&flagTimestamp != 0 {
+		p.Timestamp = time.Unix(int64(d.uint64()), 0)
+	}
+	shortString(flagType, &p.Type)
+	shortString(flagUserID, &p.UserID)
+	shortString(flagAppID, &p.AppID)
+	return size, p, d.err
+}
@@ -0,0 +1,282 @@
+// Package dns implements the k6/experimental/dns module, with DNS lookups against a resolver over UDP,
+// TCP, DNS over TLS or DNS over HTTPS, to load test the DNS servers themselves.
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	"golang.org/x/net/dns/dnsmessage"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/stats"
+)
+
+type (
+	// RootModule is the global module instance that will create module
+	// instances for each VU.
+	RootModule struct{}
+
+	// ModuleInstance represents an instance of the dns module for every VU.
+	ModuleInstance struct {
+		vu modules.VU
+	}
+)
+
+var (
+	_ modules.Module   = &RootModule{}
+	_ modules.Instance = &ModuleInstance{}
+)
+
+// New returns a pointer to a new RootModule instance.
+func New() *RootModule {
+	return &RootModule{}
+}
+
+// NewModuleInstance implements the modules.Module interface to return
+// a new instance for each VU.
+func (*RootModule) NewModuleInstance(vu modules.VU) modules.Instance {
+	return &ModuleInstance{vu: vu}
+}
+
+// Exports returns the exports of the dns module.
+func (mi *ModuleInstance) Exports() modules.Exports {
+	return modules.Exports{
+		Named: map[string]interface{}{
+			"Resolver": mi.NewResolver,
+		},
+	}
+}
+
+// NewResolver is the JS constructor for the dns Resolver.
+func (mi *ModuleInstance) NewResolver(call goja.ConstructorCall) *goja.Object {
+	rt := mi.vu.Runtime()
+	var params map[string]interface{}
+	if v := call.Argument(1); !goja.IsUndefined(v) && !goja.IsNull(v) {
+		if err := rt.ExportTo(v, &params); err != nil {
+			common.Throw(rt, fmt.Errorf("the Resolver params need to be an object: %w", err))
+		}
+	}
+	r, err := newResolver(call.Argument(0).String(), params)
+	if err != nil {
+		common.Throw(rt, err)
+	}
+	r.vu = mi.vu
+	return rt.ToValue(r).ToObject(rt)
+}
+
+const (
+	defaultTimeout = 5 * time.Second
+	maxMessageSize = 65535
+)
+
+var (
+	errLookupInInitContext = common.NewInitContextError("DNS lookups in the init context are not supported")
+	errTimeout             = errors.New("the DNS lookup timed out")
+)
+
+//nolint:gochecknoglobals
+var (
+	recordTypes = map[string]dnsmessage.Type{
+		"A":    dnsmessage.TypeA,
+		"AAAA": dnsmessage.TypeAAAA,
+		"SRV":  dnsmessage.TypeSRV,
+		"TXT":  dnsmessage.TypeTXT,
+	}
+	recordTypeNames = map[dnsmessage.Type]string{
+		dnsmessage.TypeA:     "A",
+		dnsmessage.TypeAAAA:  "AAAA",
+		dnsmessage.TypeSRV:   "SRV",
+		dnsmessage.TypeTXT:   "TXT",
+		dnsmessage.TypeCNAME: "CNAME",
+	}
+	rcodeNames = map[dnsmessage.RCode]string{
+		dnsmessage.RCodeSuccess:        "NOERROR",
+		dnsmessage.RCodeFormatError:    "FORMERR",
+		dnsmessage.RCodeServerFailure:  "SERVFAIL",
+		dnsmessage.RCodeNameError:      "NXDOMAIN",
+		dnsmessage.RCodeNotImplemented: "NOTIMP",
+		dnsmessage.RCodeRefused:        "REFUSED",
+	}
+)
+
+func rcodeName(rcode dnsmessage.RCode) string {
+	if name, ok := rcodeNames[rcode]; ok {
+		return name
+	}
+	return fmt.Sprintf("RCODE%d", rcode)
+}
+
+// Resolver sends its lookups to a DNS server, with a new connection for every lookup.
+type Resolver struct {
+	vu modules.VU
+
+	// server is the URL of the server, its scheme is udp, tcp, tls or https
+	server  *url.URL
+	addr    string
+	timeout time.Duration
+	tags    map[string]string
+}
+
+// newResolver returns a Resolver for the server, which is a host:port address of a server over
+// UDP, or a udp://, tcp://, tls:// or https:// URL.
+func newResolver(server string, params map[string]interface{}) (*Resolver, error) {
+	if !strings.Contains(server, "://") {
+		server = "udp://" + server
+	}
+	u, err := url.Parse(server)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DNS server %q: %w", server, err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid DNS server %q, it needs a host", server)
+	}
+	r := &Resolver{server: u, timeout: defaultTimeout}
+	port := u.Port()
+	switch u.Scheme {
+	case "udp", "tcp":
+		if port == "" {
+			port = "53"
+		}
+	case "tls":
+		if port == "" {
+			port = "853"
+		}
+	case "https":
+	default:
+		return nil, fmt.Errorf("invalid DNS server scheme %q, it needs to be udp, tcp, tls or https", u.Scheme)
+	}
+	r.addr = net.JoinHostPort(u.Hostname(), port)
+
+	for k, v := range params {
+		switch k {
+		case "timeout":
+			r.timeout, err = types.GetDurationValue(v)
+			if err != nil || r.timeout <= 0 {
+				return nil, fmt.Errorf("invalid timeout value '%#v'", v)
+			}
+		case "tags":
+			tags, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("metric tags must be an object of string values, got '%#v'", v)
+			}
+			r.tags = make(map[string]string, len(tags))
+			for name, tag := range tags {
+				r.tags[name] = fmt.Sprint(tag)
+			}
+		default:
+			return nil, fmt.Errorf("unknown Resolver param: %q", k)
+		}
+	}
+	return r, nil
+}
+
+// Response is the response of the server to a lookup.
+type Response struct {
+	Rcode   string                   `js:"rcode"`
+	Answers []map[string]interface{} `js:"answers"`
+}
+
+// Lookup looks up the records of the type, A, AAAA, SRV or TXT, of the name. The responses with
+// an error response code, like NXDOMAIN, are returned, only the failed exchanges throw.
+func (r *Resolver) Lookup(name, recordType string) (*Response, error) {
+	state := r.vu.State()
+	if state == nil {
+		return nil, errLookupInInitContext
+	}
+	qtype, ok := recordTypes[strings.ToUpper(recordType)]
+	if !ok {
+		return nil, fmt.Errorf("invalid record type %q, it needs to be A, AAAA, SRV or TXT", recordType)
+	}
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	qname, err := dnsmessage.NewName(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid name %q: %w", name, err)
+	}
+	query, id, err := r.newQuery(qname, qtype)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := state.CloneTags()
+	if state.Options.SystemTags.Has(stats.TagURL) {
+		tags["url"] = r.server.String()
+	}
+	tags["type"] = strings.ToUpper(recordType)
+	for k, v := range r.tags {
+		tags[k] = v
+	}
+
+	ctx, cancel := context.WithTimeout(r.vu.Context(), r.timeout)
+	defer cancel()
+	start := time.Now()
+	resp, err := r.exchange(ctx, query, id)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = errTimeout
+		}
+		r.push(state.BuiltinMetrics.DNSLookupFailed, 1, tags)
+		return nil, err
+	}
+	tags["rcode"] = rcodeName(resp.RCode)
+	r.push(state.BuiltinMetrics.DNSLookupDuration, stats.D(time.Since(start)), tags)
+	r.push(state.BuiltinMetrics.DNSLookupFailed, stats.B(resp.RCode != dnsmessage.RCodeSuccess), tags)
+
+	res := &Response{Rcode: rcodeName(resp.RCode), Answers: make([]map[string]interface{}, 0, len(resp.Answers))}
+	for _, answer := range resp.Answers {
+		res.Answers = append(res.Answers, record(answer))
+	}
+	return res, nil
+}
+
+// record returns the JS object of a resource record.
+func record(res dnsmessage.Resource) map[string]interface{} {
+	rec := map[string]interface{}{
+		"name": res.Header.Name.String(),
+		"ttl":  res.Header.TTL,
+	}
+	if name, ok := recordTypeNames[res.Header.Type]; ok {
+		rec["type"] = name
+	} else {
+		rec["type"] = fmt.Sprintf("TYPE%d", res.Header.Type)
+	}
+	switch body := res.Body.(type) {
+	case *dnsmessage.AResource:
+		rec["address"] = net.IP(body.A[:]).String()
+	case *dnsmessage.AAAAResource:
+		rec["address"] = net.IP(body.AAAA[:]).String()
+	case *dnsmessage.CNAMEResource:
+		rec["target"] = body.CNAME.String()
+	case *dnsmessage.SRVResource:
+		rec["target"] = body.Target.String()
+		rec["port"] = body.Port
+		rec["priority"] = body.Priority
+		rec["weight"] = body.Weight
+	case *dnsmessage.TXTResource:
+		rec["texts"] = body.TXT
+	}
+	return rec
+}
+
+func (r *Resolver) push(metric *stats.Metric, value float64, tags map[string]string) {
+	sampleTags := make(map[string]string, len(tags))
+	for k, v := range tags {
+		sampleTags[k] = v
+	}
+	stats.PushIfNotDone(r.vu.Context(), r.vu.State().Samples, stats.Sample{
+		Metric: metric,
+		Tags:   stats.IntoSampleTags(&sampleTags),
+		Value:  value,
+		Time:   time.Now(),
+	})
+}
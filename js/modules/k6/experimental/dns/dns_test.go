@@ -0,0 +1,299 @@
+package dns
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/lib/testutils/httpmultibin"
+	"go.k6.io/k6/stats"
+)
+
+func newTestVU(t *testing.T) (*httpmultibin.HTTPMultiBin, *modulestest.VU, chan stats.SampleContainer) {
+	t.Helper()
+	tb := httpmultibin.NewHTTPMultiBin(t)
+	vu, samples := modulestest.NewTestVU(t, tb, stats.TagURL)
+	m, ok := New().NewModuleInstance(vu).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, vu.Runtime().Set("dns", m.Exports().Named))
+	return tb, vu, samples
+}
+
+// answer is the handler of the test servers, the TXT records of big.example.com are truncated over UDP.
+func answer(t *testing.T, query []byte, udp bool) []byte {
+	var msg dnsmessage.Message
+	require.NoError(t, msg.Unpack(query))
+	q := msg.Questions[0]
+	resp := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: msg.ID, Response: true, RecursionAvailable: true},
+		Questions: msg.Questions,
+	}
+	header := dnsmessage.ResourceHeader{Name: q.Name, Type: q.Type, Class: dnsmessage.ClassINET, TTL: 300}
+	switch name := q.Name.String(); {
+	case name == "slow.example.com.":
+		time.Sleep(time.Second)
+	case name == "missing.example.com.":
+		resp.RCode = dnsmessage.RCodeNameError
+	case name == "big.example.com." && udp:
+		resp.Truncated = true
+	case q.Type == dnsmessage.TypeA:
+		a := [4]byte{10, 0, 0, 1}
+		resp.Answers = append(resp.Answers, dnsmessage.Resource{Header: header, Body: &dnsmessage.AResource{A: a}})
+	case q.Type == dnsmessage.TypeAAAA:
+		aaaa := [16]byte{0x20, 0x01, 0x0d, 0xb8, 15: 1}
+		resp.Answers = append(resp.Answers, dnsmessage.Resource{Header: header, Body: &dnsmessage.AAAAResource{AAAA: aaaa}})
+	case q.Type == dnsmessage.TypeSRV:
+		target := dnsmessage.MustNewName("sip.example.com.")
+		resp.Answers = append(resp.Answers, dnsmessage.Resource{Header: header, Body: &dnsmessage.SRVResource{
+			Priority: 10, Weight: 5, Port: 5060, Target: target,
+		}})
+	case q.Type == dnsmessage.TypeTXT:
+		resp.Answers = append(resp.Answers, dnsmessage.Resource{Header: header, Body: &dnsmessage.TXTResource{
+			TXT: []string{"v=spf1 -all", strings.Repeat("x", 200)},
+		}})
+	}
+	b, err := resp.Pack()
+	require.NoError(t, err)
+	return b
+}
+
+// newUDPServer starts a DNS server over UDP and returns its address.
+func newUDPServer(t *testing.T) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	go func() {
+		buf := make([]byte, maxMessageSize)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			query := append([]byte{}, buf[:n]...)
+			go func() { _, _ = conn.WriteTo(answer(t, query, true), addr) }()
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+// newStreamServer starts a DNS server over TCP, or TLS if it has a TLS config, and returns its address.
+func newStreamServer(t *testing.T, tlsConfig *tls.Config) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	return serveStream(t, ln, tlsConfig)
+}
+
+func serveStream(t *testing.T, ln net.Listener, tlsConfig *tls.Config) string {
+	t.Helper()
+	if tlsConfig != nil {
+		ln = tls.NewListener(ln, tlsConfig)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer func() { _ = conn.Close() }()
+				var length [2]byte
+				if _, err := io.ReadFull(conn, length[:]); err != nil {
+					return
+				}
+				query := make([]byte, binary.BigEndian.Uint16(length[:]))
+				if _, err := io.ReadFull(conn, query); err != nil {
+					return
+				}
+				resp := answer(t, query, false)
+				binary.BigEndian.PutUint16(length[:], uint16(len(resp)))
+				_, _ = conn.Write(append(length[:], resp...))
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestResolver(t *testing.T) {
+	t.Parallel()
+
+	t.Run("UDP", func(t *testing.T) {
+		t.Parallel()
+		_, vu, samples := newTestVU(t)
+		addr := newUDPServer(t)
+
+		_, err := vu.Runtime().RunString(`
+			var resolver = new dns.Resolver("` + addr + `", { tags: { tag: "value" } });
+			var a = resolver.lookup("example.com", "A");
+			var aaaa = resolver.lookup("example.com", "aaaa");
+			var srv = resolver.lookup("_sip._udp.example.com", "SRV").answers[0];
+			var missing = resolver.lookup("missing.example.com", "A");
+		`)
+		require.NoError(t, err)
+
+		v, err := vu.Runtime().RunString(`[
+			a.rcode, a.answers[0].name, a.answers[0].type, a.answers[0].ttl, a.answers[0].address,
+			aaaa.answers[0].address,
+			srv.target, srv.port, srv.priority, srv.weight,
+			missing.rcode, missing.answers.length,
+		].join("|")`)
+		require.NoError(t, err)
+		assert.Equal(t, "NOERROR|example.com.|A|300|10.0.0.1|2001:db8::1|sip.example.com.|5060|10|5|NXDOMAIN|0", v.String())
+
+		var durations int
+		failed := map[string]float64{}
+		for _, c := range stats.GetBufferedSamples(samples) {
+			for _, s := range c.GetSamples() {
+				url, _ := s.Tags.Get("url")
+				assert.Equal(t, "udp://"+addr, url)
+				tag, _ := s.Tags.Get("tag")
+				assert.Equal(t, "value", tag)
+				switch s.Metric.Name {
+				case metrics.DNSLookupDurationName:
+					durations++
+				case metrics.DNSLookupFailedName:
+					rcode, _ := s.Tags.Get("rcode")
+					recordType, _ := s.Tags.Get("type")
+					failed[recordType+" "+rcode] += s.Value
+				}
+			}
+		}
+		assert.Equal(t, 4, durations)
+		assert.Equal(t, map[string]float64{"A NOERROR": 0, "AAAA NOERROR": 0, "SRV NOERROR": 0, "A NXDOMAIN": 1}, failed)
+	})
+
+	t.Run("Truncated", func(t *testing.T) {
+		t.Parallel()
+		_, vu, _ := newTestVU(t)
+		addr := newUDPServer(t)
+		// the retry over TCP goes to the same address
+		_, port, err := net.SplitHostPort(addr)
+		require.NoError(t, err)
+		ln, err := net.Listen("tcp", "127.0.0.1:"+port)
+		if err != nil {
+			t.Skip("the TCP port of the UDP server isn't free")
+		}
+		serveStream(t, ln, nil)
+
+		_, err = vu.Runtime().RunString(`
+			var txt = new dns.Resolver("udp://` + addr + `").lookup("big.example.com", "TXT");
+		`)
+		require.NoError(t, err)
+		v, err := vu.Runtime().RunString(`txt.answers[0].texts[0] + "|" + txt.answers[0].texts[1].length`)
+		require.NoError(t, err)
+		assert.Equal(t, "v=spf1 -all|200", v.String())
+	})
+
+	t.Run("TCP", func(t *testing.T) {
+		t.Parallel()
+		_, vu, _ := newTestVU(t)
+		addr := newStreamServer(t, nil)
+
+		_, err := vu.Runtime().RunString(`
+			var res = new dns.Resolver("tcp://` + addr + `").lookup("example.com.", "A");
+		`)
+		require.NoError(t, err)
+		v, err := vu.Runtime().RunString(`res.answers[0].address`)
+		require.NoError(t, err)
+		assert.Equal(t, "10.0.0.1", v.String())
+	})
+
+	t.Run("TLS", func(t *testing.T) {
+		t.Parallel()
+		tb, vu, _ := newTestVU(t)
+		addr := newStreamServer(t, tb.ServerHTTPS.TLS)
+
+		_, err := vu.Runtime().RunString(`
+			var res = new dns.Resolver("tls://` + addr + `").lookup("example.com", "TXT");
+		`)
+		require.NoError(t, err)
+		v, err := vu.Runtime().RunString(`res.answers[0].texts[0]`)
+		require.NoError(t, err)
+		assert.Equal(t, "v=spf1 -all", v.String())
+	})
+
+	t.Run("HTTPS", func(t *testing.T) {
+		t.Parallel()
+		tb, vu, _ := newTestVU(t)
+		tb.Mux.HandleFunc("/dns-query", func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "application/dns-message", r.Header.Get("Content-Type"))
+			query, err := ioutil.ReadAll(r.Body)
+			require.NoError(t, err)
+			w.Header().Set("Content-Type", "application/dns-message")
+			_, _ = w.Write(answer(t, query, false))
+		})
+
+		_, err := vu.Runtime().RunString(tb.Replacer.Replace(`
+			var res = new dns.Resolver("HTTPSBIN_URL/dns-query").lookup("example.com", "AAAA");
+		`))
+		require.NoError(t, err)
+		v, err := vu.Runtime().RunString(`res.answers[0].address`)
+		require.NoError(t, err)
+		assert.Equal(t, "2001:db8::1", v.String())
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		t.Parallel()
+		_, vu, samples := newTestVU(t)
+		addr := newUDPServer(t)
+
+		_, err := vu.Runtime().RunString(`
+			new dns.Resolver("` + addr + `", { timeout: "50ms" }).lookup("slow.example.com", "A");
+		`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "the DNS lookup timed out")
+
+		var failed float64
+		for _, c := range stats.GetBufferedSamples(samples) {
+			for _, s := range c.GetSamples() {
+				require.Equal(t, metrics.DNSLookupFailedName, s.Metric.Name)
+				failed += s.Value
+			}
+		}
+		assert.Equal(t, float64(1), failed)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		t.Parallel()
+		_, vu, _ := newTestVU(t)
+
+		tests := map[string]string{
+			`new dns.Resolver("quic://127.0.0.1")`:                        "invalid DNS server scheme",
+			`new dns.Resolver("udp://")`:                                  "it needs a host",
+			`new dns.Resolver("127.0.0.1", { retries: 2 })`:               "unknown Resolver param",
+			`new dns.Resolver("127.0.0.1", { timeout: "soon" })`:          "invalid timeout value",
+			`new dns.Resolver("127.0.0.1").lookup("example.com", "MX")`:   "invalid record type",
+			`new dns.Resolver("127.0.0.1").lookup("a..example.com", "A")`: "invalid name",
+			`new dns.Resolver("127.0.0.1", { tags: "tag" })`:              "metric tags must be an object",
+		}
+		for script, msg := range tests {
+			_, err := vu.Runtime().RunString(script)
+			require.Error(t, err, script)
+			assert.Contains(t, err.Error(), msg, script)
+		}
+	})
+
+	t.Run("InitContext", func(t *testing.T) {
+		t.Parallel()
+		_, vu, _ := newTestVU(t)
+		vu.StateField = nil
+
+		_, err := vu.Runtime().RunString(`new dns.Resolver("127.0.0.1").lookup("example.com", "A")`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "DNS lookups in the init context are not supported")
+	})
+}
@@ -0,0 +1,176 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/netext"
+)
+
+// newQuery returns the packed query for the records of the type of the name, and its ID.
+func (r *Resolver) newQuery(name dnsmessage.Name, qtype dnsmessage.Type) ([]byte, uint16, error) {
+	var id uint16
+	if r.server.Scheme != "https" {
+		// DoH uses 0, so that the responses can be cached by HTTP
+		var b [2]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			return nil, 0, err
+		}
+		id = binary.BigEndian.Uint16(b[:])
+	}
+	query := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: name, Type: qtype, Class: dnsmessage.ClassINET}},
+	}
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid name %q: %w", name.String(), err)
+	}
+	return packed, id, nil
+}
+
+// exchange sends the query to the server and returns its response. Over UDP, the query is sent again
+// over TCP if the response is truncated.
+func (r *Resolver) exchange(ctx context.Context, packed []byte, id uint16) (*dnsmessage.Message, error) {
+	var (
+		resp *dnsmessage.Message
+		err  error
+	)
+	state := r.vu.State()
+	switch r.server.Scheme {
+	case "udp":
+		resp, err = r.exchangeUDP(ctx, state, packed, id)
+		if err == nil && resp.Truncated {
+			resp, err = r.exchangeStream(ctx, state, packed, id, false)
+		}
+	case "tcp":
+		resp, err = r.exchangeStream(ctx, state, packed, id, false)
+	case "tls":
+		resp, err = r.exchangeStream(ctx, state, packed, id, true)
+	default:
+		resp, err = r.exchangeHTTPS(ctx, state, packed, id)
+	}
+	return resp, err
+}
+
+func (r *Resolver) exchangeUDP(
+	ctx context.Context, state *lib.State, query []byte, id uint16,
+) (*dnsmessage.Message, error) {
+	conn, err := state.Dialer.DialContext(ctx, "udp", r.addr)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+	defer netext.CloseOnDone(ctx, conn)()
+
+	if _, err = conn.Write(query); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := unpack(buf[:n], id)
+		if errors.Is(err, errMismatchedID) {
+			// a late response to an earlier query of the same port
+			continue
+		}
+		return resp, err
+	}
+}
+
+// exchangeStream exchanges the query over TCP, or TLS, with the 2 bytes length prefix of the messages.
+func (r *Resolver) exchangeStream(
+	ctx context.Context, state *lib.State, query []byte, id uint16, useTLS bool,
+) (*dnsmessage.Message, error) {
+	conn, err := state.Dialer.DialContext(ctx, "tcp", r.addr)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+	defer netext.CloseOnDone(ctx, conn)()
+
+	if useTLS {
+		tlsConfig := &tls.Config{} //nolint:gosec
+		if state.TLSConfig != nil {
+			tlsConfig = state.TLSConfig.Clone()
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = r.server.Hostname()
+		}
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err = tlsConn.HandshakeContext(ctx); err != nil {
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	msg := make([]byte, 2, 2+len(query))
+	binary.BigEndian.PutUint16(msg, uint16(len(query)))
+	if _, err = conn.Write(append(msg, query...)); err != nil {
+		return nil, err
+	}
+	var length [2]byte
+	if _, err = io.ReadFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err = io.ReadFull(conn, resp); err != nil {
+		return nil, err
+	}
+	return unpack(resp, id)
+}
+
+// exchangeHTTPS exchanges the query with a POST request, as in RFC 8484.
+func (r *Resolver) exchangeHTTPS(
+	ctx context.Context, state *lib.State, query []byte, id uint16,
+) (*dnsmessage.Message, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.server.String(), bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+	res, err := state.Transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("the DNS server responded with the HTTP status %d", res.StatusCode)
+	}
+	resp, err := ioutil.ReadAll(io.LimitReader(res.Body, maxMessageSize))
+	if err != nil {
+		return nil, err
+	}
+	return unpack(resp, id)
+}
+
+var errMismatchedID = errors.New("the ID of the DNS response doesn't match the query")
+
+func unpack(b []byte, id uint16) (*dnsmessage.Message, error) {
+	var msg dnsmessage.Message
+	if err := msg.Unpack(b); err != nil {
+		return nil, fmt.Errorf("invalid DNS response: %w", err)
+	}
+	if msg.ID != id {
+		return nil, errMismatchedID
+	}
+	if !msg.Response {
+		return nil, errors.New("invalid DNS response, it isn't a response")
+	}
+	return &msg, nil
+}
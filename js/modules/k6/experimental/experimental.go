@@ -2,20 +2,29 @@
 package experimental
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/dop251/goja"
 	"go.k6.io/k6/js/common"
 	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/stats"
 )
 
 type (
 	// RootModule is the root experimental module
-	RootModule struct{}
+	RootModule struct {
+		// timers are shared by all the instances of a VU, so it doesn't
+		// matter which of them was used to add or clear a timer
+		timers sync.Map // modules.VU -> *timers
+	}
 	// ModuleInstance represents an instance of the experimental module
 	ModuleInstance struct {
-		vu modules.VU
+		vu     modules.VU
+		timers *timers
 	}
 )
 
@@ -25,8 +34,9 @@ var (
 )
 
 // NewModuleInstance implements modules.Module interface
-func (*RootModule) NewModuleInstance(m modules.VU) modules.Instance {
-	return &ModuleInstance{vu: m}
+func (r *RootModule) NewModuleInstance(m modules.VU) modules.Instance {
+	t, _ := r.timers.LoadOrStore(m, newTimers(m))
+	return &ModuleInstance{vu: m, timers: t.(*timers)} //nolint:forcetypeassert
 }
 
 // New returns a new RootModule.
@@ -38,31 +48,174 @@ func New() *RootModule {
 func (mi *ModuleInstance) Exports() modules.Exports {
 	return modules.Exports{
 		Named: map[string]interface{}{
-			"setTimeout": mi.setTimeout,
+			"setTimeout":     mi.setTimeout,
+			"clearTimeout":   mi.clearTimeout,
+			"setInterval":    mi.setInterval,
+			"clearInterval":  mi.clearInterval,
+			"setImmediate":   mi.setImmediate,
+			"clearImmediate": mi.clearImmediate,
+			"queueMicrotask": mi.queueMicrotask,
+			"delay":          mi.delay,
+
+			"getEventLoopStats": mi.getEventLoopStats,
+			"openFile":          mi.openFile,
+
+			"AbortController": mi.newAbortController,
+			"AbortSignal":     mi.newAbortSignalObject(),
 		},
 	}
 }
 
-func (mi *ModuleInstance) setTimeout(f goja.Callable, t float64) {
+func (mi *ModuleInstance) setTimeout(f goja.Callable, delay float64, args ...goja.Value) int64 {
 	if f == nil {
 		common.Throw(mi.vu.Runtime(), errors.New("setTimeout requires a function as first argument"))
 	}
-	// TODO maybe really return something to use with `clearTimeout
-	// TODO support arguments ... maybe
-	runOnLoop := mi.vu.RegisterCallback()
-	go func() {
-		timer := time.NewTimer(time.Duration(t * float64(time.Millisecond)))
-		select {
-		case <-timer.C:
-			runOnLoop(func() error {
-				_, err := f(goja.Undefined())
-				return err
-			})
-		case <-mi.vu.Context().Done():
-			// TODO log something?
-
-			timer.Stop()
-			runOnLoop(func() error { return nil })
+	return mi.timers.add("setTimeout", f, delay, false, args)
+}
+
+func (mi *ModuleInstance) clearTimeout(id int64) {
+	mi.timers.remove(id)
+}
+
+// setInterval calls f every delay milliseconds, with any additional arguments followed by the number of ticks
+// that were skipped since the previous call, as it took longer than the delay for it to be called.
+func (mi *ModuleInstance) setInterval(f goja.Callable, delay float64, args ...goja.Value) int64 {
+	if f == nil {
+		common.Throw(mi.vu.Runtime(), errors.New("setInterval requires a function as first argument"))
+	}
+	return mi.timers.add("setInterval", f, delay, true, args)
+}
+
+func (mi *ModuleInstance) clearInterval(id int64) {
+	mi.timers.remove(id)
+}
+
+// setImmediate calls f with any additional arguments once the event loop has run everything that is already queued,
+// unlike a setTimeout with no delay, which has to wait for its timer to fire first.
+func (mi *ModuleInstance) setImmediate(f goja.Callable, args ...goja.Value) int64 {
+	if f == nil {
+		common.Throw(mi.vu.Runtime(), errors.New("setImmediate requires a function as first argument"))
+	}
+	return mi.timers.addImmediate(f, args)
+}
+
+func (mi *ModuleInstance) clearImmediate(id int64) {
+	mi.timers.removeImmediate(id)
+}
+
+// delay returns a promise that is resolved after ms milliseconds, unless the signal in the options
+// is aborted first, in which case it's rejected with the reason of the abort.
+func (mi *ModuleInstance) delay(ms float64, options goja.Value) *goja.Promise {
+	rt := mi.vu.Runtime()
+	var signal *AbortSignal
+	if obj, ok := options.(*goja.Object); ok {
+		if v := obj.Get("signal"); v != nil && !goja.IsUndefined(v) && !goja.IsNull(v) {
+			if signal, ok = v.Export().(*AbortSignal); !ok {
+				common.Throw(rt, errors.New("signal must be an AbortSignal"))
+			}
+		}
+	}
+
+	p, resolve, reject := rt.NewPromise()
+	if signal == nil {
+		mi.timers.add("delay", func(goja.Value, ...goja.Value) (goja.Value, error) {
+			resolve(goja.Undefined())
+			return nil, nil
+		}, ms, false, nil)
+		return p
+	}
+	if signal.Aborted() {
+		reject(signal.Reason())
+		return p
+	}
+
+	var id int64
+	onAbort := rt.ToValue(func() {
+		mi.timers.remove(id)
+		reject(signal.Reason())
+	})
+	id = mi.timers.add("delay", func(goja.Value, ...goja.Value) (goja.Value, error) {
+		signal.removeEventListener("abort", onAbort)
+		resolve(goja.Undefined())
+		return nil, nil
+	}, ms, false, nil)
+	signal.addEventListener("abort", onAbort)
+	return p
+}
+
+// eventLoopStats is what getEventLoopStats returns, the lag is in milliseconds.
+type eventLoopStats struct {
+	PendingTimers     int     `js:"pendingTimers"`
+	PendingImmediates int     `js:"pendingImmediates"`
+	PendingCallbacks  int     `js:"pendingCallbacks"`
+	QueuedCallbacks   int     `js:"queuedCallbacks"`
+	Lag               float64 `js:"lag"`
+}
+
+// getEventLoopStats helps with finding out why an iteration doesn't end, as it waits on everything
+// that was registered on the event loop. The timers and immediates of this module instance are counted
+// separately, but they are also part of the pending and queued callbacks.
+func (mi *ModuleInstance) getEventLoopStats() eventLoopStats {
+	var s eventLoopStats
+	s.PendingTimers, s.PendingImmediates = mi.timers.pending()
+	if inspector, ok := mi.vu.(modules.EventLoopInspector); ok {
+		loop := inspector.EventLoopStats()
+		s.PendingCallbacks = loop.PendingCallbacks
+		s.QueuedCallbacks = loop.QueuedCallbacks
+		s.Lag = float64(loop.Lag) / float64(time.Millisecond)
+	}
+	return s
+}
+
+// ReportLeakedTimers drops the timers and immediates that were still pending at the end of an iteration,
+// emitting their count as a leaked_timers sample and logging a single warning with where they were created.
+// It must be called after the event loop of the iteration has finished.
+func (mi *ModuleInstance) ReportLeakedTimers() {
+	state := mi.vu.State()
+	leaked := mi.timers.leaked()
+	if len(leaked) == 0 || state == nil {
+		return
+	}
+
+	var b bytes.Buffer
+	for _, tm := range leaked {
+		fmt.Fprintf(&b, "\n\t%s %d", tm.kind, tm.id)
+		for _, frame := range tm.stack {
+			b.WriteString("\n\t\tat ")
+			frame.Write(&b)
 		}
-	}()
+	}
+	noun := "timers"
+	if len(leaked) == 1 {
+		noun = "timer"
+	}
+	state.Logger.WithField("leaked_timers", len(leaked)).Warnf(
+		"The iteration ended with %d %s that will never run, created by:%s", len(leaked), noun, b.String())
+
+	// the context of the iteration is already done, so the sample is sent the same way the iteration's ones are
+	tags := state.CloneTags()
+	state.Samples <- stats.Sample{
+		Time:   time.Now(),
+		Metric: state.BuiltinMetrics.LeakedTimers,
+		Tags:   stats.IntoSampleTags(&tags),
+		Value:  float64(len(leaked)),
+	}
+}
+
+// queueMicrotask queues f to be run as a microtask, after the currently running code
+// and any microtasks (for example promise reactions) that were queued before it.
+func (mi *ModuleInstance) queueMicrotask(f goja.Value) {
+	rt := mi.vu.Runtime()
+	if _, ok := goja.AssertFunction(f); !ok {
+		common.Throw(rt, errors.New("queueMicrotask requires a function as first argument"))
+	}
+	// goja doesn't expose its job queue, so the microtask is queued the same way
+	// a reaction on an already resolved promise would be.
+	p, resolve, _ := rt.NewPromise()
+	resolve(goja.Undefined())
+	promise := rt.ToValue(p).ToObject(rt)
+	then, _ := goja.AssertFunction(promise.Get("then"))
+	if _, err := then(promise, f); err != nil {
+		common.Throw(rt, err)
+	}
 }
@@ -0,0 +1,129 @@
+package experimental
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modulestest"
+)
+
+func newTestRuntime(t *testing.T) *goja.Runtime {
+	t.Helper()
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	m, ok := New().NewModuleInstance(
+		&modulestest.VU{
+			CtxField:     context.Background(),
+			RuntimeField: rt,
+		},
+	).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, rt.Set("experimental", m.Exports().Named))
+	return rt
+}
+
+func TestQueueMicrotask(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Ordering", func(t *testing.T) {
+		t.Parallel()
+		rt := newTestRuntime(t)
+		_, err := rt.RunString(`
+			var log = [];
+			Promise.resolve().then(function() { log.push("promise 1"); });
+			experimental.queueMicrotask(function() {
+				log.push("microtask 1");
+				experimental.queueMicrotask(function() { log.push("microtask 3"); });
+			});
+			Promise.resolve().then(function() { log.push("promise 2"); });
+			experimental.queueMicrotask(function() { log.push("microtask 2"); });
+			log.push("sync");
+		`)
+		require.NoError(t, err)
+		_, err = rt.RunString(`
+			var expected = ["sync", "promise 1", "microtask 1", "promise 2", "microtask 2", "microtask 3"];
+			if (JSON.stringify(log) !== JSON.stringify(expected)) {
+				throw new Error("wrong order: " + JSON.stringify(log));
+			}
+		`)
+		require.NoError(t, err)
+	})
+
+	t.Run("NotAFunction", func(t *testing.T) {
+		t.Parallel()
+		rt := newTestRuntime(t)
+		_, err := rt.RunString(`experimental.queueMicrotask(5)`)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "queueMicrotask requires a function as first argument")
+	})
+}
+
+func TestAbortController(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Abort", func(t *testing.T) {
+		t.Parallel()
+		rt := newTestRuntime(t)
+		_, err := rt.RunString(`
+			var controller = new experimental.AbortController();
+			var signal = controller.signal;
+			var calls = [];
+			var removed = function() { calls.push("removed"); };
+			signal.onabort = function(e) { calls.push("onabort " + e.type); };
+			signal.addEventListener("abort", function(e) {
+				calls.push("listener");
+				if (e.target !== signal || this !== signal) {
+					throw new Error("wrong target");
+				}
+			});
+			signal.addEventListener("abort", removed);
+			signal.removeEventListener("abort", removed);
+			if (signal.aborted || signal.reason !== undefined) {
+				throw new Error("signal shouldn't be aborted yet");
+			}
+			signal.throwIfAborted();
+
+			controller.abort();
+			controller.abort("again");
+			if (!signal.aborted || signal.reason.name !== "AbortError") {
+				throw new Error("wrong reason: " + signal.reason);
+			}
+			if (calls.join(",") !== "onabort abort,listener") {
+				throw new Error("wrong calls: " + calls.join(","));
+			}
+			try {
+				signal.throwIfAborted();
+				throw new Error("throwIfAborted should've thrown");
+			} catch (e) {
+				if (e !== signal.reason) {
+					throw e;
+				}
+			}
+		`)
+		require.NoError(t, err)
+	})
+
+	t.Run("AbortSignal", func(t *testing.T) {
+		t.Parallel()
+		rt := newTestRuntime(t)
+		_, err := rt.RunString(`
+			var signal = experimental.AbortSignal.abort("reason");
+			if (!signal.aborted || signal.reason !== "reason") {
+				throw new Error("the signal should be aborted");
+			}
+			signal = experimental.AbortSignal.timeout(60000);
+			if (signal.aborted) {
+				throw new Error("the signal shouldn't be aborted yet");
+			}
+		`)
+		require.NoError(t, err)
+
+		_, err = rt.RunString(`new experimental.AbortSignal()`)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "Illegal constructor")
+	})
+}
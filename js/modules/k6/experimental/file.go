@@ -0,0 +1,57 @@
+package experimental
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/spf13/afero"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/lib/fsext"
+)
+
+// File is a file that is read from the disk every time it's used, instead of being kept in memory like
+// the contents returned by open(). It can be used as the body of a request to upload files that are too
+// big to be loaded by every VU.
+//
+// As its contents are never cached, it can't be used with archives or in the cloud.
+type File struct {
+	Name string `js:"name"`
+	Size int64  `js:"size"`
+
+	fs afero.Fs
+}
+
+// Open opens the file for reading, every call returns a new reader that starts from the beginning.
+func (f *File) Open() (io.ReadCloser, error) {
+	return f.fs.Open(f.Name)
+}
+
+// openFile returns a File for the given path, it can only be used in the init context.
+func (mi *ModuleInstance) openFile(filename string) *File {
+	rt := mi.vu.Runtime()
+	initEnv := mi.vu.InitEnv()
+	if mi.vu.State() != nil || initEnv == nil {
+		common.Throw(rt, errors.New("openFile() can only be used in the init context"))
+	}
+	if filename == "" {
+		common.Throw(rt, errors.New("openFile() can't be used with an empty filename"))
+	}
+
+	filename = initEnv.GetAbsFilePath(filename)
+	fs := initEnv.FileSystems["file"]
+	// the path is remembered by the caching file system, so that it can be opened by all the VUs,
+	// but the file itself is read from the real one as its contents shouldn't be cached
+	info, err := fs.Stat(filename)
+	if err != nil {
+		common.Throw(rt, fmt.Errorf("openFile() couldn't open %q: %w", filename, err))
+	}
+	if info.IsDir() {
+		common.Throw(rt, fmt.Errorf("openFile() can't be used with a directory, path: %q", filename))
+	}
+	if bfs, ok := fs.(fsext.BaseLayerGetter); ok {
+		fs = bfs.GetBaseFs()
+	}
+	return &File{Name: filename, Size: info.Size(), fs: fs}
+}
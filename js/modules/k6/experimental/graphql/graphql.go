@@ -0,0 +1,385 @@
+// Package graphql implements the k6/experimental/graphql module, with a GraphQL client that sends the
+// queries and mutations over HTTP with k6/http, and the subscriptions with graphql-ws over k6/ws.
+package graphql
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modules"
+	httpModule "go.k6.io/k6/js/modules/k6/http"
+	"go.k6.io/k6/js/modules/k6/ws"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/stats"
+)
+
+type (
+	// RootModule is the global module instance that will create module
+	// instances for each VU.
+	RootModule struct{}
+
+	// ModuleInstance represents an instance of the graphql module for every VU.
+	ModuleInstance struct {
+		vu   modules.VU
+		http *httpModule.Client
+		ws   *ws.WS
+	}
+)
+
+var (
+	_ modules.Module   = &RootModule{}
+	_ modules.Instance = &ModuleInstance{}
+)
+
+// New returns a pointer to a new RootModule instance.
+func New() *RootModule {
+	return &RootModule{}
+}
+
+// NewModuleInstance implements the modules.Module interface to return
+// a new instance for each VU.
+func (*RootModule) NewModuleInstance(vu modules.VU) modules.Instance {
+	mi := &ModuleInstance{vu: vu}
+	if httpMI, ok := httpModule.New().NewModuleInstance(vu).(*httpModule.ModuleInstance); ok {
+		mi.http = httpMI.DefaultClient()
+	}
+	mi.ws, _ = ws.New().NewModuleInstance(vu).(*ws.WS)
+	return mi
+}
+
+// Exports returns the exports of the graphql module.
+func (mi *ModuleInstance) Exports() modules.Exports {
+	return modules.Exports{
+		Named: map[string]interface{}{
+			"Client": mi.NewClient,
+		},
+	}
+}
+
+// NewClient is the JS constructor for the graphql Client.
+func (mi *ModuleInstance) NewClient(call goja.ConstructorCall) *goja.Object {
+	rt := mi.vu.Runtime()
+	c, err := newClient(mi, call.Argument(0).String(), call.Argument(1))
+	if err != nil {
+		common.Throw(rt, err)
+	}
+	return rt.ToValue(c).ToObject(rt)
+}
+
+var errInInitContext = common.NewInitContextError("using GraphQL in the init context is not supported")
+
+// Client sends the operations to a GraphQL endpoint.
+type Client struct {
+	mi *ModuleInstance
+
+	url, wsURL string
+	headers    map[string]string
+	tags       map[string]string
+	timeout    time.Duration
+	// persisted is whether the queries are sent as Automatic Persisted Queries, their hash first
+	persisted        bool
+	connectionParams interface{}
+}
+
+func newClient(mi *ModuleInstance, endpoint string, params goja.Value) (*Client, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid GraphQL endpoint %q, it needs to be an HTTP or HTTPS URL", endpoint)
+	}
+	c := &Client{mi: mi, url: endpoint}
+	wsURL := *u
+	wsURL.Scheme = map[string]string{"http": "ws", "https": "wss"}[u.Scheme]
+	c.wsURL = wsURL.String()
+
+	if isNullish(params) {
+		return c, nil
+	}
+	rt := mi.vu.Runtime()
+	obj := params.ToObject(rt)
+	for _, k := range obj.Keys() {
+		v := obj.Get(k)
+		switch k {
+		case "headers":
+			if c.headers, err = toStrings(v, "headers"); err != nil {
+				return nil, err
+			}
+		case "tags":
+			if c.tags, err = toStrings(v, "metric tags"); err != nil {
+				return nil, err
+			}
+		case "timeout":
+			if c.timeout, err = types.GetDurationValue(v.Export()); err != nil || c.timeout <= 0 {
+				return nil, fmt.Errorf("invalid timeout value '%#v'", v.Export())
+			}
+		case "persistedQueries":
+			var ok bool
+			if c.persisted, ok = v.Export().(bool); !ok {
+				return nil, errors.New("the persistedQueries param needs to be a boolean")
+			}
+		case "wsURL":
+			c.wsURL = v.String()
+		case "connectionParams":
+			c.connectionParams = v.Export()
+		default:
+			return nil, fmt.Errorf("unknown Client param: %q", k)
+		}
+	}
+	return c, nil
+}
+
+// toStrings returns the string values of an object, like the headers and the tags.
+func toStrings(v goja.Value, what string) (map[string]string, error) {
+	raw, ok := v.Export().(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be an object of string values, got '%#v'", what, v.Export())
+	}
+	m := make(map[string]string, len(raw))
+	for k, v := range raw {
+		m[k] = fmt.Sprint(v)
+	}
+	return m, nil
+}
+
+// operationParams are the params of an operation, the callbacks and the limit are only for the subscriptions.
+type operationParams struct {
+	operationName string
+	headers       map[string]string
+	tags          map[string]string
+	timeout       time.Duration
+
+	onNext, onError, onComplete goja.Callable
+	limit                       int64
+}
+
+func (c *Client) parseParams(params goja.Value, subscription bool) (operationParams, error) {
+	p := operationParams{timeout: c.timeout}
+	if isNullish(params) {
+		return p, nil
+	}
+	obj := params.ToObject(c.mi.vu.Runtime())
+	var err error
+	for _, k := range obj.Keys() {
+		v := obj.Get(k)
+		switch k {
+		case "operationName":
+			p.operationName = v.String()
+		case "headers":
+			if p.headers, err = toStrings(v, "headers"); err != nil {
+				return p, err
+			}
+		case "tags":
+			if p.tags, err = toStrings(v, "metric tags"); err != nil {
+				return p, err
+			}
+		case "timeout":
+			if p.timeout, err = types.GetDurationValue(v.Export()); err != nil || p.timeout <= 0 {
+				return p, fmt.Errorf("invalid timeout value '%#v'", v.Export())
+			}
+		case "onNext", "onError", "onComplete":
+			fn, ok := goja.AssertFunction(v)
+			if !subscription || !ok {
+				return p, fmt.Errorf("invalid %s param, it needs to be a function of a subscription", k)
+			}
+			switch k {
+			case "onNext":
+				p.onNext = fn
+			case "onError":
+				p.onError = fn
+			default:
+				p.onComplete = fn
+			}
+		case "limit":
+			if p.limit = v.ToInteger(); !subscription || p.limit <= 0 {
+				return p, fmt.Errorf("invalid limit param '%#v', it needs to be a positive number of events", v.Export())
+			}
+		default:
+			return p, fmt.Errorf("unknown operation param: %q", k)
+		}
+	}
+	return p, nil
+}
+
+// operationTags returns the tags of the samples of an operation, which are also set on its HTTP requests.
+func (c *Client) operationTags(op operation, p operationParams) map[string]string {
+	tags := make(map[string]string, len(c.tags)+len(p.tags)+2)
+	for k, v := range c.tags {
+		tags[k] = v
+	}
+	tags["operation_type"] = op.kind
+	if op.name != "" {
+		tags["operation_name"] = op.name
+	}
+	for k, v := range p.tags {
+		tags[k] = v
+	}
+	return tags
+}
+
+// Response is the result of a query or a mutation.
+type Response struct {
+	Data       interface{}            `js:"data"`
+	Errors     interface{}            `js:"errors"`
+	Extensions map[string]interface{} `js:"extensions"`
+	// Response is the HTTP response, with the usual methods like json()
+	Response *httpModule.Response `js:"response"`
+}
+
+// Query executes a query operation.
+func (c *Client) Query(query string, variables goja.Value, params goja.Value) (*Response, error) {
+	return c.execute("query", query, variables, params)
+}
+
+// Mutate executes a mutation operation.
+func (c *Client) Mutate(mutation string, variables goja.Value, params goja.Value) (*Response, error) {
+	return c.execute("mutation", mutation, variables, params)
+}
+
+func (c *Client) execute(kind, document string, variables goja.Value, params goja.Value) (*Response, error) {
+	state := c.mi.vu.State()
+	if state == nil {
+		return nil, errInInitContext
+	}
+	p, err := c.parseParams(params, false)
+	if err != nil {
+		return nil, err
+	}
+	op, err := findOperation(document, p.operationName)
+	if err != nil {
+		return nil, err
+	}
+	if op.kind != kind {
+		method := "query"
+		if kind == "mutation" {
+			method = "mutate"
+		}
+		return nil, fmt.Errorf("the operation is a %s, it can't be executed by %s()", op.kind, method)
+	}
+
+	body := map[string]interface{}{"query": document}
+	if !isNullish(variables) {
+		body["variables"] = variables.Export()
+	}
+	if op.name != "" {
+		body["operationName"] = op.name
+	}
+	tags := c.operationTags(op, p)
+
+	start := time.Now()
+	var res *Response
+	if c.persisted {
+		hash := sha256.Sum256([]byte(document))
+		body["extensions"] = map[string]interface{}{
+			"persistedQuery": map[string]interface{}{"version": 1, "sha256Hash": hex.EncodeToString(hash[:])},
+		}
+		delete(body, "query")
+		if res, err = c.post(body, p, tags); err != nil {
+			return nil, err
+		}
+		if persistedQueryNotFound(res.Errors) {
+			// the server doesn't have the query yet, it's registered by sending it with its hash
+			body["query"] = document
+			res = nil
+		}
+	}
+	if res == nil {
+		if res, err = c.post(body, p, tags); err != nil {
+			return nil, err
+		}
+	}
+	failed := res.Response.Error != "" || res.Response.Status < 200 || res.Response.Status > 299 ||
+		res.Data == nil && res.Errors == nil || hasErrors(res.Errors)
+
+	sampleTags := state.CloneTags()
+	if state.Options.SystemTags.Has(stats.TagURL) {
+		sampleTags["url"] = c.url
+	}
+	for k, v := range tags {
+		sampleTags[k] = v
+	}
+	c.push(state.BuiltinMetrics.GraphQLReqDuration, stats.D(time.Since(start)), sampleTags)
+	c.push(state.BuiltinMetrics.GraphQLReqFailed, stats.B(failed), sampleTags)
+	return res, nil
+}
+
+// post sends the body of an operation with k6/http, so that it also emits the http_req_* metrics.
+func (c *Client) post(body map[string]interface{}, p operationParams, tags map[string]string) (*Response, error) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("the variables can't be encoded to JSON: %w", err)
+	}
+	headers := map[string]string{"Content-Type": "application/json", "Accept": "application/json"}
+	for _, h := range []map[string]string{c.headers, p.headers} {
+		for k, v := range h {
+			headers[k] = v
+		}
+	}
+	params := map[string]interface{}{"headers": headers, "tags": tags, "responseType": "text"}
+	if p.timeout > 0 {
+		params["timeout"] = p.timeout.String()
+	}
+
+	rt := c.mi.vu.Runtime()
+	resp, err := c.mi.http.Request(http.MethodPost, rt.ToValue(c.url), rt.ToValue(string(b)), rt.ToValue(params))
+	if err != nil {
+		return nil, err
+	}
+	res := &Response{Response: resp}
+	if text, ok := resp.Body.(string); ok {
+		var parsed struct {
+			Data       interface{}            `json:"data"`
+			Errors     interface{}            `json:"errors"`
+			Extensions map[string]interface{} `json:"extensions"`
+		}
+		if json.Unmarshal([]byte(text), &parsed) == nil {
+			res.Data, res.Errors, res.Extensions = parsed.Data, parsed.Errors, parsed.Extensions
+		}
+	}
+	return res, nil
+}
+
+func isNullish(v goja.Value) bool {
+	return v == nil || goja.IsUndefined(v) || goja.IsNull(v)
+}
+
+func hasErrors(errs interface{}) bool {
+	list, ok := errs.([]interface{})
+	return ok && len(list) > 0
+}
+
+// persistedQueryNotFound returns whether the errors are the one of the Automatic Persisted Queries
+// of a server that doesn't have the query of the hash.
+func persistedQueryNotFound(errs interface{}) bool {
+	list, _ := errs.([]interface{})
+	for _, e := range list {
+		gqlErr, _ := e.(map[string]interface{})
+		if gqlErr["message"] == "PersistedQueryNotFound" {
+			return true
+		}
+		if ext, ok := gqlErr["extensions"].(map[string]interface{}); ok && ext["code"] == "PERSISTED_QUERY_NOT_FOUND" {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Client) push(metric *stats.Metric, value float64, tags map[string]string) {
+	sampleTags := make(map[string]string, len(tags))
+	for k, v := range tags {
+		sampleTags[k] = v
+	}
+	stats.PushIfNotDone(c.mi.vu.Context(), c.mi.vu.State().Samples, stats.Sample{
+		Metric: metric,
+		Tags:   stats.IntoSampleTags(&sampleTags),
+		Value:  value,
+		Time:   time.Now(),
+	})
+}
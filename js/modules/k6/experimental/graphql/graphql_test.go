@@ -0,0 +1,423 @@
+package graphql
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/oxtoacart/bpool"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/lib/testutils/httpmultibin"
+	"go.k6.io/k6/stats"
+)
+
+func newTestVU(t *testing.T) (*httpmultibin.HTTPMultiBin, *modulestest.VU, chan stats.SampleContainer) {
+	t.Helper()
+	tb := httpmultibin.NewHTTPMultiBin(t)
+	vu, samples := modulestest.NewTestVU(t, tb, stats.TagURL, stats.TagStatus)
+	root, err := lib.NewGroup("", nil)
+	require.NoError(t, err)
+
+	vu.InitEnvField = &common.InitEnvironment{}
+	state := vu.StateField
+	state.Group, state.BPool, state.Logger = root, bpool.NewBufferPool(1), logrus.New()
+	state.Options.MaxRedirects = null.IntFrom(10)
+	state.Options.UserAgent = null.StringFrom("TestUserAgent")
+	state.Options.Throw = null.BoolFrom(true)
+	m, ok := New().NewModuleInstance(vu).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, vu.Runtime().Set("graphql", m.Exports().Named))
+	return tb, vu, samples
+}
+
+// server is a GraphQL server with Automatic Persisted Queries, and graphql-ws subscriptions.
+type server struct {
+	t *testing.T
+
+	mu        sync.Mutex
+	persisted map[string]string
+	requests  []map[string]interface{}
+	initParam interface{}
+}
+
+func newServer(tb *httpmultibin.HTTPMultiBin, t *testing.T) *server {
+	s := &server{t: t, persisted: make(map[string]string)}
+	tb.Mux.HandleFunc("/graphql", func(w http.ResponseWriter, r *http.Request) {
+		if websocket.IsWebSocketUpgrade(r) {
+			s.subscriptions(w, r)
+			return
+		}
+		s.operation(w, r)
+	})
+	return s
+}
+
+func (s *server) operation(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Query         string                 `json:"query"`
+		Variables     map[string]interface{} `json:"variables"`
+		OperationName string                 `json:"operationName"`
+		Extensions    struct {
+			PersistedQuery struct {
+				SHA256Hash string `json:"sha256Hash"`
+			} `json:"persistedQuery"`
+		} `json:"extensions"`
+	}
+	require.NoError(s.t, json.NewDecoder(r.Body).Decode(&req))
+	s.mu.Lock()
+	s.requests = append(s.requests, map[string]interface{}{"query": req.Query, "operationName": req.OperationName})
+	if hash := req.Extensions.PersistedQuery.SHA256Hash; hash != "" {
+		if req.Query == "" {
+			req.Query = s.persisted[hash]
+		} else {
+			sum := sha256.Sum256([]byte(req.Query))
+			assert.Equal(s.t, hex.EncodeToString(sum[:]), hash)
+			s.persisted[hash] = req.Query
+		}
+	}
+	s.mu.Unlock()
+
+	var resp interface{}
+	switch {
+	case req.Query == "":
+		resp = map[string]interface{}{"errors": []interface{}{map[string]interface{}{
+			"message": "PersistedQueryNotFound", "extensions": map[string]interface{}{"code": "PERSISTED_QUERY_NOT_FOUND"},
+		}}}
+	case req.OperationName == "GetUser":
+		resp = map[string]interface{}{"data": map[string]interface{}{
+			"user": map[string]interface{}{"id": req.Variables["id"], "name": "k6"},
+		}}
+	case req.OperationName == "AddUser":
+		resp = map[string]interface{}{"data": map[string]interface{}{
+			"addUser": map[string]interface{}{"name": req.Variables["name"]},
+		}}
+	default:
+		resp = map[string]interface{}{"data": nil, "errors": []interface{}{map[string]interface{}{"message": "boom"}}}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	require.NoError(s.t, json.NewEncoder(w).Encode(resp))
+}
+
+// subscriptions sends 3 events to the Count subscriptions, the Forever ones until they're
+// completed, and an error to the others.
+func (s *server) subscriptions(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{Subprotocols: []string{subprotocol}}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if !assert.NoError(s.t, err) {
+		return
+	}
+	defer func() { _ = conn.Close() }()
+	if !assert.Equal(s.t, subprotocol, conn.Subprotocol()) {
+		return
+	}
+
+	var init struct {
+		Type    string      `json:"type"`
+		Payload interface{} `json:"payload"`
+	}
+	if conn.ReadJSON(&init) != nil || !assert.Equal(s.t, "connection_init", init.Type) {
+		return
+	}
+	s.mu.Lock()
+	s.initParam = init.Payload
+	s.mu.Unlock()
+	_ = conn.WriteJSON(map[string]interface{}{"type": "ping"})
+	_ = conn.WriteJSON(map[string]interface{}{"type": "connection_ack"})
+
+	var sub struct {
+		ID      string `json:"id"`
+		Type    string `json:"type"`
+		Payload struct {
+			OperationName string                 `json:"operationName"`
+			Variables     map[string]interface{} `json:"variables"`
+		} `json:"payload"`
+	}
+	for sub.Type != "subscribe" {
+		if conn.ReadJSON(&sub) != nil {
+			return
+		}
+		// the pong is skipped
+	}
+
+	next := func(i int) error {
+		return conn.WriteJSON(map[string]interface{}{
+			"id": sub.ID, "type": "next", "payload": map[string]interface{}{"data": map[string]interface{}{"count": i}},
+		})
+	}
+	switch sub.Payload.OperationName {
+	case "Count":
+		for i := 1; i <= 3; i++ {
+			_ = next(i)
+		}
+		_ = conn.WriteJSON(map[string]interface{}{"id": sub.ID, "type": "complete"})
+		_, _, _ = conn.ReadMessage()
+	case "Forever":
+		go func() {
+			for i := 1; next(i) == nil; i++ {
+				time.Sleep(10 * time.Millisecond)
+			}
+		}()
+		for {
+			var msg map[string]interface{}
+			if conn.ReadJSON(&msg) != nil || msg["type"] == "complete" {
+				return
+			}
+		}
+	default:
+		_ = conn.WriteJSON(map[string]interface{}{
+			"id": sub.ID, "type": "error", "payload": []interface{}{map[string]interface{}{"message": "no such subscription"}},
+		})
+		_, _, _ = conn.ReadMessage()
+	}
+}
+
+func TestClient(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Query", func(t *testing.T) {
+		t.Parallel()
+		tb, vu, samples := newTestVU(t)
+		newServer(tb, t)
+
+		_, err := vu.Runtime().RunString(tb.Replacer.Replace(`
+			var client = new graphql.Client("HTTPBIN_URL/graphql", { tags: { tag: "value" } });
+			var res = client.query("query GetUser($id: ID!) { user(id: $id) { id name } }", { id: "42" });
+		`))
+		require.NoError(t, err)
+
+		v, err := vu.Runtime().RunString(`[res.data.user.id, res.data.user.name, res.errors, res.response.status].join("|")`)
+		require.NoError(t, err)
+		assert.Equal(t, "42|k6||200", v.String())
+
+		seen := map[string]float64{}
+		for _, c := range stats.GetBufferedSamples(samples) {
+			for _, s := range c.GetSamples() {
+				name, _ := s.Tags.Get("operation_name")
+				kind, _ := s.Tags.Get("operation_type")
+				tag, _ := s.Tags.Get("tag")
+				assert.Equal(t, "GetUser query value", name+" "+kind+" "+tag, s.Metric.Name)
+				if s.Metric.Name == metrics.HTTPReqsName || s.Metric.Name == metrics.GraphQLReqFailedName {
+					seen[s.Metric.Name] += s.Value
+				}
+				if s.Metric.Name == metrics.GraphQLReqDurationName {
+					seen[s.Metric.Name]++
+				}
+			}
+		}
+		assert.Equal(t, map[string]float64{
+			metrics.HTTPReqsName:           1,
+			metrics.GraphQLReqDurationName: 1,
+			metrics.GraphQLReqFailedName:   0,
+		}, seen)
+	})
+
+	t.Run("ErrorsInResponse", func(t *testing.T) {
+		t.Parallel()
+		tb, vu, samples := newTestVU(t)
+		newServer(tb, t)
+
+		_, err := vu.Runtime().RunString(tb.Replacer.Replace(`
+			var res = new graphql.Client("HTTPBIN_URL/graphql").query("{ broken }");
+		`))
+		require.NoError(t, err)
+		v, err := vu.Runtime().RunString(`res.data + "|" + res.errors[0].message`)
+		require.NoError(t, err)
+		assert.Equal(t, "null|boom", v.String())
+
+		var failed float64
+		for _, c := range stats.GetBufferedSamples(samples) {
+			for _, s := range c.GetSamples() {
+				if s.Metric.Name == metrics.GraphQLReqFailedName {
+					failed += s.Value
+					_, hasName := s.Tags.Get("operation_name")
+					assert.False(t, hasName)
+				}
+			}
+		}
+		assert.Equal(t, float64(1), failed)
+	})
+
+	t.Run("Mutation", func(t *testing.T) {
+		t.Parallel()
+		tb, vu, _ := newTestVU(t)
+		newServer(tb, t)
+
+		_, err := vu.Runtime().RunString(tb.Replacer.Replace(`
+			var client = new graphql.Client("HTTPBIN_URL/graphql");
+			var document = "query GetUser { user { name } } mutation AddUser($name: String) { addUser(name: $name) { name } }";
+			var res = client.mutate(document, { name: "new" }, { operationName: "AddUser" });
+		`))
+		require.NoError(t, err)
+		assert.Equal(t, "new", vu.Runtime().Get("res").ToObject(vu.Runtime()).Get("data").
+			ToObject(vu.Runtime()).Get("addUser").ToObject(vu.Runtime()).Get("name").String())
+
+		_, err = vu.Runtime().RunString(`client.query(document, null, { operationName: "AddUser" })`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "the operation is a mutation, it can't be executed by query()")
+	})
+
+	t.Run("PersistedQueries", func(t *testing.T) {
+		t.Parallel()
+		tb, vu, _ := newTestVU(t)
+		srv := newServer(tb, t)
+
+		_, err := vu.Runtime().RunString(tb.Replacer.Replace(`
+			var client = new graphql.Client("HTTPBIN_URL/graphql", { persistedQueries: true });
+			var query = "query GetUser($id: ID!) { user(id: $id) { id } }";
+			var ids = [client.query(query, { id: "1" }).data.user.id, client.query(query, { id: "2" }).data.user.id];
+		`))
+		require.NoError(t, err)
+		v, err := vu.Runtime().RunString(`ids.join("|")`)
+		require.NoError(t, err)
+		assert.Equal(t, "1|2", v.String())
+
+		srv.mu.Lock()
+		defer srv.mu.Unlock()
+		// the query is only sent after the server didn't find its hash
+		var withQuery []bool
+		for _, req := range srv.requests {
+			withQuery = append(withQuery, req["query"] != "")
+		}
+		assert.Equal(t, []bool{false, true, false}, withQuery)
+	})
+
+	t.Run("Subscription", func(t *testing.T) {
+		t.Parallel()
+		tb, vu, samples := newTestVU(t)
+		srv := newServer(tb, t)
+
+		_, err := vu.Runtime().RunString(tb.Replacer.Replace(`
+			var client = new graphql.Client("HTTPBIN_URL/graphql", { connectionParams: { token: "secret" } });
+			var counts = [];
+			var completed = false;
+			var events = client.subscribe("subscription Count { count }", null, {
+				onNext: function(payload) { counts.push(payload.data.count); },
+				onComplete: function() { completed = true; },
+			});
+		`))
+		require.NoError(t, err)
+		v, err := vu.Runtime().RunString(`events + "|" + counts.join(",") + "|" + completed`)
+		require.NoError(t, err)
+		assert.Equal(t, "3|1,2,3|true", v.String())
+
+		srv.mu.Lock()
+		assert.Equal(t, map[string]interface{}{"token": "secret"}, srv.initParam)
+		srv.mu.Unlock()
+
+		var events float64
+		for _, c := range stats.GetBufferedSamples(samples) {
+			for _, s := range c.GetSamples() {
+				if s.Metric.Name == metrics.GraphQLSubscriptionEventsName {
+					events += s.Value
+					name, _ := s.Tags.Get("operation_name")
+					assert.Equal(t, "Count", name)
+					url, _ := s.Tags.Get("url")
+					assert.Equal(t, tb.Replacer.Replace("WSBIN_URL/graphql"), url)
+				}
+			}
+		}
+		assert.Equal(t, float64(3), events)
+	})
+
+	t.Run("SubscriptionLimit", func(t *testing.T) {
+		t.Parallel()
+		tb, vu, _ := newTestVU(t)
+		newServer(tb, t)
+
+		_, err := vu.Runtime().RunString(tb.Replacer.Replace(`
+			var client = new graphql.Client("HTTPBIN_URL/graphql");
+			var limited = client.subscribe("subscription Forever { count }", null, { limit: 2 });
+			var timedOut = client.subscribe("subscription Forever { count }", null, { timeout: "100ms" });
+		`))
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), vu.Runtime().Get("limited").ToInteger())
+		assert.Greater(t, vu.Runtime().Get("timedOut").ToInteger(), int64(0))
+	})
+
+	t.Run("SubscriptionError", func(t *testing.T) {
+		t.Parallel()
+		tb, vu, _ := newTestVU(t)
+		newServer(tb, t)
+
+		_, err := vu.Runtime().RunString(tb.Replacer.Replace(`
+			var message = "";
+			new graphql.Client("HTTPBIN_URL/graphql").subscribe("subscription Unknown { count }", null, {
+				onError: function(errors) { message = errors[0].message; },
+			});
+		`))
+		require.NoError(t, err)
+		assert.Equal(t, "no such subscription", vu.Runtime().Get("message").String())
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		t.Parallel()
+		tb, vu, _ := newTestVU(t)
+		newServer(tb, t)
+		require.NoError(t, vu.Runtime().Set("url", tb.Replacer.Replace("HTTPBIN_URL/graphql")))
+		_, err := vu.Runtime().RunString(`var client = new graphql.Client(url)`)
+		require.NoError(t, err)
+
+		tests := map[string]string{
+			`new graphql.Client("ftp://example.com")`:                      "it needs to be an HTTP or HTTPS URL",
+			`new graphql.Client(url, { retries: 1 })`:                      "unknown Client param",
+			`new graphql.Client(url, { persistedQueries: "yes" })`:         "needs to be a boolean",
+			`client.query("query A { a } query B { b }")`:                  "the operationName param is needed",
+			`client.query("query A { a }", null, { operationName: "B" })`:  `doesn't have an operation named "B"`,
+			`client.query("fragment F on User { id }")`:                    "doesn't have an operation",
+			`client.query("{ a { b }")`:                                    "unmatched '{'",
+			`client.query("{ a }", null, { onNext: function() {} })`:       "needs to be a function of a subscription",
+			`client.query("{ a }", null, { tags: "tag" })`:                 "metric tags must be an object",
+			`client.subscribe("subscription S { s }", null, { limit: 0 })`: "invalid limit param",
+			`client.subscribe("{ a }")`:                                    "can't be executed by subscribe()",
+		}
+		for script, msg := range tests {
+			_, err := vu.Runtime().RunString(script)
+			require.Error(t, err, script)
+			assert.Contains(t, err.Error(), msg, script)
+		}
+	})
+
+	t.Run("InitContext", func(t *testing.T) {
+		t.Parallel()
+		_, vu, _ := newTestVU(t)
+		vu.StateField = nil
+
+		_, err := vu.Runtime().RunString(`new graphql.Client("http://127.0.0.1/graphql").query("{ a }")`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "using GraphQL in the init context is not supported")
+	})
+}
+
+func TestFindOperation(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		document, name string
+		expected       operation
+	}{
+		{"{ user { id } }", "", operation{kind: "query"}},
+		{"query { user { id } }", "", operation{kind: "query"}},
+		{"query GetUser($id: ID = \"}\") @cached { user(id: $id) { id } }", "", operation{kind: "query", name: "GetUser"}},
+		{"# mutation Commented {\nmutation AddUser { add { id } }", "", operation{kind: "mutation", name: "AddUser"}},
+		{"fragment F on U { id }\nsubscription OnUser { u { ...F } }", "", operation{kind: "subscription", name: "OnUser"}},
+		{"query A { a(s: \"{\") } query B { b(s: \"\"\"\n}\"\"\") }", "B", operation{kind: "query", name: "B"}},
+	}
+	for _, tc := range tests {
+		op, err := findOperation(tc.document, tc.name)
+		require.NoError(t, err, tc.document)
+		assert.Equal(t, tc.expected, op, tc.document)
+	}
+}
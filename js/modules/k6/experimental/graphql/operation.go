@@ -0,0 +1,147 @@
+package graphql
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// operation is an operation definition of a GraphQL document.
+type operation struct {
+	// kind is query, mutation or subscription
+	kind string
+	name string
+}
+
+// findOperation returns the operation of the document that is executed, the one with the name if
+// it's set, or else its only operation.
+func findOperation(document, name string) (operation, error) {
+	ops, err := operations(document)
+	if err != nil {
+		return operation{}, err
+	}
+	if name != "" {
+		for _, op := range ops {
+			if op.name == name {
+				return op, nil
+			}
+		}
+		return operation{}, fmt.Errorf("the document doesn't have an operation named %q", name)
+	}
+	switch len(ops) {
+	case 0:
+		return operation{}, errors.New("the document doesn't have an operation")
+	case 1:
+		return ops[0], nil
+	default:
+		return operation{}, errors.New("the document has several operations, the operationName param is needed")
+	}
+}
+
+// operations returns the operation definitions of the document. It only tokenizes the document
+// enough to find them, the validation of the document is left to the server.
+func operations(document string) ([]operation, error) {
+	var (
+		ops   []operation
+		depth int
+		// words has the names at the top level since the last selection set
+		words []string
+	)
+	for i := 0; i < len(document); i++ {
+		switch c := document[i]; {
+		case c == '#':
+			for i < len(document) && document[i] != '\n' {
+				i++
+			}
+		case c == '"':
+			end, err := skipString(document, i)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+		case c == '{':
+			if depth == 0 {
+				if op, ok := definition(words); ok {
+					ops = append(ops, op)
+				}
+				words = words[:0]
+			}
+			depth++
+		case c == '}':
+			if depth--; depth < 0 {
+				return nil, errors.New("invalid GraphQL document, it has an unmatched '}'")
+			}
+		case c == '(':
+			// the variable definitions, or the arguments of a directive, don't have names of the definition
+			for depth == 0 && i < len(document) && document[i] != ')' {
+				i++
+			}
+		case c == '@':
+			// the names of directives aren't names of the definition
+			for i+1 < len(document) && isNameContinue(document[i+1]) {
+				i++
+			}
+		case isNameStart(c):
+			start := i
+			for i+1 < len(document) && isNameContinue(document[i+1]) {
+				i++
+			}
+			if depth == 0 {
+				words = append(words, document[start:i+1])
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, errors.New("invalid GraphQL document, it has an unmatched '{'")
+	}
+	return ops, nil
+}
+
+// definition returns the operation of the words before a top level selection set, which are
+// nothing for the query shorthand.
+func definition(words []string) (operation, bool) {
+	if len(words) == 0 {
+		return operation{kind: "query"}, true
+	}
+	switch words[0] {
+	case "query", "mutation", "subscription":
+		op := operation{kind: words[0]}
+		if len(words) > 1 {
+			op.name = words[1]
+		}
+		return op, true
+	default:
+		// fragments, and the type system definitions
+		return operation{}, false
+	}
+}
+
+// skipString returns the index of the closing quote of the string or block string at i.
+func skipString(document string, i int) (int, error) {
+	if strings.HasPrefix(document[i:], `"""`) {
+		end := strings.Index(document[i+3:], `"""`)
+		if end < 0 {
+			return 0, errors.New("invalid GraphQL document, it has an unterminated block string")
+		}
+		return i + 3 + end + 2, nil
+	}
+	for j := i + 1; j < len(document); j++ {
+		switch document[j] {
+		case '\\':
+			j++
+		case '"':
+			return j, nil
+		case '\n':
+			return 0, errors.New("invalid GraphQL document, it has an unterminated string")
+		}
+	}
+	return 0, errors.New("invalid GraphQL document, it has an unterminated string")
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameContinue(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,173 @@
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modules/k6/ws"
+	"go.k6.io/k6/stats"
+)
+
+// subprotocol is the subprotocol of the graphql-ws library, see
+// https://github.com/enisdenjo/graphql-ws/blob/master/PROTOCOL.md
+const subprotocol = "graphql-transport-ws"
+
+// subscriptionID is the ID of the only subscription of each connection.
+const subscriptionID = "1"
+
+// message is a message of the graphql-ws protocol.
+type message struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// Subscribe executes a subscription operation over a WebSocket connection, which is open until the
+// server completes the subscription, sends an error, or until the limit of events or the timeout.
+// Its onNext, onError and onComplete callbacks are called with the events. It returns the number of
+// events that were received.
+func (c *Client) Subscribe(subscription string, variables goja.Value, params goja.Value) (int64, error) {
+	state := c.mi.vu.State()
+	if state == nil {
+		return 0, errInInitContext
+	}
+	p, err := c.parseParams(params, true)
+	if err != nil {
+		return 0, err
+	}
+	op, err := findOperation(subscription, p.operationName)
+	if err != nil {
+		return 0, err
+	}
+	if op.kind != "subscription" {
+		return 0, fmt.Errorf("the operation is a %s, it can't be executed by subscribe()", op.kind)
+	}
+
+	payload := map[string]interface{}{"query": subscription}
+	if !isNullish(variables) {
+		payload["variables"] = variables.Export()
+	}
+	if op.name != "" {
+		payload["operationName"] = op.name
+	}
+	subscribe, err := json.Marshal(payload)
+	if err != nil {
+		return 0, fmt.Errorf("the variables can't be encoded to JSON: %w", err)
+	}
+	init := message{Type: "connection_init"}
+	if c.connectionParams != nil {
+		if init.Payload, err = json.Marshal(c.connectionParams); err != nil {
+			return 0, fmt.Errorf("the connectionParams can't be encoded to JSON: %w", err)
+		}
+	}
+
+	headers := map[string]string{"Sec-WebSocket-Protocol": subprotocol}
+	for _, h := range []map[string]string{c.headers, p.headers} {
+		for k, v := range h {
+			headers[k] = v
+		}
+	}
+	tags := c.operationTags(op, p)
+	s := &subscriptionSession{client: c, params: p, subscribe: subscribe, tags: state.CloneTags()}
+	if state.Options.SystemTags.Has(stats.TagURL) {
+		s.tags["url"] = c.wsURL
+	}
+	for k, v := range tags {
+		s.tags[k] = v
+	}
+
+	rt := c.mi.vu.Runtime()
+	wsParams := map[string]interface{}{"headers": headers, "tags": tags}
+	_, err = c.mi.ws.Connect(c.wsURL, rt.ToValue(wsParams), rt.ToValue(func(socket *ws.Socket) {
+		s.socket = socket
+		socket.On("open", rt.ToValue(func() { s.send(init) }))
+		socket.On("message", rt.ToValue(s.receive))
+		if p.timeout > 0 {
+			err := socket.SetTimeout(func(goja.Value, ...goja.Value) (goja.Value, error) {
+				s.complete()
+				return goja.Undefined(), nil
+			}, float64(p.timeout)/float64(time.Millisecond))
+			if err != nil {
+				common.Throw(rt, err)
+			}
+		}
+	}))
+	return s.events, err
+}
+
+// subscriptionSession handles the messages of a subscription, in the event loop of the WebSocket connection.
+type subscriptionSession struct {
+	client    *Client
+	params    operationParams
+	subscribe json.RawMessage
+	tags      map[string]string
+
+	socket *ws.Socket
+	events int64
+}
+
+func (s *subscriptionSession) send(msg message) {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		common.Throw(s.client.mi.vu.Runtime(), err)
+	}
+	s.socket.Send(string(b))
+}
+
+// complete stops the subscription and closes the connection.
+func (s *subscriptionSession) complete() {
+	s.send(message{ID: subscriptionID, Type: "complete"})
+	s.socket.Close()
+}
+
+func (s *subscriptionSession) receive(data string) {
+	rt := s.client.mi.vu.Runtime()
+	var msg message
+	if err := json.Unmarshal([]byte(data), &msg); err != nil {
+		common.Throw(rt, fmt.Errorf("invalid graphql-ws message: %w", err))
+	}
+	var payload interface{}
+	if len(msg.Payload) > 0 {
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			common.Throw(rt, fmt.Errorf("invalid payload of the graphql-ws %s message: %w", msg.Type, err))
+		}
+	}
+
+	switch msg.Type {
+	case "connection_ack":
+		s.send(message{ID: subscriptionID, Type: "subscribe", Payload: s.subscribe})
+	case "ping":
+		s.send(message{Type: "pong"})
+	case "next":
+		s.events++
+		s.client.push(s.client.mi.vu.State().BuiltinMetrics.GraphQLSubscriptionEvents, 1, s.tags)
+		s.call(s.params.onNext, payload)
+		if s.params.limit > 0 && s.events >= s.params.limit {
+			s.complete()
+		}
+	case "error":
+		s.call(s.params.onError, payload)
+		s.socket.Close()
+	case "complete":
+		s.call(s.params.onComplete, nil)
+		s.socket.Close()
+	}
+}
+
+func (s *subscriptionSession) call(fn goja.Callable, payload interface{}) {
+	if fn == nil {
+		return
+	}
+	rt := s.client.mi.vu.Runtime()
+	var args []goja.Value
+	if payload != nil {
+		args = append(args, rt.ToValue(payload))
+	}
+	if _, err := fn(goja.Undefined(), args...); err != nil {
+		common.Throw(rt, err)
+	}
+}
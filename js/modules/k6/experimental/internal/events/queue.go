@@ -0,0 +1,92 @@
+// Package events implements the queue of the events of the connections of the experimental modules,
+// whose callbacks are run on the event loop of the VU.
+package events
+
+import (
+	"sync"
+
+	"go.k6.io/k6/js/modules"
+)
+
+// Queue runs the functions queued by a connection on the event loop, which it keeps waiting
+// for them while it's held, e.g. as long as the client has subscriptions.
+type Queue struct {
+	vu modules.VU
+
+	mu   sync.Mutex
+	held bool
+	// runOnLoop is the callback registered to run the queued functions, it's nil while the
+	// ones queued with it haven't been run yet, as a new one can only be registered on the loop
+	runOnLoop func(func() error)
+	queued    []func() error
+}
+
+// NewQueue returns a new Queue, which isn't held.
+func NewQueue(vu modules.VU) *Queue {
+	return &Queue{vu: vu}
+}
+
+// Hold keeps the event loop waiting for the queued functions, it must be called on the event loop.
+func (q *Queue) Hold() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.held {
+		return
+	}
+	q.held = true
+	if q.runOnLoop == nil && q.queued == nil {
+		q.runOnLoop = q.vu.RegisterCallback()
+	}
+}
+
+// Release lets the event loop finish, after running what is already queued.
+// It can be called from any goroutine.
+func (q *Queue) Release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.held = false
+	q.schedule()
+}
+
+// Queue queues f to be run on the event loop, it returns false if the queue isn't held,
+// in which case f is dropped. It can be called from any goroutine.
+func (q *Queue) Queue(f func() error) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.held {
+		return false
+	}
+	q.queued = append(q.queued, f)
+	q.schedule()
+	return true
+}
+
+// schedule has the registered callback run the queued functions, the lock has to be held.
+func (q *Queue) schedule() {
+	if q.runOnLoop == nil {
+		return
+	}
+	runOnLoop := q.runOnLoop
+	q.runOnLoop = nil
+	if q.queued == nil {
+		q.queued = []func() error{}
+	}
+	runOnLoop(q.run)
+}
+
+func (q *Queue) run() error {
+	q.mu.Lock()
+	queued := q.queued
+	q.queued = nil
+	if q.held && q.vu.Context().Err() == nil {
+		q.runOnLoop = q.vu.RegisterCallback()
+	}
+	q.mu.Unlock()
+
+	for _, f := range queued {
+		if err := f(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/lib/netext/kafkaext"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/stats"
+)
+
+// Consumer consumes the messages of a partition of a topic, from its position which is
+// kept between the calls of consume(). Like the Producer, it can be created in the init context.
+type Consumer struct {
+	vu        modules.VU
+	client    *client
+	topic     string
+	partition int32
+	// start is the offset, or the earliestOffset or latestOffset timestamp, of the first consumed message
+	start    int64
+	maxWait  time.Duration
+	maxBytes int32
+
+	keyDeserializer   deserializer
+	valueDeserializer deserializer
+	// position is the offset of the next message, -1 until it's known
+	position int64
+}
+
+// NewConsumer is the JS constructor for the Consumer.
+func (mi *ModuleInstance) NewConsumer(call goja.ConstructorCall) *goja.Object {
+	rt := mi.vu.Runtime()
+	c, err := newConsumer(rt, mi.vu, call.Argument(0))
+	if err != nil {
+		common.Throw(rt, err)
+	}
+	return rt.ToValue(c).ToObject(rt)
+}
+
+func newConsumer(rt *goja.Runtime, vu modules.VU, params goja.Value) (*Consumer, error) {
+	if isNullish(params) {
+		return nil, errors.New("the Consumer needs a config with at least the brokers and the topic")
+	}
+	cfg := clientConfig{Config: kafkaext.Config{ClientID: "k6", Timeout: kafkaext.DefaultTimeout}}
+	c := &Consumer{
+		vu:       vu,
+		start:    kafkaext.LatestOffset,
+		maxWait:  defaultMaxWait,
+		maxBytes: defaultMaxBytes,
+		position: -1,
+	}
+	var err error
+	if c.keyDeserializer, err = newDeserializer(rt, rt.ToValue("string")); err != nil {
+		return nil, err
+	}
+	c.valueDeserializer = c.keyDeserializer
+
+	obj := params.ToObject(rt)
+	for _, k := range obj.Keys() {
+		v := obj.Get(k)
+		if ok, err := cfg.parse(rt, k, v); ok {
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		switch k {
+		case "topic":
+			c.topic = v.String()
+		case "partition":
+			if c.partition = int32(v.ToInteger()); c.partition < 0 {
+				return nil, fmt.Errorf("invalid partition %d", c.partition)
+			}
+		case "offset":
+			switch v.String() {
+			case "earliest":
+				c.start = kafkaext.EarliestOffset
+			case "latest":
+				c.start = kafkaext.LatestOffset
+			default:
+				if n := v.ToNumber(); goja.IsNaN(n) || n.ToInteger() < 0 {
+					return nil, fmt.Errorf("invalid offset %q, it needs to be earliest, latest or an offset", v)
+				}
+				c.start = v.ToInteger()
+			}
+		case "maxWait":
+			if c.maxWait, err = types.GetDurationValue(v.Export()); err != nil || c.maxWait <= 0 {
+				return nil, fmt.Errorf("invalid maxWait value '%s'", v)
+			}
+		case "maxBytes":
+			if c.maxBytes = int32(v.ToInteger()); c.maxBytes <= 0 {
+				return nil, fmt.Errorf("invalid maxBytes value '%s'", v)
+			}
+		case "keyDeserializer":
+			if c.keyDeserializer, err = newDeserializer(rt, v); err != nil {
+				return nil, err
+			}
+		case "valueDeserializer":
+			if c.valueDeserializer, err = newDeserializer(rt, v); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unknown Consumer param: %q", k)
+		}
+	}
+	if len(cfg.Brokers) == 0 {
+		return nil, errors.New("the Consumer needs the brokers param")
+	}
+	if c.topic == "" {
+		return nil, errors.New("the Consumer needs the topic param")
+	}
+	if c.maxWait >= cfg.Timeout {
+		return nil, fmt.Errorf("the maxWait of the Consumer, %s, needs to be shorter than its timeout, %s",
+			c.maxWait, cfg.Timeout)
+	}
+	c.client = newClient(vu, cfg)
+	return c, nil
+}
+
+// Consume returns the next messages of the partition, it waits for them until it has the limit of messages
+// in its params, 1 by default, or for the maxWait of the Consumer.
+func (c *Consumer) Consume(params goja.Value) []map[string]interface{} {
+	rt := c.vu.Runtime()
+	if c.vu.State() == nil {
+		common.Throw(rt, errInInitContext)
+	}
+	limit := int64(1)
+	if !isNullish(params) {
+		if v := params.ToObject(rt).Get("limit"); !isNullish(v) {
+			if limit = v.ToInteger(); limit <= 0 {
+				common.Throw(rt, fmt.Errorf("invalid limit %d", limit))
+			}
+		}
+	}
+	messages, err := c.consume(int(limit))
+	if err != nil {
+		common.Throw(rt, err)
+	}
+	return messages
+}
+
+func (c *Consumer) consume(limit int) ([]map[string]interface{}, error) {
+	client, err := c.client.get()
+	if err != nil {
+		return nil, err
+	}
+	ctx := c.vu.Context()
+	if c.position < 0 {
+		if err = c.seek(client); err != nil {
+			return nil, err
+		}
+	}
+
+	var records []kafkaext.Record
+	deadline := time.Now().Add(c.maxWait)
+	for len(records) < limit {
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			break
+		}
+		rs, err := client.Fetch(ctx, c.topic, c.partition, c.position, wait, c.maxBytes)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rs {
+			// the batches can start before the position
+			if r.Offset < c.position || len(records) == limit {
+				continue
+			}
+			records = append(records, r)
+			c.position = r.Offset + 1
+		}
+	}
+
+	now := time.Now()
+	builtinMetrics := c.vu.State().BuiltinMetrics
+	messages := make([]map[string]interface{}, 0, len(records))
+	for _, r := range records {
+		m, err := c.message(r)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+		c.client.push(builtinMetrics.KafkaEndToEndLatency, stats.D(now.Sub(r.Timestamp)), c.topic, now)
+	}
+	if len(records) > 0 {
+		c.client.push(builtinMetrics.KafkaMessagesConsumed, float64(len(records)), c.topic, now)
+	}
+	return messages, nil
+}
+
+// seek sets the position to the start offset, which is requested from the leader of the partition
+// if it's the earliest or the latest one.
+func (c *Consumer) seek(client *kafkaext.Client) error {
+	if c.start >= 0 {
+		c.position = c.start
+		return nil
+	}
+	var err error
+	c.position, err = client.ListOffsets(c.vu.Context(), c.topic, c.partition, c.start)
+	return err
+}
+
+func (c *Consumer) message(r kafkaext.Record) (map[string]interface{}, error) {
+	m := map[string]interface{}{
+		"topic":     c.topic,
+		"partition": c.partition,
+		"offset":    r.Offset,
+		"timestamp": r.Timestamp.UnixMilli(),
+		"key":       goja.Null(),
+		"value":     goja.Null(),
+	}
+	var err error
+	if r.Key != nil {
+		if m["key"], err = c.keyDeserializer(r.Key, c.topic); err != nil {
+			return nil, fmt.Errorf("the key of the message at the offset %d couldn't be deserialized: %w", r.Offset, err)
+		}
+	}
+	if r.Value != nil {
+		if m["value"], err = c.valueDeserializer(r.Value, c.topic); err != nil {
+			return nil, fmt.Errorf("the value of the message at the offset %d couldn't be deserialized: %w",
+				r.Offset, err)
+		}
+	}
+	headers := make(map[string]string, len(r.Headers))
+	for _, h := range r.Headers {
+		headers[h.Key] = string(h.Value)
+	}
+	m["headers"] = headers
+	return m, nil
+}
+
+// Close closes the connections of the consumer.
+func (c *Consumer) Close() {
+	c.client.close()
+}
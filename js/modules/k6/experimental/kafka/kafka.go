@@ -0,0 +1,224 @@
+// Package kafka implements the k6/experimental/kafka module, with a producer and a consumer of Kafka topics.
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/lib/netext/kafkaext"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/stats"
+)
+
+type (
+	// RootModule is the global module instance that will create module
+	// instances for each VU.
+	RootModule struct{}
+
+	// ModuleInstance represents an instance of the kafka module for every VU.
+	ModuleInstance struct {
+		vu modules.VU
+	}
+)
+
+var (
+	_ modules.Module   = &RootModule{}
+	_ modules.Instance = &ModuleInstance{}
+)
+
+// New returns a pointer to a new RootModule instance.
+func New() *RootModule {
+	return &RootModule{}
+}
+
+// NewModuleInstance implements the modules.Module interface to return
+// a new instance for each VU.
+func (*RootModule) NewModuleInstance(vu modules.VU) modules.Instance {
+	return &ModuleInstance{vu: vu}
+}
+
+// Exports returns the exports of the kafka module.
+func (mi *ModuleInstance) Exports() modules.Exports {
+	return modules.Exports{
+		Named: map[string]interface{}{
+			"Producer": mi.NewProducer,
+			"Consumer": mi.NewConsumer,
+		},
+	}
+}
+
+const (
+	defaultMaxWait  = time.Second
+	defaultMaxBytes = 1 << 20
+)
+
+var errInInitContext = common.NewInitContextError("using Kafka in the init context is not supported")
+
+// clientConfig is the config shared by the producers and the consumers.
+type clientConfig struct {
+	kafkaext.Config
+	Tags map[string]string
+}
+
+// parse parses a param of the config, it returns false if it isn't one of them.
+func (cfg *clientConfig) parse(rt *goja.Runtime, k string, v goja.Value) (bool, error) {
+	var err error
+	switch k {
+	case "brokers":
+		if err = rt.ExportTo(v, &cfg.Brokers); err != nil || len(cfg.Brokers) == 0 {
+			return true, errors.New("the brokers param needs to be a non-empty array of host:port addresses")
+		}
+	case "clientId":
+		cfg.ClientID = v.String()
+	case "tls":
+		cfg.TLS = v.ToBoolean()
+	case "sasl":
+		var sasl map[string]string
+		if err = rt.ExportTo(v, &sasl); err != nil {
+			return true, fmt.Errorf("invalid sasl param: %w", err)
+		}
+		cfg.SASL = kafkaext.SASLConfig{
+			Mechanism: sasl["mechanism"], Username: sasl["username"], Password: sasl["password"],
+		}
+		if cfg.SASL.Mechanism == "" {
+			return true, errors.New("the sasl param needs a mechanism")
+		}
+		if err = cfg.SASL.Validate(); err != nil {
+			return true, err
+		}
+	case "timeout":
+		if cfg.Timeout, err = types.GetDurationValue(v.Export()); err != nil || cfg.Timeout <= 0 {
+			return true, fmt.Errorf("invalid timeout value '%s'", v)
+		}
+	case "tags":
+		if err = rt.ExportTo(v, &cfg.Tags); err != nil {
+			return true, fmt.Errorf("metric tags must be an object of string values: %w", err)
+		}
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// client is the client of a cluster of a producer or a consumer, which is created when it's first used,
+// since the dialer and the TLS config of the VU aren't known in the init context.
+type client struct {
+	vu     modules.VU
+	cfg    clientConfig
+	client *kafkaext.Client
+}
+
+func newClient(vu modules.VU, cfg clientConfig) *client {
+	return &client{vu: vu, cfg: cfg}
+}
+
+func (c *client) get() (*kafkaext.Client, error) {
+	if c.client != nil {
+		return c.client, nil
+	}
+	state := c.vu.State()
+	if state == nil {
+		return nil, errInInitContext
+	}
+	c.client = kafkaext.NewClient(c.cfg.Config, state.Dialer, state.TLSConfig)
+	return c.client, nil
+}
+
+func (c *client) close() {
+	if c.client != nil {
+		c.client.Close()
+	}
+}
+
+func (c *client) push(metric *stats.Metric, value float64, topic string, t time.Time) {
+	tags := make(map[string]string, len(c.cfg.Tags)+1)
+	for k, v := range c.vu.State().CloneTags() {
+		tags[k] = v
+	}
+	tags["topic"] = topic
+	for k, v := range c.cfg.Tags {
+		tags[k] = v
+	}
+	stats.PushIfNotDone(c.vu.Context(), c.vu.State().Samples, stats.Sample{
+		Metric: metric,
+		Tags:   stats.IntoSampleTags(&tags),
+		Value:  value,
+		Time:   t,
+	})
+}
+
+// serializer converts the keys and the values of the produced messages to bytes, it's either the name of one
+// of the built-in ones, string or json, or a function that returns a string or an ArrayBuffer, for the other
+// formats like Avro.
+type serializer func(v goja.Value, topic string) ([]byte, error)
+
+func newSerializer(rt *goja.Runtime, v goja.Value) (serializer, error) {
+	if fn, ok := goja.AssertFunction(v); ok {
+		return func(v goja.Value, topic string) ([]byte, error) {
+			res, err := fn(goja.Undefined(), v, rt.ToValue(topic))
+			if err != nil {
+				return nil, err
+			}
+			return common.ToBytes(res.Export())
+		}, nil
+	}
+	switch v.String() {
+	case "string":
+		return func(v goja.Value, _ string) ([]byte, error) {
+			if b, ok := v.Export().(goja.ArrayBuffer); ok {
+				return b.Bytes(), nil
+			}
+			return []byte(v.String()), nil
+		}, nil
+	case "json":
+		stringify, _ := goja.AssertFunction(rt.Get("JSON").ToObject(rt).Get("stringify"))
+		return func(v goja.Value, _ string) ([]byte, error) {
+			res, err := stringify(goja.Undefined(), v)
+			if err != nil {
+				return nil, err
+			}
+			return []byte(res.String()), nil
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid serializer %q, it needs to be string, json or a function", v)
+	}
+}
+
+// deserializer converts the keys and the values of the consumed messages from bytes, it's either the name of
+// one of the built-in ones, string, json or binary for an ArrayBuffer, or a function that gets an ArrayBuffer.
+type deserializer func(b []byte, topic string) (goja.Value, error)
+
+func newDeserializer(rt *goja.Runtime, v goja.Value) (deserializer, error) {
+	if fn, ok := goja.AssertFunction(v); ok {
+		return func(b []byte, topic string) (goja.Value, error) {
+			return fn(goja.Undefined(), rt.ToValue(rt.NewArrayBuffer(b)), rt.ToValue(topic))
+		}, nil
+	}
+	switch v.String() {
+	case "string":
+		return func(b []byte, _ string) (goja.Value, error) {
+			return rt.ToValue(string(b)), nil
+		}, nil
+	case "binary":
+		return func(b []byte, _ string) (goja.Value, error) {
+			return rt.ToValue(rt.NewArrayBuffer(b)), nil
+		}, nil
+	case "json":
+		parse, _ := goja.AssertFunction(rt.Get("JSON").ToObject(rt).Get("parse"))
+		return func(b []byte, _ string) (goja.Value, error) {
+			return parse(goja.Undefined(), rt.ToValue(string(b)))
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid deserializer %q, it needs to be string, json, binary or a function", v)
+	}
+}
+
+// isNullish returns true for the values that are missing, undefined or null.
+func isNullish(v goja.Value) bool {
+	return v == nil || goja.IsUndefined(v) || goja.IsNull(v)
+}
@@ -0,0 +1,199 @@
+package kafka
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/lib/netext/kafkaext"
+	"go.k6.io/k6/lib/testutils/httpmultibin"
+	"go.k6.io/k6/stats"
+)
+
+func newTestVU(t *testing.T) (*httpmultibin.HTTPMultiBin, *modulestest.VU, chan stats.SampleContainer) {
+	t.Helper()
+	tb := httpmultibin.NewHTTPMultiBin(t)
+	vu, samples := modulestest.NewTestVU(t, tb, stats.TagName)
+	m, ok := New().NewModuleInstance(vu).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, vu.Runtime().Set("kafka", m.Exports().Named))
+	return tb, vu, samples
+}
+
+func sampleCounts(samples chan stats.SampleContainer) map[string]float64 {
+	counts := map[string]float64{}
+	for _, c := range stats.GetBufferedSamples(samples) {
+		for _, s := range c.GetSamples() {
+			if topic, _ := s.Tags.Get("topic"); topic == "test" {
+				counts[s.Metric.Name] += s.Value
+			}
+		}
+	}
+	return counts
+}
+
+func TestProducerConsumer(t *testing.T) {
+	t.Parallel()
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		t.Parallel()
+		_, vu, samples := newTestVU(t)
+		broker := kafkaext.NewTestBroker(t, nil, false)
+
+		_, err := vu.Runtime().RunString(strings.ReplaceAll(`
+			var producer = new kafka.Producer({ brokers: ["BROKER"], valueSerializer: "json", tags: { tag: "value" } });
+			var results = producer.produce("test", [
+				{ value: { n: 1 }, partition: 1, headers: { h: "v" } },
+				{ key: "21", value: { n: 2 } },
+				{ value: [3], partition: 1 },
+			]);
+			producer.close();
+
+			var consumer = new kafka.Consumer({
+				brokers: ["BROKER"], topic: "test", partition: 1, offset: "earliest", valueDeserializer: "json",
+			});
+			var messages = consumer.consume({ limit: 10 });
+			var more = consumer.consume();
+			consumer.close();
+		`, "BROKER", broker.Addr))
+		require.NoError(t, err)
+
+		v, err := vu.Runtime().RunString(`JSON.stringify(results)`)
+		require.NoError(t, err)
+		// murmur2("21") is -973932308, so the key "21" is in the partition 0
+		assert.JSONEq(t, `[{"partition":1,"offset":0},{"partition":0,"offset":0},{"partition":1,"offset":1}]`, v.String())
+
+		v, err = vu.Runtime().RunString(`messages.map(function(m) {
+			return [m.offset, m.key, JSON.stringify(m.value), m.headers.h || "", m.timestamp > 0].join(" ");
+		}).join(",") + "|" + more.length`)
+		require.NoError(t, err)
+		assert.Equal(t, `0  {"n":1} v true,1  [3]  true|0`, v.String())
+
+		counts := sampleCounts(samples)
+		assert.Equal(t, float64(3), counts[metrics.KafkaMessagesProducedName])
+		assert.Equal(t, float64(2), counts[metrics.KafkaMessagesConsumedName])
+		assert.Contains(t, counts, metrics.KafkaProduceDurationName)
+		assert.Contains(t, counts, metrics.KafkaEndToEndLatencyName)
+	})
+
+	t.Run("LatestOffset", func(t *testing.T) {
+		t.Parallel()
+		tb, vu, _ := newTestVU(t)
+		broker := kafkaext.NewTestBroker(t, tb.ServerHTTPS.TLS, false)
+
+		_, err := vu.Runtime().RunString(strings.ReplaceAll(`
+			var producer = new kafka.Producer({ brokers: ["BROKER"], tls: true, compression: "gzip", acks: 1 });
+			producer.produce("test", [{ value: "old", partition: 0 }]);
+			var consumer = new kafka.Consumer({ brokers: ["BROKER"], tls: true, topic: "test", maxWait: "100ms" });
+			var before = consumer.consume();
+			producer.produce("test", [{ key: "k", value: "new", partition: 0 }]);
+			var after = consumer.consume({ limit: 5 });
+		`, "BROKER", broker.Addr))
+		require.NoError(t, err)
+
+		v, err := vu.Runtime().RunString(`before.length + " " + after.map(function(m) {
+			return [m.offset, m.key, m.value].join(":");
+		}).join(",")`)
+		require.NoError(t, err)
+		assert.Equal(t, "0 1:k:new", v.String())
+	})
+
+	t.Run("Serializers", func(t *testing.T) {
+		t.Parallel()
+		_, vu, _ := newTestVU(t)
+		broker := kafkaext.NewTestBroker(t, nil, false)
+
+		_, err := vu.Runtime().RunString(strings.ReplaceAll(`
+			var producer = new kafka.Producer({
+				brokers: ["BROKER"],
+				acks: 0,
+				keySerializer: function(key, topic) { return new Uint8Array([key, topic.length]).buffer; },
+				valueSerializer: function(value) { return value.toUpperCase(); },
+			});
+			var results = producer.produce("test", [{ key: 7, value: "value", partition: 0 }]);
+			var consumer = new kafka.Consumer({
+				brokers: ["BROKER"],
+				topic: "test",
+				offset: 0,
+				keyDeserializer: "binary",
+				valueDeserializer: function(b, topic) { return topic + ":" + String.fromCharCode.apply(null, new Uint8Array(b)); },
+			});
+			var m = consumer.consume()[0];
+		`, "BROKER", broker.Addr))
+		require.NoError(t, err)
+
+		v, err := vu.Runtime().RunString(`results[0].offset + " " + new Uint8Array(m.key).join(",") + " " + m.value`)
+		require.NoError(t, err)
+		assert.Equal(t, "-1 7,4 test:VALUE", v.String())
+	})
+
+	for _, mechanism := range []string{"PLAIN", "SCRAM-SHA-256", "SCRAM-SHA-512"} {
+		mechanism := mechanism
+		t.Run("SASL/"+mechanism, func(t *testing.T) {
+			t.Parallel()
+			_, vu, _ := newTestVU(t)
+			broker := kafkaext.NewTestBroker(t, nil, true)
+			require.NoError(t, vu.Runtime().Set("mechanism", mechanism))
+
+			_, err := vu.Runtime().RunString(strings.ReplaceAll(`
+				var producer = new kafka.Producer({
+					brokers: ["BROKER"], sasl: { mechanism: mechanism, username: "k6", password: "secret" },
+				});
+				producer.produce("test", [{ value: "v", partition: 0 }]);
+			`, "BROKER", broker.Addr))
+			require.NoError(t, err)
+
+			_, err = vu.Runtime().RunString(strings.ReplaceAll(`
+				new kafka.Producer({
+					brokers: ["BROKER"], sasl: { mechanism: mechanism, username: "k6", password: "wrong" },
+				}).produce("test", [{ value: "v" }]);
+			`, "BROKER", broker.Addr))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "SASL_AUTHENTICATION_FAILED")
+		})
+	}
+
+	t.Run("Errors", func(t *testing.T) {
+		t.Parallel()
+		_, vu, _ := newTestVU(t)
+		broker := kafkaext.NewTestBroker(t, nil, false)
+
+		tests := map[string]string{
+			`new kafka.Producer()`:                                                                        "needs a config",
+			`new kafka.Producer({ brokers: [] })`:                                                         "non-empty array",
+			`new kafka.Producer({ brokers: ["BROKER"], acks: 2 })`:                                        "invalid acks",
+			`new kafka.Producer({ brokers: ["BROKER"], compression: "zstd" })`:                            "invalid compression",
+			`new kafka.Producer({ brokers: ["BROKER"], valueSerializer: "avro" })`:                        "invalid serializer",
+			`new kafka.Producer({ brokers: ["BROKER"], sasl: { mechanism: "GSSAPI" } })`:                  "invalid SASL mechanism",
+			`new kafka.Producer({ brokers: ["BROKER"], unknown: 1 })`:                                     "unknown Producer param",
+			`new kafka.Consumer({ brokers: ["BROKER"] })`:                                                 "needs the topic",
+			`new kafka.Consumer({ brokers: ["BROKER"], topic: "test", maxWait: "1m" })`:                   "shorter than its timeout",
+			`new kafka.Consumer({ brokers: ["BROKER"], topic: "test", offset: "first" })`:                 "invalid offset",
+			`new kafka.Producer({ brokers: ["BROKER"] }).produce("missing", [{ value: "v" }])`:            "UNKNOWN_TOPIC_OR_PARTITION",
+			`new kafka.Producer({ brokers: ["BROKER"] }).produce("test", [{ value: "v", partition: 5 }])`: "no partition 5",
+			`new kafka.Consumer({ brokers: ["BROKER"], topic: "test" }).consume({ limit: 0 })`:            "invalid limit",
+		}
+		for script, msg := range tests {
+			_, err := vu.Runtime().RunString(strings.ReplaceAll(script, "BROKER", broker.Addr))
+			require.Error(t, err, script)
+			assert.Contains(t, err.Error(), msg, script)
+		}
+	})
+
+	t.Run("InitContext", func(t *testing.T) {
+		t.Parallel()
+		_, vu, _ := newTestVU(t)
+		vu.StateField = nil
+
+		_, err := vu.Runtime().RunString(`
+			var producer = new kafka.Producer({ brokers: ["127.0.0.1:9092"] });
+			producer.produce("test", [{ value: "v" }]);
+		`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "using Kafka in the init context is not supported")
+	})
+}
@@ -0,0 +1,219 @@
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/lib/netext/kafkaext"
+	"go.k6.io/k6/stats"
+)
+
+// Producer produces messages to the topics of a cluster, it connects to the brokers when it first needs them
+// and keeps its connections until it's closed, so it can be created in the init context and used by all
+// the iterations of a VU.
+type Producer struct {
+	vu          modules.VU
+	client      *client
+	acks        int16
+	compression int16
+
+	keySerializer   serializer
+	valueSerializer serializer
+	// next is the partition of the next message without a key nor a partition
+	next int32
+}
+
+// NewProducer is the JS constructor for the Producer.
+func (mi *ModuleInstance) NewProducer(call goja.ConstructorCall) *goja.Object {
+	rt := mi.vu.Runtime()
+	p, err := newProducer(rt, mi.vu, call.Argument(0))
+	if err != nil {
+		common.Throw(rt, err)
+	}
+	return rt.ToValue(p).ToObject(rt)
+}
+
+func newProducer(rt *goja.Runtime, vu modules.VU, params goja.Value) (*Producer, error) {
+	if isNullish(params) {
+		return nil, errors.New("the Producer needs a config with at least the brokers")
+	}
+	cfg := clientConfig{Config: kafkaext.Config{ClientID: "k6", Timeout: kafkaext.DefaultTimeout}}
+	p := &Producer{vu: vu, acks: -1}
+	var err error
+	if p.keySerializer, err = newSerializer(rt, rt.ToValue("string")); err != nil {
+		return nil, err
+	}
+	p.valueSerializer = p.keySerializer
+
+	obj := params.ToObject(rt)
+	for _, k := range obj.Keys() {
+		v := obj.Get(k)
+		if ok, err := cfg.parse(rt, k, v); ok {
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		switch k {
+		case "acks":
+			switch acks := v.ToInteger(); acks {
+			case -1, 0, 1:
+				p.acks = int16(acks)
+			default:
+				return nil, fmt.Errorf("invalid acks value %d, it needs to be -1 (all), 0 or 1", acks)
+			}
+		case "compression":
+			switch v.String() {
+			case "none":
+				p.compression = kafkaext.CompressionNone
+			case "gzip":
+				p.compression = kafkaext.CompressionGzip
+			default:
+				return nil, fmt.Errorf("invalid compression %q, it needs to be none or gzip", v)
+			}
+		case "keySerializer":
+			if p.keySerializer, err = newSerializer(rt, v); err != nil {
+				return nil, err
+			}
+		case "valueSerializer":
+			if p.valueSerializer, err = newSerializer(rt, v); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unknown Producer param: %q", k)
+		}
+	}
+	if len(cfg.Brokers) == 0 {
+		return nil, errors.New("the Producer needs the brokers param")
+	}
+	p.client = newClient(vu, cfg)
+	return p, nil
+}
+
+// Produce produces messages to a topic, which are objects with a value and optionally a key, headers and
+// a partition. It returns the partition and the offset of every message.
+func (p *Producer) Produce(topic string, messages []goja.Value) []map[string]interface{} {
+	rt := p.vu.Runtime()
+	if p.vu.State() == nil {
+		common.Throw(rt, errInInitContext)
+	}
+	results, err := p.produce(topic, messages)
+	if err != nil {
+		common.Throw(rt, err)
+	}
+	return results
+}
+
+func (p *Producer) produce(topic string, messages []goja.Value) ([]map[string]interface{}, error) {
+	rt := p.vu.Runtime()
+	client, err := p.client.get()
+	if err != nil {
+		return nil, err
+	}
+	ctx := p.vu.Context()
+	partitions, err := client.Partitions(ctx, topic, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// the records of every partition, in the order of the first of their messages
+	var order []int32
+	batches := make(map[int32][]kafkaext.Record)
+	indexes := make(map[int32][]int)
+	now := time.Now()
+	for i, m := range messages {
+		if isNullish(m) {
+			return nil, fmt.Errorf("the message %d is null", i)
+		}
+		r, partition, err := p.record(rt, topic, m.ToObject(rt), now)
+		if err != nil {
+			return nil, fmt.Errorf("the message %d: %w", i, err)
+		}
+		if partition < 0 {
+			partition = p.partition(r.Key, partitions)
+		}
+		if _, ok := batches[partition]; !ok {
+			order = append(order, partition)
+		}
+		batches[partition] = append(batches[partition], r)
+		indexes[partition] = append(indexes[partition], i)
+	}
+
+	results := make([]map[string]interface{}, len(messages))
+	for _, partition := range order {
+		start := time.Now()
+		offset, err := client.Produce(ctx, topic, partition, batches[partition], p.acks, p.compression)
+		end := time.Now()
+		if err != nil {
+			return nil, err
+		}
+		builtinMetrics := p.vu.State().BuiltinMetrics
+		p.client.push(builtinMetrics.KafkaProduceDuration, stats.D(end.Sub(start)), topic, end)
+		p.client.push(builtinMetrics.KafkaMessagesProduced, float64(len(batches[partition])), topic, end)
+
+		for j, i := range indexes[partition] {
+			result := map[string]interface{}{"partition": partition, "offset": int64(-1)}
+			if offset >= 0 {
+				result["offset"] = offset + int64(j)
+			}
+			results[i] = result
+		}
+	}
+	return results, nil
+}
+
+// record returns the record of a message and its partition, which is -1 if it isn't set.
+func (p *Producer) record(
+	rt *goja.Runtime, topic string, m *goja.Object, now time.Time,
+) (kafkaext.Record, int32, error) {
+	r := kafkaext.Record{Timestamp: now}
+	partition := int32(-1)
+	var err error
+	if v := m.Get("key"); !isNullish(v) {
+		if r.Key, err = p.keySerializer(v, topic); err != nil {
+			return r, 0, fmt.Errorf("the key couldn't be serialized: %w", err)
+		}
+	}
+	if v := m.Get("value"); !isNullish(v) {
+		if r.Value, err = p.valueSerializer(v, topic); err != nil {
+			return r, 0, fmt.Errorf("the value couldn't be serialized: %w", err)
+		}
+	}
+	if v := m.Get("headers"); !isNullish(v) {
+		headers := v.ToObject(rt)
+		for _, k := range headers.Keys() {
+			hv := headers.Get(k)
+			value := []byte(hv.String())
+			if b, ok := hv.Export().(goja.ArrayBuffer); ok {
+				value = b.Bytes()
+			}
+			r.Headers = append(r.Headers, kafkaext.Header{Key: k, Value: value})
+		}
+	}
+	if v := m.Get("partition"); !isNullish(v) {
+		if partition = int32(v.ToInteger()); partition < 0 {
+			return r, 0, fmt.Errorf("invalid partition %d", partition)
+		}
+	}
+	return r, partition, nil
+}
+
+// partition returns the partition of a message, from the hash of its key like the default partitioner
+// of the Kafka clients, or in turn for the messages without a key.
+func (p *Producer) partition(key []byte, partitions []int32) int32 {
+	if key == nil {
+		p.next = (p.next + 1) % int32(len(partitions))
+		return partitions[p.next]
+	}
+	return kafkaext.KeyPartition(key, partitions)
+}
+
+// Close closes the connections of the producer.
+func (p *Producer) Close() {
+	p.client.close()
+}
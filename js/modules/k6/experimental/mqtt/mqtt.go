@@ -0,0 +1,847 @@
+// Package mqtt implements the k6/experimental/mqtt module, with a client for MQTT 3.1.1 and 5 brokers.
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/js/modules/k6/experimental/internal/events"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/stats"
+)
+
+type (
+	// RootModule is the global module instance that will create module
+	// instances for each VU.
+	RootModule struct{}
+
+	// ModuleInstance represents an instance of the mqtt module for every VU.
+	ModuleInstance struct {
+		vu modules.VU
+	}
+)
+
+var (
+	_ modules.Module   = &RootModule{}
+	_ modules.Instance = &ModuleInstance{}
+)
+
+// New returns a pointer to a new RootModule instance.
+func New() *RootModule {
+	return &RootModule{}
+}
+
+// NewModuleInstance implements the modules.Module interface to return
+// a new instance for each VU.
+func (*RootModule) NewModuleInstance(vu modules.VU) modules.Instance {
+	return &ModuleInstance{vu: vu}
+}
+
+// Exports returns the exports of the mqtt module.
+func (mi *ModuleInstance) Exports() modules.Exports {
+	return modules.Exports{
+		Named: map[string]interface{}{
+			"Client": mi.NewClient,
+		},
+	}
+}
+
+// NewClient is the JS constructor for the mqtt Client.
+func (mi *ModuleInstance) NewClient(call goja.ConstructorCall) *goja.Object {
+	rt := mi.vu.Runtime()
+	c := &Client{
+		vu:            mi.vu,
+		handlers:      make(map[string][]goja.Callable),
+		subscriptions: make(map[string]bool),
+		events:        events.NewQueue(mi.vu),
+	}
+	return rt.ToValue(c).ToObject(rt)
+}
+
+const (
+	defaultKeepAlive = 60 * time.Second
+	defaultTimeout   = 10 * time.Second
+)
+
+var (
+	errConnectInInitContext = common.NewInitContextError("connecting to an MQTT broker in the init context is not supported")
+	errNotConnected         = errors.New("the MQTT client isn't connected")
+	errClosed               = errors.New("the MQTT connection was closed")
+)
+
+// Client is an MQTT client, which can be connected once. Its connection is closed at the latest when the
+// iteration ends, and while it has subscriptions, the iteration waits for the messages delivered to the
+// "message" handlers.
+type Client struct {
+	vu modules.VU
+
+	// set by Connect
+	conn    net.Conn
+	version byte
+	tags    map[string]string
+	timeout time.Duration
+	// ctx is cancelled when the connection is closed, after err is set
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	writeMu sync.Mutex
+	pingMu  sync.Mutex
+	pongs   chan struct{}
+
+	mu     sync.Mutex
+	err    error
+	nextID uint16
+	// pending has the channels of the packets that wait for an acknowledgement, by packet identifier
+	pending map[uint16]chan *packet
+	// incoming has the QoS 2 messages that were received, but not released by a PUBREL yet
+	incoming map[uint16]bool
+
+	// only accessed from the event loop
+	handlers      map[string][]goja.Callable
+	subscriptions map[string]bool
+	events        *events.Queue
+}
+
+// connectParams are the params of connect().
+type connectParams struct {
+	ClientID     string
+	Username     string
+	Password     string
+	Version      byte
+	CleanSession bool
+	KeepAlive    time.Duration
+	Timeout      time.Duration
+	Tags         map[string]string
+}
+
+func (c *Client) parseConnectParams(raw map[string]interface{}) (connectParams, error) {
+	p := connectParams{
+		Version:      version311,
+		CleanSession: true,
+		KeepAlive:    defaultKeepAlive,
+		Timeout:      defaultTimeout,
+	}
+	var err error
+	for k, v := range raw {
+		switch k {
+		case "clientId":
+			p.ClientID, _ = v.(string)
+		case "username":
+			p.Username, _ = v.(string)
+		case "password":
+			p.Password, _ = v.(string)
+		case "version":
+			switch fmt.Sprint(v) {
+			case "3.1.1", "4":
+				p.Version = version311
+			case "5", "5.0":
+				p.Version = version5
+			default:
+				return p, fmt.Errorf("invalid MQTT version %q, it needs to be 3.1.1 or 5", fmt.Sprint(v))
+			}
+		case "cleanSession":
+			var ok bool
+			if p.CleanSession, ok = v.(bool); !ok {
+				return p, errors.New("the cleanSession param needs to be a boolean")
+			}
+		case "keepAlive":
+			p.KeepAlive, err = types.GetDurationValue(v)
+			if err != nil || p.KeepAlive < 0 || p.KeepAlive > 0xffff*time.Second {
+				return p, fmt.Errorf("invalid keepAlive value '%#v'", v)
+			}
+		case "timeout":
+			p.Timeout, err = types.GetDurationValue(v)
+			if err != nil || p.Timeout <= 0 {
+				return p, fmt.Errorf("invalid timeout value '%#v'", v)
+			}
+		case "tags":
+			tags, ok := v.(map[string]interface{})
+			if !ok {
+				return p, fmt.Errorf("metric tags must be an object of string values, got '%#v'", v)
+			}
+			p.Tags = make(map[string]string, len(tags))
+			for name, tag := range tags {
+				p.Tags[name] = fmt.Sprint(tag)
+			}
+		default:
+			return p, fmt.Errorf("unknown connect param: %q", k)
+		}
+	}
+	if p.ClientID == "" {
+		id := make([]byte, 8)
+		if _, err := rand.Read(id); err != nil {
+			return p, err
+		}
+		p.ClientID = "k6-" + hex.EncodeToString(id)
+	}
+	return p, nil
+}
+
+// brokerAddress returns the address to dial for the URL of a broker and whether it uses TLS,
+// the schemes being mqtt:// or tcp:// for plain connections and mqtts://, ssl:// or tls:// for TLS.
+func brokerAddress(rawURL string) (*url.URL, string, bool, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("invalid MQTT broker URL %q: %w", rawURL, err)
+	}
+	var useTLS bool
+	port := "1883"
+	switch u.Scheme {
+	case "mqtt", "tcp":
+	case "mqtts", "ssl", "tls":
+		useTLS, port = true, "8883"
+	default:
+		return nil, "", false, fmt.Errorf("invalid MQTT broker URL %q, the scheme needs to be mqtt or mqtts", rawURL)
+	}
+	if u.Port() != "" {
+		port = u.Port()
+	}
+	// the credentials are given in the params, they aren't part of the url tag
+	u.User = nil
+	return u, net.JoinHostPort(u.Hostname(), port), useTLS, nil
+}
+
+// Connect connects to a broker and waits for it to acknowledge the connection.
+func (c *Client) Connect(brokerURL string, params map[string]interface{}) error {
+	state := c.vu.State()
+	if state == nil {
+		return errConnectInInitContext
+	}
+	if c.conn != nil {
+		return errors.New("the MQTT client was already connected, a new client is needed to connect again")
+	}
+	p, err := c.parseConnectParams(params)
+	if err != nil {
+		return err
+	}
+	u, addr, useTLS, err := brokerAddress(brokerURL)
+	if err != nil {
+		return err
+	}
+
+	c.tags = state.CloneTags()
+	if state.Options.SystemTags.Has(stats.TagURL) {
+		c.tags["url"] = u.String()
+	}
+	for k, v := range p.Tags {
+		c.tags[k] = v
+	}
+	if _, ok := c.tags["name"]; !ok && state.Options.SystemTags.Has(stats.TagName) {
+		c.tags["name"] = u.String()
+	}
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(c.vu.Context(), p.Timeout)
+	defer cancel()
+	conn, err := state.Dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	if useTLS {
+		tlsConfig := &tls.Config{} //nolint:gosec
+		if state.TLSConfig != nil {
+			tlsConfig = state.TLSConfig.Clone()
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = u.Hostname()
+		}
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			_ = conn.Close()
+			return err
+		}
+		conn = tlsConn
+	}
+
+	r := bufio.NewReader(conn)
+	connack, err := c.handshake(ctx, conn, r, &connectOptions{
+		version:      p.Version,
+		clientID:     p.ClientID,
+		username:     p.Username,
+		password:     p.Password,
+		cleanSession: p.CleanSession,
+		keepAlive:    uint16(p.KeepAlive / time.Second),
+	})
+	if err == nil {
+		_, err = decodeConnack(p.Version, connack)
+	}
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+
+	c.conn, c.version, c.timeout = conn, p.Version, p.Timeout
+	c.ctx, c.cancel = context.WithCancel(c.vu.Context())
+	c.pending = make(map[uint16]chan *packet)
+	c.incoming = make(map[uint16]bool)
+	c.pongs = make(chan struct{}, 1)
+	c.push(state.BuiltinMetrics.MQTTConnecting, stats.D(time.Since(start)), c.tags)
+
+	go c.read(r)
+	go func() {
+		// the connection doesn't outlive the iteration
+		<-c.ctx.Done()
+		c.closeWith(errClosed)
+	}()
+	if p.KeepAlive > 0 {
+		go c.keepAlive(p.KeepAlive)
+	}
+	return nil
+}
+
+// handshake sends the CONNECT packet and returns the packet the broker responds with.
+func (c *Client) handshake(ctx context.Context, conn net.Conn, r *bufio.Reader, o *connectOptions) (*packet, error) {
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(encodeConnect(o).encode()); err != nil {
+		return nil, err
+	}
+	p, err := readPacket(r)
+	if err != nil {
+		return nil, err
+	}
+	return p, conn.SetDeadline(time.Time{})
+}
+
+// read reads the packets of the connection until it's closed.
+func (c *Client) read(r *bufio.Reader) {
+	for {
+		p, err := readPacket(r)
+		if err == nil {
+			err = c.handle(p)
+		}
+		if err != nil {
+			c.closeWith(err)
+			return
+		}
+	}
+}
+
+func (c *Client) handle(p *packet) error {
+	switch p.kind {
+	case packetPublish:
+		m, err := decodePublish(c.version, p)
+		if err != nil {
+			return err
+		}
+		switch m.QoS {
+		case 1:
+			if err := c.write(encodeAck(packetPuback, m.PacketID)); err != nil {
+				return err
+			}
+		case 2:
+			c.mu.Lock()
+			duplicate := c.incoming[m.PacketID]
+			c.incoming[m.PacketID] = true
+			c.mu.Unlock()
+			if err := c.write(encodeAck(packetPubrec, m.PacketID)); err != nil || duplicate {
+				return err
+			}
+		}
+		c.receive(m)
+	case packetPubrel:
+		id, _, err := decodeAck(c.version, p)
+		if err != nil {
+			return err
+		}
+		c.mu.Lock()
+		delete(c.incoming, id)
+		c.mu.Unlock()
+		return c.write(encodeAck(packetPubcomp, id))
+	case packetPuback, packetPubrec, packetPubcomp, packetSuback, packetUnsuback:
+		id, _, err := decodeAck(c.version, p)
+		if err != nil {
+			return err
+		}
+		c.mu.Lock()
+		ch := c.pending[id]
+		c.mu.Unlock()
+		if ch != nil {
+			select {
+			case ch <- p:
+			default:
+			}
+		}
+	case packetPingresp:
+		select {
+		case c.pongs <- struct{}{}:
+		default:
+		}
+	case packetDisconnect:
+		d := decoder{b: p.body}
+		if code := d.byte(); d.err == nil {
+			return fmt.Errorf("the MQTT broker closed the connection with the reason code 0x%02x", code)
+		}
+		return errors.New("the MQTT broker closed the connection")
+	default:
+		return fmt.Errorf("unexpected MQTT packet of type %d", p.kind)
+	}
+	return nil
+}
+
+// receive delivers a message to the "message" handlers.
+func (c *Client) receive(m *message) {
+	state := c.vu.State()
+	c.push(state.BuiltinMetrics.MQTTMessagesReceived, 1, c.tags)
+	c.events.Queue(func() error {
+		rt := c.vu.Runtime()
+		msg := rt.NewObject()
+		must(rt, msg.Set("topic", m.Topic))
+		must(rt, msg.Set("payload", string(m.Payload)))
+		must(rt, msg.Set("qos", m.QoS))
+		must(rt, msg.Set("retain", m.Retain))
+		must(rt, msg.Set("dup", m.Dup))
+		return c.emit("message", msg)
+	})
+}
+
+// closeWith closes the connection, failing everything that waits on it with err.
+func (c *Client) closeWith(err error) {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return
+	}
+	c.err = err
+	c.mu.Unlock()
+
+	c.cancel()
+	_ = c.conn.Close()
+	if !errors.Is(err, errClosed) {
+		c.events.Queue(func() error {
+			return c.emit("error", c.vu.Runtime().NewGoError(err))
+		})
+	}
+	c.events.Release()
+}
+
+// closedErr returns why the connection was closed.
+func (c *Client) closedErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *Client) write(p *packet) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err := c.conn.Write(p.encode())
+	return err
+}
+
+// request sends a packet with a new packet identifier, that is returned along with the channel of the
+// acknowledgements for it, which have to be waited for with await.
+func (c *Client) request(encode func(id uint16) *packet) (uint16, chan *packet, error) {
+	c.mu.Lock()
+	var id uint16
+	for {
+		c.nextID++
+		if c.nextID == 0 {
+			c.nextID++
+		}
+		if _, ok := c.pending[c.nextID]; !ok {
+			id = c.nextID
+			break
+		}
+	}
+	ch := make(chan *packet, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	if err := c.write(encode(id)); err != nil {
+		c.done(id)
+		return 0, nil, err
+	}
+	return id, ch, nil
+}
+
+// await waits for the next acknowledgement of a request, which is checked to be of the expected kind.
+func (c *Client) await(ch chan *packet, kind byte, timer *time.Timer) ([]byte, error) {
+	select {
+	case p := <-ch:
+		if p.kind != kind {
+			return nil, fmt.Errorf("unexpected MQTT packet of type %d, expected %d", p.kind, kind)
+		}
+		_, codes, err := decodeAck(c.version, p)
+		return codes, err
+	case <-c.ctx.Done():
+		return nil, c.closedErr()
+	case <-timer.C:
+		return nil, fmt.Errorf("the MQTT broker didn't respond within %s", c.timeout)
+	}
+}
+
+func (c *Client) done(id uint16) {
+	c.mu.Lock()
+	delete(c.pending, id)
+	c.mu.Unlock()
+}
+
+func (c *Client) checkConnected() error {
+	if c.conn == nil {
+		return errNotConnected
+	}
+	if err := c.closedErr(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// publishParams are the params of publish().
+type publishParams struct {
+	QoS    byte
+	Retain bool
+	Tags   map[string]string
+}
+
+func parsePublishParams(raw map[string]interface{}) (publishParams, error) {
+	var p publishParams
+	for k, v := range raw {
+		switch k {
+		case "qos":
+			qos, err := parseQoS(v)
+			if err != nil {
+				return p, err
+			}
+			p.QoS = qos
+		case "retain":
+			var ok bool
+			if p.Retain, ok = v.(bool); !ok {
+				return p, errors.New("the retain param needs to be a boolean")
+			}
+		case "tags":
+			tags, ok := v.(map[string]interface{})
+			if !ok {
+				return p, fmt.Errorf("metric tags must be an object of string values, got '%#v'", v)
+			}
+			p.Tags = make(map[string]string, len(tags))
+			for name, tag := range tags {
+				p.Tags[name] = fmt.Sprint(tag)
+			}
+		default:
+			return p, fmt.Errorf("unknown publish param: %q", k)
+		}
+	}
+	return p, nil
+}
+
+func parseQoS(v interface{}) (byte, error) {
+	if qos, ok := v.(int64); ok && qos >= 0 && qos <= 2 {
+		return byte(qos), nil
+	}
+	return 0, fmt.Errorf("invalid qos value '%#v', it needs to be 0, 1 or 2", v)
+}
+
+// Publish publishes a message, which is a string or an ArrayBuffer, and waits until the broker acknowledges
+// it, as far as its QoS requires: not at all for 0, for the PUBACK for 1 and for the PUBCOMP for 2.
+func (c *Client) Publish(topic string, payload goja.Value, params map[string]interface{}) error {
+	if err := c.checkConnected(); err != nil {
+		return err
+	}
+	p, err := parsePublishParams(params)
+	if err != nil {
+		return err
+	}
+	data, err := common.ToBytes(payload.Export())
+	if err != nil {
+		return err
+	}
+	tags := c.tags
+	if len(p.Tags) > 0 {
+		tags = make(map[string]string, len(c.tags)+len(p.Tags))
+		for k, v := range c.tags {
+			tags[k] = v
+		}
+		for k, v := range p.Tags {
+			tags[k] = v
+		}
+	}
+
+	start := time.Now()
+	m := &message{Topic: topic, Payload: data, QoS: p.QoS, Retain: p.Retain}
+	if m.QoS == 0 {
+		err = c.write(encodePublish(c.version, m))
+	} else {
+		err = c.publishAcked(m)
+	}
+	if err != nil {
+		return err
+	}
+	builtinMetrics := c.vu.State().BuiltinMetrics
+	c.push(builtinMetrics.MQTTMessagesSent, 1, tags)
+	c.push(builtinMetrics.MQTTPublishDuration, stats.D(time.Since(start)), tags)
+	return nil
+}
+
+func (c *Client) publishAcked(m *message) error {
+	id, ch, err := c.request(func(id uint16) *packet {
+		m.PacketID = id
+		return encodePublish(c.version, m)
+	})
+	if err != nil {
+		return err
+	}
+	defer c.done(id)
+
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+	ack := packetPuback
+	if m.QoS == 2 {
+		codes, err := c.await(ch, packetPubrec, timer)
+		if err != nil {
+			return err
+		}
+		if codes[0] >= 0x80 {
+			return fmt.Errorf("the MQTT broker refused the message with the reason code 0x%02x", codes[0])
+		}
+		if err := c.write(encodeAck(packetPubrel, id)); err != nil {
+			return err
+		}
+		ack = packetPubcomp
+	}
+	codes, err := c.await(ch, ack, timer)
+	if err != nil {
+		return err
+	}
+	if codes[0] >= 0x80 {
+		return fmt.Errorf("the MQTT broker refused the message with the reason code 0x%02x", codes[0])
+	}
+	return nil
+}
+
+// topicFilters returns the topic filters of subscribe() and unsubscribe(), which are either one string
+// or an array of them, and whether there was only one.
+func topicFilters(v interface{}) ([]string, bool, error) {
+	switch v := v.(type) {
+	case string:
+		return []string{v}, true, nil
+	case []interface{}:
+		filters := make([]string, len(v))
+		for i, f := range v {
+			s, ok := f.(string)
+			if !ok || s == "" {
+				return nil, false, fmt.Errorf("invalid topic filter '%#v'", f)
+			}
+			filters[i] = s
+		}
+		if len(filters) > 0 {
+			return filters, false, nil
+		}
+	}
+	return nil, false, fmt.Errorf("invalid topic filters '%#v', it needs to be a string or an array of strings", v)
+}
+
+// Subscribe subscribes to one or more topic filters, with the QoS in the params, and returns the QoS granted
+// by the broker, or a list of them if a list of filters was given.
+func (c *Client) Subscribe(v interface{}, params map[string]interface{}) (interface{}, error) {
+	if err := c.checkConnected(); err != nil {
+		return nil, err
+	}
+	filters, single, err := topicFilters(v)
+	if err != nil {
+		return nil, err
+	}
+	var qos byte
+	for k, v := range params {
+		if k != "qos" {
+			return nil, fmt.Errorf("unknown subscribe param: %q", k)
+		}
+		if qos, err = parseQoS(v); err != nil {
+			return nil, err
+		}
+	}
+
+	// the messages can arrive right after the SUBACK, so they have to be waited for already
+	c.events.Hold()
+	granted, err := c.subscribeFilters(filters, qos)
+	if len(c.subscriptions) == 0 {
+		c.events.Release()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if single {
+		return granted[0], nil
+	}
+	return granted, nil
+}
+
+func (c *Client) subscribeFilters(filters []string, qos byte) ([]int, error) {
+	codes, err := c.subscribe(packetSuback, func(id uint16) *packet {
+		return encodeSubscribe(c.version, id, filters, qos)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(codes) != len(filters) {
+		return nil, errMalformedPacket
+	}
+	granted := make([]int, len(codes))
+	for i, code := range codes {
+		if code >= 0x80 {
+			return nil, fmt.Errorf("the subscription to %q was refused with the reason code 0x%02x", filters[i], code)
+		}
+		granted[i] = int(code)
+		c.subscriptions[filters[i]] = true
+	}
+	return granted, nil
+}
+
+// Unsubscribe unsubscribes from one or more topic filters. Once the client has no subscriptions left,
+// the iteration doesn't wait for messages anymore.
+func (c *Client) Unsubscribe(v interface{}) error {
+	if err := c.checkConnected(); err != nil {
+		return err
+	}
+	filters, _, err := topicFilters(v)
+	if err != nil {
+		return err
+	}
+	codes, err := c.subscribe(packetUnsuback, func(id uint16) *packet {
+		return encodeUnsubscribe(c.version, id, filters)
+	})
+	if err != nil {
+		return err
+	}
+	for i, f := range filters {
+		if i < len(codes) && codes[i] >= 0x80 {
+			return fmt.Errorf("unsubscribing from %q failed with the reason code 0x%02x", f, codes[i])
+		}
+		delete(c.subscriptions, f)
+	}
+	if len(c.subscriptions) == 0 {
+		c.events.Release()
+	}
+	return nil
+}
+
+// subscribe sends a SUBSCRIBE or UNSUBSCRIBE packet and returns the reason codes of its acknowledgement.
+func (c *Client) subscribe(ack byte, encode func(id uint16) *packet) ([]byte, error) {
+	id, ch, err := c.request(encode)
+	if err != nil {
+		return nil, err
+	}
+	defer c.done(id)
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+	return c.await(ch, ack, timer)
+}
+
+// On sets a handler for the "message" or "error" events.
+func (c *Client) On(event string, handler goja.Value) error {
+	if event != "message" && event != "error" {
+		return fmt.Errorf("unknown MQTT client event %q, it needs to be message or error", event)
+	}
+	fn, ok := goja.AssertFunction(handler)
+	if !ok {
+		return fmt.Errorf("the handler of the %q event needs to be a function", event)
+	}
+	c.handlers[event] = append(c.handlers[event], fn)
+	return nil
+}
+
+func (c *Client) emit(event string, arg goja.Value) error {
+	for _, fn := range c.handlers[event] {
+		if _, err := fn(goja.Undefined(), arg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Ping measures the round-trip time to the broker with a PINGREQ, which is also sent every keepAlive,
+// and returns it in milliseconds.
+func (c *Client) Ping() (float64, error) {
+	if err := c.checkConnected(); err != nil {
+		return 0, err
+	}
+	d, err := c.ping()
+	return stats.D(d), err
+}
+
+func (c *Client) ping() (time.Duration, error) {
+	c.pingMu.Lock()
+	defer c.pingMu.Unlock()
+	select { // a late response to a ping that timed out
+	case <-c.pongs:
+	default:
+	}
+
+	start := time.Now()
+	if err := c.write(&packet{kind: packetPingreq}); err != nil {
+		return 0, err
+	}
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+	select {
+	case <-c.pongs:
+		d := time.Since(start)
+		c.push(c.vu.State().BuiltinMetrics.MQTTPing, stats.D(d), c.tags)
+		return d, nil
+	case <-c.ctx.Done():
+		return 0, c.closedErr()
+	case <-timer.C:
+		return 0, fmt.Errorf("the MQTT broker didn't respond to a ping within %s", c.timeout)
+	}
+}
+
+func (c *Client) keepAlive(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := c.ping(); err != nil {
+				c.closeWith(err)
+				return
+			}
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+// Close disconnects from the broker, after which no more messages are delivered.
+func (c *Client) Close() error {
+	if c.conn == nil || c.closedErr() != nil {
+		return nil
+	}
+	c.subscriptions = make(map[string]bool)
+	err := c.write(&packet{kind: packetDisconnect})
+	c.closeWith(errClosed)
+	return err
+}
+
+func (c *Client) push(metric *stats.Metric, value float64, tags map[string]string) {
+	tagsCopy := make(map[string]string, len(tags))
+	for k, v := range tags {
+		tagsCopy[k] = v
+	}
+	stats.PushIfNotDone(c.vu.Context(), c.vu.State().Samples, stats.Sample{
+		Metric: metric,
+		Tags:   stats.IntoSampleTags(&tagsCopy),
+		Value:  value,
+		Time:   time.Now(),
+	})
+}
+
+func must(rt *goja.Runtime, err error) {
+	if err != nil {
+		common.Throw(rt, err)
+	}
+}
@@ -0,0 +1,461 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/lib/testutils/httpmultibin"
+	"go.k6.io/k6/stats"
+)
+
+func newTestVU(
+	t *testing.T,
+) (*httpmultibin.HTTPMultiBin, *modulestest.LoopVU, context.CancelFunc, chan stats.SampleContainer) {
+	t.Helper()
+	tb := httpmultibin.NewHTTPMultiBin(t)
+	testVU, samples := modulestest.NewTestVU(t, tb, stats.TagName, stats.TagURL)
+	ctx, cancel := context.WithCancel(testVU.CtxField)
+	testVU.CtxField = ctx
+	vu := modulestest.NewLoopVU(testVU)
+	m, ok := New().NewModuleInstance(vu).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, vu.Runtime().Set("mqtt", m.Exports().Named))
+	return tb, vu, cancel, samples
+}
+
+// testBroker is an MQTT broker with just enough of the protocol for the tests. It refuses the connections
+// with the "bad" user name and the subscriptions to the "forbidden" topic, and it disconnects the clients that
+// publish to the "disconnect" topic.
+type testBroker struct {
+	addr string
+
+	mu       sync.Mutex
+	subs     map[*brokerConn]map[string]byte
+	retained map[string]*message
+}
+
+type brokerConn struct {
+	net.Conn
+	version byte
+	mu      sync.Mutex
+	nextID  uint16
+}
+
+func (c *brokerConn) write(p *packet) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, _ = c.Write(p.encode())
+}
+
+func (c *brokerConn) publish(m message, qos byte) {
+	if m.QoS > qos {
+		m.QoS = qos
+	}
+	if m.QoS > 0 {
+		c.mu.Lock()
+		c.nextID++
+		m.PacketID = c.nextID
+		c.mu.Unlock()
+	}
+	c.write(encodePublish(c.version, &m))
+}
+
+func newTestBroker(t *testing.T, tlsConfig *tls.Config) *testBroker {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	if tlsConfig != nil {
+		ln = tls.NewListener(ln, tlsConfig)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	b := &testBroker{
+		addr:     ln.Addr().String(),
+		subs:     make(map[*brokerConn]map[string]byte),
+		retained: make(map[string]*message),
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(&brokerConn{Conn: conn})
+		}
+	}()
+	return b
+}
+
+func (b *testBroker) serve(c *brokerConn) {
+	defer func() {
+		b.mu.Lock()
+		delete(b.subs, c)
+		b.mu.Unlock()
+		_ = c.Close()
+	}()
+	r := bufio.NewReader(c)
+	p, err := readPacket(r)
+	if err != nil || p.kind != packetConnect {
+		return
+	}
+	d := decoder{b: p.body}
+	_ = d.string()
+	c.version = d.byte()
+	flags := d.byte()
+	_ = d.uint16()
+	d.properties(c.version)
+	_ = d.string()
+	var username string
+	if flags&0x80 != 0 {
+		username = d.string()
+	}
+	connack := &packet{kind: packetConnack, body: []byte{0, 0}}
+	if username == "bad" {
+		connack.body[1] = 4
+		if c.version == version5 {
+			connack.body[1] = 0x86
+		}
+	}
+	if c.version == version5 {
+		connack.body = append(connack.body, 0)
+	}
+	c.write(connack)
+	if connack.body[1] != 0 {
+		return
+	}
+
+	for {
+		p, err := readPacket(r)
+		if err != nil {
+			return
+		}
+		switch p.kind {
+		case packetPublish:
+			m, err := decodePublish(c.version, p)
+			if err != nil {
+				return
+			}
+			if m.Topic == "disconnect" {
+				return
+			}
+			switch m.QoS {
+			case 1:
+				c.write(encodeAck(packetPuback, m.PacketID))
+			case 2:
+				c.write(encodeAck(packetPubrec, m.PacketID))
+			}
+			b.route(m)
+		case packetPubrel:
+			id, _, _ := decodeAck(c.version, p)
+			c.write(encodeAck(packetPubcomp, id))
+		case packetPubrec:
+			id, _, _ := decodeAck(c.version, p)
+			c.write(encodeAck(packetPubrel, id))
+		case packetSubscribe:
+			b.subscribe(c, p)
+		case packetUnsubscribe:
+			d := decoder{b: p.body}
+			ack := encodeAck(packetUnsuback, d.uint16())
+			d.properties(c.version)
+			if c.version == version5 {
+				ack.body = append(ack.body, 0)
+			}
+			b.mu.Lock()
+			for len(d.b) > 0 {
+				delete(b.subs[c], d.string())
+				if c.version == version5 {
+					ack.body = append(ack.body, 0)
+				}
+			}
+			b.mu.Unlock()
+			c.write(ack)
+		case packetPingreq:
+			c.write(&packet{kind: packetPingresp})
+		case packetDisconnect:
+			return
+		}
+	}
+}
+
+// subscribe acknowledges a subscription and sends the retained messages of its topics, before any other one.
+func (b *testBroker) subscribe(c *brokerConn, p *packet) {
+	d := decoder{b: p.body}
+	ack := encodeAck(packetSuback, d.uint16())
+	d.properties(c.version)
+	if c.version == version5 {
+		ack.body = append(ack.body, 0)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subs[c] == nil {
+		b.subs[c] = make(map[string]byte)
+	}
+	var filters []string
+	for len(d.b) > 0 {
+		filter, qos := d.string(), d.byte()
+		if filter == "forbidden" {
+			ack.body = append(ack.body, 0x80)
+			continue
+		}
+		b.subs[c][filter] = qos
+		filters = append(filters, filter)
+		ack.body = append(ack.body, qos)
+	}
+	c.write(ack)
+	for _, filter := range filters {
+		for topic, m := range b.retained {
+			if matchTopic(filter, topic) {
+				c.publish(*m, b.subs[c][filter])
+			}
+		}
+	}
+}
+
+func (b *testBroker) route(m *message) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if m.Retain {
+		b.retained[m.Topic] = m
+	}
+	forward := *m
+	forward.Retain = false
+	for c, filters := range b.subs {
+		for filter, qos := range filters {
+			if matchTopic(filter, m.Topic) {
+				c.publish(forward, qos)
+				break
+			}
+		}
+	}
+}
+
+func matchTopic(filter, topic string) bool {
+	f, t := strings.Split(filter, "/"), strings.Split(topic, "/")
+	for i, level := range f {
+		switch {
+		case level == "#":
+			return true
+		case i >= len(t):
+			return false
+		case level != "+" && level != t[i]:
+			return false
+		}
+	}
+	return len(f) == len(t)
+}
+
+func TestClient(t *testing.T) {
+	t.Parallel()
+
+	for _, version := range []string{"3.1.1", "5"} {
+		version := version
+		t.Run("PublishSubscribe/"+version, func(t *testing.T) {
+			t.Parallel()
+			_, vu, _, samples := newTestVU(t)
+			broker := newTestBroker(t, nil)
+			require.NoError(t, vu.Runtime().Set("version", version))
+
+			_, err := vu.Runtime().RunString(strings.ReplaceAll(`
+				var pub = new mqtt.Client();
+				pub.connect("mqtt://BROKER", { version: version });
+				pub.publish("sensors/temp", "20", { qos: 1, retain: true });
+
+				var received = [];
+				var sub = new mqtt.Client();
+				sub.connect("mqtt://BROKER", { version: version, clientId: "subscriber" });
+				sub.on("message", function(m) {
+					received.push([m.topic, m.payload, m.qos, m.retain].join(" "));
+					if (received.length === 4) {
+						sub.close();
+					}
+				});
+				var granted = sub.subscribe(["sensors/+", "alerts/#"], { qos: 1 });
+
+				pub.publish("sensors/temp", "21");
+				pub.publish("sensors/humidity", "50", { qos: 1, tags: { tag: "value" } });
+				pub.publish("other", "x");
+				pub.publish("alerts/high/temp", new Uint8Array([104, 105]).buffer, { qos: 2 });
+				pub.close();
+			`, "BROKER", broker.addr))
+			require.NoError(t, err)
+			require.NoError(t, vu.Run())
+
+			v, err := vu.Runtime().RunString(`granted.join(",") + "|" + received.join(",")`)
+			require.NoError(t, err)
+			assert.Equal(t, "1,1|sensors/temp 20 1 true,sensors/temp 21 0 false,sensors/humidity 50 1 false,"+
+				"alerts/high/temp hi 1 false", v.String())
+
+			counts := map[string]float64{}
+			for _, c := range stats.GetBufferedSamples(samples) {
+				for _, s := range c.GetSamples() {
+					url, _ := s.Tags.Get("url")
+					assert.Equal(t, "mqtt://"+broker.addr, url)
+					counts[s.Metric.Name] += s.Value
+					if tag, ok := s.Tags.Get("tag"); ok {
+						assert.Equal(t, "value", tag)
+						assert.Contains(t, []string{metrics.MQTTMessagesSentName, metrics.MQTTPublishDurationName},
+							s.Metric.Name)
+					}
+				}
+			}
+			assert.Equal(t, float64(5), counts[metrics.MQTTMessagesSentName])
+			assert.Equal(t, float64(4), counts[metrics.MQTTMessagesReceivedName])
+			assert.Contains(t, counts, metrics.MQTTConnectingName)
+			assert.Contains(t, counts, metrics.MQTTPublishDurationName)
+		})
+	}
+
+	t.Run("TLS", func(t *testing.T) {
+		t.Parallel()
+		tb, vu, _, samples := newTestVU(t)
+		broker := newTestBroker(t, tb.ServerHTTPS.TLS)
+
+		_, err := vu.Runtime().RunString(strings.ReplaceAll(`
+			var client = new mqtt.Client();
+			client.connect("mqtts://BROKER", { version: 5, keepAlive: 0 });
+			var rtt = client.ping();
+			client.publish("topic", "payload", { qos: 2 });
+			client.close();
+		`, "BROKER", broker.addr))
+		require.NoError(t, err)
+		require.NoError(t, vu.Run())
+		assert.GreaterOrEqual(t, vu.Runtime().Get("rtt").ToFloat(), float64(0))
+
+		var pings int
+		for _, c := range stats.GetBufferedSamples(samples) {
+			for _, s := range c.GetSamples() {
+				if s.Metric.Name == metrics.MQTTPingName {
+					pings++
+				}
+			}
+		}
+		assert.Equal(t, 1, pings)
+	})
+
+	t.Run("BrokerDisconnect", func(t *testing.T) {
+		t.Parallel()
+		_, vu, _, _ := newTestVU(t)
+		broker := newTestBroker(t, nil)
+
+		_, err := vu.Runtime().RunString(strings.ReplaceAll(`
+			var errors = [];
+			var client = new mqtt.Client();
+			client.connect("mqtt://BROKER");
+			client.on("error", function(e) { errors.push(e.message); });
+			client.subscribe("topic");
+			client.publish("disconnect", "");
+		`, "BROKER", broker.addr))
+		require.NoError(t, err)
+		require.NoError(t, vu.Run())
+		v, err := vu.Runtime().RunString(`errors.join(",")`)
+		require.NoError(t, err)
+		assert.Equal(t, io.EOF.Error(), v.String())
+
+		_, err = vu.Runtime().RunString(`client.publish("topic", "")`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "EOF")
+	})
+
+	t.Run("IterationEnd", func(t *testing.T) {
+		t.Parallel()
+		_, vu, cancel, _ := newTestVU(t)
+		broker := newTestBroker(t, nil)
+
+		_, err := vu.Runtime().RunString(strings.ReplaceAll(`
+			var client = new mqtt.Client();
+			client.connect("mqtt://BROKER");
+			client.on("message", function() {});
+			client.subscribe("topic");
+		`, "BROKER", broker.addr))
+		require.NoError(t, err)
+		// the subscription keeps the iteration waiting for messages until it ends
+		assert.Equal(t, 1, vu.Registered())
+		cancel()
+		require.NoError(t, vu.Run())
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		t.Parallel()
+		_, vu, _, _ := newTestVU(t)
+		broker := newTestBroker(t, nil)
+		require.NoError(t, vu.Runtime().Set("broker", "mqtt://"+broker.addr))
+
+		tests := []struct {
+			script, err string
+		}{
+			{`new mqtt.Client().connect(broker, { username: "bad" })`, "bad user name or password"},
+			{`new mqtt.Client().connect(broker, { username: "bad", version: "5" })`, "reason code 0x86"},
+			{`new mqtt.Client().connect("http://" + broker.slice(7))`, "the scheme needs to be mqtt or mqtts"},
+			{`new mqtt.Client().connect(broker, { version: "3.1" })`, `invalid MQTT version "3.1"`},
+			{`new mqtt.Client().connect(broker, { qos: 1 })`, `unknown connect param: "qos"`},
+			{`new mqtt.Client().publish("topic", "")`, "isn't connected"},
+			{`var c = new mqtt.Client(); c.connect(broker); c.connect(broker)`, "was already connected"},
+			{`var c = new mqtt.Client(); c.connect(broker); c.publish("topic", "", { qos: 3 })`, "invalid qos value"},
+			{`var c = new mqtt.Client(); c.connect(broker); c.subscribe("forbidden")`, `subscription to "forbidden"`},
+			{`var c = new mqtt.Client(); c.connect(broker); c.subscribe([])`, "invalid topic filters"},
+			{`var c = new mqtt.Client(); c.on("close", function() {})`, `unknown MQTT client event "close"`},
+		}
+		for _, tc := range tests {
+			_, err := vu.Runtime().RunString(tc.script)
+			require.Error(t, err, tc.script)
+			assert.Contains(t, err.Error(), tc.err, tc.script)
+		}
+		_, err := vu.Runtime().RunString(`c.close()`)
+		require.NoError(t, err)
+		require.NoError(t, vu.Run())
+	})
+
+	t.Run("InitContext", func(t *testing.T) {
+		t.Parallel()
+		_, vu, _, _ := newTestVU(t)
+		vu.StateField = nil
+		_, err := vu.Runtime().RunString(`new mqtt.Client().connect("mqtt://localhost")`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "in the init context is not supported")
+	})
+}
+
+func TestPacket(t *testing.T) {
+	t.Parallel()
+
+	m := &message{Topic: "a/b", Payload: []byte("payload"), QoS: 2, Retain: true, Dup: true, PacketID: 42}
+	for _, version := range []byte{version311, version5} {
+		p, err := readPacket(bufio.NewReader(strings.NewReader(string(encodePublish(version, m).encode()))))
+		require.NoError(t, err)
+		decoded, err := decodePublish(version, p)
+		require.NoError(t, err)
+		assert.Equal(t, m, decoded)
+	}
+
+	// a remaining length that needs more than one byte
+	large := &packet{kind: packetPublish, body: make([]byte, 200)}
+	p, err := readPacket(bufio.NewReader(strings.NewReader(string(large.encode()))))
+	require.NoError(t, err)
+	assert.Equal(t, large, p)
+
+	_, err = readPacket(bufio.NewReader(strings.NewReader("\x30\x05abc")))
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+
+	// the reason code and the properties of an MQTT 5 PUBACK are optional
+	_, codes, err := decodeAck(version5, &packet{kind: packetPuback, body: []byte{0, 1}})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0}, codes)
+	_, codes, err = decodeAck(version5, &packet{kind: packetPuback, body: []byte{0, 1, 0x87}})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x87}, codes)
+	_, _, err = decodeAck(version5, &packet{kind: packetPuback, body: []byte{0, 1, 0x87, 5}})
+	assert.Equal(t, errMalformedPacket, err)
+}
@@ -0,0 +1,339 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// The MQTT control packet types, see
+// https://docs.oasis-open.org/mqtt/mqtt/v5.0/os/mqtt-v5.0-os.html#_Toc3901022
+const (
+	packetConnect     byte = 1
+	packetConnack     byte = 2
+	packetPublish     byte = 3
+	packetPuback      byte = 4
+	packetPubrec      byte = 5
+	packetPubrel      byte = 6
+	packetPubcomp     byte = 7
+	packetSubscribe   byte = 8
+	packetSuback      byte = 9
+	packetUnsubscribe byte = 10
+	packetUnsuback    byte = 11
+	packetPingreq     byte = 12
+	packetPingresp    byte = 13
+	packetDisconnect  byte = 14
+)
+
+// The protocol levels of the supported MQTT versions.
+const (
+	version311 byte = 4
+	version5   byte = 5
+)
+
+// maxPacketSize is the largest remaining length that can be encoded.
+const maxPacketSize = 268435455
+
+var errMalformedPacket = errors.New("malformed MQTT packet")
+
+// packet is a control packet, with the flags of its fixed header and everything after the remaining length.
+type packet struct {
+	kind  byte
+	flags byte
+	body  []byte
+}
+
+// readPacket reads the next packet of a connection.
+func readPacket(r *bufio.Reader) (*packet, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if length > maxPacketSize {
+		return nil, errMalformedPacket
+	}
+	p := &packet{kind: header >> 4, flags: header & 0x0f, body: make([]byte, length)}
+	if _, err := io.ReadFull(r, p.body); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// encode returns the packet with its fixed header, ready to be written to a connection.
+func (p *packet) encode() []byte {
+	b := make([]byte, 1+binary.MaxVarintLen32, 1+binary.MaxVarintLen32+len(p.body))
+	b[0] = p.kind<<4 | p.flags
+	n := binary.PutUvarint(b[1:], uint64(len(p.body)))
+	return append(b[:1+n], p.body...)
+}
+
+// encoder builds the variable header and the payload of a packet.
+type encoder struct {
+	bytes.Buffer
+}
+
+func (e *encoder) uint16(v uint16) {
+	_ = e.WriteByte(byte(v >> 8))
+	_ = e.WriteByte(byte(v))
+}
+
+// binary writes binary data or a UTF-8 string, prefixed with its length.
+func (e *encoder) binary(b []byte) {
+	e.uint16(uint16(len(b)))
+	_, _ = e.Write(b)
+}
+
+func (e *encoder) string(s string) {
+	e.binary([]byte(s))
+}
+
+// noProperties writes an empty property list, for the packets of MQTT 5.
+func (e *encoder) noProperties(version byte) {
+	if version == version5 {
+		_ = e.WriteByte(0)
+	}
+}
+
+// decoder reads the variable header and the payload of a packet.
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil || len(d.b) < 1 {
+		d.err = errMalformedPacket
+		return 0
+	}
+	v := d.b[0]
+	d.b = d.b[1:]
+	return v
+}
+
+func (d *decoder) uint16() uint16 {
+	if d.err != nil || len(d.b) < 2 {
+		d.err = errMalformedPacket
+		return 0
+	}
+	v := binary.BigEndian.Uint16(d.b)
+	d.b = d.b[2:]
+	return v
+}
+
+func (d *decoder) binary() []byte {
+	n := int(d.uint16())
+	if d.err != nil || len(d.b) < n {
+		d.err = errMalformedPacket
+		return nil
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) string() string {
+	return string(d.binary())
+}
+
+// properties skips the property list of an MQTT 5 packet.
+func (d *decoder) properties(version byte) {
+	if version != version5 || d.err != nil {
+		return
+	}
+	n, size := binary.Uvarint(d.b)
+	if size <= 0 || uint64(len(d.b)-size) < n {
+		d.err = errMalformedPacket
+		return
+	}
+	d.b = d.b[size+int(n):]
+}
+
+// rest returns everything that hasn't been read yet.
+func (d *decoder) rest() []byte {
+	v := d.b
+	d.b = nil
+	return v
+}
+
+// message is a published application message.
+type message struct {
+	Topic    string
+	Payload  []byte
+	QoS      byte
+	Retain   bool
+	Dup      bool
+	PacketID uint16
+}
+
+func encodePublish(version byte, m *message) *packet {
+	var e encoder
+	e.string(m.Topic)
+	if m.QoS > 0 {
+		e.uint16(m.PacketID)
+	}
+	e.noProperties(version)
+	_, _ = e.Write(m.Payload)
+
+	flags := m.QoS << 1
+	if m.Retain {
+		flags |= 0x01
+	}
+	if m.Dup {
+		flags |= 0x08
+	}
+	return &packet{kind: packetPublish, flags: flags, body: e.Bytes()}
+}
+
+func decodePublish(version byte, p *packet) (*message, error) {
+	d := decoder{b: p.body}
+	m := &message{
+		Topic:  d.string(),
+		QoS:    (p.flags >> 1) & 0x03,
+		Retain: p.flags&0x01 != 0,
+		Dup:    p.flags&0x08 != 0,
+	}
+	if m.QoS > 2 {
+		return nil, errMalformedPacket
+	}
+	if m.QoS > 0 {
+		m.PacketID = d.uint16()
+	}
+	d.properties(version)
+	m.Payload = d.rest()
+	return m, d.err
+}
+
+// encodeAck returns one of the packets that only have a packet identifier, like PUBACK or PUBREL.
+func encodeAck(kind byte, id uint16) *packet {
+	var e encoder
+	e.uint16(id)
+	var flags byte
+	if kind == packetPubrel || kind == packetSubscribe || kind == packetUnsubscribe {
+		flags = 0x02
+	}
+	return &packet{kind: kind, flags: flags, body: e.Bytes()}
+}
+
+// decodeAck returns the packet identifier and the reason code of an acknowledgement, which for the SUBACK
+// and UNSUBACK packets is the list of the reason codes of each topic filter.
+func decodeAck(version byte, p *packet) (uint16, []byte, error) {
+	d := decoder{b: p.body}
+	id := d.uint16()
+	switch p.kind {
+	case packetSuback, packetUnsuback:
+		d.properties(version)
+		// the UNSUBACK packets of MQTT 3.1.1 have no reason codes, so they are all successful
+		return id, d.rest(), d.err
+	default:
+		// the reason code and the properties can be left out if the code is 0
+		if version == version5 && len(d.b) > 0 {
+			code := d.byte()
+			if len(d.b) > 0 {
+				d.properties(version)
+			}
+			return id, []byte{code}, d.err
+		}
+		return id, []byte{0}, d.err
+	}
+}
+
+// connectOptions are the fields of a CONNECT packet.
+type connectOptions struct {
+	version      byte
+	clientID     string
+	username     string
+	password     string
+	cleanSession bool
+	keepAlive    uint16
+}
+
+func encodeConnect(o *connectOptions) *packet {
+	var e encoder
+	e.string("MQTT")
+	_ = e.WriteByte(o.version)
+	var flags byte
+	if o.username != "" {
+		flags |= 0x80
+	}
+	if o.password != "" {
+		flags |= 0x40
+	}
+	if o.cleanSession {
+		flags |= 0x02
+	}
+	_ = e.WriteByte(flags)
+	e.uint16(o.keepAlive)
+	e.noProperties(o.version)
+	e.string(o.clientID)
+	if o.username != "" {
+		e.string(o.username)
+	}
+	if o.password != "" {
+		e.string(o.password)
+	}
+	return &packet{kind: packetConnect, body: e.Bytes()}
+}
+
+// connectErrors are the reasons why a broker refused an MQTT 3.1.1 connection.
+var connectErrors = map[byte]string{ //nolint:gochecknoglobals
+	1: "unacceptable protocol version",
+	2: "identifier rejected",
+	3: "server unavailable",
+	4: "bad user name or password",
+	5: "not authorized",
+}
+
+// decodeConnack returns whether the broker had a session for the client, or why it refused the connection.
+func decodeConnack(version byte, p *packet) (bool, error) {
+	if p.kind != packetConnack {
+		return false, fmt.Errorf("expected a CONNACK packet, got a packet of type %d", p.kind)
+	}
+	d := decoder{b: p.body}
+	sessionPresent := d.byte()&0x01 != 0
+	code := d.byte()
+	d.properties(version)
+	if d.err != nil {
+		return false, d.err
+	}
+	switch {
+	case code == 0:
+		return sessionPresent, nil
+	case version == version5:
+		return false, fmt.Errorf("the MQTT connection was refused with the reason code 0x%02x", code)
+	case connectErrors[code] != "":
+		return false, fmt.Errorf("the MQTT connection was refused: %s", connectErrors[code])
+	default:
+		return false, fmt.Errorf("the MQTT connection was refused with the return code %d", code)
+	}
+}
+
+func encodeSubscribe(version byte, id uint16, filters []string, qos byte) *packet {
+	var e encoder
+	e.uint16(id)
+	e.noProperties(version)
+	for _, f := range filters {
+		e.string(f)
+		_ = e.WriteByte(qos)
+	}
+	return &packet{kind: packetSubscribe, flags: 0x02, body: e.Bytes()}
+}
+
+func encodeUnsubscribe(version byte, id uint16, filters []string) *packet {
+	var e encoder
+	e.uint16(id)
+	e.noProperties(version)
+	for _, f := range filters {
+		e.string(f)
+	}
+	return &packet{kind: packetUnsubscribe, flags: 0x02, body: e.Bytes()}
+}
@@ -0,0 +1,1030 @@
+// Package nats implements the k6/experimental/nats module, with a client for NATS servers and JetStream.
+package nats
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/js/modules/k6/experimental/internal/events"
+	"go.k6.io/k6/lib/consts"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/stats"
+)
+
+type (
+	// RootModule is the global module instance that will create module
+	// instances for each VU.
+	RootModule struct{}
+
+	// ModuleInstance represents an instance of the nats module for every VU.
+	ModuleInstance struct {
+		vu modules.VU
+	}
+)
+
+var (
+	_ modules.Module   = &RootModule{}
+	_ modules.Instance = &ModuleInstance{}
+)
+
+// New returns a pointer to a new RootModule instance.
+func New() *RootModule {
+	return &RootModule{}
+}
+
+// NewModuleInstance implements the modules.Module interface to return
+// a new instance for each VU.
+func (*RootModule) NewModuleInstance(vu modules.VU) modules.Instance {
+	return &ModuleInstance{vu: vu}
+}
+
+// Exports returns the exports of the nats module.
+func (mi *ModuleInstance) Exports() modules.Exports {
+	return modules.Exports{
+		Named: map[string]interface{}{
+			"Client": mi.NewClient,
+		},
+	}
+}
+
+// NewClient is the JS constructor for the nats Client.
+func (mi *ModuleInstance) NewClient(call goja.ConstructorCall) *goja.Object {
+	rt := mi.vu.Runtime()
+	c := &Client{
+		vu:       mi.vu,
+		handlers: make(map[string][]goja.Callable),
+		events:   events.NewQueue(mi.vu),
+	}
+	return rt.ToValue(c).ToObject(rt)
+}
+
+const (
+	defaultTimeout = 10 * time.Second
+	defaultMaxWait = time.Second
+
+	// inboxSID is the sid of the subscription to the reply subjects of the requests
+	inboxSID = "1"
+)
+
+var (
+	errConnectInInitContext = common.NewInitContextError("connecting to a NATS server in the init context is not supported")
+	errNotConnected         = errors.New("the NATS client isn't connected")
+	errClosed               = errors.New("the NATS connection was closed")
+)
+
+// Client is a NATS client, which can be connected once. Its connection is closed at the latest when the
+// iteration ends, and while it has subscriptions, the iteration waits for the messages delivered to the
+// "message" handlers.
+type Client struct {
+	vu modules.VU
+
+	// set by Connect
+	conn    net.Conn
+	info    serverInfo
+	tags    map[string]string
+	timeout time.Duration
+	// inbox is the prefix of the reply subjects of the requests
+	inbox string
+	// ctx is cancelled when the connection is closed, after err is set
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	writeMu sync.Mutex
+	pingMu  sync.Mutex
+	pongs   chan struct{}
+
+	mu        sync.Mutex
+	err       error
+	nextToken uint64
+	// replies has the channels of the requests that wait for replies, by the last token of their reply subject
+	replies map[string]chan *message
+	// subscriptions has the subjects of the subscriptions, by sid
+	subscriptions map[string]string
+
+	// only accessed from the event loop
+	nextSID  int
+	handlers map[string][]goja.Callable
+	events   *events.Queue
+}
+
+// connectParams are the params of connect().
+type connectParams struct {
+	Name    string
+	User    string
+	Pass    string
+	Token   string
+	Timeout time.Duration
+	Tags    map[string]string
+}
+
+func parseConnectParams(raw map[string]interface{}) (connectParams, error) {
+	p := connectParams{Timeout: defaultTimeout}
+	var err error
+	for k, v := range raw {
+		switch k {
+		case "name":
+			p.Name, _ = v.(string)
+		case "user":
+			p.User, _ = v.(string)
+		case "pass":
+			p.Pass, _ = v.(string)
+		case "token":
+			p.Token, _ = v.(string)
+		case "timeout":
+			p.Timeout, err = types.GetDurationValue(v)
+			if err != nil || p.Timeout <= 0 {
+				return p, fmt.Errorf("invalid timeout value '%#v'", v)
+			}
+		case "tags":
+			if p.Tags, err = parseTags(v); err != nil {
+				return p, err
+			}
+		default:
+			return p, fmt.Errorf("unknown connect param: %q", k)
+		}
+	}
+	return p, nil
+}
+
+func parseTags(v interface{}) (map[string]string, error) {
+	rawTags, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("metric tags must be an object of string values, got '%#v'", v)
+	}
+	tags := make(map[string]string, len(rawTags))
+	for name, tag := range rawTags {
+		tags[name] = fmt.Sprint(tag)
+	}
+	return tags, nil
+}
+
+// serverAddress returns the address to dial for the URL of a server and whether it uses TLS,
+// the schemes being nats:// for plain connections, unless the server requires TLS, and tls:// for TLS.
+func serverAddress(rawURL string) (*url.URL, string, bool, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("invalid NATS server URL %q: %w", rawURL, err)
+	}
+	var useTLS bool
+	switch u.Scheme {
+	case "nats":
+	case "tls":
+		useTLS = true
+	default:
+		return nil, "", false, fmt.Errorf("invalid NATS server URL %q, the scheme needs to be nats or tls", rawURL)
+	}
+	port := "4222"
+	if u.Port() != "" {
+		port = u.Port()
+	}
+	// the credentials are given in the params, they aren't part of the url tag
+	u.User = nil
+	return u, net.JoinHostPort(u.Hostname(), port), useTLS, nil
+}
+
+// Connect connects to a server and waits for it to accept the connection.
+func (c *Client) Connect(serverURL string, params map[string]interface{}) error {
+	state := c.vu.State()
+	if state == nil {
+		return errConnectInInitContext
+	}
+	if c.conn != nil {
+		return errors.New("the NATS client was already connected, a new client is needed to connect again")
+	}
+	p, err := parseConnectParams(params)
+	if err != nil {
+		return err
+	}
+	u, addr, useTLS, err := serverAddress(serverURL)
+	if err != nil {
+		return err
+	}
+
+	c.tags = state.CloneTags()
+	if state.Options.SystemTags.Has(stats.TagURL) {
+		c.tags["url"] = u.String()
+	}
+	for k, v := range p.Tags {
+		c.tags[k] = v
+	}
+	if _, ok := c.tags["name"]; !ok && state.Options.SystemTags.Has(stats.TagName) {
+		c.tags["name"] = u.String()
+	}
+
+	id := make([]byte, 8)
+	if _, err = rand.Read(id); err != nil {
+		return err
+	}
+	inbox := "_INBOX." + hex.EncodeToString(id) + "."
+
+	ctx, cancel := context.WithTimeout(c.vu.Context(), p.Timeout)
+	defer cancel()
+	conn, err := state.Dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	conn, r, info, err := c.handshake(ctx, conn, u.Hostname(), useTLS, inbox, &connectOptions{
+		Name:         p.Name,
+		Lang:         "go",
+		Version:      consts.Version,
+		Protocol:     1,
+		Headers:      true,
+		NoResponders: true,
+		User:         p.User,
+		Pass:         p.Pass,
+		AuthToken:    p.Token,
+	})
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+
+	c.conn, c.info, c.timeout, c.inbox = conn, info, p.Timeout, inbox
+	c.ctx, c.cancel = context.WithCancel(c.vu.Context())
+	c.replies = make(map[string]chan *message)
+	c.subscriptions = make(map[string]string)
+	c.pongs = make(chan struct{}, 1)
+	c.nextSID = 1
+
+	go c.read(r)
+	go func() {
+		// the connection doesn't outlive the iteration
+		<-c.ctx.Done()
+		c.closeWith(errClosed)
+	}()
+	return nil
+}
+
+// handshake reads the INFO of the server, upgrades the connection to TLS if needed and sends the CONNECT,
+// along with the subscription to the inbox, until the server answers the PING that follows them.
+func (c *Client) handshake(
+	ctx context.Context, conn net.Conn, host string, useTLS bool, inbox string, o *connectOptions,
+) (net.Conn, *bufio.Reader, serverInfo, error) {
+	var info serverInfo
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return conn, nil, info, err
+	}
+	r := bufio.NewReaderSize(conn, maxControlLine)
+	first, err := readOp(r)
+	if err != nil {
+		return conn, nil, info, err
+	}
+	if first.name != "INFO" {
+		return conn, nil, info, fmt.Errorf("%w: expected INFO, got %q", errProtocol, first.name)
+	}
+	if err = json.Unmarshal([]byte(first.arg), &info); err != nil {
+		return conn, nil, info, fmt.Errorf("%w: %s", errProtocol, err)
+	}
+
+	if useTLS || info.TLSRequired {
+		tlsConfig := &tls.Config{} //nolint:gosec
+		if state := c.vu.State(); state.TLSConfig != nil {
+			tlsConfig = state.TLSConfig.Clone()
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = host
+		}
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err = tlsConn.HandshakeContext(ctx); err != nil {
+			return conn, nil, info, err
+		}
+		conn, r = tlsConn, bufio.NewReaderSize(tlsConn, maxControlLine)
+		o.TLSRequired = true
+	}
+	if !info.Headers {
+		// the no responders status is sent in the headers
+		o.Headers, o.NoResponders = false, false
+	}
+
+	options, err := json.Marshal(o)
+	if err != nil {
+		return conn, nil, info, err
+	}
+	handshake := "CONNECT " + string(options) + "\r\n" + string(encodeSub(inbox+"*", "", inboxSID)) + "PING\r\n"
+	if _, err = conn.Write([]byte(handshake)); err != nil {
+		return conn, nil, info, err
+	}
+	for {
+		o, err := readOp(r)
+		if err != nil {
+			return conn, nil, info, err
+		}
+		switch o.name {
+		case "PONG":
+			return conn, r, info, conn.SetDeadline(time.Time{})
+		case "-ERR":
+			return conn, nil, info, fmt.Errorf("the NATS server refused the connection: %s", strings.Trim(o.arg, "'"))
+		case "+OK", "INFO":
+		default:
+			return conn, nil, info, fmt.Errorf("%w: unexpected %q", errProtocol, o.name)
+		}
+	}
+}
+
+// read reads the protocol messages of the connection until it's closed.
+func (c *Client) read(r *bufio.Reader) {
+	for {
+		o, err := readOp(r)
+		if err == nil {
+			err = c.handle(o)
+		}
+		if err != nil {
+			c.closeWith(err)
+			return
+		}
+	}
+}
+
+func (c *Client) handle(o *op) error {
+	switch o.name {
+	case "MSG", "HMSG":
+		c.deliver(o.msg)
+	case "PING":
+		return c.write([]byte("PONG\r\n"))
+	case "PONG":
+		select {
+		case c.pongs <- struct{}{}:
+		default:
+		}
+	case "+OK", "INFO":
+	case "-ERR":
+		reason := strings.Trim(o.arg, "'")
+		if !strings.HasPrefix(strings.ToLower(reason), "permissions violation") {
+			return fmt.Errorf("the NATS server closed the connection: %s", reason)
+		}
+		// the connection stays open after a permissions violation, a refused subscription is
+		// removed for Subscribe to fail once its flush is done
+		if subject, ok := refusedSubscription(reason); ok {
+			c.mu.Lock()
+			for sid, s := range c.subscriptions {
+				if s == subject {
+					delete(c.subscriptions, sid)
+				}
+			}
+			c.mu.Unlock()
+			return nil
+		}
+		err := errors.New(reason)
+		c.events.Queue(func() error {
+			return c.emit("error", c.vu.Runtime().NewGoError(err))
+		})
+	default:
+		return fmt.Errorf("%w: unexpected %q", errProtocol, o.name)
+	}
+	return nil
+}
+
+// refusedSubscription returns the subject of a permissions violation for a subscription, if it's one.
+func refusedSubscription(reason string) (string, bool) {
+	const prefix = `for subscription to "`
+	i := strings.Index(strings.ToLower(reason), prefix)
+	if i < 0 {
+		return "", false
+	}
+	subject := reason[i+len(prefix):]
+	if j := strings.IndexByte(subject, '"'); j >= 0 {
+		subject = subject[:j]
+	}
+	return subject, true
+}
+
+// deliver delivers a message to the request waiting for it, or to the "message" handlers.
+func (c *Client) deliver(m *message) {
+	if m.SID == inboxSID {
+		c.mu.Lock()
+		ch := c.replies[strings.TrimPrefix(m.Subject, c.inbox)]
+		c.mu.Unlock()
+		if ch != nil {
+			select {
+			case ch <- m:
+			default:
+			}
+		}
+		return
+	}
+
+	c.mu.Lock()
+	_, ok := c.subscriptions[m.SID]
+	c.mu.Unlock()
+	if !ok {
+		// a message sent before the server processed an UNSUB
+		return
+	}
+	c.push(c.vu.State().BuiltinMetrics.NATSMessagesReceived, 1, c.tags)
+	c.events.Queue(func() error {
+		return c.emit("message", c.vu.Runtime().ToValue(messageObject(m)))
+	})
+}
+
+// messageObject returns the JS object of a message, with only the first value of its headers.
+func messageObject(m *message) map[string]interface{} {
+	headers := make(map[string]string, len(m.Header))
+	for k := range m.Header {
+		headers[k] = m.Header.Get(k)
+	}
+	return map[string]interface{}{
+		"subject": m.Subject,
+		"reply":   m.Reply,
+		"headers": headers,
+		"data":    string(m.Data),
+	}
+}
+
+// closeWith closes the connection, failing everything that waits on it with err.
+func (c *Client) closeWith(err error) {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return
+	}
+	c.err = err
+	c.mu.Unlock()
+
+	c.cancel()
+	_ = c.conn.Close()
+	if !errors.Is(err, errClosed) {
+		c.events.Queue(func() error {
+			return c.emit("error", c.vu.Runtime().NewGoError(err))
+		})
+	}
+	c.events.Release()
+}
+
+// closedErr returns why the connection was closed.
+func (c *Client) closedErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *Client) write(b []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err := c.conn.Write(b)
+	return err
+}
+
+func (c *Client) checkConnected() error {
+	if c.conn == nil {
+		return errNotConnected
+	}
+	if err := c.closedErr(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// request publishes a message with a new reply subject, and returns the channel of its replies, which keeps
+// at most size of them, with the function to call once they aren't waited for anymore.
+func (c *Client) request(m *message, size int) (chan *message, func(), error) {
+	c.mu.Lock()
+	c.nextToken++
+	token := strconv.FormatUint(c.nextToken, 10)
+	ch := make(chan *message, size)
+	c.replies[token] = ch
+	c.mu.Unlock()
+	done := func() {
+		c.mu.Lock()
+		delete(c.replies, token)
+		c.mu.Unlock()
+	}
+
+	m.Reply = c.inbox + token
+	if err := c.write(encodePub(m)); err != nil {
+		done()
+		return nil, nil, err
+	}
+	return ch, done, nil
+}
+
+// await waits for the next reply of a request to subject.
+func (c *Client) await(ch chan *message, subject string, timer *time.Timer, timeout time.Duration) (*message, error) {
+	select {
+	case m := <-ch:
+		if m.Status == 503 {
+			return nil, fmt.Errorf("no responders are available for the NATS subject %q", subject)
+		}
+		return m, nil
+	case <-c.ctx.Done():
+		return nil, c.closedErr()
+	case <-timer.C:
+		return nil, fmt.Errorf("the NATS request to %q timed out after %s", subject, timeout)
+	}
+}
+
+// roundTrip publishes a message and waits for its first reply.
+func (c *Client) roundTrip(m *message, timeout time.Duration) (*message, error) {
+	ch, done, err := c.request(m, 1)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	return c.await(ch, m.Subject, timer, timeout)
+}
+
+// flush waits until the server has processed everything that was written before, by answering a PING.
+func (c *Client) flush() error {
+	c.pingMu.Lock()
+	defer c.pingMu.Unlock()
+	select { // a late response to a ping that timed out
+	case <-c.pongs:
+	default:
+	}
+
+	if err := c.write([]byte("PING\r\n")); err != nil {
+		return err
+	}
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+	select {
+	case <-c.pongs:
+		return nil
+	case <-c.ctx.Done():
+		return c.closedErr()
+	case <-timer.C:
+		return fmt.Errorf("the NATS server didn't respond to a ping within %s", c.timeout)
+	}
+}
+
+// publishParams are the params of publish(), request() and jetStreamPublish().
+type publishParams struct {
+	Header  textproto.MIMEHeader
+	Timeout time.Duration
+	Tags    map[string]string
+}
+
+func (c *Client) parsePublishParams(method string, raw map[string]interface{}) (publishParams, error) {
+	p := publishParams{Timeout: c.timeout}
+	var err error
+	for k, v := range raw {
+		switch {
+		case k == "headers":
+			headers, ok := v.(map[string]interface{})
+			if !ok {
+				return p, fmt.Errorf("the headers must be an object of string values, got '%#v'", v)
+			}
+			if p.Header == nil {
+				p.Header = make(textproto.MIMEHeader, len(headers))
+			}
+			for name, value := range headers {
+				p.Header.Set(name, fmt.Sprint(value))
+			}
+		case k == "timeout" && method != "publish":
+			p.Timeout, err = types.GetDurationValue(v)
+			if err != nil || p.Timeout <= 0 {
+				return p, fmt.Errorf("invalid timeout value '%#v'", v)
+			}
+		case (k == "msgId" || k == "expectedStream") && method == "jetStreamPublish":
+			if p.Header == nil {
+				p.Header = make(textproto.MIMEHeader, 1)
+			}
+			name := map[string]string{"msgId": "Nats-Msg-Id", "expectedStream": "Nats-Expected-Stream"}[k]
+			p.Header.Set(name, fmt.Sprint(v))
+		case k == "tags":
+			if p.Tags, err = parseTags(v); err != nil {
+				return p, err
+			}
+		default:
+			return p, fmt.Errorf("unknown %s param: %q", method, k)
+		}
+	}
+	if p.Header != nil && !c.info.Headers {
+		return p, errors.New("the NATS server doesn't support headers")
+	}
+	return p, nil
+}
+
+// newMessage returns the message to publish to subject, with data being a string or an ArrayBuffer.
+func (c *Client) newMessage(subject string, data goja.Value, p publishParams) (*message, error) {
+	if err := checkSubject("subject", subject); err != nil {
+		return nil, err
+	}
+	var b []byte
+	if data != nil && !goja.IsUndefined(data) && !goja.IsNull(data) {
+		var err error
+		if b, err = common.ToBytes(data.Export()); err != nil {
+			return nil, err
+		}
+	}
+	m := &message{Subject: subject, Header: p.Header, Data: b}
+	size := len(b)
+	if m.Header != nil {
+		size += len(encodeHeader(m.Header))
+	}
+	if c.info.MaxPayload > 0 && size > c.info.MaxPayload {
+		return nil, fmt.Errorf("the message of %d bytes exceeds the maximum payload of the NATS server, %d bytes",
+			size, c.info.MaxPayload)
+	}
+	return m, nil
+}
+
+// Publish publishes a message, which is a string or an ArrayBuffer. The server doesn't acknowledge it,
+// it's only known to have received it once the client closes the connection or gets the reply of a request.
+func (c *Client) Publish(subject string, data goja.Value, params map[string]interface{}) error {
+	if err := c.checkConnected(); err != nil {
+		return err
+	}
+	p, err := c.parsePublishParams("publish", params)
+	if err != nil {
+		return err
+	}
+	m, err := c.newMessage(subject, data, p)
+	if err != nil {
+		return err
+	}
+	if err = c.write(encodePub(m)); err != nil {
+		return err
+	}
+	c.push(c.vu.State().BuiltinMetrics.NATSMessagesSent, 1, c.metricTags(p.Tags, "subject", subject))
+	return nil
+}
+
+// Request publishes a message and returns its first reply, or fails after the timeout of the params,
+// the one of the connection by default.
+func (c *Client) Request(
+	subject string, data goja.Value, params map[string]interface{},
+) (map[string]interface{}, error) {
+	if err := c.checkConnected(); err != nil {
+		return nil, err
+	}
+	p, err := c.parsePublishParams("request", params)
+	if err != nil {
+		return nil, err
+	}
+	m, err := c.newMessage(subject, data, p)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := c.roundTrip(m, p.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	builtinMetrics, tags := c.vu.State().BuiltinMetrics, c.metricTags(p.Tags, "subject", subject)
+	c.push(builtinMetrics.NATSMessagesSent, 1, tags)
+	c.push(builtinMetrics.NATSMessagesReceived, 1, tags)
+	return messageObject(reply), nil
+}
+
+// apiError is the error of a response of the JetStream API.
+type apiError struct {
+	Code        int    `json:"code"`
+	ErrCode     int    `json:"err_code"`
+	Description string `json:"description"`
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("the JetStream request failed with the error %d: %s", e.ErrCode, e.Description)
+}
+
+// JetStreamPublish publishes a message to a JetStream stream and returns its acknowledgement, with the stream,
+// the sequence of the message in it and whether it was a duplicate of one with the same msgId.
+func (c *Client) JetStreamPublish(
+	subject string, data goja.Value, params map[string]interface{},
+) (map[string]interface{}, error) {
+	if err := c.checkConnected(); err != nil {
+		return nil, err
+	}
+	p, err := c.parsePublishParams("jetStreamPublish", params)
+	if err != nil {
+		return nil, err
+	}
+	m, err := c.newMessage(subject, data, p)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	reply, err := c.roundTrip(m, p.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	d := time.Since(start)
+	var ack struct {
+		Stream    string    `json:"stream"`
+		Seq       uint64    `json:"seq"`
+		Duplicate bool      `json:"duplicate"`
+		Error     *apiError `json:"error"`
+	}
+	if err = json.Unmarshal(reply.Data, &ack); err != nil {
+		return nil, fmt.Errorf("invalid JetStream publish acknowledgement: %w", err)
+	}
+	if ack.Error != nil {
+		return nil, ack.Error
+	}
+	if ack.Stream == "" {
+		return nil, fmt.Errorf("the subject %q isn't stored by any JetStream stream", subject)
+	}
+
+	builtinMetrics, tags := c.vu.State().BuiltinMetrics, c.metricTags(p.Tags, "subject", subject)
+	c.push(builtinMetrics.NATSMessagesSent, 1, tags)
+	c.push(builtinMetrics.NATSPublishDuration, stats.D(d), tags)
+	return map[string]interface{}{
+		"stream":    ack.Stream,
+		"seq":       ack.Seq,
+		"duplicate": ack.Duplicate,
+	}, nil
+}
+
+// jsMetadata is the metadata of a message delivered by a JetStream consumer, from its reply subject.
+type jsMetadata struct {
+	Stream     string
+	Consumer   string
+	Deliveries uint64
+	Sequence   uint64
+	Pending    uint64
+	Timestamp  time.Time
+}
+
+// parseJSMetadata parses a reply subject $JS.ACK.<stream>.<consumer>.<deliveries>.<stream sequence>.
+// <consumer sequence>.<timestamp>.<pending>, or the one of NATS 2.9 that has the domain and the hash of
+// the account before the stream, and an extra token at the end.
+func parseJSMetadata(reply string) (*jsMetadata, error) {
+	tokens := strings.Split(reply, ".")
+	if len(tokens) < 9 || tokens[0] != "$JS" || tokens[1] != "ACK" || len(tokens) == 10 {
+		return nil, fmt.Errorf("the message with the reply subject %q isn't a JetStream message", reply)
+	}
+	if len(tokens) == 9 {
+		tokens = tokens[2:]
+	} else {
+		tokens = tokens[4:]
+	}
+	var numbers [5]uint64
+	for i := range numbers {
+		n, err := strconv.ParseUint(tokens[i+2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("the message with the reply subject %q isn't a JetStream message", reply)
+		}
+		numbers[i] = n
+	}
+	return &jsMetadata{
+		Stream:     tokens[0],
+		Consumer:   tokens[1],
+		Deliveries: numbers[0],
+		Sequence:   numbers[1],
+		Timestamp:  time.Unix(0, int64(numbers[3])),
+		Pending:    numbers[4],
+	}, nil
+}
+
+// Consume pulls the messages of a JetStream pull consumer, it waits for them until it has the limit of
+// messages in its params, 1 by default, or for its maxWait, 1s by default. The messages are acknowledged
+// before they're returned.
+//
+//nolint:funlen,cyclop
+func (c *Client) Consume(stream, consumer string, params map[string]interface{}) ([]map[string]interface{}, error) {
+	if err := c.checkConnected(); err != nil {
+		return nil, err
+	}
+	if err := checkSubject("stream", stream); err != nil {
+		return nil, err
+	}
+	if err := checkSubject("consumer", consumer); err != nil {
+		return nil, err
+	}
+	limit, maxWait := int64(1), defaultMaxWait
+	var tags map[string]string
+	var err error
+	for k, v := range params {
+		switch k {
+		case "limit":
+			var ok bool
+			if limit, ok = v.(int64); !ok || limit <= 0 || limit > 10000 {
+				return nil, fmt.Errorf("invalid limit '%#v'", v)
+			}
+		case "maxWait":
+			if maxWait, err = types.GetDurationValue(v); err != nil || maxWait <= 0 {
+				return nil, fmt.Errorf("invalid maxWait value '%#v'", v)
+			}
+		case "tags":
+			if tags, err = parseTags(v); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unknown consume param: %q", k)
+		}
+	}
+
+	pull, err := json.Marshal(map[string]int64{"batch": limit, "expires": maxWait.Nanoseconds()})
+	if err != nil {
+		return nil, err
+	}
+	subject := "$JS.API.CONSUMER.MSG.NEXT." + stream + "." + consumer
+	// the limit of messages, and the status that ends the pull request
+	ch, done, err := c.request(&message{Subject: subject, Data: pull}, int(limit)+1)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	// the server ends the pull request once it expires, the timer is only there in case it doesn't
+	timer := time.NewTimer(maxWait + c.timeout)
+	defer timer.Stop()
+
+	var messages []*message
+	for len(messages) < int(limit) {
+		m, err := c.await(ch, subject, timer, maxWait+c.timeout)
+		if err != nil {
+			return nil, err
+		}
+		if m.Status == 404 || m.Status == 408 {
+			// no messages, or no more of them before the pull request expired
+			break
+		}
+		if m.Status == 100 {
+			continue
+		}
+		if m.Status != 0 {
+			return nil, fmt.Errorf("the JetStream pull request failed with the status %d %s", m.Status, m.Description)
+		}
+		messages = append(messages, m)
+	}
+
+	builtinMetrics := c.vu.State().BuiltinMetrics
+	metricTags := c.metricTags(tags, "stream", stream)
+	objects := make([]map[string]interface{}, 0, len(messages))
+	now := time.Now()
+	for _, m := range messages {
+		meta, err := parseJSMetadata(m.Reply)
+		if err != nil {
+			return nil, err
+		}
+		if err = c.write(encodePub(&message{Subject: m.Reply, Data: []byte("+ACK")})); err != nil {
+			return nil, err
+		}
+		c.push(builtinMetrics.NATSDeliveryLag, stats.D(now.Sub(meta.Timestamp)), metricTags)
+
+		obj := messageObject(m)
+		obj["stream"] = meta.Stream
+		obj["seq"] = meta.Sequence
+		obj["deliveries"] = meta.Deliveries
+		obj["pending"] = meta.Pending
+		obj["timestamp"] = meta.Timestamp.UnixMilli()
+		objects = append(objects, obj)
+	}
+	if len(messages) > 0 {
+		c.push(builtinMetrics.NATSMessagesReceived, float64(len(messages)), metricTags)
+	}
+	return objects, nil
+}
+
+// Subscribe subscribes to a subject, which can have wildcards, in the queue group of the params if there's one.
+// The messages are delivered to the "message" handlers.
+func (c *Client) Subscribe(subject string, params map[string]interface{}) error {
+	if err := c.checkConnected(); err != nil {
+		return err
+	}
+	if err := checkSubject("subject", subject); err != nil {
+		return err
+	}
+	var queue string
+	for k, v := range params {
+		if k != "queue" {
+			return fmt.Errorf("unknown subscribe param: %q", k)
+		}
+		queue, _ = v.(string)
+		if err := checkSubject("queue group", queue); err != nil {
+			return err
+		}
+	}
+
+	c.nextSID++
+	sid := strconv.Itoa(c.nextSID)
+	// the messages can arrive right after the SUB, so they have to be waited for already
+	c.events.Hold()
+	c.mu.Lock()
+	c.subscriptions[sid] = subject
+	c.mu.Unlock()
+	err := c.write(encodeSub(subject, queue, sid))
+	if err == nil {
+		err = c.flush()
+	}
+	c.mu.Lock()
+	if _, ok := c.subscriptions[sid]; !ok && err == nil {
+		err = fmt.Errorf("the subscription to %q was refused by the NATS server", subject)
+	}
+	c.mu.Unlock()
+	if err != nil {
+		c.mu.Lock()
+		delete(c.subscriptions, sid)
+		c.mu.Unlock()
+		if c.subscribed() == 0 {
+			c.events.Release()
+		}
+	}
+	return err
+}
+
+// Unsubscribe unsubscribes from all the subscriptions to a subject. Once the client has no subscriptions left,
+// the iteration doesn't wait for messages anymore.
+func (c *Client) Unsubscribe(subject string) error {
+	if err := c.checkConnected(); err != nil {
+		return err
+	}
+	var sids []string
+	c.mu.Lock()
+	for sid, s := range c.subscriptions {
+		if s == subject {
+			sids = append(sids, sid)
+			delete(c.subscriptions, sid)
+		}
+	}
+	c.mu.Unlock()
+	if len(sids) == 0 {
+		return fmt.Errorf("the NATS client isn't subscribed to %q", subject)
+	}
+	for _, sid := range sids {
+		if err := c.write([]byte("UNSUB " + sid + "\r\n")); err != nil {
+			return err
+		}
+	}
+	if c.subscribed() == 0 {
+		c.events.Release()
+	}
+	return nil
+}
+
+func (c *Client) subscribed() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.subscriptions)
+}
+
+// On sets a handler for the "message" or "error" events.
+func (c *Client) On(event string, handler goja.Value) error {
+	if event != "message" && event != "error" {
+		return fmt.Errorf("unknown NATS client event %q, it needs to be message or error", event)
+	}
+	fn, ok := goja.AssertFunction(handler)
+	if !ok {
+		return fmt.Errorf("the handler of the %q event needs to be a function", event)
+	}
+	c.handlers[event] = append(c.handlers[event], fn)
+	return nil
+}
+
+func (c *Client) emit(event string, arg goja.Value) error {
+	for _, fn := range c.handlers[event] {
+		if _, err := fn(goja.Undefined(), arg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the connection once the server has processed what was published, after which
+// no more messages are delivered.
+func (c *Client) Close() error {
+	if c.conn == nil || c.closedErr() != nil {
+		return nil
+	}
+	c.mu.Lock()
+	c.subscriptions = make(map[string]string)
+	c.mu.Unlock()
+	err := c.flush()
+	c.closeWith(errClosed)
+	return err
+}
+
+func (c *Client) metricTags(extra map[string]string, name, value string) map[string]string {
+	tags := make(map[string]string, len(c.tags)+len(extra)+1)
+	for k, v := range c.tags {
+		tags[k] = v
+	}
+	tags[name] = value
+	for k, v := range extra {
+		tags[k] = v
+	}
+	return tags
+}
+
+func (c *Client) push(metric *stats.Metric, value float64, tags map[string]string) {
+	tagsCopy := make(map[string]string, len(tags))
+	for k, v := range tags {
+		tagsCopy[k] = v
+	}
+	stats.PushIfNotDone(c.vu.Context(), c.vu.State().Samples, stats.Sample{
+		Metric: metric,
+		Tags:   stats.IntoSampleTags(&tagsCopy),
+		Value:  value,
+		Time:   time.Now(),
+	})
+}
@@ -0,0 +1,593 @@
+package nats
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/lib/testutils/httpmultibin"
+	"go.k6.io/k6/stats"
+)
+
+func newTestVU(
+	t *testing.T,
+) (*httpmultibin.HTTPMultiBin, *modulestest.LoopVU, context.CancelFunc, chan stats.SampleContainer) {
+	t.Helper()
+	tb := httpmultibin.NewHTTPMultiBin(t)
+	testVU, samples := modulestest.NewTestVU(t, tb, stats.TagName, stats.TagURL)
+	ctx, cancel := context.WithCancel(testVU.CtxField)
+	testVU.CtxField = ctx
+	vu := modulestest.NewLoopVU(testVU)
+	m, ok := New().NewModuleInstance(vu).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, vu.Runtime().Set("nats", m.Exports().Named))
+	return tb, vu, cancel, samples
+}
+
+// testServer is a NATS server with just enough of the protocol for the tests. It refuses the connections
+// with the "bad" user and the subscriptions to the "forbidden" subject, it answers the requests to "echo"
+// and disconnects the clients that publish to "disconnect". It has a JetStream stream ORDERS, of the
+// orders.> subjects, with a pull consumer "worker".
+type testServer struct {
+	addr      string
+	tlsConfig *tls.Config
+
+	mu     sync.Mutex
+	subs   map[*serverConn]map[string]serverSub
+	stored []storedMessage
+	msgIDs map[string]int
+	// consumed is the number of stored messages delivered to the consumer
+	consumed int
+	acks     []string
+}
+
+type serverSub struct {
+	subject, queue string
+}
+
+type storedMessage struct {
+	header, data []byte
+	time         time.Time
+}
+
+type serverConn struct {
+	net.Conn
+	noResponders bool
+	mu           sync.Mutex
+}
+
+func (c *serverConn) send(s string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, _ = io.WriteString(c, s)
+}
+
+func (c *serverConn) msg(subject, sid, reply string, header, data []byte) {
+	if reply != "" {
+		reply += " "
+	}
+	if header == nil {
+		c.send(fmt.Sprintf("MSG %s %s %s%d\r\n%s\r\n", subject, sid, reply, len(data), data))
+		return
+	}
+	c.send(fmt.Sprintf("HMSG %s %s %s%d %d\r\n%s%s\r\n", subject, sid, reply, len(header),
+		len(header)+len(data), header, data))
+}
+
+func newTestServer(t *testing.T, tlsConfig *tls.Config) *testServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+
+	s := &testServer{
+		addr:      ln.Addr().String(),
+		tlsConfig: tlsConfig,
+		subs:      make(map[*serverConn]map[string]serverSub),
+		msgIDs:    make(map[string]int),
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+//nolint:cyclop
+func (s *testServer) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	info := fmt.Sprintf(`INFO {"server_id":"test","headers":true,"max_payload":1024,"tls_required":%t}`+"\r\n",
+		s.tlsConfig != nil)
+	if _, err := io.WriteString(conn, info); err != nil {
+		return
+	}
+	if s.tlsConfig != nil {
+		conn = tls.Server(conn, s.tlsConfig)
+	}
+	c := &serverConn{Conn: conn}
+	s.mu.Lock()
+	s.subs[c] = make(map[string]serverSub)
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.subs, c)
+		s.mu.Unlock()
+	}()
+
+	r := bufio.NewReader(c)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		args := strings.Fields(line)
+		if len(args) == 0 {
+			continue
+		}
+		switch strings.ToUpper(args[0]) {
+		case "CONNECT":
+			var o connectOptions
+			if json.Unmarshal([]byte(strings.TrimSpace(line)[len("CONNECT "):]), &o) != nil || o.User == "bad" {
+				c.send("-ERR 'Authorization Violation'\r\n")
+				return
+			}
+			c.noResponders = o.NoResponders
+		case "PING":
+			c.send("PONG\r\n")
+		case "SUB":
+			sub := serverSub{subject: args[1]}
+			if len(args) == 4 {
+				sub.queue = args[2]
+			}
+			if sub.subject == "forbidden" {
+				c.send(`-ERR 'Permissions Violation for Subscription to "forbidden"'` + "\r\n")
+				continue
+			}
+			s.mu.Lock()
+			s.subs[c][args[len(args)-1]] = sub
+			s.mu.Unlock()
+		case "UNSUB":
+			s.mu.Lock()
+			delete(s.subs[c], args[1])
+			s.mu.Unlock()
+		case "PUB", "HPUB":
+			m, header, err := readPub(r, args)
+			if err != nil || m.Subject == "disconnect" {
+				return
+			}
+			s.route(c, m, header)
+		}
+	}
+}
+
+// readPub reads the payload of a PUB or an HPUB, and returns the message with its header block.
+func readPub(r *bufio.Reader, args []string) (*message, []byte, error) {
+	sizes := 1
+	if args[0] == "HPUB" {
+		sizes = 2
+	}
+	m := &message{Subject: args[1]}
+	if len(args) == sizes+3 {
+		m.Reply = args[2]
+	}
+	total, _ := strconv.Atoi(args[len(args)-1])
+	var headerSize int
+	if sizes == 2 {
+		headerSize, _ = strconv.Atoi(args[len(args)-2])
+	}
+	b := make([]byte, total+2)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, nil, err
+	}
+	var header []byte
+	if sizes == 2 {
+		header = b[:headerSize]
+		if err := m.decodeHeader(header); err != nil {
+			return nil, nil, err
+		}
+	}
+	m.Data = b[headerSize:total]
+	return m, header, nil
+}
+
+func (s *testServer) route(c *serverConn, m *message, header []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case m.Subject == "echo":
+		s.reply(c, m.Reply, header, []byte(strings.ToUpper(string(m.Data))))
+		return
+	case m.Subject == "void":
+		return
+	case strings.HasPrefix(m.Subject, "orders."):
+		s.store(c, m, header)
+		return
+	case m.Subject == "$JS.API.CONSUMER.MSG.NEXT.ORDERS.worker":
+		s.pull(c, m)
+		return
+	case strings.HasPrefix(m.Subject, "$JS.ACK."):
+		s.acks = append(s.acks, string(m.Data)+" "+m.Subject)
+		return
+	}
+
+	var delivered bool
+	groups := make(map[string]bool)
+	for conn, subs := range s.subs {
+		for sid, sub := range subs {
+			if !matchSubject(sub.subject, m.Subject) || groups[sub.queue] {
+				continue
+			}
+			if sub.queue != "" {
+				groups[sub.queue] = true
+			}
+			conn.msg(m.Subject, sid, m.Reply, header, m.Data)
+			delivered = true
+		}
+	}
+	if !delivered && m.Reply != "" && c.noResponders {
+		s.reply(c, m.Reply, []byte("NATS/1.0 503\r\n\r\n"), nil)
+	}
+}
+
+// reply sends a message to the subscription of c to the reply subject, the lock has to be held.
+func (s *testServer) reply(c *serverConn, subject string, header, data []byte) {
+	s.replyWith(c, subject, "", header, data)
+}
+
+func (s *testServer) replyWith(c *serverConn, subject, reply string, header, data []byte) {
+	for sid, sub := range s.subs[c] {
+		if matchSubject(sub.subject, subject) {
+			c.msg(subject, sid, reply, header, data)
+			return
+		}
+	}
+}
+
+// store stores a message in the ORDERS stream, the lock has to be held.
+func (s *testServer) store(c *serverConn, m *message, header []byte) {
+	if stream := m.Header.Get("Nats-Expected-Stream"); stream != "" && stream != "ORDERS" {
+		s.reply(c, m.Reply, nil,
+			[]byte(`{"error":{"code":400,"err_code":10060,"description":"expected stream does not match"}}`))
+		return
+	}
+	id := m.Header.Get("Nats-Msg-Id")
+	if seq, ok := s.msgIDs[id]; ok && id != "" {
+		s.reply(c, m.Reply, nil, []byte(fmt.Sprintf(`{"stream":"ORDERS","seq":%d,"duplicate":true}`, seq)))
+		return
+	}
+	// as if it was stored a second ago, for a measurable delivery lag
+	s.stored = append(s.stored, storedMessage{header: header, data: m.Data, time: time.Now().Add(-time.Second)})
+	s.msgIDs[id] = len(s.stored)
+	s.reply(c, m.Reply, nil, []byte(fmt.Sprintf(`{"stream":"ORDERS","seq":%d}`, len(s.stored))))
+}
+
+// pull delivers the messages of a pull request of the worker consumer, it doesn't wait for the
+// request to expire to end it with a 408 status. The lock has to be held.
+func (s *testServer) pull(c *serverConn, m *message) {
+	var req struct {
+		Batch int `json:"batch"`
+	}
+	if err := json.Unmarshal(m.Data, &req); err != nil {
+		return
+	}
+	var sent int
+	for ; sent < req.Batch && s.consumed < len(s.stored); sent++ {
+		s.consumed++
+		stored := s.stored[s.consumed-1]
+		ack := fmt.Sprintf("$JS.ACK.ORDERS.worker.1.%d.%d.%d.%d",
+			s.consumed, s.consumed, stored.time.UnixNano(), len(s.stored)-s.consumed)
+		s.replyWith(c, m.Reply, ack, stored.header, stored.data)
+	}
+	if sent < req.Batch {
+		s.reply(c, m.Reply, []byte("NATS/1.0 408 Request Timeout\r\n\r\n"), nil)
+	}
+}
+
+func matchSubject(pattern, subject string) bool {
+	p, s := strings.Split(pattern, "."), strings.Split(subject, ".")
+	for i, token := range p {
+		switch {
+		case token == ">":
+			return i < len(s)
+		case i >= len(s):
+			return false
+		case token != "*" && token != s[i]:
+			return false
+		}
+	}
+	return len(p) == len(s)
+}
+
+func TestClient(t *testing.T) {
+	t.Parallel()
+
+	t.Run("PublishSubscribe", func(t *testing.T) {
+		t.Parallel()
+		_, vu, _, samples := newTestVU(t)
+		server := newTestServer(t, nil)
+
+		_, err := vu.Runtime().RunString(strings.ReplaceAll(`
+			var received = [];
+			var sub = new nats.Client();
+			sub.connect("nats://SERVER");
+			sub.on("message", function(m) {
+				received.push([m.subject, m.data, m.headers["X-Id"] || "-"].join(" "));
+				if (received.length === 3) {
+					sub.close();
+				}
+			});
+			sub.subscribe("sensors.*");
+			sub.subscribe("alerts.>", { queue: "workers" });
+			sub.subscribe("alerts.>", { queue: "workers" });
+
+			var pub = new nats.Client();
+			pub.connect("nats://SERVER");
+			pub.publish("sensors.temp", "21");
+			pub.publish("other", "x");
+			pub.publish("sensors.humidity", "50", { headers: { "X-Id": 1 }, tags: { tag: "value" } });
+			pub.publish("alerts.high.temp", new Uint8Array([104, 105]).buffer);
+			pub.close();
+		`, "SERVER", server.addr))
+		require.NoError(t, err)
+		require.NoError(t, vu.Run())
+
+		v, err := vu.Runtime().RunString(`received.join(",")`)
+		require.NoError(t, err)
+		assert.Equal(t, "sensors.temp 21 -,sensors.humidity 50 1,alerts.high.temp hi -", v.String())
+
+		counts := map[string]float64{}
+		for _, c := range stats.GetBufferedSamples(samples) {
+			for _, s := range c.GetSamples() {
+				url, _ := s.Tags.Get("url")
+				assert.Equal(t, "nats://"+server.addr, url)
+				counts[s.Metric.Name] += s.Value
+				if tag, ok := s.Tags.Get("tag"); ok {
+					assert.Equal(t, "value", tag)
+					subject, _ := s.Tags.Get("subject")
+					assert.Equal(t, "sensors.humidity", subject)
+				}
+			}
+		}
+		assert.Equal(t, float64(4), counts[metrics.NATSMessagesSentName])
+		assert.Equal(t, float64(3), counts[metrics.NATSMessagesReceivedName])
+	})
+
+	t.Run("Request", func(t *testing.T) {
+		t.Parallel()
+		_, vu, _, _ := newTestVU(t)
+		server := newTestServer(t, nil)
+
+		_, err := vu.Runtime().RunString(strings.ReplaceAll(`
+			var client = new nats.Client();
+			client.connect("nats://SERVER");
+			var reply = client.request("echo", "hello", { headers: { "X-Id": "1" }, timeout: "1s" });
+			client.close();
+		`, "SERVER", server.addr))
+		require.NoError(t, err)
+		require.NoError(t, vu.Run())
+
+		v, err := vu.Runtime().RunString(`reply.data + " " + reply.headers["X-Id"] + " " + reply.subject.indexOf("_INBOX.")`)
+		require.NoError(t, err)
+		assert.Equal(t, "HELLO 1 0", v.String())
+	})
+
+	t.Run("JetStream", func(t *testing.T) {
+		t.Parallel()
+		_, vu, _, samples := newTestVU(t)
+		server := newTestServer(t, nil)
+
+		_, err := vu.Runtime().RunString(strings.ReplaceAll(`
+			var client = new nats.Client();
+			client.connect("nats://SERVER");
+			var acks = [
+				client.jetStreamPublish("orders.new", "1", { msgId: "a" }),
+				client.jetStreamPublish("orders.new", "2", { msgId: "b", expectedStream: "ORDERS" }),
+				client.jetStreamPublish("orders.new", "1", { msgId: "a" }),
+			].map(function(a) { return [a.stream, a.seq, a.duplicate].join(" "); });
+			var first = client.consume("ORDERS", "worker");
+			var rest = client.consume("ORDERS", "worker", { limit: 5, maxWait: "100ms" });
+			var none = client.consume("ORDERS", "worker");
+			client.close();
+		`, "SERVER", server.addr))
+		require.NoError(t, err)
+		require.NoError(t, vu.Run())
+
+		v, err := vu.Runtime().RunString(`acks.join(",") + "|" + first.concat(rest).map(function(m) {
+			return [m.data, m.stream, m.seq, m.deliveries, m.pending, m.headers["Nats-Msg-Id"]].join(" ");
+		}).join(",") + "|" + none.length`)
+		require.NoError(t, err)
+		assert.Equal(t, "ORDERS 1 false,ORDERS 2 false,ORDERS 1 true|1 ORDERS 1 1 1 a,2 ORDERS 2 1 0 b|0", v.String())
+		v, err = vu.Runtime().RunString(`Date.now() - first[0].timestamp`)
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, v.ToInteger(), int64(1000))
+
+		server.mu.Lock()
+		acked := server.acks
+		server.mu.Unlock()
+		require.Len(t, acked, 2)
+		assert.True(t, strings.HasPrefix(acked[0], "+ACK $JS.ACK.ORDERS.worker.1.1.1."), acked[0])
+		assert.True(t, strings.HasPrefix(acked[1], "+ACK $JS.ACK.ORDERS.worker.1.2.2."), acked[1])
+
+		counts := map[string]float64{}
+		for _, c := range stats.GetBufferedSamples(samples) {
+			for _, s := range c.GetSamples() {
+				counts[s.Metric.Name]++
+				switch s.Metric.Name {
+				case metrics.NATSDeliveryLagName:
+					assert.GreaterOrEqual(t, s.Value, float64(1000))
+					stream, _ := s.Tags.Get("stream")
+					assert.Equal(t, "ORDERS", stream)
+				case metrics.NATSPublishDurationName:
+					subject, _ := s.Tags.Get("subject")
+					assert.Equal(t, "orders.new", subject)
+				}
+			}
+		}
+		assert.Equal(t, float64(3), counts[metrics.NATSPublishDurationName])
+		assert.Equal(t, float64(3), counts[metrics.NATSMessagesSentName])
+		assert.Equal(t, float64(2), counts[metrics.NATSDeliveryLagName])
+		assert.Equal(t, float64(2), counts[metrics.NATSMessagesReceivedName])
+	})
+
+	t.Run("TLS", func(t *testing.T) {
+		t.Parallel()
+		tb, vu, _, _ := newTestVU(t)
+		server := newTestServer(t, tb.ServerHTTPS.TLS)
+
+		_, err := vu.Runtime().RunString(strings.ReplaceAll(`
+			var client = new nats.Client();
+			client.connect("tls://SERVER");
+			var reply = client.request("echo", "tls");
+			client.close();
+		`, "SERVER", server.addr))
+		require.NoError(t, err)
+		require.NoError(t, vu.Run())
+		assert.Equal(t, "TLS", vu.Runtime().Get("reply").ToObject(vu.Runtime()).Get("data").String())
+	})
+
+	t.Run("ServerDisconnect", func(t *testing.T) {
+		t.Parallel()
+		_, vu, _, _ := newTestVU(t)
+		server := newTestServer(t, nil)
+
+		_, err := vu.Runtime().RunString(strings.ReplaceAll(`
+			var errors = [];
+			var client = new nats.Client();
+			client.connect("nats://SERVER");
+			client.on("error", function(e) { errors.push(e.message); });
+			client.subscribe("subject");
+			client.publish("disconnect", "");
+		`, "SERVER", server.addr))
+		require.NoError(t, err)
+		require.NoError(t, vu.Run())
+		v, err := vu.Runtime().RunString(`errors.join(",")`)
+		require.NoError(t, err)
+		assert.Equal(t, io.EOF.Error(), v.String())
+
+		_, err = vu.Runtime().RunString(`client.publish("subject", "")`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "EOF")
+	})
+
+	t.Run("IterationEnd", func(t *testing.T) {
+		t.Parallel()
+		_, vu, cancel, _ := newTestVU(t)
+		server := newTestServer(t, nil)
+
+		_, err := vu.Runtime().RunString(strings.ReplaceAll(`
+			var client = new nats.Client();
+			client.connect("nats://SERVER");
+			client.on("message", function() {});
+			client.subscribe("subject");
+		`, "SERVER", server.addr))
+		require.NoError(t, err)
+		// the subscription keeps the iteration waiting for messages until it ends
+		assert.Equal(t, 1, vu.Registered())
+		cancel()
+		require.NoError(t, vu.Run())
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		t.Parallel()
+		_, vu, _, _ := newTestVU(t)
+		server := newTestServer(t, nil)
+		require.NoError(t, vu.Runtime().Set("server", "nats://"+server.addr))
+
+		tests := []struct {
+			script, err string
+		}{
+			{`new nats.Client().connect(server, { user: "bad" })`, "refused the connection: Authorization Violation"},
+			{`new nats.Client().connect("http://" + server.slice(7))`, "the scheme needs to be nats or tls"},
+			{`new nats.Client().connect(server, { qos: 1 })`, `unknown connect param: "qos"`},
+			{`new nats.Client().publish("subject", "")`, "isn't connected"},
+			{`var c = new nats.Client(); c.connect(server); c.connect(server)`, "was already connected"},
+			{`c.publish("a b", "")`, `invalid NATS subject "a b"`},
+			{`c.publish("subject", "", { timeout: 1 })`, `unknown publish param: "timeout"`},
+			{`c.publish("subject", "x".repeat(2000))`, "exceeds the maximum payload of the NATS server, 1024 bytes"},
+			{`c.subscribe("forbidden")`, `the subscription to "forbidden" was refused`},
+			{`c.subscribe("subject", { queue: "" })`, `invalid NATS queue group ""`},
+			{`c.unsubscribe("subject")`, `isn't subscribed to "subject"`},
+			{`c.request("nobody", "")`, `no responders are available for the NATS subject "nobody"`},
+			{`c.request("void", "", { timeout: "100ms" })`, `the NATS request to "void" timed out after 100ms`},
+			{`c.jetStreamPublish("orders.new", "", { expectedStream: "OTHER" })`, "the error 10060"},
+			{`c.consume("ORDERS", "worker", { limit: 0 })`, "invalid limit"},
+			{`c.on("close", function() {})`, `unknown NATS client event "close"`},
+		}
+		for _, tc := range tests {
+			_, err := vu.Runtime().RunString(tc.script)
+			require.Error(t, err, tc.script)
+			assert.Contains(t, err.Error(), tc.err, tc.script)
+		}
+		_, err := vu.Runtime().RunString(`c.close()`)
+		require.NoError(t, err)
+		require.NoError(t, vu.Run())
+	})
+
+	t.Run("InitContext", func(t *testing.T) {
+		t.Parallel()
+		_, vu, _, _ := newTestVU(t)
+		vu.StateField = nil
+		_, err := vu.Runtime().RunString(`new nats.Client().connect("nats://localhost")`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "in the init context is not supported")
+	})
+}
+
+func TestProtocol(t *testing.T) {
+	t.Parallel()
+
+	m := &message{Subject: "a.b", Reply: "reply", Header: map[string][]string{"X-Id": {"1"}}, Data: []byte("data")}
+	pub := string(encodePub(m))
+	assert.Equal(t, "HPUB a.b reply 21 25\r\nNATS/1.0\r\nX-Id: 1\r\n\r\ndata\r\n", pub)
+	o, err := readOp(bufio.NewReader(strings.NewReader(strings.Replace(pub, "HPUB a.b ", "HMSG a.b 5 ", 1))))
+	require.NoError(t, err)
+	assert.Equal(t, "HMSG", o.name)
+	m.SID = "5"
+	assert.Equal(t, m, o.msg)
+
+	o, err = readOp(bufio.NewReader(strings.NewReader("HMSG inbox 1 16 16\r\nNATS/1.0 503\r\n\r\n\r\n")))
+	require.NoError(t, err)
+	assert.Equal(t, 503, o.msg.Status)
+	assert.Empty(t, o.msg.Data)
+
+	o, err = readOp(bufio.NewReader(strings.NewReader("-ERR 'Stale Connection'\r\n")))
+	require.NoError(t, err)
+	assert.Equal(t, &op{name: "-ERR", arg: "'Stale Connection'"}, o)
+
+	for _, invalid := range []string{"MSG a 1 x\r\n", "MSG a 1 2\r\nabc\r\n", "HMSG a 1 5 4\r\nabcd\r\n"} {
+		_, err = readOp(bufio.NewReader(strings.NewReader(invalid)))
+		assert.ErrorIs(t, err, errProtocol, invalid)
+	}
+	_, err = readOp(bufio.NewReader(strings.NewReader("MSG a 1 10\r\nabc")))
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	meta, err := parseJSMetadata("$JS.ACK.S.C.2.10.4.1000000000.7")
+	require.NoError(t, err)
+	assert.Equal(t, &jsMetadata{
+		Stream: "S", Consumer: "C", Deliveries: 2, Sequence: 10, Pending: 7, Timestamp: time.Unix(1, 0),
+	}, meta)
+	meta, err = parseJSMetadata("$JS.ACK.domain.hash.S.C.2.10.4.1000000000.7.token")
+	require.NoError(t, err)
+	assert.Equal(t, "S", meta.Stream)
+	_, err = parseJSMetadata("_INBOX.abc.1")
+	assert.Error(t, err)
+}
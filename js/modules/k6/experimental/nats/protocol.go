@@ -0,0 +1,209 @@
+package nats
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxControlLine limits the lines of the protocol, the INFO ones included
+	maxControlLine = 64 << 10
+	// maxMessageSize limits the messages that are read, whatever the server announces
+	maxMessageSize = 64 << 20
+
+	headerVersion = "NATS/1.0"
+)
+
+var errProtocol = errors.New("invalid NATS protocol message")
+
+// serverInfo is the part of the INFO of the server that the client uses.
+type serverInfo struct {
+	ServerID    string `json:"server_id"`
+	Version     string `json:"version"`
+	Headers     bool   `json:"headers"`
+	MaxPayload  int    `json:"max_payload"`
+	TLSRequired bool   `json:"tls_required"`
+}
+
+// connectOptions are the options sent with CONNECT.
+type connectOptions struct {
+	Verbose      bool   `json:"verbose"`
+	Pedantic     bool   `json:"pedantic"`
+	TLSRequired  bool   `json:"tls_required"`
+	Name         string `json:"name,omitempty"`
+	Lang         string `json:"lang"`
+	Version      string `json:"version"`
+	Protocol     int    `json:"protocol"`
+	Headers      bool   `json:"headers"`
+	NoResponders bool   `json:"no_responders"`
+	User         string `json:"user,omitempty"`
+	Pass         string `json:"pass,omitempty"`
+	AuthToken    string `json:"auth_token,omitempty"`
+}
+
+// message is a message delivered with MSG or HMSG, or one to publish with PUB or HPUB.
+type message struct {
+	Subject string
+	SID     string
+	Reply   string
+	// Header is nil for the messages without headers
+	Header textproto.MIMEHeader
+	// Status and Description are those of the status messages of the server, like 503 for no responders
+	Status      int
+	Description string
+	Data        []byte
+}
+
+// op is a protocol message of the server, with its message for MSG and HMSG.
+type op struct {
+	name string
+	arg  string
+	msg  *message
+}
+
+// readOp reads the next protocol message of the server.
+func readOp(r *bufio.Reader) (*op, error) {
+	line, err := r.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		return nil, fmt.Errorf("%w: the line is longer than %d bytes", errProtocol, maxControlLine)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := strings.TrimRight(string(line), "\r\n")
+	o := &op{name: s}
+	if i := strings.IndexAny(s, " \t"); i >= 0 {
+		o.name, o.arg = s[:i], strings.TrimSpace(s[i+1:])
+	}
+	o.name = strings.ToUpper(o.name)
+	if o.name == "MSG" || o.name == "HMSG" {
+		o.msg, err = readMessage(r, o.name == "HMSG", strings.Fields(o.arg))
+	}
+	return o, err
+}
+
+// readMessage reads the payload of a MSG, with the arguments subject sid [reply] size,
+// or of an HMSG, with subject sid [reply] header-size total-size.
+func readMessage(r *bufio.Reader, hasHeader bool, args []string) (*message, error) {
+	sizes := 1
+	if hasHeader {
+		sizes = 2
+	}
+	if len(args) != sizes+2 && len(args) != sizes+3 {
+		return nil, errProtocol
+	}
+	m := &message{Subject: args[0], SID: args[1]}
+	if len(args) == sizes+3 {
+		m.Reply = args[2]
+	}
+	total, err := strconv.Atoi(args[len(args)-1])
+	if err != nil || total < 0 || total > maxMessageSize {
+		return nil, errProtocol
+	}
+	var headerSize int
+	if hasHeader {
+		if headerSize, err = strconv.Atoi(args[len(args)-2]); err != nil || headerSize < 0 || headerSize > total {
+			return nil, errProtocol
+		}
+	}
+
+	b := make([]byte, total+2)
+	if _, err = io.ReadFull(r, b); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if string(b[total:]) != "\r\n" {
+		return nil, errProtocol
+	}
+	if hasHeader {
+		if err = m.decodeHeader(b[:headerSize]); err != nil {
+			return nil, err
+		}
+	}
+	m.Data = b[headerSize:total]
+	return m, nil
+}
+
+// decodeHeader decodes the header block of an HMSG, which starts with the version line and the status, if any.
+func (m *message) decodeHeader(b []byte) error {
+	r := textproto.NewReader(bufio.NewReader(bytes.NewReader(b)))
+	line, err := r.ReadLine()
+	if err != nil || !strings.HasPrefix(line, headerVersion) {
+		return errProtocol
+	}
+	if status := strings.TrimSpace(line[len(headerVersion):]); status != "" {
+		code := status
+		if i := strings.IndexByte(status, ' '); i >= 0 {
+			code, m.Description = status[:i], strings.TrimSpace(status[i+1:])
+		}
+		if m.Status, err = strconv.Atoi(code); err != nil {
+			return errProtocol
+		}
+	}
+	if m.Header, err = r.ReadMIMEHeader(); err != nil && !errors.Is(err, io.EOF) {
+		return errProtocol
+	}
+	return nil
+}
+
+// encodeHeader encodes the header block of an HPUB, with the keys sorted.
+func encodeHeader(h textproto.MIMEHeader) []byte {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b bytes.Buffer
+	b.WriteString(headerVersion + "\r\n")
+	for _, k := range keys {
+		for _, v := range h[k] {
+			b.WriteString(k + ": " + v + "\r\n")
+		}
+	}
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
+
+// encodePub encodes a PUB, or an HPUB for a message with headers.
+func encodePub(m *message) []byte {
+	var b bytes.Buffer
+	reply := ""
+	if m.Reply != "" {
+		reply = m.Reply + " "
+	}
+	if m.Header == nil {
+		fmt.Fprintf(&b, "PUB %s %s%d\r\n", m.Subject, reply, len(m.Data))
+	} else {
+		header := encodeHeader(m.Header)
+		fmt.Fprintf(&b, "HPUB %s %s%d %d\r\n", m.Subject, reply, len(header), len(header)+len(m.Data))
+		b.Write(header)
+	}
+	b.Write(m.Data)
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
+
+// encodeSub encodes a SUB, the queue group being optional.
+func encodeSub(subject, queue, sid string) []byte {
+	if queue == "" {
+		return []byte("SUB " + subject + " " + sid + "\r\n")
+	}
+	return []byte("SUB " + subject + " " + queue + " " + sid + "\r\n")
+}
+
+// checkSubject checks that a subject, or a queue group, can be sent in a protocol line.
+func checkSubject(kind, s string) error {
+	if s == "" || strings.ContainsAny(s, " \t\r\n") {
+		return fmt.Errorf("invalid NATS %s %q", kind, s)
+	}
+	return nil
+}
@@ -0,0 +1,721 @@
+package sql
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1" //nolint:gosec
+	"crypto/sha256"
+	"crypto/x509"
+	"database/sql/driver"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/netext"
+)
+
+const (
+	mysqlDefaultPort   = "3306"
+	mysqlMaxPacketSize = 1<<24 - 1
+	mysqlTimeFormat    = "2006-01-02 15:04:05.999999"
+	// mysqlCharset is utf8mb4_general_ci
+	mysqlCharset = 45
+	// mysqlBinaryCharset is the charset of the binary columns
+	mysqlBinaryCharset = 63
+
+	mysqlNativePassword = "mysql_native_password"
+	mysqlCachingSHA2    = "caching_sha2_password"
+
+	mysqlComQuery = 0x03
+
+	mysqlOK         = 0x00
+	mysqlMoreData   = 0x01
+	mysqlLocalFile  = 0xfb
+	mysqlEOF        = 0xfe
+	mysqlErr        = 0xff
+	mysqlNull       = 0xfb
+	mysqlFastAuthOK = 0x03
+	mysqlFullAuth   = 0x04
+	mysqlPublicKey  = 0x02
+
+	mysqlClientLongPassword     = 0x1
+	mysqlClientConnectWithDB    = 0x8
+	mysqlClientProtocol41       = 0x200
+	mysqlClientSSL              = 0x800
+	mysqlClientTransactions     = 0x2000
+	mysqlClientSecureConnection = 0x8000
+	mysqlClientPluginAuth       = 0x80000
+
+	mysqlStatusNoBackslashEscapes = 0x200
+)
+
+// The column types that aren't returned as strings.
+const (
+	mysqlTypeTiny       = 1
+	mysqlTypeShort      = 2
+	mysqlTypeLong       = 3
+	mysqlTypeFloat      = 4
+	mysqlTypeDouble     = 5
+	mysqlTypeLongLong   = 8
+	mysqlTypeInt24      = 9
+	mysqlTypeYear       = 13
+	mysqlTypeVarchar    = 15
+	mysqlTypeTinyBlob   = 249
+	mysqlTypeMediumBlob = 250
+	mysqlTypeLongBlob   = 251
+	mysqlTypeBlob       = 252
+	mysqlTypeVarString  = 253
+	mysqlTypeString     = 254
+)
+
+var (
+	errInvalidMySQLPacket = errors.New("invalid MySQL packet")
+	errInvalidArgs        = errors.New("invalid query args")
+)
+
+// mysqlConnector opens the connections of a MySQL database, see
+// https://dev.mysql.com/doc/dev/mysql-server/latest/PAGE_PROTOCOL.html
+type mysqlConnector struct {
+	vu       modules.VU
+	addr     string
+	host     string
+	user     string
+	password string
+	database string
+	// tls is false, preferred, skip-verify or true, like the tls param of the go-sql-driver/mysql
+	// DSNs, the certificate of the server is only verified with true
+	tls string
+}
+
+func newMySQLConnector(vu modules.VU, u *url.URL) (*mysqlConnector, error) {
+	c := &mysqlConnector{vu: vu, host: u.Hostname(), tls: "false"}
+	port := u.Port()
+	if port == "" {
+		port = mysqlDefaultPort
+	}
+	c.addr = net.JoinHostPort(c.host, port)
+	if u.User == nil || u.User.Username() == "" {
+		return nil, errors.New("invalid database URL, it needs a user")
+	}
+	c.user = u.User.Username()
+	c.password, _ = u.User.Password()
+	c.database = strings.TrimPrefix(u.Path, "/")
+	for k, v := range u.Query() {
+		switch k {
+		case "tls":
+			switch c.tls = v[0]; c.tls {
+			case "false", "preferred", "skip-verify", "true":
+			default:
+				return nil, fmt.Errorf("invalid tls %q, it needs to be false, preferred, skip-verify or true", v[0])
+			}
+		default:
+			return nil, fmt.Errorf("unknown MySQL URL param: %q", k)
+		}
+	}
+	return c, nil
+}
+
+// Connect implements driver.Connector.
+func (c *mysqlConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	state := c.vu.State()
+	if state == nil {
+		return nil, errQueryInInitContext
+	}
+	nc, err := state.Dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, err
+	}
+	stop := netext.CloseOnDone(ctx, nc)
+	m, err := c.handshake(ctx, state, nc)
+	stop()
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		_ = nc.Close()
+		return nil, err
+	}
+	return &conn{netConn: m.conn, protocol: m}, nil
+}
+
+// Driver implements driver.Connector.
+func (c *mysqlConnector) Driver() driver.Driver {
+	return connectorDriver{}
+}
+
+// handshake answers the initial handshake of the server, over TLS if it's enabled, and authenticates the user.
+func (c *mysqlConnector) handshake(ctx context.Context, state *lib.State, nc net.Conn) (*mysql, error) {
+	m := &mysql{conn: nc, r: bufio.NewReader(nc)}
+	packet, err := m.readPacket()
+	if err != nil {
+		return nil, err
+	}
+	if packet[0] == mysqlErr {
+		return nil, mysqlError(packet)
+	}
+	if packet[0] != 10 {
+		return nil, fmt.Errorf("the MySQL protocol version %d is not supported", packet[0])
+	}
+	r := &mysqlReader{b: packet[1:]}
+	r.cstring() // the server version
+	r.next(4)   // the connection ID
+	nonce := append([]byte{}, r.next(8)...)
+	r.next(1)
+	serverCaps := uint32(r.uint16())
+	plugin := mysqlNativePassword
+	if len(r.b) > 0 {
+		r.next(1) // the charset
+		m.status = r.uint16()
+		serverCaps |= uint32(r.uint16()) << 16
+		nonceLen := int(r.byte())
+		r.next(10)
+		if serverCaps&mysqlClientSecureConnection != 0 {
+			part2Len := nonceLen - 8
+			if part2Len < 13 {
+				part2Len = 13
+			}
+			part2 := r.next(part2Len)
+			nonce = append(nonce, bytes.TrimRight(part2, "\x00")...)
+		}
+		if serverCaps&mysqlClientPluginAuth != 0 {
+			plugin = r.cstring()
+		}
+	}
+	if r.err {
+		return nil, errInvalidMySQLPacket
+	}
+	if serverCaps&mysqlClientProtocol41 == 0 {
+		return nil, errors.New("the MySQL server doesn't support the protocol 4.1")
+	}
+
+	caps := uint32(mysqlClientLongPassword | mysqlClientProtocol41 | mysqlClientTransactions |
+		mysqlClientSecureConnection | mysqlClientPluginAuth)
+	if c.database != "" {
+		caps |= mysqlClientConnectWithDB
+	}
+	secure := false
+	if c.tls != "false" {
+		if serverCaps&mysqlClientSSL != 0 {
+			caps |= mysqlClientSSL
+			if err := m.writePacket(mysqlHandshakeHeader(caps)); err != nil {
+				return nil, err
+			}
+			tlsConn, err := startTLS(ctx, state, nc, c.host, c.tls != "true")
+			if err != nil {
+				return nil, err
+			}
+			m.conn, m.r, secure = tlsConn, bufio.NewReader(tlsConn), true
+		} else if c.tls != "preferred" {
+			return nil, errors.New("the MySQL server doesn't support TLS")
+		}
+	}
+
+	authResp, err := mysqlScramble(plugin, c.password, nonce)
+	if err != nil {
+		return nil, err
+	}
+	resp := append(mysqlHandshakeHeader(caps), c.user...)
+	resp = append(append(resp, 0, byte(len(authResp))), authResp...)
+	if c.database != "" {
+		resp = append(append(resp, c.database...), 0)
+	}
+	resp = append(append(resp, plugin...), 0)
+	if err := m.writePacket(resp); err != nil {
+		return nil, err
+	}
+	if err := m.authenticate(plugin, c.password, nonce, secure); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// mysqlHandshakeHeader returns the start of the handshake response, which is the whole SSLRequest.
+func mysqlHandshakeHeader(caps uint32) []byte {
+	header := make([]byte, 32)
+	binary.LittleEndian.PutUint32(header, caps)
+	binary.LittleEndian.PutUint32(header[4:], mysqlMaxPacketSize)
+	header[8] = mysqlCharset
+	return header
+}
+
+// mysqlScramble returns the auth response of the password with the nonce of the server.
+func mysqlScramble(plugin, password string, nonce []byte) ([]byte, error) {
+	if len(nonce) > 20 {
+		nonce = nonce[:20]
+	}
+	if plugin != mysqlNativePassword && plugin != mysqlCachingSHA2 {
+		return nil, fmt.Errorf("the MySQL authentication plugin %q is not supported", plugin)
+	}
+	if password == "" {
+		return nil, nil
+	}
+	var h1, h3 []byte
+	switch plugin {
+	case mysqlNativePassword:
+		// SHA1(password) XOR SHA1(nonce + SHA1(SHA1(password)))
+		s1 := sha1.Sum([]byte(password))                             //nolint:gosec
+		s2 := sha1.Sum(s1[:])                                        //nolint:gosec
+		s3 := sha1.Sum(append(append([]byte{}, nonce...), s2[:]...)) //nolint:gosec
+		h1, h3 = s1[:], s3[:]
+	case mysqlCachingSHA2:
+		// SHA256(password) XOR SHA256(SHA256(SHA256(password)) + nonce)
+		s1 := sha256.Sum256([]byte(password))
+		s2 := sha256.Sum256(s1[:])
+		s3 := sha256.Sum256(append(s2[:], nonce...))
+		h1, h3 = s1[:], s3[:]
+	}
+	for i := range h1 {
+		h1[i] ^= h3[i]
+	}
+	return h1, nil
+}
+
+// mysql is the protocol of a MySQL connection. Its queries are sent with COM_QUERY and the args of
+// their ? placeholders are interpolated, with the escaping of the SQL mode of the server.
+type mysql struct {
+	conn   net.Conn
+	r      *bufio.Reader
+	seq    byte
+	status uint16
+}
+
+// readPacket returns the payload of the next packet, which is never empty.
+func (m *mysql) readPacket() ([]byte, error) {
+	var payload []byte
+	for {
+		var header [4]byte
+		if _, err := io.ReadFull(m.r, header[:]); err != nil {
+			return nil, err
+		}
+		length := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+		if header[3] != m.seq {
+			return nil, errors.New("out of order MySQL packet")
+		}
+		m.seq++
+		start := len(payload)
+		payload = append(payload, make([]byte, length)...)
+		if _, err := io.ReadFull(m.r, payload[start:]); err != nil {
+			return nil, err
+		}
+		// a payload of the max size is continued by the next packet
+		if length < mysqlMaxPacketSize {
+			break
+		}
+	}
+	if len(payload) == 0 {
+		return nil, errInvalidMySQLPacket
+	}
+	return payload, nil
+}
+
+func (m *mysql) writePacket(payload []byte) error {
+	var buf []byte
+	for {
+		n := len(payload)
+		if n > mysqlMaxPacketSize {
+			n = mysqlMaxPacketSize
+		}
+		buf = append(buf, byte(n), byte(n>>8), byte(n>>16), m.seq)
+		buf = append(buf, payload[:n]...)
+		m.seq++
+		payload = payload[n:]
+		if n < mysqlMaxPacketSize {
+			break
+		}
+	}
+	_, err := m.conn.Write(buf)
+	return err
+}
+
+// authenticate handles the responses of the server to the handshake response, until it's OK.
+func (m *mysql) authenticate(plugin, password string, nonce []byte, secure bool) error {
+	for {
+		packet, err := m.readPacket()
+		if err != nil {
+			return err
+		}
+		var resp []byte
+		switch packet[0] {
+		case mysqlOK:
+			m.readOK(packet)
+			return nil
+		case mysqlErr:
+			return mysqlError(packet)
+		case mysqlEOF:
+			// AuthSwitchRequest
+			r := &mysqlReader{b: packet[1:]}
+			plugin = r.cstring()
+			nonce = bytes.TrimRight(r.b, "\x00")
+			if resp, err = mysqlScramble(plugin, password, nonce); err != nil {
+				return err
+			}
+		case mysqlMoreData:
+			if plugin != mysqlCachingSHA2 || len(packet) < 2 {
+				return errInvalidMySQLPacket
+			}
+			switch {
+			case packet[1] == mysqlFastAuthOK:
+				// the OK packet follows
+				continue
+			case packet[1] == mysqlFullAuth && secure:
+				resp = append([]byte(password), 0)
+			case packet[1] == mysqlFullAuth:
+				resp = []byte{mysqlPublicKey}
+			default:
+				if resp, err = encryptPassword(packet[1:], password, nonce); err != nil {
+					return err
+				}
+			}
+		default:
+			return errInvalidMySQLPacket
+		}
+		if err := m.writePacket(resp); err != nil {
+			return err
+		}
+	}
+}
+
+// encryptPassword encrypts the password with the PEM public key of the server, for the full
+// authentication of caching_sha2_password without TLS.
+func encryptPassword(publicKey []byte, password string, nonce []byte) ([]byte, error) {
+	block, _ := pem.Decode(publicKey)
+	if block == nil {
+		return nil, errors.New("invalid public key of the MySQL server")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key of the MySQL server: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("the public key of the MySQL server isn't an RSA key")
+	}
+	plain := append([]byte(password), 0)
+	for i := range plain {
+		plain[i] ^= nonce[i%len(nonce)]
+	}
+	return rsa.EncryptOAEP(sha1.New(), rand.Reader, rsaKey, plain, nil) //nolint:gosec
+}
+
+// exchange executes the query with COM_QUERY, and reads its result set.
+func (m *mysql) exchange(query string, args []driver.NamedValue) (*resultSet, error) {
+	if len(args) > 0 {
+		var err error
+		if query, err = m.interpolate(query, args); err != nil {
+			return nil, err
+		}
+	}
+	m.seq = 0
+	if err := m.writePacket(append([]byte{mysqlComQuery}, query...)); err != nil {
+		return nil, err
+	}
+	packet, err := m.readPacket()
+	if err != nil {
+		return nil, err
+	}
+	switch packet[0] {
+	case mysqlOK:
+		return m.readOK(packet), nil
+	case mysqlErr:
+		return nil, mysqlError(packet)
+	case mysqlLocalFile:
+		return nil, errors.New("LOAD DATA LOCAL INFILE is not supported")
+	}
+
+	r := &mysqlReader{b: packet}
+	n, _ := r.lenEncInt()
+	if r.err {
+		return nil, errInvalidMySQLPacket
+	}
+	res := &resultSet{columns: make([]string, n)}
+	columns := make([]mysqlColumn, n)
+	for i := range columns {
+		if packet, err = m.readPacket(); err != nil {
+			return nil, err
+		}
+		if columns[i], err = parseColumn(packet); err != nil {
+			return nil, err
+		}
+		res.columns[i] = columns[i].name
+	}
+	if packet, err = m.readPacket(); err != nil {
+		return nil, err
+	}
+	if packet[0] != mysqlEOF {
+		return nil, errInvalidMySQLPacket
+	}
+
+	for {
+		if packet, err = m.readPacket(); err != nil {
+			return nil, err
+		}
+		switch {
+		case packet[0] == mysqlEOF && len(packet) < 9:
+			if len(packet) >= 5 {
+				m.status = binary.LittleEndian.Uint16(packet[3:])
+			}
+			return res, nil
+		case packet[0] == mysqlErr:
+			return nil, mysqlError(packet)
+		}
+		row, err := parseRow(packet, columns)
+		if err != nil {
+			return nil, err
+		}
+		res.rows = append(res.rows, row)
+	}
+}
+
+// readOK returns the result of an OK packet, and updates the status of the server.
+func (m *mysql) readOK(packet []byte) *resultSet {
+	r := &mysqlReader{b: packet[1:]}
+	affected, _ := r.lenEncInt()
+	insertID, _ := r.lenEncInt()
+	if status := r.uint16(); !r.err {
+		m.status = status
+	}
+	return &resultSet{rowsAffected: int64(affected), lastInsertID: int64(insertID)}
+}
+
+// interpolate replaces the ? placeholders of the query with the quoted args, the placeholders in
+// the strings and quoted identifiers aren't replaced.
+func (m *mysql) interpolate(query string, args []driver.NamedValue) (string, error) {
+	noBackslashEscapes := m.status&mysqlStatusNoBackslashEscapes != 0
+	var (
+		b        strings.Builder
+		quote    byte
+		argIndex int
+		inEscape bool
+	)
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case inEscape:
+			inEscape = false
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else if c == '\\' && quote != '`' && !noBackslashEscapes {
+				inEscape = true
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '?':
+			if argIndex < len(args) {
+				if err := m.appendValue(&b, args[argIndex].Value, noBackslashEscapes); err != nil {
+					return "", fmt.Errorf("%w, the arg %d: %s", errInvalidArgs, argIndex+1, err.Error())
+				}
+			}
+			argIndex++
+			continue
+		}
+		b.WriteByte(c)
+	}
+	if argIndex != len(args) {
+		return "", fmt.Errorf("%w, the query has %d placeholders and %d args", errInvalidArgs, argIndex, len(args))
+	}
+	return b.String(), nil
+}
+
+func (m *mysql) appendValue(b *strings.Builder, v driver.Value, noBackslashEscapes bool) error {
+	switch v := v.(type) {
+	case nil:
+		b.WriteString("NULL")
+	case int64:
+		b.WriteString(strconv.FormatInt(v, 10))
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return errors.New("MySQL doesn't support NaN and Infinity")
+		}
+		b.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+	case bool:
+		if v {
+			b.WriteString("TRUE")
+		} else {
+			b.WriteString("FALSE")
+		}
+	case []byte:
+		b.WriteString("X'" + hex.EncodeToString(v) + "'")
+	case time.Time:
+		// the time is converted to UTC, the time zone of the DATETIME and TIMESTAMP columns is the
+		// one of the session, which is SYSTEM by default
+		b.WriteString("'" + v.UTC().Format(mysqlTimeFormat) + "'")
+	case string:
+		b.WriteString("'" + escape(v, noBackslashEscapes) + "'")
+	default:
+		return fmt.Errorf("unsupported type %T", v)
+	}
+	return nil
+}
+
+//nolint:gochecknoglobals
+var backslashEscaper = strings.NewReplacer(
+	"\x00", `\0`, "\n", `\n`, "\r", `\r`, "\x1a", `\Z`, `'`, `\'`, `"`, `\"`, `\`, `\\`,
+)
+
+// escape escapes a string to be quoted with single quotes.
+func escape(s string, noBackslashEscapes bool) string {
+	if noBackslashEscapes {
+		return strings.ReplaceAll(s, "'", "''")
+	}
+	return backslashEscaper.Replace(s)
+}
+
+type mysqlColumn struct {
+	name    string
+	charset uint16
+	typ     byte
+}
+
+// parseColumn parses a ColumnDefinition41 packet.
+func parseColumn(packet []byte) (mysqlColumn, error) {
+	r := &mysqlReader{b: packet}
+	// the catalog, schema, table and original table
+	for i := 0; i < 4; i++ {
+		r.lenEncString()
+	}
+	name, _ := r.lenEncString()
+	r.lenEncString() // the original name
+	r.lenEncInt()    // the length of the fixed length fields
+	col := mysqlColumn{name: string(name), charset: r.uint16()}
+	r.next(4) // the column length
+	col.typ = r.byte()
+	if r.err {
+		return mysqlColumn{}, errInvalidMySQLPacket
+	}
+	return col, nil
+}
+
+// parseRow parses a row of the text protocol, the numbers and the binary strings are decoded and
+// the other values are strings.
+func parseRow(packet []byte, columns []mysqlColumn) ([]driver.Value, error) {
+	r := &mysqlReader{b: packet}
+	row := make([]driver.Value, len(columns))
+	for i, col := range columns {
+		v, null := r.lenEncString()
+		if r.err {
+			return nil, errInvalidMySQLPacket
+		}
+		if null {
+			continue
+		}
+		switch col.typ {
+		case mysqlTypeTiny, mysqlTypeShort, mysqlTypeLong, mysqlTypeLongLong, mysqlTypeInt24, mysqlTypeYear:
+			n, err := strconv.ParseInt(string(v), 10, 64)
+			if err != nil {
+				// the BIGINT UNSIGNED values that overflow
+				row[i] = string(v)
+			} else {
+				row[i] = n
+			}
+		case mysqlTypeFloat, mysqlTypeDouble:
+			f, err := strconv.ParseFloat(string(v), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value of the column %q: %w", col.name, err)
+			}
+			row[i] = f
+		case mysqlTypeVarchar, mysqlTypeTinyBlob, mysqlTypeMediumBlob, mysqlTypeLongBlob, mysqlTypeBlob,
+			mysqlTypeVarString, mysqlTypeString:
+			if col.charset == mysqlBinaryCharset {
+				row[i] = append([]byte{}, v...)
+			} else {
+				row[i] = string(v)
+			}
+		default:
+			row[i] = string(v)
+		}
+	}
+	return row, nil
+}
+
+// mysqlError returns the error of an ERR packet.
+func mysqlError(packet []byte) error {
+	r := &mysqlReader{b: packet[1:]}
+	code := r.uint16()
+	sqlState := "HY000"
+	if len(r.b) > 0 && r.b[0] == '#' {
+		sqlState = string(r.next(6)[1:])
+	}
+	if r.err {
+		return errInvalidMySQLPacket
+	}
+	return &serverError{code: fmt.Sprintf("error %d, SQLSTATE %s", code, sqlState), message: string(r.b)}
+}
+
+// mysqlReader reads the fields of a packet, err is set if the packet is too short.
+type mysqlReader struct {
+	b   []byte
+	err bool
+}
+
+func (r *mysqlReader) next(n int) []byte {
+	if r.err || n < 0 || len(r.b) < n {
+		r.err = true
+		return make([]byte, n)
+	}
+	b := r.b[:n]
+	r.b = r.b[n:]
+	return b
+}
+
+func (r *mysqlReader) byte() byte {
+	return r.next(1)[0]
+}
+
+func (r *mysqlReader) uint16() uint16 {
+	return binary.LittleEndian.Uint16(r.next(2))
+}
+
+func (r *mysqlReader) cstring() string {
+	end := bytes.IndexByte(r.b, 0)
+	if end < 0 {
+		r.err = true
+		return ""
+	}
+	s := string(r.b[:end])
+	r.b = r.b[end+1:]
+	return s
+}
+
+// lenEncInt reads a length-encoded integer, null is true for the NULL values of the rows.
+func (r *mysqlReader) lenEncInt() (v uint64, null bool) {
+	switch first := r.byte(); first {
+	case mysqlNull:
+		return 0, true
+	case 0xfc:
+		return uint64(binary.LittleEndian.Uint16(r.next(2))), false
+	case 0xfd:
+		b := r.next(3)
+		return uint64(b[0]) | uint64(b[1])<<8 | uint64(b[2])<<16, false
+	case 0xfe:
+		return binary.LittleEndian.Uint64(r.next(8)), false
+	default:
+		return uint64(first), false
+	}
+}
+
+func (r *mysqlReader) lenEncString() ([]byte, bool) {
+	n, null := r.lenEncInt()
+	if null {
+		return nil, true
+	}
+	if n > uint64(len(r.b)) {
+		r.err = true
+		return nil, false
+	}
+	return r.next(int(n)), false
+}
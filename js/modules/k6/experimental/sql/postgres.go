@@ -0,0 +1,301 @@
+package sql
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/netext"
+	"go.k6.io/k6/lib/netext/pgext"
+)
+
+const (
+	pgDefaultPort       = "5432"
+	pgTimestampTZFormat = "2006-01-02 15:04:05.999999999Z07:00"
+)
+
+// The OIDs of the types that aren't returned as strings.
+const (
+	pgBool   = 16
+	pgBytea  = 17
+	pgInt8   = 20
+	pgInt2   = 21
+	pgInt4   = 23
+	pgOID    = 26
+	pgFloat4 = 700
+	pgFloat8 = 701
+)
+
+// postgresConnector opens the connections of a Postgres database, see
+// https://www.postgresql.org/docs/current/protocol.html
+type postgresConnector struct {
+	vu       modules.VU
+	addr     string
+	host     string
+	user     string
+	password string
+	database string
+	// sslMode is one of the SSL modes of pgext
+	sslMode string
+}
+
+func newPostgresConnector(vu modules.VU, u *url.URL) (*postgresConnector, error) {
+	c := &postgresConnector{vu: vu, host: u.Hostname(), sslMode: pgext.SSLModePrefer}
+	port := u.Port()
+	if port == "" {
+		port = pgDefaultPort
+	}
+	c.addr = net.JoinHostPort(c.host, port)
+	if u.User == nil || u.User.Username() == "" {
+		return nil, errors.New("invalid database URL, it needs a user")
+	}
+	c.user = u.User.Username()
+	c.password, _ = u.User.Password()
+	c.database = strings.TrimPrefix(u.Path, "/")
+	for k, v := range u.Query() {
+		switch k {
+		case "sslmode":
+			switch c.sslMode = v[0]; c.sslMode {
+			case pgext.SSLModeDisable, pgext.SSLModePrefer, pgext.SSLModeRequire, pgext.SSLModeVerifyFull:
+			default:
+				return nil, fmt.Errorf("invalid sslmode %q, it needs to be disable, prefer, require or verify-full", v[0])
+			}
+		default:
+			return nil, fmt.Errorf("unknown Postgres URL param: %q", k)
+		}
+	}
+	return c, nil
+}
+
+// Connect implements driver.Connector.
+func (c *postgresConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	state := c.vu.State()
+	if state == nil {
+		return nil, errQueryInInitContext
+	}
+	nc, err := state.Dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, err
+	}
+	stop := netext.CloseOnDone(ctx, nc)
+	p, err := c.startup(ctx, state, nc)
+	stop()
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		_ = nc.Close()
+		return nil, err
+	}
+	return &conn{netConn: p, protocol: p}, nil
+}
+
+// Driver implements driver.Connector.
+func (c *postgresConnector) Driver() driver.Driver {
+	return connectorDriver{}
+}
+
+// startup starts the session of the user, over TLS if the server supports it.
+func (c *postgresConnector) startup(ctx context.Context, state *lib.State, nc net.Conn) (*postgres, error) {
+	pgConn, err := pgext.Connect(nc, pgext.Config{
+		User:     c.user,
+		Password: c.password,
+		Database: c.database,
+		SSLMode:  c.sslMode,
+		StartTLS: func(nc net.Conn, verify bool) (net.Conn, error) {
+			return startTLS(ctx, state, nc, c.host, !verify)
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &postgres{Conn: pgConn}, nil
+}
+
+// postgres is the protocol of a Postgres connection, its queries are executed with the extended
+// query protocol and the args are sent in the text format.
+type postgres struct {
+	*pgext.Conn
+}
+
+// exchange executes the query with the Parse, Bind, Describe, Execute and Sync messages, and reads
+// the responses until the server is ready for the next query.
+func (p *postgres) exchange(query string, args []driver.NamedValue) (*resultSet, error) {
+	parse := appendInt16(append(append([]byte{0}, query...), 0), 0)
+	// the unnamed portal and statement, and the text format for all the params and columns
+	bind := appendInt16(appendInt16([]byte{0, 0}, 0), int16(len(args)))
+	for _, arg := range args {
+		if arg.Value == nil {
+			bind = appendInt32(bind, -1)
+			continue
+		}
+		v := pgEncode(arg.Value)
+		bind = append(appendInt32(bind, int32(len(v))), v...)
+	}
+	bind = appendInt16(bind, 0)
+
+	msg := pgext.AppendMessage(nil, 'P', parse)
+	msg = pgext.AppendMessage(msg, 'B', bind)
+	msg = pgext.AppendMessage(msg, 'D', []byte{'P', 0})
+	msg = pgext.AppendMessage(msg, 'E', appendInt32([]byte{0}, 0))
+	msg = pgext.AppendMessage(msg, 'S', nil)
+	if _, err := p.Write(msg); err != nil {
+		return nil, err
+	}
+
+	res := &resultSet{}
+	var (
+		oids      []uint32
+		serverErr error
+	)
+	for {
+		typ, body, err := p.ReadMessage()
+		if err != nil {
+			return nil, err
+		}
+		switch typ {
+		case 'T':
+			if res.columns, oids, err = pgRowDescription(body); err != nil {
+				return nil, err
+			}
+		case 'D':
+			row, err := pgDataRow(body, oids)
+			if err != nil {
+				return nil, err
+			}
+			res.rows = append(res.rows, row)
+		case 'C':
+			tag := strings.Fields(strings.TrimRight(string(body), "\x00"))
+			if len(tag) > 1 {
+				res.rowsAffected, _ = strconv.ParseInt(tag[len(tag)-1], 10, 64)
+			}
+		case 'E':
+			serverErr = pgError(body)
+		case 'Z':
+			if serverErr != nil {
+				return nil, serverErr
+			}
+			return res, nil
+		}
+	}
+}
+
+// pgEncode returns the text format of a driver value.
+func pgEncode(v driver.Value) []byte {
+	switch v := v.(type) {
+	case int64:
+		return strconv.AppendInt(nil, v, 10)
+	case float64:
+		switch {
+		case math.IsInf(v, 1):
+			return []byte("Infinity")
+		case math.IsInf(v, -1):
+			return []byte("-Infinity")
+		default:
+			return strconv.AppendFloat(nil, v, 'g', -1, 64)
+		}
+	case bool:
+		return strconv.AppendBool(nil, v)
+	case []byte:
+		return append([]byte(`\x`), hex.EncodeToString(v)...)
+	case time.Time:
+		return []byte(v.Format(pgTimestampTZFormat))
+	default:
+		return []byte(fmt.Sprint(v))
+	}
+}
+
+// pgDecode returns the driver value of a column in the text format, the numbers, booleans and
+// bytea columns are decoded and the others are strings.
+func pgDecode(oid uint32, v []byte) (driver.Value, error) {
+	switch oid {
+	case pgBool:
+		return string(v) == "t", nil
+	case pgInt2, pgInt4, pgInt8, pgOID:
+		return strconv.ParseInt(string(v), 10, 64)
+	case pgFloat4, pgFloat8:
+		return strconv.ParseFloat(string(v), 64)
+	case pgBytea:
+		if strings.HasPrefix(string(v), `\x`) {
+			return hex.DecodeString(string(v[2:]))
+		}
+		return v, nil
+	default:
+		return string(v), nil
+	}
+}
+
+// pgRowDescription returns the names and the type OIDs of the columns of a RowDescription message.
+func pgRowDescription(body []byte) ([]string, []uint32, error) {
+	if len(body) < 2 {
+		return nil, nil, pgext.ErrInvalidMessage
+	}
+	n := int(binary.BigEndian.Uint16(body))
+	body = body[2:]
+	names, oids := make([]string, n), make([]uint32, n)
+	for i := 0; i < n; i++ {
+		end := strings.IndexByte(string(body), 0)
+		// the name is followed by the table OID, the column number, the type OID, size and
+		// modifier, and the format
+		if end < 0 || len(body) < end+19 {
+			return nil, nil, pgext.ErrInvalidMessage
+		}
+		names[i] = string(body[:end])
+		oids[i] = binary.BigEndian.Uint32(body[end+7:])
+		body = body[end+19:]
+	}
+	return names, oids, nil
+}
+
+// pgDataRow returns the values of a DataRow message.
+func pgDataRow(body []byte, oids []uint32) ([]driver.Value, error) {
+	if len(body) < 2 || int(binary.BigEndian.Uint16(body)) != len(oids) {
+		return nil, pgext.ErrInvalidMessage
+	}
+	body = body[2:]
+	row := make([]driver.Value, len(oids))
+	for i, oid := range oids {
+		if len(body) < 4 {
+			return nil, pgext.ErrInvalidMessage
+		}
+		length := int32(binary.BigEndian.Uint32(body))
+		body = body[4:]
+		if length < 0 {
+			continue
+		}
+		if int(length) > len(body) {
+			return nil, pgext.ErrInvalidMessage
+		}
+		v, err := pgDecode(oid, body[:length])
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of the column %d: %w", i+1, err)
+		}
+		row[i], body = v, body[length:]
+	}
+	return row, nil
+}
+
+// pgError returns the error of an ErrorResponse message.
+func pgError(body []byte) error {
+	e := pgext.ParseError(body)
+	return &serverError{code: "SQLSTATE " + e.Code, message: e.Severity + ": " + e.Message}
+}
+
+func appendInt16(b []byte, v int16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func appendInt32(b []byte, v int32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
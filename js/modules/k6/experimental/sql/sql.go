@@ -0,0 +1,556 @@
+// Package sql implements the k6/experimental/sql module, a client of Postgres and MySQL databases
+// with a pool of connections per VU, to load test the database-backed services and to verify their
+// data side effects.
+package sql
+
+import (
+	"context"
+	"crypto/tls"
+	dbsql "database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/netext"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/stats"
+)
+
+type (
+	// RootModule is the global module instance that will create module
+	// instances for each VU.
+	RootModule struct{}
+
+	// ModuleInstance represents an instance of the sql module for every VU.
+	ModuleInstance struct {
+		vu modules.VU
+	}
+)
+
+var (
+	_ modules.Module   = &RootModule{}
+	_ modules.Instance = &ModuleInstance{}
+)
+
+// New returns a pointer to a new RootModule instance.
+func New() *RootModule {
+	return &RootModule{}
+}
+
+// NewModuleInstance implements the modules.Module interface to return
+// a new instance for each VU.
+func (*RootModule) NewModuleInstance(vu modules.VU) modules.Instance {
+	return &ModuleInstance{vu: vu}
+}
+
+// Exports returns the exports of the sql module.
+func (mi *ModuleInstance) Exports() modules.Exports {
+	return modules.Exports{
+		Named: map[string]interface{}{
+			"Database": mi.NewDatabase,
+		},
+	}
+}
+
+// NewDatabase is the JS constructor for the sql Database.
+func (mi *ModuleInstance) NewDatabase(call goja.ConstructorCall) *goja.Object {
+	rt := mi.vu.Runtime()
+	var params map[string]interface{}
+	if v := call.Argument(1); !goja.IsUndefined(v) && !goja.IsNull(v) {
+		if err := rt.ExportTo(v, &params); err != nil {
+			common.Throw(rt, fmt.Errorf("the Database params need to be an object: %w", err))
+		}
+	}
+	db, err := newDatabase(mi.vu, call.Argument(0).String(), params)
+	if err != nil {
+		common.Throw(rt, err)
+	}
+	return rt.ToValue(db).ToObject(rt)
+}
+
+const (
+	defaultTimeout      = time.Minute
+	defaultMaxIdleConns = 2
+)
+
+var errQueryInInitContext = common.NewInitContextError("SQL queries in the init context are not supported")
+
+// Database is a database with a pool of connections. The Database objects are created by each VU,
+// so the connections of a VU are never used by the others.
+type Database struct {
+	vu modules.VU
+	db *dbsql.DB
+
+	driver string
+	// url is the URL of the database without the password, for the url tag
+	url     string
+	timeout time.Duration
+	tags    map[string]string
+}
+
+// newDatabase returns a Database for the postgres:// or mysql:// URL. The connections are only
+// opened by the queries, so that it can be created in the init context.
+func newDatabase(vu modules.VU, rawURL string, params map[string]interface{}) (*Database, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		// the error has the URL, with the password
+		return nil, errors.New("invalid database URL")
+	}
+	if u.Host == "" {
+		return nil, errors.New("invalid database URL, it needs a host")
+	}
+
+	var connector driver.Connector
+	d := &Database{vu: vu, timeout: defaultTimeout}
+	switch u.Scheme {
+	case "postgres", "postgresql":
+		d.driver = "postgres"
+		connector, err = newPostgresConnector(vu, u)
+	case "mysql":
+		d.driver = "mysql"
+		connector, err = newMySQLConnector(vu, u)
+	default:
+		return nil, fmt.Errorf("invalid database URL scheme %q, it needs to be postgres or mysql", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+	redacted := *u
+	if u.User != nil {
+		redacted.User = url.User(u.User.Username())
+	}
+	d.url = redacted.String()
+
+	maxIdleConns := defaultMaxIdleConns
+	var maxOpenConns int
+	var connMaxLifetime, connMaxIdleTime time.Duration
+	for k, v := range params {
+		switch k {
+		case "maxOpenConns", "maxIdleConns":
+			n, ok := v.(int64)
+			if !ok || n < 0 {
+				return nil, fmt.Errorf("invalid %s value '%#v', it needs to be a non-negative integer", k, v)
+			}
+			if k == "maxOpenConns" {
+				maxOpenConns = int(n)
+			} else {
+				maxIdleConns = int(n)
+			}
+		case "connMaxLifetime", "connMaxIdleTime":
+			duration, err := types.GetDurationValue(v)
+			if err != nil || duration < 0 {
+				return nil, fmt.Errorf("invalid %s value '%#v'", k, v)
+			}
+			if k == "connMaxLifetime" {
+				connMaxLifetime = duration
+			} else {
+				connMaxIdleTime = duration
+			}
+		case "timeout":
+			d.timeout, err = types.GetDurationValue(v)
+			if err != nil || d.timeout <= 0 {
+				return nil, fmt.Errorf("invalid timeout value '%#v'", v)
+			}
+		case "tags":
+			tags, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("metric tags must be an object of string values, got '%#v'", v)
+			}
+			d.tags = make(map[string]string, len(tags))
+			for name, tag := range tags {
+				d.tags[name] = fmt.Sprint(tag)
+			}
+		default:
+			return nil, fmt.Errorf("unknown Database param: %q", k)
+		}
+	}
+
+	d.db = dbsql.OpenDB(connector)
+	d.db.SetMaxOpenConns(maxOpenConns)
+	d.db.SetMaxIdleConns(maxIdleConns)
+	d.db.SetConnMaxLifetime(connMaxLifetime)
+	d.db.SetConnMaxIdleTime(connMaxIdleTime)
+	return d, nil
+}
+
+// Result is the result of a statement executed by exec().
+type Result struct {
+	RowsAffected int64 `js:"rowsAffected"`
+	// LastInsertID is the AUTO_INCREMENT value of the inserted row with MySQL, and 0 with Postgres
+	// that needs a RETURNING clause instead.
+	LastInsertID int64 `js:"lastInsertId"`
+}
+
+// Query executes a query with the args of its placeholders, $1, $2... with Postgres, and ? with
+// MySQL, and returns its rows as objects with a property for each column.
+func (d *Database) Query(query string, args ...goja.Value) ([]map[string]interface{}, error) {
+	var rows []map[string]interface{}
+	err := d.run(query, args, func(ctx context.Context, values []interface{}) error {
+		res, err := d.db.QueryContext(ctx, query, values...)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = res.Close() }()
+		rows, err = scanRows(d.vu.Runtime(), res)
+		return err
+	})
+	return rows, err
+}
+
+// Exec executes a statement with the args of its placeholders, like an INSERT, UPDATE or DELETE,
+// and returns the number of rows that it affected.
+func (d *Database) Exec(query string, args ...goja.Value) (*Result, error) {
+	var result *Result
+	err := d.run(query, args, func(ctx context.Context, values []interface{}) error {
+		res, err := d.db.ExecContext(ctx, query, values...)
+		if err != nil {
+			return err
+		}
+		result = &Result{}
+		result.RowsAffected, err = res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		result.LastInsertID, err = res.LastInsertId()
+		return err
+	})
+	return result, err
+}
+
+// Close closes the connections of the pool, the Database can't be used after it.
+func (d *Database) Close() error {
+	return d.db.Close()
+}
+
+// run executes a query with the timeout and emits its metrics.
+func (d *Database) run(query string, args []goja.Value, fn func(context.Context, []interface{}) error) error {
+	state := d.vu.State()
+	if state == nil {
+		return errQueryInInitContext
+	}
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		v, err := exportArg(arg)
+		if err != nil {
+			return fmt.Errorf("invalid query arg %d: %w", i+1, err)
+		}
+		values[i] = v
+	}
+
+	tags := state.CloneTags()
+	if state.Options.SystemTags.Has(stats.TagURL) {
+		tags["url"] = d.url
+	}
+	tags["driver"] = d.driver
+	tags["operation"] = operation(query)
+	for k, v := range d.tags {
+		tags[k] = v
+	}
+
+	ctx, cancel := context.WithTimeout(d.vu.Context(), d.timeout)
+	defer cancel()
+	start := time.Now()
+	err := fn(ctx, values)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("the SQL query timed out after %s", d.timeout)
+	}
+	if err == nil {
+		d.push(state.BuiltinMetrics.SQLQueryDuration, stats.D(time.Since(start)), tags)
+	}
+	d.push(state.BuiltinMetrics.SQLQueryFailed, stats.B(err != nil), tags)
+	return err
+}
+
+// operation returns the first keyword of the query, like SELECT or INSERT, for the operation tag.
+func operation(query string) string {
+	fields := strings.FieldsFunc(query, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+	})
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToUpper(fields[0])
+}
+
+// exportArg returns the driver value of the JS value of a placeholder.
+func exportArg(v goja.Value) (interface{}, error) {
+	if goja.IsUndefined(v) || goja.IsNull(v) {
+		return nil, nil
+	}
+	switch arg := v.Export().(type) {
+	case int64, float64, bool, string, time.Time:
+		return arg, nil
+	case goja.ArrayBuffer:
+		return arg.Bytes(), nil
+	case []byte:
+		return arg, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %T, it needs to be a number, boolean, string, Date or ArrayBuffer", arg)
+	}
+}
+
+// scanRows reads the rows as objects, the binary values are ArrayBuffers.
+func scanRows(rt *goja.Runtime, rows *dbsql.Rows) ([]map[string]interface{}, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	result := make([]map[string]interface{}, 0)
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			if b, ok := values[i].([]byte); ok {
+				row[column] = rt.NewArrayBuffer(b)
+			} else {
+				row[column] = values[i]
+			}
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+func (d *Database) push(metric *stats.Metric, value float64, tags map[string]string) {
+	sampleTags := make(map[string]string, len(tags))
+	for k, v := range tags {
+		sampleTags[k] = v
+	}
+	stats.PushIfNotDone(d.vu.Context(), d.vu.State().Samples, stats.Sample{
+		Metric: metric,
+		Tags:   stats.IntoSampleTags(&sampleTags),
+		Value:  value,
+		Time:   time.Now(),
+	})
+}
+
+// serverError is an error response of the database server, after which the connection can still
+// be used.
+type serverError struct {
+	code    string
+	message string
+}
+
+func (e *serverError) Error() string {
+	return e.message + " (" + e.code + ")"
+}
+
+// protocol is the wire protocol of a database.
+type protocol interface {
+	// exchange executes the query with its args and reads its whole result.
+	exchange(query string, args []driver.NamedValue) (*resultSet, error)
+}
+
+// conn is a connection of the pool, it implements the database/sql driver interfaces over the
+// protocol of the database.
+type conn struct {
+	netConn  net.Conn
+	protocol protocol
+	broken   bool
+}
+
+var (
+	_ driver.QueryerContext  = &conn{}
+	_ driver.ExecerContext   = &conn{}
+	_ driver.Validator       = &conn{}
+	_ driver.SessionResetter = &conn{}
+)
+
+// run executes the query, the connection is closed if the context is done before its result.
+func (c *conn) run(ctx context.Context, query string, args []driver.NamedValue) (*resultSet, error) {
+	if c.broken {
+		return nil, driver.ErrBadConn
+	}
+	stop := netext.CloseOnDone(ctx, c.netConn)
+	res, err := c.protocol.exchange(query, args)
+	stop()
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		c.broken = true
+		return nil, ctxErr
+	}
+	var serverErr *serverError
+	if err != nil && !errors.As(err, &serverErr) && !errors.Is(err, errInvalidArgs) {
+		c.broken = true
+	}
+	return res, err
+}
+
+// QueryContext implements driver.QueryerContext.
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.run(ctx, query, args)
+}
+
+// ExecContext implements driver.ExecerContext.
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.run(ctx, query, args)
+}
+
+// CheckNamedValue implements driver.NamedValueChecker, the protocols only have positional args.
+func (c *conn) CheckNamedValue(v *driver.NamedValue) error {
+	if v.Name != "" {
+		return errors.New("named args are not supported")
+	}
+	var err error
+	v.Value, err = driver.DefaultParameterConverter.ConvertValue(v.Value)
+	return err
+}
+
+// Prepare implements driver.Conn, the statements are executed by the queries of the connection.
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return stmt{conn: c, query: query}, nil
+}
+
+// PrepareContext implements driver.ConnPrepareContext.
+func (c *conn) PrepareContext(_ context.Context, query string) (driver.Stmt, error) {
+	return c.Prepare(query)
+}
+
+// Begin implements driver.Conn.
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+// BeginTx implements driver.ConnBeginTx.
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if opts.Isolation != driver.IsolationLevel(dbsql.LevelDefault) || opts.ReadOnly {
+		return nil, errors.New("the transaction options are not supported")
+	}
+	if _, err := c.run(ctx, "BEGIN", nil); err != nil {
+		return nil, err
+	}
+	return tx{conn: c}, nil
+}
+
+// Close implements driver.Conn.
+func (c *conn) Close() error {
+	c.broken = true
+	return c.netConn.Close()
+}
+
+// IsValid implements driver.Validator, so that the broken connections aren't put back in the pool.
+func (c *conn) IsValid() bool {
+	return !c.broken
+}
+
+// ResetSession implements driver.SessionResetter.
+func (c *conn) ResetSession(context.Context) error {
+	if c.broken {
+		return driver.ErrBadConn
+	}
+	return nil
+}
+
+type tx struct {
+	conn *conn
+}
+
+func (t tx) Commit() error {
+	_, err := t.conn.run(context.Background(), "COMMIT", nil)
+	return err
+}
+
+func (t tx) Rollback() error {
+	_, err := t.conn.run(context.Background(), "ROLLBACK", nil)
+	return err
+}
+
+// stmt is a statement of the connection, which is sent with every execution.
+type stmt struct {
+	conn  *conn
+	query string
+}
+
+func (s stmt) Close() error  { return nil }
+func (s stmt) NumInput() int { return -1 }
+
+func (s stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.run(context.Background(), s.query, namedValues(args))
+}
+
+func (s stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.run(context.Background(), s.query, namedValues(args))
+}
+
+func (s stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.run(ctx, s.query, args)
+}
+
+func (s stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.run(ctx, s.query, args)
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return named
+}
+
+// resultSet is the whole result of a statement, the protocols read all of its rows before it's
+// returned.
+type resultSet struct {
+	columns      []string
+	rows         [][]driver.Value
+	rowsAffected int64
+	lastInsertID int64
+}
+
+func (r *resultSet) Columns() []string { return r.columns }
+func (r *resultSet) Close() error      { return nil }
+
+func (r *resultSet) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func (r *resultSet) LastInsertId() (int64, error) { return r.lastInsertID, nil }
+func (r *resultSet) RowsAffected() (int64, error) { return r.rowsAffected, nil }
+
+// connectorDriver is the driver of the connectors, the connections are only opened by them.
+type connectorDriver struct{}
+
+func (connectorDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("the connections are opened by the connectors of the databases")
+}
+
+// startTLS starts a TLS session over the connection with the TLS config of the VU, the certificate
+// of the server isn't verified if it's insecure.
+func startTLS(ctx context.Context, state *lib.State, nc net.Conn, host string, insecure bool) (net.Conn, error) {
+	tlsConfig := &tls.Config{} //nolint:gosec
+	if state.TLSConfig != nil {
+		tlsConfig = state.TLSConfig.Clone()
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = host
+	}
+	if insecure {
+		tlsConfig.InsecureSkipVerify = true
+	}
+	tlsConn := tls.Client(nc, tlsConfig)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return nil, err
+	}
+	return tlsConn, nil
+}
@@ -0,0 +1,492 @@
+package sql
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1" //nolint:gosec
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/lib/netext/pgext"
+	"go.k6.io/k6/lib/netext/scram"
+	"go.k6.io/k6/lib/testutils/httpmultibin"
+	"go.k6.io/k6/stats"
+)
+
+const testPassword = "secret"
+
+func newTestVU(t *testing.T) (*modulestest.VU, chan stats.SampleContainer) {
+	t.Helper()
+	tb := httpmultibin.NewHTTPMultiBin(t)
+	vu, samples := modulestest.NewTestVU(t, tb, stats.TagURL)
+	m, ok := New().NewModuleInstance(vu).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, vu.Runtime().Set("sql", m.Exports().Named))
+	return vu, samples
+}
+
+// listen starts a server with the handler of the connections, and returns its address and the
+// number of connections that it accepted.
+func listen(t *testing.T, handle func(net.Conn)) (string, *int64) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+	var conns int64
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt64(&conns, 1)
+			go func() {
+				defer func() { _ = conn.Close() }()
+				handle(conn)
+			}()
+		}
+	}()
+	return ln.Addr().String(), &conns
+}
+
+// servePostgres is a Postgres server of the k6 user, which authenticates with md5 or scram.
+func servePostgres(t *testing.T, conn net.Conn, auth string) {
+	r := bufio.NewReader(conn)
+	var startup []byte
+	for {
+		var length [4]byte
+		if _, err := io.ReadFull(r, length[:]); err != nil {
+			return
+		}
+		startup = make([]byte, binary.BigEndian.Uint32(length[:])-4)
+		if _, err := io.ReadFull(r, startup); err != nil {
+			return
+		}
+		if binary.BigEndian.Uint32(startup) != pgext.SSLRequestCode {
+			break
+		}
+		_, _ = conn.Write([]byte("N"))
+	}
+	params := strings.Split(string(startup[4:]), "\x00")
+	assert.Equal(t, []string{"user", "k6", "database", "test", "client_encoding", "UTF8", "", ""}, params)
+
+	if !authenticatePostgres(t, conn, r, auth) {
+		_, _ = conn.Write(pgext.AppendMessage(nil, 'E',
+			[]byte("SFATAL\x00C28P01\x00Mpassword authentication failed\x00\x00")))
+		return
+	}
+	ready := pgext.AppendMessage(nil, 'R', appendInt32(nil, pgext.AuthOK))
+	_, _ = conn.Write(pgext.AppendMessage(ready, 'Z', []byte("I")))
+
+	var (
+		query string
+		args  []interface{}
+	)
+	for {
+		typ, body, err := pgext.ReadMessage(r)
+		if err != nil {
+			return
+		}
+		switch typ {
+		case 'P':
+			query = string(body[1 : bytes.IndexByte(body[1:], 0)+1])
+		case 'B':
+			args = nil
+			n := int(binary.BigEndian.Uint16(body[4:]))
+			body = body[6:]
+			for i := 0; i < n; i++ {
+				length := int32(binary.BigEndian.Uint32(body))
+				body = body[4:]
+				if length < 0 {
+					args = append(args, nil)
+					continue
+				}
+				args = append(args, string(body[:length]))
+				body = body[length:]
+			}
+		case 'S':
+			_, _ = conn.Write(postgresResponse(query, args))
+		case 'X':
+			return
+		}
+	}
+}
+
+func authenticatePostgres(t *testing.T, conn net.Conn, r *bufio.Reader, auth string) bool {
+	switch auth {
+	case "md5":
+		salt := []byte{1, 2, 3, 4}
+		_, _ = conn.Write(pgext.AppendMessage(nil, 'R', append(appendInt32(nil, pgext.AuthMD5Password), salt...)))
+		_, body, err := pgext.ReadMessage(r)
+		require.NoError(t, err)
+		return string(body) == pgext.MD5Password("k6", testPassword, salt)+"\x00"
+	case "scram":
+		_, _ = conn.Write(pgext.AppendMessage(nil, 'R',
+			append(appendInt32(nil, pgext.AuthSASL), "SCRAM-SHA-256\x00\x00"...)))
+		_, body, err := pgext.ReadMessage(r)
+		require.NoError(t, err)
+		require.True(t, bytes.HasPrefix(body, []byte("SCRAM-SHA-256\x00")))
+		server := &scram.TestServer{H: sha256.New, Password: testPassword}
+		serverFirst, _ := server.Step(body[len("SCRAM-SHA-256")+5:])
+		_, _ = conn.Write(pgext.AppendMessage(nil, 'R',
+			append(appendInt32(nil, pgext.AuthSASLContinue), serverFirst...)))
+
+		_, body, err = pgext.ReadMessage(r)
+		require.NoError(t, err)
+		serverFinal, ok := server.Step(body)
+		if !ok {
+			return false
+		}
+		_, _ = conn.Write(pgext.AppendMessage(nil, 'R', append(appendInt32(nil, pgext.AuthSASLFinal), serverFinal...)))
+		return true
+	default:
+		return false
+	}
+}
+
+func postgresResponse(query string, args []interface{}) []byte {
+	msg := append(pgext.AppendMessage(nil, '1', nil), pgext.AppendMessage(nil, '2', nil)...)
+	switch query {
+	case "SELECT id, name, active, score, data FROM users WHERE id >= $1":
+		var desc []byte
+		for _, col := range []struct {
+			name string
+			oid  int32
+		}{{"id", pgInt4}, {"name", 25}, {"active", pgBool}, {"score", pgFloat8}, {"data", pgBytea}} {
+			desc = append(append(desc, col.name...), 0)
+			desc = appendInt16(appendInt32(appendInt16(appendInt32(desc, 0), 0), col.oid), -1)
+			desc = appendInt16(appendInt32(desc, -1), 0)
+		}
+		msg = append(msg, pgext.AppendMessage(nil, 'T', append(appendInt16(nil, 5), desc...))...)
+		msg = append(msg, pgDataRowMessage(args[0], "alice", "t", "9.5", `\x0102`)...)
+		msg = append(msg, pgDataRowMessage("43", nil, "f", "-1", nil)...)
+		msg = append(msg, pgext.AppendMessage(nil, 'C', []byte("SELECT 2\x00"))...)
+	case "INSERT INTO users (name) VALUES ($1), ($2)":
+		msg = append(msg, pgext.AppendMessage(nil, 'n', nil)...)
+		msg = append(msg, pgext.AppendMessage(nil, 'C', []byte("INSERT 0 2\x00"))...)
+	case "SELECT pg_sleep(1)":
+		time.Sleep(time.Second)
+		msg = append(msg, pgext.AppendMessage(nil, 'n', nil)...)
+		msg = append(msg, pgext.AppendMessage(nil, 'C', []byte("SELECT 1\x00"))...)
+	default:
+		msg = pgext.AppendMessage(nil, 'E', []byte("SERROR\x00C42P01\x00Mrelation \"missing\" does not exist\x00\x00"))
+	}
+	return append(msg, pgext.AppendMessage(nil, 'Z', []byte("I"))...)
+}
+
+func pgDataRowMessage(values ...interface{}) []byte {
+	row := appendInt16(nil, int16(len(values)))
+	for _, v := range values {
+		if v == nil {
+			row = appendInt32(row, -1)
+			continue
+		}
+		s, _ := v.(string)
+		row = append(appendInt32(row, int32(len(s))), s...)
+	}
+	return pgext.AppendMessage(nil, 'D', row)
+}
+
+// serveMySQL is a MySQL server of the k6 user, which authenticates with mysql_native_password.
+func serveMySQL(t *testing.T, conn net.Conn) {
+	m := &mysql{conn: conn, r: bufio.NewReader(conn)}
+	nonce := []byte("abcdefghijklmnopqrst")
+	handshake := append([]byte{10}, "8.0.0-fake\x00"...)
+	handshake = append(append(handshake, 1, 0, 0, 0), nonce[:8]...)
+	caps := uint32(mysqlClientProtocol41 | mysqlClientSecureConnection | mysqlClientPluginAuth | mysqlClientConnectWithDB)
+	handshake = append(handshake, 0, byte(caps), byte(caps>>8), mysqlCharset, 0, 0, byte(caps>>16), byte(caps>>24), 21)
+	handshake = append(append(handshake, make([]byte, 10)...), nonce[8:]...)
+	handshake = append(append(handshake, 0), mysqlNativePassword+"\x00"...)
+	require.NoError(t, m.writePacket(handshake))
+
+	resp, err := m.readPacket()
+	require.NoError(t, err)
+	r := &mysqlReader{b: resp[32:]}
+	assert.Equal(t, "k6", r.cstring())
+	authResp := r.next(int(r.byte()))
+	assert.Equal(t, "test", r.cstring())
+	assert.Equal(t, mysqlNativePassword, r.cstring())
+	s1 := sha1.Sum([]byte(testPassword))                         //nolint:gosec
+	s2 := sha1.Sum(s1[:])                                        //nolint:gosec
+	s3 := sha1.Sum(append(append([]byte{}, nonce...), s2[:]...)) //nolint:gosec
+	for i := range s1 {
+		s1[i] ^= s3[i]
+	}
+	if !bytes.Equal(authResp, s1[:]) {
+		_ = m.writePacket(append([]byte{mysqlErr, 0x15, 0x04}, "#28000Access denied for user 'k6'"...))
+		return
+	}
+	require.NoError(t, m.writePacket([]byte{mysqlOK, 0, 0, 2, 0, 0, 0}))
+
+	for {
+		m.seq = 0
+		packet, err := m.readPacket()
+		if err != nil || packet[0] != mysqlComQuery {
+			return
+		}
+		switch string(packet[1:]) {
+		case `SELECT id, name, data FROM users WHERE name = 'o\'brien' AND id > 1`:
+			_ = m.writePacket([]byte{3})
+			_ = m.writePacket(mysqlColumnPacket("id", mysqlBinaryCharset, mysqlTypeLongLong))
+			_ = m.writePacket(mysqlColumnPacket("name", mysqlCharset, mysqlTypeVarString))
+			_ = m.writePacket(mysqlColumnPacket("data", mysqlBinaryCharset, mysqlTypeBlob))
+			_ = m.writePacket([]byte{mysqlEOF, 0, 0, 2, 0})
+			_ = m.writePacket([]byte("\x017\x07o'brien\x02\x01\x02"))
+			_ = m.writePacket([]byte{1, '8', mysqlNull, mysqlNull})
+			_ = m.writePacket([]byte{mysqlEOF, 0, 0, 2, 0})
+		case "INSERT INTO users (name) VALUES ('k6')":
+			_ = m.writePacket([]byte{mysqlOK, 1, 7, 2, 0, 0, 0})
+		default:
+			_ = m.writePacket(append([]byte{mysqlErr, 0x7a, 0x04}, "#42S02Table 'test.missing' doesn't exist"...))
+		}
+	}
+}
+
+func mysqlColumnPacket(name string, charset uint16, typ byte) []byte {
+	var packet []byte
+	for _, s := range []string{"def", "test", "users", "users", name, name} {
+		packet = append(append(packet, byte(len(s))), s...)
+	}
+	packet = append(packet, 0x0c, byte(charset), byte(charset>>8), 0, 1, 0, 0, typ, 0, 0, 0, 0, 0)
+	return packet
+}
+
+func TestDatabase(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Postgres", func(t *testing.T) {
+		t.Parallel()
+		vu, samples := newTestVU(t)
+		addr, _ := listen(t, func(conn net.Conn) { servePostgres(t, conn, "md5") })
+
+		_, err := vu.Runtime().RunString(`
+			var db = new sql.Database("postgres://k6:secret@` + addr + `/test", { tags: { tag: "value" } });
+			var rows = db.query("SELECT id, name, active, score, data FROM users WHERE id >= $1", 42);
+			var res = db.exec("INSERT INTO users (name) VALUES ($1), ($2)", "alice", null);
+		`)
+		require.NoError(t, err)
+
+		v, err := vu.Runtime().RunString(`[
+			rows.length, rows[0].id, rows[0].name, rows[0].active, rows[0].score,
+			new Uint8Array(rows[0].data).join(","), rows[1].name === null, rows[1].data === null, rows[1].score,
+			res.rowsAffected, res.lastInsertId,
+		].join("|")`)
+		require.NoError(t, err)
+		assert.Equal(t, "2|42|alice|true|9.5|1,2|true|true|-1|2|0", v.String())
+
+		_, err = vu.Runtime().RunString(`db.query("SELECT * FROM missing")`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `ERROR: relation "missing" does not exist (SQLSTATE 42P01)`)
+
+		var durations int
+		failed := map[string]float64{}
+		for _, c := range stats.GetBufferedSamples(samples) {
+			for _, s := range c.GetSamples() {
+				url, _ := s.Tags.Get("url")
+				assert.Equal(t, "postgres://k6@"+addr+"/test", url)
+				tag, _ := s.Tags.Get("tag")
+				assert.Equal(t, "value", tag)
+				driver, _ := s.Tags.Get("driver")
+				assert.Equal(t, "postgres", driver)
+				switch s.Metric.Name {
+				case metrics.SQLQueryDurationName:
+					durations++
+				case metrics.SQLQueryFailedName:
+					operation, _ := s.Tags.Get("operation")
+					failed[operation] += s.Value
+				}
+			}
+		}
+		assert.Equal(t, 2, durations)
+		assert.Equal(t, map[string]float64{"SELECT": 1, "INSERT": 0}, failed)
+	})
+
+	t.Run("PostgresSCRAM", func(t *testing.T) {
+		t.Parallel()
+		vu, _ := newTestVU(t)
+		addr, _ := listen(t, func(conn net.Conn) { servePostgres(t, conn, "scram") })
+
+		v, err := vu.Runtime().RunString(`
+			new sql.Database("postgresql://k6:secret@` + addr + `/test?sslmode=prefer").exec(
+				"INSERT INTO users (name) VALUES ($1), ($2)", "alice", "bob").rowsAffected;
+		`)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), v.Export())
+
+		_, err = vu.Runtime().RunString(`
+			new sql.Database("postgres://k6:wrong@` + addr + `/test").query("SELECT 1");
+		`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "password authentication failed (SQLSTATE 28P01)")
+	})
+
+	t.Run("MySQL", func(t *testing.T) {
+		t.Parallel()
+		vu, samples := newTestVU(t)
+		addr, _ := listen(t, func(conn net.Conn) { serveMySQL(t, conn) })
+
+		_, err := vu.Runtime().RunString(`
+			var db = new sql.Database("mysql://k6:secret@` + addr + `/test");
+			var rows = db.query("SELECT id, name, data FROM users WHERE name = ? AND id > ?", "o'brien", 1);
+			var res = db.exec("INSERT INTO users (name) VALUES (?)", "k6");
+		`)
+		require.NoError(t, err)
+
+		v, err := vu.Runtime().RunString(`[
+			rows.length, rows[0].id, rows[0].name, new Uint8Array(rows[0].data).join(","),
+			rows[1].id, rows[1].name === null, res.rowsAffected, res.lastInsertId,
+		].join("|")`)
+		require.NoError(t, err)
+		assert.Equal(t, "2|7|o'brien|1,2|8|true|1|7", v.String())
+
+		_, err = vu.Runtime().RunString(`db.query("SELECT * FROM missing")`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Table 'test.missing' doesn't exist (error 1146, SQLSTATE 42S02)")
+
+		_, err = vu.Runtime().RunString(`db.query("SELECT * FROM users WHERE id = ?", 1, 2)`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid query args, the query has 1 placeholders and 2 args")
+
+		_, err = vu.Runtime().RunString(`
+			new sql.Database("mysql://k6:wrong@` + addr + `/test").query("SELECT 1");
+		`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Access denied for user 'k6' (error 1045, SQLSTATE 28000)")
+
+		var durations int
+		for _, c := range stats.GetBufferedSamples(samples) {
+			for _, s := range c.GetSamples() {
+				if s.Metric.Name == metrics.SQLQueryDurationName {
+					durations++
+				}
+			}
+		}
+		assert.Equal(t, 2, durations)
+	})
+
+	t.Run("Pool", func(t *testing.T) {
+		t.Parallel()
+		vu, _ := newTestVU(t)
+		addr, conns := listen(t, func(conn net.Conn) { servePostgres(t, conn, "md5") })
+
+		_, err := vu.Runtime().RunString(`
+			var db = new sql.Database("postgres://k6:secret@` + addr + `/test", { maxOpenConns: 1 });
+			for (var i = 0; i < 5; i++) {
+				db.query("SELECT id, name, active, score, data FROM users WHERE id >= $1", i);
+			}
+			db.close();
+		`)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), atomic.LoadInt64(conns))
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		t.Parallel()
+		vu, _ := newTestVU(t)
+		addr, conns := listen(t, func(conn net.Conn) { servePostgres(t, conn, "md5") })
+
+		_, err := vu.Runtime().RunString(`
+			var db = new sql.Database("postgres://k6:secret@` + addr + `/test", { timeout: "100ms" });
+			db.query("SELECT pg_sleep(1)");
+		`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "the SQL query timed out after 100ms")
+
+		// the connection of the query that timed out isn't reused
+		_, err = vu.Runtime().RunString(`db.exec("INSERT INTO users (name) VALUES ($1), ($2)", "alice", "bob")`)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), atomic.LoadInt64(conns))
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		t.Parallel()
+		vu, _ := newTestVU(t)
+
+		tests := map[string]string{
+			`new sql.Database("sqlite://k6@localhost/test")`:                      "invalid database URL scheme",
+			`new sql.Database("postgres:///test")`:                                "it needs a host",
+			`new sql.Database("mysql://localhost/test")`:                          "it needs a user",
+			`new sql.Database("postgres://k6@localhost/test?sslmode=allow")`:      "invalid sslmode",
+			`new sql.Database("postgres://k6@localhost/test?connect_timeout=1")`:  "unknown Postgres URL param",
+			`new sql.Database("mysql://k6@localhost/test?tls=maybe")`:             "invalid tls",
+			`new sql.Database("mysql://k6@localhost/test", { pool: 2 })`:          "unknown Database param",
+			`new sql.Database("mysql://k6@localhost/test", { maxOpenConns: -1 })`: "invalid maxOpenConns value",
+			`new sql.Database("mysql://k6@localhost/test", { timeout: "soon" })`:  "invalid timeout value",
+			`new sql.Database("mysql://k6@localhost/test", { tags: "tag" })`:      "metric tags must be an object",
+			`new sql.Database("mysql://k6@localhost/test").query("SELECT ?", {})`: "invalid query arg 1",
+		}
+		for script, msg := range tests {
+			_, err := vu.Runtime().RunString(script)
+			require.Error(t, err, script)
+			assert.Contains(t, err.Error(), msg, script)
+		}
+	})
+
+	t.Run("InitContext", func(t *testing.T) {
+		t.Parallel()
+		vu, _ := newTestVU(t)
+		vu.StateField = nil
+
+		_, err := vu.Runtime().RunString(`new sql.Database("postgres://k6@127.0.0.1/test").query("SELECT 1")`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "SQL queries in the init context are not supported")
+	})
+}
+
+func TestInterpolate(t *testing.T) {
+	t.Parallel()
+
+	date := time.Date(2021, 11, 2, 15, 4, 5, 123456000, time.FixedZone("", 3600))
+	tests := []struct {
+		query, expected    string
+		args               []driver.Value
+		noBackslashEscapes bool
+	}{
+		{
+			query:    "SELECT * FROM t WHERE a = ? AND b = ? AND c = ? AND d = ? AND e IS ?",
+			args:     []driver.Value{int64(1), 1.5, true, []byte{0xca, 0xfe}, nil},
+			expected: "SELECT * FROM t WHERE a = 1 AND b = 1.5 AND c = TRUE AND d = X'cafe' AND e IS NULL",
+		},
+		{
+			query:    "SELECT '?', `a?`, \"it\\\"s?\", ? FROM t",
+			args:     []driver.Value{"a'b\\c\n"},
+			expected: "SELECT '?', `a?`, \"it\\\"s?\", 'a\\'b\\\\c\\n' FROM t",
+		},
+		{
+			query:              `SELECT 'a\', ?`,
+			args:               []driver.Value{"it's \\"},
+			noBackslashEscapes: true,
+			expected:           `SELECT 'a\', 'it''s \'`,
+		},
+		{
+			query:    "INSERT INTO t VALUES (?)",
+			args:     []driver.Value{date},
+			expected: "INSERT INTO t VALUES ('2021-11-02 14:04:05.123456')",
+		},
+	}
+	for _, tc := range tests {
+		m := &mysql{}
+		if tc.noBackslashEscapes {
+			m.status = mysqlStatusNoBackslashEscapes
+		}
+		args := make([]driver.NamedValue, len(tc.args))
+		for i, arg := range tc.args {
+			args[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+		}
+		query, err := m.interpolate(tc.query, args)
+		require.NoError(t, err, tc.query)
+		assert.Equal(t, tc.expected, query)
+	}
+}
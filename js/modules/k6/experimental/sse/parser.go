@@ -0,0 +1,121 @@
+package sse
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// event is a dispatched Server-Sent Event.
+type event struct {
+	Type        string
+	Data        string
+	LastEventID string
+}
+
+// parser reads the events of an event stream, as described in
+// https://html.spec.whatwg.org/multipage/server-sent-events.html#event-stream-interpretation
+type parser struct {
+	scanner *bufio.Scanner
+	// lastEventID is kept between the events and the connections
+	lastEventID string
+	// retry is the reconnection time that was last set by the stream, or 0
+	retry time.Duration
+	// skipLF is set after a line that ended with a CR at the end of the read data
+	skipLF bool
+}
+
+func newParser(r io.Reader, lastEventID string) *parser {
+	p := &parser{scanner: bufio.NewScanner(r), lastEventID: lastEventID}
+	p.scanner.Buffer(make([]byte, 0, 4096), 1024*1024)
+	p.scanner.Split(p.scanLines)
+	return p
+}
+
+// next returns the next event, or the error that ended the stream, which is io.EOF if it just ended.
+func (p *parser) next() (*event, error) {
+	var (
+		eventType string
+		data      strings.Builder
+		hasData   bool
+	)
+	for p.scanner.Scan() {
+		line := p.scanner.Text()
+		if line == "" {
+			if !hasData {
+				eventType = ""
+				continue
+			}
+			if eventType == "" {
+				eventType = "message"
+			}
+			return &event{
+				Type:        eventType,
+				Data:        strings.TrimSuffix(data.String(), "\n"),
+				LastEventID: p.lastEventID,
+			}, nil
+		}
+		if line[0] == ':' {
+			continue // a comment
+		}
+
+		field, value := line, ""
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+		switch field {
+		case "event":
+			eventType = value
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				p.lastEventID = value
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(value, 10, 63); err == nil {
+				p.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+	if err := p.scanner.Err(); err != nil {
+		return nil, err
+	}
+	// an event that isn't followed by a blank line is discarded
+	return nil, io.EOF
+}
+
+// scanLines is a bufio.SplitFunc for the lines of an event stream, which end with CRLF, LF or CR.
+// A line that ends with a CR is returned right away, so the LF that might follow it is skipped later.
+func (p *parser) scanLines(data []byte, atEOF bool) (int, []byte, error) {
+	if len(data) == 0 {
+		return 0, nil, nil
+	}
+	if p.skipLF {
+		p.skipLF = false
+		if data[0] == '\n' {
+			return 1, nil, nil
+		}
+	}
+	i := bytes.IndexAny(data, "\r\n")
+	switch {
+	case i < 0 && atEOF:
+		return len(data), data, nil
+	case i < 0:
+		return 0, nil, nil
+	case data[i] == '\n':
+		return i + 1, data[:i], nil
+	case i+1 == len(data):
+		p.skipLF = true
+		return i + 1, data[:i], nil
+	case data[i+1] == '\n':
+		return i + 2, data[:i], nil
+	default:
+		return i + 1, data[:i], nil
+	}
+}
@@ -0,0 +1,525 @@
+// Package sse implements the k6/experimental/sse module, with an EventSource for Server-Sent Events.
+package sse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/stats"
+)
+
+type (
+	// RootModule is the global module instance that will create module
+	// instances for each VU.
+	RootModule struct{}
+
+	// ModuleInstance represents an instance of the sse module for every VU.
+	ModuleInstance struct {
+		vu modules.VU
+	}
+)
+
+var (
+	_ modules.Module   = &RootModule{}
+	_ modules.Instance = &ModuleInstance{}
+)
+
+// The ready states of an EventSource.
+const (
+	connecting = 0
+	open       = 1
+	closed     = 2
+)
+
+// defaultRetry is how long to wait before reconnecting, unless the stream or the params set it.
+const defaultRetry = 3 * time.Second
+
+// New returns a pointer to a new RootModule instance.
+func New() *RootModule {
+	return &RootModule{}
+}
+
+// NewModuleInstance implements the modules.Module interface to return
+// a new instance for each VU.
+func (*RootModule) NewModuleInstance(vu modules.VU) modules.Instance {
+	return &ModuleInstance{vu: vu}
+}
+
+// Exports returns the exports of the sse module.
+func (mi *ModuleInstance) Exports() modules.Exports {
+	return modules.Exports{
+		Named: map[string]interface{}{
+			"EventSource": mi.newEventSource,
+			"CONNECTING":  connecting,
+			"OPEN":        open,
+			"CLOSED":      closed,
+		},
+	}
+}
+
+// EventSource receives the Server-Sent Events of a URL, like the EventSource of the browsers, see
+// https://html.spec.whatwg.org/multipage/server-sent-events.html. The events are delivered on the event loop,
+// which keeps waiting for them until the EventSource is closed. When the stream ends, it reconnects after
+// the retry delay, with the id of the last event in the Last-Event-ID header.
+type EventSource struct {
+	vu     modules.VU
+	url    string
+	header http.Header
+	tags   map[string]string
+	client *http.Client
+	// maxRetries is how many times it reconnects after the stream ends, or unlimited if it's negative
+	maxRetries  int
+	retry       time.Duration
+	lastEventID string
+	ctx         context.Context
+	cancel      context.CancelFunc
+
+	// everything below is only accessed from the event loop
+	readyState int
+	handlers   map[string]goja.Value
+	listeners  map[string][]goja.Value
+	methods    map[string]goja.Value
+	obj        *goja.Object
+}
+
+var _ goja.DynamicObject = &EventSource{}
+
+// newEventSource implements the EventSource constructor, which is called with the URL and the params:
+//
+//	{ headers: {...}, tags: {...}, retry: "3s", maxRetries: 10, lastEventId: "42" }
+func (mi *ModuleInstance) newEventSource(call goja.ConstructorCall) *goja.Object {
+	rt := mi.vu.Runtime()
+	state := mi.vu.State()
+	if state == nil {
+		common.Throw(rt, common.NewInitContextError("using EventSource in the init context is not supported"))
+	}
+	es, err := newEventSource(mi.vu, call.Argument(0).String(), call.Argument(1))
+	if err != nil {
+		common.Throw(rt, err)
+	}
+	go es.run(mi.vu.Context(), mi.vu.RegisterCallback())
+	return es.obj
+}
+
+func newEventSource(vu modules.VU, rawURL string, params goja.Value) (*EventSource, error) {
+	rt, state := vu.Runtime(), vu.State()
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid EventSource URL %q, it needs to be an http or https URL", rawURL)
+	}
+	es := &EventSource{
+		vu:         vu,
+		url:        u.String(),
+		header:     make(http.Header),
+		tags:       state.CloneTags(),
+		maxRetries: -1,
+		retry:      defaultRetry,
+		handlers:   map[string]goja.Value{"onopen": goja.Null(), "onmessage": goja.Null(), "onerror": goja.Null()},
+		listeners:  make(map[string][]goja.Value),
+	}
+	if state.Options.SystemTags.Has(stats.TagURL) {
+		es.tags["url"] = es.url
+	}
+	if params != nil && !goja.IsUndefined(params) && !goja.IsNull(params) {
+		if err := es.parseParams(params.ToObject(rt)); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := es.tags["name"]; !ok && state.Options.SystemTags.Has(stats.TagName) {
+		es.tags["name"] = es.url
+	}
+	if ua := state.Options.UserAgent; ua.String != "" && es.header.Get("User-Agent") == "" {
+		es.header.Set("User-Agent", ua.String)
+	}
+	es.header.Set("Accept", "text/event-stream")
+	es.header.Set("Cache-Control", "no-cache")
+
+	es.client = &http.Client{Transport: state.Transport}
+	if state.CookieJar != nil {
+		es.client.Jar = state.CookieJar
+	}
+	es.ctx, es.cancel = context.WithCancel(vu.Context())
+
+	es.methods = map[string]goja.Value{
+		"addEventListener":    rt.ToValue(es.addEventListener),
+		"removeEventListener": rt.ToValue(es.removeEventListener),
+		"close":               rt.ToValue(es.close),
+	}
+	es.obj = rt.NewDynamicObject(es)
+	return es, nil
+}
+
+func (es *EventSource) parseParams(params *goja.Object) error {
+	rt := es.vu.Runtime()
+	for _, k := range params.Keys() {
+		v := params.Get(k)
+		switch k {
+		case "headers":
+			headers := v.ToObject(rt)
+			for _, name := range headers.Keys() {
+				es.header.Set(name, headers.Get(name).String())
+			}
+		case "tags":
+			tags := v.ToObject(rt)
+			for _, name := range tags.Keys() {
+				es.tags[name] = tags.Get(name).String()
+			}
+		case "retry":
+			retry, err := types.GetDurationValue(v.Export())
+			if err != nil {
+				return fmt.Errorf("invalid EventSource retry value: %w", err)
+			}
+			es.retry = retry
+		case "maxRetries":
+			es.maxRetries = int(v.ToInteger())
+		case "lastEventId":
+			es.lastEventID = v.String()
+		default:
+			return fmt.Errorf("unknown EventSource param %q", k)
+		}
+	}
+	return nil
+}
+
+// run connects and reads the events until the EventSource is closed or it can't reconnect anymore.
+func (es *EventSource) run(vuCtx context.Context, runOnLoop func(func() error)) {
+	defer es.cancel()
+	d := &dispatcher{vu: es.vu, vuCtx: vuCtx, runOnLoop: runOnLoop}
+	for retries := 0; ; retries++ {
+		start := time.Now()
+		body, err := es.connect()
+		if es.ctx.Err() != nil {
+			if body != nil {
+				_ = body.Close()
+			}
+			d.finish(es.setClosed)
+			return
+		}
+		var fatal *fatalError
+		if errors.As(err, &fatal) {
+			d.finish(func() error {
+				es.readyState = closed
+				return es.emitError(err)
+			})
+			return
+		}
+
+		if err == nil {
+			if !d.dispatch(es.setOpen) {
+				_ = body.Close()
+				return
+			}
+			err = es.read(d, body, start)
+			_ = body.Close()
+			if errors.Is(err, errAbandoned) {
+				return
+			}
+			if es.ctx.Err() != nil {
+				d.finish(es.setClosed)
+				return
+			}
+		}
+
+		if es.maxRetries >= 0 && retries >= es.maxRetries {
+			d.finish(func() error {
+				es.readyState = closed
+				return es.emitError(err)
+			})
+			return
+		}
+		if !d.dispatch(func() error {
+			if es.readyState == closed {
+				return nil
+			}
+			es.readyState = connecting
+			return es.emitError(err)
+		}) {
+			return
+		}
+
+		timer := time.NewTimer(es.retry)
+		select {
+		case <-timer.C:
+		case <-es.ctx.Done():
+			timer.Stop()
+			d.finish(es.setClosed)
+			return
+		}
+	}
+}
+
+// fatalError is a failure to connect after which the EventSource doesn't reconnect.
+type fatalError struct {
+	msg string
+}
+
+func (e *fatalError) Error() string {
+	return e.msg
+}
+
+var errAbandoned = errors.New("the iteration has ended")
+
+// connect sends the request of the stream and returns the body of the response.
+func (es *EventSource) connect() (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(es.ctx, http.MethodGet, es.url, nil)
+	if err != nil {
+		return nil, &fatalError{msg: err.Error()}
+	}
+	req.Header = es.header.Clone()
+	if es.lastEventID != "" {
+		req.Header.Set("Last-Event-ID", es.lastEventID)
+	}
+	resp, err := es.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch {
+	case resp.StatusCode != http.StatusOK:
+		_ = resp.Body.Close()
+		return nil, &fatalError{msg: fmt.Sprintf("the EventSource response status is %d", resp.StatusCode)}
+	case mediaType != "text/event-stream":
+		_ = resp.Body.Close()
+		return nil, &fatalError{msg: fmt.Sprintf("the EventSource response content type is %q", mediaType)}
+	}
+	return resp.Body, nil
+}
+
+// read dispatches the events of a stream until it ends, it returns the error that ended it.
+func (es *EventSource) read(d *dispatcher, body io.Reader, start time.Time) error {
+	p := newParser(body, es.lastEventID)
+	for first := true; ; first = false {
+		ev, err := p.next()
+		es.lastEventID = p.lastEventID
+		if p.retry > 0 {
+			es.retry = p.retry
+		}
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if first {
+			es.push(es.vu.State().BuiltinMetrics.SSETimeToFirstEvent, stats.D(now.Sub(start)), now)
+		}
+		es.push(es.vu.State().BuiltinMetrics.SSEEvents, 1, now)
+		if !d.dispatch(func() error { return es.emitEvent(ev) }) {
+			return errAbandoned
+		}
+	}
+}
+
+func (es *EventSource) push(metric *stats.Metric, value float64, t time.Time) {
+	tags := make(map[string]string, len(es.tags))
+	for k, v := range es.tags {
+		tags[k] = v
+	}
+	stats.PushIfNotDone(es.ctx, es.vu.State().Samples, stats.Sample{
+		Metric: metric,
+		Tags:   stats.IntoSampleTags(&tags),
+		Value:  value,
+		Time:   t,
+	})
+}
+
+func (es *EventSource) setOpen() error {
+	if es.readyState == closed {
+		return nil
+	}
+	es.readyState = open
+	return es.emit("open", es.newEvent("open"))
+}
+
+func (es *EventSource) setClosed() error {
+	es.readyState = closed
+	return nil
+}
+
+func (es *EventSource) emitEvent(ev *event) error {
+	if es.readyState == closed {
+		return nil
+	}
+	e := es.newEvent(ev.Type)
+	rt := es.vu.Runtime()
+	must(rt, e.Set("data", ev.Data))
+	must(rt, e.Set("lastEventId", ev.LastEventID))
+	must(rt, e.Set("origin", es.url))
+	return es.emit(ev.Type, e)
+}
+
+func (es *EventSource) emitError(err error) error {
+	e := es.newEvent("error")
+	if err != nil && !errors.Is(err, io.EOF) {
+		must(es.vu.Runtime(), e.Set("error", err.Error()))
+	}
+	return es.emit("error", e)
+}
+
+func (es *EventSource) newEvent(eventType string) *goja.Object {
+	rt := es.vu.Runtime()
+	e := rt.NewObject()
+	must(rt, e.Set("type", eventType))
+	must(rt, e.Set("target", es.obj))
+	return e
+}
+
+// emit calls the on<type> handler, if the type has one, and the listeners of the event.
+func (es *EventSource) emit(eventType string, e *goja.Object) error {
+	listeners := es.listeners[eventType]
+	if handler, ok := es.handlers["on"+eventType]; ok {
+		listeners = append([]goja.Value{handler}, listeners...)
+	}
+	for _, listener := range listeners {
+		if fn, ok := goja.AssertFunction(listener); ok {
+			if _, err := fn(es.obj, e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (es *EventSource) addEventListener(eventType string, listener goja.Value) {
+	if _, ok := goja.AssertFunction(listener); !ok {
+		return
+	}
+	for _, l := range es.listeners[eventType] {
+		if l.StrictEquals(listener) {
+			return
+		}
+	}
+	es.listeners[eventType] = append(es.listeners[eventType], listener)
+}
+
+func (es *EventSource) removeEventListener(eventType string, listener goja.Value) {
+	listeners := es.listeners[eventType]
+	for i, l := range listeners {
+		if l.StrictEquals(listener) {
+			es.listeners[eventType] = append(listeners[:i:i], listeners[i+1:]...)
+			return
+		}
+	}
+}
+
+// close closes the connection, after which no more events are delivered.
+func (es *EventSource) close() {
+	es.readyState = closed
+	es.cancel()
+}
+
+// Get implements goja.DynamicObject.
+func (es *EventSource) Get(key string) goja.Value {
+	rt := es.vu.Runtime()
+	switch key {
+	case "url":
+		return rt.ToValue(es.url)
+	case "readyState":
+		return rt.ToValue(es.readyState)
+	case "CONNECTING":
+		return rt.ToValue(connecting)
+	case "OPEN":
+		return rt.ToValue(open)
+	case "CLOSED":
+		return rt.ToValue(closed)
+	}
+	if handler, ok := es.handlers[key]; ok {
+		return handler
+	}
+	return es.methods[key]
+}
+
+// Set implements goja.DynamicObject, only the event handlers can be set.
+func (es *EventSource) Set(key string, val goja.Value) bool {
+	if _, ok := es.handlers[key]; !ok {
+		return false
+	}
+	es.handlers[key] = val
+	return true
+}
+
+// Has implements goja.DynamicObject.
+func (es *EventSource) Has(key string) bool {
+	switch key {
+	case "url", "readyState", "CONNECTING", "OPEN", "CLOSED":
+		return true
+	}
+	if _, ok := es.handlers[key]; ok {
+		return true
+	}
+	_, ok := es.methods[key]
+	return ok
+}
+
+// Delete implements goja.DynamicObject, none of the properties can be deleted.
+func (es *EventSource) Delete(string) bool {
+	return false
+}
+
+// Keys implements goja.DynamicObject.
+func (es *EventSource) Keys() []string {
+	return []string{"url", "readyState", "onopen", "onmessage", "onerror"}
+}
+
+// dispatcher queues functions on the event loop from another goroutine, keeping the event loop
+// waiting for the next one until finish is called.
+type dispatcher struct {
+	vu        modules.VU
+	vuCtx     context.Context
+	runOnLoop func(func() error)
+
+	mu        sync.Mutex
+	abandoned bool
+}
+
+// dispatch queues f on the event loop and waits for it to be run, it returns false if the
+// iteration has ended before that, in which case nothing more can be dispatched.
+func (d *dispatcher) dispatch(f func() error) bool {
+	next := make(chan func(func() error), 1)
+	d.runOnLoop(func() error {
+		d.mu.Lock()
+		if !d.abandoned {
+			next <- d.vu.RegisterCallback()
+		}
+		d.mu.Unlock()
+		return f()
+	})
+	select {
+	case d.runOnLoop = <-next:
+		return true
+	case <-d.vuCtx.Done():
+		// the callback might never be run, and if it already was, the one
+		// it registered has to be released for the event loop to finish
+		d.mu.Lock()
+		d.abandoned = true
+		select {
+		case runOnLoop := <-next:
+			runOnLoop(func() error { return nil })
+		default:
+		}
+		d.mu.Unlock()
+		return false
+	}
+}
+
+// finish queues the last function on the event loop.
+func (d *dispatcher) finish(f func() error) {
+	d.runOnLoop(f)
+}
+
+func must(rt *goja.Runtime, err error) {
+	if err != nil {
+		common.Throw(rt, err)
+	}
+}
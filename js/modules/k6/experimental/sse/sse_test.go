@@ -0,0 +1,258 @@
+package sse
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/lib/testutils/httpmultibin"
+	"go.k6.io/k6/stats"
+)
+
+func newTestVU(t *testing.T) (*httpmultibin.HTTPMultiBin, *modulestest.LoopVU, chan stats.SampleContainer) {
+	t.Helper()
+	tb := httpmultibin.NewHTTPMultiBin(t)
+	testVU, samples := modulestest.NewTestVU(t, tb, stats.TagName, stats.TagURL)
+	vu := modulestest.NewLoopVU(testVU)
+	m, ok := New().NewModuleInstance(vu).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, vu.Runtime().Set("sse", m.Exports().Named))
+	return tb, vu, samples
+}
+
+// eventStream returns a handler that writes the next of the given streams for each connection, with the
+// LAST_EVENT_ID placeholder replaced by the header of the request. Every stream but the last one ends the
+// response, the last one is kept open until the client closes it.
+func eventStream(t *testing.T, streams ...string) http.HandlerFunc {
+	var conn int
+	return func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "text/event-stream", r.Header.Get("Accept"))
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, strings.ReplaceAll(streams[conn], "LAST_EVENT_ID", r.Header.Get("Last-Event-ID")))
+		w.(http.Flusher).Flush()
+		conn++
+		if conn == len(streams) {
+			<-r.Context().Done()
+		}
+	}
+}
+
+func TestEventSource(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Reconnect", func(t *testing.T) {
+		t.Parallel()
+		tb, vu, samples := newTestVU(t)
+		tb.Mux.HandleFunc("/sse", eventStream(t,
+			": a comment\nretry: 10\nid: 1\ndata: a\ndata: b\n\nevent: ping\nid: 2\ndata: c\n\n",
+			"data: after LAST_EVENT_ID\n\n",
+		))
+
+		_, err := vu.Runtime().RunString(tb.Replacer.Replace(`
+			var log = [];
+			var es = new sse.EventSource("HTTPBIN_URL/sse");
+			es.onopen = function(e) { log.push(e.type + " " + es.readyState); };
+			es.onmessage = function(e) {
+				log.push(e.type + " " + JSON.stringify(e.data) + " " + e.lastEventId);
+				if (e.data.indexOf("after") === 0) {
+					es.close();
+				}
+			};
+			es.onerror = function(e) { log.push(e.type + " " + es.readyState); };
+			es.addEventListener("ping", function(e) { log.push(e.type + " " + e.data + " " + e.lastEventId); });
+		`))
+		require.NoError(t, err)
+		require.NoError(t, vu.Run())
+		v, err := vu.Runtime().RunString(`log.push("closed " + es.readyState); log.join(",")`)
+		require.NoError(t, err)
+		assert.Equal(t, `open 1,message "a\nb" 1,ping c 2,error 0,open 1,message "after 2" 2,closed 2`, v.String())
+
+		counts := map[string]float64{}
+		for _, c := range stats.GetBufferedSamples(samples) {
+			for _, s := range c.GetSamples() {
+				surl, _ := s.Tags.Get("url")
+				assert.Equal(t, tb.Replacer.Replace("HTTPBIN_URL/sse"), surl)
+				counts[s.Metric.Name]++
+			}
+		}
+		assert.Equal(t, float64(3), counts[metrics.SSEEventsName])
+		assert.Equal(t, float64(2), counts[metrics.SSETimeToFirstEventName])
+	})
+
+	t.Run("Params", func(t *testing.T) {
+		t.Parallel()
+		tb, vu, samples := newTestVU(t)
+		tb.Mux.HandleFunc("/sse", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+			_, _ = fmt.Fprintf(w, "data: %s %s\n\n", r.Header.Get("Last-Event-ID"), r.Header.Get("X-Test"))
+		})
+
+		_, err := vu.Runtime().RunString(tb.Replacer.Replace(`
+			var data;
+			var es = new sse.EventSource("HTTPBIN_URL/sse", {
+				lastEventId: "41",
+				headers: { "X-Test": "header" },
+				tags: { tag: "value" },
+			});
+			es.addEventListener("message", function(e) {
+				data = e.data;
+				es.close();
+			});
+		`))
+		require.NoError(t, err)
+		require.NoError(t, vu.Run())
+		assert.Equal(t, "41 header", vu.Runtime().Get("data").String())
+
+		for _, c := range stats.GetBufferedSamples(samples) {
+			for _, s := range c.GetSamples() {
+				tag, _ := s.Tags.Get("tag")
+				assert.Equal(t, "value", tag)
+			}
+		}
+	})
+
+	t.Run("MaxRetries", func(t *testing.T) {
+		t.Parallel()
+		tb, vu, _ := newTestVU(t)
+		var conns int
+		tb.Mux.HandleFunc("/sse", func(w http.ResponseWriter, r *http.Request) {
+			conns++
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = fmt.Fprintf(w, "data: %d\n\n", conns)
+		})
+
+		_, err := vu.Runtime().RunString(tb.Replacer.Replace(`
+			var log = [];
+			var es = new sse.EventSource("HTTPBIN_URL/sse", { retry: "1ms", maxRetries: 1 });
+			es.onmessage = function(e) { log.push(e.data); };
+			es.onerror = function(e) { log.push("error " + es.readyState); };
+		`))
+		require.NoError(t, err)
+		require.NoError(t, vu.Run())
+		v, err := vu.Runtime().RunString(`log.join(",")`)
+		require.NoError(t, err)
+		assert.Equal(t, "1,error 0,2,error 2", v.String())
+	})
+
+	t.Run("Fatal", func(t *testing.T) {
+		t.Parallel()
+		tb, vu, _ := newTestVU(t)
+		tb.Mux.HandleFunc("/plain", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = io.WriteString(w, "data: 1\n\n")
+		})
+
+		_, err := vu.Runtime().RunString(tb.Replacer.Replace(`
+			var errors = [];
+			var onerror = function(e) { errors.push(e.target.readyState + " " + e.error); };
+			new sse.EventSource("HTTPBIN_URL/status/404").onerror = onerror;
+			new sse.EventSource("HTTPBIN_URL/plain").onerror = onerror;
+		`))
+		require.NoError(t, err)
+		require.NoError(t, vu.Run())
+		v, err := vu.Runtime().RunString(`errors.sort().join(",")`)
+		require.NoError(t, err)
+		assert.Equal(t, `2 the EventSource response content type is "text/plain",2 the EventSource response status is 404`,
+			v.String())
+	})
+
+	t.Run("InvalidArguments", func(t *testing.T) {
+		t.Parallel()
+		_, vu, _ := newTestVU(t)
+
+		_, err := vu.Runtime().RunString(`new sse.EventSource("ws://localhost/sse");`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "it needs to be an http or https URL")
+
+		_, err = vu.Runtime().RunString(`new sse.EventSource("http://localhost/sse", { retry: "soon" });`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid EventSource retry value")
+
+		_, err = vu.Runtime().RunString(`new sse.EventSource("http://localhost/sse", { method: "POST" });`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `unknown EventSource param "method"`)
+	})
+}
+
+func TestParser(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name, stream string
+		events       []event
+		initialID    string
+		lastEventID  string
+		retry        time.Duration
+	}{
+		{
+			name:   "LineEndings",
+			stream: "data: lf\n\ndata: crlf\r\n\r\ndata: cr\r\rdata:  spaces \n\n",
+			events: []event{
+				{Type: "message", Data: "lf"},
+				{Type: "message", Data: "crlf"},
+				{Type: "message", Data: "cr"},
+				{Type: "message", Data: " spaces "},
+			},
+		},
+		{
+			name:        "Fields",
+			stream:      ": comment\nevent: update\ndata\ndata: second\nid: 3\nretry: 2500\nunknown: field\n\n",
+			events:      []event{{Type: "update", Data: "\nsecond", LastEventID: "3"}},
+			lastEventID: "3",
+			retry:       2500 * time.Millisecond,
+		},
+		{
+			name:        "NoData",
+			stream:      "event: ignored\nid: 4\n\ndata: 5\n\nid\n\nretry: soon\n\ndata: unterminated",
+			events:      []event{{Type: "message", Data: "5", LastEventID: "4"}},
+			lastEventID: "",
+		},
+		{
+			name:        "InitialLastEventID",
+			stream:      "data: a\n\nid: x\x00y\ndata: b\n\n",
+			initialID:   "0",
+			events:      []event{{Type: "message", Data: "a", LastEventID: "0"}, {Type: "message", Data: "b", LastEventID: "0"}},
+			lastEventID: "0",
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			// read the stream a byte at a time to check the lines split across reads
+			p := newParser(&oneByteReader{strings.NewReader(tc.stream)}, tc.initialID)
+			var events []event
+			for {
+				ev, err := p.next()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				require.NoError(t, err)
+				events = append(events, *ev)
+			}
+			assert.Equal(t, tc.events, events)
+			assert.Equal(t, tc.lastEventID, p.lastEventID)
+			assert.Equal(t, tc.retry, p.retry)
+		})
+	}
+}
+
+type oneByteReader struct {
+	r io.Reader
+}
+
+func (r *oneByteReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	return r.r.Read(p[:1])
+}
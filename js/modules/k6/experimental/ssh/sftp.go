@@ -0,0 +1,259 @@
+package ssh
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// The packet types and constants of the version 3 of SFTP, see
+// https://datatracker.ietf.org/doc/html/draft-ietf-secsh-filexfer-02
+const (
+	sftpProtocolVersion = 3
+
+	sftpTypeInit    = 1
+	sftpTypeVersion = 2
+	sftpTypeOpen    = 3
+	sftpTypeClose   = 4
+	sftpTypeRead    = 5
+	sftpTypeWrite   = 6
+	sftpTypeStatus  = 101
+	sftpTypeHandle  = 102
+	sftpTypeData    = 103
+
+	sftpFlagRead   = 0x1
+	sftpFlagWrite  = 0x2
+	sftpFlagCreate = 0x8
+	sftpFlagTrunc  = 0x10
+
+	sftpAttrPermissions = 0x4
+
+	sftpOK  = 0
+	sftpEOF = 1
+
+	// sftpChunkSize is the size of the reads and writes, the servers support at least 32KB
+	sftpChunkSize = 32 * 1024
+	// sftpMaxPacketSize limits the packets of the server, which are at most a chunk of data
+	sftpMaxPacketSize = 256 * 1024
+)
+
+var errInvalidSFTPPacket = errors.New("invalid SFTP packet")
+
+//nolint:gochecknoglobals
+var sftpStatusNames = map[uint32]string{
+	1: "end of file",
+	2: "no such file",
+	3: "permission denied",
+	4: "failure",
+	5: "bad message",
+	6: "no connection",
+	7: "connection lost",
+	8: "operation unsupported",
+}
+
+// sftpError is an error status of the server.
+type sftpError struct {
+	code    uint32
+	message string
+}
+
+func (e *sftpError) Error() string {
+	name, ok := sftpStatusNames[e.code]
+	if !ok {
+		name = fmt.Sprintf("status %d", e.code)
+	}
+	if e.message == "" {
+		return "SFTP error: " + name
+	}
+	return fmt.Sprintf("SFTP error: %s (%s)", e.message, name)
+}
+
+// sftpSession is an SFTP session over the sftp subsystem of an SSH session, which sends one
+// request at a time.
+type sftpSession struct {
+	w  io.Writer
+	r  *bufio.Reader
+	id uint32
+}
+
+func openSFTP(session *gossh.Session) (*sftpSession, error) {
+	w, err := session.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	r, err := session.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err = session.RequestSubsystem("sftp"); err != nil {
+		return nil, fmt.Errorf("the server doesn't support SFTP: %w", err)
+	}
+	s := &sftpSession{w: w, r: bufio.NewReader(r)}
+	// the init packet has the version instead of a request ID
+	if err = writeSFTPPacket(s.w, sftpTypeInit, appendUint32(nil, sftpProtocolVersion)); err != nil {
+		return nil, err
+	}
+	typ, _, err := readSFTPPacket(s.r)
+	if err != nil {
+		return nil, err
+	}
+	if typ != sftpTypeVersion {
+		return nil, errInvalidSFTPPacket
+	}
+	return s, nil
+}
+
+// request sends a request and returns the type and the payload of its response, without its ID.
+func (s *sftpSession) request(typ byte, payload []byte) (byte, []byte, error) {
+	s.id++
+	if err := writeSFTPPacket(s.w, typ, append(appendUint32(nil, s.id), payload...)); err != nil {
+		return 0, nil, err
+	}
+	respType, resp, err := readSFTPPacket(s.r)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(resp) < 4 || binary.BigEndian.Uint32(resp) != s.id {
+		return 0, nil, errInvalidSFTPPacket
+	}
+	return respType, resp[4:], nil
+}
+
+// status returns the error of a response that needs to be an OK status.
+func (s *sftpSession) status(typ byte, payload []byte) error {
+	respType, resp, err := s.request(typ, payload)
+	if err != nil {
+		return err
+	}
+	return statusError(respType, resp)
+}
+
+func (s *sftpSession) open(path string, flags, mode uint32) ([]byte, error) {
+	payload := appendUint32(appendString(nil, path), flags)
+	if flags&sftpFlagCreate != 0 {
+		payload = appendUint32(appendUint32(payload, sftpAttrPermissions), mode)
+	} else {
+		payload = appendUint32(payload, 0)
+	}
+	typ, resp, err := s.request(sftpTypeOpen, payload)
+	if err != nil {
+		return nil, err
+	}
+	if typ != sftpTypeHandle {
+		return nil, statusError(typ, resp)
+	}
+	handle, _, ok := readString(resp)
+	if !ok {
+		return nil, errInvalidSFTPPacket
+	}
+	return handle, nil
+}
+
+func (s *sftpSession) writeFile(path string, data []byte, mode uint32) error {
+	handle, err := s.open(path, sftpFlagWrite|sftpFlagCreate|sftpFlagTrunc, mode)
+	if err != nil {
+		return err
+	}
+	for offset := 0; offset < len(data); offset += sftpChunkSize {
+		chunk := data[offset:]
+		if len(chunk) > sftpChunkSize {
+			chunk = chunk[:sftpChunkSize]
+		}
+		payload := appendString(appendUint64(appendString(nil, string(handle)), uint64(offset)), string(chunk))
+		if err = s.status(sftpTypeWrite, payload); err != nil {
+			return err
+		}
+	}
+	return s.status(sftpTypeClose, appendString(nil, string(handle)))
+}
+
+func (s *sftpSession) readFile(path string) ([]byte, error) {
+	handle, err := s.open(path, sftpFlagRead, 0)
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	for {
+		payload := appendUint32(appendUint64(appendString(nil, string(handle)), uint64(len(data))), sftpChunkSize)
+		typ, resp, err := s.request(sftpTypeRead, payload)
+		if err != nil {
+			return nil, err
+		}
+		if typ == sftpTypeData {
+			chunk, _, ok := readString(resp)
+			if !ok {
+				return nil, errInvalidSFTPPacket
+			}
+			data = append(data, chunk...)
+			continue
+		}
+		var statusErr *sftpError
+		if err = statusError(typ, resp); !errors.As(err, &statusErr) || statusErr.code != sftpEOF {
+			return nil, err
+		}
+		break
+	}
+	return data, s.status(sftpTypeClose, appendString(nil, string(handle)))
+}
+
+// statusError returns the error of a status response, which is nil if it's OK.
+func statusError(typ byte, resp []byte) error {
+	if typ != sftpTypeStatus || len(resp) < 4 {
+		return errInvalidSFTPPacket
+	}
+	code := binary.BigEndian.Uint32(resp)
+	if code == sftpOK {
+		return nil
+	}
+	message, _, _ := readString(resp[4:])
+	return &sftpError{code: code, message: string(message)}
+}
+
+func readSFTPPacket(r io.Reader) (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	length := binary.BigEndian.Uint32(header[:])
+	if length < 1 || length > sftpMaxPacketSize {
+		return 0, nil, errInvalidSFTPPacket
+	}
+	payload := make([]byte, length-1)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return header[4], payload, nil
+}
+
+func writeSFTPPacket(w io.Writer, typ byte, payload []byte) error {
+	packet := append(appendUint32(nil, uint32(len(payload)+1)), typ)
+	_, err := w.Write(append(packet, payload...))
+	return err
+}
+
+func readString(b []byte) ([]byte, []byte, bool) {
+	if len(b) < 4 {
+		return nil, nil, false
+	}
+	n := binary.BigEndian.Uint32(b)
+	if uint64(n) > uint64(len(b)-4) {
+		return nil, nil, false
+	}
+	return b[4 : 4+n], b[4+n:], true
+}
+
+func appendString(b []byte, s string) []byte {
+	return append(appendUint32(b, uint32(len(s))), s...)
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(v>>32)), uint32(v))
+}
@@ -0,0 +1,493 @@
+// Package ssh implements the k6/experimental/ssh module, an SSH client that runs commands and transfers
+// files with SFTP, for the orchestration steps of the tests, like resetting the fixtures in setup()
+// or fetching the logs of the servers in teardown().
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	gossh "golang.org/x/crypto/ssh"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/netext"
+	"go.k6.io/k6/lib/types"
+)
+
+type (
+	// RootModule is the global module instance that will create module
+	// instances for each VU.
+	RootModule struct{}
+
+	// ModuleInstance represents an instance of the ssh module for every VU.
+	ModuleInstance struct {
+		vu modules.VU
+	}
+)
+
+var (
+	_ modules.Module   = &RootModule{}
+	_ modules.Instance = &ModuleInstance{}
+)
+
+// New returns a pointer to a new RootModule instance.
+func New() *RootModule {
+	return &RootModule{}
+}
+
+// NewModuleInstance implements the modules.Module interface to return
+// a new instance for each VU.
+func (*RootModule) NewModuleInstance(vu modules.VU) modules.Instance {
+	return &ModuleInstance{vu: vu}
+}
+
+// Exports returns the exports of the ssh module.
+func (mi *ModuleInstance) Exports() modules.Exports {
+	return modules.Exports{
+		Named: map[string]interface{}{
+			"Client": mi.NewClient,
+		},
+	}
+}
+
+// NewClient is the JS constructor for the ssh Client.
+func (mi *ModuleInstance) NewClient(call goja.ConstructorCall) *goja.Object {
+	rt := mi.vu.Runtime()
+	return rt.ToValue(&Client{vu: mi.vu}).ToObject(rt)
+}
+
+const (
+	defaultPort           = "22"
+	defaultConnectTimeout = 10 * time.Second
+	defaultTimeout        = time.Minute
+	defaultFileMode       = 0o644
+)
+
+var (
+	errConnectInInitContext = common.NewInitContextError("SSH connections in the init context are not supported")
+	errNotConnected         = errors.New("the SSH client isn't connected")
+)
+
+// Client is an SSH client with a single connection, which is closed at the end of the iteration,
+// or of setup() and teardown(). Its methods return promises, the commands and the transfers run
+// outside of the event loop, which settles their promises when they end or time out.
+type Client struct {
+	vu modules.VU
+
+	conn       *gossh.Client
+	connecting bool
+}
+
+// Result is the result of a command, a non-zero exit code doesn't reject the promise of run().
+type Result struct {
+	// ExitCode is 128 plus the number of the signal if the command was killed by one, or -1 if the
+	// server didn't send it.
+	ExitCode int    `js:"exitCode"`
+	Signal   string `js:"signal"`
+	Stdout   string `js:"stdout"`
+	Stderr   string `js:"stderr"`
+}
+
+// Connect connects to the server and authenticates with a password or a private key, after the
+// host key of the server is verified.
+func (c *Client) Connect(params map[string]interface{}) *goja.Promise {
+	state := c.vu.State()
+	if state == nil {
+		return c.rejected(errConnectInInitContext)
+	}
+	if c.conn != nil || c.connecting {
+		return c.rejected(errors.New("the SSH client is already connected"))
+	}
+	addr, timeout, config, err := parseConnectParams(params)
+	if err != nil {
+		return c.rejected(err)
+	}
+
+	c.connecting = true
+	vuCtx := c.vu.Context()
+	return c.async(func() (func() interface{}, error) {
+		ctx, cancel := context.WithTimeout(vuCtx, timeout)
+		defer cancel()
+		conn, err := dial(ctx, state.Dialer, addr, config)
+		if err != nil {
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				err = fmt.Errorf("the SSH connection to %s timed out after %s", addr, timeout)
+			}
+			return func() interface{} { c.connecting = false; return nil }, err
+		}
+		go func() {
+			// the connection doesn't outlive the iteration
+			closed := make(chan struct{})
+			go func() { _ = conn.Wait(); close(closed) }()
+			select {
+			case <-vuCtx.Done():
+				_ = conn.Close()
+			case <-closed:
+			}
+		}()
+		return func() interface{} {
+			c.connecting, c.conn = false, conn
+			return goja.Undefined()
+		}, nil
+	})
+}
+
+// dial connects to the server, the handshake is interrupted when the context is done.
+func dial(
+	ctx context.Context, dialer lib.DialContexter, addr string, config *gossh.ClientConfig,
+) (*gossh.Client, error) {
+	nc, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	stop := netext.CloseOnDone(ctx, nc)
+	conn, chans, reqs, err := gossh.NewClientConn(nc, addr, config)
+	stop()
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		_ = nc.Close()
+		return nil, err
+	}
+	return gossh.NewClient(conn, chans, reqs), nil
+}
+
+// Run runs a command in a new session, with its env, stdin and timeout params. The promise is
+// resolved with the Result of the command once it exits, even with a non-zero exit code, and it's
+// rejected if the command doesn't exit before the timeout, after the command is killed.
+func (c *Client) Run(command string, params map[string]interface{}) *goja.Promise {
+	if c.conn == nil {
+		return c.rejected(errNotConnected)
+	}
+	timeout := defaultTimeout
+	var (
+		env   map[string]string
+		stdin []byte
+		err   error
+	)
+	for k, v := range params {
+		switch k {
+		case "timeout":
+			timeout, err = parseTimeout(v)
+		case "env":
+			vars, ok := v.(map[string]interface{})
+			if !ok {
+				return c.rejected(fmt.Errorf("the env param needs to be an object, got '%#v'", v))
+			}
+			env = make(map[string]string, len(vars))
+			for name, value := range vars {
+				env[name] = fmt.Sprint(value)
+			}
+		case "stdin":
+			stdin, err = common.ToBytes(v)
+		default:
+			err = fmt.Errorf("unknown run param: %q", k)
+		}
+		if err != nil {
+			return c.rejected(err)
+		}
+	}
+
+	conn, vuCtx := c.conn, c.vu.Context()
+	return c.async(func() (func() interface{}, error) {
+		session, err := conn.NewSession()
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = session.Close() }()
+		for name, value := range env {
+			if err := session.Setenv(name, value); err != nil {
+				return nil, fmt.Errorf("the server didn't accept the env variable %s: %w", name, err)
+			}
+		}
+		var stdout, stderr bytes.Buffer
+		session.Stdout, session.Stderr = &stdout, &stderr
+		if stdin != nil {
+			session.Stdin = bytes.NewReader(stdin)
+		}
+
+		ctx, cancel := context.WithTimeout(vuCtx, timeout)
+		defer cancel()
+		if err := session.Start(command); err != nil {
+			return nil, err
+		}
+		done := make(chan error, 1)
+		go func() { done <- session.Wait() }()
+		select {
+		case err = <-done:
+		case <-ctx.Done():
+			_ = session.Signal(gossh.SIGKILL)
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, fmt.Errorf("the command timed out after %s", timeout)
+			}
+			return nil, ctx.Err()
+		}
+
+		res := &Result{}
+		var (
+			exitErr    *gossh.ExitError
+			missingErr *gossh.ExitMissingError
+		)
+		switch {
+		case errors.As(err, &exitErr):
+			res.ExitCode, res.Signal = exitErr.ExitStatus(), exitErr.Signal()
+		case errors.As(err, &missingErr):
+			res.ExitCode = -1
+		case err != nil:
+			return nil, err
+		}
+		res.Stdout, res.Stderr = stdout.String(), stderr.String()
+		return func() interface{} { return res }, nil
+	})
+}
+
+// Upload writes a string or an ArrayBuffer to a file of the server with SFTP, the file is
+// created with the mode param, or truncated if it exists.
+func (c *Client) Upload(data interface{}, path string, params map[string]interface{}) *goja.Promise {
+	if c.conn == nil {
+		return c.rejected(errNotConnected)
+	}
+	b, err := common.ToBytes(data)
+	if err != nil {
+		return c.rejected(err)
+	}
+	timeout, mode := defaultTimeout, uint32(defaultFileMode)
+	for k, v := range params {
+		switch k {
+		case "timeout":
+			timeout, err = parseTimeout(v)
+		case "mode":
+			var n int64
+			switch v := v.(type) {
+			case int64:
+				n = v
+			case string:
+				n, err = strconv.ParseInt(v, 8, 32)
+			}
+			if err != nil || n <= 0 || n > 0o7777 {
+				err = fmt.Errorf("invalid mode value '%#v', it needs to be a number like 0o644 or an octal string", v)
+			}
+			mode = uint32(n)
+		default:
+			err = fmt.Errorf("unknown upload param: %q", k)
+		}
+		if err != nil {
+			return c.rejected(err)
+		}
+	}
+
+	return c.transfer(timeout, func(s *sftpSession) (func() interface{}, error) {
+		if err := s.writeFile(path, b, mode); err != nil {
+			return nil, err
+		}
+		return func() interface{} { return goja.Undefined() }, nil
+	})
+}
+
+// Download reads a file of the server with SFTP, as a string or an ArrayBuffer with the binary param.
+func (c *Client) Download(path string, params map[string]interface{}) *goja.Promise {
+	if c.conn == nil {
+		return c.rejected(errNotConnected)
+	}
+	timeout, binary := defaultTimeout, false
+	var err error
+	for k, v := range params {
+		switch k {
+		case "timeout":
+			timeout, err = parseTimeout(v)
+		case "binary":
+			var ok bool
+			if binary, ok = v.(bool); !ok {
+				err = errors.New("the binary param needs to be a boolean")
+			}
+		default:
+			err = fmt.Errorf("unknown download param: %q", k)
+		}
+		if err != nil {
+			return c.rejected(err)
+		}
+	}
+
+	rt := c.vu.Runtime()
+	return c.transfer(timeout, func(s *sftpSession) (func() interface{}, error) {
+		b, err := s.readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return func() interface{} {
+			if binary {
+				return rt.NewArrayBuffer(b)
+			}
+			return string(b)
+		}, nil
+	})
+}
+
+// Close closes the connection, the commands and transfers that are running fail.
+func (c *Client) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// transfer runs fn with a new SFTP session, which is closed after the timeout.
+func (c *Client) transfer(
+	timeout time.Duration, fn func(*sftpSession) (func() interface{}, error),
+) *goja.Promise {
+	conn, vuCtx := c.conn, c.vu.Context()
+	return c.async(func() (func() interface{}, error) {
+		session, err := conn.NewSession()
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = session.Close() }()
+		ctx, cancel := context.WithTimeout(vuCtx, timeout)
+		defer cancel()
+		defer netext.CloseOnDone(ctx, session)()
+
+		s, err := openSFTP(session)
+		if err == nil {
+			var settle func() interface{}
+			if settle, err = fn(s); err == nil {
+				return settle, nil
+			}
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("the SFTP transfer timed out after %s", timeout)
+		}
+		return nil, err
+	})
+}
+
+// async runs work outside of the event loop, and settles the promise on the event loop with the
+// value that the func returned by work returns, which is called there.
+func (c *Client) async(work func() (func() interface{}, error)) *goja.Promise {
+	rt := c.vu.Runtime()
+	p, resolve, reject := rt.NewPromise()
+	runOnLoop := c.vu.RegisterCallback()
+	go func() {
+		settle, err := work()
+		runOnLoop(func() error {
+			var v interface{}
+			if settle != nil {
+				v = settle()
+			}
+			if err != nil {
+				reject(rt.NewGoError(err))
+			} else {
+				resolve(v)
+			}
+			return nil
+		})
+	}()
+	return p
+}
+
+func (c *Client) rejected(err error) *goja.Promise {
+	rt := c.vu.Runtime()
+	p, _, reject := rt.NewPromise()
+	reject(rt.NewGoError(err))
+	return p
+}
+
+// parseConnectParams returns the address of the server, the timeout and the config of a connection.
+func parseConnectParams(params map[string]interface{}) (string, time.Duration, *gossh.ClientConfig, error) {
+	var (
+		host, port          = "", defaultPort
+		timeout             = defaultConnectTimeout
+		privateKey          []byte
+		passphrase, hostKey string
+		insecure            bool
+		err                 error
+	)
+	config := &gossh.ClientConfig{}
+	for k, v := range params {
+		switch k {
+		case "host":
+			host = fmt.Sprint(v)
+		case "port":
+			port = fmt.Sprint(v)
+		case "username":
+			config.User = fmt.Sprint(v)
+		case "password":
+			config.Auth = append(config.Auth, gossh.Password(fmt.Sprint(v)))
+		case "privateKey":
+			privateKey = []byte(fmt.Sprint(v))
+		case "passphrase":
+			passphrase = fmt.Sprint(v)
+		case "hostKey":
+			hostKey = fmt.Sprint(v)
+		case "insecureIgnoreHostKey":
+			var ok bool
+			if insecure, ok = v.(bool); !ok {
+				err = errors.New("the insecureIgnoreHostKey param needs to be a boolean")
+			}
+		case "timeout":
+			timeout, err = parseTimeout(v)
+		default:
+			err = fmt.Errorf("unknown connect param: %q", k)
+		}
+		if err != nil {
+			return "", 0, nil, err
+		}
+	}
+	if host == "" || config.User == "" {
+		return "", 0, nil, errors.New("the host and username params are required")
+	}
+
+	if privateKey != nil {
+		var signer gossh.Signer
+		if passphrase != "" {
+			signer, err = gossh.ParsePrivateKeyWithPassphrase(privateKey, []byte(passphrase))
+		} else {
+			signer, err = gossh.ParsePrivateKey(privateKey)
+		}
+		if err != nil {
+			return "", 0, nil, fmt.Errorf("invalid privateKey: %w", err)
+		}
+		config.Auth = append(config.Auth, gossh.PublicKeys(signer))
+	}
+
+	switch {
+	case hostKey != "" && strings.HasPrefix(hostKey, "SHA256:"):
+		config.HostKeyCallback = func(_ string, _ net.Addr, key gossh.PublicKey) error {
+			if fingerprint := gossh.FingerprintSHA256(key); fingerprint != hostKey {
+				return fmt.Errorf("the host key of the server has the fingerprint %s, not %s", fingerprint, hostKey)
+			}
+			return nil
+		}
+	case hostKey != "":
+		key, _, _, _, err := gossh.ParseAuthorizedKey([]byte(hostKey))
+		if err != nil {
+			return "", 0, nil, fmt.Errorf("invalid hostKey, it needs to be a public key or a SHA256 fingerprint: %w", err)
+		}
+		config.HostKeyCallback = gossh.FixedHostKey(key)
+	case insecure:
+		config.HostKeyCallback = gossh.InsecureIgnoreHostKey() //nolint:gosec
+	default:
+		return "", 0, nil, errors.New("the host key of the server needs to be verified, " +
+			"with the hostKey param, unless the insecureIgnoreHostKey param is true")
+	}
+	return net.JoinHostPort(host, port), timeout, config, nil
+}
+
+func parseTimeout(v interface{}) (time.Duration, error) {
+	timeout, err := types.GetDurationValue(v)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("invalid timeout value '%#v'", v)
+	}
+	return timeout, nil
+}
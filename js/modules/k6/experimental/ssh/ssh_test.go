@@ -0,0 +1,441 @@
+package ssh
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib/testutils/httpmultibin"
+)
+
+func newTestVU(t *testing.T) (*modulestest.LoopVU, context.CancelFunc) {
+	t.Helper()
+	testVU, _ := modulestest.NewTestVU(t, httpmultibin.NewHTTPMultiBin(t))
+	ctx, cancel := context.WithCancel(testVU.CtxField)
+	testVU.CtxField = ctx
+	vu := modulestest.NewLoopVU(testVU)
+	m, ok := New().NewModuleInstance(vu).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, vu.Runtime().Set("ssh", m.Exports().Named))
+	return vu, cancel
+}
+
+// run runs a script that returns a promise and returns the error it was rejected with.
+func run(t *testing.T, vu *modulestest.LoopVU, script string) string {
+	t.Helper()
+	rejection, err := vu.RunPromise(script)
+	require.NoError(t, err)
+	return rejection
+}
+
+// testServer is an SSH server of the k6 user, with an in-memory SFTP server and a few commands.
+type testServer struct {
+	addr    string
+	hostKey gossh.PublicKey
+	// clientKey is the PEM private key that the k6 user can authenticate with
+	clientKey string
+
+	mu     sync.Mutex
+	files  map[string][]byte
+	modes  map[string]uint32
+	killed chan struct{}
+	closed chan struct{}
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	_, hostPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	hostSigner, err := gossh.NewSignerFromKey(hostPriv)
+	require.NoError(t, err)
+	clientPriv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalECPrivateKey(clientPriv)
+	require.NoError(t, err)
+	clientPub, err := gossh.NewPublicKey(&clientPriv.PublicKey)
+	require.NoError(t, err)
+
+	s := &testServer{
+		hostKey:   hostSigner.PublicKey(),
+		clientKey: string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})),
+		files:     map[string][]byte{"/var/log/app.log": []byte("started\n")},
+		modes:     make(map[string]uint32),
+		killed:    make(chan struct{}, 1),
+		closed:    make(chan struct{}, 1),
+	}
+	config := &gossh.ServerConfig{
+		PasswordCallback: func(conn gossh.ConnMetadata, password []byte) (*gossh.Permissions, error) {
+			if conn.User() == "k6" && string(password) == "secret" {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("invalid password")
+		},
+		PublicKeyCallback: func(conn gossh.ConnMetadata, key gossh.PublicKey) (*gossh.Permissions, error) {
+			if conn.User() == "k6" && string(key.Marshal()) == string(clientPub.Marshal()) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("invalid public key")
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+	s.addr = ln.Addr().String()
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(nc, config)
+		}
+	}()
+	return s
+}
+
+func (s *testServer) serve(nc net.Conn, config *gossh.ServerConfig) {
+	conn, chans, reqs, err := gossh.NewServerConn(nc, config)
+	if err != nil {
+		return
+	}
+	go gossh.DiscardRequests(reqs)
+	go func() {
+		_ = conn.Wait()
+		s.closed <- struct{}{}
+	}()
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(gossh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		ch, reqs, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go s.session(ch, reqs)
+	}
+}
+
+func (s *testServer) session(ch gossh.Channel, reqs <-chan *gossh.Request) {
+	env := make(map[string]string)
+	signals := make(chan string, 1)
+	for req := range reqs {
+		switch req.Type {
+		case "env":
+			var kv struct{ Name, Value string }
+			_ = gossh.Unmarshal(req.Payload, &kv)
+			env[kv.Name] = kv.Value
+			_ = req.Reply(true, nil)
+		case "exec":
+			var cmd struct{ Command string }
+			_ = gossh.Unmarshal(req.Payload, &cmd)
+			_ = req.Reply(true, nil)
+			go exec(ch, cmd.Command, env, signals)
+		case "subsystem":
+			var subsystem struct{ Name string }
+			_ = gossh.Unmarshal(req.Payload, &subsystem)
+			_ = req.Reply(subsystem.Name == "sftp", nil)
+			if subsystem.Name == "sftp" {
+				go s.sftp(ch)
+			}
+		case "signal":
+			var signal struct{ Signal string }
+			_ = gossh.Unmarshal(req.Payload, &signal)
+			signals <- signal.Signal
+			s.killed <- struct{}{}
+		default:
+			_ = req.Reply(false, nil)
+		}
+	}
+}
+
+func exec(ch gossh.Channel, command string, env map[string]string, signals chan string) {
+	defer func() { _ = ch.Close() }()
+	status := uint32(0)
+	switch {
+	case command == "echo hello":
+		_, _ = ch.Write([]byte("hello\n"))
+	case command == "fail":
+		_, _ = ch.Stderr().Write([]byte("oops\n"))
+		status = 3
+	case strings.HasPrefix(command, "printenv "):
+		_, _ = ch.Write([]byte(env[strings.TrimPrefix(command, "printenv ")]))
+	case command == "cat":
+		_, _ = io.Copy(ch, ch)
+	case command == "sleep":
+		signal := <-signals
+		_, _ = ch.SendRequest("exit-signal", false, gossh.Marshal(struct {
+			Signal     string
+			CoreDumped bool
+			Error      string
+			Lang       string
+		}{Signal: signal}))
+		return
+	default:
+		_, _ = ch.Stderr().Write([]byte(command + ": command not found\n"))
+		status = 127
+	}
+	_, _ = ch.SendRequest("exit-status", false, gossh.Marshal(struct{ Status uint32 }{status}))
+}
+
+// sftp serves the in-memory files, the handles are the paths of the files.
+func (s *testServer) sftp(ch gossh.Channel) {
+	defer func() { _ = ch.Close() }()
+	r := bufio.NewReader(ch)
+	if typ, _, err := readSFTPPacket(r); err != nil || typ != sftpTypeInit {
+		return
+	}
+	_ = writeSFTPPacket(ch, sftpTypeVersion, appendUint32(nil, sftpProtocolVersion))
+	status := func(id []byte, code uint32, message string) {
+		_ = writeSFTPPacket(ch, sftpTypeStatus, appendString(appendString(appendUint32(id, code), message), ""))
+	}
+	for {
+		typ, payload, err := readSFTPPacket(r)
+		if err != nil {
+			return
+		}
+		id := append([]byte{}, payload[:4]...)
+		path, rest, _ := readString(payload[4:])
+		s.mu.Lock()
+		file, exists := s.files[string(path)]
+		switch typ {
+		case sftpTypeOpen:
+			flags := binary.BigEndian.Uint32(rest)
+			switch {
+			case flags&sftpFlagCreate != 0:
+				s.files[string(path)] = nil
+				s.modes[string(path)] = binary.BigEndian.Uint32(rest[8:])
+				_ = writeSFTPPacket(ch, sftpTypeHandle, appendString(id, string(path)))
+			case exists:
+				_ = writeSFTPPacket(ch, sftpTypeHandle, appendString(id, string(path)))
+			default:
+				status(id, 2, "")
+			}
+		case sftpTypeWrite:
+			offset := binary.BigEndian.Uint64(rest)
+			data, _, _ := readString(rest[8:])
+			s.files[string(path)] = append(file[:offset], data...)
+			status(id, sftpOK, "")
+		case sftpTypeRead:
+			offset, length := binary.BigEndian.Uint64(rest), binary.BigEndian.Uint32(rest[8:])
+			if offset >= uint64(len(file)) {
+				status(id, sftpEOF, "")
+				break
+			}
+			end := offset + uint64(length)
+			if end > uint64(len(file)) {
+				end = uint64(len(file))
+			}
+			_ = writeSFTPPacket(ch, sftpTypeData, appendString(id, string(file[offset:end])))
+		case sftpTypeClose:
+			status(id, sftpOK, "")
+		default:
+			status(id, 8, "unsupported")
+		}
+		s.mu.Unlock()
+	}
+}
+
+func TestClient(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Run", func(t *testing.T) {
+		t.Parallel()
+		vu, _ := newTestVU(t)
+		s := newTestServer(t)
+		require.NoError(t, vu.Runtime().Set("fingerprint", gossh.FingerprintSHA256(s.hostKey)))
+
+		assert.Empty(t, run(t, vu, `
+			var client = new ssh.Client();
+			results = [];
+			return client.connect({
+				host: "127.0.0.1", port: `+s.addr[strings.LastIndex(s.addr, ":")+1:]+`,
+				username: "k6", password: "secret", hostKey: fingerprint,
+			}).then(function() {
+				return client.run("echo hello");
+			}).then(function(res) {
+				results.push(res.exitCode, res.stdout);
+				return client.run("fail");
+			}).then(function(res) {
+				results.push(res.exitCode, res.stdout, res.stderr);
+				return client.run("printenv FIXTURE", { env: { FIXTURE: "users" } });
+			}).then(function(res) {
+				results.push(res.stdout);
+				return client.run("cat", { stdin: "from stdin" });
+			}).then(function(res) {
+				results.push(res.stdout);
+				client.close();
+			});
+		`))
+		v, err := vu.Runtime().RunString(`results.join("|")`)
+		require.NoError(t, err)
+		assert.Equal(t, "0|hello\n|3||oops\n|users|from stdin", v.String())
+	})
+
+	t.Run("PrivateKey", func(t *testing.T) {
+		t.Parallel()
+		vu, _ := newTestVU(t)
+		s := newTestServer(t)
+		host, port, err := net.SplitHostPort(s.addr)
+		require.NoError(t, err)
+		require.NoError(t, vu.Runtime().Set("params", map[string]interface{}{
+			"host": host, "port": port, "username": "k6", "privateKey": s.clientKey,
+			"hostKey": string(gossh.MarshalAuthorizedKey(s.hostKey)),
+		}))
+
+		assert.Empty(t, run(t, vu, `
+			var client = new ssh.Client();
+			return client.connect(params).then(function() {
+				return client.run("echo hello");
+			}).then(function(res) {
+				if (res.stdout !== "hello\n") { throw new Error("unexpected stdout " + res.stdout); }
+			});
+		`))
+	})
+
+	t.Run("HostKeyMismatch", func(t *testing.T) {
+		t.Parallel()
+		vu, _ := newTestVU(t)
+		s := newTestServer(t)
+		other := newTestServer(t)
+		host, port, err := net.SplitHostPort(s.addr)
+		require.NoError(t, err)
+		require.NoError(t, vu.Runtime().Set("params", map[string]interface{}{
+			"host": host, "port": port, "username": "k6", "password": "secret",
+			"hostKey": gossh.FingerprintSHA256(other.hostKey),
+		}))
+
+		err2 := run(t, vu, `return new ssh.Client().connect(params);`)
+		assert.Contains(t, err2, "the host key of the server has the fingerprint "+gossh.FingerprintSHA256(s.hostKey))
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		t.Parallel()
+		vu, _ := newTestVU(t)
+		s := newTestServer(t)
+		host, port, err := net.SplitHostPort(s.addr)
+		require.NoError(t, err)
+		require.NoError(t, vu.Runtime().Set("params", map[string]interface{}{
+			"host": host, "port": port, "username": "k6", "password": "secret", "insecureIgnoreHostKey": true,
+		}))
+
+		err = fmt.Errorf("%s", run(t, vu, `
+			var client = new ssh.Client();
+			return client.connect(params).then(function() {
+				return client.run("sleep", { timeout: "100ms" });
+			});
+		`))
+		assert.Contains(t, err.Error(), "the command timed out after 100ms")
+		select {
+		case <-s.killed:
+		case <-time.After(time.Second):
+			t.Fatal("the command wasn't killed")
+		}
+	})
+
+	t.Run("SFTP", func(t *testing.T) {
+		t.Parallel()
+		vu, _ := newTestVU(t)
+		s := newTestServer(t)
+		host, port, err := net.SplitHostPort(s.addr)
+		require.NoError(t, err)
+		require.NoError(t, vu.Runtime().Set("params", map[string]interface{}{
+			"host": host, "port": port, "username": "k6", "password": "secret", "insecureIgnoreHostKey": true,
+		}))
+
+		assert.Empty(t, run(t, vu, `
+			var client = new ssh.Client();
+			var fixture = new Array(10001).join("0123456789");
+			results = [];
+			return client.connect(params).then(function() {
+				return client.upload(fixture, "/tmp/fixture.txt", { mode: 0o600 });
+			}).then(function() {
+				return client.download("/tmp/fixture.txt");
+			}).then(function(data) {
+				results.push(data === fixture);
+				return client.download("/var/log/app.log", { binary: true });
+			}).then(function(data) {
+				results.push(data.byteLength, String.fromCharCode.apply(null, new Uint8Array(data)));
+				return client.download("/missing");
+			}).catch(function(e) {
+				results.push(String(e));
+			});
+		`))
+		v, err := vu.Runtime().RunString(`results.join("|")`)
+		require.NoError(t, err)
+		assert.Equal(t, "true|8|started\n|GoError: SFTP error: no such file", v.String())
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		assert.Len(t, s.files["/tmp/fixture.txt"], 100000)
+		assert.Equal(t, uint32(0o600), s.modes["/tmp/fixture.txt"])
+	})
+
+	t.Run("IterationEnd", func(t *testing.T) {
+		t.Parallel()
+		vu, cancel := newTestVU(t)
+		s := newTestServer(t)
+		host, port, err := net.SplitHostPort(s.addr)
+		require.NoError(t, err)
+		require.NoError(t, vu.Runtime().Set("params", map[string]interface{}{
+			"host": host, "port": port, "username": "k6", "password": "secret", "insecureIgnoreHostKey": true,
+		}))
+
+		assert.Empty(t, run(t, vu, `return new ssh.Client().connect(params);`))
+		cancel()
+		select {
+		case <-s.closed:
+		case <-time.After(time.Second):
+			t.Fatal("the connection wasn't closed at the end of the iteration")
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		t.Parallel()
+		vu, _ := newTestVU(t)
+
+		tests := map[string]string{
+			`connect({ username: "k6" })`:                                      "the host and username params are required",
+			`connect({ host: "h", username: "k6", retries: 1 })`:               "unknown connect param",
+			`connect({ host: "h", username: "k6" })`:                           "needs to be verified",
+			`connect({ host: "h", username: "k6", hostKey: "AAAA" })`:          "invalid hostKey",
+			`connect({ host: "h", username: "k6", privateKey: "key" })`:        "invalid privateKey",
+			`connect({ host: "h", username: "k6", timeout: "soon" })`:          "invalid timeout value",
+			`connect({ host: "h", username: "k6", insecureIgnoreHostKey: 1 })`: "needs to be a boolean",
+			`run("echo hello")`:           "the SSH client isn't connected",
+			`upload("data", "/tmp/data")`: "the SSH client isn't connected",
+			`download("/tmp/data")`:       "the SSH client isn't connected",
+		}
+		for script, msg := range tests {
+			assert.Contains(t, run(t, vu, `return new ssh.Client().`+script+`;`), msg, script)
+		}
+	})
+
+	t.Run("InitContext", func(t *testing.T) {
+		t.Parallel()
+		vu, _ := newTestVU(t)
+		vu.StateField = nil
+
+		err := run(t, vu, `return new ssh.Client().connect({ host: "127.0.0.1", username: "k6" });`)
+		assert.Contains(t, err, "SSH connections in the init context are not supported")
+	})
+}
@@ -0,0 +1,500 @@
+// Package tcp implements the k6/experimental/tcp module, with raw TCP sockets for the protocols that
+// don't have a module, optionally framed by a delimiter or a length prefix.
+package tcp
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/stats"
+)
+
+type (
+	// RootModule is the global module instance that will create module
+	// instances for each VU.
+	RootModule struct{}
+
+	// ModuleInstance represents an instance of the tcp module for every VU.
+	ModuleInstance struct {
+		vu modules.VU
+	}
+)
+
+var (
+	_ modules.Module   = &RootModule{}
+	_ modules.Instance = &ModuleInstance{}
+)
+
+// New returns a pointer to a new RootModule instance.
+func New() *RootModule {
+	return &RootModule{}
+}
+
+// NewModuleInstance implements the modules.Module interface to return
+// a new instance for each VU.
+func (*RootModule) NewModuleInstance(vu modules.VU) modules.Instance {
+	return &ModuleInstance{vu: vu}
+}
+
+// Exports returns the exports of the tcp module.
+func (mi *ModuleInstance) Exports() modules.Exports {
+	return modules.Exports{
+		Named: map[string]interface{}{
+			"Socket": mi.NewSocket,
+		},
+	}
+}
+
+// NewSocket is the JS constructor for the tcp Socket.
+func (mi *ModuleInstance) NewSocket(call goja.ConstructorCall) *goja.Object {
+	rt := mi.vu.Runtime()
+	return rt.ToValue(&Socket{vu: mi.vu}).ToObject(rt)
+}
+
+const (
+	defaultTimeout   = 10 * time.Second
+	defaultMaxLength = 16 << 20
+	readSize         = 32 << 10
+)
+
+var (
+	errConnectInInitContext = common.NewInitContextError("connecting a TCP socket in the init context is not supported")
+	errNotConnected         = errors.New("the TCP socket isn't connected")
+	errClosed               = errors.New("the TCP socket was closed")
+)
+
+// framing splits the data that is read in frames, and frames the data that is written.
+type framing struct {
+	// kind is none, delimiter or length
+	kind      string
+	delimiter []byte
+	// lengthBytes is the size of the big-endian length prefix of the length framing, 1, 2 or 4
+	lengthBytes int
+	maxLength   int
+}
+
+func parseFraming(v interface{}) (framing, error) {
+	f := framing{kind: "none", delimiter: []byte("\n"), lengthBytes: 4, maxLength: defaultMaxLength}
+	if s, ok := v.(string); ok {
+		v = map[string]interface{}{"type": s}
+	}
+	raw, ok := v.(map[string]interface{})
+	if !ok {
+		return f, fmt.Errorf("the framing param needs to be a framing type or an object, got '%#v'", v)
+	}
+	for k, v := range raw {
+		switch k {
+		case "type":
+			f.kind = fmt.Sprint(v)
+			if f.kind != "none" && f.kind != "delimiter" && f.kind != "length" {
+				return f, fmt.Errorf("invalid framing type %q, it needs to be none, delimiter or length", f.kind)
+			}
+		case "delimiter":
+			if f.delimiter = []byte(fmt.Sprint(v)); len(f.delimiter) == 0 {
+				return f, errors.New("the framing delimiter can't be empty")
+			}
+		case "lengthBytes":
+			n, ok := v.(int64)
+			if !ok || (n != 1 && n != 2 && n != 4) {
+				return f, fmt.Errorf("invalid lengthBytes '%#v', it needs to be 1, 2 or 4", v)
+			}
+			f.lengthBytes = int(n)
+		case "maxLength":
+			n, ok := v.(int64)
+			if !ok || n <= 0 {
+				return f, fmt.Errorf("invalid maxLength '%#v'", v)
+			}
+			f.maxLength = int(n)
+		default:
+			return f, fmt.Errorf("unknown framing param: %q", k)
+		}
+	}
+	return f, nil
+}
+
+// frame returns data with its framing.
+func (f *framing) frame(data []byte) ([]byte, error) {
+	switch f.kind {
+	case "delimiter":
+		if bytes.Contains(data, f.delimiter) {
+			return nil, errors.New("the data contains the framing delimiter")
+		}
+		return append(append(make([]byte, 0, len(data)+len(f.delimiter)), data...), f.delimiter...), nil
+	case "length":
+		if len(data) > f.maxLength || uint64(len(data)) >= 1<<(8*f.lengthBytes) {
+			return nil, fmt.Errorf("the data is longer than the max length of the frames, %d bytes", len(data))
+		}
+		var prefix [8]byte
+		binary.BigEndian.PutUint64(prefix[:], uint64(len(data)))
+		return append(append(make([]byte, 0, f.lengthBytes+len(data)), prefix[8-f.lengthBytes:]...), data...), nil
+	default:
+		return data, nil
+	}
+}
+
+// split returns the first frame of buf and the size of buf it takes, which is 0 if buf doesn't have
+// the whole frame yet. Without framing, the frame is all of buf, or its first n bytes if n is set.
+func (f *framing) split(buf []byte, n int) ([]byte, int, error) {
+	switch f.kind {
+	case "delimiter":
+		i := bytes.Index(buf, f.delimiter)
+		if i < 0 {
+			if len(buf) > f.maxLength {
+				return nil, 0, fmt.Errorf("no delimiter in the first %d bytes", f.maxLength)
+			}
+			return nil, 0, nil
+		}
+		return buf[:i], i + len(f.delimiter), nil
+	case "length":
+		if len(buf) < f.lengthBytes {
+			return nil, 0, nil
+		}
+		var prefix [8]byte
+		copy(prefix[8-f.lengthBytes:], buf[:f.lengthBytes])
+		length := binary.BigEndian.Uint64(prefix[:])
+		if length > uint64(f.maxLength) {
+			return nil, 0, fmt.Errorf("the length of the frame, %d bytes, is over the max length", length)
+		}
+		if end := f.lengthBytes + int(length); len(buf) >= end {
+			return buf[f.lengthBytes:end], end, nil
+		}
+		return nil, 0, nil
+	default:
+		if n > 0 {
+			if len(buf) < n {
+				return nil, 0, nil
+			}
+			return buf[:n], n, nil
+		}
+		return buf, len(buf), nil
+	}
+}
+
+// Socket is a TCP socket, which can be connected once. It's closed at the latest when the iteration ends.
+type Socket struct {
+	vu modules.VU
+
+	// set by Connect
+	conn    net.Conn
+	host    string
+	framing framing
+	timeout time.Duration
+	tags    map[string]string
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	mu  sync.Mutex
+	err error
+
+	// buf has the data that was read but isn't part of a returned frame yet
+	buf []byte
+}
+
+// connectParams are the params of connect().
+type connectParams struct {
+	TLS     bool
+	Framing framing
+	Timeout time.Duration
+	Tags    map[string]string
+}
+
+func parseConnectParams(raw map[string]interface{}) (connectParams, error) {
+	p := connectParams{Timeout: defaultTimeout}
+	var err error
+	if p.Framing, err = parseFraming("none"); err != nil {
+		return p, err
+	}
+	for k, v := range raw {
+		switch k {
+		case "tls":
+			var ok bool
+			if p.TLS, ok = v.(bool); !ok {
+				return p, errors.New("the tls param needs to be a boolean")
+			}
+		case "framing":
+			if p.Framing, err = parseFraming(v); err != nil {
+				return p, err
+			}
+		case "timeout":
+			p.Timeout, err = types.GetDurationValue(v)
+			if err != nil || p.Timeout <= 0 {
+				return p, fmt.Errorf("invalid timeout value '%#v'", v)
+			}
+		case "tags":
+			tags, ok := v.(map[string]interface{})
+			if !ok {
+				return p, fmt.Errorf("metric tags must be an object of string values, got '%#v'", v)
+			}
+			p.Tags = make(map[string]string, len(tags))
+			for name, tag := range tags {
+				p.Tags[name] = fmt.Sprint(tag)
+			}
+		default:
+			return p, fmt.Errorf("unknown connect param: %q", k)
+		}
+	}
+	return p, nil
+}
+
+// Connect connects the socket to a host:port address, with TLS if the tls param is set.
+func (s *Socket) Connect(addr string, params map[string]interface{}) error {
+	state := s.vu.State()
+	if state == nil {
+		return errConnectInInitContext
+	}
+	if s.conn != nil {
+		return errors.New("the TCP socket was already connected, a new socket is needed to connect again")
+	}
+	p, err := parseConnectParams(params)
+	if err != nil {
+		return err
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid address %q, it needs to be host:port: %w", addr, err)
+	}
+
+	s.tags = state.CloneTags()
+	if state.Options.SystemTags.Has(stats.TagURL) {
+		scheme := "tcp"
+		if p.TLS {
+			scheme = "tls"
+		}
+		s.tags["url"] = scheme + "://" + addr
+	}
+	for k, v := range p.Tags {
+		s.tags[k] = v
+	}
+
+	ctx, cancel := context.WithTimeout(s.vu.Context(), p.Timeout)
+	defer cancel()
+	start := time.Now()
+	conn, err := state.Dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.push(state.BuiltinMetrics.TCPConnecting, stats.D(time.Since(start)))
+
+	s.conn, s.host, s.framing, s.timeout = conn, host, p.Framing, p.Timeout
+	s.ctx, s.cancel = context.WithCancel(s.vu.Context())
+	go func() {
+		// the socket doesn't outlive the iteration
+		<-s.ctx.Done()
+		s.closeWith(errClosed)
+	}()
+	if p.TLS {
+		if err := s.handshake(ctx, ""); err != nil {
+			s.closeWith(err)
+			return err
+		}
+	}
+	return nil
+}
+
+// handshake does the TLS handshake over the connection.
+func (s *Socket) handshake(ctx context.Context, serverName string) error {
+	state := s.vu.State()
+	tlsConfig := &tls.Config{} //nolint:gosec
+	if state.TLSConfig != nil {
+		tlsConfig = state.TLSConfig.Clone()
+	}
+	if serverName != "" {
+		tlsConfig.ServerName = serverName
+	} else if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = s.host
+	}
+
+	start := time.Now()
+	tlsConn := tls.Client(s.conn, tlsConfig)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return err
+	}
+	s.push(state.BuiltinMetrics.TCPTLSHandshaking, stats.D(time.Since(start)))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.conn = tlsConn
+	return nil
+}
+
+// StartTLS upgrades the connection to TLS, like after the STARTTLS command of SMTP. The server name
+// is the host of the address the socket is connected to, unless it's set in the params.
+func (s *Socket) StartTLS(params map[string]interface{}) error {
+	if err := s.check(); err != nil {
+		return err
+	}
+	if _, ok := s.conn.(*tls.Conn); ok {
+		return errors.New("the TCP socket already uses TLS")
+	}
+	if len(s.buf) > 0 {
+		return errors.New("the TCP socket has data that wasn't read yet, it can't be upgraded to TLS")
+	}
+	var serverName string
+	for k, v := range params {
+		if k != "serverName" {
+			return fmt.Errorf("unknown startTLS param: %q", k)
+		}
+		serverName = fmt.Sprint(v)
+	}
+	ctx, cancel := context.WithTimeout(s.ctx, s.timeout)
+	defer cancel()
+	return s.handshake(ctx, serverName)
+}
+
+func (s *Socket) check() error {
+	if s.conn == nil {
+		return errNotConnected
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Write writes a string or an ArrayBuffer to the socket, with its framing.
+func (s *Socket) Write(data interface{}) error {
+	if err := s.check(); err != nil {
+		return err
+	}
+	b, err := common.ToBytes(data)
+	if err != nil {
+		return err
+	}
+	if b, err = s.framing.frame(b); err != nil {
+		return err
+	}
+	if err = s.conn.SetWriteDeadline(time.Now().Add(s.timeout)); err != nil {
+		return err
+	}
+	if _, err = s.conn.Write(b); err != nil {
+		return s.failed(err)
+	}
+	s.push(s.vu.State().BuiltinMetrics.TCPBytesSent, float64(len(b)))
+	return nil
+}
+
+// Read reads the next frame, or without framing the data that was received or its bytes param.
+// It returns a string, or an ArrayBuffer if its binary param is set.
+func (s *Socket) Read(params map[string]interface{}) (interface{}, error) {
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	timeout, n, binary := s.timeout, 0, false
+	var err error
+	for k, v := range params {
+		switch k {
+		case "timeout":
+			if timeout, err = types.GetDurationValue(v); err != nil || timeout <= 0 {
+				return nil, fmt.Errorf("invalid timeout value '%#v'", v)
+			}
+		case "bytes":
+			size, ok := v.(int64)
+			if !ok || size <= 0 || s.framing.kind != "none" {
+				return nil, fmt.Errorf("invalid bytes '%#v', it needs to be a positive number, without framing", v)
+			}
+			n = int(size)
+		case "binary":
+			var ok bool
+			if binary, ok = v.(bool); !ok {
+				return nil, errors.New("the binary param needs to be a boolean")
+			}
+		default:
+			return nil, fmt.Errorf("unknown read param: %q", k)
+		}
+	}
+
+	start := time.Now()
+	frame, err := s.read(n, start.Add(timeout))
+	if err != nil {
+		return nil, err
+	}
+	s.push(s.vu.State().BuiltinMetrics.TCPReadDuration, stats.D(time.Since(start)))
+	if binary {
+		return s.vu.Runtime().NewArrayBuffer(frame), nil
+	}
+	return string(frame), nil
+}
+
+// read returns the next frame, it keeps the data that was read if it times out before the whole frame.
+func (s *Socket) read(n int, deadline time.Time) ([]byte, error) {
+	chunk := make([]byte, readSize)
+	for {
+		frame, size, err := s.framing.split(s.buf, n)
+		if err != nil {
+			return nil, err
+		}
+		if size > 0 {
+			frame = append([]byte{}, frame...)
+			s.buf = s.buf[size:]
+			return frame, nil
+		}
+
+		if err = s.conn.SetReadDeadline(deadline); err != nil {
+			return nil, err
+		}
+		read, err := s.conn.Read(chunk)
+		s.buf = append(s.buf, chunk[:read]...)
+		if read > 0 {
+			s.push(s.vu.State().BuiltinMetrics.TCPBytesReceived, float64(read))
+		}
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return nil, errors.New("timed out reading from the TCP socket")
+		}
+		if err != nil {
+			return nil, s.failed(err)
+		}
+	}
+}
+
+// failed closes the socket after an error of the connection, it returns the error the socket was closed with.
+func (s *Socket) failed(err error) error {
+	s.closeWith(err)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close closes the socket.
+func (s *Socket) Close() {
+	if s.conn != nil {
+		s.closeWith(errClosed)
+	}
+}
+
+func (s *Socket) closeWith(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return
+	}
+	s.err = err
+	_ = s.conn.Close()
+	s.cancel()
+}
+
+func (s *Socket) push(metric *stats.Metric, value float64) {
+	tags := make(map[string]string, len(s.tags))
+	for k, v := range s.tags {
+		tags[k] = v
+	}
+	stats.PushIfNotDone(s.vu.Context(), s.vu.State().Samples, stats.Sample{
+		Metric: metric,
+		Tags:   stats.IntoSampleTags(&tags),
+		Value:  value,
+		Time:   time.Now(),
+	})
+}
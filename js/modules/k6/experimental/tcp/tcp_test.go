@@ -0,0 +1,357 @@
+package tcp
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/lib/testutils/httpmultibin"
+	"go.k6.io/k6/stats"
+)
+
+func newTestVU(t *testing.T) (*httpmultibin.HTTPMultiBin, *modulestest.VU, chan stats.SampleContainer) {
+	t.Helper()
+	tb := httpmultibin.NewHTTPMultiBin(t)
+	vu, samples := modulestest.NewTestVU(t, tb, stats.TagURL)
+	m, ok := New().NewModuleInstance(vu).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, vu.Runtime().Set("tcp", m.Exports().Named))
+	return tb, vu, samples
+}
+
+// newTestServer starts a server that handles its connections with serve, it returns its address.
+func newTestServer(t *testing.T, tlsConfig *tls.Config, serve func(net.Conn)) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	if tlsConfig != nil {
+		ln = tls.NewListener(ln, tlsConfig)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer func() { _ = conn.Close() }()
+				serve(conn)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func echo(conn net.Conn) {
+	_, _ = io.Copy(conn, conn)
+}
+
+// smtp greets the clients and answers their lines, until they send QUIT. After a STARTTLS line,
+// it continues over TLS if it has a TLS config.
+func smtp(tlsConfig *tls.Config) func(net.Conn) {
+	return func(conn net.Conn) {
+		_, _ = conn.Write([]byte("220 ready\r\n"))
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimSuffix(line, "\r\n")
+			switch {
+			case line == "QUIT":
+				_, _ = conn.Write([]byte("221 bye\r\n"))
+				return
+			case line == "STARTTLS" && tlsConfig != nil:
+				_, _ = conn.Write([]byte("220 go ahead\r\n"))
+				conn = tls.Server(conn, tlsConfig)
+				r = bufio.NewReader(conn)
+			default:
+				_, _ = conn.Write([]byte("250 " + line + "\r\n"))
+			}
+		}
+	}
+}
+
+func TestSocket(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Delimiter", func(t *testing.T) {
+		t.Parallel()
+		_, vu, samples := newTestVU(t)
+		addr := newTestServer(t, nil, smtp(nil))
+
+		_, err := vu.Runtime().RunString(strings.ReplaceAll(`
+			var socket = new tcp.Socket();
+			socket.connect("ADDR", { framing: { type: "delimiter", delimiter: "\r\n" }, tags: { tag: "value" } });
+			var lines = [socket.read()];
+			socket.write("EHLO k6");
+			lines.push(socket.read());
+			socket.write("QUIT");
+			lines.push(socket.read());
+			socket.close();
+		`, "ADDR", addr))
+		require.NoError(t, err)
+
+		v, err := vu.Runtime().RunString(`lines.join("|")`)
+		require.NoError(t, err)
+		assert.Equal(t, "220 ready|250 EHLO k6|221 bye", v.String())
+
+		counts := map[string]float64{}
+		for _, c := range stats.GetBufferedSamples(samples) {
+			for _, s := range c.GetSamples() {
+				url, _ := s.Tags.Get("url")
+				assert.Equal(t, "tcp://"+addr, url)
+				tag, _ := s.Tags.Get("tag")
+				assert.Equal(t, "value", tag)
+				counts[s.Metric.Name] += s.Value
+			}
+		}
+		assert.Equal(t, float64(len("EHLO k6\r\nQUIT\r\n")), counts[metrics.TCPBytesSentName])
+		assert.Equal(t, float64(len("220 ready\r\n250 EHLO k6\r\n221 bye\r\n")), counts[metrics.TCPBytesReceivedName])
+		assert.Contains(t, counts, metrics.TCPConnectingName)
+		assert.Contains(t, counts, metrics.TCPReadDurationName)
+	})
+
+	t.Run("Length", func(t *testing.T) {
+		t.Parallel()
+		_, vu, _ := newTestVU(t)
+		addr := newTestServer(t, nil, echo)
+
+		_, err := vu.Runtime().RunString(strings.ReplaceAll(`
+			var socket = new tcp.Socket();
+			socket.connect("ADDR", { framing: { type: "length", lengthBytes: 2 } });
+			socket.write(new Uint8Array([1, 2, 3]).buffer);
+			socket.write("");
+			socket.write("text");
+			var frames = [new Uint8Array(socket.read({ binary: true })).join(","), socket.read(), socket.read()];
+		`, "ADDR", addr))
+		require.NoError(t, err)
+
+		v, err := vu.Runtime().RunString(`frames.join("|")`)
+		require.NoError(t, err)
+		assert.Equal(t, "1,2,3||text", v.String())
+	})
+
+	t.Run("Bytes", func(t *testing.T) {
+		t.Parallel()
+		_, vu, _ := newTestVU(t)
+		addr := newTestServer(t, nil, echo)
+
+		_, err := vu.Runtime().RunString(strings.ReplaceAll(`
+			var socket = new tcp.Socket();
+			socket.connect("ADDR");
+			socket.write("abcdef");
+			var parts = [socket.read({ bytes: 3 }), socket.read({ bytes: 3 })];
+		`, "ADDR", addr))
+		require.NoError(t, err)
+
+		v, err := vu.Runtime().RunString(`parts.join("|")`)
+		require.NoError(t, err)
+		assert.Equal(t, "abc|def", v.String())
+	})
+
+	t.Run("ReadTimeout", func(t *testing.T) {
+		t.Parallel()
+		_, vu, _ := newTestVU(t)
+		rest := make(chan struct{})
+		addr := newTestServer(t, nil, func(conn net.Conn) {
+			_, _ = conn.Write([]byte("par"))
+			<-rest
+			_, _ = conn.Write([]byte("tial\n"))
+		})
+		require.NoError(t, vu.Runtime().Set("rest", func() { close(rest) }))
+
+		_, err := vu.Runtime().RunString(strings.ReplaceAll(`
+			var socket = new tcp.Socket();
+			socket.connect("ADDR", { framing: "delimiter" });
+			var timedOut = "";
+			try {
+				socket.read({ timeout: "50ms" });
+			} catch (e) {
+				timedOut = e.message;
+			}
+			rest();
+			var line = socket.read();
+		`, "ADDR", addr))
+		require.NoError(t, err)
+
+		v, err := vu.Runtime().RunString(`timedOut + "|" + line`)
+		require.NoError(t, err)
+		assert.Equal(t, "timed out reading from the TCP socket|partial", v.String())
+	})
+
+	t.Run("TLS", func(t *testing.T) {
+		t.Parallel()
+		tb, vu, samples := newTestVU(t)
+		addr := newTestServer(t, tb.ServerHTTPS.TLS, echo)
+
+		_, err := vu.Runtime().RunString(strings.ReplaceAll(`
+			var socket = new tcp.Socket();
+			socket.connect("ADDR", { tls: true, framing: "delimiter" });
+			socket.write("secret");
+			var line = socket.read();
+		`, "ADDR", addr))
+		require.NoError(t, err)
+		assert.Equal(t, "secret", vu.Runtime().Get("line").String())
+
+		var handshakes int
+		for _, c := range stats.GetBufferedSamples(samples) {
+			for _, s := range c.GetSamples() {
+				url, _ := s.Tags.Get("url")
+				assert.Equal(t, "tls://"+addr, url)
+				if s.Metric.Name == metrics.TCPTLSHandshakingName {
+					handshakes++
+				}
+			}
+		}
+		assert.Equal(t, 1, handshakes)
+	})
+
+	t.Run("StartTLS", func(t *testing.T) {
+		t.Parallel()
+		tb, vu, _ := newTestVU(t)
+		addr := newTestServer(t, nil, smtp(tb.ServerHTTPS.TLS))
+
+		_, err := vu.Runtime().RunString(strings.ReplaceAll(`
+			var socket = new tcp.Socket();
+			socket.connect("ADDR", { framing: { type: "delimiter", delimiter: "\r\n" } });
+			var lines = [socket.read()];
+			socket.write("STARTTLS");
+			lines.push(socket.read());
+			socket.startTLS();
+			socket.write("AUTH PLAIN");
+			lines.push(socket.read());
+			var again = "";
+			try {
+				socket.startTLS();
+			} catch (e) {
+				again = e.message;
+			}
+		`, "ADDR", addr))
+		require.NoError(t, err)
+
+		v, err := vu.Runtime().RunString(`lines.join("|") + "|" + again`)
+		require.NoError(t, err)
+		assert.Equal(t, "220 ready|220 go ahead|250 AUTH PLAIN|the TCP socket already uses TLS", v.String())
+	})
+
+	t.Run("ServerClose", func(t *testing.T) {
+		t.Parallel()
+		_, vu, _ := newTestVU(t)
+		addr := newTestServer(t, nil, func(conn net.Conn) {
+			_, _ = conn.Write([]byte("bye"))
+		})
+
+		_, err := vu.Runtime().RunString(strings.ReplaceAll(`
+			var socket = new tcp.Socket();
+			socket.connect("ADDR");
+			var data = socket.read({ bytes: 3 });
+			var errors = [];
+			[function() { socket.read(); }, function() { socket.write("x"); }].forEach(function(f) {
+				try {
+					f();
+				} catch (e) {
+					errors.push(e.message);
+				}
+			});
+		`, "ADDR", addr))
+		require.NoError(t, err)
+
+		v, err := vu.Runtime().RunString(`data + "|" + errors.join("|")`)
+		require.NoError(t, err)
+		assert.Equal(t, "bye|EOF|EOF", v.String())
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		t.Parallel()
+		_, vu, _ := newTestVU(t)
+		addr := newTestServer(t, nil, echo)
+		require.NoError(t, vu.Runtime().Set("addr", addr))
+
+		tests := map[string]string{
+			`new tcp.Socket().write("x")`:                                                                              "isn't connected",
+			`new tcp.Socket().connect("localhost")`:                                                                    "it needs to be host:port",
+			`new tcp.Socket().connect(addr, { framing: "lines" })`:                                                     "invalid framing type",
+			`new tcp.Socket().connect(addr, { framing: { lengthBytes: 3 } })`:                                          "invalid lengthBytes",
+			`new tcp.Socket().connect(addr, { keepAlive: true })`:                                                      "unknown connect param",
+			`var s = new tcp.Socket(); s.connect(addr); s.connect(addr)`:                                               "already connected",
+			`var s = new tcp.Socket(); s.connect(addr, { framing: "delimiter" }); s.write("a\nb")`:                     "contains the framing delimiter",
+			`var s = new tcp.Socket(); s.connect(addr, { framing: "delimiter" }); s.read({ bytes: 1 })`:                "without framing",
+			`var s = new tcp.Socket(); s.connect(addr, { framing: { type: "length", maxLength: 2 } }); s.write("abc")`: "max length",
+			`var s = new tcp.Socket(); s.connect(addr); s.close(); s.write("x")`:                                       "the TCP socket was closed",
+		}
+		for script, msg := range tests {
+			_, err := vu.Runtime().RunString(script)
+			require.Error(t, err, script)
+			assert.Contains(t, err.Error(), msg, script)
+		}
+	})
+
+	t.Run("IterationEnd", func(t *testing.T) {
+		t.Parallel()
+		_, vu, _ := newTestVU(t)
+		addr := newTestServer(t, nil, echo)
+		ctx, cancel := context.WithCancel(vu.CtxField)
+		vu.CtxField = ctx
+
+		_, err := vu.Runtime().RunString(`var socket = new tcp.Socket(); socket.connect("` + addr + `");`)
+		require.NoError(t, err)
+		cancel()
+		require.Eventually(t, func() bool {
+			_, err = vu.Runtime().RunString(`socket.write("x")`)
+			return err != nil && strings.Contains(err.Error(), "the TCP socket was closed")
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("InitContext", func(t *testing.T) {
+		t.Parallel()
+		_, vu, _ := newTestVU(t)
+		vu.StateField = nil
+
+		_, err := vu.Runtime().RunString(`new tcp.Socket().connect("127.0.0.1:1")`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "connecting a TCP socket in the init context is not supported")
+	})
+}
+
+func TestFraming(t *testing.T) {
+	t.Parallel()
+
+	f, err := parseFraming(map[string]interface{}{"type": "length", "lengthBytes": int64(1), "maxLength": int64(300)})
+	require.NoError(t, err)
+	_, err = f.frame(make([]byte, 256))
+	assert.Error(t, err, "the length doesn't fit in the prefix")
+	framed, err := f.frame([]byte("ab"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("\x02ab"), framed)
+
+	frame, size, err := f.split([]byte("\x02a"), 0)
+	require.NoError(t, err)
+	assert.Equal(t, 0, size)
+	assert.Nil(t, frame)
+	frame, size, err = f.split([]byte("\x02abc"), 0)
+	require.NoError(t, err)
+	assert.Equal(t, 3, size)
+	assert.Equal(t, []byte("ab"), frame)
+
+	f, err = parseFraming(map[string]interface{}{"type": "delimiter", "delimiter": "||", "maxLength": int64(4)})
+	require.NoError(t, err)
+	frame, size, err = f.split([]byte("ab||cd"), 0)
+	require.NoError(t, err)
+	assert.Equal(t, 4, size)
+	assert.Equal(t, []byte("ab"), frame)
+	_, _, err = f.split([]byte("abcdef"), 0)
+	assert.Error(t, err)
+}
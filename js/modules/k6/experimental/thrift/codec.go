@@ -0,0 +1,297 @@
+package thrift
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/dop251/goja"
+)
+
+// maxDepth limits the nesting of the values, so that a recursive struct can't overflow the stack.
+const maxDepth = 64
+
+var errMaxDepth = errors.New("the value is nested too deeply")
+
+// encodeStruct encodes a JS object as a struct, the fields that are null or undefined aren't sent.
+func encodeStruct(e encoder, def *structDef, v interface{}, depth int) error {
+	if depth > maxDepth {
+		return errMaxDepth
+	}
+	obj, ok := v.(map[string]interface{})
+	if v != nil && !ok {
+		return fmt.Errorf("%s needs to be an object, not %T", def.name, v)
+	}
+	for name := range obj {
+		if _, ok := def.byName[name]; !ok {
+			return fmt.Errorf("unknown field %q of %s", name, def.name)
+		}
+	}
+	e.writeStructBegin()
+	set := 0
+	for _, f := range def.fields {
+		fv, ok := obj[f.name]
+		if !ok || fv == nil {
+			if f.required {
+				return fmt.Errorf("the required field %q of %s is missing", f.name, def.name)
+			}
+			continue
+		}
+		set++
+		e.writeFieldBegin(f.typ.id, f.id)
+		if err := encodeValue(e, f.typ, fv, depth+1); err != nil {
+			return fmt.Errorf("invalid field %q of %s: %w", f.name, def.name, err)
+		}
+	}
+	if def.union && set != 1 {
+		return fmt.Errorf("the union %s needs exactly one field, not %d", def.name, set)
+	}
+	e.writeFieldStop()
+	e.writeStructEnd()
+	return nil
+}
+
+//nolint:funlen,cyclop
+func encodeValue(e encoder, t *fieldType, v interface{}, depth int) error {
+	switch t.id {
+	case typeBool:
+		b, ok := v.(bool)
+		if !ok {
+			return fmt.Errorf("%T needs to be a boolean", v)
+		}
+		e.writeBool(b)
+	case typeByte, typeI16, typeI32, typeI64:
+		n, err := toInt(t, v)
+		if err != nil {
+			return err
+		}
+		switch t.id {
+		case typeByte:
+			e.writeByte(int8(n))
+		case typeI16:
+			e.writeI16(int16(n))
+		case typeI32:
+			e.writeI32(int32(n))
+		default:
+			e.writeI64(n)
+		}
+	case typeDouble:
+		switch n := v.(type) {
+		case int64:
+			e.writeDouble(float64(n))
+		case float64:
+			e.writeDouble(n)
+		default:
+			return fmt.Errorf("%T needs to be a number", v)
+		}
+	case typeString:
+		switch s := v.(type) {
+		case string:
+			e.writeBinary([]byte(s))
+		case goja.ArrayBuffer:
+			e.writeBinary(s.Bytes())
+		case []byte:
+			e.writeBinary(s)
+		default:
+			return fmt.Errorf("%T needs to be a string or an ArrayBuffer", v)
+		}
+	case typeStruct:
+		return encodeStruct(e, t.def, v, depth)
+	case typeList, typeSet:
+		elems, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("%T needs to be an array", v)
+		}
+		e.writeListBegin(t.elem.id, len(elems))
+		for i, elem := range elems {
+			if err := encodeValue(e, t.elem, elem, depth+1); err != nil {
+				return fmt.Errorf("invalid element %d: %w", i, err)
+			}
+		}
+	case typeMap:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%T needs to be an object", v)
+		}
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		e.writeMapBegin(t.key.id, t.elem.id, len(keys))
+		for _, k := range keys {
+			key, err := parseKey(t.key, k)
+			if err != nil {
+				return fmt.Errorf("invalid key %q: %w", k, err)
+			}
+			if err = encodeValue(e, t.key, key, depth+1); err != nil {
+				return fmt.Errorf("invalid key %q: %w", k, err)
+			}
+			if err = encodeValue(e, t.elem, obj[k], depth+1); err != nil {
+				return fmt.Errorf("invalid value of %q: %w", k, err)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported type %s", t)
+	}
+	return nil
+}
+
+// toInt returns the integer of a number, of a string for the i64 numbers that aren't safe in JS,
+// or of the name of an enum value.
+func toInt(t *fieldType, v interface{}) (int64, error) {
+	var n int64
+	switch v := v.(type) {
+	case int64:
+		n = v
+	case int8, int16, int32:
+		// the decoded values
+		n, _ = strconv.ParseInt(fmt.Sprint(v), 10, 64)
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, fmt.Errorf("%v needs to be an integer", v)
+		}
+		n = int64(v)
+	case string:
+		if t.enum != nil {
+			value, ok := t.enum.values[v]
+			if !ok {
+				return 0, fmt.Errorf("unknown value %q of the enum %s", v, t.enum.name)
+			}
+			return int64(value), nil
+		}
+		var err error
+		if n, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return 0, fmt.Errorf("%q needs to be an integer", v)
+		}
+	default:
+		return 0, fmt.Errorf("%T needs to be a number", v)
+	}
+	bits := map[byte]uint{typeByte: 8, typeI16: 16, typeI32: 32, typeI64: 64}[t.id]
+	if bits < 64 && (n < -1<<(bits-1) || n >= 1<<(bits-1)) {
+		return 0, fmt.Errorf("%d is out of the range of %s", n, t)
+	}
+	return n, nil
+}
+
+// parseKey parses a key of a JS object as a key of a map.
+func parseKey(t *fieldType, k string) (interface{}, error) {
+	switch t.id {
+	case typeString:
+		return k, nil
+	case typeBool:
+		return strconv.ParseBool(k)
+	case typeByte, typeI16, typeI32, typeI64:
+		return k, nil
+	case typeDouble:
+		return strconv.ParseFloat(k, 64)
+	default:
+		return nil, fmt.Errorf("the keys of the type %s aren't supported", t)
+	}
+}
+
+// decodeStruct decodes a struct to a JS object, with the fields that are set.
+func decodeStruct(rt *goja.Runtime, d decoder, def *structDef, depth int) (map[string]interface{}, error) {
+	if depth > maxDepth {
+		return nil, errMaxDepth
+	}
+	obj := make(map[string]interface{})
+	d.readStructBegin()
+	for {
+		typ, id, err := d.readFieldBegin()
+		if err != nil {
+			return nil, err
+		}
+		if typ == typeStop {
+			break
+		}
+		f, ok := def.byID[id]
+		if !ok || f.typ.id != typ {
+			if err = skip(d, typ, depth+1); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if obj[f.name], err = decodeValue(rt, d, f.typ, depth+1); err != nil {
+			return nil, fmt.Errorf("invalid field %q of %s: %w", f.name, def.name, err)
+		}
+	}
+	d.readStructEnd()
+	for _, f := range def.fields {
+		if _, ok := obj[f.name]; f.required && !ok {
+			return nil, fmt.Errorf("the required field %q of %s is missing", f.name, def.name)
+		}
+	}
+	return obj, nil
+}
+
+//nolint:funlen,cyclop
+func decodeValue(rt *goja.Runtime, d decoder, t *fieldType, depth int) (interface{}, error) {
+	switch t.id {
+	case typeBool:
+		return d.readBool()
+	case typeByte:
+		return d.readByte()
+	case typeI16:
+		return d.readI16()
+	case typeI32:
+		v, err := d.readI32()
+		if err != nil || t.enum == nil {
+			return v, err
+		}
+		if name, ok := t.enum.names[v]; ok {
+			return name, nil
+		}
+		return v, nil
+	case typeI64:
+		return d.readI64()
+	case typeDouble:
+		return d.readDouble()
+	case typeString:
+		b, err := d.readBinary()
+		if err != nil || !t.binary {
+			return string(b), err
+		}
+		return rt.NewArrayBuffer(b), nil
+	case typeStruct:
+		return decodeStruct(rt, d, t.def, depth)
+	case typeList, typeSet:
+		elem, size, err := d.readListBegin()
+		if err != nil {
+			return nil, err
+		}
+		if size > 0 && elem != t.elem.id {
+			return nil, fmt.Errorf("%w: the elements are of the type %d instead of %s", errInvalidMessage, elem, t.elem)
+		}
+		elems := make([]interface{}, size)
+		for i := range elems {
+			if elems[i], err = decodeValue(rt, d, t.elem, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return elems, nil
+	case typeMap:
+		key, elem, size, err := d.readMapBegin()
+		if err != nil {
+			return nil, err
+		}
+		if size > 0 && (key != t.key.id || elem != t.elem.id) {
+			return nil, fmt.Errorf("%w: the map is of the types %d and %d instead of %s", errInvalidMessage, key, elem, t)
+		}
+		obj := make(map[string]interface{}, size)
+		for i := 0; i < size; i++ {
+			k, err := decodeValue(rt, d, t.key, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			if obj[fmt.Sprint(k)], err = decodeValue(rt, d, t.elem, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return obj, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %s", t)
+	}
+}
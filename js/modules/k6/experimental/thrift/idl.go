@@ -0,0 +1,791 @@
+package thrift
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// The wire types of the fields, which are the same for the binary and the compact protocols
+// before the compact protocol maps them to its own.
+const (
+	typeStop   byte = 0
+	typeBool   byte = 2
+	typeByte   byte = 3
+	typeDouble byte = 4
+	typeI16    byte = 6
+	typeI32    byte = 8
+	typeI64    byte = 10
+	typeString byte = 11
+	typeStruct byte = 12
+	typeMap    byte = 13
+	typeSet    byte = 14
+	typeList   byte = 15
+)
+
+// maxTypedefDepth limits the typedefs of typedefs, so that a cycle of them is an error.
+const maxTypedefDepth = 32
+
+// fieldType is a type of the IDL, with the definition of the named types once they're resolved.
+type fieldType struct {
+	id byte
+	// binary is true if the string is a binary, which is an ArrayBuffer in JS.
+	binary bool
+	// key is the type of the keys of a map, elem the type of the elements of a list, a set or a map.
+	key, elem *fieldType
+	// name is the name of a named type, which is resolved to a typedef, an enum or a struct.
+	name  string
+	def   *structDef
+	enum  *enumDef
+	depth int
+}
+
+func (t *fieldType) String() string {
+	switch {
+	case t.def != nil:
+		return t.def.name
+	case t.enum != nil:
+		return t.enum.name
+	case t.id == typeMap:
+		return fmt.Sprintf("map<%s,%s>", t.key, t.elem)
+	case t.id == typeSet:
+		return fmt.Sprintf("set<%s>", t.elem)
+	case t.id == typeList:
+		return fmt.Sprintf("list<%s>", t.elem)
+	case t.binary:
+		return "binary"
+	}
+	for name, id := range baseTypes {
+		if id == t.id && name != "binary" && name != "i8" {
+			return name
+		}
+	}
+	return t.name
+}
+
+//nolint:gochecknoglobals
+var baseTypes = map[string]byte{
+	"bool":   typeBool,
+	"byte":   typeByte,
+	"i8":     typeByte,
+	"i16":    typeI16,
+	"i32":    typeI32,
+	"i64":    typeI64,
+	"double": typeDouble,
+	"string": typeString,
+	"binary": typeString,
+}
+
+// structDef is a struct, a union or an exception, and the args and the result of the functions.
+type structDef struct {
+	name   string
+	union  bool
+	fields []*field
+	byID   map[int16]*field
+	byName map[string]*field
+}
+
+type field struct {
+	id       int16
+	name     string
+	typ      *fieldType
+	required bool
+}
+
+func newStructDef(name string) *structDef {
+	return &structDef{name: name, byID: make(map[int16]*field), byName: make(map[string]*field)}
+}
+
+func (s *structDef) add(f *field) error {
+	if _, ok := s.byID[f.id]; ok {
+		return fmt.Errorf("duplicate field ID %d in %s", f.id, s.name)
+	}
+	if _, ok := s.byName[f.name]; ok {
+		return fmt.Errorf("duplicate field %q in %s", f.name, s.name)
+	}
+	s.fields = append(s.fields, f)
+	s.byID[f.id], s.byName[f.name] = f, f
+	return nil
+}
+
+type enumDef struct {
+	name   string
+	values map[string]int32
+	names  map[int32]string
+}
+
+type serviceDef struct {
+	name      string
+	extends   string
+	parent    *serviceDef
+	functions []*function
+}
+
+type function struct {
+	name   string
+	oneway bool
+	// returns is nil if the function is void.
+	returns *fieldType
+	args    *structDef
+	// result has the success field, with the ID 0, and the exceptions that the function throws.
+	result *structDef
+}
+
+// document is a parsed IDL file.
+type document struct {
+	path string
+	// includes are the included documents, by their file names without the extension, which are
+	// the prefixes of their types.
+	includes map[string]*document
+	defs     map[string]interface{}
+	services []*serviceDef
+	// types are the named types that are resolved once all the documents are parsed.
+	types []*fieldType
+}
+
+// loader parses the IDL files and the files that they include.
+type loader struct {
+	importPaths []string
+	open        func(filename string) (io.ReadCloser, error)
+	docs        map[string]*document
+	loading     map[string]bool
+}
+
+// load parses a file and the files that it includes, the file is looked up in the directory of the
+// file that includes it first, and then in the import paths.
+func (l *loader) load(filename, dir string) (*document, error) {
+	var candidates []string
+	if filepath.IsAbs(filename) {
+		candidates = []string{filename}
+	} else {
+		if dir != "" {
+			candidates = append(candidates, filepath.Join(dir, filename))
+		}
+		for _, p := range l.importPaths {
+			candidates = append(candidates, filepath.Join(p, filename))
+		}
+	}
+	for _, path := range candidates {
+		if doc, ok := l.docs[path]; ok {
+			return doc, nil
+		}
+		if l.loading[path] {
+			return nil, fmt.Errorf("%s is included in a cycle", path)
+		}
+		r, err := l.open(path)
+		if err != nil {
+			continue
+		}
+		src, err := ioutil.ReadAll(r)
+		_ = r.Close()
+		if err != nil {
+			return nil, err
+		}
+		l.loading[path] = true
+		doc, err := l.parse(path, string(src))
+		delete(l.loading, path)
+		if err != nil {
+			return nil, err
+		}
+		l.docs[path] = doc
+		return doc, nil
+	}
+	return nil, fmt.Errorf("can't find %s in the import paths %v", filename, l.importPaths)
+}
+
+func (l *loader) parse(path, src string) (*document, error) {
+	p := &parser{doc: &document{
+		path:     path,
+		includes: make(map[string]*document),
+		defs:     make(map[string]interface{}),
+	}, tokens: tokenize(src)}
+	if err := p.parseDocument(l); err != nil {
+		return nil, fmt.Errorf("%s:%d: %w", path, p.line(), err)
+	}
+	if err := p.doc.resolve(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return p.doc, nil
+}
+
+// resolve resolves the named types of the document to their definitions.
+func (d *document) resolve() error {
+	for _, t := range d.types {
+		if err := d.resolveType(t); err != nil {
+			return err
+		}
+	}
+	for _, s := range d.services {
+		if s.extends == "" {
+			continue
+		}
+		doc, name := d.lookup(s.extends)
+		if doc != nil {
+			for _, parent := range doc.services {
+				if parent.name == name {
+					s.parent = parent
+				}
+			}
+		}
+		if s.parent == nil {
+			return fmt.Errorf("unknown service %q extended by %s", s.extends, s.name)
+		}
+	}
+	return nil
+}
+
+func (d *document) resolveType(t *fieldType) error {
+	if t.name == "" || t.def != nil || t.enum != nil || t.id != typeStop {
+		return nil
+	}
+	if t.depth > maxTypedefDepth {
+		return fmt.Errorf("the typedef %q is recursive", t.name)
+	}
+	doc, name := d.lookup(t.name)
+	var def interface{}
+	if doc != nil {
+		def = doc.defs[name]
+	}
+	switch def := def.(type) {
+	case *structDef:
+		t.id, t.def = typeStruct, def
+	case *enumDef:
+		t.id, t.enum = typeI32, def
+	case *fieldType:
+		def.depth = t.depth + 1
+		if err := doc.resolveType(def); err != nil {
+			return err
+		}
+		name := t.name
+		*t = *def
+		if t.name == "" {
+			t.name = name
+		}
+	default:
+		return fmt.Errorf("unknown type %q", t.name)
+	}
+	return nil
+}
+
+// lookup returns the document of a name, which is prefixed with the file name of an included
+// document if it's defined there, and the name without the prefix.
+func (d *document) lookup(name string) (*document, string) {
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		if doc, ok := d.includes[name[:i]]; ok {
+			return doc, name[i+1:]
+		}
+	}
+	return d, name
+}
+
+type token struct {
+	text string
+	// str is true if the token is a string literal, whose text is unquoted.
+	str  bool
+	line int
+}
+
+// tokenize splits an IDL in identifiers, which include the dots of the qualified names, numbers,
+// string literals and punctuation, without the comments.
+func tokenize(src string) []token {
+	var tokens []token
+	line := 1
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '#' || strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				end = len(src) - i - 4
+			}
+			line += strings.Count(src[i:i+end+4], "\n")
+			i += end + 4
+		case c == '"' || c == '\'':
+			j := i + 1
+			var sb strings.Builder
+			for ; j < len(src) && src[j] != c; j++ {
+				if src[j] == '\\' && j+1 < len(src) {
+					j++
+				}
+				sb.WriteByte(src[j])
+			}
+			tokens = append(tokens, token{text: sb.String(), str: true, line: line})
+			line += strings.Count(src[i:j], "\n")
+			i = j + 1
+		case isIdentChar(rune(c)) || ((c == '-' || c == '+') && i+1 < len(src) && unicode.IsDigit(rune(src[i+1]))):
+			j := i + 1
+			for j < len(src) && (isIdentChar(rune(src[j])) ||
+				((src[j] == '-' || src[j] == '+') && (src[j-1] == 'e' || src[j-1] == 'E') && unicode.IsDigit(rune(src[i])))) {
+				j++
+			}
+			tokens = append(tokens, token{text: src[i:j], line: line})
+			i = j
+		default:
+			tokens = append(tokens, token{text: string(c), line: line})
+			i++
+		}
+	}
+	return tokens
+}
+
+func isIdentChar(r rune) bool {
+	return r == '_' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+type parser struct {
+	doc    *document
+	tokens []token
+	pos    int
+}
+
+var errUnexpectedEnd = errors.New("unexpected end of the file")
+
+// line returns the line of the last token, which is the one with the error.
+func (p *parser) line() int {
+	if p.pos > 0 {
+		return p.tokens[p.pos-1].line
+	}
+	return 1
+}
+
+func (p *parser) peek() string {
+	if p.pos < len(p.tokens) && !p.tokens[p.pos].str {
+		return p.tokens[p.pos].text
+	}
+	return ""
+}
+
+func (p *parser) next() (token, error) {
+	if p.pos >= len(p.tokens) {
+		return token{}, errUnexpectedEnd
+	}
+	p.pos++
+	return p.tokens[p.pos-1], nil
+}
+
+func (p *parser) accept(text string) bool {
+	if p.peek() == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(text string) error {
+	t, err := p.next()
+	if err != nil {
+		return err
+	}
+	if t.str || t.text != text {
+		return fmt.Errorf("expected %q instead of %q", text, t.text)
+	}
+	return nil
+}
+
+func (p *parser) ident() (string, error) {
+	t, err := p.next()
+	if err != nil {
+		return "", err
+	}
+	if t.str || !unicode.IsLetter(rune(t.text[0])) && t.text[0] != '_' {
+		return "", fmt.Errorf("expected an identifier instead of %q", t.text)
+	}
+	return t.text, nil
+}
+
+func (p *parser) literal() (string, error) {
+	t, err := p.next()
+	if err != nil {
+		return "", err
+	}
+	if !t.str {
+		return "", fmt.Errorf("expected a string literal instead of %q", t.text)
+	}
+	return t.text, nil
+}
+
+func (p *parser) intConst() (int64, error) {
+	t, err := p.next()
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(t.text, 0, 64)
+	if t.str || err != nil {
+		return 0, fmt.Errorf("expected an integer instead of %q", t.text)
+	}
+	return n, nil
+}
+
+// skipSeparator skips the optional separator of the definitions, fields and functions.
+func (p *parser) skipSeparator() {
+	if !p.accept(",") {
+		p.accept(";")
+	}
+}
+
+// skipAnnotations skips the annotations in parentheses, which don't change the encoding.
+func (p *parser) skipAnnotations() error {
+	if !p.accept("(") {
+		return nil
+	}
+	for !p.accept(")") {
+		if _, err := p.next(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *parser) parseDocument(l *loader) error {
+	for p.pos < len(p.tokens) {
+		keyword, err := p.ident()
+		if err != nil {
+			return err
+		}
+		switch keyword {
+		case "include":
+			err = p.parseInclude(l)
+		case "cpp_include":
+			_, err = p.literal()
+		case "namespace":
+			if _, err = p.next(); err == nil {
+				_, err = p.next()
+			}
+		case "const":
+			err = p.parseConst()
+		case "typedef":
+			err = p.parseTypedef()
+		case "enum":
+			err = p.parseEnum()
+		case "struct", "union", "exception":
+			err = p.parseStruct(keyword == "union")
+		case "service":
+			err = p.parseService()
+		default:
+			return fmt.Errorf("unexpected %q", keyword)
+		}
+		if err != nil {
+			return err
+		}
+		if err = p.skipAnnotations(); err != nil {
+			return err
+		}
+		p.skipSeparator()
+	}
+	return nil
+}
+
+func (p *parser) parseInclude(l *loader) error {
+	filename, err := p.literal()
+	if err != nil {
+		return err
+	}
+	doc, err := l.load(filename, filepath.Dir(p.doc.path))
+	if err != nil {
+		return err
+	}
+	name := filepath.Base(filename)
+	p.doc.includes[strings.TrimSuffix(name, filepath.Ext(name))] = doc
+	return nil
+}
+
+func (p *parser) define(name string, def interface{}) error {
+	if _, ok := p.doc.defs[name]; ok {
+		return fmt.Errorf("%q is already defined", name)
+	}
+	p.doc.defs[name] = def
+	return nil
+}
+
+// parseConst parses a constant, whose value isn't used, as the default values aren't sent.
+func (p *parser) parseConst() error {
+	if _, err := p.parseType(); err != nil {
+		return err
+	}
+	if _, err := p.ident(); err != nil {
+		return err
+	}
+	if err := p.expect("="); err != nil {
+		return err
+	}
+	return p.skipConstValue()
+}
+
+func (p *parser) skipConstValue() error {
+	t, err := p.next()
+	if err != nil {
+		return err
+	}
+	if t.str {
+		return nil
+	}
+	var end string
+	switch t.text {
+	case "[":
+		end = "]"
+	case "{":
+		end = "}"
+	default:
+		return nil
+	}
+	for !p.accept(end) {
+		if err := p.skipConstValue(); err != nil {
+			return err
+		}
+		if p.accept(":") {
+			if err := p.skipConstValue(); err != nil {
+				return err
+			}
+		}
+		p.skipSeparator()
+	}
+	return nil
+}
+
+func (p *parser) parseTypedef() error {
+	t, err := p.parseType()
+	if err != nil {
+		return err
+	}
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	return p.define(name, t)
+}
+
+func (p *parser) parseEnum() error {
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	e := &enumDef{name: name, values: make(map[string]int32), names: make(map[int32]string)}
+	if err = p.expect("{"); err != nil {
+		return err
+	}
+	next := int64(0)
+	for !p.accept("}") {
+		value, err := p.ident()
+		if err != nil {
+			return err
+		}
+		if p.accept("=") {
+			if next, err = p.intConst(); err != nil {
+				return err
+			}
+		}
+		if _, ok := e.values[value]; ok {
+			return fmt.Errorf("duplicate value %q in the enum %s", value, name)
+		}
+		e.values[value] = int32(next)
+		if _, ok := e.names[int32(next)]; !ok {
+			e.names[int32(next)] = value
+		}
+		next++
+		if err = p.skipAnnotations(); err != nil {
+			return err
+		}
+		p.skipSeparator()
+	}
+	return p.define(name, e)
+}
+
+func (p *parser) parseStruct(union bool) error {
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	p.accept("xsd_all")
+	def := newStructDef(name)
+	def.union = union
+	if err = p.expect("{"); err != nil {
+		return err
+	}
+	if err = p.parseFields(def, "}"); err != nil {
+		return err
+	}
+	return p.define(name, def)
+}
+
+// parseFields parses the fields of a struct, or the args of a function, until the end token.
+func (p *parser) parseFields(def *structDef, end string) error {
+	// the fields without an ID get negative IDs, like with the Thrift compiler
+	implicitID := int16(-1)
+	for !p.accept(end) {
+		f := &field{}
+		if _, err := strconv.ParseInt(p.peek(), 0, 16); err == nil && p.pos+1 < len(p.tokens) &&
+			p.tokens[p.pos+1].text == ":" {
+			id, _ := p.intConst()
+			f.id = int16(id)
+			p.pos++
+		} else {
+			f.id = implicitID
+			implicitID--
+		}
+		switch {
+		case p.accept("required"):
+			f.required = true
+		case p.accept("optional"):
+		}
+		var err error
+		if f.typ, err = p.parseType(); err != nil {
+			return err
+		}
+		if f.name, err = p.ident(); err != nil {
+			return err
+		}
+		if p.accept("=") {
+			if err = p.skipConstValue(); err != nil {
+				return err
+			}
+		}
+		p.accept("xsd_optional")
+		p.accept("xsd_nillable")
+		if err = p.skipAnnotations(); err != nil {
+			return err
+		}
+		p.skipSeparator()
+		if err = def.add(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *parser) parseType() (*fieldType, error) {
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	var t *fieldType
+	switch name {
+	case "map":
+		t = &fieldType{id: typeMap}
+		if err = p.skipAnnotations(); err != nil {
+			return nil, err
+		}
+		if err = p.expect("<"); err != nil {
+			return nil, err
+		}
+		if t.key, err = p.parseType(); err != nil {
+			return nil, err
+		}
+		if err = p.expect(","); err != nil {
+			return nil, err
+		}
+		if t.elem, err = p.parseType(); err != nil {
+			return nil, err
+		}
+		err = p.expect(">")
+	case "list", "set":
+		t = &fieldType{id: typeList}
+		if name == "set" {
+			t.id = typeSet
+		}
+		if err = p.expect("<"); err != nil {
+			return nil, err
+		}
+		if t.elem, err = p.parseType(); err != nil {
+			return nil, err
+		}
+		err = p.expect(">")
+	default:
+		if id, ok := baseTypes[name]; ok {
+			t = &fieldType{id: id, binary: name == "binary"}
+		} else {
+			t = &fieldType{name: name}
+			p.doc.types = append(p.doc.types, t)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t, p.skipAnnotations()
+}
+
+func (p *parser) parseService() error {
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	s := &serviceDef{name: name}
+	if p.accept("extends") {
+		if s.extends, err = p.ident(); err != nil {
+			return err
+		}
+	}
+	if err = p.expect("{"); err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	for !p.accept("}") {
+		fn, err := p.parseFunction(name)
+		if err != nil {
+			return err
+		}
+		if seen[fn.name] {
+			return fmt.Errorf("duplicate function %q in the service %s", fn.name, name)
+		}
+		seen[fn.name] = true
+		s.functions = append(s.functions, fn)
+	}
+	if err = p.define(name, s); err != nil {
+		return err
+	}
+	p.doc.services = append(p.doc.services, s)
+	return nil
+}
+
+func (p *parser) parseFunction(service string) (*function, error) {
+	fn := &function{oneway: p.accept("oneway")}
+	var err error
+	if !p.accept("void") {
+		if fn.returns, err = p.parseType(); err != nil {
+			return nil, err
+		}
+	}
+	if fn.name, err = p.ident(); err != nil {
+		return nil, err
+	}
+	fn.args = newStructDef(service + "." + fn.name + " args")
+	fn.result = newStructDef(service + "." + fn.name + " result")
+	if err = p.expect("("); err != nil {
+		return nil, err
+	}
+	if err = p.parseFields(fn.args, ")"); err != nil {
+		return nil, err
+	}
+	if fn.returns != nil {
+		_ = fn.result.add(&field{id: 0, name: "success", typ: fn.returns})
+	}
+	if p.accept("throws") {
+		if err = p.expect("("); err != nil {
+			return nil, err
+		}
+		if err = p.parseFields(fn.result, ")"); err != nil {
+			return nil, err
+		}
+	}
+	if fn.oneway && (fn.returns != nil || len(fn.result.fields) > 0) {
+		return nil, fmt.Errorf("the oneway function %q can't return a value or throw exceptions", fn.name)
+	}
+	if err = p.skipAnnotations(); err != nil {
+		return nil, err
+	}
+	p.skipSeparator()
+	return fn, nil
+}
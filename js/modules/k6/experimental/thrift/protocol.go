@@ -0,0 +1,711 @@
+package thrift
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// The types of the messages.
+const (
+	messageCall      byte = 1
+	messageReply     byte = 2
+	messageException byte = 3
+	messageOneway    byte = 4
+)
+
+const (
+	binaryVersion1    = 0x80010000
+	binaryVersionMask = 0xffff0000
+
+	compactProtocolID = 0x82
+	compactVersion    = 1
+
+	// maxMessageSize limits the frames, the strings and the collections of the server, so that a
+	// corrupted length doesn't allocate them.
+	maxMessageSize = 64 << 20
+)
+
+var errInvalidMessage = errors.New("invalid Thrift message")
+
+type reader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// encoder appends the values of a protocol to a message.
+type encoder interface {
+	writeMessageBegin(name string, typ byte, seq int32)
+	writeStructBegin()
+	writeStructEnd()
+	writeFieldBegin(typ byte, id int16)
+	writeFieldStop()
+	writeMapBegin(key, elem byte, size int)
+	writeListBegin(elem byte, size int)
+	writeBool(v bool)
+	writeByte(v int8)
+	writeI16(v int16)
+	writeI32(v int32)
+	writeI64(v int64)
+	writeDouble(v float64)
+	writeBinary(v []byte)
+	bytes() []byte
+}
+
+// decoder reads the values of a protocol from a message.
+type decoder interface {
+	readMessageBegin() (name string, typ byte, seq int32, err error)
+	readStructBegin()
+	readStructEnd()
+	readFieldBegin() (typ byte, id int16, err error)
+	readMapBegin() (key, elem byte, size int, err error)
+	readListBegin() (elem byte, size int, err error)
+	readBool() (bool, error)
+	readByte() (int8, error)
+	readI16() (int16, error)
+	readI32() (int32, error)
+	readI64() (int64, error)
+	readDouble() (float64, error)
+	readBinary() ([]byte, error)
+}
+
+func newEncoder(protocol string) encoder {
+	if protocol == "compact" {
+		return &compactEncoder{}
+	}
+	return &binaryEncoder{}
+}
+
+func newDecoder(protocol string, r reader) decoder {
+	if protocol == "compact" {
+		return &compactDecoder{r: r}
+	}
+	return &binaryDecoder{r: r}
+}
+
+// skip skips a value of the given type, like the fields that aren't in the IDL.
+func skip(d decoder, typ byte, depth int) error {
+	if depth > maxDepth {
+		return errMaxDepth
+	}
+	var err error
+	switch typ {
+	case typeBool:
+		_, err = d.readBool()
+	case typeByte:
+		_, err = d.readByte()
+	case typeI16:
+		_, err = d.readI16()
+	case typeI32:
+		_, err = d.readI32()
+	case typeI64:
+		_, err = d.readI64()
+	case typeDouble:
+		_, err = d.readDouble()
+	case typeString:
+		_, err = d.readBinary()
+	case typeStruct:
+		d.readStructBegin()
+		for {
+			ft, _, err := d.readFieldBegin()
+			if err != nil {
+				return err
+			}
+			if ft == typeStop {
+				break
+			}
+			if err = skip(d, ft, depth+1); err != nil {
+				return err
+			}
+		}
+		d.readStructEnd()
+	case typeMap:
+		key, elem, size, err := d.readMapBegin()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < size; i++ {
+			if err = skip(d, key, depth+1); err != nil {
+				return err
+			}
+			if err = skip(d, elem, depth+1); err != nil {
+				return err
+			}
+		}
+	case typeSet, typeList:
+		elem, size, err := d.readListBegin()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < size; i++ {
+			if err = skip(d, elem, depth+1); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("%w: unknown type %d", errInvalidMessage, typ)
+	}
+	return err
+}
+
+func checkSize(size int64) (int, error) {
+	if size < 0 || size > maxMessageSize {
+		return 0, fmt.Errorf("%w: invalid size %d", errInvalidMessage, size)
+	}
+	return int(size), nil
+}
+
+func readFull(r io.Reader, size int) ([]byte, error) {
+	b := make([]byte, size)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// binaryEncoder encodes the strict binary protocol, with the version in the messages.
+type binaryEncoder struct {
+	buf []byte
+}
+
+func (e *binaryEncoder) writeMessageBegin(name string, typ byte, seq int32) {
+	e.writeI32(int32(binaryVersion1 | uint32(typ)))
+	e.writeBinary([]byte(name))
+	e.writeI32(seq)
+}
+
+func (e *binaryEncoder) writeStructBegin() {}
+func (e *binaryEncoder) writeStructEnd()   {}
+
+func (e *binaryEncoder) writeFieldBegin(typ byte, id int16) {
+	e.buf = append(e.buf, typ)
+	e.writeI16(id)
+}
+
+func (e *binaryEncoder) writeFieldStop() { e.buf = append(e.buf, typeStop) }
+
+func (e *binaryEncoder) writeMapBegin(key, elem byte, size int) {
+	e.buf = append(e.buf, key, elem)
+	e.writeI32(int32(size))
+}
+
+func (e *binaryEncoder) writeListBegin(elem byte, size int) {
+	e.buf = append(e.buf, elem)
+	e.writeI32(int32(size))
+}
+
+func (e *binaryEncoder) writeBool(v bool) {
+	if v {
+		e.buf = append(e.buf, 1)
+	} else {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+func (e *binaryEncoder) writeByte(v int8)      { e.buf = append(e.buf, byte(v)) }
+func (e *binaryEncoder) writeI16(v int16)      { e.buf = append(e.buf, byte(v>>8), byte(v)) }
+func (e *binaryEncoder) writeI32(v int32)      { e.buf = appendUint32(e.buf, uint32(v)) }
+func (e *binaryEncoder) writeI64(v int64)      { e.buf = appendUint64(e.buf, uint64(v)) }
+func (e *binaryEncoder) writeDouble(v float64) { e.writeI64(int64(math.Float64bits(v))) }
+
+func (e *binaryEncoder) writeBinary(v []byte) {
+	e.writeI32(int32(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+func (e *binaryEncoder) bytes() []byte { return e.buf }
+
+type binaryDecoder struct {
+	r reader
+}
+
+func (d *binaryDecoder) readMessageBegin() (string, byte, int32, error) {
+	size, err := d.readI32()
+	if err != nil {
+		return "", 0, 0, err
+	}
+	var name []byte
+	var typ byte
+	if size < 0 {
+		if uint32(size)&binaryVersionMask != binaryVersion1 {
+			return "", 0, 0, fmt.Errorf("%w: unsupported version %#x", errInvalidMessage, uint32(size))
+		}
+		typ = byte(size)
+		if name, err = d.readBinary(); err != nil {
+			return "", 0, 0, err
+		}
+	} else {
+		// the old servers don't send the version, and send the type after the name
+		n, err := checkSize(int64(size))
+		if err != nil {
+			return "", 0, 0, err
+		}
+		if name, err = readFull(d.r, n); err != nil {
+			return "", 0, 0, err
+		}
+		if typ, err = d.r.ReadByte(); err != nil {
+			return "", 0, 0, err
+		}
+	}
+	seq, err := d.readI32()
+	return string(name), typ, seq, err
+}
+
+func (d *binaryDecoder) readStructBegin() {}
+func (d *binaryDecoder) readStructEnd()   {}
+
+func (d *binaryDecoder) readFieldBegin() (byte, int16, error) {
+	typ, err := d.r.ReadByte()
+	if err != nil || typ == typeStop {
+		return typ, 0, err
+	}
+	id, err := d.readI16()
+	return typ, id, err
+}
+
+func (d *binaryDecoder) readMapBegin() (byte, byte, int, error) {
+	b, err := readFull(d.r, 2)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	size, err := d.readI32()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	n, err := checkSize(int64(size))
+	return b[0], b[1], n, err
+}
+
+func (d *binaryDecoder) readListBegin() (byte, int, error) {
+	elem, err := d.r.ReadByte()
+	if err != nil {
+		return 0, 0, err
+	}
+	size, err := d.readI32()
+	if err != nil {
+		return 0, 0, err
+	}
+	n, err := checkSize(int64(size))
+	return elem, n, err
+}
+
+func (d *binaryDecoder) readBool() (bool, error) {
+	b, err := d.r.ReadByte()
+	return b != 0, err
+}
+
+func (d *binaryDecoder) readByte() (int8, error) {
+	b, err := d.r.ReadByte()
+	return int8(b), err
+}
+
+func (d *binaryDecoder) readI16() (int16, error) {
+	b, err := readFull(d.r, 2)
+	if err != nil {
+		return 0, err
+	}
+	return int16(binary.BigEndian.Uint16(b)), nil
+}
+
+func (d *binaryDecoder) readI32() (int32, error) {
+	b, err := readFull(d.r, 4)
+	if err != nil {
+		return 0, err
+	}
+	return int32(binary.BigEndian.Uint32(b)), nil
+}
+
+func (d *binaryDecoder) readI64() (int64, error) {
+	b, err := readFull(d.r, 8)
+	if err != nil {
+		return 0, err
+	}
+	return int64(binary.BigEndian.Uint64(b)), nil
+}
+
+func (d *binaryDecoder) readDouble() (float64, error) {
+	v, err := d.readI64()
+	return math.Float64frombits(uint64(v)), err
+}
+
+func (d *binaryDecoder) readBinary() ([]byte, error) {
+	size, err := d.readI32()
+	if err != nil {
+		return nil, err
+	}
+	n, err := checkSize(int64(size))
+	if err != nil {
+		return nil, err
+	}
+	return readFull(d.r, n)
+}
+
+// The types of the compact protocol.
+const (
+	compactBoolTrue  byte = 1
+	compactBoolFalse byte = 2
+	compactByte      byte = 3
+	compactI16       byte = 4
+	compactI32       byte = 5
+	compactI64       byte = 6
+	compactDouble    byte = 7
+	compactBinary    byte = 8
+	compactList      byte = 9
+	compactSet       byte = 10
+	compactMap       byte = 11
+	compactStruct    byte = 12
+)
+
+//nolint:gochecknoglobals
+var (
+	toCompactType = map[byte]byte{
+		typeBool:   compactBoolTrue,
+		typeByte:   compactByte,
+		typeI16:    compactI16,
+		typeI32:    compactI32,
+		typeI64:    compactI64,
+		typeDouble: compactDouble,
+		typeString: compactBinary,
+		typeList:   compactList,
+		typeSet:    compactSet,
+		typeMap:    compactMap,
+		typeStruct: compactStruct,
+	}
+	fromCompactType = map[byte]byte{
+		compactBoolTrue:  typeBool,
+		compactBoolFalse: typeBool,
+		compactByte:      typeByte,
+		compactI16:       typeI16,
+		compactI32:       typeI32,
+		compactI64:       typeI64,
+		compactDouble:    typeDouble,
+		compactBinary:    typeString,
+		compactList:      typeList,
+		compactSet:       typeSet,
+		compactMap:       typeMap,
+		compactStruct:    typeStruct,
+	}
+)
+
+// compactEncoder encodes the compact protocol, whose field headers have the delta from the ID
+// of the previous field of the struct, and the value of the bool fields.
+type compactEncoder struct {
+	buf         []byte
+	lastFieldID int16
+	lastFields  []int16
+	// boolField is the ID of the bool field whose header is written with its value, or nil.
+	boolField *int16
+}
+
+func (e *compactEncoder) writeMessageBegin(name string, typ byte, seq int32) {
+	e.buf = append(e.buf, compactProtocolID, compactVersion|typ<<5)
+	e.buf = appendUvarint(e.buf, uint64(uint32(seq)))
+	e.writeBinary([]byte(name))
+}
+
+func (e *compactEncoder) writeStructBegin() {
+	e.lastFields = append(e.lastFields, e.lastFieldID)
+	e.lastFieldID = 0
+}
+
+func (e *compactEncoder) writeStructEnd() {
+	e.lastFieldID = e.lastFields[len(e.lastFields)-1]
+	e.lastFields = e.lastFields[:len(e.lastFields)-1]
+}
+
+func (e *compactEncoder) writeFieldBegin(typ byte, id int16) {
+	if typ == typeBool {
+		e.boolField = &id
+		return
+	}
+	e.writeFieldHeader(toCompactType[typ], id)
+}
+
+func (e *compactEncoder) writeFieldHeader(typ byte, id int16) {
+	if delta := id - e.lastFieldID; delta > 0 && delta <= 15 {
+		e.buf = append(e.buf, byte(delta)<<4|typ)
+	} else {
+		e.buf = append(e.buf, typ)
+		e.writeI16(id)
+	}
+	e.lastFieldID = id
+}
+
+func (e *compactEncoder) writeFieldStop() { e.buf = append(e.buf, typeStop) }
+
+func (e *compactEncoder) writeMapBegin(key, elem byte, size int) {
+	if size == 0 {
+		e.buf = append(e.buf, 0)
+		return
+	}
+	e.buf = appendUvarint(e.buf, uint64(size))
+	e.buf = append(e.buf, toCompactType[key]<<4|toCompactType[elem])
+}
+
+func (e *compactEncoder) writeListBegin(elem byte, size int) {
+	if size < 15 {
+		e.buf = append(e.buf, byte(size)<<4|toCompactType[elem])
+		return
+	}
+	e.buf = append(e.buf, 0xf0|toCompactType[elem])
+	e.buf = appendUvarint(e.buf, uint64(size))
+}
+
+func (e *compactEncoder) writeBool(v bool) {
+	typ := compactBoolFalse
+	if v {
+		typ = compactBoolTrue
+	}
+	if e.boolField != nil {
+		e.writeFieldHeader(typ, *e.boolField)
+		e.boolField = nil
+		return
+	}
+	e.buf = append(e.buf, typ)
+}
+
+func (e *compactEncoder) writeByte(v int8) { e.buf = append(e.buf, byte(v)) }
+func (e *compactEncoder) writeI16(v int16) { e.buf = appendVarint(e.buf, int64(v)) }
+func (e *compactEncoder) writeI32(v int32) { e.buf = appendVarint(e.buf, int64(v)) }
+func (e *compactEncoder) writeI64(v int64) { e.buf = appendVarint(e.buf, v) }
+
+func (e *compactEncoder) writeDouble(v float64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], math.Float64bits(v))
+	e.buf = append(e.buf, b[:]...)
+}
+
+func (e *compactEncoder) writeBinary(v []byte) {
+	e.buf = appendUvarint(e.buf, uint64(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+func (e *compactEncoder) bytes() []byte { return e.buf }
+
+type compactDecoder struct {
+	r           reader
+	lastFieldID int16
+	lastFields  []int16
+	// boolValue is the value of the last bool field, which is in its header.
+	boolValue *bool
+}
+
+func (d *compactDecoder) readMessageBegin() (string, byte, int32, error) {
+	b, err := readFull(d.r, 2)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	if b[0] != compactProtocolID || b[1]&0x1f != compactVersion {
+		return "", 0, 0, fmt.Errorf("%w: unsupported compact protocol %#x %#x", errInvalidMessage, b[0], b[1])
+	}
+	seq, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	name, err := d.readBinary()
+	return string(name), b[1] >> 5, int32(seq), err
+}
+
+func (d *compactDecoder) readStructBegin() {
+	d.lastFields = append(d.lastFields, d.lastFieldID)
+	d.lastFieldID = 0
+}
+
+func (d *compactDecoder) readStructEnd() {
+	d.lastFieldID = d.lastFields[len(d.lastFields)-1]
+	d.lastFields = d.lastFields[:len(d.lastFields)-1]
+}
+
+func (d *compactDecoder) readFieldBegin() (byte, int16, error) {
+	b, err := d.r.ReadByte()
+	if err != nil || b == typeStop {
+		return typeStop, 0, err
+	}
+	id := d.lastFieldID + int16(b>>4)
+	if b>>4 == 0 {
+		if id, err = d.readI16(); err != nil {
+			return 0, 0, err
+		}
+	}
+	d.lastFieldID = id
+	typ, ok := fromCompactType[b&0x0f]
+	if !ok {
+		return 0, 0, fmt.Errorf("%w: unknown type %d", errInvalidMessage, b&0x0f)
+	}
+	if typ == typeBool {
+		v := b&0x0f == compactBoolTrue
+		d.boolValue = &v
+	}
+	return typ, id, nil
+}
+
+func (d *compactDecoder) readMapBegin() (byte, byte, int, error) {
+	size, err := binary.ReadUvarint(d.r)
+	if err != nil || size == 0 {
+		return 0, 0, 0, err
+	}
+	n, err := checkSize(int64(size))
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	b, err := d.r.ReadByte()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	key, keyOK := fromCompactType[b>>4]
+	elem, elemOK := fromCompactType[b&0x0f]
+	if !keyOK || !elemOK {
+		return 0, 0, 0, fmt.Errorf("%w: unknown map types %#x", errInvalidMessage, b)
+	}
+	return key, elem, n, nil
+}
+
+func (d *compactDecoder) readListBegin() (byte, int, error) {
+	b, err := d.r.ReadByte()
+	if err != nil {
+		return 0, 0, err
+	}
+	elem, ok := fromCompactType[b&0x0f]
+	if !ok {
+		return 0, 0, fmt.Errorf("%w: unknown type %d", errInvalidMessage, b&0x0f)
+	}
+	size := int64(b >> 4)
+	if size == 15 {
+		n, err := binary.ReadUvarint(d.r)
+		if err != nil {
+			return 0, 0, err
+		}
+		size = int64(n)
+		if n > maxMessageSize {
+			size = -1
+		}
+	}
+	n, err := checkSize(size)
+	return elem, n, err
+}
+
+func (d *compactDecoder) readBool() (bool, error) {
+	if d.boolValue != nil {
+		v := *d.boolValue
+		d.boolValue = nil
+		return v, nil
+	}
+	b, err := d.r.ReadByte()
+	return b == compactBoolTrue, err
+}
+
+func (d *compactDecoder) readByte() (int8, error) {
+	b, err := d.r.ReadByte()
+	return int8(b), err
+}
+
+func (d *compactDecoder) readI16() (int16, error) {
+	v, err := binary.ReadVarint(d.r)
+	return int16(v), err
+}
+
+func (d *compactDecoder) readI32() (int32, error) {
+	v, err := binary.ReadVarint(d.r)
+	return int32(v), err
+}
+
+func (d *compactDecoder) readI64() (int64, error) {
+	return binary.ReadVarint(d.r)
+}
+
+func (d *compactDecoder) readDouble() (float64, error) {
+	b, err := readFull(d.r, 8)
+	if err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+}
+
+func (d *compactDecoder) readBinary() ([]byte, error) {
+	size, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return nil, err
+	}
+	if size > maxMessageSize {
+		return nil, fmt.Errorf("%w: invalid size %d", errInvalidMessage, size)
+	}
+	return readFull(d.r, int(size))
+}
+
+// transport sends and receives the messages over the connection.
+type transport interface {
+	writeMessage(b []byte) error
+	// nextMessage returns the reader of the next message.
+	nextMessage() (reader, error)
+}
+
+// framedTransport prefixes the messages with their size.
+type framedTransport struct {
+	w io.Writer
+	r io.Reader
+}
+
+func (t *framedTransport) writeMessage(b []byte) error {
+	_, err := t.w.Write(append(appendUint32(make([]byte, 0, 4+len(b)), uint32(len(b))), b...))
+	return err
+}
+
+func (t *framedTransport) nextMessage() (reader, error) {
+	header, err := readFull(t.r, 4)
+	if err != nil {
+		return nil, err
+	}
+	size, err := checkSize(int64(binary.BigEndian.Uint32(header)))
+	if err != nil {
+		return nil, err
+	}
+	frame, err := readFull(t.r, size)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(frame), nil
+}
+
+// bufferedTransport sends the messages as they are, which are read from the stream.
+type bufferedTransport struct {
+	w io.Writer
+	r *bufio.Reader
+}
+
+func (t *bufferedTransport) writeMessage(b []byte) error {
+	_, err := t.w.Write(b)
+	return err
+}
+
+func (t *bufferedTransport) nextMessage() (reader, error) {
+	return t.r, nil
+}
+
+func newTransport(name string, rw io.ReadWriter) transport {
+	if name == "framed" {
+		return &framedTransport{w: rw, r: rw}
+	}
+	return &bufferedTransport{w: rw, r: bufio.NewReader(rw)}
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(v>>32)), uint32(v))
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+func appendVarint(b []byte, v int64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutVarint(buf[:], v)]...)
+}
@@ -0,0 +1,520 @@
+// Package thrift implements the k6/experimental/thrift module, a client of Thrift services with
+// the binary and the compact protocols, whose IDL files are loaded like the proto files of gRPC.
+package thrift
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/lib/netext"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/stats"
+)
+
+type (
+	// RootModule is the global module instance that will create module
+	// instances for each VU.
+	RootModule struct{}
+
+	// ModuleInstance represents an instance of the thrift module for every VU.
+	ModuleInstance struct {
+		vu      modules.VU
+		exports map[string]interface{}
+	}
+)
+
+var (
+	_ modules.Module   = &RootModule{}
+	_ modules.Instance = &ModuleInstance{}
+)
+
+// New returns a pointer to a new RootModule instance.
+func New() *RootModule {
+	return &RootModule{}
+}
+
+// NewModuleInstance implements the modules.Module interface to return
+// a new instance for each VU.
+func (*RootModule) NewModuleInstance(vu modules.VU) modules.Instance {
+	mi := &ModuleInstance{
+		vu:      vu,
+		exports: make(map[string]interface{}),
+	}
+	mi.exports["Client"] = mi.NewClient
+	mi.defineConstants()
+	return mi
+}
+
+// NewClient is the JS constructor for the thrift Client.
+func (mi *ModuleInstance) NewClient(call goja.ConstructorCall) *goja.Object {
+	rt := mi.vu.Runtime()
+	return rt.ToValue(&Client{vu: mi.vu}).ToObject(rt)
+}
+
+// The types of the application exceptions, the errors of the Thrift servers outside of the IDL.
+const (
+	ApplicationErrorUnknown               = 0
+	ApplicationErrorUnknownMethod         = 1
+	ApplicationErrorInvalidMessageType    = 2
+	ApplicationErrorWrongMethodName       = 3
+	ApplicationErrorBadSequenceID         = 4
+	ApplicationErrorMissingResult         = 5
+	ApplicationErrorInternalError         = 6
+	ApplicationErrorProtocolError         = 7
+	ApplicationErrorInvalidTransform      = 8
+	ApplicationErrorInvalidProtocol       = 9
+	ApplicationErrorUnsupportedClientType = 10
+)
+
+// defineConstants defines the constant variables of the module.
+func (mi *ModuleInstance) defineConstants() {
+	mi.exports["ApplicationErrorUnknown"] = ApplicationErrorUnknown
+	mi.exports["ApplicationErrorUnknownMethod"] = ApplicationErrorUnknownMethod
+	mi.exports["ApplicationErrorInvalidMessageType"] = ApplicationErrorInvalidMessageType
+	mi.exports["ApplicationErrorWrongMethodName"] = ApplicationErrorWrongMethodName
+	mi.exports["ApplicationErrorBadSequenceID"] = ApplicationErrorBadSequenceID
+	mi.exports["ApplicationErrorMissingResult"] = ApplicationErrorMissingResult
+	mi.exports["ApplicationErrorInternalError"] = ApplicationErrorInternalError
+	mi.exports["ApplicationErrorProtocolError"] = ApplicationErrorProtocolError
+	mi.exports["ApplicationErrorInvalidTransform"] = ApplicationErrorInvalidTransform
+	mi.exports["ApplicationErrorInvalidProtocol"] = ApplicationErrorInvalidProtocol
+	mi.exports["ApplicationErrorUnsupportedClientType"] = ApplicationErrorUnsupportedClientType
+}
+
+// Exports returns the exports of the thrift module.
+func (mi *ModuleInstance) Exports() modules.Exports {
+	return modules.Exports{
+		Named: mi.exports,
+	}
+}
+
+const defaultTimeout = time.Minute
+
+//nolint:gochecknoglobals,lll
+var (
+	errInvokeInInitContext  = common.NewInitContextError("invoking Thrift methods in the init context is not supported")
+	errConnectInInitContext = common.NewInitContextError("connecting to a Thrift server in the init context is not supported")
+)
+
+// applicationExceptionDef is the struct of the application exceptions.
+//
+//nolint:gochecknoglobals
+var applicationExceptionDef = func() *structDef {
+	def := newStructDef("TApplicationException")
+	_ = def.add(&field{id: 1, name: "message", typ: &fieldType{id: typeString}})
+	_ = def.add(&field{id: 2, name: "type", typ: &fieldType{id: typeI32}})
+	return def
+}()
+
+// Client is a client of the services of the loaded IDL files, with a connection to a server.
+type Client struct {
+	vu      modules.VU
+	methods map[string]*method
+
+	conn      net.Conn
+	transport transport
+	protocol  string
+	// multiplexed is true if the names of the methods are prefixed with their service, for the
+	// servers with a multiplexed processor.
+	multiplexed bool
+	target      string
+	seq         int32
+}
+
+type method struct {
+	service string
+	fn      *function
+}
+
+// MethodInfo holds information on the methods of the loaded IDL files.
+type MethodInfo struct {
+	Service    string
+	Name       string
+	FullMethod string
+	Oneway     bool
+}
+
+// Response is the response of a method.
+type Response struct {
+	// Message is the value that the method returned, which is null if it's void.
+	Message interface{}
+	// Exception is the exception of the IDL that the method threw.
+	Exception *Exception
+	// Error is the application exception of the server, like an unknown method.
+	Error *ApplicationError
+}
+
+// Exception is an exception of the IDL.
+type Exception struct {
+	// Name is the name of the exception in the IDL.
+	Name  string
+	Value map[string]interface{}
+}
+
+// ApplicationError is an application exception, with one of the ApplicationError types.
+type ApplicationError struct {
+	Type    int32
+	Message string
+}
+
+// Load parses the given IDL files and the files that they include, and makes the methods of their
+// services available to invoke.
+func (c *Client) Load(importPaths []string, filenames ...string) ([]MethodInfo, error) {
+	if c.vu.State() != nil {
+		return nil, errors.New("load must be called in the init context")
+	}
+	initEnv := c.vu.InitEnv()
+	if initEnv == nil {
+		return nil, errors.New("missing init environment")
+	}
+
+	// If no import paths are specified, use the current working directory
+	if len(importPaths) == 0 {
+		importPaths = append(importPaths, initEnv.CWD.Path)
+	}
+	l := &loader{
+		importPaths: importPaths,
+		open: func(filename string) (io.ReadCloser, error) {
+			return initEnv.FileSystems["file"].Open(initEnv.GetAbsFilePath(filename))
+		},
+		docs:    make(map[string]*document),
+		loading: make(map[string]bool),
+	}
+	var rtn []MethodInfo
+	if c.methods == nil {
+		// This allows us to call load() multiple times, without overwriting the
+		// previously loaded definitions.
+		c.methods = make(map[string]*method)
+	}
+	for _, filename := range filenames {
+		if _, err := l.load(filename, ""); err != nil {
+			return nil, err
+		}
+	}
+	for _, doc := range l.docs {
+		for _, s := range doc.services {
+			// the functions of the extended services are invoked with the name of this one
+			for parent, depth := s, 0; parent != nil && depth <= maxTypedefDepth; parent, depth = parent.parent, depth+1 {
+				for _, fn := range parent.functions {
+					name := s.name + "." + fn.name
+					c.methods[name] = &method{service: s.name, fn: fn}
+					rtn = append(rtn, MethodInfo{Service: s.name, Name: fn.name, FullMethod: name, Oneway: fn.oneway})
+				}
+			}
+		}
+	}
+	sort.Slice(rtn, func(i, j int) bool { return rtn[i].FullMethod < rtn[j].FullMethod })
+	return rtn, nil
+}
+
+type connectParams struct {
+	Protocol    string
+	Transport   string
+	TLS         bool
+	Multiplexed bool
+	Timeout     time.Duration
+}
+
+func parseConnectParams(raw map[string]interface{}) (connectParams, error) {
+	params := connectParams{
+		Protocol:  "binary",
+		Transport: "buffered",
+		Timeout:   defaultTimeout,
+	}
+	for k, v := range raw {
+		switch k {
+		case "protocol":
+			protocol, ok := v.(string)
+			if !ok || (protocol != "binary" && protocol != "compact") {
+				return params, fmt.Errorf("invalid protocol value: '%#v', it needs to be binary or compact", v)
+			}
+			params.Protocol = protocol
+		case "transport":
+			transport, ok := v.(string)
+			if !ok || (transport != "buffered" && transport != "framed") {
+				return params, fmt.Errorf("invalid transport value: '%#v', it needs to be buffered or framed", v)
+			}
+			params.Transport = transport
+		case "tls":
+			var ok bool
+			params.TLS, ok = v.(bool)
+			if !ok {
+				return params, fmt.Errorf("invalid tls value: '%#v', it needs to be boolean", v)
+			}
+		case "multiplexed":
+			var ok bool
+			params.Multiplexed, ok = v.(bool)
+			if !ok {
+				return params, fmt.Errorf("invalid multiplexed value: '%#v', it needs to be boolean", v)
+			}
+		case "timeout":
+			var err error
+			params.Timeout, err = types.GetDurationValue(v)
+			if err != nil {
+				return params, fmt.Errorf("invalid timeout value: %w", err)
+			}
+		default:
+			return params, fmt.Errorf("unknown connect param: %q", k)
+		}
+	}
+	return params, nil
+}
+
+// Connect connects to the Thrift server at the given address (host:port), with the protocol and
+// the transport in the params.
+func (c *Client) Connect(addr string, params map[string]interface{}) error {
+	state := c.vu.State()
+	if state == nil {
+		return errConnectInInitContext
+	}
+	p, err := parseConnectParams(params)
+	if err != nil {
+		return err
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid address %q: %w", addr, err)
+	}
+	if c.conn != nil {
+		_ = c.conn.Close()
+		c.conn = nil
+	}
+
+	ctx, cancel := context.WithTimeout(c.vu.Context(), p.Timeout)
+	defer cancel()
+	conn, err := state.Dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	if p.TLS {
+		tlsConfig := &tls.Config{} //nolint:gosec
+		if state.TLSConfig != nil {
+			tlsConfig = state.TLSConfig.Clone()
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = host
+		}
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err = tlsConn.HandshakeContext(ctx); err != nil {
+			_ = conn.Close()
+			return err
+		}
+		conn = tlsConn
+	}
+	c.conn, c.transport = conn, newTransport(p.Transport, conn)
+	c.protocol, c.multiplexed = p.Protocol, p.Multiplexed
+	c.target = "thrift://" + addr
+	return nil
+}
+
+type params struct {
+	Tags    map[string]string
+	Timeout time.Duration
+}
+
+func parseParams(raw map[string]interface{}) (params, error) {
+	p := params{
+		Timeout: defaultTimeout,
+	}
+	for k, v := range raw {
+		switch k {
+		case "tags":
+			p.Tags = make(map[string]string)
+			rawTags, ok := v.(map[string]interface{})
+			if !ok {
+				return p, errors.New("tags must be an object with key-value pairs")
+			}
+			for tk, tv := range rawTags {
+				p.Tags[tk] = fmt.Sprint(tv)
+			}
+		case "timeout":
+			var err error
+			p.Timeout, err = types.GetDurationValue(v)
+			if err != nil {
+				return p, fmt.Errorf("invalid timeout value: %w", err)
+			}
+		default:
+			return p, fmt.Errorf("unknown param: %q", k)
+		}
+	}
+	return p, nil
+}
+
+// Invoke calls a method, by its service and its name like Service.method, with an object of its
+// args by their names.
+func (c *Client) Invoke(name string, args goja.Value, params map[string]interface{}) (*Response, error) {
+	state := c.vu.State()
+	if state == nil {
+		return nil, errInvokeInInitContext
+	}
+	if c.conn == nil {
+		return nil, errors.New("no Thrift connection, you must call connect first")
+	}
+	m := c.methods[name]
+	if m == nil {
+		return nil, fmt.Errorf("method %q not found in the loaded IDL files", name)
+	}
+	p, err := parseParams(params)
+	if err != nil {
+		return nil, err
+	}
+	var argsValue interface{}
+	if args != nil && !goja.IsUndefined(args) && !goja.IsNull(args) {
+		argsValue = args.Export()
+	}
+
+	c.seq++
+	messageName, messageType := m.fn.name, messageCall
+	if c.multiplexed {
+		messageName = m.service + ":" + m.fn.name
+	}
+	if m.fn.oneway {
+		messageType = messageOneway
+	}
+	e := newEncoder(c.protocol)
+	e.writeMessageBegin(messageName, messageType, c.seq)
+	if err = encodeStruct(e, m.fn.args, argsValue, 0); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(c.vu.Context(), p.Timeout)
+	defer cancel()
+	stop := netext.CloseOnDone(ctx, c.conn)
+	start := time.Now()
+	resp, err := c.call(m.fn, e.bytes())
+	stop()
+	end := time.Now()
+	if err != nil {
+		// the connection is in the middle of a message, so it can't be used anymore
+		_ = c.conn.Close()
+		c.conn = nil
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("the Thrift call timed out after %s", p.Timeout)
+		}
+	}
+
+	tags := c.callTags(name, m, p)
+	if err == nil {
+		c.push(state.BuiltinMetrics.ThriftReqDuration, stats.D(end.Sub(start)), tags, end)
+	}
+	failed := err != nil || resp.Exception != nil || resp.Error != nil
+	c.push(state.BuiltinMetrics.ThriftReqFailed, stats.B(failed), tags, end)
+	return resp, err
+}
+
+// call sends a message and reads the reply, unless the function is oneway.
+func (c *Client) call(fn *function, message []byte) (*Response, error) {
+	if err := c.transport.writeMessage(message); err != nil {
+		return nil, err
+	}
+	resp := &Response{}
+	if fn.oneway {
+		return resp, nil
+	}
+	r, err := c.transport.nextMessage()
+	if err != nil {
+		return nil, err
+	}
+	rt := c.vu.Runtime()
+	d := newDecoder(c.protocol, r)
+	name, typ, seq, err := d.readMessageBegin()
+	if err != nil {
+		return nil, err
+	}
+	if seq != c.seq {
+		return nil, fmt.Errorf("%w: the sequence ID %d of the reply isn't %d", errInvalidMessage, seq, c.seq)
+	}
+	if typ == messageException {
+		obj, err := decodeStruct(rt, d, applicationExceptionDef, 0)
+		if err != nil {
+			return nil, err
+		}
+		resp.Error = &ApplicationError{}
+		resp.Error.Message, _ = obj["message"].(string)
+		resp.Error.Type, _ = obj["type"].(int32)
+		return resp, nil
+	}
+	if typ != messageReply || name != fn.name {
+		return nil, fmt.Errorf("%w: unexpected message %q of the type %d", errInvalidMessage, name, typ)
+	}
+	result, err := decodeStruct(rt, d, fn.result, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range fn.result.fields {
+		v, ok := result[f.name]
+		switch {
+		case !ok:
+		case f.id == 0:
+			resp.Message = v
+			return resp, nil
+		default:
+			resp.Exception = &Exception{Name: f.typ.String()}
+			resp.Exception.Value, _ = v.(map[string]interface{})
+			return resp, nil
+		}
+	}
+	if fn.returns != nil {
+		resp.Error = &ApplicationError{
+			Type:    ApplicationErrorMissingResult,
+			Message: fn.name + " failed: unknown result",
+		}
+	}
+	return resp, nil
+}
+
+// callTags returns the tags of the metrics of a call.
+func (c *Client) callTags(name string, m *method, p params) map[string]string {
+	state := c.vu.State()
+	tags := state.CloneTags()
+	for k, v := range p.Tags {
+		tags[k] = v
+	}
+	if state.Options.SystemTags.Has(stats.TagURL) {
+		tags["url"] = c.target
+	}
+	if state.Options.SystemTags.Has(stats.TagService) {
+		tags["service"] = m.service
+	}
+	if state.Options.SystemTags.Has(stats.TagMethod) {
+		tags["method"] = m.fn.name
+	}
+	// Only set the name system tag if the user didn't explicitly set it beforehand
+	if _, ok := tags["name"]; !ok && state.Options.SystemTags.Has(stats.TagName) {
+		tags["name"] = name
+	}
+	return tags
+}
+
+func (c *Client) push(metric *stats.Metric, value float64, tags map[string]string, t time.Time) {
+	sampleTags := make(map[string]string, len(tags))
+	for k, v := range tags {
+		sampleTags[k] = v
+	}
+	stats.PushIfNotDone(c.vu.Context(), c.vu.State().Samples, stats.Sample{
+		Metric: metric,
+		Tags:   stats.IntoSampleTags(&sampleTags),
+		Value:  value,
+		Time:   t,
+	})
+}
+
+// Close closes the connection to the server.
+func (c *Client) Close() error {
+	if c == nil || c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
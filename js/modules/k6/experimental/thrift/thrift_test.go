@@ -0,0 +1,447 @@
+package thrift
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/lib/testutils/httpmultibin"
+	"go.k6.io/k6/stats"
+)
+
+const sharedIDL = `
+namespace go shared
+namespace * shared
+
+# the exceptions and the types shared by the services
+exception NotFound {
+	1: string reason,
+	2: i64 id,
+}
+
+enum Color {
+	RED = 1,
+	GREEN,
+	BLUE = 10 (deprecated = "true"),
+}
+
+struct Point {
+	1: required double x;
+	2: required double y;
+}
+
+service Base {
+	string version()
+}
+`
+
+const calculatorIDL = `
+include "shared.thrift"
+
+typedef i64 ID
+typedef map<string, list<shared.Point>> Shapes
+
+union Value {
+	1: i64 number
+	2: string text
+}
+
+struct Item {
+	1: required ID id,
+	2: optional string name = "unnamed",
+	3: shared.Color color,
+	4: set<string> tags,
+	5: Shapes shapes,
+	6: binary payload,
+	7: bool active,
+	8: map<i32, Value> values,
+	9: byte level (annotation = "x"),
+	10: i16 rank,
+	20: bool archived,
+	21: double score,
+}
+
+const list<string> NAMES = ["a", "b"]
+const map<string, i32> LIMITS = {"items": 10, "tags": 5}
+
+/*
+ * The service of the tests.
+ */
+service Calculator extends shared.Base {
+	i32 add(1: i32 a, 2: i32 b),
+	void ping(),
+	Item echo(1: Item item),
+	Item get(1: ID id) throws (1: shared.NotFound notFound),
+	oneway void log(1: string line),
+	string slow(),
+	i32 crash(),
+	i32 missing(),
+}
+`
+
+func newTestFS(t *testing.T) afero.Fs {
+	t.Helper()
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/scripts/shared/shared.thrift", []byte(sharedIDL), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/scripts/idl/calculator.thrift", []byte(calculatorIDL), 0o644))
+	return fs
+}
+
+// newTestVU returns a VU in the init context, and its state to set once the IDL files are loaded.
+func newTestVU(t *testing.T, fs afero.Fs) (*modulestest.VU, *lib.State, chan stats.SampleContainer) {
+	t.Helper()
+	tb := httpmultibin.NewHTTPMultiBin(t)
+	vu, samples := modulestest.NewTestVU(t, tb, stats.TagURL, stats.TagService, stats.TagMethod, stats.TagName)
+
+	// the VU starts in the init context, the tests set the state when they need it
+	state := vu.StateField
+	vu.StateField = nil
+	vu.InitEnvField = &common.InitEnvironment{
+		FileSystems: map[string]afero.Fs{"file": fs},
+		CWD:         &url.URL{Path: "/scripts"},
+	}
+	m, ok := New().NewModuleInstance(vu).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, vu.Runtime().Set("thrift", m.Exports().Named))
+	return vu, state, samples
+}
+
+// testServer serves the Calculator service, with the IDL of the tests.
+type testServer struct {
+	addr      string
+	protocol  string
+	transport string
+	functions map[string]*function
+	logs      chan string
+}
+
+func newTestServer(t *testing.T, protocol, transport string) *testServer {
+	t.Helper()
+	fs := newTestFS(t)
+	l := &loader{
+		importPaths: []string{"/scripts/idl", "/scripts/shared"},
+		open: func(filename string) (io.ReadCloser, error) {
+			return fs.Open(filename)
+		},
+		docs:    make(map[string]*document),
+		loading: make(map[string]bool),
+	}
+	doc, err := l.load("calculator.thrift", "")
+	require.NoError(t, err)
+	s := &testServer{
+		protocol:  protocol,
+		transport: transport,
+		functions: make(map[string]*function),
+		logs:      make(chan string, 10),
+	}
+	for service := doc.services[0]; service != nil; service = service.parent {
+		for _, fn := range service.functions {
+			s.functions[fn.name] = fn
+		}
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+	s.addr = ln.Addr().String()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *testServer) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	rt := goja.New()
+	tr := newTransport(s.transport, conn)
+	for {
+		r, err := tr.nextMessage()
+		if err != nil {
+			return
+		}
+		d := newDecoder(s.protocol, r)
+		name, _, seq, err := d.readMessageBegin()
+		if err != nil {
+			return
+		}
+		name = strings.TrimPrefix(name, "Calculator:")
+		e := newEncoder(s.protocol)
+		fn := s.functions[name]
+		if fn == nil || name == "crash" || name == "missing" {
+			if err = skip(d, typeStruct, 0); err != nil {
+				return
+			}
+			message, typ := "boom", int64(ApplicationErrorInternalError)
+			if name != "crash" {
+				message, typ = "unknown method "+name, ApplicationErrorUnknownMethod
+			}
+			e.writeMessageBegin(name, messageException, seq)
+			_ = encodeStruct(e, applicationExceptionDef, map[string]interface{}{"message": message, "type": typ}, 0)
+			if tr.writeMessage(e.bytes()) != nil {
+				return
+			}
+			continue
+		}
+		args, err := decodeStruct(rt, d, fn.args, 0)
+		if err != nil {
+			return
+		}
+		result := make(map[string]interface{})
+		switch name {
+		case "version":
+			result["success"] = "1.0"
+		case "add":
+			result["success"] = int64(args["a"].(int32) + args["b"].(int32)) //nolint:forcetypeassert
+		case "echo":
+			result["success"] = args["item"]
+		case "get":
+			if id := args["id"].(int64); id == 404 { //nolint:forcetypeassert
+				result["notFound"] = map[string]interface{}{"reason": "no such item", "id": id}
+			} else {
+				result["success"] = map[string]interface{}{"id": id, "name": "item"}
+			}
+		case "log":
+			s.logs <- args["line"].(string) //nolint:forcetypeassert
+			continue
+		case "slow":
+			time.Sleep(time.Second)
+			result["success"] = "done"
+		}
+		e.writeMessageBegin(name, messageReply, seq)
+		if err = encodeStruct(e, fn.result, result, 0); err != nil {
+			panic(err)
+		}
+		if tr.writeMessage(e.bytes()) != nil {
+			return
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	t.Parallel()
+
+	for _, protocol := range []string{"binary", "compact"} {
+		for _, transport := range []string{"buffered", "framed"} {
+			protocol, transport := protocol, transport
+			t.Run(protocol+"/"+transport, func(t *testing.T) {
+				t.Parallel()
+				vu, state, samples := newTestVU(t, newTestFS(t))
+				s := newTestServer(t, protocol, transport)
+
+				_, err := vu.Runtime().RunString(`
+					var client = new thrift.Client();
+					var methods = client.load(["idl", "shared"], "calculator.thrift");`)
+				require.NoError(t, err)
+				vu.StateField = state
+
+				_, err = vu.Runtime().RunString(fmt.Sprintf(`
+					function check(actual, expected, what) {
+						if (actual !== expected) {
+							throw new Error(what + " is " + actual + " instead of " + expected);
+						}
+					}
+					client.connect(%q, { protocol: %q, transport: %q, multiplexed: %t });
+
+					check(client.invoke("Calculator.add", { a: 1, b: 2 }).message, 3, "add");
+					check(client.invoke("Calculator.version").message, "1.0", "version");
+					check(client.invoke("Calculator.ping", {}).message, null, "ping");
+
+					var payload = new Uint8Array([0, 1, 255]).buffer;
+					var item = client.invoke("Calculator.echo", { item: {
+						id: "9007199254740993", name: "pen", color: "BLUE", tags: ["a", "b"],
+						shapes: { line: [{ x: 0, y: 0 }, { x: 1.5, y: -2 }] }, payload: payload,
+						active: true, archived: false, values: { "1": { number: 7 }, "-2": { text: "t" } },
+						level: -3, rank: 300, score: 0.5,
+					}}).message;
+					check(item.id, 9007199254740993, "id");
+					check(item.name, "pen", "name");
+					check(item.color, "BLUE", "color");
+					check(item.tags.join(), "a,b", "tags");
+					check(item.shapes.line[1].y, -2, "shapes");
+					check(new Uint8Array(item.payload).join(), "0,1,255", "payload");
+					check(item.active, true, "active");
+					check(item.archived, false, "archived");
+					check(item.values["1"].number, 7, "number value");
+					check(item.values["-2"].text, "t", "text value");
+					check(item.level, -3, "level");
+					check(item.rank, 300, "rank");
+					check(item.score, 0.5, "score");
+
+					var res = client.invoke("Calculator.get", { id: 404 });
+					check(res.message, null, "message");
+					check(res.exception.name, "NotFound", "exception");
+					check(res.exception.value.reason, "no such item", "reason");
+					check(res.exception.value.id, 404, "exception id");
+					check(client.invoke("Calculator.get", { id: 1 }).message.name, "item", "get");
+
+					res = client.invoke("Calculator.crash");
+					check(res.error.type, thrift.ApplicationErrorInternalError, "error type");
+					check(res.error.message, "boom", "error message");
+					check(client.invoke("Calculator.missing").error.type, thrift.ApplicationErrorUnknownMethod, "missing");
+
+					check(client.invoke("Calculator.log", { line: "hello" }).message, null, "log");
+					check(client.invoke("Calculator.add", { a: -1, b: 1 }).message, 0, "add after log");
+					client.close();
+				`, s.addr, protocol, transport, transport == "framed"))
+				require.NoError(t, err)
+				assert.Equal(t, "hello", <-s.logs)
+
+				v, err := vu.Runtime().RunString(`methods.map(function(m) { return m.full_method; }).join()`)
+				require.NoError(t, err)
+				assert.Equal(t, "Base.version,Calculator.add,Calculator.crash,Calculator.echo,Calculator.get,"+
+					"Calculator.log,Calculator.missing,Calculator.ping,Calculator.slow,Calculator.version", v.String())
+
+				var durations, failures int
+				for _, container := range stats.GetBufferedSamples(samples) {
+					for _, sample := range container.GetSamples() {
+						tags := sample.Tags.CloneTags()
+						switch sample.Metric.Name {
+						case metrics.ThriftReqDurationName:
+							durations++
+						case metrics.ThriftReqFailedName:
+							if sample.Value == 0 {
+								continue
+							}
+							failures++
+							assert.Contains(t, []string{"Calculator.get", "Calculator.crash", "Calculator.missing"}, tags["name"])
+						}
+						assert.Equal(t, "thrift://"+s.addr, tags["url"])
+						assert.Equal(t, "Calculator", tags["service"])
+						assert.Equal(t, tags["name"], "Calculator."+tags["method"])
+					}
+				}
+				assert.Equal(t, 10, durations)
+				assert.Equal(t, 3, failures)
+			})
+		}
+	}
+
+	t.Run("Timeout", func(t *testing.T) {
+		t.Parallel()
+		vu, state, _ := newTestVU(t, newTestFS(t))
+		s := newTestServer(t, "binary", "buffered")
+
+		_, err := vu.Runtime().RunString(`
+			var client = new thrift.Client();
+			client.load(["/scripts/idl", "/scripts/shared"], "calculator.thrift");`)
+		require.NoError(t, err)
+		vu.StateField = state
+
+		_, err = vu.Runtime().RunString(`client.connect("` + s.addr + `");
+			client.invoke("Calculator.slow", {}, { timeout: "100ms" });`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "the Thrift call timed out after 100ms")
+
+		_, err = vu.Runtime().RunString(`client.invoke("Calculator.add", { a: 1, b: 2 });`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no Thrift connection, you must call connect first")
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		t.Parallel()
+		vu, state, _ := newTestVU(t, newTestFS(t))
+		s := newTestServer(t, "binary", "buffered")
+
+		_, err := vu.Runtime().RunString(`
+			var client = new thrift.Client();
+			client.load(["idl", "shared"], "calculator.thrift");
+			client.connect("` + s.addr + `");`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "connecting to a Thrift server in the init context is not supported")
+		vu.StateField = state
+		_, err = vu.Runtime().RunString(`
+			var addr = "` + s.addr + `";
+			client.connect(addr);
+			function add(args, params) { return client.invoke("Calculator.add", args, params); }
+			function echo(item) { return client.invoke("Calculator.echo", { item: item }); }`)
+		require.NoError(t, err)
+
+		tests := map[string]string{
+			`client.load([], "calculator.thrift")`:                       "load must be called in the init context",
+			`client.invoke("Calculator.divide", {})`:                     `method "Calculator.divide" not found`,
+			`add({ a: 1, c: 2 })`:                                        `unknown field "c" of Calculator.add args`,
+			`add({ a: 1.5 })`:                                            "1.5 needs to be an integer",
+			`add({ a: "one" })`:                                          `"one" needs to be an integer`,
+			`add({ a: 2147483648 })`:                                     "out of the range of i32",
+			`add([])`:                                                    "needs to be an object",
+			`add({}, { retries: 1 })`:                                    `unknown param: "retries"`,
+			`add({}, { timeout: "soon" })`:                               "invalid timeout value",
+			`echo({ name: "pen" })`:                                      `the required field "id" of Item is missing`,
+			`echo({ id: 1, color: "X" })`:                                `unknown value "X" of the enum Color`,
+			`echo({ id: 1, tags: "a" })`:                                 "needs to be an array",
+			`echo({ id: 1, active: 1 })`:                                 "needs to be a boolean",
+			`echo({ id: 1, values: { "1": { number: 1, text: "a" } } })`: "the union Value needs exactly one field, not 2",
+			`echo({ id: 1, values: { "a": { number: 1 } } })`:            `invalid key "a"`,
+			`client.connect(addr, { protocol: "json" })`:                 "it needs to be binary or compact",
+			`client.connect(addr, { transport: "http" })`:                "it needs to be buffered or framed",
+			`client.connect(addr, { tls: "yes" })`:                       "invalid tls value",
+			`client.connect(addr, { retries: 1 })`:                       `unknown connect param: "retries"`,
+			`client.connect("localhost")`:                                "invalid address",
+		}
+		for script, msg := range tests {
+			_, err := vu.Runtime().RunString(script)
+			if assert.Error(t, err, script) {
+				assert.Contains(t, err.Error(), msg, script)
+			}
+		}
+
+		// the connection can still be used after the errors of the args
+		v, err := vu.Runtime().RunString(`client.invoke("Calculator.add", { a: 1, b: 2 }).message`)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), v.ToInteger())
+
+		vu.StateField = nil
+		_, err = vu.Runtime().RunString(`client.invoke("Calculator.add", { a: 1, b: 2 })`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invoking Thrift methods in the init context is not supported")
+	})
+}
+
+func TestLoadErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"unknown type":              "struct A { 1: B b }",
+		"is recursive":              "typedef A B\ntypedef B A\nstruct C { 1: A a }",
+		"in a cycle":                `include "main.thrift"`,
+		"can't find":                `include "missing.thrift"`,
+		"duplicate field ID 1 in A": "struct A { 1: i32 a, 1: i32 b }",
+		`"A" is already defined`:    "struct A {}\nenum A { X }",
+		`unknown service "Missing" extended by S`: "service S extends Missing {}",
+		`main.thrift:3: expected ">"`:             "struct A {\n\t1: i32 a,\n\t2: list<i32 b\n}",
+		"can't return a value":                    "service S { oneway i32 f() }",
+		"unexpected end of the file":              "struct A {",
+	}
+	for msg, src := range tests {
+		msg, src := msg, src
+		t.Run(msg, func(t *testing.T) {
+			t.Parallel()
+			fs := afero.NewMemMapFs()
+			require.NoError(t, afero.WriteFile(fs, "/scripts/main.thrift", []byte(src), 0o644))
+			vu, _, _ := newTestVU(t, fs)
+
+			_, err := vu.Runtime().RunString(`new thrift.Client().load([], "main.thrift")`)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), msg)
+		})
+	}
+}
@@ -0,0 +1,335 @@
+package experimental
+
+import (
+	"container/heap"
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/modules"
+)
+
+// timers keeps track of all the timers of a module instance.
+//
+// Instead of a goroutine per timer, the timers are kept in a heap ordered by
+// their deadline and at most one goroutine (see timerWaiter) waits for the
+// earliest of them, keeping a single callback registered on the event loop.
+// Everything apart from the waiter is only accessed from the event loop.
+type timers struct {
+	vu modules.VU
+
+	// ctx is the context in which the current timers were created, once it
+	// changes (i.e. a new iteration has started) all of them are dropped.
+	ctx    context.Context
+	lastID int64
+	queue  timerQueue
+	byID   map[int64]*timer
+	waiter *timerWaiter
+
+	// immediates are the setImmediate callbacks that haven't been run or cleared yet,
+	// they don't need a waiter as they are queued on the event loop right away
+	immediates map[int64]*timer
+
+	// running is set while due timers are being run, so that they aren't rescheduled one by one
+	running bool
+}
+
+type timer struct {
+	id       int64
+	kind     string // the name of the function that created it
+	stack    []goja.StackFrame
+	deadline time.Time
+	interval time.Duration // zero for timeouts
+	callback goja.Callable
+	args     []goja.Value
+	index    int // the index in the timerQueue, as required by container/heap
+}
+
+func newTimers(vu modules.VU) *timers {
+	return &timers{
+		vu:         vu,
+		byID:       make(map[int64]*timer),
+		immediates: make(map[int64]*timer),
+	}
+}
+
+// reset drops all the timers if the context of the VU has changed since they were created.
+// Any waiter from before will not be able to run anything as it's no longer the current one.
+func (t *timers) reset() {
+	ctx := t.vu.Context()
+	if ctx == t.ctx {
+		return
+	}
+	t.ctx = ctx
+	t.queue = nil
+	t.byID = make(map[int64]*timer)
+	t.immediates = make(map[int64]*timer)
+	t.waiter = nil
+}
+
+// creationStack is kept for every timer, so that the ones that were leaked can be tracked down.
+// Only a few frames are captured, as there can be a lot of timers.
+func (t *timers) creationStack() []goja.StackFrame {
+	stack := t.vu.Runtime().CaptureCallStack(4, nil)
+	if len(stack) > 0 {
+		stack = stack[1:] // the native setTimeout, setInterval or setImmediate
+	}
+	return stack
+}
+
+// add adds a timer, the kind is the name of the function that created it and is only used for reporting.
+func (t *timers) add(kind string, callback goja.Callable, delay float64, repeat bool, args []goja.Value) int64 {
+	t.reset()
+	t.lastID++
+	d := toDuration(delay)
+	tm := &timer{
+		id:       t.lastID,
+		kind:     kind,
+		stack:    t.creationStack(),
+		deadline: time.Now().Add(d),
+		callback: callback,
+		args:     args,
+	}
+	if repeat {
+		if d < time.Millisecond {
+			d = time.Millisecond
+			tm.deadline = time.Now().Add(d)
+		}
+		tm.interval = d
+	}
+	t.byID[tm.id] = tm
+	heap.Push(&t.queue, tm)
+	t.schedule()
+
+	return tm.id
+}
+
+func (t *timers) remove(id int64) {
+	t.reset()
+	tm, ok := t.byID[id]
+	if !ok {
+		return
+	}
+	delete(t.byID, id)
+	heap.Remove(&t.queue, tm.index)
+	t.schedule()
+}
+
+// addImmediate queues callback to be run on the event loop after everything that is already queued.
+func (t *timers) addImmediate(callback goja.Callable, args []goja.Value) int64 {
+	t.reset()
+	t.lastID++
+	id, ctx := t.lastID, t.ctx
+	t.immediates[id] = &timer{id: id, kind: "setImmediate", stack: t.creationStack()}
+	t.vu.RegisterCallback()(func() error {
+		if _, ok := t.immediates[id]; !ok || t.ctx != ctx {
+			return nil // it was cleared or the timers were reset
+		}
+		delete(t.immediates, id)
+		_, err := callback(goja.Undefined(), args...)
+		return err
+	})
+	return id
+}
+
+func (t *timers) removeImmediate(id int64) {
+	t.reset()
+	delete(t.immediates, id)
+}
+
+// pending returns the number of timers and immediates that haven't been run or cleared yet,
+// not counting any from previous iterations.
+func (t *timers) pending() (timers int, immediates int) {
+	if t.vu.Context() != t.ctx {
+		return 0, 0
+	}
+	return len(t.queue), len(t.immediates)
+}
+
+// leaked returns the pending timers and immediates of the current iteration ordered by their id and
+// then drops them. It must only be called once the iteration has ended, as they will never run by then.
+func (t *timers) leaked() []*timer {
+	if t.vu.Context() != t.ctx {
+		return nil
+	}
+	leaked := make([]*timer, 0, len(t.byID)+len(t.immediates))
+	for _, tm := range t.byID {
+		leaked = append(leaked, tm)
+	}
+	for _, tm := range t.immediates {
+		leaked = append(leaked, tm)
+	}
+	sort.Slice(leaked, func(i, j int) bool { return leaked[i].id < leaked[j].id })
+
+	t.ctx = nil
+	t.reset()
+	return leaked
+}
+
+// schedule makes certain that something is waiting for the earliest timer, if there is one.
+func (t *timers) schedule() {
+	if t.running {
+		return
+	}
+	var deadline time.Time
+	if len(t.queue) > 0 {
+		deadline = t.queue[0].deadline
+	}
+	if t.waiter != nil {
+		t.waiter.setDeadline(deadline)
+		return
+	}
+	if deadline.IsZero() {
+		return
+	}
+	w := &timerWaiter{
+		deadline: deadline,
+		wakeupCh: make(chan struct{}, 1),
+	}
+	t.waiter = w
+	go w.wait(t.ctx, t.vu.RegisterCallback(),
+		func() error { return t.runDue(w) },
+		func() error { return t.release(w) },
+	)
+}
+
+// runDue runs all the timers that were due by the time it was called.
+// Timers added or rescheduled while it runs are left for the next time.
+func (t *timers) runDue(w *timerWaiter) error {
+	if t.waiter != w {
+		return nil // the timers were reset since the waiter was started
+	}
+	t.waiter = nil
+	t.running = true
+	defer func() { t.running = false }()
+
+	now := time.Now()
+	for len(t.queue) > 0 && !t.queue[0].deadline.After(now) {
+		tm := t.queue[0]
+		args := tm.args
+		if tm.interval > 0 {
+			// the next tick is relative to when this one should've fired, so slow callbacks don't make
+			// the interval drift, and any ticks that were missed in the meantime are skipped
+			skipped := int64(now.Sub(tm.deadline) / tm.interval)
+			tm.deadline = tm.deadline.Add(time.Duration(skipped+1) * tm.interval)
+			heap.Fix(&t.queue, 0)
+			args = append(args[:len(args):len(args)], t.vu.Runtime().ToValue(skipped))
+		} else {
+			heap.Pop(&t.queue)
+			delete(t.byID, tm.id)
+		}
+		if _, err := tm.callback(goja.Undefined(), args...); err != nil {
+			return err // the event loop will stop, so there is no point in waiting on the rest
+		}
+	}
+	t.running = false
+	t.schedule()
+	return nil
+}
+
+// release is run on the event loop after a waiter has stopped waiting without firing.
+func (t *timers) release(w *timerWaiter) error {
+	if t.waiter == w {
+		t.waiter = nil
+		// a timer could've been added after the waiter decided to stop
+		t.schedule()
+	}
+	return nil
+}
+
+func toDuration(ms float64) time.Duration {
+	if math.IsNaN(ms) || ms < 0 {
+		return 0
+	}
+	if ms > float64(math.MaxInt64/int64(time.Millisecond)) {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// timerWaiter waits, in its own goroutine, for the earliest timer's deadline
+// and then queues the running of all due timers on the event loop.
+type timerWaiter struct {
+	mu       sync.Mutex
+	deadline time.Time // zero if there is nothing to wait for
+	wakeupCh chan struct{}
+}
+
+func (w *timerWaiter) setDeadline(deadline time.Time) {
+	w.mu.Lock()
+	changed := !w.deadline.Equal(deadline)
+	w.deadline = deadline
+	w.mu.Unlock()
+	if changed {
+		select {
+		case w.wakeupCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (w *timerWaiter) getDeadline() time.Time {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.deadline
+}
+
+// wait returns once it has queued either fire or release on the event loop,
+// or a no-op if the context is done as then the timers will be dropped anyway.
+func (w *timerWaiter) wait(ctx context.Context, runOnLoop func(func() error), fire, release func() error) {
+	for {
+		deadline := w.getDeadline()
+		if deadline.IsZero() {
+			runOnLoop(release)
+			return
+		}
+		tm := time.NewTimer(time.Until(deadline))
+		select {
+		case <-tm.C:
+			runOnLoop(fire)
+			return
+		case <-w.wakeupCh:
+			tm.Stop()
+		case <-ctx.Done():
+			tm.Stop()
+			runOnLoop(func() error { return nil })
+			return
+		}
+	}
+}
+
+// timerQueue implements heap.Interface, ordering the timers by their deadline and then by their creation.
+type timerQueue []*timer
+
+func (q timerQueue) Len() int { return len(q) }
+
+func (q timerQueue) Less(i, j int) bool {
+	if q[i].deadline.Equal(q[j].deadline) {
+		return q[i].id < q[j].id
+	}
+	return q[i].deadline.Before(q[j].deadline)
+}
+
+func (q timerQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *timerQueue) Push(x interface{}) {
+	tm := x.(*timer) //nolint:forcetypeassert
+	tm.index = len(*q)
+	*q = append(*q, tm)
+}
+
+func (q *timerQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	tm := old[n-1]
+	old[n-1] = nil
+	*q = old[:n-1]
+	return tm
+}
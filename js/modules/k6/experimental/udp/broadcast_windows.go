@@ -0,0 +1,14 @@
+package udp
+
+import "syscall"
+
+// setBroadcast allows the socket to send to broadcast addresses.
+func setBroadcast(_, _ string, c syscall.RawConn) error {
+	var err error
+	if cerr := c.Control(func(fd uintptr) {
+		err = syscall.SetsockoptInt(syscall.Handle(fd), syscall.SOL_SOCKET, syscall.SO_BROADCAST, 1)
+	}); cerr != nil {
+		return cerr
+	}
+	return err
+}
@@ -0,0 +1,396 @@
+// Package udp implements the k6/experimental/udp module, with UDP sockets that send datagrams to an
+// address, or broadcast them, and receive the replies.
+package udp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/stats"
+)
+
+type (
+	// RootModule is the global module instance that will create module
+	// instances for each VU.
+	RootModule struct{}
+
+	// ModuleInstance represents an instance of the udp module for every VU.
+	ModuleInstance struct {
+		vu modules.VU
+	}
+)
+
+var (
+	_ modules.Module   = &RootModule{}
+	_ modules.Instance = &ModuleInstance{}
+)
+
+// New returns a pointer to a new RootModule instance.
+func New() *RootModule {
+	return &RootModule{}
+}
+
+// NewModuleInstance implements the modules.Module interface to return
+// a new instance for each VU.
+func (*RootModule) NewModuleInstance(vu modules.VU) modules.Instance {
+	return &ModuleInstance{vu: vu}
+}
+
+// Exports returns the exports of the udp module.
+func (mi *ModuleInstance) Exports() modules.Exports {
+	return modules.Exports{
+		Named: map[string]interface{}{
+			"Socket": mi.NewSocket,
+		},
+	}
+}
+
+// NewSocket is the JS constructor for the udp Socket.
+func (mi *ModuleInstance) NewSocket(call goja.ConstructorCall) *goja.Object {
+	rt := mi.vu.Runtime()
+	return rt.ToValue(&Socket{vu: mi.vu}).ToObject(rt)
+}
+
+const (
+	defaultTimeout = 5 * time.Second
+	maxDatagram    = 64 << 10
+)
+
+var (
+	errConnectInInitContext = common.NewInitContextError("connecting a UDP socket in the init context is not supported")
+	errNotConnected         = errors.New("the UDP socket isn't connected")
+	errClosed               = errors.New("the UDP socket was closed")
+)
+
+// broadcastConn sends the datagrams written to it to a broadcast address, and reads the datagrams
+// from any address, since the replies come from the hosts that received the broadcast.
+type broadcastConn struct {
+	net.PacketConn
+	addr *net.UDPAddr
+}
+
+func (c *broadcastConn) Read(b []byte) (int, error) {
+	n, _, err := c.ReadFrom(b)
+	return n, err
+}
+
+func (c *broadcastConn) Write(b []byte) (int, error) {
+	return c.WriteTo(b, c.addr)
+}
+
+func (c *broadcastConn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+// Socket is a UDP socket, which can be connected once. It's closed at the latest when the iteration ends.
+type Socket struct {
+	vu modules.VU
+
+	// set by Connect
+	conn    net.Conn
+	timeout time.Duration
+	tags    map[string]string
+	cancel  context.CancelFunc
+
+	mu  sync.Mutex
+	err error
+}
+
+// connectParams are the params of connect().
+type connectParams struct {
+	Broadcast bool
+	Timeout   time.Duration
+	Tags      map[string]string
+}
+
+func parseConnectParams(raw map[string]interface{}) (connectParams, error) {
+	p := connectParams{Timeout: defaultTimeout}
+	var err error
+	for k, v := range raw {
+		switch k {
+		case "broadcast":
+			var ok bool
+			if p.Broadcast, ok = v.(bool); !ok {
+				return p, errors.New("the broadcast param needs to be a boolean")
+			}
+		case "timeout":
+			p.Timeout, err = types.GetDurationValue(v)
+			if err != nil || p.Timeout <= 0 {
+				return p, fmt.Errorf("invalid timeout value '%#v'", v)
+			}
+		case "tags":
+			tags, ok := v.(map[string]interface{})
+			if !ok {
+				return p, fmt.Errorf("metric tags must be an object of string values, got '%#v'", v)
+			}
+			p.Tags = make(map[string]string, len(tags))
+			for name, tag := range tags {
+				p.Tags[name] = fmt.Sprint(tag)
+			}
+		default:
+			return p, fmt.Errorf("unknown connect param: %q", k)
+		}
+	}
+	return p, nil
+}
+
+// Connect sets the host:port address the socket sends its datagrams to. With the broadcast param,
+// the address needs to be an IPv4 broadcast address, and the replies are received from any address.
+func (s *Socket) Connect(addr string, params map[string]interface{}) error {
+	state := s.vu.State()
+	if state == nil {
+		return errConnectInInitContext
+	}
+	if s.conn != nil {
+		return errors.New("the UDP socket was already connected, a new socket is needed to connect again")
+	}
+	p, err := parseConnectParams(params)
+	if err != nil {
+		return err
+	}
+	if _, _, err = net.SplitHostPort(addr); err != nil {
+		return fmt.Errorf("invalid address %q, it needs to be host:port: %w", addr, err)
+	}
+
+	ctx, cancel := context.WithTimeout(s.vu.Context(), p.Timeout)
+	defer cancel()
+	var conn net.Conn
+	if p.Broadcast {
+		conn, err = s.listenBroadcast(ctx, addr)
+	} else {
+		conn, err = state.Dialer.DialContext(ctx, "udp", addr)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.tags = state.CloneTags()
+	if state.Options.SystemTags.Has(stats.TagURL) {
+		s.tags["url"] = "udp://" + addr
+	}
+	for k, v := range p.Tags {
+		s.tags[k] = v
+	}
+
+	s.conn, s.timeout = conn, p.Timeout
+	var socketCtx context.Context
+	socketCtx, s.cancel = context.WithCancel(s.vu.Context())
+	go func() {
+		// the socket doesn't outlive the iteration
+		<-socketCtx.Done()
+		s.closeWith(errClosed)
+	}()
+	return nil
+}
+
+// listenBroadcast opens an unconnected socket that can send to the broadcast address addr.
+func (s *Socket) listenBroadcast(ctx context.Context, addr string) (net.Conn, error) {
+	host, port, _ := net.SplitHostPort(addr)
+	ip := net.ParseIP(host).To4()
+	if ip == nil {
+		return nil, fmt.Errorf("the broadcast address %q needs to be an IPv4 address", host)
+	}
+	for _, ipnet := range s.vu.State().Options.BlacklistIPs {
+		if ipnet.Contains(ip) {
+			return nil, fmt.Errorf("IP (%s) is in a blacklisted range (%s)", ip, ipnet)
+		}
+	}
+	udpAddr, err := net.ResolveUDPAddr("udp4", net.JoinHostPort(ip.String(), port))
+	if err != nil {
+		return nil, err
+	}
+	lc := net.ListenConfig{Control: setBroadcast}
+	conn, err := lc.ListenPacket(ctx, "udp4", ":0")
+	if err != nil {
+		return nil, err
+	}
+	return &broadcastConn{PacketConn: conn, addr: udpAddr}, nil
+}
+
+func (s *Socket) check() error {
+	if s.conn == nil {
+		return errNotConnected
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Send sends a string or an ArrayBuffer in a datagram.
+func (s *Socket) Send(data interface{}) error {
+	if err := s.check(); err != nil {
+		return err
+	}
+	b, err := common.ToBytes(data)
+	if err != nil {
+		return err
+	}
+	return s.send(b)
+}
+
+func (s *Socket) send(b []byte) error {
+	if len(b) > maxDatagram {
+		return fmt.Errorf("the data is longer than the max size of a datagram, %d bytes", len(b))
+	}
+	if err := s.conn.SetWriteDeadline(time.Now().Add(s.timeout)); err != nil {
+		return err
+	}
+	if _, err := s.conn.Write(b); err != nil {
+		return s.wrapErr(err)
+	}
+	s.push(s.vu.State().BuiltinMetrics.UDPPacketsSent, 1)
+	return nil
+}
+
+// receiveParams are the params of receive() and request().
+type receiveParams struct {
+	Timeout time.Duration
+	Binary  bool
+}
+
+func (s *Socket) parseReceiveParams(method string, raw map[string]interface{}) (receiveParams, error) {
+	p := receiveParams{Timeout: s.timeout}
+	var err error
+	for k, v := range raw {
+		switch k {
+		case "timeout":
+			if p.Timeout, err = types.GetDurationValue(v); err != nil || p.Timeout <= 0 {
+				return p, fmt.Errorf("invalid timeout value '%#v'", v)
+			}
+		case "binary":
+			var ok bool
+			if p.Binary, ok = v.(bool); !ok {
+				return p, errors.New("the binary param needs to be a boolean")
+			}
+		default:
+			return p, fmt.Errorf("unknown %s param: %q", method, k)
+		}
+	}
+	return p, nil
+}
+
+// Receive returns the next datagram that's received, as a string or an ArrayBuffer if its binary
+// param is set. It returns null if no datagram is received before the timeout.
+func (s *Socket) Receive(params map[string]interface{}) (interface{}, error) {
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	p, err := s.parseReceiveParams("receive", params)
+	if err != nil {
+		return nil, err
+	}
+	b, err := s.receive(p.Timeout)
+	if b == nil || err != nil {
+		return nil, err
+	}
+	return s.value(b, p.Binary), nil
+}
+
+// Request sends a datagram and returns the reply, like Receive. It emits the round-trip time of the
+// reply, and a packet loss if there's no reply before the timeout.
+func (s *Socket) Request(data interface{}, params map[string]interface{}) (interface{}, error) {
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	b, err := common.ToBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	p, err := s.parseReceiveParams("request", params)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	if err = s.send(b); err != nil {
+		return nil, err
+	}
+	reply, err := s.receive(p.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	metrics := s.vu.State().BuiltinMetrics
+	if reply == nil {
+		s.push(metrics.UDPPacketLoss, 1)
+		return nil, nil
+	}
+	s.push(metrics.UDPRTT, stats.D(time.Since(start)))
+	s.push(metrics.UDPPacketLoss, 0)
+	return s.value(reply, p.Binary), nil
+}
+
+// receive returns the next datagram, or nil if it times out.
+func (s *Socket) receive(timeout time.Duration) ([]byte, error) {
+	if err := s.conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, maxDatagram)
+	n, err := s.conn.Read(buf)
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, s.wrapErr(err)
+	}
+	s.push(s.vu.State().BuiltinMetrics.UDPPacketsReceived, 1)
+	return buf[:n], nil
+}
+
+func (s *Socket) value(b []byte, binary bool) interface{} {
+	if binary {
+		return s.vu.Runtime().NewArrayBuffer(b)
+	}
+	return string(b)
+}
+
+// wrapErr returns the error the socket was closed with, if it was closed, or else err. Unlike the TCP
+// sockets, the UDP sockets stay open after errors like the ICMP port unreachable of a connected socket.
+func (s *Socket) wrapErr(err error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	return err
+}
+
+// Close closes the socket.
+func (s *Socket) Close() {
+	if s.conn != nil {
+		s.closeWith(errClosed)
+	}
+}
+
+func (s *Socket) closeWith(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return
+	}
+	s.err = err
+	_ = s.conn.Close()
+	s.cancel()
+}
+
+func (s *Socket) push(metric *stats.Metric, value float64) {
+	tags := make(map[string]string, len(s.tags))
+	for k, v := range s.tags {
+		tags[k] = v
+	}
+	stats.PushIfNotDone(s.vu.Context(), s.vu.State().Samples, stats.Sample{
+		Metric: metric,
+		Tags:   stats.IntoSampleTags(&tags),
+		Value:  value,
+		Time:   time.Now(),
+	})
+}
@@ -0,0 +1,219 @@
+package udp
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/lib/testutils/httpmultibin"
+	"go.k6.io/k6/stats"
+)
+
+func newTestVU(t *testing.T) (*modulestest.VU, chan stats.SampleContainer) {
+	t.Helper()
+	tb := httpmultibin.NewHTTPMultiBin(t)
+	vu, samples := modulestest.NewTestVU(t, tb, stats.TagURL)
+	m, ok := New().NewModuleInstance(vu).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, vu.Runtime().Set("udp", m.Exports().Named))
+	return vu, samples
+}
+
+// newEchoServer starts a server on the address that echoes the datagrams it receives, except for
+// the ones that drop returns true for, it returns its address.
+func newEchoServer(t *testing.T, addr string, drop func(data []byte) bool) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp4", addr)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	go func() {
+		buf := make([]byte, maxDatagram)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if drop == nil || !drop(buf[:n]) {
+				_, _ = conn.WriteTo(buf[:n], addr)
+			}
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestSocket(t *testing.T) {
+	t.Parallel()
+
+	t.Run("SendReceive", func(t *testing.T) {
+		t.Parallel()
+		vu, samples := newTestVU(t)
+		addr := newEchoServer(t, "127.0.0.1:0", nil)
+
+		_, err := vu.Runtime().RunString(strings.ReplaceAll(`
+			var socket = new udp.Socket();
+			socket.connect("ADDR", { tags: { tag: "value" } });
+			socket.send("ping");
+			var text = socket.receive();
+			socket.send(new Uint8Array([1, 2, 3]).buffer);
+			var bytes = new Uint8Array(socket.receive({ binary: true }));
+			var none = socket.receive({ timeout: "20ms" });
+			socket.close();
+		`, "ADDR", addr))
+		require.NoError(t, err)
+
+		v, err := vu.Runtime().RunString(`text + "|" + bytes.join(",") + "|" + none`)
+		require.NoError(t, err)
+		assert.Equal(t, "ping|1,2,3|null", v.String())
+
+		counts := map[string]float64{}
+		for _, c := range stats.GetBufferedSamples(samples) {
+			for _, s := range c.GetSamples() {
+				url, _ := s.Tags.Get("url")
+				assert.Equal(t, "udp://"+addr, url)
+				tag, _ := s.Tags.Get("tag")
+				assert.Equal(t, "value", tag)
+				counts[s.Metric.Name] += s.Value
+			}
+		}
+		assert.Equal(t, map[string]float64{
+			metrics.UDPPacketsSentName:     2,
+			metrics.UDPPacketsReceivedName: 2,
+		}, counts)
+	})
+
+	t.Run("Request", func(t *testing.T) {
+		t.Parallel()
+		vu, samples := newTestVU(t)
+		addr := newEchoServer(t, "127.0.0.1:0", func(data []byte) bool { return string(data) == "lost" })
+
+		_, err := vu.Runtime().RunString(strings.ReplaceAll(`
+			var socket = new udp.Socket();
+			socket.connect("ADDR");
+			var replies = [
+				socket.request("first"),
+				socket.request("lost", { timeout: "20ms" }),
+				socket.request("last"),
+			];
+		`, "ADDR", addr))
+		require.NoError(t, err)
+
+		v, err := vu.Runtime().RunString(`replies.join("|")`)
+		require.NoError(t, err)
+		assert.Equal(t, "first||last", v.String())
+
+		var rtts int
+		var losses []float64
+		for _, c := range stats.GetBufferedSamples(samples) {
+			for _, s := range c.GetSamples() {
+				switch s.Metric.Name {
+				case metrics.UDPRTTName:
+					rtts++
+				case metrics.UDPPacketLossName:
+					losses = append(losses, s.Value)
+				}
+			}
+		}
+		assert.Equal(t, 2, rtts)
+		assert.Equal(t, []float64{0, 1, 0}, losses)
+	})
+
+	t.Run("Broadcast", func(t *testing.T) {
+		t.Parallel()
+		vu, _ := newTestVU(t)
+		var received int64
+		// the server needs to listen on all the addresses to receive the broadcasts
+		addr := newEchoServer(t, "0.0.0.0:0", func([]byte) bool {
+			atomic.AddInt64(&received, 1)
+			return false
+		})
+		_, port, err := net.SplitHostPort(addr)
+		require.NoError(t, err)
+
+		_, err = vu.Runtime().RunString(`
+			var socket = new udp.Socket();
+			socket.connect("127.255.255.255:` + port + `", { broadcast: true });
+			socket.send("discover");
+			var reply = socket.receive({ timeout: "1s" });
+		`)
+		if err != nil && strings.Contains(err.Error(), "network is unreachable") {
+			t.Skip("the loopback network doesn't support broadcasts here")
+		}
+		require.NoError(t, err)
+		if atomic.LoadInt64(&received) == 0 {
+			t.Skip("the broadcast wasn't delivered to the loopback address here")
+		}
+		assert.Equal(t, "discover", vu.Runtime().Get("reply").String())
+	})
+
+	t.Run("BroadcastBlacklisted", func(t *testing.T) {
+		t.Parallel()
+		vu, _ := newTestVU(t)
+		ipnet, err := lib.ParseCIDR("10.0.0.0/8")
+		require.NoError(t, err)
+		vu.StateField.Options.BlacklistIPs = []*lib.IPNet{ipnet}
+
+		_, err = vu.Runtime().RunString(`new udp.Socket().connect("10.255.255.255:9", { broadcast: true })`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "is in a blacklisted range")
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		t.Parallel()
+		vu, _ := newTestVU(t)
+		addr := newEchoServer(t, "127.0.0.1:0", nil)
+		require.NoError(t, vu.Runtime().Set("addr", addr))
+
+		tests := map[string]string{
+			`new udp.Socket().send("x")`:                                                        "isn't connected",
+			`new udp.Socket().connect("localhost")`:                                             "it needs to be host:port",
+			`new udp.Socket().connect("localhost:9", { broadcast: true })`:                      "needs to be an IPv4 address",
+			`new udp.Socket().connect(addr, { broadcast: "yes" })`:                              "needs to be a boolean",
+			`new udp.Socket().connect(addr, { ttl: 1 })`:                                        "unknown connect param",
+			`var s = new udp.Socket(); s.connect(addr); s.connect(addr)`:                        "already connected",
+			`var s = new udp.Socket(); s.connect(addr); s.receive({ timeout: -1 })`:             "invalid timeout value",
+			`var s = new udp.Socket(); s.connect(addr); s.request("x", { retries: 1 })`:         "unknown request param",
+			`var s = new udp.Socket(); s.connect(addr); s.send(new ArrayBuffer(64 * 1024 + 1))`: "max size of a datagram",
+			`var s = new udp.Socket(); s.connect(addr); s.close(); s.send("x")`:                 "the UDP socket was closed",
+		}
+		for script, msg := range tests {
+			_, err := vu.Runtime().RunString(script)
+			require.Error(t, err, script)
+			assert.Contains(t, err.Error(), msg, script)
+		}
+	})
+
+	t.Run("IterationEnd", func(t *testing.T) {
+		t.Parallel()
+		vu, _ := newTestVU(t)
+		addr := newEchoServer(t, "127.0.0.1:0", nil)
+		ctx, cancel := context.WithCancel(vu.CtxField)
+		vu.CtxField = ctx
+
+		_, err := vu.Runtime().RunString(`var socket = new udp.Socket(); socket.connect("` + addr + `");`)
+		require.NoError(t, err)
+		cancel()
+		require.Eventually(t, func() bool {
+			_, err = vu.Runtime().RunString(`socket.send("x")`)
+			return err != nil && strings.Contains(err.Error(), "the UDP socket was closed")
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("InitContext", func(t *testing.T) {
+		t.Parallel()
+		vu, _ := newTestVU(t)
+		vu.StateField = nil
+
+		_, err := vu.Runtime().RunString(`new udp.Socket().connect("127.0.0.1:1")`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "connecting a UDP socket in the init context is not supported")
+	})
+}
@@ -69,6 +69,15 @@ var (
 type Client struct {
 	mds  map[string]protoreflect.MethodDescriptor
 	conn *grpc.ClientConn
+	// cc sends the calls, it's either the connection or the gRPC-Web client
+	cc     grpc.ClientConnInterface
+	target string
+
+	serviceConfig   *serviceConfig
+	iterationBudget time.Duration
+	// balanced is true if the calls are balanced across endpoints, in which case
+	// the metrics are tagged with the endpoint that each call was sent to
+	balanced bool
 
 	vu modules.VU
 }
@@ -88,6 +97,8 @@ type Response struct {
 	Headers  map[string][]string
 	Trailers map[string][]string
 	Error    interface{}
+	// Attempts is how many attempts of the call were made, with its retry or hedging policy.
+	Attempts int
 }
 
 func walkFileDescriptors(seen map[string]struct{}, fd *desc.FileDescriptor) []*descriptorpb.FileDescriptorProto {
@@ -205,8 +216,9 @@ func (t transportCreds) ClientHandshake(ctx context.Context,
 	return out, auth, err
 }
 
-// Connect is a block dial to the gRPC server at the given address (host:port)
-func (c *Client) Connect(addr string, params map[string]interface{}) (bool, error) {
+// Connect is a block dial to the gRPC server at the given address (host:port), or to the list
+// of endpoints that the calls are balanced across, with the policy in the params.
+func (c *Client) Connect(addr interface{}, params map[string]interface{}) (bool, error) {
 	state := c.vu.State() //nolint:ifshort
 	if state == nil {
 		return false, errConnectInInitContext
@@ -216,6 +228,29 @@ func (c *Client) Connect(addr string, params map[string]interface{}) (bool, erro
 	if err != nil {
 		return false, err
 	}
+	c.serviceConfig, c.iterationBudget = p.ServiceConfig, p.IterationBudget
+
+	target, targetOpts, err := parseTarget(addr)
+	if err != nil {
+		return false, err
+	}
+	if p.Policy != "" {
+		policyOpt, err := balancingPolicy(p.Policy)
+		if err != nil {
+			return false, err
+		}
+		targetOpts = append(targetOpts, policyOpt)
+	}
+	c.balanced = len(targetOpts) > 0
+
+	if p.Protocol != "grpc" {
+		if c.balanced || p.UseReflectionProtocol {
+			return false, fmt.Errorf("the %s protocol doesn't support reflection or balancing across endpoints", p.Protocol)
+		}
+		c.cc = newWebClient(c.vu, target, p.IsPlaintext, p.Protocol == "grpc-web-text")
+		c.target = c.cc.(*webClient).baseURL //nolint:forcetypeassert
+		return true, nil
+	}
 
 	// (rogchap) Even with FailOnNonTempDialError, if there is a TLS error this will timeout
 	// rather than report the error, so we can't rely on WithBlock. By running in a goroutine
@@ -230,6 +265,7 @@ func (c *Client) Connect(addr string, params map[string]interface{}) (bool, erro
 			grpc.FailOnNonTempDialError(true),
 			grpc.WithStatsHandler(c),
 		}
+		opts = append(opts, targetOpts...)
 
 		if ua := state.Options.UserAgent; ua.Valid {
 			opts = append(opts, grpc.WithUserAgent(ua.ValueOrZero()))
@@ -262,11 +298,12 @@ func (c *Client) Connect(addr string, params map[string]interface{}) (bool, erro
 		defer cancel()
 
 		var err error
-		c.conn, err = grpc.DialContext(ctx, addr, opts...)
+		c.conn, err = grpc.DialContext(ctx, target, opts...)
 		if err != nil {
 			errc <- err
 			return
 		}
+		c.cc, c.target = c.conn, c.conn.Target()
 		if p.UseReflectionProtocol {
 			err := c.reflect(ctx)
 			if err != nil {
@@ -389,6 +426,11 @@ type params struct {
 	Metadata map[string]string
 	Tags     map[string]string
 	Timeout  time.Duration
+	Retry    *retryPolicy
+	Hedging  *hedgingPolicy
+	// PolicySet is true if the retry or hedging policy of the call is set, even to null,
+	// in which case the ones in the service config aren't used.
+	PolicySet bool
 }
 
 func (c *Client) parseParams(raw map[string]interface{}) (params, error) {
@@ -435,6 +477,22 @@ func (c *Client) parseParams(raw map[string]interface{}) (params, error) {
 			if err != nil {
 				return p, fmt.Errorf("invalid timeout value: %w", err)
 			}
+		case "retry", "hedging":
+			p.PolicySet = true
+			if v == nil || v == false {
+				continue
+			}
+			var err error
+			if k == "retry" {
+				p.Retry = &retryPolicy{}
+				err = parsePolicy(v, p.Retry)
+			} else {
+				p.Hedging = &hedgingPolicy{}
+				err = parsePolicy(v, p.Hedging)
+			}
+			if err != nil {
+				return p, fmt.Errorf("invalid %s value: %w", k, err)
+			}
 		default:
 			return p, fmt.Errorf("unknown param: %q", k)
 		}
@@ -453,7 +511,7 @@ func (c *Client) Invoke(
 	if state == nil {
 		return nil, errInvokeRPCInInitContext
 	}
-	if c.conn == nil {
+	if c.cc == nil {
 		return nil, errors.New("no gRPC connection, you must call connect first")
 	}
 	if method == "" {
@@ -472,18 +530,70 @@ func (c *Client) Invoke(
 		return nil, err
 	}
 
+	if p.Retry != nil && p.Hedging != nil {
+		return nil, errors.New("a call can't have both a retry and a hedging policy")
+	}
+	timeout, retry, hedging := c.callPolicies(method, p, params)
+	tags := c.callTags(method, p)
+
+	reqdm, err := toMessage(rt, md.Input(), req)
+	if err != nil {
+		return nil, err
+	}
+
+	// the deadline covers all the attempts and it's propagated to the server
+	reqCtx, cancel := c.callContext(p), context.CancelFunc(func() {})
+	if deadline, ok := c.callDeadline(timeout); ok {
+		reqCtx, cancel = context.WithDeadline(reqCtx, deadline)
+	}
+	defer cancel()
+
+	var res callResult
+	switch {
+	case hedging != nil:
+		res = c.invokeHedged(reqCtx, method, md, reqdm, tags, hedging)
+	case retry != nil:
+		res = c.invokeWithRetries(reqCtx, method, md, reqdm, tags, retry)
+	default:
+		res = c.invokeAttempt(reqCtx, method, md, reqdm, tags, 0)
+	}
+
+	var response Response
+	response.Headers = res.header
+	response.Trailers = res.trailer
+	response.Attempts = res.attempts
+
+	if res.err != nil {
+		sterr := status.Convert(res.err)
+		response.Status = sterr.Code()
+		response.Error = statusToObject(sterr)
+	}
+
+	if res.resp != nil {
+		response.Message = messageToObject(res.resp)
+	}
+	return &response, nil
+}
+
+// callContext returns the context of a call with its metadata.
+func (c *Client) callContext(p params) context.Context {
 	ctx := metadata.NewOutgoingContext(c.vu.Context(), metadata.New(nil))
 	for param, strval := range p.Metadata {
 		ctx = metadata.AppendToOutgoingContext(ctx, param, strval)
 	}
+	return ctx
+}
 
+// callTags returns the tags of the metrics of a call.
+func (c *Client) callTags(method string, p params) map[string]string {
+	state := c.vu.State()
 	tags := state.CloneTags()
 	for k, v := range p.Tags {
 		tags[k] = v
 	}
 
 	if state.Options.SystemTags.Has(stats.TagURL) {
-		tags["url"] = fmt.Sprintf("%s%s", c.conn.Target(), method)
+		tags["url"] = fmt.Sprintf("%s%s", c.target, method)
 	}
 	parts := strings.Split(method[1:], "/")
 	if state.Options.SystemTags.Has(stats.TagService) {
@@ -497,75 +607,62 @@ func (c *Client) Invoke(
 	if _, ok := tags["name"]; !ok && state.Options.SystemTags.Has(stats.TagName) {
 		tags["name"] = method
 	}
+	return tags
+}
 
-	ctx = withTags(ctx, tags)
-
-	reqdm := dynamicpb.NewMessage(md.Input())
-	{
-		b, err := req.ToObject(rt).MarshalJSON()
-		if err != nil {
-			return nil, fmt.Errorf("unable to serialise request object: %w", err)
-		}
-		if err := protojson.Unmarshal(b, reqdm); err != nil {
-			return nil, fmt.Errorf("unable to serialise request object to protocol buffer: %w", err)
-		}
+// toMessage converts a JS object to a protocol buffer message of the given type.
+func toMessage(rt *goja.Runtime, md protoreflect.MessageDescriptor, v goja.Value) (*dynamicpb.Message, error) {
+	msg := dynamicpb.NewMessage(md)
+	b, err := v.ToObject(rt).MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("unable to serialise request object: %w", err)
 	}
+	if err := protojson.Unmarshal(b, msg); err != nil {
+		return nil, fmt.Errorf("unable to serialise request object to protocol buffer: %w", err)
+	}
+	return msg, nil
+}
 
-	reqCtx, cancel := context.WithTimeout(ctx, p.Timeout)
-	defer cancel()
-
-	resp := dynamicpb.NewMessage(md.Output())
-	header, trailer := metadata.New(nil), metadata.New(nil)
-	err = c.conn.Invoke(reqCtx, method, reqdm, resp, grpc.Header(&header), grpc.Trailer(&trailer))
-
-	var response Response
-	response.Headers = header
-	response.Trailers = trailer
-
+// messageToObject converts a protocol buffer message to a map that can be used as a JS object.
+func messageToObject(msg proto.Message) map[string]interface{} {
+	// (rogchap) there is a lot of marshaling/unmarshaling here, but if we just pass the dynamic message
+	// the default Marshaller would be used, which would strip any zero/default values from the JSON.
+	// eg. given this message:
+	// message Point {
+	//    double x = 1;
+	// 	  double y = 2;
+	// 	  double z = 3;
+	// }
+	// and a value like this:
+	// msg := Point{X: 6, Y: 4, Z: 0}
+	// would result in JSON output:
+	// {"x":6,"y":4}
+	// rather than the desired:
+	// {"x":6,"y":4,"z":0}
 	marshaler := protojson.MarshalOptions{EmitUnpopulated: true}
+	raw, _ := marshaler.Marshal(msg)
+	obj := make(map[string]interface{})
+	_ = json.Unmarshal(raw, &obj)
+	return obj
+}
 
-	if err != nil {
-		sterr := status.Convert(err)
-		response.Status = sterr.Code()
-
-		// (rogchap) when you access a JSON property in goja, you are actually accessing the underling
-		// Go type (struct, map, slice etc); because these are dynamic messages the Unmarshaled JSON does
-		// not map back to a "real" field or value (as a normal Go type would). If we don't marshal and then
-		// unmarshal back to a map, you will get "undefined" when accessing JSON properties, even when
-		// JSON.Stringify() shows the object to be correctly present.
-
-		raw, _ := marshaler.Marshal(sterr.Proto())
-		errMsg := make(map[string]interface{})
-		_ = json.Unmarshal(raw, &errMsg)
-		response.Error = errMsg
-	}
-
-	if resp != nil {
-		// (rogchap) there is a lot of marshaling/unmarshaling here, but if we just pass the dynamic message
-		// the default Marshaller would be used, which would strip any zero/default values from the JSON.
-		// eg. given this message:
-		// message Point {
-		//    double x = 1;
-		// 	  double y = 2;
-		// 	  double z = 3;
-		// }
-		// and a value like this:
-		// msg := Point{X: 6, Y: 4, Z: 0}
-		// would result in JSON output:
-		// {"x":6,"y":4}
-		// rather than the desired:
-		// {"x":6,"y":4,"z":0}
-		raw, _ := marshaler.Marshal(resp)
-		msg := make(map[string]interface{})
-		_ = json.Unmarshal(raw, &msg)
-		response.Message = msg
-	}
-	return &response, nil
+// statusToObject converts an error status to a map that can be used as a JS object.
+func statusToObject(sterr *status.Status) map[string]interface{} {
+	// (rogchap) when you access a JSON property in goja, you are actually accessing the underling
+	// Go type (struct, map, slice etc); because these are dynamic messages the Unmarshaled JSON does
+	// not map back to a "real" field or value (as a normal Go type would). If we don't marshal and then
+	// unmarshal back to a map, you will get "undefined" when accessing JSON properties, even when
+	// JSON.Stringify() shows the object to be correctly present.
+	return messageToObject(sterr.Proto())
 }
 
 // Close will close the client gRPC connection
 func (c *Client) Close() error {
-	if c == nil || c.conn == nil {
+	if c == nil || c.cc == nil {
+		return nil
+	}
+	c.cc = nil
+	if c.conn == nil {
 		return nil
 	}
 	err := c.conn.Close()
@@ -602,17 +699,24 @@ func (c *Client) HandleRPC(ctx context.Context, stat grpcstats.RPCStats) {
 				tags["ip"] = ip
 			}
 		}
+		if c.balanced && s.RemoteAddr != nil {
+			tags["endpoint"] = s.RemoteAddr.String()
+		}
 	case *grpcstats.End:
 		if state.Options.SystemTags.Has(stats.TagStatus) {
 			tags["status"] = strconv.Itoa(int(status.Code(s.Error)))
 		}
 
+		metric := state.BuiltinMetrics.GRPCReqDuration
+		if isStream(ctx) {
+			metric = state.BuiltinMetrics.GRPCStreamDuration
+		}
 		mTags := map[string]string(tags)
 		sampleTags := stats.IntoSampleTags(&mTags)
 		stats.PushIfNotDone(ctx, state.Samples, stats.ConnectedSamples{
 			Samples: []stats.Sample{
 				{
-					Metric: state.BuiltinMetrics.GRPCReqDuration,
+					Metric: metric,
 					Tags:   sampleTags,
 					Value:  stats.D(s.EndTime.Sub(s.BeginTime)),
 					Time:   s.EndTime,
@@ -633,6 +737,14 @@ type connectParams struct {
 	IsPlaintext           bool
 	UseReflectionProtocol bool
 	Timeout               time.Duration
+	// ServiceConfig has the timeouts and the retry and hedging policies of the calls.
+	ServiceConfig *serviceConfig
+	// IterationBudget is how long after the start of the iteration the deadline of every call is, at the latest.
+	IterationBudget time.Duration
+	// Policy is the load balancing policy, pick_first or round_robin.
+	Policy string
+	// Protocol is grpc, or grpc-web or grpc-web-text for gRPC-Web.
+	Protocol string
 }
 
 func (c *Client) parseConnectParams(raw map[string]interface{}) (connectParams, error) {
@@ -640,6 +752,7 @@ func (c *Client) parseConnectParams(raw map[string]interface{}) (connectParams,
 		IsPlaintext:           false,
 		UseReflectionProtocol: false,
 		Timeout:               time.Minute,
+		Protocol:              "grpc",
 	}
 	for k, v := range raw {
 		switch k {
@@ -661,6 +774,30 @@ func (c *Client) parseConnectParams(raw map[string]interface{}) (connectParams,
 			if !ok {
 				return params, fmt.Errorf("invalid reflect value: '%#v', it needs to be boolean", v)
 			}
+		case "serviceConfig":
+			var err error
+			params.ServiceConfig, err = parseServiceConfig(v)
+			if err != nil {
+				return params, fmt.Errorf("invalid serviceConfig value: %w", err)
+			}
+		case "protocol":
+			protocol, ok := v.(string)
+			if !ok || (protocol != "grpc" && protocol != "grpc-web" && protocol != "grpc-web-text") {
+				return params, fmt.Errorf("invalid protocol value: '%#v', it needs to be grpc, grpc-web or grpc-web-text", v)
+			}
+			params.Protocol = protocol
+		case "policy":
+			var ok bool
+			params.Policy, ok = v.(string)
+			if !ok {
+				return params, fmt.Errorf("invalid policy value: '%#v', it needs to be a string", v)
+			}
+		case "iterationBudget":
+			var err error
+			params.IterationBudget, err = types.GetDurationValue(v)
+			if err != nil {
+				return params, fmt.Errorf("invalid iterationBudget value: %w", err)
+			}
 
 		default:
 			return params, fmt.Errorf("unknown connect param: %q", k)
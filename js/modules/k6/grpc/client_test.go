@@ -27,12 +27,14 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/url"
 	"os"
 	"runtime"
 	"strings"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"google.golang.org/grpc/reflection"
 	reflectpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
@@ -112,7 +114,8 @@ func TestClient(t *testing.T) {
 			BuiltinMetrics: metrics.RegisterBuiltinMetrics(
 				metrics.NewRegistry(),
 			),
-			Tags: lib.NewTagMap(nil),
+			Tags:           lib.NewTagMap(nil),
+			IterationStart: time.Now(),
 		}
 
 		cwd, err := os.Getwd()
@@ -650,6 +653,151 @@ func TestClient(t *testing.T) {
 				`,
 			},
 		},
+		{
+			name: "Retry",
+			initString: codeBlock{
+				code: `
+				var client = new grpc.Client();
+				client.load([], "../../../../vendor/google.golang.org/grpc/test/grpc_testing/test.proto");`,
+			},
+			setup: func(tb *httpmultibin.HTTPMultiBin) {
+				var calls int64
+				tb.GRPCStub.UnaryCallFunc = func(context.Context, *grpc_testing.SimpleRequest) (*grpc_testing.SimpleResponse, error) {
+					if atomic.AddInt64(&calls, 1)%3 != 0 {
+						return nil, status.Error(codes.Unavailable, "try again")
+					}
+					return &grpc_testing.SimpleResponse{Username: "k6"}, nil
+				}
+			},
+			vuString: codeBlock{
+				code: `
+				client.connect("GRPCBIN_ADDR");
+				var retry = {
+					maxAttempts: 3, initialBackoff: "1ms", maxBackoff: "10ms", backoffMultiplier: 2,
+					retryableStatusCodes: ["UNAVAILABLE"],
+				};
+				var resp = client.invoke("grpc.testing.TestService/UnaryCall", {}, { retry: retry });
+				if (resp.status !== grpc.StatusOK || resp.attempts !== 3 || resp.message.username !== "k6") {
+					throw new Error("unexpected response: " + JSON.stringify(resp));
+				}
+				retry.maxAttempts = 2;
+				resp = client.invoke("grpc.testing.TestService/UnaryCall", {}, { retry: retry });
+				if (resp.status !== grpc.StatusUnavailable || resp.attempts !== 2) {
+					throw new Error("unexpected response: " + JSON.stringify(resp));
+				}`,
+				asserts: func(t *testing.T, rb *httpmultibin.HTTPMultiBin, samples chan stats.SampleContainer, _ error) {
+					var attempts []string
+					for _, c := range stats.GetBufferedSamples(samples) {
+						for _, s := range c.GetSamples() {
+							attempt, _ := s.Tags.Get("attempt")
+							attempts = append(attempts, attempt)
+						}
+					}
+					assert.Equal(t, []string{"1", "2", "3", "1", "2"}, attempts)
+				},
+			},
+		},
+		{
+			name: "Hedging",
+			initString: codeBlock{
+				code: `
+				var client = new grpc.Client();
+				client.load([], "../../../../vendor/google.golang.org/grpc/test/grpc_testing/test.proto");`,
+			},
+			setup: func(tb *httpmultibin.HTTPMultiBin) {
+				var calls int64
+				tb.GRPCStub.UnaryCallFunc = func(ctx context.Context, _ *grpc_testing.SimpleRequest) (*grpc_testing.SimpleResponse, error) {
+					if atomic.AddInt64(&calls, 1) == 1 {
+						<-ctx.Done() // the first attempt hangs until it's canceled
+						return nil, ctx.Err()
+					}
+					return &grpc_testing.SimpleResponse{Username: "hedged"}, nil
+				}
+			},
+			vuString: codeBlock{
+				code: `
+				client.connect("GRPCBIN_ADDR", { serviceConfig: JSON.stringify({
+					methodConfig: [{
+						name: [{ service: "grpc.testing.TestService" }],
+						hedgingPolicy: { maxAttempts: 3, hedgingDelay: "50ms", nonFatalStatusCodes: ["UNAVAILABLE"] },
+					}],
+				}) });
+				var resp = client.invoke("grpc.testing.TestService/UnaryCall", {});
+				if (resp.status !== grpc.StatusOK || resp.attempts !== 2 || resp.message.username !== "hedged") {
+					throw new Error("unexpected response: " + JSON.stringify(resp));
+				}`,
+			},
+		},
+		{
+			name: "ServiceConfigTimeout",
+			initString: codeBlock{
+				code: `
+				var client = new grpc.Client();
+				client.load([], "../../../../vendor/google.golang.org/grpc/test/grpc_testing/test.proto");`,
+			},
+			setup: func(tb *httpmultibin.HTTPMultiBin) {
+				tb.GRPCStub.UnaryCallFunc = func(ctx context.Context, _ *grpc_testing.SimpleRequest) (*grpc_testing.SimpleResponse, error) {
+					<-ctx.Done()
+					return nil, ctx.Err()
+				}
+			},
+			vuString: codeBlock{
+				code: `
+				client.connect("GRPCBIN_ADDR", { serviceConfig: {
+					methodConfig: [
+						{ name: [{}], timeout: "1m" },
+						{ name: [{ service: "grpc.testing.TestService", method: "UnaryCall" }], timeout: "10ms" },
+					],
+				} });
+				var resp = client.invoke("grpc.testing.TestService/UnaryCall", {});
+				if (resp.status !== grpc.StatusDeadlineExceeded) {
+					throw new Error("unexpected response: " + JSON.stringify(resp));
+				}`,
+			},
+		},
+		{
+			name: "IterationBudget",
+			initString: codeBlock{
+				code: `
+				var client = new grpc.Client();
+				client.load([], "../../../../vendor/google.golang.org/grpc/test/grpc_testing/test.proto");`,
+			},
+			setup: func(tb *httpmultibin.HTTPMultiBin) {
+				tb.GRPCStub.UnaryCallFunc = func(ctx context.Context, _ *grpc_testing.SimpleRequest) (*grpc_testing.SimpleResponse, error) {
+					if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) > 30*time.Second {
+						return nil, status.Error(codes.InvalidArgument, "the deadline wasn't propagated")
+					}
+					return &grpc_testing.SimpleResponse{}, nil
+				}
+			},
+			vuString: codeBlock{
+				code: `
+				client.connect("GRPCBIN_ADDR", { iterationBudget: "30s" });
+				var resp = client.invoke("grpc.testing.TestService/UnaryCall", {}, { timeout: "1m" });
+				if (resp.status !== grpc.StatusOK) {
+					throw new Error("unexpected response: " + JSON.stringify(resp));
+				}
+				client.connect("GRPCBIN_ADDR", { iterationBudget: 1 });
+				resp = client.invoke("grpc.testing.TestService/UnaryCall", {});
+				if (resp.status !== grpc.StatusDeadlineExceeded || resp.attempts !== 1) {
+					throw new Error("the budget wasn't enforced: " + JSON.stringify(resp));
+				}`,
+			},
+		},
+		{
+			name: "InvalidRetryPolicy",
+			initString: codeBlock{
+				code: `
+				var client = new grpc.Client();
+				client.load([], "../../../../vendor/google.golang.org/grpc/test/grpc_testing/test.proto");`,
+			},
+			vuString: codeBlock{
+				code: `
+				client.connect("GRPCBIN_ADDR");
+				client.invoke("grpc.testing.TestService/EmptyCall", {}, { retry: { maxAttempts: 1 } });`,
+				err: "invalid retry value: the retry policy maxAttempts must be greater than 1",
+			},
+		},
 		{
 			name: "Close",
 			initString: codeBlock{
@@ -924,3 +1072,58 @@ func (m *getServiceFileDescriptorMock) Recv() (*reflectpb.ServerReflectionRespon
 	}
 	return srr, nil
 }
+
+func TestClientLoadBalancing(t *testing.T) {
+	t.Parallel()
+
+	// startServer starts a plaintext gRPC server that responds with its name
+	startServer := func(name string) string {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		srv := grpc.NewServer()
+		grpc_testing.RegisterTestServiceServer(srv, &httpmultibin.GRPCStub{
+			UnaryCallFunc: func(context.Context, *grpc_testing.SimpleRequest) (*grpc_testing.SimpleResponse, error) {
+				return &grpc_testing.SimpleResponse{Username: name}, nil
+			},
+		})
+		go func() { _ = srv.Serve(lis) }()
+		t.Cleanup(srv.Stop)
+		return lis.Addr().String()
+	}
+	addrs := []string{startServer("a"), startServer("b")}
+
+	_, vu, samples := newTestVU(t)
+	require.NoError(t, vu.Runtime().Set("addrs", addrs))
+	// the calls are only balanced once the connections to both endpoints are ready
+	v, err := vu.Runtime().RunString(`
+		client.connect(addrs, { plaintext: true, policy: "round_robin" });
+		var names = { a: 0, b: 0 };
+		for (var i = 0; i < 20; i++) {
+			names[client.invoke("grpc.testing.TestService/UnaryCall", {}).message.username]++;
+		}
+		client.close();
+		[names.a, names.b];
+	`)
+	require.NoError(t, err)
+	var names []int64
+	require.NoError(t, vu.Runtime().ExportTo(v, &names))
+	assert.Greater(t, names[0], int64(0))
+	assert.Greater(t, names[1], int64(0))
+
+	endpoints := map[string]int64{}
+	for _, c := range stats.GetBufferedSamples(samples) {
+		for _, s := range c.GetSamples() {
+			endpoint, _ := s.Tags.Get("endpoint")
+			endpoints[endpoint]++
+		}
+	}
+	assert.Equal(t, map[string]int64{addrs[0]: names[0], addrs[1]: names[1]}, endpoints)
+
+	_, err = vu.Runtime().RunString(`client.connect(addrs, { plaintext: true, policy: "random" });`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid policy value: "random"`)
+
+	_, err = vu.Runtime().RunString(`client.connect([], { plaintext: true });`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "the list of endpoints to connect to can't be empty")
+}
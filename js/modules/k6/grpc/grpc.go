@@ -57,6 +57,7 @@ func (*RootModule) NewModuleInstance(vu modules.VU) modules.Instance {
 	}
 
 	mi.exports["Client"] = mi.NewClient
+	mi.exports["Stream"] = mi.NewStream
 	mi.defineConstants()
 	return mi
 }
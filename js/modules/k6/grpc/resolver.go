@@ -0,0 +1,108 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2021 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package grpc
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/resolver"
+)
+
+// endpointsScheme is the scheme of the targets of the clients that are connected to a list of endpoints.
+const endpointsScheme = "endpoints"
+
+// endpointsResolver resolves a target to a fixed list of endpoints.
+type endpointsResolver struct {
+	addrs []resolver.Address
+}
+
+// Build implements the resolver.Builder interface.
+func (r *endpointsResolver) Build(_ resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (
+	resolver.Resolver, error,
+) {
+	return r, cc.UpdateState(resolver.State{Addresses: r.addrs})
+}
+
+// Scheme implements the resolver.Builder interface.
+func (*endpointsResolver) Scheme() string {
+	return endpointsScheme
+}
+
+// ResolveNow implements the resolver.Resolver interface.
+func (*endpointsResolver) ResolveNow(resolver.ResolveNowOptions) {}
+
+// Close implements the resolver.Resolver interface.
+func (*endpointsResolver) Close() {}
+
+// parseTarget returns the target to dial for the address of a connect() call, which is either a
+// single target, like "host:port" or "dns:///host:port", or a list of endpoints to balance the calls
+// across, along with the dial options needed for it.
+func parseTarget(addr interface{}) (string, []grpc.DialOption, error) {
+	var endpoints []string
+	switch v := addr.(type) {
+	case string:
+		return v, nil, nil
+	case []string:
+		endpoints = v
+	case []interface{}:
+		for _, e := range v {
+			s, ok := e.(string)
+			if !ok || s == "" {
+				return "", nil, fmt.Errorf("invalid endpoint '%#v', it needs to be a host:port string", e)
+			}
+			endpoints = append(endpoints, s)
+		}
+	default:
+		return "", nil, fmt.Errorf("invalid address '%#v', it needs to be a string or an array of strings", addr)
+	}
+
+	switch len(endpoints) {
+	case 0:
+		return "", nil, errors.New("the list of endpoints to connect to can't be empty")
+	case 1:
+		return endpoints[0], nil, nil
+	}
+	r := &endpointsResolver{addrs: make([]resolver.Address, len(endpoints))}
+	for i, e := range endpoints {
+		host, _, err := net.SplitHostPort(e)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid endpoint %q: %w", e, err)
+		}
+		// the server name is used for the TLS handshake, as the authority of the target is the whole list
+		r.addrs[i] = resolver.Address{Addr: e, ServerName: host}
+	}
+	target := endpointsScheme + ":///" + strings.Join(endpoints, ",")
+	return target, []grpc.DialOption{grpc.WithResolvers(r)}, nil
+}
+
+// balancingPolicy returns the dial option of the load balancing policy with the given name.
+func balancingPolicy(policy string) (grpc.DialOption, error) {
+	switch policy {
+	case "pick_first", "round_robin":
+		return grpc.WithDefaultServiceConfig(fmt.Sprintf(`{"loadBalancingConfig": [{%q: {}}]}`, policy)), nil
+	default:
+		return nil, fmt.Errorf("invalid policy value: %q, it needs to be pick_first or round_robin", policy)
+	}
+}
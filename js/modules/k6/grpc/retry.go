@@ -0,0 +1,327 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2021 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"go.k6.io/k6/lib/types"
+)
+
+// serviceConfig is the part of a gRPC service config, see
+// https://github.com/grpc/grpc/blob/master/doc/service_config.md, that k6 applies to the calls.
+type serviceConfig struct {
+	MethodConfig []methodConfig `json:"methodConfig"`
+}
+
+// methodConfig is the config of the methods that match any of its names. A name without a method
+// matches all the methods of its service and one without a service matches all the methods.
+type methodConfig struct {
+	Name          []methodName       `json:"name"`
+	Timeout       types.NullDuration `json:"timeout"`
+	RetryPolicy   *retryPolicy       `json:"retryPolicy"`
+	HedgingPolicy *hedgingPolicy     `json:"hedgingPolicy"`
+}
+
+type methodName struct {
+	Service string `json:"service"`
+	Method  string `json:"method"`
+}
+
+// retryPolicy makes the calls that fail with one of the retryable status codes be retried, after
+// an exponential backoff with jitter, until maxAttempts attempts have been made.
+type retryPolicy struct {
+	MaxAttempts          int            `json:"maxAttempts"`
+	InitialBackoff       types.Duration `json:"initialBackoff"`
+	MaxBackoff           types.Duration `json:"maxBackoff"`
+	BackoffMultiplier    float64        `json:"backoffMultiplier"`
+	RetryableStatusCodes []codes.Code   `json:"retryableStatusCodes"`
+}
+
+// hedgingPolicy makes up to maxAttempts attempts of the calls be sent, hedgingDelay apart, until
+// one of them succeeds or fails with a status code that isn't one of the non-fatal ones.
+type hedgingPolicy struct {
+	MaxAttempts         int            `json:"maxAttempts"`
+	HedgingDelay        types.Duration `json:"hedgingDelay"`
+	NonFatalStatusCodes []codes.Code   `json:"nonFatalStatusCodes"`
+}
+
+// callResult is the outcome of a call and of all of its attempts.
+type callResult struct {
+	resp     *dynamicpb.Message
+	header   metadata.MD
+	trailer  metadata.MD
+	err      error
+	attempts int
+}
+
+// parseServiceConfig parses a service config given either as a JSON string or as an object.
+func parseServiceConfig(v interface{}) (*serviceConfig, error) {
+	raw, ok := v.(string)
+	if !ok {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		raw = string(b)
+	}
+	var sc serviceConfig
+	if err := json.Unmarshal([]byte(raw), &sc); err != nil {
+		return nil, err
+	}
+	for _, mc := range sc.MethodConfig {
+		if mc.RetryPolicy != nil && mc.HedgingPolicy != nil {
+			return nil, errors.New("a method config can't have both a retryPolicy and a hedgingPolicy")
+		}
+		if mc.RetryPolicy != nil {
+			if err := mc.RetryPolicy.validate(); err != nil {
+				return nil, err
+			}
+		}
+		if mc.HedgingPolicy != nil {
+			if err := mc.HedgingPolicy.validate(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &sc, nil
+}
+
+// methodConfig returns the most specific config of the fully qualified method, or nil if there's none.
+func (sc *serviceConfig) methodConfig(method string) *methodConfig {
+	if sc == nil {
+		return nil
+	}
+	parts := strings.Split(strings.TrimPrefix(method, "/"), "/")
+	var svcConfig, defaultConfig *methodConfig
+	for i := range sc.MethodConfig {
+		mc := &sc.MethodConfig[i]
+		for _, name := range mc.Name {
+			switch {
+			case name.Service == parts[0] && name.Method == parts[1]:
+				return mc
+			case name.Service == parts[0] && name.Method == "" && svcConfig == nil:
+				svcConfig = mc
+			case name.Service == "" && defaultConfig == nil:
+				defaultConfig = mc
+			}
+		}
+	}
+	if svcConfig != nil {
+		return svcConfig
+	}
+	return defaultConfig
+}
+
+// parsePolicy parses the retry or hedging policy of a call, given as an object with the same
+// properties as in a service config.
+func parsePolicy(v interface{}, policy interface{ validate() error }) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, policy); err != nil {
+		return err
+	}
+	return policy.validate()
+}
+
+func (p *retryPolicy) validate() error {
+	switch {
+	case p.MaxAttempts < 2:
+		return errors.New("the retry policy maxAttempts must be greater than 1")
+	case p.InitialBackoff <= 0 || p.MaxBackoff <= 0:
+		return errors.New("the retry policy initialBackoff and maxBackoff must be greater than 0")
+	case p.BackoffMultiplier <= 0:
+		return errors.New("the retry policy backoffMultiplier must be greater than 0")
+	case len(p.RetryableStatusCodes) == 0:
+		return errors.New("the retry policy retryableStatusCodes can't be empty")
+	}
+	return nil
+}
+
+// backoff returns how long to wait before the next attempt, after the given one failed.
+func (p *retryPolicy) backoff(attempt int) time.Duration {
+	max := math.Min(
+		float64(p.InitialBackoff)*math.Pow(p.BackoffMultiplier, float64(attempt-1)),
+		float64(p.MaxBackoff),
+	)
+	return time.Duration(rand.Float64() * max) //nolint:gosec
+}
+
+func (p *hedgingPolicy) validate() error {
+	switch {
+	case p.MaxAttempts < 2:
+		return errors.New("the hedging policy maxAttempts must be greater than 1")
+	case p.HedgingDelay < 0:
+		return errors.New("the hedging policy hedgingDelay can't be negative")
+	}
+	return nil
+}
+
+func hasCode(list []codes.Code, code codes.Code) bool {
+	for _, c := range list {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// invokeAttempt makes a single attempt of a call, the attempt number is added to the
+// tags of its metrics if it isn't 0.
+func (c *Client) invokeAttempt(
+	ctx context.Context, method string, md protoreflect.MethodDescriptor,
+	req *dynamicpb.Message, tags map[string]string, attempt int,
+) callResult {
+	// every attempt gets its own tags, as they're changed by HandleRPC
+	attemptTags := make(map[string]string, len(tags)+1)
+	for k, v := range tags {
+		attemptTags[k] = v
+	}
+	if attempt > 0 {
+		attemptTags["attempt"] = strconv.Itoa(attempt)
+	}
+
+	res := callResult{
+		resp:     dynamicpb.NewMessage(md.Output()),
+		header:   metadata.New(nil),
+		trailer:  metadata.New(nil),
+		attempts: 1,
+	}
+	res.err = c.cc.Invoke(withTags(ctx, attemptTags), method, req, res.resp,
+		grpc.Header(&res.header), grpc.Trailer(&res.trailer))
+	return res
+}
+
+// invokeWithRetries makes attempts of a call until one doesn't fail with a retryable status code,
+// or the policy's maxAttempts or the deadline of the call is reached.
+func (c *Client) invokeWithRetries(
+	ctx context.Context, method string, md protoreflect.MethodDescriptor,
+	req *dynamicpb.Message, tags map[string]string, policy *retryPolicy,
+) callResult {
+	for attempt := 1; ; attempt++ {
+		res := c.invokeAttempt(ctx, method, md, req, tags, attempt)
+		res.attempts = attempt
+		if res.err == nil || attempt >= policy.MaxAttempts ||
+			!hasCode(policy.RetryableStatusCodes, status.Code(res.err)) {
+			return res
+		}
+
+		timer := time.NewTimer(policy.backoff(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return res
+		}
+	}
+}
+
+// invokeHedged sends a new attempt of a call every hedgingDelay, or right after one fails with a
+// non-fatal status code, until one of them succeeds or fails with a fatal one. The attempts that are
+// still in flight then are canceled.
+func (c *Client) invokeHedged(
+	ctx context.Context, method string, md protoreflect.MethodDescriptor,
+	req *dynamicpb.Message, tags map[string]string, policy *hedgingPolicy,
+) callResult {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan callResult, policy.MaxAttempts)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	var (
+		started, finished int
+		last              callResult
+	)
+	for {
+		select {
+		case <-timer.C:
+			started++
+			go func(attempt int) {
+				results <- c.invokeAttempt(ctx, method, md, req, tags, attempt)
+			}(started)
+			if started < policy.MaxAttempts {
+				timer.Reset(time.Duration(policy.HedgingDelay))
+			}
+		case last = <-results:
+			finished++
+			last.attempts = started
+			if last.err == nil || finished == policy.MaxAttempts ||
+				!hasCode(policy.NonFatalStatusCodes, status.Code(last.err)) {
+				return last
+			}
+			if started < policy.MaxAttempts && finished == started {
+				if !timer.Stop() {
+					<-timer.C
+				}
+				timer.Reset(0)
+			}
+		}
+	}
+}
+
+// callPolicies returns the timeout, the retry and the hedging policy of a call to the fully qualified method,
+// the ones in the params take precedence over the ones in the service config of the client.
+func (c *Client) callPolicies(method string, p params, raw map[string]interface{}) (
+	time.Duration, *retryPolicy, *hedgingPolicy,
+) {
+	timeout, retry, hedging := p.Timeout, p.Retry, p.Hedging
+	if mc := c.serviceConfig.methodConfig(method); mc != nil {
+		if _, ok := raw["timeout"]; !ok && mc.Timeout.Valid {
+			timeout = time.Duration(mc.Timeout.Duration)
+		}
+		if !p.PolicySet {
+			retry, hedging = mc.RetryPolicy, mc.HedgingPolicy
+		}
+	}
+	return timeout, retry, hedging
+}
+
+// callDeadline returns the deadline of a call that times out after the given timeout, or when the
+// iteration budget of the client runs out, if that happens first. A zero timeout means no timeout.
+func (c *Client) callDeadline(timeout time.Duration) (time.Time, bool) {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	if start := c.vu.State().IterationStart; c.iterationBudget > 0 && !start.IsZero() {
+		if budget := start.Add(c.iterationBudget); deadline.IsZero() || budget.Before(deadline) {
+			deadline = budget
+		}
+	}
+	return deadline, !deadline.IsZero()
+}
@@ -0,0 +1,69 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2021 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package grpc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+
+	"go.k6.io/k6/lib/types"
+)
+
+func TestParseServiceConfig(t *testing.T) {
+	t.Parallel()
+
+	sc, err := parseServiceConfig(`{"methodConfig": [
+		{"name": [{}], "timeout": "1s"},
+		{"name": [{"service": "pkg.Svc"}], "timeout": "2s", "retryPolicy": {
+			"maxAttempts": 4, "initialBackoff": "0.1s", "maxBackoff": "1s", "backoffMultiplier": 2,
+			"retryableStatusCodes": ["UNAVAILABLE", 4]
+		}},
+		{"name": [{"service": "pkg.Svc", "method": "Hedged"}], "hedgingPolicy": {"maxAttempts": 2, "hedgingDelay": 100}}
+	]}`)
+	require.NoError(t, err)
+
+	assert.Equal(t, types.NullDurationFrom(time.Second), sc.methodConfig("/other.Svc/Method").Timeout)
+	retry := sc.methodConfig("/pkg.Svc/Method").RetryPolicy
+	require.NotNil(t, retry)
+	assert.Equal(t, types.Duration(100*time.Millisecond), retry.InitialBackoff)
+	assert.Equal(t, []codes.Code{codes.Unavailable, codes.DeadlineExceeded}, retry.RetryableStatusCodes)
+	hedging := sc.methodConfig("/pkg.Svc/Hedged").HedgingPolicy
+	require.NotNil(t, hedging)
+	assert.Equal(t, types.Duration(100*time.Millisecond), hedging.HedgingDelay)
+
+	for i := 1; i < 10; i++ {
+		assert.LessOrEqual(t, retry.backoff(i), time.Second)
+	}
+	assert.Nil(t, (*serviceConfig)(nil).methodConfig("/pkg.Svc/Method"))
+
+	_, err = parseServiceConfig(map[string]interface{}{"methodConfig": []interface{}{map[string]interface{}{
+		"retryPolicy":   map[string]interface{}{"maxAttempts": 2},
+		"hedgingPolicy": map[string]interface{}{"maxAttempts": 2},
+	}}})
+	assert.EqualError(t, err, "a method config can't have both a retryPolicy and a hedgingPolicy")
+
+	_, err = parseServiceConfig(`{"methodConfig": [{"retryPolicy": {"maxAttempts": 2}}]}`)
+	assert.EqualError(t, err, "the retry policy initialBackoff and maxBackoff must be greater than 0")
+}
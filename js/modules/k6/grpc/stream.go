@@ -0,0 +1,278 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2021 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/stats"
+)
+
+var errStreamInInitContext = common.NewInitContextError("opening gRPC streams in the init context is not supported")
+
+// Stream is a client-streaming, server-streaming or bidirectional streaming RPC. The messages are
+// written with write() and the received ones are delivered to the data listeners on the event loop,
+// so the iteration doesn't end before the stream does.
+type Stream struct {
+	vu        modules.VU
+	method    string
+	md        protoreflect.MethodDescriptor
+	stream    grpc.ClientStream
+	tags      map[string]string
+	listeners map[string][]goja.Callable
+	sentCount int
+	// closed is set once end() has been called, after which nothing more can be written
+	closed bool
+}
+
+// streamEvent is a message, or the error or the end of the stream, received by the reading goroutine.
+type streamEvent struct {
+	msg map[string]interface{}
+	err error
+}
+
+// NewStream is the JS constructor for the grpc Stream, it's called with the connected client,
+// the fully qualified method name and the same params as client.invoke().
+func (mi *ModuleInstance) NewStream(call goja.ConstructorCall) *goja.Object {
+	rt := mi.vu.Runtime()
+	client, ok := call.Argument(0).Export().(*Client)
+	if !ok {
+		common.Throw(rt, errors.New("the first argument of a gRPC stream must be a client"))
+	}
+	var params map[string]interface{}
+	if v := call.Argument(2); !goja.IsUndefined(v) && !goja.IsNull(v) {
+		params, ok = v.Export().(map[string]interface{})
+		if !ok {
+			common.Throw(rt, errors.New("the gRPC stream params must be an object"))
+		}
+	}
+	s, err := client.openStream(call.Argument(1).String(), params)
+	if err != nil {
+		common.Throw(rt, err)
+	}
+	return rt.ToValue(s).ToObject(rt)
+}
+
+// openStream opens a stream of a streaming RPC by fully qualified method name. Unlike with invoke(),
+// the stream has no timeout, unless one is set in the params or the client has an iteration budget.
+func (c *Client) openStream(method string, params map[string]interface{}) (*Stream, error) {
+	state := c.vu.State()
+	if state == nil {
+		return nil, errStreamInInitContext
+	}
+	if c.cc == nil {
+		return nil, errors.New("no gRPC connection, you must call connect first")
+	}
+	if method == "" {
+		return nil, errors.New("method to stream cannot be empty")
+	}
+	if method[0] != '/' {
+		method = "/" + method
+	}
+	md := c.mds[method]
+	if md == nil {
+		return nil, fmt.Errorf("method %q not found in file descriptors", method)
+	}
+	if !md.IsStreamingClient() && !md.IsStreamingServer() {
+		return nil, fmt.Errorf("method %q is unary, it must be called with invoke", method)
+	}
+
+	p, err := c.parseParams(params)
+	if err != nil {
+		return nil, err
+	}
+	tags := c.callTags(method, p)
+	var timeout time.Duration
+	if _, ok := params["timeout"]; ok {
+		timeout = p.Timeout
+	}
+	ctx, cancel := withStream(withTags(c.callContext(p), tags)), context.CancelFunc(nil)
+	if deadline, ok := c.callDeadline(timeout); ok {
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+
+	stream, err := c.cc.NewStream(ctx, &grpc.StreamDesc{
+		StreamName:    string(md.Name()),
+		ServerStreams: md.IsStreamingServer(),
+		ClientStreams: md.IsStreamingClient(),
+	}, method)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	s := &Stream{
+		vu:        c.vu,
+		method:    method,
+		md:        md,
+		stream:    stream,
+		tags:      tags,
+		listeners: make(map[string][]goja.Callable),
+	}
+	s.pushCounter(state.BuiltinMetrics.GRPCStreams)
+	go s.read(c.vu.Context(), cancel, c.vu.RegisterCallback())
+	return s, nil
+}
+
+// On adds a listener of the data, error or end events of the stream. The data listeners get every
+// received message, the error listeners get the status of a stream that failed, as the error of
+// the response of invoke(), and the end listeners are called once the stream is over.
+func (s *Stream) On(event string, listener goja.Value) error {
+	fn, ok := goja.AssertFunction(listener)
+	if !ok {
+		return fmt.Errorf("the %s listener of a gRPC stream must be a function", event)
+	}
+	switch event {
+	case "data", "error", "end":
+		s.listeners[event] = append(s.listeners[event], fn)
+	default:
+		return fmt.Errorf("unknown gRPC stream event %q", event)
+	}
+	return nil
+}
+
+// Write sends a message on the stream.
+func (s *Stream) Write(msg goja.Value) error {
+	if s.closed {
+		return errors.New("can't write to a gRPC stream that has been ended")
+	}
+	if !s.md.IsStreamingClient() && s.sentCount > 0 {
+		return fmt.Errorf("method %q isn't client-streaming, only one message can be written", s.method)
+	}
+	reqdm, err := toMessage(s.vu.Runtime(), s.md.Input(), msg)
+	if err != nil {
+		return err
+	}
+	if err := s.stream.SendMsg(reqdm); err != nil {
+		// io.EOF means that the stream was closed by the server, its status is received by RecvMsg
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		return err
+	}
+	s.sentCount++
+	s.pushCounter(s.vu.State().BuiltinMetrics.GRPCStreamsMessagesSent)
+	return nil
+}
+
+// End closes the sending side of the stream, signaling the server that no more messages will be written.
+func (s *Stream) End() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.stream.CloseSend()
+}
+
+// read receives the messages of the stream until it's over and delivers them to the listeners on the event loop.
+// Every delivery registers the callback of the next one, so the event loop waits for the stream to end.
+func (s *Stream) read(vuCtx context.Context, cancel context.CancelFunc, runOnLoop func(func() error)) {
+	defer cancel()
+	var (
+		mu        sync.Mutex
+		abandoned bool
+	)
+	for {
+		ev := s.receive()
+		next := make(chan func(func() error), 1)
+		runOnLoop(func() error {
+			if ev.err == nil {
+				mu.Lock()
+				if !abandoned {
+					next <- s.vu.RegisterCallback()
+				}
+				mu.Unlock()
+				s.pushCounter(s.vu.State().BuiltinMetrics.GRPCStreamsMessagesReceived)
+				return s.emit("data", s.vu.Runtime().ToValue(ev.msg))
+			}
+			if !errors.Is(ev.err, io.EOF) {
+				if err := s.emit("error", s.vu.Runtime().ToValue(statusToObject(status.Convert(ev.err)))); err != nil {
+					return err
+				}
+			}
+			return s.emit("end")
+		})
+		if ev.err != nil {
+			return
+		}
+		select {
+		case runOnLoop = <-next:
+		case <-vuCtx.Done():
+			// the iteration is over, so the callback might never be run, and if it already
+			// was, the one it registered has to be released for the event loop to finish
+			mu.Lock()
+			abandoned = true
+			select {
+			case runOnLoop = <-next:
+				runOnLoop(func() error { return nil })
+			default:
+			}
+			mu.Unlock()
+			return
+		}
+	}
+}
+
+func (s *Stream) receive() streamEvent {
+	msg := dynamicpb.NewMessage(s.md.Output())
+	if err := s.stream.RecvMsg(msg); err != nil {
+		return streamEvent{err: err}
+	}
+	return streamEvent{msg: messageToObject(msg)}
+}
+
+func (s *Stream) emit(event string, args ...goja.Value) error {
+	for _, fn := range s.listeners[event] {
+		if _, err := fn(goja.Undefined(), args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Stream) pushCounter(metric *stats.Metric) {
+	state := s.vu.State()
+	mTags := make(map[string]string, len(s.tags))
+	for k, v := range s.tags {
+		mTags[k] = v
+	}
+	stats.PushIfNotDone(s.vu.Context(), state.Samples, stats.Sample{
+		Metric: metric,
+		Tags:   stats.IntoSampleTags(&mTags),
+		Value:  1,
+		Time:   time.Now(),
+	})
+}
@@ -0,0 +1,248 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2021 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package grpc
+
+import (
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/grpc_testing"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib/fsext"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/lib/testutils/httpmultibin"
+	"go.k6.io/k6/stats"
+)
+
+func newTestVU(t *testing.T) (*httpmultibin.HTTPMultiBin, *modulestest.LoopVU, chan stats.SampleContainer) {
+	t.Helper()
+	tb := httpmultibin.NewHTTPMultiBin(t)
+	testVU, samples := modulestest.NewTestVU(t, tb, stats.TagName, stats.TagURL)
+	vu := modulestest.NewLoopVU(testVU)
+
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+	fs := afero.NewOsFs()
+	if isWindows {
+		fs = fsext.NewTrimFilePathSeparatorFs(fs)
+	}
+	// the protos are loaded in the init context, without a state
+	state := vu.StateField
+	vu.StateField = nil
+	vu.InitEnvField = &common.InitEnvironment{
+		Logger:      logrus.New(),
+		CWD:         &url.URL{Path: cwd},
+		FileSystems: map[string]afero.Fs{"file": fs},
+	}
+	m, ok := New().NewModuleInstance(vu).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, vu.Runtime().Set("grpc", m.Exports().Named))
+
+	_, err = vu.Runtime().RunString(`
+		var client = new grpc.Client();
+		client.load([], "../../../../vendor/google.golang.org/grpc/test/grpc_testing/test.proto");`)
+	require.NoError(t, err)
+
+	vu.StateField = state
+	return tb, vu, samples
+}
+
+func TestStream(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Bidirectional", func(t *testing.T) {
+		t.Parallel()
+		tb, vu, samples := newTestVU(t)
+		tb.GRPCStub.FullDuplexCallFunc = func(stream grpc_testing.TestService_FullDuplexCallServer) error {
+			for {
+				req, err := stream.Recv()
+				if errors.Is(err, io.EOF) {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				if err := stream.Send(&grpc_testing.StreamingOutputCallResponse{Payload: req.Payload}); err != nil {
+					return err
+				}
+			}
+		}
+
+		_, err := vu.Runtime().RunString(tb.Replacer.Replace(`
+			client.connect("GRPCBIN_ADDR");
+			var received = [], ended = false;
+			var stream = new grpc.Stream(client, "grpc.testing.TestService/FullDuplexCall");
+			stream.on("data", function(msg) {
+				received.push(msg.payload.body);
+				if (received.length === 2) {
+					stream.write({ payload: { body: "Mw==" } });
+					stream.end();
+				}
+			});
+			stream.on("error", function(e) { throw new Error("unexpected error " + JSON.stringify(e)); });
+			stream.on("end", function() { ended = true; });
+			stream.write({ payload: { body: "MQ==" } });
+			stream.write({ payload: { body: "Mg==" } });
+		`))
+		require.NoError(t, err)
+		require.NoError(t, vu.Run())
+		v, err := vu.Runtime().RunString(`ended && received.join(",")`)
+		require.NoError(t, err)
+		assert.Equal(t, "MQ==,Mg==,Mw==", v.String())
+
+		counts := map[string]float64{}
+		for _, c := range stats.GetBufferedSamples(samples) {
+			for _, s := range c.GetSamples() {
+				surl, _ := s.Tags.Get("url")
+				assert.Equal(t, tb.Replacer.Replace("GRPCBIN_ADDR/grpc.testing.TestService/FullDuplexCall"), surl)
+				counts[s.Metric.Name] += s.Value
+			}
+		}
+		assert.Equal(t, float64(1), counts[metrics.GRPCStreamsName])
+		assert.Equal(t, float64(3), counts[metrics.GRPCStreamsMessagesSentName])
+		assert.Equal(t, float64(3), counts[metrics.GRPCStreamsMessagesReceivedName])
+		assert.Contains(t, counts, metrics.GRPCStreamDurationName)
+		assert.NotContains(t, counts, metrics.GRPCReqDurationName)
+	})
+
+	t.Run("ClientStreaming", func(t *testing.T) {
+		t.Parallel()
+		tb, vu, _ := newTestVU(t)
+		tb.GRPCStub.StreamingInputCallFunc = func(stream grpc_testing.TestService_StreamingInputCallServer) error {
+			var size int32
+			for {
+				req, err := stream.Recv()
+				if errors.Is(err, io.EOF) {
+					return stream.SendAndClose(&grpc_testing.StreamingInputCallResponse{AggregatedPayloadSize: size})
+				}
+				if err != nil {
+					return err
+				}
+				size += int32(len(req.Payload.Body))
+			}
+		}
+
+		_, err := vu.Runtime().RunString(tb.Replacer.Replace(`
+			client.connect("GRPCBIN_ADDR");
+			var size;
+			var stream = new grpc.Stream(client, "grpc.testing.TestService/StreamingInputCall", { timeout: "10s" });
+			stream.on("data", function(msg) { size = msg.aggregatedPayloadSize; });
+			stream.write({ payload: { body: "MTI=" } });
+			stream.write({ payload: { body: "MzQ1" } });
+			stream.end();
+		`))
+		require.NoError(t, err)
+		require.NoError(t, vu.Run())
+		assert.Equal(t, int64(5), vu.Runtime().Get("size").ToInteger())
+	})
+
+	t.Run("ServerStreaming", func(t *testing.T) {
+		t.Parallel()
+		tb, vu, _ := newTestVU(t)
+		tb.GRPCStub.StreamingOutputCallFunc = func(req *grpc_testing.StreamingOutputCallRequest,
+			stream grpc_testing.TestService_StreamingOutputCallServer) error {
+			for _, p := range req.ResponseParameters {
+				body := make([]byte, p.Size)
+				if err := stream.Send(&grpc_testing.StreamingOutputCallResponse{
+					Payload: &grpc_testing.Payload{Body: body},
+				}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		_, err := vu.Runtime().RunString(tb.Replacer.Replace(`
+			client.connect("GRPCBIN_ADDR");
+			var count = 0;
+			var stream = new grpc.Stream(client, "grpc.testing.TestService/StreamingOutputCall");
+			stream.on("data", function() { count++; });
+			stream.write({ responseParameters: [{ size: 1 }, { size: 2 }, { size: 3 }] });
+			stream.end();
+		`))
+		require.NoError(t, err)
+		require.NoError(t, vu.Run())
+		assert.Equal(t, int64(3), vu.Runtime().Get("count").ToInteger())
+
+		_, err = vu.Runtime().RunString(`
+			var stream = new grpc.Stream(client, "grpc.testing.TestService/StreamingOutputCall");
+			stream.write({});
+			stream.write({});
+		`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "only one message can be written")
+		require.NoError(t, vu.Run())
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		t.Parallel()
+		tb, vu, _ := newTestVU(t)
+		tb.GRPCStub.FullDuplexCallFunc = func(grpc_testing.TestService_FullDuplexCallServer) error {
+			return status.Error(codes.NotFound, "not found")
+		}
+
+		_, err := vu.Runtime().RunString(tb.Replacer.Replace(`
+			client.connect("GRPCBIN_ADDR");
+			var events = [];
+			var stream = new grpc.Stream(client, "grpc.testing.TestService/FullDuplexCall");
+			stream.on("error", function(e) { events.push("error " + e.code + " " + e.message); });
+			stream.on("end", function() { events.push("end"); });
+		`))
+		require.NoError(t, err)
+		require.NoError(t, vu.Run())
+		v, err := vu.Runtime().RunString(`events.join(",")`)
+		require.NoError(t, err)
+		assert.Equal(t, "error 5 not found,end", v.String())
+	})
+
+	t.Run("InvalidArguments", func(t *testing.T) {
+		t.Parallel()
+		tb, vu, _ := newTestVU(t)
+
+		_, err := vu.Runtime().RunString(tb.Replacer.Replace(`
+			client.connect("GRPCBIN_ADDR");
+			new grpc.Stream(client, "grpc.testing.TestService/UnaryCall");
+		`))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "is unary, it must be called with invoke")
+
+		_, err = vu.Runtime().RunString(`new grpc.Stream({}, "grpc.testing.TestService/FullDuplexCall");`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "must be a client")
+
+		_, err = vu.Runtime().RunString(`
+			new grpc.Stream(client, "grpc.testing.TestService/FullDuplexCall").on("message", function() {});
+		`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `unknown gRPC stream event "message"`)
+	})
+}
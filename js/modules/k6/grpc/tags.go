@@ -22,7 +22,10 @@ package grpc
 
 import "context"
 
-type ctxKeyTags struct{}
+type (
+	ctxKeyTags   struct{}
+	ctxKeyStream struct{}
+)
 
 type reqtags map[string]string
 
@@ -37,3 +40,13 @@ func getTags(ctx context.Context) reqtags {
 	}
 	return v.(reqtags)
 }
+
+// withStream marks the context of a streaming RPC, whose duration is measured by grpc_stream_duration.
+func withStream(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ctxKeyStream{}, true)
+}
+
+func isStream(ctx context.Context) bool {
+	v, _ := ctx.Value(ctxKeyStream{}).(bool)
+	return v
+}
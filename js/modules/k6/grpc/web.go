@@ -0,0 +1,375 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2021 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package grpc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/stats"
+)
+
+const (
+	// the flag of the frames with the trailers, the others have messages
+	webTrailerFrame = 0x80
+	webFrameHeader  = 5
+)
+
+// webClient sends the calls with the gRPC-Web protocol, see
+// https://github.com/grpc/grpc/blob/master/doc/PROTOCOL-WEB.md, over HTTP/1.1 or HTTP/2, so that they can
+// go through the gRPC-Web proxies and gateways of the browser clients. Only unary calls are supported.
+type webClient struct {
+	vu      modules.VU
+	baseURL string
+	// text is true for the grpc-web-text mode, in which the bodies are base64 encoded
+	text      bool
+	userAgent string
+	client    *http.Client
+}
+
+var _ grpc.ClientConnInterface = &webClient{}
+
+// newWebClient returns a gRPC-Web client for the address, which is either a host:port, in which case
+// https is used unless plaintext is set, or a base URL.
+func newWebClient(vu modules.VU, addr string, plaintext, text bool) *webClient {
+	baseURL := strings.TrimSuffix(addr, "/")
+	if !strings.HasPrefix(baseURL, "http://") && !strings.HasPrefix(baseURL, "https://") {
+		if plaintext {
+			baseURL = "http://" + baseURL
+		} else {
+			baseURL = "https://" + baseURL
+		}
+	}
+	state := vu.State()
+	return &webClient{
+		vu:        vu,
+		baseURL:   baseURL,
+		text:      text,
+		userAgent: state.Options.UserAgent.String,
+		client:    &http.Client{Transport: state.Transport},
+	}
+}
+
+func (w *webClient) contentType() string {
+	if w.text {
+		return "application/grpc-web-text+proto"
+	}
+	return "application/grpc-web+proto"
+}
+
+// Invoke implements the grpc.ClientConnInterface interface.
+func (w *webClient) Invoke(
+	ctx context.Context, method string, args interface{}, reply interface{}, opts ...grpc.CallOption,
+) error {
+	start := time.Now()
+	header, trailer, remoteAddr, err := w.roundTrip(ctx, method, args, reply)
+	end := time.Now()
+
+	for _, opt := range opts {
+		switch o := opt.(type) {
+		case grpc.HeaderCallOption:
+			*o.HeaderAddr = header
+		case grpc.TrailerCallOption:
+			*o.TrailerAddr = trailer
+		}
+	}
+	w.pushDuration(ctx, start, end, remoteAddr, err)
+	return err
+}
+
+// NewStream implements the grpc.ClientConnInterface interface.
+func (*webClient) NewStream(context.Context, *grpc.StreamDesc, string, ...grpc.CallOption) (grpc.ClientStream, error) {
+	return nil, errors.New("only unary calls with invoke are supported with the gRPC-Web protocol")
+}
+
+// roundTrip sends the request of a call and reads its response, it returns the headers, the trailers
+// and the address of the server, along with the status of the call as an error.
+func (w *webClient) roundTrip(ctx context.Context, method string, args, reply interface{}) (
+	metadata.MD, metadata.MD, string, error,
+) {
+	header, trailer := metadata.New(nil), metadata.New(nil)
+	msg, ok := args.(proto.Message)
+	if !ok {
+		return header, trailer, "", status.Errorf(codes.Internal, "invalid request message %T", args)
+	}
+	body, err := w.encode(msg)
+	if err != nil {
+		return header, trailer, "", status.Error(codes.Internal, err.Error())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.baseURL+method, bytes.NewReader(body))
+	if err != nil {
+		return header, trailer, "", status.Error(codes.Internal, err.Error())
+	}
+	if md, ok := metadata.FromOutgoingContext(ctx); ok {
+		for k, vs := range md {
+			for _, v := range vs {
+				req.Header.Add(k, v)
+			}
+		}
+	}
+	req.Header.Set("Content-Type", w.contentType())
+	req.Header.Set("Accept", w.contentType())
+	req.Header.Set("X-Grpc-Web", "1")
+	if w.userAgent != "" {
+		req.Header.Set("X-User-Agent", w.userAgent)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		req.Header.Set("Grpc-Timeout", encodeTimeout(time.Until(deadline)))
+	}
+
+	var remoteAddr string
+	ctx = withRemoteAddr(ctx, &remoteAddr)
+	resp, err := w.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return header, trailer, remoteAddr, status.FromContextError(ctxErr(ctx, err)).Err()
+	}
+	defer func() { _ = resp.Body.Close() }()
+	for k, vs := range resp.Header {
+		header[strings.ToLower(k)] = vs
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return header, trailer, remoteAddr, status.Errorf(httpStatusCode(resp.StatusCode),
+			"unexpected HTTP status code received from server: %d (%s)", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+	// a response without a body has the status in its headers
+	if code := resp.Header.Get("Grpc-Status"); code != "" {
+		return header, trailer, remoteAddr, statusFromMetadata(header)
+	}
+
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return header, trailer, remoteAddr, status.FromContextError(ctxErr(ctx, err)).Err()
+	}
+	if w.text {
+		if raw, err = decodeWebText(raw); err != nil {
+			return header, trailer, remoteAddr, status.Error(codes.Internal, err.Error())
+		}
+	}
+	err = readWebFrames(raw, reply, trailer)
+	return header, trailer, remoteAddr, err
+}
+
+// encode returns the framed message of a request, base64 encoded in the text mode.
+func (w *webClient) encode(msg proto.Message) ([]byte, error) {
+	b, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	frame := make([]byte, webFrameHeader+len(b))
+	binary.BigEndian.PutUint32(frame[1:webFrameHeader], uint32(len(b)))
+	copy(frame[webFrameHeader:], b)
+	if !w.text {
+		return frame, nil
+	}
+	encoded := make([]byte, base64.StdEncoding.EncodedLen(len(frame)))
+	base64.StdEncoding.Encode(encoded, frame)
+	return encoded, nil
+}
+
+// readWebFrames reads the message and the trailers of a response, it returns the status of the call.
+func readWebFrames(raw []byte, reply interface{}, trailer metadata.MD) error {
+	var gotMessage, gotTrailers bool
+	for len(raw) > 0 {
+		if len(raw) < webFrameHeader {
+			return status.Error(codes.Internal, "malformed gRPC-Web response frame")
+		}
+		flag, length := raw[0], binary.BigEndian.Uint32(raw[1:webFrameHeader])
+		if uint64(len(raw)-webFrameHeader) < uint64(length) {
+			return status.Error(codes.Internal, "malformed gRPC-Web response frame")
+		}
+		data := raw[webFrameHeader : webFrameHeader+int(length)]
+		raw = raw[webFrameHeader+int(length):]
+
+		if flag&webTrailerFrame != 0 {
+			tp := textproto.NewReader(bufio.NewReader(io.MultiReader(bytes.NewReader(data), strings.NewReader("\r\n"))))
+			mimeHeader, err := tp.ReadMIMEHeader()
+			if err != nil && !errors.Is(err, io.EOF) {
+				return status.Errorf(codes.Internal, "malformed gRPC-Web trailers: %s", err)
+			}
+			for k, vs := range mimeHeader {
+				trailer[strings.ToLower(k)] = vs
+			}
+			gotTrailers = true
+			continue
+		}
+		if gotMessage {
+			return status.Error(codes.Internal, "more than one message in the response of a unary call")
+		}
+		msg, ok := reply.(proto.Message)
+		if !ok {
+			return status.Errorf(codes.Internal, "invalid response message %T", reply)
+		}
+		if err := proto.Unmarshal(data, msg); err != nil {
+			return status.Errorf(codes.Internal, "can't unmarshal the response message: %s", err)
+		}
+		gotMessage = true
+	}
+	if !gotTrailers {
+		return status.Error(codes.Internal, "the gRPC-Web response has no trailers")
+	}
+	return statusFromMetadata(trailer)
+}
+
+// decodeWebText decodes the body of a grpc-web-text response, which may be a series of
+// base64 encoded chunks, each with its own padding.
+func decodeWebText(raw []byte) ([]byte, error) {
+	raw = bytes.TrimSpace(raw)
+	var out []byte
+	for len(raw) > 0 {
+		// a chunk ends after its padding, or at the end of the body
+		end := len(raw)
+		if i := bytes.IndexByte(raw, '='); i >= 0 {
+			end = i
+			for end < len(raw) && raw[end] == '=' {
+				end++
+			}
+		}
+		chunk := make([]byte, base64.StdEncoding.DecodedLen(end))
+		n, err := base64.StdEncoding.Decode(chunk, raw[:end])
+		if err != nil {
+			return nil, fmt.Errorf("malformed grpc-web-text response: %w", err)
+		}
+		out = append(out, chunk[:n]...)
+		raw = raw[end:]
+	}
+	return out, nil
+}
+
+// statusFromMetadata returns the status in the grpc-status and grpc-message headers or trailers.
+func statusFromMetadata(md metadata.MD) error {
+	values := md.Get("grpc-status")
+	if len(values) == 0 {
+		return status.Error(codes.Internal, "the gRPC-Web response has no grpc-status")
+	}
+	code, err := strconv.Atoi(values[0])
+	if err != nil {
+		return status.Errorf(codes.Internal, "invalid grpc-status %q", values[0])
+	}
+	var message string
+	if messages := md.Get("grpc-message"); len(messages) > 0 {
+		message = decodeGRPCMessage(messages[0])
+	}
+	return status.Error(codes.Code(code), message)
+}
+
+// decodeGRPCMessage decodes the percent-encoding of a grpc-message.
+func decodeGRPCMessage(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		if msg[i] == '%' && i+2 < len(msg) {
+			if v, err := strconv.ParseUint(msg[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(msg[i])
+	}
+	return b.String()
+}
+
+// encodeTimeout encodes a timeout for the grpc-timeout header, in milliseconds.
+func encodeTimeout(d time.Duration) string {
+	ms := d.Milliseconds()
+	if ms < 1 {
+		ms = 1
+	}
+	return strconv.FormatInt(ms, 10) + "m"
+}
+
+// httpStatusCode returns the status code of a call whose HTTP response had the status, see
+// https://github.com/grpc/grpc/blob/master/doc/http-grpc-status-mapping.md.
+func httpStatusCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest:
+		return codes.Internal
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.Unimplemented
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return codes.Unavailable
+	default:
+		return codes.Unknown
+	}
+}
+
+// withRemoteAddr returns a context that makes the address of the server that an HTTP request is sent to be saved in addr.
+func withRemoteAddr(ctx context.Context, addr *string) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			*addr = info.Conn.RemoteAddr().String()
+		},
+	})
+}
+
+func ctxErr(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// pushDuration emits the grpc_req_duration of a call, as HandleRPC does for the other protocol.
+func (w *webClient) pushDuration(ctx context.Context, start, end time.Time, remoteAddr string, err error) {
+	state := w.vu.State()
+	tags := getTags(ctx)
+	if state.Options.SystemTags.Has(stats.TagIP) && remoteAddr != "" {
+		if ip, _, err := net.SplitHostPort(remoteAddr); err == nil {
+			tags["ip"] = ip
+		}
+	}
+	if state.Options.SystemTags.Has(stats.TagStatus) {
+		tags["status"] = strconv.Itoa(int(status.Code(err)))
+	}
+	mTags := map[string]string(tags)
+	stats.PushIfNotDone(ctx, state.Samples, stats.Sample{
+		Metric: state.BuiltinMetrics.GRPCReqDuration,
+		Tags:   stats.IntoSampleTags(&mTags),
+		Value:  stats.D(end.Sub(start)),
+		Time:   end,
+	})
+}
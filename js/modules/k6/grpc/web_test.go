@@ -0,0 +1,133 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2021 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package grpc
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/test/grpc_testing"
+	"google.golang.org/protobuf/proto"
+
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/stats"
+)
+
+func webFrame(flag byte, data []byte) []byte {
+	frame := make([]byte, webFrameHeader, webFrameHeader+len(data))
+	frame[0] = flag
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(data)))
+	return append(frame, data...)
+}
+
+func TestClientGRPCWeb(t *testing.T) {
+	t.Parallel()
+	tb, vu, samples := newTestVU(t)
+
+	// a gRPC-Web gateway of UnaryCall that responds with the x-scope metadata of the request,
+	// or with an error without a body if the response size is negative
+	tb.Mux.HandleFunc("/grpc.testing.TestService/UnaryCall", func(w http.ResponseWriter, r *http.Request) {
+		text := r.Header.Get("Content-Type") == "application/grpc-web-text+proto"
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		if text {
+			body, err = base64.StdEncoding.DecodeString(string(body))
+			require.NoError(t, err)
+		}
+		require.Equal(t, "1", r.Header.Get("X-Grpc-Web"))
+		require.NotEmpty(t, r.Header.Get("Grpc-Timeout"))
+
+		var req grpc_testing.SimpleRequest
+		require.NoError(t, proto.Unmarshal(body[webFrameHeader:], &req))
+		w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+		if req.ResponseSize < 0 {
+			w.Header().Set("Grpc-Status", "5")
+			w.Header().Set("Grpc-Message", "no%20such%20user")
+			return
+		}
+
+		msg, err := proto.Marshal(&grpc_testing.SimpleResponse{
+			Username: "k6", OauthScope: r.Header.Get("X-Scope"),
+		})
+		require.NoError(t, err)
+		frames := [][]byte{webFrame(0, msg), webFrame(webTrailerFrame, []byte("grpc-status: 0\r\nx-trailer: t\r\n"))}
+		for _, frame := range frames {
+			if text {
+				frame = []byte(base64.StdEncoding.EncodeToString(frame))
+			}
+			_, _ = w.Write(frame)
+		}
+	})
+
+	_, err := vu.Runtime().RunString(tb.Replacer.Replace(`
+		for (var protocol of ["grpc-web", "grpc-web-text"]) {
+			client.connect("HTTPBIN_URL", { protocol: protocol });
+			var resp = client.invoke("grpc.testing.TestService/UnaryCall", { fillUsername: true },
+				{ metadata: { "x-scope": protocol } });
+			if (resp.status !== grpc.StatusOK || resp.message.oauthScope !== protocol || !resp.message.username) {
+				throw new Error(protocol + " unexpected response: " + JSON.stringify(resp));
+			}
+			if (resp.trailers["x-trailer"][0] !== "t") {
+				throw new Error(protocol + " unexpected trailers: " + JSON.stringify(resp.trailers));
+			}
+			resp = client.invoke("grpc.testing.TestService/UnaryCall", { responseSize: -1 });
+			if (resp.status !== grpc.StatusNotFound || resp.error.message !== "no such user") {
+				throw new Error(protocol + " unexpected error: " + JSON.stringify(resp));
+			}
+			client.close();
+		}
+	`))
+	require.NoError(t, err)
+
+	var urls []string
+	for _, c := range stats.GetBufferedSamples(samples) {
+		for _, s := range c.GetSamples() {
+			require.Equal(t, metrics.GRPCReqDurationName, s.Metric.Name)
+			url, _ := s.Tags.Get("url")
+			urls = append(urls, url)
+		}
+	}
+	assert.Equal(t, strings.Repeat(tb.Replacer.Replace("HTTPBIN_URL/grpc.testing.TestService/UnaryCall,"), 4),
+		strings.Join(urls, ",")+",")
+
+	_, err = vu.Runtime().RunString(tb.Replacer.Replace(`
+		client.connect("HTTPBIN_URL", { protocol: "grpc-web" });
+		new grpc.Stream(client, "grpc.testing.TestService/FullDuplexCall");
+	`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "only unary calls with invoke are supported with the gRPC-Web protocol")
+}
+
+func TestDecodeWebText(t *testing.T) {
+	t.Parallel()
+	b, err := decodeWebText([]byte("YQ==YmM=ZGVm\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "abcdef", string(b))
+
+	_, err = decodeWebText([]byte("Y!=="))
+	assert.Error(t, err)
+}
@@ -0,0 +1,83 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package http
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dop251/goja"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/lib/netext/httpext"
+)
+
+// SetAWSv4Signer sets the AWS Signature Version 4 credentials used to sign all the requests that don't have
+// their own in their params, null disables the signing.
+func (c *Client) SetAWSv4Signer(val goja.Value) {
+	signer, err := parseAWSv4Signer(val)
+	if err != nil {
+		common.Throw(c.moduleInstance.vu.Runtime(), err)
+	}
+	c.awsv4Signer = signer
+}
+
+// parseAWSv4Signer parses the AWS credentials of a signer like:
+//
+//	{ accessKeyId: "...", secretAccessKey: "...", sessionToken: "...", region: "us-east-1", service: "s3",
+//	  unsignedPayload: false }
+//
+// where the session token and unsignedPayload are optional. It returns nil for null, undefined and false,
+// so that the signing can be disabled for single requests.
+func parseAWSv4Signer(val goja.Value) (*httpext.AWSv4Signer, error) {
+	if enabled, ok := val.Export().(bool); !isSet(val) || (ok && !enabled) {
+		return nil, nil //nolint:nilnil
+	}
+	obj, ok := val.(*goja.Object)
+	if !ok {
+		return nil, errors.New("the AWS signer credentials must be an object")
+	}
+
+	signer := &httpext.AWSv4Signer{}
+	for _, k := range obj.Keys() {
+		v := obj.Get(k)
+		switch k {
+		case "accessKeyId":
+			signer.AccessKeyID = v.String()
+		case "secretAccessKey":
+			signer.SecretAccessKey = v.String()
+		case "sessionToken":
+			signer.SessionToken = v.String()
+		case "region":
+			signer.Region = v.String()
+		case "service":
+			signer.Service = v.String()
+		case "unsignedPayload":
+			signer.UnsignedPayload = v.ToBoolean()
+		default:
+			return nil, fmt.Errorf("unknown AWS signer option %s", k)
+		}
+	}
+	if signer.AccessKeyID == "" || signer.SecretAccessKey == "" || signer.Region == "" || signer.Service == "" {
+		return nil, errors.New("the AWS signer requires an accessKeyId, secretAccessKey, region and service")
+	}
+	return signer, nil
+}
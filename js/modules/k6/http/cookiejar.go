@@ -21,15 +21,17 @@
 package http
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"net/http/cookiejar"
 	neturl "net/url"
 	"strings"
 	"time"
 
 	"github.com/dop251/goja"
 	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/lib"
 )
 
 // ErrJarForbiddenInInitContext is used when a cookie jar was made in the init context
@@ -41,7 +43,7 @@ type CookieJar struct {
 	moduleInstance *ModuleInstance
 	// js is to make it not be accessible from inside goja/js, the json is
 	// for when it is returned from setup().
-	Jar *cookiejar.Jar `js:"-" json:"-"`
+	Jar *lib.CookieJar `js:"-" json:"-"`
 }
 
 // CookiesForURL return the cookies for a given url as a map of key and values
@@ -100,3 +102,31 @@ func (j CookieJar) Set(url, name, value string, opts goja.Value) (bool, error) {
 	j.Jar.SetCookies(u, []*http.Cookie{&c})
 	return true, nil
 }
+
+// ExportCookies returns the cookies in the jar as plain objects, which can be serialized to JSON, e.g. by
+// returning them from setup(), and set in another jar with importCookies().
+func (j CookieJar) ExportCookies() (goja.Value, error) {
+	data, err := json.Marshal(j.Jar.Export())
+	if err != nil {
+		return nil, err
+	}
+	rt := j.moduleInstance.vu.Runtime()
+	parse, _ := goja.AssertFunction(rt.Get("JSON").ToObject(rt).Get("parse"))
+	return parse(goja.Undefined(), rt.ToValue(string(data)))
+}
+
+// ImportCookies sets the cookies previously returned by exportCookies() in the jar.
+func (j CookieJar) ImportCookies(cookies goja.Value) error {
+	if !isSet(cookies) {
+		return errors.New("importCookies requires an array of cookies as argument")
+	}
+	data, err := json.Marshal(cookies.Export())
+	if err != nil {
+		return err
+	}
+	var exported []lib.ExportedCookie
+	if err = json.Unmarshal(data, &exported); err != nil {
+		return fmt.Errorf("invalid cookies: %w", err)
+	}
+	return j.Jar.Import(exported)
+}
@@ -0,0 +1,734 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package http
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/dop251/goja"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modules/k6/experimental"
+	"go.k6.io/k6/lib/netext/httpext"
+)
+
+// fetch implements the fetch() Web API and its Headers, Request and Response classes on top of the same
+// httpext.MakeRequest as the rest of the module, so the requests emit the usual http_req_* metrics.
+//
+// Unlike http.request(), the request is made in the background and the returned promise is resolved on the event loop.
+// The response bodies are streamed, the promise is resolved once the headers are received and the body is read on
+// demand, in the background too, with the stream response type of httpext.
+// The objects are plain JS objects with the methods defined on them, the Go values behind them are kept under symbols
+// so that they can be told apart from objects that only look like them.
+type fetch struct {
+	mi *ModuleInstance
+
+	headersSym, requestSym, responseSym *goja.Symbol
+	headersClass, requestClass          *goja.Object
+	responseClass                       *goja.Object
+}
+
+func newFetch(mi *ModuleInstance) *fetch {
+	rt := mi.vu.Runtime()
+	f := &fetch{
+		mi:          mi,
+		headersSym:  goja.NewSymbol("Headers"),
+		requestSym:  goja.NewSymbol("Request"),
+		responseSym: goja.NewSymbol("Response"),
+	}
+	f.headersClass = rt.ToValue(f.newHeadersObject).ToObject(rt)
+	f.requestClass = rt.ToValue(f.newRequestObject).ToObject(rt)
+	f.responseClass = rt.ToValue(f.newResponseObject).ToObject(rt)
+	return f
+}
+
+// fetchHeaders is the Go side of a Headers object, the names are kept lowercased.
+type fetchHeaders struct {
+	values map[string][]string
+}
+
+func (h *fetchHeaders) names() []string {
+	names := make([]string, 0, len(h.values))
+	for name := range h.values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (h *fetchHeaders) get(name string) (string, bool) {
+	values, ok := h.values[strings.ToLower(name)]
+	return strings.Join(values, ", "), ok
+}
+
+func (h *fetchHeaders) clone() *fetchHeaders {
+	c := &fetchHeaders{values: make(map[string][]string, len(h.values))}
+	for name, values := range h.values {
+		c.values[name] = append([]string{}, values...)
+	}
+	return c
+}
+
+// fetchRequest is the Go side of a Request object.
+type fetchRequest struct {
+	method   string
+	url      string
+	headers  *fetchHeaders
+	body     []byte
+	redirect string
+	signal   goja.Value
+	// params are the k6 specific options, e.g. tags, which are passed to the request as they are
+	params *goja.Object
+}
+
+// fetchResponse is the Go side of a Response object.
+type fetchResponse struct {
+	status     int
+	statusText string
+	url        string
+	redirected bool
+	headers    *fetchHeaders
+	body       []byte
+	// stream is the body that is read on demand, the fetched responses have it instead of the body
+	stream   io.ReadCloser
+	bodyUsed bool
+	// lastRead is closed once the last queued read of the stream is done, the next one waits for it
+	lastRead chan struct{}
+}
+
+// bodyStream returns the stream of the body, or nil if there is no body.
+func (r *fetchResponse) bodyStream() io.ReadCloser {
+	if r.stream == nil && r.body != nil {
+		r.stream, r.body = io.NopCloser(bytes.NewReader(r.body)), nil
+	}
+	return r.stream
+}
+
+// Fetch starts a request and returns a promise for its Response.
+func (f *fetch) Fetch(input goja.Value, init goja.Value) *goja.Promise {
+	rt := f.mi.vu.Runtime()
+	p, resolve, reject := rt.NewPromise()
+
+	state := f.mi.vu.State()
+	if state == nil {
+		reject(rt.NewTypeError(ErrHTTPForbiddenInInitContext.Error()))
+		return p
+	}
+	req, err := f.toRequest(input, init)
+	if err != nil {
+		reject(rt.NewTypeError(err.Error()))
+		return p
+	}
+	if signal := f.abortSignal(req.signal); signal != nil && signal.Aborted() {
+		reject(signal.Reason())
+		return p
+	}
+	var body interface{}
+	if len(req.body) > 0 {
+		body = req.body
+	}
+	parsed, err := f.mi.defaultClient.parseRequest(req.method, rt.ToValue(req.url), body, f.params(req))
+	if err != nil {
+		reject(rt.NewTypeError(err.Error()))
+		return p
+	}
+
+	ctx, reqURL := f.mi.vu.Context(), parsed.Req.URL.String()
+	runOnLoop := f.mi.vu.RegisterCallback()
+	go func() {
+		resp, err := httpext.MakeRequest(ctx, state, parsed)
+		runOnLoop(func() error {
+			if err != nil {
+				if signal := f.abortSignal(req.signal); signal != nil && signal.Aborted() {
+					reject(signal.Reason())
+				} else {
+					reject(rt.NewTypeError("fetch failed: " + err.Error()))
+				}
+				return nil
+			}
+			stream, _ := resp.Body.(*httpext.ResponseStream)
+			closeStream := func() {
+				if stream != nil {
+					_ = stream.Close()
+				}
+			}
+			if req.redirect == "error" && resp.Status >= 300 && resp.Status < 400 {
+				closeStream()
+				reject(rt.NewTypeError("fetch failed: unexpected redirect to " + resp.Headers["Location"]))
+				return nil
+			}
+			// the hooks get the stream object of http.request(), what they read isn't in the body of the Response
+			client, hookResp := f.mi.defaultClient, *resp
+			client.processResponse(&hookResp, httpext.ResponseTypeStream)
+			if err := client.runResponseHooks(client.responseFromHTTPext(&hookResp)); err != nil {
+				closeStream()
+				var exception *goja.Exception
+				if errors.As(err, &exception) {
+					reject(exception.Value())
+				} else {
+					reject(rt.NewGoError(err))
+				}
+				return nil
+			}
+			resolve(f.newResponse(reqURL, resp, stream))
+			return nil
+		})
+	}()
+	return p
+}
+
+// params returns the params for parseRequest, the fetch options translated to their http.request() equivalents.
+func (f *fetch) params(req *fetchRequest) goja.Value {
+	rt := f.mi.vu.Runtime()
+	params := rt.NewObject()
+	if req.params != nil {
+		for _, k := range []string{"tags", "timeout", "cookies", "jar", "auth", "responseCallback", "retry", "h2c"} {
+			if v := req.params.Get(k); isSet(v) {
+				must(rt, params.Set(k, v))
+			}
+		}
+	}
+	headers := make(map[string]string, len(req.headers.values))
+	for _, name := range req.headers.names() {
+		headers[name], _ = req.headers.get(name)
+	}
+	must(rt, params.Set("headers", headers))
+	must(rt, params.Set("responseType", httpext.ResponseTypeStream.String()))
+	must(rt, params.Set("throw", true))
+	if req.redirect != "follow" {
+		must(rt, params.Set("redirects", 0))
+	}
+	if isSet(req.signal) {
+		must(rt, params.Set("signal", req.signal))
+	}
+	return params
+}
+
+func (f *fetch) abortSignal(v goja.Value) *experimental.AbortSignal {
+	if !isSet(v) {
+		return nil
+	}
+	signal, _ := v.Export().(*experimental.AbortSignal)
+	return signal
+}
+
+func (f *fetch) newResponse(reqURL string, resp *httpext.Response, stream *httpext.ResponseStream) *goja.Object {
+	headers := &fetchHeaders{values: make(map[string][]string, len(resp.Headers))}
+	for name, value := range resp.Headers {
+		headers.values[strings.ToLower(name)] = []string{value}
+	}
+	r := &fetchResponse{
+		status:     resp.Status,
+		statusText: strings.TrimSpace(strings.TrimPrefix(resp.StatusText, fmt.Sprint(resp.Status))),
+		url:        resp.URL,
+		redirected: resp.URL != reqURL,
+		headers:    headers,
+	}
+	if stream != nil {
+		r.stream = stream
+	}
+	return f.responseObject(r, nil)
+}
+
+// toRequest returns a copy of the Request object or a new request for the URL in input, updated with the init options.
+func (f *fetch) toRequest(input goja.Value, init goja.Value) (*fetchRequest, error) {
+	var req *fetchRequest
+	if existing := f.requestOf(input); existing != nil {
+		req = &fetchRequest{
+			method:   existing.method,
+			url:      existing.url,
+			headers:  existing.headers.clone(),
+			body:     existing.body,
+			redirect: existing.redirect,
+			signal:   existing.signal,
+			params:   existing.params,
+		}
+	} else {
+		if !isSet(input) {
+			return nil, errors.New("a URL or a Request is required")
+		}
+		req = &fetchRequest{
+			method:   http.MethodGet,
+			url:      input.String(),
+			headers:  &fetchHeaders{values: make(map[string][]string)},
+			redirect: "follow",
+		}
+	}
+
+	options, ok := init.(*goja.Object)
+	if !ok {
+		return req, nil
+	}
+	if v := options.Get("method"); isSet(v) {
+		req.method = strings.ToUpper(v.String())
+	}
+	if v := options.Get("headers"); isSet(v) {
+		headers, err := f.toHeaders(v)
+		if err != nil {
+			return nil, err
+		}
+		req.headers = headers
+	}
+	if v := options.Get("body"); isSet(v) {
+		if req.method == http.MethodGet || req.method == http.MethodHead {
+			return nil, fmt.Errorf("a %s request can't have a body", req.method)
+		}
+		body, contentType, err := toBody(v)
+		if err != nil {
+			return nil, err
+		}
+		req.body = body
+		if _, ok := req.headers.values["content-type"]; !ok && contentType != "" {
+			req.headers.values["content-type"] = []string{contentType}
+		}
+	}
+	if v := options.Get("redirect"); isSet(v) {
+		switch redirect := v.String(); redirect {
+		case "follow", "manual", "error":
+			req.redirect = redirect
+		default:
+			return nil, fmt.Errorf("invalid redirect mode %q", redirect)
+		}
+	}
+	if v := options.Get("signal"); v != nil && !goja.IsUndefined(v) {
+		if isSet(v) && f.abortSignal(v) == nil {
+			return nil, errors.New("signal must be an AbortSignal")
+		}
+		req.signal = v
+	}
+	req.params = options
+	return req, nil
+}
+
+// toBody returns the bytes of a request or response body and the content type that goes with them.
+func toBody(v goja.Value) ([]byte, string, error) {
+	switch body := v.Export().(type) {
+	case string:
+		return []byte(body), "text/plain;charset=UTF-8", nil
+	case goja.ArrayBuffer:
+		return body.Bytes(), "", nil
+	case []byte:
+		return body, "", nil
+	default:
+		return nil, "", fmt.Errorf("unsupported body type %T, it needs to be a string or an ArrayBuffer", body)
+	}
+}
+
+// toHeaders returns the headers from a Headers object, an array of name and value pairs or an object.
+func (f *fetch) toHeaders(v goja.Value) (*fetchHeaders, error) {
+	rt := f.mi.vu.Runtime()
+	headers := &fetchHeaders{values: make(map[string][]string)}
+	if !isSet(v) {
+		return headers, nil
+	}
+	if existing := f.headersOf(v); existing != nil {
+		return existing.clone(), nil
+	}
+	obj := v.ToObject(rt)
+	if obj.ClassName() == "Array" {
+		var pairs [][]string
+		if err := rt.ExportTo(v, &pairs); err != nil {
+			return nil, errors.New("the headers need to be name and value pairs")
+		}
+		for _, pair := range pairs {
+			if len(pair) != 2 {
+				return nil, errors.New("the headers need to be name and value pairs")
+			}
+			name := strings.ToLower(pair[0])
+			headers.values[name] = append(headers.values[name], pair[1])
+		}
+		return headers, nil
+	}
+	for _, name := range obj.Keys() {
+		headers.values[strings.ToLower(name)] = []string{obj.Get(name).String()}
+	}
+	return headers, nil
+}
+
+func (f *fetch) headersOf(v goja.Value) *fetchHeaders {
+	obj, ok := v.(*goja.Object)
+	if !ok {
+		return nil
+	}
+	v = obj.GetSymbol(f.headersSym)
+	if v == nil {
+		return nil
+	}
+	h, _ := v.Export().(*fetchHeaders)
+	return h
+}
+
+func (f *fetch) requestOf(v goja.Value) *fetchRequest {
+	obj, ok := v.(*goja.Object)
+	if !ok {
+		return nil
+	}
+	v = obj.GetSymbol(f.requestSym)
+	if v == nil {
+		return nil
+	}
+	r, _ := v.Export().(*fetchRequest)
+	return r
+}
+
+// attach keeps the Go value under the symbol, so it's not visible to scripts.
+func attach(rt *goja.Runtime, obj *goja.Object, sym *goja.Symbol, v interface{}) {
+	must(rt, obj.DefineDataPropertySymbol(sym, rt.ToValue(v), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_FALSE))
+}
+
+// newHeadersObject implements the Headers constructor.
+func (f *fetch) newHeadersObject(call goja.ConstructorCall) *goja.Object {
+	rt := f.mi.vu.Runtime()
+	h, err := f.toHeaders(call.Argument(0))
+	if err != nil {
+		common.Throw(rt, err)
+	}
+	return f.headersObject(h, call.This)
+}
+
+// headersObject defines the Headers methods on obj, or on a new object if it's nil.
+func (f *fetch) headersObject(h *fetchHeaders, obj *goja.Object) *goja.Object {
+	rt := f.mi.vu.Runtime()
+	if obj == nil {
+		obj = rt.CreateObject(f.headersClass.Get("prototype").ToObject(rt))
+	}
+	attach(rt, obj, f.headersSym, h)
+
+	entries := func() goja.Value {
+		pairs := make([]interface{}, 0, len(h.values))
+		for _, name := range h.names() {
+			value, _ := h.get(name)
+			pairs = append(pairs, []interface{}{name, value})
+		}
+		return rt.NewArray(pairs...)
+	}
+	iterator := func(v goja.Value) goja.Value {
+		values, _ := goja.AssertFunction(v.ToObject(rt).Get("values"))
+		it, err := values(v)
+		must(rt, err)
+		return it
+	}
+	methods := map[string]interface{}{
+		"append": func(name, value string) {
+			name = strings.ToLower(name)
+			h.values[name] = append(h.values[name], value)
+		},
+		"delete": func(name string) { delete(h.values, strings.ToLower(name)) },
+		"get": func(name string) goja.Value {
+			if value, ok := h.get(name); ok {
+				return rt.ToValue(value)
+			}
+			return goja.Null()
+		},
+		"has": func(name string) bool {
+			_, ok := h.values[strings.ToLower(name)]
+			return ok
+		},
+		"set": func(name, value string) { h.values[strings.ToLower(name)] = []string{value} },
+		"forEach": func(callback goja.Callable) {
+			for _, name := range h.names() {
+				value, _ := h.get(name)
+				if _, err := callback(goja.Undefined(), rt.ToValue(value), rt.ToValue(name), obj); err != nil {
+					panic(err)
+				}
+			}
+		},
+		"entries": func() goja.Value { return iterator(entries()) },
+		"keys": func() goja.Value {
+			return iterator(rt.ToValue(h.names()))
+		},
+		"values": func() goja.Value {
+			values := make([]string, 0, len(h.values))
+			for _, name := range h.names() {
+				value, _ := h.get(name)
+				values = append(values, value)
+			}
+			return iterator(rt.ToValue(values))
+		},
+	}
+	for name, method := range methods {
+		must(rt, obj.Set(name, method))
+	}
+	must(rt, obj.SetSymbol(goja.SymIterator, obj.Get("entries")))
+	return obj
+}
+
+// newRequestObject implements the Request constructor.
+func (f *fetch) newRequestObject(call goja.ConstructorCall) *goja.Object {
+	rt := f.mi.vu.Runtime()
+	req, err := f.toRequest(call.Argument(0), call.Argument(1))
+	if err != nil {
+		common.Throw(rt, err)
+	}
+	obj := call.This
+	attach(rt, obj, f.requestSym, req)
+	must(rt, obj.Set("method", req.method))
+	must(rt, obj.Set("url", req.url))
+	must(rt, obj.Set("headers", f.headersObject(req.headers, nil)))
+	must(rt, obj.Set("redirect", req.redirect))
+	signal := req.signal
+	if signal == nil {
+		signal = goja.Null()
+	}
+	must(rt, obj.Set("signal", signal))
+	must(rt, obj.Set("clone", func() *goja.Object {
+		clone, err := rt.New(f.requestClass, obj)
+		must(rt, err)
+		return clone
+	}))
+	f.defineBody(obj, &fetchResponse{body: req.body})
+	return obj
+}
+
+// newResponseObject implements the Response constructor.
+func (f *fetch) newResponseObject(call goja.ConstructorCall) *goja.Object {
+	rt := f.mi.vu.Runtime()
+	resp := &fetchResponse{status: http.StatusOK, headers: &fetchHeaders{values: make(map[string][]string)}}
+	if body := call.Argument(0); isSet(body) {
+		var contentType string
+		var err error
+		if resp.body, contentType, err = toBody(body); err != nil {
+			common.Throw(rt, err)
+		}
+		if contentType != "" {
+			resp.headers.values["content-type"] = []string{contentType}
+		}
+	}
+	if options, ok := call.Argument(1).(*goja.Object); ok {
+		if v := options.Get("status"); isSet(v) {
+			resp.status = int(v.ToInteger())
+			if resp.status < 200 || resp.status > 599 {
+				common.Throw(rt, fmt.Errorf("invalid status %d", resp.status))
+			}
+		}
+		if v := options.Get("statusText"); isSet(v) {
+			resp.statusText = v.String()
+		}
+		if v := options.Get("headers"); isSet(v) {
+			headers, err := f.toHeaders(v)
+			if err != nil {
+				common.Throw(rt, err)
+			}
+			for name, values := range resp.headers.values {
+				if _, ok := headers.values[name]; !ok {
+					headers.values[name] = values
+				}
+			}
+			resp.headers = headers
+		}
+	}
+	return f.responseObject(resp, call.This)
+}
+
+// responseObject defines the Response properties and methods on obj, or on a new object if it's nil.
+func (f *fetch) responseObject(resp *fetchResponse, obj *goja.Object) *goja.Object {
+	rt := f.mi.vu.Runtime()
+	if obj == nil {
+		obj = rt.CreateObject(f.responseClass.Get("prototype").ToObject(rt))
+	}
+	attach(rt, obj, f.responseSym, resp)
+	must(rt, obj.Set("status", resp.status))
+	must(rt, obj.Set("statusText", resp.statusText))
+	must(rt, obj.Set("ok", resp.status >= 200 && resp.status <= 299))
+	must(rt, obj.Set("url", resp.url))
+	must(rt, obj.Set("redirected", resp.redirected))
+	must(rt, obj.Set("type", "basic"))
+	must(rt, obj.Set("headers", f.headersObject(resp.headers, nil)))
+	must(rt, obj.Set("clone", func() *goja.Object {
+		if resp.bodyUsed {
+			common.Throw(rt, errors.New("the body of the response has already been used"))
+		}
+		// both of the responses need the whole body, so the rest of a stream is read into memory
+		if resp.stream != nil {
+			body, err := io.ReadAll(resp.stream)
+			_ = resp.stream.Close()
+			if err != nil {
+				common.Throw(rt, err)
+			}
+			resp.stream, resp.body = nil, body
+		}
+		clone := *resp
+		clone.headers = resp.headers.clone()
+		return f.responseObject(&clone, nil)
+	}))
+	f.defineBody(obj, resp)
+	return obj
+}
+
+// defineBody defines the body stream and the methods for consuming the body, which can only be done once.
+func (f *fetch) defineBody(obj *goja.Object, resp *fetchResponse) {
+	rt := f.mi.vu.Runtime()
+	must(rt, obj.DefineAccessorProperty("bodyUsed", rt.ToValue(func() bool { return resp.bodyUsed }), nil,
+		goja.FLAG_FALSE, goja.FLAG_TRUE))
+	var body goja.Value
+	must(rt, obj.DefineAccessorProperty("body", rt.ToValue(func() goja.Value {
+		if body == nil {
+			body = goja.Null()
+			if resp.bodyStream() != nil {
+				body = f.bodyObject(resp)
+			}
+		}
+		return body
+	}), nil, goja.FLAG_FALSE, goja.FLAG_TRUE))
+
+	consume := func(convert func([]byte) (goja.Value, error)) func() *goja.Promise {
+		return func() *goja.Promise {
+			p, resolve, reject := rt.NewPromise()
+			if resp.bodyUsed {
+				reject(rt.NewTypeError("the body has already been used"))
+				return p
+			}
+			resp.bodyUsed = true
+			stream := resp.bodyStream()
+			if stream == nil {
+				v, err := convert(nil)
+				if err != nil {
+					reject(err)
+					return p
+				}
+				resolve(v)
+				return p
+			}
+			f.queueRead(resp, func() ([]byte, error) {
+				defer func() { _ = stream.Close() }()
+				return io.ReadAll(stream)
+			}, func(b []byte, err error) {
+				if err != nil {
+					reject(rt.NewTypeError(err.Error()))
+					return
+				}
+				v, err := convert(b)
+				if err != nil {
+					reject(err)
+					return
+				}
+				resolve(v)
+			})
+			return p
+		}
+	}
+	must(rt, obj.Set("text", consume(func(b []byte) (goja.Value, error) {
+		return rt.ToValue(string(b)), nil
+	})))
+	must(rt, obj.Set("arrayBuffer", consume(func(b []byte) (goja.Value, error) {
+		return rt.ToValue(rt.NewArrayBuffer(append([]byte{}, b...))), nil
+	})))
+	must(rt, obj.Set("json", consume(func(b []byte) (goja.Value, error) {
+		parse, _ := goja.AssertFunction(rt.Get("JSON").ToObject(rt).Get("parse"))
+		return parse(goja.Undefined(), rt.ToValue(string(b)))
+	})))
+}
+
+// bodyObject returns the ReadableStream of the body, its reader returns the chunks of the body as Uint8Arrays.
+func (f *fetch) bodyObject(resp *fetchResponse) *goja.Object {
+	rt := f.mi.vu.Runtime()
+	stream := resp.bodyStream()
+
+	read := func() *goja.Promise {
+		p, resolve, reject := rt.NewPromise()
+		resp.bodyUsed = true
+		buf := make([]byte, defaultStreamChunkSize)
+		f.queueRead(resp, func() ([]byte, error) {
+			for {
+				n, err := stream.Read(buf)
+				if n > 0 {
+					return buf[:n], nil
+				}
+				if errors.Is(err, io.EOF) {
+					return nil, stream.Close()
+				}
+				if err != nil {
+					return nil, err
+				}
+			}
+		}, func(b []byte, err error) {
+			if err != nil {
+				reject(rt.NewTypeError(err.Error()))
+				return
+			}
+			result := rt.NewObject()
+			must(rt, result.Set("done", b == nil))
+			if b != nil {
+				chunk, err := rt.New(rt.Get("Uint8Array"), rt.ToValue(rt.NewArrayBuffer(b)))
+				must(rt, err)
+				must(rt, result.Set("value", chunk))
+			}
+			resolve(result)
+		})
+		return p
+	}
+	cancel := func() *goja.Promise {
+		p, resolve, _ := rt.NewPromise()
+		resp.bodyUsed = true
+		f.queueRead(resp, func() ([]byte, error) {
+			return nil, stream.Close()
+		}, func([]byte, error) {
+			resolve(goja.Undefined())
+		})
+		return p
+	}
+
+	obj := rt.NewObject()
+	must(rt, obj.Set("getReader", func() *goja.Object {
+		reader := rt.NewObject()
+		must(rt, reader.Set("read", read))
+		must(rt, reader.Set("cancel", cancel))
+		must(rt, reader.Set("releaseLock", func() {}))
+		return reader
+	}))
+	must(rt, obj.Set("cancel", cancel))
+	return obj
+}
+
+// queueRead runs read in the background, after the previous reads of the stream, and calls done with its results on
+// the event loop. The streams block while they wait for the body, so they can't be read on the event loop.
+func (f *fetch) queueRead(resp *fetchResponse, read func() ([]byte, error), done func([]byte, error)) {
+	previous, last := resp.lastRead, make(chan struct{})
+	resp.lastRead = last
+	runOnLoop := f.mi.vu.RegisterCallback()
+	go func() {
+		defer close(last)
+		if previous != nil {
+			<-previous
+		}
+		b, err := read()
+		runOnLoop(func() error {
+			done(b, err)
+			return nil
+		})
+	}()
+}
+
+func isSet(v goja.Value) bool {
+	return v != nil && !goja.IsUndefined(v) && !goja.IsNull(v)
+}
+
+func must(rt *goja.Runtime, err error) {
+	if err != nil {
+		common.Throw(rt, err)
+	}
+}
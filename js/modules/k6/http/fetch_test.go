@@ -0,0 +1,236 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package http
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/js/modules/k6/experimental"
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/stats"
+)
+
+func TestFetch(t *testing.T) {
+	t.Parallel()
+	tb, state, samples, rt, _ := newRuntime(t)
+	sr := tb.Replacer.Replace
+
+	vu := modulestest.NewLoopVU(&modulestest.VU{RuntimeField: rt, CtxField: tb.Context, StateField: state})
+	mi, ok := New().NewModuleInstance(vu).(*ModuleInstance)
+	require.True(t, ok)
+	for _, name := range []string{"fetch", "Headers", "Request", "Response"} {
+		require.NoError(t, rt.Set(name, mi.exports.Get(name)))
+	}
+	events := experimental.New().NewModuleInstance(vu).Exports().Named
+	for _, name := range []string{"AbortController", "AbortSignal", "setTimeout"} {
+		require.NoError(t, rt.Set(name, events[name]))
+	}
+	// run runs a script that returns a promise, as there is no async/await
+	run := func(t *testing.T, script string) {
+		t.Helper()
+		rejection, err := vu.RunPromise(sr(script))
+		require.NoError(t, err)
+		require.Empty(t, rejection)
+	}
+	// expectTypeError is used for the promises that should be rejected
+	_, err := rt.RunString(`
+		function expectTypeError(promise) {
+			return promise.then(function() { throw new Error("the promise should've been rejected"); }, function(e) {
+				if (!(e instanceof TypeError)) { throw e; }
+				return e;
+			});
+		}`)
+	require.NoError(t, err)
+
+	t.Run("get", func(t *testing.T) {
+		run(t, `
+			var res;
+			return fetch("HTTPBIN_URL/get", { headers: { "X-Test": "1" }, tags: { name: "fetch" } }).then(function(r) {
+				res = r;
+				if (!(res instanceof Response) || !(res.headers instanceof Headers)) { throw new Error("wrong classes"); }
+				if (!res.ok || res.status !== 200 || res.statusText !== "OK" || res.redirected) {
+					throw new Error("wrong response: " + res.status + " " + res.statusText);
+				}
+				if (res.headers.get("content-type").indexOf("application/json") !== 0) {
+					throw new Error("wrong content type: " + res.headers.get("content-type"));
+				}
+				return res.json();
+			}).then(function(body) {
+				if (body.headers["X-Test"][0] !== "1") { throw new Error("the header wasn't sent"); }
+				if (!res.bodyUsed) { throw new Error("the body should be used"); }
+				return expectTypeError(res.text());
+			});
+		`)
+		var found bool
+		for _, s := range stats.GetBufferedSamples(samples) {
+			for _, sample := range s.GetSamples() {
+				if sample.Metric.Name == metrics.HTTPReqsName {
+					tags := sample.Tags.CloneTags()
+					assert.Equal(t, "fetch", tags["name"])
+					assert.Equal(t, http.MethodGet, tags["method"])
+					found = true
+				}
+			}
+		}
+		assert.True(t, found)
+	})
+
+	t.Run("post", func(t *testing.T) {
+		run(t, `
+			var req = new Request("HTTPBIN_URL/post", { method: "post", body: "hello" });
+			return fetch(req.clone()).then(function(res) { return res.json(); }).then(function(body) {
+				if (req.method !== "POST" || body.data !== "hello" ||
+					body.headers["Content-Type"][0] !== "text/plain;charset=UTF-8") {
+					throw new Error("wrong body: " + JSON.stringify(body));
+				}
+			});
+		`)
+	})
+
+	t.Run("redirect", func(t *testing.T) {
+		run(t, `
+			return fetch("HTTPBIN_URL/redirect/1").then(function(res) {
+				if (!res.redirected || res.url !== "HTTPBIN_URL/get") { throw new Error("wrong url " + res.url); }
+				return fetch("HTTPBIN_URL/redirect/1", { redirect: "manual" });
+			}).then(function(res) {
+				if (res.status !== 302) { throw new Error("wrong status " + res.status); }
+				return expectTypeError(fetch("HTTPBIN_URL/redirect/1", { redirect: "error" }));
+			});
+		`)
+	})
+
+	t.Run("classes", func(t *testing.T) {
+		run(t, `
+			var headers = new Headers([["A", "1"], ["b", "2"]]);
+			headers.append("a", "3");
+			var seen = [];
+			for (var entry of headers) { seen.push(entry[0] + "=" + entry[1]); }
+			if (seen.join("&") !== "a=1, 3&b=2" || !headers.has("B") || headers.get("c") !== null) {
+				throw new Error("wrong headers: " + seen.join("&"));
+			}
+			var res = new Response("body", { status: 404, headers: headers });
+			if (res.ok || res.headers.get("a") !== "1, 3") {
+				throw new Error("wrong response");
+			}
+			return res.clone().text().then(function(text) {
+				if (text !== "body") { throw new Error("wrong text " + text); }
+				return res.arrayBuffer();
+			}).then(function(buf) {
+				if (buf.byteLength !== 4) { throw new Error("wrong array buffer"); }
+			});
+		`)
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		run(t, `
+			var res, reader, received = 0;
+			function readAll() {
+				return reader.read().then(function(chunk) {
+					if (chunk.done) { return; }
+					if (!(chunk.value instanceof Uint8Array)) { throw new Error("wrong chunk " + chunk.value); }
+					received += chunk.value.length;
+					return readAll();
+				});
+			}
+			return fetch("HTTPBIN_URL/stream-bytes/100000?chunk_size=1000").then(function(r) {
+				res = r;
+				if (res.bodyUsed || res.body !== res.body) { throw new Error("wrong body"); }
+				reader = res.body.getReader();
+				return readAll();
+			}).then(function() {
+				if (received !== 100000 || !res.bodyUsed) { throw new Error("wrong number of bytes " + received); }
+				return expectTypeError(res.text());
+			}).then(function() {
+				var constructed = new Response("body");
+				reader = constructed.body.getReader();
+				received = 0;
+				return readAll();
+			}).then(function() {
+				if (received !== 4 || new Response().body !== null) { throw new Error("wrong constructed body"); }
+				return fetch("HTTPBIN_URL/bytes/100");
+			}).then(function(r) {
+				var clone = r.clone();
+				return r.body.cancel().then(function() { return clone.arrayBuffer(); });
+			}).then(function(buf) {
+				if (buf.byteLength !== 100) { throw new Error("wrong clone " + buf.byteLength); }
+			});
+		`)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		run(t, `
+			return expectTypeError(fetch("HTTPBIN_URL/get", { body: "no body for get" })).then(function() {
+				return expectTypeError(fetch("http://127.0.0.1:1"));
+			}).then(function(e) {
+				if (e.message.indexOf("fetch failed") !== 0) { throw e; }
+				return fetch("HTTPBIN_URL/status/500");
+			}).then(function(res) {
+				if (res.ok || res.status !== 500) { throw new Error("wrong status " + res.status); }
+			});
+		`)
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		startTime := time.Now()
+		run(t, `
+			var signal = AbortSignal.timeout(200);
+			return fetch("HTTPBIN_URL/delay/10", { signal: signal }).then(function() {
+				throw new Error("the request should've been aborted");
+			}, function(e) {
+				if (e !== signal.reason || e.name !== "TimeoutError") { throw e; }
+			});
+		`)
+		assert.WithinDuration(t, startTime.Add(200*time.Millisecond), time.Now(), time.Second)
+	})
+
+	t.Run("abort from a timer", func(t *testing.T) {
+		startTime := time.Now()
+		run(t, `
+			var controller = new AbortController();
+			setTimeout(function() { controller.abort(); }, 200);
+			return fetch("HTTPBIN_URL/delay/10", { signal: controller.signal }).then(function() {
+				throw new Error("the request should've been aborted");
+			}, function(e) {
+				if (e !== controller.signal.reason || e.name !== "AbortError") { throw e; }
+			});
+		`)
+		assert.WithinDuration(t, startTime.Add(200*time.Millisecond), time.Now(), time.Second)
+	})
+
+	t.Run("a timer can't abort a synchronous request", func(t *testing.T) {
+		run(t, `
+			var controller = new AbortController();
+			setTimeout(function() { controller.abort(); }, 200);
+			var res = http.get("HTTPBIN_URL/delay/1", { signal: controller.signal });
+			if (res.status !== 200) { throw new Error("wrong status: " + res.status); }
+			if (controller.signal.aborted) { throw new Error("the timer shouldn't have run during the request"); }
+			return new Promise(function(resolve) { setTimeout(resolve, 0); }).then(function() {
+				if (!controller.signal.aborted) { throw new Error("the signal should be aborted after the request"); }
+			});
+		`)
+	})
+}
@@ -0,0 +1,52 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package http
+
+import (
+	"encoding/json"
+
+	"github.com/dop251/goja"
+
+	"go.k6.io/k6/lib/consts"
+	"go.k6.io/k6/lib/netext/har"
+)
+
+// StartRecording starts recording all the requests made through the client by the current VU, including
+// the redirects and retries, discarding anything that was recorded before.
+func (c *Client) StartRecording() {
+	c.harRecorder = har.NewRecorder()
+}
+
+// StopRecording stops the recording started by startRecording() and returns the recorded requests as an
+// HTTP Archive (HAR) object, which can be serialized to JSON, or null if nothing was being recorded.
+func (c *Client) StopRecording() (goja.Value, error) {
+	rt := c.moduleInstance.vu.Runtime()
+	if c.harRecorder == nil {
+		return goja.Null(), nil
+	}
+	data, err := json.Marshal(c.harRecorder.HAR(&har.Creator{Name: "k6", Version: consts.Version}))
+	c.harRecorder = nil
+	if err != nil {
+		return nil, err
+	}
+	parse, _ := goja.AssertFunction(rt.Get("JSON").ToObject(rt).Get("parse"))
+	return parse(goja.Undefined(), rt.ToValue(string(data)))
+}
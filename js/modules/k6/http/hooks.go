@@ -0,0 +1,162 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package http
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/dop251/goja"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/lib/netext/httpext"
+)
+
+// AddRequestHook adds a function that is called with every request made through the client, including the
+// batched and fetch() ones, right before it's sent. The function gets an object with the method, url, headers,
+// tags and body of the request, and any changes it makes to them are applied to the request, so it can be
+// used to add headers, sign the request, etc. The headers are arrays of values by name and the body is an
+// ArrayBuffer, or null if there is none. The hooks are called in the order they were added.
+func (c *Client) AddRequestHook(fn goja.Value) {
+	hook, ok := goja.AssertFunction(fn)
+	if !ok {
+		common.Throw(c.moduleInstance.vu.Runtime(), errors.New("addRequestHook requires a function as argument"))
+	}
+	c.requestHooks = append(c.requestHooks, hook)
+}
+
+// AddResponseHook adds a function that is called with every response received by the client, before it's
+// returned to the code that made the request. The hooks are called in the order they were added.
+func (c *Client) AddResponseHook(fn goja.Value) {
+	hook, ok := goja.AssertFunction(fn)
+	if !ok {
+		common.Throw(c.moduleInstance.vu.Runtime(), errors.New("addResponseHook requires a function as argument"))
+	}
+	c.responseHooks = append(c.responseHooks, hook)
+}
+
+// runRequestHooks calls the request hooks with the parsed request and applies their changes to it.
+func (c *Client) runRequestHooks(preq *httpext.ParsedHTTPRequest) error {
+	if len(c.requestHooks) == 0 {
+		return nil
+	}
+	rt := c.moduleInstance.vu.Runtime()
+
+	headers := rt.NewObject()
+	for k, vs := range preq.Req.Header {
+		values := make([]interface{}, len(vs))
+		for i, v := range vs {
+			values[i] = v
+		}
+		must(rt, headers.Set(k, rt.NewArray(values...)))
+	}
+	tags := rt.NewObject()
+	for k, v := range preq.Tags {
+		must(rt, tags.Set(k, v))
+	}
+	var body goja.Value = goja.Null()
+	var bodyBuffer goja.ArrayBuffer
+	if preq.Body != nil {
+		bodyBuffer = rt.NewArrayBuffer(append([]byte{}, preq.Body.Bytes()...))
+		body = rt.ToValue(bodyBuffer)
+	}
+	req := rt.NewObject()
+	must(rt, req.Set("method", preq.Req.Method))
+	must(rt, req.Set("url", preq.Req.URL.String()))
+	must(rt, req.Set("headers", headers))
+	must(rt, req.Set("tags", tags))
+	must(rt, req.Set("body", body))
+
+	for _, hook := range c.requestHooks {
+		if _, err := hook(goja.Undefined(), req); err != nil {
+			return err
+		}
+	}
+
+	preq.Req.Method = strings.ToUpper(req.Get("method").String())
+	if reqURL := req.Get("url").String(); reqURL != preq.Req.URL.String() {
+		u, err := httpext.NewURL(reqURL, reqURL)
+		if err != nil {
+			return err
+		}
+		preq.URL, preq.Req.URL = &u, u.GetURL()
+	}
+	preq.Req.Header = make(http.Header)
+	if headers := req.Get("headers"); isSet(headers) {
+		headers := headers.ToObject(rt)
+		for _, k := range headers.Keys() {
+			var values []string
+			v := headers.Get(k)
+			if obj, ok := v.(*goja.Object); ok && obj.ClassName() == "Array" {
+				if err := rt.ExportTo(v, &values); err != nil {
+					return err
+				}
+			} else if isSet(v) {
+				values = []string{v.String()}
+			}
+			if strings.ToLower(k) == "host" && len(values) > 0 {
+				preq.Req.Host = values[0]
+			}
+			for _, v := range values {
+				preq.Req.Header.Add(k, v)
+			}
+		}
+	}
+	preq.Tags = make(map[string]string)
+	if tags := req.Get("tags"); isSet(tags) {
+		tags := tags.ToObject(rt)
+		for _, k := range tags.Keys() {
+			preq.Tags[k] = tags.Get(k).String()
+		}
+	}
+	newBody := req.Get("body")
+	switch {
+	case newBody.SameAs(body) && preq.Body != nil:
+		// the buffer could have been changed in place
+		preq.Body = bytes.NewBuffer(bodyBuffer.Bytes())
+	case !newBody.SameAs(body):
+		preq.Body, preq.StreamedBody = nil, nil
+		if isSet(newBody) {
+			b, _, err := toBody(newBody)
+			if err != nil {
+				return err
+			}
+			preq.Body = bytes.NewBuffer(b)
+		}
+	}
+	return nil
+}
+
+// runResponseHooks calls the response hooks with the response.
+func (c *Client) runResponseHooks(resp *Response) error {
+	if len(c.responseHooks) == 0 {
+		return nil
+	}
+	v := c.moduleInstance.vu.Runtime().ToValue(resp)
+	for _, hook := range c.responseHooks {
+		if _, err := hook(goja.Undefined(), v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
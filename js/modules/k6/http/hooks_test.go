@@ -0,0 +1,94 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package http
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/stats"
+)
+
+func TestRequestAndResponseHooks(t *testing.T) {
+	t.Parallel()
+	tb, _, samples, rt, _ := newRuntime(t)
+	sr := tb.Replacer.Replace
+
+	_, err := rt.RunString(sr(`
+		var seen = [];
+		http.addRequestHook(function(req) {
+			var body = req.body ? String.fromCharCode.apply(null, new Uint8Array(req.body)) : "";
+			req.headers["X-Signature"] = req.method + " " + body;
+			req.headers["X-Multi"] = ["a", "b"];
+			if (req.headers["Content-Type"] && req.headers["Content-Type"].length !== 1) {
+				throw new Error("wrong content type " + JSON.stringify(req.headers["Content-Type"]));
+			}
+			req.tags.hooked = "yes";
+		});
+		http.addRequestHook(function(req) {
+			if (req.url.indexOf("/post?rewrite") > 0) {
+				req.url = "HTTPBIN_URL/put";
+				req.method = "put";
+				req.body = "changed";
+			}
+		});
+		http.addResponseHook(function(res) { seen.push(res.status + " " + res.url); });
+
+		var res = http.post("HTTPBIN_URL/post?rewrite", "original");
+		if (res.status !== 200 || res.json().data !== "changed") { throw new Error("wrong request " + res.body); }
+		if (res.json().headers["X-Signature"][0] !== "POST original") { throw new Error("wrong signature " + res.body); }
+		if (res.json().headers["X-Multi"].join() !== "a,b") { throw new Error("wrong multi header " + res.body); }
+
+		var responses = http.batch(["HTTPBIN_URL/status/201", "HTTPBIN_URL/status/202"]);
+		if (seen.length !== 3 || seen[0] !== "200 HTTPBIN_URL/put" ||
+			seen[1] !== "201 HTTPBIN_URL/status/201" || seen[2] !== "202 HTTPBIN_URL/status/202") {
+			throw new Error("wrong responses seen " + JSON.stringify(seen));
+		}
+	`))
+	require.NoError(t, err)
+
+	var hooked int
+	for _, s := range stats.GetBufferedSamples(samples) {
+		for _, sample := range s.GetSamples() {
+			if sample.Metric.Name == metrics.HTTPReqsName {
+				assert.Equal(t, "yes", sample.Tags.CloneTags()["hooked"])
+				hooked++
+			}
+		}
+	}
+	assert.Equal(t, 3, hooked)
+
+	t.Run("throw", func(t *testing.T) {
+		_, err := rt.RunString(sr(`
+			http.addResponseHook(function(res) { throw new Error("unexpected " + res.status); });
+			http.get("HTTPBIN_URL/status/204");
+		`))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unexpected 204")
+
+		_, err = rt.RunString(`http.addRequestHook("not a function")`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "addRequestHook requires a function as argument")
+	})
+}
@@ -22,12 +22,13 @@ package http
 
 import (
 	"net/http"
-	"net/http/cookiejar"
 
 	"github.com/dop251/goja"
 	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/lib"
 	"go.k6.io/k6/js/modules"
 	"go.k6.io/k6/lib/netext"
+	"go.k6.io/k6/lib/netext/har"
 	"go.k6.io/k6/lib/netext/httpext"
 )
 
@@ -107,10 +108,23 @@ func (r *RootModule) NewModuleInstance(vu modules.VU) modules.Instance {
 	mustExport("options", mi.defaultClient.getMethodClosure(http.MethodOptions))
 	mustExport("request", mi.defaultClient.Request)
 	mustExport("batch", mi.defaultClient.Batch)
+	mustExport("paginate", mi.defaultClient.Paginate)
 	mustExport("setResponseCallback", mi.defaultClient.SetResponseCallback)
+	mustExport("setRetryPolicy", mi.defaultClient.SetRetryPolicy)
+	mustExport("addRequestHook", mi.defaultClient.AddRequestHook)
+	mustExport("addResponseHook", mi.defaultClient.AddResponseHook)
+	mustExport("startRecording", mi.defaultClient.StartRecording)
+	mustExport("stopRecording", mi.defaultClient.StopRecording)
+	mustExport("setAWSv4Signer", mi.defaultClient.SetAWSv4Signer)
 
 	mustExport("expectedStatuses", mi.expectedStatuses) // TODO: refactor?
 
+	fetch := newFetch(mi)
+	mustExport("fetch", fetch.Fetch)
+	mustExport("Headers", fetch.headersClass)
+	mustExport("Request", fetch.requestClass)
+	mustExport("Response", fetch.responseClass)
+
 	// TODO: actually expose the default client as k6/http.defaultClient when we
 	// have a better HTTP API (e.g. proper Client constructor, an actual Request
 	// object, custom Transport implementations you can pass the Client, etc.).
@@ -130,6 +144,12 @@ func (mi *ModuleInstance) Exports() modules.Exports {
 	}
 }
 
+// DefaultClient returns the client behind the request functions of the module instance, for the
+// other modules that make HTTP requests, like k6/experimental/graphql.
+func (mi *ModuleInstance) DefaultClient() *Client {
+	return mi.defaultClient
+}
+
 func (mi *ModuleInstance) defineConstants() {
 	rt := mi.vu.Runtime()
 	mustAddProp := func(name, val string) {
@@ -162,7 +182,7 @@ func (mi *ModuleInstance) defineConstants() {
 
 func (mi *ModuleInstance) newCookieJar(call goja.ConstructorCall) *goja.Object {
 	rt := mi.vu.Runtime()
-	jar, err := cookiejar.New(nil)
+	jar, err := lib.NewCookieJar()
 	if err != nil {
 		common.Throw(rt, err)
 	}
@@ -199,4 +219,9 @@ func (mi *ModuleInstance) URL(parts []string, pieces ...string) (httpext.URL, er
 type Client struct {
 	moduleInstance   *ModuleInstance
 	responseCallback func(int) bool
+	retryPolicy      *httpext.RetryPolicy
+	requestHooks     []goja.Callable
+	responseHooks    []goja.Callable
+	harRecorder      *har.Recorder
+	awsv4Signer      *httpext.AWSv4Signer
 }
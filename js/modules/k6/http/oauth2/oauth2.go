@@ -0,0 +1,436 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package oauth2 implements the k6/http/oauth2 module, which gets OAuth 2.0 access tokens with the client
+// credentials and refresh token grants, caches them and refreshes them before they expire.
+package oauth2
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/lib/netext/httpext"
+	"go.k6.io/k6/lib/types"
+)
+
+const (
+	grantClientCredentials = "client_credentials"
+	grantRefreshToken      = "refresh_token"
+
+	authMethodBasic = "basic"
+	authMethodBody  = "body"
+
+	defaultRefreshBefore = 10 * time.Second
+)
+
+type (
+	// RootModule is the global module instance that will create module
+	// instances for each VU. It keeps the tokens shared by all the VUs.
+	RootModule struct {
+		sharedMu sync.Mutex
+		shared   map[string]*tokenCache
+	}
+
+	// ModuleInstance represents an instance of the OAuth 2.0 module for every VU.
+	ModuleInstance struct {
+		vu   modules.VU
+		root *RootModule
+	}
+)
+
+var (
+	_ modules.Module   = &RootModule{}
+	_ modules.Instance = &ModuleInstance{}
+)
+
+// New returns a pointer to a new RootModule instance.
+func New() *RootModule {
+	return &RootModule{shared: make(map[string]*tokenCache)}
+}
+
+// NewModuleInstance implements the modules.Module interface to return
+// a new instance for each VU.
+func (r *RootModule) NewModuleInstance(vu modules.VU) modules.Instance {
+	return &ModuleInstance{vu: vu, root: r}
+}
+
+// Exports returns the exports of the OAuth 2.0 module.
+func (mi *ModuleInstance) Exports() modules.Exports {
+	return modules.Exports{
+		Named: map[string]interface{}{
+			"TokenManager": mi.newTokenManager,
+		},
+	}
+}
+
+// config is the configuration of a TokenManager, the token endpoint is discovered from the OpenID
+// Connect configuration of the issuer if only the issuer is set.
+type config struct {
+	TokenURL      string
+	Issuer        string
+	ClientID      string
+	ClientSecret  string
+	Scope         string
+	Audience      string
+	GrantType     string
+	RefreshToken  string
+	AuthMethod    string
+	Shared        bool
+	RefreshBefore time.Duration
+}
+
+// key identifies the tokens that can be shared between the VUs.
+func (c config) key() string {
+	return strings.Join([]string{
+		c.TokenURL, c.Issuer, c.ClientID, c.ClientSecret, c.Scope, c.Audience, c.GrantType, c.RefreshToken,
+	}, "\x00")
+}
+
+// parseConfig parses a configuration object like:
+//
+//	{ tokenURL: "https://example.com/token", clientId: "id", clientSecret: "secret", scope: "read write",
+//	  audience: "api", grantType: "client_credentials", refreshToken: "...", authMethod: "basic",
+//	  shared: false, refreshBefore: "10s" }
+//
+// where either the tokenURL or the issuer is required, and the refreshToken is required only for the
+// refresh_token grant type.
+func parseConfig(obj *goja.Object) (config, error) {
+	cfg := config{
+		GrantType:     grantClientCredentials,
+		AuthMethod:    authMethodBasic,
+		RefreshBefore: defaultRefreshBefore,
+	}
+	for _, k := range obj.Keys() {
+		v := obj.Get(k)
+		var err error
+		switch k {
+		case "tokenURL":
+			cfg.TokenURL = v.String()
+		case "issuer":
+			cfg.Issuer = strings.TrimSuffix(v.String(), "/")
+		case "clientId":
+			cfg.ClientID = v.String()
+		case "clientSecret":
+			cfg.ClientSecret = v.String()
+		case "scope":
+			cfg.Scope = v.String()
+		case "audience":
+			cfg.Audience = v.String()
+		case "grantType":
+			cfg.GrantType = v.String()
+			if cfg.GrantType != grantClientCredentials && cfg.GrantType != grantRefreshToken {
+				err = fmt.Errorf("it must be %s or %s", grantClientCredentials, grantRefreshToken)
+			}
+		case "refreshToken":
+			cfg.RefreshToken = v.String()
+		case "authMethod":
+			cfg.AuthMethod = v.String()
+			if cfg.AuthMethod != authMethodBasic && cfg.AuthMethod != authMethodBody {
+				err = fmt.Errorf("it must be %s or %s", authMethodBasic, authMethodBody)
+			}
+		case "shared":
+			cfg.Shared = v.ToBoolean()
+		case "refreshBefore":
+			cfg.RefreshBefore, err = types.GetDurationValue(v.Export())
+		default:
+			err = errors.New("unknown option")
+		}
+		if err != nil {
+			return cfg, fmt.Errorf("invalid OAuth2 token manager %s: %w", k, err)
+		}
+	}
+
+	if cfg.TokenURL == "" && cfg.Issuer == "" {
+		return cfg, errors.New("the OAuth2 token manager requires a tokenURL or an issuer")
+	}
+	if cfg.GrantType == grantRefreshToken && cfg.RefreshToken == "" {
+		return cfg, errors.New("the refresh_token grant type requires a refreshToken")
+	}
+	return cfg, nil
+}
+
+// newTokenManager is the JS constructor of TokenManager.
+func (mi *ModuleInstance) newTokenManager(call goja.ConstructorCall) *goja.Object {
+	rt := mi.vu.Runtime()
+	arg := call.Argument(0)
+	if goja.IsUndefined(arg) || goja.IsNull(arg) {
+		common.Throw(rt, errors.New("TokenManager requires a configuration object"))
+	}
+	cfg, err := parseConfig(arg.ToObject(rt))
+	if err != nil {
+		common.Throw(rt, err)
+	}
+
+	var cache *tokenCache
+	if cfg.Shared {
+		mi.root.sharedMu.Lock()
+		key := cfg.key()
+		if cache = mi.root.shared[key]; cache == nil {
+			cache = &tokenCache{}
+			mi.root.shared[key] = cache
+		}
+		mi.root.sharedMu.Unlock()
+	} else {
+		cache = &tokenCache{}
+	}
+
+	return rt.ToValue(&TokenManager{vu: mi.vu, config: cfg, cache: cache}).ToObject(rt)
+}
+
+// token is the successful response of a token endpoint.
+type token struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+
+	// expiresAt is zero if the token doesn't expire
+	expiresAt time.Time
+}
+
+// tokenCache keeps the current token of a TokenManager, or of all the ones with the same configuration
+// if they are shared. It's locked while a token is being requested, so only one VU requests it.
+type tokenCache struct {
+	mu           sync.Mutex
+	token        *token
+	refreshToken string
+	tokenURL     string
+}
+
+// TokenManager gets an access token from a token endpoint when it's first needed and caches it until
+// shortly before it expires, when a new one is requested, with the refresh token if one was issued.
+type TokenManager struct {
+	vu     modules.VU
+	config config
+	cache  *tokenCache
+}
+
+// GetToken returns the current access token, requesting a new one if there's none or it's about to expire.
+func (tm *TokenManager) GetToken() (string, error) {
+	t, err := tm.token()
+	if err != nil {
+		return "", err
+	}
+	return t.AccessToken, nil
+}
+
+// Hook sets the Authorization header of a request to the current access token, it's meant to be
+// passed to http.addRequestHook().
+func (tm *TokenManager) Hook(req *goja.Object) error {
+	t, err := tm.token()
+	if err != nil {
+		return err
+	}
+	rt := tm.vu.Runtime()
+	headers := req.Get("headers")
+	if goja.IsUndefined(headers) || goja.IsNull(headers) {
+		headers = rt.NewObject()
+		if err = req.Set("headers", headers); err != nil {
+			return err
+		}
+	}
+	tokenType := t.TokenType
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+	return headers.ToObject(rt).Set("Authorization", tokenType+" "+t.AccessToken)
+}
+
+// Invalidate discards the current access token, so that a new one is requested the next time it's needed,
+// e.g. after it was rejected by the server.
+func (tm *TokenManager) Invalidate() {
+	tm.cache.mu.Lock()
+	defer tm.cache.mu.Unlock()
+	tm.cache.token = nil
+}
+
+func (tm *TokenManager) token() (*token, error) {
+	if tm.vu.State() == nil {
+		return nil, errors.New("OAuth2 tokens can't be requested in the init context")
+	}
+
+	cache := tm.cache
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if t := cache.token; t != nil && (t.expiresAt.IsZero() || time.Now().Add(tm.config.RefreshBefore).Before(t.expiresAt)) {
+		return t, nil
+	}
+
+	if cache.tokenURL == "" {
+		tokenURL, err := tm.discoverTokenURL()
+		if err != nil {
+			return nil, err
+		}
+		cache.tokenURL = tokenURL
+	}
+
+	form := url.Values{}
+	refreshToken := cache.refreshToken
+	if refreshToken == "" && tm.config.GrantType == grantRefreshToken {
+		refreshToken = tm.config.RefreshToken
+	}
+	if refreshToken != "" {
+		form.Set("grant_type", grantRefreshToken)
+		form.Set("refresh_token", refreshToken)
+	} else {
+		form.Set("grant_type", grantClientCredentials)
+		if tm.config.Audience != "" {
+			form.Set("audience", tm.config.Audience)
+		}
+	}
+	if tm.config.Scope != "" {
+		form.Set("scope", tm.config.Scope)
+	}
+
+	t, err := tm.requestToken(cache.tokenURL, form)
+	if err != nil && cache.refreshToken != "" && tm.config.GrantType == grantClientCredentials {
+		// the refresh token issued with the client credentials grant may have expired, so get a new token
+		cache.refreshToken = ""
+		form.Del("refresh_token")
+		form.Set("grant_type", grantClientCredentials)
+		if tm.config.Audience != "" {
+			form.Set("audience", tm.config.Audience)
+		}
+		t, err = tm.requestToken(cache.tokenURL, form)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if t.ExpiresIn > 0 {
+		t.expiresAt = time.Now().Add(time.Duration(t.ExpiresIn) * time.Second)
+	}
+	if t.RefreshToken != "" {
+		cache.refreshToken = t.RefreshToken
+	}
+	cache.token = t
+	return t, nil
+}
+
+// requestToken requests a token with the form parameters and the client credentials.
+func (tm *TokenManager) requestToken(tokenURL string, form url.Values) (*token, error) {
+	header := make(http.Header)
+	header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if tm.config.ClientID != "" {
+		if tm.config.AuthMethod == authMethodBody {
+			form.Set("client_id", tm.config.ClientID)
+			if tm.config.ClientSecret != "" {
+				form.Set("client_secret", tm.config.ClientSecret)
+			}
+		} else {
+			// the credentials are form encoded before they are used for basic auth, see RFC 6749 section 2.3.1
+			header.Set("Authorization", "Basic "+basicAuth(
+				url.QueryEscape(tm.config.ClientID), url.QueryEscape(tm.config.ClientSecret),
+			))
+		}
+	}
+
+	status, body, err := tm.do(http.MethodPost, tokenURL, header, bytes.NewBufferString(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	if status < 200 || status > 299 {
+		var tokenErr struct {
+			Error            string `json:"error"`
+			ErrorDescription string `json:"error_description"`
+		}
+		if json.Unmarshal([]byte(body), &tokenErr) == nil && tokenErr.Error != "" {
+			return nil, fmt.Errorf("the OAuth2 token request failed with status %d: %s %s",
+				status, tokenErr.Error, tokenErr.ErrorDescription)
+		}
+		return nil, fmt.Errorf("the OAuth2 token request failed with status %d", status)
+	}
+
+	t := &token{}
+	if err := json.Unmarshal([]byte(body), t); err != nil {
+		return nil, fmt.Errorf("invalid OAuth2 token response: %w", err)
+	}
+	if t.AccessToken == "" {
+		return nil, errors.New("the OAuth2 token response doesn't have an access_token")
+	}
+	return t, nil
+}
+
+// discoverTokenURL returns the configured token URL or gets it from the OpenID Connect configuration of the issuer.
+func (tm *TokenManager) discoverTokenURL() (string, error) {
+	if tm.config.TokenURL != "" {
+		return tm.config.TokenURL, nil
+	}
+	header := make(http.Header)
+	status, body, err := tm.do(http.MethodGet, tm.config.Issuer+"/.well-known/openid-configuration", header, nil)
+	if err != nil {
+		return "", err
+	}
+	if status != http.StatusOK {
+		return "", fmt.Errorf("the OpenID Connect discovery of %s failed with status %d", tm.config.Issuer, status)
+	}
+	var discovery struct {
+		TokenEndpoint string `json:"token_endpoint"`
+	}
+	if err := json.Unmarshal([]byte(body), &discovery); err != nil || discovery.TokenEndpoint == "" {
+		return "", fmt.Errorf("the OpenID Connect configuration of %s doesn't have a token_endpoint", tm.config.Issuer)
+	}
+	return discovery.TokenEndpoint, nil
+}
+
+// do sends a request with the VU state, so it's measured like the ones made with k6/http.
+func (tm *TokenManager) do(method, reqURL string, header http.Header, body *bytes.Buffer) (int, string, error) {
+	state := tm.vu.State()
+	u, err := httpext.NewURL(reqURL, reqURL)
+	if err != nil {
+		return 0, "", err
+	}
+	header.Set("Accept", "application/json")
+	header.Set("User-Agent", state.Options.UserAgent.String)
+
+	resp, err := httpext.MakeRequest(tm.vu.Context(), state, &httpext.ParsedHTTPRequest{
+		URL:          &u,
+		Req:          &http.Request{Method: method, URL: u.GetURL(), Header: header},
+		Body:         body,
+		Timeout:      60 * time.Second,
+		Throw:        true,
+		ResponseType: httpext.ResponseTypeText,
+		Redirects:    state.Options.MaxRedirects,
+		Cookies:      make(map[string]*httpext.HTTPRequestCookie),
+		Tags:         make(map[string]string),
+	})
+	if err != nil {
+		return 0, "", err
+	}
+	respBody, _ := resp.Body.(string)
+	return resp.Status, respBody, nil
+}
+
+func basicAuth(username, password string) string {
+	return base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+}
@@ -0,0 +1,222 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package oauth2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/oxtoacart/bpool"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/lib/testutils/httpmultibin"
+	"go.k6.io/k6/stats"
+)
+
+func newTestRuntime(t *testing.T, root *RootModule, tb *httpmultibin.HTTPMultiBin, withState bool) *goja.Runtime {
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+
+	var state *lib.State
+	if withState {
+		state = &lib.State{
+			Options: lib.Options{
+				MaxRedirects: null.IntFrom(10),
+				UserAgent:    null.StringFrom("TestUserAgent"),
+				SystemTags:   &stats.DefaultSystemTagSet,
+			},
+			Logger:         logrus.New(),
+			TLSConfig:      tb.TLSClientConfig,
+			Transport:      tb.HTTPTransport,
+			BPool:          bpool.NewBufferPool(1),
+			Samples:        make(chan stats.SampleContainer, 1000),
+			Tags:           lib.NewTagMap(nil),
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+		}
+	}
+	mi, ok := root.NewModuleInstance(&modulestest.VU{
+		RuntimeField: rt,
+		InitEnvField: &common.InitEnvironment{},
+		CtxField:     context.Background(),
+		StateField:   state,
+	}).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, rt.Set("oauth2", mi.Exports().Named))
+	return rt
+}
+
+// tokenServer counts the token requests and issues tokens that expire after expiresIn seconds.
+func tokenServer(t *testing.T, tb *httpmultibin.HTTPMultiBin, path string, expiresIn int) *int64 {
+	var count int64
+	tb.Mux.HandleFunc(path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(&count, 1)
+		require.NoError(t, r.ParseForm())
+		id, secret := r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+		if user, pass, ok := r.BasicAuth(); ok {
+			id, _ = url.QueryUnescape(user)
+			secret, _ = url.QueryUnescape(pass)
+		}
+		if id != "id" || secret != "s3cr+t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = fmt.Fprint(w, `{"error": "invalid_client", "error_description": "wrong credentials"}`)
+			return
+		}
+		if r.PostForm.Get("grant_type") == "refresh_token" && r.PostForm.Get("refresh_token") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":  fmt.Sprintf("token%d:%s:%s", n, r.PostForm.Get("grant_type"), r.PostForm.Get("scope")),
+			"token_type":    "bearer",
+			"expires_in":    expiresIn,
+			"refresh_token": fmt.Sprintf("refresh%d", n),
+		}))
+	}))
+	return &count
+}
+
+func TestTokenManager(t *testing.T) {
+	t.Parallel()
+
+	t.Run("client credentials", func(t *testing.T) {
+		t.Parallel()
+		tb := httpmultibin.NewHTTPMultiBin(t)
+		count := tokenServer(t, tb, "/token", 3600)
+		rt := newTestRuntime(t, New(), tb, true)
+
+		_, err := rt.RunString(tb.Replacer.Replace(`
+			var tm = new oauth2.TokenManager({
+				tokenURL: "HTTPBIN_URL/token", clientId: "id", clientSecret: "s3cr+t", scope: "read",
+			});
+			var token = tm.getToken();
+			if (token !== "token1:client_credentials:read" || tm.getToken() !== token) {
+				throw new Error("wrong token " + token);
+			}
+			var req = { headers: {} };
+			tm.hook(req);
+			if (req.headers.Authorization !== "Bearer " + token) {
+				throw new Error("wrong header " + req.headers.Authorization);
+			}
+			tm.invalidate();
+			if (tm.getToken() !== "token2:refresh_token:read") {
+				throw new Error("the token wasn't refreshed");
+			}
+		`))
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), atomic.LoadInt64(count))
+	})
+
+	t.Run("refresh before expiry", func(t *testing.T) {
+		t.Parallel()
+		tb := httpmultibin.NewHTTPMultiBin(t)
+		count := tokenServer(t, tb, "/token", 5)
+		rt := newTestRuntime(t, New(), tb, true)
+
+		_, err := rt.RunString(tb.Replacer.Replace(`
+			var tm = new oauth2.TokenManager({
+				tokenURL: "HTTPBIN_URL/token", clientId: "id", clientSecret: "s3cr+t", authMethod: "body",
+				grantType: "refresh_token", refreshToken: "initial", refreshBefore: "10s",
+			});
+			if (tm.getToken() !== "token1:refresh_token:" || tm.getToken() !== "token2:refresh_token:") {
+				throw new Error("the token wasn't refreshed before it expired");
+			}
+		`))
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), atomic.LoadInt64(count))
+	})
+
+	t.Run("shared", func(t *testing.T) {
+		t.Parallel()
+		tb := httpmultibin.NewHTTPMultiBin(t)
+		count := tokenServer(t, tb, "/token", 3600)
+		root := New()
+
+		script := tb.Replacer.Replace(`
+			var tm = new oauth2.TokenManager({
+				tokenURL: "HTTPBIN_URL/token", clientId: "id", clientSecret: "s3cr+t", shared: true,
+			});
+			tm.getToken();
+		`)
+		for i := 0; i < 3; i++ {
+			v, err := newTestRuntime(t, root, tb, true).RunString(script)
+			require.NoError(t, err)
+			assert.Equal(t, "token1:client_credentials:", v.String())
+		}
+		assert.Equal(t, int64(1), atomic.LoadInt64(count))
+	})
+
+	t.Run("issuer discovery", func(t *testing.T) {
+		t.Parallel()
+		tb := httpmultibin.NewHTTPMultiBin(t)
+		tokenServer(t, tb, "/oidc/token", 3600)
+		tb.Mux.HandleFunc("/oidc/.well-known/openid-configuration", http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				_, _ = fmt.Fprint(w, tb.Replacer.Replace(`{"token_endpoint": "HTTPBIN_URL/oidc/token"}`))
+			}))
+		rt := newTestRuntime(t, New(), tb, true)
+
+		v, err := rt.RunString(tb.Replacer.Replace(`
+			new oauth2.TokenManager({ issuer: "HTTPBIN_URL/oidc/", clientId: "id", clientSecret: "s3cr+t" }).getToken();
+		`))
+		require.NoError(t, err)
+		assert.Equal(t, "token1:client_credentials:", v.String())
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		t.Parallel()
+		tb := httpmultibin.NewHTTPMultiBin(t)
+		tokenServer(t, tb, "/token", 3600)
+
+		_, err := newTestRuntime(t, New(), tb, true).RunString(tb.Replacer.Replace(`
+			new oauth2.TokenManager({ tokenURL: "HTTPBIN_URL/token", clientId: "id", clientSecret: "wrong" }).getToken();
+		`))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed with status 401: invalid_client wrong credentials")
+
+		_, err = newTestRuntime(t, New(), tb, true).RunString(`new oauth2.TokenManager({ clientId: "id" });`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "requires a tokenURL or an issuer")
+
+		_, err = newTestRuntime(t, New(), tb, true).RunString(
+			`new oauth2.TokenManager({ tokenURL: "http://localhost", grantType: "password" });`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid OAuth2 token manager grantType")
+
+		_, err = newTestRuntime(t, New(), tb, false).RunString(tb.Replacer.Replace(`
+			new oauth2.TokenManager({ tokenURL: "HTTPBIN_URL/token" }).getToken();
+		`))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "can't be requested in the init context")
+	})
+}
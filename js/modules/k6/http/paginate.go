@@ -0,0 +1,239 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dop251/goja"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/lib/netext/httpext"
+)
+
+// linkRegexp matches a single link of a Link header and its parameters, see RFC 8288.
+var linkRegexp = regexp.MustCompile(`<([^>]*)>((?:\s*;\s*[^;,]+)*)`) //nolint:gochecknoglobals
+
+// paginator requests the pages of a paginated API one by one, as its iterator is advanced.
+type paginator struct {
+	client *Client
+	// nextURL is the URL of the next page, it's empty when there are no more pages
+	nextURL string
+	// nextLink is a function that gets the response and returns the next URL or cursor, or a JSON
+	// selector of it, if it's nil the next URL is taken from the Link header
+	nextLink    goja.Callable
+	selector    string
+	cursorParam string
+	maxPages    int64
+	params      goja.Value
+	page        int64
+}
+
+// Paginate returns an iterator over the pages of a paginated API, starting with the one at url. Every page is
+// requested with GET only when the iterator gets to it, with a page tag that has its number, starting from 1.
+// The options are:
+//
+//	{ nextLink: "meta.next", cursorParam: "cursor", maxPages: 10, params: { headers: {...} } }
+//
+// where nextLink is either a JSON selector of the next URL in the response body, or a function that gets the
+// response and returns the next URL, and null or an empty string if it was the last page. If nextLink isn't
+// set, the URL with rel="next" in the Link header is used. If cursorParam is set, the value of nextLink is
+// a cursor that's sent in that query parameter of the first URL, instead of the next URL. The iteration stops
+// after maxPages pages, if it's set, and after a failed request.
+func (c *Client) Paginate(reqURL goja.Value, options goja.Value) (*goja.Object, error) {
+	rt := c.moduleInstance.vu.Runtime()
+	if c.moduleInstance.vu.State() == nil {
+		return nil, ErrHTTPForbiddenInInitContext
+	}
+
+	u, err := httpext.ToURL(reqURL.Export())
+	if err != nil {
+		return nil, err
+	}
+	p := &paginator{client: c, nextURL: u.URL}
+	if isSet(options) {
+		opts := options.ToObject(rt)
+		for _, k := range opts.Keys() {
+			v := opts.Get(k)
+			switch k {
+			case "nextLink":
+				if fn, ok := goja.AssertFunction(v); ok {
+					p.nextLink = fn
+				} else if isSet(v) {
+					p.selector = v.String()
+				}
+			case "cursorParam":
+				p.cursorParam = v.String()
+			case "maxPages":
+				p.maxPages = v.ToInteger()
+			case "params":
+				p.params = v
+			default:
+				return nil, fmt.Errorf("unknown paginate option %s", k)
+			}
+		}
+	}
+	if p.cursorParam != "" && p.nextLink == nil && p.selector == "" {
+		return nil, errors.New("the paginate cursorParam requires a nextLink")
+	}
+
+	iterator := rt.NewObject()
+	must(rt, iterator.Set("next", func() *goja.Object {
+		result := rt.NewObject()
+		res, err := p.next()
+		if err != nil {
+			common.Throw(rt, err)
+		}
+		if res == nil {
+			must(rt, result.Set("done", true))
+			must(rt, result.Set("value", goja.Undefined()))
+		} else {
+			must(rt, result.Set("done", false))
+			must(rt, result.Set("value", res))
+		}
+		return result
+	}))
+	must(rt, iterator.SetSymbol(goja.SymIterator, func() *goja.Object { return iterator }))
+	return iterator, nil
+}
+
+// next requests the next page and finds the URL of the one after it, it returns nil if there are no more pages.
+func (p *paginator) next() (*Response, error) {
+	if p.nextURL == "" || (p.maxPages > 0 && p.page >= p.maxPages) {
+		return nil, nil //nolint:nilnil
+	}
+	rt := p.client.moduleInstance.vu.Runtime()
+	p.page++
+
+	res, err := p.client.Request(http.MethodGet, rt.ToValue(p.nextURL), goja.Undefined(), p.pageParams())
+	if err != nil {
+		return nil, err
+	}
+	requestURL := p.nextURL
+	p.nextURL = ""
+	if res.Error != "" || res.Body == nil {
+		return res, nil
+	}
+
+	var next string
+	switch {
+	case p.nextLink != nil:
+		v, err := p.nextLink(goja.Undefined(), rt.ToValue(res))
+		if err != nil {
+			return nil, err
+		}
+		if isSet(v) {
+			next = v.String()
+		}
+	case p.selector != "":
+		if v := res.JSON(p.selector); isSet(v) {
+			next = v.String()
+		}
+	default:
+		next = nextFromLinkHeader(res.Headers["Link"])
+	}
+	if next == "" {
+		return res, nil
+	}
+
+	if p.cursorParam != "" {
+		p.nextURL, err = withQueryParam(requestURL, p.cursorParam, next)
+	} else {
+		p.nextURL, err = resolveURL(res.URL, next)
+	}
+	return res, err
+}
+
+// pageParams returns a copy of the request params with the page tag added.
+func (p *paginator) pageParams() goja.Value {
+	rt := p.client.moduleInstance.vu.Runtime()
+	params, tags := rt.NewObject(), rt.NewObject()
+	if isSet(p.params) {
+		original := p.params.ToObject(rt)
+		for _, k := range original.Keys() {
+			must(rt, params.Set(k, original.Get(k)))
+		}
+		if originalTags := original.Get("tags"); isSet(originalTags) {
+			originalTags := originalTags.ToObject(rt)
+			for _, k := range originalTags.Keys() {
+				must(rt, tags.Set(k, originalTags.Get(k)))
+			}
+		}
+	}
+	must(rt, tags.Set("page", strconv.FormatInt(p.page, 10)))
+	must(rt, params.Set("tags", tags))
+	return params
+}
+
+// nextFromLinkHeader returns the URL of the link with rel="next" in a Link header, or an empty string.
+func nextFromLinkHeader(header string) string {
+	for _, match := range linkRegexp.FindAllStringSubmatch(header, -1) {
+		for _, param := range strings.Split(match[2], ";") {
+			name, value := splitParam(param)
+			if !strings.EqualFold(name, "rel") {
+				continue
+			}
+			for _, rel := range strings.Fields(value) {
+				if strings.EqualFold(rel, "next") {
+					return match[1]
+				}
+			}
+		}
+	}
+	return ""
+}
+
+func splitParam(param string) (string, string) {
+	parts := strings.SplitN(param, "=", 2)
+	if len(parts) < 2 {
+		return strings.TrimSpace(parts[0]), ""
+	}
+	return strings.TrimSpace(parts[0]), strings.Trim(strings.TrimSpace(parts[1]), `"`)
+}
+
+func resolveURL(base, ref string) (string, error) {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	refURL, err := url.Parse(ref)
+	if err != nil {
+		return "", fmt.Errorf("invalid next page URL %q: %w", ref, err)
+	}
+	return baseURL.ResolveReference(refURL).String(), nil
+}
+
+func withQueryParam(rawURL, name, value string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	query.Set(name, value)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
@@ -0,0 +1,110 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/stats"
+)
+
+func TestPaginate(t *testing.T) {
+	t.Parallel()
+	tb, _, samples, rt, _ := newRuntime(t)
+	sr := tb.Replacer.Replace
+
+	tb.Mux.HandleFunc("/pages", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page < 3 {
+			w.Header().Set("Link", fmt.Sprintf(`</pages?page=1>; rel="first", </pages?page=%d>; rel="next"`, page+1))
+		}
+		_, _ = fmt.Fprintf(w, `{"page": %d, "cursor": %q}`, page, r.URL.Query().Get("cursor"))
+	}))
+	tb.Mux.HandleFunc("/cursor", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next := map[string]string{"": "a", "a": "b", "b": ""}[r.URL.Query().Get("cursor")]
+		_, _ = fmt.Fprintf(w, `{"meta": {"next": %q}, "token": %q}`, next, r.Header.Get("X-Token"))
+	}))
+
+	_, err := rt.RunString(sr(`
+		var pages = [];
+		for (var res of http.paginate("HTTPBIN_URL/pages?page=1")) {
+			pages.push(res.json().page);
+		}
+		if (JSON.stringify(pages) !== "[1,2,3]") {
+			throw new Error("wrong Link header pages " + JSON.stringify(pages));
+		}
+
+		var cursors = [];
+		var iter = http.paginate("HTTPBIN_URL/cursor?size=10", {
+			nextLink: "meta.next", cursorParam: "cursor", params: { headers: { "X-Token": "t" }, tags: { api: "c" } },
+		});
+		for (var res of iter) {
+			if (res.json().token !== "t" || res.url.indexOf("size=10") < 0) {
+				throw new Error("wrong cursor page " + res.url + " " + res.body);
+			}
+			cursors.push(res.request.url);
+		}
+		if (cursors.length !== 3 || cursors[2].indexOf("cursor=b") < 0) {
+			throw new Error("wrong cursor pages " + JSON.stringify(cursors));
+		}
+
+		var count = 0;
+		for (var res of http.paginate("HTTPBIN_URL/pages?page=1", {
+			nextLink: function(res) { return "/pages?page=" + (res.json().page + 1); }, maxPages: 2,
+		})) {
+			count++;
+		}
+		if (count !== 2) {
+			throw new Error("maxPages wasn't respected: " + count);
+		}
+	`))
+	require.NoError(t, err)
+
+	var cursorPages []string
+	for _, s := range stats.GetBufferedSamples(samples) {
+		for _, sample := range s.GetSamples() {
+			tags := sample.Tags.CloneTags()
+			if sample.Metric.Name == metrics.HTTPReqsName && tags["api"] == "c" {
+				cursorPages = append(cursorPages, tags["page"])
+			}
+		}
+	}
+	assert.Equal(t, []string{"1", "2", "3"}, cursorPages)
+
+	_, err = rt.RunString(`http.paginate("HTTPBIN_URL/pages", { cursorParam: "cursor" })`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cursorParam requires a nextLink")
+}
+
+func TestNextFromLinkHeader(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "/b", nextFromLinkHeader(`</a>; rel="prev", </b>; title="x"; rel="next last"`))
+	assert.Equal(t, "https://x.io/?page=2", nextFromLinkHeader(`<https://x.io/?page=2>;rel=next`))
+	assert.Equal(t, "", nextFromLinkHeader(`</a>; rel="prev"`))
+	assert.Equal(t, "", nextFromLinkHeader(""))
+}
@@ -36,6 +36,7 @@ import (
 	"gopkg.in/guregu/null.v3"
 
 	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modules/k6/experimental"
 	"go.k6.io/k6/lib/netext/httpext"
 	"go.k6.io/k6/lib/types"
 )
@@ -90,16 +91,23 @@ func (c *Client) Request(method string, url goja.Value, args ...goja.Value) (*Re
 		return nil, err
 	}
 	c.processResponse(resp, req.ResponseType)
-	return c.responseFromHTTPext(resp), nil
+	response := c.responseFromHTTPext(resp)
+	if err := c.runResponseHooks(response); err != nil {
+		return nil, err
+	}
+	return response, nil
 }
 
 // processResponse stores the body as an ArrayBuffer if indicated by
-// respType. This is done here instead of in httpext.readResponseBody to avoid
+// respType, or wraps it in a JS object if it's a stream. This is done here instead of in httpext.readResponseBody to avoid
 // a reverse dependency on js/common or goja.
 func (c *Client) processResponse(resp *httpext.Response, respType httpext.ResponseType) {
 	if respType == httpext.ResponseTypeBinary && resp.Body != nil {
 		resp.Body = c.moduleInstance.vu.Runtime().NewArrayBuffer(resp.Body.([]byte))
 	}
+	if stream, ok := resp.Body.(*httpext.ResponseStream); ok {
+		resp.Body = c.newStreamObject(stream)
+	}
 }
 
 func (c *Client) responseFromHTTPext(resp *httpext.Response) *Response {
@@ -138,6 +146,9 @@ func (c *Client) parseRequest(
 		Cookies:          make(map[string]*httpext.HTTPRequestCookie),
 		Tags:             make(map[string]string),
 		ResponseCallback: c.responseCallback,
+		Retry:            c.retryPolicy,
+		HARRecorder:      c.harRecorder,
+		AWSv4:            c.awsv4Signer,
 	}
 
 	if state.Options.DiscardResponseBodies.Bool {
@@ -238,6 +249,8 @@ func (c *Client) parseRequest(
 			result.Body = bytes.NewBufferString(data)
 		case []byte:
 			result.Body = bytes.NewBuffer(data)
+		case *experimental.File:
+			result.StreamedBody = &httpext.StreamedBody{Length: data.Size, Open: data.Open}
 		default:
 			return nil, fmt.Errorf("unknown request body type %T", body)
 		}
@@ -252,6 +265,9 @@ func (c *Client) parseRequest(
 	// TODO: ditch goja.Value, reflections and Object and use a simple go map and type assertions?
 	if params != nil && !goja.IsUndefined(params) && !goja.IsNull(params) {
 		params := params.ToObject(rt)
+		// an explicit responseType has precedence over discardResponseBody, regardless of their order
+		var responseTypeSet bool
+		var discardResponseBody goja.Value
 		for _, k := range params.Keys() {
 			switch k {
 			case "cookies":
@@ -355,6 +371,33 @@ func (c *Client) parseRequest(
 					return nil, err
 				}
 				result.ResponseType = responseType
+				responseTypeSet = true
+			case "discardResponseBody":
+				discardResponseBody = params.Get(k)
+			case "signal":
+				// the event loop is blocked by the synchronous requests, so only the timeout signals,
+				// or the ones aborted before, abort them, see experimental.AbortSignal
+				v := params.Get(k).Export()
+				if v == nil {
+					continue
+				}
+				signal, ok := v.(*experimental.AbortSignal)
+				if !ok {
+					return nil, errors.New("signal must be an AbortSignal")
+				}
+				result.Abort = signal.Done()
+			case "h2c":
+				result.H2C = params.Get(k).ToBoolean()
+			case "retry":
+				result.Retry, err = parseRetryPolicy(rt, params.Get(k))
+				if err != nil {
+					return nil, err
+				}
+			case "awsv4":
+				result.AWSv4, err = parseAWSv4Signer(params.Get(k))
+				if err != nil {
+					return nil, err
+				}
 			case "responseCallback":
 				v := params.Get(k).Export()
 				if v == nil {
@@ -366,12 +409,25 @@ func (c *Client) parseRequest(
 				}
 			}
 		}
+
+		// the discarded bodies are still read, so they are counted in data_received as usual
+		if isSet(discardResponseBody) && !responseTypeSet {
+			if discardResponseBody.ToBoolean() {
+				result.ResponseType = httpext.ResponseTypeNone
+			} else if result.ResponseType == httpext.ResponseTypeNone {
+				result.ResponseType = httpext.ResponseTypeText
+			}
+		}
 	}
 
 	if result.ActiveJar != nil {
 		httpext.SetRequestCookies(result.Req, result.ActiveJar, result.Cookies)
 	}
 
+	if err := c.runRequestHooks(result); err != nil {
+		return nil, err
+	}
+
 	return result, nil
 }
 
@@ -399,6 +455,14 @@ func (c *Client) prepareBatchArray(requests []interface{}) (
 			Response:          resp,
 		}
 		results[i] = c.responseFromHTTPext(resp)
+
+		for _, dep := range batchDependsOn(req) {
+			index, ok := dep.(int64)
+			if !ok || index < 0 || index >= int64(reqCount) {
+				return batchReqs, results, fmt.Errorf("batch request %d depends on an invalid index %v", i, dep)
+			}
+			batchReqs[i].DependsOn = append(batchReqs[i].DependsOn, int(index))
+		}
 	}
 
 	return batchReqs, results, nil
@@ -411,6 +475,8 @@ func (c *Client) prepareBatchObject(requests map[string]interface{}) (
 	batchReqs := make([]httpext.BatchParsedHTTPRequest, reqCount)
 	results := make(map[string]*Response, reqCount)
 
+	keyIndexes := make(map[string]int, reqCount)
+	dependsOn := make([][]interface{}, reqCount)
 	i := 0
 	for key, req := range requests {
 		resp := httpext.NewResponse()
@@ -429,20 +495,53 @@ func (c *Client) prepareBatchObject(requests map[string]interface{}) (
 			Response:          resp,
 		}
 		results[key] = c.responseFromHTTPext(resp)
+		keyIndexes[key] = i
+		dependsOn[i] = batchDependsOn(req)
 		i++
 	}
 
+	for i, deps := range dependsOn {
+		for _, dep := range deps {
+			key, _ := dep.(string)
+			index, ok := keyIndexes[key]
+			if !ok {
+				return batchReqs, results, fmt.Errorf("a batch request depends on an unknown request %v", dep)
+			}
+			batchReqs[i].DependsOn = append(batchReqs[i].DependsOn, index)
+		}
+	}
+
 	return batchReqs, results, nil
 }
 
 // Batch makes multiple simultaneous HTTP requests. The provideds reqsV should be an array of request
 // objects. Batch returns an array of responses and/or error
-func (c *Client) Batch(reqsV goja.Value) (interface{}, error) {
+//
+// The requests can have a dependsOn property with the index, or the key if reqsV is an object, of the
+// requests that have to succeed before they are sent, or an array of them. The options can have a
+// concurrency property that overrides the batch option for the number of requests sent at the same time.
+func (c *Client) Batch(reqsV goja.Value, options goja.Value) (interface{}, error) {
 	state := c.moduleInstance.vu.State()
 	if state == nil {
 		return nil, ErrBatchForbiddenInInitContext
 	}
 
+	concurrency := int(state.Options.Batch.Int64)
+	if isSet(options) {
+		opts := options.ToObject(c.moduleInstance.vu.Runtime())
+		for _, k := range opts.Keys() {
+			switch k {
+			case "concurrency":
+				concurrency = int(opts.Get(k).ToInteger())
+				if concurrency < 1 {
+					return nil, errors.New("the batch concurrency must be at least 1")
+				}
+			default:
+				return nil, fmt.Errorf("unknown batch option %s", k)
+			}
+		}
+	}
+
 	var (
 		err       error
 		batchReqs []httpext.BatchParsedHTTPRequest
@@ -457,6 +556,9 @@ func (c *Client) Batch(reqsV goja.Value) (interface{}, error) {
 	default:
 		return nil, fmt.Errorf("invalid http.batch() argument type %T", v)
 	}
+	if err == nil {
+		err = httpext.CheckBatchDependencies(batchReqs)
+	}
 
 	if err != nil {
 		if state.Options.Throw.Bool {
@@ -469,7 +571,7 @@ func (c *Client) Batch(reqsV goja.Value) (interface{}, error) {
 	reqCount := len(batchReqs)
 	errs := httpext.MakeBatchRequests(
 		c.moduleInstance.vu.Context(), state, batchReqs, reqCount,
-		int(state.Options.Batch.Int64), int(state.Options.BatchPerHost.Int64),
+		concurrency, int(state.Options.BatchPerHost.Int64),
 		c.processResponse,
 	)
 
@@ -478,7 +580,24 @@ func (c *Client) Batch(reqsV goja.Value) (interface{}, error) {
 			err = e
 		}
 	}
-	return results, err
+	if err != nil {
+		return results, err
+	}
+	switch results := results.(type) {
+	case []*Response:
+		for _, resp := range results {
+			if err = c.runResponseHooks(resp); err != nil {
+				return nil, err
+			}
+		}
+	case map[string]*Response:
+		for _, resp := range results {
+			if err = c.runResponseHooks(resp); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return results, nil
 }
 
 func (c *Client) parseBatchRequest(key interface{}, val interface{}) (*httpext.ParsedHTTPRequest, error) {
@@ -538,6 +657,22 @@ func (c *Client) parseBatchRequest(key interface{}, val interface{}) (*httpext.P
 	return c.parseRequest(method, reqURL, body, params)
 }
 
+// batchDependsOn returns the dependencies of a batch request, if it's an object with a dependsOn property.
+func batchDependsOn(req interface{}) []interface{} {
+	data, ok := req.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	switch deps := data["dependsOn"].(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return deps
+	default:
+		return []interface{}{deps}
+	}
+}
+
 func requestContainsFile(data map[string]interface{}) bool {
 	for _, v := range data {
 		switch v.(type) {
@@ -25,15 +25,20 @@ import (
 	"compress/gzip"
 	"compress/zlib"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
-	"net/http/cookiejar"
 	"net/url"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -44,15 +49,24 @@ import (
 	"github.com/oxtoacart/bpool"
 	"github.com/sirupsen/logrus"
 	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 	"gopkg.in/guregu/null.v3"
 
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modules/k6/experimental"
 	"go.k6.io/k6/js/modulestest"
 	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/fsext"
 	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/lib/netext/har"
+	"go.k6.io/k6/lib/netext/httpext"
 	"go.k6.io/k6/lib/testutils"
 	"go.k6.io/k6/lib/testutils/httpmultibin"
+	"go.k6.io/k6/lib/types"
 	"go.k6.io/k6/stats"
 )
 
@@ -164,6 +178,7 @@ func newRuntime(t testing.TB) (
 			"group": root.Path,
 		}),
 		BuiltinMetrics: metrics.RegisterBuiltinMetrics(registry),
+		OpenStreams:    new(lib.OpenStreams),
 	}
 
 	rt, mi := getTestModuleInstance(t, tb.Context, state)
@@ -622,7 +637,7 @@ func TestRequestAndBatch(t *testing.T) {
 
 		t.Run("cookies", func(t *testing.T) {
 			t.Run("access", func(t *testing.T) {
-				cookieJar, err := cookiejar.New(nil)
+				cookieJar, err := lib.NewCookieJar()
 				assert.NoError(t, err)
 				state.CookieJar = cookieJar
 				_, err = rt.RunString(sr(`
@@ -644,7 +659,7 @@ func TestRequestAndBatch(t *testing.T) {
 			})
 
 			t.Run("vuJar", func(t *testing.T) {
-				cookieJar, err := cookiejar.New(nil)
+				cookieJar, err := lib.NewCookieJar()
 				assert.NoError(t, err)
 				state.CookieJar = cookieJar
 				_, err = rt.RunString(sr(`
@@ -662,7 +677,7 @@ func TestRequestAndBatch(t *testing.T) {
 			})
 
 			t.Run("requestScope", func(t *testing.T) {
-				cookieJar, err := cookiejar.New(nil)
+				cookieJar, err := lib.NewCookieJar()
 				assert.NoError(t, err)
 				state.CookieJar = cookieJar
 				_, err = rt.RunString(sr(`
@@ -677,7 +692,7 @@ func TestRequestAndBatch(t *testing.T) {
 			})
 
 			t.Run("requestScopeReplace", func(t *testing.T) {
-				cookieJar, err := cookiejar.New(nil)
+				cookieJar, err := lib.NewCookieJar()
 				assert.NoError(t, err)
 				state.CookieJar = cookieJar
 				_, err = rt.RunString(sr(`
@@ -706,7 +721,7 @@ func TestRequestAndBatch(t *testing.T) {
 						http.SetCookie(w, &cookie)
 						w.WriteHeader(200)
 					}))
-					cookieJar, err := cookiejar.New(nil)
+					cookieJar, err := lib.NewCookieJar()
 					require.NoError(t, err)
 					state.CookieJar = cookieJar
 					_, err = rt.RunString(sr(`
@@ -732,7 +747,7 @@ func TestRequestAndBatch(t *testing.T) {
 					)
 				})
 				t.Run("set cookie before redirect", func(t *testing.T) {
-					cookieJar, err := cookiejar.New(nil)
+					cookieJar, err := lib.NewCookieJar()
 					require.NoError(t, err)
 					state.CookieJar = cookieJar
 					_, err = rt.RunString(sr(`
@@ -759,7 +774,7 @@ func TestRequestAndBatch(t *testing.T) {
 					)
 				})
 				t.Run("set cookie after redirect and before second redirect", func(t *testing.T) {
-					cookieJar, err := cookiejar.New(nil)
+					cookieJar, err := lib.NewCookieJar()
 					require.NoError(t, err)
 					state.CookieJar = cookieJar
 
@@ -808,7 +823,7 @@ func TestRequestAndBatch(t *testing.T) {
 			})
 
 			t.Run("domain", func(t *testing.T) {
-				cookieJar, err := cookiejar.New(nil)
+				cookieJar, err := lib.NewCookieJar()
 				assert.NoError(t, err)
 				state.CookieJar = cookieJar
 				_, err = rt.RunString(sr(`
@@ -832,7 +847,7 @@ func TestRequestAndBatch(t *testing.T) {
 			})
 
 			t.Run("path", func(t *testing.T) {
-				cookieJar, err := cookiejar.New(nil)
+				cookieJar, err := lib.NewCookieJar()
 				assert.NoError(t, err)
 				state.CookieJar = cookieJar
 				_, err = rt.RunString(sr(`
@@ -856,7 +871,7 @@ func TestRequestAndBatch(t *testing.T) {
 			})
 
 			t.Run("expires", func(t *testing.T) {
-				cookieJar, err := cookiejar.New(nil)
+				cookieJar, err := lib.NewCookieJar()
 				assert.NoError(t, err)
 				state.CookieJar = cookieJar
 				_, err = rt.RunString(sr(`
@@ -877,7 +892,7 @@ func TestRequestAndBatch(t *testing.T) {
 			})
 
 			t.Run("secure", func(t *testing.T) {
-				cookieJar, err := cookiejar.New(nil)
+				cookieJar, err := lib.NewCookieJar()
 				assert.NoError(t, err)
 				state.CookieJar = cookieJar
 				_, err = rt.RunString(sr(`
@@ -893,7 +908,7 @@ func TestRequestAndBatch(t *testing.T) {
 			})
 
 			t.Run("localJar", func(t *testing.T) {
-				cookieJar, err := cookiejar.New(nil)
+				cookieJar, err := lib.NewCookieJar()
 				assert.NoError(t, err)
 				state.CookieJar = cookieJar
 				_, err = rt.RunString(sr(`
@@ -2188,7 +2203,7 @@ func TestRedirectMetricTags(t *testing.T) {
 
 	checkTags := func(sc stats.SampleContainer, expTags map[string]string) {
 		allSamples := sc.GetSamples()
-		assert.Len(t, allSamples, 9)
+		assert.Len(t, allSamples, 12)
 		for _, s := range allSamples {
 			assert.Equal(t, expTags, s.Tags.CloneTags())
 		}
@@ -2215,6 +2230,38 @@ func TestRedirectMetricTags(t *testing.T) {
 	checkTags(<-samples, expGETtags)
 }
 
+func TestURLGroupsNameTag(t *testing.T) {
+	t.Parallel()
+	tb, state, samples, rt, _ := newRuntime(t)
+	sr := tb.Replacer.Replace
+
+	groups, err := types.NewURLGroups(
+		types.URLGroup{Template: "/status/{code}"},
+		types.URLGroup{Regex: `/bytes/\d+$`, Name: "bytes"},
+	)
+	require.NoError(t, err)
+	state.Options.URLGroups = groups
+
+	_, err = rt.RunString(sr(`
+		http.get("HTTPBIN_URL/status/200");
+		http.get("HTTPBIN_URL/status/201");
+		http.get("HTTPBIN_URL/bytes/10");
+		http.get("HTTPBIN_URL/status/200", {tags: {name: "explicit"}});
+		http.get("HTTPBIN_URL/get");
+	`))
+	require.NoError(t, err)
+
+	expected := []string{
+		sr("HTTPBIN_URL/status/{code}"), sr("HTTPBIN_URL/status/{code}"), "bytes", "explicit", sr("HTTPBIN_URL/get"),
+	}
+	for _, name := range expected {
+		for _, sample := range (<-samples).GetSamples() {
+			tag, _ := sample.Tags.Get("name")
+			assert.Equal(t, name, tag)
+		}
+	}
+}
+
 func BenchmarkHandlingOfResponseBodies(b *testing.B) {
 	tb, state, samples, rt, _ := newRuntime(b)
 
@@ -2411,3 +2458,620 @@ func TestBinaryResponseWithStatus0(t *testing.T) {
 	`)
 	require.NoError(t, err)
 }
+
+func TestRequestAbortSignal(t *testing.T) {
+	t.Parallel()
+	tb, state, _, rt, _ := newRuntime(t)
+	sr := tb.Replacer.Replace
+	state.Options.Throw = null.BoolFrom(false)
+
+	events := experimental.New().NewModuleInstance(&modulestest.VU{
+		RuntimeField: rt,
+		CtxField:     tb.Context,
+		StateField:   state,
+	}).Exports().Named
+	require.NoError(t, rt.Set("AbortController", events["AbortController"]))
+	require.NoError(t, rt.Set("AbortSignal", events["AbortSignal"]))
+
+	t.Run("timeout", func(t *testing.T) {
+		startTime := time.Now()
+		_, err := rt.RunString(sr(`
+			var signal = AbortSignal.timeout(500);
+			var res = http.get("HTTPBIN_URL/delay/10", { signal: signal });
+			if (res.error_code !== 1051) { throw new Error("wrong error code: " + res.error_code); }
+			if (res.error !== "request aborted") { throw new Error("wrong error: " + res.error); }
+			if (!signal.aborted) { throw new Error("the signal should be aborted"); }
+			if (signal.reason.name !== "TimeoutError") { throw new Error("wrong reason: " + signal.reason); }
+		`))
+		require.NoError(t, err)
+		assert.WithinDuration(t, startTime.Add(500*time.Millisecond), time.Now(), time.Second)
+	})
+
+	t.Run("already aborted", func(t *testing.T) {
+		_, err := rt.RunString(sr(`
+			var controller = new AbortController();
+			controller.abort("no reason");
+			var res = http.get("HTTPBIN_URL/get", { signal: controller.signal });
+			if (res.error_code !== 1051) { throw new Error("wrong error code: " + res.error_code); }
+			if (controller.signal.reason !== "no reason") { throw new Error("wrong reason: " + controller.signal.reason); }
+		`))
+		require.NoError(t, err)
+	})
+
+	t.Run("not aborted", func(t *testing.T) {
+		_, err := rt.RunString(sr(`
+			var res = http.get("HTTPBIN_URL/get", { signal: new AbortController().signal });
+			if (res.status !== 200) { throw new Error("wrong status: " + res.status); }
+		`))
+		require.NoError(t, err)
+	})
+
+	t.Run("not a signal", func(t *testing.T) {
+		_, err := rt.RunString(sr(`
+			var res = http.get("HTTPBIN_URL/get", { signal: {} });
+			if (res.error !== "signal must be an AbortSignal") { throw new Error("wrong error: " + res.error); }
+		`))
+		require.NoError(t, err)
+	})
+}
+
+func TestResponseTypeStream(t *testing.T) {
+	t.Parallel()
+	tb, state, samples, rt, _ := newRuntime(t)
+	sr := tb.Replacer.Replace
+
+	countSamples := func() map[string]int {
+		counts := map[string]int{}
+		for _, s := range stats.GetBufferedSamples(samples) {
+			for _, sample := range s.GetSamples() {
+				counts[sample.Metric.Name]++
+			}
+		}
+		return counts
+	}
+
+	t.Run("read", func(t *testing.T) {
+		_, err := rt.RunString(sr(`
+			var res = http.get("HTTPBIN_URL/stream-bytes/100000?chunk_size=1000", { responseType: "stream" });
+			if (res.status !== 200 || res.body.done) { throw new Error("wrong response " + res.status); }
+			var first = res.body.read(10);
+			if (first.byteLength > 10) { throw new Error("read too much: " + first.byteLength); }
+		`))
+		require.NoError(t, err)
+		counts := countSamples()
+		assert.Equal(t, 0, counts[metrics.HTTPReqsName], "the request metrics are emitted once the stream is done")
+		assert.Equal(t, 1, counts[metrics.HTTPStreamFirstChunkName])
+
+		_, err = rt.RunString(`
+			var total = first.byteLength;
+			for (var chunk of res.body) { total += chunk.byteLength; }
+			if (total !== 100000) { throw new Error("wrong total " + total); }
+			if (!res.body.done || res.body.read() !== null) { throw new Error("the stream should be done"); }
+			if (res.timings.duration <= 0) { throw new Error("the timings weren't updated"); }
+		`)
+		require.NoError(t, err)
+		counts = countSamples()
+		assert.Equal(t, 1, counts[metrics.HTTPReqsName])
+		assert.Equal(t, 1, counts[metrics.HTTPReqReceivingName])
+		assert.GreaterOrEqual(t, counts[metrics.HTTPStreamThroughputName], 1)
+	})
+
+	t.Run("close", func(t *testing.T) {
+		_, err := rt.RunString(sr(`
+			var res = http.get("HTTPBIN_URL/stream-bytes/100000?chunk_size=1000", { responseType: "stream" });
+			res.body.read();
+			res.body.close();
+			if (!res.body.done || res.body.read() !== null) { throw new Error("the stream should be done"); }
+		`))
+		require.NoError(t, err)
+		assert.Equal(t, 1, countSamples()[metrics.HTTPReqsName])
+		assert.Equal(t, 0, state.OpenStreams.Len())
+	})
+
+	t.Run("abandoned", func(t *testing.T) {
+		_, err := rt.RunString(sr(`
+			var res = http.get("HTTPBIN_URL/stream-bytes/100000?chunk_size=1000", { responseType: "stream" });
+			res.body.read(10);
+		`))
+		require.NoError(t, err)
+		assert.Equal(t, 0, countSamples()[metrics.HTTPReqsName])
+		assert.Equal(t, 1, state.OpenStreams.Len())
+
+		// what happens at the end of the iteration
+		state.OpenStreams.CloseAll()
+		assert.Equal(t, 0, state.OpenStreams.Len())
+		assert.Equal(t, 1, countSamples()[metrics.HTTPReqsName])
+		_, err = rt.RunString(`
+			if (!res.body.done || res.body.read() !== null) { throw new Error("the stream should be done"); }
+		`)
+		require.NoError(t, err)
+	})
+}
+
+func TestRequestStreamedFileBody(t *testing.T) {
+	t.Parallel()
+	tb, _, _, rt, _ := newRuntime(t) //nolint: dogsled
+	sr := tb.Replacer.Replace
+
+	data := strings.Repeat("k6", 1<<16)
+	base := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(base, "/upload.txt", []byte(data), 0o644))
+	fs := fsext.NewCacheOnReadFs(base, afero.NewMemMapFs(), 0)
+	init := experimental.New().NewModuleInstance(&modulestest.VU{
+		RuntimeField: rt,
+		CtxField:     tb.Context,
+		InitEnvField: &common.InitEnvironment{
+			FileSystems: map[string]afero.Fs{"file": fs},
+			CWD:         &url.URL{Path: "/"},
+		},
+	}).Exports().Named
+	require.NoError(t, rt.Set("openFile", init["openFile"]))
+
+	tb.Mux.HandleFunc("/upload", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(data)), r.ContentLength)
+		assert.Equal(t, data, string(body))
+		_, err = fmt.Fprint(w, len(body))
+		require.NoError(t, err)
+	}))
+
+	_, err := rt.RunString(sr(`
+		var file = openFile("upload.txt");
+		if (file.size !== 131072 || file.name !== "/upload.txt") { throw new Error("wrong file " + file.name); }
+		var res = http.post("HTTPBIN_URL/upload", file);
+		if (res.status !== 200 || res.body !== "131072") { throw new Error("wrong response " + res.body); }
+		// the file is opened again when the body has to be sent again after a redirect
+		res = http.post("HTTPBIN_URL/redirect-to?status_code=307&url=" + encodeURIComponent("HTTPBIN_URL/upload"), file);
+		if (res.status !== 200 || res.body !== "131072") { throw new Error("wrong redirected response " + res.body); }
+	`))
+	require.NoError(t, err)
+	cached, err := afero.Exists(fs.(fsext.CacheLayerGetter).GetCachingFs(), "/upload.txt")
+	require.NoError(t, err)
+	assert.False(t, cached, "the file shouldn't be cached")
+
+	_, err = rt.RunString(sr(`http.post("HTTPBIN_URL/upload", file, { compression: "gzip" });`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "compression can't be used with a streamed request body")
+}
+
+func TestRequestRetryPolicy(t *testing.T) {
+	t.Parallel()
+	tb, _, samples, rt, _ := newRuntime(t)
+	sr := tb.Replacer.Replace
+
+	var calls int64
+	tb.Mux.HandleFunc("/flaky", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "data", string(body))
+		if atomic.AddInt64(&calls, 1)%3 != 0 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	attempts := func() []string {
+		var result []string
+		for _, s := range stats.GetBufferedSamples(samples) {
+			for _, sample := range s.GetSamples() {
+				if sample.Metric.Name == metrics.HTTPReqsName {
+					result = append(result, sample.Tags.CloneTags()["attempt"])
+				}
+			}
+		}
+		return result
+	}
+
+	t.Run("params", func(t *testing.T) {
+		_, err := rt.RunString(sr(`
+			var res = http.post("HTTPBIN_URL/flaky", "data", { retry: { attempts: 3, backoff: "1s" } });
+			if (res.status !== 200) { throw new Error("wrong status " + res.status); }
+		`))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"1", "2", "3"}, attempts())
+	})
+
+	t.Run("global", func(t *testing.T) {
+		_, err := rt.RunString(sr(`
+			http.setRetryPolicy({ attempts: 2, statusCodes: [503], backoff: 1 });
+			var res = http.post("HTTPBIN_URL/flaky", "data");
+			if (res.status !== 503) { throw new Error("wrong status " + res.status); }
+			res = http.post("HTTPBIN_URL/flaky", "data", { retry: false });
+			if (res.status !== 200) { throw new Error("wrong status " + res.status); }
+			http.setRetryPolicy(null);
+		`))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"1", "2", ""}, attempts())
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := rt.RunString(`http.setRetryPolicy({ attempts: 2, jitter: 2 })`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid retry policy jitter")
+		_, err = rt.RunString(`http.setRetryPolicy({ statusCodes: [500] })`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "the attempts of a retry policy must be at least 1")
+	})
+}
+
+func TestConnectionPoolLimitsAndMetrics(t *testing.T) {
+	t.Parallel()
+	tb, state, samples, rt, _ := newRuntime(t)
+	sr := tb.Replacer.Replace
+	state.Options.MaxRequestsPerConn = null.IntFrom(2)
+
+	var remoteAddrs []string
+	var mu sync.Mutex
+	tb.Mux.HandleFunc("/conn", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		remoteAddrs = append(remoteAddrs, r.RemoteAddr)
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	_, err := rt.RunString(sr(`
+		for (var i = 0; i < 5; i++) {
+			var res = http.get("HTTPBIN_URL/conn");
+			if (res.status !== 204) { throw new Error("wrong status " + res.status); }
+		}
+	`))
+	require.NoError(t, err)
+
+	require.Len(t, remoteAddrs, 5)
+	assert.Equal(t, remoteAddrs[0], remoteAddrs[1])
+	assert.NotEqual(t, remoteAddrs[1], remoteAddrs[2])
+	assert.Equal(t, remoteAddrs[2], remoteAddrs[3])
+	assert.NotEqual(t, remoteAddrs[3], remoteAddrs[4])
+
+	values := map[string][]float64{}
+	for _, s := range stats.GetBufferedSamples(samples) {
+		for _, sample := range s.GetSamples() {
+			switch sample.Metric.Name {
+			case metrics.HTTPConnsOpenName, metrics.HTTPConnsIdleName, metrics.HTTPReqsInFlightName:
+				values[sample.Metric.Name] = append(values[sample.Metric.Name], sample.Value)
+			}
+		}
+	}
+	assert.Equal(t, []float64{0, 0, 0, 0, 0}, values[metrics.HTTPReqsInFlightName])
+	require.Len(t, values[metrics.HTTPConnsOpenName], 5)
+	assert.Equal(t, values[metrics.HTTPConnsOpenName], values[metrics.HTTPConnsIdleName])
+	// the first connection is still open, as it's kept alive for its second request
+	assert.Equal(t, float64(1), values[metrics.HTTPConnsOpenName][0])
+}
+
+func TestConnectionPoolLimitsHTTP2(t *testing.T) {
+	t.Parallel()
+	tb, state, _, rt, _ := newRuntime(t)
+	sr := tb.Replacer.Replace
+	state.Options.MaxRequestsPerConn = null.IntFrom(2)
+
+	var remoteAddrs []string
+	var mu sync.Mutex
+	tb.Mux.HandleFunc("/conn", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		remoteAddrs = append(remoteAddrs, r.RemoteAddr)
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	_, err := rt.RunString(sr(`
+		for (var i = 0; i < 20; i++) {
+			var res = http.get("HTTP2BIN_URL/conn");
+			if (res.status !== 204 || res.proto !== "HTTP/2.0") {
+				throw new Error("wrong response " + res.status + " " + res.proto + " " + res.error);
+			}
+		}
+	`))
+	require.NoError(t, err)
+
+	require.Len(t, remoteAddrs, 20)
+	for i := 0; i < len(remoteAddrs); i += 2 {
+		assert.Equal(t, remoteAddrs[i], remoteAddrs[i+1])
+		if i > 0 {
+			assert.NotEqual(t, remoteAddrs[i-1], remoteAddrs[i])
+		}
+	}
+}
+
+func TestDiscardResponseBodyParam(t *testing.T) {
+	t.Parallel()
+	tb, state, _, rt, _ := newRuntime(t)
+	sr := tb.Replacer.Replace
+
+	bytesRead := func(script string) int64 {
+		before := atomic.LoadInt64(&tb.Dialer.BytesRead)
+		_, err := rt.RunString(sr(script))
+		require.NoError(t, err)
+		return atomic.LoadInt64(&tb.Dialer.BytesRead) - before
+	}
+
+	t.Run("override global", func(t *testing.T) {
+		state.Options.DiscardResponseBodies = null.BoolFrom(true)
+		defer func() { state.Options.DiscardResponseBodies = null.BoolFrom(false) }()
+
+		discarded := bytesRead(`
+			var res = http.get("HTTPBIN_URL/bytes/10000");
+			if (res.body !== null) { throw new Error("the body should be discarded"); }
+		`)
+		kept := bytesRead(`
+			var res = http.get("HTTPBIN_URL/bytes/10000", { discardResponseBody: false });
+			if (res.body.length === 0) { throw new Error("the body should be kept"); }
+		`)
+		assert.Greater(t, discarded, int64(10000))
+		assert.Equal(t, kept, discarded)
+	})
+
+	t.Run("override default", func(t *testing.T) {
+		_, err := rt.RunString(sr(`
+			var res = http.get("HTTPBIN_URL/bytes/100", { discardResponseBody: true });
+			if (res.body !== null) { throw new Error("the body should be discarded"); }
+			res = http.get("HTTPBIN_URL/bytes/100", { responseType: "binary", discardResponseBody: true });
+			if (res.body.byteLength !== 100) { throw new Error("the responseType should have precedence"); }
+		`))
+		require.NoError(t, err)
+	})
+}
+
+func TestResponseTrailersAndInformational(t *testing.T) {
+	t.Parallel()
+	tb, _, _, rt, _ := newRuntime(t)
+	sr := tb.Replacer.Replace
+
+	tb.Mux.HandleFunc("/early-hints", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", "</style.css>; rel=preload; as=style")
+		w.WriteHeader(http.StatusEarlyHints)
+		time.Sleep(50 * time.Millisecond)
+		w.Header().Del("Link")
+		w.Header().Set("Trailer", "X-Checksum, X-Missing")
+		w.WriteHeader(http.StatusOK)
+		_, err := w.Write([]byte("body"))
+		assert.NoError(t, err)
+		w.Header().Set("X-Checksum", "abc")
+	}))
+
+	for _, responseType := range []string{"text", "stream"} {
+		responseType := responseType
+		t.Run(responseType, func(t *testing.T) {
+			_, err := rt.RunString(sr(`
+				var res = http.get("HTTPBIN_URL/early-hints", { responseType: "` + responseType + `" });
+				if (res.body.read) {
+					while (res.body.read() !== null) {}
+				}
+				if (res.status !== 200 || res.informational.length !== 1) {
+					throw new Error("wrong informational responses " + JSON.stringify(res.informational));
+				}
+				var hints = res.informational[0];
+				if (hints.status !== 103 || hints.headers["Link"] !== "</style.css>; rel=preload; as=style") {
+					throw new Error("wrong early hints " + JSON.stringify(hints));
+				}
+				if (hints.time <= 0 || hints.time > res.timings.duration) {
+					throw new Error("wrong early hints time " + hints.time);
+				}
+				if (JSON.stringify(res.trailers) !== '{"X-Checksum":"abc"}') {
+					throw new Error("wrong trailers " + JSON.stringify(res.trailers));
+				}
+			`))
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestRequestUnixSocketAndH2C(t *testing.T) {
+	t.Parallel()
+	tb, state, _, rt, _ := newRuntime(t)
+
+	socket := filepath.Join(t.TempDir(), "app.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, "%s %s %s", r.Proto, r.Host, r.URL.RequestURI())
+	})
+	srv := &http.Server{Handler: h2c.NewHandler(handler, &http2.Server{})} //nolint:gosec
+	go func() { _ = srv.Serve(listener) }()
+	t.Cleanup(func() { _ = srv.Close() })
+
+	state.H2CTransport = httpext.NewH2CTransport(tb.Dialer)
+	require.NoError(t, rt.Set("socketURL", "http+unix://"+url.PathEscape(socket)))
+
+	_, err = rt.RunString(`
+		var res = http.get(socketURL + "/path?a=1");
+		if (res.status !== 200 || res.body !== "HTTP/1.1 localhost /path?a=1") {
+			throw new Error("wrong response " + res.status + " " + res.body);
+		}
+		if (res.url !== socketURL + "/path?a=1") {
+			throw new Error("wrong url " + res.url);
+		}
+		res = http.get(socketURL + "/h2c", { h2c: true, headers: { Host: "app.local" } });
+		if (res.status !== 200 || res.body !== "HTTP/2.0 app.local /h2c" || res.proto !== "HTTP/2.0") {
+			throw new Error("wrong h2c response " + res.status + " " + res.body + " " + res.proto);
+		}
+	`)
+	require.NoError(t, err)
+}
+
+func TestRequestHARRecording(t *testing.T) {
+	t.Parallel()
+	tb, state, _, rt, _ := newRuntime(t)
+	sr := tb.Replacer.Replace
+
+	stateRecorder := har.NewRecorder()
+	state.HARRecorder = stateRecorder
+
+	_, err := rt.RunString(sr(`
+		if (http.stopRecording() !== null) {
+			throw new Error("there shouldn't be a recording yet");
+		}
+		http.get("HTTPBIN_URL/get");
+		http.startRecording();
+		http.get("HTTPBIN_URL/redirect-to?url=/get%3Fa%3D1", { headers: { "X-Test": "yes" } });
+		http.post("HTTPBIN_URL/post", "some data", { headers: { "Content-Type": "text/plain" } });
+		http.get("HTTPBIN_URL/bytes/3");
+		var recorded = http.stopRecording();
+		http.get("HTTPBIN_URL/get");
+
+		var entries = recorded.log.entries;
+		if (recorded.log.version !== "1.2" || recorded.log.creator.name !== "k6" || entries.length !== 4) {
+			throw new Error("wrong recording " + JSON.stringify(recorded));
+		}
+
+		var redirect = entries[0];
+		if (redirect.request.method !== "GET" || redirect.response.status !== 302 ||
+			redirect.response.redirectURL !== "/get?a=1" || redirect.response.statusText !== "Found") {
+			throw new Error("wrong redirect entry " + JSON.stringify(redirect));
+		}
+		var get = entries[1];
+		if (get.request.url !== "HTTPBIN_URL/get?a=1" || get.request.queryString[0].name !== "a" ||
+			get.request.headers.filter(function(h) { return h.name === "X-Test" && h.value === "yes" }).length !== 1) {
+			throw new Error("wrong get request entry " + JSON.stringify(get.request));
+		}
+		if (get.response.status !== 200 || JSON.parse(get.response.content.text).args.a[0] !== "1" ||
+			get.response.content.mimeType !== "application/json; encoding=utf-8" || get.time <= 0) {
+			throw new Error("wrong get response entry " + JSON.stringify(get.response));
+		}
+		var post = entries[2];
+		if (post.request.postData.text !== "some data" || post.request.postData.mimeType !== "text/plain" ||
+			post.request.bodySize !== 9) {
+			throw new Error("wrong post entry " + JSON.stringify(post.request));
+		}
+		var bytes = entries[3].response.content;
+		if (bytes.size !== 3 || (bytes.encoding !== "base64" && bytes.encoding !== undefined)) {
+			throw new Error("wrong binary content " + JSON.stringify(bytes));
+		}
+	`))
+	require.NoError(t, err)
+
+	assert.Len(t, stateRecorder.HAR(&har.Creator{}).Log.Entries, 6)
+}
+
+func TestRequestAWSv4Signing(t *testing.T) {
+	t.Parallel()
+	tb, _, _, rt, _ := newRuntime(t)
+	sr := tb.Replacer.Replace
+
+	tb.Mux.HandleFunc("/aws", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		hash := sha256.Sum256(body)
+		if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(hash[:]) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = fmt.Fprint(w, r.Header.Get("Authorization"))
+	}))
+
+	_, err := rt.RunString(sr(`
+		if (http.get("HTTPBIN_URL/aws").body !== "") {
+			throw new Error("the request shouldn't be signed");
+		}
+		http.setAWSv4Signer({ accessKeyId: "AKID", secretAccessKey: "secret", region: "us-east-1", service: "s3" });
+		var res = http.put("HTTPBIN_URL/aws", "some data");
+		if (res.status !== 200 || !res.body.startsWith("AWS4-HMAC-SHA256 Credential=AKID/") ||
+			res.body.indexOf("/us-east-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, ") < 0) {
+			throw new Error("wrong signature: " + res.status + " " + res.body);
+		}
+		res = http.get("HTTPBIN_URL/redirect-to?url=/aws");
+		if (res.status !== 200 || !res.body.startsWith("AWS4-HMAC-SHA256")) {
+			throw new Error("the redirect wasn't signed: " + res.status + " " + res.body);
+		}
+		res = http.get("HTTPBIN_URL/aws", { awsv4: { accessKeyId: "OTHER", secretAccessKey: "secret",
+			region: "eu-west-1", service: "s3", sessionToken: "token" } });
+		if (res.body.indexOf("Credential=OTHER/") < 0 || res.body.indexOf("x-amz-security-token") < 0) {
+			throw new Error("wrong request signature: " + res.body);
+		}
+		if (http.get("HTTPBIN_URL/aws", { awsv4: false }).body !== "") {
+			throw new Error("the request signing wasn't disabled");
+		}
+		try {
+			http.setAWSv4Signer({ accessKeyId: "AKID" });
+			throw new Error("incomplete credentials should throw");
+		} catch (e) {
+			if (e.toString().indexOf("requires an accessKeyId") < 0) {
+				throw e;
+			}
+		}
+	`))
+	require.NoError(t, err)
+}
+
+func TestBatchConcurrencyAndDependencies(t *testing.T) {
+	t.Parallel()
+	tb, _, _, rt, _ := newRuntime(t)
+	sr := tb.Replacer.Replace
+
+	var (
+		mu               sync.Mutex
+		order            []string
+		inFlight, maxFly int
+	)
+	tb.Mux.HandleFunc("/order", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		order = append(order, r.URL.Query().Get("name"))
+		inFlight++
+		if inFlight > maxFly {
+			maxFly = inFlight
+		}
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+
+	_, err := rt.RunString(sr(`
+		var responses = http.batch({
+			a: "HTTPBIN_URL/order?name=a",
+			b: { method: "GET", url: "HTTPBIN_URL/order?name=b", dependsOn: "a" },
+			c: "HTTPBIN_URL/status/500",
+			d: { method: "GET", url: "HTTPBIN_URL/order?name=d", dependsOn: ["b", "c"] },
+			e: { method: "GET", url: "HTTPBIN_URL/order?name=e", dependsOn: "d" },
+		});
+		if (responses.b.status !== 200 || responses.c.status !== 500) {
+			throw new Error("wrong statuses " + responses.b.status + " " + responses.c.status);
+		}
+		if (responses.d.status !== 0 || responses.d.error_code !== 1052 || responses.e.error_code !== 1052) {
+			throw new Error("the requests depending on a failed request weren't skipped: " + responses.d.error);
+		}
+	`))
+	require.NoError(t, err)
+	mu.Lock()
+	assert.Equal(t, []string{"a", "b"}, order)
+	order, maxFly = nil, 0
+	mu.Unlock()
+
+	_, err = rt.RunString(sr(`
+		var responses = http.batch([
+			"HTTPBIN_URL/order?name=0",
+			"HTTPBIN_URL/order?name=1",
+			"HTTPBIN_URL/order?name=2",
+			{ method: "GET", url: "HTTPBIN_URL/order?name=3", dependsOn: [0, 1] },
+		], { concurrency: 1 });
+		if (responses[3].status !== 200) {
+			throw new Error("wrong status " + responses[3].status);
+		}
+	`))
+	require.NoError(t, err)
+	mu.Lock()
+	assert.Equal(t, 1, maxFly)
+	assert.Equal(t, "3", order[len(order)-1])
+	mu.Unlock()
+
+	_, err = rt.RunString(sr(`
+		http.batch({
+			a: { method: "GET", url: "HTTPBIN_URL/get", dependsOn: "b" },
+			b: { method: "GET", url: "HTTPBIN_URL/get", dependsOn: "a" },
+		});
+	`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "depend on each other in a cycle")
+
+	_, err = rt.RunString(sr(`http.batch([{ method: "GET", url: "HTTPBIN_URL/get", dependsOn: 5 }])`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "depends on an invalid index 5")
+
+	_, err = rt.RunString(sr(`http.batch(["HTTPBIN_URL/get"], { concurrency: 0 })`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "concurrency must be at least 1")
+}
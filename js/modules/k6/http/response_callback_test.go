@@ -114,6 +114,9 @@ func TestResponseCallbackInAction(t *testing.T) {
 		metrics.HTTPReqWaitingName,
 		metrics.HTTPReqSendingName,
 		metrics.HTTPReqTLSHandshakingName,
+		metrics.HTTPConnsOpenName,
+		metrics.HTTPConnsIdleName,
+		metrics.HTTPReqsInFlightName,
 	}
 
 	allHTTPMetrics := append(HTTPMetricsWithoutFailed, metrics.HTTPReqFailedName)
@@ -312,6 +315,9 @@ func TestResponseCallbackBatch(t *testing.T) {
 		metrics.HTTPReqWaitingName,
 		metrics.HTTPReqSendingName,
 		metrics.HTTPReqTLSHandshakingName,
+		metrics.HTTPConnsOpenName,
+		metrics.HTTPConnsIdleName,
+		metrics.HTTPReqsInFlightName,
 	}
 
 	allHTTPMetrics := append(HTTPMetricsWithoutFailed, metrics.HTTPReqFailedName)
@@ -426,6 +432,9 @@ func TestResponseCallbackInActionWithoutPassedTag(t *testing.T) {
 		metrics.HTTPReqSendingName,
 		metrics.HTTPReqWaitingName,
 		metrics.HTTPReqTLSHandshakingName,
+		metrics.HTTPConnsOpenName,
+		metrics.HTTPConnsIdleName,
+		metrics.HTTPReqsInFlightName,
 	}
 	deleteSystemTag(state, stats.TagExpectedResponse.String())
 
@@ -485,6 +494,9 @@ func TestDigestWithResponseCallback(t *testing.T) {
 		metrics.HTTPReqSendingName,
 		metrics.HTTPReqWaitingName,
 		metrics.HTTPReqTLSHandshakingName,
+		metrics.HTTPConnsOpenName,
+		metrics.HTTPConnsIdleName,
+		metrics.HTTPReqsInFlightName,
 	}
 	_, err := rt.RunString(fmt.Sprintf(`
 		var res = http.get(%q,  { auth: "digest" });
@@ -0,0 +1,103 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/lib/netext/httpext"
+	"go.k6.io/k6/lib/types"
+)
+
+// defaultRetryStatusCodes are the statuses that are retried if a retry policy doesn't specify them.
+var defaultRetryStatusCodes = []int{ //nolint:gochecknoglobals
+	http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout,
+}
+
+// SetRetryPolicy sets the retry policy used by all the requests that don't have one in their params,
+// null disables the retries.
+func (c *Client) SetRetryPolicy(val goja.Value) {
+	policy, err := parseRetryPolicy(c.moduleInstance.vu.Runtime(), val)
+	if err != nil {
+		common.Throw(c.moduleInstance.vu.Runtime(), err)
+	}
+	c.retryPolicy = policy
+}
+
+// parseRetryPolicy parses a retry policy object like:
+//
+//	{ attempts: 3, statusCodes: [503], networkErrors: true, backoff: "100ms", maxBackoff: "10s", jitter: 0.5 }
+//
+// where only the attempts are required. It returns nil for null, undefined and false, so that retries can be
+// disabled for single requests.
+func parseRetryPolicy(rt *goja.Runtime, val goja.Value) (*httpext.RetryPolicy, error) {
+	if enabled, ok := val.Export().(bool); !isSet(val) || (ok && !enabled) {
+		return nil, nil //nolint:nilnil
+	}
+	obj, ok := val.(*goja.Object)
+	if !ok {
+		return nil, errors.New("the retry policy must be an object")
+	}
+
+	policy := &httpext.RetryPolicy{
+		StatusCodes:   defaultRetryStatusCodes,
+		NetworkErrors: true,
+		Backoff:       100 * time.Millisecond,
+		MaxBackoff:    10 * time.Second,
+	}
+	for _, k := range obj.Keys() {
+		v := obj.Get(k)
+		var err error
+		switch k {
+		case "attempts":
+			policy.Attempts = int(v.ToInteger())
+		case "statusCodes":
+			policy.StatusCodes = nil
+			err = rt.ExportTo(v, &policy.StatusCodes)
+		case "networkErrors":
+			policy.NetworkErrors = v.ToBoolean()
+		case "backoff":
+			policy.Backoff, err = types.GetDurationValue(v.Export())
+		case "maxBackoff":
+			policy.MaxBackoff, err = types.GetDurationValue(v.Export())
+		case "jitter":
+			policy.Jitter = v.ToFloat()
+			if policy.Jitter < 0 || policy.Jitter > 1 {
+				err = errors.New("it must be between 0 and 1")
+			}
+		default:
+			err = errors.New("unknown option")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid retry policy %s: %w", k, err)
+		}
+	}
+	if policy.Attempts < 1 {
+		return nil, errors.New("the attempts of a retry policy must be at least 1")
+	}
+	return policy, nil
+}
@@ -0,0 +1,83 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package http
+
+import (
+	"errors"
+	"io"
+
+	"github.com/dop251/goja"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/lib/netext/httpext"
+)
+
+// defaultStreamChunkSize is the most that is read at once, if read() isn't given a size.
+const defaultStreamChunkSize = 64 * 1024
+
+// newStreamObject returns the JS object for the body of a response with the stream response type.
+// Its read() returns the next chunk as an ArrayBuffer, or null once the whole body has been read,
+// and it's also iterable, so the chunks can be read with a for...of loop.
+func (c *Client) newStreamObject(stream *httpext.ResponseStream) *goja.Object {
+	rt := c.moduleInstance.vu.Runtime()
+	obj := rt.NewObject()
+
+	read := func(size int) goja.Value {
+		if size <= 0 {
+			size = defaultStreamChunkSize
+		}
+		buf := make([]byte, size)
+		for {
+			n, err := stream.Read(buf)
+			if n > 0 {
+				return rt.ToValue(rt.NewArrayBuffer(buf[:n]))
+			}
+			if errors.Is(err, io.EOF) {
+				return goja.Null()
+			}
+			if err != nil {
+				common.Throw(rt, err)
+			}
+		}
+	}
+	must(rt, obj.Set("read", func(size goja.Value) goja.Value {
+		if !isSet(size) {
+			return read(0)
+		}
+		return read(int(size.ToInteger()))
+	}))
+	must(rt, obj.Set("close", func() { _ = stream.Close() }))
+	must(rt, obj.DefineAccessorProperty("done", rt.ToValue(stream.Done), nil, goja.FLAG_FALSE, goja.FLAG_TRUE))
+	must(rt, obj.SetSymbol(goja.SymIterator, func() *goja.Object {
+		it := rt.NewObject()
+		must(rt, it.Set("next", func() *goja.Object {
+			result := rt.NewObject()
+			chunk := read(0)
+			must(rt, result.Set("done", goja.IsNull(chunk)))
+			if !goja.IsNull(chunk) {
+				must(rt, result.Set("value", chunk))
+			}
+			return result
+		}))
+		return it
+	}))
+	return obj
+}
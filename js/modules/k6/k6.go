@@ -31,6 +31,7 @@ import (
 
 	"go.k6.io/k6/js/common"
 	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/lib"
 	"go.k6.io/k6/stats"
 )
 
@@ -40,6 +41,9 @@ var (
 
 	// ErrCheckInInitContext is returned when check() are using in the init context.
 	ErrCheckInInitContext = common.NewInitContextError("Using check() in the init context is not supported")
+
+	// ErrCriticalInInitContext is returned when critical() is used in the init context.
+	ErrCriticalInInitContext = common.NewInitContextError("Using critical() in the init context is not supported")
 )
 
 type (
@@ -74,6 +78,7 @@ func (mi *K6) Exports() modules.Exports {
 	return modules.Exports{
 		Named: map[string]interface{}{
 			"check":      mi.Check,
+			"critical":   mi.Critical,
 			"fail":       mi.Fail,
 			"group":      mi.Group,
 			"randomSeed": mi.RandomSeed,
@@ -151,6 +156,31 @@ func (mi *K6) Group(name string, fn goja.Callable) (goja.Value, error) {
 	return ret, err
 }
 
+// Critical runs the function as a critical section of the iteration, e.g. for
+// writes which would leave corrupt test data if they were cut. Unlike the rest
+// of the iteration, it isn't interrupted at the end of the gracefulStop or when
+// the test is stopped, until it ends or the criticalStop of the scenario expires.
+func (mi *K6) Critical(fn goja.Callable) (goja.Value, error) {
+	state := mi.vu.State()
+	if state == nil {
+		return nil, ErrCriticalInInitContext
+	}
+
+	if fn == nil {
+		return nil, errors.New("critical() requires a callback as an argument")
+	}
+
+	if state.RunCritical == nil { // e.g. in setup(), which isn't stopped like the iterations
+		return fn(goja.Undefined())
+	}
+	var ret goja.Value
+	err := state.RunCritical(func() (err error) {
+		ret, err = fn(goja.Undefined())
+		return err
+	})
+	return ret, err
+}
+
 // Check will emit check metrics for the provided checks.
 //nolint:cyclop
 func (mi *K6) Check(arg0, checks goja.Value, extras ...goja.Value) (bool, error) {
@@ -174,6 +204,9 @@ func (mi *K6) Check(arg0, checks goja.Value, extras ...goja.Value) (bool, error)
 		}
 	}
 
+	// the checks of the warm-up of scenarios are excluded from the summary
+	_, isWarmup := state.Tags.Get(lib.WarmupTagName)
+
 	succ := true
 	var exc error
 	obj := checks.ToObject(rt)
@@ -212,11 +245,15 @@ func (mi *K6) Check(arg0, checks goja.Value, extras ...goja.Value) (bool, error)
 		case <-ctx.Done():
 		default:
 			if val.ToBoolean() {
-				atomic.AddInt64(&check.Passes, 1)
+				if !isWarmup {
+					atomic.AddInt64(&check.Passes, 1)
+				}
 				stats.PushIfNotDone(ctx, state.Samples,
 					stats.Sample{Time: t, Metric: state.BuiltinMetrics.Checks, Tags: sampleTags, Value: 1})
 			} else {
-				atomic.AddInt64(&check.Fails, 1)
+				if !isWarmup {
+					atomic.AddInt64(&check.Fails, 1)
+				}
 				stats.PushIfNotDone(ctx, state.Samples,
 					stats.Sample{Time: t, Metric: state.BuiltinMetrics.Checks, Tags: sampleTags, Value: 0})
 				// A single failure makes the return value false.
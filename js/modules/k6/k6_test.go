@@ -209,6 +209,60 @@ func TestGroup(t *testing.T) {
 	})
 }
 
+func TestCritical(t *testing.T) {
+	t.Parallel()
+
+	setupCriticalTest := func(state *lib.State) *goja.Runtime {
+		rt := goja.New()
+		m, ok := New().NewModuleInstance(
+			&modulestest.VU{
+				RuntimeField: rt,
+				InitEnvField: &common.InitEnvironment{},
+				CtxField:     context.Background(),
+				StateField:   state,
+			},
+		).(*K6)
+		require.True(t, ok)
+		require.NoError(t, rt.Set("k6", m.Exports().Named))
+		return rt
+	}
+
+	t.Run("Activated", func(t *testing.T) {
+		t.Parallel()
+		var inCritical bool
+		state := &lib.State{RunCritical: func(fn func() error) error {
+			inCritical = true
+			defer func() { inCritical = false }()
+			return fn()
+		}}
+		rt := setupCriticalTest(state)
+		require.NoError(t, rt.Set("inCritical", func() bool { return inCritical }))
+
+		v, err := rt.RunString(`k6.critical(function() { return inCritical() ? "written" : "cut" })`)
+		require.NoError(t, err)
+		assert.Equal(t, "written", v.String())
+		assert.False(t, inCritical)
+
+		_, err = rt.RunString(`k6.critical(function() { throw new Error("nooo") })`)
+		assert.Contains(t, err.Error(), "nooo")
+	})
+
+	t.Run("NotActivated", func(t *testing.T) {
+		t.Parallel()
+		rt := setupCriticalTest(&lib.State{})
+		v, err := rt.RunString(`k6.critical(function() { return 42 })`)
+		require.NoError(t, err)
+		assert.Equal(t, int64(42), v.Export())
+	})
+
+	t.Run("InitContext", func(t *testing.T) {
+		t.Parallel()
+		rt := setupCriticalTest(nil)
+		_, err := rt.RunString(`k6.critical(function() {})`)
+		assert.Contains(t, err.Error(), ErrCriticalInInitContext.Error())
+	})
+}
+
 func checkTestRuntime(t testing.TB) (*goja.Runtime, chan stats.SampleContainer, *metrics.BuiltinMetrics) {
 	rt := goja.New()
 
@@ -32,6 +32,8 @@ import (
 
 	"go.k6.io/k6/js/common"
 	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/lib/types"
 	"go.k6.io/k6/stats"
 )
 
@@ -49,15 +51,43 @@ func (mi *ModuleInstance) newMetric(call goja.ConstructorCall, t stats.MetricTyp
 		return nil, errors.New("metrics must be declared in the init context")
 	}
 	rt := mi.vu.Runtime()
-	c, _ := goja.AssertFunction(rt.ToValue(func(name string, isTime ...bool) (*goja.Object, error) {
-		valueType := stats.Default
-		if len(isTime) > 0 && isTime[0] {
-			valueType = stats.Time
+	// the second argument is either isTime or the options of the metric, like {unit: "bytes",
+	// description: "The size of the uploads", id: "uploads.size"}, with the buckets of the histograms
+	c, _ := goja.AssertFunction(rt.ToValue(func(name string, args ...goja.Value) (*goja.Object, error) {
+		valueType, unit, description, id := stats.Default, "", "", ""
+		var buckets []float64
+		if len(args) > 0 {
+			if opts, ok := args[0].(*goja.Object); ok {
+				unit = optionString(opts, "unit")
+				description = optionString(opts, "description")
+				id = optionString(opts, "id")
+				if v := opts.Get("buckets"); v != nil && !goja.IsUndefined(v) && !goja.IsNull(v) {
+					if err := rt.ExportTo(v, &buckets); err != nil {
+						return nil, fmt.Errorf("the buckets of the metric '%s' need to be an array of numbers", name)
+					}
+				}
+			} else if args[0].ToBoolean() {
+				valueType = stats.Time
+			}
+		}
+		var m *stats.Metric
+		var err error
+		if unit != "" {
+			m, err = initEnv.Registry.NewMetricWithUnit(name, t, unit)
+		} else {
+			m, err = initEnv.Registry.NewMetric(name, t, valueType)
 		}
-		m, err := initEnv.Registry.NewMetric(name, t, valueType)
 		if err != nil {
 			return nil, err
 		}
+		if err = initEnv.Registry.DescribeMetric(m, description, id); err != nil {
+			return nil, err
+		}
+		if buckets != nil {
+			if err = initEnv.Registry.SetHistogramBuckets(m, buckets); err != nil {
+				return nil, err
+			}
+		}
 		metric := &Metric{metric: m, vu: mi.vu}
 		o := rt.NewObject()
 		err = o.DefineDataProperty("name", rt.ToValue(name), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_TRUE)
@@ -77,6 +107,15 @@ func (mi *ModuleInstance) newMetric(call goja.ConstructorCall, t stats.MetricTyp
 	return v.ToObject(rt), nil
 }
 
+// optionString returns the string of the option, or an empty one if it isn't set.
+func optionString(opts *goja.Object, name string) string {
+	v := opts.Get(name)
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+		return ""
+	}
+	return v.String()
+}
+
 const warnMessageValueMaxSize = 100
 
 func limitValue(v string) string {
@@ -139,7 +178,8 @@ type (
 	RootModule struct{}
 	// ModuleInstance represents an instance of the metrics module
 	ModuleInstance struct {
-		vu modules.VU
+		vu   modules.VU
+		live *metrics.LiveMetrics
 	}
 )
 
@@ -150,7 +190,12 @@ var (
 
 // NewModuleInstance implements modules.Module interface
 func (*RootModule) NewModuleInstance(m modules.VU) modules.Instance {
-	return &ModuleInstance{vu: m}
+	mi := &ModuleInstance{vu: m}
+	// the module is imported in the init context, which has the registry of the live metrics
+	if initEnv := m.InitEnv(); initEnv != nil && initEnv.Registry != nil {
+		mi.live = initEnv.Registry.LiveMetrics()
+	}
+	return mi
 }
 
 // New returns a new RootModule.
@@ -162,14 +207,42 @@ func New() *RootModule {
 func (mi *ModuleInstance) Exports() modules.Exports {
 	return modules.Exports{
 		Named: map[string]interface{}{
-			"Counter": mi.XCounter,
-			"Gauge":   mi.XGauge,
-			"Trend":   mi.XTrend,
-			"Rate":    mi.XRate,
+			"Counter":   mi.XCounter,
+			"Gauge":     mi.XGauge,
+			"Trend":     mi.XTrend,
+			"Rate":      mi.XRate,
+			"Histogram": mi.XHistogram,
+			"query":     mi.query,
 		},
 	}
 }
 
+// defaultQueryWindow is the window of query when it isn't specified.
+const defaultQueryWindow = time.Minute
+
+// query returns the current values of the metric over the window, one minute by default, e.g. the rate of a Rate
+// or the p(95) of a Trend, so the scripts can adapt to them. It returns null if the metric has no samples in it.
+func (mi *ModuleInstance) query(name string, window goja.Value) (goja.Value, error) {
+	d := defaultQueryWindow
+	if window != nil && !goja.IsUndefined(window) && !goja.IsNull(window) {
+		var err error
+		if d, err = types.GetDurationValue(window.Export()); err != nil {
+			return nil, fmt.Errorf("invalid window of the query of the metric '%s': %w", name, err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("the window of the query of the metric '%s' needs to be positive", name)
+		}
+	}
+	if mi.live == nil {
+		return goja.Null(), nil
+	}
+	values, ok := mi.live.Query(name, d)
+	if !ok {
+		return goja.Null(), nil
+	}
+	return mi.vu.Runtime().ToValue(values), nil
+}
+
 // XCounter is a counter constructor
 func (mi *ModuleInstance) XCounter(call goja.ConstructorCall, rt *goja.Runtime) *goja.Object {
 	v, err := mi.newMetric(call, stats.Counter)
@@ -205,3 +278,12 @@ func (mi *ModuleInstance) XRate(call goja.ConstructorCall, rt *goja.Runtime) *go
 	}
 	return v
 }
+
+// XHistogram is a histogram constructor
+func (mi *ModuleInstance) XHistogram(call goja.ConstructorCall, rt *goja.Runtime) *goja.Object {
+	v, err := mi.newMetric(call, stats.Histogram)
+	if err != nil {
+		common.Throw(rt, err)
+	}
+	return v
+}
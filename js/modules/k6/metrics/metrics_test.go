@@ -25,6 +25,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"testing"
+	"time"
 
 	"github.com/dop251/goja"
 	"github.com/sirupsen/logrus"
@@ -209,6 +210,114 @@ func TestMetricGetName(t *testing.T) {
 	require.Contains(t, err.Error(), "TypeError: Cannot assign to read only property 'name'")
 }
 
+func TestMetricUnit(t *testing.T) {
+	t.Parallel()
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+
+	registry := metrics.NewRegistry()
+	mii := &modulestest.VU{
+		RuntimeField: rt,
+		InitEnvField: &common.InitEnvironment{Registry: registry},
+		CtxField:     context.Background(),
+	}
+	m, ok := New().NewModuleInstance(mii).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, rt.Set("metrics", m.Exports().Named))
+	_, err := rt.RunString(`
+		new metrics.Counter("orders", {unit: "orders"});
+		new metrics.Trend("size", {unit: "bytes"});
+		new metrics.Trend("duration", true);
+		new metrics.Gauge("plain", {});
+	`)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		typ      stats.MetricType
+		contains stats.ValueType
+		unit     string
+	}{
+		{"orders", stats.Counter, stats.Default, "orders"},
+		{"size", stats.Trend, stats.Data, ""},
+		{"duration", stats.Trend, stats.Time, ""},
+		{"plain", stats.Gauge, stats.Default, ""},
+	}
+	for _, tc := range testCases {
+		metric, err := registry.NewMetric(tc.name, tc.typ)
+		require.NoError(t, err)
+		assert.Equal(t, tc.contains, metric.Contains, tc.name)
+		assert.Equal(t, tc.unit, metric.Unit, tc.name)
+	}
+
+	_, err = rt.RunString(`new metrics.Counter("orders", {unit: "carts"})`)
+	require.Error(t, err)
+}
+
+func TestMetricHistogram(t *testing.T) {
+	t.Parallel()
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+
+	registry := metrics.NewRegistry()
+	mii := &modulestest.VU{
+		RuntimeField: rt,
+		InitEnvField: &common.InitEnvironment{Registry: registry},
+		CtxField:     context.Background(),
+	}
+	m, ok := New().NewModuleInstance(mii).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, rt.Set("metrics", m.Exports().Named))
+	_, err := rt.RunString(`
+		new metrics.Histogram("size", {unit: "bytes", buckets: [1024, 4096]});
+		new metrics.Histogram("duration", true);
+	`)
+	require.NoError(t, err)
+
+	size, err := registry.NewMetric("size", stats.Histogram)
+	require.NoError(t, err)
+	assert.Equal(t, stats.Data, size.Contains)
+	assert.Equal(t, []float64{1024, 4096}, size.Buckets)
+
+	duration, err := registry.NewMetric("duration", stats.Histogram)
+	require.NoError(t, err)
+	assert.Equal(t, stats.DefaultHistogramBuckets(stats.Time), duration.Buckets)
+
+	_, err = rt.RunString(`new metrics.Histogram("other", {buckets: "10,20"})`)
+	require.Error(t, err)
+	_, err = rt.RunString(`new metrics.Histogram("other", {buckets: [20, 10]})`)
+	require.Error(t, err)
+}
+
+func TestMetricDescription(t *testing.T) {
+	t.Parallel()
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+
+	registry := metrics.NewRegistry()
+	mii := &modulestest.VU{
+		RuntimeField: rt,
+		InitEnvField: &common.InitEnvironment{Registry: registry},
+		CtxField:     context.Background(),
+	}
+	m, ok := New().NewModuleInstance(mii).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, rt.Set("metrics", m.Exports().Named))
+	_, err := rt.RunString(`
+		new metrics.Counter("orders", {unit: "orders", description: "The placed orders", id: "shop.orders"});
+	`)
+	require.NoError(t, err)
+
+	metric, err := registry.NewMetric("orders", stats.Counter)
+	require.NoError(t, err)
+	assert.Equal(t, "orders", metric.Unit)
+	assert.Equal(t, "The placed orders", metric.Description)
+	assert.Equal(t, "shop.orders", metric.ID)
+
+	_, err = rt.RunString(`new metrics.Trend("carts", {id: "shop.orders"})`)
+	require.Error(t, err)
+}
+
 func TestMetricDuplicates(t *testing.T) {
 	t.Parallel()
 	rt := goja.New()
@@ -249,3 +358,46 @@ func TestMetricDuplicates(t *testing.T) {
 
 	require.True(t, v.ToBoolean())
 }
+
+func TestMetricQuery(t *testing.T) {
+	t.Parallel()
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+
+	registry := metrics.NewRegistry()
+	mii := &modulestest.VU{
+		RuntimeField: rt,
+		InitEnvField: &common.InitEnvironment{Registry: registry},
+		CtxField:     context.Background(),
+	}
+	m, ok := New().NewModuleInstance(mii).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, rt.Set("metrics", m.Exports().Named))
+
+	failed := registry.MustNewMetric("failed", stats.Rate)
+	now := time.Now()
+	registry.LiveMetrics().Add([]stats.SampleContainer{stats.Samples{
+		{Metric: failed, Time: now.Add(-2 * time.Minute), Value: 0},
+		{Metric: failed, Time: now.Add(-30 * time.Second), Value: 1},
+		{Metric: failed, Time: now.Add(-20 * time.Second), Value: 0},
+	}})
+
+	v, err := rt.RunString(`metrics.query("failed").rate`)
+	require.NoError(t, err)
+	assert.Equal(t, 0.5, v.ToFloat())
+
+	v, err = rt.RunString(`metrics.query("failed", "5m").fails`)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), v.ToInteger())
+
+	v, err = rt.RunString(`metrics.query("failed", 10000)`)
+	require.NoError(t, err)
+	assert.True(t, goja.IsNull(v))
+
+	v, err = rt.RunString(`metrics.query("unknown")`)
+	require.NoError(t, err)
+	assert.True(t, goja.IsNull(v))
+
+	_, err = rt.RunString(`metrics.query("failed", "-1m")`)
+	require.Error(t, err)
+}
@@ -0,0 +1,181 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ws
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/gorilla/websocket"
+
+	"go.k6.io/k6/js/common"
+)
+
+// chunkSize is the size of the biggest chunk of a streamed message,
+// the frames that are bigger than it are split in several chunks.
+const chunkSize = 64 << 10
+
+// errSessionDone is returned by nextMessage when the session ended while a message was streamed.
+var errSessionDone = errors.New("the session is done")
+
+// errStreamInterrupted rejects the pending next() promises of a stream whose connection closed.
+var errStreamInterrupted = errors.New("the connection closed before the end of the message")
+
+type iteratorResult struct {
+	resolve func(interface{})
+	reject  func(interface{})
+}
+
+// BinaryStream is a binary message that is received in chunks, which are passed to its "chunk" handlers
+// as they're read. It also implements the async iterator protocol with next(), its chunks are queued once
+// next() was called, until they're taken by the next calls.
+type BinaryStream struct {
+	rt            *goja.Runtime
+	eventHandlers map[string][]goja.Callable
+
+	iterating bool
+	ended     bool
+	chunks    [][]byte
+	pending   []iteratorResult
+}
+
+func newBinaryStream(rt *goja.Runtime) *BinaryStream {
+	return &BinaryStream{rt: rt, eventHandlers: make(map[string][]goja.Callable)}
+}
+
+// On adds a handler for the "chunk" or the "end" event.
+func (bs *BinaryStream) On(event string, handler goja.Value) {
+	if handler, ok := goja.AssertFunction(handler); ok {
+		bs.eventHandlers[event] = append(bs.eventHandlers[event], handler)
+	}
+}
+
+// Next returns a promise of the next chunk, resolved with {value, done} like the ones of async iterators.
+func (bs *BinaryStream) Next() *goja.Promise {
+	bs.iterating = true
+	p, resolve, reject := bs.rt.NewPromise()
+	switch {
+	case len(bs.chunks) > 0:
+		resolve(bs.result(bs.chunks[0], false))
+		bs.chunks = bs.chunks[1:]
+	case bs.ended:
+		resolve(bs.result(nil, true))
+	default:
+		bs.pending = append(bs.pending, iteratorResult{resolve: resolve, reject: reject})
+	}
+	return p
+}
+
+func (bs *BinaryStream) result(chunk []byte, done bool) *goja.Object {
+	obj := bs.rt.NewObject()
+	if done {
+		_ = obj.Set("value", goja.Undefined())
+	} else {
+		ab := bs.rt.NewArrayBuffer(chunk)
+		_ = obj.Set("value", &ab)
+	}
+	_ = obj.Set("done", done)
+	return obj
+}
+
+func (bs *BinaryStream) handleEvent(event string, args ...goja.Value) {
+	for _, handler := range bs.eventHandlers[event] {
+		if _, err := handler(goja.Undefined(), args...); err != nil {
+			common.Throw(bs.rt, err)
+		}
+	}
+}
+
+func (bs *BinaryStream) receive(chunk []byte) {
+	switch {
+	case len(bs.pending) > 0:
+		bs.pending[0].resolve(bs.result(chunk, false))
+		bs.pending = bs.pending[1:]
+	case bs.iterating:
+		bs.chunks = append(bs.chunks, chunk)
+	}
+	ab := bs.rt.NewArrayBuffer(chunk)
+	bs.handleEvent("chunk", bs.rt.ToValue(&ab))
+}
+
+func (bs *BinaryStream) end() {
+	bs.ended = true
+	for _, p := range bs.pending {
+		p.resolve(bs.result(nil, true))
+	}
+	bs.pending = nil
+	bs.handleEvent("end")
+}
+
+func (bs *BinaryStream) interrupt() {
+	for _, p := range bs.pending {
+		p.reject(errStreamInterrupted)
+	}
+	bs.pending = nil
+}
+
+// nextMessage reads the next message of the connection. When the binary messages are streamed,
+// it passes those in chunks to readChan and returns a nil message once they're read.
+func (s *Socket) nextMessage(conn *websocket.Conn, readChan chan *message) (*message, error) {
+	if !s.streamBinary {
+		messageType, data, err := conn.ReadMessage()
+		return &message{mtype: messageType, data: data}, err
+	}
+
+	messageType, r, err := conn.NextReader()
+	if err != nil {
+		return nil, err
+	}
+	if messageType != websocket.BinaryMessage {
+		data, err := io.ReadAll(r)
+		return &message{mtype: messageType, data: data}, err
+	}
+
+	start := time.Now()
+	for {
+		// the reads of gorilla/websocket don't go past the frame, unless it's compressed
+		buf := make([]byte, chunkSize)
+		n, err := r.Read(buf)
+		now := time.Now()
+		if n > 0 {
+			chunk := &message{mtype: messageType, data: buf[:n], chunk: true, duration: now.Sub(start)}
+			select {
+			case readChan <- chunk:
+			case <-s.done:
+				return nil, errSessionDone
+			}
+			start = now
+		}
+		if errors.Is(err, io.EOF) {
+			select {
+			case readChan <- &message{mtype: messageType, chunk: true, end: true}:
+				return nil, nil
+			case <-s.done:
+				return nil, errSessionDone
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
@@ -31,6 +31,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/dop251/goja"
@@ -38,8 +39,10 @@ import (
 
 	"go.k6.io/k6/js/common"
 	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/js/modules/k6/experimental"
 	httpModule "go.k6.io/k6/js/modules/k6/http"
 	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/lib/types"
 	"go.k6.io/k6/stats"
 )
 
@@ -92,6 +95,15 @@ type Socket struct {
 	scheduled     chan goja.Callable
 	done          chan struct{}
 	shutdownOnce  sync.Once
+	// connClosed is set when the connection was closed before a reconnection, which failed
+	connClosed bool
+	// counters count the bytes of the connections of the session, which are more than one after reconnections
+	counters []*countingConn
+	// streamBinary passes the binary messages in chunks to the binaryStream handlers,
+	// stream being the one that is received
+	streamBinary bool
+	stream       *BinaryStream
+	streamSize   int
 
 	pingSendTimestamps map[string]time.Time
 	pingSendCounter    int
@@ -109,9 +121,54 @@ type WSHTTPResponse struct {
 	Error   string            `json:"error"`
 }
 
+// reconnectOptions are the options of the reconnections after the connection closed unexpectedly,
+// which wait for a delay that doubles after each attempt, up to maxDelay.
+type reconnectOptions struct {
+	attempts int64
+	delay    time.Duration
+	maxDelay time.Duration
+}
+
+func parseReconnectOptions(rt *goja.Runtime, v goja.Value) (*reconnectOptions, error) {
+	o := &reconnectOptions{attempts: 3, delay: time.Second, maxDelay: 30 * time.Second}
+	if enable, ok := v.Export().(bool); ok {
+		if !enable {
+			return nil, nil //nolint:nilnil
+		}
+		return o, nil
+	}
+	obj := v.ToObject(rt)
+	for _, k := range obj.Keys() {
+		var err error
+		switch k {
+		case "attempts":
+			var ok bool
+			if o.attempts, ok = obj.Get(k).Export().(int64); !ok || o.attempts <= 0 {
+				return nil, fmt.Errorf("invalid reconnect attempts '%s', it needs to be a positive integer", obj.Get(k))
+			}
+		case "delay":
+			if o.delay, err = types.GetDurationValue(obj.Get(k).Export()); err != nil || o.delay < 0 {
+				return nil, fmt.Errorf("invalid reconnect delay '%s'", obj.Get(k))
+			}
+		case "maxDelay":
+			if o.maxDelay, err = types.GetDurationValue(obj.Get(k).Export()); err != nil || o.maxDelay < 0 {
+				return nil, fmt.Errorf("invalid reconnect maxDelay '%s'", obj.Get(k))
+			}
+		default:
+			return nil, fmt.Errorf("unknown reconnect option '%s'", k)
+		}
+	}
+	return o, nil
+}
+
 type message struct {
 	mtype int // message type consts as defined in gorilla/websocket/conn.go
 	data  []byte
+
+	// chunk is set for the chunks of the streamed binary messages, the last one only marks their end
+	chunk    bool
+	end      bool
+	duration time.Duration
 }
 
 const writeWait = 10 * time.Second
@@ -157,6 +214,9 @@ func (mi *WS) Connect(url string, args ...goja.Value) (*WSHTTPResponse, error) {
 
 	tags := state.CloneTags()
 	jar := state.CookieJar
+	var abort <-chan struct{}
+	var reconnect *reconnectOptions
+	var streamBinary bool
 
 	// Parse the optional second argument (params)
 	if !goja.IsUndefined(paramsV) && !goja.IsNull(paramsV) {
@@ -200,8 +260,17 @@ func (mi *WS) Connect(url string, args ...goja.Value) (*WSHTTPResponse, error) {
 				// compression here relies on the implementation in gorilla/websocket package, usage is
 				// experimental and may result in decreased performance. package supports
 				// only "no context takeover" scenario
+				compressionV := params.Get(k)
+				if goja.IsUndefined(compressionV) || goja.IsNull(compressionV) {
+					continue
+				}
+				// true and false force the compression on and off
+				if enable, ok := compressionV.Export().(bool); ok {
+					enableCompression = enable
+					continue
+				}
 
-				algoString := strings.TrimSpace(params.Get(k).ToString().String())
+				algoString := strings.TrimSpace(compressionV.String())
 				if algoString == "" {
 					continue
 				}
@@ -211,6 +280,27 @@ func (mi *WS) Connect(url string, args ...goja.Value) (*WSHTTPResponse, error) {
 				}
 
 				enableCompression = true
+			case "signal":
+				v := params.Get(k).Export()
+				if v == nil {
+					continue
+				}
+				signal, ok := v.(*experimental.AbortSignal)
+				if !ok {
+					return nil, errors.New("signal must be an AbortSignal")
+				}
+				abort = signal.Done()
+			case "reconnect":
+				reconnectV := params.Get(k)
+				if goja.IsUndefined(reconnectV) || goja.IsNull(reconnectV) {
+					continue
+				}
+				var err error
+				if reconnect, err = parseReconnectOptions(rt, reconnectV); err != nil {
+					return nil, err
+				}
+			case "streamBinary":
+				streamBinary = params.Get(k).ToBoolean()
 			}
 		}
 
@@ -227,11 +317,20 @@ func (mi *WS) Connect(url string, args ...goja.Value) (*WSHTTPResponse, error) {
 		tlsConfig.NextProtos = []string{"http/1.1"}
 	}
 
+	// counter counts the bytes of the last connection that was dialed
+	var counter *countingConn
 	wsd := websocket.Dialer{
 		HandshakeTimeout: time.Second * 60, // TODO configurable
 		// Pass a custom net.DialContext function to websocket.Dialer that will substitute
 		// the underlying net.Conn with our own tracked netext.Conn
-		NetDialContext:    state.Dialer.DialContext,
+		NetDialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := state.Dialer.DialContext(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			counter = &countingConn{Conn: conn}
+			return counter, nil
+		},
 		Proxy:             http.ProxyFromEnvironment,
 		TLSClientConfig:   tlsConfig,
 		EnableCompression: enableCompression,
@@ -241,8 +340,22 @@ func (mi *WS) Connect(url string, args ...goja.Value) (*WSHTTPResponse, error) {
 		wsd.Jar = nil
 	}
 
+	dialCtx := ctx
+	if abort != nil {
+		var cancel context.CancelFunc
+		dialCtx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		go func() {
+			select {
+			case <-abort:
+				cancel()
+			case <-dialCtx.Done():
+			}
+		}()
+	}
+
 	start := time.Now()
-	conn, httpResponse, connErr := wsd.DialContext(ctx, url, header)
+	conn, httpResponse, connErr := wsd.DialContext(dialCtx, url, header)
 	connectionEnd := time.Now()
 	connectionDuration := stats.D(connectionEnd.Sub(start))
 
@@ -270,6 +383,7 @@ func (mi *WS) Connect(url string, args ...goja.Value) (*WSHTTPResponse, error) {
 		pingSendTimestamps: make(map[string]time.Time),
 		scheduled:          make(chan goja.Callable),
 		done:               make(chan struct{}),
+		streamBinary:       streamBinary,
 		samplesOutput:      state.Samples,
 		sampleTags:         stats.IntoSampleTags(&tags),
 		builtinMetrics:     state.BuiltinMetrics,
@@ -284,6 +398,9 @@ func (mi *WS) Connect(url string, args ...goja.Value) (*WSHTTPResponse, error) {
 		Time: start,
 	})
 
+	if connErr != nil && dialCtx.Err() != nil && ctx.Err() == nil {
+		connErr = fmt.Errorf("websocket connection aborted: %w", connErr)
+	}
 	if connErr != nil {
 		// Pass the error to the user script before exiting immediately
 		socket.handleEvent("error", rt.ToValue(connErr))
@@ -302,30 +419,84 @@ func (mi *WS) Connect(url string, args ...goja.Value) (*WSHTTPResponse, error) {
 	}
 	wsResponse.URL = url
 
-	defer func() { _ = conn.Close() }()
-
-	// The connection is now open, emit the event
-	socket.handleEvent("open")
-
-	// Make the default close handler a noop to avoid duplicate closes,
-	// since we use custom closing logic to call user's event
-	// handlers and for cleanup. See closeConnection.
-	// closeConnection is not set directly as a handler here to
-	// avoid race conditions when calling the Goja runtime.
-	conn.SetCloseHandler(func(code int, text string) error { return nil })
+	defer func() { _ = socket.conn.Close() }()
 
 	// Pass ping/pong events through the main control loop
 	pingChan := make(chan string)
 	pongChan := make(chan string)
-	conn.SetPingHandler(func(msg string) error { pingChan <- msg; return nil })
-	conn.SetPongHandler(func(pingID string) error { pongChan <- pingID; return nil })
 
 	readDataChan := make(chan *message)
 	readCloseChan := make(chan int)
 	readErrChan := make(chan error)
 
-	// Wraps a couple of channels around conn.ReadMessage
-	go socket.readPump(readDataChan, readErrChan, readCloseChan)
+	// open sets up the session on a new connection, the first one or the one of a reconnection
+	open := func(conn *websocket.Conn) {
+		socket.conn = conn
+		// the bytes of the handshake aren't those of frames
+		counter.markHandshake()
+		socket.counters = append(socket.counters, counter)
+
+		// Make the default close handler a noop to avoid duplicate closes,
+		// since we use custom closing logic to call user's event
+		// handlers and for cleanup. See closeConnection.
+		// closeConnection is not set directly as a handler here to
+		// avoid race conditions when calling the Goja runtime.
+		conn.SetCloseHandler(func(code int, text string) error { return nil })
+		conn.SetPingHandler(func(msg string) error { pingChan <- msg; return nil })
+		conn.SetPongHandler(func(pingID string) error { pongChan <- pingID; return nil })
+
+		// Wraps a couple of channels around conn.ReadMessage
+		go socket.readPump(conn, readDataChan, readErrChan, readCloseChan)
+
+		// The connection is now open, emit the event
+		socket.handleEvent("open")
+	}
+
+	// reconnectAfter replaces a connection that was closed unexpectedly with the code by a new one,
+	// it returns false if none could be established with the reconnect options.
+	reconnectAfter := func(code int) bool {
+		_ = socket.conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(code, ""),
+			time.Now().Add(writeWait),
+		)
+		_ = socket.conn.Close()
+		socket.connClosed = true
+
+		delay := reconnect.delay
+		for attempt := int64(0); attempt < reconnect.attempts; attempt++ {
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return false
+			case <-abort:
+				timer.Stop()
+				return false
+			}
+			if delay *= 2; delay > reconnect.maxDelay {
+				delay = reconnect.maxDelay
+			}
+
+			conn, _, err := wsd.DialContext(dialCtx, url, header) //nolint:bodyclose
+			if err != nil {
+				socket.handleEvent("error", rt.ToValue(err))
+				continue
+			}
+			socket.connClosed = false
+			stats.PushIfNotDone(ctx, state.Samples, stats.Sample{
+				Metric: socket.builtinMetrics.WSReconnects,
+				Tags:   socket.sampleTags,
+				Time:   time.Now(),
+				Value:  1,
+			})
+			open(conn)
+			return true
+		}
+		return false
+	}
+
+	open(conn)
 
 	// we do it here as below we can panic, which translates to an exception in js code
 	defer func() {
@@ -333,11 +504,35 @@ func (mi *WS) Connect(url string, args ...goja.Value) (*WSHTTPResponse, error) {
 		end := time.Now()
 		sessionDuration := stats.D(end.Sub(start))
 
-		stats.PushIfNotDone(ctx, state.Samples, stats.Sample{
-			Metric: socket.builtinMetrics.WSSessionDuration,
-			Tags:   socket.sampleTags,
-			Time:   start,
-			Value:  sessionDuration,
+		var read, written int64
+		for _, c := range socket.counters {
+			r, w := c.frames()
+			read, written = read+r, written+w
+		}
+
+		stats.PushIfNotDone(ctx, state.Samples, stats.ConnectedSamples{
+			Samples: []stats.Sample{
+				{
+					Metric: socket.builtinMetrics.WSSessionDuration,
+					Tags:   socket.sampleTags,
+					Time:   start,
+					Value:  sessionDuration,
+				},
+				{
+					Metric: socket.builtinMetrics.WSWireDataSent,
+					Tags:   socket.sampleTags,
+					Time:   end,
+					Value:  float64(written),
+				},
+				{
+					Metric: socket.builtinMetrics.WSWireDataReceived,
+					Tags:   socket.sampleTags,
+					Time:   end,
+					Value:  float64(read),
+				},
+			},
+			Tags: socket.sampleTags,
+			Time: end,
 		})
 	}()
 
@@ -361,11 +556,23 @@ func (mi *WS) Connect(url string, args ...goja.Value) (*WSHTTPResponse, error) {
 			socket.handleEvent("pong")
 
 		case msg := <-readDataChan:
-			stats.PushIfNotDone(ctx, socket.samplesOutput, stats.Sample{
-				Metric: socket.builtinMetrics.WSMessagesReceived,
-				Time:   time.Now(),
-				Tags:   socket.sampleTags,
-				Value:  1,
+			if msg.chunk {
+				socket.handleChunk(msg)
+				continue
+			}
+			now := time.Now()
+			stats.PushIfNotDone(ctx, socket.samplesOutput, stats.ConnectedSamples{
+				Samples: []stats.Sample{
+					{Metric: socket.builtinMetrics.WSMessagesReceived, Time: now, Tags: socket.sampleTags, Value: 1},
+					{
+						Metric: socket.builtinMetrics.WSMessagesDataReceived,
+						Time:   now,
+						Tags:   socket.sampleTags,
+						Value:  float64(len(msg.data)),
+					},
+				},
+				Tags: socket.sampleTags,
+				Time: now,
 			})
 
 			if msg.mtype == websocket.BinaryMessage {
@@ -379,6 +586,14 @@ func (mi *WS) Connect(url string, args ...goja.Value) (*WSHTTPResponse, error) {
 			socket.handleEvent("error", rt.ToValue(readErr))
 
 		case code := <-readCloseChan:
+			if socket.stream != nil {
+				socket.stream.interrupt()
+				socket.stream = nil
+			}
+			// a normal closure by the server isn't unexpected
+			if reconnect != nil && code != websocket.CloseNormalClosure && reconnectAfter(code) {
+				continue
+			}
 			_ = socket.closeConnection(code)
 
 		case scheduledFn := <-socket.scheduled:
@@ -392,6 +607,11 @@ func (mi *WS) Connect(url string, args ...goja.Value) (*WSHTTPResponse, error) {
 			// socket events will not be forwarded to the VU
 			_ = socket.closeConnection(websocket.CloseGoingAway)
 
+		case <-abort:
+			// the signal passed in the params was aborted
+			abort = nil
+			_ = socket.closeConnection(websocket.CloseGoingAway)
+
 		case <-socket.done:
 			// This is the final exit point normally triggered by closeConnection
 			return wsResponse, nil
@@ -415,29 +635,31 @@ func (s *Socket) handleEvent(event string, args ...goja.Value) {
 	}
 }
 
-// Send writes the given string message to the connection.
-func (s *Socket) Send(message string) {
-	if err := s.conn.WriteMessage(websocket.TextMessage, []byte(message)); err != nil {
+// Send writes the given string message to the connection. The returned promise is resolved once the frame
+// was flushed to the connection, which blocks the socket's event loop for as long as the connection can't
+// take it, or rejected with the error that is also passed to the error handlers.
+func (s *Socket) Send(message string) *goja.Promise {
+	err := s.conn.WriteMessage(websocket.TextMessage, []byte(message))
+	if err != nil {
 		s.handleEvent("error", s.rt.ToValue(err))
 	}
 
-	stats.PushIfNotDone(s.ctx, s.samplesOutput, stats.Sample{
-		Metric: s.builtinMetrics.WSMessagesSent,
-		Time:   time.Now(),
-		Tags:   s.sampleTags,
-		Value:  1,
-	})
+	s.pushSent(len(message))
+	return s.flushed(err)
 }
 
-// SendBinary writes the given ArrayBuffer message to the connection.
-func (s *Socket) SendBinary(message goja.Value) {
+// SendBinary writes the given ArrayBuffer message to the connection, it returns a promise like Send.
+func (s *Socket) SendBinary(message goja.Value) *goja.Promise {
 	if message == nil {
 		common.Throw(s.rt, errors.New("missing argument, expected ArrayBuffer"))
 	}
 
 	msg := message.Export()
+	var size int
+	var err error
 	if ab, ok := msg.(goja.ArrayBuffer); ok {
-		if err := s.conn.WriteMessage(websocket.BinaryMessage, ab.Bytes()); err != nil {
+		size = len(ab.Bytes())
+		if err = s.conn.WriteMessage(websocket.BinaryMessage, ab.Bytes()); err != nil {
 			s.handleEvent("error", s.rt.ToValue(err))
 		}
 	} else {
@@ -451,11 +673,73 @@ func (s *Socket) SendBinary(message goja.Value) {
 		common.Throw(s.rt, fmt.Errorf("expected ArrayBuffer as argument, received: %s", jsType))
 	}
 
-	stats.PushIfNotDone(s.ctx, s.samplesOutput, stats.Sample{
-		Metric: s.builtinMetrics.WSMessagesSent,
-		Time:   time.Now(),
-		Tags:   s.sampleTags,
-		Value:  1,
+	s.pushSent(size)
+	return s.flushed(err)
+}
+
+// flushed returns the promise of a sent message, settled with the error of its write.
+func (s *Socket) flushed(err error) *goja.Promise {
+	p, resolve, reject := s.rt.NewPromise()
+	if err != nil {
+		reject(err)
+	} else {
+		resolve(goja.Undefined())
+	}
+	return p
+}
+
+// handleChunk passes a chunk of a streamed binary message to its stream, which is passed to the binaryStream
+// handlers with the first one, and emits the metrics of the frame, and of the message with the last one.
+func (s *Socket) handleChunk(msg *message) {
+	if s.stream == nil {
+		s.stream = newBinaryStream(s.rt)
+		s.streamSize = 0
+		s.handleEvent("binaryStream", s.rt.ToValue(s.stream))
+	}
+
+	now := time.Now()
+	if msg.end {
+		stream := s.stream
+		s.stream = nil
+		stats.PushIfNotDone(s.ctx, s.samplesOutput, stats.ConnectedSamples{
+			Samples: []stats.Sample{
+				{Metric: s.builtinMetrics.WSMessagesReceived, Time: now, Tags: s.sampleTags, Value: 1},
+				{
+					Metric: s.builtinMetrics.WSMessagesDataReceived,
+					Time:   now,
+					Tags:   s.sampleTags,
+					Value:  float64(s.streamSize),
+				},
+			},
+			Tags: s.sampleTags,
+			Time: now,
+		})
+		stream.end()
+		return
+	}
+
+	s.streamSize += len(msg.data)
+	stats.PushIfNotDone(s.ctx, s.samplesOutput, stats.ConnectedSamples{
+		Samples: []stats.Sample{
+			{Metric: s.builtinMetrics.WSFramesReceived, Time: now, Tags: s.sampleTags, Value: 1},
+			{Metric: s.builtinMetrics.WSFrameReceiving, Time: now, Tags: s.sampleTags, Value: stats.D(msg.duration)},
+		},
+		Tags: s.sampleTags,
+		Time: now,
+	})
+	s.stream.receive(msg.data)
+}
+
+// pushSent emits the metrics of a sent message, with the size of its payload before it's compressed.
+func (s *Socket) pushSent(size int) {
+	now := time.Now()
+	stats.PushIfNotDone(s.ctx, s.samplesOutput, stats.ConnectedSamples{
+		Samples: []stats.Sample{
+			{Metric: s.builtinMetrics.WSMessagesSent, Time: now, Tags: s.sampleTags, Value: 1},
+			{Metric: s.builtinMetrics.WSMessagesDataSent, Time: now, Tags: s.sampleTags, Value: float64(size)},
+		},
+		Tags: s.sampleTags,
+		Time: now,
 	})
 }
 
@@ -578,6 +862,10 @@ func (s *Socket) closeConnection(code int) error {
 			// Stop the main control loop
 			close(s.done)
 		}()
+		if s.connClosed {
+			s.handleEvent("close", s.rt.ToValue(code))
+			return
+		}
 		err = s.conn.WriteControl(websocket.CloseMessage,
 			websocket.FormatCloseMessage(code, ""),
 			time.Now().Add(writeWait),
@@ -595,9 +883,14 @@ func (s *Socket) closeConnection(code int) error {
 }
 
 // Wraps conn.ReadMessage in a channel
-func (s *Socket) readPump(readChan chan *message, errorChan chan error, closeChan chan int) { //nolint: cyclop
+func (s *Socket) readPump(
+	conn *websocket.Conn, readChan chan *message, errorChan chan error, closeChan chan int,
+) { //nolint: cyclop
 	for {
-		messageType, data, err := s.conn.ReadMessage()
+		msg, err := s.nextMessage(conn, readChan)
+		if errors.Is(err, errSessionDone) {
+			return
+		}
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(
 				err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
@@ -619,14 +912,50 @@ func (s *Socket) readPump(readChan chan *message, errorChan chan error, closeCha
 			return
 		}
 
+		if msg == nil {
+			// the message was streamed
+			continue
+		}
 		select {
-		case readChan <- &message{messageType, data}:
+		case readChan <- msg:
 		case <-s.done:
 			return
 		}
 	}
 }
 
+// countingConn counts the bytes read from and written to a connection.
+type countingConn struct {
+	net.Conn
+	read, written int64
+
+	// the counts once the handshake was done
+	handshakeRead, handshakeWritten int64
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	atomic.AddInt64(&c.read, int64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	atomic.AddInt64(&c.written, int64(n))
+	return n, err
+}
+
+// markHandshake marks the end of the handshake, after which the bytes are those of the frames.
+func (c *countingConn) markHandshake() {
+	c.handshakeRead, c.handshakeWritten = atomic.LoadInt64(&c.read), atomic.LoadInt64(&c.written)
+}
+
+// frames returns the bytes of the frames that were read and written. For wss:// connections, they include
+// the TLS overhead, since the TLS connection is established on top of this one.
+func (c *countingConn) frames() (read, written int64) {
+	return atomic.LoadInt64(&c.read) - c.handshakeRead, atomic.LoadInt64(&c.written) - c.handshakeWritten
+}
+
 // Wrap the raw HTTPResponse we received to a WSHTTPResponse we can pass to the user
 func wrapHTTPResponse(httpResponse *http.Response) (*WSHTTPResponse, error) {
 	wsResponse := WSHTTPResponse{
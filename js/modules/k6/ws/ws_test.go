@@ -26,9 +26,10 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"net/http/cookiejar"
 	"net/http/httptest"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -40,6 +41,7 @@ import (
 
 	"go.k6.io/k6/js/common"
 	httpModule "go.k6.io/k6/js/modules/k6/http"
+	"go.k6.io/k6/js/modules/k6/experimental"
 	"go.k6.io/k6/js/modulestest"
 	"go.k6.io/k6/lib"
 	"go.k6.io/k6/lib/metrics"
@@ -914,7 +916,7 @@ func TestReadPump(t *testing.T) {
 			errChan := make(chan error)
 			closeChan := make(chan int)
 			s := &Socket{conn: conn}
-			go s.readPump(msgChan, errChan, closeChan)
+			go s.readPump(conn, msgChan, errChan, closeChan)
 
 		readChans:
 			for {
@@ -1085,6 +1087,8 @@ func TestCompression(t *testing.T) {
 			{compression: "  "},
 			{compression: "deflate"},
 			{compression: "deflate "},
+			{compression: "true"},
+			{compression: "false"},
 			{
 				compression:   "gzip",
 				expectedError: `unsupported compression algorithm 'gzip', supported algorithm is 'deflate'`,
@@ -1130,7 +1134,11 @@ func TestCompression(t *testing.T) {
 				}))
 
 				_, err := ts.rt.RunString(sr(`
-					var res = ws.connect("WSBIN_URL/ws-compression-param", {"compression":"` + testCase.compression + `"}, function(socket){
+					var compression = "` + testCase.compression + `";
+					if (compression === "true" || compression === "false") {
+						compression = compression === "true";
+					}
+					var res = ws.connect("WSBIN_URL/ws-compression-param", {"compression":compression}, function(socket){
 						socket.close()
 					});
 				`))
@@ -1146,6 +1154,300 @@ func TestCompression(t *testing.T) {
 	})
 }
 
+func TestCompressionNegotiation(t *testing.T) {
+	t.Parallel()
+	text := strings.Repeat("compressible ", 100)
+
+	testCases := []struct {
+		params     string
+		compressed bool
+	}{
+		{params: `{}`, compressed: false},
+		{params: `{ compression: true }`, compressed: true},
+		{params: `{ compression: "deflate" }`, compressed: true},
+		{params: `{ compression: false }`, compressed: false},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.params, func(t *testing.T) {
+			t.Parallel()
+			ts := newTestState(t)
+			sr := ts.tb.Replacer.Replace
+			ts.tb.Mux.HandleFunc("/ws-compression-echo", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				upgrader := websocket.Upgrader{EnableCompression: true}
+				conn, err := upgrader.Upgrade(w, req, w.Header())
+				if err != nil {
+					return
+				}
+				defer func() { _ = conn.Close() }()
+				messageType, data, err := conn.ReadMessage()
+				if err != nil {
+					return
+				}
+				_ = conn.WriteMessage(messageType, data)
+				_, _, _ = conn.ReadMessage()
+			}))
+
+			_, err := ts.rt.RunString(sr(`
+			var res = ws.connect("WSBIN_URL/ws-compression-echo", ` + tc.params + `, function(socket) {
+				socket.on("open", function() { socket.send("` + text + `"); });
+				socket.on("message", function() { socket.close(); });
+			});
+			var extensions = res.headers["Sec-Websocket-Extensions"] || "";
+			`))
+			require.NoError(t, err)
+			extensions := ts.rt.Get("extensions").String()
+			assert.Equal(t, tc.compressed, strings.Contains(extensions, "permessage-deflate"), extensions)
+
+			data := map[string]float64{}
+			for _, c := range stats.GetBufferedSamples(ts.samples) {
+				for _, s := range c.GetSamples() {
+					data[s.Metric.Name] += s.Value
+				}
+			}
+			assert.Equal(t, float64(len(text)), data[metrics.WSMessagesDataSentName])
+			assert.Equal(t, float64(len(text)), data[metrics.WSMessagesDataReceivedName])
+			// the frames of the message and of the close, with the headers and the mask of the client
+			if tc.compressed {
+				assert.Less(t, data[metrics.WSWireDataSentName], float64(len(text)))
+				assert.Less(t, data[metrics.WSWireDataReceivedName], float64(len(text)))
+			} else {
+				assert.Greater(t, data[metrics.WSWireDataSentName], float64(len(text)))
+				assert.Greater(t, data[metrics.WSWireDataReceivedName], float64(len(text)))
+			}
+		})
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reconnected", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestState(t)
+		sr := ts.tb.Replacer.Replace
+		var connections int64
+		ts.tb.Mux.HandleFunc("/ws-flaky", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			conn, err := (&websocket.Upgrader{}).Upgrade(w, req, w.Header())
+			if err != nil {
+				return
+			}
+			defer func() { _ = conn.Close() }()
+			if atomic.AddInt64(&connections, 1) == 1 {
+				_ = conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseInternalServerErr, ""), time.Now().Add(time.Second))
+				return
+			}
+			messageType, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			_ = conn.WriteMessage(messageType, data)
+			_, _, _ = conn.ReadMessage()
+		}))
+
+		_, err := ts.rt.RunString(sr(`
+		var opened = 0, closed = 0, received = "";
+		var res = ws.connect("WSBIN_URL/ws-flaky", { reconnect: { attempts: 3, delay: "10ms" } }, function(socket) {
+			socket.on("open", function() {
+				opened++;
+				if (opened == 2) {
+					socket.send("again");
+				}
+			});
+			socket.on("message", function(msg) {
+				received = msg;
+				socket.close();
+			});
+			socket.on("close", function() { closed++; });
+		});
+		if (opened !== 2) {
+			throw new Error("the connection was opened " + opened + " times");
+		}
+		if (closed !== 1) {
+			throw new Error("the connection was closed " + closed + " times");
+		}
+		if (received !== "again") {
+			throw new Error("unexpected message " + received);
+		}
+		`))
+		require.NoError(t, err)
+		assert.EqualValues(t, 2, atomic.LoadInt64(&connections))
+
+		var reconnects float64
+		for _, c := range stats.GetBufferedSamples(ts.samples) {
+			for _, s := range c.GetSamples() {
+				if s.Metric.Name == metrics.WSReconnectsName {
+					reconnects += s.Value
+				}
+			}
+		}
+		assert.Equal(t, float64(1), reconnects)
+	})
+
+	t.Run("exhausted", func(t *testing.T) {
+		t.Parallel()
+		ts := newTestState(t)
+		sr := ts.tb.Replacer.Replace
+		var connections int64
+		ts.tb.Mux.HandleFunc("/ws-gone", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if atomic.AddInt64(&connections, 1) > 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			conn, err := (&websocket.Upgrader{}).Upgrade(w, req, w.Header())
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}))
+
+		_, err := ts.rt.RunString(sr(`
+		var opened = 0, errors = 0, closeCode;
+		var res = ws.connect("WSBIN_URL/ws-gone", { reconnect: { attempts: 2, delay: "10ms" } }, function(socket) {
+			socket.on("open", function() { opened++; });
+			socket.on("error", function() { errors++; });
+			socket.on("close", function(code) { closeCode = code; });
+		});
+		if (opened !== 1) {
+			throw new Error("the connection was opened " + opened + " times");
+		}
+		// the unexpected closure and the two failed attempts
+		if (errors !== 3) {
+			throw new Error("unexpected errors " + errors);
+		}
+		if (closeCode !== 1006) {
+			throw new Error("unexpected close code " + closeCode);
+		}
+		`))
+		require.NoError(t, err)
+		assert.EqualValues(t, 3, atomic.LoadInt64(&connections))
+		for _, c := range stats.GetBufferedSamples(ts.samples) {
+			for _, s := range c.GetSamples() {
+				assert.NotEqual(t, metrics.WSReconnectsName, s.Metric.Name)
+			}
+		}
+	})
+
+	t.Run("options", func(t *testing.T) {
+		t.Parallel()
+		testCases := []struct {
+			reconnect, err string
+		}{
+			{`{ attempts: 0 }`, "invalid reconnect attempts '0', it needs to be a positive integer"},
+			{`{ attempts: 1.5 }`, "invalid reconnect attempts '1.5', it needs to be a positive integer"},
+			{`{ delay: "soon" }`, "invalid reconnect delay 'soon'"},
+			{`{ maxDelay: -1 }`, "invalid reconnect maxDelay '-1'"},
+			{`{ retries: 3 }`, "unknown reconnect option 'retries'"},
+		}
+		ts := newTestState(t)
+		sr := ts.tb.Replacer.Replace
+		for _, tc := range testCases {
+			_, err := ts.rt.RunString(sr(`ws.connect("WSBIN_URL/ws-echo", { reconnect: ` + tc.reconnect +
+				` }, function(socket) { socket.close(); });`))
+			require.Error(t, err, tc.reconnect)
+			assert.Contains(t, err.Error(), tc.err)
+		}
+	})
+}
+
+func TestSendPromise(t *testing.T) {
+	t.Parallel()
+	ts := newTestState(t)
+	sr := ts.tb.Replacer.Replace
+
+	_, err := ts.rt.RunString(sr(`
+	var sent = [], rejected;
+	var res = ws.connect("WSBIN_URL/ws-echo", function(socket) {
+		socket.on("open", function() {
+			socket.sendBinary(new Uint8Array([1, 2]).buffer).then(function() { sent.push("binary"); });
+		});
+		socket.on("binaryMessage", function() {
+			socket.close();
+			socket.send("closed").catch(function(e) { rejected = e; });
+		});
+	});
+	var textRes = ws.connect("WSBIN_URL/ws-echo", function(socket) {
+		socket.on("open", function() {
+			socket.send("text").then(function() { sent.push("text"); });
+		});
+		socket.on("message", function() { socket.close(); });
+	});
+	`))
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"binary", "text"}, ts.rt.Get("sent").Export())
+	rejected := ts.rt.Get("rejected")
+	require.False(t, goja.IsUndefined(rejected))
+	assert.Contains(t, rejected.String(), "close sent")
+}
+
+func TestStreamBinary(t *testing.T) {
+	t.Parallel()
+	ts := newTestState(t)
+	sr := ts.tb.Replacer.Replace
+	ts.tb.Mux.HandleFunc("/ws-media", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// the small buffer splits the message in frames
+		conn, err := (&websocket.Upgrader{WriteBufferSize: 1024}).Upgrade(w, req, w.Header())
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		_ = conn.WriteMessage(websocket.BinaryMessage, bytes.Repeat([]byte{1, 2, 3, 4}, 1000))
+		_ = conn.WriteMessage(websocket.TextMessage, []byte("done"))
+		_, _, _ = conn.ReadMessage()
+	}))
+
+	_, err := ts.rt.RunString(sr(`
+	var chunks = 0, size = 0, ended = 0, text = "", iterated = 0, iterationDone = false;
+	var res = ws.connect("WSBIN_URL/ws-media", { streamBinary: true }, function(socket) {
+		socket.on("binaryMessage", function() {
+			throw new Error("the streamed message was passed to binaryMessage");
+		});
+		socket.on("binaryStream", function(stream) {
+			stream.on("chunk", function(chunk) {
+				chunks++;
+				size += chunk.byteLength;
+			});
+			stream.on("end", function() { ended++; });
+			var iterate = function(r) {
+				if (r.done) {
+					iterationDone = true;
+					return;
+				}
+				iterated += r.value.byteLength;
+				return stream.next().then(iterate);
+			};
+			stream.next().then(iterate);
+		});
+		socket.on("message", function(msg) {
+			text = msg;
+			socket.close();
+		});
+	});
+	`))
+	require.NoError(t, err)
+	chunks := ts.rt.Get("chunks").ToInteger()
+	assert.Greater(t, chunks, int64(1))
+	assert.EqualValues(t, 4000, ts.rt.Get("size").ToInteger())
+	assert.EqualValues(t, 1, ts.rt.Get("ended").ToInteger())
+	assert.Equal(t, "done", ts.rt.Get("text").String())
+	assert.EqualValues(t, 4000, ts.rt.Get("iterated").ToInteger())
+	assert.True(t, ts.rt.Get("iterationDone").ToBoolean())
+
+	values := map[string]float64{}
+	counts := map[string]int{}
+	for _, c := range stats.GetBufferedSamples(ts.samples) {
+		for _, s := range c.GetSamples() {
+			values[s.Metric.Name] += s.Value
+			counts[s.Metric.Name]++
+		}
+	}
+	assert.Equal(t, float64(chunks), values[metrics.WSFramesReceivedName])
+	assert.Equal(t, int(chunks), counts[metrics.WSFrameReceivingName])
+	assert.Equal(t, float64(2), values[metrics.WSMessagesReceivedName])
+	assert.Equal(t, float64(4004), values[metrics.WSMessagesDataReceivedName])
+}
+
 func clearSamples(tb *httpmultibin.HTTPMultiBin, samples chan stats.SampleContainer) {
 	ctxDone := tb.Context.Done()
 	for {
@@ -1256,7 +1558,7 @@ func TestCookieJar(t *testing.T) {
 	}
 	err := ts.rt.Set("http", httpModule.New().NewModuleInstance(mii).Exports().Default)
 	require.NoError(t, err)
-	ts.state.CookieJar, _ = cookiejar.New(nil)
+	ts.state.CookieJar, _ = lib.NewCookieJar()
 
 	_, err = ts.rt.RunString(sr(`
 		var res = ws.connect("WSBIN_URL/ws-echo-someheader", function(socket){
@@ -1290,3 +1592,41 @@ func TestCookieJar(t *testing.T) {
 
 	assertSessionMetricsEmitted(t, stats.GetBufferedSamples(ts.samples), "", sr("WSBIN_URL/ws-echo-someheader"), statusProtocolSwitch, "")
 }
+
+func TestAbortSignal(t *testing.T) {
+	t.Parallel()
+	ts := newTestState(t)
+	sr := ts.tb.Replacer.Replace
+
+	events := experimental.New().NewModuleInstance(&modulestest.VU{
+		RuntimeField: ts.rt,
+		CtxField:     ts.tb.Context,
+		StateField:   ts.state,
+	}).Exports().Named
+	require.NoError(t, ts.rt.Set("AbortController", events["AbortController"]))
+
+	_, err := ts.rt.RunString(sr(`
+		var controller = new AbortController();
+		var closed = false;
+		var aborted = false;
+		controller.signal.addEventListener("abort", function() { aborted = true; });
+		var res = ws.connect("WSBIN_URL/ws-echo", { signal: controller.signal }, function(socket){
+			socket.on("close", function() { closed = true; });
+			socket.setTimeout(function() { controller.abort(); }, 100);
+		});
+		if (!aborted || !closed) {
+			throw new Error("the socket should've been closed by aborting the signal");
+		}
+		`))
+	require.NoError(t, err)
+
+	_, err = ts.rt.RunString(sr(`
+		var controller = new AbortController();
+		controller.abort();
+		ws.connect("WSBIN_URL/ws-echo", { signal: controller.signal }, function(socket){
+			throw new Error("the connection shouldn't have been established");
+		});
+		`))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "websocket connection aborted")
+}
@@ -26,6 +26,7 @@ import (
 	"reflect"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/dop251/goja"
 	"go.k6.io/k6/js/common"
@@ -127,6 +128,25 @@ type VU interface {
 	// implementations
 }
 
+// EventLoopStats is a snapshot of the state of the event loop of a VU.
+type EventLoopStats struct {
+	// PendingCallbacks is the number of callbacks that were registered, but haven't been queued yet.
+	PendingCallbacks int
+	// QueuedCallbacks is the number of callbacks that are queued to be run.
+	QueuedCallbacks int
+	// Lag is how long the callbacks being run, or the ones that were run last, had to wait in the queue.
+	Lag time.Duration
+}
+
+// EventLoopInspector is optionally implemented by the VUs that can report on the state of their event loop.
+//
+// Experimental
+//
+// Notice: This API is EXPERIMENTAL and may be changed or removed in a later release.
+type EventLoopInspector interface {
+	EventLoopStats() EventLoopStats
+}
+
 // Exports is representation of ESM exports of a module
 type Exports struct {
 	// Default is what will be the `default` export of a module
@@ -0,0 +1,84 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package modulestest
+
+import "errors"
+
+// LoopVU is a VU with just enough of an event loop for the tests of the modules with callbacks and promises.
+// The callbacks of RegisterCallback are queued and run by Run.
+type LoopVU struct {
+	*VU
+	registered int
+	queue      chan func() error
+}
+
+// NewLoopVU returns a LoopVU around vu.
+func NewLoopVU(vu *VU) *LoopVU {
+	return &LoopVU{VU: vu, queue: make(chan func() error, 10)}
+}
+
+// RegisterCallback queues the callback for Run, instead of the event loop of the VU.
+func (vu *LoopVU) RegisterCallback() func(func() error) {
+	vu.registered++
+	return func(f func() error) { vu.queue <- f }
+}
+
+// Registered returns the number of the registered callbacks that haven't been run yet.
+func (vu *LoopVU) Registered() int {
+	return vu.registered
+}
+
+// Run runs the queued callbacks until nothing is registered anymore.
+func (vu *LoopVU) Run() error {
+	for vu.registered > 0 {
+		vu.registered--
+		if err := (<-vu.queue)(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunPromise runs a script that returns a promise, as there is no async/await, and then the queued callbacks.
+// It returns the error the promise was rejected with as a string, which is empty if it was resolved.
+func (vu *LoopVU) RunPromise(script string) (string, error) {
+	rt := vu.Runtime()
+	_, err := rt.RunString(`
+		var result = {};
+		(function() {` + script + `})().then(
+			function() { result.done = true; },
+			function(e) { result.error = String(e); }
+		);`)
+	if err != nil {
+		return "", err
+	}
+	if err = vu.Run(); err != nil {
+		return "", err
+	}
+	result := rt.Get("result").ToObject(rt)
+	if e := result.Get("error"); e != nil {
+		return e.String(), nil
+	}
+	if done := result.Get("done"); done == nil || !done.ToBoolean() {
+		return "", errors.New("the promise wasn't settled")
+	}
+	return "", nil
+}
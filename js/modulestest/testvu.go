@@ -0,0 +1,63 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package modulestest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dop251/goja"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/lib/testutils/httpmultibin"
+	"go.k6.io/k6/stats"
+)
+
+// NewTestVU returns a VU with a new runtime, a context that is canceled at the end of the test and a state
+// that connects to the servers of tb, with the given system tags. The samples are sent to the returned channel.
+func NewTestVU(
+	t testing.TB, tb *httpmultibin.HTTPMultiBin, systemTags ...stats.SystemTagSet,
+) (*VU, chan stats.SampleContainer) {
+	t.Helper()
+	samples := make(chan stats.SampleContainer, 1000)
+
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	vu := &VU{
+		RuntimeField: rt,
+		CtxField:     ctx,
+		StateField: &lib.State{
+			Dialer:         tb.Dialer,
+			Transport:      tb.HTTPTransport,
+			TLSConfig:      tb.TLSClientConfig,
+			Samples:        samples,
+			Options:        lib.Options{SystemTags: stats.NewSystemTagSet(systemTags...)},
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+			Tags:           lib.NewTagMap(nil),
+		},
+	}
+	return vu, samples
+}
@@ -0,0 +1,231 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package js
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/stats"
+)
+
+// performance implements the parts of the Performance and User Timing web APIs that make sense in k6.
+// The times are measured with a monotonic clock and every measure is emitted as a performance_measure sample.
+//
+// Marks and measures are kept only for the duration of an iteration, otherwise they would keep on piling up.
+type performance struct {
+	vu     modules.VU
+	origin time.Time
+
+	// TimeOrigin is the unix time in milliseconds at which the VU was created, all the other times are relative to it
+	TimeOrigin float64 `js:"timeOrigin"`
+
+	ctx     context.Context
+	entries []*performanceEntry
+}
+
+// performanceEntry is both a PerformanceMark and a PerformanceMeasure
+type performanceEntry struct {
+	Name      string  `js:"name"`
+	EntryType string  `js:"entryType"`
+	StartTime float64 `js:"startTime"`
+	Duration  float64 `js:"duration"`
+}
+
+func newPerformance(vu modules.VU) *performance {
+	origin := time.Now()
+	return &performance{
+		vu:         vu,
+		origin:     origin,
+		TimeOrigin: float64(origin.UnixNano()) / float64(time.Millisecond),
+	}
+}
+
+func (p *performance) since(t time.Time) float64 {
+	return float64(t.Sub(p.origin)) / float64(time.Millisecond)
+}
+
+// timeline returns the entries of the current iteration.
+func (p *performance) timeline() []*performanceEntry {
+	if ctx := p.vu.Context(); ctx != p.ctx {
+		p.ctx = ctx
+		p.entries = nil
+	}
+	return p.entries
+}
+
+// Now returns the milliseconds since the time origin, with a sub-millisecond precision.
+func (p *performance) Now() float64 {
+	return p.since(time.Now())
+}
+
+// Mark records the current time, or the startTime from the options, under the provided name.
+func (p *performance) Mark(name string, options goja.Value) (*performanceEntry, error) {
+	if name == "" {
+		return nil, errors.New("mark() requires a name")
+	}
+	mark := &performanceEntry{Name: name, EntryType: "mark", StartTime: p.Now()}
+	if obj, ok := options.(*goja.Object); ok && isSet(obj.Get("startTime")) {
+		mark.StartTime = obj.Get("startTime").ToFloat()
+		if mark.StartTime < 0 {
+			return nil, fmt.Errorf("'%s' cannot have a negative start time", name)
+		}
+	}
+	p.entries = append(p.timeline(), mark)
+	return mark, nil
+}
+
+// Measure records the duration between two marks, or a mark and now, and emits it as a performance_measure sample.
+// The start and end can be given either as arguments or as the start, end and duration options.
+func (p *performance) Measure(name string, startOrOptions goja.Value, endMark goja.Value) (*performanceEntry, error) {
+	if name == "" {
+		return nil, errors.New("measure() requires a name")
+	}
+	now := p.Now()
+	start, end := goja.Value(nil), endMark
+	var duration goja.Value
+	if obj, ok := startOrOptions.(*goja.Object); ok {
+		start, end, duration = obj.Get("start"), obj.Get("end"), obj.Get("duration")
+		if isSet(duration) && isSet(start) == isSet(end) {
+			return nil, errors.New("measure() requires exactly one of start or end when there is a duration")
+		}
+	} else if isSet(startOrOptions) {
+		start = startOrOptions
+	}
+
+	tags := make(map[string]string, 3)
+	tags["measure"] = name
+	startTime, err := p.resolve(start, 0, "start_mark", tags)
+	if err != nil {
+		return nil, err
+	}
+	endTime, err := p.resolve(end, now, "end_mark", tags)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case isSet(duration) && isSet(start):
+		endTime = startTime + duration.ToFloat()
+	case isSet(duration):
+		startTime = endTime - duration.ToFloat()
+	}
+
+	measure := &performanceEntry{
+		Name:      name,
+		EntryType: "measure",
+		StartTime: startTime,
+		Duration:  endTime - startTime,
+	}
+	p.entries = append(p.timeline(), measure)
+	p.emit(measure, tags)
+	return measure, nil
+}
+
+// resolve returns the time of the mark with the given name, the given time if it's a number, or def if v is unset.
+func (p *performance) resolve(v goja.Value, def float64, tag string, tags map[string]string) (float64, error) {
+	if !isSet(v) {
+		return def, nil
+	}
+	if _, isString := v.Export().(string); !isString {
+		return v.ToFloat(), nil
+	}
+	name := v.String()
+	entries := p.timeline()
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].EntryType == "mark" && entries[i].Name == name {
+			tags[tag] = name
+			return entries[i].StartTime, nil
+		}
+	}
+	return 0, fmt.Errorf("the mark '%s' does not exist", name)
+}
+
+func (p *performance) emit(measure *performanceEntry, extraTags map[string]string) {
+	state := p.vu.State()
+	if state == nil {
+		return // measures in the init context aren't emitted
+	}
+	tags := state.CloneTags()
+	for k, v := range extraTags {
+		tags[k] = v
+	}
+	stats.PushIfNotDone(p.vu.Context(), state.Samples, stats.Sample{
+		Time:   time.Now(),
+		Metric: state.BuiltinMetrics.PerformanceMeasure,
+		Tags:   stats.IntoSampleTags(&tags),
+		Value:  measure.Duration,
+	})
+}
+
+// GetEntries returns all the marks and measures of the current iteration.
+func (p *performance) GetEntries() []*performanceEntry {
+	return p.filter("", "")
+}
+
+// GetEntriesByName returns the marks and measures with the given name and optionally the given type.
+func (p *performance) GetEntriesByName(name string, entryType string) []*performanceEntry {
+	return p.filter(name, entryType)
+}
+
+// GetEntriesByType returns the entries with the given type, either "mark" or "measure".
+func (p *performance) GetEntriesByType(entryType string) []*performanceEntry {
+	return p.filter("", entryType)
+}
+
+// ClearMarks removes the marks with the given name, or all marks if it's empty.
+func (p *performance) ClearMarks(name string) {
+	p.clear(name, "mark")
+}
+
+// ClearMeasures removes the measures with the given name, or all measures if it's empty.
+func (p *performance) ClearMeasures(name string) {
+	p.clear(name, "measure")
+}
+
+func (p *performance) filter(name, entryType string) []*performanceEntry {
+	result := make([]*performanceEntry, 0)
+	for _, e := range p.timeline() {
+		if (name == "" || e.Name == name) && (entryType == "" || e.EntryType == entryType) {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+func (p *performance) clear(name, entryType string) {
+	entries := p.timeline()
+	kept := entries[:0]
+	for _, e := range entries {
+		if e.EntryType != entryType || (name != "" && e.Name != name) {
+			kept = append(kept, e)
+		}
+	}
+	p.entries = kept
+}
+
+func isSet(v goja.Value) bool {
+	return v != nil && !goja.IsUndefined(v) && !goja.IsNull(v)
+}
@@ -0,0 +1,106 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package js
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/stats"
+)
+
+func TestPerformance(t *testing.T) {
+	t.Parallel()
+
+	r, err := getSimpleRunner(t, "/script.js", `
+		if (performance.timeOrigin <= 0 || performance.now() < 0) {
+			throw new Error("wrong time origin");
+		}
+		performance.mark("init");
+
+		exports.default = function() {
+			if (performance.getEntries().length !== 0) {
+				throw new Error("the marks from the init context should've been dropped");
+			}
+			var before = performance.now();
+			var start = performance.mark("start");
+			while (performance.now() - before < 5) {}
+			performance.mark("end");
+			if (start.entryType !== "mark" || start.startTime < before) {
+				throw new Error("wrong mark: " + JSON.stringify(start));
+			}
+
+			var m = performance.measure("loop", "start", "end");
+			if (m.entryType !== "measure" || m.duration < 5) {
+				throw new Error("wrong measure: " + JSON.stringify(m));
+			}
+			var fixed = performance.measure("fixed", { start: "start", duration: 3 });
+			if (fixed.duration !== 3 || fixed.startTime !== start.startTime) {
+				throw new Error("wrong measure: " + JSON.stringify(fixed));
+			}
+			if (performance.getEntriesByType("mark").length !== 2 || performance.getEntriesByName("loop").length !== 1) {
+				throw new Error("wrong entries: " + JSON.stringify(performance.getEntries()));
+			}
+			performance.clearMarks();
+			if (performance.getEntries().length !== 2) {
+				throw new Error("the marks weren't cleared: " + JSON.stringify(performance.getEntries()));
+			}
+			try {
+				performance.measure("missing", "start");
+				throw new Error("the measure shouldn't have been created");
+			} catch (e) {
+				if (e.message.indexOf("the mark 'start' does not exist") < 0) {
+					throw e;
+				}
+			}
+		}`)
+	require.NoError(t, err)
+
+	samples := make(chan stats.SampleContainer, 100)
+	initVU, err := r.NewVU(1, 1, samples)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	vu := initVU.Activate(&lib.VUActivationParams{RunContext: ctx})
+	require.NoError(t, vu.RunOnce())
+
+	measures := map[string]stats.Sample{}
+	for _, s := range stats.GetBufferedSamples(samples) {
+		for _, sample := range s.GetSamples() {
+			if sample.Metric.Name == metrics.PerformanceMeasureName {
+				measures[sample.Tags.CloneTags()["measure"]] = sample
+			}
+		}
+	}
+	require.Len(t, measures, 2)
+	loop := measures["loop"].Tags.CloneTags()
+	assert.Equal(t, "start", loop["start_mark"])
+	assert.Equal(t, "end", loop["end_mark"])
+	assert.GreaterOrEqual(t, measures["loop"].Value, 5.0)
+	assert.Equal(t, 3.0, measures["fixed"].Value)
+	assert.NotContains(t, measures["fixed"].Tags.CloneTags(), "end_mark")
+}
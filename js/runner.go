@@ -30,11 +30,11 @@ import (
 	"io"
 	"net"
 	"net/http"
-	"net/http/cookiejar"
 	"os"
 	"runtime/debug"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/dop251/goja"
@@ -51,6 +51,8 @@ import (
 	"go.k6.io/k6/lib/consts"
 	"go.k6.io/k6/lib/metrics"
 	"go.k6.io/k6/lib/netext"
+	"go.k6.io/k6/lib/netext/har"
+	"go.k6.io/k6/lib/netext/httpext"
 	"go.k6.io/k6/lib/types"
 	"go.k6.io/k6/loader"
 	"go.k6.io/k6/stats"
@@ -74,6 +76,15 @@ type Runner struct {
 
 	console   *console
 	setupData []byte
+
+	// scenarioSetupData has the setup data of the scenarios with their own setup
+	scenarioSetupData   map[string][]byte
+	scenarioSetupDataMx sync.RWMutex
+
+	// sharedCookieJar is used by all the VUs if the sharedCookieJar option is enabled
+	sharedCookieJar *lib.CookieJar
+	// harRecorder records the requests of all the VUs if the --har-out runtime option is set
+	harRecorder *har.Recorder
 }
 
 // New returns a new Runner for the provide source
@@ -111,6 +122,11 @@ func NewFromBundle(
 		return nil, err
 	}
 
+	sharedCookieJar, err := lib.NewCookieJar()
+	if err != nil {
+		return nil, err
+	}
+
 	defDNS := types.DefaultDNSConfig()
 	r := &Runner{
 		Bundle:       b,
@@ -127,6 +143,11 @@ func NewFromBundle(
 		ActualResolver: net.LookupIP,
 		builtinMetrics: builtinMetrics,
 		registry:       registry,
+
+		sharedCookieJar: sharedCookieJar,
+	}
+	if b.RuntimeOptions.HAROut.String != "" {
+		r.harRecorder = har.NewRecorder()
 	}
 
 	err = r.SetOptions(r.Bundle.Options)
@@ -134,6 +155,15 @@ func NewFromBundle(
 	return r, err
 }
 
+// HAR returns the requests recorded by all the VUs as an HTTP Archive, or nil if the --har-out runtime
+// option isn't set.
+func (r *Runner) HAR() *har.HAR {
+	if r.harRecorder == nil {
+		return nil
+	}
+	return r.harRecorder.HAR(&har.Creator{Name: "k6", Version: consts.Version})
+}
+
 func (r *Runner) MakeArchive() *lib.Archive {
 	return r.Bundle.makeArchive()
 }
@@ -148,6 +178,15 @@ func (r *Runner) NewVU(idLocal, idGlobal uint64, samplesOut chan<- stats.SampleC
 }
 
 // nolint:funlen
+// newCookieJar returns the cookie jar for a VU or its next iteration, which is always the same
+// if the VUs share a single jar, so that the cookies set by any of them are kept for the whole test.
+func (r *Runner) newCookieJar() (*lib.CookieJar, error) {
+	if r.Bundle.Options.SharedCookieJar.ValueOrZero() {
+		return r.sharedCookieJar, nil
+	}
+	return lib.NewCookieJar()
+}
+
 func (r *Runner) newVU(idLocal, idGlobal uint64, samplesOut chan<- stats.SampleContainer) (*VU, error) {
 	// Instantiate a new bundle, make a VU out of it.
 	moduleVUImpl := &moduleVUImpl{ctxPtr: new(context.Context)}
@@ -186,6 +225,7 @@ func (r *Runner) newVU(idLocal, idGlobal uint64, samplesOut chan<- stats.SampleC
 		Blacklist:        r.Bundle.Options.BlacklistIPs,
 		BlockedHostnames: r.Bundle.Options.BlockedHostnames.Trie,
 		Hosts:            r.Bundle.Options.Hosts,
+		UnixSockets:      r.Bundle.Options.UnixSockets,
 	}
 	if r.Bundle.Options.LocalIPs.Valid {
 		var ipIndex uint64
@@ -212,6 +252,10 @@ func (r *Runner) newVU(idLocal, idGlobal uint64, samplesOut chan<- stats.SampleC
 		DisableKeepAlives:   r.Bundle.Options.NoConnectionReuse.Bool,
 		MaxIdleConns:        int(r.Bundle.Options.Batch.Int64),
 		MaxIdleConnsPerHost: int(r.Bundle.Options.BatchPerHost.Int64),
+		MaxConnsPerHost:     int(r.Bundle.Options.MaxConnsPerHost.Int64),
+	}
+	if r.Bundle.Options.MaxIdleConns.Valid {
+		transport.MaxIdleConns = int(r.Bundle.Options.MaxIdleConns.Int64)
 	}
 
 	if forceHTTP1() {
@@ -219,8 +263,9 @@ func (r *Runner) newVU(idLocal, idGlobal uint64, samplesOut chan<- stats.SampleC
 	} else {
 		_ = http2.ConfigureTransport(transport) // send over h2 protocol
 	}
+	h2cTransport := httpext.NewH2CTransport(dialer)
 
-	cookieJar, err := cookiejar.New(nil)
+	cookieJar, err := r.newCookieJar()
 	if err != nil {
 		return nil, err
 	}
@@ -232,6 +277,7 @@ func (r *Runner) newVU(idLocal, idGlobal uint64, samplesOut chan<- stats.SampleC
 		BundleInstance: *bi,
 		Runner:         r,
 		Transport:      transport,
+		H2CTransport:   h2cTransport,
 		Dialer:         dialer,
 		CookieJar:      cookieJar,
 		TLSConfig:      tlsConfig,
@@ -246,9 +292,11 @@ func (r *Runner) newVU(idLocal, idGlobal uint64, samplesOut chan<- stats.SampleC
 		Logger:         vu.Runner.Logger,
 		Options:        vu.Runner.Bundle.Options,
 		Transport:      vu.Transport,
+		H2CTransport:   vu.H2CTransport,
 		Dialer:         vu.Dialer,
 		TLSConfig:      vu.TLSConfig,
 		CookieJar:      cookieJar,
+		HARRecorder:    r.harRecorder,
 		RPSLimit:       vu.Runner.RPSLimit,
 		BPool:          vu.BPool,
 		VUID:           vu.ID,
@@ -257,6 +305,7 @@ func (r *Runner) newVU(idLocal, idGlobal uint64, samplesOut chan<- stats.SampleC
 		Tags:           lib.NewTagMap(vu.Runner.Bundle.Options.RunTags.CloneTags()),
 		Group:          r.defaultGroup,
 		BuiltinMetrics: r.builtinMetrics,
+		OpenStreams:    new(lib.OpenStreams),
 	}
 	vu.moduleVUImpl.state = vu.state
 	_ = vu.Runtime.Set("console", vu.Console)
@@ -293,7 +342,7 @@ func (r *Runner) Setup(ctx context.Context, out chan<- stats.SampleContainer) er
 	setupCtx, setupCancel := context.WithTimeout(ctx, r.getTimeoutFor(consts.SetupFn))
 	defer setupCancel()
 
-	v, err := r.runPart(setupCtx, out, consts.SetupFn, nil)
+	v, err := r.runPart(setupCtx, out, consts.SetupFn, r.getTimeoutFor(consts.SetupFn), nil)
 	if err != nil {
 		return err
 	}
@@ -333,7 +382,86 @@ func (r *Runner) Teardown(ctx context.Context, out chan<- stats.SampleContainer)
 	} else {
 		data = goja.Undefined()
 	}
-	_, err := r.runPart(teardownCtx, out, consts.TeardownFn, data)
+	_, err := r.runPart(teardownCtx, out, consts.TeardownFn, r.getTimeoutFor(consts.TeardownFn), data)
+	return err
+}
+
+// ScenarioSetup runs the setup function of the scenario, if it has one, and
+// keeps the returned value as the setup data of the scenario's iterations.
+func (r *Runner) ScenarioSetup(ctx context.Context, out chan<- stats.SampleContainer, scenario string) error {
+	config, ok := r.Bundle.Options.Scenarios[scenario]
+	if !ok {
+		return fmt.Errorf("unknown scenario %s", scenario)
+	}
+	fn, timeout := config.GetSetup()
+	if fn == "" {
+		return nil
+	}
+	if timeout == 0 {
+		timeout = r.getTimeoutFor(consts.SetupFn)
+	}
+	setupCtx, setupCancel := context.WithTimeout(ctx, timeout)
+	defer setupCancel()
+
+	v, err := r.runPart(setupCtx, out, fn, timeout, nil)
+	if err != nil {
+		return err
+	}
+	var data []byte
+	if !goja.IsUndefined(v) {
+		if data, err = json.Marshal(v.Export()); err != nil {
+			return fmt.Errorf("error marshaling %s() data of scenario %s to JSON: %w", fn, scenario, err)
+		}
+	}
+
+	r.scenarioSetupDataMx.Lock()
+	defer r.scenarioSetupDataMx.Unlock()
+	if r.scenarioSetupData == nil {
+		r.scenarioSetupData = make(map[string][]byte)
+	}
+	r.scenarioSetupData[scenario] = data
+	return nil
+}
+
+// getScenarioSetupData returns the setup data of the scenario as json, and
+// whether the scenario had its own setup, whose data replaces the global one.
+func (r *Runner) getScenarioSetupData(scenario string) ([]byte, bool) {
+	r.scenarioSetupDataMx.RLock()
+	defer r.scenarioSetupDataMx.RUnlock()
+	data, ok := r.scenarioSetupData[scenario]
+	return data, ok
+}
+
+// ScenarioTeardown runs the teardown function of the scenario, if it has one,
+// with the setup data of the scenario, or the global one if it had no setup.
+func (r *Runner) ScenarioTeardown(ctx context.Context, out chan<- stats.SampleContainer, scenario string) error {
+	config, ok := r.Bundle.Options.Scenarios[scenario]
+	if !ok {
+		return fmt.Errorf("unknown scenario %s", scenario)
+	}
+	fn, timeout := config.GetTeardown()
+	if fn == "" {
+		return nil
+	}
+	if timeout == 0 {
+		timeout = r.getTimeoutFor(consts.TeardownFn)
+	}
+	teardownCtx, teardownCancel := context.WithTimeout(ctx, timeout)
+	defer teardownCancel()
+
+	setupData, ok := r.getScenarioSetupData(scenario)
+	if !ok {
+		setupData = r.setupData
+	}
+	var data interface{}
+	if setupData != nil {
+		if err := json.Unmarshal(setupData, &data); err != nil {
+			return fmt.Errorf("error unmarshaling setup data for %s() from JSON: %w", fn, err)
+		}
+	} else {
+		data = goja.Undefined()
+	}
+	_, err := r.runPart(teardownCtx, out, fn, timeout, data)
 	return err
 }
 
@@ -500,8 +628,10 @@ func parseTTL(ttlS string) (time.Duration, error) {
 }
 
 // Runs an exported function in its own temporary VU, optionally with an argument. Execution is
-// interrupted if the context expires. No error is returned if the part does not exist.
-func (r *Runner) runPart(ctx context.Context, out chan<- stats.SampleContainer, name string, arg interface{}) (goja.Value, error) {
+// interrupted if the context expires, after the timeout. No error is returned if the part does not exist.
+func (r *Runner) runPart(
+	ctx context.Context, out chan<- stats.SampleContainer, name string, timeout time.Duration, arg interface{},
+) (goja.Value, error) {
 	vu, err := r.newVU(0, 0, out)
 	if err != nil {
 		return goja.Undefined(), err
@@ -545,7 +675,7 @@ func (r *Runner) runPart(ctx context.Context, out chan<- stats.SampleContainer,
 			return v, err
 		}
 		// otherwise we have timeouted
-		return v, newTimeoutError(name, r.getTimeoutFor(name))
+		return v, newTimeoutError(name, timeout)
 	}
 	return v, err
 }
@@ -569,12 +699,14 @@ type VU struct {
 
 	Runner    *Runner
 	Transport *http.Transport
-	Dialer    *netext.Dialer
-	CookieJar *cookiejar.Jar
-	TLSConfig *tls.Config
-	ID        uint64 // local to the current instance
-	IDGlobal  uint64 // global across all instances
-	iteration int64
+	// H2CTransport is used for the requests sent over cleartext HTTP/2 with prior knowledge
+	H2CTransport *httpext.H2CTransport
+	Dialer       *netext.Dialer
+	CookieJar    *lib.CookieJar
+	TLSConfig    *tls.Config
+	ID           uint64 // local to the current instance
+	IDGlobal     uint64 // global across all instances
+	iteration    int64
 
 	Console *console
 	BPool   *bpool.BufferPool
@@ -605,6 +737,11 @@ type ActiveVU struct {
 	scenarioName              string
 	getNextIterationCounters  func() (uint64, uint64)
 	scIterLocal, scIterGlobal uint64
+
+	// the setup data of the scenario, if it had its own setup
+	hasScenarioSetup  bool
+	scenarioSetupJSON []byte
+	scenarioSetupData goja.Value
 }
 
 // GetID returns the unique VU ID.
@@ -668,16 +805,27 @@ func (u *VU) Activate(params *lib.VUActivationParams) lib.ActiveVU {
 		getNextIterationCounters: params.GetNextIterationCounters,
 	}
 
+	avu.scenarioSetupJSON, avu.hasScenarioSetup = u.Runner.getScenarioSetupData(params.Scenario)
+
 	u.state.GetScenarioLocalVUIter = func() uint64 {
 		return avu.scIterLocal
 	}
 	u.state.GetScenarioGlobalVUIter = func() uint64 {
 		return avu.scIterGlobal
 	}
+	critical := newCriticalSection()
+	u.state.RunCritical = func(fn func() error) error {
+		return critical.run(u.moduleVUImpl.ctxPtr, fn)
+	}
 
 	go func() {
 		// Wait for the run context to be over
 		<-ctx.Done()
+		// and for any running critical section of the iteration
+		if !critical.stop(params.CriticalStop) {
+			u.state.Logger.Warnf("Interrupting a critical section of VU %d, since it didn't end within the "+
+				"criticalStop of %s", u.ID, params.CriticalStop)
+		}
 		// Interrupt the JS runtime
 		u.Runtime.Interrupt(context.Canceled)
 		// Wait for the VU to stop running, if it was, and prevent it from
@@ -717,14 +865,42 @@ func (u *ActiveVU) RunOnce() error {
 			u.setupData = goja.Undefined()
 		}
 	}
+	setupData := u.setupData
+	if u.hasScenarioSetup {
+		if u.scenarioSetupData == nil {
+			u.scenarioSetupData = goja.Undefined()
+			if u.scenarioSetupJSON != nil {
+				var data interface{}
+				if err := json.Unmarshal(u.scenarioSetupJSON, &data); err != nil {
+					return fmt.Errorf("error unmarshaling scenario setup data for the iteration from JSON: %w", err)
+				}
+				u.scenarioSetupData = u.Runtime.ToValue(data)
+			}
+		}
+		setupData = u.scenarioSetupData
+	}
 
-	fn, ok := u.exports[u.Exec]
+	u.incrIteration()
+
+	exec := u.Exec
+	if u.ExecPicker != nil {
+		exec = u.ExecPicker.Pick(u.scIterGlobal)
+	}
+	fn, ok := u.exports[exec]
 	if !ok {
 		// Shouldn't happen; this is validated in cmd.validateScenarioConfig()
-		panic(fmt.Sprintf("function '%s' not found in exports", u.Exec))
+		panic(fmt.Sprintf("function '%s' not found in exports", exec))
+	}
+
+	if u.WarmupDuration > 0 {
+		ss := lib.GetScenarioState(u.RunContext)
+		if ss != nil && time.Since(ss.StartTime) < u.WarmupDuration {
+			u.state.Tags.Set(lib.WarmupTagName, "true")
+		} else {
+			u.state.Tags.Delete(lib.WarmupTagName)
+		}
 	}
 
-	u.incrIteration()
 	if err := u.Runtime.Set("__ITER", u.iteration); err != nil {
 		panic(fmt.Errorf("error setting __ITER in goja runtime: %w", err))
 	}
@@ -733,7 +909,7 @@ func (u *ActiveVU) RunOnce() error {
 	defer cancel()
 	*u.moduleVUImpl.ctxPtr = ctx
 	// Call the exported function.
-	_, isFullIteration, totalTime, err := u.runFn(ctx, true, fn, cancel, u.setupData)
+	_, isFullIteration, totalTime, err := u.runFn(ctx, true, fn, cancel, setupData)
 	if err != nil {
 		var x *goja.InterruptedError
 		if errors.As(err, &x) {
@@ -765,7 +941,7 @@ func (u *VU) runFn(
 	ctx context.Context, isDefault bool, fn goja.Callable, cancel func(), args ...goja.Value,
 ) (v goja.Value, isFullIteration bool, t time.Duration, err error) {
 	if !u.Runner.Bundle.Options.NoCookiesReset.ValueOrZero() {
-		u.state.CookieJar, err = cookiejar.New(nil)
+		u.state.CookieJar, err = u.Runner.newCookieJar()
 		if err != nil {
 			return goja.Undefined(), false, time.Duration(0), err
 		}
@@ -777,6 +953,7 @@ func (u *VU) runFn(
 	}
 
 	startTime := time.Now()
+	u.state.IterationStart = startTime
 
 	if u.moduleVUImpl.eventLoop == nil {
 		u.moduleVUImpl.eventLoop = newEventLoop(u.moduleVUImpl)
@@ -801,6 +978,9 @@ func (u *VU) runFn(
 		v, err = fn(goja.Undefined(), args...) // Actually run the JS script
 		return err
 	})
+	// the streams that weren't read until the end or closed are closed before the context is
+	// cancelled, so that their connections are released and their requests are measured
+	u.state.OpenStreams.CloseAll()
 
 	select {
 	case <-ctx.Done():
@@ -812,6 +992,9 @@ func (u *VU) runFn(
 	if cancel != nil {
 		cancel()
 		u.moduleVUImpl.eventLoop.waitOnRegistered()
+		if u.moduleVUImpl.events != nil {
+			u.moduleVUImpl.events.ReportLeakedTimers()
+		}
 	}
 	endTime := time.Now()
 	var exception *goja.Exception
@@ -821,6 +1004,7 @@ func (u *VU) runFn(
 
 	if u.Runner.Bundle.Options.NoVUConnectionReuse.Bool {
 		u.Transport.CloseIdleConnections()
+		u.H2CTransport.CloseIdleConnections()
 	}
 
 	sampleTags := stats.NewSampleTags(u.state.CloneTags())
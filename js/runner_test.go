@@ -345,6 +345,303 @@ func TestSetupDataIsolation(t *testing.T) {
 	require.Equal(t, 501, count, "mycounter should be the number of iterations + 1 for the teardown")
 }
 
+func TestScenarioSetupData(t *testing.T) {
+	t.Parallel()
+
+	script := `
+		var Counter = require("k6/metrics").Counter;
+
+		exports.options = {
+			scenarios: {
+				seeded: {
+					executor: "shared-iterations", vus: 2, iterations: 10, exec: "seeded",
+					setup: "seed", teardown: "unseed", setupTimeout: "5s",
+				},
+				plain: { executor: "shared-iterations", vus: 2, iterations: 10, exec: "plain", teardown: "unseed" },
+			},
+			setupTimeout: "5s",
+			teardownTimeout: "5s",
+		};
+		var myCounter = new Counter("mycounter");
+
+		exports.setup = function() {
+			return { from: "setup" };
+		}
+		exports.seed = function() {
+			return { from: "seed" };
+		}
+		exports.seeded = function(data) {
+			if (data.from !== "seed") {
+				throw new Error("seeded: wrong data: " + JSON.stringify(data));
+			}
+			myCounter.add(1, { from: data.from });
+		}
+		exports.plain = function(data) {
+			if (data.from !== "setup") {
+				throw new Error("plain: wrong data: " + JSON.stringify(data));
+			}
+			myCounter.add(1, { from: data.from });
+		}
+		exports.unseed = function(data) {
+			myCounter.add(100, { from: data.from });
+		}
+	`
+
+	runner, err := getSimpleRunner(t, "/script.js", script)
+	require.NoError(t, err)
+
+	options := runner.GetOptions()
+	require.Empty(t, options.Validate())
+
+	execScheduler, err := local.NewExecutionScheduler(runner, testutils.NewLogger(t))
+	require.NoError(t, err)
+
+	mockOutput := mockoutput.New()
+	registry := metrics.NewRegistry()
+	builtinMetrics := metrics.RegisterBuiltinMetrics(registry)
+	engine, err := core.NewEngine(
+		execScheduler, options, lib.RuntimeOptions{}, []output.Output{mockOutput}, testutils.NewLogger(t), builtinMetrics,
+	)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	run, wait, err := engine.Init(ctx, ctx)
+	require.NoError(t, err)
+	require.NoError(t, run())
+	cancel()
+	wait()
+	require.False(t, engine.IsTainted())
+
+	require.Contains(t, runner.defaultGroup.Groups, "seed")
+	require.Contains(t, runner.defaultGroup.Groups, "unseed")
+	counts := map[string]int{}
+	for _, s := range mockOutput.Samples {
+		if s.Metric.Name == "mycounter" {
+			from, _ := s.Tags.Get("from")
+			counts[from] += int(s.Value)
+		}
+	}
+	assert.Equal(t, map[string]int{"seed": 110, "setup": 110}, counts)
+}
+
+func TestScenarioSetupTimeout(t *testing.T) {
+	t.Parallel()
+
+	runner, err := getSimpleRunner(t, "/script.js", `
+		exports.options = {
+			scenarios: {
+				slow: { executor: "per-vu-iterations", setup: "seed", setupTimeout: "1s" },
+			},
+		};
+		exports.seed = function() { while(true) {} };
+		exports.default = function() {};
+	`)
+	require.NoError(t, err)
+
+	err = runner.ScenarioSetup(context.Background(), make(chan stats.SampleContainer, 100), "slow")
+	var terr timeoutError
+	require.ErrorAs(t, err, &terr)
+	assert.Equal(t, "seed() execution timed out after 1 seconds", err.Error())
+
+	_, ok := runner.getScenarioSetupData("slow")
+	assert.False(t, ok)
+}
+
+func TestWeightedExecFunctions(t *testing.T) {
+	t.Parallel()
+
+	runner, err := getSimpleRunner(t, "/script.js", `
+		var Counter = require("k6/metrics").Counter;
+
+		exports.options = {
+			scenarios: {
+				mix: { executor: "shared-iterations", vus: 3, iterations: 100, exec: { browse: 70, checkout: 30 } },
+			},
+		};
+		var calls = new Counter("calls");
+
+		exports.browse = function() { calls.add(1, { fn: "browse" }); };
+		exports.checkout = function() { calls.add(1, { fn: "checkout" }); };
+	`)
+	require.NoError(t, err)
+
+	options := runner.GetOptions()
+	require.Empty(t, options.Validate())
+
+	execScheduler, err := local.NewExecutionScheduler(runner, testutils.NewLogger(t))
+	require.NoError(t, err)
+
+	mockOutput := mockoutput.New()
+	registry := metrics.NewRegistry()
+	builtinMetrics := metrics.RegisterBuiltinMetrics(registry)
+	engine, err := core.NewEngine(
+		execScheduler, options, lib.RuntimeOptions{}, []output.Output{mockOutput}, testutils.NewLogger(t), builtinMetrics,
+	)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	run, wait, err := engine.Init(ctx, ctx)
+	require.NoError(t, err)
+	require.NoError(t, run())
+	cancel()
+	wait()
+	require.False(t, engine.IsTainted())
+
+	counts := map[string]int{}
+	for _, s := range mockOutput.Samples {
+		if s.Metric.Name == "calls" {
+			fn, _ := s.Tags.Get("fn")
+			counts[fn] += int(s.Value)
+		}
+	}
+	assert.Equal(t, map[string]int{"browse": 70, "checkout": 30}, counts)
+}
+
+func TestScenarioWarmup(t *testing.T) {
+	t.Parallel()
+
+	runner, err := getSimpleRunner(t, "/script.js", `
+		var Counter = require("k6/metrics").Counter;
+		var k6 = require("k6");
+
+		exports.options = {
+			scenarios: {
+				warm: { executor: "constant-vus", vus: 1, duration: "1s", gracefulStop: "0s", warmupDuration: "500ms" },
+			},
+		};
+		var calls = new Counter("calls");
+
+		exports.default = function() {
+			calls.add(1);
+			k6.check(null, { "ok": function() { return true; } });
+			k6.sleep(0.1);
+		};
+	`)
+	require.NoError(t, err)
+
+	options := runner.GetOptions()
+	require.Empty(t, options.Validate())
+
+	execScheduler, err := local.NewExecutionScheduler(runner, testutils.NewLogger(t))
+	require.NoError(t, err)
+
+	mockOutput := mockoutput.New()
+	registry := metrics.NewRegistry()
+	builtinMetrics := metrics.RegisterBuiltinMetrics(registry)
+	engine, err := core.NewEngine(
+		execScheduler, options, lib.RuntimeOptions{}, []output.Output{mockOutput}, testutils.NewLogger(t), builtinMetrics,
+	)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	run, wait, err := engine.Init(ctx, ctx)
+	require.NoError(t, err)
+	require.NoError(t, run())
+	cancel()
+	wait()
+
+	var warmupCalls, calls float64
+	for _, s := range mockOutput.Samples {
+		if s.Metric.Name != "calls" {
+			continue
+		}
+		if v, ok := s.Tags.Get(lib.WarmupTagName); ok {
+			assert.Equal(t, "true", v)
+			warmupCalls += s.Value
+		} else {
+			calls += s.Value
+		}
+	}
+	require.Greater(t, warmupCalls, 0.0)
+	require.Greater(t, calls, 0.0)
+
+	assert.Equal(t, calls, engine.Metrics["calls"].Sink.(*stats.CounterSink).Value)
+	assert.Equal(t, int64(calls), runner.defaultGroup.Checks["ok"].Passes)
+}
+
+func TestCriticalSectionAtStop(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		criticalStop, critical string
+		written                bool
+	}{
+		"ends":        {criticalStop: "5s", critical: "k6.sleep(1); written.add(1);", written: true},
+		"interrupted": {criticalStop: "200ms", critical: "while (true) {}", written: false},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			logger, hook := logtest.NewNullLogger()
+			runner, err := getSimpleRunner(t, "/script.js", fmt.Sprintf(`
+				var Counter = require("k6/metrics").Counter;
+				var k6 = require("k6");
+
+				exports.options = {
+					scenarios: {
+						writes: {
+							executor: "per-vu-iterations", maxDuration: "1s", gracefulStop: "0s",
+							criticalStop: "%s",
+						},
+					},
+				};
+				var written = new Counter("written");
+
+				exports.default = function() {
+					k6.sleep(0.5);
+					k6.critical(function() { %s });
+				};
+			`, tc.criticalStop, tc.critical), logger)
+			require.NoError(t, err)
+
+			options := runner.GetOptions()
+			require.Empty(t, options.Validate())
+
+			execScheduler, err := local.NewExecutionScheduler(runner, logger)
+			require.NoError(t, err)
+
+			mockOutput := mockoutput.New()
+			registry := metrics.NewRegistry()
+			builtinMetrics := metrics.RegisterBuiltinMetrics(registry)
+			engine, err := core.NewEngine(
+				execScheduler, options, lib.RuntimeOptions{}, []output.Output{mockOutput}, logger, builtinMetrics,
+			)
+			require.NoError(t, err)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			run, wait, err := engine.Init(ctx, ctx)
+			require.NoError(t, err)
+			start := time.Now()
+			require.NoError(t, run())
+			cancel()
+			wait()
+			assert.GreaterOrEqual(t, int64(time.Since(start)), int64(time.Second))
+
+			var written float64
+			for _, s := range mockOutput.Samples {
+				if s.Metric.Name == "written" {
+					written += s.Value
+				}
+			}
+			var warned bool
+			for _, e := range hook.AllEntries() {
+				if e.Level == logrus.WarnLevel && strings.Contains(e.Message, "Interrupting a critical section") {
+					warned = true
+				}
+			}
+			if tc.written {
+				assert.Equal(t, 1.0, written)
+				assert.False(t, warned)
+			} else {
+				assert.Equal(t, 0.0, written)
+				assert.True(t, warned)
+			}
+		})
+	}
+}
+
 func testSetupDataHelper(t *testing.T, data string) {
 	t.Helper()
 	expScriptOptions := lib.Options{
@@ -1344,6 +1641,53 @@ func TestVUIntegrationCookiesNoReset(t *testing.T) {
 	}
 }
 
+func TestVUIntegrationCookiesSharedJar(t *testing.T) {
+	t.Parallel()
+	tb := httpmultibin.NewHTTPMultiBin(t)
+
+	r, err := getSimpleRunner(t, "/script.js", tb.Replacer.Replace(`
+			var http = require("k6/http");
+			exports.setup = function() {
+				var res = http.get("HTTPBIN_URL/cookies/set?k1=v1");
+				if (res.json().k1 != "v1") { throw new Error("wrong cookies: " + res.body); }
+				return http.cookieJar().exportCookies();
+			}
+			exports.default = function(cookies) {
+				var res = http.get("HTTPBIN_URL/cookies");
+				if (res.json().k1 != "v1") { throw new Error("the cookie from setup() wasn't shared: " + res.body); }
+
+				var jar = new http.CookieJar();
+				jar.importCookies(cookies);
+				res = http.get("HTTPBIN_URL/cookies", { jar: jar });
+				if (res.json().k1 != "v1") { throw new Error("the cookie wasn't imported: " + res.body); }
+				var exported = jar.exportCookies();
+				if (exported.length !== 1 || exported[0].url !== cookies[0].url || exported[0].value !== "v1") {
+					throw new Error("wrong exported cookies: " + JSON.stringify(exported));
+				}
+			}
+		`))
+	require.NoError(t, err)
+	require.NoError(t, r.SetOptions(lib.Options{
+		Throw:           null.BoolFrom(true),
+		MaxRedirects:    null.IntFrom(10),
+		Hosts:           tb.Dialer.Hosts,
+		SharedCookieJar: null.BoolFrom(true),
+		SetupTimeout:    types.NullDurationFrom(10 * time.Second),
+	}))
+
+	ch := make(chan stats.SampleContainer, 100)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, r.Setup(ctx, ch))
+	for id := uint64(1); id <= 2; id++ {
+		initVU, err := r.NewVU(id, id, ch)
+		require.NoError(t, err)
+		vu := initVU.Activate(&lib.VUActivationParams{RunContext: ctx})
+		require.NoError(t, vu.RunOnce())
+		require.NoError(t, vu.RunOnce())
+	}
+}
+
 func TestVUIntegrationVUID(t *testing.T) {
 	t.Parallel()
 	r1, err := getSimpleRunner(t, "/script.js", `
@@ -2355,3 +2699,91 @@ func TestExecutionInfo(t *testing.T) {
 		})
 	}
 }
+
+func TestQueueMicrotaskGlobal(t *testing.T) {
+	t.Parallel()
+
+	r, err := getSimpleRunner(t, "/script.js", `
+		var order = [];
+		queueMicrotask(function() { order.push("init microtask"); });
+		order.push("init");
+
+		exports.default = function() {
+			if (order.join(",") !== "init,init microtask") {
+				throw new Error("unexpected init order: " + order.join(","));
+			}
+			var iterOrder = [];
+			queueMicrotask(function() {
+				iterOrder.push("microtask");
+				if (iterOrder.join(",") !== "sync,microtask") {
+					throw new Error("unexpected iteration order: " + iterOrder.join(","));
+				}
+			});
+			iterOrder.push("sync");
+		}`)
+	require.NoError(t, err)
+
+	initVU, err := r.NewVU(1, 1, make(chan stats.SampleContainer, 100))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	vu := initVU.Activate(&lib.VUActivationParams{RunContext: ctx})
+	require.NoError(t, vu.RunOnce())
+}
+
+func TestFetchGlobals(t *testing.T) {
+	t.Parallel()
+	_, err := getSimpleRunner(t, "/script.js", `
+		if (typeof fetch !== "function" || typeof Headers !== "function" ||
+			typeof Request !== "function" || typeof Response !== "function") {
+			throw new Error("fetch and its classes should be globals");
+		}
+		exports.default = function() {}`)
+	require.NoError(t, err)
+}
+
+func TestVUAbandonedStreams(t *testing.T) {
+	t.Parallel()
+	tb := httpmultibin.NewHTTPMultiBin(t)
+	requestDone := make(chan struct{})
+	tb.Mux.HandleFunc("/endless", func(w http.ResponseWriter, req *http.Request) {
+		defer close(requestDone)
+		_, _ = w.Write([]byte("first chunk"))
+		w.(http.Flusher).Flush()
+		<-req.Context().Done()
+	})
+
+	r, err := getSimpleRunner(t, "/script.js", tb.Replacer.Replace(`
+			var http = require("k6/http");
+			exports.default = function() {
+				var res = http.get("HTTPBIN_URL/endless", { responseType: "stream" });
+				if (res.body.read() === null) { throw new Error("the first chunk wasn't read"); }
+			}
+		`))
+	require.NoError(t, err)
+	r.SetOptions(lib.Options{Hosts: tb.Dialer.Hosts})
+
+	samples := make(chan stats.SampleContainer, 100)
+	initVU, err := r.NewVU(1, 1, samples)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	vu := initVU.Activate(&lib.VUActivationParams{RunContext: ctx})
+	require.NoError(t, vu.RunOnce())
+
+	select {
+	case <-requestDone:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "the request of the abandoned stream wasn't closed at the end of the iteration")
+	}
+	var reqs int
+	for _, c := range stats.GetBufferedSamples(samples) {
+		for _, s := range c.GetSamples() {
+			if s.Metric.Name == metrics.HTTPReqsName {
+				reqs++
+			}
+		}
+	}
+	assert.Equal(t, 1, reqs, "the request of the abandoned stream should be measured")
+}
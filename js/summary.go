@@ -67,7 +67,8 @@ func metricValueGetter(summaryTrendStats []string) func(stats.Sink, time.Duratio
 			result = sink.Format(t)
 			result["passes"] = float64(sink.Trues)
 			result["fails"] = float64(sink.Total - sink.Trues)
-		case *stats.TrendSink:
+		case stats.TrendStatsSink:
+			// the Trends and the Histograms
 			result = make(map[string]float64, len(summaryTrendStats))
 			for _, col := range summaryTrendStats {
 				result[col] = trendResolvers[col](sink)
@@ -83,12 +84,13 @@ func metricValueGetter(summaryTrendStats []string) func(stats.Sink, time.Duratio
 func summarizeMetricsToObject(data *lib.Summary, options lib.Options, setupData []byte) map[string]interface{} {
 	m := make(map[string]interface{})
 	m["root_group"] = exportGroup(data.RootGroup)
-	m["options"] = map[string]interface{}{
+	summaryOptions := map[string]interface{}{
 		// TODO: improve when we can easily export all option values, including defaults?
 		"summaryTrendStats": options.SummaryTrendStats,
 		"summaryTimeUnit":   options.SummaryTimeUnit.String,
 		"noColor":           data.NoColor, // TODO: move to the (runtime) options
 	}
+	m["options"] = summaryOptions
 	m["state"] = map[string]interface{}{
 		"isStdOutTTY":       data.UIState.IsStdOutTTY,
 		"isStdErrTTY":       data.UIState.IsStdErrTTY,
@@ -96,27 +98,64 @@ func summarizeMetricsToObject(data *lib.Summary, options lib.Options, setupData
 	}
 
 	getMetricValues := metricValueGetter(options.SummaryTrendStats)
+	// the trend metrics with their own stats have them in the options, resolved for the submetrics
+	trendStatsPerMetric := make(map[string][]string)
 
 	metricsData := make(map[string]interface{})
 	for name, m := range data.Metrics {
+		getValues := getMetricValues
+		if _, ok := m.Sink.(stats.TrendStatsSink); ok && options.SummaryTrendStatsPerMetric != nil {
+			trendStatsPerMetric[name] = options.GetSummaryTrendStats(name)
+			getValues = metricValueGetter(trendStatsPerMetric[name])
+		}
 		metricData := map[string]interface{}{
 			"type":     m.Type.String(),
 			"contains": m.Contains.String(),
-			"values":   getMetricValues(m.Sink, data.TestRunDuration),
+			"values":   getValues(m.Sink, data.TestRunDuration),
+		}
+		if m.Unit != "" {
+			metricData["unit"] = m.Unit
+		}
+		if m.Description != "" {
+			metricData["description"] = m.Description
+		}
+		if m.Type == stats.Histogram {
+			metricData["buckets"] = m.Buckets
+		}
+		if m.ID != "" {
+			metricData["id"] = m.ID
 		}
 
 		if len(m.Thresholds.Thresholds) > 0 {
 			thresholds := make(map[string]interface{})
 			for _, threshold := range m.Thresholds.Thresholds {
-				thresholds[threshold.Source] = map[string]interface{}{
+				thresholdData := map[string]interface{}{
 					"ok": !threshold.LastFailed,
 				}
+				if threshold.Severity != "" {
+					thresholdData["severity"] = string(threshold.Severity)
+				}
+				thresholds[threshold.Source] = thresholdData
 			}
 			metricData["thresholds"] = thresholds
 		}
 		metricsData[name] = metricData
 	}
 	m["metrics"] = metricsData
+	if len(data.TagCardinalityOffenders) > 0 {
+		offenders := make([]map[string]interface{}, len(data.TagCardinalityOffenders))
+		for i, offender := range data.TagCardinalityOffenders {
+			offenders[i] = map[string]interface{}{
+				"metric":           offender.Metric,
+				"tag":              offender.Tag,
+				"overLimitSamples": offender.OverLimitSamples,
+			}
+		}
+		m["tag_cardinality_offenders"] = offenders
+	}
+	if len(trendStatsPerMetric) > 0 {
+		summaryOptions["summaryTrendStatsPerMetric"] = trendStatsPerMetric
+	}
 
 	var setupDataI interface{}
 	if setupData != nil {
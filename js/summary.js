@@ -13,6 +13,7 @@ var palette = {
   faint: 2,
   red: 31,
   green: 32,
+  yellow: 33,
   cyan: 36,
   //TODO: add others?
 }
@@ -21,11 +22,13 @@ var groupPrefix = '█'
 var detailsPrefix = '↳'
 var succMark = '✓'
 var failMark = '✗'
+var warnMark = '!'
 var defaultOptions = {
   indent: ' ',
   enableColors: true,
   summaryTimeUnit: null,
   summaryTrendStats: null,
+  summaryTrendStatsPerMetric: null,
 }
 
 // strWidth tries to return the actual width the string will take up on the
@@ -212,8 +215,17 @@ function humanizeValue(val, metric, timeUnit) {
       return humanizeBytes(val)
     case 'time':
       return humanizeDuration(val, timeUnit)
-    default:
+  }
+
+  // the values of the other metrics can have a unit, in which case they are durations in seconds or custom ones
+  switch (metric.unit) {
+    case undefined:
+    case '':
       return toFixedNoTrailingZeros(val, 6)
+    case 's':
+      return humanizeDuration(val * 1000, timeUnit)
+    default:
+      return toFixedNoTrailingZeros(val, 6) + ' ' + metric.unit
   }
 }
 
@@ -241,6 +253,16 @@ function nonTrendMetricValueForSum(metric, timeUnit) {
   }
 }
 
+// trendStatsForMetric returns the summary trend stats of the metric, which has
+// its own ones in summaryTrendStatsPerMetric if they were set in the options.
+function trendStatsForMetric(name, options) {
+  var perMetric = options.summaryTrendStatsPerMetric
+  if (perMetric && perMetric.hasOwnProperty(name)) {
+    return perMetric[name]
+  }
+  return options.summaryTrendStats
+}
+
 function summarizeMetrics(options, data, decorate) {
   var indent = options.indent + '  '
   var result = []
@@ -254,7 +276,14 @@ function summarizeMetrics(options, data, decorate) {
   var nonTrendExtraMaxLens = [0, 0]
 
   var trendCols = {}
+  var trendStats = {}
   var numTrendColumns = options.summaryTrendStats.length
+  forEach(data.metrics, function (name, metric) {
+    if (metric.type == 'trend' || metric.type == 'histogram') {
+      trendStats[name] = trendStatsForMetric(name, options)
+      numTrendColumns = Math.max(numTrendColumns, trendStats[name].length)
+    }
+  })
   var trendColMaxLens = new Array(numTrendColumns).fill(0)
   forEach(data.metrics, function (name, metric) {
     names.push(name)
@@ -265,10 +294,10 @@ function summarizeMetrics(options, data, decorate) {
       nameLenMax = displayNameWidth
     }
 
-    if (metric.type == 'trend') {
+    if (metric.type == 'trend' || metric.type == 'histogram') {
       var cols = []
-      for (var i = 0; i < numTrendColumns; i++) {
-        var tc = options.summaryTrendStats[i]
+      for (var i = 0; i < trendStats[name].length; i++) {
+        var tc = trendStats[name][i]
         var value = metric.values[tc]
         if (tc === 'count') {
           value = value.toString()
@@ -315,10 +344,10 @@ function summarizeMetrics(options, data, decorate) {
   var getData = function (name) {
     if (trendCols.hasOwnProperty(name)) {
       var cols = trendCols[name]
-      var tmpCols = new Array(numTrendColumns)
+      var tmpCols = new Array(cols.length)
       for (var i = 0; i < cols.length; i++) {
         tmpCols[i] =
-          options.summaryTrendStats[i] +
+          trendStats[name][i] +
           '=' +
           decorate(cols[i], palette.cyan) +
           ' '.repeat(trendColMaxLens[i] - strWidth(cols[i]))
@@ -358,7 +387,13 @@ function summarizeMetrics(options, data, decorate) {
         return decorate(text, palette.green)
       }
       forEach(metric.thresholds, function (name, threshold) {
-        if (!threshold.ok) {
+        // the failed thresholds with the warn severity don't fail the test run
+        if (!threshold.ok && threshold.severity === 'warn') {
+          mark = warnMark
+          markColor = function (text) {
+            return decorate(text, palette.yellow)
+          }
+        } else if (!threshold.ok) {
           mark = failMark
           markColor = function (text) {
             return decorate(text, palette.red)
@@ -376,12 +411,37 @@ function summarizeMetrics(options, data, decorate) {
         palette.faint
       )
 
-    result.push(indent + fmtIndent + markColor(mark) + ' ' + fmtName + ' ' + getData(name))
+    var line = indent + fmtIndent + markColor(mark) + ' ' + fmtName + ' ' + getData(name)
+    // the descriptions of the custom metrics are only shown next to them, not their submetrics
+    if (metric.description && name.indexOf('{') === -1) {
+      line = line + ' ' + decorate(metric.description, palette.faint)
+    }
+    result.push(line)
   }
 
   return result
 }
 
+// summarizeTagCardinality lists the tags of the metrics that had more unique values than the tag cardinality limit.
+function summarizeTagCardinality(indent, offenders, decorate) {
+  var result = [indent + decorate('tags above the tag cardinality limit:', palette.red)]
+  for (var i = 0; i < offenders.length; i++) {
+    var offender = offenders[i]
+    result.push(
+      indent +
+        '  ' +
+        detailsPrefix +
+        ' ' +
+        offender.metric +
+        ' ' +
+        offender.tag +
+        ': ' +
+        decorate(offender.overLimitSamples + ' samples above the limit', palette.faint)
+    )
+  }
+  return result
+}
+
 function generateTextSummary(data, options) {
   var mergedOpts = Object.assign({}, defaultOptions, data.options, options)
   var lines = []
@@ -407,6 +467,14 @@ function generateTextSummary(data, options) {
 
   Array.prototype.push.apply(lines, summarizeMetrics(mergedOpts, data, decorate))
 
+  if (data.tag_cardinality_offenders && data.tag_cardinality_offenders.length > 0) {
+    lines.push('')
+    Array.prototype.push.apply(
+      lines,
+      summarizeTagCardinality(mergedOpts.indent + '  ', data.tag_cardinality_offenders, decorate)
+    )
+  }
+
   return lines.join('\n')
 }
 
@@ -146,6 +146,253 @@ func TestTextSummaryWithSubMetrics(t *testing.T) {
 	assert.Equal(t, "\n"+expected+"\n", string(summaryOut))
 }
 
+func TestTextSummaryWithTrendStatsPerMetric(t *testing.T) {
+	t.Parallel()
+
+	metrics := make(map[string]*stats.Metric)
+	for _, name := range []string{"my_trend", "my_trend{sub:1}", "other_trend"} {
+		m := stats.New(name, stats.Trend)
+		for _, v := range []float64{10.0, 15.0, 20.0} {
+			m.Sink.Add(stats.Sample{Value: v})
+		}
+		metrics[name] = m
+	}
+
+	summary := &lib.Summary{
+		Metrics:         metrics,
+		RootGroup:       &lib.Group{},
+		TestRunDuration: time.Second,
+	}
+
+	runner, err := getSimpleRunner(
+		t,
+		"/script.js",
+		`
+			exports.options = {
+				summaryTrendStats: ["avg", "max"],
+				summaryTrendStatsPerMetric: {my_trend: ["avg", "p(99.9)", "count"]},
+			};
+			exports.default = function() {/* we don't run this, metrics are mocked */};
+		`,
+		lib.RuntimeOptions{CompatibilityMode: null.NewString("base", true)},
+	)
+	require.NoError(t, err)
+
+	result, err := runner.HandleSummary(context.Background(), summary)
+	require.NoError(t, err)
+
+	require.Len(t, result, 1)
+	stdout := result["stdout"]
+	require.NotNil(t, stdout)
+
+	summaryOut, err := ioutil.ReadAll(stdout)
+	require.NoError(t, err)
+
+	expected := "     my_trend......: avg=15 p(99.9)=19.99 count=3\n" +
+		"       { sub:1 }...: avg=15 p(99.9)=19.99 count=3\n" +
+		"     other_trend...: avg=15 max=20   \n"
+	assert.Equal(t, "\n"+expected+"\n", string(summaryOut))
+}
+
+func TestTextSummaryWithTagCardinalityOffenders(t *testing.T) {
+	t.Parallel()
+
+	counter := stats.New("http_reqs", stats.Counter)
+	counter.Sink.Add(stats.Sample{Value: 3})
+	summary := &lib.Summary{
+		Metrics:         map[string]*stats.Metric{"http_reqs": counter},
+		RootGroup:       &lib.Group{},
+		TestRunDuration: time.Second,
+		TagCardinalityOffenders: []lib.TagCardinalityOffender{
+			{Metric: "http_req_duration", Tag: "url", OverLimitSamples: 42},
+			{Metric: "http_reqs", Tag: "url", OverLimitSamples: 42},
+		},
+	}
+
+	runner, err := getSimpleRunner(
+		t,
+		"/script.js",
+		"exports.default = function() {/* we don't run this, metrics are mocked */};",
+		lib.RuntimeOptions{CompatibilityMode: null.NewString("base", true)},
+	)
+	require.NoError(t, err)
+
+	result, err := runner.HandleSummary(context.Background(), summary)
+	require.NoError(t, err)
+
+	require.Len(t, result, 1)
+	stdout := result["stdout"]
+	require.NotNil(t, stdout)
+
+	summaryOut, err := ioutil.ReadAll(stdout)
+	require.NoError(t, err)
+
+	expected := "     http_reqs...: 3 3/s\n\n" +
+		"   tags above the tag cardinality limit:\n" +
+		"     ↳ http_req_duration url: 42 samples above the limit\n" +
+		"     ↳ http_reqs url: 42 samples above the limit\n"
+	assert.Equal(t, "\n"+expected+"\n", string(summaryOut))
+}
+
+func TestTextSummaryWithUnits(t *testing.T) {
+	t.Parallel()
+
+	orders := stats.New("orders", stats.Counter)
+	orders.Unit = "orders"
+	orders.Sink.Add(stats.Sample{Value: 4})
+	wait := stats.New("wait", stats.Gauge)
+	wait.Unit = stats.UnitSeconds
+	wait.Sink.Add(stats.Sample{Value: 1.5})
+	summary := &lib.Summary{
+		Metrics:         map[string]*stats.Metric{"orders": orders, "wait": wait},
+		RootGroup:       &lib.Group{},
+		TestRunDuration: 2 * time.Second,
+	}
+
+	runner, err := getSimpleRunner(
+		t,
+		"/script.js",
+		"exports.default = function() {/* we don't run this, metrics are mocked */};",
+		lib.RuntimeOptions{CompatibilityMode: null.NewString("base", true)},
+	)
+	require.NoError(t, err)
+
+	result, err := runner.HandleSummary(context.Background(), summary)
+	require.NoError(t, err)
+
+	require.Len(t, result, 1)
+	stdout := result["stdout"]
+	require.NotNil(t, stdout)
+
+	summaryOut, err := ioutil.ReadAll(stdout)
+	require.NoError(t, err)
+
+	expected := "     orders...: 4 orders 2 orders/s\n" +
+		"     wait.....: 1.5s     min=1.5s   max=1.5s\n"
+	assert.Equal(t, "\n"+expected+"\n", string(summaryOut))
+}
+
+func TestTextSummaryWithDescriptions(t *testing.T) {
+	t.Parallel()
+
+	orders := stats.New("orders", stats.Counter)
+	orders.Description, orders.ID = "The placed orders", "shop.orders"
+	orders.Sink.Add(stats.Sample{Value: 4})
+	shopOrders := stats.New("orders{shop:a}", stats.Counter)
+	shopOrders.Description, shopOrders.ID = orders.Description, orders.ID
+	shopOrders.Sink.Add(stats.Sample{Value: 2})
+	summary := &lib.Summary{
+		Metrics:         map[string]*stats.Metric{"orders": orders, "orders{shop:a}": shopOrders},
+		RootGroup:       &lib.Group{},
+		TestRunDuration: 2 * time.Second,
+	}
+
+	runner, err := getSimpleRunner(
+		t,
+		"/script.js",
+		"exports.default = function() {/* we don't run this, metrics are mocked */};",
+		lib.RuntimeOptions{CompatibilityMode: null.NewString("base", true)},
+	)
+	require.NoError(t, err)
+
+	result, err := runner.HandleSummary(context.Background(), summary)
+	require.NoError(t, err)
+
+	require.Len(t, result, 1)
+	stdout := result["stdout"]
+	require.NotNil(t, stdout)
+
+	summaryOut, err := ioutil.ReadAll(stdout)
+	require.NoError(t, err)
+
+	expected := "     orders.........: 4 2/s The placed orders\n" +
+		"       { shop:a }...: 2 1/s\n"
+	assert.Equal(t, "\n"+expected+"\n", string(summaryOut))
+}
+
+func TestTextSummaryWithThresholdSeverities(t *testing.T) {
+	t.Parallel()
+
+	warned := stats.New("warned", stats.Counter)
+	warned.Sink.Add(stats.Sample{Value: 4})
+	warned.Thresholds = stats.Thresholds{Thresholds: []*stats.Threshold{
+		{Source: "count<2", LastFailed: true, Severity: stats.ThresholdSeverityWarn},
+	}}
+	failed := stats.New("failed", stats.Counter)
+	failed.Sink.Add(stats.Sample{Value: 2})
+	failed.Thresholds = stats.Thresholds{Thresholds: []*stats.Threshold{
+		{Source: "count<1", LastFailed: true, Severity: stats.ThresholdSeverityWarn},
+		{Source: "count<2", LastFailed: true, Severity: stats.ThresholdSeverityCritical},
+	}}
+	summary := &lib.Summary{
+		Metrics:         map[string]*stats.Metric{"warned": warned, "failed": failed},
+		RootGroup:       &lib.Group{},
+		TestRunDuration: 2 * time.Second,
+	}
+
+	runner, err := getSimpleRunner(
+		t,
+		"/script.js",
+		"exports.default = function() {/* we don't run this, metrics are mocked */};",
+		lib.RuntimeOptions{CompatibilityMode: null.NewString("base", true)},
+	)
+	require.NoError(t, err)
+
+	result, err := runner.HandleSummary(context.Background(), summary)
+	require.NoError(t, err)
+
+	require.Len(t, result, 1)
+	stdout := result["stdout"]
+	require.NotNil(t, stdout)
+
+	summaryOut, err := ioutil.ReadAll(stdout)
+	require.NoError(t, err)
+
+	expected := "   ✗ failed...: 2 1/s\n" +
+		"   ! warned...: 4 2/s\n"
+	assert.Equal(t, "\n"+expected+"\n", string(summaryOut))
+}
+
+func TestTextSummaryWithHistograms(t *testing.T) {
+	t.Parallel()
+
+	size := stats.New("size", stats.Histogram, stats.Data)
+	size.Buckets = []float64{1000, 2000}
+	size.Sink = stats.NewHistogramSink(size.Buckets)
+	for _, v := range []float64{500, 1500, 1500, 3000} {
+		size.Sink.Add(stats.Sample{Value: v})
+	}
+	summary := &lib.Summary{
+		Metrics:         map[string]*stats.Metric{"size": size},
+		RootGroup:       &lib.Group{},
+		TestRunDuration: 2 * time.Second,
+	}
+
+	runner, err := getSimpleRunner(
+		t,
+		"/script.js",
+		`
+		exports.options = {summaryTrendStats: ["avg", "min", "med", "max", "p(90)", "count"]};
+		exports.default = function() {/* we don't run this, metrics are mocked */};
+		`,
+		lib.RuntimeOptions{CompatibilityMode: null.NewString("base", true)},
+	)
+	require.NoError(t, err)
+
+	result, err := runner.HandleSummary(context.Background(), summary)
+	require.NoError(t, err)
+
+	require.Len(t, result, 1)
+	stdout := result["stdout"]
+	require.NotNil(t, stdout)
+
+	summaryOut, err := ioutil.ReadAll(stdout)
+	require.NoError(t, err)
+
+	expected := "     size...: avg=1.6 kB min=500 B med=1.5 kB max=3.0 kB p(90)=2.6 kB count=4\n"
+	assert.Equal(t, "\n"+expected+"\n", string(summaryOut))
+}
+
 func createTestMetrics(t *testing.T) (map[string]*stats.Metric, *lib.Group) {
 	metrics := make(map[string]*stats.Metric)
 	gaugeMetric := stats.New("vus", stats.Gauge)
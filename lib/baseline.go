@@ -0,0 +1,73 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Baseline has the values of the metrics of a previous test run, by metric name and aggregation method,
+// to which the thresholds can compare the ones of the test run.
+type Baseline map[string]map[string]float64
+
+// ParseBaseline parses a baseline from a summary of a previous test run, exported with --summary-export
+// or with the JSON of the data of handleSummary().
+func ParseBaseline(data []byte) (Baseline, error) {
+	var summary struct {
+		Metrics map[string]map[string]json.RawMessage `json:"metrics"`
+	}
+	if err := json.Unmarshal(data, &summary); err != nil {
+		return nil, fmt.Errorf("couldn't parse the baseline: %w", err)
+	}
+	if len(summary.Metrics) == 0 {
+		return nil, errors.New("the baseline has no metrics")
+	}
+
+	baseline := make(Baseline, len(summary.Metrics))
+	for name, metric := range summary.Metrics {
+		fields := metric
+		if raw, ok := metric["values"]; ok {
+			if err := json.Unmarshal(raw, &fields); err != nil {
+				return nil, fmt.Errorf("couldn't parse the values of the metric %s of the baseline: %w", name, err)
+			}
+		}
+
+		values := make(map[string]float64, len(fields))
+		for method, raw := range fields {
+			var v float64
+			// the other fields, like the thresholds, aren't values
+			if json.Unmarshal(raw, &v) == nil {
+				values[method] = v
+			}
+		}
+		// the rates of the summary export are their value
+		if _, ok := fields["passes"]; ok {
+			if v, ok := values["value"]; ok {
+				values["rate"] = v
+				delete(values, "value")
+			}
+		}
+		baseline[name] = values
+	}
+	return baseline, nil
+}
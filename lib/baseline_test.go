@@ -0,0 +1,78 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBaseline(t *testing.T) {
+	t.Parallel()
+
+	t.Run("summary export", func(t *testing.T) {
+		t.Parallel()
+		baseline, err := ParseBaseline([]byte(`{
+			"root_group": {},
+			"metrics": {
+				"http_req_duration": {"avg": 120.5, "p(95)": 300, "thresholds": {"p(95)<500": false}},
+				"checks": {"passes": 9, "fails": 1, "value": 0.9},
+				"vus": {"value": 10, "min": 1, "max": 10}
+			}
+		}`))
+		require.NoError(t, err)
+		assert.Equal(t, Baseline{
+			"http_req_duration": {"avg": 120.5, "p(95)": 300},
+			"checks":            {"passes": 9, "fails": 1, "rate": 0.9},
+			"vus":               {"value": 10, "min": 1, "max": 10},
+		}, baseline)
+	})
+
+	t.Run("summary data", func(t *testing.T) {
+		t.Parallel()
+		baseline, err := ParseBaseline([]byte(`{
+			"metrics": {
+				"http_req_duration{group:::a}": {
+					"type": "trend", "contains": "time", "values": {"avg": 120.5, "p(95)": 300},
+					"thresholds": {"p(95)<500": {"ok": true}}
+				},
+				"checks": {"type": "rate", "values": {"passes": 9, "fails": 1, "rate": 0.9}}
+			}
+		}`))
+		require.NoError(t, err)
+		assert.Equal(t, Baseline{
+			"http_req_duration{group:::a}": {"avg": 120.5, "p(95)": 300},
+			"checks":                       {"passes": 9, "fails": 1, "rate": 0.9},
+		}, baseline)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		t.Parallel()
+		_, err := ParseBaseline([]byte(`{"metrics":`))
+		assert.Error(t, err)
+		_, err = ParseBaseline([]byte(`{"metrics": {}}`))
+		assert.EqualError(t, err, "the baseline has no metrics")
+		_, err = ParseBaseline([]byte(`{"metrics": {"a": {"values": 1}}}`))
+		assert.Error(t, err)
+	})
+}
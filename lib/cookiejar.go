@@ -0,0 +1,162 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CookieJar is a cookiejar.Jar that keeps track of the cookies that were set in it, as cookiejar.Jar
+// itself doesn't allow them to be listed, so that they can be exported and imported in another jar.
+// It's safe for concurrent use.
+type CookieJar struct {
+	*cookiejar.Jar
+
+	mu      sync.Mutex
+	cookies map[string]ExportedCookie // by domain, path and name
+}
+
+// ExportedCookie is a cookie as it was set in a CookieJar, with the URL it was set for.
+type ExportedCookie struct {
+	URL      string `json:"url"`
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Domain   string `json:"domain,omitempty"`
+	Path     string `json:"path,omitempty"`
+	Expires  int64  `json:"expires,omitempty"` // in milliseconds since the epoch, 0 for session cookies
+	Secure   bool   `json:"secure,omitempty"`
+	HTTPOnly bool   `json:"http_only,omitempty"`
+}
+
+// NewCookieJar returns a new empty CookieJar.
+func NewCookieJar() (*CookieJar, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	return &CookieJar{Jar: jar, cookies: make(map[string]ExportedCookie)}, nil
+}
+
+// SetCookies implements the http.CookieJar interface.
+func (j *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.Jar.SetCookies(u, cookies)
+
+	cookieURL := url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}
+	for _, c := range cookies {
+		domain := strings.TrimPrefix(strings.ToLower(c.Domain), ".")
+		if domain == "" {
+			domain = u.Hostname()
+		}
+		key := domain + ";" + c.Path + ";" + c.Name
+		if c.MaxAge < 0 || (!c.Expires.IsZero() && !c.Expires.After(time.Now())) {
+			delete(j.cookies, key)
+			continue
+		}
+		var expires int64
+		if c.MaxAge > 0 {
+			expires = time.Now().Add(time.Duration(c.MaxAge)*time.Second).UnixNano() / int64(time.Millisecond)
+		} else if !c.Expires.IsZero() {
+			expires = c.Expires.UnixNano() / int64(time.Millisecond)
+		}
+		j.cookies[key] = ExportedCookie{
+			URL:      cookieURL.String(),
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     c.Path,
+			Expires:  expires,
+			Secure:   c.Secure,
+			HTTPOnly: c.HttpOnly,
+		}
+	}
+}
+
+// Export returns the cookies that are still in the jar, sorted by their URL and name.
+func (j *CookieJar) Export() []ExportedCookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	result := make([]ExportedCookie, 0, len(j.cookies))
+	for key, c := range j.cookies {
+		if !j.contains(c) {
+			// it expired or was replaced by a cookie for a parent domain or path
+			delete(j.cookies, key)
+			continue
+		}
+		result = append(result, c)
+	}
+	sort.Slice(result, func(a, b int) bool {
+		if result[a].URL != result[b].URL {
+			return result[a].URL < result[b].URL
+		}
+		return result[a].Name < result[b].Name
+	})
+	return result
+}
+
+func (j *CookieJar) contains(c ExportedCookie) bool {
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return false
+	}
+	if c.Path != "" {
+		u.Path = c.Path
+	}
+	for _, current := range j.Jar.Cookies(u) {
+		if current.Name == c.Name && current.Value == c.Value {
+			return true
+		}
+	}
+	return false
+}
+
+// Import sets the cookies, previously returned by Export, in the jar.
+func (j *CookieJar) Import(cookies []ExportedCookie) error {
+	for _, c := range cookies {
+		u, err := url.Parse(c.URL)
+		if err != nil {
+			return fmt.Errorf("invalid URL %q of cookie %q: %w", c.URL, c.Name, err)
+		}
+		var expires time.Time
+		if c.Expires != 0 {
+			expires = time.Unix(0, c.Expires*int64(time.Millisecond))
+		}
+		j.SetCookies(u, []*http.Cookie{{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     c.Path,
+			Expires:  expires,
+			Secure:   c.Secure,
+			HttpOnly: c.HTTPOnly,
+		}})
+	}
+	return nil
+}
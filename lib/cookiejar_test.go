@@ -0,0 +1,68 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCookieJarExportImport(t *testing.T) {
+	t.Parallel()
+	jar, err := NewCookieJar()
+	require.NoError(t, err)
+
+	u, err := url.Parse("https://sub.example.com/login/form")
+	require.NoError(t, err)
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "session", Value: "1", Path: "/", Secure: true, HttpOnly: true},
+		{Name: "domain", Value: "2", Domain: "example.com", Expires: expires},
+		{Name: "form", Value: "3"},
+		{Name: "deleted", Value: "4"},
+	})
+	jar.SetCookies(u, []*http.Cookie{{Name: "deleted", Value: "", MaxAge: -1}})
+
+	exported := jar.Export()
+	assert.Equal(t, []ExportedCookie{
+		{URL: "https://sub.example.com/login/form", Name: "domain", Value: "2", Domain: "example.com",
+			Expires: expires.UnixNano() / int64(time.Millisecond)},
+		{URL: "https://sub.example.com/login/form", Name: "form", Value: "3"},
+		{URL: "https://sub.example.com/login/form", Name: "session", Value: "1", Path: "/", Secure: true, HTTPOnly: true},
+	}, exported)
+
+	imported, err := NewCookieJar()
+	require.NoError(t, err)
+	require.NoError(t, imported.Import(exported))
+	assert.Equal(t, exported, imported.Export())
+	for _, rawURL := range []string{"https://sub.example.com/login/form", "https://example.com/", "http://sub.example.com/"} {
+		u, err := url.Parse(rawURL)
+		require.NoError(t, err)
+		assert.Equal(t, jar.Cookies(u), imported.Cookies(u), rawURL)
+	}
+
+	require.Error(t, imported.Import([]ExportedCookie{{URL: "://", Name: "invalid"}}))
+}
@@ -0,0 +1,73 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"math"
+	"sort"
+)
+
+// ExecPicker picks the exported function of each iteration of a scenario
+// which spreads its iterations over multiple functions by their weights.
+//
+// The function of an iteration is picked by the fractional part of its number
+// multiplied by the golden ratio, which is a low-discrepancy sequence, so the
+// functions are evenly interleaved and their shares of the iterations match
+// their weights closely even in short tests. Since the global iteration
+// numbers of a scenario are used, all instances pick consistently.
+type ExecPicker struct {
+	names      []string
+	cumulative []float64 // the sums of the weights up to each name, as fractions of the total
+}
+
+// NewExecPicker returns a new ExecPicker for the functions with the given
+// positive weights, or nil if there are none.
+func NewExecPicker(weights map[string]int64) *ExecPicker {
+	if len(weights) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(weights))
+	var total int64
+	for name, weight := range weights {
+		names = append(names, name)
+		total += weight
+	}
+	sort.Strings(names)
+
+	cumulative := make([]float64, len(names))
+	var sum int64
+	for i, name := range names {
+		sum += weights[name]
+		cumulative[i] = float64(sum) / float64(total)
+	}
+	return &ExecPicker{names: names, cumulative: cumulative}
+}
+
+// Pick returns the name of the function of the given iteration of the scenario.
+func (ep *ExecPicker) Pick(iteration uint64) string {
+	const goldenRatioConjugate = 0.6180339887498949
+	_, x := math.Modf(float64(iteration) * goldenRatioConjugate)
+	i := sort.Search(len(ep.cumulative), func(i int) bool { return x < ep.cumulative[i] })
+	if i == len(ep.names) { // just in case of rounding errors
+		i--
+	}
+	return ep.names[i]
+}
@@ -0,0 +1,61 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecPicker(t *testing.T) {
+	t.Parallel()
+	assert.Nil(t, NewExecPicker(nil))
+
+	ep := NewExecPicker(map[string]int64{"browse": 70, "checkout": 30})
+	counts := map[string]int{}
+	longestRun, run := 0, 0
+	for i := uint64(0); i < 1000; i++ {
+		name := ep.Pick(i)
+		counts[name]++
+		if name == "browse" {
+			run++
+			if run > longestRun {
+				longestRun = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	assert.InDelta(t, 700, counts["browse"], 5)
+	assert.InDelta(t, 300, counts["checkout"], 5)
+	assert.LessOrEqual(t, longestRun, 4)
+
+	// the picks don't depend on the order in which they are made
+	for i := uint64(0); i < 100; i++ {
+		assert.Equal(t, ep.Pick(i), NewExecPicker(map[string]int64{"checkout": 30, "browse": 70}).Pick(i))
+	}
+
+	single := NewExecPicker(map[string]int64{"only": 1})
+	for i := uint64(0); i < 100; i++ {
+		assert.Equal(t, "only", single.Pick(i))
+	}
+}
@@ -89,6 +89,22 @@ type ExecutionScheduler interface {
 	SetPaused(paused bool) error
 }
 
+// ExecutionController coordinates the execution of a test between multiple k6
+// instances that each run a different execution segment of the same test. The
+// local execution scheduler uses it, if one is set, to make all instances
+// start and finish the test run at the same time and to run the setup() and
+// teardown() functions only once.
+type ExecutionController interface {
+	// GetOrCreateData returns the data with the given ID. Only a single
+	// instance executes the callback to create the data, all others wait
+	// for it and receive its result, including any error.
+	GetOrCreateData(id string, callback func() ([]byte, error)) ([]byte, error)
+
+	// SignalAndWait signals that this instance has reached the event with
+	// the given ID and blocks until all other instances have reached it too.
+	SignalAndWait(eventID string) error
+}
+
 // MaxTimeToWaitForPlannedVU specifies the maximum allowable time for an executor
 // to wait for a planned VU to be retrieved from the ExecutionState.PlannedVUs
 // buffer. If it's exceeded, k6 will emit a warning log message, since it either
@@ -126,6 +142,24 @@ const (
 	ExecutionStatusInterrupted
 )
 
+// ScenarioOutcome is how a scenario ended, the scenarios with startAfterSuccess
+// only start after the ones they depend on succeeded.
+type ScenarioOutcome uint32
+
+// Possible scenario outcomes
+const (
+	// ScenarioPending is the outcome of the scenarios that haven't ended yet.
+	ScenarioPending ScenarioOutcome = iota
+	// ScenarioSucceeded is the outcome of the scenarios whose iterations all
+	// completed without errors or interruptions.
+	ScenarioSucceeded
+	// ScenarioFailed is the outcome of the scenarios with failed or
+	// interrupted iterations, or whose executor or setup returned an error.
+	ScenarioFailed
+	// ScenarioSkipped is the outcome of the scenarios that never started.
+	ScenarioSkipped
+)
+
 // ExecutionState contains a few different things:
 //  -  Some convenience items, that are needed by all executors, like the
 //     execution segment and the unique VU ID generator. By keeping those here,
@@ -156,6 +190,10 @@ type ExecutionState struct {
 
 	ExecutionTuple *ExecutionTuple // TODO Rename, possibly move
 
+	// LiveMetrics, when set, are the recent values of the metrics, which the
+	// executors with a feedback loop on them query.
+	LiveMetrics *metrics.LiveMetrics
+
 	// vus is the shared channel buffer that contains all of the VUs that have
 	// been initialized and aren't currently being used by a executor.
 	//
@@ -228,6 +266,24 @@ type ExecutionState struct {
 	// API, etc.
 	interruptedIterationsCount *uint64
 
+	// The number of iterations of each scenario that ended with an error or
+	// were interrupted, used by the startAfterSuccess option.
+	failedIterationsCounts map[string]*uint64
+
+	// How each scenario ended, a ScenarioOutcome, used by the
+	// startAfterSuccess option.
+	scenarioOutcomes map[string]*uint32
+
+	// The pause state of each scenario, which can be paused and resumed on its
+	// own, while the rest of the test keeps running. No new iterations of a
+	// paused scenario are started until it's resumed, but its duration still
+	// runs out as usual.
+	scenarioPauses map[string]*scenarioPause
+
+	// The executors that can be scaled while they run, by the names of their
+	// scenarios. They are set before the test starts and aren't modified after.
+	scenarioScalers map[string]ScalableExecutor
+
 	// A machine-readable indicator in which the current state of the test
 	// execution is currently stored. Useful for the REST API and external
 	// observability of the k6 test run progress.
@@ -283,6 +339,18 @@ func NewExecutionState(options Options, et *ExecutionTuple, maxPlannedVUs, maxPo
 
 	maxUnplannedUninitializedVUs := int64(maxPossibleVUs - maxPlannedVUs)
 
+	failedIterationsCounts := make(map[string]*uint64, len(options.Scenarios))
+	scenarioOutcomes := make(map[string]*uint32, len(options.Scenarios))
+	for name := range options.Scenarios {
+		failedIterationsCounts[name] = new(uint64)
+		scenarioOutcomes[name] = new(uint32)
+	}
+
+	scenarioPauses := make(map[string]*scenarioPause, len(options.Scenarios))
+	for name := range options.Scenarios {
+		scenarioPauses[name] = newScenarioPause()
+	}
+
 	segIdx := NewSegmentedIndex(et)
 	return &ExecutionState{
 		Options: options,
@@ -296,6 +364,10 @@ func NewExecutionState(options Options, et *ExecutionTuple, maxPlannedVUs, maxPo
 		activeVUs:                  new(int64),
 		fullIterationsCount:        new(uint64),
 		interruptedIterationsCount: new(uint64),
+		failedIterationsCounts:     failedIterationsCounts,
+		scenarioOutcomes:           scenarioOutcomes,
+		scenarioPauses:             scenarioPauses,
+		scenarioScalers:            make(map[string]ScalableExecutor),
 		startTime:                  new(int64),
 		endTime:                    new(int64),
 		currentPauseTime:           new(int64),
@@ -382,6 +454,43 @@ func (es *ExecutionState) AddInterruptedIterations(count uint64) uint64 {
 	return atomic.AddUint64(es.interruptedIterationsCount, count)
 }
 
+// AddFailedIterations increments the number of iterations of the given
+// scenario that ended with an error or were interrupted by the provided amount.
+func (es *ExecutionState) AddFailedIterations(scenario string, count uint64) uint64 {
+	counter, ok := es.failedIterationsCounts[scenario]
+	if !ok {
+		return 0
+	}
+	return atomic.AddUint64(counter, count)
+}
+
+// GetFailedIterationCount returns the number of iterations of the given
+// scenario that ended with an error or were interrupted so far.
+func (es *ExecutionState) GetFailedIterationCount(scenario string) uint64 {
+	counter, ok := es.failedIterationsCounts[scenario]
+	if !ok {
+		return 0
+	}
+	return atomic.LoadUint64(counter)
+}
+
+// SetScenarioOutcome records how the given scenario ended.
+func (es *ExecutionState) SetScenarioOutcome(scenario string, outcome ScenarioOutcome) {
+	if o, ok := es.scenarioOutcomes[scenario]; ok {
+		atomic.StoreUint32(o, uint32(outcome))
+	}
+}
+
+// GetScenarioOutcome returns how the given scenario ended, or ScenarioPending
+// if it hasn't ended yet.
+func (es *ExecutionState) GetScenarioOutcome(scenario string) ScenarioOutcome {
+	o, ok := es.scenarioOutcomes[scenario]
+	if !ok {
+		return ScenarioPending
+	}
+	return ScenarioOutcome(atomic.LoadUint32(o))
+}
+
 // SetExecutionStatus changes the current execution status to the supplied value
 // and returns the current value.
 func (es *ExecutionState) SetExecutionStatus(newStatus ExecutionStatus) (oldStatus ExecutionStatus) {
@@ -527,6 +636,111 @@ func (es *ExecutionState) ResumeNotify() <-chan struct{} {
 	return es.resumeNotify
 }
 
+// scenarioPause is the pause state of a single scenario, with a resumeNotify
+// channel that works like the one of the whole test execution.
+type scenarioPause struct {
+	lock         sync.RWMutex
+	paused       bool
+	resumeNotify chan struct{}
+}
+
+func newScenarioPause() *scenarioPause {
+	resumeNotify := make(chan struct{})
+	close(resumeNotify)
+	return &scenarioPause{resumeNotify: resumeNotify}
+}
+
+func (es *ExecutionState) getScenarioPause(scenario string) (*scenarioPause, error) {
+	sp, ok := es.scenarioPauses[scenario]
+	if !ok {
+		return nil, fmt.Errorf("there is no scenario '%s'", scenario)
+	}
+	return sp, nil
+}
+
+// PauseScenario pauses the given scenario, so none of its VUs start new
+// iterations until it's resumed. The iterations which are already running
+// aren't interrupted. It returns an error if the scenario doesn't exist or was
+// already paused.
+func (es *ExecutionState) PauseScenario(scenario string) error {
+	sp, err := es.getScenarioPause(scenario)
+	if err != nil {
+		return err
+	}
+	sp.lock.Lock()
+	defer sp.lock.Unlock()
+
+	if sp.paused {
+		return fmt.Errorf("scenario '%s' was already paused", scenario)
+	}
+	sp.paused = true
+	sp.resumeNotify = make(chan struct{})
+	return nil
+}
+
+// ResumeScenario resumes the given paused scenario. It returns an error if the
+// scenario doesn't exist or wasn't paused.
+func (es *ExecutionState) ResumeScenario(scenario string) error {
+	sp, err := es.getScenarioPause(scenario)
+	if err != nil {
+		return err
+	}
+	sp.lock.Lock()
+	defer sp.lock.Unlock()
+
+	if !sp.paused {
+		return fmt.Errorf("scenario '%s' wasn't paused", scenario)
+	}
+	sp.paused = false
+	close(sp.resumeNotify)
+	return nil
+}
+
+// IsScenarioPaused returns whether the given scenario is currently paused.
+func (es *ExecutionState) IsScenarioPaused(scenario string) bool {
+	sp, err := es.getScenarioPause(scenario)
+	if err != nil {
+		return false
+	}
+	sp.lock.RLock()
+	defer sp.lock.RUnlock()
+	return sp.paused
+}
+
+// ScenarioResumeNotify returns a channel which will be closed as soon as the
+// given scenario is resumed, or which is already closed if it isn't paused.
+func (es *ExecutionState) ScenarioResumeNotify(scenario string) <-chan struct{} {
+	sp, err := es.getScenarioPause(scenario)
+	if err != nil {
+		resumed := make(chan struct{})
+		close(resumed)
+		return resumed
+	}
+	sp.lock.RLock()
+	defer sp.lock.RUnlock()
+	return sp.resumeNotify
+}
+
+// SetScenarioScaler sets the executor of the given scenario, which is used by
+// ScaleScenario(). It should be called before the test starts.
+func (es *ExecutionState) SetScenarioScaler(scenario string, scaler ScalableExecutor) {
+	es.scenarioScalers[scenario] = scaler
+}
+
+// ScaleScenario changes the VUs or the iteration rate of the given running
+// scenario. It returns an error if the scenario doesn't exist, its executor
+// can't be scaled or the change is invalid for it.
+func (es *ExecutionState) ScaleScenario(ctx context.Context, scenario string, scale ScenarioScale) error {
+	if _, err := es.getScenarioPause(scenario); err != nil {
+		return err
+	}
+	scaler, ok := es.scenarioScalers[scenario]
+	if !ok {
+		return fmt.Errorf("the executor of scenario '%s' doesn't support scaling", scenario)
+	}
+	return scaler.Scale(ctx, scale)
+}
+
 // GetPlannedVU tries to get a pre-initialized VU from the buffer channel. This
 // shouldn't fail and should generally be an instantaneous action, but if it
 // doesn't happen for MaxTimeToWaitForPlannedVU (for example, because the system
@@ -0,0 +1,470 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package executor
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/stats"
+	"go.k6.io/k6/ui/pb"
+)
+
+const adaptiveArrivalRateType = "adaptive-arrival-rate"
+
+func init() {
+	lib.RegisterExecutorConfigType(
+		adaptiveArrivalRateType,
+		func(name string, rawJSON []byte) (lib.ExecutorConfig, error) {
+			config := NewAdaptiveArrivalRateConfig(name)
+			err := lib.StrictJSONUnmarshal(rawJSON, &config)
+			return config, err
+		},
+	)
+}
+
+// AdaptiveArrivalRateConfig stores the configuration for the adaptive-arrival-rate executor, which
+// adjusts its iteration rate on every interval, so the value of the target metric, e.g. the p(95)
+// of http_req_duration or the rate of http_req_failed, is held at the target.
+type AdaptiveArrivalRateConfig struct {
+	BaseConfig
+	StartRate null.Int           `json:"startRate"`
+	MinRate   null.Int           `json:"minRate"`
+	MaxRate   null.Int           `json:"maxRate"`
+	TimeUnit  types.NullDuration `json:"timeUnit"`
+	Duration  types.NullDuration `json:"duration"`
+
+	// The value of the metric, queried from the live metrics over the last
+	// Interval, and how often the rate is adjusted by comparing it to Target
+	TargetMetric null.String        `json:"targetMetric"`
+	TargetValue  null.String        `json:"targetValue"`
+	Target       null.Float         `json:"target"`
+	Interval     types.NullDuration `json:"interval"`
+
+	PreAllocatedVUs null.Int `json:"preAllocatedVUs"`
+	MaxVUs          null.Int `json:"maxVUs"`
+}
+
+// NewAdaptiveArrivalRateConfig returns an AdaptiveArrivalRateConfig with default values
+func NewAdaptiveArrivalRateConfig(name string) AdaptiveArrivalRateConfig {
+	return AdaptiveArrivalRateConfig{
+		BaseConfig:   NewBaseConfig(name, adaptiveArrivalRateType),
+		MinRate:      null.NewInt(1, false),
+		TimeUnit:     types.NewNullDuration(1*time.Second, false),
+		TargetMetric: null.NewString(metrics.HTTPReqDurationName, false),
+		TargetValue:  null.NewString("p(95)", false),
+		Interval:     types.NewNullDuration(5*time.Second, false),
+	}
+}
+
+// Make sure we implement the lib.ExecutorConfig interface
+var _ lib.ExecutorConfig = &AdaptiveArrivalRateConfig{}
+
+// GetPreAllocatedVUs is just a helper method that returns the scaled pre-allocated VUs.
+func (aarc AdaptiveArrivalRateConfig) GetPreAllocatedVUs(et *lib.ExecutionTuple) int64 {
+	return et.ScaleInt64(aarc.PreAllocatedVUs.Int64)
+}
+
+// GetMaxVUs is just a helper method that returns the scaled max VUs, which
+// are the pre-allocated ones if they aren't specified.
+func (aarc AdaptiveArrivalRateConfig) GetMaxVUs(et *lib.ExecutionTuple) int64 {
+	if !aarc.MaxVUs.Valid {
+		return aarc.GetPreAllocatedVUs(et)
+	}
+	return et.ScaleInt64(aarc.MaxVUs.Int64)
+}
+
+// getRatePerSec returns the iterations per second of the rate for the given execution segment.
+func (aarc AdaptiveArrivalRateConfig) getRatePerSec(et *lib.ExecutionTuple, rate float64) float64 {
+	return rate * et.Segment.FloatLength() * float64(time.Second) / float64(aarc.TimeUnit.TimeDuration())
+}
+
+// GetDescription returns a human-readable description of the executor options
+func (aarc AdaptiveArrivalRateConfig) GetDescription(et *lib.ExecutionTuple) string {
+	preAllocatedVUs, maxVUs := aarc.GetPreAllocatedVUs(et), aarc.GetMaxVUs(et)
+	maxVUsRange := fmt.Sprintf("maxVUs: %d", preAllocatedVUs)
+	if maxVUs > preAllocatedVUs {
+		maxVUsRange += fmt.Sprintf("-%d", maxVUs)
+	}
+
+	return fmt.Sprintf("%.2f-%.2f iterations/s for %s, holding %s of %s at %g%s",
+		aarc.getRatePerSec(et, float64(aarc.MinRate.Int64)), aarc.getRatePerSec(et, float64(aarc.MaxRate.Int64)),
+		aarc.Duration.Duration, aarc.TargetValue.String, aarc.TargetMetric.String, aarc.Target.Float64,
+		aarc.getBaseInfo(maxVUsRange))
+}
+
+// Validate makes sure all options are configured and valid
+func (aarc AdaptiveArrivalRateConfig) Validate() []error {
+	errors := aarc.BaseConfig.Validate()
+	if aarc.Pacing.Valid() {
+		errors = append(errors, errArrivalRatePacing)
+	}
+	if aarc.MinRate.Int64 <= 0 {
+		errors = append(errors, fmt.Errorf("the minRate should be more than 0"))
+	}
+	if !aarc.MaxRate.Valid {
+		errors = append(errors, fmt.Errorf("the maxRate isn't specified"))
+	} else if aarc.MaxRate.Int64 < aarc.MinRate.Int64 {
+		errors = append(errors, fmt.Errorf("the maxRate shouldn't be less than the minRate"))
+	}
+	if !aarc.StartRate.Valid {
+		errors = append(errors, fmt.Errorf("the startRate isn't specified"))
+	} else if aarc.StartRate.Int64 < aarc.MinRate.Int64 || aarc.StartRate.Int64 > aarc.MaxRate.Int64 {
+		errors = append(errors, fmt.Errorf("the startRate should be between the minRate and the maxRate"))
+	}
+
+	if aarc.TimeUnit.TimeDuration() <= 0 {
+		errors = append(errors, fmt.Errorf("the timeUnit should be more than 0"))
+	}
+
+	if !aarc.Duration.Valid {
+		errors = append(errors, fmt.Errorf("the duration is unspecified"))
+	} else if aarc.Duration.TimeDuration() < minDuration {
+		errors = append(errors, fmt.Errorf(
+			"the duration should be at least %s, but is %s", minDuration, aarc.Duration,
+		))
+	}
+
+	if aarc.TargetMetric.String == "" {
+		errors = append(errors, fmt.Errorf("the targetMetric can't be empty"))
+	}
+	if aarc.TargetValue.String == "" {
+		errors = append(errors, fmt.Errorf("the targetValue can't be empty"))
+	}
+	if !aarc.Target.Valid {
+		errors = append(errors, fmt.Errorf("the target isn't specified"))
+	} else if aarc.Target.Float64 <= 0 {
+		errors = append(errors, fmt.Errorf("the target should be more than 0"))
+	}
+	if interval := aarc.Interval.TimeDuration(); interval < time.Second {
+		errors = append(errors, fmt.Errorf("the interval should be at least 1s, but is %s", aarc.Interval))
+	} else if interval > metrics.LiveMetricsRetention {
+		errors = append(errors, fmt.Errorf(
+			"the interval should be at most %s, but is %s", metrics.LiveMetricsRetention, aarc.Interval,
+		))
+	}
+
+	if !aarc.PreAllocatedVUs.Valid {
+		errors = append(errors, fmt.Errorf("the number of preAllocatedVUs isn't specified"))
+	} else if aarc.PreAllocatedVUs.Int64 < 0 {
+		errors = append(errors, fmt.Errorf("the number of preAllocatedVUs shouldn't be negative"))
+	}
+	if aarc.MaxVUs.Valid && aarc.MaxVUs.Int64 < aarc.PreAllocatedVUs.Int64 {
+		errors = append(errors, fmt.Errorf("maxVUs shouldn't be less than preAllocatedVUs"))
+	}
+
+	return errors
+}
+
+// GetExecutionRequirements returns the number of required VUs to run the
+// executor for its whole duration (disregarding any startTime), including the
+// maximum waiting time for any iterations to gracefully stop.
+func (aarc AdaptiveArrivalRateConfig) GetExecutionRequirements(et *lib.ExecutionTuple) []lib.ExecutionStep {
+	return []lib.ExecutionStep{
+		{
+			TimeOffset:      0,
+			PlannedVUs:      uint64(aarc.GetPreAllocatedVUs(et)),
+			MaxUnplannedVUs: uint64(aarc.GetMaxVUs(et) - aarc.GetPreAllocatedVUs(et)),
+		}, {
+			TimeOffset:      aarc.Duration.TimeDuration() + aarc.GracefulStop.TimeDuration(),
+			PlannedVUs:      0,
+			MaxUnplannedVUs: 0,
+		},
+	}
+}
+
+// NewExecutor creates a new AdaptiveArrivalRate executor
+func (aarc AdaptiveArrivalRateConfig) NewExecutor(
+	es *lib.ExecutionState, logger *logrus.Entry,
+) (lib.Executor, error) {
+	return &AdaptiveArrivalRate{
+		BaseExecutor: NewBaseExecutor(aarc, es, logger),
+		config:       aarc,
+	}, nil
+}
+
+// HasWork reports whether there is any work to be done for the given execution segment.
+func (aarc AdaptiveArrivalRateConfig) HasWork(et *lib.ExecutionTuple) bool {
+	return aarc.GetMaxVUs(et) > 0
+}
+
+// The gains of the rate controller, applied to the error of the target value
+// relative to the target, and the most the rate is changed in one interval.
+const (
+	rateControllerKp        = 0.5
+	rateControllerKi        = 0.1
+	rateControllerKd        = 0.2
+	rateControllerMaxChange = 0.5
+)
+
+// rateController is a PID controller of the rate, which holds a value that
+// grows with the rate, like a latency or an error rate, at the target.
+type rateController struct {
+	target, minRate, maxRate float64
+	integral, lastError      float64
+	hasLastError             bool
+}
+
+// next returns the rate for the next interval, from the current rate and the value measured with it.
+func (rc *rateController) next(rate, value float64) float64 {
+	relError := (rc.target - value) / rc.target
+	rc.integral = math.Max(-1, math.Min(1, rc.integral+relError)) // against the windup at the min and max rates
+	var derivative float64
+	if rc.hasLastError {
+		derivative = relError - rc.lastError
+	}
+	rc.lastError, rc.hasLastError = relError, true
+
+	change := rateControllerKp*relError + rateControllerKi*rc.integral + rateControllerKd*derivative
+	change = math.Max(-rateControllerMaxChange, math.Min(rateControllerMaxChange, change))
+	return math.Max(rc.minRate, math.Min(rc.maxRate, rate*(1+change)))
+}
+
+// AdaptiveArrivalRate starts iterations at a rate which it adjusts with a
+// feedback loop on the live value of a metric.
+type AdaptiveArrivalRate struct {
+	*BaseExecutor
+	config AdaptiveArrivalRateConfig
+
+	rate     uint64 // the float64 bits of the current rate, per timeUnit
+	bestRate uint64 // the float64 bits of the highest rate at which the target was held
+}
+
+// Make sure we implement the lib.Executor interface.
+var _ lib.Executor = &AdaptiveArrivalRate{}
+
+// Init makes sure the live metrics the executor adjusts its rate with are available.
+func (aar *AdaptiveArrivalRate) Init(ctx context.Context) error {
+	if aar.executionState.LiveMetrics == nil {
+		return fmt.Errorf("the %s executor needs the live metrics, which aren't available", adaptiveArrivalRateType)
+	}
+	return aar.BaseExecutor.Init(ctx)
+}
+
+// GetRate returns the current iteration rate per timeUnit of the executor.
+func (aar *AdaptiveArrivalRate) GetRate() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&aar.rate))
+}
+
+// GetBestRate returns the highest iteration rate per timeUnit at which the
+// target was held so far, or 0 if it wasn't held at all.
+func (aar *AdaptiveArrivalRate) GetBestRate() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&aar.bestRate))
+}
+
+// adjustRate queries the target value over the last interval and updates the rate with the controller.
+func (aar *AdaptiveArrivalRate) adjustRate(controller *rateController) {
+	live := aar.executionState.LiveMetrics
+	values, ok := live.Query(aar.config.TargetMetric.String, aar.config.Interval.TimeDuration())
+	if !ok {
+		return // no samples, so nothing to adjust the rate to
+	}
+	value, ok := values[aar.config.TargetValue.String]
+	if !ok {
+		aar.logger.Warnf("The metric %s has no %s value", aar.config.TargetMetric.String, aar.config.TargetValue.String)
+		return
+	}
+
+	rate := aar.GetRate()
+	if value <= controller.target && rate > aar.GetBestRate() {
+		atomic.StoreUint64(&aar.bestRate, math.Float64bits(rate))
+	}
+	newRate := controller.next(rate, value)
+	atomic.StoreUint64(&aar.rate, math.Float64bits(newRate))
+	aar.logger.WithFields(logrus.Fields{
+		"value": value, "rate": rate, "newRate": newRate,
+	}).Debug("Adjusted the iteration rate")
+}
+
+// Run starts the iterations at the current rate, and adjusts it on every interval.
+//nolint:funlen
+func (aar *AdaptiveArrivalRate) Run(
+	parentCtx context.Context, out chan<- stats.SampleContainer, builtinMetrics *metrics.BuiltinMetrics,
+) (err error) {
+	et := aar.executionState.ExecutionTuple
+	gracefulStop := aar.config.GetGracefulStop()
+	duration := aar.config.Duration.TimeDuration()
+	preAllocatedVUs := aar.config.GetPreAllocatedVUs(et)
+	maxVUs := aar.config.GetMaxVUs(et)
+	timeUnit := aar.config.TimeUnit.TimeDuration()
+	segmentLength := et.Segment.FloatLength()
+
+	controller := &rateController{
+		target:  aar.config.Target.Float64,
+		minRate: float64(aar.config.MinRate.Int64),
+		maxRate: float64(aar.config.MaxRate.Int64),
+	}
+	atomic.StoreUint64(&aar.rate, math.Float64bits(float64(aar.config.StartRate.Int64)))
+
+	aar.logger.WithFields(logrus.Fields{
+		"maxVUs": maxVUs, "preAllocatedVUs": preAllocatedVUs, "duration": duration,
+		"type": aar.config.GetType(),
+	}).Debug("Starting executor run...")
+
+	activeVUsWg := &sync.WaitGroup{}
+
+	returnedVUs := make(chan struct{})
+	startTime, maxDurationCtx, regDurationCtx, cancel := getDurationContexts(parentCtx, duration, gracefulStop)
+
+	vusPool := newActiveVUPool()
+	defer func() {
+		<-returnedVUs
+		vusPool.Close()
+		cancel()
+		activeVUsWg.Wait()
+
+		bestRatePerSec := aar.config.getRatePerSec(et, aar.GetBestRate())
+		aar.logger.Infof("The highest iteration rate which held the target was %.2f iterations/s", bestRatePerSec)
+	}()
+	activeVUsCount := uint64(0)
+
+	vusFmt := pb.GetFixedLengthIntFormat(maxVUs)
+	maxRatePerSec := aar.config.getRatePerSec(et, controller.maxRate)
+	itersFmt := pb.GetFixedLengthFloatFormat(maxRatePerSec, 2) + " iters/s"
+	progressFn := func() (float64, []string) {
+		spent := time.Since(startTime)
+		currActiveVUs := atomic.LoadUint64(&activeVUsCount)
+		progVUs := fmt.Sprintf(vusFmt+"/"+vusFmt+" VUs", vusPool.Running(), currActiveVUs)
+		progIters := fmt.Sprintf(itersFmt, aar.config.getRatePerSec(et, aar.GetRate()))
+
+		right := []string{progVUs, duration.String(), progIters}
+		if spent > duration {
+			return 1, right
+		}
+
+		spentDuration := pb.GetFixedLengthDuration(spent, duration)
+		right[1] = fmt.Sprintf("%s/%s", spentDuration, duration)
+
+		return math.Min(1, float64(spent)/float64(duration)), right
+	}
+	aar.progress.Modify(pb.WithProgress(progressFn))
+	go trackProgress(parentCtx, maxDurationCtx, regDurationCtx, aar, progressFn)
+
+	maxDurationCtx = lib.WithScenarioState(maxDurationCtx, &lib.ScenarioState{
+		Name:       aar.config.Name,
+		Executor:   aar.config.Type,
+		StartTime:  startTime,
+		ProgressFn: progressFn,
+	})
+
+	returnVU := func(u lib.InitializedVU) {
+		aar.executionState.ReturnVU(u, true)
+		activeVUsWg.Done()
+	}
+
+	runIterationBasic := getIterationRunner(aar.executionState, aar.logger)
+	activateVU := func(initVU lib.InitializedVU) lib.ActiveVU {
+		activeVUsWg.Add(1)
+		activeVU := initVU.Activate(getVUActivationParams(
+			maxDurationCtx, aar.config.BaseConfig, returnVU,
+			aar.nextIterationCounters,
+		))
+		aar.executionState.ModCurrentlyActiveVUsCount(+1)
+		atomic.AddUint64(&activeVUsCount, 1)
+		vusPool.AddVU(maxDurationCtx, activeVU, runIterationBasic)
+		return activeVU
+	}
+
+	remainingUnplannedVUs := maxVUs - preAllocatedVUs
+	makeUnplannedVUCh := make(chan struct{})
+	defer close(makeUnplannedVUCh)
+	go func() {
+		defer close(returnedVUs)
+		for range makeUnplannedVUCh {
+			aar.logger.Debug("Starting initialization of an unplanned VU...")
+			initVU, err := aar.executionState.GetUnplannedVU(maxDurationCtx, aar.logger)
+			if err != nil {
+				aar.logger.WithError(err).Error("Error while allocating unplanned VU")
+			} else {
+				aar.logger.Debug("The unplanned VU finished initializing successfully!")
+				activateVU(initVU)
+			}
+		}
+	}()
+
+	for i := int64(0); i < preAllocatedVUs; i++ {
+		initVU, err := aar.executionState.GetPlannedVU(aar.logger, false)
+		if err != nil {
+			return err
+		}
+		activateVU(initVU)
+	}
+
+	adjustTicker := time.NewTicker(aar.config.Interval.TimeDuration())
+	defer adjustTicker.Stop()
+
+	droppedIterationMetric := builtinMetrics.DroppedIterations
+	shownWarning := false
+	metricTags := aar.getMetricTags(nil)
+	nextIteration := startTime
+	timer := time.NewTimer(0)
+	for {
+		select {
+		case <-timer.C:
+			period := time.Duration(float64(timeUnit) / (aar.GetRate() * segmentLength))
+			nextIteration = nextIteration.Add(period)
+			timer.Reset(time.Until(nextIteration))
+
+			if aar.executionState.IsScenarioPaused(aar.config.Name) {
+				continue // the iterations of paused scenarios are skipped, not dropped
+			}
+			if vusPool.TryRunIteration() {
+				continue
+			}
+
+			stats.PushIfNotDone(parentCtx, out, stats.Sample{
+				Value: 1, Metric: droppedIterationMetric,
+				Tags: metricTags, Time: time.Now(),
+			})
+
+			if remainingUnplannedVUs == 0 {
+				if !shownWarning {
+					aar.logger.Warningf("Insufficient VUs, reached %d active VUs and cannot initialize more", maxVUs)
+					shownWarning = true
+				}
+				continue
+			}
+
+			select {
+			case makeUnplannedVUCh <- struct{}{}:
+				remainingUnplannedVUs--
+			default:
+			}
+
+		case <-adjustTicker.C:
+			aar.adjustRate(controller)
+
+		case <-regDurationCtx.Done():
+			timer.Stop()
+			return nil
+		}
+	}
+}
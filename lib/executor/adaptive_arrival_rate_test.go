@@ -0,0 +1,116 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package executor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/stats"
+)
+
+func TestRateController(t *testing.T) {
+	t.Parallel()
+
+	controller := &rateController{target: 200, minRate: 1, maxRate: 100}
+	rate := 5.0
+	for i := 0; i < 30; i++ {
+		rate = controller.next(rate, 10*rate) // a latency of 10ms per iteration/s
+		assert.True(t, rate >= 1 && rate <= 100, "rate %f out of bounds", rate)
+	}
+	assert.InDelta(t, 20, rate, 1)
+
+	// the rate is capped at the max, and the integral doesn't wind up there
+	controller = &rateController{target: 200, minRate: 1, maxRate: 10}
+	rate = 5
+	for i := 0; i < 30; i++ {
+		rate = controller.next(rate, 10*rate)
+	}
+	assert.Equal(t, 10.0, rate)
+	assert.Equal(t, 1.0, controller.integral)
+
+	// a value way over the target halves the rate at most
+	controller = &rateController{target: 200, minRate: 1, maxRate: 100}
+	assert.Equal(t, 25.0, controller.next(50, 2000))
+}
+
+func TestAdaptiveArrivalRateRun(t *testing.T) {
+	t.Parallel()
+
+	config := NewAdaptiveArrivalRateConfig("test")
+	config.GracefulStop = types.NullDurationFrom(0)
+	config.StartRate = null.IntFrom(5)
+	config.MaxRate = null.IntFrom(100)
+	config.Duration = types.NullDurationFrom(6 * time.Second)
+	config.TargetMetric = null.StringFrom("latency")
+	config.TargetValue = null.StringFrom("avg")
+	config.Target = null.FloatFrom(200)
+	config.Interval = types.NullDurationFrom(time.Second)
+	config.PreAllocatedVUs = null.IntFrom(10)
+	require.Empty(t, config.Validate())
+
+	et, err := lib.NewExecutionTuple(nil, nil)
+	require.NoError(t, err)
+	es := lib.NewExecutionState(lib.Options{}, et, 10, 10)
+	es.LiveMetrics = metrics.NewLiveMetrics()
+	latency := stats.New("latency", stats.Trend, stats.Time)
+
+	var executor *AdaptiveArrivalRate
+	ctx, cancel, exec, logHook := setupExecutor(t, config, es,
+		simpleRunner(func(ctx context.Context, _ *lib.State) error {
+			es.LiveMetrics.Add([]stats.SampleContainer{stats.Sample{
+				Metric: latency, Time: time.Now(), Value: 10 * executor.GetRate(),
+			}})
+			return nil
+		}),
+	)
+	defer cancel()
+	executor = exec.(*AdaptiveArrivalRate)
+
+	registry := metrics.NewRegistry()
+	builtinMetrics := metrics.RegisterBuiltinMetrics(registry)
+	require.NoError(t, exec.Run(ctx, make(chan stats.SampleContainer, 1000), builtinMetrics))
+	require.Empty(t, logHook.Drain())
+
+	assert.InDelta(t, 20, executor.GetRate(), 8)
+	assert.Greater(t, executor.GetBestRate(), 5.0)
+	assert.Less(t, executor.GetBestRate(), 28.0) // the values of a window lag a bit behind the rate
+}
+
+func TestAdaptiveArrivalRateInitWithoutLiveMetrics(t *testing.T) {
+	t.Parallel()
+
+	et, err := lib.NewExecutionTuple(nil, nil)
+	require.NoError(t, err)
+	es := lib.NewExecutionState(lib.Options{}, et, 1, 1)
+	exec, err := NewAdaptiveArrivalRateConfig("test").NewExecutor(es, nil)
+	require.NoError(t, err)
+	assert.EqualError(t, exec.Init(context.Background()),
+		"the adaptive-arrival-rate executor needs the live metrics, which aren't available")
+}
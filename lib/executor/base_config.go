@@ -21,8 +21,11 @@
 package executor
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
@@ -37,6 +40,11 @@ import (
 // TODO?: Discard? Or make this actually user-configurable somehow? hello #883...
 var DefaultGracefulStopValue = 30 * time.Second //nolint:gochecknoglobals
 
+// DefaultCriticalStopValue is how long the critical sections of iterations can
+// still run after the VUs are stopped, unless it's changed by the criticalStop
+// in each executor.
+var DefaultCriticalStopValue = 30 * time.Second //nolint:gochecknoglobals
+
 var executorNameWhitelist = regexp.MustCompile(`^[0-9a-zA-Z_-]+$`) //nolint:gochecknoglobals
 const executorNameErr = "the executor name should contain only numbers, latin letters, underscores, and dashes"
 
@@ -46,10 +54,38 @@ type BaseConfig struct {
 	Type         string             `json:"executor"`
 	StartTime    types.NullDuration `json:"startTime"`
 	GracefulStop types.NullDuration `json:"gracefulStop"`
+	CriticalStop types.NullDuration `json:"criticalStop"`
 	Env          map[string]string  `json:"env"`
-	Exec         null.String        `json:"exec"` // function name, externally validated
+	Exec         ExecFunctions      `json:"exec"` // function names, externally validated
 	Tags         map[string]string  `json:"tags"`
 
+	// StartAfter are the scenarios after whose end this one starts, its startTime is then counted from it
+	StartAfter []string `json:"startAfter"`
+	// StartAfterSuccess marks that this scenario is skipped unless all the iterations of the StartAfter
+	// scenarios completed without errors or interruptions
+	StartAfterSuccess null.Bool `json:"startAfterSuccess"`
+
+	// StartAt and StartCron schedule the start of the scenario at a wall-clock time, either a time of
+	// day like 02:00 or a timestamp, or at the first time matching a cron expression after the start of
+	// the test, instead of at a startTime from it
+	StartAt   null.String `json:"startAt"`
+	StartCron null.String `json:"startCron"`
+
+	// Setup and Teardown are the names of the exported functions which are run
+	// before and after the scenario, with the setupTimeout and teardownTimeout
+	// options as their default timeouts
+	Setup           null.String        `json:"setup"`
+	SetupTimeout    types.NullDuration `json:"setupTimeout"`
+	Teardown        null.String        `json:"teardown"`
+	TeardownTimeout types.NullDuration `json:"teardownTimeout"`
+
+	// WarmupDuration is how long after the start of the scenario its iterations
+	// are tagged with warmup=true and excluded from the thresholds and summary
+	WarmupDuration types.NullDuration `json:"warmupDuration"`
+
+	// Pacing is the delay each VU waits after every iteration, outside of its iteration_duration
+	Pacing Pacing `json:"pacing"`
+
 	// TODO: future extensions like distribution, others?
 }
 
@@ -59,6 +95,7 @@ func NewBaseConfig(name, configType string) BaseConfig {
 		Name:         name,
 		Type:         configType,
 		GracefulStop: types.NewNullDuration(DefaultGracefulStopValue, false),
+		CriticalStop: types.NewNullDuration(DefaultCriticalStopValue, false),
 	}
 }
 
@@ -72,9 +109,20 @@ func (bc BaseConfig) Validate() (errors []error) {
 	if !executorNameWhitelist.MatchString(bc.Name) {
 		errors = append(errors, fmt.Errorf(executorNameErr))
 	}
-	if bc.Exec.Valid && bc.Exec.String == "" {
+	if bc.Exec.Name.Valid && bc.Exec.Name.String == "" {
 		errors = append(errors, fmt.Errorf("exec value cannot be empty"))
 	}
+	if bc.Exec.Weights != nil && len(bc.Exec.Weights) == 0 {
+		errors = append(errors, fmt.Errorf("exec should have the weight of at least one function"))
+	}
+	for name, weight := range bc.Exec.Weights {
+		if name == "" {
+			errors = append(errors, fmt.Errorf("exec function names cannot be empty"))
+		}
+		if weight <= 0 {
+			errors = append(errors, fmt.Errorf("the exec weight of %s should be more than 0", name))
+		}
+	}
 	if bc.Type == "" {
 		errors = append(errors, fmt.Errorf("missing or empty type field"))
 	}
@@ -85,6 +133,31 @@ func (bc BaseConfig) Validate() (errors []error) {
 	if bc.GracefulStop.Duration < 0 {
 		errors = append(errors, fmt.Errorf("the gracefulStop timeout can't be negative"))
 	}
+	if bc.CriticalStop.Duration < 0 {
+		errors = append(errors, fmt.Errorf("the criticalStop timeout can't be negative"))
+	}
+	if bc.WarmupDuration.Duration < 0 {
+		errors = append(errors, fmt.Errorf("the warmupDuration can't be negative"))
+	}
+	errors = append(errors, bc.Pacing.Validate()...)
+	if bc.StartAt.Valid || bc.StartCron.Valid {
+		errors = append(errors, bc.validateSchedule()...)
+	}
+	if bc.StartAfterSuccess.Bool && len(bc.StartAfter) == 0 {
+		errors = append(errors, fmt.Errorf("startAfterSuccess needs the scenarios of startAfter"))
+	}
+	if bc.Setup.Valid && bc.Setup.String == "" {
+		errors = append(errors, fmt.Errorf("setup value cannot be empty"))
+	}
+	if bc.SetupTimeout.Valid && bc.SetupTimeout.Duration <= 0 {
+		errors = append(errors, fmt.Errorf("the setupTimeout should be more than 0"))
+	}
+	if bc.Teardown.Valid && bc.Teardown.String == "" {
+		errors = append(errors, fmt.Errorf("teardown value cannot be empty"))
+	}
+	if bc.TeardownTimeout.Valid && bc.TeardownTimeout.Duration <= 0 {
+		errors = append(errors, fmt.Errorf("the teardownTimeout should be more than 0"))
+	}
 	return errors
 }
 
@@ -104,6 +177,64 @@ func (bc BaseConfig) GetStartTime() time.Duration {
 	return bc.StartTime.TimeDuration()
 }
 
+// GetScheduledStart returns the wall-clock time of the start of the executor,
+// if it's scheduled with startAt or startCron, for a test started at the given
+// time.
+func (bc BaseConfig) GetScheduledStart(testStart time.Time) (time.Time, bool) {
+	switch {
+	case bc.StartAt.Valid:
+		at, err := parseStartAt(bc.StartAt.String, testStart)
+		return at, err == nil
+	case bc.StartCron.Valid:
+		schedule, err := parseCronSchedule(bc.StartCron.String)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return schedule.next(testStart), true
+	default:
+		return time.Time{}, false
+	}
+}
+
+func (bc BaseConfig) validateSchedule() (errors []error) {
+	if bc.StartAt.Valid && bc.StartCron.Valid {
+		errors = append(errors, fmt.Errorf("startAt and startCron can't be used together"))
+	}
+	if bc.StartTime.Valid || len(bc.StartAfter) > 0 {
+		errors = append(errors, fmt.Errorf("startAt and startCron can't be used with startTime or startAfter"))
+	}
+	if bc.StartAt.Valid {
+		if _, err := parseStartAt(bc.StartAt.String, time.Now()); err != nil {
+			errors = append(errors, err)
+		}
+	}
+	if bc.StartCron.Valid {
+		if _, err := parseCronSchedule(bc.StartCron.String); err != nil {
+			errors = append(errors, err)
+		}
+	}
+	return errors
+}
+
+// GetStartAfter returns the names of the scenarios after whose end the executor starts, and whether
+// their iterations need to have completed successfully for it to start at all.
+func (bc BaseConfig) GetStartAfter() ([]string, bool) {
+	return bc.StartAfter, bc.StartAfterSuccess.Bool
+}
+
+// GetSetup returns the name of the function which is run before the scenario,
+// if there is one, and its timeout, which is 0 if the default one is used.
+func (bc BaseConfig) GetSetup() (string, time.Duration) {
+	return bc.Setup.String, bc.SetupTimeout.TimeDuration()
+}
+
+// GetTeardown returns the name of the function which is run after the
+// scenario, if there is one, and its timeout, which is 0 if the default one
+// is used.
+func (bc BaseConfig) GetTeardown() (string, time.Duration) {
+	return bc.Teardown.String, bc.TeardownTimeout.TimeDuration()
+}
+
 // GetGracefulStop returns how long k6 is supposed to wait for any still
 // running iterations to finish executing at the end of the normal executor
 // duration, before it actually kills them.
@@ -114,6 +245,25 @@ func (bc BaseConfig) GetGracefulStop() time.Duration {
 	return bc.GracefulStop.TimeDuration()
 }
 
+// GetWarmupDuration returns how long after the start of the scenario the
+// samples of its iterations are excluded from the thresholds and summary.
+func (bc BaseConfig) GetWarmupDuration() time.Duration {
+	return bc.WarmupDuration.TimeDuration()
+}
+
+// GetPacing returns the delay each VU waits after every iteration before it
+// starts the next one.
+func (bc BaseConfig) GetPacing() Pacing {
+	return bc.Pacing
+}
+
+// GetCriticalStop returns how long the critical sections of iterations, which
+// aren't interrupted at the end of the gracefulStop or when the test is
+// stopped, can still run after that, before they are interrupted too.
+func (bc BaseConfig) GetCriticalStop() time.Duration {
+	return bc.CriticalStop.TimeDuration()
+}
+
 // GetEnv returns any specific environment key=value pairs that
 // are configured for the executor.
 func (bc BaseConfig) GetEnv() map[string]string {
@@ -122,13 +272,19 @@ func (bc BaseConfig) GetEnv() map[string]string {
 
 // GetExec returns the configured custom exec value, if any.
 func (bc BaseConfig) GetExec() string {
-	exec := bc.Exec.ValueOrZero()
+	exec := bc.Exec.Name.ValueOrZero()
 	if exec == "" {
 		exec = consts.DefaultFn
 	}
 	return exec
 }
 
+// GetExecWeights returns the weights of the functions which the iterations
+// are spread over, if multiple ones are configured.
+func (bc BaseConfig) GetExecWeights() map[string]int64 {
+	return bc.Exec.Weights
+}
+
 // GetTags returns any custom tags configured for the executor.
 func (bc BaseConfig) GetTags() map[string]string {
 	return bc.Tags
@@ -142,17 +298,89 @@ func (bc BaseConfig) IsDistributable() bool {
 
 // getBaseInfo is a helper method for the "parent" String methods.
 func (bc BaseConfig) getBaseInfo(facts ...string) string {
-	if bc.Exec.Valid {
-		facts = append(facts, fmt.Sprintf("exec: %s", bc.Exec.String))
+	if bc.Exec.Valid() {
+		facts = append(facts, fmt.Sprintf("exec: %s", bc.Exec))
+	}
+	if len(bc.StartAfter) > 0 {
+		facts = append(facts, fmt.Sprintf("startAfter: %s", strings.Join(bc.StartAfter, ", ")))
 	}
 	if bc.StartTime.Duration > 0 {
 		facts = append(facts, fmt.Sprintf("startTime: %s", bc.StartTime.Duration))
 	}
+	if bc.StartAt.Valid {
+		facts = append(facts, fmt.Sprintf("startAt: %s", bc.StartAt.String))
+	}
+	if bc.StartCron.Valid {
+		facts = append(facts, fmt.Sprintf("startCron: %s", bc.StartCron.String))
+	}
 	if bc.GracefulStop.Duration > 0 {
 		facts = append(facts, fmt.Sprintf("gracefulStop: %s", bc.GracefulStop.Duration))
 	}
+	if bc.CriticalStop.Valid {
+		facts = append(facts, fmt.Sprintf("criticalStop: %s", bc.CriticalStop.Duration))
+	}
+	if bc.WarmupDuration.Duration > 0 {
+		facts = append(facts, fmt.Sprintf("warmupDuration: %s", bc.WarmupDuration.Duration))
+	}
+	if bc.Pacing.Valid() {
+		facts = append(facts, fmt.Sprintf("pacing: %s", bc.Pacing))
+	}
 	if len(facts) == 0 {
 		return ""
 	}
 	return " (" + strings.Join(facts, ", ") + ")"
 }
+
+// ExecFunctions is the exec option of a scenario, which is either the name of
+// the exported function its iterations run, or the weights of the functions
+// the iterations are spread over, e.g. {"browse": 70, "checkout": 30}.
+type ExecFunctions struct {
+	Name    null.String
+	Weights map[string]int64
+}
+
+// Valid returns whether any exec function is configured.
+func (ef ExecFunctions) Valid() bool {
+	return ef.Name.Valid || len(ef.Weights) > 0
+}
+
+// String returns the name of the function, or the weights of the functions
+// sorted by their names.
+func (ef ExecFunctions) String() string {
+	if len(ef.Weights) == 0 {
+		return ef.Name.String
+	}
+	names := make([]string, 0, len(ef.Weights))
+	for name := range ef.Weights {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		names[i] = fmt.Sprintf("%s=%d", name, ef.Weights[name])
+	}
+	return strings.Join(names, ", ")
+}
+
+// UnmarshalJSON accepts either the name of a function or an object with the
+// weights of the functions.
+func (ef *ExecFunctions) UnmarshalJSON(data []byte) error {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		*ef = ExecFunctions{}
+		return json.Unmarshal(trimmed, &ef.Weights)
+	}
+	var name null.String
+	if err := json.Unmarshal(data, &name); err != nil {
+		return fmt.Errorf("exec should be a function name or an object with the weights of functions: %w", err)
+	}
+	*ef = ExecFunctions{Name: name}
+	return nil
+}
+
+// MarshalJSON returns the weights as an object if there are any, and the name
+// of the function otherwise.
+func (ef ExecFunctions) MarshalJSON() ([]byte, error) {
+	if len(ef.Weights) > 0 {
+		return json.Marshal(ef.Weights)
+	}
+	return json.Marshal(ef.Name)
+}
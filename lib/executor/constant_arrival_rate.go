@@ -111,6 +111,9 @@ func (carc ConstantArrivalRateConfig) GetDescription(et *lib.ExecutionTuple) str
 // Validate makes sure all options are configured and valid
 func (carc *ConstantArrivalRateConfig) Validate() []error {
 	errors := carc.BaseConfig.Validate()
+	if carc.Pacing.Valid() {
+		errors = append(errors, errArrivalRatePacing)
+	}
 	if !carc.Rate.Valid {
 		errors = append(errors, fmt.Errorf("the iteration rate isn't specified"))
 	} else if carc.Rate.Int64 <= 0 {
@@ -340,6 +343,9 @@ func (car ConstantArrivalRate) Run(
 		timer.Reset(t)
 		select {
 		case <-timer.C:
+			if car.executionState.IsScenarioPaused(car.config.Name) {
+				continue // the iterations of paused scenarios are skipped, not dropped
+			}
 			if vusPool.TryRunIteration() {
 				continue
 			}
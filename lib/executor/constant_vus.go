@@ -177,7 +177,7 @@ func (clv ConstantVUs) Run(
 	defer activeVUs.Wait()
 
 	regDurationDone := regDurationCtx.Done()
-	runIteration := getIterationRunner(clv.executionState, clv.logger)
+	runIteration := getLoopingIterationRunner(clv.executionState, clv.logger, clv.config.GetPacing(), regDurationDone)
 
 	maxDurationCtx = lib.WithScenarioState(maxDurationCtx, &lib.ScenarioState{
 		Name:       clv.config.Name,
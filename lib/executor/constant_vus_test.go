@@ -23,6 +23,7 @@ package executor
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -75,3 +76,39 @@ func TestConstantVUsRun(t *testing.T) {
 	})
 	assert.Equal(t, uint64(50), totalIters)
 }
+
+func TestConstantVUsRunPausedScenario(t *testing.T) {
+	t.Parallel()
+	var iterations int64
+	config := getTestConstantVUsConfig()
+	config.Name = "paused"
+	config.VUs = null.IntFrom(2)
+	et, err := lib.NewExecutionTuple(nil, nil)
+	require.NoError(t, err)
+	es := lib.NewExecutionState(lib.Options{Scenarios: lib.ScenarioConfigs{"paused": config}}, et, 2, 2)
+	ctx, cancel, executor, _ := setupExecutor(
+		t, config, es,
+		simpleRunner(func(ctx context.Context, state *lib.State) error {
+			atomic.AddInt64(&iterations, 1)
+			time.Sleep(20 * time.Millisecond)
+			return nil
+		}),
+	)
+	defer cancel()
+	require.NoError(t, es.PauseScenario("paused"))
+
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		assert.Equal(t, int64(0), atomic.LoadInt64(&iterations))
+		assert.NoError(t, es.ResumeScenario("paused"))
+		time.Sleep(300 * time.Millisecond)
+		assert.NotZero(t, atomic.LoadInt64(&iterations))
+		assert.NoError(t, es.PauseScenario("paused"))
+	}()
+	start := time.Now()
+	err = executor.Run(ctx, nil, nil)
+	require.NoError(t, err)
+	// the paused VUs stop waiting for the scenario to be resumed at its end
+	assert.Less(t, time.Since(start), 1100*time.Millisecond)
+	assert.True(t, es.IsScenarioPaused("paused"))
+}
@@ -0,0 +1,125 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package executor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/types"
+)
+
+const curveArrivalRateType = "curve-arrival-rate"
+
+func init() {
+	lib.RegisterExecutorConfigType(
+		curveArrivalRateType,
+		func(name string, rawJSON []byte) (lib.ExecutorConfig, error) {
+			config := NewCurveArrivalRateConfig(name)
+			err := lib.StrictJSONUnmarshal(rawJSON, &config)
+			return config, err
+		},
+	)
+}
+
+// CurveArrivalRateConfig stores the configuration for the curve-arrival-rate executor, which starts
+// the iterations at the rate of its curve, like the ramping-arrival-rate one with a stage between every
+// two points.
+type CurveArrivalRateConfig struct {
+	BaseConfig
+	LoadCurve
+	TimeUnit types.NullDuration `json:"timeUnit"`
+
+	PreAllocatedVUs null.Int `json:"preAllocatedVUs"`
+	MaxVUs          null.Int `json:"maxVUs"`
+}
+
+// NewCurveArrivalRateConfig returns a CurveArrivalRateConfig with default values
+func NewCurveArrivalRateConfig(name string) CurveArrivalRateConfig {
+	return CurveArrivalRateConfig{
+		BaseConfig: NewBaseConfig(name, curveArrivalRateType),
+		TimeUnit:   types.NewNullDuration(1*time.Second, false),
+	}
+}
+
+// Make sure we implement the lib.ExecutorConfig interface
+var _ lib.ExecutorConfig = &CurveArrivalRateConfig{}
+
+// getRampingArrivalRateConfig returns the equivalent configuration of the ramping-arrival-rate executor.
+func (carc CurveArrivalRateConfig) getRampingArrivalRateConfig() *RampingArrivalRateConfig {
+	startRate, stages := carc.getStages()
+	maxVUs := carc.MaxVUs
+	if !maxVUs.Valid {
+		maxVUs.Int64 = carc.PreAllocatedVUs.Int64
+	}
+	return &RampingArrivalRateConfig{
+		BaseConfig:      carc.BaseConfig,
+		StartRate:       null.IntFrom(startRate),
+		TimeUnit:        carc.TimeUnit,
+		Stages:          stages,
+		PreAllocatedVUs: carc.PreAllocatedVUs,
+		MaxVUs:          maxVUs,
+	}
+}
+
+// GetDescription returns a human-readable description of the executor options
+func (carc CurveArrivalRateConfig) GetDescription(et *lib.ExecutionTuple) string {
+	varc := carc.getRampingArrivalRateConfig()
+	maxVUsRange := fmt.Sprintf("maxVUs: %d", et.Segment.Scale(varc.PreAllocatedVUs.Int64))
+	if varc.MaxVUs.Int64 > varc.PreAllocatedVUs.Int64 {
+		maxVUsRange += fmt.Sprintf("-%d", et.Segment.Scale(varc.MaxVUs.Int64))
+	}
+	maxUnscaledRate := getStagesUnscaledMaxTarget(varc.StartRate.Int64, varc.Stages)
+	maxArrRatePerSec, _ := getArrivalRatePerSec(
+		getScaledArrivalRate(et.Segment, maxUnscaledRate, varc.TimeUnit.TimeDuration()),
+	).Float64()
+
+	return fmt.Sprintf("Up to %.2f iterations/s for %s over a curve of %d points%s",
+		maxArrRatePerSec, sumStagesDuration(varc.Stages), len(varc.Stages)+1, carc.getBaseInfo(maxVUsRange))
+}
+
+// Validate makes sure all options are configured and valid
+func (carc CurveArrivalRateConfig) Validate() []error {
+	if errors := carc.LoadCurve.Validate(); len(errors) > 0 {
+		return append(carc.BaseConfig.Validate(), errors...)
+	}
+	return carc.getRampingArrivalRateConfig().Validate()
+}
+
+// GetExecutionRequirements returns the number of required VUs to run the executor for its whole
+// duration, which are the ones of the equivalent ramping-arrival-rate executor.
+func (carc CurveArrivalRateConfig) GetExecutionRequirements(et *lib.ExecutionTuple) []lib.ExecutionStep {
+	return carc.getRampingArrivalRateConfig().GetExecutionRequirements(et)
+}
+
+// NewExecutor creates a new RampingArrivalRate executor for the curve
+func (carc CurveArrivalRateConfig) NewExecutor(es *lib.ExecutionState, logger *logrus.Entry) (lib.Executor, error) {
+	return carc.getRampingArrivalRateConfig().NewExecutor(es, logger)
+}
+
+// HasWork reports whether there is any work to be done for the given execution segment.
+func (carc CurveArrivalRateConfig) HasWork(et *lib.ExecutionTuple) bool {
+	return carc.getRampingArrivalRateConfig().HasWork(et)
+}
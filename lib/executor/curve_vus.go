@@ -0,0 +1,108 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package executor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/types"
+)
+
+const curveVUsType = "curve-vus"
+
+func init() {
+	lib.RegisterExecutorConfigType(
+		curveVUsType,
+		func(name string, rawJSON []byte) (lib.ExecutorConfig, error) {
+			config := NewCurveVUsConfig(name)
+			err := lib.StrictJSONUnmarshal(rawJSON, &config)
+			return config, err
+		},
+	)
+}
+
+// CurveVUsConfig stores the configuration for the curve-vus executor, which loops iterations with
+// the number of VUs of its curve, like the ramping-vus one with a stage between every two points.
+type CurveVUsConfig struct {
+	BaseConfig
+	LoadCurve
+	GracefulRampDown types.NullDuration `json:"gracefulRampDown"`
+}
+
+// NewCurveVUsConfig returns a CurveVUsConfig with its default values
+func NewCurveVUsConfig(name string) CurveVUsConfig {
+	return CurveVUsConfig{
+		BaseConfig:       NewBaseConfig(name, curveVUsType),
+		GracefulRampDown: types.NewNullDuration(30*time.Second, false),
+	}
+}
+
+// Make sure we implement the lib.ExecutorConfig interface
+var _ lib.ExecutorConfig = &CurveVUsConfig{}
+
+// getRampingVUsConfig returns the equivalent configuration of the ramping-vus executor.
+func (cvc CurveVUsConfig) getRampingVUsConfig() RampingVUsConfig {
+	startVUs, stages := cvc.getStages()
+	return RampingVUsConfig{
+		BaseConfig:       cvc.BaseConfig,
+		StartVUs:         null.IntFrom(startVUs),
+		Stages:           stages,
+		GracefulRampDown: cvc.GracefulRampDown,
+	}
+}
+
+// GetDescription returns a human-readable description of the executor options
+func (cvc CurveVUsConfig) GetDescription(et *lib.ExecutionTuple) string {
+	startVUs, stages := cvc.getStages()
+	maxVUs := et.ScaleInt64(getStagesUnscaledMaxTarget(startVUs, stages))
+	return fmt.Sprintf("Up to %d looping VUs for %s over a curve of %d points%s",
+		maxVUs, sumStagesDuration(stages), len(stages)+1,
+		cvc.getBaseInfo(fmt.Sprintf("gracefulRampDown: %s", cvc.GracefulRampDown.TimeDuration())))
+}
+
+// Validate makes sure all options are configured and valid
+func (cvc CurveVUsConfig) Validate() []error {
+	if errors := cvc.LoadCurve.Validate(); len(errors) > 0 {
+		return append(cvc.BaseConfig.Validate(), errors...)
+	}
+	return cvc.getRampingVUsConfig().Validate()
+}
+
+// GetExecutionRequirements returns the number of required VUs to run the executor for its whole
+// duration, which are the ones of the equivalent ramping-vus executor.
+func (cvc CurveVUsConfig) GetExecutionRequirements(et *lib.ExecutionTuple) []lib.ExecutionStep {
+	return cvc.getRampingVUsConfig().GetExecutionRequirements(et)
+}
+
+// NewExecutor creates a new RampingVUs executor for the curve
+func (cvc CurveVUsConfig) NewExecutor(es *lib.ExecutionState, logger *logrus.Entry) (lib.Executor, error) {
+	return cvc.getRampingVUsConfig().NewExecutor(es, logger)
+}
+
+// HasWork reports whether there is any work to be done for the given execution segment.
+func (cvc CurveVUsConfig) HasWork(et *lib.ExecutionTuple) bool {
+	return cvc.getRampingVUsConfig().HasWork(et)
+}
@@ -74,7 +74,7 @@ var configMapTestCases = []configMapTestCase{
 			sched.Duration = types.NullDurationFrom(1 * time.Minute)
 			sched.GracefulStop = types.NullDurationFrom(10 * time.Second)
 			sched.StartTime = types.NullDurationFrom(70 * time.Second)
-			sched.Exec = null.StringFrom("someFunc")
+			sched.Exec = ExecFunctions{Name: null.StringFrom("someFunc")}
 			sched.Env = map[string]string{"test": "mest"}
 			require.Equal(t, cm, lib.ScenarioConfigs{"someKey": sched})
 			require.Equal(t, sched.BaseConfig.Name, cm["someKey"].GetName())
@@ -118,6 +118,101 @@ var configMapTestCases = []configMapTestCase{
 	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "0s"}}`, exp{validationError: true}},
 	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "startTime": "-10s"}}`, exp{validationError: true}},
 	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "exec": ""}}`, exp{validationError: true}},
+	{
+		`{"someKey": {"executor": "constant-vus", "vus": 10, "duration": "60s", "exec": {"browse": 70, "checkout": 30}}}`,
+		exp{custom: func(t *testing.T, cm lib.ScenarioConfigs) {
+			assert.Equal(t, map[string]int64{"browse": 70, "checkout": 30}, cm["someKey"].GetExecWeights())
+			et, err := lib.NewExecutionTuple(nil, nil)
+			require.NoError(t, err)
+			assert.Equal(t, "10 looping VUs for 1m0s (exec: browse=70, checkout=30, gracefulStop: 30s)",
+				cm["someKey"].GetDescription(et))
+		}},
+	},
+	{
+		`{"someKey": {"executor": "constant-vus", "vus": 10, "duration": "60s", "warmupDuration": "10s"}}`,
+		exp{custom: func(t *testing.T, cm lib.ScenarioConfigs) {
+			sched, ok := cm["someKey"].(ConstantVUsConfig)
+			require.True(t, ok)
+			assert.Equal(t, 10*time.Second, sched.GetWarmupDuration())
+			et, err := lib.NewExecutionTuple(nil, nil)
+			require.NoError(t, err)
+			assert.Equal(t, "10 looping VUs for 1m0s (gracefulStop: 30s, warmupDuration: 10s)",
+				cm["someKey"].GetDescription(et))
+		}},
+	},
+	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "warmupDuration": "-1s"}}`, exp{validationError: true}},
+	{
+		`{"someKey": {"executor": "constant-vus", "vus": 10, "duration": "60s", "gracefulStop": "0s", "criticalStop": "1m"}}`,
+		exp{custom: func(t *testing.T, cm lib.ScenarioConfigs) {
+			sched, ok := cm["someKey"].(ConstantVUsConfig)
+			require.True(t, ok)
+			assert.Equal(t, time.Minute, sched.GetCriticalStop())
+			assert.Equal(t, DefaultCriticalStopValue, NewConstantVUsConfig("other").GetCriticalStop())
+			et, err := lib.NewExecutionTuple(nil, nil)
+			require.NoError(t, err)
+			assert.Equal(t, "10 looping VUs for 1m0s (criticalStop: 1m0s)", cm["someKey"].GetDescription(et))
+		}},
+	},
+	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "criticalStop": "-1s"}}`, exp{validationError: true}},
+	{
+		`{"someKey": {"executor": "constant-vus", "vus": 10, "duration": "60s", "pacing": "2s"}}`,
+		exp{custom: func(t *testing.T, cm lib.ScenarioConfigs) {
+			sched, ok := cm["someKey"].(ConstantVUsConfig)
+			require.True(t, ok)
+			assert.Equal(t, 2*time.Second, sched.GetPacing().next())
+			et, err := lib.NewExecutionTuple(nil, nil)
+			require.NoError(t, err)
+			assert.Equal(t, "10 looping VUs for 1m0s (gracefulStop: 30s, pacing: 2s)", cm["someKey"].GetDescription(et))
+		}},
+	},
+	{
+		`{"someKey": {"executor": "per-vu-iterations", "vus": 10, "iterations": 5, "pacing": {"type": "uniform", "min": "1s", "max": "3s"}}}`,
+		exp{custom: func(t *testing.T, cm lib.ScenarioConfigs) {
+			et, err := lib.NewExecutionTuple(nil, nil)
+			require.NoError(t, err)
+			assert.Equal(t, "5 iterations for each of 10 VUs (maxDuration: 10m0s, gracefulStop: 30s, pacing: uniform 1s-3s)",
+				cm["someKey"].GetDescription(et))
+		}},
+	},
+	{
+		`{"someKey": {"executor": "ramping-vus", "stages": [{"duration": "1m", "target": 10}], "pacing": {"type": "normal", "mean": "2s", "stdDev": "500ms", "max": "4s"}}}`,
+		exp{custom: func(t *testing.T, cm lib.ScenarioConfigs) {
+			sched, ok := cm["someKey"].(RampingVUsConfig)
+			require.True(t, ok)
+			assert.Equal(t, "normal mean=2s stdDev=500ms max=4s", sched.GetPacing().String())
+		}},
+	},
+	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "pacing": {"type": "exponential", "mean": "1s"}}}`, exp{}},
+	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "pacing": "-1s"}}`, exp{validationError: true}},
+	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "pacing": {"type": "uniform", "min": "3s", "max": "1s"}}}`, exp{validationError: true}},
+	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "pacing": {"type": "normal", "mean": "2s"}}}`, exp{validationError: true}},
+	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "pacing": {"type": "exponential", "min": "1s", "mean": "2s"}}}`, exp{validationError: true}},
+	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "pacing": {"type": "poisson", "mean": "2s"}}}`, exp{validationError: true}},
+	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "pacing": {"type": "uniform", "avg": "2s"}}}`, exp{parseError: true}},
+	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "pacing": true}}`, exp{parseError: true}},
+	{`{"carrival": {"executor": "constant-arrival-rate", "rate": 10, "duration": "10m", "preAllocatedVUs": 20, "pacing": "1s"}}`, exp{validationError: true}},
+	{
+		`{"someKey": {"executor": "constant-vus", "vus": 10, "duration": "60s", "startCron": "0 2 * * *"}}`,
+		exp{custom: func(t *testing.T, cm lib.ScenarioConfigs) {
+			after := time.Date(2021, time.September, 15, 14, 30, 0, 0, time.Local)
+			at, ok := cm["someKey"].GetScheduledStart(after)
+			assert.True(t, ok)
+			assert.Equal(t, time.Date(2021, time.September, 16, 2, 0, 0, 0, time.Local), at)
+			et, err := lib.NewExecutionTuple(nil, nil)
+			require.NoError(t, err)
+			assert.Equal(t, "10 looping VUs for 1m0s (startCron: 0 2 * * *, gracefulStop: 30s)",
+				cm["someKey"].GetDescription(et))
+		}},
+	},
+	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "startAt": "02:00"}}`, exp{}},
+	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "startAt": "2am"}}`, exp{validationError: true}},
+	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "startCron": "* *"}}`, exp{validationError: true}},
+	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "startAt": "02:00", "startCron": "0 2 * * *"}}`, exp{validationError: true}},
+	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "startAt": "02:00", "startTime": "1m"}}`, exp{validationError: true}},
+	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "exec": {}}}`, exp{validationError: true}},
+	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "exec": {"a": 0}}}`, exp{validationError: true}},
+	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "exec": {"": 1}}}`, exp{validationError: true}},
+	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "exec": 123}}`, exp{parseError: true}},
 	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "gracefulStop": "-2s"}}`, exp{validationError: true}},
 	// ramping-vus
 	{
@@ -420,6 +515,155 @@ var configMapTestCases = []configMapTestCase{
 	{`{"varrival": {"executor": "ramping-arrival-rate", "preAllocatedVUs": 20, "maxVUs": 50, "stages": []}}`, exp{validationError: true}},
 	{`{"varrival": {"executor": "ramping-arrival-rate", "preAllocatedVUs": 20, "maxVUs": 50, "stages": [{"duration": "5m", "target": 10}], "timeUnit": "-1s"}}`, exp{validationError: true}},
 	{`{"varrival": {"executor": "ramping-arrival-rate", "preAllocatedVUs": 30, "maxVUs": 20, "stages": [{"duration": "5m", "target": 10}]}}`, exp{validationError: true}},
+	// curve-vus
+	{
+		`{"curve": {"executor": "curve-vus", "gracefulRampDown": "10s",
+		"points": [{"time": "0s", "target": 5}, {"time": "1m", "target": 29.6}, {"time": "3m", "target": 10}]}}`,
+		exp{custom: func(t *testing.T, cm lib.ScenarioConfigs) {
+			assert.Empty(t, cm["curve"].Validate())
+
+			et, err := lib.NewExecutionTuple(nil, nil)
+			require.NoError(t, err)
+			assert.Equal(t, "Up to 30 looping VUs for 3m0s over a curve of 3 points "+
+				"(gracefulRampDown: 10s, gracefulStop: 30s)", cm["curve"].GetDescription(et))
+
+			schedReqs := cm["curve"].GetExecutionRequirements(et)
+			endOffset, isFinal := lib.GetEndOffset(schedReqs)
+			assert.Equal(t, 210*time.Second, endOffset)
+			assert.Equal(t, true, isFinal)
+			assert.Equal(t, uint64(30), lib.GetMaxPlannedVUs(schedReqs))
+		}},
+	},
+	{`{"curve": {"executor": "curve-vus", "csv": "time,vus\n0,1\n10s,10"}}`, exp{}},
+	{`{"curve": {"executor": "curve-vus", "points": [{"time": "0s", "target": 5}]}}`, exp{validationError: true}},
+	{`{"curve": {"executor": "curve-vus", "points": [{"time": "0s", "target": 0}, {"time": "1m", "target": 0}]}}`, exp{validationError: true}},
+	{`{"curve": {"executor": "curve-vus", "stages": [{"duration": "10s", "target": 10}]}}`, exp{parseError: true}},
+	// curve-arrival-rate
+	{
+		`{"curve": {"executor": "curve-arrival-rate", "timeUnit": "30s", "preAllocatedVUs": 20,
+		"csv": "0,10\n3m,30\n8m,10"}}`,
+		exp{custom: func(t *testing.T, cm lib.ScenarioConfigs) {
+			assert.Empty(t, cm["curve"].Validate())
+
+			et, err := lib.NewExecutionTuple(nil, nil)
+			require.NoError(t, err)
+			assert.Equal(t, "Up to 1.00 iterations/s for 8m0s over a curve of 3 points (maxVUs: 20, gracefulStop: 30s)",
+				cm["curve"].GetDescription(et))
+
+			schedReqs := cm["curve"].GetExecutionRequirements(et)
+			endOffset, isFinal := lib.GetEndOffset(schedReqs)
+			assert.Equal(t, 510*time.Second, endOffset)
+			assert.Equal(t, true, isFinal)
+			assert.Equal(t, uint64(20), lib.GetMaxPossibleVUs(schedReqs))
+		}},
+	},
+	{`{"curve": {"executor": "curve-arrival-rate", "csv": "0,10\n3m,30"}}`, exp{validationError: true}},
+	{`{"curve": {"executor": "curve-arrival-rate", "preAllocatedVUs": 20, "csv": "0,10\n3m,30,1"}}`, exp{validationError: true}},
+	// startAfter
+	{
+		`{"seed": {"executor": "per-vu-iterations", "vus": 1, "iterations": 1, "maxDuration": "1m", "gracefulStop": "0s"},
+		"load": {"executor": "constant-vus", "vus": 10, "duration": "5m", "startAfter": ["seed"], "startAfterSuccess": true},
+		"verify": {"executor": "shared-iterations", "vus": 1, "iterations": 1, "maxDuration": "1m", "gracefulStop": "0s",
+			"startAfter": ["load", "seed"], "startTime": "10s"}}`,
+		exp{custom: func(t *testing.T, cm lib.ScenarioConfigs) {
+			assert.Equal(t, map[string]time.Duration{
+				"seed":   0,
+				"load":   1 * time.Minute,
+				"verify": 6*time.Minute + 40*time.Second,
+			}, cm.GetStartTimes())
+
+			startAfter, successfully := cm["load"].GetStartAfter()
+			assert.Equal(t, []string{"seed"}, startAfter)
+			assert.True(t, successfully)
+
+			et, err := lib.NewExecutionTuple(nil, nil)
+			require.NoError(t, err)
+			assert.Equal(t, "1 iterations shared among 1 VUs (maxDuration: 1m0s, startAfter: load, seed, startTime: 10s)", cm["verify"].GetDescription(et))
+
+			sorted := cm.GetSortedConfigs()
+			assert.Equal(t, "seed", sorted[0].GetName())
+			assert.Equal(t, "load", sorted[1].GetName())
+			assert.Equal(t, "verify", sorted[2].GetName())
+
+			totalReqs := cm.GetFullExecutionRequirements(et)
+			endOffset, isFinal := lib.GetEndOffset(totalReqs)
+			assert.Equal(t, 7*time.Minute+40*time.Second, endOffset)
+			assert.Equal(t, true, isFinal)
+			// load and verify can start as soon as seed ends, which can be right away, so their VUs are reserved from the start
+			assert.Equal(t, uint64(12), lib.GetMaxPlannedVUs(totalReqs))
+		}},
+	},
+	{`{"load": {"executor": "constant-vus", "vus": 10, "duration": "5m", "startAfter": ["seed"]}}`, exp{validationError: true}},
+	{`{"load": {"executor": "constant-vus", "vus": 10, "duration": "5m", "startAfter": ["load"]}}`, exp{validationError: true}},
+	{`{"load": {"executor": "constant-vus", "vus": 10, "duration": "5m", "startAfterSuccess": true}}`, exp{validationError: true}},
+	{`{"a": {"executor": "constant-vus", "vus": 1, "duration": "5m", "startAfter": ["b"]},
+	"b": {"executor": "constant-vus", "vus": 1, "duration": "5m", "startAfter": ["a"]}}`, exp{validationError: true}},
+	// setup and teardown
+	{
+		`{"seeded": {"executor": "shared-iterations", "setup": "seed", "setupTimeout": "2m", "teardown": "unseed"}}`,
+		exp{custom: func(t *testing.T, cm lib.ScenarioConfigs) {
+			fn, timeout := cm["seeded"].GetSetup()
+			assert.Equal(t, "seed", fn)
+			assert.Equal(t, 2*time.Minute, timeout)
+			fn, timeout = cm["seeded"].GetTeardown()
+			assert.Equal(t, "unseed", fn)
+			assert.Equal(t, time.Duration(0), timeout)
+		}},
+	},
+	{`{"seeded": {"executor": "shared-iterations", "setup": ""}}`, exp{validationError: true}},
+	{`{"seeded": {"executor": "shared-iterations", "setup": "seed", "setupTimeout": "0s"}}`, exp{validationError: true}},
+	{`{"seeded": {"executor": "shared-iterations", "teardown": "unseed", "teardownTimeout": "-1s"}}`, exp{validationError: true}},
+	// adaptive-arrival-rate
+	{
+		`{"search": {"executor": "adaptive-arrival-rate", "startRate": 10, "maxRate": 500, "duration": "10m",
+		"target": 300, "preAllocatedVUs": 20, "maxVUs": 100}}`,
+		exp{custom: func(t *testing.T, cm lib.ScenarioConfigs) {
+			assert.Empty(t, cm["search"].Validate())
+
+			et, err := lib.NewExecutionTuple(nil, nil)
+			require.NoError(t, err)
+			assert.Equal(t, "1.00-500.00 iterations/s for 10m0s, holding p(95) of http_req_duration at 300 "+
+				"(maxVUs: 20-100, gracefulStop: 30s)", cm["search"].GetDescription(et))
+
+			schedReqs := cm["search"].GetExecutionRequirements(et)
+			endOffset, isFinal := lib.GetEndOffset(schedReqs)
+			assert.Equal(t, 630*time.Second, endOffset)
+			assert.Equal(t, true, isFinal)
+			assert.Equal(t, uint64(20), lib.GetMaxPlannedVUs(schedReqs))
+			assert.Equal(t, uint64(100), lib.GetMaxPossibleVUs(schedReqs))
+		}},
+	},
+	{`{"search": {"executor": "adaptive-arrival-rate", "startRate": 10, "maxRate": 500, "duration": "10m",
+		"targetMetric": "http_req_failed", "targetValue": "rate", "target": 0.01, "interval": "10s", "preAllocatedVUs": 20}}`, exp{}},
+	{`{"search": {"executor": "adaptive-arrival-rate", "startRate": 10, "maxRate": 500, "duration": "10m", "preAllocatedVUs": 20}}`, exp{validationError: true}},
+	{`{"search": {"executor": "adaptive-arrival-rate", "startRate": 10, "maxRate": 5, "duration": "10m", "target": 300, "preAllocatedVUs": 20}}`, exp{validationError: true}},
+	{`{"search": {"executor": "adaptive-arrival-rate", "startRate": 10, "maxRate": 500, "duration": "10m", "target": 300, "interval": "100ms", "preAllocatedVUs": 20}}`, exp{validationError: true}},
+	{`{"search": {"executor": "adaptive-arrival-rate", "startRate": 10, "maxRate": 500, "duration": "10m", "target": 300, "preAllocatedVUs": 20, "maxVUs": 10}}`, exp{validationError: true}},
+	// externally-controlled-arrival-rate
+	{
+		`{"dial": {"executor": "externally-controlled-arrival-rate", "rate": 10, "maxRate": 200, "duration": "1h",
+		"preAllocatedVUs": 20, "maxVUs": 100}}`,
+		exp{custom: func(t *testing.T, cm lib.ScenarioConfigs) {
+			assert.Empty(t, cm["dial"].Validate())
+
+			et, err := lib.NewExecutionTuple(nil, nil)
+			require.NoError(t, err)
+			assert.Equal(t, "Up to 10.00 iterations/s for 1h0m0s, changeable at runtime "+
+				"(maxVUs: 20-100, maxRate: 200.00 iterations/s, gracefulStop: 30s)", cm["dial"].GetDescription(et))
+
+			schedReqs := cm["dial"].GetExecutionRequirements(et)
+			endOffset, isFinal := lib.GetEndOffset(schedReqs)
+			assert.Equal(t, time.Hour+30*time.Second, endOffset)
+			assert.Equal(t, true, isFinal)
+			assert.Equal(t, uint64(20), lib.GetMaxPlannedVUs(schedReqs))
+			assert.Equal(t, uint64(100), lib.GetMaxPossibleVUs(schedReqs))
+		}},
+	},
+	{`{"dial": {"executor": "externally-controlled-arrival-rate", "rate": 0, "duration": "1h", "preAllocatedVUs": 20}}`, exp{}},
+	{`{"dial": {"executor": "externally-controlled-arrival-rate", "duration": "1h", "preAllocatedVUs": 20}}`, exp{validationError: true}},
+	{`{"dial": {"executor": "externally-controlled-arrival-rate", "rate": 10, "maxRate": 5, "duration": "1h", "preAllocatedVUs": 20}}`, exp{validationError: true}},
+	{`{"dial": {"executor": "externally-controlled-arrival-rate", "rate": 10, "preAllocatedVUs": 20}}`, exp{validationError: true}},
+	{`{"dial": {"executor": "externally-controlled-arrival-rate", "rate": 10, "duration": "1h"}}`, exp{validationError: true}},
 	// TODO: more tests of mixed executors and execution plans
 }
 
@@ -213,6 +213,7 @@ var (
 	_ lib.Executor              = &ExternallyControlled{}
 	_ lib.PausableExecutor      = &ExternallyControlled{}
 	_ lib.LiveUpdatableExecutor = &ExternallyControlled{}
+	_ lib.ScalableExecutor      = &ExternallyControlled{}
 )
 
 // GetCurrentConfig just returns the executor's current configuration.
@@ -306,6 +307,22 @@ func (mex *ExternallyControlled) UpdateConfig(ctx context.Context, newConf inter
 	}
 }
 
+// Scale changes the number of VUs and max VUs of the executor in real time,
+// with the same restrictions as UpdateConfig().
+func (mex *ExternallyControlled) Scale(ctx context.Context, scale lib.ScenarioScale) error {
+	if scale.Rate.Valid {
+		return fmt.Errorf("the iteration rate of the externally controlled executor can't be changed")
+	}
+	newConfig := mex.GetCurrentConfig().ExternallyControlledConfigParams
+	if scale.MaxVUs.Valid {
+		newConfig.MaxVUs = scale.MaxVUs
+	}
+	if scale.VUs.Valid {
+		newConfig.VUs = scale.VUs
+	}
+	return mex.UpdateConfig(ctx, newConfig)
+}
+
 // This is a helper function that is used in run for non-infinite durations.
 func (mex *ExternallyControlled) stopWhenDurationIsReached(ctx context.Context, duration time.Duration, cancel func()) {
 	ctxDone := ctx.Done()
@@ -541,7 +558,7 @@ func (mex *ExternallyControlled) Run(
 		currentlyPaused: false,
 		activeVUsCount:  new(int64),
 		maxVUs:          new(int64),
-		runIteration:    getIterationRunner(mex.executionState, mex.logger),
+		runIteration:    getLoopingIterationRunner(mex.executionState, mex.logger, mex.config.GetPacing(), nil),
 	}
 	ss.ProgressFn = runState.progressFn
 
@@ -0,0 +1,411 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package executor
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/stats"
+	"go.k6.io/k6/ui/pb"
+)
+
+const externallyControlledArrivalRateType = "externally-controlled-arrival-rate"
+
+func init() {
+	lib.RegisterExecutorConfigType(
+		externallyControlledArrivalRateType,
+		func(name string, rawJSON []byte) (lib.ExecutorConfig, error) {
+			config := NewExternallyControlledArrivalRateConfig(name)
+			err := lib.StrictJSONUnmarshal(rawJSON, &config)
+			return config, err
+		},
+	)
+}
+
+// ExternallyControlledArrivalRateConfig stores the configuration for the
+// externally-controlled-arrival-rate executor, which starts iterations at a
+// rate that can be changed while the test runs, with the REST API or the
+// k6 scale command.
+type ExternallyControlledArrivalRateConfig struct {
+	BaseConfig
+	Rate     null.Int           `json:"rate"`
+	MaxRate  null.Int           `json:"maxRate"` // the highest rate which can be set, if any
+	TimeUnit types.NullDuration `json:"timeUnit"`
+	Duration types.NullDuration `json:"duration"`
+
+	PreAllocatedVUs null.Int `json:"preAllocatedVUs"`
+	MaxVUs          null.Int `json:"maxVUs"`
+}
+
+// NewExternallyControlledArrivalRateConfig returns an ExternallyControlledArrivalRateConfig with default values
+func NewExternallyControlledArrivalRateConfig(name string) ExternallyControlledArrivalRateConfig {
+	return ExternallyControlledArrivalRateConfig{
+		BaseConfig: NewBaseConfig(name, externallyControlledArrivalRateType),
+		TimeUnit:   types.NewNullDuration(1*time.Second, false),
+	}
+}
+
+// Make sure we implement the lib.ExecutorConfig interface
+var _ lib.ExecutorConfig = &ExternallyControlledArrivalRateConfig{}
+
+// GetPreAllocatedVUs is just a helper method that returns the scaled pre-allocated VUs.
+func (ecarc ExternallyControlledArrivalRateConfig) GetPreAllocatedVUs(et *lib.ExecutionTuple) int64 {
+	return et.ScaleInt64(ecarc.PreAllocatedVUs.Int64)
+}
+
+// GetMaxVUs is just a helper method that returns the scaled max VUs, which
+// are the pre-allocated ones if they aren't specified.
+func (ecarc ExternallyControlledArrivalRateConfig) GetMaxVUs(et *lib.ExecutionTuple) int64 {
+	if !ecarc.MaxVUs.Valid {
+		return ecarc.GetPreAllocatedVUs(et)
+	}
+	return et.ScaleInt64(ecarc.MaxVUs.Int64)
+}
+
+// getRatePerSec returns the iterations per second of the rate for the given execution segment.
+func (ecarc ExternallyControlledArrivalRateConfig) getRatePerSec(et *lib.ExecutionTuple, rate float64) float64 {
+	return rate * et.Segment.FloatLength() * float64(time.Second) / float64(ecarc.TimeUnit.TimeDuration())
+}
+
+// GetDescription returns a human-readable description of the executor options
+func (ecarc ExternallyControlledArrivalRateConfig) GetDescription(et *lib.ExecutionTuple) string {
+	preAllocatedVUs, maxVUs := ecarc.GetPreAllocatedVUs(et), ecarc.GetMaxVUs(et)
+	maxVUsRange := fmt.Sprintf("maxVUs: %d", preAllocatedVUs)
+	if maxVUs > preAllocatedVUs {
+		maxVUsRange += fmt.Sprintf("-%d", maxVUs)
+	}
+	facts := []string{maxVUsRange}
+	if ecarc.MaxRate.Valid {
+		maxRatePerSec := ecarc.getRatePerSec(et, float64(ecarc.MaxRate.Int64))
+		facts = append(facts, fmt.Sprintf("maxRate: %.2f iterations/s", maxRatePerSec))
+	}
+
+	return fmt.Sprintf("Up to %.2f iterations/s for %s, changeable at runtime%s",
+		ecarc.getRatePerSec(et, float64(ecarc.Rate.Int64)), ecarc.Duration.Duration, ecarc.getBaseInfo(facts...))
+}
+
+// Validate makes sure all options are configured and valid
+func (ecarc ExternallyControlledArrivalRateConfig) Validate() []error {
+	errors := ecarc.BaseConfig.Validate()
+	if ecarc.Pacing.Valid() {
+		errors = append(errors, errArrivalRatePacing)
+	}
+	if !ecarc.Rate.Valid {
+		errors = append(errors, fmt.Errorf("the iteration rate isn't specified"))
+	} else if ecarc.Rate.Int64 < 0 {
+		errors = append(errors, fmt.Errorf("the iteration rate shouldn't be negative"))
+	}
+	if ecarc.MaxRate.Valid && ecarc.MaxRate.Int64 < ecarc.Rate.Int64 {
+		errors = append(errors, fmt.Errorf("the maxRate shouldn't be less than the rate"))
+	}
+
+	if ecarc.TimeUnit.TimeDuration() <= 0 {
+		errors = append(errors, fmt.Errorf("the timeUnit should be more than 0"))
+	}
+
+	if !ecarc.Duration.Valid {
+		errors = append(errors, fmt.Errorf("the duration is unspecified"))
+	} else if ecarc.Duration.TimeDuration() < minDuration {
+		errors = append(errors, fmt.Errorf(
+			"the duration should be at least %s, but is %s", minDuration, ecarc.Duration,
+		))
+	}
+
+	if !ecarc.PreAllocatedVUs.Valid {
+		errors = append(errors, fmt.Errorf("the number of preAllocatedVUs isn't specified"))
+	} else if ecarc.PreAllocatedVUs.Int64 < 0 {
+		errors = append(errors, fmt.Errorf("the number of preAllocatedVUs shouldn't be negative"))
+	}
+	if ecarc.MaxVUs.Valid && ecarc.MaxVUs.Int64 < ecarc.PreAllocatedVUs.Int64 {
+		errors = append(errors, fmt.Errorf("maxVUs shouldn't be less than preAllocatedVUs"))
+	}
+
+	return errors
+}
+
+// GetExecutionRequirements returns the number of required VUs to run the
+// executor for its whole duration (disregarding any startTime), including the
+// maximum waiting time for any iterations to gracefully stop.
+func (ecarc ExternallyControlledArrivalRateConfig) GetExecutionRequirements(
+	et *lib.ExecutionTuple,
+) []lib.ExecutionStep {
+	return []lib.ExecutionStep{
+		{
+			TimeOffset:      0,
+			PlannedVUs:      uint64(ecarc.GetPreAllocatedVUs(et)),
+			MaxUnplannedVUs: uint64(ecarc.GetMaxVUs(et) - ecarc.GetPreAllocatedVUs(et)),
+		}, {
+			TimeOffset:      ecarc.Duration.TimeDuration() + ecarc.GracefulStop.TimeDuration(),
+			PlannedVUs:      0,
+			MaxUnplannedVUs: 0,
+		},
+	}
+}
+
+// NewExecutor creates a new ExternallyControlledArrivalRate executor
+func (ecarc ExternallyControlledArrivalRateConfig) NewExecutor(
+	es *lib.ExecutionState, logger *logrus.Entry,
+) (lib.Executor, error) {
+	ecar := &ExternallyControlledArrivalRate{
+		BaseExecutor: NewBaseExecutor(ecarc, es, logger),
+		config:       ecarc,
+		rateChanged:  make(chan struct{}, 1),
+	}
+	atomic.StoreUint64(&ecar.rate, math.Float64bits(float64(ecarc.Rate.Int64)))
+	return ecar, nil
+}
+
+// HasWork reports whether there is any work to be done for the given execution segment.
+func (ecarc ExternallyControlledArrivalRateConfig) HasWork(et *lib.ExecutionTuple) bool {
+	return ecarc.GetMaxVUs(et) > 0
+}
+
+// ExternallyControlledArrivalRate starts iterations at a rate which can be
+// changed with SetRate() while it runs, e.g. from the REST API.
+type ExternallyControlledArrivalRate struct {
+	*BaseExecutor
+	config ExternallyControlledArrivalRateConfig
+
+	rate        uint64 // the float64 bits of the current rate, per timeUnit
+	rateChanged chan struct{}
+}
+
+// Make sure we implement the lib.Executor and lib.ScalableExecutor interfaces.
+var (
+	_ lib.Executor         = &ExternallyControlledArrivalRate{}
+	_ lib.ScalableExecutor = &ExternallyControlledArrivalRate{}
+)
+
+// GetRate returns the current iteration rate per timeUnit of the executor.
+func (ecar *ExternallyControlledArrivalRate) GetRate() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&ecar.rate))
+}
+
+// SetRate changes the iteration rate per timeUnit of the executor, which is
+// paused while the rate is 0.
+func (ecar *ExternallyControlledArrivalRate) SetRate(rate float64) error {
+	if rate < 0 || math.IsNaN(rate) || math.IsInf(rate, 0) {
+		return fmt.Errorf("the iteration rate should be a non-negative number, but is %g", rate)
+	}
+	if ecar.config.MaxRate.Valid && rate > float64(ecar.config.MaxRate.Int64) {
+		return fmt.Errorf("the iteration rate %g is more than the maxRate %d", rate, ecar.config.MaxRate.Int64)
+	}
+	atomic.StoreUint64(&ecar.rate, math.Float64bits(rate))
+	ecar.logger.WithField("rate", rate).Info("The iteration rate was changed")
+	select {
+	case ecar.rateChanged <- struct{}{}:
+	default: // the executor will pick up the latest rate anyway
+	}
+	return nil
+}
+
+// Scale changes the iteration rate per timeUnit of the executor, its VUs can't
+// be changed.
+func (ecar *ExternallyControlledArrivalRate) Scale(_ context.Context, scale lib.ScenarioScale) error {
+	if scale.VUs.Valid || scale.MaxVUs.Valid {
+		return fmt.Errorf("the VUs of the externally-controlled-arrival-rate executor can't be changed")
+	}
+	if !scale.Rate.Valid {
+		return nil
+	}
+	return ecar.SetRate(scale.Rate.Float64)
+}
+
+// Run starts the iterations at the current rate, until the end of the duration.
+//nolint:funlen
+func (ecar *ExternallyControlledArrivalRate) Run(
+	parentCtx context.Context, out chan<- stats.SampleContainer, builtinMetrics *metrics.BuiltinMetrics,
+) (err error) {
+	et := ecar.executionState.ExecutionTuple
+	gracefulStop := ecar.config.GetGracefulStop()
+	duration := ecar.config.Duration.TimeDuration()
+	preAllocatedVUs := ecar.config.GetPreAllocatedVUs(et)
+	maxVUs := ecar.config.GetMaxVUs(et)
+	timeUnit := ecar.config.TimeUnit.TimeDuration()
+	segmentLength := et.Segment.FloatLength()
+
+	ecar.logger.WithFields(logrus.Fields{
+		"maxVUs": maxVUs, "preAllocatedVUs": preAllocatedVUs, "duration": duration,
+		"type": ecar.config.GetType(),
+	}).Debug("Starting executor run...")
+
+	activeVUsWg := &sync.WaitGroup{}
+
+	returnedVUs := make(chan struct{})
+	startTime, maxDurationCtx, regDurationCtx, cancel := getDurationContexts(parentCtx, duration, gracefulStop)
+
+	vusPool := newActiveVUPool()
+	defer func() {
+		<-returnedVUs
+		vusPool.Close()
+		cancel()
+		activeVUsWg.Wait()
+	}()
+	activeVUsCount := uint64(0)
+
+	vusFmt := pb.GetFixedLengthIntFormat(maxVUs)
+	progressFn := func() (float64, []string) {
+		spent := time.Since(startTime)
+		currActiveVUs := atomic.LoadUint64(&activeVUsCount)
+		progVUs := fmt.Sprintf(vusFmt+"/"+vusFmt+" VUs", vusPool.Running(), currActiveVUs)
+		progIters := fmt.Sprintf("%.2f iters/s", ecar.config.getRatePerSec(et, ecar.GetRate()))
+
+		right := []string{progVUs, duration.String(), progIters}
+		if spent > duration {
+			return 1, right
+		}
+
+		spentDuration := pb.GetFixedLengthDuration(spent, duration)
+		right[1] = fmt.Sprintf("%s/%s", spentDuration, duration)
+
+		return math.Min(1, float64(spent)/float64(duration)), right
+	}
+	ecar.progress.Modify(pb.WithProgress(progressFn))
+	go trackProgress(parentCtx, maxDurationCtx, regDurationCtx, ecar, progressFn)
+
+	maxDurationCtx = lib.WithScenarioState(maxDurationCtx, &lib.ScenarioState{
+		Name:       ecar.config.Name,
+		Executor:   ecar.config.Type,
+		StartTime:  startTime,
+		ProgressFn: progressFn,
+	})
+
+	returnVU := func(u lib.InitializedVU) {
+		ecar.executionState.ReturnVU(u, true)
+		activeVUsWg.Done()
+	}
+
+	runIterationBasic := getIterationRunner(ecar.executionState, ecar.logger)
+	activateVU := func(initVU lib.InitializedVU) lib.ActiveVU {
+		activeVUsWg.Add(1)
+		activeVU := initVU.Activate(getVUActivationParams(
+			maxDurationCtx, ecar.config.BaseConfig, returnVU,
+			ecar.nextIterationCounters,
+		))
+		ecar.executionState.ModCurrentlyActiveVUsCount(+1)
+		atomic.AddUint64(&activeVUsCount, 1)
+		vusPool.AddVU(maxDurationCtx, activeVU, runIterationBasic)
+		return activeVU
+	}
+
+	remainingUnplannedVUs := maxVUs - preAllocatedVUs
+	makeUnplannedVUCh := make(chan struct{})
+	defer close(makeUnplannedVUCh)
+	go func() {
+		defer close(returnedVUs)
+		for range makeUnplannedVUCh {
+			ecar.logger.Debug("Starting initialization of an unplanned VU...")
+			initVU, err := ecar.executionState.GetUnplannedVU(maxDurationCtx, ecar.logger)
+			if err != nil {
+				ecar.logger.WithError(err).Error("Error while allocating unplanned VU")
+			} else {
+				ecar.logger.Debug("The unplanned VU finished initializing successfully!")
+				activateVU(initVU)
+			}
+		}
+	}()
+
+	for i := int64(0); i < preAllocatedVUs; i++ {
+		initVU, err := ecar.executionState.GetPlannedVU(ecar.logger, false)
+		if err != nil {
+			return err
+		}
+		activateVU(initVU)
+	}
+
+	// The timer is stopped while the rate is 0, and rescheduled from the time
+	// of every change of the rate, so a higher rate applies right away.
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	defer timer.Stop()
+	var nextIteration time.Time
+	scheduleNextIteration := func(from time.Time) {
+		rate := ecar.GetRate()
+		if rate <= 0 {
+			return
+		}
+		nextIteration = from.Add(time.Duration(float64(timeUnit) / (rate * segmentLength)))
+		timer.Reset(time.Until(nextIteration))
+	}
+	if ecar.GetRate() > 0 {
+		nextIteration = startTime
+		timer.Reset(0)
+	}
+
+	droppedIterationMetric := builtinMetrics.DroppedIterations
+	shownWarning := false
+	metricTags := ecar.getMetricTags(nil)
+	for {
+		select {
+		case <-timer.C:
+			scheduleNextIteration(nextIteration)
+
+			if ecar.executionState.IsScenarioPaused(ecar.config.Name) {
+				continue // the iterations of paused scenarios are skipped, not dropped
+			}
+			if vusPool.TryRunIteration() {
+				continue
+			}
+
+			stats.PushIfNotDone(parentCtx, out, stats.Sample{
+				Value: 1, Metric: droppedIterationMetric,
+				Tags: metricTags, Time: time.Now(),
+			})
+
+			if remainingUnplannedVUs == 0 {
+				if !shownWarning {
+					ecar.logger.Warningf("Insufficient VUs, reached %d active VUs and cannot initialize more", maxVUs)
+					shownWarning = true
+				}
+				continue
+			}
+
+			select {
+			case makeUnplannedVUCh <- struct{}{}:
+				remainingUnplannedVUs--
+			default:
+			}
+
+		case <-ecar.rateChanged:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			scheduleNextIteration(time.Now())
+
+		case <-regDurationCtx.Done():
+			return nil
+		}
+	}
+}
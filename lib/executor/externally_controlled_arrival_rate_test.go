@@ -0,0 +1,91 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package executor
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/stats"
+)
+
+func TestExternallyControlledArrivalRateRun(t *testing.T) {
+	t.Parallel()
+
+	config := NewExternallyControlledArrivalRateConfig("test")
+	config.GracefulStop = types.NullDurationFrom(0)
+	config.Rate = null.IntFrom(0)
+	config.MaxRate = null.IntFrom(100)
+	config.Duration = types.NullDurationFrom(2 * time.Second)
+	config.PreAllocatedVUs = null.IntFrom(10)
+	require.Empty(t, config.Validate())
+
+	et, err := lib.NewExecutionTuple(nil, nil)
+	require.NoError(t, err)
+	es := lib.NewExecutionState(lib.Options{}, et, 10, 10)
+
+	var count int64
+	ctx, cancel, exec, logHook := setupExecutor(t, config, es,
+		simpleRunner(func(ctx context.Context, _ *lib.State) error {
+			atomic.AddInt64(&count, 1)
+			return nil
+		}),
+	)
+	defer cancel()
+	executor, ok := exec.(*ExternallyControlledArrivalRate)
+	require.True(t, ok)
+
+	assert.Error(t, executor.SetRate(101))
+	assert.Error(t, executor.SetRate(-1))
+	assert.Equal(t, 0.0, executor.GetRate())
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		time.Sleep(500 * time.Millisecond)
+		assert.Equal(t, int64(0), atomic.LoadInt64(&count), "iterations were started at a rate of 0")
+
+		assert.NoError(t, executor.SetRate(50))
+		time.Sleep(600 * time.Millisecond)
+		assert.NoError(t, executor.SetRate(0))
+		assert.InDelta(t, 30, atomic.LoadInt64(&count), 5)
+	}()
+
+	registry := metrics.NewRegistry()
+	builtinMetrics := metrics.RegisterBuiltinMetrics(registry)
+	require.NoError(t, exec.Run(ctx, make(chan stats.SampleContainer, 1000), builtinMetrics))
+	wg.Wait()
+	require.Empty(t, logHook.Drain())
+
+	assert.InDelta(t, 30, atomic.LoadInt64(&count), 5) // no iterations after the rate was set to 0
+	assert.Equal(t, 0.0, executor.GetRate())
+}
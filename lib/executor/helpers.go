@@ -53,6 +53,45 @@ func getStagesUnscaledMaxTarget(unscaledStartValue int64, stages []Stage) int64
 	return max
 }
 
+// getStagesRampUpDuration returns the duration of the leading stages which increase the target.
+func getStagesRampUpDuration(unscaledStartValue int64, stages []Stage) (result time.Duration) {
+	previous := unscaledStartValue
+	for _, s := range stages {
+		if s.Target.Int64 <= previous {
+			break
+		}
+		result += s.Duration.TimeDuration()
+		previous = s.Target.Int64
+	}
+	return result
+}
+
+// GetRampUpDuration returns the time from the start of the test at which the ramping scenarios stop
+// ramping up, which is the end of their leading stages that increase their targets.
+func GetRampUpDuration(scenarios lib.ScenarioConfigs) time.Duration {
+	var result time.Duration
+	startTimes := scenarios.GetStartTimes()
+	for _, scenario := range scenarios {
+		var rampUp time.Duration
+		switch config := scenario.(type) {
+		case RampingVUsConfig:
+			rampUp = getStagesRampUpDuration(config.StartVUs.Int64, config.Stages)
+		case *RampingArrivalRateConfig:
+			rampUp = getStagesRampUpDuration(config.StartRate.Int64, config.Stages)
+		case CurveVUsConfig:
+			rampUp = getStagesRampUpDuration(config.getStages())
+		case CurveArrivalRateConfig:
+			rampUp = getStagesRampUpDuration(config.getStages())
+		default:
+			continue
+		}
+		if end := startTimes[scenario.GetName()] + rampUp; rampUp > 0 && end > result {
+			result = end
+		}
+	}
+	return result
+}
+
 // A helper function to avoid code duplication
 func validateStages(stages []Stage) []error {
 	var errors []error
@@ -152,9 +191,15 @@ func getIterationRunner(
 		case <-ctx.Done():
 			// Don't log errors or emit iterations metrics from cancelled iterations
 			executionState.AddInterruptedIterations(1)
+			if scenario := lib.GetScenarioState(ctx); scenario != nil {
+				executionState.AddFailedIterations(scenario.Name, 1) // an interruption isn't a success either
+			}
 			return false
 		default:
 			if err != nil {
+				if scenario := lib.GetScenarioState(ctx); scenario != nil {
+					executionState.AddFailedIterations(scenario.Name, 1)
+				}
 				if handleInterrupt(ctx, err) {
 					executionState.AddInterruptedIterations(1)
 					return false
@@ -177,6 +222,44 @@ func getIterationRunner(
 	}
 }
 
+// getLoopingIterationRunner returns the iteration runner of an executor whose
+// VUs loop over iterations. Before every iteration, each VU waits while the
+// scenario is paused, and after every full iteration, it waits for the delay of
+// the pacing, outside of the iteration_duration. The waits end early when the
+// context is done or the stop channel, if any, is closed.
+func getLoopingIterationRunner(
+	executionState *lib.ExecutionState, logger *logrus.Entry, pacing Pacing, stop <-chan struct{},
+) func(context.Context, lib.ActiveVU) bool {
+	runIteration := getIterationRunner(executionState, logger)
+	return func(ctx context.Context, vu lib.ActiveVU) bool {
+		if scenario := lib.GetScenarioState(ctx); scenario != nil && executionState.IsScenarioPaused(scenario.Name) {
+			select {
+			case <-executionState.ScenarioResumeNotify(scenario.Name):
+			case <-ctx.Done():
+				return false
+			case <-stop:
+				return false
+			}
+		}
+		fullIteration := runIteration(ctx, vu)
+		if !fullIteration || !pacing.Valid() {
+			return fullIteration
+		}
+		delay := pacing.next()
+		if delay <= 0 {
+			return true
+		}
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+		case <-stop:
+		}
+		return true
+	}
+}
+
 // getDurationContexts is used to create sub-contexts that can restrict an
 // executor to only run for its allotted time.
 //
@@ -293,6 +376,9 @@ func getVUActivationParams(
 		RunContext:               ctx,
 		Scenario:                 conf.Name,
 		Exec:                     conf.GetExec(),
+		ExecPicker:               lib.NewExecPicker(conf.GetExecWeights()),
+		WarmupDuration:           conf.GetWarmupDuration(),
+		CriticalStop:             conf.GetCriticalStop(),
 		Env:                      conf.GetEnv(),
 		Tags:                     conf.GetTags(),
 		DeactivateCallback:       deactivateCallback,
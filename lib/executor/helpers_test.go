@@ -20,7 +20,17 @@
 
 package executor
 
-import "go.k6.io/k6/stats"
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/stats"
+)
 
 func sumMetricValues(samples chan stats.SampleContainer, metricName string) (sum float64) {
 	for _, sc := range stats.GetBufferedSamples(samples) {
@@ -33,3 +43,33 @@ func sumMetricValues(samples chan stats.SampleContainer, metricName string) (sum
 	}
 	return sum
 }
+
+func TestGetRampUpDuration(t *testing.T) {
+	t.Parallel()
+
+	stage := func(d time.Duration, target int64) Stage {
+		return Stage{Duration: types.NullDurationFrom(d), Target: null.IntFrom(target)}
+	}
+
+	vus := NewRampingVUsConfig("vus")
+	vus.StartVUs = null.IntFrom(0)
+	vus.Stages = []Stage{
+		stage(time.Minute, 10), stage(30*time.Second, 20), stage(time.Minute, 20), stage(time.Minute, 30),
+	}
+
+	rate := NewRampingArrivalRateConfig("rate")
+	rate.StartTime = types.NullDurationFrom(time.Minute)
+	rate.StartRate = null.IntFrom(10)
+	rate.Stages = []Stage{stage(time.Minute, 100), stage(time.Minute, 10)}
+
+	constant := NewConstantVUsConfig("constant")
+
+	assert.Equal(t, time.Duration(0), GetRampUpDuration(lib.ScenarioConfigs{"constant": constant}))
+	assert.Equal(t, 90*time.Second, GetRampUpDuration(lib.ScenarioConfigs{"vus": vus, "constant": constant}))
+	assert.Equal(t, 2*time.Minute, GetRampUpDuration(lib.ScenarioConfigs{"vus": vus, "rate": rate}))
+
+	// the stages which don't increase the target at the start aren't a ramp-up
+	vus.StartVUs = null.IntFrom(10)
+	vus.Stages = []Stage{stage(time.Minute, 10), stage(time.Minute, 20)}
+	assert.Equal(t, time.Duration(0), GetRampUpDuration(lib.ScenarioConfigs{"vus": vus}))
+}
@@ -0,0 +1,136 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package executor
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/lib/types"
+)
+
+// CurvePoint is a point of a LoadCurve, with the target at a time from the start of the executor
+type CurvePoint struct {
+	Time   types.NullDuration `json:"time"`
+	Target null.Float         `json:"target"`
+}
+
+// LoadCurve is the curve of the target of the curve executors, with its points either listed, for
+// instance computed by a JS function of the time in the init context, or in a CSV of time,target lines,
+// for instance with the contents of a file of replayed production traffic. The target is interpolated
+// linearly between the points, and rounded, like with the stages of the ramping executors.
+type LoadCurve struct {
+	Points []CurvePoint `json:"points"`
+	CSV    null.String  `json:"csv"`
+}
+
+// getPoints returns the points of the curve, parsing them from its CSV if they aren't listed.
+// The times of the CSV are durations, like 1m30s, or milliseconds, and its first line can be a header.
+func (lc LoadCurve) getPoints() ([]CurvePoint, error) {
+	if !lc.CSV.Valid {
+		return lc.Points, nil
+	}
+	if len(lc.Points) > 0 {
+		return nil, errors.New("the curve can't have both points and a csv")
+	}
+
+	r := csv.NewReader(strings.NewReader(lc.CSV.String))
+	r.FieldsPerRecord = 2
+	r.TrimLeadingSpace = true
+	var points []CurvePoint
+	for line := 1; ; line++ {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return points, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid curve csv: %w", err)
+		}
+		target, err := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		if err != nil && line == 1 {
+			continue // the header
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid target '%s' on line %d of the curve csv", record[1], line)
+		}
+		offset, err := types.ParseExtendedDuration(strings.TrimSpace(record[0]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid time '%s' on line %d of the curve csv", record[0], line)
+		}
+		points = append(points, CurvePoint{Time: types.NullDurationFrom(offset), Target: null.FloatFrom(target)})
+	}
+}
+
+// Validate makes sure the curve has valid points, in order, starting at 0.
+func (lc LoadCurve) Validate() []error {
+	points, err := lc.getPoints()
+	if err != nil {
+		return []error{err}
+	}
+	if len(points) < 2 {
+		return []error{errors.New("the curve needs at least two points")}
+	}
+
+	var errs []error
+	for i, p := range points {
+		pointNum := i + 1
+		switch {
+		case !p.Time.Valid:
+			errs = append(errs, fmt.Errorf("point %d of the curve doesn't have a time", pointNum))
+		case i == 0 && p.Time.Duration != 0:
+			errs = append(errs, fmt.Errorf("the curve needs to start at 0, not %s", p.Time.Duration))
+		case i > 0 && p.Time.Duration < points[i-1].Time.Duration:
+			errs = append(errs, fmt.Errorf("the time of point %d of the curve is before the previous one", pointNum))
+		}
+		if !p.Target.Valid {
+			errs = append(errs, fmt.Errorf("point %d of the curve doesn't have a target", pointNum))
+		} else if p.Target.Float64 < 0 {
+			errs = append(errs, fmt.Errorf("the target of point %d of the curve shouldn't be negative", pointNum))
+		}
+	}
+	return errs
+}
+
+// getStages returns the start value and the stages between the points of the curve, which are
+// the ones of the equivalent ramping executor.
+func (lc LoadCurve) getStages() (int64, []Stage) {
+	points, err := lc.getPoints()
+	if err != nil || len(points) == 0 {
+		return 0, nil
+	}
+
+	stages := make([]Stage, 0, len(points)-1)
+	for i := 1; i < len(points); i++ {
+		stages = append(stages, Stage{
+			Duration: types.NullDurationFrom(time.Duration(points[i].Time.Duration - points[i-1].Time.Duration)),
+			Target:   null.IntFrom(int64(math.Round(points[i].Target.Float64))),
+		})
+	}
+	return int64(math.Round(points[0].Target.Float64)), stages
+}
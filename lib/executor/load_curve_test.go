@@ -0,0 +1,89 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package executor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/types"
+)
+
+func TestLoadCurve(t *testing.T) {
+	t.Parallel()
+
+	point := func(d time.Duration, target float64) CurvePoint {
+		return CurvePoint{Time: types.NullDurationFrom(d), Target: null.FloatFrom(target)}
+	}
+
+	t.Run("csv", func(t *testing.T) {
+		t.Parallel()
+
+		lc := LoadCurve{CSV: null.StringFrom("time, target\n0, 1.5\n1m30s, 10\n120000, 0\n")}
+		points, err := lc.getPoints()
+		require.NoError(t, err)
+		assert.Equal(t, []CurvePoint{point(0, 1.5), point(90*time.Second, 10), point(2*time.Minute, 0)}, points)
+		assert.Empty(t, lc.Validate())
+
+		start, stages := lc.getStages()
+		assert.Equal(t, int64(2), start)
+		assert.Equal(t, []Stage{
+			{Duration: types.NullDurationFrom(90 * time.Second), Target: null.IntFrom(10)},
+			{Duration: types.NullDurationFrom(30 * time.Second), Target: null.IntFrom(0)},
+		}, stages)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		t.Parallel()
+
+		for name, lc := range map[string]LoadCurve{
+			"no points":       {},
+			"one point":       {Points: []CurvePoint{point(0, 1)}},
+			"late start":      {Points: []CurvePoint{point(time.Second, 1), point(time.Minute, 1)}},
+			"unordered":       {Points: []CurvePoint{point(0, 1), point(time.Minute, 1), point(time.Second, 1)}},
+			"negative target": {Points: []CurvePoint{point(0, 1), point(time.Minute, -1)}},
+			"no target":       {Points: []CurvePoint{point(0, 1), {Time: types.NullDurationFrom(time.Minute)}}},
+			"points and csv":  {Points: []CurvePoint{point(0, 1)}, CSV: null.StringFrom("0,1\n1m,2")},
+			"bad csv target":  {CSV: null.StringFrom("0,1\n1m,a lot")},
+			"bad csv time":    {CSV: null.StringFrom("0,1\nlater,2")},
+		} {
+			assert.NotEmpty(t, lc.Validate(), name)
+		}
+	})
+}
+
+func TestGetRampUpDurationOfCurves(t *testing.T) {
+	t.Parallel()
+
+	vus := NewCurveVUsConfig("vus")
+	vus.CSV = null.StringFrom("0,0\n1m,10\n2m,20\n3m,5")
+	rate := NewCurveArrivalRateConfig("rate")
+	rate.StartTime = types.NullDurationFrom(time.Minute)
+	rate.CSV = null.StringFrom("0,10\n3m,100\n4m,10")
+
+	assert.Equal(t, 2*time.Minute, GetRampUpDuration(lib.ScenarioConfigs{"vus": vus}))
+	assert.Equal(t, 4*time.Minute, GetRampUpDuration(lib.ScenarioConfigs{"vus": vus, "rate": rate}))
+}
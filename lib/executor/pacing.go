@@ -0,0 +1,181 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package executor
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/types"
+)
+
+// The distributions of the pacing delays between iterations
+const (
+	pacingUniform     = "uniform"
+	pacingNormal      = "normal"
+	pacingExponential = "exponential"
+)
+
+var errArrivalRatePacing = errors.New( //nolint:gochecknoglobals
+	"pacing can't be used with arrival-rate executors, since they start the iterations at their own rate")
+
+// Pacing is the pacing option of a scenario, which is the delay each VU waits
+// after every iteration before it starts the next one. It's either a fixed
+// duration, like "2s", or an object with a random distribution of delays, like
+// {"type": "uniform", "min": "1s", "max": "3s"},
+// {"type": "normal", "mean": "2s", "stdDev": "500ms"} or
+// {"type": "exponential", "mean": "2s"}. The max is an upper bound of the
+// normal and exponential delays as well.
+type Pacing struct {
+	Fixed  types.NullDuration `json:"-"`
+	Type   null.String        `json:"type"`
+	Min    types.NullDuration `json:"min"`
+	Max    types.NullDuration `json:"max"`
+	Mean   types.NullDuration `json:"mean"`
+	StdDev types.NullDuration `json:"stdDev"`
+}
+
+// pacingDistribution is used for the (un)marshalling of the distributions,
+// without the custom methods of Pacing.
+type pacingDistribution Pacing
+
+// Valid returns whether any pacing is configured.
+func (p Pacing) Valid() bool {
+	return p.Fixed.Valid || p.Type.Valid
+}
+
+// String returns a short description of the delays.
+func (p Pacing) String() string {
+	var desc string
+	switch {
+	case p.Fixed.Valid:
+		return p.Fixed.Duration.String()
+	case p.Type.String == pacingUniform:
+		return fmt.Sprintf("uniform %s-%s", p.Min.Duration, p.Max.Duration)
+	case p.Type.String == pacingNormal:
+		desc = fmt.Sprintf("normal mean=%s stdDev=%s", p.Mean.Duration, p.StdDev.Duration)
+	default:
+		desc = fmt.Sprintf("%s mean=%s", p.Type.String, p.Mean.Duration)
+	}
+	if p.Max.Valid {
+		desc += fmt.Sprintf(" max=%s", p.Max.Duration)
+	}
+	return desc
+}
+
+// Validate checks that the delays of the pacing are fully specified and can't
+// be negative.
+func (p Pacing) Validate() (errors []error) {
+	if p.Fixed.Valid {
+		if p.Fixed.Duration < 0 {
+			errors = append(errors, fmt.Errorf("the pacing can't be negative"))
+		}
+		return errors
+	}
+	if !p.Type.Valid {
+		return nil
+	}
+	if p.Min.Duration < 0 || p.Max.Duration < 0 || p.Mean.Duration < 0 || p.StdDev.Duration < 0 {
+		errors = append(errors, fmt.Errorf("the pacing delays can't be negative"))
+	}
+	switch p.Type.String {
+	case pacingUniform:
+		if !p.Min.Valid || !p.Max.Valid {
+			errors = append(errors, fmt.Errorf("the uniform pacing needs a min and a max"))
+		} else if p.Max.Duration < p.Min.Duration {
+			errors = append(errors, fmt.Errorf("the max of the pacing shouldn't be less than its min"))
+		}
+	case pacingNormal:
+		if !p.Mean.Valid || !p.StdDev.Valid {
+			errors = append(errors, fmt.Errorf("the normal pacing needs a mean and a stdDev"))
+		}
+	case pacingExponential:
+		if p.Mean.Duration <= 0 {
+			errors = append(errors, fmt.Errorf("the exponential pacing needs a mean of more than 0"))
+		}
+	default:
+		errors = append(errors, fmt.Errorf(
+			"the pacing type should be one of %s, %s or %s, but is '%s'",
+			pacingUniform, pacingNormal, pacingExponential, p.Type.String,
+		))
+	}
+	if p.Type.String != pacingUniform && p.Min.Valid {
+		errors = append(errors, fmt.Errorf("the min of the pacing is only used by the uniform type"))
+	}
+	return errors
+}
+
+// next returns a random delay of the pacing.
+func (p Pacing) next() time.Duration {
+	var delay time.Duration
+	switch p.Type.String {
+	case pacingUniform:
+		delay = p.Min.TimeDuration() + time.Duration(rand.Int63n(int64(p.Max.Duration-p.Min.Duration)+1)) //nolint:gosec
+	case pacingNormal:
+		delay = p.Mean.TimeDuration() + time.Duration(rand.NormFloat64()*float64(p.StdDev.Duration)) //nolint:gosec
+	case pacingExponential:
+		delay = time.Duration(rand.ExpFloat64() * float64(p.Mean.Duration)) //nolint:gosec
+	default:
+		return p.Fixed.TimeDuration()
+	}
+	if p.Max.Valid && delay > p.Max.TimeDuration() {
+		delay = p.Max.TimeDuration()
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	return delay
+}
+
+// UnmarshalJSON accepts either a fixed duration or an object with the
+// distribution of the delays.
+func (p *Pacing) UnmarshalJSON(data []byte) error {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var distribution pacingDistribution
+		if err := lib.StrictJSONUnmarshal(trimmed, &distribution); err != nil {
+			return fmt.Errorf("invalid pacing distribution: %w", err)
+		}
+		*p = Pacing(distribution)
+		return nil
+	}
+	var fixed types.NullDuration
+	if err := json.Unmarshal(data, &fixed); err != nil {
+		return fmt.Errorf("pacing should be a duration or an object with a distribution of durations: %w", err)
+	}
+	*p = Pacing{Fixed: fixed}
+	return nil
+}
+
+// MarshalJSON returns the fixed duration if there is one, and the
+// distribution of the delays otherwise.
+func (p Pacing) MarshalJSON() ([]byte, error) {
+	if !p.Type.Valid {
+		return json.Marshal(p.Fixed)
+	}
+	return json.Marshal(pacingDistribution(p))
+}
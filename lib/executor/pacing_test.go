@@ -0,0 +1,115 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package executor
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/lib/types"
+)
+
+func TestPacingDelays(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		pacing   Pacing
+		min, max time.Duration
+		mean     time.Duration
+	}{
+		{
+			name:   "fixed",
+			pacing: Pacing{Fixed: types.NullDurationFrom(time.Second)},
+			min:    time.Second, max: time.Second, mean: time.Second,
+		},
+		{
+			name: "uniform",
+			pacing: Pacing{
+				Type: null.StringFrom(pacingUniform),
+				Min:  types.NullDurationFrom(time.Second), Max: types.NullDurationFrom(3 * time.Second),
+			},
+			min: time.Second, max: 3 * time.Second, mean: 2 * time.Second,
+		},
+		{
+			name: "normal",
+			pacing: Pacing{
+				Type: null.StringFrom(pacingNormal),
+				Mean: types.NullDurationFrom(2 * time.Second), StdDev: types.NullDurationFrom(500 * time.Millisecond),
+			},
+			min: 0, max: 5 * time.Second, mean: 2 * time.Second,
+		},
+		{
+			name: "exponential",
+			pacing: Pacing{
+				Type: null.StringFrom(pacingExponential),
+				Mean: types.NullDurationFrom(2 * time.Second), Max: types.NullDurationFrom(4 * time.Second),
+			},
+			min: 0, max: 4 * time.Second,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			require.Empty(t, tc.pacing.Validate())
+			const samples = 10000
+			var sum time.Duration
+			for i := 0; i < samples; i++ {
+				delay := tc.pacing.next()
+				require.GreaterOrEqual(t, delay, tc.min)
+				require.LessOrEqual(t, delay, tc.max)
+				sum += delay
+			}
+			if tc.mean > 0 {
+				assert.InDelta(t, float64(tc.mean), float64(sum/samples), float64(100*time.Millisecond))
+			}
+		})
+	}
+}
+
+func TestPacingJSON(t *testing.T) {
+	t.Parallel()
+
+	for _, data := range []string{
+		`"1.5s"`,
+		`{"type":"uniform","min":"1s","max":"3s","mean":null,"stdDev":null}`,
+		`{"type":"normal","min":null,"max":null,"mean":"2s","stdDev":"500ms"}`,
+	} {
+		var pacing Pacing
+		require.NoError(t, json.Unmarshal([]byte(data), &pacing))
+		assert.True(t, pacing.Valid())
+		marshaled, err := json.Marshal(pacing)
+		require.NoError(t, err)
+		assert.JSONEq(t, data, string(marshaled))
+	}
+
+	var pacing Pacing
+	require.NoError(t, json.Unmarshal([]byte(`1500`), &pacing))
+	assert.Equal(t, 1500*time.Millisecond, pacing.next())
+	require.NoError(t, json.Unmarshal([]byte(`null`), &pacing))
+	assert.False(t, pacing.Valid())
+}
@@ -200,7 +200,7 @@ func (pvi PerVUIterations) Run(
 	defer activeVUs.Wait()
 
 	regDurationDone := regDurationCtx.Done()
-	runIteration := getIterationRunner(pvi.executionState, pvi.logger)
+	runIteration := getLoopingIterationRunner(pvi.executionState, pvi.logger, pvi.config.GetPacing(), regDurationDone)
 
 	maxDurationCtx = lib.WithScenarioState(maxDurationCtx, &lib.ScenarioState{
 		Name:       pvi.config.Name,
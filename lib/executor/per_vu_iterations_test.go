@@ -157,3 +157,47 @@ func TestPerVuIterationsEmitDroppedIterations(t *testing.T) {
 	assert.Equal(t, int64(5), count)
 	assert.Equal(t, float64(95), sumMetricValues(engineOut, metrics.DroppedIterationsName))
 }
+
+func TestPerVUIterationsRunPacing(t *testing.T) {
+	t.Parallel()
+	var result sync.Map
+	config := PerVUIterationsConfig{
+		BaseConfig: BaseConfig{
+			GracefulStop: types.NullDurationFrom(1 * time.Second),
+			Pacing:       Pacing{Fixed: types.NullDurationFrom(100 * time.Millisecond)},
+		},
+		VUs:         null.IntFrom(2),
+		Iterations:  null.IntFrom(3),
+		MaxDuration: types.NullDurationFrom(3 * time.Second),
+	}
+	et, err := lib.NewExecutionTuple(nil, nil)
+	require.NoError(t, err)
+	es := lib.NewExecutionState(lib.Options{}, et, 2, 2)
+	ctx, cancel, executor, _ := setupExecutor(
+		t, config, es,
+		simpleRunner(func(ctx context.Context, state *lib.State) error {
+			starts, _ := result.LoadOrStore(state.VUID, []time.Time{})
+			result.Store(state.VUID, append(starts.([]time.Time), time.Now()))
+			return nil
+		}),
+	)
+	defer cancel()
+	engineOut := make(chan stats.SampleContainer, 1000)
+	registry := metrics.NewRegistry()
+	builtinMetrics := metrics.RegisterBuiltinMetrics(registry)
+	err = executor.Run(ctx, engineOut, builtinMetrics)
+	require.NoError(t, err)
+
+	var vus int
+	result.Range(func(key, value interface{}) bool {
+		starts := value.([]time.Time)
+		require.Len(t, starts, 3)
+		for i := 1; i < len(starts); i++ {
+			assert.GreaterOrEqual(t, starts[i].Sub(starts[i-1]), 100*time.Millisecond)
+		}
+		vus++
+		return true
+	})
+	assert.Equal(t, 2, vus)
+	assert.Equal(t, uint64(6), es.GetFullIterationCount())
+}
@@ -107,6 +107,9 @@ func (varc RampingArrivalRateConfig) GetDescription(et *lib.ExecutionTuple) stri
 // Validate makes sure all options are configured and valid
 func (varc *RampingArrivalRateConfig) Validate() []error {
 	errors := varc.BaseConfig.Validate()
+	if varc.Pacing.Valid() {
+		errors = append(errors, errArrivalRatePacing)
+	}
 
 	if varc.StartRate.Int64 < 0 {
 		errors = append(errors, fmt.Errorf("the startRate value shouldn't be negative"))
@@ -475,6 +478,9 @@ func (varr RampingArrivalRate) Run(
 			}
 		}
 
+		if varr.executionState.IsScenarioPaused(varr.config.Name) {
+			continue // the iterations of paused scenarios are skipped, not dropped
+		}
 		if vusPool.TryRunIteration() {
 			continue
 		}
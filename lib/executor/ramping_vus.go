@@ -537,7 +537,8 @@ func (vlv *RampingVUs) Run(ctx context.Context, _ chan<- stats.SampleContainer,
 		maxVUs:         maxVUs,
 		activeVUsCount: new(int64),
 		started:        startTime,
-		runIteration:   getIterationRunner(vlv.executionState, vlv.logger),
+		runIteration: getLoopingIterationRunner(
+			vlv.executionState, vlv.logger, vlv.config.GetPacing(), regularDurationCtx.Done()),
 	}
 
 	progressFn := runState.makeProgressFn(regularDuration)
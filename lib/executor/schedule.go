@@ -0,0 +1,181 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package executor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule is a parsed cron expression with the standard 5 fields: minute, hour, day of month,
+// month and day of week (0 or 7 being Sunday). Each field is a comma-separated list of *, values or
+// ranges like 1-5, optionally with steps like */15 or 8-18/2. The times are in the local time zone.
+type cronSchedule struct {
+	minute, hour, dayOfMonth, month, dayOfWeek uint64 // the bit sets of the matching values
+	// like in the standard cron, a day matches either day field if both are restricted
+	anyDayOfMonth, anyDayOfWeek bool
+}
+
+// cronMaxYears is how far in the future the next time of a schedule is looked for, since
+// expressions like "0 0 30 2 *" are valid, but never match.
+const cronMaxYears = 5
+
+// parseCronSchedule parses a cron expression, returning an error if it's invalid or never matches.
+func parseCronSchedule(expr string) (*cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("the cron expression '%s' should have 5 fields, "+
+			"for the minute, hour, day of month, month and day of week", expr)
+	}
+
+	cs := &cronSchedule{}
+	var err error
+	parsed := []struct {
+		bits     *uint64
+		any      *bool
+		min, max int
+	}{
+		{&cs.minute, nil, 0, 59},
+		{&cs.hour, nil, 0, 23},
+		{&cs.dayOfMonth, &cs.anyDayOfMonth, 1, 31},
+		{&cs.month, nil, 1, 12},
+		{&cs.dayOfWeek, &cs.anyDayOfWeek, 0, 7},
+	}
+	for i, p := range parsed {
+		var isAny bool
+		if *p.bits, isAny, err = parseCronField(fields[i], p.min, p.max); err != nil {
+			return nil, fmt.Errorf("invalid cron expression '%s': %w", expr, err)
+		}
+		if p.any != nil {
+			*p.any = isAny
+		}
+	}
+	if cs.dayOfWeek&(1<<7) != 0 { // Sunday can be both 0 and 7
+		cs.dayOfWeek |= 1
+	}
+
+	if cs.next(time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)).IsZero() {
+		return nil, fmt.Errorf("the cron expression '%s' never matches", expr)
+	}
+	return cs, nil
+}
+
+// parseCronField returns the bit set of the values which a field matches, and whether it's just *.
+func parseCronField(field string, min, max int) (bits uint64, isAny bool, err error) {
+	for _, item := range strings.Split(field, ",") {
+		rangeExpr, step := item, 1
+		if i := strings.IndexByte(item, '/'); i >= 0 {
+			rangeExpr = item[:i]
+			if step, err = strconv.Atoi(item[i+1:]); err != nil || step < 1 {
+				return 0, false, fmt.Errorf("invalid step in '%s'", item)
+			}
+		}
+
+		from, to := min, max
+		switch {
+		case rangeExpr == "*":
+			isAny = isAny || (item == "*")
+		case strings.Contains(rangeExpr, "-"):
+			parts := strings.SplitN(rangeExpr, "-", 2)
+			from, err = strconv.Atoi(parts[0])
+			if err == nil {
+				to, err = strconv.Atoi(parts[1])
+			}
+			if err != nil || from > to {
+				return 0, false, fmt.Errorf("invalid range '%s'", rangeExpr)
+			}
+		default:
+			if from, err = strconv.Atoi(rangeExpr); err != nil {
+				return 0, false, fmt.Errorf("invalid value '%s'", rangeExpr)
+			}
+			if step == 1 {
+				to = from
+			}
+		}
+		if from < min || to > max {
+			return 0, false, fmt.Errorf("'%s' isn't between %d and %d", item, min, max)
+		}
+
+		for v := from; v <= to; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, isAny, nil
+}
+
+// next returns the first time after the given one which matches the schedule, or the zero time if
+// there isn't one in the next cronMaxYears years.
+func (cs *cronSchedule) next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	loc := after.Location()
+	maxYear := t.Year() + cronMaxYears
+	for t.Year() <= maxYear {
+		switch {
+		case cs.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !cs.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case cs.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case cs.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (cs *cronSchedule) matchesDay(t time.Time) bool {
+	dayOfMonth := cs.dayOfMonth&(1<<uint(t.Day())) != 0
+	dayOfWeek := cs.dayOfWeek&(1<<uint(t.Weekday())) != 0
+	if cs.anyDayOfMonth || cs.anyDayOfWeek {
+		return dayOfMonth && dayOfWeek
+	}
+	return dayOfMonth || dayOfWeek
+}
+
+// startAtLayouts are the formats of the times of day of the startAt option, besides RFC3339 timestamps.
+var startAtLayouts = []string{"15:04", "15:04:05"} //nolint:gochecknoglobals
+
+// parseStartAt returns the time of the startAt option, either an RFC3339 timestamp or the first
+// time after the given one with the time of day, in the local time zone.
+func parseStartAt(value string, after time.Time) (time.Time, error) {
+	if at, err := time.Parse(time.RFC3339, value); err == nil {
+		return at, nil
+	}
+	for _, layout := range startAtLayouts {
+		timeOfDay, err := time.Parse(layout, value)
+		if err != nil {
+			continue
+		}
+		at := time.Date(after.Year(), after.Month(), after.Day(),
+			timeOfDay.Hour(), timeOfDay.Minute(), timeOfDay.Second(), 0, after.Location())
+		if !at.After(after) {
+			at = at.AddDate(0, 0, 1)
+		}
+		return at, nil
+	}
+	return time.Time{}, fmt.Errorf("the startAt value '%s' should be a time of day like 02:00 "+
+		"or a timestamp like 2006-01-02T15:04:05Z07:00", value)
+}
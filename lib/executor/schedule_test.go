@@ -0,0 +1,99 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package executor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v3"
+)
+
+func TestCronSchedule(t *testing.T) {
+	t.Parallel()
+
+	// a Wednesday
+	after := time.Date(2021, time.September, 15, 14, 30, 20, 0, time.UTC)
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2021, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	testCases := []struct {
+		expr string
+		next time.Time
+	}{
+		{"* * * * *", at(time.September, 15, 14, 31)},
+		{"0 2 * * *", at(time.September, 16, 2, 0)},
+		{"*/15 * * * *", at(time.September, 15, 14, 45)},
+		{"10-20/5 9-17 * * *", at(time.September, 15, 15, 10)},
+		{"0 0 1 * *", at(time.October, 1, 0, 0)},
+		{"30 8 * * 1-5", at(time.September, 16, 8, 30)},
+		{"0 12 * * 7", at(time.September, 19, 12, 0)},
+		{"0 12 * * 0", at(time.September, 19, 12, 0)},
+		{"0 0 20 * 6", at(time.September, 18, 0, 0)}, // either day field matches
+		{"0 0 29 2 *", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"0,45 14 15 9 *", at(time.September, 15, 14, 45)},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.expr, func(t *testing.T) {
+			t.Parallel()
+			schedule, err := parseCronSchedule(tc.expr)
+			require.NoError(t, err)
+			assert.Equal(t, tc.next, schedule.next(after))
+		})
+	}
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *",
+		"* * * * 8", "5-1 * * * *", "*/0 * * * *", "a * * * *", "0 0 30 2 *"} {
+		_, err := parseCronSchedule(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestStartAt(t *testing.T) {
+	t.Parallel()
+
+	after := time.Date(2021, time.September, 15, 14, 30, 20, 0, time.UTC)
+
+	at, err := parseStartAt("02:00", after)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2021, time.September, 16, 2, 0, 0, 0, time.UTC), at)
+
+	at, err = parseStartAt("14:30:30", after)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2021, time.September, 15, 14, 30, 30, 0, time.UTC), at)
+
+	at, err = parseStartAt("2021-09-20T10:00:00+02:00", after)
+	require.NoError(t, err)
+	assert.True(t, time.Date(2021, time.September, 20, 8, 0, 0, 0, time.UTC).Equal(at))
+
+	_, err = parseStartAt("2am", after)
+	assert.Error(t, err)
+
+	_, ok := BaseConfig{}.GetScheduledStart(after)
+	assert.False(t, ok)
+	at, ok = BaseConfig{StartCron: null.StringFrom("0 2 * * *")}.GetScheduledStart(after)
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2021, time.September, 16, 2, 0, 0, 0, time.UTC), at)
+}
@@ -234,7 +234,7 @@ func (si SharedIterations) Run(
 	}()
 
 	regDurationDone := regDurationCtx.Done()
-	runIteration := getIterationRunner(si.executionState, si.logger)
+	runIteration := getLoopingIterationRunner(si.executionState, si.logger, si.config.GetPacing(), regDurationDone)
 
 	maxDurationCtx = lib.WithScenarioState(maxDurationCtx, &lib.ScenarioState{
 		Name:       si.config.Name,
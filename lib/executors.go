@@ -30,6 +30,7 @@ import (
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"gopkg.in/guregu/null.v3"
 
 	"go.k6.io/k6/lib/metrics"
 	"go.k6.io/k6/stats"
@@ -81,6 +82,16 @@ type ExecutorConfig interface {
 	GetName() string
 	GetType() string
 	GetStartTime() time.Duration
+	// Returns the names of the scenarios after whose end the executor starts, at its start time
+	// from then, and whether their iterations need to have completed successfully for it to start.
+	GetStartAfter() (scenarios []string, successfully bool)
+	// Returns the wall-clock time at which the executor is scheduled to start
+	// instead of at its start time, for a test started at the given time.
+	GetScheduledStart(testStart time.Time) (time.Time, bool)
+	// Return the names of the functions which are run before and after the
+	// executor, if any, and their timeouts, which are 0 for the default ones.
+	GetSetup() (fn string, timeout time.Duration)
+	GetTeardown() (fn string, timeout time.Duration)
 	GetGracefulStop() time.Duration
 
 	// This is used to validate whether a particular script can run in the cloud
@@ -94,6 +105,9 @@ type ExecutorConfig interface {
 	//
 	// TODO: use interface{} so plain http requests can be specified?
 	GetExec() string
+	// Returns the weights of the functions which the iterations are spread
+	// over, if the executor has multiple ones instead of GetExec().
+	GetExecWeights() map[string]int64
 	GetTags() map[string]string
 
 	// Calculates the VU requirements in different stages of the executor's
@@ -146,6 +160,21 @@ type LiveUpdatableExecutor interface {
 	UpdateConfig(ctx context.Context, newConfig interface{}) error
 }
 
+// ScalableExecutor should be implemented by the executors whose number of VUs
+// or iteration rate can be changed on their own in the middle of the test
+// execution, through the ScaleScenario() method of the ExecutionState.
+type ScalableExecutor interface {
+	Scale(ctx context.Context, scale ScenarioScale) error
+}
+
+// ScenarioScale is a change of the VUs or of the iteration rate of a running
+// scenario. Only its valid fields are changed.
+type ScenarioScale struct {
+	VUs    null.Int
+	MaxVUs null.Int
+	Rate   null.Float
+}
+
 // ExecutorConfigConstructor is a simple function that returns a concrete
 // Config instance with the specified name and all default values correctly
 // initialized
@@ -213,10 +242,147 @@ func (scs ScenarioConfigs) Validate() (errors []error) {
 			errors = append(errors,
 				fmt.Errorf("scenario %s has configuration errors: %s", name, ConcatErrors(execErr, ", ")))
 		}
+		startAfter, _ := exec.GetStartAfter()
+		for _, dep := range startAfter {
+			switch _, ok := scs[dep]; {
+			case dep == name:
+				errors = append(errors, fmt.Errorf("scenario %s can't start after itself", name))
+			case !ok:
+				errors = append(errors, fmt.Errorf("scenario %s starts after the unknown scenario %s", name, dep))
+			}
+		}
+	}
+	if len(errors) == 0 {
+		if cycle := scs.findStartAfterCycle(); cycle != nil {
+			errors = append(errors, fmt.Errorf(
+				"the startAfter scenarios have a cycle: %s", strings.Join(cycle, " -> ")))
+		}
 	}
 	return errors
 }
 
+// findStartAfterCycle returns the names of the scenarios in a cycle of
+// startAfter dependencies, if there is any.
+func (scs ScenarioConfigs) findStartAfterCycle() []string {
+	names := make([]string, 0, len(scs))
+	for name := range scs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	const visiting, visited = 1, 2
+	state := make(map[string]int, len(scs))
+	var path []string
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			for i, n := range path {
+				if n == name {
+					return append(append([]string{}, path[i:]...), name)
+				}
+			}
+		}
+		state[name] = visiting
+		path = append(path, name)
+		startAfter, _ := scs[name].GetStartAfter()
+		for _, dep := range startAfter {
+			if _, ok := scs[dep]; !ok {
+				continue
+			}
+			if cycle := visit(dep); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		return nil
+	}
+	for _, name := range names {
+		if cycle := visit(name); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+// GetStartTimes returns the planned start times of all scenarios. For the
+// ones without startAfter, that is simply their startTime. The others start
+// their startTime after the latest planned end of their startAfter scenarios,
+// at the latest, since they start after their actual end, which can be earlier.
+// The scenarios scheduled at wall-clock times are planned as if the test
+// started now.
+//
+// The ends are always calculated for the full execution, so all instances of
+// a distributed test agree on them, regardless of their execution segments.
+func (scs ScenarioConfigs) GetStartTimes() map[string]time.Duration {
+	return scs.getStartTimes(true)
+}
+
+// getStartTimes returns the latest start times of GetStartTimes, or the
+// earliest ones, if the startAfter scenarios ended right after their start.
+func (scs ScenarioConfigs) getStartTimes(latest bool) map[string]time.Duration {
+	et, _ := NewExecutionTuple(nil, nil) // this can't fail for the full execution
+	now := time.Now()
+	result := make(map[string]time.Duration, len(scs))
+	var resolve func(name string) time.Duration
+	resolve = func(name string) time.Duration {
+		if start, ok := result[name]; ok {
+			return start
+		}
+		config := scs[name]
+		if at, ok := config.GetScheduledStart(now); ok {
+			result[name] = 0 // for timestamps in the past
+			if start := at.Sub(now); start > 0 {
+				result[name] = start
+			}
+			return result[name]
+		}
+		result[name] = config.GetStartTime() // guards against cycles, which Validate() reports
+		startAfter, _ := config.GetStartAfter()
+		var depsEnd time.Duration
+		for _, dep := range startAfter {
+			depConfig, ok := scs[dep]
+			if !ok {
+				continue
+			}
+			var depEnd time.Duration
+			if latest {
+				depEnd, _ = GetEndOffset(depConfig.GetExecutionRequirements(et))
+			}
+			if end := resolve(dep) + depEnd; end > depsEnd {
+				depsEnd = end
+			}
+		}
+		result[name] = depsEnd + config.GetStartTime()
+		return result[name]
+	}
+	for name := range scs {
+		resolve(name)
+	}
+	return result
+}
+
+// reserveMaxVUs returns the steps of a scenario that can start anywhere in a
+// window of the given length, which keep its max VUs from the start of the
+// window until its latest end.
+func reserveMaxVUs(steps []ExecutionStep, window time.Duration) []ExecutionStep {
+	reserved := ExecutionStep{}
+	for _, step := range steps {
+		if step.PlannedVUs > reserved.PlannedVUs {
+			reserved.PlannedVUs = step.PlannedVUs
+		}
+		if step.MaxUnplannedVUs > reserved.MaxUnplannedVUs {
+			reserved.MaxUnplannedVUs = step.MaxUnplannedVUs
+		}
+	}
+	end := steps[len(steps)-1]
+	end.TimeOffset += window
+	return []ExecutionStep{reserved, end}
+}
+
 // GetSortedConfigs returns a slice with the executor configurations,
 // sorted in a consistent and predictable manner. It is useful when we want or
 // have to avoid using maps with string keys (and tons of string lookups in
@@ -228,6 +394,7 @@ func (scs ScenarioConfigs) Validate() (errors []error) {
 // there are ties.
 func (scs ScenarioConfigs) GetSortedConfigs() []ExecutorConfig {
 	configs := make([]ExecutorConfig, len(scs))
+	startTimes := scs.GetStartTimes()
 
 	// Populate the configs slice with sorted executor configs
 	i := 0
@@ -237,9 +404,9 @@ func (scs ScenarioConfigs) GetSortedConfigs() []ExecutorConfig {
 	}
 	sort.Slice(configs, func(a, b int) bool { // sort by (start time, name)
 		switch {
-		case configs[a].GetStartTime() < configs[b].GetStartTime():
+		case startTimes[configs[a].GetName()] < startTimes[configs[b].GetName()]:
 			return true
-		case configs[a].GetStartTime() == configs[b].GetStartTime():
+		case startTimes[configs[a].GetName()] == startTimes[configs[b].GetName()]:
 			return strings.Compare(configs[a].GetName(), configs[b].GetName()) < 0
 		default:
 			return false
@@ -255,6 +422,8 @@ func (scs ScenarioConfigs) GetSortedConfigs() []ExecutorConfig {
 // moment in the test execution.
 func (scs ScenarioConfigs) GetFullExecutionRequirements(et *ExecutionTuple) []ExecutionStep {
 	sortedConfigs := scs.GetSortedConfigs()
+	startTimes := scs.GetStartTimes()
+	earliestStartTimes := scs.getStartTimes(false)
 
 	// Combine the steps and requirements from all different executors, and
 	// sort them by their time offset, counting the executors' startTimes as
@@ -265,8 +434,13 @@ func (scs ScenarioConfigs) GetFullExecutionRequirements(et *ExecutionTuple) []Ex
 	}
 	trackedSteps := []trackedStep{}
 	for configID, config := range sortedConfigs { // orderly iteration over a slice
-		configStartTime := config.GetStartTime()
+		configStartTime := startTimes[config.GetName()]
 		configSteps := config.GetExecutionRequirements(et)
+		if earliest := earliestStartTimes[config.GetName()]; earliest < configStartTime && len(configSteps) > 0 {
+			// the scenario starts after the actual end of its startAfter scenarios, anywhere between both
+			configSteps = reserveMaxVUs(configSteps, configStartTime-earliest)
+			configStartTime = earliest
+		}
 		for _, cs := range configSteps {
 			cs.TimeOffset += configStartTime // add the executor start time to the step time offset
 			trackedSteps = append(trackedSteps, trackedStep{cs, configID})
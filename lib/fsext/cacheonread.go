@@ -36,6 +36,7 @@ var ErrPathNeverRequestedBefore = errors.New("path never requested before")
 // that is used as cache
 type CacheOnReadFs struct {
 	afero.Fs
+	base  afero.Fs
 	cache afero.Fs
 
 	lock       *sync.Mutex
@@ -54,10 +55,16 @@ type CacheLayerGetter interface {
 	GetCachingFs() afero.Fs
 }
 
+// BaseLayerGetter provide a direct access to the underlying filesystem, bypassing the cache
+type BaseLayerGetter interface {
+	GetBaseFs() afero.Fs
+}
+
 // NewCacheOnReadFs returns a new CacheOnReadFs
 func NewCacheOnReadFs(base, layer afero.Fs, cacheTime time.Duration) afero.Fs {
 	return &CacheOnReadFs{
 		Fs:    afero.NewCacheOnReadFs(base, layer, cacheTime),
+		base:  base,
 		cache: layer,
 
 		lock:       &sync.Mutex{},
@@ -71,6 +78,11 @@ func (c *CacheOnReadFs) GetCachingFs() afero.Fs {
 	return c.cache
 }
 
+// GetBaseFs returns the afero.Fs being cached, reading from it doesn't fill the cache
+func (c *CacheOnReadFs) GetBaseFs() afero.Fs {
+	return c.base
+}
+
 // AllowOnlyCached enables the cached only mode of the CacheOnReadFs
 func (c *CacheOnReadFs) AllowOnlyCached() {
 	c.lock.Lock()
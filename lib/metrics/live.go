@@ -0,0 +1,101 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"go.k6.io/k6/stats"
+)
+
+// LiveMetricsRetention is the longest window for which the live metrics can be queried.
+const LiveMetricsRetention = 5 * time.Minute
+
+// LiveMetrics aggregates the samples of the metrics per second, for the last LiveMetricsRetention,
+// so the scripts can query the values of the metrics over a recent window while the test is running.
+type LiveMetrics struct {
+	mu      sync.Mutex
+	metrics map[string]*stats.WindowSink
+	now     func() time.Time
+	// trendRelativeError is the relative error of the percentiles of the Trends
+	trendRelativeError float64
+}
+
+// NewLiveMetrics returns new empty LiveMetrics.
+func NewLiveMetrics() *LiveMetrics {
+	return &LiveMetrics{metrics: make(map[string]*stats.WindowSink), now: time.Now}
+}
+
+// SetTrendRelativeError sets the relative error of the percentiles of the Trends, like the one of the
+// trendRelativeError option, for the metrics which don't have samples yet.
+func (l *LiveMetrics) SetTrendRelativeError(relativeError float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.trendRelativeError = relativeError
+}
+
+// Add adds the samples, the seconds which are older than the retention are dropped.
+func (l *LiveMetrics) Add(sampleContainers []stats.SampleContainer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, sc := range sampleContainers {
+		for _, sample := range sc.GetSamples() {
+			w, ok := l.metrics[sample.Metric.Name]
+			if !ok {
+				w = stats.NewWindowSink(sample.Metric, LiveMetricsRetention, l.trendRelativeError)
+				l.metrics[sample.Metric.Name] = w
+			}
+			w.Add(sample)
+		}
+	}
+}
+
+// Query returns the values of the metric over the window before now, in the same format as the thresholds,
+// e.g. count and rate for a Counter, or the percentiles p(90), p(95) and p(99) with the others of a Trend.
+// The window is capped to the retention, and false is returned if the metric has no samples in it.
+func (l *LiveMetrics) Query(name string, window time.Duration) (map[string]float64, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	w, ok := l.metrics[name]
+	if !ok {
+		return nil, false
+	}
+	sink, elapsed, ok := w.Aggregate(l.now(), window)
+	if !ok {
+		return nil, false
+	}
+
+	values := sink.Format(elapsed)
+	switch sink := sink.(type) {
+	case *stats.TrendSink:
+		values["count"] = float64(sink.Count)
+		values["p(99)"] = sink.P(0.99)
+	case *stats.HistogramSink:
+		values["p(99)"] = sink.P(0.99)
+	case *stats.RateSink:
+		values["passes"] = float64(sink.Trues)
+		values["fails"] = float64(sink.Total - sink.Trues)
+	}
+	return values, true
+}
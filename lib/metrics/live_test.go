@@ -0,0 +1,98 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/stats"
+)
+
+func TestLiveMetricsQuery(t *testing.T) {
+	t.Parallel()
+
+	registry := NewRegistry()
+	counter := registry.MustNewMetric("counter", stats.Counter)
+	gauge := registry.MustNewMetric("gauge", stats.Gauge)
+	trend := registry.MustNewMetric("trend", stats.Trend)
+	rate := registry.MustNewMetric("rate", stats.Rate)
+
+	now := time.Unix(1000, 0)
+	live := registry.LiveMetrics()
+	live.now = func() time.Time { return now }
+
+	start := now.Add(-10 * time.Minute)
+	var samples stats.Samples
+	// a sample of each metric every second for 10 minutes, the values of the last minute are greater
+	for i := 0; i < 600; i++ {
+		sampleTime := start.Add(time.Duration(i) * time.Second)
+		value := 1.0
+		if i >= 540 {
+			value = 2.0
+		}
+		samples = append(samples,
+			stats.Sample{Metric: counter, Time: sampleTime, Value: value},
+			stats.Sample{Metric: gauge, Time: sampleTime, Value: value * 10},
+			stats.Sample{Metric: trend, Time: sampleTime, Value: value * 100},
+			stats.Sample{Metric: rate, Time: sampleTime, Value: value - 1},
+		)
+	}
+	live.Add([]stats.SampleContainer{samples})
+
+	values, ok := live.Query("counter", time.Minute)
+	require.True(t, ok)
+	assert.Equal(t, 120.0, values["count"])
+	assert.Equal(t, 2.0, values["rate"])
+
+	values, ok = live.Query("gauge", time.Minute)
+	require.True(t, ok)
+	assert.Equal(t, 20.0, values["value"])
+
+	values, ok = live.Query("trend", 2*time.Minute)
+	require.True(t, ok)
+	assert.Equal(t, 120.0, values["count"])
+	assert.Equal(t, 100.0, values["min"])
+	assert.Equal(t, 200.0, values["max"])
+	assert.InDelta(t, 200.0, values["p(95)"], 2)
+	assert.Equal(t, 150.0, values["avg"])
+
+	values, ok = live.Query("rate", 2*time.Minute)
+	require.True(t, ok)
+	assert.Equal(t, 0.5, values["rate"])
+	assert.Equal(t, 60.0, values["passes"])
+	assert.Equal(t, 60.0, values["fails"])
+
+	// the window is capped to the retention, whose samples are the only ones that are kept
+	values, ok = live.Query("counter", time.Hour)
+	require.True(t, ok)
+	assert.Equal(t, 360.0, values["count"])
+
+	_, ok = live.Query("unknown", time.Minute)
+	assert.False(t, ok)
+
+	now = now.Add(2 * time.Minute)
+	_, ok = live.Query("counter", time.Minute)
+	assert.False(t, ok)
+}
@@ -44,14 +44,87 @@ const (
 	HTTPReqWaitingName        = "http_req_waiting"
 	HTTPReqReceivingName      = "http_req_receiving"
 
+	HTTPStreamFirstChunkName = "http_stream_first_chunk"
+	HTTPStreamThroughputName = "http_stream_throughput"
+
+	HTTPConnsOpenName    = "http_conns_open"
+	HTTPConnsIdleName    = "http_conns_idle"
+	HTTPReqsInFlightName = "http_reqs_in_flight"
+
 	WSSessionsName         = "ws_sessions"
 	WSMessagesSentName     = "ws_msgs_sent"
 	WSMessagesReceivedName = "ws_msgs_received"
 	WSPingName             = "ws_ping"
 	WSSessionDurationName  = "ws_session_duration"
 	WSConnectingName       = "ws_connecting"
+	WSReconnectsName       = "ws_reconnects"
+
+	WSMessagesDataSentName     = "ws_msgs_data_sent"
+	WSMessagesDataReceivedName = "ws_msgs_data_received"
+	WSWireDataSentName         = "ws_wire_data_sent"
+	WSWireDataReceivedName     = "ws_wire_data_received"
+
+	WSFramesReceivedName = "ws_frames_received"
+	WSFrameReceivingName = "ws_frame_receiving"
+
+	GRPCReqDurationName             = "grpc_req_duration"
+	GRPCStreamsName                 = "grpc_streams"
+	GRPCStreamsMessagesSentName     = "grpc_streams_msgs_sent"
+	GRPCStreamsMessagesReceivedName = "grpc_streams_msgs_received"
+	GRPCStreamDurationName          = "grpc_stream_duration"
+
+	SSEEventsName           = "sse_events"
+	SSETimeToFirstEventName = "sse_time_to_first_event"
+
+	MQTTConnectingName       = "mqtt_connecting"
+	MQTTPublishDurationName  = "mqtt_publish_duration"
+	MQTTPingName             = "mqtt_ping"
+	MQTTMessagesSentName     = "mqtt_msgs_sent"
+	MQTTMessagesReceivedName = "mqtt_msgs_received"
+
+	KafkaProduceDurationName  = "kafka_produce_duration"
+	KafkaMessagesProducedName = "kafka_msgs_produced"
+	KafkaMessagesConsumedName = "kafka_msgs_consumed"
+	KafkaEndToEndLatencyName  = "kafka_e2e_latency"
+
+	AMQPPublishConfirmDurationName = "amqp_publish_confirm_duration"
+	AMQPMessagesPublishedName      = "amqp_msgs_published"
+	AMQPMessagesConsumedName       = "amqp_msgs_consumed"
+
+	TCPConnectingName     = "tcp_connecting"
+	TCPTLSHandshakingName = "tcp_tls_handshaking"
+	TCPReadDurationName   = "tcp_read_duration"
+	TCPBytesSentName      = "tcp_bytes_sent"
+	TCPBytesReceivedName  = "tcp_bytes_received"
+
+	UDPPacketsSentName     = "udp_packets_sent"
+	UDPPacketsReceivedName = "udp_packets_received"
+	UDPRTTName             = "udp_rtt"
+	UDPPacketLossName      = "udp_packet_loss"
+
+	DNSLookupDurationName = "dns_lookup_duration"
+	DNSLookupFailedName   = "dns_lookup_failed"
 
-	GRPCReqDurationName = "grpc_req_duration"
+	GraphQLReqDurationName        = "graphql_req_duration"
+	GraphQLReqFailedName          = "graphql_req_failed"
+	GraphQLSubscriptionEventsName = "graphql_subscription_events"
+
+	SQLQueryDurationName = "sql_query_duration"
+	SQLQueryFailedName   = "sql_query_failed"
+
+	ThriftReqDurationName = "thrift_req_duration"
+	ThriftReqFailedName   = "thrift_req_failed"
+
+	NATSPublishDurationName  = "nats_publish_duration"
+	NATSDeliveryLagName      = "nats_delivery_lag"
+	NATSMessagesSentName     = "nats_msgs_sent"
+	NATSMessagesReceivedName = "nats_msgs_received"
+
+	PerformanceMeasureName = "performance_measure"
+	LeakedTimersName       = "leaked_timers"
+
+	OutputBufferedSamplesName = "output_buffered_samples"
+	OutputDroppedSamplesName  = "output_dropped_samples"
 
 	DataSentName     = "data_sent"
 	DataReceivedName = "data_received"
@@ -80,6 +153,15 @@ type BuiltinMetrics struct {
 	HTTPReqWaiting        *stats.Metric
 	HTTPReqReceiving      *stats.Metric
 
+	// Emitted while reading the bodies of the responses with the stream response type
+	HTTPStreamFirstChunk *stats.Metric
+	HTTPStreamThroughput *stats.Metric
+
+	// The connection pool of a VU for the host of a request, emitted once the request is done
+	HTTPConnsOpen    *stats.Metric
+	HTTPConnsIdle    *stats.Metric
+	HTTPReqsInFlight *stats.Metric
+
 	// Websocket-related
 	WSSessions         *stats.Metric
 	WSMessagesSent     *stats.Metric
@@ -87,9 +169,90 @@ type BuiltinMetrics struct {
 	WSPing             *stats.Metric
 	WSSessionDuration  *stats.Metric
 	WSConnecting       *stats.Metric
+	WSReconnects       *stats.Metric
+	// the payloads of the messages, before their compression and after their decompression
+	WSMessagesDataSent     *stats.Metric
+	WSMessagesDataReceived *stats.Metric
+	// the frames after the handshake, with their payloads compressed if permessage-deflate was negotiated
+	WSWireDataSent     *stats.Metric
+	WSWireDataReceived *stats.Metric
+	// the chunks of the binary messages that are streamed, which are their frames when they aren't compressed
+	WSFramesReceived *stats.Metric
+	WSFrameReceiving *stats.Metric
 
 	// gRPC-related
-	GRPCReqDuration *stats.Metric
+	GRPCReqDuration             *stats.Metric
+	GRPCStreams                 *stats.Metric
+	GRPCStreamsMessagesSent     *stats.Metric
+	GRPCStreamsMessagesReceived *stats.Metric
+	GRPCStreamDuration          *stats.Metric
+
+	// Server-Sent Events, emitted by k6/experimental/sse
+	SSEEvents           *stats.Metric
+	SSETimeToFirstEvent *stats.Metric
+
+	// MQTT-related, emitted by k6/experimental/mqtt
+	MQTTConnecting       *stats.Metric
+	MQTTPublishDuration  *stats.Metric
+	MQTTPing             *stats.Metric
+	MQTTMessagesSent     *stats.Metric
+	MQTTMessagesReceived *stats.Metric
+
+	// Kafka-related, emitted by k6/experimental/kafka
+	KafkaProduceDuration  *stats.Metric
+	KafkaMessagesProduced *stats.Metric
+	KafkaMessagesConsumed *stats.Metric
+	KafkaEndToEndLatency  *stats.Metric
+
+	// AMQP-related, emitted by k6/experimental/amqp
+	AMQPPublishConfirmDuration *stats.Metric
+	AMQPMessagesPublished      *stats.Metric
+	AMQPMessagesConsumed       *stats.Metric
+
+	// TCP-related, emitted by k6/experimental/tcp
+	TCPConnecting     *stats.Metric
+	TCPTLSHandshaking *stats.Metric
+	TCPReadDuration   *stats.Metric
+	TCPBytesSent      *stats.Metric
+	TCPBytesReceived  *stats.Metric
+
+	// UDP-related, emitted by k6/experimental/udp
+	UDPPacketsSent     *stats.Metric
+	UDPPacketsReceived *stats.Metric
+	UDPRTT             *stats.Metric
+	UDPPacketLoss      *stats.Metric
+
+	// DNS-related, emitted by k6/experimental/dns
+	DNSLookupDuration *stats.Metric
+	DNSLookupFailed   *stats.Metric
+
+	// GraphQL-related, emitted by k6/experimental/graphql
+	GraphQLReqDuration        *stats.Metric
+	GraphQLReqFailed          *stats.Metric
+	GraphQLSubscriptionEvents *stats.Metric
+
+	// SQL-related, emitted by k6/experimental/sql
+	SQLQueryDuration *stats.Metric
+	SQLQueryFailed   *stats.Metric
+
+	// Thrift-related, emitted by k6/experimental/thrift
+	ThriftReqDuration *stats.Metric
+	ThriftReqFailed   *stats.Metric
+
+	// NATS-related, emitted by k6/experimental/nats
+	NATSPublishDuration  *stats.Metric
+	NATSDeliveryLag      *stats.Metric
+	NATSMessagesSent     *stats.Metric
+	NATSMessagesReceived *stats.Metric
+
+	// Emitted by performance.measure()
+	PerformanceMeasure *stats.Metric
+	// Timers and immediates that were still pending when an iteration ended
+	LeakedTimers *stats.Metric
+
+	// Emitted by the engine for the outputs with a limited buffer
+	OutputBufferedSamples *stats.Metric
+	OutputDroppedSamples  *stats.Metric
 
 	// Network-related; used for future protocols as well.
 	DataSent     *stats.Metric
@@ -118,14 +281,86 @@ func RegisterBuiltinMetrics(registry *Registry) *BuiltinMetrics {
 		HTTPReqWaiting:        registry.MustNewMetric(HTTPReqWaitingName, stats.Trend, stats.Time),
 		HTTPReqReceiving:      registry.MustNewMetric(HTTPReqReceivingName, stats.Trend, stats.Time),
 
+		HTTPStreamFirstChunk: registry.MustNewMetric(HTTPStreamFirstChunkName, stats.Trend, stats.Time),
+		HTTPStreamThroughput: registry.MustNewMetric(HTTPStreamThroughputName, stats.Trend, stats.Data),
+
+		HTTPConnsOpen:    registry.MustNewMetric(HTTPConnsOpenName, stats.Gauge),
+		HTTPConnsIdle:    registry.MustNewMetric(HTTPConnsIdleName, stats.Gauge),
+		HTTPReqsInFlight: registry.MustNewMetric(HTTPReqsInFlightName, stats.Gauge),
+
 		WSSessions:         registry.MustNewMetric(WSSessionsName, stats.Counter),
 		WSMessagesSent:     registry.MustNewMetric(WSMessagesSentName, stats.Counter),
 		WSMessagesReceived: registry.MustNewMetric(WSMessagesReceivedName, stats.Counter),
 		WSPing:             registry.MustNewMetric(WSPingName, stats.Trend, stats.Time),
 		WSSessionDuration:  registry.MustNewMetric(WSSessionDurationName, stats.Trend, stats.Time),
 		WSConnecting:       registry.MustNewMetric(WSConnectingName, stats.Trend, stats.Time),
+		WSReconnects:       registry.MustNewMetric(WSReconnectsName, stats.Counter),
+
+		WSMessagesDataSent:     registry.MustNewMetric(WSMessagesDataSentName, stats.Counter, stats.Data),
+		WSMessagesDataReceived: registry.MustNewMetric(WSMessagesDataReceivedName, stats.Counter, stats.Data),
+		WSWireDataSent:         registry.MustNewMetric(WSWireDataSentName, stats.Counter, stats.Data),
+		WSWireDataReceived:     registry.MustNewMetric(WSWireDataReceivedName, stats.Counter, stats.Data),
+		WSFramesReceived:       registry.MustNewMetric(WSFramesReceivedName, stats.Counter),
+		WSFrameReceiving:       registry.MustNewMetric(WSFrameReceivingName, stats.Trend, stats.Time),
+
+		GRPCReqDuration:             registry.MustNewMetric(GRPCReqDurationName, stats.Trend, stats.Time),
+		GRPCStreams:                 registry.MustNewMetric(GRPCStreamsName, stats.Counter),
+		GRPCStreamsMessagesSent:     registry.MustNewMetric(GRPCStreamsMessagesSentName, stats.Counter),
+		GRPCStreamsMessagesReceived: registry.MustNewMetric(GRPCStreamsMessagesReceivedName, stats.Counter),
+		GRPCStreamDuration:          registry.MustNewMetric(GRPCStreamDurationName, stats.Trend, stats.Time),
+
+		SSEEvents:           registry.MustNewMetric(SSEEventsName, stats.Counter),
+		SSETimeToFirstEvent: registry.MustNewMetric(SSETimeToFirstEventName, stats.Trend, stats.Time),
+
+		MQTTConnecting:       registry.MustNewMetric(MQTTConnectingName, stats.Trend, stats.Time),
+		MQTTPublishDuration:  registry.MustNewMetric(MQTTPublishDurationName, stats.Trend, stats.Time),
+		MQTTPing:             registry.MustNewMetric(MQTTPingName, stats.Trend, stats.Time),
+		MQTTMessagesSent:     registry.MustNewMetric(MQTTMessagesSentName, stats.Counter),
+		MQTTMessagesReceived: registry.MustNewMetric(MQTTMessagesReceivedName, stats.Counter),
+
+		KafkaProduceDuration:  registry.MustNewMetric(KafkaProduceDurationName, stats.Trend, stats.Time),
+		KafkaMessagesProduced: registry.MustNewMetric(KafkaMessagesProducedName, stats.Counter),
+		KafkaMessagesConsumed: registry.MustNewMetric(KafkaMessagesConsumedName, stats.Counter),
+		KafkaEndToEndLatency:  registry.MustNewMetric(KafkaEndToEndLatencyName, stats.Trend, stats.Time),
+
+		AMQPPublishConfirmDuration: registry.MustNewMetric(AMQPPublishConfirmDurationName, stats.Trend, stats.Time),
+		AMQPMessagesPublished:      registry.MustNewMetric(AMQPMessagesPublishedName, stats.Counter),
+		AMQPMessagesConsumed:       registry.MustNewMetric(AMQPMessagesConsumedName, stats.Counter),
+
+		TCPConnecting:     registry.MustNewMetric(TCPConnectingName, stats.Trend, stats.Time),
+		TCPTLSHandshaking: registry.MustNewMetric(TCPTLSHandshakingName, stats.Trend, stats.Time),
+		TCPReadDuration:   registry.MustNewMetric(TCPReadDurationName, stats.Trend, stats.Time),
+		TCPBytesSent:      registry.MustNewMetric(TCPBytesSentName, stats.Counter, stats.Data),
+		TCPBytesReceived:  registry.MustNewMetric(TCPBytesReceivedName, stats.Counter, stats.Data),
+
+		UDPPacketsSent:     registry.MustNewMetric(UDPPacketsSentName, stats.Counter),
+		UDPPacketsReceived: registry.MustNewMetric(UDPPacketsReceivedName, stats.Counter),
+		UDPRTT:             registry.MustNewMetric(UDPRTTName, stats.Trend, stats.Time),
+		UDPPacketLoss:      registry.MustNewMetric(UDPPacketLossName, stats.Rate),
+
+		DNSLookupDuration: registry.MustNewMetric(DNSLookupDurationName, stats.Trend, stats.Time),
+		DNSLookupFailed:   registry.MustNewMetric(DNSLookupFailedName, stats.Rate),
+
+		GraphQLReqDuration:        registry.MustNewMetric(GraphQLReqDurationName, stats.Trend, stats.Time),
+		GraphQLReqFailed:          registry.MustNewMetric(GraphQLReqFailedName, stats.Rate),
+		GraphQLSubscriptionEvents: registry.MustNewMetric(GraphQLSubscriptionEventsName, stats.Counter),
+
+		SQLQueryDuration: registry.MustNewMetric(SQLQueryDurationName, stats.Trend, stats.Time),
+		SQLQueryFailed:   registry.MustNewMetric(SQLQueryFailedName, stats.Rate),
+
+		ThriftReqDuration: registry.MustNewMetric(ThriftReqDurationName, stats.Trend, stats.Time),
+		ThriftReqFailed:   registry.MustNewMetric(ThriftReqFailedName, stats.Rate),
+
+		NATSPublishDuration:  registry.MustNewMetric(NATSPublishDurationName, stats.Trend, stats.Time),
+		NATSDeliveryLag:      registry.MustNewMetric(NATSDeliveryLagName, stats.Trend, stats.Time),
+		NATSMessagesSent:     registry.MustNewMetric(NATSMessagesSentName, stats.Counter),
+		NATSMessagesReceived: registry.MustNewMetric(NATSMessagesReceivedName, stats.Counter),
+
+		PerformanceMeasure: registry.MustNewMetric(PerformanceMeasureName, stats.Trend, stats.Time),
+		LeakedTimers:       registry.MustNewMetric(LeakedTimersName, stats.Counter),
 
-		GRPCReqDuration: registry.MustNewMetric(GRPCReqDurationName, stats.Trend, stats.Time),
+		OutputBufferedSamples: registry.MustNewMetric(OutputBufferedSamplesName, stats.Gauge),
+		OutputDroppedSamples:  registry.MustNewMetric(OutputDroppedSamplesName, stats.Counter),
 
 		DataSent:     registry.MustNewMetric(DataSentName, stats.Counter, stats.Data),
 		DataReceived: registry.MustNewMetric(DataReceivedName, stats.Counter, stats.Data),
@@ -31,20 +31,31 @@ import (
 // Registry is what can create metrics
 type Registry struct {
 	metrics map[string]*stats.Metric
+	ids     map[string]string // the metric names by their IDs
 	l       sync.RWMutex
+	live    *LiveMetrics
 }
 
 // NewRegistry returns a new registry
 func NewRegistry() *Registry {
 	return &Registry{
 		metrics: make(map[string]*stats.Metric),
+		ids:     make(map[string]string),
+		live:    NewLiveMetrics(),
 	}
 }
 
+// LiveMetrics returns the live metrics of the metrics of the registry, which are queried by the scripts.
+func (r *Registry) LiveMetrics() *LiveMetrics {
+	return r.live
+}
+
 const nameRegexString = "^[\\p{L}\\p{N}\\._ !\\?/&#\\(\\)<>%-]{1,128}$"
 
 var compileNameRegex = regexp.MustCompile(nameRegexString)
 
+var idRegex = regexp.MustCompile(`^[a-zA-Z0-9_.:/-]{1,128}$`)
+
 func checkName(name string) bool {
 	return compileNameRegex.Match([]byte(name))
 }
@@ -77,6 +88,98 @@ func (r *Registry) NewMetric(name string, typ stats.MetricType, t ...stats.Value
 	return oldMetric, nil
 }
 
+// Get returns the metric with the name, or nil if there isn't one.
+func (r *Registry) Get(name string) *stats.Metric {
+	r.l.RLock()
+	defer r.l.RUnlock()
+	return r.metrics[name]
+}
+
+// NewMetricWithUnit is like NewMetric, but for a metric declared with a unit, which is parsed with
+// stats.ParseUnit. An existing metric needs to have the same unit.
+func (r *Registry) NewMetricWithUnit(name string, typ stats.MetricType, unit string) (*stats.Metric, error) {
+	valueType, unit := stats.ParseUnit(unit)
+	m, err := r.NewMetric(name, typ, valueType)
+	if err != nil {
+		return nil, err
+	}
+
+	r.l.Lock()
+	defer r.l.Unlock()
+	if m.Unit != unit {
+		if m.Unit != "" {
+			return nil, fmt.Errorf("metric '%s' already exists but with the unit %s, instead of %s", name, m.Unit, unit)
+		}
+		m.Unit = unit
+	}
+	return m, nil
+}
+
+// DescribeMetric sets the description and the stable ID of the metric, the empty ones are left unset.
+// They can't be changed once they are set, and the ID needs to be unique among the metrics of the registry.
+func (r *Registry) DescribeMetric(m *stats.Metric, description, id string) error {
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	if description != "" && m.Description != "" && m.Description != description {
+		return fmt.Errorf("metric '%s' already exists but with the description '%s'", m.Name, m.Description)
+	}
+	if id != "" {
+		if !idRegex.MatchString(id) {
+			return fmt.Errorf("invalid ID '%s' of the metric '%s', it can only have up to 128 letters, "+
+				"digits and the characters _.:/-", id, m.Name)
+		}
+		if m.ID != "" && m.ID != id {
+			return fmt.Errorf("metric '%s' already exists but with the ID '%s', instead of '%s'", m.Name, m.ID, id)
+		}
+		if name, ok := r.ids[id]; ok && name != m.Name {
+			return fmt.Errorf("the ID '%s' of the metric '%s' is already the one of the metric '%s'", id, m.Name, name)
+		}
+		r.ids[id] = m.Name
+		m.ID = id
+	}
+	if description != "" {
+		m.Description = description
+	}
+	return nil
+}
+
+// SetHistogramBuckets sets the bounds of the buckets of the Histogram, instead of the default ones for its
+// value type. The other declarations of the Histogram need to have the same ones, or none.
+func (r *Registry) SetHistogramBuckets(m *stats.Metric, buckets []float64) error {
+	if m.Type != stats.Histogram {
+		return fmt.Errorf("metric '%s' is a %s, only the histograms have buckets", m.Name, m.Type)
+	}
+	if err := stats.ValidateHistogramBuckets(buckets); err != nil {
+		return fmt.Errorf("invalid buckets of the metric '%s': %w", m.Name, err)
+	}
+
+	r.l.Lock()
+	defer r.l.Unlock()
+	if equalBuckets(m.Buckets, buckets) {
+		return nil
+	}
+	if !equalBuckets(m.Buckets, stats.DefaultHistogramBuckets(m.Contains)) {
+		return fmt.Errorf("metric '%s' already exists but with the buckets %v, instead of %v",
+			m.Name, m.Buckets, buckets)
+	}
+	m.Buckets = buckets
+	m.Sink = stats.NewHistogramSink(buckets)
+	return nil
+}
+
+func equalBuckets(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
 // MustNewMetric is like NewMetric, but will panic if there is an error
 func (r *Registry) MustNewMetric(name string, typ stats.MetricType, t ...stats.ValueType) *stats.Metric {
 	m, err := r.NewMetric(name, typ, t...)
@@ -48,6 +48,74 @@ func TestRegistryNewMetric(t *testing.T) {
 	require.Error(t, err)
 }
 
+func TestRegistryNewMetricWithUnit(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+
+	orders, err := r.NewMetricWithUnit("orders", stats.Counter, "orders")
+	require.NoError(t, err)
+	require.Equal(t, stats.Default, orders.Contains)
+	require.Equal(t, "orders", orders.Unit)
+	ordersAgain, err := r.NewMetricWithUnit("orders", stats.Counter, "orders")
+	require.NoError(t, err)
+	require.Same(t, orders, ordersAgain)
+	_, err = r.NewMetricWithUnit("orders", stats.Counter, "carts")
+	require.Error(t, err)
+
+	size, err := r.NewMetricWithUnit("size", stats.Trend, "bytes")
+	require.NoError(t, err)
+	require.Equal(t, stats.Data, size.Contains)
+	require.Empty(t, size.Unit)
+	_, err = r.NewMetricWithUnit("size", stats.Trend, "ms")
+	require.Error(t, err)
+
+	wait, err := r.NewMetricWithUnit("wait", stats.Trend, "seconds")
+	require.NoError(t, err)
+	require.Equal(t, stats.Default, wait.Contains)
+	require.Equal(t, stats.UnitSeconds, wait.Unit)
+}
+
+func TestRegistryDescribeMetric(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+
+	orders := r.MustNewMetric("orders", stats.Counter)
+	require.NoError(t, r.DescribeMetric(orders, "The placed orders", "shop.orders"))
+	assert.Equal(t, "The placed orders", orders.Description)
+	assert.Equal(t, "shop.orders", orders.ID)
+
+	// the metadata can be declared again, or left out, by the other declarations of the metric
+	require.NoError(t, r.DescribeMetric(orders, "The placed orders", "shop.orders"))
+	require.NoError(t, r.DescribeMetric(orders, "", ""))
+
+	assert.Error(t, r.DescribeMetric(orders, "The orders", ""))
+	assert.Error(t, r.DescribeMetric(orders, "", "shop.carts"))
+
+	carts := r.MustNewMetric("carts", stats.Counter)
+	assert.Error(t, r.DescribeMetric(carts, "", "shop.orders"))
+	assert.Error(t, r.DescribeMetric(carts, "", "shop carts"))
+	require.NoError(t, r.DescribeMetric(carts, "", "shop.carts"))
+}
+
+func TestRegistrySetHistogramBuckets(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+
+	size := r.MustNewMetric("size", stats.Histogram, stats.Data)
+	assert.Equal(t, stats.DefaultHistogramBuckets(stats.Data), size.Buckets)
+
+	require.NoError(t, r.SetHistogramBuckets(size, []float64{100, 1000}))
+	assert.Equal(t, []float64{100, 1000}, size.Buckets)
+	assert.Equal(t, []float64{100, 1000}, size.Sink.(*stats.HistogramSink).Bounds)
+
+	// the other declarations need the same buckets
+	require.NoError(t, r.SetHistogramBuckets(size, []float64{100, 1000}))
+	assert.Error(t, r.SetHistogramBuckets(size, []float64{100, 10000}))
+
+	assert.Error(t, r.SetHistogramBuckets(r.MustNewMetric("other", stats.Histogram), []float64{10, 1}))
+	assert.Error(t, r.SetHistogramBuckets(r.MustNewMetric("trend", stats.Trend), []float64{1, 10}))
+}
+
 func TestMetricNames(t *testing.T) {
 	t.Parallel()
 	testMap := map[string]bool{
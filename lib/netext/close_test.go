@@ -0,0 +1,64 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netext
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testCloser struct{ closed int32 }
+
+func (c *testCloser) Close() error {
+	atomic.StoreInt32(&c.closed, 1)
+	return nil
+}
+
+func (c *testCloser) isClosed() bool {
+	return atomic.LoadInt32(&c.closed) == 1
+}
+
+func TestCloseOnDone(t *testing.T) {
+	t.Parallel()
+
+	t.Run("done", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(context.Background())
+		c := &testCloser{}
+		defer CloseOnDone(ctx, c)()
+		cancel()
+		assert.Eventually(t, c.isClosed, time.Second, time.Millisecond)
+	})
+
+	t.Run("stopped", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(context.Background())
+		c := &testCloser{}
+		CloseOnDone(ctx, c)()
+		cancel()
+		time.Sleep(10 * time.Millisecond)
+		assert.False(t, c.isClosed())
+	})
+}
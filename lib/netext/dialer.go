@@ -24,7 +24,10 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"path"
 	"strconv"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -43,9 +46,15 @@ type Dialer struct {
 	Blacklist        []*lib.IPNet
 	BlockedHostnames *types.HostnameTrie
 	Hosts            map[string]*lib.HostAddress
+	// UnixSockets are the paths, or the patterns of the paths, of the unix sockets that
+	// may be dialed, all of them may be if it's nil.
+	UnixSockets []string
 
 	BytesRead    int64
 	BytesWritten int64
+
+	poolsMu sync.Mutex
+	pools   map[string]*connPool
 }
 
 // NewDialer constructs a new Dialer with the given DNS resolver.
@@ -76,17 +85,47 @@ func (b BlockedHostError) Error() string {
 	return fmt.Sprintf("hostname (%s) is in a blocked pattern (%s)", b.hostname, b.match)
 }
 
+// UnixSocketNotAllowedError is returned when a unix socket isn't in the allowed ones
+type UnixSocketNotAllowedError struct {
+	socket string
+}
+
+func (u UnixSocketNotAllowedError) Error() string {
+	return fmt.Sprintf("unix socket (%s) isn't in the allowed ones", u.socket)
+}
+
 // DialContext wraps the net.Dialer.DialContext and handles the k6 specifics
 func (d *Dialer) DialContext(ctx context.Context, proto, addr string) (net.Conn, error) {
-	dialAddr, err := d.getDialAddr(addr)
-	if err != nil {
-		return nil, err
+	var (
+		conn net.Conn
+		err  error
+	)
+	if socket, ok := unixSocketPath(addr); ok {
+		if err = d.checkUnixSocket(socket); err != nil {
+			return nil, err
+		}
+		dialer := d.Dialer
+		dialer.LocalAddr = nil // the local IPs are TCP addresses
+		conn, err = dialer.DialContext(ctx, "unix", socket)
+	} else {
+		var dialAddr string
+		dialAddr, err = d.getDialAddr(addr)
+		if err != nil {
+			return nil, err
+		}
+		dialer := d.Dialer
+		if tcpAddr, ok := dialer.LocalAddr.(*net.TCPAddr); ok && strings.HasPrefix(proto, "udp") {
+			// the local IPs are TCP addresses, the same IPs are used for UDP
+			dialer.LocalAddr = &net.UDPAddr{IP: tcpAddr.IP, Zone: tcpAddr.Zone}
+		}
+		conn, err = dialer.DialContext(ctx, proto, dialAddr)
 	}
-	conn, err := d.Dialer.DialContext(ctx, proto, dialAddr)
 	if err != nil {
 		return nil, err
 	}
-	conn = &Conn{conn, &d.BytesRead, &d.BytesWritten}
+	pool := d.getPool(addr)
+	pool.opened()
+	conn = &Conn{Conn: conn, BytesRead: &d.BytesRead, BytesWritten: &d.BytesWritten, pool: pool}
 	return conn, err
 }
 
@@ -142,6 +181,47 @@ func (d *Dialer) GetTrail(
 	}
 }
 
+// unixSocketPath returns the path of the unix domain socket if the host of the address is one,
+// which is how the HTTP requests to http+unix URLs are dialed.
+func unixSocketPath(addr string) (string, bool) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil || !strings.HasPrefix(host, "/") {
+		return "", false
+	}
+	return host, true
+}
+
+// checkUnixSocket returns an error if the unix socket isn't allowed, or if it's blocked like the
+// local host is, when the local host is blocked by its name or its IP.
+func (d *Dialer) checkUnixSocket(socket string) error {
+	if d.UnixSockets != nil {
+		var allowed bool
+		for _, pattern := range d.UnixSockets {
+			if ok, _ := path.Match(pattern, socket); ok {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return UnixSocketNotAllowedError{socket: socket}
+		}
+	}
+
+	if d.BlockedHostnames != nil {
+		if match, blocked := d.BlockedHostnames.Contains("localhost"); blocked {
+			return BlockedHostError{hostname: socket, match: match}
+		}
+	}
+	for _, ip := range []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback} {
+		for _, ipnet := range d.Blacklist {
+			if ipnet.Contains(ip) {
+				return BlackListedIPError{ip: ip, net: ipnet}
+			}
+		}
+	}
+	return nil
+}
+
 func (d *Dialer) getDialAddr(addr string) (string, error) {
 	remote, err := d.findRemote(addr)
 	if err != nil {
@@ -250,6 +330,12 @@ type Conn struct {
 	net.Conn
 
 	BytesRead, BytesWritten *int64
+
+	pool     *connPool
+	requests int64 // these are guarded by the pool's mutex
+	inFlight int64
+	closed   bool
+	retired  bool
 }
 
 func (c *Conn) Read(b []byte) (int, error) {
@@ -21,7 +21,9 @@
 package netext
 
 import (
+	"context"
 	"net"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -125,6 +127,71 @@ func TestDialerAddrBlockHostnamesStar(t *testing.T) {
 	}
 }
 
+func TestDialerUDPLocalAddr(t *testing.T) {
+	t.Parallel()
+	dialer := NewDialer(net.Dialer{LocalAddr: &net.TCPAddr{IP: net.ParseIP("127.0.0.1")}}, newResolver())
+
+	conn, err := dialer.DialContext(context.Background(), "udp", "127.0.0.1:9")
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+	require.Equal(t, "127.0.0.1", conn.LocalAddr().(*net.UDPAddr).IP.String())
+}
+
+func TestDialerUnixSocket(t *testing.T) {
+	t.Parallel()
+	socket := filepath.Join(t.TempDir(), "app.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	blockedLocalhost, err := types.NewHostnameTrie([]string{"*host"})
+	require.NoError(t, err)
+	loopback, err := lib.ParseCIDR("127.0.0.0/8")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name   string
+		setup  func(d *Dialer)
+		expErr string
+	}{
+		{name: "allowed", setup: func(d *Dialer) {}},
+		{
+			name:  "in the allowed ones",
+			setup: func(d *Dialer) { d.UnixSockets = []string{filepath.Dir(socket) + "/*.sock"} },
+		},
+		{
+			name:   "not in the allowed ones",
+			setup:  func(d *Dialer) { d.UnixSockets = []string{"/var/run/*.sock"} },
+			expErr: "unix socket (" + socket + ") isn't in the allowed ones",
+		},
+		{
+			name:   "localhost blocked",
+			setup:  func(d *Dialer) { d.BlockedHostnames = blockedLocalhost },
+			expErr: "hostname (" + socket + ") is in a blocked pattern (*host)",
+		},
+		{
+			name:   "loopback blacklisted",
+			setup:  func(d *Dialer) { d.Blacklist = []*lib.IPNet{loopback} },
+			expErr: "IP (127.0.0.1) is in a blacklisted range (127.0.0.0/8)",
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			dialer := NewDialer(net.Dialer{}, newResolver())
+			tc.setup(dialer)
+			conn, err := dialer.DialContext(context.Background(), "tcp", net.JoinHostPort(socket, "80"))
+			if tc.expErr != "" {
+				require.EqualError(t, err, tc.expErr)
+				return
+			}
+			require.NoError(t, err)
+			_ = conn.Close()
+		})
+	}
+}
+
 func newResolver() *mockresolver.MockResolver {
 	return mockresolver.New(
 		map[string][]net.IP{
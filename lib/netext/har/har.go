@@ -0,0 +1,210 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package har contains the types of the HTTP Archive (HAR) 1.2 format and a recorder of its entries,
+// see http://www.softwareishard.com/blog/har-12-spec/ for the format.
+package har
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Version is the version of the HAR format.
+const Version = "1.2"
+
+// HAR is the root of an HTTP Archive.
+type HAR struct {
+	Log *Log `json:"log"`
+}
+
+// Log contains the recorded entries.
+type Log struct {
+	Version string   `json:"version"`
+	Creator *Creator `json:"creator"`
+	Entries []*Entry `json:"entries"`
+}
+
+// Creator is the application that created the archive.
+type Creator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Entry is a single sent request and its response.
+type Entry struct {
+	StartedDateTime time.Time `json:"startedDateTime"`
+	// Time is the total time of the request in milliseconds, the sum of the timings
+	Time            float64   `json:"time"`
+	Request         *Request  `json:"request"`
+	Response        *Response `json:"response"`
+	Cache           struct{}  `json:"cache"`
+	Timings         *Timings  `json:"timings"`
+	ServerIPAddress string    `json:"serverIPAddress,omitempty"`
+	Comment         string    `json:"comment,omitempty"`
+}
+
+// Request is the request of an entry.
+type Request struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []Cookie    `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	QueryString []NameValue `json:"queryString"`
+	PostData    *PostData   `json:"postData,omitempty"`
+	HeadersSize int64       `json:"headersSize"`
+	BodySize    int64       `json:"bodySize"`
+}
+
+// Response is the response of an entry, its status is 0 if the request failed.
+type Response struct {
+	Status      int         `json:"status"`
+	StatusText  string      `json:"statusText"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []Cookie    `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	Content     *Content    `json:"content"`
+	RedirectURL string      `json:"redirectURL"`
+	HeadersSize int64       `json:"headersSize"`
+	BodySize    int64       `json:"bodySize"`
+}
+
+// Cookie is a cookie sent with a request or set by a response.
+type Cookie struct {
+	Name     string     `json:"name"`
+	Value    string     `json:"value"`
+	Path     string     `json:"path,omitempty"`
+	Domain   string     `json:"domain,omitempty"`
+	Expires  *time.Time `json:"expires,omitempty"`
+	HTTPOnly bool       `json:"httpOnly,omitempty"`
+	Secure   bool       `json:"secure,omitempty"`
+}
+
+// NameValue is a header or a query string parameter.
+type NameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// PostData is the body of a request.
+type PostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// Content is the body of a response, the binary ones are base64 encoded.
+type Content struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// Timings are the durations of the phases of a request in milliseconds, -1 for the ones that don't apply.
+type Timings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// Headers returns the headers sorted by their name.
+func Headers(header http.Header) []NameValue {
+	result := make([]NameValue, 0, len(header))
+	for name, values := range header {
+		for _, value := range values {
+			result = append(result, NameValue{Name: name, Value: value})
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// QueryString returns the query string parameters of the URL sorted by their name.
+func QueryString(u *url.URL) []NameValue {
+	query := u.Query()
+	result := make([]NameValue, 0, len(query))
+	for name, values := range query {
+		for _, value := range values {
+			result = append(result, NameValue{Name: name, Value: value})
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// Cookies converts the cookies of a request or a response.
+func Cookies(cookies []*http.Cookie) []Cookie {
+	result := make([]Cookie, 0, len(cookies))
+	for _, c := range cookies {
+		cookie := Cookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Domain:   c.Domain,
+			HTTPOnly: c.HttpOnly,
+			Secure:   c.Secure,
+		}
+		if !c.Expires.IsZero() {
+			expires := c.Expires
+			cookie.Expires = &expires
+		}
+		result = append(result, cookie)
+	}
+	return result
+}
+
+// Recorder collects entries, it's safe for concurrent use.
+type Recorder struct {
+	mu      sync.Mutex
+	entries []*Entry
+}
+
+// NewRecorder returns a new empty Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Record adds the entries to the recorder.
+func (r *Recorder) Record(entries ...*Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, entries...)
+}
+
+// HAR returns an archive with the entries recorded so far, sorted by the time they were started.
+func (r *Recorder) HAR(creator *Creator) *HAR {
+	r.mu.Lock()
+	entries := make([]*Entry, len(r.entries))
+	copy(entries, r.entries)
+	r.mu.Unlock()
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].StartedDateTime.Before(entries[j].StartedDateTime)
+	})
+	return &HAR{Log: &Log{Version: Version, Creator: creator, Entries: entries}}
+}
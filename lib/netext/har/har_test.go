@@ -0,0 +1,82 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package har
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	t.Parallel()
+
+	recorder := NewRecorder()
+	start := time.Date(2021, 12, 1, 10, 0, 0, 0, time.UTC)
+	var wg sync.WaitGroup
+	for i := 4; i >= 0; i-- {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			recorder.Record(&Entry{StartedDateTime: start.Add(time.Duration(i) * time.Second)})
+		}(i)
+	}
+	wg.Wait()
+
+	archive := recorder.HAR(&Creator{Name: "k6", Version: "1.0"})
+	assert.Equal(t, Version, archive.Log.Version)
+	require.Len(t, archive.Log.Entries, 5)
+	for i, entry := range archive.Log.Entries {
+		assert.Equal(t, start.Add(time.Duration(i)*time.Second), entry.StartedDateTime)
+	}
+
+	data, err := json.Marshal(archive)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"creator":{"name":"k6","version":"1.0"}`)
+	assert.Contains(t, string(data), `"startedDateTime":"2021-12-01T10:00:00Z"`)
+}
+
+func TestConversions(t *testing.T) {
+	t.Parallel()
+
+	headers := Headers(http.Header{"B": {"2"}, "A": {"1", "3"}})
+	assert.Equal(t, []NameValue{{"A", "1"}, {"A", "3"}, {"B", "2"}}, headers)
+
+	u, err := url.Parse("http://example.com/?z=1&a=2&a=3")
+	require.NoError(t, err)
+	assert.Equal(t, []NameValue{{"a", "2"}, {"a", "3"}, {"z", "1"}}, QueryString(u))
+
+	expires := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	cookies := Cookies([]*http.Cookie{
+		{Name: "session", Value: "abc", Path: "/", HttpOnly: true, Expires: expires},
+		{Name: "other", Value: "def"},
+	})
+	assert.Equal(t, []Cookie{
+		{Name: "session", Value: "abc", Path: "/", HTTPOnly: true, Expires: &expires},
+		{Name: "other", Value: "def"},
+	}, cookies)
+}
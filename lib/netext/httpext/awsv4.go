@@ -0,0 +1,239 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package httpext
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	awsv4Algorithm       = "AWS4-HMAC-SHA256"
+	awsv4TimeFormat      = "20060102T150405Z"
+	awsv4DateFormat      = "20060102"
+	awsv4UnsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// awsv4IgnoredHeaders aren't signed, since they are commonly changed by proxies or after the signing.
+var awsv4IgnoredHeaders = map[string]bool{ //nolint:gochecknoglobals
+	"authorization":   true,
+	"user-agent":      true,
+	"x-amzn-trace-id": true,
+	"expect":          true,
+}
+
+// AWSv4Signer signs the requests with the AWS Signature Version 4, see
+// https://docs.aws.amazon.com/general/latest/gr/signature-version-4.html
+//
+// The body of every request is hashed when it's signed, the streamed ones are read in chunks, so they
+// don't have to fit in memory. If UnsignedPayload is set, the body isn't hashed at all, which S3 supports.
+type AWSv4Signer struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is sent in the X-Amz-Security-Token header if it's set
+	SessionToken    string
+	Region          string
+	Service         string
+	UnsignedPayload bool
+}
+
+// awsv4Transport signs every request, including the redirected and retried ones, right before it's sent.
+type awsv4Transport struct {
+	signer            *AWSv4Signer
+	originalTransport http.RoundTripper
+}
+
+// RoundTrip signs a copy of the request and sends it with the original transport.
+func (t awsv4Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	signed := req.Clone(req.Context())
+	if err := t.signer.sign(signed, time.Now()); err != nil {
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return nil, err
+	}
+	return t.originalTransport.RoundTrip(signed)
+}
+
+// Sign signs the request as if it was sent now, its body is read again from GetBody to be hashed.
+func (s *AWSv4Signer) Sign(req *http.Request) error {
+	return s.sign(req, time.Now())
+}
+
+// sign sets the X-Amz-Date, X-Amz-Security-Token, X-Amz-Content-Sha256 and Authorization headers
+// of the request as if it was sent at the given time.
+func (s *AWSv4Signer) sign(req *http.Request, now time.Time) error {
+	payloadHash, err := s.payloadHash(req)
+	if err != nil {
+		return fmt.Errorf("couldn't hash the request body for the AWS signature: %w", err)
+	}
+
+	now = now.UTC()
+	req.Header.Set("X-Amz-Date", now.Format(awsv4TimeFormat))
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+	// S3 requires the header, the other services accept only signed payloads
+	if s.Service == "s3" || s.UnsignedPayload {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	canonicalHeaders, signedHeaders := s.canonicalHeaders(req)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s.canonicalURI(req),
+		canonicalQuery(req),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{now.Format(awsv4DateFormat), s.Region, s.Service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		awsv4Algorithm,
+		now.Format(awsv4TimeFormat),
+		scope,
+		hashHex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), now.Format(awsv4DateFormat))
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, s.Service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		awsv4Algorithm, s.AccessKeyID, scope, signedHeaders, signature))
+	return nil
+}
+
+// payloadHash returns the hex encoded SHA256 hash of the request body, which is read again from GetBody,
+// so the body that's going to be sent isn't consumed.
+func (s *AWSv4Signer) payloadHash(req *http.Request) (string, error) {
+	if s.UnsignedPayload {
+		return awsv4UnsignedPayload, nil
+	}
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody == nil {
+		return hashHex(nil), nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = body.Close() }()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, body); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// canonicalURI returns the escaped path, which is escaped once more for all the services except S3.
+func (s *AWSv4Signer) canonicalURI(req *http.Request) string {
+	path := req.URL.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	if s.Service == "s3" {
+		return path
+	}
+	return awsv4Escape(path, false)
+}
+
+func canonicalQuery(req *http.Request) string {
+	query := req.URL.Query()
+	params := make([]string, 0, len(query))
+	for name, values := range query {
+		for _, value := range values {
+			params = append(params, awsv4Escape(name, true)+"="+awsv4Escape(value, true))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+// canonicalHeaders returns the canonical headers, each on its own line, and the names of the signed ones.
+func (s *AWSv4Signer) canonicalHeaders(req *http.Request) (string, string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if awsv4IgnoredHeaders[name] || name == "host" {
+			continue
+		}
+		trimmed := make([]string, len(values))
+		for i, value := range values {
+			trimmed[i] = strings.Join(strings.Fields(value), " ")
+		}
+		headers[name] = strings.Join(trimmed, ",")
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonical strings.Builder
+	for _, name := range names {
+		canonical.WriteString(name + ":" + headers[name] + "\n")
+	}
+	return canonical.String(), strings.Join(names, ";")
+}
+
+// awsv4Escape percent-encodes everything except the unreserved characters and, if encodeSlash
+// isn't set, the slashes.
+func awsv4Escape(s string, encodeSlash bool) string {
+	var escaped strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && !encodeSlash:
+			escaped.WriteByte(c)
+		default:
+			fmt.Fprintf(&escaped, "%%%02X", c)
+		}
+	}
+	return escaped.String()
+}
+
+func hashHex(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,100 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package httpext
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAWSv4Signer(t *testing.T) {
+	t.Parallel()
+
+	// the credentials and the request of the AWS Signature Version 4 test suite
+	signer := &AWSv4Signer{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		Region:          "us-east-1",
+		Service:         "service",
+	}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+	t.Run("get vanilla", func(t *testing.T) {
+		t.Parallel()
+		req := httptest.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+		req.Header = make(http.Header)
+		req.Host = ""
+		require.NoError(t, signer.sign(req, now))
+
+		assert.Equal(t, "20150830T123600Z", req.Header.Get("X-Amz-Date"))
+		assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+			"SignedHeaders=host;x-amz-date, "+
+			"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+			req.Header.Get("Authorization"))
+		assert.Empty(t, req.Header.Get("X-Amz-Content-Sha256"))
+	})
+
+	t.Run("body and session token", func(t *testing.T) {
+		t.Parallel()
+		s3Signer := *signer
+		s3Signer.Service = "s3"
+		s3Signer.SessionToken = "token"
+		body := []byte("some data")
+		req := httptest.NewRequest(http.MethodPut, "https://bucket.s3.amazonaws.com/some%20key?b=2&a=1", nil)
+		req.Header = http.Header{"User-Agent": {"k6"}}
+		req.GetBody = func() (io.ReadCloser, error) { return ioutil.NopCloser(bytes.NewReader(body)), nil }
+		req.Body, _ = req.GetBody()
+		require.NoError(t, s3Signer.sign(req, now))
+
+		assert.Equal(t, hashHex(body), req.Header.Get("X-Amz-Content-Sha256"))
+		assert.Equal(t, "token", req.Header.Get("X-Amz-Security-Token"))
+		assert.Contains(t, req.Header.Get("Authorization"),
+			"SignedHeaders=host;x-amz-content-sha256;x-amz-date;x-amz-security-token, ")
+		// the body that's going to be sent isn't consumed by the hashing
+		sent, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+		assert.Equal(t, body, sent)
+	})
+
+	t.Run("unsigned payload", func(t *testing.T) {
+		t.Parallel()
+		unsignedSigner := *signer
+		unsignedSigner.UnsignedPayload = true
+		req := httptest.NewRequest(http.MethodPost, "https://example.amazonaws.com/", strings.NewReader("data"))
+		require.NoError(t, unsignedSigner.sign(req, now))
+		assert.Equal(t, "UNSIGNED-PAYLOAD", req.Header.Get("X-Amz-Content-Sha256"))
+	})
+}
+
+func TestAWSv4Escape(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "/a%20b/c~d", awsv4Escape("/a b/c~d", false))
+	assert.Equal(t, "%2Fa%2520b", awsv4Escape("/a%20b", true))
+}
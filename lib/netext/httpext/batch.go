@@ -22,6 +22,9 @@ package httpext
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync"
 	"sync/atomic"
 
 	"go.k6.io/k6/lib"
@@ -33,6 +36,44 @@ import (
 type BatchParsedHTTPRequest struct {
 	*ParsedHTTPRequest
 	Response *Response // this is modified by MakeBatchRequests()
+	// DependsOn are the indexes of the requests that have to succeed before this one is sent
+	DependsOn []int
+}
+
+// CheckBatchDependencies returns an error if the requests depend on requests that don't exist or on
+// each other in a cycle.
+func CheckBatchDependencies(requests []BatchParsedHTTPRequest) error {
+	const (
+		visiting = iota + 1
+		visited
+	)
+	states := make([]int, len(requests))
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch states[i] {
+		case visiting:
+			return errors.New("the batch requests depend on each other in a cycle")
+		case visited:
+			return nil
+		}
+		states[i] = visiting
+		for _, dep := range requests[i].DependsOn {
+			if dep < 0 || dep >= len(requests) {
+				return fmt.Errorf("batch request %d depends on a request that doesn't exist", i)
+			}
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		states[i] = visited
+		return nil
+	}
+	for i := range requests {
+		if err := visit(i); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // MakeBatchRequests concurrently makes multiple requests. It spawns
@@ -44,6 +85,11 @@ type BatchParsedHTTPRequest struct {
 // the requests channel is closed.
 // The processResponse callback can be used to modify the response, e.g.
 // to replace the body.
+//
+// The requests with dependencies are sent only after all of them succeeded, i.e.
+// got a response that the response callback considers expected. If one of them
+// fails, the request isn't sent at all and its response has an error instead.
+// The dependencies have to be checked with CheckBatchDependencies() beforehand.
 func MakeBatchRequests(
 	ctx context.Context, state *lib.State,
 	requests []BatchParsedHTTPRequest,
@@ -57,7 +103,7 @@ func MakeBatchRequests(
 	result := make(chan error, reqCount)
 	perHostLimiter := lib.NewMultiSlotLimiter(perHostLimit)
 
-	makeRequest := func(req BatchParsedHTTPRequest) {
+	makeRequest := func(req BatchParsedHTTPRequest) bool {
 		if hl := perHostLimiter.Slot(req.URL.GetURL().Host); hl != nil {
 			hl.Begin()
 			defer hl.End()
@@ -69,6 +115,13 @@ func MakeBatchRequests(
 			*req.Response = *resp
 		}
 		result <- err
+		return err == nil && resp != nil && resp.Error == "" &&
+			(req.ResponseCallback == nil || req.ResponseCallback(resp.Status))
+	}
+
+	if hasBatchDependencies(requests) {
+		makeDependentRequests(requests, reqCount, workers, result, makeRequest)
+		return result
 	}
 
 	counter, i32reqCount := int32(-1), int32(reqCount)
@@ -86,3 +139,71 @@ func MakeBatchRequests(
 
 	return result
 }
+
+func hasBatchDependencies(requests []BatchParsedHTTPRequest) bool {
+	for _, req := range requests {
+		if len(req.DependsOn) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// makeDependentRequests sends every request with one of the workers as soon as all of its dependencies
+// succeeded, and skips the ones with a failed dependency.
+func makeDependentRequests(
+	requests []BatchParsedHTTPRequest, reqCount, workers int, result chan<- error,
+	makeRequest func(BatchParsedHTTPRequest) bool,
+) {
+	var mu sync.Mutex
+	pending := make([]int, reqCount)
+	dependents := make([][]int, reqCount)
+	ready := make(chan int, reqCount)
+	remaining := reqCount
+	for i := 0; i < reqCount; i++ {
+		pending[i] = len(requests[i].DependsOn)
+		for _, dep := range requests[i].DependsOn {
+			dependents[dep] = append(dependents[dep], i)
+		}
+		if pending[i] == 0 {
+			ready <- i
+		}
+	}
+
+	// finish has to be called with the lock held, it queues the dependents that are now ready
+	// or skips them if the request failed
+	var finish func(i int, succeeded bool)
+	finish = func(i int, succeeded bool) {
+		remaining--
+		for _, d := range dependents[i] {
+			if pending[d] < 0 { // already skipped
+				continue
+			}
+			if !succeeded {
+				pending[d] = -1
+				requests[d].Response.Error = "skipped because a batch request it depends on failed"
+				requests[d].Response.ErrorCode = int(requestSkippedErrorCode)
+				result <- nil
+				finish(d, false)
+				continue
+			}
+			if pending[d]--; pending[d] == 0 {
+				ready <- d
+			}
+		}
+		if remaining == 0 {
+			close(ready)
+		}
+	}
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			for reqNum := range ready {
+				succeeded := makeRequest(requests[reqNum])
+				mu.Lock()
+				finish(reqNum, succeeded)
+				mu.Unlock()
+			}
+		}()
+	}
+}
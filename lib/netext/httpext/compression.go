@@ -135,35 +135,11 @@ func wrapDecompressionError(err error) error {
 	return err
 }
 
-func readResponseBody(
-	state *lib.State,
-	respType ResponseType,
-	resp *http.Response,
-	respErr error,
-) (interface{}, error) {
-	if resp == nil || respErr != nil {
-		return nil, respErr
-	}
-
-	if respType == ResponseTypeNone {
-		_, err := io.Copy(ioutil.Discard, resp.Body)
-		_ = resp.Body.Close()
-		if err != nil {
-			respErr = err
-		}
-		return nil, respErr
-	}
-
+// decompressBody returns a reader that transparently decompresses the response body if it has
+// a content-encoding we support. If not, it simply returns a reader for the body as it is.
+func decompressBody(resp *http.Response) (*readCloser, error) {
 	rc := &readCloser{resp.Body}
-	// Ensure that the entire response body is read and closed, e.g. in case of decoding errors
-	defer func(respBody io.ReadCloser) {
-		_, _ = io.Copy(ioutil.Discard, respBody)
-		_ = respBody.Close()
-	}(resp.Body)
-
 	contentEncodings := strings.Split(resp.Header.Get("Content-Encoding"), ",")
-	// Transparently decompress the body if it's has a content-encoding we
-	// support. If not, simply return it as it is.
 	for i := len(contentEncodings) - 1; i >= 0; i-- {
 		contentEncoding := strings.TrimSpace(contentEncodings[i])
 		if compression, err := CompressionTypeString(contentEncoding); err == nil {
@@ -191,10 +167,42 @@ func readResponseBody(
 			rc = &readCloser{decoder}
 		}
 	}
+	return rc, nil
+}
+
+func readResponseBody(
+	state *lib.State,
+	respType ResponseType,
+	resp *http.Response,
+	respErr error,
+) (interface{}, error) {
+	if resp == nil || respErr != nil {
+		return nil, respErr
+	}
+
+	if respType == ResponseTypeNone {
+		_, err := io.Copy(ioutil.Discard, resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			respErr = err
+		}
+		return nil, respErr
+	}
+
+	// Ensure that the entire response body is read and closed, e.g. in case of decoding errors
+	defer func(respBody io.ReadCloser) {
+		_, _ = io.Copy(ioutil.Discard, respBody)
+		_ = respBody.Close()
+	}(resp.Body)
+
+	rc, err := decompressBody(resp)
+	if err != nil {
+		return nil, err
+	}
 	buf := state.BPool.Get()
 	defer state.BPool.Put(buf)
 	buf.Reset()
-	_, err := io.Copy(buf, rc.Reader)
+	_, err = io.Copy(buf, rc.Reader)
 	if err != nil {
 		respErr = wrapDecompressionError(err)
 	}
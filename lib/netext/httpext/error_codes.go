@@ -48,11 +48,14 @@ const (
 	defaultNetNonTCPErrorCode errCode = 1010
 	invalidURLErrorCode       errCode = 1020
 	requestTimeoutErrorCode   errCode = 1050
+	requestAbortedErrorCode   errCode = 1051
+	requestSkippedErrorCode   errCode = 1052
 	// DNS errors
-	defaultDNSErrorCode      errCode = 1100
-	dnsNoSuchHostErrorCode   errCode = 1101
-	blackListedIPErrorCode   errCode = 1110
-	blockedHostnameErrorCode errCode = 1111
+	defaultDNSErrorCode           errCode = 1100
+	dnsNoSuchHostErrorCode        errCode = 1101
+	blackListedIPErrorCode        errCode = 1110
+	blockedHostnameErrorCode      errCode = 1111
+	unixSocketNotAllowedErrorCode errCode = 1112
 	// tcp errors
 	defaultTCPErrorCode      errCode = 1200
 	tcpBrokenPipeErrorCode   errCode = 1201
@@ -96,12 +99,14 @@ const (
 	dnsNoSuchHostErrorCodeMsg   = "lookup: no such host"
 	blackListedIPErrorCodeMsg   = "ip is blacklisted"
 	blockedHostnameErrorMsg     = "hostname is blocked"
+	unixSocketNotAllowedMsg     = "unix socket isn't allowed"
 	http2GoAwayErrorCodeMsg     = "http2: received GoAway with http2 ErrCode %s"
 	http2StreamErrorCodeMsg     = "http2: stream error with http2 ErrCode %s"
 	http2ConnectionErrorCodeMsg = "http2: connection error with http2 ErrCode %s"
 	x509HostnameErrorCodeMsg    = "x509: certificate doesn't match hostname"
 	x509UnknownAuthority        = "x509: unknown authority"
 	requestTimeoutErrorCodeMsg  = "request timeout"
+	requestAbortedErrorCodeMsg  = "request aborted"
 	invalidURLErrorCodeMsg      = "invalid URL"
 )
 
@@ -192,6 +197,8 @@ func errorCodeForError(err error) (errCode, string) {
 		return blackListedIPErrorCode, blackListedIPErrorCodeMsg
 	case netext.BlockedHostError:
 		return blockedHostnameErrorCode, blockedHostnameErrorMsg
+	case netext.UnixSocketNotAllowedError:
+		return unixSocketNotAllowedErrorCode, unixSocketNotAllowedMsg
 	case http2.GoAwayError:
 		return unknownHTTP2GoAwayErrorCode + http2ErrCodeOffset(e.ErrCode),
 			fmt.Sprintf(http2GoAwayErrorCodeMsg, e.ErrCode)
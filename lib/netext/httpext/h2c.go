@@ -0,0 +1,150 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package httpext
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+
+	"golang.org/x/net/http2"
+
+	"go.k6.io/k6/lib"
+)
+
+// H2CTransport sends the requests over cleartext HTTP/2 with prior knowledge.
+//
+// Its connections are dialed with the contexts of the requests that need them, which the DialTLS
+// of this version of http2.Transport isn't given, so that they are canceled along with them.
+type H2CTransport struct {
+	transport *http2.Transport
+	pool      *h2cConnPool
+}
+
+var _ http.RoundTripper = &H2CTransport{}
+
+// NewH2CTransport returns a new H2CTransport whose connections are made by the dialer.
+func NewH2CTransport(dialer lib.DialContexter) *H2CTransport {
+	transport := &http2.Transport{
+		AllowHTTP:          true,
+		DisableCompression: true,
+	}
+	pool := &h2cConnPool{transport: transport, dialer: dialer}
+	transport.ConnPool = pool
+	return &H2CTransport{transport: transport, pool: pool}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *H2CTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.transport.RoundTrip(req)
+}
+
+// CloseIdleConnections closes the connections that aren't used, the ones with requests in flight are
+// closed once they are done.
+func (t *H2CTransport) CloseIdleConnections() {
+	t.pool.shutdown()
+}
+
+// h2cConnPool is the http2.ClientConnPool of an H2CTransport.
+type h2cConnPool struct {
+	transport *http2.Transport
+	dialer    lib.DialContexter
+
+	mu    sync.Mutex
+	conns map[string][]*http2.ClientConn
+}
+
+var _ http2.ClientConnPool = &h2cConnPool{}
+
+func (p *h2cConnPool) GetClientConn(req *http.Request, addr string) (*http2.ClientConn, error) {
+	ctx := req.Context()
+	if trace := httptrace.ContextClientTrace(ctx); trace != nil && trace.GetConn != nil {
+		trace.GetConn(addr)
+	}
+
+	p.mu.Lock()
+	for _, cc := range p.conns[addr] {
+		if cc.ReserveNewRequest() {
+			p.mu.Unlock()
+			return cc, nil
+		}
+	}
+	p.mu.Unlock()
+
+	cc, err := p.dial(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	if p.conns == nil {
+		p.conns = make(map[string][]*http2.ClientConn)
+	}
+	p.conns[addr] = append(p.conns[addr], cc)
+	p.mu.Unlock()
+	return cc, nil
+}
+
+func (p *h2cConnPool) dial(ctx context.Context, addr string) (*http2.ClientConn, error) {
+	conn, err := p.dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	cc, err := p.transport.NewClientConn(conn)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	// the upcoming request is accounted for, as it is for the connections that are reused
+	cc.ReserveNewRequest()
+	return cc, nil
+}
+
+func (p *h2cConnPool) MarkDead(dead *http2.ClientConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for addr, conns := range p.conns {
+		for i, cc := range conns {
+			if cc == dead {
+				p.conns[addr] = append(conns[:i:i], conns[i+1:]...)
+				break
+			}
+		}
+		if len(p.conns[addr]) == 0 {
+			delete(p.conns, addr)
+		}
+	}
+}
+
+// shutdown removes all the connections from the pool and closes them gracefully,
+// once their requests are done.
+func (p *h2cConnPool) shutdown() {
+	p.mu.Lock()
+	conns := p.conns
+	p.conns = nil
+	p.mu.Unlock()
+
+	for _, ccs := range conns {
+		for _, cc := range ccs {
+			go func(cc *http2.ClientConn) { _ = cc.Shutdown(context.Background()) }(cc)
+		}
+	}
+}
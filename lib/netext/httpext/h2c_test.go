@@ -0,0 +1,85 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package httpext
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+type dialerFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+func (f dialerFunc) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return f(ctx, network, addr)
+}
+
+func TestH2CTransport(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Proto))
+	}), &http2.Server{}))
+	t.Cleanup(srv.Close)
+
+	type ctxKey struct{}
+	var dials []interface{}
+	transport := NewH2CTransport(dialerFunc(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dials = append(dials, ctx.Value(ctxKey{}))
+		return (&net.Dialer{}).DialContext(ctx, network, addr)
+	}))
+
+	get := func(value string) {
+		ctx := context.WithValue(context.Background(), ctxKey{}, value)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+		require.NoError(t, err)
+		res, err := transport.RoundTrip(req)
+		require.NoError(t, err)
+		assert.Equal(t, "HTTP/2.0", res.Proto)
+		require.NoError(t, res.Body.Close())
+	}
+
+	get("first")
+	get("second")
+	// the connection is dialed with the context of the first request and reused for the second one
+	assert.Equal(t, []interface{}{"first"}, dials)
+
+	transport.CloseIdleConnections()
+	get("third")
+	assert.Equal(t, []interface{}{"first", "third"}, dials)
+
+	// the new connection is dialed with the context of the request, so it's canceled along with it
+	transport.CloseIdleConnections()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+	_, err = transport.RoundTrip(req)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "dial tcp")
+	assert.Contains(t, err.Error(), "operation was canceled")
+}
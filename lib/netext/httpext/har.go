@@ -0,0 +1,144 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package httpext
+
+import (
+	"encoding/base64"
+	"net"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/netext/har"
+	"go.k6.io/k6/stats"
+)
+
+// newHAREntry returns the HAR entry of a finished request, the bodies are filled in by recordHAR since
+// they are known only after the whole response has been read.
+func newHAREntry(finishedReq *finishedRequest) *har.Entry {
+	req, res, trail := finishedReq.request, finishedReq.response, finishedReq.trail
+
+	timings := &har.Timings{
+		Blocked: stats.D(trail.Blocked),
+		DNS:     -1,
+		Connect: -1,
+		Send:    stats.D(trail.Sending),
+		Wait:    stats.D(trail.Waiting),
+		Receive: stats.D(trail.Receiving),
+		SSL:     -1,
+	}
+	if !trail.ConnReused {
+		timings.Connect = stats.D(trail.ConnDuration)
+		if trail.TLSHandshaking > 0 {
+			timings.SSL = stats.D(trail.TLSHandshaking)
+		}
+	}
+	total := trail.Blocked + trail.ConnDuration + trail.Duration
+
+	entry := &har.Entry{
+		StartedDateTime: trail.EndTime.Add(-total),
+		Time:            stats.D(total),
+		Request: &har.Request{
+			Method:      req.Method,
+			URL:         urlString(req.URL),
+			HTTPVersion: req.Proto,
+			Cookies:     har.Cookies(req.Cookies()),
+			Headers:     har.Headers(req.Header),
+			QueryString: har.QueryString(req.URL),
+			HeadersSize: -1,
+			BodySize:    req.ContentLength,
+		},
+		Response: &har.Response{
+			Cookies:     []har.Cookie{},
+			Headers:     []har.NameValue{},
+			Content:     &har.Content{},
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+		Timings: timings,
+		Comment: finishedReq.errorMsg,
+	}
+	if entry.Request.HTTPVersion == "" {
+		entry.Request.HTTPVersion = "HTTP/1.1"
+	}
+	if trail.ConnRemoteAddr != nil {
+		if ip, _, err := net.SplitHostPort(trail.ConnRemoteAddr.String()); err == nil {
+			entry.ServerIPAddress = ip
+		}
+	}
+	if res != nil {
+		entry.Request.HTTPVersion = res.Proto
+		entry.Response.Status = res.StatusCode
+		entry.Response.StatusText = strings.TrimSpace(strings.TrimPrefix(res.Status, strconv.Itoa(res.StatusCode)))
+		entry.Response.HTTPVersion = res.Proto
+		entry.Response.Cookies = har.Cookies(res.Cookies())
+		entry.Response.Headers = har.Headers(res.Header)
+		entry.Response.Content.MimeType = res.Header.Get("Content-Type")
+		if res.ContentLength > 0 {
+			entry.Response.Content.Size = res.ContentLength
+		}
+		entry.Response.BodySize = res.ContentLength
+		entry.Response.RedirectURL = res.Header.Get("Location")
+	}
+	return entry
+}
+
+// recordHAR adds the entries of all the requests sent for a k6 request, including the redirects, to the recorders.
+func recordHAR(state *lib.State, preq *ParsedHTTPRequest, entries []*har.Entry, respReq *Request, resp *Response) {
+	if len(entries) == 0 {
+		return
+	}
+	for _, entry := range entries {
+		// the 307 and 308 redirects send the same body again
+		if entry.Request.BodySize > 0 && respReq.Body != "" {
+			entry.Request.PostData = &har.PostData{
+				MimeType: preq.Req.Header.Get("Content-Type"),
+				Text:     respReq.Body,
+			}
+		}
+	}
+
+	content := entries[len(entries)-1].Response.Content
+	var body []byte
+	switch b := resp.Body.(type) {
+	case string:
+		body = []byte(b)
+	case []byte:
+		body = b
+	}
+	if body != nil {
+		content.Size = int64(len(body))
+		if utf8.Valid(body) {
+			content.Text = string(body)
+		} else {
+			content.Text = base64.StdEncoding.EncodeToString(body)
+			content.Encoding = "base64"
+		}
+	}
+
+	if state.HARRecorder != nil {
+		state.HARRecorder.Record(entries...)
+	}
+	if preq.HARRecorder != nil {
+		preq.HARRecorder.Record(entries...)
+	}
+}
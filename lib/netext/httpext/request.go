@@ -29,7 +29,6 @@ import (
 	"io/ioutil"
 	"net"
 	"net/http"
-	"net/http/cookiejar"
 	"strconv"
 	"strings"
 	"time"
@@ -39,6 +38,7 @@ import (
 	"gopkg.in/guregu/null.v3"
 
 	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/netext/har"
 	"go.k6.io/k6/stats"
 )
 
@@ -61,6 +61,7 @@ type Request struct {
 type ParsedHTTPRequest struct {
 	URL              *URL
 	Body             *bytes.Buffer
+	StreamedBody     *StreamedBody
 	Req              *http.Request
 	Timeout          time.Duration
 	Auth             string
@@ -69,9 +70,25 @@ type ParsedHTTPRequest struct {
 	ResponseCallback func(int) bool
 	Compressions     []CompressionType
 	Redirects        null.Int
-	ActiveJar        *cookiejar.Jar
+	ActiveJar        *lib.CookieJar
 	Cookies          map[string]*HTTPRequestCookie
 	Tags             map[string]string
+	// Abort is closed if the request should be aborted
+	Abort <-chan struct{}
+	Retry *RetryPolicy
+	// H2C makes the request be sent over cleartext HTTP/2 with prior knowledge
+	H2C bool
+	// HARRecorder records the request in addition to the one in the state, if any
+	HARRecorder *har.Recorder
+	// AWSv4 signs the request with the AWS Signature Version 4 if it's set
+	AWSv4 *AWSv4Signer
+}
+
+// StreamedBody is a request body with a known length that isn't kept in memory, it's opened
+// again every time the request needs to be sent, e.g. after some redirects.
+type StreamedBody struct {
+	Length int64
+	Open   func() (io.ReadCloser, error)
 }
 
 // Matches non-compliant io.Closer implementations (e.g. zstd.Decoder)
@@ -116,6 +133,7 @@ func updateK6Response(k6Response *Response, finishedReq *finishedRequest) {
 		k6Response.RemoteIP = remoteHost
 		k6Response.RemotePort = remotePort
 	}
+	k6Response.Informational = trail.Informational
 	k6Response.Timings = ResponseTimings{
 		Duration:       stats.D(trail.Duration),
 		Blocked:        stats.D(trail.Blocked),
@@ -132,9 +150,21 @@ func updateK6Response(k6Response *Response, finishedReq *finishedRequest) {
 // TODO: split apart...
 // nolint: cyclop, gocyclo, funlen, gocognit
 func MakeRequest(ctx context.Context, state *lib.State, preq *ParsedHTTPRequest) (*Response, error) {
+	if preq.H2C {
+		if preq.Req.URL.Scheme != "http" {
+			return nil, fmt.Errorf("h2c can only be used with http URLs, not %s", preq.Req.URL.Scheme)
+		}
+		if state.H2CTransport == nil {
+			return nil, errors.New("h2c isn't supported in this context")
+		}
+	}
+	if isUnixSocketURL(preq.Req.URL) && preq.Req.Host == "" {
+		preq.Req.Host = unixSocketHost
+	}
+
 	respReq := &Request{
 		Method:  preq.Req.Method,
-		URL:     preq.Req.URL.String(),
+		URL:     urlString(preq.Req.URL),
 		Cookies: stdCookiesToHTTPRequestCookies(preq.Req.Cookies()),
 		Headers: preq.Req.Header,
 	}
@@ -171,6 +201,17 @@ func MakeRequest(ctx context.Context, state *lib.State, preq *ParsedHTTPRequest)
 		}
 		// as per the documentation using GetBody still requires setting the Body.
 		preq.Req.Body, _ = preq.Req.GetBody()
+	} else if preq.StreamedBody != nil {
+		if len(preq.Compressions) > 0 {
+			return nil, errors.New("compression can't be used with a streamed request body")
+		}
+		preq.Req.ContentLength = preq.StreamedBody.Length
+		preq.Req.GetBody = preq.StreamedBody.Open
+		body, err := preq.StreamedBody.Open()
+		if err != nil {
+			return nil, err
+		}
+		preq.Req.Body = body
 	}
 
 	if contentLengthHeader := preq.Req.Header.Get("Content-Length"); contentLengthHeader != "" {
@@ -187,11 +228,25 @@ func MakeRequest(ctx context.Context, state *lib.State, preq *ParsedHTTPRequest)
 		}
 	}
 
+	if preq.Retry != nil && preq.Retry.Attempts > 1 {
+		return retryRequest(ctx, state, preq, respReq)
+	}
+	return doRequest(ctx, state, preq, respReq, 0)
+}
+
+// doRequest sends the already prepared request, attempt is used for the attempt tag if it's a retried request.
+// nolint: cyclop, gocyclo, funlen, gocognit
+func doRequest(ctx context.Context, state *lib.State, preq *ParsedHTTPRequest, respReq *Request, attempt int) (
+	*Response, error,
+) {
 	tags := state.CloneTags()
 	// Override any global tags with request-specific ones.
 	for k, v := range preq.Tags {
 		tags[k] = v
 	}
+	if attempt > 0 {
+		tags["attempt"] = strconv.Itoa(attempt)
+	}
 
 	// Only set the name system tag if the user didn't explicitly set it beforehand,
 	// and the Name was generated from a tagged template string (via http.url).
@@ -203,11 +258,16 @@ func MakeRequest(ctx context.Context, state *lib.State, preq *ParsedHTTPRequest)
 	// Check rate limit *after* we've prepared a request; no need to wait with that part.
 	if rpsLimit := state.RPSLimit; rpsLimit != nil {
 		if err := rpsLimit.Wait(ctx); err != nil {
+			if preq.Req.Body != nil {
+				_ = preq.Req.Body.Close()
+			}
 			return nil, err
 		}
 	}
 
 	tracerTransport := newTransport(ctx, state, tags, preq.ResponseCallback)
+	tracerTransport.h2c = preq.H2C
+	tracerTransport.recordHAR = state.HARRecorder != nil || preq.HARRecorder != nil
 	var transport http.RoundTripper = tracerTransport
 
 	// Combine tags with common log fields
@@ -226,6 +286,10 @@ func MakeRequest(ctx context.Context, state *lib.State, preq *ParsedHTTPRequest)
 		}
 	}
 
+	if preq.AWSv4 != nil {
+		transport = awsv4Transport{signer: preq.AWSv4, originalTransport: transport}
+	}
+
 	if preq.Auth == "digest" {
 		// Until digest authentication is refactored, the first response will always
 		// be a 401 error, so we expect that.
@@ -254,7 +318,7 @@ func MakeRequest(ctx context.Context, state *lib.State, preq *ParsedHTTPRequest)
 	client := http.Client{
 		Transport: transport,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			resp.URL = req.URL.String()
+			resp.URL = urlString(req.URL)
 
 			// Update active jar with cookies found in "Set-Cookie" header(s) of redirect response
 			if preq.ActiveJar != nil {
@@ -282,7 +346,32 @@ func MakeRequest(ctx context.Context, state *lib.State, preq *ParsedHTTPRequest)
 	}
 
 	reqCtx, cancelFunc := context.WithTimeout(ctx, preq.Timeout)
-	defer cancelFunc()
+	release := cancelFunc
+	// streams release the request themselves, once they have been read or closed
+	var streaming bool
+	defer func() {
+		if !streaming {
+			release()
+		}
+	}()
+	if preq.Abort != nil {
+		reqCtx = withAbort(reqCtx, preq.Abort)
+		if isAborted(reqCtx) {
+			cancelFunc() // so that an already aborted request is never sent
+		}
+		finished := make(chan struct{})
+		release = func() {
+			cancelFunc()
+			close(finished)
+		}
+		go func() {
+			select {
+			case <-preq.Abort:
+				cancelFunc()
+			case <-finished:
+			}
+		}()
+	}
 	mreq := preq.Req.WithContext(reqCtx)
 	res, resErr := client.Do(mreq)
 
@@ -295,16 +384,49 @@ func MakeRequest(ctx context.Context, state *lib.State, preq *ParsedHTTPRequest)
 		return nil, fmt.Errorf("unsupported response status: %s", res.Status)
 	}
 
-	if resErr == nil {
+	if resErr == nil && preq.ResponseType == ResponseTypeStream {
+		var rc *readCloser
+		if rc, resErr = decompressBody(res); resErr != nil {
+			_ = res.Body.Close()
+		} else {
+			streaming = true
+			var stream *ResponseStream
+			stream = newResponseStream(ctx, state, res, rc, streamTags(state, tags, res.Request),
+				func(err error) {
+					if state.OpenStreams != nil {
+						state.OpenStreams.Remove(stream)
+					}
+					if errors.Is(err, context.DeadlineExceeded) {
+						err = NewK6Error(requestTimeoutErrorCode, requestTimeoutErrorCodeMsg, err)
+					} else if err != nil && isAborted(reqCtx) {
+						err = NewK6Error(requestAbortedErrorCode, requestAbortedErrorCodeMsg, err)
+					}
+					if finishedReq := tracerTransport.processLastSavedRequest(wrapDecompressionError(err)); finishedReq != nil {
+						updateK6Response(resp, finishedReq)
+					}
+					resp.Trailers = responseTrailers(res)
+					recordHAR(state, preq, tracerTransport.harEntries, respReq, resp)
+					release()
+				})
+			if state.OpenStreams != nil {
+				state.OpenStreams.Add(stream)
+			}
+			resp.Body = stream
+		}
+	} else if resErr == nil {
 		resp.Body, resErr = readResponseBody(state, preq.ResponseType, res, resErr)
 		if resErr != nil && errors.Is(resErr, context.DeadlineExceeded) {
 			// TODO This can be more specific that the timeout happened in the middle of the reading of the body
 			resErr = NewK6Error(requestTimeoutErrorCode, requestTimeoutErrorCodeMsg, resErr)
+		} else if resErr != nil && isAborted(reqCtx) {
+			resErr = NewK6Error(requestAbortedErrorCode, requestAbortedErrorCodeMsg, resErr)
 		}
 	}
-	finishedReq := tracerTransport.processLastSavedRequest(wrapDecompressionError(resErr))
-	if finishedReq != nil {
-		updateK6Response(resp, finishedReq)
+	if !streaming {
+		finishedReq := tracerTransport.processLastSavedRequest(wrapDecompressionError(resErr))
+		if finishedReq != nil {
+			updateK6Response(resp, finishedReq)
+		}
 	}
 
 	if resErr == nil {
@@ -314,7 +436,7 @@ func MakeRequest(ctx context.Context, state *lib.State, preq *ParsedHTTPRequest)
 			}
 		}
 
-		resp.URL = res.Request.URL.String()
+		resp.URL = urlString(res.Request.URL)
 		resp.Status = res.StatusCode
 		resp.StatusText = res.Status
 		resp.Proto = res.Proto
@@ -327,6 +449,10 @@ func MakeRequest(ctx context.Context, state *lib.State, preq *ParsedHTTPRequest)
 		for k, vs := range res.Header {
 			resp.Headers[k] = strings.Join(vs, ", ")
 		}
+		if !streaming {
+			// the trailers are only known once the whole body has been read
+			resp.Trailers = responseTrailers(res)
+		}
 
 		resCookies := res.Cookies()
 		resp.Cookies = make(map[string][]*HTTPCookie, len(resCookies))
@@ -344,6 +470,10 @@ func MakeRequest(ctx context.Context, state *lib.State, preq *ParsedHTTPRequest)
 		}
 	}
 
+	if !streaming {
+		recordHAR(state, preq, tracerTransport.harEntries, respReq, resp)
+	}
+
 	if resErr != nil {
 		if preq.Throw { // if we are going to throw, we shouldn't log it
 			return nil, resErr
@@ -360,9 +490,19 @@ func MakeRequest(ctx context.Context, state *lib.State, preq *ParsedHTTPRequest)
 	return resp, nil
 }
 
+func responseTrailers(res *http.Response) map[string]string {
+	trailers := make(map[string]string, len(res.Trailer))
+	for k, vs := range res.Trailer {
+		if len(vs) > 0 {
+			trailers[k] = strings.Join(vs, ", ")
+		}
+	}
+	return trailers
+}
+
 // SetRequestCookies sets the cookies of the requests getting those cookies both from the jar and
 // from the reqCookies map. The Replace field of the HTTPRequestCookie will be taken into account
-func SetRequestCookies(req *http.Request, jar *cookiejar.Jar, reqCookies map[string]*HTTPRequestCookie) {
+func SetRequestCookies(req *http.Request, jar *lib.CookieJar, reqCookies map[string]*HTTPRequestCookie) {
 	replacedCookies := make(map[string]struct{})
 	for key, reqCookie := range reqCookies {
 		req.AddCookie(&http.Cookie{Name: key, Value: reqCookie.Value})
@@ -376,3 +516,24 @@ func SetRequestCookies(req *http.Request, jar *cookiejar.Jar, reqCookies map[str
 		}
 	}
 }
+
+type abortKey struct{}
+
+// withAbort returns a context that tells the transport that the request can be aborted through the abort channel,
+// so that the errors caused by that can be told apart from any other context cancellation.
+func withAbort(ctx context.Context, abort <-chan struct{}) context.Context {
+	return context.WithValue(ctx, abortKey{}, abort)
+}
+
+func isAborted(ctx context.Context) bool {
+	abort, ok := ctx.Value(abortKey{}).(<-chan struct{})
+	if !ok {
+		return false
+	}
+	select {
+	case <-abort:
+		return true
+	default:
+		return false
+	}
+}
@@ -160,6 +160,47 @@ func TestMakeRequestError(t *testing.T) {
 		assert.Nil(t, res)
 		assert.EqualError(t, err, "unsupported response status: 101 Switching Protocols")
 	})
+
+	t.Run("h2c with https URL", func(t *testing.T) {
+		t.Parallel()
+		req, err := http.NewRequest("GET", "https://wont.be.used", nil)
+		require.NoError(t, err)
+		state := &lib.State{
+			Options:      lib.Options{RunTags: &stats.SampleTags{}},
+			Transport:    http.DefaultTransport,
+			H2CTransport: http.DefaultTransport,
+			Logger:       logrus.New(),
+			Tags:         lib.NewTagMap(nil),
+		}
+		_, err = MakeRequest(ctx, state, &ParsedHTTPRequest{Req: req, H2C: true})
+		require.EqualError(t, err, "h2c can only be used with http URLs, not https")
+	})
+}
+
+func TestNewUnixSocketURL(t *testing.T) {
+	t.Parallel()
+
+	u, err := NewURL("http+unix://%2Fvar%2Frun%2Fapp.sock/path?a=1#frag", "name")
+	require.NoError(t, err)
+	assert.Equal(t, "http", u.GetURL().Scheme)
+	assert.Equal(t, "/var/run/app.sock", u.GetURL().Host)
+	assert.Equal(t, "/path", u.GetURL().Path)
+	assert.Equal(t, "a=1", u.GetURL().RawQuery)
+	assert.Equal(t, "http+unix://%2Fvar%2Frun%2Fapp.sock/path?a=1#frag", u.Clean())
+	assert.True(t, isUnixSocketURL(u.GetURL()))
+	assert.Equal(t, "http+unix://%2Fvar%2Frun%2Fapp.sock/path?a=1#frag", urlString(u.GetURL()))
+
+	u, err = NewURL("HTTP+UNIX://%2Fapp.sock", "name")
+	require.NoError(t, err)
+	assert.Equal(t, "/app.sock", u.GetURL().Host)
+	assert.Equal(t, "", u.GetURL().Path)
+
+	_, err = NewURL("http+unix://app.sock/path", "name")
+	require.Error(t, err)
+
+	u, err = NewURL("http://example.com/path", "name")
+	require.NoError(t, err)
+	assert.False(t, isUnixSocketURL(u.GetURL()))
 }
 
 func TestResponseStatus(t *testing.T) {
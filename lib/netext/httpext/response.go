@@ -49,6 +49,10 @@ const (
 	// want to  measure, but we don't care about their responses' contents. This is the
 	// default value for all requests if the global discardResponseBodies is enablled.
 	ResponseTypeNone
+	// ResponseTypeStream causes k6 to return as soon as the response headers are received, with
+	// a ResponseStream as the body that is read on demand. The request metrics are emitted once
+	// the whole body has been read or the stream is closed, so that they include the receiving.
+	ResponseTypeStream
 )
 
 // ResponseTimings is a struct to put all timings for a given HTTP response/request
@@ -63,6 +67,14 @@ type ResponseTimings struct {
 	Receiving      float64 `json:"receiving"`
 }
 
+// InformationalResponse is a 1xx response that was received before the final response of a request.
+type InformationalResponse struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers"`
+	// Time is when it was received in milliseconds, since the connection for the request was obtained
+	Time float64 `json:"time"`
+}
+
 // HTTPCookie is a representation of an http cookies used in the Response object
 type HTTPCookie struct {
 	Name, Value, Domain, Path string
@@ -80,6 +92,8 @@ type Response struct {
 	StatusText     string                   `json:"status_text"`
 	Proto          string                   `json:"proto"`
 	Headers        map[string]string        `json:"headers"`
+	Trailers       map[string]string        `json:"trailers"`
+	Informational  []InformationalResponse  `json:"informational"`
 	Cookies        map[string][]*HTTPCookie `json:"cookies"`
 	Body           interface{}              `json:"body"`
 	Timings        ResponseTimings          `json:"timings"`
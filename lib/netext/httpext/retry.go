@@ -0,0 +1,164 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package httpext
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.k6.io/k6/lib"
+)
+
+// RetryPolicy describes when and how often a failed request is sent again.
+//
+// A request is retried if its response has one of the StatusCodes or, if NetworkErrors is set, if it
+// failed without a response. The delay before every retry doubles, starting from Backoff and never
+// going over MaxBackoff, and is randomly shortened by up to the Jitter fraction of it. If the response
+// has a Retry-After header, it is used as the delay instead, still limited by MaxBackoff.
+type RetryPolicy struct {
+	// Attempts is the maximum number of times the request is sent, including the first one
+	Attempts      int
+	StatusCodes   []int
+	NetworkErrors bool
+	Backoff       time.Duration
+	MaxBackoff    time.Duration
+	Jitter        float64
+}
+
+// retryRequest sends the request until it succeeds or the attempts of its retry policy run out, every
+// attempt is tagged with its number so the retries can be told apart in the metrics.
+func retryRequest(ctx context.Context, state *lib.State, preq *ParsedHTTPRequest, respReq *Request) (
+	*Response, error,
+) {
+	policy := preq.Retry
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && preq.Req.GetBody != nil {
+			body, err := preq.Req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			preq.Req.Body = body
+		}
+
+		resp, err := doRequest(ctx, state, preq, respReq, attempt)
+		if attempt >= policy.Attempts || !policy.shouldRetry(resp, err) || ctx.Err() != nil || isClosed(preq.Abort) {
+			return resp, err
+		}
+		// there's no response if the error is going to be thrown, which happens only after the last attempt
+		if stream, ok := responseBody(resp).(*ResponseStream); ok {
+			_ = stream.Close()
+		}
+		if !waitRetry(ctx, preq.Abort, policy.delay(attempt, resp)) {
+			return resp, err
+		}
+	}
+}
+
+func responseBody(resp *Response) interface{} {
+	if resp == nil {
+		return nil
+	}
+	return resp.Body
+}
+
+func (p *RetryPolicy) shouldRetry(resp *Response, err error) bool {
+	if err != nil || resp.Error != "" {
+		return p.NetworkErrors
+	}
+	for _, status := range p.StatusCodes {
+		if resp.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+// delay returns how long to wait before the retry that follows the given attempt.
+func (p *RetryPolicy) delay(attempt int, resp *Response) time.Duration {
+	if resp != nil {
+		if d, ok := parseRetryAfter(resp.Headers["Retry-After"]); ok {
+			return p.limit(d)
+		}
+	}
+
+	d := p.Backoff
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	d = p.limit(d)
+	if p.Jitter > 0 {
+		d -= time.Duration(rand.Float64() * p.Jitter * float64(d)) //nolint:gosec
+	}
+	return d
+}
+
+func (p *RetryPolicy) limit(d time.Duration) time.Duration {
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return d
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which is either a number of seconds or a date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if d := time.Until(date); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// waitRetry waits for the delay, it returns false if the context is done or the request is aborted before that.
+func waitRetry(ctx context.Context, abort <-chan struct{}, delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	case <-abort:
+		return false
+	}
+}
+
+func isClosed(ch <-chan struct{}) bool {
+	if ch == nil {
+		return false
+	}
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,51 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package httpext
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	t.Parallel()
+	policy := &RetryPolicy{Backoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	assert.Equal(t, 100*time.Millisecond, policy.delay(1, nil))
+	assert.Equal(t, 400*time.Millisecond, policy.delay(3, nil))
+	assert.Equal(t, time.Second, policy.delay(10, nil))
+	assert.Equal(t, time.Second, policy.delay(100, nil))
+
+	resp := &Response{Headers: map[string]string{"Retry-After": "0"}}
+	assert.Equal(t, time.Duration(0), policy.delay(3, resp))
+	resp.Headers["Retry-After"] = "120"
+	assert.Equal(t, time.Second, policy.delay(1, resp))
+	resp.Headers["Retry-After"] = time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)
+	assert.Equal(t, time.Duration(0), policy.delay(1, resp))
+
+	policy.Jitter = 0.5
+	for i := 0; i < 10; i++ {
+		d := policy.delay(2, nil)
+		assert.True(t, d > 100*time.Millisecond && d <= 200*time.Millisecond, d)
+	}
+}
@@ -0,0 +1,162 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package httpext
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/stats"
+)
+
+// streamWindow is how often the throughput of a stream is emitted while it's being read.
+const streamWindow = time.Second
+
+// ResponseStream is the body of a response with the stream response type. It isn't safe for concurrent use.
+//
+// Besides the usual request metrics, which are emitted once the stream is done, it emits how long it took
+// for the first chunk of the body to arrive after the headers and the throughput of every window of
+// the stream in bytes per second, so that long downloads can be followed while they are in progress.
+type ResponseStream struct {
+	ctx    context.Context
+	state  *lib.State
+	tags   *stats.SampleTags
+	body   io.Closer
+	reader *readCloser
+	finish func(error)
+
+	start       time.Time
+	gotFirst    bool
+	windowStart time.Time
+	windowBytes int64
+	done        bool
+}
+
+var _ io.ReadCloser = &ResponseStream{}
+
+func newResponseStream(
+	ctx context.Context, state *lib.State, res *http.Response, reader *readCloser,
+	tags *stats.SampleTags, finish func(error),
+) *ResponseStream {
+	now := time.Now()
+	return &ResponseStream{
+		ctx:         ctx,
+		state:       state,
+		tags:        tags,
+		body:        res.Body,
+		reader:      reader,
+		finish:      finish,
+		start:       now,
+		windowStart: now,
+	}
+}
+
+// Read reads the next chunk of the body, it returns io.EOF once all of it has been read.
+func (s *ResponseStream) Read(p []byte) (int, error) {
+	if s.done {
+		return 0, io.EOF
+	}
+	n, err := s.reader.Read(p)
+	now := time.Now()
+	if n > 0 {
+		if !s.gotFirst {
+			s.gotFirst = true
+			s.push(s.state.BuiltinMetrics.HTTPStreamFirstChunk, now, stats.D(now.Sub(s.start)))
+		}
+		s.windowBytes += int64(n)
+		if now.Sub(s.windowStart) >= streamWindow {
+			s.pushThroughput(now)
+		}
+	}
+	if errors.Is(err, io.EOF) {
+		s.end(nil)
+	} else if err != nil {
+		s.end(wrapDecompressionError(err))
+	}
+	return n, err
+}
+
+// Close stops the reading of the body, what was read until then is still measured as usual.
+func (s *ResponseStream) Close() error {
+	if !s.done {
+		s.end(nil)
+	}
+	return nil
+}
+
+// Done returns whether the whole body has been read or the stream was closed.
+func (s *ResponseStream) Done() bool {
+	return s.done
+}
+
+func (s *ResponseStream) end(err error) {
+	s.done = true
+	if s.windowBytes > 0 {
+		s.pushThroughput(time.Now())
+	}
+	_ = s.reader.Close()
+	_ = s.body.Close()
+	s.finish(err)
+}
+
+func (s *ResponseStream) pushThroughput(now time.Time) {
+	if elapsed := now.Sub(s.windowStart); elapsed > 0 {
+		s.push(s.state.BuiltinMetrics.HTTPStreamThroughput, now, float64(s.windowBytes)/elapsed.Seconds())
+	}
+	s.windowStart, s.windowBytes = now, 0
+}
+
+func (s *ResponseStream) push(metric *stats.Metric, now time.Time, value float64) {
+	stats.PushIfNotDone(s.ctx, s.state.Samples, stats.Sample{
+		Metric: metric,
+		Time:   now,
+		Tags:   s.tags,
+		Value:  value,
+	})
+}
+
+// streamTags returns the tags for the stream metrics, the request tags with the same
+// url, name and method system tags as the rest of the request metrics.
+func streamTags(state *lib.State, reqTags map[string]string, req *http.Request) *stats.SampleTags {
+	tags := make(map[string]string, len(reqTags)+3)
+	for k, v := range reqTags {
+		tags[k] = v
+	}
+	enabledTags := state.Options.SystemTags
+	cleanURL := URL{u: req.URL, URL: urlString(req.URL)}.Clean()
+	if enabledTags.Has(stats.TagURL) {
+		tags["url"] = cleanURL
+	}
+	if _, ok := tags["name"]; !ok && enabledTags.Has(stats.TagName) {
+		tags["name"] = cleanURL
+		if name, ok := state.Options.URLGroups.Name(req.URL, cleanURL); ok {
+			tags["name"] = name
+		}
+	}
+	if enabledTags.Has(stats.TagMethod) {
+		tags["method"] = req.Method
+	}
+	return stats.IntoSampleTags(&tags)
+}
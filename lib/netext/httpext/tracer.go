@@ -24,6 +24,9 @@ import (
 	"crypto/tls"
 	"net"
 	"net/http/httptrace"
+	"net/textproto"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -54,6 +57,9 @@ type Trail struct {
 	ConnReused     bool
 	ConnRemoteAddr net.Addr
 
+	// The 1xx responses received before the final one.
+	Informational []InformationalResponse
+
 	Failed null.Bool
 	// Populated by SaveSamples()
 	Tags    *stats.SampleTags
@@ -111,6 +117,15 @@ type Tracer struct {
 
 	connReused     bool
 	connRemoteAddr net.Addr
+
+	informationalLock sync.Mutex
+	informational     []informationalResponse
+}
+
+type informationalResponse struct {
+	status  int
+	headers textproto.MIMEHeader
+	at      int64
 }
 
 // Trace returns a premade ClientTrace that calls all of the Tracer's hooks.
@@ -124,6 +139,7 @@ func (t *Tracer) Trace() *httptrace.ClientTrace {
 		GotConn:              t.GotConn,
 		WroteRequest:         t.WroteRequest,
 		GotFirstResponseByte: t.GotFirstResponseByte,
+		Got1xxResponse:       t.Got1xxResponse,
 	}
 }
 
@@ -266,6 +282,15 @@ func (t *Tracer) GotFirstResponseByte() {
 	atomic.CompareAndSwapInt64(&t.gotFirstResponseByte, 0, now())
 }
 
+// Got1xxResponse is called for each 1xx informational response header
+// returned before the final non-1xx response, e.g. 100 Continue or 103 Early Hints.
+func (t *Tracer) Got1xxResponse(code int, header textproto.MIMEHeader) error {
+	t.informationalLock.Lock()
+	t.informational = append(t.informational, informationalResponse{status: code, headers: header, at: now()})
+	t.informationalLock.Unlock()
+	return nil
+}
+
 // Done calculates all metrics and should be called when the request is finished.
 func (t *Tracer) Done() *Trail {
 	done := time.Now()
@@ -329,6 +354,23 @@ func (t *Tracer) Done() *Trail {
 		trail.Receiving = done.Sub(time.Unix(0, gotFirstResponseByte))
 	}
 
+	t.informationalLock.Lock()
+	trail.Informational = make([]InformationalResponse, 0, len(t.informational))
+	for _, info := range t.informational {
+		headers := make(map[string]string, len(info.headers))
+		for k, vs := range info.headers {
+			headers[k] = strings.Join(vs, ", ")
+		}
+		var at time.Duration
+		if gotConn != 0 && info.at > gotConn {
+			at = time.Duration(info.at - gotConn)
+		}
+		trail.Informational = append(trail.Informational, InformationalResponse{
+			Status: info.status, Headers: headers, Time: stats.D(at),
+		})
+	}
+	t.informationalLock.Unlock()
+
 	// Calculate total times using adjusted values.
 	trail.EndTime = done
 	trail.ConnDuration = trail.Connecting + trail.TLSHandshaking
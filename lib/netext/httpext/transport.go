@@ -31,6 +31,7 @@ import (
 
 	"go.k6.io/k6/lib"
 	"go.k6.io/k6/lib/netext"
+	"go.k6.io/k6/lib/netext/har"
 	"go.k6.io/k6/stats"
 )
 
@@ -41,6 +42,10 @@ type transport struct {
 	state            *lib.State
 	tags             map[string]string
 	responseCallback func(int) bool
+	h2c              bool
+	recordHAR        bool
+	// harEntries are the HAR entries of the finished requests if recordHAR is set
+	harEntries []*har.Entry
 
 	lastRequest     *unfinishedRequest
 	lastRequestLock *sync.Mutex
@@ -54,6 +59,7 @@ type unfinishedRequest struct {
 	request  *http.Request
 	response *http.Response
 	err      error
+	conn     *netext.Conn
 }
 
 // finishedRequest is produced once the request has been finalized; it is
@@ -112,12 +118,15 @@ func (t *transport) measureAndEmitMetrics(unfReq *unfinishedRequest) *finishedRe
 		setName = true
 	}
 	if urlEnabled || setName {
-		cleanURL := URL{u: unfReq.request.URL, URL: unfReq.request.URL.String()}.Clean()
+		cleanURL := URL{u: unfReq.request.URL, URL: urlString(unfReq.request.URL)}.Clean()
 		if urlEnabled {
 			tags["url"] = cleanURL
 		}
 		if setName {
 			tags["name"] = cleanURL
+			if name, ok := t.state.Options.URLGroups.Name(unfReq.request.URL, cleanURL); ok {
+				tags["name"] = name
+			}
 		}
 	}
 
@@ -198,7 +207,25 @@ func (t *transport) measureAndEmitMetrics(unfReq *unfinishedRequest) *finishedRe
 			},
 		)
 	}
+	if unfReq.conn != nil {
+		unfReq.conn.RequestFinished()
+		pool := unfReq.conn.PoolStats()
+		trail.Samples = append(trail.Samples,
+			stats.Sample{
+				Metric: builtinMetrics.HTTPConnsOpen, Time: trail.EndTime, Tags: finalTags, Value: float64(pool.Open),
+			},
+			stats.Sample{
+				Metric: builtinMetrics.HTTPConnsIdle, Time: trail.EndTime, Tags: finalTags, Value: float64(pool.Idle),
+			},
+			stats.Sample{
+				Metric: builtinMetrics.HTTPReqsInFlight, Time: trail.EndTime, Tags: finalTags, Value: float64(pool.InFlight),
+			},
+		)
+	}
 	stats.PushIfNotDone(t.ctx, t.state.Samples, trail)
+	if t.recordHAR {
+		t.harEntries = append(t.harEntries, newHAREntry(result))
+	}
 
 	return result
 }
@@ -240,12 +267,46 @@ func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
 	t.processLastSavedRequest(nil)
 
 	ctx := req.Context()
-	tracer := &Tracer{}
-	reqWithTracer := req.WithContext(httptrace.WithClientTrace(ctx, tracer.Trace()))
-	resp, err := t.state.Transport.RoundTrip(reqWithTracer)
+	roundTripper := t.state.Transport
+	if t.h2c {
+		roundTripper = t.state.H2CTransport
+	}
+	var (
+		resp   *http.Response
+		err    error
+		tracer *Tracer
+		conn   *netext.Conn
+	)
+	for {
+		var closedConn bool
+		tracer = &Tracer{}
+		trace := tracer.Trace()
+		conn = nil
+		gotConn := trace.GotConn
+		trace.GotConn = func(info httptrace.GotConnInfo) {
+			gotConn(info)
+			if c, ok := netext.UnwrapConn(info.Conn); ok {
+				conn = c
+				closedConn = !c.RequestStarted(t.state.Options.MaxRequestsPerConn.Int64)
+			}
+		}
+		resp, err = roundTripper.RoundTrip(req.WithContext(httptrace.WithClientTrace(ctx, trace)))
+		// a connection that was retired, as it reached the limit of its requests, can still be handed
+		// out before the transport notices that it was closed, the request is sent again then
+		if err == nil || !closedConn {
+			break
+		}
+		rewound := rewoundRequest(req)
+		if rewound == nil {
+			break
+		}
+		req = rewound
+	}
 
 	var netError net.Error
-	if errors.As(err, &netError) && netError.Timeout() {
+	if err != nil && isAborted(ctx) {
+		err = NewK6Error(requestAbortedErrorCode, requestAbortedErrorCodeMsg, err)
+	} else if errors.As(err, &netError) && netError.Timeout() {
 		var netOpError *net.OpError
 		if errors.As(err, &netOpError) && netOpError.Op == "dial" {
 			err = NewK6Error(tcpDialTimeoutErrorCode, tcpDialTimeoutErrorCodeMsg, netError)
@@ -260,7 +321,26 @@ func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
 		request:  req,
 		response: resp,
 		err:      err,
+		conn:     conn,
 	})
 
 	return resp, err
 }
+
+// rewoundRequest returns the request with its body rewound, so that it can be sent again,
+// or nil if the body can't be rewound.
+func rewoundRequest(req *http.Request) *http.Request {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req
+	}
+	if req.GetBody == nil {
+		return nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil
+	}
+	rewound := *req
+	rewound.Body = body
+	return &rewound
+}
@@ -21,10 +21,19 @@
 package httpext
 
 import (
+	"errors"
 	"fmt"
 	"net/url"
+	"strings"
 )
 
+// unixSocketScheme is the scheme of the URLs of HTTP servers listening on a unix domain socket,
+// their host is the percent-encoded path of the socket, e.g. http+unix://%2Fvar%2Frun%2Fapp.sock/path
+const unixSocketScheme = "http+unix"
+
+// unixSocketHost is the Host header sent to servers listening on a unix domain socket.
+const unixSocketHost = "localhost"
+
 // A URL wraps net.URL, and preserves the template (if any) the URL was constructed from.
 type URL struct {
 	u        *url.URL
@@ -36,7 +45,7 @@ type URL struct {
 // NewURL returns a new URL for the provided url and name. The error is returned if the url provided
 // can't be parsed
 func NewURL(urlString, name string) (URL, error) {
-	u, err := url.Parse(urlString)
+	u, err := parseURL(urlString)
 	if err != nil {
 		return URL{}, NewK6Error(invalidURLErrorCode,
 			fmt.Sprintf("%s: %s", invalidURLErrorCodeMsg, err), err)
@@ -49,6 +58,48 @@ func NewURL(urlString, name string) (URL, error) {
 	return newURL, nil
 }
 
+// parseURL parses the URL string, the unix socket URLs are turned into http ones with the socket path
+// as their host, so that the dialer knows to connect to the socket instead of a TCP address.
+func parseURL(urlString string) (*url.URL, error) {
+	prefix := unixSocketScheme + "://"
+	if len(urlString) < len(prefix) || !strings.EqualFold(urlString[:len(prefix)], prefix) {
+		return url.Parse(urlString)
+	}
+	rest := urlString[len(prefix):]
+	hostEnd := strings.IndexAny(rest, "/?#")
+	if hostEnd < 0 {
+		hostEnd = len(rest)
+	}
+	socket, err := url.PathUnescape(rest[:hostEnd])
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(socket, "/") {
+		return nil, errors.New("the host of a " + unixSocketScheme + " URL must be the percent-encoded absolute path of a socket")
+	}
+	u, err := url.Parse("http://" + unixSocketHost + rest[hostEnd:])
+	if err != nil {
+		return nil, err
+	}
+	u.Host = socket
+	return u, nil
+}
+
+// isUnixSocketURL returns whether the URL was parsed from a unix socket URL.
+func isUnixSocketURL(u *url.URL) bool {
+	return strings.HasPrefix(u.Host, "/")
+}
+
+// urlString returns the string form of the URL, which for the unix socket URLs is the original http+unix one.
+func urlString(u *url.URL) string {
+	if !isUnixSocketURL(u) {
+		return u.String()
+	}
+	socketURL := *u
+	socketURL.Host = unixSocketHost
+	return unixSocketScheme + "://" + url.PathEscape(u.Host) + strings.TrimPrefix(socketURL.String(), "http://"+unixSocketHost)
+}
+
 // Clean returns an output-safe representation of URL
 func (u URL) Clean() string {
 	if u.CleanURL != "" {
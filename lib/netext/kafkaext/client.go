@@ -0,0 +1,301 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package kafkaext implements a client of Kafka clusters, with just the requests needed to produce and
+// consume records, that connects to the brokers with the dialer and the TLS config of k6.
+package kafkaext
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.k6.io/k6/lib"
+)
+
+// DefaultTimeout is the default timeout of the connections and the requests.
+const DefaultTimeout = 10 * time.Second
+
+// Config is the config of a Client.
+type Config struct {
+	// Brokers are the host:port addresses of the bootstrap brokers
+	Brokers  []string
+	ClientID string
+	TLS      bool
+	SASL     SASLConfig
+	Timeout  time.Duration
+}
+
+// Client is a client of a cluster, which connects to the brokers when they are needed and keeps its
+// connections until it's closed.
+type Client struct {
+	cfg       Config
+	dialer    lib.DialContexter
+	tlsConfig *tls.Config
+
+	mu    sync.Mutex
+	conns map[string]*conn
+	// partitions are the partitions of the topics that were used, with their leaders
+	partitions map[string][]partitionMetadata
+	brokers    map[int32]broker
+}
+
+// NewClient returns a new Client, which connects to the brokers with the dialer, and with a clone
+// of the TLS config if the config has TLS enabled.
+func NewClient(cfg Config, dialer lib.DialContexter, tlsConfig *tls.Config) *Client {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	return &Client{
+		cfg:        cfg,
+		dialer:     dialer,
+		tlsConfig:  tlsConfig,
+		conns:      make(map[string]*conn),
+		partitions: make(map[string][]partitionMetadata),
+		brokers:    make(map[int32]broker),
+	}
+}
+
+// Timeout returns the timeout of the requests.
+func (c *Client) Timeout() time.Duration {
+	return c.cfg.Timeout
+}
+
+func (c *Client) conn(ctx context.Context, addr string) (*conn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cn, ok := c.conns[addr]; ok {
+		return cn, nil
+	}
+	cn, err := dial(ctx, c.dialer, c.tlsConfig, addr, &c.cfg)
+	if err != nil {
+		return nil, err
+	}
+	c.conns[addr] = cn
+	return cn, nil
+}
+
+// roundTrip sends a request to a broker, the connection is closed if it fails,
+// so it gets reconnected by the next request.
+func (c *Client) roundTrip(ctx context.Context, addr string, key int16, body []byte, noResponse bool) ([]byte, error) {
+	cn, err := c.conn(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := cn.roundTrip(key, body, noResponse)
+	if err != nil {
+		_ = cn.Close()
+		c.mu.Lock()
+		if c.conns[addr] == cn {
+			delete(c.conns, addr)
+		}
+		c.mu.Unlock()
+	}
+	return resp, err
+}
+
+// Partitions returns the sorted IDs of the partitions of a topic, which are requested from
+// the bootstrap brokers the first time or if refresh is set.
+func (c *Client) Partitions(ctx context.Context, topic string, refresh bool) ([]int32, error) {
+	partitions, err := c.topicPartitions(ctx, topic, refresh)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int32, len(partitions))
+	for i, p := range partitions {
+		ids[i] = p.ID
+	}
+	return ids, nil
+}
+
+func (c *Client) topicPartitions(ctx context.Context, topic string, refresh bool) ([]partitionMetadata, error) {
+	c.mu.Lock()
+	p, ok := c.partitions[topic]
+	c.mu.Unlock()
+	if ok && !refresh {
+		return p, nil
+	}
+	var err error
+	for _, addr := range c.cfg.Brokers {
+		var resp []byte
+		if resp, err = c.roundTrip(ctx, addr, apiMetadata, encodeMetadata([]string{topic}), false); err != nil {
+			continue
+		}
+		var m *metadata
+		if m, err = decodeMetadata(resp); err != nil {
+			return nil, err
+		}
+		if len(m.Partitions[topic]) == 0 {
+			return nil, fmt.Errorf("the topic %q has no partitions", topic)
+		}
+		c.mu.Lock()
+		for id, b := range m.Brokers {
+			c.brokers[id] = b
+		}
+		c.partitions[topic] = m.Partitions[topic]
+		c.mu.Unlock()
+		return m.Partitions[topic], nil
+	}
+	return nil, fmt.Errorf("the metadata of the topic %q couldn't be requested from any broker: %w", topic, err)
+}
+
+// leaderRoundTrip sends a request to the leader of a partition, after refreshing the metadata if
+// the leader has changed since it was requested.
+func (c *Client) leaderRoundTrip(ctx context.Context, topic string, partition int32, key int16, body []byte,
+	noResponse bool, decode func([]byte) error,
+) error {
+	for refresh := false; ; refresh = true {
+		partitions, err := c.topicPartitions(ctx, topic, refresh)
+		if err != nil {
+			return err
+		}
+		i := sort.Search(len(partitions), func(i int) bool { return partitions[i].ID >= partition })
+		if i == len(partitions) || partitions[i].ID != partition {
+			return fmt.Errorf("the topic %q has no partition %d", topic, partition)
+		}
+		c.mu.Lock()
+		leader, ok := c.brokers[partitions[i].Leader]
+		c.mu.Unlock()
+		if !ok {
+			err = Error(5) // the leader isn't available
+		} else {
+			var resp []byte
+			if resp, err = c.roundTrip(ctx, leader.Addr, key, body, noResponse); err == nil {
+				err = decode(resp)
+			}
+		}
+		var kafkaErr Error
+		if refresh || !errors.As(err, &kafkaErr) || (kafkaErr != 5 && kafkaErr != 6) {
+			return err
+		}
+	}
+}
+
+// Produce produces records to a partition of a topic, in a single batch. It returns the offset of
+// the first record, or -1 if acks is 0 and the request has no response.
+func (c *Client) Produce(
+	ctx context.Context, topic string, partition int32, records []Record, acks, compression int16,
+) (int64, error) {
+	batch, err := encodeBatch(records, compression)
+	if err != nil {
+		return -1, err
+	}
+	offset := int64(-1)
+	err = c.leaderRoundTrip(ctx, topic, partition, apiProduce,
+		encodeProduce(acks, int32(c.cfg.Timeout.Milliseconds()), topic, partition, batch),
+		acks == 0, func(resp []byte) error {
+			if acks == 0 {
+				return nil
+			}
+			var err error
+			offset, err = decodeProduce(resp)
+			return err
+		})
+	if err != nil {
+		return -1, fmt.Errorf("producing to the partition %d of the topic %q failed: %w", partition, topic, err)
+	}
+	return offset, nil
+}
+
+// ListOffsets returns the offset of a partition for a timestamp, LatestOffset or EarliestOffset.
+func (c *Client) ListOffsets(ctx context.Context, topic string, partition int32, timestamp int64) (int64, error) {
+	var offset int64
+	err := c.leaderRoundTrip(ctx, topic, partition, apiListOffsets,
+		encodeListOffsets(topic, partition, timestamp), false, func(resp []byte) error {
+			var err error
+			offset, err = decodeListOffsets(resp)
+			return err
+		})
+	return offset, err
+}
+
+// Fetch returns the records of a partition from an offset, the broker waits for them until the max wait.
+// The returned records can start before the offset, since they are in the batches that contain it.
+func (c *Client) Fetch(
+	ctx context.Context, topic string, partition int32, offset int64, maxWait time.Duration, maxBytes int32,
+) ([]Record, error) {
+	var batches []byte
+	err := c.leaderRoundTrip(ctx, topic, partition, apiFetch,
+		encodeFetch(topic, partition, offset, int32(maxWait.Milliseconds()), maxBytes),
+		false, func(resp []byte) error {
+			var err error
+			batches, err = decodeFetch(resp)
+			return err
+		})
+	if err != nil {
+		return nil, fmt.Errorf("fetching the partition %d of the topic %q failed: %w", partition, topic, err)
+	}
+	return decodeBatches(batches)
+}
+
+// Close closes the connections of the client, it reconnects if it's used again.
+func (c *Client) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for addr, cn := range c.conns {
+		_ = cn.Close()
+		delete(c.conns, addr)
+	}
+}
+
+// KeyPartition returns the partition of a key, from its murmur2 hash like the default partitioner
+// of the Kafka clients, so the records produced with a key end up in the same partition as with them.
+func KeyPartition(key []byte, partitions []int32) int32 {
+	return partitions[(murmur2(key)&0x7fffffff)%int32(len(partitions))]
+}
+
+// murmur2 is the hash of the keys of the default partitioner of the Kafka clients.
+func murmur2(data []byte) int32 {
+	const (
+		seed uint32 = 0x9747b28c
+		m    uint32 = 0x5bd1e995
+		r           = 24
+	)
+	h := seed ^ uint32(len(data))
+	n := len(data) &^ 3
+	for i := 0; i < n; i += 4 {
+		k := binary.LittleEndian.Uint32(data[i:])
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+	}
+	switch tail := data[n:]; len(tail) {
+	case 3:
+		h ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(tail[0])
+		h *= m
+	}
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return int32(h)
+}
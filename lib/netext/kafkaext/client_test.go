@@ -0,0 +1,115 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package kafkaext
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient(t *testing.T) {
+	t.Parallel()
+	broker := NewTestBroker(t, nil, false)
+	ctx := context.Background()
+	c := NewClient(Config{Brokers: []string{broker.Addr}, ClientID: "k6"}, &net.Dialer{}, nil)
+	defer c.Close()
+
+	partitions, err := c.Partitions(ctx, "test", false)
+	require.NoError(t, err)
+	assert.Equal(t, []int32{0, 1}, partitions)
+	// murmur2("21") is -973932308, so the key "21" is in the partition 0
+	assert.Equal(t, int32(0), KeyPartition([]byte("21"), partitions))
+
+	records := []Record{{Timestamp: time.Now(), Value: []byte("a")}, {Timestamp: time.Now(), Value: []byte("b")}}
+	offset, err := c.Produce(ctx, "test", 1, records, -1, CompressionGzip)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), offset)
+	offset, err = c.Produce(ctx, "test", 1, records[:1], 0, CompressionNone)
+	require.NoError(t, err)
+	assert.Equal(t, int64(-1), offset)
+
+	latest, err := c.ListOffsets(ctx, "test", 1, LatestOffset)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), latest)
+	fetched, err := c.Fetch(ctx, "test", 1, 1, 100*time.Millisecond, 1<<20)
+	require.NoError(t, err)
+	require.Len(t, fetched, 3)
+	assert.Equal(t, []byte("a"), fetched[2].Value)
+
+	_, err = c.Produce(ctx, "missing", 0, records, -1, CompressionNone)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "UNKNOWN_TOPIC_OR_PARTITION")
+	_, err = c.Produce(ctx, "test", 5, records, -1, CompressionNone)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no partition 5")
+}
+
+func TestMurmur2(t *testing.T) {
+	t.Parallel()
+	// the values of the tests of the Kafka clients
+	tests := map[string]int32{
+		"21":                         -973932308,
+		"foobar":                     -790332482,
+		"a-little-bit-long-string":   -985981536,
+		"a-little-bit-longer-string": -1486304829,
+		"lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8": -58897971,
+		"abc": 479470107,
+	}
+	for key, hash := range tests {
+		assert.Equal(t, hash, murmur2([]byte(key)), key)
+	}
+}
+
+func TestRecordBatch(t *testing.T) {
+	t.Parallel()
+	now := time.UnixMilli(time.Now().UnixMilli())
+	records := []Record{
+		{Timestamp: now, Key: []byte("key"), Value: []byte("value"), Headers: []Header{{Key: "h", Value: []byte("v")}}},
+		{Timestamp: now.Add(time.Second), Value: []byte("value2")},
+	}
+	for _, compression := range []int16{CompressionNone, CompressionGzip} {
+		batch, err := encodeBatch(records, compression)
+		require.NoError(t, err)
+		binary.BigEndian.PutUint64(batch, 10)
+
+		// a trailing partial batch is ignored
+		decoded, err := decodeBatches(append(batch, batch[:20]...))
+		require.NoError(t, err)
+		require.Len(t, decoded, 2)
+		for i, r := range decoded {
+			assert.Equal(t, int64(10+i), r.Offset)
+			assert.True(t, records[i].Timestamp.Equal(r.Timestamp))
+			assert.Equal(t, records[i].Key, r.Key)
+			assert.Equal(t, records[i].Value, r.Value)
+			assert.Equal(t, records[i].Headers, r.Headers)
+		}
+
+		batch[len(batch)-1]++
+		_, err = decodeBatches(batch)
+		assert.Error(t, err)
+	}
+}
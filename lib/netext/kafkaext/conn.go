@@ -0,0 +1,221 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package kafkaext
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/netext/scram"
+)
+
+// conn is a connection to a broker, which sends one request at a time.
+type conn struct {
+	net.Conn
+	r        *bufio.Reader
+	clientID string
+	timeout  time.Duration
+
+	mu            sync.Mutex
+	correlationID int32
+}
+
+// dial connects to a broker and authenticates with SASL, if the config has a mechanism.
+func dial(
+	ctx context.Context, dialer lib.DialContexter, baseTLSConfig *tls.Config, addr string, cfg *Config,
+) (*conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+	nc, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.TLS {
+		tlsConfig := &tls.Config{} //nolint:gosec
+		if baseTLSConfig != nil {
+			tlsConfig = baseTLSConfig.Clone()
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName, _, _ = net.SplitHostPort(addr)
+		}
+		tlsConn := tls.Client(nc, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			_ = nc.Close()
+			return nil, err
+		}
+		nc = tlsConn
+	}
+
+	c := &conn{Conn: nc, r: bufio.NewReader(nc), clientID: cfg.ClientID, timeout: cfg.Timeout}
+	if cfg.SASL.Mechanism != "" {
+		if err := c.authenticate(&cfg.SASL); err != nil {
+			_ = nc.Close()
+			return nil, fmt.Errorf("the SASL authentication with %s failed: %w", addr, err)
+		}
+	}
+	return c, nil
+}
+
+// roundTrip sends a request and returns the body of its response, unless it has none,
+// like the produce requests that don't wait for any acknowledgement.
+func (c *conn) roundTrip(key int16, body []byte, noResponse bool) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.correlationID++
+
+	var e encoder
+	e.int32(0) // the size, set below
+	e.int16(key)
+	e.int16(apiVersions[key])
+	e.int32(c.correlationID)
+	e.nullableString(c.clientID)
+	e.b = append(e.b, body...)
+	binary.BigEndian.PutUint32(e.b, uint32(len(e.b)-4))
+
+	if err := c.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return nil, err
+	}
+	if _, err := c.Write(e.b); err != nil {
+		return nil, err
+	}
+	if noResponse {
+		return nil, nil
+	}
+
+	var size [4]byte
+	if _, err := io.ReadFull(c.r, size[:]); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, binary.BigEndian.Uint32(size[:]))
+	if _, err := io.ReadFull(c.r, resp); err != nil {
+		return nil, err
+	}
+	d := decoder{b: resp}
+	if id := d.int32(); d.err != nil || id != c.correlationID {
+		return nil, errMalformedResponse
+	}
+	return d.b, nil
+}
+
+// SASLConfig is the SASL mechanism and credentials of the connections.
+type SASLConfig struct {
+	Mechanism string
+	Username  string
+	Password  string
+}
+
+// Validate returns an error if the SASL mechanism isn't one of the supported ones.
+func (cfg SASLConfig) Validate() error {
+	switch cfg.Mechanism {
+	case "", "PLAIN", "SCRAM-SHA-256", "SCRAM-SHA-512":
+		return nil
+	default:
+		return fmt.Errorf("invalid SASL mechanism %q, it needs to be PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512",
+			cfg.Mechanism)
+	}
+}
+
+func (c *conn) authenticate(cfg *SASLConfig) error {
+	var e encoder
+	e.string(cfg.Mechanism)
+	resp, err := c.roundTrip(apiSaslHandshake, e.b, false)
+	if err != nil {
+		return err
+	}
+	d := decoder{b: resp}
+	code := d.int16()
+	var mechanisms []string
+	for i, n := 0, d.arrayLen(); i < n; i++ {
+		mechanisms = append(mechanisms, d.string())
+	}
+	if d.err != nil {
+		return d.err
+	}
+	if err := errorCode(code); err != nil {
+		return fmt.Errorf("%w, the broker supports %s", err, strings.Join(mechanisms, ", "))
+	}
+
+	switch cfg.Mechanism {
+	case "PLAIN":
+		_, err = c.saslAuthenticate([]byte("\x00" + cfg.Username + "\x00" + cfg.Password))
+		return err
+	case "SCRAM-SHA-256":
+		return c.scram(sha256.New, cfg)
+	case "SCRAM-SHA-512":
+		return c.scram(sha512.New, cfg)
+	default:
+		return fmt.Errorf("the SASL mechanism %q isn't supported", cfg.Mechanism)
+	}
+}
+
+func (c *conn) saslAuthenticate(b []byte) ([]byte, error) {
+	var e encoder
+	e.bytes(b)
+	resp, err := c.roundTrip(apiSaslAuthenticate, e.b, false)
+	if err != nil {
+		return nil, err
+	}
+	d := decoder{b: resp}
+	code, msg, authBytes := d.int16(), d.string(), d.bytes()
+	if d.err != nil {
+		return nil, d.err
+	}
+	if err := errorCode(code); err != nil {
+		if msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	return authBytes, nil
+}
+
+// scram authenticates with a SCRAM mechanism, see https://datatracker.ietf.org/doc/html/rfc5802
+func (c *conn) scram(h func() hash.Hash, cfg *SASLConfig) error {
+	client, err := scram.NewClient(h, cfg.Username, cfg.Password)
+	if err != nil {
+		return err
+	}
+	serverFirst, err := c.saslAuthenticate(client.ClientFirst())
+	if err != nil {
+		return err
+	}
+	clientFinal, err := client.ClientFinal(serverFirst)
+	if err != nil {
+		return err
+	}
+	serverFinal, err := c.saslAuthenticate(clientFinal)
+	if err != nil {
+		return err
+	}
+	return client.Verify(serverFinal)
+}
@@ -0,0 +1,411 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package kafkaext
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// The keys and the versions of the Kafka APIs that are used, see https://kafka.apache.org/protocol#protocol_api_keys
+const (
+	apiProduce          int16 = 0
+	apiFetch            int16 = 1
+	apiListOffsets      int16 = 2
+	apiMetadata         int16 = 3
+	apiSaslHandshake    int16 = 17
+	apiSaslAuthenticate int16 = 36
+)
+
+// apiVersions are the versions of the requests, the oldest ones that support the record batches.
+var apiVersions = map[int16]int16{ //nolint:gochecknoglobals
+	apiProduce:          3,
+	apiFetch:            4,
+	apiListOffsets:      1,
+	apiMetadata:         1,
+	apiSaslHandshake:    1,
+	apiSaslAuthenticate: 0,
+}
+
+var errMalformedResponse = errors.New("malformed Kafka response")
+
+// Error is an error code returned by a broker.
+type Error int16
+
+// errorNames are the names of the error codes that are the most likely to be returned, see
+// https://kafka.apache.org/protocol#protocol_error_codes
+var errorNames = map[Error]string{ //nolint:gochecknoglobals
+	1:  "OFFSET_OUT_OF_RANGE",
+	2:  "CORRUPT_MESSAGE",
+	3:  "UNKNOWN_TOPIC_OR_PARTITION",
+	5:  "LEADER_NOT_AVAILABLE",
+	6:  "NOT_LEADER_OR_FOLLOWER",
+	7:  "REQUEST_TIMED_OUT",
+	10: "MESSAGE_TOO_LARGE",
+	17: "INVALID_TOPIC_EXCEPTION",
+	19: "NOT_ENOUGH_REPLICAS",
+	29: "TOPIC_AUTHORIZATION_FAILED",
+	33: "UNSUPPORTED_SASL_MECHANISM",
+	34: "ILLEGAL_SASL_STATE",
+	58: "SASL_AUTHENTICATION_FAILED",
+}
+
+func (e Error) Error() string {
+	if name, ok := errorNames[e]; ok {
+		return fmt.Sprintf("kafka error %d (%s)", int16(e), name)
+	}
+	return fmt.Sprintf("kafka error %d", int16(e))
+}
+
+// errorCode returns the error of an error code, or nil if it's 0.
+func errorCode(code int16) error {
+	if code == 0 {
+		return nil
+	}
+	return Error(code)
+}
+
+// encoder builds a request, with the big-endian encoding of the protocol.
+type encoder struct {
+	b []byte
+}
+
+func (e *encoder) int8(v int8) {
+	e.b = append(e.b, byte(v))
+}
+
+func (e *encoder) int16(v int16) {
+	e.b = append(e.b, byte(v>>8), byte(v))
+}
+
+func (e *encoder) int32(v int32) {
+	e.b = append(e.b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func (e *encoder) int64(v int64) {
+	e.int32(int32(v >> 32))
+	e.int32(int32(v))
+}
+
+func (e *encoder) string(s string) {
+	e.int16(int16(len(s)))
+	e.b = append(e.b, s...)
+}
+
+// nullableString writes the null string for an empty one.
+func (e *encoder) nullableString(s string) {
+	if s == "" {
+		e.int16(-1)
+		return
+	}
+	e.string(s)
+}
+
+func (e *encoder) bytes(b []byte) {
+	e.int32(int32(len(b)))
+	e.b = append(e.b, b...)
+}
+
+// arrayLen writes the length of an array, whose elements have to be written after it.
+func (e *encoder) arrayLen(n int) {
+	e.int32(int32(n))
+}
+
+// varint writes a zigzag encoded integer, as used in the records.
+func (e *encoder) varint(v int64) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(buf[:], v)
+	e.b = append(e.b, buf[:n]...)
+}
+
+// varBytes writes bytes prefixed with their varint length, or -1 for nil.
+func (e *encoder) varBytes(b []byte) {
+	if b == nil {
+		e.varint(-1)
+		return
+	}
+	e.varint(int64(len(b)))
+	e.b = append(e.b, b...)
+}
+
+// decoder reads a response, a read past the end sets err and returns zero values.
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) take(n int) []byte {
+	if d.err != nil || n < 0 || len(d.b) < n {
+		d.err = errMalformedResponse
+		return nil
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) int8() int8 {
+	if b := d.take(1); b != nil {
+		return int8(b[0])
+	}
+	return 0
+}
+
+func (d *decoder) int16() int16 {
+	if b := d.take(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (d *decoder) int32() int32 {
+	if b := d.take(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (d *decoder) int64() int64 {
+	if b := d.take(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+func (d *decoder) string() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.take(int(n)))
+}
+
+// bytes returns nil for null bytes.
+func (d *decoder) bytes() []byte {
+	n := d.int32()
+	if n < 0 {
+		return nil
+	}
+	return d.take(int(n))
+}
+
+// arrayLen returns the length of an array, which is 0 for null arrays or after an error.
+func (d *decoder) arrayLen() int {
+	n := int(d.int32())
+	// every element takes at least a byte, this catches the lengths of corrupted responses
+	if d.err != nil || n < 0 || n > len(d.b) {
+		if n > len(d.b) {
+			d.err = errMalformedResponse
+		}
+		return 0
+	}
+	return n
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.b)
+	if n <= 0 {
+		d.err = errMalformedResponse
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) varBytes() []byte {
+	n := d.varint()
+	if n < 0 {
+		return nil
+	}
+	return d.take(int(n))
+}
+
+// broker is a broker of the cluster, as listed by the metadata.
+type broker struct {
+	ID   int32
+	Addr string
+}
+
+// partitionMetadata is the metadata of a partition of a topic.
+type partitionMetadata struct {
+	ID     int32
+	Leader int32
+}
+
+// metadata is the part of a metadata response that is used.
+type metadata struct {
+	Brokers map[int32]broker
+	// Partitions are the partitions of the requested topics, sorted by partition ID
+	Partitions map[string][]partitionMetadata
+}
+
+func encodeMetadata(topics []string) []byte {
+	var e encoder
+	e.arrayLen(len(topics))
+	for _, t := range topics {
+		e.string(t)
+	}
+	return e.b
+}
+
+func decodeMetadata(b []byte) (*metadata, error) {
+	d := decoder{b: b}
+	m := &metadata{Brokers: make(map[int32]broker), Partitions: make(map[string][]partitionMetadata)}
+	for i, n := 0, d.arrayLen(); i < n; i++ {
+		id, host, port := d.int32(), d.string(), d.int32()
+		_ = d.string() // rack
+		m.Brokers[id] = broker{ID: id, Addr: fmt.Sprintf("%s:%d", host, port)}
+	}
+	_ = d.int32() // controller id
+	for i, n := 0, d.arrayLen(); i < n; i++ {
+		code, name := d.int16(), d.string()
+		_ = d.int8() // is internal
+		if err := errorCode(code); err != nil && d.err == nil {
+			return nil, fmt.Errorf("the metadata of the topic %q: %w", name, err)
+		}
+		partitions := make([]partitionMetadata, d.arrayLen())
+		for j := range partitions {
+			_ = d.int16() // the partition errors are checked by the requests that use it
+			partitions[j] = partitionMetadata{ID: d.int32(), Leader: d.int32()}
+			for k, replicas := 0, d.arrayLen(); k < replicas; k++ {
+				_ = d.int32()
+			}
+			for k, isr := 0, d.arrayLen(); k < isr; k++ {
+				_ = d.int32()
+			}
+		}
+		sort.Slice(partitions, func(i, j int) bool { return partitions[i].ID < partitions[j].ID })
+		m.Partitions[name] = partitions
+	}
+	return m, d.err
+}
+
+func encodeProduce(acks int16, timeout int32, topic string, partition int32, batch []byte) []byte {
+	var e encoder
+	e.nullableString("") // transactional id
+	e.int16(acks)
+	e.int32(timeout)
+	e.arrayLen(1)
+	e.string(topic)
+	e.arrayLen(1)
+	e.int32(partition)
+	e.bytes(batch)
+	return e.b
+}
+
+// decodeProduce returns the offset of the first produced record.
+func decodeProduce(b []byte) (int64, error) {
+	d := decoder{b: b}
+	if d.arrayLen() != 1 {
+		return 0, errMalformedResponse
+	}
+	_ = d.string()
+	if d.arrayLen() != 1 {
+		return 0, errMalformedResponse
+	}
+	_ = d.int32()
+	code, offset := d.int16(), d.int64()
+	if d.err != nil {
+		return 0, d.err
+	}
+	return offset, errorCode(code)
+}
+
+// The special timestamps of the ListOffsets requests, for the earliest and the latest offsets of a partition.
+const (
+	LatestOffset   int64 = -1
+	EarliestOffset int64 = -2
+)
+
+func encodeListOffsets(topic string, partition int32, timestamp int64) []byte {
+	var e encoder
+	e.int32(-1) // replica id
+	e.arrayLen(1)
+	e.string(topic)
+	e.arrayLen(1)
+	e.int32(partition)
+	e.int64(timestamp)
+	return e.b
+}
+
+func decodeListOffsets(b []byte) (int64, error) {
+	d := decoder{b: b}
+	if d.arrayLen() != 1 {
+		return 0, errMalformedResponse
+	}
+	_ = d.string()
+	if d.arrayLen() != 1 {
+		return 0, errMalformedResponse
+	}
+	_ = d.int32()
+	code := d.int16()
+	_ = d.int64() // timestamp
+	offset := d.int64()
+	if d.err != nil {
+		return 0, d.err
+	}
+	return offset, errorCode(code)
+}
+
+func encodeFetch(topic string, partition int32, offset int64, maxWait, maxBytes int32) []byte {
+	var e encoder
+	e.int32(-1) // replica id
+	e.int32(maxWait)
+	e.int32(1) // min bytes
+	e.int32(maxBytes)
+	e.int8(0) // read uncommitted, the records of aborted transactions aren't filtered out
+	e.arrayLen(1)
+	e.string(topic)
+	e.arrayLen(1)
+	e.int32(partition)
+	e.int64(offset)
+	e.int32(maxBytes)
+	return e.b
+}
+
+// decodeFetch returns the record batches of a fetch response.
+func decodeFetch(b []byte) ([]byte, error) {
+	d := decoder{b: b}
+	_ = d.int32() // throttle time
+	if d.arrayLen() != 1 {
+		return nil, errMalformedResponse
+	}
+	_ = d.string()
+	if d.arrayLen() != 1 {
+		return nil, errMalformedResponse
+	}
+	_ = d.int32()
+	code := d.int16()
+	_, _ = d.int64(), d.int64() // high watermark and last stable offset
+	for i, n := 0, d.arrayLen(); i < n; i++ {
+		_, _ = d.int64(), d.int64() // aborted transactions
+	}
+	records := d.bytes()
+	if d.err != nil {
+		return nil, d.err
+	}
+	return records, errorCode(code)
+}
@@ -0,0 +1,211 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package kafkaext
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"time"
+)
+
+// Record is a record of a topic partition, the offset is only set for the consumed ones.
+type Record struct {
+	Offset    int64
+	Timestamp time.Time
+	Key       []byte
+	Value     []byte
+	Headers   []Header
+}
+
+// Header is a header of a record.
+type Header struct {
+	Key   string
+	Value []byte
+}
+
+// The compression codecs of the record batches, in the lowest bits of their attributes.
+const (
+	CompressionNone int16 = 0
+	CompressionGzip int16 = 1
+
+	attributesCompression = 0x07
+	attributesControl     = 0x20
+)
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli) //nolint:gochecknoglobals
+
+// encodeBatch encodes records in a record batch of the version 2 of the message format, see
+// https://kafka.apache.org/documentation/#recordbatch
+func encodeBatch(records []Record, compression int16) ([]byte, error) {
+	firstTimestamp := records[0].Timestamp
+	maxTimestamp := firstTimestamp
+	var e encoder
+	for i, r := range records {
+		if r.Timestamp.After(maxTimestamp) {
+			maxTimestamp = r.Timestamp
+		}
+		var re encoder
+		re.int8(0) // attributes
+		re.varint(r.Timestamp.Sub(firstTimestamp).Milliseconds())
+		re.varint(int64(i))
+		re.varBytes(r.Key)
+		re.varBytes(r.Value)
+		re.varint(int64(len(r.Headers)))
+		for _, h := range r.Headers {
+			re.varBytes([]byte(h.Key))
+			re.varBytes(h.Value)
+		}
+		e.varint(int64(len(re.b)))
+		e.b = append(e.b, re.b...)
+	}
+	data, err := compress(compression, e.b)
+	if err != nil {
+		return nil, err
+	}
+
+	// everything after the CRC, which is computed over it
+	var body encoder
+	body.int16(compression)
+	body.int32(int32(len(records) - 1)) // last offset delta
+	body.int64(firstTimestamp.UnixMilli())
+	body.int64(maxTimestamp.UnixMilli())
+	body.int64(-1) // producer id
+	body.int16(-1) // producer epoch
+	body.int32(-1) // base sequence
+	body.arrayLen(len(records))
+	body.b = append(body.b, data...)
+
+	var batch encoder
+	batch.int64(0)                              // base offset, assigned by the broker
+	batch.int32(int32(4 + 1 + 4 + len(body.b))) // the length of everything after it
+	batch.int32(-1)                             // partition leader epoch
+	batch.int8(2)                               // magic
+	batch.int32(int32(crc32.Checksum(body.b, crc32c)))
+	batch.b = append(batch.b, body.b...)
+	return batch.b, nil
+}
+
+// decodeBatches decodes the records of the record batches in the records of a fetch response, which can end
+// with a partial batch, as the brokers stop at the max bytes of the request.
+func decodeBatches(b []byte) ([]Record, error) {
+	var records []Record
+	for len(b) >= 12 {
+		length := int(binary.BigEndian.Uint32(b[8:12]))
+		if len(b) < 12+length {
+			break
+		}
+		batch := b[:12+length]
+		b = b[12+length:]
+
+		rs, err := decodeBatch(batch)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rs...)
+	}
+	return records, nil
+}
+
+func decodeBatch(b []byte) ([]Record, error) {
+	d := decoder{b: b}
+	baseOffset := d.int64()
+	_, _ = d.int32(), d.int32() // length and partition leader epoch
+	if magic := d.int8(); magic != 2 && d.err == nil {
+		return nil, fmt.Errorf("the version %d of the message format isn't supported", magic)
+	}
+	crc := uint32(d.int32())
+	if d.err == nil && crc32.Checksum(d.b, crc32c) != crc {
+		return nil, fmt.Errorf("the record batch at the offset %d is corrupted", baseOffset)
+	}
+	attributes := d.int16()
+	_ = d.int32() // last offset delta
+	firstTimestamp := d.int64()
+	_, _, _, _ = d.int64(), d.int64(), d.int16(), d.int32() // max timestamp, producer id and epoch, base sequence
+	count := d.int32()
+	if d.err != nil {
+		return nil, d.err
+	}
+	if attributes&attributesControl != 0 {
+		return nil, nil // the markers of the transactions
+	}
+	data, err := decompress(attributes&attributesCompression, d.b)
+	if err != nil {
+		return nil, err
+	}
+
+	d = decoder{b: data}
+	records := make([]Record, 0, count)
+	for i := int32(0); i < count && d.err == nil; i++ {
+		rd := decoder{b: d.take(int(d.varint()))}
+		_ = rd.int8() // attributes
+		timestampDelta := rd.varint()
+		offsetDelta := rd.varint()
+		r := Record{
+			Offset:    baseOffset + offsetDelta,
+			Timestamp: time.UnixMilli(firstTimestamp + timestampDelta),
+			Key:       rd.varBytes(),
+			Value:     rd.varBytes(),
+		}
+		for j, n := int64(0), rd.varint(); j < n && rd.err == nil; j++ {
+			r.Headers = append(r.Headers, Header{Key: string(rd.varBytes()), Value: rd.varBytes()})
+		}
+		if rd.err != nil {
+			return nil, rd.err
+		}
+		records = append(records, r)
+	}
+	return records, d.err
+}
+
+// compress compresses the records of a batch, the other codecs than gzip need newer versions of the requests.
+func compress(codec int16, data []byte) ([]byte, error) {
+	if codec == CompressionNone {
+		return data, nil
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decompress(codec int16, data []byte) ([]byte, error) {
+	switch codec {
+	case CompressionNone:
+		return data, nil
+	case CompressionGzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(r)
+	default:
+		return nil, fmt.Errorf("the compression codec %d isn't supported, only gzip is", codec)
+	}
+}
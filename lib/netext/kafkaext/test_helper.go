@@ -0,0 +1,303 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package kafkaext
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/lib/netext/scram"
+)
+
+// TestBroker is a cluster of a single broker with just enough of the protocol for the tests. It has the
+// "test" topic with 2 partitions, and it authenticates the "k6" user with the "secret" password when it
+// has SASL enabled.
+type TestBroker struct {
+	Addr string
+	sasl bool
+
+	mu   sync.Mutex
+	logs map[int32][][]byte
+	next map[int32]int64
+}
+
+// TestPartitions is the number of partitions of the "test" topic of the TestBroker.
+const TestPartitions = 2
+
+// NewTestBroker starts a TestBroker, which is stopped at the end of the test, with TLS if it has a config.
+func NewTestBroker(t testing.TB, tlsConfig *tls.Config, sasl bool) *TestBroker {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	if tlsConfig != nil {
+		ln = tls.NewListener(ln, tlsConfig)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	b := &TestBroker{
+		Addr: ln.Addr().String(),
+		sasl: sasl,
+		logs: make(map[int32][][]byte),
+		next: make(map[int32]int64),
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(conn)
+		}
+	}()
+	return b
+}
+
+func (b *TestBroker) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	r := bufio.NewReader(conn)
+	authenticated := !b.sasl
+	var scramServer *scram.TestServer
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			return
+		}
+		req := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(r, req); err != nil {
+			return
+		}
+		d := decoder{b: req}
+		key, _, correlationID := d.int16(), d.int16(), d.int32()
+		_ = d.string() // client id
+
+		var resp encoder
+		switch {
+		case key == apiSaslHandshake:
+			mechanism := d.string()
+			code := int16(33)
+			switch mechanism {
+			case "PLAIN":
+				code = 0
+			case "SCRAM-SHA-256":
+				code, scramServer = 0, &scram.TestServer{H: sha256.New, Username: "k6", Password: "secret"}
+			case "SCRAM-SHA-512":
+				code, scramServer = 0, &scram.TestServer{H: sha512.New, Username: "k6", Password: "secret"}
+			}
+			resp.int16(code)
+			resp.arrayLen(3)
+			resp.string("PLAIN")
+			resp.string("SCRAM-SHA-256")
+			resp.string("SCRAM-SHA-512")
+		case key == apiSaslAuthenticate:
+			var reply []byte
+			ok := false
+			if scramServer == nil {
+				ok = string(d.bytes()) == "\x00k6\x00secret"
+			} else {
+				reply, ok = scramServer.Step(d.bytes())
+			}
+			if !ok {
+				resp.int16(58)
+				resp.string("invalid credentials")
+				resp.bytes(nil)
+				b.respond(conn, correlationID, resp.b)
+				return
+			}
+			authenticated = scramServer == nil || scramServer.Done
+			resp.int16(0)
+			resp.nullableString("")
+			resp.bytes(reply)
+		case !authenticated:
+			return
+		case key == apiMetadata:
+			b.metadata(&d, &resp)
+		case key == apiProduce:
+			if !b.produce(&d, &resp) {
+				continue
+			}
+		case key == apiListOffsets:
+			b.listOffsets(&d, &resp)
+		case key == apiFetch:
+			b.fetch(&d, &resp)
+		default:
+			return
+		}
+		b.respond(conn, correlationID, resp.b)
+	}
+}
+
+func (b *TestBroker) respond(conn net.Conn, correlationID int32, body []byte) {
+	var e encoder
+	e.int32(int32(4 + len(body)))
+	e.int32(correlationID)
+	e.b = append(e.b, body...)
+	_, _ = conn.Write(e.b)
+}
+
+func (b *TestBroker) metadata(d *decoder, resp *encoder) {
+	host, rawPort, _ := net.SplitHostPort(b.Addr)
+	port, _ := strconv.Atoi(rawPort)
+	resp.arrayLen(1)
+	resp.int32(1)
+	resp.string(host)
+	resp.int32(int32(port))
+	resp.nullableString("")
+	resp.int32(1) // controller id
+
+	n := d.arrayLen()
+	resp.arrayLen(n)
+	for i := 0; i < n; i++ {
+		topic := d.string()
+		if topic != "test" {
+			resp.int16(3)
+			resp.string(topic)
+			resp.int8(0)
+			resp.arrayLen(0)
+			continue
+		}
+		resp.int16(0)
+		resp.string(topic)
+		resp.int8(0)
+		resp.arrayLen(TestPartitions)
+		for p := int32(TestPartitions - 1); p >= 0; p-- {
+			resp.int16(0)
+			resp.int32(p)
+			resp.int32(1) // leader
+			resp.arrayLen(1)
+			resp.int32(1)
+			resp.arrayLen(1)
+			resp.int32(1)
+		}
+	}
+}
+
+// produce appends the batch of a produce request to the log of its partition, after setting its base offset.
+// It returns false if the request doesn't have a response.
+func (b *TestBroker) produce(d *decoder, resp *encoder) bool {
+	_ = d.string() // transactional id
+	acks := d.int16()
+	_ = d.int32()
+	_ = d.arrayLen()
+	topic := d.string()
+	_ = d.arrayLen()
+	partition := d.int32()
+	batch := append([]byte{}, d.bytes()...)
+
+	b.mu.Lock()
+	offset := b.next[partition]
+	binary.BigEndian.PutUint64(batch, uint64(offset))
+	b.next[partition] += int64(binary.BigEndian.Uint32(batch[23:27])) + 1
+	b.logs[partition] = append(b.logs[partition], batch)
+	b.mu.Unlock()
+
+	resp.arrayLen(1)
+	resp.string(topic)
+	resp.arrayLen(1)
+	resp.int32(partition)
+	resp.int16(0)
+	resp.int64(offset)
+	resp.int64(-1) // log append time
+	resp.int32(0)  // throttle time
+	return acks != 0
+}
+
+func (b *TestBroker) listOffsets(d *decoder, resp *encoder) {
+	_ = d.int32()
+	_ = d.arrayLen()
+	topic := d.string()
+	_ = d.arrayLen()
+	partition, timestamp := d.int32(), d.int64()
+
+	offset := int64(0)
+	if timestamp == LatestOffset {
+		b.mu.Lock()
+		offset = b.next[partition]
+		b.mu.Unlock()
+	}
+	resp.arrayLen(1)
+	resp.string(topic)
+	resp.arrayLen(1)
+	resp.int32(partition)
+	resp.int16(0)
+	resp.int64(-1)
+	resp.int64(offset)
+}
+
+// fetch returns all the batches with records from the offset, it waits for them until the max wait.
+func (b *TestBroker) fetch(d *decoder, resp *encoder) {
+	_ = d.int32()
+	maxWait := time.Duration(d.int32()) * time.Millisecond
+	_, _, _ = d.int32(), d.int32(), d.int8()
+	_ = d.arrayLen()
+	topic := d.string()
+	_ = d.arrayLen()
+	partition, offset := d.int32(), d.int64()
+
+	var records []byte
+	for deadline := time.Now().Add(maxWait); ; time.Sleep(10 * time.Millisecond) {
+		b.mu.Lock()
+		for _, batch := range b.logs[partition] {
+			if int64(binary.BigEndian.Uint64(batch))+int64(binary.BigEndian.Uint32(batch[23:27])) >= offset {
+				records = append(records, batch...)
+			}
+		}
+		b.mu.Unlock()
+		if records != nil || time.Now().After(deadline) {
+			break
+		}
+	}
+	resp.int32(0)
+	resp.arrayLen(1)
+	resp.string(topic)
+	resp.arrayLen(1)
+	resp.int32(partition)
+	resp.int16(0)
+	resp.int64(-1)
+	resp.int64(-1)
+	resp.int32(-1) // aborted transactions
+	resp.bytes(records)
+}
+
+// Records returns the records that were produced to a partition of the "test" topic.
+func (b *TestBroker) Records(partition int32) ([]Record, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var records []Record
+	for _, batch := range b.logs[partition] {
+		rs, err := decodeBatches(batch)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rs...)
+	}
+	return records, nil
+}
@@ -0,0 +1,309 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package pgext implements the frontend side of the PostgreSQL protocol, see
+// https://www.postgresql.org/docs/current/protocol.html, with the startup and the authentication of
+// the sessions and the simple queries. The other messages can be exchanged with ReadMessage and
+// AppendMessage.
+package pgext
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5" //nolint:gosec
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"go.k6.io/k6/lib/netext/scram"
+)
+
+// The codes of the startup and the authentication messages.
+const (
+	ProtocolVersion = 196608 // 3.0
+	SSLRequestCode  = 80877103
+
+	AuthOK                = 0
+	AuthCleartextPassword = 3
+	AuthMD5Password       = 5
+	AuthSASL              = 10
+	AuthSASLContinue      = 11
+	AuthSASLFinal         = 12
+
+	scramSHA256    = "SCRAM-SHA-256"
+	maxMessageSize = 1 << 30
+)
+
+// The SSL modes, like the sslmode of libpq, except that prefer and require don't verify the
+// certificate of the server.
+const (
+	SSLModeDisable    = "disable"
+	SSLModePrefer     = "prefer"
+	SSLModeRequire    = "require"
+	SSLModeVerifyFull = "verify-full"
+)
+
+// ErrInvalidMessage is returned for the messages of the server that can't be decoded.
+var ErrInvalidMessage = errors.New("invalid Postgres message")
+
+// Error is an ErrorResponse of the server.
+type Error struct {
+	Severity, Code, Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s (SQLSTATE %s)", e.Severity, e.Message, e.Code)
+}
+
+// ParseError returns the Error of the body of an ErrorResponse message.
+func ParseError(body []byte) *Error {
+	e := &Error{}
+	for _, field := range bytes.Split(body, []byte{0}) {
+		if len(field) == 0 {
+			continue
+		}
+		switch field[0] {
+		case 'S':
+			e.Severity = string(field[1:])
+		case 'C':
+			e.Code = string(field[1:])
+		case 'M':
+			e.Message = string(field[1:])
+		}
+	}
+	return e
+}
+
+// Config is the config of the session of a Conn.
+type Config struct {
+	User     string
+	Password string
+	Database string
+	// ApplicationName is sent as the application_name parameter, if it isn't empty
+	ApplicationName string
+	// SSLMode is one of the SSL modes, the connection is upgraded with StartTLS unless it's disabled
+	SSLMode string
+	// StartTLS starts a TLS session over the connection, which verifies the certificate of the server
+	// if verify is set.
+	StartTLS func(nc net.Conn, verify bool) (net.Conn, error)
+}
+
+// Conn is a connection to a server, after the startup of its session.
+type Conn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// Connect starts a session over the connection, over TLS depending on the SSL mode, and authenticates
+// its user with the password, md5 or SCRAM-SHA-256. The deadlines of the connection are left to the
+// caller.
+func Connect(nc net.Conn, cfg Config) (*Conn, error) {
+	if cfg.SSLMode != SSLModeDisable && cfg.SSLMode != "" {
+		var err error
+		if nc, err = sslRequest(nc, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	startup := appendInt32(nil, ProtocolVersion)
+	for _, param := range [][2]string{
+		{"user", cfg.User}, {"database", cfg.Database}, {"client_encoding", "UTF8"},
+		{"application_name", cfg.ApplicationName},
+	} {
+		if param[1] != "" {
+			startup = append(append(append(append(startup, param[0]...), 0), param[1]...), 0)
+		}
+	}
+	if _, err := nc.Write(AppendMessage(nil, 0, append(startup, 0))); err != nil {
+		return nil, err
+	}
+	c := &Conn{Conn: nc, r: bufio.NewReader(nc)}
+	if err := c.authenticate(cfg.User, cfg.Password); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func sslRequest(nc net.Conn, cfg Config) (net.Conn, error) {
+	if _, err := nc.Write(AppendMessage(nil, 0, appendInt32(nil, SSLRequestCode))); err != nil {
+		return nil, err
+	}
+	var resp [1]byte
+	if _, err := io.ReadFull(nc, resp[:]); err != nil {
+		return nil, err
+	}
+	switch {
+	case resp[0] == 'S':
+		return cfg.StartTLS(nc, cfg.SSLMode == SSLModeVerifyFull)
+	case resp[0] == 'N' && cfg.SSLMode == SSLModePrefer:
+		return nc, nil
+	case resp[0] == 'N':
+		return nil, errors.New("the Postgres server doesn't support TLS")
+	default:
+		return nil, ErrInvalidMessage
+	}
+}
+
+// authenticate answers the authentication requests of the server until it's ready for queries.
+func (c *Conn) authenticate(user, password string) error {
+	var client *scram.Client
+	for {
+		typ, body, err := c.ReadMessage()
+		if err != nil {
+			return err
+		}
+		switch typ {
+		case 'E':
+			return ParseError(body)
+		case 'Z':
+			return nil
+		case 'R':
+		default:
+			// ParameterStatus, BackendKeyData and NoticeResponse
+			continue
+		}
+		if len(body) < 4 {
+			return ErrInvalidMessage
+		}
+		var resp []byte
+		switch code, data := binary.BigEndian.Uint32(body), body[4:]; code {
+		case AuthOK:
+			continue
+		case AuthCleartextPassword:
+			resp = append([]byte(password), 0)
+		case AuthMD5Password:
+			if len(data) != 4 {
+				return ErrInvalidMessage
+			}
+			resp = append([]byte(MD5Password(user, password, data)), 0)
+		case AuthSASL:
+			if !strings.Contains("\x00"+string(data), "\x00"+scramSHA256+"\x00") {
+				return fmt.Errorf("the SASL mechanisms of the server aren't supported, only %s is", scramSHA256)
+			}
+			// the server uses the user of the startup message
+			if client, err = scram.NewClient(sha256.New, "", password); err != nil {
+				return err
+			}
+			first := client.ClientFirst()
+			resp = appendInt32(append([]byte(scramSHA256), 0), int32(len(first)))
+			resp = append(resp, first...)
+		case AuthSASLContinue:
+			if client == nil {
+				return ErrInvalidMessage
+			}
+			if resp, err = client.ClientFinal(data); err != nil {
+				return err
+			}
+		case AuthSASLFinal:
+			if client == nil {
+				return ErrInvalidMessage
+			}
+			if err = client.Verify(data); err != nil {
+				return err
+			}
+			continue
+		default:
+			return fmt.Errorf("the Postgres authentication method %d is not supported", code)
+		}
+		if _, err := c.Write(AppendMessage(nil, 'p', resp)); err != nil {
+			return err
+		}
+	}
+}
+
+// Exec runs a query with the simple query protocol, and returns the first error of the server.
+// If the query is a COPY FROM STDIN, copyData is sent as its data.
+func (c *Conn) Exec(query string, copyData []byte) error {
+	if _, err := c.Write(AppendMessage(nil, 'Q', append([]byte(query), 0))); err != nil {
+		return err
+	}
+	var queryErr error
+	for {
+		typ, body, err := c.ReadMessage()
+		if err != nil {
+			return err
+		}
+		switch typ {
+		case 'G':
+			if _, err := c.Write(AppendMessage(AppendMessage(nil, 'd', copyData), 'c', nil)); err != nil {
+				return err
+			}
+		case 'E':
+			if queryErr == nil {
+				queryErr = ParseError(body)
+			}
+		case 'Z':
+			return queryErr
+		}
+	}
+}
+
+// ReadMessage returns the type and the body of the next message of the server.
+func (c *Conn) ReadMessage() (byte, []byte, error) {
+	return ReadMessage(c.r)
+}
+
+// Close terminates the session and closes the connection.
+func (c *Conn) Close() error {
+	_, _ = c.Write(AppendMessage(nil, 'X', nil))
+	return c.Conn.Close()
+}
+
+// ReadMessage returns the type and the body of the next message with a type.
+func ReadMessage(r io.Reader) (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	length := binary.BigEndian.Uint32(header[1:])
+	if length < 4 || length > maxMessageSize {
+		return 0, nil, ErrInvalidMessage
+	}
+	body := make([]byte, length-4)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header[0], body, nil
+}
+
+// AppendMessage appends a message to b, the startup messages have the 0 type, since they don't have one.
+func AppendMessage(b []byte, typ byte, body []byte) []byte {
+	if typ != 0 {
+		b = append(b, typ)
+	}
+	return append(appendInt32(b, int32(len(body)+4)), body...)
+}
+
+// MD5Password returns the response to the md5 authentication request of the server, without the
+// terminating 0.
+func MD5Password(user, password string, salt []byte) string {
+	inner := md5.Sum([]byte(password + user))                               //nolint:gosec
+	outer := md5.Sum(append([]byte(hex.EncodeToString(inner[:])), salt...)) //nolint:gosec
+	return "md5" + hex.EncodeToString(outer[:])
+}
+
+func appendInt32(b []byte, v int32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
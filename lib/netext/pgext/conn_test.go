@@ -0,0 +1,143 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package pgext
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/lib/netext/scram"
+)
+
+// serve is a server of the k6 user with the secret password, which authenticates with the auth method,
+// and fails the queries with "fail" in them.
+func serve(t *testing.T, nc net.Conn, auth uint32) {
+	defer func() { _ = nc.Close() }()
+	r := bufio.NewReader(nc)
+	readStartup := func() []byte {
+		var size [4]byte
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			return nil
+		}
+		startup := make([]byte, binary.BigEndian.Uint32(size[:])-4)
+		if _, err := io.ReadFull(r, startup); err != nil {
+			return nil
+		}
+		return startup
+	}
+	startup := readStartup()
+	if binary.BigEndian.Uint32(startup) == SSLRequestCode {
+		_, _ = nc.Write([]byte("N"))
+		if startup = readStartup(); startup == nil {
+			return
+		}
+	}
+	assert.Equal(t, "user\x00k6\x00database\x00db\x00client_encoding\x00UTF8\x00\x00", string(startup[4:]))
+
+	authenticated := false
+	switch auth {
+	case AuthCleartextPassword:
+		_, _ = nc.Write(AppendMessage(nil, 'R', appendInt32(nil, AuthCleartextPassword)))
+		_, body, _ := ReadMessage(r)
+		authenticated = string(body) == "secret\x00"
+	case AuthMD5Password:
+		_, _ = nc.Write(AppendMessage(nil, 'R', append(appendInt32(nil, AuthMD5Password), 1, 2, 3, 4)))
+		_, body, _ := ReadMessage(r)
+		authenticated = string(body) == MD5Password("k6", "secret", []byte{1, 2, 3, 4})+"\x00"
+	case AuthSASL:
+		_, _ = nc.Write(AppendMessage(nil, 'R', append(appendInt32(nil, AuthSASL), "SCRAM-SHA-256\x00\x00"...)))
+		_, body, _ := ReadMessage(r)
+		server := &scram.TestServer{H: sha256.New, Password: "secret"}
+		serverFirst, ok := server.Step(body[len("SCRAM-SHA-256\x00")+4:])
+		require.True(t, ok)
+		_, _ = nc.Write(AppendMessage(nil, 'R', append(appendInt32(nil, AuthSASLContinue), serverFirst...)))
+		_, body, _ = ReadMessage(r)
+		var serverFinal []byte
+		if serverFinal, authenticated = server.Step(body); authenticated {
+			_, _ = nc.Write(AppendMessage(nil, 'R', append(appendInt32(nil, AuthSASLFinal), serverFinal...)))
+		}
+	}
+	if !authenticated {
+		_, _ = nc.Write(AppendMessage(nil, 'E', []byte("SFATAL\x00C28P01\x00Mpassword authentication failed\x00\x00")))
+		return
+	}
+	msg := AppendMessage(nil, 'R', appendInt32(nil, AuthOK))
+	msg = AppendMessage(msg, 'S', []byte("server_version\x0014\x00"))
+	_, _ = nc.Write(AppendMessage(msg, 'Z', []byte("I")))
+
+	for {
+		typ, body, err := ReadMessage(r)
+		if err != nil || typ != 'Q' {
+			return
+		}
+		query := strings.TrimSuffix(string(body), "\x00")
+		var msg []byte
+		if strings.HasPrefix(query, "COPY") {
+			_, _ = nc.Write(AppendMessage(nil, 'G', []byte{0, 0, 0}))
+			typ, body, _ = ReadMessage(r)
+			assert.Equal(t, byte('d'), typ)
+			assert.Equal(t, "data", string(body))
+			typ, _, _ = ReadMessage(r)
+			assert.Equal(t, byte('c'), typ)
+		}
+		if strings.Contains(query, "fail") {
+			msg = AppendMessage(msg, 'E', []byte("SERROR\x00C42501\x00Mpermission denied\x00\x00"))
+		} else {
+			msg = AppendMessage(msg, 'C', []byte("OK\x00"))
+		}
+		_, _ = nc.Write(AppendMessage(msg, 'Z', []byte("I")))
+	}
+}
+
+func TestConnect(t *testing.T) {
+	t.Parallel()
+	for _, auth := range []uint32{AuthCleartextPassword, AuthMD5Password, AuthSASL} {
+		for _, password := range []string{"secret", "wrong"} {
+			client, server := net.Pipe()
+			go serve(t, server, auth)
+			c, err := Connect(client, Config{User: "k6", Password: password, Database: "db", SSLMode: SSLModePrefer})
+			if password != "secret" {
+				require.EqualError(t, err, "FATAL: password authentication failed (SQLSTATE 28P01)")
+				continue
+			}
+			require.NoError(t, err)
+			require.NoError(t, c.Exec("SELECT 1", nil))
+			require.NoError(t, c.Exec("COPY t FROM STDIN", []byte("data")))
+			require.EqualError(t, c.Exec("COPY fail FROM STDIN", []byte("data")),
+				"ERROR: permission denied (SQLSTATE 42501)")
+			require.NoError(t, c.Exec("SELECT 2", nil))
+			require.NoError(t, c.Close())
+		}
+	}
+
+	client, server := net.Pipe()
+	go serve(t, server, AuthOK)
+	_, err := Connect(client, Config{User: "k6", Database: "db", SSLMode: SSLModeRequire})
+	require.EqualError(t, err, "the Postgres server doesn't support TLS")
+}
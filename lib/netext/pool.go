@@ -0,0 +1,165 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netext
+
+import (
+	"crypto/tls"
+	"net"
+	"sync"
+)
+
+// ConnPoolStats are the stats of the connections of a Dialer to a single address.
+type ConnPoolStats struct {
+	// Open is the number of connections that haven't been closed yet
+	Open int64
+	// Idle is the number of open connections that don't have any request in flight
+	Idle int64
+	// InFlight is the number of requests that are being sent or whose response is being read
+	InFlight int64
+}
+
+// connPool keeps track of the connections to a single address, the requests are tracked
+// by the HTTP transport, as it's the only one that knows when they start and end.
+type connPool struct {
+	mu    sync.Mutex
+	stats ConnPoolStats
+}
+
+// getPool returns the pool of connections to the given address, creating it if necessary.
+func (d *Dialer) getPool(addr string) *connPool {
+	d.poolsMu.Lock()
+	defer d.poolsMu.Unlock()
+	if d.pools == nil {
+		d.pools = make(map[string]*connPool)
+	}
+	pool, ok := d.pools[addr]
+	if !ok {
+		pool = &connPool{}
+		d.pools[addr] = pool
+	}
+	return pool
+}
+
+// PoolStats returns the stats of the connections to the given address, as it was passed to DialContext.
+func (d *Dialer) PoolStats(addr string) ConnPoolStats {
+	d.poolsMu.Lock()
+	pool, ok := d.pools[addr]
+	d.poolsMu.Unlock()
+	if !ok {
+		return ConnPoolStats{}
+	}
+	return pool.get()
+}
+
+func (p *connPool) get() ConnPoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stats
+}
+
+func (p *connPool) opened() {
+	p.mu.Lock()
+	p.stats.Open++
+	p.stats.Idle++
+	p.mu.Unlock()
+}
+
+// RequestStarted marks the start of a request sent over the connection. If maxRequests isn't 0 and
+// this is the last request allowed over the connection, the connection is retired: it's closed
+// once all its requests have finished, so that it isn't reused, whatever the protocol is.
+// It returns false if the connection was already closed, before the transport noticed it.
+func (c *Conn) RequestStarted(maxRequests int64) bool {
+	if c.pool == nil {
+		return true
+	}
+	c.pool.mu.Lock()
+	defer c.pool.mu.Unlock()
+	c.requests++
+	if maxRequests > 0 && c.requests >= maxRequests {
+		c.retired = true
+	}
+	if !c.closed {
+		if c.inFlight == 0 {
+			c.pool.stats.Idle--
+		}
+		c.inFlight++
+		c.pool.stats.InFlight++
+	}
+	return !c.closed
+}
+
+// RequestFinished marks the end of a request started with RequestStarted, once its response has been read.
+func (c *Conn) RequestFinished() {
+	if c.pool == nil {
+		return
+	}
+	c.pool.mu.Lock()
+	if c.closed || c.inFlight == 0 {
+		c.pool.mu.Unlock()
+		return
+	}
+	c.inFlight--
+	c.pool.stats.InFlight--
+	if c.inFlight == 0 {
+		c.pool.stats.Idle++
+	}
+	retire := c.retired && c.inFlight == 0
+	c.pool.mu.Unlock()
+
+	if retire {
+		_ = c.Close()
+	}
+}
+
+// PoolStats returns the stats of the connections to the same address as this one.
+func (c *Conn) PoolStats() ConnPoolStats {
+	if c.pool == nil {
+		return ConnPoolStats{}
+	}
+	return c.pool.get()
+}
+
+// Close closes the connection, the requests that are still in flight over it aren't counted anymore.
+func (c *Conn) Close() error {
+	if c.pool != nil {
+		c.pool.mu.Lock()
+		if !c.closed {
+			c.closed = true
+			c.pool.stats.Open--
+			if c.inFlight == 0 {
+				c.pool.stats.Idle--
+			}
+			c.pool.stats.InFlight -= c.inFlight
+			c.inFlight = 0
+		}
+		c.pool.mu.Unlock()
+	}
+	return c.Conn.Close()
+}
+
+// UnwrapConn returns the Conn of a connection made by a Dialer, even if it's wrapped in a TLS connection.
+func UnwrapConn(conn net.Conn) (*Conn, bool) {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+	c, ok := conn.(*Conn)
+	return c, ok
+}
@@ -0,0 +1,142 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package scram implements the client side of the SCRAM authentication mechanisms, see
+// https://datatracker.ietf.org/doc/html/rfc5802, which the network protocols of k6 use.
+package scram
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+)
+
+// Client is the client side of a single SCRAM exchange.
+type Client struct {
+	h               func() hash.Hash
+	password        string
+	clientNonce     string
+	clientFirstBare string
+	saltedPassword  []byte
+	authMessage     string
+}
+
+// NewClient returns a new Client of a user, with the hash of the mechanism, e.g. sha256.New
+// for SCRAM-SHA-256. The user can be empty if the protocol sends it separately.
+func NewClient(h func() hash.Hash, username, password string) (*Client, error) {
+	nonce := make([]byte, 18)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return newClient(h, username, password, base64.StdEncoding.EncodeToString(nonce)), nil
+}
+
+func newClient(h func() hash.Hash, username, password, nonce string) *Client {
+	username = strings.NewReplacer("=", "=3D", ",", "=2C").Replace(username)
+	return &Client{h: h, password: password, clientNonce: nonce, clientFirstBare: "n=" + username + ",r=" + nonce}
+}
+
+// ClientFirst returns the client-first-message, without channel binding.
+func (c *Client) ClientFirst() []byte {
+	return []byte("n,," + c.clientFirstBare)
+}
+
+// ClientFinal returns the client-final-message, with the proof for the server-first-message.
+func (c *Client) ClientFinal(serverFirst []byte) ([]byte, error) {
+	attrs := Attributes(string(serverFirst))
+	salt, err := base64.StdEncoding.DecodeString(attrs["s"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid SCRAM salt: %w", err)
+	}
+	iterations, err := strconv.Atoi(attrs["i"])
+	if err != nil || iterations < 1 {
+		return nil, fmt.Errorf("invalid SCRAM iteration count %q", attrs["i"])
+	}
+	if !strings.HasPrefix(attrs["r"], c.clientNonce) {
+		return nil, errors.New("the SCRAM nonce of the server doesn't start with the client nonce")
+	}
+
+	clientFinalBare := "c=biws,r=" + attrs["r"]
+	c.authMessage = c.clientFirstBare + "," + string(serverFirst) + "," + clientFinalBare
+	c.saltedPassword = SaltedPassword(c.h, []byte(c.password), salt, iterations)
+	clientKey := HMAC(c.h, c.saltedPassword, []byte("Client Key"))
+	storedKey := c.h()
+	storedKey.Write(clientKey)
+	clientSignature := HMAC(c.h, storedKey.Sum(nil), []byte(c.authMessage))
+	proof := make([]byte, len(clientKey))
+	for i := range proof {
+		proof[i] = clientKey[i] ^ clientSignature[i]
+	}
+	return []byte(clientFinalBare + ",p=" + base64.StdEncoding.EncodeToString(proof)), nil
+}
+
+// Verify checks the signature of the server-final-message, or returns its error.
+func (c *Client) Verify(serverFinal []byte) error {
+	attrs := Attributes(string(serverFinal))
+	if attrs["e"] != "" {
+		return fmt.Errorf("SCRAM error: %s", attrs["e"])
+	}
+	if c.saltedPassword == nil {
+		return errors.New("unexpected SCRAM server-final-message")
+	}
+	serverKey := HMAC(c.h, c.saltedPassword, []byte("Server Key"))
+	serverSignature := base64.StdEncoding.EncodeToString(HMAC(c.h, serverKey, []byte(c.authMessage)))
+	if !hmac.Equal([]byte(attrs["v"]), []byte(serverSignature)) {
+		return errors.New("the SCRAM signature of the server is invalid")
+	}
+	return nil
+}
+
+// Attributes returns the attributes of a SCRAM message by their names.
+func Attributes(msg string) map[string]string {
+	attrs := make(map[string]string)
+	for _, attr := range strings.Split(msg, ",") {
+		if len(attr) > 2 && attr[1] == '=' {
+			attrs[attr[:1]] = attr[2:]
+		}
+	}
+	return attrs
+}
+
+// HMAC returns the HMAC of the data with the hash.
+func HMAC(h func() hash.Hash, key, data []byte) []byte {
+	mac := hmac.New(h, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// SaltedPassword is the Hi function of SCRAM, which is PBKDF2 with HMAC and a single block, as long as
+// the hash.
+func SaltedPassword(h func() hash.Hash, password, salt []byte, iterations int) []byte {
+	u := HMAC(h, password, append(append([]byte{}, salt...), 0, 0, 0, 1))
+	result := append([]byte{}, u...)
+	for i := 1; i < iterations; i++ {
+		u = HMAC(h, password, u)
+		for j := range result {
+			result[j] ^= u[j]
+		}
+	}
+	return result
+}
@@ -0,0 +1,77 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package scram
+
+import (
+	"crypto/sha1" //nolint:gosec
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient(t *testing.T) {
+	t.Parallel()
+	// the examples of https://datatracker.ietf.org/doc/html/rfc5802#section-5 and
+	// https://datatracker.ietf.org/doc/html/rfc7677#section-3
+	c := newClient(sha1.New, "user", "pencil", "fyko+d2lbbFgONRv9qkxdawL")
+	assert.Equal(t, "n,,n=user,r=fyko+d2lbbFgONRv9qkxdawL", string(c.ClientFirst()))
+	final, err := c.ClientFinal([]byte("r=fyko+d2lbbFgONRv9qkxdawL3rfcNHYJY1ZVvWVs7j,s=QSXCR+Q6sek8bf92,i=4096"))
+	require.NoError(t, err)
+	assert.Equal(t, "c=biws,r=fyko+d2lbbFgONRv9qkxdawL3rfcNHYJY1ZVvWVs7j,p=v0X8v3Bz2T0CJGbJQyF0X+HI4Ts=", string(final))
+	require.NoError(t, c.Verify([]byte("v=rmF9pqV8S7suAoZWja4dJRkFsKQ=")))
+
+	c = newClient(sha256.New, "user", "pencil", "rOprNGfwEbeRWgbNEkqO")
+	assert.Equal(t, "n,,n=user,r=rOprNGfwEbeRWgbNEkqO", string(c.ClientFirst()))
+	final, err = c.ClientFinal(
+		[]byte("r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"))
+	require.NoError(t, err)
+	assert.Equal(t, "c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,"+
+		"p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ=", string(final))
+	require.NoError(t, c.Verify([]byte("v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=")))
+	require.EqualError(t, c.Verify([]byte("v=AAAA")), "the SCRAM signature of the server is invalid")
+	require.EqualError(t, c.Verify([]byte("e=invalid-proof")), "SCRAM error: invalid-proof")
+
+	_, err = c.ClientFinal([]byte("r=another,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"))
+	require.EqualError(t, err, "the SCRAM nonce of the server doesn't start with the client nonce")
+	_, err = c.ClientFinal([]byte("r=rOprNGfwEbeRWgbNEkqOserver,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=0"))
+	require.EqualError(t, err, `invalid SCRAM iteration count "0"`)
+}
+
+func TestTestServer(t *testing.T) {
+	t.Parallel()
+	for _, password := range []string{"secret", "wrong"} {
+		c, err := NewClient(sha256.New, "k6,=", password)
+		require.NoError(t, err)
+		s := &TestServer{H: sha256.New, Username: "k6=2C=3D", Password: "secret"}
+		serverFirst, ok := s.Step(c.ClientFirst())
+		require.True(t, ok)
+		final, err := c.ClientFinal(serverFirst)
+		require.NoError(t, err)
+		serverFinal, ok := s.Step(final)
+		require.Equal(t, password == "secret", ok)
+		require.Equal(t, password == "secret", s.Done)
+		if ok {
+			require.NoError(t, c.Verify(serverFinal))
+		}
+	}
+}
@@ -0,0 +1,82 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package scram
+
+import (
+	"encoding/base64"
+	"hash"
+	"strings"
+)
+
+// TestServer is the server side of a SCRAM exchange for the tests, with the "salt" salt and 4096
+// iterations. It doesn't check the user if its Username is empty.
+type TestServer struct {
+	H        func() hash.Hash
+	Username string
+	Password string
+	// Done is set after the proof of the client was verified
+	Done bool
+
+	clientFirstBare string
+	serverFirst     string
+}
+
+// Step returns the reply to a message of the client, or false if the client isn't authenticated.
+func (s *TestServer) Step(msg []byte) ([]byte, bool) {
+	salt := []byte("salt")
+	saltedPassword := SaltedPassword(s.H, []byte(s.Password), salt, 4096)
+	if s.serverFirst == "" {
+		s.clientFirstBare = strings.TrimPrefix(string(msg), "n,,")
+		attrs := Attributes(s.clientFirstBare)
+		if s.Username != "" && attrs["n"] != s.Username {
+			return nil, false
+		}
+		s.serverFirst = "r=" + attrs["r"] + "server,s=" + base64.StdEncoding.EncodeToString(salt) + ",i=4096"
+		return []byte(s.serverFirst), true
+	}
+
+	i := strings.LastIndex(string(msg), ",p=")
+	if i < 0 {
+		return nil, false
+	}
+	proof, err := base64.StdEncoding.DecodeString(string(msg[i+3:]))
+	if err != nil {
+		return nil, false
+	}
+	authMessage := s.clientFirstBare + "," + s.serverFirst + "," + string(msg[:i])
+	storedKey := s.H()
+	storedKey.Write(HMAC(s.H, saltedPassword, []byte("Client Key")))
+	clientSignature := HMAC(s.H, storedKey.Sum(nil), []byte(authMessage))
+	if len(proof) != len(clientSignature) {
+		return nil, false
+	}
+	for j := range proof {
+		proof[j] ^= clientSignature[j]
+	}
+	proofKey := s.H()
+	proofKey.Write(proof)
+	if string(proofKey.Sum(nil)) != string(storedKey.Sum(nil)) {
+		return nil, false
+	}
+	s.Done = true
+	serverKey := HMAC(s.H, saltedPassword, []byte("Server Key"))
+	return []byte("v=" + base64.StdEncoding.EncodeToString(HMAC(s.H, serverKey, []byte(authMessage)))), true
+}
@@ -25,8 +25,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"net"
+	"net/url"
 	"reflect"
+	"sort"
 	"strconv"
+	"strings"
 
 	"go.k6.io/k6/lib/types"
 	"go.k6.io/k6/stats"
@@ -38,6 +41,13 @@ import (
 // iterations+vus, or stages)
 const DefaultScenarioName = "default"
 
+// The actions for the values of a tag of a metric above the TagCardinalityLimit
+const (
+	TagCardinalityWarn = "warn"
+	TagCardinalityDrop = "drop"
+	TagCardinalityHash = "hash"
+)
+
 // DefaultSummaryTrendStats are the default trend columns shown in the test summary output
 // nolint: gochecknoglobals
 var DefaultSummaryTrendStats = []string{"avg", "min", "med", "max", "p(90)", "p(95)"}
@@ -318,6 +328,13 @@ type Options struct {
 	Batch        null.Int `json:"batch" envconfig:"K6_BATCH"`
 	BatchPerHost null.Int `json:"batchPerHost" envconfig:"K6_BATCH_PER_HOST"`
 
+	// Limits of the HTTP connection pool of every VU: the connections per host, the idle connections
+	// kept open in total (the batch limit if not set) and the requests sent over a connection before
+	// it's closed, all of them are unlimited if 0.
+	MaxConnsPerHost    null.Int `json:"maxConnsPerHost" envconfig:"K6_MAX_CONNS_PER_HOST"`
+	MaxIdleConns       null.Int `json:"maxIdleConns" envconfig:"K6_MAX_IDLE_CONNS"`
+	MaxRequestsPerConn null.Int `json:"maxRequestsPerConn" envconfig:"K6_MAX_REQUESTS_PER_CONN"`
+
 	// Should all HTTP requests and responses be logged (excluding body)?
 	HTTPDebug null.String `json:"httpDebug" envconfig:"K6_HTTP_DEBUG"`
 
@@ -337,12 +354,23 @@ type Options struct {
 	// metric on a nonexistent metric named 'real_metric{tagA:valueA,tagB:valueB}'.
 	Thresholds map[string]stats.Thresholds `json:"thresholds" envconfig:"K6_THRESHOLDS"`
 
+	// Evaluate the thresholds separately for every scenario, on their submetrics with its scenario tag,
+	// instead of on all the samples.
+	ThresholdsPerScenario null.Bool `json:"thresholdsPerScenario" envconfig:"K6_THRESHOLDS_PER_SCENARIO"`
+
+	// URL to which the thresholds which start failing during the test run are posted as they do, in JSON.
+	ThresholdsWebhook null.String `json:"thresholdsWebhook" envconfig:"K6_THRESHOLDS_WEBHOOK"`
+
 	// Blacklist IP ranges that tests may not contact. Mainly useful in hosted setups.
 	BlacklistIPs []*IPNet `json:"blacklistIPs" envconfig:"K6_BLACKLIST_IPS"`
 
 	// Block hostname patterns that tests may not contact.
 	BlockedHostnames types.NullHostnameTrie `json:"blockHostnames" envconfig:"K6_BLOCK_HOSTNAMES"`
 
+	// Paths, or path.Match patterns of them, of the unix sockets that tests may contact, all of them
+	// if it isn't set. They are also blocked along with localhost and the loopback IPs.
+	UnixSockets []string `json:"unixSockets" envconfig:"K6_UNIX_SOCKETS"`
+
 	// Hosts overrides dns entries for given hosts
 	Hosts map[string]*HostAddress `json:"hosts" envconfig:"K6_HOSTS"`
 
@@ -362,12 +390,30 @@ type Options struct {
 	// Can't be set through env vars.
 	External map[string]json.RawMessage `json:"ext" ignored:"true"`
 
+	// Groups of the URLs of the HTTP requests under the same name tag, when it isn't set by the requests.
+	// Can't be set through env vars.
+	URLGroups types.URLGroups `json:"urlGroups" ignored:"true"`
+
 	// Summary trend stats for trend metrics (response times) in CLI output
 	SummaryTrendStats []string `json:"summaryTrendStats" envconfig:"K6_SUMMARY_TREND_STATS"`
 
+	// Summary trend stats of specific trend metrics, instead of the SummaryTrendStats.
+	// Can't be set through env vars.
+	SummaryTrendStatsPerMetric map[string][]string `json:"summaryTrendStatsPerMetric" ignored:"true"`
+
 	// Summary time unit for summary metrics (response times) in CLI output
 	SummaryTimeUnit null.String `json:"summaryTimeUnit" envconfig:"K6_SUMMARY_TIME_UNIT"`
 
+	// Relative error of the percentiles of the trend metrics, which keep all their values when it's 0
+	TrendRelativeError null.Float `json:"trendRelativeError" envconfig:"K6_TREND_RELATIVE_ERROR"`
+
+	// Unique values of a tag of a metric above which the TagCardinalityAction is taken; 0 disables it
+	TagCardinalityLimit null.Int `json:"tagCardinalityLimit" envconfig:"K6_TAG_CARDINALITY_LIMIT"`
+
+	// What is done with the values above the TagCardinalityLimit, besides warning about them:
+	// nothing (warn), removing the tag (drop) or replacing them by one of as many hashes as the limit (hash)
+	TagCardinalityAction null.String `json:"tagCardinalityAction" envconfig:"K6_TAG_CARDINALITY_ACTION"`
+
 	// Which system tags to include with metrics ("method", "vu" etc.)
 	// Use pointer for identifying whether user provide any tag or not.
 	SystemTags *stats.SystemTagSet `json:"systemTags" envconfig:"K6_SYSTEM_TAGS"`
@@ -381,6 +427,9 @@ type Options struct {
 	// Do not reset cookies after a VU iteration
 	NoCookiesReset null.Bool `json:"noCookiesReset" envconfig:"K6_NO_COOKIES_RESET"`
 
+	// Use the same cookie jar in all VUs, including the one running setup(), for the whole test
+	SharedCookieJar null.Bool `json:"sharedCookieJar" envconfig:"K6_SHARED_COOKIE_JAR"`
+
 	// Discard Http Responses Body
 	DiscardResponseBodies null.Bool `json:"discardResponseBodies" envconfig:"K6_DISCARD_RESPONSE_BODIES"`
 
@@ -474,6 +523,15 @@ func (o Options) Apply(opts Options) Options {
 	if opts.BatchPerHost.Valid {
 		o.BatchPerHost = opts.BatchPerHost
 	}
+	if opts.MaxConnsPerHost.Valid {
+		o.MaxConnsPerHost = opts.MaxConnsPerHost
+	}
+	if opts.MaxIdleConns.Valid {
+		o.MaxIdleConns = opts.MaxIdleConns
+	}
+	if opts.MaxRequestsPerConn.Valid {
+		o.MaxRequestsPerConn = opts.MaxRequestsPerConn
+	}
 	if opts.HTTPDebug.Valid {
 		o.HTTPDebug = opts.HTTPDebug
 	}
@@ -495,12 +553,21 @@ func (o Options) Apply(opts Options) Options {
 	if opts.Thresholds != nil {
 		o.Thresholds = opts.Thresholds
 	}
+	if opts.ThresholdsPerScenario.Valid {
+		o.ThresholdsPerScenario = opts.ThresholdsPerScenario
+	}
+	if opts.ThresholdsWebhook.Valid {
+		o.ThresholdsWebhook = opts.ThresholdsWebhook
+	}
 	if opts.BlacklistIPs != nil {
 		o.BlacklistIPs = opts.BlacklistIPs
 	}
 	if opts.BlockedHostnames.Valid {
 		o.BlockedHostnames = opts.BlockedHostnames
 	}
+	if opts.UnixSockets != nil {
+		o.UnixSockets = opts.UnixSockets
+	}
 	if opts.Hosts != nil {
 		o.Hosts = opts.Hosts
 	}
@@ -516,15 +583,33 @@ func (o Options) Apply(opts Options) Options {
 	if opts.NoCookiesReset.Valid {
 		o.NoCookiesReset = opts.NoCookiesReset
 	}
+	if opts.SharedCookieJar.Valid {
+		o.SharedCookieJar = opts.SharedCookieJar
+	}
 	if opts.External != nil {
 		o.External = opts.External
 	}
+	if opts.URLGroups != nil {
+		o.URLGroups = opts.URLGroups
+	}
 	if opts.SummaryTrendStats != nil {
 		o.SummaryTrendStats = opts.SummaryTrendStats
 	}
+	if opts.SummaryTrendStatsPerMetric != nil {
+		o.SummaryTrendStatsPerMetric = opts.SummaryTrendStatsPerMetric
+	}
 	if opts.SummaryTimeUnit.Valid {
 		o.SummaryTimeUnit = opts.SummaryTimeUnit
 	}
+	if opts.TrendRelativeError.Valid {
+		o.TrendRelativeError = opts.TrendRelativeError
+	}
+	if opts.TagCardinalityLimit.Valid {
+		o.TagCardinalityLimit = opts.TagCardinalityLimit
+	}
+	if opts.TagCardinalityAction.Valid {
+		o.TagCardinalityAction = opts.TagCardinalityAction
+	}
 	if opts.SystemTags != nil {
 		o.SystemTags = opts.SystemTags
 	}
@@ -575,9 +660,58 @@ func (o Options) Validate() []error {
 					o.ExecutionSegment, o.ExecutionSegmentSequence))
 		}
 	}
+	if o.TrendRelativeError.Valid && (o.TrendRelativeError.Float64 < 0 || o.TrendRelativeError.Float64 >= 1) {
+		errors = append(errors, fmt.Errorf("the trend relative error should be between 0 and 1, but it's %g",
+			o.TrendRelativeError.Float64))
+	}
+	if o.TagCardinalityLimit.Int64 < 0 {
+		errors = append(errors, fmt.Errorf("the tag cardinality limit can't be negative, but it's %d",
+			o.TagCardinalityLimit.Int64))
+	}
+	switch o.TagCardinalityAction.String {
+	case "", TagCardinalityWarn, TagCardinalityDrop, TagCardinalityHash:
+	default:
+		errors = append(errors, fmt.Errorf("unsupported tag cardinality action '%s', supported ones are "+
+			"'%s', '%s' and '%s'", o.TagCardinalityAction.String,
+			TagCardinalityWarn, TagCardinalityDrop, TagCardinalityHash))
+	}
+	if o.ThresholdsPerScenario.Bool && o.SystemTags != nil && !o.SystemTags.Has(stats.TagScenario) {
+		errors = append(errors, fmt.Errorf("the thresholds can't be evaluated per scenario without the %s "+
+			"system tag", stats.TagScenario))
+	}
+	if o.ThresholdsWebhook.String != "" {
+		if u, err := url.Parse(o.ThresholdsWebhook.String); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			errors = append(errors, fmt.Errorf("the thresholds webhook needs to be an http or https URL, not '%s'",
+				o.ThresholdsWebhook.String))
+		}
+	}
+	names := make([]string, 0, len(o.Thresholds))
+	for name := range o.Thresholds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, _, err := stats.ParseSubmetric(name); err != nil {
+			errors = append(errors, err)
+		}
+	}
 	return append(errors, o.Scenarios.Validate()...)
 }
 
+// GetSummaryTrendStats returns the summary trend stats of the metric, which are the ones of its parent
+// for a submetric without its own, and the SummaryTrendStats for a metric without any.
+func (o Options) GetSummaryTrendStats(metricName string) []string {
+	if trendStats, ok := o.SummaryTrendStatsPerMetric[metricName]; ok {
+		return trendStats
+	}
+	if i := strings.IndexByte(metricName, '{'); i >= 0 {
+		if trendStats, ok := o.SummaryTrendStatsPerMetric[metricName[:i]]; ok {
+			return trendStats
+		}
+	}
+	return o.SummaryTrendStats
+}
+
 // ForEachSpecified enumerates all struct fields and calls the supplied function with each
 // element that is valid. It panics for any unfamiliar or unexpected fields, so make sure
 // new fields in Options are accounted for.
@@ -108,6 +108,16 @@ func TestOptions(t *testing.T) {
 		assert.True(t, opts.BatchPerHost.Valid)
 		assert.Equal(t, int64(12345), opts.BatchPerHost.Int64)
 	})
+	t.Run("ConnectionPool", func(t *testing.T) {
+		opts := Options{}.Apply(Options{
+			MaxConnsPerHost:    null.IntFrom(1),
+			MaxIdleConns:       null.IntFrom(2),
+			MaxRequestsPerConn: null.IntFrom(3),
+		})
+		assert.Equal(t, null.IntFrom(1), opts.MaxConnsPerHost)
+		assert.Equal(t, null.IntFrom(2), opts.MaxIdleConns)
+		assert.Equal(t, null.IntFrom(3), opts.MaxRequestsPerConn)
+	})
 	t.Run("HTTPDebug", func(t *testing.T) {
 		opts := Options{}.Apply(Options{HTTPDebug: null.StringFrom("foo")})
 		assert.True(t, opts.HTTPDebug.Valid)
@@ -296,6 +306,11 @@ func TestOptions(t *testing.T) {
 		assert.True(t, opts.NoVUConnectionReuse.Valid)
 		assert.True(t, opts.NoVUConnectionReuse.Bool)
 	})
+	t.Run("SharedCookieJar", func(t *testing.T) {
+		opts := Options{}.Apply(Options{SharedCookieJar: null.BoolFrom(true)})
+		assert.True(t, opts.SharedCookieJar.Valid)
+		assert.True(t, opts.SharedCookieJar.Bool)
+	})
 	t.Run("NoCookiesReset", func(t *testing.T) {
 		opts := Options{}.Apply(Options{NoCookiesReset: null.BoolFrom(true)})
 		assert.True(t, opts.NoCookiesReset.Valid)
@@ -362,6 +377,30 @@ func TestOptions(t *testing.T) {
 		}})
 		assert.NotNil(t, opts.Thresholds)
 		assert.NotEmpty(t, opts.Thresholds)
+		assert.Empty(t, opts.Validate())
+
+		assert.Empty(t, Options{Thresholds: map[string]stats.Thresholds{
+			`metric{name=~"/api/v1/.*"}`: {},
+		}}.Validate())
+		assert.Len(t, Options{Thresholds: map[string]stats.Thresholds{
+			`metric{name=~"/api/(v1"}`: {},
+		}}.Validate(), 1)
+	})
+	t.Run("ThresholdsPerScenario", func(t *testing.T) {
+		opts := Options{}.Apply(Options{ThresholdsPerScenario: null.BoolFrom(true)})
+		assert.Equal(t, null.BoolFrom(true), opts.ThresholdsPerScenario)
+		assert.Empty(t, opts.Validate())
+		assert.Len(t, Options{
+			ThresholdsPerScenario: null.BoolFrom(true),
+			SystemTags:            stats.NewSystemTagSet(stats.TagVU),
+		}.Validate(), 1)
+	})
+	t.Run("ThresholdsWebhook", func(t *testing.T) {
+		opts := Options{}.Apply(Options{ThresholdsWebhook: null.StringFrom("https://example.com/alerts")})
+		assert.Equal(t, null.StringFrom("https://example.com/alerts"), opts.ThresholdsWebhook)
+		assert.Empty(t, opts.Validate())
+		assert.Len(t, Options{ThresholdsWebhook: null.StringFrom("example.com/alerts")}.Validate(), 1)
+		assert.Len(t, Options{ThresholdsWebhook: null.StringFrom("ftp://example.com")}.Validate(), 1)
 	})
 	t.Run("External", func(t *testing.T) {
 		ext := map[string]json.RawMessage{"a": json.RawMessage("1")}
@@ -406,6 +445,40 @@ func TestOptions(t *testing.T) {
 			})
 		})
 	})
+	t.Run("TrendRelativeError", func(t *testing.T) {
+		opts := Options{}.Apply(Options{TrendRelativeError: null.FloatFrom(0.05)})
+		assert.Equal(t, null.FloatFrom(0.05), opts.TrendRelativeError)
+		assert.Empty(t, opts.Validate())
+		assert.Len(t, Options{TrendRelativeError: null.FloatFrom(1)}.Validate(), 1)
+		assert.Len(t, Options{TrendRelativeError: null.FloatFrom(-0.1)}.Validate(), 1)
+	})
+	t.Run("SummaryTrendStatsPerMetric", func(t *testing.T) {
+		perMetric := map[string][]string{"http_req_duration": {"avg", "p(99.9)"}}
+		opts := Options{SummaryTrendStats: DefaultSummaryTrendStats}.
+			Apply(Options{SummaryTrendStatsPerMetric: perMetric})
+		assert.Equal(t, perMetric, opts.SummaryTrendStatsPerMetric)
+		assert.Equal(t, []string{"avg", "p(99.9)"}, opts.GetSummaryTrendStats("http_req_duration"))
+		assert.Equal(t, []string{"avg", "p(99.9)"},
+			opts.GetSummaryTrendStats("http_req_duration{expected_response:true}"))
+		assert.Equal(t, DefaultSummaryTrendStats, opts.GetSummaryTrendStats("iteration_duration"))
+	})
+	t.Run("TagCardinality", func(t *testing.T) {
+		opts := Options{}.Apply(Options{
+			TagCardinalityLimit:  null.IntFrom(100),
+			TagCardinalityAction: null.StringFrom(TagCardinalityHash),
+		})
+		assert.Equal(t, null.IntFrom(100), opts.TagCardinalityLimit)
+		assert.Equal(t, null.StringFrom(TagCardinalityHash), opts.TagCardinalityAction)
+		assert.Empty(t, opts.Validate())
+		assert.Len(t, Options{TagCardinalityLimit: null.IntFrom(-1)}.Validate(), 1)
+		assert.Len(t, Options{TagCardinalityAction: null.StringFrom("ignore")}.Validate(), 1)
+	})
+	t.Run("URLGroups", func(t *testing.T) {
+		groups, err := types.NewURLGroups(types.URLGroup{Template: "/users/{id}"})
+		require.NoError(t, err)
+		opts := Options{}.Apply(Options{URLGroups: groups})
+		assert.Equal(t, groups, opts.URLGroups)
+	})
 	t.Run("SummaryTrendStats", func(t *testing.T) {
 		stats := []string{"myStat1", "myStat2"}
 		opts := Options{}.Apply(Options{SummaryTrendStats: stats})
@@ -521,6 +594,11 @@ func TestOptionsEnv(t *testing.T) {
 			"true":  null.BoolFrom(true),
 			"false": null.BoolFrom(false),
 		},
+		{"SharedCookieJar", "K6_SHARED_COOKIE_JAR"}: {
+			"":      null.Bool{},
+			"true":  null.BoolFrom(true),
+			"false": null.BoolFrom(false),
+		},
 		// Thresholds
 		// External
 	}
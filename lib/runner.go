@@ -56,8 +56,22 @@ type VUActivationParams struct {
 	Env, Tags                map[string]string
 	Exec, Scenario           string
 	GetNextIterationCounters func() (uint64, uint64)
+
+	// ExecPicker, when set, picks the function of each iteration instead of Exec
+	ExecPicker *ExecPicker
+	// WarmupDuration is how long after the start of the scenario the iterations
+	// are tagged with WarmupTagName
+	WarmupDuration time.Duration
+	// CriticalStop is how long the critical section of an iteration can still
+	// run after the VU is stopped
+	CriticalStop time.Duration
 }
 
+// WarmupTagName is the tag of the samples of the iterations which started
+// during the warm-up of their scenario. They are still sent to the outputs,
+// but they are excluded from the thresholds and the end-of-test summary.
+const WarmupTagName = "warmup"
+
 // A Runner is a factory for VUs. It should precompute as much as possible upon
 // creation (parse ASTs, load files into memory, etc.), so that spawning VUs
 // becomes as fast as possible. The Runner doesn't actually *do* anything in
@@ -88,6 +102,13 @@ type Runner interface {
 	// Runs post-test teardown, if applicable.
 	Teardown(ctx context.Context, out chan<- stats.SampleContainer) error
 
+	// Runs the setup of the scenario, if it has one, and keeps its data for
+	// the iterations of the scenario instead of the data of the global setup.
+	ScenarioSetup(ctx context.Context, out chan<- stats.SampleContainer, scenario string) error
+
+	// Runs the teardown of the scenario, if it has one, with its setup data.
+	ScenarioTeardown(ctx context.Context, out chan<- stats.SampleContainer, scenario string) error
+
 	// Returns the default (root) Group.
 	GetDefaultGroup() *Group
 
@@ -118,4 +139,14 @@ type Summary struct {
 	TestRunDuration time.Duration // TODO: use lib.ExecutionState-based interface instead?
 	NoColor         bool          // TODO: drop this when noColor is part of the (runtime) options
 	UIState         UIState
+
+	TagCardinalityOffenders []TagCardinalityOffender
+}
+
+// TagCardinalityOffender is a tag of a metric with more unique values than the TagCardinalityLimit.
+type TagCardinalityOffender struct {
+	Metric string
+	Tag    string
+	// OverLimitSamples is the count of the samples with a value of the tag above the limit
+	OverLimitSamples uint64
 }
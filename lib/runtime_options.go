@@ -58,6 +58,18 @@ type RuntimeOptions struct {
 	NoThresholds  null.Bool   `json:"noThresholds"`
 	NoSummary     null.Bool   `json:"noSummary"`
 	SummaryExport null.String `json:"summaryExport"`
+
+	// The summary export of a previous test run, whose values the thresholds can compare to with baseline
+	Baseline null.String `json:"baseline"`
+
+	// The file the HTTP requests of all VUs are recorded to as an HTTP Archive (HAR)
+	HAROut null.String `json:"harOut"`
+
+	// The s3:// or gs:// URL the summary, the HAR file and the files of the outputs are uploaded to
+	UploadResults null.String `json:"uploadResults"`
+
+	// Whether to not define setTimeout, clearTimeout, setInterval and clearInterval as globals
+	NoGlobalTimers null.Bool `json:"noGlobalTimers"`
 }
 
 // ValidateCompatibilityMode checks if the provided val is a valid compatibility mode
@@ -23,16 +23,18 @@ package lib
 import (
 	"context"
 	"crypto/tls"
+	"io"
 	"net"
 	"net/http"
-	"net/http/cookiejar"
 	"sync"
+	"time"
 
 	"github.com/oxtoacart/bpool"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/time/rate"
 
 	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/lib/netext/har"
 	"go.k6.io/k6/stats"
 )
 
@@ -59,8 +61,12 @@ type State struct {
 	// TODO: move a lot of the things below to the k6/http ModuleInstance, see
 	// https://github.com/grafana/k6/issues/2293.
 	Transport http.RoundTripper
-	CookieJar *cookiejar.Jar
-	TLSConfig *tls.Config
+	// H2CTransport sends the requests over cleartext HTTP/2 with prior knowledge.
+	H2CTransport http.RoundTripper
+	CookieJar    *CookieJar
+	TLSConfig    *tls.Config
+	// HARRecorder records all the HTTP requests if it's set.
+	HARRecorder *har.Recorder
 
 	// Rate limits.
 	RPSLimit *rate.Limiter
@@ -74,7 +80,9 @@ type State struct {
 
 	VUID, VUIDGlobal uint64
 	Iteration        int64
-	Tags             *TagMap
+	// IterationStart is when the current iteration, or setup() or teardown(), started.
+	IterationStart time.Time
+	Tags           *TagMap
 	// These will be assigned on VU activation.
 	// Returns the iteration number of this VU in the current scenario.
 	GetScenarioVUIter func() uint64
@@ -86,8 +94,16 @@ type State struct {
 	// unique globally across k6 instances (taking into account execution
 	// segments).
 	GetScenarioGlobalVUIter func() uint64
+	// Runs the function as a critical section of the iteration, which isn't
+	// interrupted when the VU is stopped until the criticalStop of the scenario
+	// expires. It's nil when the VU isn't activated, e.g. in setup().
+	RunCritical func(fn func() error) error
 
 	BuiltinMetrics *metrics.BuiltinMetrics
+
+	// OpenStreams are the streamed response bodies that haven't been read
+	// until the end or closed yet, they are closed when the iteration ends.
+	OpenStreams *OpenStreams
 }
 
 // CloneTags makes a copy of the tags map and returns it.
@@ -95,6 +111,49 @@ func (s *State) CloneTags() map[string]string {
 	return s.Tags.Clone()
 }
 
+// OpenStreams keeps track of the streams of a VU that are still open. It's safe for concurrent use.
+type OpenStreams struct {
+	streams map[io.Closer]struct{}
+	mutex   sync.Mutex
+}
+
+// Add adds a stream that was opened.
+func (s *OpenStreams) Add(stream io.Closer) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.streams == nil {
+		s.streams = make(map[io.Closer]struct{})
+	}
+	s.streams[stream] = struct{}{}
+}
+
+// Remove removes a stream that was closed.
+func (s *OpenStreams) Remove(stream io.Closer) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.streams, stream)
+}
+
+// Len returns the number of the open streams.
+func (s *OpenStreams) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.streams)
+}
+
+// CloseAll closes all the open streams.
+func (s *OpenStreams) CloseAll() {
+	s.mutex.Lock()
+	streams := s.streams
+	s.streams = nil
+	s.mutex.Unlock()
+
+	// the streams are closed without the lock, since closing removes them
+	for stream := range streams {
+		_ = stream.Close()
+	}
+}
+
 // TagMap is a safe-concurrent Tags lookup.
 type TagMap struct {
 	m     map[string]string
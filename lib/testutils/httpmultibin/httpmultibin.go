@@ -211,6 +211,10 @@ type GRPCStub struct {
 	grpctest.TestServiceServer
 	EmptyCallFunc func(context.Context, *grpctest.Empty) (*grpctest.Empty, error)
 	UnaryCallFunc func(context.Context, *grpctest.SimpleRequest) (*grpctest.SimpleResponse, error)
+
+	StreamingOutputCallFunc func(*grpctest.StreamingOutputCallRequest, grpctest.TestService_StreamingOutputCallServer) error
+	StreamingInputCallFunc  func(grpctest.TestService_StreamingInputCallServer) error
+	FullDuplexCallFunc      func(grpctest.TestService_FullDuplexCallServer) error
 }
 
 // EmptyCall implements the interface for the gRPC TestServiceServer
@@ -232,18 +236,30 @@ func (s *GRPCStub) UnaryCall(ctx context.Context, req *grpctest.SimpleRequest) (
 }
 
 // StreamingOutputCall implements the interface for the gRPC TestServiceServer
-func (*GRPCStub) StreamingOutputCall(*grpctest.StreamingOutputCallRequest,
-	grpctest.TestService_StreamingOutputCallServer) error {
+func (s *GRPCStub) StreamingOutputCall(req *grpctest.StreamingOutputCallRequest,
+	stream grpctest.TestService_StreamingOutputCallServer) error {
+	if s.StreamingOutputCallFunc != nil {
+		return s.StreamingOutputCallFunc(req, stream)
+	}
+
 	return status.Errorf(codes.Unimplemented, "method StreamingOutputCall not implemented")
 }
 
 // StreamingInputCall implements the interface for the gRPC TestServiceServer
-func (*GRPCStub) StreamingInputCall(grpctest.TestService_StreamingInputCallServer) error {
+func (s *GRPCStub) StreamingInputCall(stream grpctest.TestService_StreamingInputCallServer) error {
+	if s.StreamingInputCallFunc != nil {
+		return s.StreamingInputCallFunc(stream)
+	}
+
 	return status.Errorf(codes.Unimplemented, "method StreamingInputCall not implemented")
 }
 
 // FullDuplexCall implements the interface for the gRPC TestServiceServer
-func (*GRPCStub) FullDuplexCall(grpctest.TestService_FullDuplexCallServer) error {
+func (s *GRPCStub) FullDuplexCall(stream grpctest.TestService_FullDuplexCallServer) error {
+	if s.FullDuplexCallFunc != nil {
+		return s.FullDuplexCallFunc(stream)
+	}
+
 	return status.Errorf(codes.Unimplemented, "method FullDuplexCall not implemented")
 }
 
@@ -44,6 +44,9 @@ type MiniRunner struct {
 	TeardownFn      func(ctx context.Context, out chan<- stats.SampleContainer) error
 	HandleSummaryFn func(context.Context, *lib.Summary) (map[string]io.Reader, error)
 
+	ScenarioSetupFn    func(ctx context.Context, out chan<- stats.SampleContainer, scenario string) error
+	ScenarioTeardownFn func(ctx context.Context, out chan<- stats.SampleContainer, scenario string) error
+
 	SetupData []byte
 
 	Group   *lib.Group
@@ -96,6 +99,22 @@ func (r MiniRunner) Teardown(ctx context.Context, out chan<- stats.SampleContain
 	return nil
 }
 
+// ScenarioSetup calls the supplied mock scenario setup function, if present.
+func (r MiniRunner) ScenarioSetup(ctx context.Context, out chan<- stats.SampleContainer, scenario string) error {
+	if fn := r.ScenarioSetupFn; fn != nil {
+		return fn(ctx, out, scenario)
+	}
+	return nil
+}
+
+// ScenarioTeardown calls the supplied mock scenario teardown function, if present.
+func (r MiniRunner) ScenarioTeardown(ctx context.Context, out chan<- stats.SampleContainer, scenario string) error {
+	if fn := r.ScenarioTeardownFn; fn != nil {
+		return fn(ctx, out, scenario)
+	}
+	return nil
+}
+
 // GetDefaultGroup returns the default group.
 func (r MiniRunner) GetDefaultGroup() *lib.Group {
 	if r.Group == nil {
@@ -0,0 +1,142 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// URLGroup is a rule which groups the URLs matching it under the same name tag, either with an OpenAPI-style
+// template like /users/{id}, whose parameters match a path segment, or with a regular expression.
+type URLGroup struct {
+	// Template is matched against the path of the URLs when it starts with a /, and against their scheme, host
+	// and path otherwise. The query strings are ignored.
+	Template string `json:"template,omitempty"`
+	// Regex is matched against the whole URLs, with the masked credentials
+	Regex string `json:"regex,omitempty"`
+	// Name is the name tag of the matching URLs. It's required for the regular expressions, and by default the
+	// template, with the scheme and host of the URL for a path one.
+	Name string `json:"name,omitempty"`
+
+	re *regexp.Regexp
+}
+
+//nolint:gochecknoglobals
+var templateParam = regexp.MustCompile(`\{[^{}/]*\}`)
+
+func (g *URLGroup) compile() error {
+	switch {
+	case g.Template != "" && g.Regex != "":
+		return fmt.Errorf("the URL group %q has both a template and a regex", g.Template)
+	case g.Template != "":
+		var pattern strings.Builder
+		pattern.WriteString("^")
+		last := 0
+		for _, param := range templateParam.FindAllStringIndex(g.Template, -1) {
+			pattern.WriteString(regexp.QuoteMeta(g.Template[last:param[0]]))
+			pattern.WriteString("[^/]+")
+			last = param[1]
+		}
+		pattern.WriteString(regexp.QuoteMeta(g.Template[last:]))
+		pattern.WriteString("$")
+		g.re = regexp.MustCompile(pattern.String())
+	case g.Regex != "":
+		if g.Name == "" {
+			return fmt.Errorf("the URL group with the regex %q needs a name", g.Regex)
+		}
+		re, err := regexp.Compile(g.Regex)
+		if err != nil {
+			return fmt.Errorf("invalid regex of the URL group %q: %w", g.Name, err)
+		}
+		g.re = re
+	default:
+		return errors.New("a URL group needs a template or a regex")
+	}
+	return nil
+}
+
+// name returns the name tag of the URL if it matches the group.
+func (g URLGroup) name(u *url.URL, cleanURL string) (string, bool) {
+	switch {
+	case g.Regex != "":
+		if g.re.MatchString(cleanURL) {
+			return g.Name, true
+		}
+	case strings.HasPrefix(g.Template, "/"):
+		if g.re.MatchString(u.Path) {
+			if g.Name != "" {
+				return g.Name, true
+			}
+			return u.Scheme + "://" + u.Host + g.Template, true
+		}
+	default:
+		if g.re.MatchString(u.Scheme + "://" + u.Host + u.Path) {
+			if g.Name != "" {
+				return g.Name, true
+			}
+			return g.Template, true
+		}
+	}
+	return "", false
+}
+
+// URLGroups are the URL groups of the options, which are matched in their order.
+type URLGroups []URLGroup
+
+// NewURLGroups validates the groups and returns them.
+func NewURLGroups(groups ...URLGroup) (URLGroups, error) {
+	for i := range groups {
+		if err := groups[i].compile(); err != nil {
+			return nil, err
+		}
+	}
+	return groups, nil
+}
+
+// UnmarshalJSON unmarshals the groups and validates them.
+func (gs *URLGroups) UnmarshalJSON(data []byte) error {
+	var groups []URLGroup
+	if err := json.Unmarshal(data, &groups); err != nil {
+		return err
+	}
+	result, err := NewURLGroups(groups...)
+	if err != nil {
+		return err
+	}
+	*gs = result
+	return nil
+}
+
+// Name returns the name tag of the first group matching the URL, whose string with the masked
+// credentials is cleanURL.
+func (gs URLGroups) Name(u *url.URL, cleanURL string) (string, bool) {
+	for _, g := range gs {
+		if name, ok := g.name(u, cleanURL); ok {
+			return name, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,71 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package types
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLGroups(t *testing.T) {
+	t.Parallel()
+
+	var groups URLGroups
+	require.NoError(t, json.Unmarshal([]byte(`[
+		{"template": "/users/{id}/posts/{postID}"},
+		{"template": "https://api.example.com/items/{id}", "name": "items"},
+		{"template": "https://api.example.com/orders/{id}"},
+		{"regex": "^https://example\\.com/search\\?q=", "name": "search"}
+	]`), &groups))
+
+	testCases := []struct {
+		url, name string
+	}{
+		{"https://example.com/users/1/posts/2", "https://example.com/users/{id}/posts/{postID}"},
+		{"http://example.com:8080/users/abc/posts/def?x=1", "http://example.com:8080/users/{id}/posts/{postID}"},
+		{"https://api.example.com/items/42", "items"},
+		{"https://api.example.com/orders/42?expand=true", "https://api.example.com/orders/{id}"},
+		{"https://example.com/search?q=k6", "search"},
+		{"https://example.com/users/1/posts/2/comments", ""},
+		{"https://example.com/users/1/posts", ""},
+		{"https://other.example.com/items/42", ""},
+	}
+	for _, tc := range testCases {
+		u, err := url.Parse(tc.url)
+		require.NoError(t, err)
+		name, ok := groups.Name(u, tc.url)
+		assert.Equal(t, tc.name != "", ok, tc.url)
+		assert.Equal(t, tc.name, name, tc.url)
+	}
+
+	for _, invalid := range []string{
+		`[{}]`,
+		`[{"template": "/users/{id}", "regex": "^/users"}]`,
+		`[{"regex": "^/users"}]`,
+		`[{"regex": "(", "name": "invalid"}]`,
+	} {
+		assert.Error(t, json.Unmarshal([]byte(invalid), &groups), invalid)
+	}
+}
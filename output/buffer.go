@@ -0,0 +1,132 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.k6.io/k6/lib/types"
+)
+
+// The policies of the buffers when they are full.
+const (
+	// OverflowBlock makes the engine wait for the next flush of the output, which stalls the VUs if it takes too long.
+	OverflowBlock = "block"
+	// OverflowDrop drops the samples, which are counted by the output_dropped_samples metric.
+	OverflowDrop = "drop"
+)
+
+// DefaultBlockTimeout is how long the engine waits for a flush of a full buffer with the block policy by default,
+// before it drops the samples.
+const DefaultBlockTimeout = 10 * time.Second
+
+// BufferLimit limits the samples that are buffered by an output until they are flushed, so the outputs with slow
+// remote services don't take all the memory. It's a part of the JSON config of every output, and it can be set with
+// the K6_<OUTPUT>_MAX_BUFFERED_SAMPLES, K6_<OUTPUT>_OVERFLOW, K6_<OUTPUT>_BLOCK_TIMEOUT, K6_<OUTPUT>_FLUSH_INTERVAL
+// and K6_<OUTPUT>_MAX_IN_FLIGHT_BATCHES env vars, e.g. K6_INFLUXDB_MAX_BUFFERED_SAMPLES=100000.
+// The buffers aren't limited by default, and the outputs flush them with their own intervals, one batch at a time.
+type BufferLimit struct {
+	// Output is the type of the output, which the metrics of its buffer are tagged with.
+	Output     string `json:"-"`
+	MaxSamples int64  `json:"maxBufferedSamples,omitempty"`
+	Overflow   string `json:"overflow,omitempty"`
+	// BlockTimeout is how long the block policy waits for a flush, the samples are dropped after it.
+	BlockTimeout types.NullDuration `json:"blockTimeout,omitempty"`
+	// FlushInterval overwrites the push interval of the output.
+	FlushInterval types.NullDuration `json:"flushInterval,omitempty"`
+	// MaxInFlightBatches is how many flushes of the buffer can run at once, the ticks are skipped when all of them are.
+	MaxInFlightBatches int64 `json:"maxInFlightBatches,omitempty"`
+}
+
+// BufferStatus is the status of a limited buffer.
+type BufferStatus struct {
+	Limit    BufferLimit
+	Buffered int64
+	Dropped  int64
+}
+
+// GetBufferLimit returns the buffer limit of the output from its JSON config, with the values of its env vars
+// overwriting the ones of the JSON config.
+func GetBufferLimit(outputType string, jsonRawConf json.RawMessage, env map[string]string) (BufferLimit, error) {
+	l := BufferLimit{}
+	if jsonRawConf != nil {
+		if err := json.Unmarshal(jsonRawConf, &l); err != nil {
+			return l, err
+		}
+	}
+	l.Output = outputType
+
+	prefix := "K6_" + strings.ToUpper(outputType) + "_"
+	if v, ok := env[prefix+"MAX_BUFFERED_SAMPLES"]; ok {
+		maxSamples, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return l, fmt.Errorf("invalid %sMAX_BUFFERED_SAMPLES '%s': %w", prefix, v, err)
+		}
+		l.MaxSamples = maxSamples
+	}
+	if v, ok := env[prefix+"OVERFLOW"]; ok {
+		l.Overflow = v
+	}
+	durations := map[string]*types.NullDuration{"BLOCK_TIMEOUT": &l.BlockTimeout, "FLUSH_INTERVAL": &l.FlushInterval}
+	for name, d := range durations {
+		if v, ok := env[prefix+name]; ok {
+			duration, err := types.ParseExtendedDuration(v)
+			if err != nil {
+				return l, fmt.Errorf("invalid %s%s '%s': %w", prefix, name, v, err)
+			}
+			*d = types.NullDurationFrom(duration)
+		}
+	}
+	if v, ok := env[prefix+"MAX_IN_FLIGHT_BATCHES"]; ok {
+		maxInFlight, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return l, fmt.Errorf("invalid %sMAX_IN_FLIGHT_BATCHES '%s': %w", prefix, v, err)
+		}
+		l.MaxInFlightBatches = maxInFlight
+	}
+
+	switch {
+	case l.FlushInterval.Valid && l.FlushInterval.Duration <= 0:
+		return l, fmt.Errorf("the flush interval needs to be positive, but it was %s", l.FlushInterval)
+	case l.MaxInFlightBatches < 0:
+		return l, fmt.Errorf("the max in-flight batches can't be negative, but it was %d", l.MaxInFlightBatches)
+	case l.MaxSamples < 0:
+		return l, fmt.Errorf("the max buffered samples can't be negative, but it was %d", l.MaxSamples)
+	case l.MaxSamples == 0 && l.Overflow != "":
+		return l, fmt.Errorf("the '%s' overflow policy needs the max buffered samples", l.Overflow)
+	case l.MaxSamples == 0 && l.BlockTimeout.Valid:
+		return l, fmt.Errorf("the block timeout needs the max buffered samples")
+	case l.BlockTimeout.Valid && l.BlockTimeout.Duration <= 0:
+		return l, fmt.Errorf("the block timeout needs to be positive, but it was %s", l.BlockTimeout)
+	case l.BlockTimeout.Valid && l.Overflow == OverflowDrop:
+		return l, fmt.Errorf("the block timeout needs the '%s' overflow policy", OverflowBlock)
+	case l.Overflow == "":
+		l.Overflow = OverflowBlock
+	case l.Overflow != OverflowBlock && l.Overflow != OverflowDrop:
+		return l, fmt.Errorf("invalid overflow policy '%s', it needs to be '%s' or '%s'",
+			l.Overflow, OverflowBlock, OverflowDrop)
+	}
+	return l, nil
+}
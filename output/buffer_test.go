@@ -0,0 +1,85 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package output
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/lib/types"
+)
+
+func TestGetBufferLimit(t *testing.T) {
+	t.Parallel()
+
+	l, err := GetBufferLimit("influxdb", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, BufferLimit{Output: "influxdb", Overflow: OverflowBlock}, l)
+
+	l, err = GetBufferLimit("influxdb", []byte(`{"addr":"http://localhost:8086","maxBufferedSamples":1000}`),
+		map[string]string{"K6_INFLUXDB_OVERFLOW": "drop"})
+	require.NoError(t, err)
+	assert.Equal(t, BufferLimit{Output: "influxdb", MaxSamples: 1000, Overflow: OverflowDrop}, l)
+
+	l, err = GetBufferLimit("influxdb", []byte(`{"maxBufferedSamples":1000}`),
+		map[string]string{"K6_INFLUXDB_MAX_BUFFERED_SAMPLES": "10"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), l.MaxSamples)
+
+	l, err = GetBufferLimit("statsd", []byte(`{"maxBufferedSamples":100,"blockTimeout":"2s","maxInFlightBatches":2}`),
+		map[string]string{"K6_STATSD_FLUSH_INTERVAL": "500ms"})
+	require.NoError(t, err)
+	assert.Equal(t, BufferLimit{
+		Output:             "statsd",
+		MaxSamples:         100,
+		Overflow:           OverflowBlock,
+		BlockTimeout:       types.NullDurationFrom(2 * time.Second),
+		FlushInterval:      types.NullDurationFrom(500 * time.Millisecond),
+		MaxInFlightBatches: 2,
+	}, l)
+
+	for env, expErr := range map[string]string{
+		"K6_CSV_MAX_BUFFERED_SAMPLES=many": "invalid K6_CSV_MAX_BUFFERED_SAMPLES 'many'",
+		"K6_CSV_MAX_BUFFERED_SAMPLES=-1":   "can't be negative",
+		"K6_CSV_OVERFLOW=drop":             "the 'drop' overflow policy needs the max buffered samples",
+		"K6_CSV_BLOCK_TIMEOUT=1s":          "the block timeout needs the max buffered samples",
+		"K6_CSV_FLUSH_INTERVAL=soon":       "invalid K6_CSV_FLUSH_INTERVAL 'soon'",
+		"K6_CSV_FLUSH_INTERVAL=0s":         "the flush interval needs to be positive",
+		"K6_CSV_MAX_IN_FLIGHT_BATCHES=-2":  "the max in-flight batches can't be negative",
+	} {
+		kv := strings.SplitN(env, "=", 2)
+		_, err = GetBufferLimit("csv", nil, map[string]string{kv[0]: kv[1]})
+		require.Error(t, err, env)
+		assert.Contains(t, err.Error(), expErr)
+	}
+
+	_, err = GetBufferLimit("csv", []byte(`{"maxBufferedSamples":10,"overflow":"spill"}`), nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid overflow policy 'spill'")
+
+	_, err = GetBufferLimit("csv", []byte(`{"maxBufferedSamples":10,"overflow":"drop","blockTimeout":"1s"}`), nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "the block timeout needs the 'block' overflow policy")
+}
@@ -0,0 +1,248 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package clickhouse implements an output that inserts the samples in a ClickHouse table through its HTTP interface,
+// with the tags that are configured flattened in columns of their own.
+package clickhouse
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.k6.io/k6/output"
+	"go.k6.io/k6/stats"
+)
+
+const timestampFormat = "2006-01-02 15:04:05.000000"
+
+// Output is the ClickHouse Output struct
+type Output struct {
+	output.SampleBuffer
+
+	config Config
+	client *http.Client
+	table  string
+
+	logger          logrus.FieldLogger
+	periodicFlusher *output.PeriodicFlusher
+	semaphoreCh     chan struct{}
+	wg              sync.WaitGroup
+}
+
+// New returns new ClickHouse output
+func New(params output.Params) (output.Output, error) {
+	return newOutput(params)
+}
+
+func newOutput(params output.Params) (*Output, error) {
+	conf, err := GetConsolidatedConfig(params.JSONConfig, params.Environment, params.ConfigArgument)
+	if err != nil {
+		return nil, err
+	}
+	return &Output{
+		config: conf,
+		client: &http.Client{
+			Timeout: conf.Timeout.TimeDuration(),
+			Transport: &http.Transport{
+				Proxy: http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{
+					InsecureSkipVerify: conf.Insecure.Bool, //nolint:gosec
+				},
+			},
+		},
+		table: quoteIdentifier(conf.DB.String) + "." + quoteIdentifier(conf.Table.String),
+		logger: params.Logger.WithFields(logrus.Fields{
+			"output": "ClickHouse",
+		}),
+		semaphoreCh: make(chan struct{}, conf.ConcurrentWrites.Int64),
+	}, nil
+}
+
+func quoteIdentifier(name string) string {
+	return "`" + strings.NewReplacer("\\", "\\\\", "`", "\\`").Replace(name) + "`"
+}
+
+// Description returns a human-readable description of the output.
+func (o *Output) Description() string {
+	return fmt.Sprintf("ClickHouse (%s, %s)", o.config.Addr.String, o.table)
+}
+
+// Start creates the database and the table, if it's enabled, and starts the goroutine for metric flushing.
+func (o *Output) Start() error {
+	o.logger.Debug("Starting...")
+	if o.config.CreateTable.Bool {
+		if err := o.createTable(); err != nil {
+			return err
+		}
+	}
+
+	pf, err := o.NewPeriodicFlusher(o.config.PushInterval.TimeDuration(), o.flushMetrics)
+	if err != nil {
+		return err
+	}
+	o.logger.Debug("Started!")
+	o.periodicFlusher = pf
+
+	return nil
+}
+
+// Stop flushes any remaining metrics and stops the goroutine.
+func (o *Output) Stop() error {
+	o.logger.Debug("Stopping...")
+	defer o.logger.Debug("Stopped!")
+	o.periodicFlusher.Stop()
+	o.wg.Wait()
+	return nil
+}
+
+// createTable creates the table, with a LowCardinality column for every flattened tag and a map for the other ones.
+func (o *Output) createTable() error {
+	if err := o.exec("CREATE DATABASE IF NOT EXISTS "+quoteIdentifier(o.config.DB.String), nil, nil); err != nil {
+		return fmt.Errorf("couldn't create the clickhouse database: %w", err)
+	}
+
+	var columns strings.Builder
+	columns.WriteString("timestamp DateTime64(6, 'UTC'), metric LowCardinality(String), " +
+		"metric_type LowCardinality(String), value Float64, ")
+	for _, tag := range o.config.TagColumns {
+		columns.WriteString(quoteIdentifier(tag) + " LowCardinality(String), ")
+	}
+	columns.WriteString("tags Map(String, String)")
+
+	query := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s) ENGINE = MergeTree ORDER BY (metric, timestamp)",
+		o.table, columns.String())
+	if err := o.exec(query, nil, nil); err != nil {
+		return fmt.Errorf("couldn't create the clickhouse table: %w", err)
+	}
+	return nil
+}
+
+// exec runs the query, with the body as its data, if there is one.
+func (o *Output) exec(query string, body io.Reader, settings url.Values) error {
+	if settings == nil {
+		settings = url.Values{}
+	}
+	settings.Set("query", query)
+	req, err := http.NewRequest(http.MethodPost, o.config.Addr.String+"/?"+settings.Encode(), body)
+	if err != nil {
+		return err
+	}
+	if o.config.Username.Valid {
+		req.SetBasicAuth(o.config.Username.String, o.config.Password.String)
+	}
+
+	res, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+	if res.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1<<10))
+		if len(bytes.TrimSpace(msg)) == 0 {
+			return fmt.Errorf("clickhouse responded with %s", res.Status)
+		}
+		return fmt.Errorf("clickhouse responded with %s: %s", res.Status, bytes.TrimSpace(msg))
+	}
+	_, _ = io.Copy(ioutil.Discard, res.Body)
+	return nil
+}
+
+// batchFromSamples encodes the samples as rows of the JSONEachRow format.
+func (o *Output) batchFromSamples(containers []stats.SampleContainer) ([]byte, int, error) {
+	var batch bytes.Buffer
+	encoder := json.NewEncoder(&batch)
+	var count int
+	for _, container := range containers {
+		for _, sample := range container.GetSamples() {
+			tags := sample.Tags.CloneTags()
+			row := make(map[string]interface{}, len(o.config.TagColumns)+5)
+			row["timestamp"] = sample.Time.UTC().Format(timestampFormat)
+			row["metric"] = sample.Metric.Name
+			row["metric_type"] = sample.Metric.Type.String()
+			row["value"] = sample.Value
+			for _, tag := range o.config.TagColumns {
+				row[tag] = tags[tag]
+				delete(tags, tag)
+			}
+			row["tags"] = tags
+			if err := encoder.Encode(row); err != nil {
+				return nil, 0, fmt.Errorf("couldn't encode the sample: %w", err)
+			}
+			count++
+		}
+	}
+	return batch.Bytes(), count, nil
+}
+
+func (o *Output) flushMetrics() {
+	samples := o.GetBufferedSamples()
+	if len(samples) < 1 {
+		return
+	}
+
+	o.wg.Add(1)
+	o.semaphoreCh <- struct{}{}
+	go func() {
+		defer func() {
+			<-o.semaphoreCh
+			o.wg.Done()
+		}()
+
+		batch, count, err := o.batchFromSamples(samples)
+		if err != nil {
+			o.logger.WithError(err).Error("Couldn't create batch from samples")
+			return
+		}
+
+		settings := url.Values{}
+		if o.config.AsyncInsert.Bool {
+			// waiting for the async inserts makes their errors visible, without losing their batching on the server
+			settings.Set("async_insert", "1")
+			settings.Set("wait_for_async_insert", "1")
+		}
+
+		o.logger.WithField("rows", count).Debug("Inserting...")
+		startTime := time.Now()
+		if err := o.exec("INSERT INTO "+o.table+" FORMAT JSONEachRow", bytes.NewReader(batch), settings); err != nil {
+			o.logger.WithError(err).Error("Couldn't insert the samples")
+			return
+		}
+		t := time.Since(startTime)
+		o.logger.WithField("t", t).Debug("Batch inserted!")
+
+		if t > o.config.PushInterval.TimeDuration() {
+			o.logger.WithField("t", t).
+				Warn("The flush operation took higher than the expected set push interval. If you see this message multiple times then the setup or configuration need to be adjusted to achieve a sustainable rate.") //nolint:lll
+		}
+	}()
+}
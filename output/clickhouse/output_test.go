@@ -0,0 +1,157 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package clickhouse
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/output"
+	"go.k6.io/k6/stats"
+)
+
+type query struct {
+	query, asyncInsert, user, body string
+}
+
+func newTestServer(t *testing.T, status int) (*httptest.Server, func() []query) {
+	t.Helper()
+	var (
+		mu      sync.Mutex
+		queries []query
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		assert.NoError(t, err)
+		user, _, _ := r.BasicAuth()
+		mu.Lock()
+		queries = append(queries, query{
+			query:       r.URL.Query().Get("query"),
+			asyncInsert: r.URL.Query().Get("async_insert"),
+			user:        user,
+			body:        string(body),
+		})
+		mu.Unlock()
+		rw.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []query {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]query{}, queries...)
+	}
+}
+
+func TestOutput(t *testing.T) {
+	t.Parallel()
+	srv, queries := newTestServer(t, http.StatusOK)
+
+	out, err := newOutput(output.Params{
+		Logger:         testutils.NewLogger(t),
+		ConfigArgument: srv.URL + "/k6db?table=results&tagColumns=name,status&pushInterval=10ms",
+		JSONConfig:     []byte(`{"username":"k6"}`),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "ClickHouse ("+srv.URL+", `k6db`.`results`)", out.Description())
+
+	require.NoError(t, out.Start())
+	out.AddMetricSamples([]stats.SampleContainer{
+		stats.Sample{
+			Time:   time.Date(2022, 1, 2, 3, 4, 5, 6000, time.UTC),
+			Metric: stats.New("http_req_duration", stats.Trend, stats.Time),
+			Value:  1.5,
+			Tags: stats.NewSampleTags(map[string]string{
+				"name": "http://test.k6.io", "status": "200", "proto": "HTTP/1.1",
+			}),
+		},
+		stats.Sample{
+			Time:   time.Date(2022, 1, 2, 3, 4, 6, 0, time.UTC),
+			Metric: stats.New("iterations", stats.Counter),
+			Value:  1,
+			Tags:   stats.NewSampleTags(map[string]string{}),
+		},
+	})
+	time.Sleep(100 * time.Millisecond)
+	require.NoError(t, out.Stop())
+
+	qs := queries()
+	require.Len(t, qs, 3)
+	assert.Equal(t, "CREATE DATABASE IF NOT EXISTS `k6db`", qs[0].query)
+	assert.Equal(t, "CREATE TABLE IF NOT EXISTS `k6db`.`results` (timestamp DateTime64(6, 'UTC'), "+
+		"metric LowCardinality(String), metric_type LowCardinality(String), value Float64, "+
+		"`name` LowCardinality(String), `status` LowCardinality(String), tags Map(String, String)) "+
+		"ENGINE = MergeTree ORDER BY (metric, timestamp)", qs[1].query)
+	assert.Equal(t, "INSERT INTO `k6db`.`results` FORMAT JSONEachRow", qs[2].query)
+	assert.Equal(t, "1", qs[2].asyncInsert)
+	assert.Equal(t, "k6", qs[2].user)
+
+	var rows []map[string]interface{}
+	scanner := bufio.NewScanner(strings.NewReader(qs[2].body))
+	for scanner.Scan() {
+		var row map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &row))
+		rows = append(rows, row)
+	}
+	assert.Equal(t, []map[string]interface{}{
+		{
+			"timestamp": "2022-01-02 03:04:05.000006", "metric": "http_req_duration", "metric_type": "trend",
+			"value": 1.5, "name": "http://test.k6.io", "status": "200",
+			"tags": map[string]interface{}{"proto": "HTTP/1.1"},
+		},
+		{
+			"timestamp": "2022-01-02 03:04:06.000000", "metric": "iterations", "metric_type": "counter",
+			"value": 1.0, "name": "", "status": "", "tags": map[string]interface{}{},
+		},
+	}, rows)
+}
+
+func TestOutputCreateTableError(t *testing.T) {
+	t.Parallel()
+	srv, queries := newTestServer(t, http.StatusForbidden)
+
+	out, err := newOutput(output.Params{
+		Logger:         testutils.NewLogger(t),
+		ConfigArgument: srv.URL,
+	})
+	require.NoError(t, err)
+	require.EqualError(t, out.Start(), "couldn't create the clickhouse database: clickhouse responded with 403 Forbidden")
+	assert.Len(t, queries(), 1)
+
+	out, err = newOutput(output.Params{
+		Logger:         testutils.NewLogger(t),
+		ConfigArgument: srv.URL + "?createTable=false",
+	})
+	require.NoError(t, err)
+	require.NoError(t, out.Start())
+	require.NoError(t, out.Stop())
+	assert.Len(t, queries(), 1)
+}
@@ -31,6 +31,7 @@ import (
 	"github.com/mstoykov/envconfig"
 	"github.com/sirupsen/logrus"
 	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/output"
 )
 
 // Config is the config for the csv output
@@ -38,6 +39,10 @@ type Config struct {
 	// Samples.
 	FileName     null.String        `json:"file_name" envconfig:"K6_CSV_FILENAME"`
 	SaveInterval types.NullDuration `json:"save_interval" envconfig:"K6_CSV_SAVE_INTERVAL"`
+
+	// The file is rotated when it's bigger than RotateSize or older than RotateInterval, if they are set
+	RotateSize     output.NullFileSize `json:"rotate_size" envconfig:"K6_CSV_ROTATE_SIZE"`
+	RotateInterval types.NullDuration  `json:"rotate_interval" envconfig:"K6_CSV_ROTATE_INTERVAL"`
 }
 
 // NewConfig creates a new Config instance with default values for some fields.
@@ -56,6 +61,12 @@ func (c Config) Apply(cfg Config) Config {
 	if cfg.SaveInterval.Valid {
 		c.SaveInterval = cfg.SaveInterval
 	}
+	if cfg.RotateSize.Valid {
+		c.RotateSize = cfg.RotateSize
+	}
+	if cfg.RotateInterval.Valid {
+		c.RotateInterval = cfg.RotateInterval
+	}
 	return c
 }
 
@@ -89,6 +100,14 @@ func ParseArg(arg string, logger *logrus.Logger) (Config, error) {
 			fallthrough
 		case "fileName":
 			c.FileName = null.StringFrom(r[1])
+		case "rotateSize":
+			if err := c.RotateSize.UnmarshalText([]byte(r[1])); err != nil {
+				return c, err
+			}
+		case "rotateInterval":
+			if err := c.RotateInterval.UnmarshalText([]byte(r[1])); err != nil {
+				return c, err
+			}
 		default:
 			return c, fmt.Errorf("unknown key %q as argument for csv output", r[0])
 		}
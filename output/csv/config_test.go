@@ -33,6 +33,7 @@ import (
 
 	"go.k6.io/k6/lib/testutils"
 	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/output"
 )
 
 func TestNewConfig(t *testing.T) {
@@ -126,6 +127,16 @@ func TestParseArg(t *testing.T) {
 		"filename=test.csv,save_interval=5s": {
 			expectedErr: true,
 		},
+		"fileName=test.csv.zst,rotateSize=10MB,rotateInterval=1h": {
+			config: Config{
+				FileName:       null.StringFrom("test.csv.zst"),
+				RotateSize:     output.NullFileSizeFrom(10 << 20),
+				RotateInterval: types.NullDurationFrom(time.Hour),
+			},
+		},
+		"rotateSize=10PB": {
+			expectedErr: true,
+		},
 	}
 
 	for arg, testCase := range cases {
@@ -145,6 +156,8 @@ func TestParseArg(t *testing.T) {
 			}
 			assert.Equal(t, testCase.config.FileName.String, config.FileName.String)
 			assert.Equal(t, testCase.config.SaveInterval.String(), config.SaveInterval.String())
+			assert.Equal(t, testCase.config.RotateSize, config.RotateSize)
+			assert.Equal(t, testCase.config.RotateInterval, config.RotateInterval)
 
 			var entries []string
 			for _, v := range hook.AllEntries() {
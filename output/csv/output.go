@@ -22,12 +22,10 @@ package csv
 
 import (
 	"bytes"
-	"compress/gzip"
 	"encoding/csv"
 	"fmt"
 	"os"
 	"sort"
-	"strings"
 	"sync"
 	"time"
 
@@ -48,6 +46,7 @@ type Output struct {
 	fname     string
 	csvWriter *csv.Writer
 	csvLock   sync.Mutex
+	file      *output.FileWriter
 	closeFn   func() error
 
 	resTags      []string
@@ -103,7 +102,8 @@ func newOutput(params output.Params) (*Output, error) {
 		}, nil
 	}
 
-	logFile, err := params.FS.Create(fname)
+	file, err := output.NewFileWriter(params.FS, fname,
+		config.RotateSize.Int64, config.RotateInterval.TimeDuration())
 	if err != nil {
 		return nil, err
 	}
@@ -116,20 +116,9 @@ func newOutput(params output.Params) (*Output, error) {
 		saveInterval: saveInterval,
 		logger:       logger,
 		params:       params,
-	}
-
-	if strings.HasSuffix(fname, ".gz") {
-		outfile := gzip.NewWriter(logFile)
-		csvWriter := csv.NewWriter(outfile)
-		c.csvWriter = csvWriter
-		c.closeFn = func() error {
-			_ = outfile.Close()
-			return logFile.Close()
-		}
-	} else {
-		csvWriter := csv.NewWriter(logFile)
-		c.csvWriter = csvWriter
-		c.closeFn = logFile.Close
+		file:         file,
+		csvWriter:    csv.NewWriter(file),
+		closeFn:      file.Close,
 	}
 
 	return &c, nil
@@ -147,14 +136,9 @@ func (o *Output) Description() string {
 func (o *Output) Start() error {
 	o.logger.Debug("Starting...")
 
-	header := MakeHeader(o.resTags)
-	err := o.csvWriter.Write(header)
-	if err != nil {
-		o.logger.WithField("filename", o.fname).Error("CSV: Error writing column names to file")
-	}
-	o.csvWriter.Flush()
+	o.writeHeader()
 
-	pf, err := output.NewPeriodicFlusher(o.saveInterval, o.flushMetrics)
+	pf, err := o.NewPeriodicFlusher(o.saveInterval, o.flushMetrics)
 	if err != nil {
 		return err
 	}
@@ -179,6 +163,7 @@ func (o *Output) flushMetrics() {
 	if len(samples) > 0 {
 		o.csvLock.Lock()
 		defer o.csvLock.Unlock()
+		o.rotate()
 		for _, sc := range samples {
 			for _, sample := range sc.GetSamples() {
 				sample := sample
@@ -193,6 +178,32 @@ func (o *Output) flushMetrics() {
 	}
 }
 
+func (o *Output) writeHeader() {
+	header := MakeHeader(o.resTags)
+	err := o.csvWriter.Write(header)
+	if err != nil {
+		o.logger.WithField("filename", o.fname).Error("CSV: Error writing column names to file")
+	}
+	o.csvWriter.Flush()
+}
+
+// rotate rotates the file before the samples are written, if it's time for it,
+// and starts the new one with the header.
+func (o *Output) rotate() {
+	if o.file == nil {
+		return
+	}
+	rotated, err := o.file.Rotate()
+	if err != nil {
+		o.logger.WithError(err).Error("CSV: Error rotating the file")
+		return
+	}
+	if rotated {
+		o.logger.WithField("filename", o.file.Name()).Debug("CSV: Rotated the file")
+		o.writeHeader()
+	}
+}
+
 // MakeHeader creates list of column names for csv file
 func MakeHeader(tags []string) []string {
 	tags = append(tags, "extra_tags")
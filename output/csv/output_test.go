@@ -317,3 +317,37 @@ func sortExtraTagsForTest(t *testing.T, input string) string {
 	w.Flush()
 	return b.String()
 }
+
+func TestRunRotated(t *testing.T) {
+	t.Parallel()
+
+	mem := afero.NewMemMapFs()
+	out, err := newOutput(output.Params{
+		Logger:         testutils.NewLogger(t),
+		FS:             mem,
+		ConfigArgument: "fileName=test.csv,saveInterval=50ms,rotateSize=60",
+		ScriptOptions: lib.Options{
+			SystemTags: stats.NewSystemTagSet(stats.TagError),
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, out.Start())
+
+	metric := stats.New("my_metric", stats.Gauge)
+	out.AddMetricSamples([]stats.SampleContainer{
+		stats.Sample{Time: time.Unix(1562324643, 0), Metric: metric, Value: 1, Tags: stats.NewSampleTags(nil)},
+	})
+	time.Sleep(100 * time.Millisecond)
+	out.AddMetricSamples([]stats.SampleContainer{
+		stats.Sample{Time: time.Unix(1562324644, 0), Metric: metric, Value: 2, Tags: stats.NewSampleTags(nil)},
+	})
+	require.NoError(t, out.Stop())
+
+	assert.Equal(t, "metric_name,timestamp,metric_value,error,extra_tags\nmy_metric,1562324643,1.000000,,\n",
+		readUnCompressedFile("test-0001.csv", mem))
+	assert.Equal(t, "metric_name,timestamp,metric_value,error,extra_tags\nmy_metric,1562324644,2.000000,,\n",
+		readUnCompressedFile("test-0002.csv", mem))
+	exists, err := afero.Exists(mem, "test-0003.csv")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
@@ -0,0 +1,214 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package output
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/spf13/afero"
+)
+
+// NullFileSize is a nullable size of a file in bytes, which can be written as a number of bytes or as a string
+// with one of the KB, MB and GB suffixes, which are powers of 1024, e.g. "100MB".
+type NullFileSize struct {
+	Int64 int64
+	Valid bool
+}
+
+// NullFileSizeFrom returns a new valid NullFileSize.
+func NullFileSizeFrom(size int64) NullFileSize {
+	return NullFileSize{Int64: size, Valid: true}
+}
+
+// UnmarshalText parses the size, an empty one is invalid.
+func (s *NullFileSize) UnmarshalText(data []byte) error {
+	text := strings.ToUpper(strings.TrimSpace(string(data)))
+	if text == "" {
+		*s = NullFileSize{}
+		return nil
+	}
+	multiplier := int64(1)
+	for _, suffix := range []struct {
+		unit       string
+		multiplier int64
+	}{{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30}, {"B", 1}} {
+		if strings.HasSuffix(text, suffix.unit) {
+			text, multiplier = strings.TrimSpace(strings.TrimSuffix(text, suffix.unit)), suffix.multiplier
+			break
+		}
+	}
+	size, err := strconv.ParseInt(text, 10, 64)
+	if err != nil || size < 0 {
+		return fmt.Errorf("invalid file size %q", string(data))
+	}
+	*s = NullFileSizeFrom(size * multiplier)
+	return nil
+}
+
+// UnmarshalJSON parses a number of bytes or a string with a unit.
+func (s *NullFileSize) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte(`null`)) {
+		*s = NullFileSize{}
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var text string
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+		return s.UnmarshalText([]byte(text))
+	}
+	return s.UnmarshalText(data)
+}
+
+// MarshalJSON returns the number of bytes, or null if it isn't valid.
+func (s NullFileSize) MarshalJSON() ([]byte, error) {
+	if !s.Valid {
+		return []byte(`null`), nil
+	}
+	return []byte(strconv.FormatInt(s.Int64, 10)), nil
+}
+
+// FileWriter writes to a file, which is compressed with gzip or zstd if its name ends with .gz or .zst. If a
+// maximum size or age are set, the file is rotated when Rotate is called after they are exceeded, and the files
+// are numbered before their extensions, e.g. results-0001.json.gz, results-0002.json.gz.
+//
+// The rotation is left to the outputs, so that they only rotate the files between their records.
+type FileWriter struct {
+	fs         afero.Fs
+	stem, ext  string
+	compressor func(io.Writer) (io.WriteCloser, error)
+	maxSize    int64
+	maxAge     time.Duration
+
+	index   int
+	name    string
+	file    afero.File
+	counter *countingWriter
+	w       io.Writer
+	closeFn func() error
+	opened  time.Time
+}
+
+type countingWriter struct {
+	w       io.Writer
+	written int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.written += int64(n)
+	return n, err
+}
+
+// NewFileWriter creates the first file. A maxSize or a maxAge of 0 disables the rotation by them.
+func NewFileWriter(fs afero.Fs, name string, maxSize int64, maxAge time.Duration) (*FileWriter, error) {
+	fw := &FileWriter{fs: fs, stem: name, maxSize: maxSize, maxAge: maxAge}
+	switch {
+	case strings.HasSuffix(name, ".gz"):
+		fw.compressor = func(w io.Writer) (io.WriteCloser, error) {
+			return gzip.NewWriter(w), nil
+		}
+	case strings.HasSuffix(name, ".zst"), strings.HasSuffix(name, ".zstd"):
+		fw.compressor = func(w io.Writer) (io.WriteCloser, error) {
+			return zstd.NewWriter(w)
+		}
+	}
+	if maxSize > 0 || maxAge > 0 {
+		// the number goes before all the extensions of the base name, so results.json.gz stays a .json.gz
+		dir, base := path.Split(name)
+		if i := strings.IndexByte(base, '.'); i > 0 {
+			fw.stem, fw.ext = dir+base[:i], base[i:]
+		}
+	}
+	if err := fw.open(); err != nil {
+		return nil, err
+	}
+	return fw, nil
+}
+
+func (fw *FileWriter) open() error {
+	fw.name = fw.stem + fw.ext
+	if fw.maxSize > 0 || fw.maxAge > 0 {
+		fw.index++
+		fw.name = fmt.Sprintf("%s-%04d%s", fw.stem, fw.index, fw.ext)
+	}
+	file, err := fw.fs.Create(fw.name)
+	if err != nil {
+		return err
+	}
+	fw.file, fw.counter, fw.opened = file, &countingWriter{w: file}, time.Now()
+	fw.w, fw.closeFn = fw.counter, file.Close
+	if fw.compressor != nil {
+		compressed, err := fw.compressor(fw.counter)
+		if err != nil {
+			_ = file.Close()
+			return err
+		}
+		fw.w = compressed
+		fw.closeFn = func() error {
+			if err := compressed.Close(); err != nil {
+				_ = file.Close()
+				return err
+			}
+			return file.Close()
+		}
+	}
+	return nil
+}
+
+// Name returns the name of the current file.
+func (fw *FileWriter) Name() string {
+	return fw.name
+}
+
+// Write writes to the current file.
+func (fw *FileWriter) Write(p []byte) (int, error) {
+	return fw.w.Write(p)
+}
+
+// Rotate closes the current file and creates the next one, if the current one has reached the maximum size
+// or age. It returns whether the file was rotated, so that the outputs can start the new one with their headers.
+// The size is the one written to the disk, so it lags behind for the compressed files, which are written in blocks.
+func (fw *FileWriter) Rotate() (bool, error) {
+	if (fw.maxSize <= 0 || fw.counter.written < fw.maxSize) &&
+		(fw.maxAge <= 0 || time.Since(fw.opened) < fw.maxAge) {
+		return false, nil
+	}
+	if err := fw.closeFn(); err != nil {
+		return false, err
+	}
+	return true, fw.open()
+}
+
+// Close flushes and closes the current file.
+func (fw *FileWriter) Close() error {
+	return fw.closeFn()
+}
@@ -0,0 +1,175 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package output
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNullFileSize(t *testing.T) {
+	t.Parallel()
+	for text, expected := range map[string]NullFileSize{
+		"":        {},
+		"100":     NullFileSizeFrom(100),
+		"100B":    NullFileSizeFrom(100),
+		"512kb":   NullFileSizeFrom(512 << 10),
+		"100MB":   NullFileSizeFrom(100 << 20),
+		" 2 GB ":  NullFileSizeFrom(2 << 30),
+		"0":       NullFileSizeFrom(0),
+		"1.5GB":   {Int64: -1},
+		"-1MB":    {Int64: -1},
+		"100TB":   {Int64: -1},
+		"hundred": {Int64: -1},
+	} {
+		var size NullFileSize
+		err := size.UnmarshalText([]byte(text))
+		if expected.Int64 < 0 {
+			assert.EqualError(t, err, `invalid file size "`+text+`"`)
+			continue
+		}
+		require.NoError(t, err, text)
+		assert.Equal(t, expected, size, text)
+	}
+
+	var sizes struct{ A, B, C NullFileSize }
+	require.NoError(t, json.Unmarshal([]byte(`{"A":1024,"B":"1KB","C":null}`), &sizes))
+	assert.Equal(t, NullFileSizeFrom(1024), sizes.A)
+	assert.Equal(t, NullFileSizeFrom(1024), sizes.B)
+	assert.False(t, sizes.C.Valid)
+	b, err := json.Marshal(sizes)
+	require.NoError(t, err)
+	assert.Equal(t, `{"A":1024,"B":1024,"C":null}`, string(b))
+}
+
+func readFile(t *testing.T, fs afero.Fs, name string) string {
+	t.Helper()
+	data, err := afero.ReadFile(fs, name)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestFileWriter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("plain", func(t *testing.T) {
+		t.Parallel()
+		fs := afero.NewMemMapFs()
+		fw, err := NewFileWriter(fs, "/results.json", 0, 0)
+		require.NoError(t, err)
+		_, err = fw.Write([]byte("line\n"))
+		require.NoError(t, err)
+		rotated, err := fw.Rotate()
+		require.NoError(t, err)
+		assert.False(t, rotated)
+		require.NoError(t, fw.Close())
+		assert.Equal(t, "/results.json", fw.Name())
+		assert.Equal(t, "line\n", readFile(t, fs, "/results.json"))
+	})
+
+	t.Run("rotated by size", func(t *testing.T) {
+		t.Parallel()
+		fs := afero.NewMemMapFs()
+		fw, err := NewFileWriter(fs, "/out/results.json", 10, 0)
+		require.NoError(t, err)
+		assert.Equal(t, "/out/results-0001.json", fw.Name())
+
+		_, err = fw.Write([]byte("first\n"))
+		require.NoError(t, err)
+		rotated, err := fw.Rotate()
+		require.NoError(t, err)
+		assert.False(t, rotated)
+		_, err = fw.Write([]byte("second\n"))
+		require.NoError(t, err)
+		rotated, err = fw.Rotate()
+		require.NoError(t, err)
+		assert.True(t, rotated)
+		assert.Equal(t, "/out/results-0002.json", fw.Name())
+		_, err = fw.Write([]byte("third\n"))
+		require.NoError(t, err)
+		require.NoError(t, fw.Close())
+
+		assert.Equal(t, "first\nsecond\n", readFile(t, fs, "/out/results-0001.json"))
+		assert.Equal(t, "third\n", readFile(t, fs, "/out/results-0002.json"))
+	})
+
+	t.Run("gzip", func(t *testing.T) {
+		t.Parallel()
+		fs := afero.NewMemMapFs()
+		fw, err := NewFileWriter(fs, "/results.json.gz", 0, 0)
+		require.NoError(t, err)
+		_, err = fw.Write([]byte("line\n"))
+		require.NoError(t, err)
+		require.NoError(t, fw.Close())
+
+		f, err := fs.Open("/results.json.gz")
+		require.NoError(t, err)
+		r, err := gzip.NewReader(f)
+		require.NoError(t, err)
+		data, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+		assert.Equal(t, "line\n", string(data))
+	})
+
+	t.Run("zstd rotated by age", func(t *testing.T) {
+		t.Parallel()
+		fs := afero.NewMemMapFs()
+		fw, err := NewFileWriter(fs, "/results.csv.zst", 0, 50*time.Millisecond)
+		require.NoError(t, err)
+		_, err = fw.Write([]byte("a,b\n"))
+		require.NoError(t, err)
+		rotated, err := fw.Rotate()
+		require.NoError(t, err)
+		assert.False(t, rotated)
+		time.Sleep(60 * time.Millisecond)
+		rotated, err = fw.Rotate()
+		require.NoError(t, err)
+		assert.True(t, rotated)
+		require.NoError(t, fw.Close())
+
+		decoder, err := zstd.NewReader(nil)
+		require.NoError(t, err)
+		defer decoder.Close()
+		data, err := decoder.DecodeAll([]byte(readFile(t, fs, "/results-0001.csv.zst")), nil)
+		require.NoError(t, err)
+		assert.Equal(t, "a,b\n", string(data))
+		data, err = decoder.DecodeAll([]byte(readFile(t, fs, "/results-0002.csv.zst")), nil)
+		require.NoError(t, err)
+		assert.Empty(t, data)
+	})
+
+	t.Run("without an extension", func(t *testing.T) {
+		t.Parallel()
+		fs := afero.NewMemMapFs()
+		fw, err := NewFileWriter(fs, "/my.dir/results", 1, 0)
+		require.NoError(t, err)
+		assert.Equal(t, "/my.dir/results-0001", fw.Name())
+		require.NoError(t, fw.Close())
+	})
+}
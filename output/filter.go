@@ -0,0 +1,264 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/stats"
+)
+
+// FilterConfig selects the metrics and the tags of the samples that are sent to an output. It's a part of the JSON
+// config of every output, and it can be set with the K6_<OUTPUT>_INCLUDE_METRICS, K6_<OUTPUT>_EXCLUDE_METRICS,
+// K6_<OUTPUT>_INCLUDE_TAGS and K6_<OUTPUT>_EXCLUDE_TAGS env vars as comma separated lists, e.g.
+// K6_CSV_EXCLUDE_TAGS=url,name.
+//
+// The metrics are matched with the patterns of path.Match, e.g. http_req_*. If there are included metrics, only
+// the samples of the ones that match them are sent, without the ones that match the excluded metrics. If there
+// are included tags, only they are sent, without the excluded ones.
+type FilterConfig struct {
+	IncludeMetrics []string `json:"includeMetrics,omitempty"`
+	ExcludeMetrics []string `json:"excludeMetrics,omitempty"`
+	IncludeTags    []string `json:"includeTags,omitempty"`
+	ExcludeTags    []string `json:"excludeTags,omitempty"`
+}
+
+// IsEmpty returns whether the config doesn't filter anything.
+func (c FilterConfig) IsEmpty() bool {
+	return len(c.IncludeMetrics) == 0 && len(c.ExcludeMetrics) == 0 &&
+		len(c.IncludeTags) == 0 && len(c.ExcludeTags) == 0
+}
+
+// GetFilterConfig returns the filter config of the output from its JSON config, with the lists of its env vars
+// overwriting the ones of the JSON config.
+func GetFilterConfig(outputType string, jsonRawConf json.RawMessage, env map[string]string) (FilterConfig, error) {
+	c := FilterConfig{}
+	if jsonRawConf != nil {
+		if err := json.Unmarshal(jsonRawConf, &c); err != nil {
+			return c, err
+		}
+	}
+
+	prefix := "K6_" + strings.ToUpper(outputType) + "_"
+	for suffix, list := range map[string]*[]string{
+		"INCLUDE_METRICS": &c.IncludeMetrics,
+		"EXCLUDE_METRICS": &c.ExcludeMetrics,
+		"INCLUDE_TAGS":    &c.IncludeTags,
+		"EXCLUDE_TAGS":    &c.ExcludeTags,
+	} {
+		if v, ok := env[prefix+suffix]; ok {
+			*list = nil
+			for _, item := range strings.Split(v, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					*list = append(*list, item)
+				}
+			}
+		}
+	}
+
+	for _, pattern := range append(append([]string{}, c.IncludeMetrics...), c.ExcludeMetrics...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return c, fmt.Errorf("invalid metric pattern '%s': %w", pattern, err)
+		}
+	}
+	return c, nil
+}
+
+// FilteredOutput wraps an output and sends it only the samples and the tags that are selected by its filter config.
+// It implements all the optional interfaces of the outputs and forwards their calls to the wrapped output if it
+// implements them too.
+//
+// The containers that are changed are sent as a stats.Sample if they were one, as stats.ConnectedSamples if they
+// were connected, or as stats.Samples otherwise.
+type FilteredOutput struct {
+	Output
+	config      FilterConfig
+	includeTags map[string]bool
+	excludeTags map[string]bool
+}
+
+var (
+	_ WithThresholds       = &FilteredOutput{}
+	_ WithTestRunStop      = &FilteredOutput{}
+	_ WithRunStatusUpdates = &FilteredOutput{}
+	_ WithBuiltinMetrics   = &FilteredOutput{}
+	_ WithBufferLimit      = &FilteredOutput{}
+)
+
+// NewFilteredOutput wraps the output with the filter config.
+func NewFilteredOutput(out Output, config FilterConfig) *FilteredOutput {
+	toSet := func(list []string) map[string]bool {
+		if len(list) == 0 {
+			return nil
+		}
+		set := make(map[string]bool, len(list))
+		for _, item := range list {
+			set[item] = true
+		}
+		return set
+	}
+	return &FilteredOutput{
+		Output:      out,
+		config:      config,
+		includeTags: toSet(config.IncludeTags),
+		excludeTags: toSet(config.ExcludeTags),
+	}
+}
+
+// Unwrap returns the wrapped output.
+func (o *FilteredOutput) Unwrap() Output {
+	return o.Output
+}
+
+// SetThresholds forwards the thresholds to the wrapped output.
+func (o *FilteredOutput) SetThresholds(thresholds map[string]stats.Thresholds) {
+	if out, ok := o.Output.(WithThresholds); ok {
+		out.SetThresholds(thresholds)
+	}
+}
+
+// SetTestRunStopCallback forwards the callback to the wrapped output.
+func (o *FilteredOutput) SetTestRunStopCallback(callback func(error)) {
+	if out, ok := o.Output.(WithTestRunStop); ok {
+		out.SetTestRunStopCallback(callback)
+	}
+}
+
+// SetRunStatus forwards the status to the wrapped output.
+func (o *FilteredOutput) SetRunStatus(latestStatus lib.RunStatus) {
+	if out, ok := o.Output.(WithRunStatusUpdates); ok {
+		out.SetRunStatus(latestStatus)
+	}
+}
+
+// SetBuiltinMetrics forwards the builtin metrics to the wrapped output.
+func (o *FilteredOutput) SetBuiltinMetrics(builtinMetrics *metrics.BuiltinMetrics) {
+	if out, ok := o.Output.(WithBuiltinMetrics); ok {
+		out.SetBuiltinMetrics(builtinMetrics)
+	}
+}
+
+// SetBufferLimit forwards the limit to the wrapped output.
+func (o *FilteredOutput) SetBufferLimit(limit BufferLimit) {
+	if out, ok := o.Output.(WithBufferLimit); ok {
+		out.SetBufferLimit(limit)
+	}
+}
+
+// BufferStatus returns the status of the buffer of the wrapped output, which is empty if it can't be limited.
+func (o *FilteredOutput) BufferStatus() BufferStatus {
+	if out, ok := o.Output.(WithBufferLimit); ok {
+		return out.BufferStatus()
+	}
+	return BufferStatus{}
+}
+
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		// the patterns were validated by GetFilterConfig
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *FilteredOutput) includesMetric(name string) bool {
+	if len(o.config.IncludeMetrics) > 0 && !matchesAny(o.config.IncludeMetrics, name) {
+		return false
+	}
+	return !matchesAny(o.config.ExcludeMetrics, name)
+}
+
+// filterTags returns the tags without the filtered ones, and whether some were filtered.
+func (o *FilteredOutput) filterTags(tags *stats.SampleTags, cache map[*stats.SampleTags]*stats.SampleTags) (
+	*stats.SampleTags, bool,
+) {
+	if tags == nil || (o.includeTags == nil && o.excludeTags == nil) {
+		return tags, false
+	}
+	if filtered, ok := cache[tags]; ok {
+		return filtered, filtered != tags
+	}
+	all := tags.CloneTags()
+	changed := false
+	for k := range all {
+		if (o.includeTags != nil && !o.includeTags[k]) || o.excludeTags[k] {
+			delete(all, k)
+			changed = true
+		}
+	}
+	filtered := tags
+	if changed {
+		filtered = stats.IntoSampleTags(&all)
+	}
+	cache[tags] = filtered
+	return filtered, changed
+}
+
+func isSample(container stats.SampleContainer) bool {
+	_, ok := container.(stats.Sample)
+	return ok
+}
+
+// AddMetricSamples filters the samples and sends the remaining ones to the wrapped output.
+func (o *FilteredOutput) AddMetricSamples(containers []stats.SampleContainer) {
+	result := make([]stats.SampleContainer, 0, len(containers))
+	cache := make(map[*stats.SampleTags]*stats.SampleTags)
+	for _, container := range containers {
+		samples := container.GetSamples()
+		filtered := make([]stats.Sample, 0, len(samples))
+		changed := false
+		for _, sample := range samples {
+			if !o.includesMetric(sample.Metric.Name) {
+				changed = true
+				continue
+			}
+			var tagsChanged bool
+			sample.Tags, tagsChanged = o.filterTags(sample.Tags, cache)
+			changed = changed || tagsChanged
+			filtered = append(filtered, sample)
+		}
+
+		switch {
+		case !changed:
+			result = append(result, container)
+		case len(filtered) == 0:
+		case len(filtered) == 1 && isSample(container):
+			result = append(result, filtered[0])
+		default:
+			if connected, ok := container.(stats.ConnectedSampleContainer); ok {
+				tags, _ := o.filterTags(connected.GetTags(), cache)
+				result = append(result, stats.ConnectedSamples{Samples: filtered, Tags: tags, Time: connected.GetTime()})
+			} else {
+				result = append(result, stats.Samples(filtered))
+			}
+		}
+	}
+	if len(result) > 0 {
+		o.Output.AddMetricSamples(result)
+	}
+}
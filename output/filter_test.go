@@ -0,0 +1,138 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package output
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/stats"
+)
+
+type recordingOutput struct {
+	SampleBuffer
+	status lib.RunStatus
+}
+
+func (o *recordingOutput) Description() string { return "recording" }
+func (o *recordingOutput) Start() error        { return nil }
+func (o *recordingOutput) Stop() error         { return nil }
+
+func (o *recordingOutput) SetRunStatus(latestStatus lib.RunStatus) {
+	o.status = latestStatus
+}
+
+func TestGetFilterConfig(t *testing.T) {
+	t.Parallel()
+
+	c, err := GetFilterConfig("csv",
+		[]byte(`{"fileName":"file.csv","includeMetrics":["http_*"],"excludeTags":["url"]}`),
+		map[string]string{"K6_CSV_EXCLUDE_TAGS": "url, name,", "K6_JSON_INCLUDE_TAGS": "status"})
+	require.NoError(t, err)
+	assert.Equal(t, FilterConfig{IncludeMetrics: []string{"http_*"}, ExcludeTags: []string{"url", "name"}}, c)
+	assert.False(t, c.IsEmpty())
+
+	c, err = GetFilterConfig("csv", nil, nil)
+	require.NoError(t, err)
+	assert.True(t, c.IsEmpty())
+
+	_, err = GetFilterConfig("csv", nil, map[string]string{"K6_CSV_EXCLUDE_METRICS": "http_[req"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid metric pattern 'http_[req'")
+}
+
+func TestFilteredOutput(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	reqs := stats.New("http_reqs", stats.Counter)
+	duration := stats.New("http_req_duration", stats.Trend)
+	iterations := stats.New("iterations", stats.Counter)
+	tags := stats.NewSampleTags(map[string]string{"url": "http://test.k6.io", "name": "home", "status": "200"})
+	sample := func(m *stats.Metric) stats.Sample {
+		return stats.Sample{Metric: m, Time: now, Value: 1, Tags: tags}
+	}
+	untouched := stats.Sample{Metric: iterations, Time: now, Value: 1, Tags: stats.NewSampleTags(nil)}
+	containers := []stats.SampleContainer{
+		stats.ConnectedSamples{Samples: []stats.Sample{sample(reqs), sample(duration)}, Tags: tags, Time: now},
+		sample(duration),
+		untouched,
+	}
+
+	t.Run("metrics", func(t *testing.T) {
+		t.Parallel()
+		inner := &recordingOutput{}
+		out := NewFilteredOutput(inner, FilterConfig{
+			IncludeMetrics: []string{"http_*", "iterations"},
+			ExcludeMetrics: []string{"*_duration"},
+		})
+		out.AddMetricSamples(containers)
+		assert.Equal(t, []stats.SampleContainer{
+			stats.ConnectedSamples{Samples: []stats.Sample{sample(reqs)}, Tags: tags, Time: now},
+			untouched,
+		}, inner.GetBufferedSamples())
+
+		out.AddMetricSamples([]stats.SampleContainer{sample(duration)})
+		assert.Empty(t, inner.GetBufferedSamples())
+	})
+
+	t.Run("tags", func(t *testing.T) {
+		t.Parallel()
+		inner := &recordingOutput{}
+		out := NewFilteredOutput(inner, FilterConfig{IncludeTags: []string{"name", "status"}, ExcludeTags: []string{"name"}})
+		out.AddMetricSamples(containers)
+		buffered := inner.GetBufferedSamples()
+		require.Len(t, buffered, 3)
+
+		trimmed := map[string]string{"status": "200"}
+		connected, ok := buffered[0].(stats.ConnectedSamples)
+		require.True(t, ok)
+		assert.Equal(t, trimmed, connected.Tags.CloneTags())
+		require.Len(t, connected.Samples, 2)
+		for _, s := range connected.Samples {
+			assert.Equal(t, trimmed, s.Tags.CloneTags())
+		}
+		single, ok := buffered[1].(stats.Sample)
+		require.True(t, ok)
+		assert.Equal(t, trimmed, single.Tags.CloneTags())
+		assert.Equal(t, untouched, buffered[2])
+
+		// the original tags aren't changed
+		assert.Len(t, tags.CloneTags(), 3)
+	})
+
+	t.Run("optional interfaces", func(t *testing.T) {
+		t.Parallel()
+		inner := &recordingOutput{}
+		out := NewFilteredOutput(inner, FilterConfig{ExcludeTags: []string{"url"}})
+		out.SetRunStatus(lib.RunStatusFinished)
+		assert.Equal(t, lib.RunStatusFinished, inner.status)
+		assert.Equal(t, Output(inner), out.Unwrap())
+		// the inner output doesn't implement them, so they are ignored
+		out.SetThresholds(nil)
+		out.SetTestRunStopCallback(nil)
+		out.SetBuiltinMetrics(nil)
+	})
+}
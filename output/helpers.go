@@ -36,16 +36,106 @@ type SampleBuffer struct {
 	sync.Mutex
 	buffer []stats.SampleContainer
 	maxLen int
+
+	// limit is set by SetBufferLimit, the buffered samples are only counted then
+	limit           BufferLimit
+	bufferedSamples int64
+	droppedSamples  int64
+	// flushed is closed and replaced by every flush, stopped is closed when the flusher of the buffer is stopped
+	flushed chan struct{}
+	stopped chan struct{}
 }
 
-// AddMetricSamples adds the given metric samples to the internal buffer.
+// AddMetricSamples adds the given metric samples to the internal buffer. If the buffer is limited, the containers
+// that don't fit in it wait for the next flush or are dropped, depending on the overflow policy of the limit. The
+// waiting ones are dropped too when the block timeout passes or the flusher of the buffer is stopped.
 func (sc *SampleBuffer) AddMetricSamples(samples []stats.SampleContainer) {
 	if len(samples) == 0 {
 		return
 	}
 	sc.Lock()
-	sc.buffer = append(sc.buffer, samples...)
-	sc.Unlock()
+	defer sc.Unlock()
+	if sc.limit.MaxSamples <= 0 {
+		sc.buffer = append(sc.buffer, samples...)
+		return
+	}
+
+	for _, container := range samples {
+		n := int64(len(container.GetSamples()))
+		fits := sc.fits(n)
+		if !fits && sc.limit.Overflow == OverflowBlock {
+			fits = sc.waitToFit(n)
+		}
+		if !fits {
+			sc.droppedSamples += n
+			continue
+		}
+		sc.buffer = append(sc.buffer, container)
+		sc.bufferedSamples += n
+	}
+}
+
+// waitToFit waits for the flushes of the buffer until the samples fit in it, it returns false if they still don't
+// when the block timeout passes or the flusher is stopped. It needs to be called with the lock held.
+func (sc *SampleBuffer) waitToFit(n int64) bool {
+	timeout := DefaultBlockTimeout
+	if sc.limit.BlockTimeout.Valid {
+		timeout = sc.limit.BlockTimeout.TimeDuration()
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for !sc.fits(n) {
+		flushed := sc.flushed
+		sc.Unlock()
+		select {
+		case <-flushed:
+			sc.Lock()
+		case <-sc.stopped:
+			sc.Lock()
+			return false
+		case <-timer.C:
+			sc.Lock()
+			return sc.fits(n)
+		}
+	}
+	return true
+}
+
+// fits returns whether the samples fit in the limited buffer, the containers that are bigger
+// than the limit only fit when the buffer is empty.
+func (sc *SampleBuffer) fits(n int64) bool {
+	return sc.bufferedSamples == 0 || sc.bufferedSamples+n <= sc.limit.MaxSamples
+}
+
+// SetBufferLimit limits the samples in the buffer, it needs to be called before the first samples are added.
+func (sc *SampleBuffer) SetBufferLimit(limit BufferLimit) {
+	sc.Lock()
+	defer sc.Unlock()
+	sc.limit = limit
+	sc.flushed = make(chan struct{})
+	sc.stopped = make(chan struct{})
+}
+
+// stop makes the samples that don't fit in the buffer to be dropped instead of waiting for flushes.
+func (sc *SampleBuffer) stop() {
+	sc.Lock()
+	defer sc.Unlock()
+	if sc.stopped == nil {
+		return
+	}
+	select {
+	case <-sc.stopped:
+	default:
+		close(sc.stopped)
+	}
+}
+
+// BufferStatus returns the limit of the buffer with the numbers of samples in it and of the dropped ones.
+func (sc *SampleBuffer) BufferStatus() BufferStatus {
+	sc.Lock()
+	defer sc.Unlock()
+	return BufferStatus{Limit: sc.limit, Buffered: sc.bufferedSamples, Dropped: sc.droppedSamples}
 }
 
 // GetBufferedSamples returns the currently buffered metric samples and makes a
@@ -65,10 +155,42 @@ func (sc *SampleBuffer) GetBufferedSamples() []stats.SampleContainer {
 	// Make the new buffer halfway between the previously allocated size and the
 	// maximum buffer size we've seen so far, to hopefully reduce copying a bit.
 	sc.buffer = make([]stats.SampleContainer, 0, (bufferedLen+sc.maxLen)/2)
+	if sc.flushed != nil {
+		sc.bufferedSamples = 0
+		close(sc.flushed)
+		sc.flushed = make(chan struct{})
+	}
 
 	return buffered
 }
 
+// NewPeriodicFlusher creates a PeriodicFlusher for the buffer and starts its goroutine. The flush interval and the
+// max in-flight batches of the buffer limit overwrite the given period and the sequential flushes, when they are
+// set, and stopping the flusher drops the samples that wait for a flush of the full buffer.
+func (sc *SampleBuffer) NewPeriodicFlusher(period time.Duration, flushCallback func()) (*PeriodicFlusher, error) {
+	sc.Lock()
+	limit := sc.limit
+	sc.Unlock()
+	if limit.FlushInterval.Valid {
+		period = limit.FlushInterval.TimeDuration()
+	}
+	return newPeriodicFlusher(period, flushCallback, limit.MaxInFlightBatches, sc.stop)
+}
+
+// NewSequentialPeriodicFlusher is like NewPeriodicFlusher, but the flushes never overlap, for the outputs which
+// write the batches to a single file or stream. With more than one max in-flight batch, the next flushes wait for
+// the running one, instead of the ticks being skipped.
+func (sc *SampleBuffer) NewSequentialPeriodicFlusher(
+	period time.Duration, flushCallback func(),
+) (*PeriodicFlusher, error) {
+	var flushLock sync.Mutex
+	return sc.NewPeriodicFlusher(period, func() {
+		flushLock.Lock()
+		defer flushLock.Unlock()
+		flushCallback()
+	})
+}
+
 // PeriodicFlusher is a small helper for asynchronously flushing buffered metric
 // samples on regular intervals. The biggest benefit is having a Stop() method
 // that waits for one last flush before it returns.
@@ -78,16 +200,24 @@ type PeriodicFlusher struct {
 	stop          chan struct{}
 	stopped       chan struct{}
 	once          *sync.Once
+	// inFlight limits the concurrent flushes, they are run one after the other when it's nil
+	inFlight chan struct{}
+	onStop   func()
 }
 
 func (pf *PeriodicFlusher) run() {
 	ticker := time.NewTicker(pf.period)
 	defer ticker.Stop()
+	wg := &sync.WaitGroup{}
 	for {
 		select {
 		case <-ticker.C:
-			pf.flushCallback()
+			pf.flush(wg)
 		case <-pf.stop:
+			wg.Wait()
+			if pf.onStop != nil {
+				pf.onStop()
+			}
 			pf.flushCallback()
 			close(pf.stopped)
 			return
@@ -95,6 +225,26 @@ func (pf *PeriodicFlusher) run() {
 	}
 }
 
+func (pf *PeriodicFlusher) flush(wg *sync.WaitGroup) {
+	if pf.inFlight == nil {
+		pf.flushCallback()
+		return
+	}
+	select {
+	case pf.inFlight <- struct{}{}:
+	default:
+		return // all the batches are in flight, the samples are flushed on the next tick
+	}
+	wg.Add(1)
+	go func() {
+		defer func() {
+			<-pf.inFlight
+			wg.Done()
+		}()
+		pf.flushCallback()
+	}()
+}
+
 // Stop waits for the periodic flusher flush one last time and exit. You can
 // safely call Stop() multiple times from different goroutines, you just can't
 // call it from inside of the flushing function.
@@ -107,6 +257,12 @@ func (pf *PeriodicFlusher) Stop() {
 
 // NewPeriodicFlusher creates a new PeriodicFlusher and starts its goroutine.
 func NewPeriodicFlusher(period time.Duration, flushCallback func()) (*PeriodicFlusher, error) {
+	return newPeriodicFlusher(period, flushCallback, 0, nil)
+}
+
+func newPeriodicFlusher(
+	period time.Duration, flushCallback func(), maxInFlight int64, onStop func(),
+) (*PeriodicFlusher, error) {
 	if period <= 0 {
 		return nil, fmt.Errorf("metric flush period should be positive but was %s", period)
 	}
@@ -117,6 +273,10 @@ func NewPeriodicFlusher(period time.Duration, flushCallback func()) (*PeriodicFl
 		stop:          make(chan struct{}),
 		stopped:       make(chan struct{}),
 		once:          &sync.Once{},
+		onStop:        onStop,
+	}
+	if maxInFlight > 1 {
+		pf.inFlight = make(chan struct{}, maxInFlight)
 	}
 
 	go pf.run()
@@ -23,12 +23,14 @@ package output
 import (
 	"math/rand"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"go.k6.io/k6/lib/types"
 	"go.k6.io/k6/stats"
 )
 
@@ -182,3 +184,158 @@ func TestPeriodicFlusherConcurrency(t *testing.T) {
 	stopWG.Wait()
 	assert.True(t, count >= 101) // due to the short intervals, we might not get exactly 101
 }
+
+func TestSampleBufferLimit(t *testing.T) {
+	t.Parallel()
+	single := stats.Sample{Time: time.Now(), Metric: stats.New("my_metric", stats.Counter), Value: 1}
+	connected := stats.ConnectedSamples{Samples: []stats.Sample{single, single, single}, Time: single.Time}
+
+	t.Run("drop", func(t *testing.T) {
+		t.Parallel()
+		buffer := SampleBuffer{}
+		buffer.SetBufferLimit(BufferLimit{Output: "test", MaxSamples: 3, Overflow: OverflowDrop})
+		buffer.AddMetricSamples([]stats.SampleContainer{single, connected, single, single})
+		assert.Equal(t, BufferStatus{
+			Limit:    BufferLimit{Output: "test", MaxSamples: 3, Overflow: OverflowDrop},
+			Buffered: 3,
+			Dropped:  3,
+		}, buffer.BufferStatus())
+		assert.Equal(t, []stats.SampleContainer{single, single, single}, buffer.GetBufferedSamples())
+
+		// the containers that are bigger than the limit are buffered when the buffer is empty
+		big := stats.ConnectedSamples{Samples: []stats.Sample{single, single, single, single}}
+		buffer.AddMetricSamples([]stats.SampleContainer{big, single})
+		assert.Equal(t, []stats.SampleContainer{big}, buffer.GetBufferedSamples())
+		assert.Equal(t, int64(4), buffer.BufferStatus().Dropped)
+	})
+
+	t.Run("block", func(t *testing.T) {
+		t.Parallel()
+		buffer := SampleBuffer{}
+		buffer.SetBufferLimit(BufferLimit{MaxSamples: 3, Overflow: OverflowBlock})
+		added := make(chan struct{})
+		go func() {
+			defer close(added)
+			buffer.AddMetricSamples([]stats.SampleContainer{connected, single})
+		}()
+
+		select {
+		case <-added:
+			t.Fatal("the samples were added to the full buffer")
+		case <-time.After(50 * time.Millisecond):
+		}
+		assert.Equal(t, int64(3), buffer.BufferStatus().Buffered)
+		assert.Equal(t, []stats.SampleContainer{connected}, buffer.GetBufferedSamples())
+		<-added
+		assert.Equal(t, []stats.SampleContainer{single}, buffer.GetBufferedSamples())
+		assert.Equal(t, int64(0), buffer.BufferStatus().Dropped)
+	})
+
+	t.Run("block timeout", func(t *testing.T) {
+		t.Parallel()
+		buffer := SampleBuffer{}
+		buffer.SetBufferLimit(BufferLimit{
+			MaxSamples: 3, Overflow: OverflowBlock, BlockTimeout: types.NullDurationFrom(50 * time.Millisecond),
+		})
+		start := time.Now()
+		buffer.AddMetricSamples([]stats.SampleContainer{connected, single})
+		assert.True(t, time.Since(start) >= 50*time.Millisecond)
+		assert.Equal(t, int64(1), buffer.BufferStatus().Dropped)
+		assert.Equal(t, []stats.SampleContainer{connected}, buffer.GetBufferedSamples())
+	})
+
+	t.Run("block stopped", func(t *testing.T) {
+		t.Parallel()
+		buffer := SampleBuffer{}
+		buffer.SetBufferLimit(BufferLimit{MaxSamples: 3, Overflow: OverflowBlock})
+		f, err := buffer.NewPeriodicFlusher(time.Hour, func() {})
+		require.NoError(t, err)
+		added := make(chan struct{})
+		go func() {
+			defer close(added)
+			buffer.AddMetricSamples([]stats.SampleContainer{connected, single})
+		}()
+
+		select {
+		case <-added:
+			t.Fatal("the samples were added to the full buffer")
+		case <-time.After(50 * time.Millisecond):
+		}
+		f.Stop()
+		select {
+		case <-added:
+		case <-time.After(time.Second):
+			t.Fatal("stopping the flusher didn't release the blocked samples")
+		}
+		assert.Equal(t, int64(1), buffer.BufferStatus().Dropped)
+	})
+}
+
+func TestSampleBufferPeriodicFlusher(t *testing.T) {
+	t.Parallel()
+
+	t.Run("flush interval", func(t *testing.T) {
+		t.Parallel()
+		buffer := SampleBuffer{}
+		buffer.SetBufferLimit(BufferLimit{FlushInterval: types.NullDurationFrom(time.Millisecond)})
+		flushed := make(chan struct{}, 1)
+		f, err := buffer.NewPeriodicFlusher(time.Hour, func() {
+			select {
+			case flushed <- struct{}{}:
+			default:
+			}
+		})
+		require.NoError(t, err)
+		defer f.Stop()
+		select {
+		case <-flushed:
+		case <-time.After(time.Second):
+			t.Fatal("the flush interval of the limit wasn't used")
+		}
+	})
+
+	t.Run("max in-flight batches", func(t *testing.T) {
+		t.Parallel()
+		buffer := SampleBuffer{}
+		buffer.SetBufferLimit(BufferLimit{MaxInFlightBatches: 3})
+		var mu sync.Mutex
+		var inFlight, maxInFlight int
+		release := make(chan struct{})
+		f, err := buffer.NewPeriodicFlusher(time.Millisecond, func() {
+			mu.Lock()
+			inFlight++
+			if inFlight > maxInFlight {
+				maxInFlight = inFlight
+			}
+			mu.Unlock()
+			<-release
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		})
+		require.NoError(t, err)
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		f.Stop()
+		assert.Equal(t, 3, maxInFlight)
+	})
+	t.Run("sequential", func(t *testing.T) {
+		t.Parallel()
+		buffer := SampleBuffer{}
+		buffer.SetBufferLimit(BufferLimit{MaxInFlightBatches: 3})
+		var inFlight, maxInFlight, flushes int64
+		f, err := buffer.NewSequentialPeriodicFlusher(time.Millisecond, func() {
+			if n := atomic.AddInt64(&inFlight, 1); n > atomic.LoadInt64(&maxInFlight) {
+				atomic.StoreInt64(&maxInFlight, n)
+			}
+			atomic.AddInt64(&flushes, 1)
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt64(&inFlight, -1)
+		})
+		require.NoError(t, err)
+		time.Sleep(50 * time.Millisecond)
+		f.Stop()
+		assert.Equal(t, int64(1), atomic.LoadInt64(&maxInFlight))
+		assert.Greater(t, atomic.LoadInt64(&flushes), int64(1))
+	})
+}
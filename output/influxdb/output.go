@@ -184,7 +184,7 @@ func (o *Output) Start() error {
 		o.logger.WithError(err).Debug("Couldn't create database; most likely harmless")
 	}
 
-	pf, err := output.NewPeriodicFlusher(o.Config.PushInterval.TimeDuration(), o.flushMetrics)
+	pf, err := o.NewPeriodicFlusher(o.Config.PushInterval.TimeDuration(), o.flushMetrics)
 	if err != nil {
 		return err
 	}
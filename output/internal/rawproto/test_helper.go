@@ -0,0 +1,88 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package rawproto
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Message is a decoded protobuf message for the tests, with the values of its fields by their numbers,
+// which are the bytes of the length-delimited fields and the uint64 of the others.
+type Message map[protowire.Number][]interface{}
+
+// Decode decodes a message, without knowing its type.
+func Decode(t *testing.T, b []byte) Message {
+	m := Message{}
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		require.GreaterOrEqual(t, n, 0)
+		b = b[n:]
+		var v interface{}
+		switch typ {
+		case protowire.BytesType:
+			v, n = protowire.ConsumeBytes(b)
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			v, n = protowire.ConsumeFixed64(b)
+		default:
+			t.Fatalf("unexpected wire type %d", typ)
+		}
+		require.GreaterOrEqual(t, n, 0)
+		b = b[n:]
+		m[num] = append(m[num], v)
+	}
+	return m
+}
+
+// Messages decodes the embedded messages of a field.
+func (m Message) Messages(t *testing.T, num protowire.Number) []Message {
+	res := make([]Message, 0, len(m[num]))
+	for _, v := range m[num] {
+		res = append(res, Decode(t, v.([]byte)))
+	}
+	return res
+}
+
+// String returns the first value of a string field, or "" if it's missing.
+func (m Message) String(num protowire.Number) string {
+	if len(m[num]) == 0 {
+		return ""
+	}
+	return string(m[num][0].([]byte))
+}
+
+// Uint returns the first value of a varint or fixed64 field, or 0 if it's missing.
+func (m Message) Uint(num protowire.Number) uint64 {
+	if len(m[num]) == 0 {
+		return 0
+	}
+	return m[num][0].(uint64)
+}
+
+// Double returns the first value of a double field, or 0 if it's missing.
+func (m Message) Double(num protowire.Number) float64 {
+	return math.Float64frombits(m.Uint(num))
+}
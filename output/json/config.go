@@ -0,0 +1,120 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2021 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package json
+
+import (
+	stdlibjson "encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mstoykov/envconfig"
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/output"
+)
+
+// Config is the config for the json output
+type Config struct {
+	// FileName is the file the samples are written to, they are written to the stdout if it's empty or "-"
+	FileName null.String `json:"fileName" envconfig:"K6_JSON_FILENAME"`
+	// The file is rotated when it's bigger than RotateSize or older than RotateInterval, if they are set
+	RotateSize     output.NullFileSize `json:"rotateSize" envconfig:"K6_JSON_ROTATE_SIZE"`
+	RotateInterval types.NullDuration  `json:"rotateInterval" envconfig:"K6_JSON_ROTATE_INTERVAL"`
+}
+
+// Apply merges two configs by overwriting properties in the old config
+func (c Config) Apply(cfg Config) Config {
+	if cfg.FileName.Valid {
+		c.FileName = cfg.FileName
+	}
+	if cfg.RotateSize.Valid {
+		c.RotateSize = cfg.RotateSize
+	}
+	if cfg.RotateInterval.Valid {
+		c.RotateInterval = cfg.RotateInterval
+	}
+	return c
+}
+
+// ParseArg takes an arg string, which is either the file name or key=value pairs, and converts it to a config
+func ParseArg(arg string) (Config, error) {
+	c := Config{}
+
+	if !strings.Contains(arg, "=") {
+		c.FileName = null.StringFrom(arg)
+		return c, nil
+	}
+
+	for _, pair := range strings.Split(arg, ",") {
+		r := strings.SplitN(pair, "=", 2)
+		if len(r) != 2 {
+			return c, fmt.Errorf("couldn't parse %q as argument for json output", arg)
+		}
+		switch r[0] {
+		case "fileName":
+			c.FileName = null.StringFrom(r[1])
+		case "rotateSize":
+			if err := c.RotateSize.UnmarshalText([]byte(r[1])); err != nil {
+				return c, err
+			}
+		case "rotateInterval":
+			if err := c.RotateInterval.UnmarshalText([]byte(r[1])); err != nil {
+				return c, err
+			}
+		default:
+			return c, fmt.Errorf("unknown key %q as argument for json output", r[0])
+		}
+	}
+
+	return c, nil
+}
+
+// GetConsolidatedConfig combines {JSON config + environment vars + arg config values}, and returns the final result.
+func GetConsolidatedConfig(jsonRawConf stdlibjson.RawMessage, env map[string]string, arg string) (Config, error) {
+	result := Config{}
+	if jsonRawConf != nil {
+		jsonConf := Config{}
+		if err := stdlibjson.Unmarshal(jsonRawConf, &jsonConf); err != nil {
+			return result, err
+		}
+		result = result.Apply(jsonConf)
+	}
+
+	envConfig := Config{}
+	if err := envconfig.Process("", &envConfig, func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}); err != nil {
+		return result, err
+	}
+	result = result.Apply(envConfig)
+
+	if arg != "" {
+		argConf, err := ParseArg(arg)
+		if err != nil {
+			return result, err
+		}
+		result = result.Apply(argConf)
+	}
+
+	return result, nil
+}
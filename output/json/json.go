@@ -21,10 +21,8 @@
 package json
 
 import (
-	"compress/gzip"
 	stdlibjson "encoding/json"
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -41,11 +39,13 @@ type Output struct {
 	output.SampleBuffer
 
 	params          output.Params
+	config          Config
 	periodicFlusher *output.PeriodicFlusher
 
 	logger      logrus.FieldLogger
 	filename    string
 	encoder     *stdlibjson.Encoder
+	file        *output.FileWriter
 	closeFn     func() error
 	seenMetrics map[string]struct{}
 	thresholds  map[string][]*stats.Threshold
@@ -53,12 +53,17 @@ type Output struct {
 
 // New returns a new JSON output.
 func New(params output.Params) (output.Output, error) {
+	config, err := GetConsolidatedConfig(params.JSONConfig, params.Environment, params.ConfigArgument)
+	if err != nil {
+		return nil, err
+	}
 	return &Output{
 		params:   params,
-		filename: params.ConfigArgument,
+		config:   config,
+		filename: config.FileName.String,
 		logger: params.Logger.WithFields(logrus.Fields{
 			"output":   "json",
-			"filename": params.ConfigArgument,
+			"filename": config.FileName.String,
 		}),
 		seenMetrics: make(map[string]struct{}),
 	}, nil
@@ -73,7 +78,7 @@ func (o *Output) Description() string {
 }
 
 // Start tries to open the specified JSON file and starts the goroutine for
-// metric flushing. If gzip encoding is specified, it also handles that.
+// metric flushing. If gzip or zstd encoding is specified, it also handles that.
 func (o *Output) Start() error {
 	o.logger.Debug("Starting...")
 
@@ -83,28 +88,19 @@ func (o *Output) Start() error {
 			return nil
 		}
 	} else {
-		logfile, err := o.params.FS.Create(o.filename)
+		file, err := output.NewFileWriter(o.params.FS, o.filename,
+			o.config.RotateSize.Int64, o.config.RotateInterval.TimeDuration())
 		if err != nil {
 			return err
 		}
-
-		if strings.HasSuffix(o.filename, ".gz") {
-			outfile := gzip.NewWriter(logfile)
-
-			o.closeFn = func() error {
-				_ = outfile.Close()
-				return logfile.Close()
-			}
-			o.encoder = stdlibjson.NewEncoder(outfile)
-		} else {
-			o.closeFn = logfile.Close
-			o.encoder = stdlibjson.NewEncoder(logfile)
-		}
+		o.file = file
+		o.closeFn = file.Close
+		o.encoder = stdlibjson.NewEncoder(file)
 	}
 
 	o.encoder.SetEscapeHTML(false)
 
-	pf, err := output.NewPeriodicFlusher(flushPeriod, o.flushMetrics)
+	pf, err := o.NewSequentialPeriodicFlusher(flushPeriod, o.flushMetrics)
 	if err != nil {
 		return err
 	}
@@ -133,6 +129,9 @@ func (o *Output) SetThresholds(thresholds map[string]stats.Thresholds) {
 
 func (o *Output) flushMetrics() {
 	samples := o.GetBufferedSamples()
+	if len(samples) > 0 {
+		o.rotate()
+	}
 	start := time.Now()
 	var count int
 	for _, sc := range samples {
@@ -154,6 +153,23 @@ func (o *Output) flushMetrics() {
 	}
 }
 
+// rotate rotates the file before the samples are written, if it's time for it, and resets the seen metrics,
+// so that every file starts with the definitions of the metrics it has samples of.
+func (o *Output) rotate() {
+	if o.file == nil {
+		return
+	}
+	rotated, err := o.file.Rotate()
+	if err != nil {
+		o.logger.WithError(err).Error("Couldn't rotate the JSON file")
+		return
+	}
+	if rotated {
+		o.logger.WithField("filename", o.file.Name()).Debug("Rotated the JSON file")
+		o.seenMetrics = make(map[string]struct{})
+	}
+}
+
 func (o *Output) handleMetric(m *stats.Metric) {
 	if _, ok := o.seenMetrics[m.Name]; ok {
 		return
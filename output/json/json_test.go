@@ -24,15 +24,19 @@ import (
 	"bufio"
 	"bytes"
 	"compress/gzip"
+	"fmt"
 	"io"
+	"os"
 	"testing"
 	"time"
 
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v3"
 
 	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/lib/types"
 	"go.k6.io/k6/output"
 	"go.k6.io/k6/stats"
 )
@@ -57,6 +61,7 @@ func getValidator(t *testing.T, expected []string) func(io.Reader) {
 func generateTestMetricSamples(t *testing.T) ([]stats.SampleContainer, func(io.Reader)) {
 	metric1 := stats.New("my_metric1", stats.Gauge)
 	metric2 := stats.New("my_metric2", stats.Counter, stats.Data)
+	metric2.Description, metric2.ID = "The sent data", "net.sent"
 	time1 := time.Date(2021, time.February, 24, 13, 37, 10, 0, time.UTC)
 	time2 := time1.Add(10 * time.Second)
 	time3 := time2.Add(10 * time.Second)
@@ -75,7 +80,7 @@ func generateTestMetricSamples(t *testing.T) ([]stats.SampleContainer, func(io.R
 		`{"type":"Metric","data":{"name":"my_metric1","type":"gauge","contains":"default","tainted":null,"thresholds":["rate<0.01","p(99)<250"],"submetrics":null,"sub":{"name":"","parent":"","suffix":"","tags":null}},"metric":"my_metric1"}`,
 		`{"type":"Point","data":{"time":"2021-02-24T13:37:10Z","value":1,"tags":{"tag1":"val1"}},"metric":"my_metric1"}`,
 		`{"type":"Point","data":{"time":"2021-02-24T13:37:10Z","value":2,"tags":{"tag2":"val2"}},"metric":"my_metric1"}`,
-		`{"type":"Metric","data":{"name":"my_metric2","type":"counter","contains":"data","tainted":null,"thresholds":[],"submetrics":null,"sub":{"name":"","parent":"","suffix":"","tags":null}},"metric":"my_metric2"}`,
+		`{"type":"Metric","data":{"name":"my_metric2","type":"counter","contains":"data","description":"The sent data","id":"net.sent","tainted":null,"thresholds":[],"submetrics":null,"sub":{"name":"","parent":"","suffix":"","tags":null}},"metric":"my_metric2"}`,
 		`{"type":"Point","data":{"time":"2021-02-24T13:37:20Z","value":3,"tags":{"key":"val"}},"metric":"my_metric2"}`,
 		`{"type":"Point","data":{"time":"2021-02-24T13:37:20Z","value":4,"tags":{"key":"val"}},"metric":"my_metric1"}`,
 		`{"type":"Point","data":{"time":"2021-02-24T13:37:30Z","value":5,"tags":{"tag3":"val3"}},"metric":"my_metric2"}`,
@@ -201,3 +206,57 @@ func setThresholds(t *testing.T, out output.Output) {
 
 	jout.SetThresholds(map[string]stats.Thresholds{"my_metric1": ts})
 }
+
+func TestJsonOutputFileRotated(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewMemMapFs()
+	out, err := New(output.Params{
+		Logger:         testutils.NewLogger(t),
+		FS:             fs,
+		ConfigArgument: "fileName=/json-output.json,rotateSize=1",
+	})
+	require.NoError(t, err)
+	require.NoError(t, out.Start())
+
+	metric := stats.New("my_metric", stats.Gauge)
+	sampleTime := time.Date(2021, time.February, 24, 13, 37, 10, 0, time.UTC)
+	out.AddMetricSamples([]stats.SampleContainer{stats.Sample{Time: sampleTime, Metric: metric, Value: 1}})
+	time.Sleep(3 * flushPeriod / 2)
+	out.AddMetricSamples([]stats.SampleContainer{stats.Sample{Time: sampleTime, Metric: metric, Value: 2}})
+	require.NoError(t, out.Stop())
+
+	metricLine := `{"type":"Metric","data":{"name":"my_metric","type":"gauge","contains":"default","tainted":null,"thresholds":[],"submetrics":null,"sub":{"name":"","parent":"","suffix":"","tags":null}},"metric":"my_metric"}` //nolint:lll
+	for i, name := range []string{"/json-output-0001.json", "/json-output-0002.json"} {
+		file, err := fs.Open(name)
+		require.NoError(t, err)
+		getValidator(t, []string{
+			metricLine,
+			fmt.Sprintf(
+				`{"type":"Point","data":{"time":"2021-02-24T13:37:10Z","value":%d,"tags":null},"metric":"my_metric"}`, i+1),
+		})(file)
+	}
+	_, err = fs.Stat("/json-output-0003.json")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestParseArg(t *testing.T) {
+	t.Parallel()
+
+	config, err := ParseArg("results.json")
+	require.NoError(t, err)
+	assert.Equal(t, Config{FileName: null.StringFrom("results.json")}, config)
+
+	config, err = ParseArg("fileName=results.json.gz,rotateSize=100MB,rotateInterval=1h")
+	require.NoError(t, err)
+	assert.Equal(t, Config{
+		FileName:       null.StringFrom("results.json.gz"),
+		RotateSize:     output.NullFileSizeFrom(100 << 20),
+		RotateInterval: types.NullDurationFrom(time.Hour),
+	}, config)
+
+	_, err = ParseArg("fileName=results.json,rotate=1h")
+	assert.EqualError(t, err, `unknown key "rotate" as argument for json output`)
+	_, err = ParseArg("rotateSize=big")
+	assert.EqualError(t, err, `invalid file size "big"`)
+}
@@ -0,0 +1,106 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package kafka
+
+import (
+	"encoding/binary"
+	"math"
+
+	"go.k6.io/k6/stats"
+)
+
+// AvroSchema is the Avro schema of the samples with the avro format. They are in the single object encoding
+// of the spec, which starts with the fingerprint of the schema, see
+// https://avro.apache.org/docs/1.11.1/specification/#single-object-encoding
+const AvroSchema = `{"type":"record","name":"Sample","namespace":"io.k6","fields":[` +
+	`{"name":"metric","type":"string"},` +
+	`{"name":"type","type":"string"},` +
+	`{"name":"time","type":{"type":"long","logicalType":"timestamp-micros"}},` +
+	`{"name":"value","type":"double"},` +
+	`{"name":"tags","type":{"type":"map","values":"string"}}]}`
+
+// avroCanonicalSchema is the parsing canonical form of AvroSchema, which the fingerprint is the hash of.
+const avroCanonicalSchema = `{"name":"io.k6.Sample","type":"record","fields":[` +
+	`{"name":"metric","type":"string"},` +
+	`{"name":"type","type":"string"},` +
+	`{"name":"time","type":"long"},` +
+	`{"name":"value","type":"double"},` +
+	`{"name":"tags","type":{"type":"map","values":"string"}}]}`
+
+// avroHeader is the marker of the single object encoding and the little-endian fingerprint of the schema.
+var avroHeader = func() []byte { //nolint:gochecknoglobals
+	header := []byte{0xc3, 0x01, 0, 0, 0, 0, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint64(header[2:], avroFingerprint(avroCanonicalSchema))
+	return header
+}()
+
+// avroFingerprint returns the CRC-64-AVRO fingerprint of a schema, which is a Rabin fingerprint, see
+// https://avro.apache.org/docs/1.11.1/specification/#schema-fingerprints
+func avroFingerprint(schema string) uint64 {
+	const empty uint64 = 0xc15d213aa4d7a795
+	var table [256]uint64
+	for i := range table {
+		fp := uint64(i)
+		for j := 0; j < 8; j++ {
+			fp = (fp >> 1) ^ (empty & -(fp & 1))
+		}
+		table[i] = fp
+	}
+	fp := empty
+	for _, b := range []byte(schema) {
+		fp = (fp >> 8) ^ table[byte(fp)^b]
+	}
+	return fp
+}
+
+// encodeAvro encodes a sample with the binary encoding of AvroSchema, after the header.
+func encodeAvro(sample stats.Sample) []byte {
+	tags := sample.Tags.CloneTags()
+	b := make([]byte, 0, 128)
+	b = append(b, avroHeader...)
+	b = appendAvroString(b, sample.Metric.Name)
+	b = appendAvroString(b, sample.Metric.Type.String())
+	b = appendAvroLong(b, sample.Time.UnixMicro())
+	var double [8]byte
+	binary.LittleEndian.PutUint64(double[:], math.Float64bits(sample.Value))
+	b = append(b, double[:]...)
+	// a map is a block of its entries, preceded by their count, and an empty block
+	if len(tags) > 0 {
+		b = appendAvroLong(b, int64(len(tags)))
+		for k, v := range tags {
+			b = appendAvroString(b, k)
+			b = appendAvroString(b, v)
+		}
+	}
+	return appendAvroLong(b, 0)
+}
+
+// appendAvroLong appends a long, which is zig-zag encoded in a varint.
+func appendAvroLong(b []byte, v int64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutVarint(buf[:], v)]...)
+}
+
+// appendAvroString appends a string, which is its zig-zag encoded length and its bytes.
+func appendAvroString(b []byte, s string) []byte {
+	b = appendAvroLong(b, int64(len(s)))
+	return append(b, s...)
+}
@@ -0,0 +1,205 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package kafka implements an output that publishes the samples to a Kafka topic, as JSON or Avro records,
+// with the in-tree client of lib/netext/kafkaext.
+package kafka
+
+import (
+	"context"
+	"crypto/tls"
+	stdlibjson "encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.k6.io/k6/lib/netext/kafkaext"
+	"go.k6.io/k6/output"
+	"go.k6.io/k6/output/json"
+	"go.k6.io/k6/stats"
+)
+
+// maxBatchSize is the size of the values of the records that are produced in a batch, so the requests stay
+// under the default max message size of the brokers, which is 1MB.
+const maxBatchSize = 512 << 10
+
+// New creates a new kafka output.
+func New(params output.Params) (output.Output, error) {
+	return newOutput(params)
+}
+
+func newOutput(params output.Params) (*Output, error) {
+	conf, err := GetConsolidatedConfig(params.JSONConfig, params.Environment, params.ConfigArgument)
+	if err != nil {
+		return nil, err
+	}
+	logger := params.Logger.WithFields(logrus.Fields{"output": "kafka", "topic": conf.Topic.String})
+
+	return &Output{
+		config: conf,
+		logger: logger,
+	}, nil
+}
+
+var _ output.Output = &Output{}
+
+// Output produces the buffered samples to a topic at every push interval, in a batch for every partition.
+type Output struct {
+	output.SampleBuffer
+
+	periodicFlusher *output.PeriodicFlusher
+
+	config Config
+
+	logger logrus.FieldLogger
+	client *kafkaext.Client
+	// next is the partition of the records without a key of the next flush
+	next int
+}
+
+// Description returns a human-readable description of the output.
+func (o *Output) Description() string {
+	return fmt.Sprintf("kafka (%s/%s)", strings.Join(o.config.Brokers, ","), o.config.Topic.String)
+}
+
+// Start connects to the brokers, to get the partitions of the topic, and starts the goroutine for
+// metric flushing.
+func (o *Output) Start() error {
+	o.logger.Debug("Starting...")
+
+	var tlsConfig *tls.Config
+	if o.config.TLS.Bool {
+		tlsConfig = &tls.Config{InsecureSkipVerify: o.config.Insecure.Bool} //nolint:gosec
+	}
+	o.client = kafkaext.NewClient(o.config.clientConfig(), &net.Dialer{}, tlsConfig)
+	if _, err := o.client.Partitions(context.Background(), o.config.Topic.String, false); err != nil {
+		o.client.Close()
+		return err
+	}
+
+	pf, err := o.NewSequentialPeriodicFlusher(o.config.PushInterval.TimeDuration(), o.flushMetrics)
+	if err != nil {
+		o.client.Close()
+		return err
+	}
+	o.logger.Debug("Started!")
+	o.periodicFlusher = pf
+
+	return nil
+}
+
+// Stop flushes any remaining metrics and stops the goroutine.
+func (o *Output) Stop() error {
+	o.logger.Debug("Stopping...")
+	defer o.logger.Debug("Stopped!")
+	o.periodicFlusher.Stop()
+	o.client.Close()
+	return nil
+}
+
+func (o *Output) flushMetrics() {
+	samples := o.GetBufferedSamples()
+	if len(samples) == 0 {
+		return
+	}
+
+	start := time.Now()
+	ctx := context.Background()
+	topic := o.config.Topic.String
+	partitions, err := o.client.Partitions(ctx, topic, false)
+	if err != nil {
+		o.logger.WithError(err).Error("Couldn't get the partitions of the topic")
+		return
+	}
+	// the records without a key go to the same partition for the whole flush, so they are in one batch
+	o.next = (o.next + 1) % len(partitions)
+
+	var order []int32
+	batches := make(map[int32][]kafkaext.Record)
+	var count int
+	for _, sc := range samples {
+		for _, sample := range sc.GetSamples() {
+			r, err := o.record(sample)
+			if err != nil {
+				o.logger.WithError(err).Error("Sample couldn't be encoded")
+				continue
+			}
+			partition := partitions[o.next]
+			if r.Key != nil {
+				partition = kafkaext.KeyPartition(r.Key, partitions)
+			}
+			if _, ok := batches[partition]; !ok {
+				order = append(order, partition)
+			}
+			batches[partition] = append(batches[partition], r)
+			count++
+		}
+	}
+
+	for _, partition := range order {
+		for _, batch := range splitBatch(batches[partition]) {
+			_, err := o.client.Produce(ctx, topic, partition, batch, int16(o.config.Acks.Int64), o.compression())
+			if err != nil {
+				o.logger.WithError(err).WithField("samples", len(batch)).Error("Couldn't produce the samples")
+			}
+		}
+	}
+	o.logger.WithField("t", time.Since(start)).WithField("samples", count).Debug("Produced the samples")
+}
+
+// record returns the record of a sample, with its time. Its key is the value of the partition key tag, if it has it.
+func (o *Output) record(sample stats.Sample) (kafkaext.Record, error) {
+	r := kafkaext.Record{Timestamp: sample.Time}
+	if tag := o.config.PartitionKey.String; tag != "" {
+		if v, ok := sample.Tags.Get(tag); ok {
+			r.Key = []byte(v)
+		}
+	}
+	if o.config.Format.String == formatAvro {
+		r.Value = encodeAvro(sample)
+		return r, nil
+	}
+	var err error
+	r.Value, err = stdlibjson.Marshal(json.WrapSample(sample))
+	return r, err
+}
+
+func (o *Output) compression() int16 {
+	if o.config.Compression.String == compressionGzip {
+		return kafkaext.CompressionGzip
+	}
+	return kafkaext.CompressionNone
+}
+
+// splitBatch splits the records of a partition in batches of up to maxBatchSize.
+func splitBatch(records []kafkaext.Record) [][]kafkaext.Record {
+	var batches [][]kafkaext.Record
+	start, size := 0, 0
+	for i, r := range records {
+		if size += len(r.Key) + len(r.Value); size > maxBatchSize && i > start {
+			batches = append(batches, records[start:i])
+			start, size = i, len(r.Key)+len(r.Value)
+		}
+	}
+	return append(batches, records[start:])
+}
@@ -0,0 +1,152 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package kafka
+
+import (
+	"encoding/binary"
+	stdlibjson "encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/lib/netext/kafkaext"
+	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/output"
+	"go.k6.io/k6/output/json"
+	"go.k6.io/k6/stats"
+)
+
+func testSamples(now time.Time) []stats.SampleContainer {
+	counter := stats.New("my_counter", stats.Counter)
+	var samples stats.Samples
+	for i, scenario := range []string{"a", "b", "a", "c"} {
+		samples = append(samples, stats.Sample{
+			Metric: counter,
+			Tags:   stats.IntoSampleTags(&map[string]string{"scenario": scenario}),
+			Time:   now.Add(time.Duration(i) * time.Millisecond),
+			Value:  float64(i),
+		})
+	}
+	return []stats.SampleContainer{samples}
+}
+
+func TestOutput(t *testing.T) {
+	t.Parallel()
+	broker := kafkaext.NewTestBroker(t, nil, false)
+
+	out, err := newOutput(output.Params{
+		Logger:         testutils.NewLogger(t),
+		ConfigArgument: "kafka://" + broker.Addr + "/test?partitionKey=scenario&pushInterval=1h",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "kafka ("+broker.Addr+"/test)", out.Description())
+	require.NoError(t, out.Start())
+	now := time.UnixMilli(time.Now().UnixMilli())
+	out.AddMetricSamples(testSamples(now))
+	require.NoError(t, out.Stop())
+
+	partitions := []int32{0, 1}
+	values := map[string][]float64{}
+	for _, partition := range partitions {
+		records, err := broker.Records(partition)
+		require.NoError(t, err)
+		for _, r := range records {
+			// the samples of a scenario are in the partition of its key, in order
+			assert.Equal(t, kafkaext.KeyPartition(r.Key, partitions), partition)
+			var envelope struct {
+				json.Envelope
+				Data json.Sample `json:"data"`
+			}
+			require.NoError(t, stdlibjson.Unmarshal(r.Value, &envelope))
+			assert.Equal(t, "Point", envelope.Type)
+			assert.Equal(t, "my_counter", envelope.Metric)
+			assert.True(t, r.Timestamp.Equal(envelope.Data.Time))
+			scenario, _ := envelope.Data.Tags.Get("scenario")
+			assert.Equal(t, string(r.Key), scenario)
+			values[scenario] = append(values[scenario], envelope.Data.Value)
+		}
+	}
+	assert.Equal(t, map[string][]float64{"a": {0, 2}, "b": {1}, "c": {3}}, values)
+}
+
+func TestOutputStartError(t *testing.T) {
+	t.Parallel()
+	broker := kafkaext.NewTestBroker(t, nil, false)
+
+	out, err := newOutput(output.Params{
+		Logger:         testutils.NewLogger(t),
+		ConfigArgument: "kafka://" + broker.Addr + "/missing",
+	})
+	require.NoError(t, err)
+	err = out.Start()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "UNKNOWN_TOPIC_OR_PARTITION")
+}
+
+func TestAvro(t *testing.T) {
+	t.Parallel()
+	// the fingerprint of "int" in the tests of the Avro spec
+	assert.Equal(t, uint64(8247732601305521295), avroFingerprint(`"int"`))
+
+	now := time.Now()
+	sample := testSamples(now)[0].GetSamples()[3]
+	b := encodeAvro(sample)
+	require.Equal(t, avroHeader, b[:10])
+	assert.Equal(t, avroFingerprint(avroCanonicalSchema), binary.LittleEndian.Uint64(b[2:10]))
+
+	b = b[10:]
+	long := func() int64 {
+		v, n := binary.Varint(b)
+		require.Greater(t, n, 0)
+		b = b[n:]
+		return v
+	}
+	str := func() string {
+		n := long()
+		s := string(b[:n])
+		b = b[n:]
+		return s
+	}
+	assert.Equal(t, "my_counter", str())
+	assert.Equal(t, "counter", str())
+	assert.Equal(t, now.UnixMicro()+3000, long())
+	assert.Equal(t, 3.0, math.Float64frombits(binary.LittleEndian.Uint64(b)))
+	b = b[8:]
+	assert.Equal(t, int64(1), long())
+	assert.Equal(t, "scenario", str())
+	assert.Equal(t, "c", str())
+	assert.Equal(t, int64(0), long())
+	assert.Empty(t, b)
+}
+
+func TestSplitBatch(t *testing.T) {
+	t.Parallel()
+	value := make([]byte, maxBatchSize/2)
+	records := []kafkaext.Record{{Value: value}, {Value: value}, {Value: value}, {Value: []byte("v")}}
+	batches := splitBatch(records)
+	require.Len(t, batches, 2)
+	assert.Len(t, batches[0], 2)
+	assert.Len(t, batches[1], 2)
+	assert.Len(t, splitBatch(records[:1]), 1)
+}
@@ -0,0 +1,284 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package otel
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"go.k6.io/k6/lib/consts"
+	"go.k6.io/k6/output/internal/rawproto"
+	"go.k6.io/k6/stats"
+)
+
+// series is the aggregated value of the samples of a metric with the same tags.
+type series struct {
+	key  string
+	tags map[string]string
+
+	// value is the sum of a Counter, the last value of a Gauge and the non-zero samples of a Rate
+	value float64
+	// total is the count of the samples of a Rate
+	total float64
+
+	// count, sum, min, max and buckets are the histogram of a Trend
+	count    uint64
+	sum      float64
+	min, max float64
+	buckets  []uint64
+}
+
+type metricSeries struct {
+	metric *stats.Metric
+	series map[string]*series
+}
+
+// aggregator aggregates the samples between the exports, since the start of the test with the cumulative
+// temporality, or since the last export with the delta one.
+type aggregator struct {
+	conf config
+
+	start   time.Time
+	metrics map[string]*metricSeries
+}
+
+func newAggregator(conf config, start time.Time) *aggregator {
+	return &aggregator{conf: conf, start: start, metrics: make(map[string]*metricSeries)}
+}
+
+// seriesKey returns the key of the series of the tags, which are sorted.
+func seriesKey(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte(0)
+		b.WriteString(tags[k])
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+func (a *aggregator) add(sample stats.Sample) {
+	ms, ok := a.metrics[sample.Metric.Name]
+	if !ok {
+		ms = &metricSeries{metric: sample.Metric, series: make(map[string]*series)}
+		a.metrics[sample.Metric.Name] = ms
+	}
+
+	tags := sample.Tags.CloneTags()
+	key := seriesKey(tags)
+	s, ok := ms.series[key]
+	if !ok {
+		s = &series{key: key, tags: tags, min: math.Inf(1), max: math.Inf(-1)}
+		if sample.Metric.Type == stats.Trend || sample.Metric.Type == stats.Histogram {
+			s.buckets = make([]uint64, len(a.bounds(sample.Metric))+1)
+		}
+		ms.series[key] = s
+	}
+
+	switch sample.Metric.Type {
+	case stats.Counter:
+		s.value += sample.Value
+	case stats.Gauge:
+		s.value = sample.Value
+	case stats.Rate:
+		if sample.Value != 0 {
+			s.value++
+		}
+		s.total++
+	case stats.Trend, stats.Histogram:
+		s.count++
+		s.sum += sample.Value
+		s.min = math.Min(s.min, sample.Value)
+		s.max = math.Max(s.max, sample.Value)
+		// the buckets are (bound[i-1], bound[i]], the last one being (bound[len-1], +inf)
+		s.buckets[sort.SearchFloat64s(a.bounds(sample.Metric), sample.Value)]++
+	}
+}
+
+// bounds returns the bounds of the buckets of the histogram of the metric, which are the ones of the config
+// for the Trends and their own for the Histograms.
+func (a *aggregator) bounds(m *stats.Metric) []float64 {
+	if m.Type == stats.Histogram {
+		return m.Buckets
+	}
+	return a.conf.HistogramBuckets
+}
+
+// export encodes the ExportMetricsServiceRequest of the aggregated series, or returns nil if there's none.
+// With the delta temporality, the aggregation starts over.
+func (a *aggregator) export(now time.Time) []byte {
+	if len(a.metrics) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(a.metrics))
+	for name := range a.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var metrics []byte
+	for _, name := range names {
+		metrics = a.appendMetric(metrics, a.metrics[name], now)
+	}
+
+	var scope []byte
+	scope = rawproto.AppendString(scope, fieldScopeName, "k6")
+	scope = rawproto.AppendString(scope, fieldScopeVersion, consts.Version)
+	var scopeMetrics []byte
+	scopeMetrics = rawproto.AppendMessage(scopeMetrics, fieldScope, scope)
+	scopeMetrics = append(scopeMetrics, metrics...)
+
+	attributes := map[string]string{"service.name": a.conf.ServiceName.String, "service.version": consts.Version}
+	for k, v := range a.conf.ResourceAttributes {
+		attributes[k] = v
+	}
+	var resource []byte
+	resource = appendAttributes(resource, fieldResourceAttributes, attributes)
+
+	var resourceMetrics []byte
+	resourceMetrics = rawproto.AppendMessage(resourceMetrics, fieldResource, resource)
+	resourceMetrics = rawproto.AppendMessage(resourceMetrics, fieldScopeMetrics, scopeMetrics)
+
+	if a.conf.Temporality.String == temporalityDelta {
+		a.start = now
+		a.metrics = make(map[string]*metricSeries)
+	}
+	return rawproto.AppendMessage(nil, fieldResourceMetrics, resourceMetrics)
+}
+
+// appendMetric appends the Metric of the series. Counters are monotonic Sums, Gauges are Gauges, Trends and
+// Histograms are Histograms and Rates are two monotonic Sums, of the non-zero samples and of all of them.
+func (a *aggregator) appendMetric(b []byte, ms *metricSeries, now time.Time) []byte {
+	name := a.conf.MetricPrefix.String + ms.metric.Name
+	unit := ""
+	switch ms.metric.Contains {
+	case stats.Time:
+		unit = "ms"
+	case stats.Data:
+		unit = "By"
+	case stats.Default:
+		// the custom units are annotations for OpenTelemetry, which uses the UCUM units
+		switch ms.metric.Unit {
+		case "", stats.UnitSeconds:
+			unit = ms.metric.Unit
+		default:
+			unit = "{" + ms.metric.Unit + "}"
+		}
+	}
+
+	sorted := make([]*series, 0, len(ms.series))
+	for _, s := range ms.series {
+		sorted = append(sorted, s)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].key < sorted[j].key })
+
+	switch ms.metric.Type {
+	case stats.Counter:
+		return a.appendSum(b, name, unit, sorted, now, func(s *series) float64 { return s.value })
+	case stats.Gauge:
+		var gauge []byte
+		for _, s := range sorted {
+			gauge = rawproto.AppendMessage(gauge, fieldDataPoints, a.numberDataPoint(s, s.value, now))
+		}
+		return rawproto.AppendMessage(b, fieldMetrics, a.metric(name, unit, fieldGauge, gauge))
+	case stats.Rate:
+		b = a.appendSum(b, name+".occurred", "", sorted, now, func(s *series) float64 { return s.value })
+		return a.appendSum(b, name+".total", "", sorted, now, func(s *series) float64 { return s.total })
+	case stats.Trend, stats.Histogram:
+		var histogram []byte
+		for _, s := range sorted {
+			histogram = rawproto.AppendMessage(histogram, fieldDataPoints,
+				a.histogramDataPoint(s, a.bounds(ms.metric), now))
+		}
+		histogram = appendVarint(histogram, fieldAggregationTemporality, a.temporality())
+		return rawproto.AppendMessage(b, fieldMetrics, a.metric(name, unit, fieldHistogram, histogram))
+	default:
+		return b
+	}
+}
+
+func (a *aggregator) appendSum(
+	b []byte, name, unit string, sorted []*series, now time.Time, value func(*series) float64,
+) []byte {
+	var sum []byte
+	for _, s := range sorted {
+		sum = rawproto.AppendMessage(sum, fieldDataPoints, a.numberDataPoint(s, value(s), now))
+	}
+	sum = appendVarint(sum, fieldAggregationTemporality, a.temporality())
+	sum = appendVarint(sum, fieldIsMonotonic, 1)
+	return rawproto.AppendMessage(b, fieldMetrics, a.metric(name, unit, fieldSum, sum))
+}
+
+func (a *aggregator) metric(name, unit string, dataField protowire.Number, data []byte) []byte {
+	var metric []byte
+	metric = rawproto.AppendString(metric, fieldMetricName, name)
+	metric = rawproto.AppendString(metric, fieldMetricUnit, unit)
+	return rawproto.AppendMessage(metric, dataField, data)
+}
+
+func (a *aggregator) temporality() uint64 {
+	if a.conf.Temporality.String == temporalityDelta {
+		return aggregationTemporalityDelta
+	}
+	return aggregationTemporalityCumulative
+}
+
+func (a *aggregator) numberDataPoint(s *series, value float64, now time.Time) []byte {
+	var dp []byte
+	dp = appendFixed64(dp, fieldNumberStartTime, uint64(a.start.UnixNano()))
+	dp = appendFixed64(dp, fieldNumberTime, uint64(now.UnixNano()))
+	dp = appendDouble(dp, fieldNumberAsDouble, value)
+	return appendAttributes(dp, fieldNumberAttributes, s.tags)
+}
+
+func (a *aggregator) histogramDataPoint(s *series, bounds []float64, now time.Time) []byte {
+	var dp []byte
+	dp = appendFixed64(dp, fieldHistogramStartTime, uint64(a.start.UnixNano()))
+	dp = appendFixed64(dp, fieldHistogramTime, uint64(now.UnixNano()))
+	dp = appendFixed64(dp, fieldHistogramCount, s.count)
+	dp = appendDouble(dp, fieldHistogramSum, s.sum)
+
+	var counts []byte
+	for _, c := range s.buckets {
+		counts = protowire.AppendFixed64(counts, c)
+	}
+	dp = rawproto.AppendMessage(dp, fieldHistogramBucketCounts, counts)
+	var encodedBounds []byte
+	for _, bound := range bounds {
+		encodedBounds = protowire.AppendFixed64(encodedBounds, math.Float64bits(bound))
+	}
+	dp = rawproto.AppendMessage(dp, fieldHistogramExplicitBounds, encodedBounds)
+
+	dp = appendAttributes(dp, fieldHistogramAttributes, s.tags)
+	dp = appendDouble(dp, fieldHistogramMin, s.min)
+	return appendDouble(dp, fieldHistogramMax, s.max)
+}
@@ -0,0 +1,191 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package otel
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mstoykov/envconfig"
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/lib/types"
+)
+
+const (
+	protocolGRPC = "grpc"
+	protocolHTTP = "http"
+
+	temporalityCumulative = "cumulative"
+	temporalityDelta      = "delta"
+)
+
+// defaultHistogramBuckets are the default explicit bounds of the OpenTelemetry SDKs, in milliseconds.
+//
+//nolint:gochecknoglobals
+var defaultHistogramBuckets = []float64{0, 5, 10, 25, 50, 75, 100, 250, 500, 750, 1000, 2500, 5000, 7500, 10000}
+
+// keyValues are the headers or the resource attributes, which are set
+// with key=value pairs separated by commas in the environment variables.
+type keyValues map[string]string
+
+// Decode implements envconfig.Decoder.
+func (kv *keyValues) Decode(value string) error {
+	*kv = keyValues{}
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		i := strings.IndexByte(pair, '=')
+		if i < 0 {
+			return fmt.Errorf("invalid key=value pair '%s'", pair)
+		}
+		(*kv)[strings.TrimSpace(pair[:i])] = strings.TrimSpace(pair[i+1:])
+	}
+	return nil
+}
+
+// config defines the OpenTelemetry output configuration.
+type config struct {
+	Protocol           null.String        `json:"protocol,omitempty" envconfig:"K6_OTEL_PROTOCOL"`
+	Endpoint           null.String        `json:"endpoint,omitempty" envconfig:"K6_OTEL_ENDPOINT"`
+	Insecure           null.Bool          `json:"insecure,omitempty" envconfig:"K6_OTEL_INSECURE"`
+	Headers            keyValues          `json:"headers,omitempty" envconfig:"K6_OTEL_HEADERS"`
+	ServiceName        null.String        `json:"serviceName,omitempty" envconfig:"K6_OTEL_SERVICE_NAME"`
+	ResourceAttributes keyValues          `json:"resourceAttributes,omitempty" envconfig:"K6_OTEL_RESOURCE_ATTRIBUTES"`
+	MetricPrefix       null.String        `json:"metricPrefix,omitempty" envconfig:"K6_OTEL_METRIC_PREFIX"`
+	Temporality        null.String        `json:"temporality,omitempty" envconfig:"K6_OTEL_TEMPORALITY"`
+	HistogramBuckets   []float64          `json:"histogramBuckets,omitempty" envconfig:"K6_OTEL_HISTOGRAM_BUCKETS"`
+	PushInterval       types.NullDuration `json:"pushInterval,omitempty" envconfig:"K6_OTEL_PUSH_INTERVAL"`
+	Timeout            types.NullDuration `json:"timeout,omitempty" envconfig:"K6_OTEL_TIMEOUT"`
+}
+
+// Apply saves config non-zero config values from the passed config in the receiver.
+func (c config) Apply(cfg config) config { //nolint:cyclop
+	if cfg.Protocol.Valid {
+		c.Protocol = cfg.Protocol
+	}
+	if cfg.Endpoint.Valid {
+		c.Endpoint = cfg.Endpoint
+	}
+	if cfg.Insecure.Valid {
+		c.Insecure = cfg.Insecure
+	}
+	if cfg.Headers != nil {
+		c.Headers = cfg.Headers
+	}
+	if cfg.ServiceName.Valid {
+		c.ServiceName = cfg.ServiceName
+	}
+	if cfg.ResourceAttributes != nil {
+		c.ResourceAttributes = cfg.ResourceAttributes
+	}
+	if cfg.MetricPrefix.Valid {
+		c.MetricPrefix = cfg.MetricPrefix
+	}
+	if cfg.Temporality.Valid {
+		c.Temporality = cfg.Temporality
+	}
+	if cfg.HistogramBuckets != nil {
+		c.HistogramBuckets = cfg.HistogramBuckets
+	}
+	if cfg.PushInterval.Valid {
+		c.PushInterval = cfg.PushInterval
+	}
+	if cfg.Timeout.Valid {
+		c.Timeout = cfg.Timeout
+	}
+
+	return c
+}
+
+// newConfig creates a new Config instance with default values for some fields.
+func newConfig() config {
+	return config{
+		Protocol:         null.NewString(protocolGRPC, false),
+		Insecure:         null.NewBool(false, false),
+		ServiceName:      null.NewString("k6", false),
+		MetricPrefix:     null.NewString("k6_", false),
+		Temporality:      null.NewString(temporalityCumulative, false),
+		HistogramBuckets: defaultHistogramBuckets,
+		PushInterval:     types.NewNullDuration(10*time.Second, false),
+		Timeout:          types.NewNullDuration(10*time.Second, false),
+	}
+}
+
+// validate checks the config and sets the default endpoint of the protocol, when none was set.
+func (c *config) validate() error {
+	switch c.Protocol.String {
+	case protocolGRPC:
+		if !c.Endpoint.Valid {
+			c.Endpoint = null.NewString("localhost:4317", false)
+		}
+	case protocolHTTP:
+		if !c.Endpoint.Valid {
+			c.Endpoint = null.NewString("localhost:4318", false)
+		}
+	default:
+		return fmt.Errorf("unsupported OTLP protocol '%s', supported protocols are 'grpc' and 'http'", c.Protocol.String)
+	}
+	if t := c.Temporality.String; t != temporalityCumulative && t != temporalityDelta {
+		return fmt.Errorf("invalid temporality '%s', it needs to be 'cumulative' or 'delta'", t)
+	}
+	for i := 1; i < len(c.HistogramBuckets); i++ {
+		if c.HistogramBuckets[i] <= c.HistogramBuckets[i-1] {
+			return fmt.Errorf("the histogram buckets need to be increasing, %g isn't greater than %g",
+				c.HistogramBuckets[i], c.HistogramBuckets[i-1])
+		}
+	}
+	return nil
+}
+
+// getConsolidatedConfig combines {default config values + JSON config +
+// environment vars + the endpoint in the argument}, and returns the final result.
+func getConsolidatedConfig(jsonRawConf json.RawMessage, env map[string]string, arg string) (config, error) {
+	result := newConfig()
+	if jsonRawConf != nil {
+		jsonConf := config{}
+		if err := json.Unmarshal(jsonRawConf, &jsonConf); err != nil {
+			return result, err
+		}
+		result = result.Apply(jsonConf)
+	}
+
+	envConfig := config{}
+	if err := envconfig.Process("", &envConfig, func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}); err != nil {
+		return result, err
+	}
+	result = result.Apply(envConfig)
+
+	if arg != "" {
+		result.Endpoint = null.StringFrom(arg)
+	}
+
+	if err := result.validate(); err != nil {
+		return result, err
+	}
+	return result, nil
+}
@@ -0,0 +1,101 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package otel
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig(t *testing.T) {
+	t.Parallel()
+
+	t.Run("defaults", func(t *testing.T) {
+		t.Parallel()
+		conf, err := getConsolidatedConfig(nil, nil, "")
+		require.NoError(t, err)
+		assert.Equal(t, "grpc", conf.Protocol.String)
+		assert.Equal(t, "localhost:4317", conf.Endpoint.String)
+		assert.Equal(t, "cumulative", conf.Temporality.String)
+		assert.Equal(t, defaultHistogramBuckets, conf.HistogramBuckets)
+		assert.Equal(t, 10*time.Second, conf.PushInterval.TimeDuration())
+	})
+
+	t.Run("http", func(t *testing.T) {
+		t.Parallel()
+		conf, err := getConsolidatedConfig(json.RawMessage(`{"protocol":"http"}`), nil, "")
+		require.NoError(t, err)
+		assert.Equal(t, "localhost:4318", conf.Endpoint.String)
+	})
+
+	t.Run("precedence", func(t *testing.T) {
+		t.Parallel()
+		conf, err := getConsolidatedConfig(
+			json.RawMessage(`{"endpoint":"json:4317","temporality":"delta","serviceName":"json",`+
+				`"resourceAttributes":{"team":"json"},"histogramBuckets":[1,2]}`),
+			map[string]string{
+				"K6_OTEL_SERVICE_NAME":        "env",
+				"K6_OTEL_HEADERS":             "authorization=Bearer token, x-scope = k6",
+				"K6_OTEL_RESOURCE_ATTRIBUTES": "team=env,region=eu",
+				"K6_OTEL_HISTOGRAM_BUCKETS":   "10,20,30",
+				"K6_OTEL_PUSH_INTERVAL":       "1s",
+			},
+			"arg:4317",
+		)
+		require.NoError(t, err)
+		assert.Equal(t, "arg:4317", conf.Endpoint.String)
+		assert.Equal(t, "delta", conf.Temporality.String)
+		assert.Equal(t, "env", conf.ServiceName.String)
+		assert.Equal(t, keyValues{"authorization": "Bearer token", "x-scope": "k6"}, conf.Headers)
+		assert.Equal(t, keyValues{"team": "env", "region": "eu"}, conf.ResourceAttributes)
+		assert.Equal(t, []float64{10, 20, 30}, conf.HistogramBuckets)
+		assert.Equal(t, time.Second, conf.PushInterval.TimeDuration())
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		t.Parallel()
+		testCases := []struct {
+			json, err string
+			env       map[string]string
+		}{
+			{
+				json: `{"protocol":"udp"}`,
+				err:  "unsupported OTLP protocol 'udp', supported protocols are 'grpc' and 'http'",
+			},
+			{json: `{"temporality":"sometimes"}`, err: "invalid temporality 'sometimes'"},
+			{json: `{"histogramBuckets":[10,5]}`, err: "the histogram buckets need to be increasing, 5 isn't greater than 10"},
+			{env: map[string]string{"K6_OTEL_HEADERS": "authorization"}, err: "invalid key=value pair 'authorization'"},
+		}
+		for _, tc := range testCases {
+			var jsonConf json.RawMessage
+			if tc.json != "" {
+				jsonConf = json.RawMessage(tc.json)
+			}
+			_, err := getConsolidatedConfig(jsonConf, tc.env, "")
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.err)
+		}
+	})
+}
@@ -0,0 +1,155 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package otel
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+
+	"go.k6.io/k6/lib/consts"
+	"go.k6.io/k6/output/internal/rawproto"
+)
+
+const (
+	grpcExportMethod = "/opentelemetry.proto.collector.metrics.v1.MetricsService/Export"
+	httpExportPath   = "/v1/metrics"
+)
+
+// exporter sends the encoded ExportMetricsServiceRequests and returns the encoded responses.
+type exporter interface {
+	export(ctx context.Context, request []byte) ([]byte, error)
+	close() error
+}
+
+func newExporter(conf config) (exporter, error) {
+	if conf.Protocol.String == protocolHTTP {
+		return newHTTPExporter(conf)
+	}
+	return newGRPCExporter(conf)
+}
+
+type grpcExporter struct {
+	conn *grpc.ClientConn
+	md   metadata.MD
+}
+
+func newGRPCExporter(conf config) (*grpcExporter, error) {
+	target, insecure := conf.Endpoint.String, conf.Insecure.Bool
+	// the scheme of an endpoint like the ones of the HTTP exporter selects the security
+	if u, err := url.Parse(target); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		target, insecure = u.Host, u.Scheme == "http"
+	}
+
+	creds := grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12}))
+	if insecure {
+		creds = grpc.WithInsecure()
+	}
+	conn, err := grpc.Dial(target, creds, grpc.WithUserAgent("k6/"+consts.Version))
+	if err != nil {
+		return nil, err
+	}
+
+	md := metadata.MD{}
+	for k, v := range conf.Headers {
+		md.Set(k, v)
+	}
+	return &grpcExporter{conn: conn, md: md}, nil
+}
+
+func (e *grpcExporter) export(ctx context.Context, request []byte) ([]byte, error) {
+	var response []byte
+	ctx = metadata.NewOutgoingContext(ctx, e.md)
+	if err := e.conn.Invoke(ctx, grpcExportMethod, &request, &response, grpc.ForceCodec(rawproto.Codec{})); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (e *grpcExporter) close() error {
+	return e.conn.Close()
+}
+
+type httpExporter struct {
+	client  *http.Client
+	url     string
+	headers map[string]string
+}
+
+func newHTTPExporter(conf config) (*httpExporter, error) {
+	endpoint := conf.Endpoint.String
+	if !strings.Contains(endpoint, "://") {
+		scheme := "https://"
+		if conf.Insecure.Bool {
+			scheme = "http://"
+		}
+		endpoint = scheme + endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OTLP endpoint '%s': %w", conf.Endpoint.String, err)
+	}
+	// the endpoints without a path are the ones of the collectors, the others are the full URLs
+	if u.Path == "" || u.Path == "/" {
+		u.Path = httpExportPath
+	}
+	return &httpExporter{client: &http.Client{}, url: u.String(), headers: conf.Headers}, nil
+}
+
+func (e *httpExporter) export(ctx context.Context, request []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(request))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", "k6/"+consts.Version)
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("the OTLP endpoint responded with the status %d", resp.StatusCode)
+	}
+	return body, nil
+}
+
+func (e *httpExporter) close() error {
+	e.client.CloseIdleConnections()
+	return nil
+}
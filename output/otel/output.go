@@ -0,0 +1,130 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package otel implements an output that exports the metrics with OTLP, over gRPC or HTTP,
+// to the OpenTelemetry collectors and the backends that support it.
+package otel
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.k6.io/k6/output"
+)
+
+// New creates a new OpenTelemetry output.
+func New(params output.Params) (output.Output, error) {
+	return newOutput(params)
+}
+
+func newOutput(params output.Params) (*Output, error) {
+	conf, err := getConsolidatedConfig(params.JSONConfig, params.Environment, params.ConfigArgument)
+	if err != nil {
+		return nil, err
+	}
+	logger := params.Logger.WithFields(logrus.Fields{"output": "otel"})
+
+	return &Output{
+		config: conf,
+		logger: logger,
+	}, nil
+}
+
+var _ output.Output = &Output{}
+
+// Output aggregates the samples and exports them to an OTLP endpoint at every push interval.
+type Output struct {
+	output.SampleBuffer
+
+	periodicFlusher *output.PeriodicFlusher
+
+	config config
+
+	logger     logrus.FieldLogger
+	exporter   exporter
+	aggregator *aggregator
+}
+
+// Description returns a human-readable description of the output.
+func (o *Output) Description() string {
+	return fmt.Sprintf("otel (%s %s)", o.config.Protocol.String, o.config.Endpoint.String)
+}
+
+// Start creates the exporter and starts the goroutine for metric flushing.
+func (o *Output) Start() error {
+	o.logger.Debug("Starting...")
+
+	var err error
+	if o.exporter, err = newExporter(o.config); err != nil {
+		return err
+	}
+	o.aggregator = newAggregator(o.config, time.Now())
+
+	pf, err := o.NewSequentialPeriodicFlusher(o.config.PushInterval.TimeDuration(), o.flushMetrics)
+	if err != nil {
+		return err
+	}
+	o.logger.Debug("Started!")
+	o.periodicFlusher = pf
+
+	return nil
+}
+
+// Stop flushes any remaining metrics and stops the goroutine.
+func (o *Output) Stop() error {
+	o.logger.Debug("Stopping...")
+	defer o.logger.Debug("Stopped!")
+	o.periodicFlusher.Stop()
+	return o.exporter.close()
+}
+
+func (o *Output) flushMetrics() {
+	var count int
+	for _, sc := range o.GetBufferedSamples() {
+		for _, sample := range sc.GetSamples() {
+			o.aggregator.add(sample)
+			count++
+		}
+	}
+
+	start := time.Now()
+	request := o.aggregator.export(start)
+	if request == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), o.config.Timeout.TimeDuration())
+	defer cancel()
+	response, err := o.exporter.export(ctx, request)
+	if err != nil {
+		o.logger.WithError(err).Error("Couldn't export the metrics")
+		return
+	}
+	rejected, message, err := decodeExportResponse(response)
+	if err != nil {
+		o.logger.WithError(err).Warn("Couldn't decode the response of the OTLP endpoint")
+	} else if rejected > 0 || message != "" {
+		o.logger.WithField("rejected", rejected).Warnf("The OTLP endpoint partially accepted the metrics: %s", message)
+	}
+	o.logger.WithField("t", time.Since(start)).WithField("samples", count).Debug("Exported the metrics")
+}
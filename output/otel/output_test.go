@@ -0,0 +1,285 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package otel
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/output"
+	"go.k6.io/k6/output/internal/rawproto"
+	"go.k6.io/k6/stats"
+)
+
+func attributes(t *testing.T, kvs []rawproto.Message) map[string]string {
+	res := make(map[string]string, len(kvs))
+	for _, kv := range kvs {
+		res[kv.String(fieldKey)] = rawproto.Decode(t, kv[fieldValue][0].([]byte)).String(fieldStringValue)
+	}
+	return res
+}
+
+// exported decodes an ExportMetricsServiceRequest, it returns the resource attributes and the metrics by name.
+func exported(t *testing.T, request []byte) (map[string]string, map[string]rawproto.Message) {
+	resourceMetrics := rawproto.Decode(t, request).Messages(t, fieldResourceMetrics)
+	require.Len(t, resourceMetrics, 1)
+	resource := resourceMetrics[0].Messages(t, fieldResource)[0]
+	scopeMetrics := resourceMetrics[0].Messages(t, fieldScopeMetrics)
+	require.Len(t, scopeMetrics, 1)
+	assert.Equal(t, "k6", scopeMetrics[0].Messages(t, fieldScope)[0].String(fieldScopeName))
+
+	metrics := map[string]rawproto.Message{}
+	for _, m := range scopeMetrics[0].Messages(t, fieldMetrics) {
+		metrics[m.String(fieldMetricName)] = m
+	}
+	return attributes(t, resource.Messages(t, fieldResourceAttributes)), metrics
+}
+
+func TestAggregator(t *testing.T) {
+	t.Parallel()
+	counter := stats.New("my_counter", stats.Counter, stats.Data)
+	gauge := stats.New("my_gauge", stats.Gauge)
+	gauge.Unit = "requests"
+	rate := stats.New("my_rate", stats.Rate)
+	trend := stats.New("my_trend", stats.Trend, stats.Time)
+	histogram := stats.New("my_histogram", stats.Histogram)
+	histogram.Buckets = []float64{1, 100}
+	tags := stats.IntoSampleTags(&map[string]string{"method": "GET"})
+	now := time.Now()
+	samples := []stats.Sample{
+		{Metric: counter, Tags: tags, Time: now, Value: 10},
+		{Metric: counter, Tags: tags, Time: now, Value: 5},
+		{Metric: counter, Tags: stats.IntoSampleTags(&map[string]string{"method": "POST"}), Time: now, Value: 1},
+		{Metric: gauge, Tags: tags, Time: now, Value: 3},
+		{Metric: gauge, Tags: tags, Time: now, Value: 7},
+		{Metric: rate, Tags: tags, Time: now, Value: 1},
+		{Metric: rate, Tags: tags, Time: now, Value: 0},
+		{Metric: rate, Tags: tags, Time: now, Value: 1},
+		{Metric: trend, Tags: tags, Time: now, Value: 5},
+		{Metric: trend, Tags: tags, Time: now, Value: 7},
+		{Metric: trend, Tags: tags, Time: now, Value: 30},
+		{Metric: histogram, Tags: tags, Time: now, Value: 50},
+	}
+
+	for _, temporality := range []string{temporalityCumulative, temporalityDelta} {
+		temporality := temporality
+		t.Run(temporality, func(t *testing.T) {
+			t.Parallel()
+			conf := newConfig()
+			conf.Temporality.String = temporality
+			conf.HistogramBuckets = []float64{5, 10, 20}
+			conf.ResourceAttributes = keyValues{"team": "perf"}
+			a := newAggregator(conf, now)
+			for _, s := range samples {
+				a.add(s)
+			}
+
+			resource, metrics := exported(t, a.export(now.Add(time.Second)))
+			assert.Equal(t, "k6", resource["service.name"])
+			assert.Equal(t, "perf", resource["team"])
+			require.Len(t, metrics, 6)
+
+			sum := metrics["k6_my_counter"].Messages(t, fieldSum)[0]
+			assert.Equal(t, "By", metrics["k6_my_counter"].String(fieldMetricUnit))
+			assert.Equal(t, []interface{}{uint64(1)}, sum[fieldIsMonotonic])
+			points := sum.Messages(t, fieldDataPoints)
+			require.Len(t, points, 2)
+			assert.Equal(t, 15.0, points[0].Double(fieldNumberAsDouble))
+			assert.Equal(t, map[string]string{"method": "GET"},
+				attributes(t, points[0].Messages(t, fieldNumberAttributes)))
+			assert.Equal(t, 1.0, points[1].Double(fieldNumberAsDouble))
+			assert.Equal(t, []interface{}{uint64(now.UnixNano())}, points[0][fieldNumberStartTime])
+
+			assert.Equal(t, "{requests}", metrics["k6_my_gauge"].String(fieldMetricUnit))
+			gaugePoints := metrics["k6_my_gauge"].Messages(t, fieldGauge)[0].Messages(t, fieldDataPoints)
+			assert.Equal(t, 7.0, gaugePoints[0].Double(fieldNumberAsDouble))
+
+			occurred := metrics["k6_my_rate.occurred"].Messages(t, fieldSum)[0].Messages(t, fieldDataPoints)
+			assert.Equal(t, 2.0, occurred[0].Double(fieldNumberAsDouble))
+			total := metrics["k6_my_rate.total"].Messages(t, fieldSum)[0].Messages(t, fieldDataPoints)
+			assert.Equal(t, 3.0, total[0].Double(fieldNumberAsDouble))
+
+			histogram := metrics["k6_my_trend"].Messages(t, fieldHistogram)[0]
+			assert.Equal(t, "ms", metrics["k6_my_trend"].String(fieldMetricUnit))
+			point := histogram.Messages(t, fieldDataPoints)[0]
+			assert.Equal(t, []interface{}{uint64(3)}, point[fieldHistogramCount])
+			assert.Equal(t, 42.0, point.Double(fieldHistogramSum))
+			assert.Equal(t, 5.0, point.Double(fieldHistogramMin))
+			assert.Equal(t, 30.0, point.Double(fieldHistogramMax))
+			counts := point[fieldHistogramBucketCounts][0].([]byte)
+			require.Len(t, counts, 4*8)
+			for i, expected := range []uint64{1, 1, 0, 1} {
+				v, _ := protowire.ConsumeFixed64(counts[i*8:])
+				assert.Equal(t, expected, v, i)
+			}
+
+			// the Histograms have their own buckets
+			point = metrics["k6_my_histogram"].Messages(t, fieldHistogram)[0].Messages(t, fieldDataPoints)[0]
+			counts = point[fieldHistogramBucketCounts][0].([]byte)
+			require.Len(t, counts, 3*8)
+			for i, expected := range []uint64{0, 1, 0} {
+				v, _ := protowire.ConsumeFixed64(counts[i*8:])
+				assert.Equal(t, expected, v, i)
+			}
+			bounds := point[fieldHistogramExplicitBounds][0].([]byte)
+			require.Len(t, bounds, 2*8)
+			v, _ := protowire.ConsumeFixed64(bounds[8:])
+			assert.Equal(t, 100.0, math.Float64frombits(v))
+
+			expectedTemporality := uint64(aggregationTemporalityCumulative)
+			if temporality == temporalityDelta {
+				expectedTemporality = aggregationTemporalityDelta
+			}
+			assert.Equal(t, []interface{}{expectedTemporality}, histogram[fieldAggregationTemporality])
+			assert.Equal(t, []interface{}{expectedTemporality}, sum[fieldAggregationTemporality])
+
+			// the second export has the same series with the cumulative temporality, and none with the delta one
+			second := a.export(now.Add(2 * time.Second))
+			if temporality == temporalityDelta {
+				assert.Nil(t, second)
+				return
+			}
+			_, metrics = exported(t, second)
+			points = metrics["k6_my_counter"].Messages(t, fieldSum)[0].Messages(t, fieldDataPoints)
+			assert.Equal(t, 15.0, points[0].Double(fieldNumberAsDouble))
+		})
+	}
+}
+
+func TestOutputHTTP(t *testing.T) {
+	t.Parallel()
+	var (
+		mu       sync.Mutex
+		requests [][]byte
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/metrics", r.URL.Path)
+		assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		mu.Lock()
+		requests = append(requests, body)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	out, err := newOutput(output.Params{
+		Logger: testutils.NewLogger(t),
+		JSONConfig: json.RawMessage(`{"protocol":"http","endpoint":"` + srv.URL + `",` +
+			`"headers":{"Authorization":"Bearer token"},"pushInterval":"1h"}`),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "otel (http "+srv.URL+")", out.Description())
+	require.NoError(t, out.Start())
+
+	counter := stats.New("my_counter", stats.Counter)
+	out.AddMetricSamples([]stats.SampleContainer{stats.Sample{
+		Metric: counter, Tags: stats.IntoSampleTags(&map[string]string{}), Time: time.Now(), Value: 3,
+	}})
+	require.NoError(t, out.Stop())
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, requests, 1)
+	_, metrics := exported(t, requests[0])
+	points := metrics["k6_my_counter"].Messages(t, fieldSum)[0].Messages(t, fieldDataPoints)
+	assert.Equal(t, 3.0, points[0].Double(fieldNumberAsDouble))
+}
+
+func TestOutputGRPC(t *testing.T) {
+	t.Parallel()
+	requests := make(chan []byte, 1)
+	srv := grpc.NewServer(
+		grpc.ForceServerCodec(rawproto.Codec{}),
+		grpc.UnknownServiceHandler(func(_ interface{}, stream grpc.ServerStream) error {
+			method, _ := grpc.MethodFromServerStream(stream)
+			assert.Equal(t, grpcExportMethod, method)
+			md, _ := metadata.FromIncomingContext(stream.Context())
+			assert.Equal(t, []string{"k6"}, md.Get("x-scope"))
+
+			var request []byte
+			if err := stream.RecvMsg(&request); err != nil {
+				return err
+			}
+			requests <- request
+
+			// a partial success, with one rejected data point
+			var partial []byte
+			partial = appendVarint(partial, fieldRejectedDataPoints, 1)
+			partial = rawproto.AppendString(partial, fieldErrorMessage, "too old")
+			response := rawproto.AppendMessage(nil, fieldPartialSuccess, partial)
+			return stream.SendMsg(&response)
+		}),
+	)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	logger := testutils.NewLogger(t)
+	hook := &testutils.SimpleLogrusHook{HookedLevels: []logrus.Level{logrus.WarnLevel}}
+	logger.AddHook(hook)
+	out, err := newOutput(output.Params{
+		Logger:         logger,
+		JSONConfig:     json.RawMessage(`{"insecure":true,"headers":{"x-scope":"k6"},"pushInterval":"1h"}`),
+		Environment:    map[string]string{"K6_OTEL_SERVICE_NAME": "checkout"},
+		ConfigArgument: lis.Addr().String(),
+	})
+	require.NoError(t, err)
+	require.NoError(t, out.Start())
+
+	trend := stats.New("my_trend", stats.Trend, stats.Time)
+	out.AddMetricSamples([]stats.SampleContainer{stats.Sample{
+		Metric: trend, Tags: stats.IntoSampleTags(&map[string]string{}), Time: time.Now(), Value: 12,
+	}})
+	require.NoError(t, out.Stop())
+
+	request := <-requests
+	resource, metrics := exported(t, request)
+	assert.Equal(t, "checkout", resource["service.name"])
+	point := metrics["k6_my_trend"].Messages(t, fieldHistogram)[0].Messages(t, fieldDataPoints)[0]
+	assert.Equal(t, 12.0, point.Double(fieldHistogramSum))
+
+	var warned bool
+	for _, e := range hook.Drain() {
+		if e.Message == "The OTLP endpoint partially accepted the metrics: too old" {
+			warned = true
+			assert.Equal(t, int64(1), e.Data["rejected"])
+		}
+	}
+	assert.True(t, warned)
+}
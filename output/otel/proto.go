@@ -0,0 +1,200 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package otel
+
+import (
+	"errors"
+	"math"
+	"sort"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"go.k6.io/k6/output/internal/rawproto"
+)
+
+// The messages of opentelemetry-proto are encoded by hand, with the field numbers of
+// opentelemetry/proto/collector/metrics/v1/metrics_service.proto and of the protos it imports.
+
+// ExportMetricsServiceRequest and ExportMetricsServiceResponse
+const (
+	fieldResourceMetrics = 1
+	fieldPartialSuccess  = 1
+
+	fieldRejectedDataPoints = 1
+	fieldErrorMessage       = 2
+)
+
+// ResourceMetrics, Resource, ScopeMetrics and InstrumentationScope
+const (
+	fieldResource     = 1
+	fieldScopeMetrics = 2
+
+	fieldResourceAttributes = 1
+
+	fieldScope   = 1
+	fieldMetrics = 2
+
+	fieldScopeName    = 1
+	fieldScopeVersion = 2
+)
+
+// KeyValue and AnyValue
+const (
+	fieldKey   = 1
+	fieldValue = 2
+
+	fieldStringValue = 1
+)
+
+// Metric, Gauge, Sum and Histogram
+const (
+	fieldMetricName = 1
+	fieldMetricUnit = 3
+	fieldGauge      = 5
+	fieldSum        = 7
+	fieldHistogram  = 9
+
+	fieldDataPoints             = 1
+	fieldAggregationTemporality = 2
+	fieldIsMonotonic            = 3
+)
+
+// NumberDataPoint
+const (
+	fieldNumberStartTime  = 2
+	fieldNumberTime       = 3
+	fieldNumberAsDouble   = 4
+	fieldNumberAttributes = 7
+)
+
+// HistogramDataPoint
+const (
+	fieldHistogramStartTime      = 2
+	fieldHistogramTime           = 3
+	fieldHistogramCount          = 4
+	fieldHistogramSum            = 5
+	fieldHistogramBucketCounts   = 6
+	fieldHistogramExplicitBounds = 7
+	fieldHistogramAttributes     = 9
+	fieldHistogramMin            = 11
+	fieldHistogramMax            = 12
+)
+
+// the values of AggregationTemporality
+const (
+	aggregationTemporalityDelta      = 1
+	aggregationTemporalityCumulative = 2
+)
+
+var errInvalidResponse = errors.New("invalid ExportMetricsServiceResponse")
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func appendFixed64(b []byte, num protowire.Number, v uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, v)
+}
+
+func appendDouble(b []byte, num protowire.Number, v float64) []byte {
+	return appendFixed64(b, num, math.Float64bits(v))
+}
+
+// appendAttributes appends the KeyValues with string values of the attributes, sorted by their keys.
+func appendAttributes(b []byte, num protowire.Number, attributes map[string]string) []byte {
+	keys := make([]string, 0, len(attributes))
+	for k := range attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		var value []byte
+		value = protowire.AppendTag(value, fieldStringValue, protowire.BytesType)
+		value = protowire.AppendString(value, attributes[k])
+
+		var kv []byte
+		kv = rawproto.AppendString(kv, fieldKey, k)
+		kv = rawproto.AppendMessage(kv, fieldValue, value)
+		b = rawproto.AppendMessage(b, num, kv)
+	}
+	return b
+}
+
+// decodeExportResponse returns the rejected data points and the error message of the partial success
+// of an ExportMetricsServiceResponse, which are zero for a full success.
+func decodeExportResponse(b []byte) (rejected int64, message string, err error) {
+	partial, err := findField(b, fieldPartialSuccess)
+	if err != nil || partial == nil {
+		return 0, "", err
+	}
+	for len(partial) > 0 {
+		num, typ, n := protowire.ConsumeTag(partial)
+		if n < 0 {
+			return 0, "", errInvalidResponse
+		}
+		partial = partial[n:]
+		switch {
+		case num == fieldRejectedDataPoints && typ == protowire.VarintType:
+			v, m := protowire.ConsumeVarint(partial)
+			if m < 0 {
+				return 0, "", errInvalidResponse
+			}
+			rejected, n = int64(v), m
+		case num == fieldErrorMessage && typ == protowire.BytesType:
+			v, m := protowire.ConsumeString(partial)
+			if m < 0 {
+				return 0, "", errInvalidResponse
+			}
+			message, n = v, m
+		default:
+			if n = protowire.ConsumeFieldValue(num, typ, partial); n < 0 {
+				return 0, "", errInvalidResponse
+			}
+		}
+		partial = partial[n:]
+	}
+	return rejected, message, nil
+}
+
+// findField returns the bytes of the last occurrence of a length-delimited field, or nil if there's none.
+func findField(b []byte, field protowire.Number) ([]byte, error) {
+	var found []byte
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		b = b[n:]
+		if num == field && typ == protowire.BytesType {
+			v, m := protowire.ConsumeBytes(b)
+			if m < 0 {
+				return nil, protowire.ParseError(m)
+			}
+			found, n = v, m
+		} else if n = protowire.ConsumeFieldValue(num, typ, b); n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		b = b[n:]
+	}
+	return found, nil
+}
@@ -0,0 +1,142 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package parquet
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mstoykov/envconfig"
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/lib/types"
+)
+
+const (
+	compressionNone = "none"
+	compressionGzip = "gzip"
+)
+
+// Config is the config for the parquet output
+type Config struct {
+	FileName null.String `json:"fileName" envconfig:"K6_PARQUET_FILENAME"`
+	// SaveInterval is the interval of the row groups
+	SaveInterval types.NullDuration `json:"saveInterval" envconfig:"K6_PARQUET_SAVE_INTERVAL"`
+	Compression  null.String        `json:"compression" envconfig:"K6_PARQUET_COMPRESSION"`
+}
+
+// NewConfig creates a new Config instance with default values for some fields.
+func NewConfig() Config {
+	return Config{
+		FileName:     null.NewString("results.parquet", false),
+		SaveInterval: types.NewNullDuration(10*time.Second, false),
+		Compression:  null.NewString(compressionGzip, false),
+	}
+}
+
+// Apply merges two configs by overwriting properties in the old config
+func (c Config) Apply(cfg Config) Config {
+	if cfg.FileName.Valid {
+		c.FileName = cfg.FileName
+	}
+	if cfg.SaveInterval.Valid {
+		c.SaveInterval = cfg.SaveInterval
+	}
+	if cfg.Compression.Valid {
+		c.Compression = cfg.Compression
+	}
+	return c
+}
+
+// ParseArg takes an arg string, which is either the file name or key=value pairs, and converts it to a config
+func ParseArg(arg string) (Config, error) {
+	c := Config{}
+
+	if !strings.Contains(arg, "=") {
+		c.FileName = null.StringFrom(arg)
+		return c, nil
+	}
+
+	for _, pair := range strings.Split(arg, ",") {
+		r := strings.SplitN(pair, "=", 2)
+		if len(r) != 2 {
+			return c, fmt.Errorf("couldn't parse %q as argument for parquet output", arg)
+		}
+		switch r[0] {
+		case "fileName":
+			c.FileName = null.StringFrom(r[1])
+		case "saveInterval":
+			if err := c.SaveInterval.UnmarshalText([]byte(r[1])); err != nil {
+				return c, err
+			}
+		case "compression":
+			c.Compression = null.StringFrom(r[1])
+		default:
+			return c, fmt.Errorf("unknown key %q as argument for parquet output", r[0])
+		}
+	}
+
+	return c, nil
+}
+
+// GetConsolidatedConfig combines {default config values + JSON config +
+// environment vars + arg config values}, and returns the final result.
+func GetConsolidatedConfig(jsonRawConf json.RawMessage, env map[string]string, arg string) (Config, error) {
+	result := NewConfig()
+	if jsonRawConf != nil {
+		jsonConf := Config{}
+		if err := json.Unmarshal(jsonRawConf, &jsonConf); err != nil {
+			return result, err
+		}
+		result = result.Apply(jsonConf)
+	}
+
+	envConfig := Config{}
+	if err := envconfig.Process("", &envConfig, func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}); err != nil {
+		return result, err
+	}
+	result = result.Apply(envConfig)
+
+	if arg != "" {
+		argConf, err := ParseArg(arg)
+		if err != nil {
+			return result, err
+		}
+		result = result.Apply(argConf)
+	}
+
+	switch result.Compression.String {
+	case compressionNone, compressionGzip:
+	default:
+		return result, fmt.Errorf("unsupported parquet compression '%s', supported ones are 'none' and 'gzip'",
+			result.Compression.String)
+	}
+	if result.FileName.String == "" {
+		return result, errors.New("the parquet output needs a file name")
+	}
+	return result, nil
+}
@@ -0,0 +1,105 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package parquet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/lib/types"
+)
+
+func TestNewConfig(t *testing.T) {
+	t.Parallel()
+	config := NewConfig()
+	assert.Equal(t, "results.parquet", config.FileName.String)
+	assert.Equal(t, "10s", config.SaveInterval.String())
+	assert.Equal(t, "gzip", config.Compression.String)
+}
+
+func TestParseArg(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		config      Config
+		expectedErr bool
+	}{
+		"results.parquet": {
+			config: Config{FileName: null.StringFrom("results.parquet")},
+		},
+		"fileName=test.parquet,saveInterval=5s,compression=none": {
+			config: Config{
+				FileName:     null.StringFrom("test.parquet"),
+				SaveInterval: types.NullDurationFrom(5 * time.Second),
+				Compression:  null.StringFrom("none"),
+			},
+		},
+		"saveInterval=5s": {
+			config: Config{SaveInterval: types.NullDurationFrom(5 * time.Second)},
+		},
+		"filename=test.parquet": {
+			expectedErr: true,
+		},
+		"saveInterval=5x": {
+			expectedErr: true,
+		},
+	}
+
+	for arg, testCase := range cases {
+		arg, testCase := arg, testCase
+		t.Run(arg, func(t *testing.T) {
+			t.Parallel()
+			config, err := ParseArg(arg)
+			if testCase.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, testCase.config, config)
+		})
+	}
+}
+
+func TestGetConsolidatedConfig(t *testing.T) {
+	t.Parallel()
+
+	config, err := GetConsolidatedConfig(
+		[]byte(`{"fileName":"json.parquet","compression":"none"}`),
+		map[string]string{"K6_PARQUET_SAVE_INTERVAL": "2s", "K6_PARQUET_FILENAME": "env.parquet"},
+		"")
+	require.NoError(t, err)
+	assert.Equal(t, "env.parquet", config.FileName.String)
+	assert.Equal(t, "2s", config.SaveInterval.String())
+	assert.Equal(t, "none", config.Compression.String)
+
+	config, err = GetConsolidatedConfig(nil, map[string]string{"K6_PARQUET_FILENAME": "env.parquet"}, "arg.parquet")
+	require.NoError(t, err)
+	assert.Equal(t, "arg.parquet", config.FileName.String)
+
+	_, err = GetConsolidatedConfig(nil, nil, "compression=snappy")
+	require.EqualError(t, err, "unsupported parquet compression 'snappy', supported ones are 'none' and 'gzip'")
+
+	_, err = GetConsolidatedConfig(nil, nil, "fileName=")
+	require.EqualError(t, err, "the parquet output needs a file name")
+}
@@ -0,0 +1,195 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package parquet implements an output that writes the samples to a parquet file, with a row per sample
+// and a column per system tag, like the csv output, so they can be analyzed with columnar tools.
+package parquet
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+
+	"go.k6.io/k6/output"
+	"go.k6.io/k6/stats"
+)
+
+// Output implements the lib.Output interface for saving to parquet files.
+type Output struct {
+	output.SampleBuffer
+
+	periodicFlusher *output.PeriodicFlusher
+
+	logger logrus.FieldLogger
+	config Config
+	fs     afero.Fs
+	file   afero.File
+	writer *fileWriter
+
+	// the columns are the name, timestamp and value of the metric, one per system tag and the extra tags
+	columns     []*column
+	resTags     []string
+	ignoredTags map[string]bool
+}
+
+// New creates a new instance of the parquet output
+func New(params output.Params) (output.Output, error) {
+	return newOutput(params)
+}
+
+func newOutput(params output.Params) (*Output, error) {
+	config, err := GetConsolidatedConfig(params.JSONConfig, params.Environment, params.ConfigArgument)
+	if err != nil {
+		return nil, err
+	}
+
+	resTags := []string{}
+	ignoredTags := map[string]bool{}
+	for tag, enabled := range params.ScriptOptions.SystemTags.Map() {
+		if enabled {
+			resTags = append(resTags, tag)
+		} else {
+			ignoredTags[tag] = true
+		}
+	}
+	sort.Strings(resTags)
+
+	columns := []*column{
+		{name: "metric_name", typ: typeByteArray, converted: convertedUTF8},
+		{name: "timestamp", typ: typeInt64, converted: convertedTimestampMicros},
+		{name: "metric_value", typ: typeDouble, converted: -1},
+	}
+	for _, tag := range resTags {
+		columns = append(columns, &column{name: tag, typ: typeByteArray, converted: convertedUTF8, optional: true})
+	}
+	columns = append(columns,
+		&column{name: "extra_tags", typ: typeByteArray, converted: convertedUTF8, optional: true})
+
+	return &Output{
+		logger: params.Logger.WithFields(logrus.Fields{
+			"output":   "parquet",
+			"filename": config.FileName.String,
+		}),
+		config:      config,
+		fs:          params.FS,
+		columns:     columns,
+		resTags:     resTags,
+		ignoredTags: ignoredTags,
+	}, nil
+}
+
+// Description returns a human-readable description of the output.
+func (o *Output) Description() string {
+	return fmt.Sprintf("parquet (%s)", o.config.FileName.String)
+}
+
+// Start creates the file and starts a new output.PeriodicFlusher, which writes a row group at every save interval.
+func (o *Output) Start() error {
+	o.logger.Debug("Starting...")
+
+	var err error
+	if o.file, err = o.fs.Create(o.config.FileName.String); err != nil {
+		return err
+	}
+	if o.writer, err = newFileWriter(o.file, o.columns, o.config.Compression.String); err != nil {
+		_ = o.file.Close()
+		return err
+	}
+
+	pf, err := o.NewSequentialPeriodicFlusher(o.config.SaveInterval.TimeDuration(), o.flushMetrics)
+	if err != nil {
+		_ = o.file.Close()
+		return err
+	}
+	o.logger.Debug("Started!")
+	o.periodicFlusher = pf
+
+	return nil
+}
+
+// Stop writes the remaining samples and the footer of the file, and closes it.
+func (o *Output) Stop() error {
+	o.logger.Debug("Stopping...")
+	defer o.logger.Debug("Stopped!")
+	o.periodicFlusher.Stop()
+	if err := o.writer.close(); err != nil {
+		_ = o.file.Close()
+		return err
+	}
+	return o.file.Close()
+}
+
+// flushMetrics writes the buffered samples as a row group.
+func (o *Output) flushMetrics() {
+	start := time.Now()
+	var count int
+	for _, sc := range o.GetBufferedSamples() {
+		for _, sample := range sc.GetSamples() {
+			o.appendSample(sample)
+			count++
+		}
+	}
+	if count == 0 {
+		return
+	}
+	if err := o.writer.writeRowGroup(); err != nil {
+		o.logger.WithError(err).Error("Parquet: Error writing a row group to the file")
+		return
+	}
+	o.logger.WithField("t", time.Since(start)).WithField("count", count).Debug("Wrote a row group")
+}
+
+func (o *Output) appendSample(sample stats.Sample) {
+	o.columns[0].appendString(sample.Metric.Name)
+	o.columns[1].appendInt64(sample.Time.UnixNano() / int64(time.Microsecond))
+	o.columns[2].appendDouble(sample.Value)
+
+	tags := sample.Tags.CloneTags()
+	for i, tag := range o.resTags {
+		if v, ok := tags[tag]; ok {
+			o.columns[3+i].appendString(v)
+		} else {
+			o.columns[3+i].appendNull()
+		}
+	}
+
+	// the other tags are joined like in the csv output, sorted by their keys
+	extra := make([]string, 0, len(tags))
+	for tag, v := range tags {
+		if _, ok := o.ignoredTags[tag]; ok {
+			continue
+		}
+		if i := sort.SearchStrings(o.resTags, tag); i < len(o.resTags) && o.resTags[i] == tag {
+			continue
+		}
+		extra = append(extra, tag+"="+v)
+	}
+	extraColumn := o.columns[len(o.columns)-1]
+	if len(extra) == 0 {
+		extraColumn.appendNull()
+		return
+	}
+	sort.Strings(extra)
+	extraColumn.appendString(strings.Join(extra, "&"))
+}
@@ -0,0 +1,260 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/output"
+	"go.k6.io/k6/stats"
+)
+
+// compactReader decodes thrift structs of the compact protocol into maps of the field ids to their values,
+// which are int64s, strings, slices and maps, so that the written metadata can be checked.
+type compactReader struct {
+	t *testing.T
+	b []byte
+}
+
+func (r *compactReader) varint() uint64 {
+	v, n := binary.Uvarint(r.b)
+	require.Greater(r.t, n, 0)
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *compactReader) zigzag() int64 {
+	v := r.varint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *compactReader) value(typ byte) interface{} {
+	switch typ {
+	case compactI32, compactI64:
+		return r.zigzag()
+	case compactBinary:
+		size := r.varint()
+		v := string(r.b[:size])
+		r.b = r.b[size:]
+		return v
+	case compactList:
+		header := r.b[0]
+		r.b = r.b[1:]
+		size := uint64(header >> 4)
+		if size == 15 {
+			size = r.varint()
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			list[i] = r.value(header & 0x0f)
+		}
+		return list
+	case compactStruct:
+		return r.structure()
+	}
+	r.t.Fatalf("unexpected thrift type %d", typ)
+	return nil
+}
+
+func (r *compactReader) structure() map[int16]interface{} {
+	s := map[int16]interface{}{}
+	var id int16
+	for {
+		header := r.b[0]
+		r.b = r.b[1:]
+		if header == 0 {
+			return s
+		}
+		if delta := int16(header >> 4); delta != 0 {
+			id += delta
+		} else {
+			id = int16(r.zigzag())
+		}
+		s[id] = r.value(header & 0x0f)
+	}
+}
+
+type readColumn struct {
+	name   string
+	values []interface{}
+}
+
+// readFile checks the structure of the parquet file and returns its columns, with nil for the null values.
+func readFile(t *testing.T, data []byte, numRowGroups int) []readColumn {
+	t.Helper()
+	require.Equal(t, magic, string(data[:4]))
+	require.Equal(t, magic, string(data[len(data)-4:]))
+	footerSize := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footer := &compactReader{t: t, b: data[len(data)-8-footerSize : len(data)-8]}
+	meta := footer.structure()
+	require.Empty(t, footer.b)
+
+	schema := meta[2].([]interface{})
+	columns := make([]readColumn, len(schema)-1)
+	for i := range columns {
+		columns[i].name = schema[i+1].(map[int16]interface{})[4].(string)
+	}
+
+	rowGroups := meta[4].([]interface{})
+	require.Len(t, rowGroups, numRowGroups)
+	var numRows int64
+	for _, rg := range rowGroups {
+		rg := rg.(map[int16]interface{})
+		numRows += rg[3].(int64)
+		for i, chunk := range rg[1].([]interface{}) {
+			chunkMeta := chunk.(map[int16]interface{})[3].(map[int16]interface{})
+			optional := schema[i+1].(map[int16]interface{})[3].(int64) == repetitionOptional
+			page := &compactReader{t: t, b: data[chunkMeta[9].(int64):]}
+			header := page.structure()
+			require.Equal(t, chunkMeta[7].(int64), int64(len(data)-len(page.b))-chunkMeta[9].(int64)+header[3].(int64))
+			values := page.b[:header[3].(int64)]
+			if chunkMeta[4].(int64) == codecGzip {
+				gz, err := gzip.NewReader(bytes.NewReader(values))
+				require.NoError(t, err)
+				values, err = ioutil.ReadAll(gz)
+				require.NoError(t, err)
+			}
+			require.Len(t, values, int(header[2].(int64)))
+			columns[i].values = append(columns[i].values,
+				decodePage(t, values, schema[i+1].(map[int16]interface{})[1].(int64), optional, rg[3].(int64))...)
+		}
+	}
+	require.Equal(t, meta[3].(int64), numRows)
+	return columns
+}
+
+func decodePage(t *testing.T, b []byte, typ int64, optional bool, numValues int64) []interface{} {
+	defined := make([]bool, 0, numValues)
+	if optional {
+		size := binary.LittleEndian.Uint32(b)
+		levels := &compactReader{t: t, b: b[4 : 4+size]}
+		b = b[4+size:]
+		for len(levels.b) > 0 {
+			run := levels.varint() >> 1
+			for i := uint64(0); i < run; i++ {
+				defined = append(defined, levels.b[0] == 1)
+			}
+			levels.b = levels.b[1:]
+		}
+	} else {
+		for i := int64(0); i < numValues; i++ {
+			defined = append(defined, true)
+		}
+	}
+	require.Len(t, defined, int(numValues))
+
+	values := make([]interface{}, numValues)
+	for i := range values {
+		if !defined[i] {
+			continue
+		}
+		switch typ {
+		case typeByteArray:
+			size := binary.LittleEndian.Uint32(b)
+			values[i] = string(b[4 : 4+size])
+			b = b[4+size:]
+		case typeInt64:
+			values[i] = int64(binary.LittleEndian.Uint64(b))
+			b = b[8:]
+		case typeDouble:
+			values[i] = math.Float64frombits(binary.LittleEndian.Uint64(b))
+			b = b[8:]
+		}
+	}
+	require.Empty(t, b)
+	return values
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	for _, compression := range []string{compressionNone, compressionGzip} {
+		compression := compression
+		t.Run(compression, func(t *testing.T) {
+			t.Parallel()
+			mem := afero.NewMemMapFs()
+			out, err := newOutput(output.Params{
+				Logger:         testutils.NewLogger(t),
+				FS:             mem,
+				ConfigArgument: "fileName=test.parquet,saveInterval=50ms,compression=" + compression,
+				ScriptOptions: lib.Options{
+					SystemTags: stats.NewSystemTagSet(stats.TagError | stats.TagCheck),
+				},
+			})
+			require.NoError(t, err)
+			require.Equal(t, "parquet (test.parquet)", out.Description())
+
+			metric := stats.New("my_metric", stats.Gauge)
+			require.NoError(t, out.Start())
+			out.AddMetricSamples([]stats.SampleContainer{
+				stats.Sample{
+					Time:   time.Unix(1562324643, 0),
+					Metric: metric,
+					Value:  1,
+					Tags: stats.NewSampleTags(map[string]string{
+						"check": "val1", "url": "val2", "error": "val3",
+					}),
+				},
+			})
+			time.Sleep(200 * time.Millisecond)
+			out.AddMetricSamples([]stats.SampleContainer{
+				stats.Sample{
+					Time:   time.Unix(1562324644, 500),
+					Metric: metric,
+					Value:  2.5,
+					Tags:   stats.NewSampleTags(map[string]string{"check": "val1", "name": "val4", "url": "val2"}),
+				},
+				stats.Sample{
+					Time:   time.Unix(1562324645, 0),
+					Metric: metric,
+					Value:  3,
+					Tags:   stats.NewSampleTags(map[string]string{"error": "val3"}),
+				},
+			})
+			require.NoError(t, out.Stop())
+
+			data, err := afero.ReadFile(mem, "test.parquet")
+			require.NoError(t, err)
+			assert.Equal(t, []readColumn{
+				{name: "metric_name", values: []interface{}{"my_metric", "my_metric", "my_metric"}},
+				{name: "timestamp", values: []interface{}{
+					int64(1562324643000000), int64(1562324644000000), int64(1562324645000000),
+				}},
+				{name: "metric_value", values: []interface{}{1.0, 2.5, 3.0}},
+				{name: "check", values: []interface{}{"val1", "val1", nil}},
+				{name: "error", values: []interface{}{"val3", nil, "val3"}},
+				{name: "extra_tags", values: []interface{}{"url=val2", "name=val4&url=val2", nil}},
+			}, readFile(t, data, 2))
+		})
+	}
+}
@@ -0,0 +1,116 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package parquet
+
+import "encoding/binary"
+
+// the types of the thrift compact protocol, in which the metadata of parquet is encoded
+const (
+	compactI32    = 5
+	compactI64    = 6
+	compactBinary = 8
+	compactList   = 9
+	compactStruct = 12
+)
+
+// compactWriter encodes thrift structs with the compact protocol. The structs are written field by field,
+// in the order of their ids, and ended with end().
+type compactWriter struct {
+	b []byte
+	// lastField is the id of the last field of the current struct, the ones of the outer structs are in stack
+	lastField int16
+	stack     []int16
+}
+
+func (w *compactWriter) varint(v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	w.b = append(w.b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+func (w *compactWriter) zigzag(v int64) {
+	w.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (w *compactWriter) field(id int16, typ byte) {
+	if delta := id - w.lastField; delta > 0 && delta <= 15 {
+		w.b = append(w.b, byte(delta)<<4|typ)
+	} else {
+		w.b = append(w.b, typ)
+		w.zigzag(int64(id))
+	}
+	w.lastField = id
+}
+
+func (w *compactWriter) i32(id int16, v int32) {
+	w.field(id, compactI32)
+	w.zigzag(int64(v))
+}
+
+func (w *compactWriter) i64(id int16, v int64) {
+	w.field(id, compactI64)
+	w.zigzag(v)
+}
+
+func (w *compactWriter) binary(id int16, v string) {
+	w.field(id, compactBinary)
+	w.varint(uint64(len(v)))
+	w.b = append(w.b, v...)
+}
+
+// list starts a list of elements of the type, which are written with the element methods.
+func (w *compactWriter) list(id int16, elemType byte, size int) {
+	w.field(id, compactList)
+	if size < 15 {
+		w.b = append(w.b, byte(size)<<4|elemType)
+		return
+	}
+	w.b = append(w.b, 0xf0|elemType)
+	w.varint(uint64(size))
+}
+
+func (w *compactWriter) i32Element(v int32) {
+	w.zigzag(int64(v))
+}
+
+func (w *compactWriter) binaryElement(v string) {
+	w.varint(uint64(len(v)))
+	w.b = append(w.b, v...)
+}
+
+// structField starts a struct in a field, structElement starts one in a list.
+func (w *compactWriter) structField(id int16) {
+	w.field(id, compactStruct)
+	w.structElement()
+}
+
+func (w *compactWriter) structElement() {
+	w.stack = append(w.stack, w.lastField)
+	w.lastField = 0
+}
+
+// end ends the current struct.
+func (w *compactWriter) end() {
+	w.b = append(w.b, 0)
+	if len(w.stack) > 0 {
+		w.lastField = w.stack[len(w.stack)-1]
+		w.stack = w.stack[:len(w.stack)-1]
+	}
+}
@@ -0,0 +1,299 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"math"
+
+	"go.k6.io/k6/lib/consts"
+)
+
+const magic = "PAR1"
+
+// the values of the enums of parquet.thrift that are used
+const (
+	typeInt64     = 2
+	typeDouble    = 5
+	typeByteArray = 6
+
+	repetitionRequired = 0
+	repetitionOptional = 1
+
+	convertedUTF8            = 0
+	convertedTimestampMicros = 10
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	codecUncompressed = 0
+	codecGzip         = 2
+
+	pageTypeData = 0
+)
+
+// column is a column of the schema, which is flat. Its values are buffered until the row group is written.
+type column struct {
+	name      string
+	typ       int32
+	converted int32
+	optional  bool
+
+	// values are PLAIN encoded, the nulls of the optional columns are only in defined
+	values  bytes.Buffer
+	defined []bool
+	count   int
+}
+
+func (c *column) appendString(v string) {
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(len(v)))
+	c.values.Write(size[:])
+	c.values.WriteString(v)
+	c.defined = append(c.defined, true)
+	c.count++
+}
+
+func (c *column) appendNull() {
+	c.defined = append(c.defined, false)
+	c.count++
+}
+
+func (c *column) appendInt64(v int64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], uint64(v))
+	c.values.Write(b[:])
+	c.count++
+}
+
+func (c *column) appendDouble(v float64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], math.Float64bits(v))
+	c.values.Write(b[:])
+	c.count++
+}
+
+func (c *column) reset() {
+	c.values.Reset()
+	c.defined = c.defined[:0]
+	c.count = 0
+}
+
+// columnChunk is the metadata of a column chunk of a row group that was written.
+type columnChunk struct {
+	offset                           int64
+	numValues                        int64
+	uncompressedSize, compressedSize int64
+}
+
+type rowGroup struct {
+	chunks  []columnChunk
+	size    int64
+	numRows int64
+}
+
+// fileWriter writes a parquet file with a flat schema, in row groups of a single page per column, with the PLAIN
+// encoding and the RLE one for the definition levels of the optional columns.
+type fileWriter struct {
+	w       io.Writer
+	offset  int64
+	columns []*column
+	codec   int32
+
+	rowGroups []rowGroup
+	numRows   int64
+}
+
+func newFileWriter(w io.Writer, columns []*column, compression string) (*fileWriter, error) {
+	fw := &fileWriter{w: w, columns: columns, codec: codecUncompressed}
+	if compression == compressionGzip {
+		fw.codec = codecGzip
+	}
+	return fw, fw.write([]byte(magic))
+}
+
+func (fw *fileWriter) write(b []byte) error {
+	n, err := fw.w.Write(b)
+	fw.offset += int64(n)
+	return err
+}
+
+// writeRowGroup writes the buffered values of the columns as a row group, if there are some.
+func (fw *fileWriter) writeRowGroup() error {
+	numRows := fw.columns[0].count
+	if numRows == 0 {
+		return nil
+	}
+	rg := rowGroup{numRows: int64(numRows)}
+	for _, c := range fw.columns {
+		chunk, err := fw.writePage(c)
+		if err != nil {
+			return err
+		}
+		rg.chunks = append(rg.chunks, chunk)
+		rg.size += chunk.uncompressedSize
+		c.reset()
+	}
+	fw.rowGroups = append(fw.rowGroups, rg)
+	fw.numRows += rg.numRows
+	return nil
+}
+
+func (fw *fileWriter) writePage(c *column) (columnChunk, error) {
+	var page bytes.Buffer
+	if c.optional {
+		levels := encodeDefinitionLevels(c.defined)
+		var size [4]byte
+		binary.LittleEndian.PutUint32(size[:], uint32(len(levels)))
+		page.Write(size[:])
+		page.Write(levels)
+	}
+	page.Write(c.values.Bytes())
+
+	data := page.Bytes()
+	if fw.codec == codecGzip {
+		var compressed bytes.Buffer
+		gz := gzip.NewWriter(&compressed)
+		if _, err := gz.Write(data); err != nil {
+			return columnChunk{}, err
+		}
+		if err := gz.Close(); err != nil {
+			return columnChunk{}, err
+		}
+		data = compressed.Bytes()
+	}
+
+	var header compactWriter
+	header.i32(1, pageTypeData)
+	header.i32(2, int32(page.Len()))
+	header.i32(3, int32(len(data)))
+	header.structField(5)
+	header.i32(1, int32(c.count))
+	header.i32(2, encodingPlain)
+	header.i32(3, encodingRLE)
+	header.i32(4, encodingRLE)
+	header.end()
+	header.end()
+
+	chunk := columnChunk{
+		offset:           fw.offset,
+		numValues:        int64(c.count),
+		uncompressedSize: int64(len(header.b) + page.Len()),
+		compressedSize:   int64(len(header.b) + len(data)),
+	}
+	if err := fw.write(header.b); err != nil {
+		return chunk, err
+	}
+	return chunk, fw.write(data)
+}
+
+// encodeDefinitionLevels encodes the levels, which are 1 for the values and 0 for the nulls,
+// with the runs of the RLE/bit-packing hybrid encoding, with a bit width of 1.
+func encodeDefinitionLevels(defined []bool) []byte {
+	var b []byte
+	var buf [binary.MaxVarintLen64]byte
+	for i := 0; i < len(defined); {
+		j := i
+		for j < len(defined) && defined[j] == defined[i] {
+			j++
+		}
+		b = append(b, buf[:binary.PutUvarint(buf[:], uint64(j-i)<<1)]...)
+		if defined[i] {
+			b = append(b, 1)
+		} else {
+			b = append(b, 0)
+		}
+		i = j
+	}
+	return b
+}
+
+// close writes the remaining row group and the footer, with the metadata of the file.
+func (fw *fileWriter) close() error {
+	if err := fw.writeRowGroup(); err != nil {
+		return err
+	}
+
+	var meta compactWriter
+	meta.i32(1, 1)
+	meta.list(2, compactStruct, len(fw.columns)+1)
+	meta.structElement()
+	meta.binary(4, "schema")
+	meta.i32(5, int32(len(fw.columns)))
+	meta.end()
+	for _, c := range fw.columns {
+		meta.structElement()
+		meta.i32(1, c.typ)
+		repetition := int32(repetitionRequired)
+		if c.optional {
+			repetition = repetitionOptional
+		}
+		meta.i32(3, repetition)
+		meta.binary(4, c.name)
+		if c.converted >= 0 {
+			meta.i32(6, c.converted)
+		}
+		meta.end()
+	}
+	meta.i64(3, fw.numRows)
+	meta.list(4, compactStruct, len(fw.rowGroups))
+	for _, rg := range fw.rowGroups {
+		fw.writeRowGroupMeta(&meta, rg)
+	}
+	meta.binary(6, "k6 version "+consts.Version)
+	meta.end()
+
+	footer := meta.b
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(len(footer)))
+	footer = append(footer, size[:]...)
+	return fw.write(append(footer, magic...))
+}
+
+func (fw *fileWriter) writeRowGroupMeta(meta *compactWriter, rg rowGroup) {
+	meta.structElement()
+	meta.list(1, compactStruct, len(rg.chunks))
+	for i, chunk := range rg.chunks {
+		c := fw.columns[i]
+		meta.structElement()
+		meta.i64(2, chunk.offset)
+		meta.structField(3)
+		meta.i32(1, c.typ)
+		meta.list(2, compactI32, 2)
+		meta.i32Element(encodingPlain)
+		meta.i32Element(encodingRLE)
+		meta.list(3, compactBinary, 1)
+		meta.binaryElement(c.name)
+		meta.i32(4, fw.codec)
+		meta.i64(5, chunk.numValues)
+		meta.i64(6, chunk.uncompressedSize)
+		meta.i64(7, chunk.compressedSize)
+		meta.i64(9, chunk.offset)
+		meta.end()
+		meta.end()
+	}
+	meta.i64(2, rg.size)
+	meta.i64(3, rg.numRows)
+	meta.end()
+}
@@ -0,0 +1,101 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/mstoykov/envconfig"
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/lib/types"
+)
+
+// config defines the configuration of the output plugins.
+type config struct {
+	Address      null.String        `json:"address,omitempty" envconfig:"K6_PLUGIN_ADDRESS"`
+	TLS          null.Bool          `json:"tls,omitempty" envconfig:"K6_PLUGIN_TLS"`
+	PushInterval types.NullDuration `json:"pushInterval,omitempty" envconfig:"K6_PLUGIN_PUSH_INTERVAL"`
+	// Timeout is the one of the connection to the plugin, and of the end of its stream after the test run
+	Timeout types.NullDuration `json:"timeout,omitempty" envconfig:"K6_PLUGIN_TIMEOUT"`
+}
+
+// newConfig creates a new config instance with default values for some fields.
+func newConfig() config {
+	return config{
+		TLS:          null.NewBool(false, false),
+		PushInterval: types.NewNullDuration(time.Second, false),
+		Timeout:      types.NewNullDuration(10*time.Second, false),
+	}
+}
+
+// Apply saves config non-zero config values from the passed config in the receiver.
+func (c config) Apply(cfg config) config {
+	if cfg.Address.Valid {
+		c.Address = cfg.Address
+	}
+	if cfg.TLS.Valid {
+		c.TLS = cfg.TLS
+	}
+	if cfg.PushInterval.Valid {
+		c.PushInterval = cfg.PushInterval
+	}
+	if cfg.Timeout.Valid {
+		c.Timeout = cfg.Timeout
+	}
+	return c
+}
+
+// getConsolidatedConfig combines {default config values + JSON config +
+// environment vars + the address in the argument}, and returns the final result.
+func getConsolidatedConfig(jsonRawConf json.RawMessage, env map[string]string, arg string) (config, error) {
+	result := newConfig()
+	if jsonRawConf != nil {
+		jsonConf := config{}
+		if err := json.Unmarshal(jsonRawConf, &jsonConf); err != nil {
+			return result, err
+		}
+		result = result.Apply(jsonConf)
+	}
+
+	envConfig := config{}
+	if err := envconfig.Process("", &envConfig, func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}); err != nil {
+		return result, err
+	}
+	result = result.Apply(envConfig)
+
+	if arg != "" {
+		result.Address = null.StringFrom(arg)
+	}
+
+	if result.Address.String == "" {
+		return result, errors.New("the plugin output needs the address of the plugin, e.g. --out plugin=localhost:9000")
+	}
+	if result.PushInterval.Duration <= 0 {
+		return result, errors.New("the push interval of the plugin output needs to be positive")
+	}
+	return result, nil
+}
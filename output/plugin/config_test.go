@@ -0,0 +1,73 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package plugin
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig(t *testing.T) {
+	t.Parallel()
+
+	t.Run("defaults", func(t *testing.T) {
+		t.Parallel()
+		conf, err := getConsolidatedConfig(nil, nil, "localhost:9000")
+		require.NoError(t, err)
+		assert.Equal(t, "localhost:9000", conf.Address.String)
+		assert.False(t, conf.TLS.Bool)
+		assert.Equal(t, time.Second, conf.PushInterval.TimeDuration())
+		assert.Equal(t, 10*time.Second, conf.Timeout.TimeDuration())
+	})
+
+	t.Run("precedence", func(t *testing.T) {
+		t.Parallel()
+		conf, err := getConsolidatedConfig(
+			json.RawMessage(`{"address":"json:9000","tls":true,"pushInterval":"5s","pluginOption":"ignored"}`),
+			map[string]string{"K6_PLUGIN_ADDRESS": "env:9000", "K6_PLUGIN_TIMEOUT": "3s"},
+			"",
+		)
+		require.NoError(t, err)
+		assert.Equal(t, "env:9000", conf.Address.String)
+		assert.True(t, conf.TLS.Bool)
+		assert.Equal(t, 5*time.Second, conf.PushInterval.TimeDuration())
+		assert.Equal(t, 3*time.Second, conf.Timeout.TimeDuration())
+
+		conf, err = getConsolidatedConfig(nil, map[string]string{"K6_PLUGIN_ADDRESS": "env:9000"}, "arg:9000")
+		require.NoError(t, err)
+		assert.Equal(t, "arg:9000", conf.Address.String)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		t.Parallel()
+		_, err := getConsolidatedConfig(nil, nil, "")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "needs the address of the plugin")
+
+		_, err = getConsolidatedConfig(json.RawMessage(`{"pushInterval":"0s"}`), nil, "localhost:9000")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "needs to be positive")
+	})
+}
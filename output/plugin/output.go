@@ -0,0 +1,217 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package plugin implements an output that streams the samples over gRPC to a sidecar process, so the output
+// integrations can be written in any language instead of as xk6 extensions. The protocol is in plugin.proto.
+package plugin
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"go.k6.io/k6/lib/consts"
+	"go.k6.io/k6/output"
+	"go.k6.io/k6/output/internal/rawproto"
+	"go.k6.io/k6/stats"
+)
+
+const streamMethod = "/k6.output.plugin.v1.Output/Stream"
+
+// New creates a new output plugin.
+func New(params output.Params) (output.Output, error) {
+	return newOutput(params)
+}
+
+func newOutput(params output.Params) (*Output, error) {
+	conf, err := getConsolidatedConfig(params.JSONConfig, params.Environment, params.ConfigArgument)
+	if err != nil {
+		return nil, err
+	}
+
+	var scriptPath string
+	if params.ScriptPath != nil {
+		scriptPath = params.ScriptPath.String()
+	}
+	return &Output{
+		config:     conf,
+		logger:     params.Logger.WithFields(logrus.Fields{"output": "plugin", "address": conf.Address.String}),
+		scriptPath: scriptPath,
+		jsonConfig: string(params.JSONConfig),
+	}, nil
+}
+
+var (
+	_ output.Output          = &Output{}
+	_ output.WithTestRunStop = &Output{}
+)
+
+// Output streams the samples to the plugin at every push interval.
+type Output struct {
+	output.SampleBuffer
+
+	periodicFlusher *output.PeriodicFlusher
+
+	config     config
+	logger     logrus.FieldLogger
+	scriptPath string
+	jsonConfig string
+
+	conn   *grpc.ClientConn
+	stream grpc.ClientStream
+	cancel context.CancelFunc
+	// closed is closed when the plugin closes its side of the stream
+	closed chan struct{}
+
+	testRunStopCallback func(error)
+}
+
+// Description returns a human-readable description of the output.
+func (o *Output) Description() string {
+	return fmt.Sprintf("plugin (%s)", o.config.Address.String)
+}
+
+// SetTestRunStopCallback receives the function that stops the test run when the plugin aborts it.
+func (o *Output) SetTestRunStopCallback(callback func(error)) {
+	o.testRunStopCallback = callback
+}
+
+// Start connects to the plugin, opens the stream and sends the Start event to it,
+// then it starts the goroutine for metric flushing.
+func (o *Output) Start() error {
+	o.logger.Debug("Starting...")
+
+	creds := grpc.WithInsecure()
+	if o.config.TLS.Bool {
+		creds = grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12}))
+	}
+	dialCtx, dialCancel := context.WithTimeout(context.Background(), o.config.Timeout.TimeDuration())
+	defer dialCancel()
+	conn, err := grpc.DialContext(dialCtx, o.config.Address.String,
+		creds, grpc.WithBlock(), grpc.WithUserAgent("k6/"+consts.Version))
+	if err != nil {
+		return fmt.Errorf("couldn't connect to the output plugin at %s: %w", o.config.Address.String, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true, ClientStreams: true},
+		streamMethod, grpc.ForceCodec(rawproto.Codec{}))
+	if err != nil {
+		cancel()
+		_ = conn.Close()
+		return fmt.Errorf("couldn't open the stream of the output plugin: %w", err)
+	}
+	o.conn, o.stream, o.cancel = conn, stream, cancel
+	if err = o.send(encodeStart(consts.Version, o.scriptPath, o.jsonConfig)); err != nil {
+		cancel()
+		_ = conn.Close()
+		return fmt.Errorf("couldn't start the output plugin: %w", err)
+	}
+
+	o.closed = make(chan struct{})
+	go o.receive()
+
+	pf, err := o.NewSequentialPeriodicFlusher(o.config.PushInterval.TimeDuration(), o.flushMetrics)
+	if err != nil {
+		cancel()
+		_ = conn.Close()
+		return err
+	}
+	o.logger.Debug("Started!")
+	o.periodicFlusher = pf
+
+	return nil
+}
+
+// Stop flushes the remaining samples, sends the Stop event and waits for the plugin to close the stream.
+func (o *Output) Stop() error {
+	o.logger.Debug("Stopping...")
+	defer o.logger.Debug("Stopped!")
+	o.periodicFlusher.Stop()
+
+	err := o.send(encodeStop())
+	if err == nil {
+		err = o.stream.CloseSend()
+	}
+	if err != nil {
+		o.logger.WithError(err).Error("Couldn't stop the output plugin")
+	} else {
+		select {
+		case <-o.closed:
+		case <-time.After(o.config.Timeout.TimeDuration()):
+			o.logger.Warn("The output plugin didn't close the stream in time")
+		}
+	}
+	o.cancel()
+	return o.conn.Close()
+}
+
+func (o *Output) send(event []byte) error {
+	return o.stream.SendMsg(&event)
+}
+
+// receive handles the responses of the plugin until it closes the stream.
+func (o *Output) receive() {
+	defer close(o.closed)
+	for {
+		var response []byte
+		err := o.stream.RecvMsg(&response)
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		if err != nil {
+			o.logger.WithError(err).Error("The stream of the output plugin failed")
+			return
+		}
+
+		abortErr, abort, err := decodeResponse(response)
+		if err != nil {
+			o.logger.WithError(err).Warn("Couldn't decode the response of the output plugin")
+			continue
+		}
+		if abort && o.testRunStopCallback != nil {
+			o.testRunStopCallback(fmt.Errorf("the output plugin aborted the test run: %s", abortErr))
+		}
+	}
+}
+
+func (o *Output) flushMetrics() {
+	var samples []stats.Sample
+	for _, sc := range o.GetBufferedSamples() {
+		samples = append(samples, sc.GetSamples()...)
+	}
+	if len(samples) == 0 {
+		return
+	}
+
+	start := time.Now()
+	if err := o.send(encodeSamples(samples)); err != nil {
+		o.logger.WithError(err).Error("Couldn't send the samples to the output plugin")
+		return
+	}
+	o.logger.WithField("t", time.Since(start)).WithField("samples", len(samples)).Debug("Sent the samples")
+}
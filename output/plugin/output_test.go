@@ -0,0 +1,147 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package plugin
+
+import (
+	"encoding/json"
+	"math"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"go.k6.io/k6/lib/consts"
+	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/output"
+	"go.k6.io/k6/output/internal/rawproto"
+	"go.k6.io/k6/stats"
+)
+
+func TestOutput(t *testing.T) {
+	t.Parallel()
+	events := make(chan rawproto.Message, 10)
+	srv := grpc.NewServer(
+		grpc.ForceServerCodec(rawproto.Codec{}),
+		grpc.UnknownServiceHandler(func(_ interface{}, stream grpc.ServerStream) error {
+			method, _ := grpc.MethodFromServerStream(stream)
+			assert.Equal(t, streamMethod, method)
+			for {
+				var event []byte
+				if err := stream.RecvMsg(&event); err != nil {
+					return err
+				}
+				decoded := rawproto.Decode(t, event)
+				events <- decoded
+				if _, ok := decoded[fieldSamples]; ok {
+					var abort []byte
+					abort = rawproto.AppendString(abort, fieldAbortError, "too many errors")
+					response := rawproto.AppendMessage(nil, fieldAbort, abort)
+					if err := stream.SendMsg(&response); err != nil {
+						return err
+					}
+				}
+				if _, ok := decoded[fieldStop]; ok {
+					close(events)
+					return nil
+				}
+			}
+		}),
+	)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	out, err := newOutput(output.Params{
+		Logger:         testutils.NewLogger(t),
+		ScriptPath:     &url.URL{Scheme: "file", Path: "/script.js"},
+		JSONConfig:     json.RawMessage(`{"pushInterval":"1h","pluginOption":1}`),
+		ConfigArgument: lis.Addr().String(),
+	})
+	require.NoError(t, err)
+	aborted := make(chan error, 1)
+	out.SetTestRunStopCallback(func(err error) { aborted <- err })
+	require.NoError(t, out.Start())
+
+	now := time.Now()
+	trend := stats.New("http_req_duration", stats.Trend, stats.Time)
+	out.AddMetricSamples([]stats.SampleContainer{stats.Sample{
+		Metric: trend, Time: now, Value: 12.5,
+		Tags: stats.NewSampleTags(map[string]string{"status": "200", "method": "GET"}),
+	}})
+	require.NoError(t, out.Stop())
+
+	var received []rawproto.Message
+	for e := range events {
+		received = append(received, e)
+	}
+	require.Len(t, received, 3)
+
+	start := received[0].Messages(t, fieldStart)[0]
+	assert.Equal(t, consts.Version, start.String(fieldK6Version))
+	assert.Equal(t, "file:///script.js", start.String(fieldScriptPath))
+	assert.JSONEq(t, `{"pushInterval":"1h","pluginOption":1}`, start.String(fieldJSONConfig))
+
+	samples := received[1].Messages(t, fieldSamples)[0].Messages(t, fieldSample)
+	require.Len(t, samples, 1)
+	assert.Equal(t, "http_req_duration", samples[0].String(fieldMetric))
+	assert.Equal(t, uint64(3), samples[0].Uint(fieldType))
+	assert.Equal(t, uint64(1), samples[0].Uint(fieldContains))
+	assert.Equal(t, now.UnixNano(), int64(samples[0].Uint(fieldTimeUnixNano)))
+	assert.Equal(t, 12.5, math.Float64frombits(samples[0].Uint(fieldValue)))
+	tags := map[string]string{}
+	for _, entry := range samples[0].Messages(t, fieldTags) {
+		tags[entry.String(fieldMapKey)] = entry.String(fieldMapValue)
+	}
+	assert.Equal(t, map[string]string{"status": "200", "method": "GET"}, tags)
+
+	assert.Contains(t, received[2], protowire.Number(fieldStop))
+
+	select {
+	case err := <-aborted:
+		assert.EqualError(t, err, "the output plugin aborted the test run: too many errors")
+	default:
+		t.Fatal("the test run wasn't aborted")
+	}
+}
+
+func TestOutputWithoutPlugin(t *testing.T) {
+	t.Parallel()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := lis.Addr().String()
+	require.NoError(t, lis.Close())
+
+	out, err := newOutput(output.Params{
+		Logger:         testutils.NewLogger(t),
+		Environment:    map[string]string{"K6_PLUGIN_TIMEOUT": "100ms"},
+		ConfigArgument: addr,
+	})
+	require.NoError(t, err)
+	err = out.Start()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "couldn't connect to the output plugin at "+addr)
+}
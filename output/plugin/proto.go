@@ -0,0 +1,164 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package plugin
+
+import (
+	"errors"
+	"math"
+	"sort"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"go.k6.io/k6/output/internal/rawproto"
+	"go.k6.io/k6/stats"
+)
+
+// The messages of plugin.proto are encoded by hand, like the ones of the otel output, and sent with rawproto.Codec.
+
+// Event
+const (
+	fieldStart   = 1
+	fieldSamples = 2
+	fieldStop    = 3
+)
+
+// Start
+const (
+	fieldK6Version  = 1
+	fieldScriptPath = 2
+	fieldJSONConfig = 3
+)
+
+// Samples and Sample
+const (
+	fieldSample = 1
+
+	fieldMetric       = 1
+	fieldType         = 2
+	fieldContains     = 3
+	fieldTimeUnixNano = 4
+	fieldValue        = 5
+	fieldTags         = 6
+
+	fieldMapKey   = 1
+	fieldMapValue = 2
+)
+
+// Response and Abort
+const (
+	fieldAbort = 1
+
+	fieldAbortError = 1
+)
+
+var errInvalidResponse = errors.New("invalid Response")
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func encodeStart(k6Version, scriptPath, jsonConfig string) []byte {
+	var start []byte
+	start = rawproto.AppendString(start, fieldK6Version, k6Version)
+	start = rawproto.AppendString(start, fieldScriptPath, scriptPath)
+	start = rawproto.AppendString(start, fieldJSONConfig, jsonConfig)
+	return rawproto.AppendMessage(nil, fieldStart, start)
+}
+
+func encodeStop() []byte {
+	return rawproto.AppendMessage(nil, fieldStop, nil)
+}
+
+// encodeSamples encodes the samples as an Event, the types of the metrics are the ones of stats shifted by one,
+// since 0 is the unspecified one, and their value types are the same.
+func encodeSamples(samples []stats.Sample) []byte {
+	var batch []byte
+	for _, sample := range samples {
+		var s []byte
+		s = rawproto.AppendString(s, fieldMetric, sample.Metric.Name)
+		s = appendVarint(s, fieldType, uint64(sample.Metric.Type)+1)
+		s = appendVarint(s, fieldContains, uint64(sample.Metric.Contains))
+		s = appendVarint(s, fieldTimeUnixNano, uint64(sample.Time.UnixNano()))
+		s = protowire.AppendTag(s, fieldValue, protowire.Fixed64Type)
+		s = protowire.AppendFixed64(s, math.Float64bits(sample.Value))
+
+		tags := sample.Tags.CloneTags()
+		keys := make([]string, 0, len(tags))
+		for k := range tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			var entry []byte
+			entry = rawproto.AppendString(entry, fieldMapKey, k)
+			entry = rawproto.AppendString(entry, fieldMapValue, tags[k])
+			s = rawproto.AppendMessage(s, fieldTags, entry)
+		}
+		batch = rawproto.AppendMessage(batch, fieldSample, s)
+	}
+	return rawproto.AppendMessage(nil, fieldSamples, batch)
+}
+
+// decodeResponse returns the error of the Abort of a Response, and whether there was one.
+func decodeResponse(b []byte) (abortErr string, abort bool, err error) {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return "", false, errInvalidResponse
+		}
+		b = b[n:]
+		if num != fieldAbort || typ != protowire.BytesType {
+			if n = protowire.ConsumeFieldValue(num, typ, b); n < 0 {
+				return "", false, errInvalidResponse
+			}
+			b = b[n:]
+			continue
+		}
+		msg, m := protowire.ConsumeBytes(b)
+		if m < 0 {
+			return "", false, errInvalidResponse
+		}
+		b = b[m:]
+		abort, abortErr = true, ""
+		for len(msg) > 0 {
+			num, typ, n := protowire.ConsumeTag(msg)
+			if n < 0 {
+				return "", false, errInvalidResponse
+			}
+			msg = msg[n:]
+			if num == fieldAbortError && typ == protowire.BytesType {
+				v, m := protowire.ConsumeString(msg)
+				if m < 0 {
+					return "", false, errInvalidResponse
+				}
+				abortErr, n = v, m
+			} else if n = protowire.ConsumeFieldValue(num, typ, msg); n < 0 {
+				return "", false, errInvalidResponse
+			}
+			msg = msg[n:]
+		}
+	}
+	return abortErr, abort, nil
+}
@@ -22,6 +22,7 @@ package statsd
 
 import (
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/mstoykov/envconfig"
@@ -39,6 +40,32 @@ type config struct {
 	PushInterval types.NullDuration `json:"pushInterval,omitempty" envconfig:"K6_STATSD_PUSH_INTERVAL"`
 	TagBlocklist stats.TagSet       `json:"tagBlocklist,omitempty" envconfig:"K6_STATSD_TAG_BLOCKLIST"`
 	EnableTags   null.Bool          `json:"enableTags,omitempty" envconfig:"K6_STATSD_ENABLE_TAGS"`
+	// TrendType is the StatsD type of the Trends, the distributions and histograms are DogStatsD ones
+	TrendType null.String `json:"trendType,omitempty" envconfig:"K6_STATSD_TREND_TYPE"`
+}
+
+const (
+	trendTypeTiming       = "timing"
+	trendTypeDistribution = "distribution"
+	trendTypeHistogram    = "histogram"
+)
+
+// originTags returns the tags of the origin of the metrics for the DogStatsD agents, which are the ones of the
+// unified service tagging of Datadog and the entity ID of the origin detection over UDP. The origin detection over
+// a Unix socket, with an address like unix:///var/run/datadog/dsd.socket, is done by the agent itself.
+func originTags(env map[string]string) []string {
+	var tags []string
+	for _, t := range []struct{ envVar, tag string }{
+		{"DD_ENV", "env"},
+		{"DD_SERVICE", "service"},
+		{"DD_VERSION", "version"},
+		{"DD_ENTITY_ID", "dd.internal.entity_id"},
+	} {
+		if v := env[t.envVar]; v != "" {
+			tags = append(tags, t.tag+":"+v)
+		}
+	}
+	return tags
 }
 
 func processTags(t stats.TagSet, tags map[string]string) []string {
@@ -71,6 +98,9 @@ func (c config) Apply(cfg config) config {
 	if cfg.EnableTags.Valid {
 		c.EnableTags = cfg.EnableTags
 	}
+	if cfg.TrendType.Valid {
+		c.TrendType = cfg.TrendType
+	}
 
 	return c
 }
@@ -84,6 +114,7 @@ func newConfig() config {
 		PushInterval: types.NewNullDuration(1*time.Second, false),
 		TagBlocklist: (stats.TagVU | stats.TagIter | stats.TagURL).Map(),
 		EnableTags:   null.NewBool(false, false),
+		TrendType:    null.NewString(trendTypeTiming, false),
 	}
 }
 
@@ -109,5 +140,11 @@ func getConsolidatedConfig(jsonRawConf json.RawMessage, env map[string]string, _
 	}
 	result = result.Apply(envConfig)
 
+	switch result.TrendType.String {
+	case trendTypeTiming, trendTypeDistribution, trendTypeHistogram:
+	default:
+		return result, fmt.Errorf("invalid trend type '%s', it needs to be '%s', '%s' or '%s'",
+			result.TrendType.String, trendTypeTiming, trendTypeDistribution, trendTypeHistogram)
+	}
 	return result, nil
 }
@@ -43,9 +43,15 @@ func newOutput(params output.Params) (*Output, error) {
 	}
 	logger := params.Logger.WithFields(logrus.Fields{"output": "statsd"})
 
+	var globalTags []string
+	if conf.EnableTags.Bool {
+		globalTags = originTags(params.Environment)
+	}
+
 	return &Output{
-		config: conf,
-		logger: logger,
+		config:     conf,
+		logger:     logger,
+		globalTags: globalTags,
 	}, nil
 }
 
@@ -61,6 +67,8 @@ type Output struct {
 
 	logger logrus.FieldLogger
 	client *statsd.Client
+	// globalTags are added to all the metrics
+	globalTags []string
 }
 
 func (o *Output) dispatch(entry stats.Sample) error {
@@ -73,7 +81,16 @@ func (o *Output) dispatch(entry stats.Sample) error {
 	case stats.Counter:
 		return o.client.Count(entry.Metric.Name, int64(entry.Value), tagList, 1)
 	case stats.Trend:
-		return o.client.TimeInMilliseconds(entry.Metric.Name, entry.Value, tagList, 1)
+		switch o.config.TrendType.String {
+		case trendTypeDistribution:
+			return o.client.Distribution(entry.Metric.Name, entry.Value, tagList, 1)
+		case trendTypeHistogram:
+			return o.client.Histogram(entry.Metric.Name, entry.Value, tagList, 1)
+		default:
+			return o.client.TimeInMilliseconds(entry.Metric.Name, entry.Value, tagList, 1)
+		}
+	case stats.Histogram:
+		return o.client.Histogram(entry.Metric.Name, entry.Value, tagList, 1)
 	case stats.Gauge:
 		return o.client.Gauge(entry.Metric.Name, entry.Value, tagList, 1)
 	case stats.Rate:
@@ -104,8 +121,8 @@ func (o *Output) Description() string {
 	return fmt.Sprintf("statsd (%s)", o.config.Addr.String)
 }
 
-// Start tries to open a connection to specified statsd service and starts the goroutine for
-// metric flushing.
+// Start tries to open a connection to specified statsd service, over UDP or over a Unix socket
+// for the addresses like unix:///var/run/datadog/dsd.socket, and starts the goroutine for metric flushing.
 func (o *Output) Start() error {
 	o.logger.Debug("Starting...")
 
@@ -130,8 +147,9 @@ func (o *Output) Start() error {
 	if namespace := o.config.Namespace.String; namespace != "" {
 		o.client.Namespace = namespace
 	}
+	o.client.Tags = o.globalTags
 
-	pf, err := output.NewPeriodicFlusher(o.config.PushInterval.TimeDuration(), o.flushMetrics)
+	pf, err := o.NewPeriodicFlusher(o.config.PushInterval.TimeDuration(), o.flushMetrics)
 	if err != nil {
 		return err
 	}
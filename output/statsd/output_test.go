@@ -23,8 +23,11 @@ package statsd
 import (
 	"encoding/json"
 	"fmt"
+	"net"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
@@ -139,3 +142,48 @@ func TestLinkReturnAddress(t *testing.T) {
 	}
 	require.Equal(t, fmt.Sprintf("statsd (%s)", bogusValue), c.Description())
 }
+
+func TestStatsdDogStatsD(t *testing.T) {
+	t.Parallel()
+	socket := filepath.Join(t.TempDir(), "dsd.socket")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	out, err := newOutput(output.Params{
+		Logger: testutils.NewLogger(t),
+		JSONConfig: json.RawMessage(fmt.Sprintf(
+			`{"addr":"unix://%s","namespace":"","pushInterval":"1h","enableTags":true,"trendType":"distribution"}`,
+			socket)),
+		Environment: map[string]string{"DD_ENV": "staging", "DD_SERVICE": "checkout", "DD_ENTITY_ID": "pod-uid"},
+	})
+	require.NoError(t, err)
+	require.NoError(t, out.Start())
+	out.AddMetricSamples([]stats.SampleContainer{stats.Sample{
+		Time: time.Now(), Metric: stats.New("my_trend", stats.Trend), Value: 14,
+		Tags: stats.NewSampleTags(map[string]string{"status": "200"}),
+	}})
+	require.NoError(t, out.Stop())
+
+	var buf [4096]byte
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, err := conn.Read(buf[:])
+	require.NoError(t, err)
+	assert.Equal(t, "my_trend:14.000000|d|#env:staging,service:checkout,dd.internal.entity_id:pod-uid,status:200",
+		string(buf[:n]))
+}
+
+func TestStatsdConfigTrendType(t *testing.T) {
+	t.Parallel()
+	conf, err := getConsolidatedConfig(nil, nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, "timing", conf.TrendType.String)
+
+	conf, err = getConsolidatedConfig(nil, map[string]string{"K6_STATSD_TREND_TYPE": "histogram"}, "")
+	require.NoError(t, err)
+	assert.Equal(t, "histogram", conf.TrendType.String)
+
+	_, err = getConsolidatedConfig(json.RawMessage(`{"trendType":"set"}`), nil, "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid trend type 'set'")
+}
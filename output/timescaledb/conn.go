@@ -0,0 +1,80 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package timescaledb
+
+import (
+	"crypto/tls"
+	"net"
+	"time"
+
+	"go.k6.io/k6/lib/netext/pgext"
+)
+
+// conn is a connection to the server, with the timeout of the config for each query.
+type conn struct {
+	*pgext.Conn
+	timeout time.Duration
+}
+
+// dial connects to the server, starts the session and authenticates the user.
+func dial(config Config) (*conn, error) {
+	timeout := config.Timeout.TimeDuration()
+	netConn, err := net.DialTimeout("tcp", config.Addr.String, timeout)
+	if err != nil {
+		return nil, err
+	}
+	_ = netConn.SetDeadline(time.Now().Add(timeout))
+
+	host, _, err := net.SplitHostPort(config.Addr.String)
+	if err != nil {
+		_ = netConn.Close()
+		return nil, err
+	}
+	pgConn, err := pgext.Connect(netConn, pgext.Config{
+		User:            config.Username.String,
+		Password:        config.Password.String,
+		Database:        config.DB.String,
+		ApplicationName: "k6",
+		SSLMode:         config.SSLMode.String,
+		StartTLS: func(nc net.Conn, verify bool) (net.Conn, error) {
+			tlsConn := tls.Client(nc, &tls.Config{
+				ServerName:         host,
+				InsecureSkipVerify: !verify, //nolint:gosec
+			})
+			return tlsConn, tlsConn.Handshake()
+		},
+	})
+	if err != nil {
+		_ = netConn.Close()
+		return nil, err
+	}
+	return &conn{Conn: pgConn, timeout: timeout}, nil
+}
+
+// exec runs the query with the simple query protocol. If it's a COPY FROM STDIN, copyData is sent as its data.
+func (c *conn) exec(query string, copyData []byte) error {
+	_ = c.SetDeadline(time.Now().Add(c.timeout))
+	return c.Exec(query, copyData)
+}
+
+func (c *conn) close() error {
+	return c.Close()
+}
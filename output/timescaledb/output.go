@@ -0,0 +1,239 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package timescaledb implements an output that writes the samples to a PostgreSQL table with COPY, which is
+// made a hypertable when the TimescaleDB extension is installed.
+package timescaledb
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.k6.io/k6/lib/netext/pgext"
+	"go.k6.io/k6/output"
+	"go.k6.io/k6/stats"
+)
+
+// Output is the TimescaleDB Output struct
+type Output struct {
+	output.SampleBuffer
+
+	config Config
+	table  string
+
+	logger          logrus.FieldLogger
+	periodicFlusher *output.PeriodicFlusher
+	semaphoreCh     chan struct{}
+	wg              sync.WaitGroup
+
+	// idleConns are the connections that aren't used by a write, there are at most ConcurrentWrites of them
+	idleConns chan *conn
+}
+
+// New returns new TimescaleDB output
+func New(params output.Params) (output.Output, error) {
+	return newOutput(params)
+}
+
+func newOutput(params output.Params) (*Output, error) {
+	conf, err := GetConsolidatedConfig(params.JSONConfig, params.Environment, params.ConfigArgument)
+	if err != nil {
+		return nil, err
+	}
+	return &Output{
+		config: conf,
+		table:  quoteIdentifier(conf.Table.String),
+		logger: params.Logger.WithFields(logrus.Fields{
+			"output": "TimescaleDB",
+		}),
+		semaphoreCh: make(chan struct{}, conf.ConcurrentWrites.Int64),
+		idleConns:   make(chan *conn, conf.ConcurrentWrites.Int64),
+	}, nil
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// Description returns a human-readable description of the output.
+func (o *Output) Description() string {
+	return fmt.Sprintf("TimescaleDB (%s/%s, %s)", o.config.Addr.String, o.config.DB.String, o.config.Table.String)
+}
+
+// Start creates the table, if it doesn't exist, and starts the goroutine for metric flushing.
+func (o *Output) Start() error {
+	o.logger.Debug("Starting...")
+	c, err := dial(o.config)
+	if err != nil {
+		return fmt.Errorf("couldn't connect to timescaledb: %w", err)
+	}
+	if err = c.exec(o.schema(), nil); err != nil {
+		_ = c.close()
+		return fmt.Errorf("couldn't create the timescaledb table: %w", err)
+	}
+	o.idleConns <- c
+
+	pf, err := o.NewPeriodicFlusher(o.config.PushInterval.TimeDuration(), o.flushMetrics)
+	if err != nil {
+		return err
+	}
+	o.logger.Debug("Started!")
+	o.periodicFlusher = pf
+
+	return nil
+}
+
+// Stop flushes any remaining metrics, stops the goroutine and closes the connections.
+func (o *Output) Stop() error {
+	o.logger.Debug("Stopping...")
+	defer o.logger.Debug("Stopped!")
+	o.periodicFlusher.Stop()
+	o.wg.Wait()
+	close(o.idleConns)
+	for c := range o.idleConns {
+		_ = c.close()
+	}
+	return nil
+}
+
+// schema returns the statements that create the table, with an index for the queries by metric, and make it
+// a hypertable partitioned by the time of the samples if the timescaledb extension is installed.
+func (o *Output) schema() string {
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %[1]s (
+	ts timestamptz NOT NULL,
+	metric text NOT NULL,
+	value double precision NOT NULL,
+	tags jsonb
+);
+CREATE INDEX IF NOT EXISTS %[2]s ON %[1]s (metric, ts DESC);
+DO $$ BEGIN
+	IF EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'timescaledb') THEN
+		PERFORM create_hypertable(%[3]s, 'ts', if_not_exists => TRUE, migrate_data => TRUE);
+	END IF;
+END $$;`, o.table, quoteIdentifier(o.config.Table.String+"_metric_ts_idx"), quoteLiteral(o.table))
+}
+
+// copyEscaper escapes the values of the text format of COPY.
+//
+//nolint:gochecknoglobals
+var copyEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// batchFromSamples encodes the samples as rows of the text format of COPY.
+func batchFromSamples(containers []stats.SampleContainer) ([]byte, int, error) {
+	var batch bytes.Buffer
+	var count int
+	cache := map[*stats.SampleTags]string{}
+	for _, container := range containers {
+		for _, sample := range container.GetSamples() {
+			tags, ok := cache[sample.Tags]
+			if !ok {
+				b, err := json.Marshal(sample.Tags.CloneTags())
+				if err != nil {
+					return nil, 0, fmt.Errorf("couldn't encode the tags: %w", err)
+				}
+				tags = copyEscaper.Replace(string(b))
+				cache[sample.Tags] = tags
+			}
+			batch.WriteString(sample.Time.UTC().Format(time.RFC3339Nano))
+			batch.WriteByte('\t')
+			batch.WriteString(copyEscaper.Replace(sample.Metric.Name))
+			batch.WriteByte('\t')
+			batch.WriteString(strconv.FormatFloat(sample.Value, 'g', -1, 64))
+			batch.WriteByte('\t')
+			batch.WriteString(tags)
+			batch.WriteByte('\n')
+			count++
+		}
+	}
+	return batch.Bytes(), count, nil
+}
+
+// write copies the batch with an idle connection, or a new one if there isn't one.
+func (o *Output) write(batch []byte) error {
+	var c *conn
+	select {
+	case c = <-o.idleConns:
+	default:
+		var err error
+		if c, err = dial(o.config); err != nil {
+			return err
+		}
+	}
+	if err := c.exec("COPY "+o.table+" (ts, metric, value, tags) FROM STDIN", batch); err != nil {
+		// the errors of the server leave the connection usable, the other ones may be in the middle of a message
+		var pgErr *pgext.Error
+		if !errors.As(err, &pgErr) {
+			_ = c.close()
+			return err
+		}
+		o.idleConns <- c
+		return err
+	}
+	o.idleConns <- c
+	return nil
+}
+
+func (o *Output) flushMetrics() {
+	samples := o.GetBufferedSamples()
+	if len(samples) < 1 {
+		return
+	}
+
+	o.wg.Add(1)
+	o.semaphoreCh <- struct{}{}
+	go func() {
+		defer func() {
+			<-o.semaphoreCh
+			o.wg.Done()
+		}()
+
+		batch, count, err := batchFromSamples(samples)
+		if err != nil {
+			o.logger.WithError(err).Error("Couldn't create batch from samples")
+			return
+		}
+
+		o.logger.WithField("rows", count).Debug("Copying...")
+		startTime := time.Now()
+		if err := o.write(batch); err != nil {
+			o.logger.WithError(err).Error("Couldn't copy the samples")
+			return
+		}
+		t := time.Since(startTime)
+		o.logger.WithField("t", t).Debug("Batch copied!")
+
+		if t > o.config.PushInterval.TimeDuration() {
+			o.logger.WithField("t", t).
+				Warn("The flush operation took higher than the expected set push interval. If you see this message multiple times then the setup or configuration need to be adjusted to achieve a sustainable rate.") //nolint:lll
+		}
+	}()
+}
@@ -0,0 +1,230 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package timescaledb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/lib/netext/pgext"
+	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/output"
+	"go.k6.io/k6/stats"
+)
+
+// fakeServer is a PostgreSQL server which asks for an md5 password, records the queries with the data copied
+// by them and fails the ones with failQuery in them.
+type fakeServer struct {
+	t        *testing.T
+	listener net.Listener
+
+	mu        sync.Mutex
+	queries   []string
+	copyData  []string
+	failQuery string
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &fakeServer{t: t, listener: listener}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			c, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+	return s
+}
+
+func (s *fakeServer) send(w io.Writer, typ byte, data ...byte) {
+	_, err := w.Write(pgext.AppendMessage(nil, typ, data))
+	assert.NoError(s.t, err)
+}
+
+func (s *fakeServer) receive(r *bufio.Reader) (byte, []byte) {
+	typ, msg, _ := pgext.ReadMessage(r)
+	return typ, msg
+}
+
+func (s *fakeServer) receiveStartup(r *bufio.Reader) []byte {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil
+	}
+	msg := make([]byte, binary.BigEndian.Uint32(size[:])-4)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil
+	}
+	return msg
+}
+
+func (s *fakeServer) serve(c net.Conn) {
+	defer func() { _ = c.Close() }()
+	r := bufio.NewReader(c)
+
+	startup := s.receiveStartup(r)
+	if len(startup) == 4 && binary.BigEndian.Uint32(startup) == pgext.SSLRequestCode {
+		_, _ = c.Write([]byte{'N'})
+		if startup = s.receiveStartup(r); startup == nil {
+			return
+		}
+	}
+	assert.Equal(s.t, "\x00\x03\x00\x00user\x00k6user\x00database\x00k6db\x00client_encoding\x00UTF8\x00"+
+		"application_name\x00k6\x00\x00", string(startup))
+
+	s.send(c, 'R', 0, 0, 0, pgext.AuthMD5Password, 1, 2, 3, 4)
+	typ, password := s.receive(r)
+	if typ != 'p' || string(password) != pgext.MD5Password("k6user", "secret", []byte{1, 2, 3, 4})+"\x00" {
+		s.send(c, 'E', []byte("SFATAL\x00C28P01\x00Mpassword authentication failed\x00\x00")...)
+		return
+	}
+	s.send(c, 'R', 0, 0, 0, pgext.AuthOK)
+	s.send(c, 'S', []byte("server_version\x0014\x00")...)
+	s.send(c, 'Z', 'I')
+
+	for {
+		typ, msg := s.receive(r)
+		if typ != 'Q' {
+			return
+		}
+		query := strings.TrimSuffix(string(msg), "\x00")
+		s.mu.Lock()
+		s.queries = append(s.queries, query)
+		failQuery := s.failQuery
+		s.mu.Unlock()
+
+		if strings.HasPrefix(query, "COPY") {
+			s.send(c, 'G', 0, 0, 0)
+			var data []byte
+			for typ, msg = s.receive(r); typ == 'd'; typ, msg = s.receive(r) {
+				data = append(data, msg...)
+			}
+			s.mu.Lock()
+			s.copyData = append(s.copyData, string(data))
+			s.mu.Unlock()
+		}
+		if failQuery != "" && strings.Contains(query, failQuery) {
+			s.send(c, 'E', []byte("SERROR\x00C42501\x00Mpermission denied\x00\x00")...)
+		} else {
+			s.send(c, 'C', []byte("OK\x00")...)
+		}
+		s.send(c, 'Z', 'I')
+	}
+}
+
+func (s *fakeServer) url(query string) string {
+	return "postgres://k6user:secret@" + s.listener.Addr().String() + "/k6db?" + query
+}
+
+func (s *fakeServer) recorded() ([]string, []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.queries...), append([]string{}, s.copyData...)
+}
+
+func TestOutput(t *testing.T) {
+	t.Parallel()
+	srv := newFakeServer(t)
+
+	out, err := newOutput(output.Params{
+		Logger:         testutils.NewLogger(t),
+		ConfigArgument: srv.url("table=k6_samples&pushInterval=10ms"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "TimescaleDB ("+srv.listener.Addr().String()+"/k6db, k6_samples)", out.Description())
+
+	require.NoError(t, out.Start())
+	out.AddMetricSamples([]stats.SampleContainer{
+		stats.Sample{
+			Time:   time.Date(2022, 1, 2, 3, 4, 5, 6000, time.UTC),
+			Metric: stats.New("http_req_duration", stats.Trend, stats.Time),
+			Value:  1.5,
+			Tags:   stats.NewSampleTags(map[string]string{"name": "a\tb", "status": "200"}),
+		},
+		stats.Sample{
+			Time:   time.Date(2022, 1, 2, 3, 4, 6, 0, time.UTC),
+			Metric: stats.New("iterations", stats.Counter),
+			Value:  1,
+			Tags:   stats.NewSampleTags(map[string]string{}),
+		},
+	})
+	time.Sleep(100 * time.Millisecond)
+	require.NoError(t, out.Stop())
+
+	queries, copyData := srv.recorded()
+	require.Len(t, queries, 2)
+	assert.Contains(t, queries[0], `CREATE TABLE IF NOT EXISTS "k6_samples" (`)
+	assert.Contains(t, queries[0],
+		`CREATE INDEX IF NOT EXISTS "k6_samples_metric_ts_idx" ON "k6_samples" (metric, ts DESC);`)
+	assert.Contains(t, queries[0],
+		`PERFORM create_hypertable('"k6_samples"', 'ts', if_not_exists => TRUE, migrate_data => TRUE);`)
+	assert.Equal(t, `COPY "k6_samples" (ts, metric, value, tags) FROM STDIN`, queries[1])
+	assert.Equal(t, []string{
+		"2022-01-02T03:04:05.000006Z\thttp_req_duration\t1.5\t{\"name\":\"a\\\\tb\",\"status\":\"200\"}\n" +
+			"2022-01-02T03:04:06Z\titerations\t1\t{}\n",
+	}, copyData)
+}
+
+func TestOutputErrors(t *testing.T) {
+	t.Parallel()
+	srv := newFakeServer(t)
+
+	out, err := newOutput(output.Params{
+		Logger:         testutils.NewLogger(t),
+		ConfigArgument: strings.Replace(srv.url(""), "secret", "wrong", 1),
+	})
+	require.NoError(t, err)
+	require.EqualError(t, out.Start(),
+		"couldn't connect to timescaledb: FATAL: password authentication failed (SQLSTATE 28P01)")
+
+	srv.mu.Lock()
+	srv.failQuery = "CREATE TABLE"
+	srv.mu.Unlock()
+	out, err = newOutput(output.Params{
+		Logger:         testutils.NewLogger(t),
+		ConfigArgument: srv.url("sslmode=disable"),
+	})
+	require.NoError(t, err)
+	require.EqualError(t, out.Start(),
+		"couldn't create the timescaledb table: ERROR: permission denied (SQLSTATE 42501)")
+
+	out, err = newOutput(output.Params{
+		Logger:         testutils.NewLogger(t),
+		ConfigArgument: srv.url("sslmode=require"),
+	})
+	require.NoError(t, err)
+	require.EqualError(t, out.Start(), "couldn't connect to timescaledb: the Postgres server doesn't support TLS")
+}
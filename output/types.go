@@ -102,3 +102,11 @@ type WithBuiltinMetrics interface {
 	Output
 	SetBuiltinMetrics(builtinMetrics *metrics.BuiltinMetrics)
 }
+
+// WithBufferLimit is an output that can limit the samples that it buffers until they are flushed, which all the
+// outputs that embed a SampleBuffer can do.
+type WithBufferLimit interface {
+	Output
+	SetBufferLimit(limit BufferLimit)
+	BufferStatus() BufferStatus
+}
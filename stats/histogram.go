@@ -0,0 +1,155 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package stats
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+)
+
+// HistogramSink counts the values of a Histogram in the buckets of its bounds. Like the ones of Prometheus,
+// the buckets are (Bounds[i-1], Bounds[i]], and the last count is the one of the values above all the bounds.
+type HistogramSink struct {
+	Bounds []float64
+	Counts []uint64
+
+	Count    uint64
+	Min, Max float64
+	Sum, Avg float64
+}
+
+// NewHistogramSink returns a HistogramSink with the bounds, which need to be sorted.
+func NewHistogramSink(bounds []float64) *HistogramSink {
+	return &HistogramSink{Bounds: bounds, Counts: make([]uint64, len(bounds)+1)}
+}
+
+// DefaultHistogramBuckets returns the bounds of the Histograms which are declared without them,
+// in milliseconds for the times, in bytes for the data, and a 1-2.5-5 series for the others.
+func DefaultHistogramBuckets(vt ValueType) []float64 {
+	switch vt {
+	case Time:
+		return []float64{1, 2.5, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 25000, 60000}
+	case Data:
+		return []float64{256, 1024, 4096, 16384, 65536, 262144, 1048576, 4194304, 16777216, 67108864}
+	default:
+		return []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 25, 50, 100, 250, 500, 1000}
+	}
+}
+
+// ValidateHistogramBuckets checks that the bounds are finite and sorted in increasing order.
+func ValidateHistogramBuckets(bounds []float64) error {
+	if len(bounds) == 0 {
+		return errors.New("a histogram needs at least one bucket")
+	}
+	for i, bound := range bounds {
+		if math.IsNaN(bound) || math.IsInf(bound, 0) {
+			return errors.New("the bucket bounds of a histogram need to be finite")
+		}
+		if i > 0 && bound <= bounds[i-1] {
+			return errors.New("the bucket bounds of a histogram need to be in increasing order")
+		}
+	}
+	return nil
+}
+
+func (h *HistogramSink) Add(s Sample) {
+	h.Counts[sort.SearchFloat64s(h.Bounds, s.Value)]++
+	if h.Count == 0 || s.Value > h.Max {
+		h.Max = s.Value
+	}
+	if h.Count == 0 || s.Value < h.Min {
+		h.Min = s.Value
+	}
+	h.Count++
+	h.Sum += s.Value
+	h.Avg = h.Sum / float64(h.Count)
+}
+
+// Merge adds the counts of the other sink, which needs to have the same bounds.
+func (h *HistogramSink) Merge(other *HistogramSink) {
+	if other.Count == 0 {
+		return
+	}
+	for i, count := range other.Counts {
+		h.Counts[i] += count
+	}
+	if h.Count == 0 || other.Max > h.Max {
+		h.Max = other.Max
+	}
+	if h.Count == 0 || other.Min < h.Min {
+		h.Min = other.Min
+	}
+	h.Count += other.Count
+	h.Sum += other.Sum
+	h.Avg = h.Sum / float64(h.Count)
+}
+
+// P estimates the percentile by a linear interpolation in the bucket where it falls, like the histogram_quantile
+// of Prometheus. The first and the last buckets start and end at the min and the max.
+func (h *HistogramSink) P(pct float64) float64 {
+	switch {
+	case h.Count == 0:
+		return 0
+	case pct <= 0:
+		return h.Min
+	case pct >= 1:
+		return h.Max
+	}
+
+	rank := pct * float64(h.Count)
+	var seen uint64
+	for i, count := range h.Counts {
+		if count == 0 || float64(seen+count) < rank {
+			seen += count
+			continue
+		}
+		lower, upper := h.Min, h.Max
+		if i > 0 {
+			lower = math.Max(lower, h.Bounds[i-1])
+		}
+		if i < len(h.Bounds) {
+			upper = math.Min(upper, h.Bounds[i])
+		}
+		return lower + (upper-lower)*(rank-float64(seen))/float64(count)
+	}
+	return h.Max
+}
+
+func (h *HistogramSink) Calc() {}
+
+func (h *HistogramSink) Format(tt time.Duration) map[string]float64 {
+	return map[string]float64{
+		"count": float64(h.Count),
+		"min":   h.Min,
+		"max":   h.Max,
+		"avg":   h.Avg,
+		"med":   h.P(0.5),
+		"p(90)": h.P(0.90),
+		"p(95)": h.P(0.95),
+	}
+}
+
+// TrendValues returns the stats of the values, for the trend stats of the summary.
+func (h *HistogramSink) TrendValues() TrendValues {
+	return TrendValues{Count: h.Count, Min: h.Min, Max: h.Max, Avg: h.Avg, Med: h.P(0.5)}
+}
@@ -0,0 +1,108 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package stats
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistogramSink(t *testing.T) {
+	t.Parallel()
+
+	sink := NewHistogramSink([]float64{10, 20, 50})
+	assert.Equal(t, 0.0, sink.P(0.5))
+
+	for _, v := range []float64{5, 10, 12, 15, 18, 20, 30, 40, 45, 100} {
+		sink.Add(Sample{Value: v})
+	}
+	assert.Equal(t, []uint64{2, 4, 3, 1}, sink.Counts)
+	assert.Equal(t, uint64(10), sink.Count)
+	assert.Equal(t, 5.0, sink.Min)
+	assert.Equal(t, 100.0, sink.Max)
+	assert.Equal(t, 29.5, sink.Avg)
+
+	// the percentiles are interpolated in their buckets, the first one starting at the min and the last one
+	// ending at the max
+	assert.Equal(t, 5.0, sink.P(0))
+	assert.Equal(t, 7.5, sink.P(0.1))
+	assert.Equal(t, 17.5, sink.P(0.5))
+	assert.Equal(t, 50.0, sink.P(0.9))
+	assert.Equal(t, 75.0, sink.P(0.95))
+	assert.Equal(t, 100.0, sink.P(1))
+
+	values := sink.Format(0)
+	assert.Equal(t, 10.0, values["count"])
+	assert.Equal(t, 17.5, values["med"])
+	assert.Equal(t, TrendValues{Count: 10, Min: 5, Max: 100, Avg: 29.5, Med: 17.5}, sink.TrendValues())
+
+	t.Run("merge", func(t *testing.T) {
+		t.Parallel()
+
+		first, second := NewHistogramSink([]float64{10, 20}), NewHistogramSink([]float64{10, 20})
+		first.Add(Sample{Value: 15})
+		second.Add(Sample{Value: 5})
+		second.Add(Sample{Value: 25})
+		first.Merge(second)
+		first.Merge(NewHistogramSink([]float64{10, 20}))
+		assert.Equal(t, []uint64{1, 1, 1}, first.Counts)
+		assert.Equal(t, uint64(3), first.Count)
+		assert.Equal(t, 5.0, first.Min)
+		assert.Equal(t, 25.0, first.Max)
+		assert.Equal(t, 15.0, first.Avg)
+	})
+}
+
+func TestHistogramSinkThresholds(t *testing.T) {
+	t.Parallel()
+
+	sink := NewHistogramSink([]float64{10, 20, 50})
+	for _, v := range []float64{5, 10, 12, 15, 18, 20, 30, 40, 45, 100} {
+		sink.Add(Sample{Value: v})
+	}
+
+	ts := NewThresholds([]string{"count==10", "p(50)<20", "avg<30", "max<100"})
+	require.NoError(t, ts.Parse())
+	ok, err := ts.Run(sink, 0)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	for i, expected := range []bool{false, false, false, true} {
+		assert.Equal(t, expected, ts.Thresholds[i].LastFailed, ts.Thresholds[i].Source)
+	}
+}
+
+func TestValidateHistogramBuckets(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, ValidateHistogramBuckets([]float64{-1, 0, 0.5, 10}))
+	assert.Error(t, ValidateHistogramBuckets(nil))
+	assert.Error(t, ValidateHistogramBuckets([]float64{1, 1}))
+	assert.Error(t, ValidateHistogramBuckets([]float64{2, 1}))
+	assert.Error(t, ValidateHistogramBuckets([]float64{1, math.Inf(1)}))
+	assert.Error(t, ValidateHistogramBuckets([]float64{math.NaN()}))
+
+	for _, vt := range []ValueType{Default, Time, Data} {
+		assert.NoError(t, ValidateHistogramBuckets(DefaultHistogramBuckets(vt)))
+	}
+}
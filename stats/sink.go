@@ -33,6 +33,10 @@ var (
 	_ Sink = &TrendSink{}
 	_ Sink = &RateSink{}
 	_ Sink = &DummySink{}
+	_ Sink = &HistogramSink{}
+
+	_ TrendStatsSink = &TrendSink{}
+	_ TrendStatsSink = &HistogramSink{}
 )
 
 type Sink interface {
@@ -41,6 +45,20 @@ type Sink interface {
 	Format(t time.Duration) map[string]float64 // Data for thresholds.
 }
 
+// TrendStatsSink is a sink whose values are summarized by the trend stats, like avg or p(95),
+// which are the ones of the Trends and the Histograms.
+type TrendStatsSink interface {
+	Sink
+	P(pct float64) float64
+	TrendValues() TrendValues
+}
+
+// TrendValues are the stats of the values of a TrendStatsSink, besides their percentiles.
+type TrendValues struct {
+	Count              uint64
+	Min, Max, Avg, Med float64
+}
+
 type CounterSink struct {
 	Value float64
 	First time.Time
@@ -85,9 +103,12 @@ func (g *GaugeSink) Format(t time.Duration) map[string]float64 {
 	return map[string]float64{"value": g.Value}
 }
 
+// TrendSink keeps all the values of a Trend, unless it was created with a relative error by NewTrendSink,
+// in which case they are counted in a histogram, whose memory doesn't grow with the count of the values.
 type TrendSink struct {
 	Values  []float64
 	jumbled bool
+	sketch  *trendSketch
 
 	Count    uint64
 	Min, Max float64
@@ -95,8 +116,21 @@ type TrendSink struct {
 	Med      float64
 }
 
+// NewTrendSink returns a TrendSink which keeps all the values when relativeError is 0, otherwise
+// the percentiles it calculates are within relativeError, e.g. 0.01 for 1%, of the exact ones.
+func NewTrendSink(relativeError float64) *TrendSink {
+	if relativeError <= 0 {
+		return &TrendSink{}
+	}
+	return &TrendSink{sketch: newTrendSketch(relativeError)}
+}
+
 func (t *TrendSink) Add(s Sample) {
-	t.Values = append(t.Values, s.Value)
+	if t.sketch != nil {
+		t.sketch.add(s.Value)
+	} else {
+		t.Values = append(t.Values, s.Value)
+	}
 	t.jumbled = true
 	t.Count += 1
 	t.Sum += s.Value
@@ -110,24 +144,55 @@ func (t *TrendSink) Add(s Sample) {
 	}
 }
 
+// Merge adds the values of the other sink, which needs to have been created with the same relative error.
+func (t *TrendSink) Merge(other *TrendSink) {
+	if other.Count == 0 {
+		return
+	}
+	if t.sketch != nil {
+		t.sketch.merge(other.sketch)
+	} else {
+		t.Values = append(t.Values, other.Values...)
+	}
+	if t.Count == 0 || other.Max > t.Max {
+		t.Max = other.Max
+	}
+	if t.Count == 0 || other.Min < t.Min {
+		t.Min = other.Min
+	}
+	t.jumbled = true
+	t.Count += other.Count
+	t.Sum += other.Sum
+	t.Avg = t.Sum / float64(t.Count)
+}
+
 // P calculates the given percentile from sink values.
 func (t *TrendSink) P(pct float64) float64 {
 	switch t.Count {
 	case 0:
 		return 0
 	case 1:
-		return t.Values[0]
-	default:
-		// If percentile falls on a value in Values slice, we return that value.
-		// If percentile does not fall on a value in Values slice, we calculate (linear interpolation)
-		// the value that would fall at percentile, given the values above and below that percentile.
-		t.Calc()
-		i := pct * (float64(t.Count) - 1.0)
-		j := t.Values[int(math.Floor(i))]
-		k := t.Values[int(math.Ceil(i))]
-		f := i - math.Floor(i)
-		return j + (k-j)*f
+		return t.Min
+	}
+	if t.sketch != nil {
+		switch {
+		case pct <= 0:
+			return t.Min
+		case pct >= 1:
+			return t.Max
+		}
+		return math.Max(t.Min, math.Min(t.Max, t.sketch.quantile(pct*(float64(t.Count)-1.0))))
 	}
+
+	// If percentile falls on a value in Values slice, we return that value.
+	// If percentile does not fall on a value in Values slice, we calculate (linear interpolation)
+	// the value that would fall at percentile, given the values above and below that percentile.
+	t.Calc()
+	i := pct * (float64(t.Count) - 1.0)
+	j := t.Values[int(math.Floor(i))]
+	k := t.Values[int(math.Ceil(i))]
+	f := i - math.Floor(i)
+	return j + (k-j)*f
 }
 
 func (t *TrendSink) Calc() {
@@ -135,8 +200,12 @@ func (t *TrendSink) Calc() {
 		return
 	}
 
-	sort.Float64s(t.Values)
 	t.jumbled = false
+	if t.sketch != nil {
+		t.Med = t.P(0.5)
+		return
+	}
+	sort.Float64s(t.Values)
 
 	// The median of an even number of values is the average of the middle two.
 	if (t.Count & 0x01) == 0 {
@@ -146,6 +215,11 @@ func (t *TrendSink) Calc() {
 	}
 }
 
+// TrendValues returns the stats of the values, for the trend stats of the summary.
+func (t *TrendSink) TrendValues() TrendValues {
+	return TrendValues{Count: t.Count, Min: t.Min, Max: t.Max, Avg: t.Avg, Med: t.Med}
+}
+
 func (t *TrendSink) Format(tt time.Duration) map[string]float64 {
 	t.Calc()
 	// TODO: respect the summaryTrendStats for REST API
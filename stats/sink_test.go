@@ -21,6 +21,7 @@
 package stats
 
 import (
+	"math"
 	"testing"
 	"time"
 
@@ -221,6 +222,53 @@ func TestTrendSink(t *testing.T) {
 			assert.InDelta(t, expV, result[k], tolerance)
 		}
 	})
+	t.Run("relative error", func(t *testing.T) {
+		exact, sketch := TrendSink{}, NewTrendSink(0.01)
+		sketch.Add(Sample{Metric: &Metric{}, Value: 10.0})
+		assert.Equal(t, 10.0, sketch.P(0.5))
+
+		sketch = NewTrendSink(0.01)
+		for i := 0; i < 100000; i++ {
+			v := float64((i*7919)%100000) - 1000
+			exact.Add(Sample{Metric: &Metric{}, Value: v})
+			sketch.Add(Sample{Metric: &Metric{}, Value: v})
+		}
+		assert.Nil(t, sketch.Values)
+		assert.Equal(t, exact.Count, sketch.Count)
+		assert.Equal(t, exact.Min, sketch.P(0))
+		assert.Equal(t, exact.Max, sketch.P(1))
+		for _, pct := range []float64{0.001, 0.005, 0.1, 0.5, 0.9, 0.95, 0.99, 0.999} {
+			expected := exact.P(pct)
+			assert.InDelta(t, expected, sketch.P(pct), math.Abs(expected)*0.01+1, pct)
+		}
+		exact.Calc()
+		sketch.Calc()
+		assert.InDelta(t, exact.Med, sketch.Med, exact.Med*0.01+1)
+		assert.Equal(t, exact.Avg, sketch.Avg)
+	})
+	t.Run("merge", func(t *testing.T) {
+		for _, relativeError := range []float64{0, 0.01} {
+			all, first, second := NewTrendSink(relativeError), NewTrendSink(relativeError), NewTrendSink(relativeError)
+			for i := 0; i < 1000; i++ {
+				s := Sample{Metric: &Metric{}, Value: float64(i%100) - 10}
+				all.Add(s)
+				if i < 300 {
+					first.Add(s)
+				} else {
+					second.Add(s)
+				}
+			}
+			first.Merge(second)
+			first.Merge(NewTrendSink(relativeError))
+			assert.Equal(t, all.Count, first.Count)
+			assert.Equal(t, all.Min, first.Min)
+			assert.Equal(t, all.Max, first.Max)
+			assert.Equal(t, all.Avg, first.Avg)
+			for _, pct := range []float64{0.1, 0.5, 0.95} {
+				assert.Equal(t, all.P(pct), first.P(pct), pct)
+			}
+		}
+	})
 }
 
 func TestRateSink(t *testing.T) {
@@ -0,0 +1,124 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package stats
+
+import "math"
+
+// trendSketch is a DDSketch (https://arxiv.org/abs/1908.10693) of the values of a Trend. The values are
+// counted in logarithmic bins, so the memory doesn't grow with the number of values but with their range,
+// and every quantile is within the relative error of the exact one.
+type trendSketch struct {
+	gamma    float64
+	logGamma float64
+
+	positive, negative sketchBins
+	zeros              uint64
+}
+
+func newTrendSketch(relativeError float64) *trendSketch {
+	gamma := (1 + relativeError) / (1 - relativeError)
+	return &trendSketch{gamma: gamma, logGamma: math.Log(gamma)}
+}
+
+// sketchBins are the counts of the contiguous bins starting from the one with the offset index.
+type sketchBins struct {
+	offset int
+	counts []uint64
+}
+
+func (b *sketchBins) add(index int, count uint64) {
+	switch {
+	case len(b.counts) == 0:
+		b.offset = index
+		b.counts = []uint64{0}
+	case index < b.offset:
+		counts := make([]uint64, len(b.counts)+b.offset-index)
+		copy(counts[b.offset-index:], b.counts)
+		b.counts, b.offset = counts, index
+	case index >= b.offset+len(b.counts):
+		b.counts = append(b.counts, make([]uint64, index-b.offset-len(b.counts)+1)...)
+	}
+	b.counts[index-b.offset] += count
+}
+
+func (s *trendSketch) index(v float64) int {
+	return int(math.Ceil(math.Log(v) / s.logGamma))
+}
+
+// value returns the value of the bin with the index, whose relative error is the one of the sketch
+// for all the values in the bin.
+func (s *trendSketch) value(index int) float64 {
+	return math.Exp(float64(index)*s.logGamma) * 2 / (1 + s.gamma)
+}
+
+func (s *trendSketch) add(v float64) {
+	switch {
+	case v > 0:
+		s.positive.add(s.index(v), 1)
+	case v < 0:
+		s.negative.add(s.index(-v), 1)
+	default:
+		s.zeros++
+	}
+}
+
+// merge adds the counts of the other sketch, which needs to have the same relative error.
+func (s *trendSketch) merge(other *trendSketch) {
+	for i, count := range other.positive.counts {
+		if count > 0 {
+			s.positive.add(other.positive.offset+i, count)
+		}
+	}
+	for i, count := range other.negative.counts {
+		if count > 0 {
+			s.negative.add(other.negative.offset+i, count)
+		}
+	}
+	s.zeros += other.zeros
+}
+
+// quantile returns the value with the rank, between 0 and the count of the values - 1.
+func (s *trendSketch) quantile(rank float64) float64 {
+	var seen uint64
+	// the bins of the negative values are from the greatest absolute value
+	for i := len(s.negative.counts) - 1; i >= 0; i-- {
+		seen += s.negative.counts[i]
+		if float64(seen) > rank {
+			return -s.value(s.negative.offset + i)
+		}
+	}
+	seen += s.zeros
+	if float64(seen) > rank {
+		return 0
+	}
+	last := 0.0
+	for i, count := range s.positive.counts {
+		if count == 0 {
+			continue
+		}
+		seen += count
+		last = s.value(s.positive.offset + i)
+		if float64(seen) > rank {
+			return last
+		}
+	}
+	return last
+}
@@ -25,6 +25,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -34,10 +35,11 @@ import (
 )
 
 const (
-	counterString = "counter"
-	gaugeString   = "gauge"
-	trendString   = "trend"
-	rateString    = "rate"
+	counterString   = "counter"
+	gaugeString     = "gauge"
+	trendString     = "trend"
+	rateString      = "rate"
+	histogramString = "histogram"
 
 	defaultString = "default"
 	timeString    = "time"
@@ -46,10 +48,11 @@ const (
 
 // Possible values for MetricType.
 const (
-	Counter = MetricType(iota) // A counter that sums its data points
-	Gauge                      // A gauge that displays the latest value
-	Trend                      // A trend, min/max/avg/med are interesting
-	Rate                       // A rate, displays % of values that aren't 0
+	Counter   = MetricType(iota) // A counter that sums its data points
+	Gauge                        // A gauge that displays the latest value
+	Trend                        // A trend, min/max/avg/med are interesting
+	Rate                         // A rate, displays % of values that aren't 0
+	Histogram                    // A histogram, counts the values in buckets
 )
 
 // Possible values for ValueType.
@@ -88,6 +91,8 @@ func (t MetricType) MarshalText() ([]byte, error) {
 		return []byte(trendString), nil
 	case Rate:
 		return []byte(rateString), nil
+	case Histogram:
+		return []byte(histogramString), nil
 	default:
 		return nil, ErrInvalidMetricType
 	}
@@ -104,6 +109,8 @@ func (t *MetricType) UnmarshalText(data []byte) error {
 		*t = Trend
 	case rateString:
 		*t = Rate
+	case histogramString:
+		*t = Histogram
 	default:
 		return ErrInvalidMetricType
 	}
@@ -121,11 +128,32 @@ func (t MetricType) String() string {
 		return trendString
 	case Rate:
 		return rateString
+	case Histogram:
+		return histogramString
 	default:
 		return "[INVALID]"
 	}
 }
 
+// UnitSeconds is the unit of the metrics whose values are durations in seconds, the ones in milliseconds are Time.
+const UnitSeconds = "s"
+
+// ParseUnit returns the value type and the unit of a metric declared with the unit. The bytes and milliseconds
+// are the Data and Time value types, without a unit, the seconds are UnitSeconds, and the others are custom units,
+// like requests, which are kept as they are.
+func ParseUnit(unit string) (ValueType, string) {
+	switch strings.ToLower(unit) {
+	case "b", "bytes", "data":
+		return Data, ""
+	case "ms", "milliseconds", "time":
+		return Time, ""
+	case "s", "seconds":
+		return Default, UnitSeconds
+	default:
+		return Default, unit
+	}
+}
+
 // The type of values a metric contains.
 type ValueType int
 
@@ -440,14 +468,18 @@ func PushIfNotDone(ctx context.Context, output chan<- SampleContainer, sample Sa
 
 // A Metric defines the shape of a set of data.
 type Metric struct {
-	Name       string       `json:"name"`
-	Type       MetricType   `json:"type"`
-	Contains   ValueType    `json:"contains"`
-	Tainted    null.Bool    `json:"tainted"`
-	Thresholds Thresholds   `json:"thresholds"`
-	Submetrics []*Submetric `json:"submetrics"`
-	Sub        Submetric    `json:"sub,omitempty"`
-	Sink       Sink         `json:"-"`
+	Name        string       `json:"name"`
+	Type        MetricType   `json:"type"`
+	Contains    ValueType    `json:"contains"`
+	Unit        string       `json:"unit,omitempty"`        // of the Default values, see ParseUnit
+	Buckets     []float64    `json:"buckets,omitempty"`     // the bounds of the buckets of a Histogram
+	Description string       `json:"description,omitempty"` // optional, for the reports and the outputs
+	ID          string       `json:"id,omitempty"`          // optional, stable across the test runs
+	Tainted     null.Bool    `json:"tainted"`
+	Thresholds  Thresholds   `json:"thresholds"`
+	Submetrics  []*Submetric `json:"submetrics"`
+	Sub         Submetric    `json:"sub,omitempty"`
+	Sink        Sink         `json:"-"`
 }
 
 // Sample samples the metric at the given time, with the provided tags and value
@@ -475,6 +507,9 @@ func New(name string, typ MetricType, t ...ValueType) *Metric {
 		sink = &TrendSink{}
 	case Rate:
 		sink = &RateSink{}
+	case Histogram:
+		buckets := DefaultHistogramBuckets(vt)
+		return &Metric{Name: name, Type: typ, Contains: vt, Buckets: buckets, Sink: NewHistogramSink(buckets)}
 	default:
 		return nil
 	}
@@ -487,22 +522,68 @@ type Submetric struct {
 	Parent string      `json:"parent"`
 	Suffix string      `json:"suffix"`
 	Tags   *SampleTags `json:"tags"`
-	Metric *Metric     `json:"-"`
+	// Matchers are the tags matched with regular expressions, like `name=~"/api/v1/.*"`
+	Matchers []*TagMatcher `json:"matchers,omitempty"`
+	Metric   *Metric       `json:"-"`
+}
+
+// TagMatcher matches the values of a tag with a regular expression, which has to match the whole value.
+// A negated matcher matches the values that don't match it, and the samples without the tag.
+type TagMatcher struct {
+	Key     string `json:"key"`
+	Pattern string `json:"pattern"`
+	Negated bool   `json:"negated"`
+
+	re *regexp.Regexp
+}
+
+// Matches returns whether the tags match the ones of the submetric and its matchers.
+func (sm *Submetric) Matches(tags *SampleTags) bool {
+	if !tags.Contains(sm.Tags) {
+		return false
+	}
+	for _, m := range sm.Matchers {
+		v, ok := tags.Get(m.Key)
+		matches := ok && m.re.MatchString(v)
+		if matches == m.Negated {
+			return false
+		}
+	}
+	return true
 }
 
 // Creates a submetric from a name.
 func NewSubmetric(name string) (parentName string, sm *Submetric) {
+	parentName, sm, _ = ParseSubmetric(name)
+	return parentName, sm
+}
+
+// ParseSubmetric creates a submetric from a name, like NewSubmetric, and returns an error if the regular
+// expression of one of its tags is invalid. The matchers of the invalid ones match nothing.
+func ParseSubmetric(name string) (parentName string, sm *Submetric, err error) {
 	parts := strings.SplitN(strings.TrimSuffix(name, "}"), "{", 2)
 	if len(parts) == 1 {
-		return parts[0], &Submetric{Name: name}
+		return parts[0], &Submetric{Name: name}, nil
 	}
 
-	kvs := strings.Split(parts[1], ",")
+	kvs := splitSubmetricTags(parts[1])
 	tags := make(map[string]string, len(kvs))
+	var matchers []*TagMatcher
 	for _, kv := range kvs {
 		if kv == "" {
 			continue
 		}
+
+		if i, negated := indexTagMatcher(kv); i >= 0 {
+			matcher, matcherErr := newTagMatcher(strings.TrimSpace(kv[:i]), unquoteTagValue(kv[i+2:]), negated)
+			if matcherErr != nil && err == nil {
+				err = fmt.Errorf("invalid regular expression of the tag %s of the submetric %s: %w",
+					matcher.Key, name, matcherErr)
+			}
+			matchers = append(matchers, matcher)
+			continue
+		}
+
 		parts := strings.SplitN(kv, ":", 2)
 
 		key := strings.TrimSpace(strings.Trim(parts[0], `"'`))
@@ -514,7 +595,62 @@ func NewSubmetric(name string) (parentName string, sm *Submetric) {
 		value := strings.TrimSpace(strings.Trim(parts[1], `"'`))
 		tags[key] = value
 	}
-	return parts[0], &Submetric{Name: name, Parent: parts[0], Suffix: parts[1], Tags: IntoSampleTags(&tags)}
+	return parts[0], &Submetric{
+		Name: name, Parent: parts[0], Suffix: parts[1], Tags: IntoSampleTags(&tags), Matchers: matchers,
+	}, err
+}
+
+// splitSubmetricTags splits the tags of a submetric on the commas which aren't quoted.
+func splitSubmetricTags(tags string) []string {
+	var kvs []string
+	var quote rune
+	start := 0
+	for i, c := range tags {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			kvs = append(kvs, tags[start:i])
+			start = i + 1
+		}
+	}
+	return append(kvs, tags[start:])
+}
+
+// indexTagMatcher returns the index of the =~ or !~ operator of the tag, if it comes before any colon,
+// and whether it's the negated one.
+func indexTagMatcher(kv string) (int, bool) {
+	i, negated := strings.Index(kv, "=~"), false
+	if j := strings.Index(kv, "!~"); j >= 0 && (i < 0 || j < i) {
+		i, negated = j, true
+	}
+	if colon := strings.IndexByte(kv, ':'); colon >= 0 && colon < i {
+		return -1, false
+	}
+	return i, negated
+}
+
+func newTagMatcher(key, pattern string, negated bool) (*TagMatcher, error) {
+	m := &TagMatcher{Key: key, Pattern: pattern, Negated: negated}
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		// the invalid regular expressions match nothing
+		re = regexp.MustCompile(`[^\s\S]`)
+	}
+	m.re = re
+	return m, err
+}
+
+func unquoteTagValue(v string) string {
+	v = strings.TrimSpace(v)
+	if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+		return v[1 : len(v)-1]
+	}
+	return v
 }
 
 // parsePercentile is a helper function to parse and validate percentile notations
@@ -534,21 +670,21 @@ func parsePercentile(stat string) (float64, error) {
 
 // GetResolversForTrendColumns checks if passed trend columns are valid for use in
 // the summary output and then returns a map of the corresponding resolvers.
-func GetResolversForTrendColumns(trendColumns []string) (map[string]func(s *TrendSink) float64, error) {
-	staticResolvers := map[string]func(s *TrendSink) float64{
-		"avg":   func(s *TrendSink) float64 { return s.Avg },
-		"min":   func(s *TrendSink) float64 { return s.Min },
-		"med":   func(s *TrendSink) float64 { return s.Med },
-		"max":   func(s *TrendSink) float64 { return s.Max },
-		"count": func(s *TrendSink) float64 { return float64(s.Count) },
-	}
-	dynamicResolver := func(percentile float64) func(s *TrendSink) float64 {
-		return func(s *TrendSink) float64 {
+func GetResolversForTrendColumns(trendColumns []string) (map[string]func(s TrendStatsSink) float64, error) {
+	staticResolvers := map[string]func(s TrendStatsSink) float64{
+		"avg":   func(s TrendStatsSink) float64 { return s.TrendValues().Avg },
+		"min":   func(s TrendStatsSink) float64 { return s.TrendValues().Min },
+		"med":   func(s TrendStatsSink) float64 { return s.TrendValues().Med },
+		"max":   func(s TrendStatsSink) float64 { return s.TrendValues().Max },
+		"count": func(s TrendStatsSink) float64 { return float64(s.TrendValues().Count) },
+	}
+	dynamicResolver := func(percentile float64) func(s TrendStatsSink) float64 {
+		return func(s TrendStatsSink) float64 {
 			return s.P(percentile / 100)
 		}
 	}
 
-	result := make(map[string]func(s *TrendSink) float64, len(trendColumns))
+	result := make(map[string]func(s TrendStatsSink) float64, len(trendColumns))
 
 	for _, stat := range trendColumns {
 		if staticStat, ok := staticResolvers[stat]; ok {
@@ -27,6 +27,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNew(t *testing.T) {
@@ -35,10 +36,11 @@ func TestNew(t *testing.T) {
 		Type     MetricType
 		SinkType Sink
 	}{
-		"Counter": {Counter, &CounterSink{}},
-		"Gauge":   {Gauge, &GaugeSink{}},
-		"Trend":   {Trend, &TrendSink{}},
-		"Rate":    {Rate, &RateSink{}},
+		"Counter":   {Counter, &CounterSink{}},
+		"Gauge":     {Gauge, &GaugeSink{}},
+		"Trend":     {Trend, &TrendSink{}},
+		"Rate":      {Rate, &RateSink{}},
+		"Histogram": {Histogram, &HistogramSink{}},
 	}
 
 	for name, data := range testdata {
@@ -83,6 +85,62 @@ func TestNewSubmetric(t *testing.T) {
 	}
 }
 
+func TestSubmetricMatches(t *testing.T) {
+	t.Parallel()
+
+	tags := func(kvs ...string) *SampleTags {
+		m := make(map[string]string)
+		for i := 0; i < len(kvs); i += 2 {
+			m[kvs[i]] = kvs[i+1]
+		}
+		return IntoSampleTags(&m)
+	}
+	testdata := map[string]struct {
+		matches    []*SampleTags
+		mismatches []*SampleTags
+	}{
+		`my_metric{name=~"/api/v1/.*"}`: {
+			[]*SampleTags{tags("name", "/api/v1/users"), tags("name", "/api/v1/", "status", "200")},
+			[]*SampleTags{tags("name", "/api/v2/users"), tags("name", "/v1/api/v1/users"), nil},
+		},
+		`my_metric{status:200, name=~'/api/v[12]/.*'}`: {
+			[]*SampleTags{tags("name", "/api/v2/users", "status", "200")},
+			[]*SampleTags{tags("name", "/api/v2/users", "status", "500"), tags("status", "200")},
+		},
+		`my_metric{name!~"/static/.*",method=~"GET|POST"}`: {
+			[]*SampleTags{tags("name", "/api", "method", "GET"), tags("method", "POST")},
+			[]*SampleTags{tags("name", "/static/a.css", "method", "GET"), tags("name", "/api", "method", "PUT")},
+		},
+		`my_metric{name=~"/api/[a-z]{1,3}"}`: {
+			[]*SampleTags{tags("name", "/api/abc")},
+			[]*SampleTags{tags("name", "/api/abcd")},
+		},
+	}
+
+	for name, data := range testdata {
+		name, data := name, data
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			parent, sm, err := ParseSubmetric(name)
+			require.NoError(t, err)
+			assert.Equal(t, "my_metric", parent)
+			for _, tags := range data.matches {
+				assert.True(t, sm.Matches(tags), tags.CloneTags())
+			}
+			for _, tags := range data.mismatches {
+				assert.False(t, sm.Matches(tags), tags.CloneTags())
+			}
+		})
+	}
+
+	t.Run("invalid regular expression", func(t *testing.T) {
+		t.Parallel()
+		_, sm, err := ParseSubmetric(`my_metric{name=~"/api/(v1"}`)
+		assert.Error(t, err)
+		assert.False(t, sm.Matches(tags("name", "/api/(v1")))
+	})
+}
+
 func TestSampleTags(t *testing.T) {
 	t.Parallel()
 
@@ -26,9 +26,63 @@ import (
 	"strings"
 	"time"
 
+	"go.k6.io/k6/errext"
 	"go.k6.io/k6/lib/types"
 )
 
+// ThresholdSeverity is how serious the failure of a threshold is
+type ThresholdSeverity string
+
+// The possible threshold severities
+const (
+	// ThresholdSeverityWarn thresholds are only reported when they fail, without failing the test run
+	ThresholdSeverityWarn ThresholdSeverity = "warn"
+	// ThresholdSeverityFail thresholds fail the test run when they fail, it's the default severity
+	ThresholdSeverityFail ThresholdSeverity = "fail"
+	// ThresholdSeverityCritical thresholds fail the test run with their exit code before the ones of the
+	// thresholds with the fail severity
+	ThresholdSeverityCritical ThresholdSeverity = "critical"
+)
+
+// rank returns the order of the severity, the failed threshold with the highest one sets the exit code
+func (s ThresholdSeverity) rank() int {
+	switch s {
+	case ThresholdSeverityWarn:
+		return 0
+	case ThresholdSeverityCritical:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// ThresholdSLO is the service level objective of a threshold on the burn_rate of a Rate metric, which is
+// the rate of its errors divided by the one its error budget allows.
+type ThresholdSLO struct {
+	// Target is the targeted availability, e.g. 0.999 for an error budget of 0.1%
+	Target float64 `json:"target"`
+	// Windows, if set, are the sliding windows of the burn rate, e.g. 5m and 1h. The threshold fails
+	// only when it fails in all of them, like the multi-window burn rate alerts.
+	Windows []types.Duration `json:"windows,omitempty"`
+	// Successes marks that the trues of the Rate are the successes, like the ones of the checks,
+	// instead of the errors, like the ones of http_req_failed
+	Successes bool `json:"successes,omitempty"`
+}
+
+// withBurnRate returns a copy of the values of a Rate with the burn rate of its error budget.
+func (slo *ThresholdSLO) withBurnRate(sinked map[string]float64) map[string]float64 {
+	values := make(map[string]float64, len(sinked)+1)
+	for k, v := range sinked {
+		values[k] = v
+	}
+	errorRate := sinked[tokenRate]
+	if slo.Successes {
+		errorRate = 1 - errorRate
+	}
+	values[tokenBurnRate] = errorRate / (1 - slo.Target)
+	return values
+}
+
 // Threshold is a representation of a single threshold for a single metric
 type Threshold struct {
 	// Source is the text based source of the threshold
@@ -40,19 +94,50 @@ type Threshold struct {
 	// AbortGracePeriod is a the minimum amount of time a test should be running before a failing
 	// this threshold will abort the test
 	AbortGracePeriod types.NullDuration
+	// Window, if set, is the sliding window of the last samples to which the threshold is applied,
+	// instead of all the samples of the test run
+	Window types.NullDuration
+	// StartAfter, if set, is the time from the start of the test run before which the samples are ignored
+	StartAfter types.NullDuration
+	// IgnoreRampUp marks that the samples of the ramp-up of the ramping scenarios are ignored
+	IgnoreRampUp bool
+	// Severity is how serious the failure of the threshold is, the empty one is ThresholdSeverityFail
+	Severity ThresholdSeverity
+	// ExitCode, if not zero, is the exit code of the test run when the threshold fails
+	ExitCode errext.ExitCode
+	// SLO, if set, is the service level objective of the burn_rate of the threshold
+	SLO *ThresholdSLO
+	// rampUp is the duration of the ramp-up of the test run
+	rampUp time.Duration
 	// parsed is the threshold expression parsed from the Source
 	parsed *thresholdExpression
+	// condition is the composite threshold expression parsed from the Source, when it isn't a simple one
+	condition thresholdCondition
+	// aggregations are the aggregation methods used by the threshold
+	aggregations []*thresholdAggregation
+	// values are the values of the aggregations of the last testing of the threshold
+	values map[string]float64
 }
 
-func newThreshold(src string, abortOnFail bool, gracePeriod types.NullDuration) *Threshold {
+func newThreshold(src string, abortOnFail bool, gracePeriod, window types.NullDuration) *Threshold {
 	return &Threshold{
 		Source:           src,
 		AbortOnFail:      abortOnFail,
 		AbortGracePeriod: gracePeriod,
+		Window:           window,
 		parsed:           nil,
 	}
 }
 
+// start returns the time from the start of the test run of the first samples of the threshold.
+func (t *Threshold) start() time.Duration {
+	start := time.Duration(t.StartAfter.Duration)
+	if t.IgnoreRampUp && t.rampUp > start {
+		start = t.rampUp
+	}
+	return start
+}
+
 func (t *Threshold) runNoTaint(sinks map[string]float64) (bool, error) {
 	// Extract the sink value for the aggregation method used in the threshold
 	// expression
@@ -66,23 +151,8 @@ func (t *Threshold) runNoTaint(sinks map[string]float64) (bool, error) {
 
 	// Apply the threshold expression operator to the left and
 	// right hand side values
-	var passes bool
-	switch t.parsed.Operator {
-	case ">":
-		passes = lhs > t.parsed.Value
-	case ">=":
-		passes = lhs >= t.parsed.Value
-	case "<=":
-		passes = lhs <= t.parsed.Value
-	case "<":
-		passes = lhs < t.parsed.Value
-	case "==", "===":
-		// Considering a sink always maps to float64 values,
-		// strictly equal is equivalent to loosely equal
-		passes = lhs == t.parsed.Value
-	case "!=":
-		passes = lhs != t.parsed.Value
-	default:
+	passes, ok := compareThresholdValues(lhs, t.parsed.Operator, t.parsed.Value)
+	if !ok {
 		// The parseThresholdExpression function should ensure that no invalid
 		// operator gets through, but let's protect our future selves anyhow.
 		return false, fmt.Errorf("unable to apply threshold %s over metrics; "+
@@ -96,16 +166,102 @@ func (t *Threshold) runNoTaint(sinks map[string]float64) (bool, error) {
 	return passes, nil
 }
 
+// compareThresholdValues applies the operator to the values, and returns false as its second value
+// if the operator is unknown.
+func compareThresholdValues(lhs float64, operator string, rhs float64) (bool, bool) {
+	switch operator {
+	case ">":
+		return lhs > rhs, true
+	case ">=":
+		return lhs >= rhs, true
+	case "<=":
+		return lhs <= rhs, true
+	case "<":
+		return lhs < rhs, true
+	case "==", "===":
+		// Considering a sink always maps to float64 values,
+		// strictly equal is equivalent to loosely equal
+		return lhs == rhs, true
+	case "!=":
+		return lhs != rhs, true
+	default:
+		return false, false
+	}
+}
+
 func (t *Threshold) run(sinks map[string]float64) (bool, error) {
 	passes, err := t.runNoTaint(sinks)
 	t.LastFailed = !passes
 	return passes, err
 }
 
+// evaluate tests the threshold with the values of its metric, the ones of the metrics it refers to
+// and the ones of the baseline, and keeps them.
+func (t *Threshold) evaluate(sinks map[string]float64, referenced, baseline thresholdValues) (bool, error) {
+	var passes bool
+	var err error
+	if t.condition != nil {
+		passes, err = t.runCondition(sinks, referenced, baseline)
+	} else {
+		passes, err = t.run(sinks)
+	}
+	t.setValues(sinks, referenced)
+	return passes, err
+}
+
+// Values returns the values of the aggregations of the metrics with which the threshold was last tested,
+// by aggregation method, prefixed with "metric." for the ones of the metrics it refers to.
+func (t *Threshold) Values() map[string]float64 {
+	return t.values
+}
+
+// setValues keeps the values of the aggregations of the threshold, without the ones of the baseline.
+func (t *Threshold) setValues(sinks map[string]float64, referenced thresholdValues) {
+	t.values = make(map[string]float64, len(t.aggregations))
+	for _, a := range t.aggregations {
+		if a.Baseline {
+			continue
+		}
+		values, key := sinks, a.AggregationMethod
+		if a.Metric != "" {
+			values, key = referenced[a.Metric], a.Metric+"."+a.AggregationMethod
+		}
+		if v, ok := values[a.AggregationMethod]; ok {
+			t.values[key] = v
+		}
+	}
+}
+
+// runCondition evaluates the composite threshold expression with the values of the metric of the thresholds,
+// the ones of the metrics it refers to and the ones of the baseline.
+func (t *Threshold) runCondition(sinks map[string]float64, referenced, baseline thresholdValues) (bool, error) {
+	values := make(thresholdValues, len(referenced)+len(baseline)+1)
+	for name, v := range referenced {
+		values[name] = v
+	}
+	for name, v := range baseline {
+		values[thresholdBaselinePrefix+name] = v
+	}
+	values[""] = sinks
+
+	passes, err := t.condition.eval(values)
+	if err != nil {
+		err = fmt.Errorf("unable to apply threshold %s over metrics; reason: %w", t.Source, err)
+	}
+	t.LastFailed = !passes
+	return passes, err
+}
+
 type thresholdConfig struct {
 	Threshold        string             `json:"threshold"`
 	AbortOnFail      bool               `json:"abortOnFail"`
 	AbortGracePeriod types.NullDuration `json:"delayAbortEval"`
+	Window           *types.Duration    `json:"window,omitempty"`
+	StartAfter       *types.Duration    `json:"startAfter,omitempty"`
+	IgnoreRampUp     bool               `json:"ignoreRampUp,omitempty"`
+	Severity         ThresholdSeverity  `json:"severity,omitempty"`
+	ExitCode         errext.ExitCode    `json:"exitCode,omitempty"`
+	SLO              *ThresholdSLO      `json:"slo,omitempty"`
 }
 
 // used internally for JSON marshalling
@@ -123,18 +279,76 @@ func (tc *thresholdConfig) UnmarshalJSON(data []byte) error {
 
 func (tc thresholdConfig) MarshalJSON() ([]byte, error) {
 	var data interface{} = tc.Threshold
-	if tc.AbortOnFail {
+	if tc.AbortOnFail || tc.Window != nil || tc.StartAfter != nil || tc.IgnoreRampUp ||
+		tc.Severity != "" || tc.ExitCode != 0 || tc.SLO != nil {
 		data = rawThresholdConfig(tc)
 	}
 
 	return MarshalJSONWithoutHTMLEscape(data)
 }
 
+// validateSLO checks that the threshold has a valid SLO when it uses the burn_rate, and only then.
+func (t *Threshold) validateSLO() error {
+	burnRate := false
+	for _, a := range t.aggregations {
+		burnRate = burnRate || (a.AggregationMethod == tokenBurnRate && a.Metric == "")
+	}
+	switch {
+	case t.SLO == nil && burnRate:
+		return fmt.Errorf("the threshold %s needs an slo for its %s", t.Source, tokenBurnRate)
+	case t.SLO == nil:
+		return nil
+	case !burnRate:
+		return fmt.Errorf("the threshold %s has an slo, but doesn't use the %s", t.Source, tokenBurnRate)
+	case t.SLO.Target <= 0 || t.SLO.Target >= 1:
+		return fmt.Errorf("the slo target of the threshold %s needs to be between 0 and 1, not %g",
+			t.Source, t.SLO.Target)
+	case t.Window.Valid:
+		return fmt.Errorf("the threshold %s can't have a window, the windows of its slo are used instead",
+			t.Source)
+	}
+	for _, w := range t.SLO.Windows {
+		if w <= 0 {
+			return fmt.Errorf("the slo windows of the threshold %s need to be positive, not %s", t.Source, w)
+		}
+	}
+	return nil
+}
+
+// MostSevereFailedThreshold returns the first of the failed thresholds, among the ones which fail the
+// test run, with the highest severity, or nil if none failed.
+func MostSevereFailedThreshold(thresholds []*Threshold) *Threshold {
+	var result *Threshold
+	for _, t := range thresholds {
+		if !t.LastFailed || t.Severity == ThresholdSeverityWarn {
+			continue
+		}
+		if result == nil || t.Severity.rank() > result.Severity.rank() {
+			result = t
+		}
+	}
+	return result
+}
+
 // Thresholds is the combination of all Thresholds for a given metric
 type Thresholds struct {
 	Thresholds []*Threshold
 	Abort      bool
 	sinked     map[string]float64
+	// rate is whether the metric of the thresholds is a Rate, which has a burn_rate
+	rate bool
+	// referenced has the values of the other metrics used by the thresholds
+	referenced thresholdValues
+	// baseline has the values of the metric and the other metrics in the baseline
+	baseline thresholdValues
+
+	// window has the samples of the longest Window of the thresholds, it's nil when none has one
+	window          *WindowSink
+	windowRetention time.Duration
+	// trendRelativeError is the relative error of the Trends of the window and the started samples
+	trendRelativeError float64
+	// started has the samples since the starts of the thresholds which ignore the first samples
+	started map[time.Duration]Sink
 }
 
 // NewThresholds returns Thresholds objects representing the provided source strings
@@ -151,23 +365,180 @@ func newThresholdsWithConfig(configs []thresholdConfig) Thresholds {
 	thresholds := make([]*Threshold, len(configs))
 	sinked := make(map[string]float64)
 
+	var windowRetention time.Duration
 	for i, config := range configs {
-		t := newThreshold(config.Threshold, config.AbortOnFail, config.AbortGracePeriod)
+		var window types.NullDuration
+		if config.Window != nil {
+			window = types.NullDurationFrom(time.Duration(*config.Window))
+			if d := time.Duration(*config.Window); d > windowRetention {
+				windowRetention = d
+			}
+		}
+		t := newThreshold(config.Threshold, config.AbortOnFail, config.AbortGracePeriod, window)
+		if config.StartAfter != nil {
+			t.StartAfter = types.NullDurationFrom(time.Duration(*config.StartAfter))
+		}
+		t.IgnoreRampUp = config.IgnoreRampUp
+		t.Severity = config.Severity
+		t.ExitCode = config.ExitCode
+		t.SLO = config.SLO
+		if config.SLO != nil {
+			for _, w := range config.SLO.Windows {
+				if d := time.Duration(w); d > windowRetention {
+					windowRetention = d
+				}
+			}
+		}
 		thresholds[i] = t
 	}
 
-	return Thresholds{thresholds, false, sinked}
+	return Thresholds{Thresholds: thresholds, sinked: sinked, windowRetention: windowRetention}
+}
+
+// Copy returns a copy of the thresholds, parsed if they were, without their results and samples.
+func (ts Thresholds) Copy() Thresholds {
+	thresholds := make([]*Threshold, len(ts.Thresholds))
+	for i, t := range ts.Thresholds {
+		c := *t
+		c.LastFailed, c.values = false, nil
+		thresholds[i] = &c
+	}
+	return Thresholds{
+		Thresholds:         thresholds,
+		sinked:             make(map[string]float64),
+		windowRetention:    ts.windowRetention,
+		trendRelativeError: ts.trendRelativeError,
+	}
+}
+
+// SetBaseline sets the values in the baseline, by metric name, to which the thresholds can compare the ones
+// of their metric, which is named name, and of the metrics they refer to.
+func (ts *Thresholds) SetBaseline(name string, baseline map[string]map[string]float64) {
+	ts.baseline = make(thresholdValues)
+	if values, ok := baseline[name]; ok {
+		ts.baseline[""] = values
+	}
+	for _, ref := range ts.References() {
+		if values, ok := baseline[ref]; ok {
+			ts.baseline[ref] = values
+		}
+	}
+}
+
+// SetRampUpDuration sets the duration of the ramp-up of the test run, whose samples are ignored
+// by the thresholds with IgnoreRampUp.
+func (ts *Thresholds) SetRampUpDuration(d time.Duration) {
+	for _, t := range ts.Thresholds {
+		t.rampUp = d
+	}
+}
+
+// SetTrendRelativeError sets the relative error of the percentiles of the Trends, for the samples of the
+// thresholds with a Window or which ignore the first samples, like the one of the sink of their metric.
+func (ts *Thresholds) SetTrendRelativeError(relativeError float64) {
+	ts.trendRelativeError = relativeError
+}
+
+// AddSample adds the sample, processed at the elapsed time of the test run, to the sliding window
+// of the thresholds with a Window, and to the samples of the thresholds which ignore the first ones.
+func (ts *Thresholds) AddSample(s Sample, elapsed time.Duration) {
+	if ts.windowRetention > 0 {
+		if ts.window == nil {
+			ts.window = NewWindowSink(s.Metric, ts.windowRetention, ts.trendRelativeError)
+		}
+		ts.window.Add(s)
+	}
+
+thresholds:
+	for i, t := range ts.Thresholds {
+		start := t.start()
+		if start == 0 || elapsed < start {
+			continue
+		}
+		// the thresholds with the same start share their samples
+		for _, previous := range ts.Thresholds[:i] {
+			if previous.start() == start {
+				continue thresholds
+			}
+		}
+		if ts.started == nil {
+			ts.started = make(map[time.Duration]Sink)
+		}
+		sink, ok := ts.started[start]
+		if !ok {
+			sink = newWindowSecondSink(s.Metric, ts.trendRelativeError)
+			ts.started[start] = sink
+		}
+		sink.Add(s)
+	}
 }
 
 func (ts *Thresholds) runAll(timeSpentInTest time.Duration) (bool, error) {
 	succeeded := true
+	now := time.Now()
+thresholds:
 	for i, threshold := range ts.Thresholds {
-		b, err := threshold.run(ts.sinked)
+		// the thresholds pass when the metrics they refer to have no samples yet,
+		// or their baselines have no values
+		for _, name := range thresholdMetrics(threshold.aggregations) {
+			if _, ok := ts.referenced[name]; !ok {
+				threshold.LastFailed = false
+				continue thresholds
+			}
+		}
+		for _, a := range threshold.aggregations {
+			if _, ok := ts.baseline[a.Metric][a.AggregationMethod]; a.Baseline && !ok {
+				threshold.LastFailed = false
+				continue thresholds
+			}
+		}
+
+		sinked := ts.sinked
+		start := threshold.start()
+		if start > 0 {
+			// the thresholds pass until they have samples since their start
+			sink, ok := ts.started[start]
+			if !ok || timeSpentInTest <= start {
+				threshold.LastFailed = false
+				continue
+			}
+			if !threshold.Window.Valid {
+				var err error
+				if sinked, err = ts.sinkValues(sink, timeSpentInTest-start, ""); err != nil {
+					return false, err
+				}
+			}
+		}
+		if threshold.Window.Valid {
+			var ok bool
+			var err error
+			sinked, ok, err = ts.windowValues(time.Duration(threshold.Window.Duration), start, timeSpentInTest, now)
+			if err != nil {
+				return false, err
+			}
+			// the thresholds pass when there are no samples in their window
+			if !ok {
+				threshold.LastFailed = false
+				continue
+			}
+		}
+
+		var b bool
+		var err error
+		if threshold.SLO != nil {
+			b, err = ts.runBurnRate(threshold, sinked, start, timeSpentInTest, now)
+		} else {
+			b, err = threshold.evaluate(sinked, ts.referenced, ts.baseline)
+		}
 		if err != nil {
 			return false, fmt.Errorf("threshold %d run error: %w", i, err)
 		}
 
 		if !b {
+			// the thresholds with the warn severity are only reported
+			if threshold.Severity == ThresholdSeverityWarn {
+				continue
+			}
 			succeeded = false
 
 			if ts.Abort || !threshold.AbortOnFail {
@@ -182,11 +553,106 @@ func (ts *Thresholds) runAll(timeSpentInTest time.Duration) (bool, error) {
 	return succeeded, nil
 }
 
+// windowValues returns the values of the samples of the sliding window, which doesn't go back before the start
+// of the threshold, or false if it has none.
+func (ts *Thresholds) windowValues(
+	window, start, timeSpentInTest time.Duration, now time.Time,
+) (map[string]float64, bool, error) {
+	if ts.window == nil {
+		return nil, false, nil
+	}
+	if start > 0 && timeSpentInTest-start < window {
+		window = timeSpentInTest - start
+	}
+	sink, duration, ok := ts.window.Aggregate(now, window)
+	if !ok {
+		return nil, false, nil
+	}
+	sinked, err := ts.sinkValues(sink, duration, "")
+	if err != nil {
+		return nil, false, err
+	}
+	return sinked, true, nil
+}
+
+// runBurnRate evaluates the threshold with the burn rate of the error budget of its SLO, in each of its
+// windows, and fails it only when it fails in all of them.
+func (ts *Thresholds) runBurnRate(
+	t *Threshold, sinked map[string]float64, start, timeSpentInTest time.Duration, now time.Time,
+) (bool, error) {
+	if !ts.rate {
+		return false, fmt.Errorf("the threshold %s needs a Rate metric for its %s", t.Source, tokenBurnRate)
+	}
+	if len(t.SLO.Windows) == 0 {
+		return t.evaluate(t.SLO.withBurnRate(sinked), ts.referenced, ts.baseline)
+	}
+
+	passes := false
+	values := make(map[string]float64, len(t.SLO.Windows))
+	for _, w := range t.SLO.Windows {
+		windowed, ok, err := ts.windowValues(time.Duration(w), start, timeSpentInTest, now)
+		if err != nil {
+			return false, err
+		}
+		// the windows without samples have no errors
+		if !ok {
+			passes = true
+			continue
+		}
+		windowed = t.SLO.withBurnRate(windowed)
+		b, err := t.evaluate(windowed, ts.referenced, ts.baseline)
+		if err != nil {
+			return false, err
+		}
+		passes = passes || b
+		values[tokenBurnRate+"@"+w.String()] = windowed[tokenBurnRate]
+	}
+	t.LastFailed, t.values = !passes, values
+	return passes, nil
+}
+
 // Run processes all the thresholds with the provided Sink at the provided time and returns if any
 // of them fails
 func (ts *Thresholds) Run(sink Sink, duration time.Duration) (bool, error) {
-	// Initialize the sinks store
-	ts.sinked = make(map[string]float64)
+	return ts.RunWithMetrics(sink, duration, nil)
+}
+
+// RunWithMetrics is like Run, with the metrics to which the composite thresholds can refer.
+// The thresholds that refer to metrics which aren't in metrics pass.
+func (ts *Thresholds) RunWithMetrics(sink Sink, duration time.Duration, metrics map[string]*Metric) (bool, error) {
+	var err error
+	if ts.sinked, err = ts.sinkValues(sink, duration, ""); err != nil {
+		return false, err
+	}
+	_, ts.rate = sink.(*RateSink)
+
+	ts.referenced = make(thresholdValues)
+	for _, name := range ts.References() {
+		m, ok := metrics[name]
+		if !ok {
+			continue
+		}
+		if ts.referenced[name], err = ts.sinkValues(m.Sink, duration, name); err != nil {
+			return false, err
+		}
+	}
+
+	return ts.runAll(duration)
+}
+
+// References returns the sorted names of the other metrics, or submetrics, to which the parsed thresholds refer.
+func (ts *Thresholds) References() []string {
+	var aggregations []*thresholdAggregation
+	for _, t := range ts.Thresholds {
+		aggregations = append(aggregations, t.aggregations...)
+	}
+	return thresholdMetrics(aggregations)
+}
+
+// sinkValues returns the values of the sink for the aggregation methods of the thresholds
+// on the metric, which is empty for the metric of the thresholds.
+func (ts *Thresholds) sinkValues(sink Sink, duration time.Duration, metric string) (map[string]float64, error) {
+	sinked := make(map[string]float64)
 
 	// FIXME: Remove this comment as soon as the stats.Sink does not expose Format anymore.
 	//
@@ -197,46 +663,88 @@ func (ts *Thresholds) Run(sink Sink, duration time.Duration) (bool, error) {
 	// For more details, see https://github.com/grafana/k6/issues/2320
 	switch sinkImpl := sink.(type) {
 	case *CounterSink:
-		ts.sinked["count"] = sinkImpl.Value
-		ts.sinked["rate"] = sinkImpl.Value / (float64(duration) / float64(time.Second))
+		sinked["count"] = sinkImpl.Value
+		sinked["rate"] = sinkImpl.Value / (float64(duration) / float64(time.Second))
 	case *GaugeSink:
-		ts.sinked["value"] = sinkImpl.Value
-	case *TrendSink:
-		ts.sinked["min"] = sinkImpl.Min
-		ts.sinked["max"] = sinkImpl.Max
-		ts.sinked["avg"] = sinkImpl.Avg
-		ts.sinked["med"] = sinkImpl.Med
+		sinked["value"] = sinkImpl.Value
+	case TrendStatsSink:
+		// the Trends and the Histograms
+		values := sinkImpl.TrendValues()
+		sinked["min"] = values.Min
+		sinked["max"] = values.Max
+		sinked["avg"] = values.Avg
+		sinked["med"] = values.Med
+		if _, ok := sinkImpl.(*HistogramSink); ok {
+			sinked["count"] = float64(values.Count)
+		}
 
 		// Parse the percentile thresholds and insert them in
 		// the sinks mapping.
 		for _, threshold := range ts.Thresholds {
-			if !strings.HasPrefix(threshold.parsed.AggregationMethod, "p(") {
-				continue
-			}
+			for _, a := range threshold.aggregations {
+				if a.Metric != metric || !strings.HasPrefix(a.AggregationMethod, "p(") {
+					continue
+				}
 
-			ts.sinked[threshold.parsed.AggregationMethod] = sinkImpl.P(threshold.parsed.AggregationValue.Float64 / 100)
+				sinked[a.AggregationMethod] = sinkImpl.P(a.AggregationValue.Float64 / 100)
+			}
 		}
 	case *RateSink:
-		ts.sinked["rate"] = float64(sinkImpl.Trues) / float64(sinkImpl.Total)
+		sinked["rate"] = float64(sinkImpl.Trues) / float64(sinkImpl.Total)
 	case DummySink:
 		for k, v := range sinkImpl {
-			ts.sinked[k] = v
+			sinked[k] = v
 		}
 	default:
-		return false, fmt.Errorf("unable to run Thresholds; reason: unknown sink type")
+		return nil, fmt.Errorf("unable to run Thresholds; reason: unknown sink type")
 	}
 
-	return ts.runAll(duration)
+	return sinked, nil
 }
 
-// Parse parses the Thresholds and fills each Threshold.parsed field with the result.
+// Parse parses the Thresholds and fills each Threshold.parsed field with the result,
+// or the Threshold.condition one for the composite threshold expressions.
 // It effectively asserts they are syntaxically correct.
 func (ts *Thresholds) Parse() error {
 	for _, t := range ts.Thresholds {
 		parsed, err := parseThresholdExpression(t.Source)
-		if err != nil {
+		if err == nil {
+			t.aggregations = []*thresholdAggregation{{
+				AggregationMethod: parsed.AggregationMethod,
+				AggregationValue:  parsed.AggregationValue,
+			}}
+		} else {
+			var condition thresholdCondition
+			if condition, t.aggregations, err = parseThresholdCondition(t.Source); err != nil {
+				return err
+			}
+			t.condition = condition
+		}
+		if t.Window.Valid && t.Window.Duration <= 0 {
+			return fmt.Errorf("the window of the threshold %s needs to be positive, not %s",
+				t.Source, t.Window.Duration)
+		}
+		if t.StartAfter.Valid && t.StartAfter.Duration < 0 {
+			return fmt.Errorf("the startAfter of the threshold %s can't be negative, but it's %s",
+				t.Source, t.StartAfter.Duration)
+		}
+		switch t.Severity {
+		case "", ThresholdSeverityWarn, ThresholdSeverityFail, ThresholdSeverityCritical:
+		default:
+			return fmt.Errorf("the severity of the threshold %s needs to be %s, %s or %s, not %q", t.Source,
+				ThresholdSeverityWarn, ThresholdSeverityFail, ThresholdSeverityCritical, t.Severity)
+		}
+		if t.Severity == ThresholdSeverityWarn && t.ExitCode != 0 {
+			return fmt.Errorf("the threshold %s with the %s severity can't have an exit code, since it doesn't "+
+				"fail the test run", t.Source, ThresholdSeverityWarn)
+		}
+		if err := t.validateSLO(); err != nil {
 			return err
 		}
+		if t.Severity == ThresholdSeverityWarn && t.AbortOnFail {
+			return fmt.Errorf("the threshold %s with the %s severity can't abort the test run on failure",
+				t.Source, ThresholdSeverityWarn)
+		}
 
 		t.parsed = parsed
 	}
@@ -263,6 +771,18 @@ func (ts Thresholds) MarshalJSON() ([]byte, error) {
 		configs[i].Threshold = t.Source
 		configs[i].AbortOnFail = t.AbortOnFail
 		configs[i].AbortGracePeriod = t.AbortGracePeriod
+		if t.Window.Valid {
+			window := t.Window.Duration
+			configs[i].Window = &window
+		}
+		if t.StartAfter.Valid {
+			startAfter := t.StartAfter.Duration
+			configs[i].StartAfter = &startAfter
+		}
+		configs[i].IgnoreRampUp = t.IgnoreRampUp
+		configs[i].Severity = t.Severity
+		configs[i].ExitCode = t.ExitCode
+		configs[i].SLO = t.SLO
 	}
 
 	return MarshalJSONWithoutHTMLEscape(configs)
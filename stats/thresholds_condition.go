@@ -0,0 +1,543 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package stats
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/guregu/null.v3"
+)
+
+// thresholdValues are the values of the aggregation methods of the metrics used by the threshold conditions,
+// by metric name. The ones of the metric of the thresholds are under the empty name.
+type thresholdValues map[string]map[string]float64
+
+// thresholdCondition is a composite threshold expression, like `p(95)<500 and rate>100`, which can also compare
+// the aggregation methods of other metrics, like `http_req_duration{group:a}.p(99) < 2 * med`.
+type thresholdCondition interface {
+	eval(values thresholdValues) (bool, error)
+}
+
+// thresholdOperand is a numeric term of a threshold condition.
+type thresholdOperand interface {
+	value(values thresholdValues) (float64, error)
+}
+
+// thresholdBaselinePrefix prefixes the metrics of the values of the baseline, since the names of the metrics
+// can't have a colon.
+const thresholdBaselinePrefix = "baseline:"
+
+// thresholdAggregation is an aggregation method of the metric of the thresholds, or of the metric named by
+// Metric when it's not empty. A Baseline one is the value of the aggregation method in the baseline.
+type thresholdAggregation struct {
+	Metric            string
+	AggregationMethod string
+	AggregationValue  null.Float
+	Baseline          bool
+}
+
+func (a *thresholdAggregation) value(values thresholdValues) (float64, error) {
+	if a.Baseline {
+		v, ok := values[thresholdBaselinePrefix+a.Metric][a.AggregationMethod]
+		if !ok {
+			return 0, fmt.Errorf("no baseline value of the %s aggregation method", a.AggregationMethod)
+		}
+		return v, nil
+	}
+	v, ok := values[a.Metric][a.AggregationMethod]
+	if !ok {
+		if a.Metric != "" {
+			return 0, fmt.Errorf("the metric %s has no %s aggregation method", a.Metric, a.AggregationMethod)
+		}
+		return 0, fmt.Errorf("no metric supporting the %s aggregation method found", a.AggregationMethod)
+	}
+	return v, nil
+}
+
+type thresholdNumber float64
+
+func (n thresholdNumber) value(thresholdValues) (float64, error) {
+	return float64(n), nil
+}
+
+type thresholdArithmetic struct {
+	operator    string
+	left, right thresholdOperand
+}
+
+func (a thresholdArithmetic) value(values thresholdValues) (float64, error) {
+	left, err := a.left.value(values)
+	if err != nil {
+		return 0, err
+	}
+	right, err := a.right.value(values)
+	if err != nil {
+		return 0, err
+	}
+	switch a.operator {
+	case "+":
+		return left + right, nil
+	case "-":
+		return left - right, nil
+	case "*":
+		return left * right, nil
+	default:
+		return left / right, nil
+	}
+}
+
+type thresholdNegation struct {
+	operand thresholdOperand
+}
+
+func (n thresholdNegation) value(values thresholdValues) (float64, error) {
+	v, err := n.operand.value(values)
+	return -v, err
+}
+
+type thresholdComparison struct {
+	operator    string
+	left, right thresholdOperand
+}
+
+func (c thresholdComparison) eval(values thresholdValues) (bool, error) {
+	left, err := c.left.value(values)
+	if err != nil {
+		return false, err
+	}
+	right, err := c.right.value(values)
+	if err != nil {
+		return false, err
+	}
+	passes, ok := compareThresholdValues(left, c.operator, right)
+	if !ok {
+		return false, fmt.Errorf("%s is an invalid operator", c.operator)
+	}
+	return passes, nil
+}
+
+type thresholdLogical struct {
+	and         bool
+	left, right thresholdCondition
+}
+
+func (l thresholdLogical) eval(values thresholdValues) (bool, error) {
+	left, err := l.left.eval(values)
+	if err != nil || left != l.and {
+		// the right hand side isn't needed when the left one is false for and, or true for or
+		return left, err
+	}
+	return l.right.eval(values)
+}
+
+type thresholdNot struct {
+	condition thresholdCondition
+}
+
+func (n thresholdNot) eval(values thresholdValues) (bool, error) {
+	passes, err := n.condition.eval(values)
+	return !passes, err
+}
+
+// parseThresholdCondition parses a composite threshold expression, and returns it with its aggregations.
+//
+// It is expected to be of the form defined by the following BNF, where the aggregation methods are the ones of
+// parseThresholdExpression, and the names of the metrics are made of letters, digits and underscores, optionally
+// followed by the tags of a submetric. The baseline is the value in the baseline of the first aggregation of its
+// comparison:
+// ```
+// condition    -> and (("or" | "||") and)*
+// and          -> not (("and" | "&&") not)*
+// not          -> ("not" | "!") not | "(" condition ")" | comparison
+// comparison   -> sum operator sum
+// sum          -> product (("+" | "-") product)*
+// product      -> unary (("*" | "/") unary)*
+// unary        -> "-" unary | "(" sum ")" | float | aggregation | "baseline"
+// aggregation  -> (metric ("{" tags "}")? ".")? aggregation_method
+// ```
+func parseThresholdCondition(input string) (thresholdCondition, []*thresholdAggregation, error) {
+	tokens, err := scanThresholdCondition(input)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed parsing threshold expression %q; reason: %w", input, err)
+	}
+
+	p := &thresholdConditionParser{tokens: tokens}
+	condition, err := p.parseCondition()
+	if err == nil && p.peek().kind != thresholdTokenEOF {
+		err = fmt.Errorf("unexpected %q", p.peek().text)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed parsing threshold expression %q; reason: %w", input, err)
+	}
+	return condition, p.aggregations, nil
+}
+
+const (
+	thresholdTokenEOF = iota
+	thresholdTokenNumber
+	thresholdTokenAggregation
+	thresholdTokenSymbol
+	thresholdTokenBaseline
+)
+
+type thresholdToken struct {
+	kind int
+	text string
+	// metric is the metric of an aggregation, if it's not the one of the thresholds
+	metric string
+}
+
+// thresholdSymbols are the symbols of the threshold conditions, the longer ones first like operatorTokens
+var thresholdSymbols = [...]string{ //nolint:gochecknoglobals
+	"===", "==", "!=", "<=", ">=", "<", ">", "&&", "||", "!", "(", ")", "+", "-", "*", "/",
+}
+
+func scanThresholdCondition(input string) ([]thresholdToken, error) {
+	var tokens []thresholdToken
+	for i := 0; i < len(input); {
+		c := input[i]
+		switch {
+		case c == ' ':
+			i++
+		case isThresholdDigit(c) || c == '.':
+			j := i
+			for j < len(input) && (isThresholdDigit(input[j]) || input[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, thresholdToken{kind: thresholdTokenNumber, text: input[i:j]})
+			i = j
+		case isThresholdLetter(c):
+			token, n, err := scanThresholdWord(input[i:])
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token)
+			i += n
+		default:
+			symbol := ""
+			for _, s := range thresholdSymbols {
+				if strings.HasPrefix(input[i:], s) {
+					symbol = s
+					break
+				}
+			}
+			if symbol == "" {
+				return nil, fmt.Errorf("unexpected character %q", c)
+			}
+			tokens = append(tokens, thresholdToken{kind: thresholdTokenSymbol, text: symbol})
+			i += len(symbol)
+		}
+	}
+	return tokens, nil
+}
+
+// scanThresholdWord scans a keyword or an aggregation, which can be a percentile or the one of another metric,
+// and returns it with its length.
+func scanThresholdWord(input string) (thresholdToken, int, error) {
+	n := 0
+	for n < len(input) && (isThresholdLetter(input[n]) || isThresholdDigit(input[n])) {
+		n++
+	}
+	word := input[:n]
+	switch word {
+	case "and":
+		return thresholdToken{kind: thresholdTokenSymbol, text: "&&"}, n, nil
+	case "or":
+		return thresholdToken{kind: thresholdTokenSymbol, text: "||"}, n, nil
+	case "not":
+		return thresholdToken{kind: thresholdTokenSymbol, text: "!"}, n, nil
+	case "baseline":
+		if n == len(input) || (input[n] != '.' && input[n] != '{') {
+			return thresholdToken{kind: thresholdTokenBaseline, text: word}, n, nil
+		}
+	}
+
+	var metric string
+	if n < len(input) && input[n] == '{' {
+		end := strings.IndexByte(input[n:], '}')
+		if end < 0 {
+			return thresholdToken{}, 0, fmt.Errorf("unterminated tags of the metric %s", word)
+		}
+		n += end + 1
+		metric = input[:n]
+		if n == len(input) || input[n] != '.' {
+			return thresholdToken{}, 0, fmt.Errorf("no aggregation method for the metric %s", metric)
+		}
+	}
+	if n < len(input) && input[n] == '.' {
+		if metric == "" {
+			metric = word
+		}
+		n++
+		start := n
+		for n < len(input) && (isThresholdLetter(input[n]) || isThresholdDigit(input[n])) {
+			n++
+		}
+		word = input[start:n]
+	}
+	if word == tokenPercentile && n < len(input) && input[n] == '(' {
+		end := strings.IndexByte(input[n:], ')')
+		if end < 0 {
+			return thresholdToken{}, 0, fmt.Errorf("unterminated percentile")
+		}
+		word = input[n-1 : n+end+1]
+		n += end + 1
+	}
+	return thresholdToken{kind: thresholdTokenAggregation, text: word, metric: metric}, n, nil
+}
+
+func isThresholdDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isThresholdLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '_'
+}
+
+type thresholdConditionParser struct {
+	tokens       []thresholdToken
+	pos          int
+	aggregations []*thresholdAggregation
+}
+
+func (p *thresholdConditionParser) peek() thresholdToken {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return thresholdToken{kind: thresholdTokenEOF}
+}
+
+// accept consumes the next token if it's one of the symbols, and returns it.
+func (p *thresholdConditionParser) accept(symbols ...string) (string, bool) {
+	token := p.peek()
+	if token.kind != thresholdTokenSymbol {
+		return "", false
+	}
+	for _, s := range symbols {
+		if token.text == s {
+			p.pos++
+			return s, true
+		}
+	}
+	return "", false
+}
+
+func (p *thresholdConditionParser) parseCondition() (thresholdCondition, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("||"); !ok {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = thresholdLogical{and: false, left: left, right: right}
+	}
+}
+
+func (p *thresholdConditionParser) parseAnd() (thresholdCondition, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("&&"); !ok {
+			return left, nil
+		}
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = thresholdLogical{and: true, left: left, right: right}
+	}
+}
+
+func (p *thresholdConditionParser) parseNot() (thresholdCondition, error) {
+	if _, ok := p.accept("!"); ok {
+		condition, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return thresholdNot{condition: condition}, nil
+	}
+
+	// a parenthesis can start a condition or a sum, so the condition is tried first
+	// and the comparison is parsed from the parenthesis if it isn't one
+	start, aggregations := p.pos, len(p.aggregations)
+	if _, ok := p.accept("("); ok {
+		condition, err := p.parseCondition()
+		if err == nil {
+			if _, ok := p.accept(")"); ok {
+				return condition, nil
+			}
+		}
+		p.pos, p.aggregations = start, p.aggregations[:aggregations]
+	}
+	return p.parseComparison()
+}
+
+func (p *thresholdConditionParser) parseComparison() (thresholdCondition, error) {
+	start := len(p.aggregations)
+	left, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	operator, ok := p.accept(operatorTokens[:]...)
+	if !ok {
+		return nil, fmt.Errorf("expected a comparison operator instead of %q", p.peek().text)
+	}
+	right, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+
+	// the baselines are the ones of the first aggregation of the comparison
+	var compared *thresholdAggregation
+	for _, a := range p.aggregations[start:] {
+		if !a.Baseline {
+			compared = a
+			break
+		}
+	}
+	for _, a := range p.aggregations[start:] {
+		if !a.Baseline {
+			continue
+		}
+		if compared == nil {
+			return nil, fmt.Errorf("the baseline needs an aggregation method to be compared with")
+		}
+		a.Metric, a.AggregationMethod, a.AggregationValue = compared.Metric, compared.AggregationMethod,
+			compared.AggregationValue
+	}
+	return thresholdComparison{operator: operator, left: left, right: right}, nil
+}
+
+func (p *thresholdConditionParser) parseSum() (thresholdOperand, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		operator, ok := p.accept("+", "-")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		left = thresholdArithmetic{operator: operator, left: left, right: right}
+	}
+}
+
+func (p *thresholdConditionParser) parseProduct() (thresholdOperand, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		operator, ok := p.accept("*", "/")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = thresholdArithmetic{operator: operator, left: left, right: right}
+	}
+}
+
+func (p *thresholdConditionParser) parseUnary() (thresholdOperand, error) {
+	if _, ok := p.accept("-"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return thresholdNegation{operand: operand}, nil
+	}
+	if _, ok := p.accept("("); ok {
+		operand, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := p.accept(")"); !ok {
+			return nil, fmt.Errorf("expected a closing parenthesis instead of %q", p.peek().text)
+		}
+		return operand, nil
+	}
+
+	token := p.peek()
+	switch token.kind {
+	case thresholdTokenNumber:
+		p.pos++
+		v, err := strconv.ParseFloat(token.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed number %q", token.text)
+		}
+		return thresholdNumber(v), nil
+	case thresholdTokenAggregation:
+		p.pos++
+		method, methodValue, err := parseThresholdAggregationMethod(token.text)
+		if err != nil {
+			return nil, fmt.Errorf("unknown aggregation method %q", token.text)
+		}
+		aggregation := &thresholdAggregation{
+			Metric:            token.metric,
+			AggregationMethod: method,
+			AggregationValue:  methodValue,
+		}
+		p.aggregations = append(p.aggregations, aggregation)
+		return aggregation, nil
+	case thresholdTokenBaseline:
+		p.pos++
+		aggregation := &thresholdAggregation{Baseline: true}
+		p.aggregations = append(p.aggregations, aggregation)
+		return aggregation, nil
+	case thresholdTokenEOF:
+		return nil, fmt.Errorf("unexpected end of the expression")
+	default:
+		return nil, fmt.Errorf("unexpected %q", token.text)
+	}
+}
+
+// thresholdMetrics returns the sorted names of the other metrics used by the aggregations.
+func thresholdMetrics(aggregations []*thresholdAggregation) []string {
+	var names []string
+	for _, a := range aggregations {
+		if a.Metric == "" || a.Baseline {
+			continue
+		}
+		if i := sort.SearchStrings(names, a.Metric); i == len(names) || names[i] != a.Metric {
+			names = append(names, "")
+			copy(names[i+1:], names[i:])
+			names[i] = a.Metric
+		}
+	}
+	return names
+}
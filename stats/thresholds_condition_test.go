@@ -0,0 +1,119 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package stats
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseThresholdCondition(t *testing.T) {
+	t.Parallel()
+
+	values := thresholdValues{
+		"":                                    {"p(95)": 400, "avg": 200, "rate": 150, "count": 10},
+		"http_req_duration{group:a}":          {"p(99)": 900},
+		"http_req_duration":                   {"p(99)": 500},
+		"baseline:":                           {"p(95)": 380},
+		"baseline:http_req_duration{group:a}": {"p(99)": 1000},
+	}
+	tests := []struct {
+		input   string
+		want    bool
+		metrics []string
+		wantErr bool
+	}{
+		{input: "p(95)<500 and rate>100", want: true},
+		{input: "p(95)<500 && rate>200", want: false},
+		{input: "p(95)>500 or rate>100", want: true},
+		{input: "p(95)>500 || rate>200", want: false},
+		{input: "not (avg < 300)", want: false},
+		{input: "!(avg > 300) and count == 10", want: true},
+		{input: "(avg + 100) * 2 == 600", want: true},
+		{input: "avg / -2 < -50 and p(95) - avg >= 200", want: true},
+		{input: "(avg < 100 or rate > 100) and (count != 10 or p(95) === 400)", want: true},
+		{input: "avg < 100 or rate > 100 and count != 10", want: false},
+		{
+			input:   "http_req_duration{group:a}.p(99) < 2 * http_req_duration.p(99)",
+			want:    true,
+			metrics: []string{"http_req_duration", "http_req_duration{group:a}"},
+		},
+		{
+			input:   "http_req_duration{group:a}.p(99) < p(95) * 2",
+			want:    false,
+			metrics: []string{"http_req_duration{group:a}"},
+		},
+		{input: "p(95) < baseline * 1.10", want: true},
+		{input: "baseline * 1.01 > p(95) or avg > 300", want: false},
+		{
+			input:   "http_req_duration{group:a}.p(99) <= baseline and p(95) - baseline < 50",
+			want:    true,
+			metrics: []string{"http_req_duration{group:a}"},
+		},
+		{input: "baseline < 100", wantErr: true},
+		{input: "avg < 100 and", wantErr: true},
+		{input: "avg < 100 rate", wantErr: true},
+		{input: "avg and rate", wantErr: true},
+		{input: "(avg < 100", wantErr: true},
+		{input: "foo < 100 or avg < 100", wantErr: true},
+		{input: "avg < 100ms", wantErr: true},
+		{input: "http_req_duration{group:a < 100", wantErr: true},
+		{input: "http_req_duration{group:a} < 100", wantErr: true},
+		{input: "avg < 100 % 2", wantErr: true},
+	}
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.input, func(t *testing.T) {
+			t.Parallel()
+
+			condition, aggregations, err := parseThresholdCondition(testCase.input)
+			if testCase.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, testCase.metrics, thresholdMetrics(aggregations))
+
+			got, err := condition.eval(values)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.want, got)
+		})
+	}
+
+	t.Run("missing aggregation method", func(t *testing.T) {
+		t.Parallel()
+
+		condition, _, err := parseThresholdCondition("avg > 100 and value > 1")
+		require.NoError(t, err)
+		_, err = condition.eval(values)
+		assert.Error(t, err)
+
+		// the right hand side isn't evaluated when the left one is enough
+		condition, _, err = parseThresholdCondition("avg > 300 and value > 1")
+		require.NoError(t, err)
+		got, err := condition.eval(values)
+		require.NoError(t, err)
+		assert.False(t, got)
+	})
+}
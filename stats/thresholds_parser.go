@@ -60,7 +60,7 @@ type thresholdExpression struct {
 // aggregation_method  -> trend | rate | gauge | counter
 // counter             -> "count" | "rate"
 // gauge               -> "value"
-// rate                -> "rate"
+// rate                -> "rate" | "burn_rate"
 // trend               -> "avg" | "min" | "max" | "med" | percentile
 // percentile          -> "p(" float ")"
 // operator            -> ">" | ">=" | "<=" | "<" | "==" | "===" | "!="
@@ -161,6 +161,7 @@ const (
 	tokenMin        = "min"
 	tokenMed        = "med"
 	tokenMax        = "max"
+	tokenBurnRate   = "burn_rate"
 	tokenPercentile = "p"
 )
 
@@ -170,7 +171,7 @@ const (
 // It is meant to be used during the parsing of threshold expressions.
 // Although declared as a `var`, being an array, it is effectively
 // immutable and can be considered constant.
-var aggregationMethodTokens = [8]string{ // nolint:gochecknoglobals
+var aggregationMethodTokens = [9]string{ // nolint:gochecknoglobals
 	tokenValue,
 	tokenCount,
 	tokenRate,
@@ -178,6 +179,7 @@ var aggregationMethodTokens = [8]string{ // nolint:gochecknoglobals
 	tokenMin,
 	tokenMed,
 	tokenMax,
+	tokenBurnRate,
 	tokenPercentile,
 }
 
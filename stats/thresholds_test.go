@@ -37,13 +37,15 @@ func TestNewThreshold(t *testing.T) {
 	src := `rate<0.01`
 	abortOnFail := false
 	gracePeriod := types.NullDurationFrom(2 * time.Second)
+	window := types.NullDurationFrom(time.Minute)
 
-	gotThreshold := newThreshold(src, abortOnFail, gracePeriod)
+	gotThreshold := newThreshold(src, abortOnFail, gracePeriod, window)
 
 	assert.Equal(t, src, gotThreshold.Source)
 	assert.False(t, gotThreshold.LastFailed)
 	assert.Equal(t, abortOnFail, gotThreshold.AbortOnFail)
 	assert.Equal(t, gracePeriod, gotThreshold.AbortGracePeriod)
+	assert.Equal(t, window, gotThreshold.Window)
 	assert.Nil(t, gotThreshold.parsed)
 }
 
@@ -206,7 +208,7 @@ func TestThresholdRun(t *testing.T) {
 		sinks := map[string]float64{"rate": 0.0001}
 		parsed, parseErr := parseThresholdExpression("rate<0.01")
 		require.NoError(t, parseErr)
-		threshold := newThreshold(`rate<0.01`, false, types.NullDuration{})
+		threshold := newThreshold(`rate<0.01`, false, types.NullDuration{}, types.NullDuration{})
 		threshold.parsed = parsed
 
 		t.Run("no taint", func(t *testing.T) {
@@ -232,7 +234,7 @@ func TestThresholdRun(t *testing.T) {
 		sinks := map[string]float64{"rate": 1}
 		parsed, parseErr := parseThresholdExpression("rate<0.01")
 		require.NoError(t, parseErr)
-		threshold := newThreshold(`rate<0.01`, false, types.NullDuration{})
+		threshold := newThreshold(`rate<0.01`, false, types.NullDuration{}, types.NullDuration{})
 		threshold.parsed = parsed
 
 		t.Run("no taint", func(t *testing.T) {
@@ -261,7 +263,7 @@ func TestThresholdsParse(t *testing.T) {
 		// correct thresholds
 		ts := Thresholds{
 			Thresholds: []*Threshold{
-				newThreshold("rate<1", false, types.NullDuration{}),
+				newThreshold("rate<1", false, types.NullDuration{}, types.NullDuration{}),
 			},
 		}
 
@@ -287,7 +289,7 @@ func TestThresholdsParse(t *testing.T) {
 		// correct thresholds
 		ts := Thresholds{
 			Thresholds: []*Threshold{
-				newThreshold("foo&1", false, types.NullDuration{}),
+				newThreshold("foo&1", false, types.NullDuration{}, types.NullDuration{}),
 			},
 		}
 
@@ -313,8 +315,8 @@ func TestThresholdsParse(t *testing.T) {
 		// correct thresholds
 		ts := Thresholds{
 			Thresholds: []*Threshold{
-				newThreshold("rate<1", false, types.NullDuration{}),
-				newThreshold("foo&1", false, types.NullDuration{}),
+				newThreshold("rate<1", false, types.NullDuration{}, types.NullDuration{}),
+				newThreshold("foo&1", false, types.NullDuration{}, types.NullDuration{}),
 			},
 		}
 
@@ -370,8 +372,8 @@ func TestNewThresholdsWithConfig(t *testing.T) {
 		t.Parallel()
 
 		configs := []thresholdConfig{
-			{`rate<0.01`, false, types.NullDuration{}},
-			{`p(95)<200`, true, types.NullDuration{}},
+			{`rate<0.01`, false, types.NullDuration{}, nil, nil, false, "", 0, nil},
+			{`p(95)<200`, true, types.NullDuration{}, nil, nil, false, ThresholdSeverityCritical, 42, nil},
 		}
 		ts := newThresholdsWithConfig(configs)
 		assert.Len(t, ts.Thresholds, 2)
@@ -379,6 +381,8 @@ func TestNewThresholdsWithConfig(t *testing.T) {
 			assert.Equal(t, configs[i].Threshold, th.Source)
 			assert.False(t, th.LastFailed)
 			assert.Equal(t, configs[i].AbortOnFail, th.AbortOnFail)
+			assert.Equal(t, configs[i].Severity, th.Severity)
+			assert.Equal(t, configs[i].ExitCode, th.ExitCode)
 		}
 	})
 }
@@ -490,6 +494,355 @@ func TestThresholds_Run(t *testing.T) {
 	}
 }
 
+func TestThresholdsRunWindow(t *testing.T) {
+	t.Parallel()
+
+	var ts Thresholds
+	require.NoError(t, json.Unmarshal([]byte(`["rate<0.5", {"threshold":"rate<0.5","window":"1m"}]`), &ts))
+	require.NoError(t, ts.Parse())
+
+	// the errors of the last minute are diluted in the whole test run
+	rate := New("rate", Rate)
+	sink := &RateSink{}
+	now := time.Now()
+	for i := 0; i < 600; i++ {
+		s := Sample{Metric: rate, Time: now.Add(time.Duration(i-600) * time.Second), Value: 0}
+		if i >= 540 {
+			s.Value = 1
+		}
+		sink.Add(s)
+		ts.AddSample(s, 0)
+	}
+
+	ok, err := ts.Run(sink, 10*time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.False(t, ts.Thresholds[0].LastFailed)
+	assert.True(t, ts.Thresholds[1].LastFailed)
+	assert.Len(t, ts.window.seconds, 61)
+
+	t.Run("no samples", func(t *testing.T) {
+		t.Parallel()
+
+		var ts Thresholds
+		require.NoError(t, json.Unmarshal([]byte(`[{"threshold":"rate<0.5","window":"1m"}]`), &ts))
+		require.NoError(t, ts.Parse())
+		ok, err := ts.Run(&RateSink{Trues: 1, Total: 1}, time.Minute)
+		require.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("invalid window", func(t *testing.T) {
+		t.Parallel()
+
+		var ts Thresholds
+		require.NoError(t, json.Unmarshal([]byte(`[{"threshold":"rate<0.5","window":"-1m"}]`), &ts))
+		assert.Error(t, ts.Parse())
+	})
+}
+
+func TestThresholdsWindowTrendRelativeError(t *testing.T) {
+	t.Parallel()
+
+	trend := New("trend", Trend)
+	now := time.Now()
+	percentiles := make(map[float64]float64)
+	for _, relativeError := range []float64{0, 0.1} {
+		var ts Thresholds
+		require.NoError(t, json.Unmarshal([]byte(`[{"threshold":"p(90)<1000","window":"1m"}]`), &ts))
+		require.NoError(t, ts.Parse())
+		ts.SetTrendRelativeError(relativeError)
+
+		// the percentiles of the window are as precise as the ones of the sink of the metric
+		sink := NewTrendSink(relativeError)
+		for i := 1; i <= 100; i++ {
+			s := Sample{Metric: trend, Time: now.Add(time.Duration(i-100) * 100 * time.Millisecond), Value: float64(i)}
+			sink.Add(s)
+			ts.AddSample(s, 0)
+		}
+		sink.Calc()
+		window, _, ok := ts.window.Aggregate(now, time.Minute)
+		require.True(t, ok)
+		assert.Equal(t, sink.P(0.9), window.(*TrendSink).P(0.9))
+		percentiles[relativeError] = window.(*TrendSink).P(0.9)
+	}
+	assert.NotEqual(t, percentiles[0], percentiles[0.1])
+}
+
+func TestThresholdsRunBurnRate(t *testing.T) {
+	t.Parallel()
+
+	// the errors are the trues of the samples of the last two hours, one per second, from the first error
+	run := func(t *testing.T, errorsFrom, errorsEvery int) Thresholds {
+		var ts Thresholds
+		require.NoError(t, json.Unmarshal([]byte(`[
+			{"threshold":"burn_rate<5","slo":{"target":0.99}},
+			{"threshold":"burn_rate<5","slo":{"target":0.99,"windows":["5m","1h"]}}
+		]`), &ts))
+		require.NoError(t, ts.Parse())
+
+		rate := New("rate", Rate)
+		sink := &RateSink{}
+		now := time.Now()
+		for i := 0; i < 7200; i++ {
+			s := Sample{Metric: rate, Time: now.Add(time.Duration(i-7200) * time.Second), Value: 0}
+			if i >= errorsFrom && (i-errorsFrom)%errorsEvery == 0 {
+				s.Value = 1
+			}
+			sink.Add(s)
+			ts.AddSample(s, 0)
+		}
+		_, err := ts.Run(sink, 2*time.Hour)
+		require.NoError(t, err)
+		return ts
+	}
+
+	t.Run("spike", func(t *testing.T) {
+		t.Parallel()
+
+		// the errors of the last minute only burn the budget of the short window
+		ts := run(t, 7140, 1)
+		assert.False(t, ts.Thresholds[0].LastFailed)
+		assert.InDelta(t, 0.83, ts.Thresholds[0].Values()["burn_rate"], 0.01)
+		assert.False(t, ts.Thresholds[1].LastFailed)
+		values := ts.Thresholds[1].Values()
+		assert.InDelta(t, 20, values["burn_rate@5m0s"], 0.5)
+		assert.InDelta(t, 1.67, values["burn_rate@1h0m0s"], 0.05)
+	})
+
+	t.Run("sustained", func(t *testing.T) {
+		t.Parallel()
+
+		ts := run(t, 3600, 8)
+		assert.True(t, ts.Thresholds[0].LastFailed)
+		assert.InDelta(t, 6.25, ts.Thresholds[0].Values()["burn_rate"], 0.01)
+		assert.True(t, ts.Thresholds[1].LastFailed)
+		values := ts.Thresholds[1].Values()
+		assert.InDelta(t, 12.5, values["burn_rate@5m0s"], 0.5)
+		assert.InDelta(t, 12.5, values["burn_rate@1h0m0s"], 0.05)
+	})
+
+	t.Run("successes", func(t *testing.T) {
+		t.Parallel()
+
+		slo := &ThresholdSLO{Target: 0.9, Successes: true}
+		assert.InDelta(t, 2, slo.withBurnRate(map[string]float64{"rate": 0.8})["burn_rate"], 1e-9)
+	})
+
+	t.Run("not a rate", func(t *testing.T) {
+		t.Parallel()
+
+		var ts Thresholds
+		require.NoError(t, json.Unmarshal([]byte(`[{"threshold":"burn_rate<5","slo":{"target":0.99}}]`), &ts))
+		require.NoError(t, ts.Parse())
+		_, err := ts.Run(&CounterSink{Value: 1}, time.Second)
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		t.Parallel()
+
+		for _, data := range []string{
+			`["burn_rate<5"]`,
+			`[{"threshold":"rate<0.01","slo":{"target":0.99}}]`,
+			`[{"threshold":"burn_rate<5","slo":{"target":1}}]`,
+			`[{"threshold":"burn_rate<5","slo":{"target":0.99,"windows":["-5m"]}}]`,
+			`[{"threshold":"burn_rate<5","slo":{"target":0.99},"window":"5m"}]`,
+		} {
+			var ts Thresholds
+			require.NoError(t, json.Unmarshal([]byte(data), &ts))
+			assert.Error(t, ts.Parse(), data)
+		}
+	})
+}
+
+func TestThresholdsRunStartAfter(t *testing.T) {
+	t.Parallel()
+
+	var ts Thresholds
+	require.NoError(t, json.Unmarshal([]byte(`[
+		"rate<0.5",
+		{"threshold":"rate<0.5","startAfter":"1m"},
+		{"threshold":"rate<0.5","ignoreRampUp":true},
+		{"threshold":"rate<0.5","startAfter":"1m","window":"10m"}
+	]`), &ts))
+	require.NoError(t, ts.Parse())
+	ts.SetRampUpDuration(10 * time.Second)
+
+	// the errors of the first minute fail the thresholds which don't ignore them
+	rate := New("rate", Rate)
+	sink := &RateSink{}
+	now := time.Now()
+	for i := 0; i < 100; i++ {
+		elapsed := time.Duration(i) * time.Second
+		s := Sample{Metric: rate, Time: now.Add(elapsed - 100*time.Second), Value: 0}
+		if i < 60 {
+			s.Value = 1
+		}
+		sink.Add(s)
+		ts.AddSample(s, elapsed)
+	}
+
+	ok, err := ts.Run(sink, 100*time.Second)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.True(t, ts.Thresholds[0].LastFailed)
+	assert.False(t, ts.Thresholds[1].LastFailed)
+	assert.True(t, ts.Thresholds[2].LastFailed)
+	assert.False(t, ts.Thresholds[3].LastFailed)
+	assert.Len(t, ts.started, 2)
+
+	t.Run("before the start", func(t *testing.T) {
+		t.Parallel()
+
+		ts := NewThresholds([]string{"rate<0.5"})
+		ts.Thresholds[0].StartAfter = types.NullDurationFrom(time.Minute)
+		require.NoError(t, ts.Parse())
+		ts.AddSample(Sample{Metric: rate, Time: now, Value: 1}, 30*time.Second)
+		ok, err := ts.Run(&RateSink{Trues: 1, Total: 1}, 30*time.Second)
+		require.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("negative", func(t *testing.T) {
+		t.Parallel()
+
+		var ts Thresholds
+		require.NoError(t, json.Unmarshal([]byte(`[{"threshold":"rate<0.5","startAfter":"-1m"}]`), &ts))
+		assert.Error(t, ts.Parse())
+	})
+}
+
+func TestThresholdsRunBaseline(t *testing.T) {
+	t.Parallel()
+
+	ts := NewThresholds([]string{
+		"p(95) < baseline * 1.10",
+		"max < baseline",
+		"p(99) < baseline",
+		"my_trend{group:a}.avg < baseline",
+	})
+	require.NoError(t, ts.Parse())
+	ts.SetBaseline("my_trend", map[string]map[string]float64{
+		"my_trend":          {"p(95)": 280, "max": 250},
+		"my_trend{group:a}": {"avg": 100},
+		"other":             {"avg": 1},
+	})
+	assert.Equal(t, thresholdValues{"": {"p(95)": 280, "max": 250}, "my_trend{group:a}": {"avg": 100}}, ts.baseline)
+
+	sink := &TrendSink{}
+	for _, v := range []float64{100, 200, 300} {
+		sink.Add(Sample{Value: v})
+	}
+	groupA := New("my_trend", Trend)
+	groupA.Sink.Add(Sample{Value: 150})
+
+	ok, err := ts.RunWithMetrics(sink, 0, map[string]*Metric{"my_trend{group:a}": groupA})
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.False(t, ts.Thresholds[0].LastFailed)
+	assert.True(t, ts.Thresholds[1].LastFailed)
+	// the thresholds pass without a baseline value
+	assert.False(t, ts.Thresholds[2].LastFailed)
+	assert.True(t, ts.Thresholds[3].LastFailed)
+}
+
+func TestThresholdsRunConditions(t *testing.T) {
+	t.Parallel()
+
+	ts := NewThresholds([]string{
+		"p(95)<500 and max>200",
+		"my_trend{group:a}.p(99) < 2 * my_trend{group:b}.p(99)",
+		"avg < my_trend{group:c}.avg",
+	})
+	require.NoError(t, ts.Parse())
+	assert.Nil(t, ts.Thresholds[0].parsed)
+	assert.Equal(t, []string{"my_trend{group:a}", "my_trend{group:b}", "my_trend{group:c}"}, ts.References())
+
+	newTrend := func(values ...float64) *Metric {
+		m := New("my_trend", Trend)
+		for _, v := range values {
+			m.Sink.Add(Sample{Value: v})
+		}
+		return m
+	}
+	metrics := map[string]*Metric{
+		"my_trend{group:a}": newTrend(300, 500),
+		"my_trend{group:b}": newTrend(200),
+	}
+
+	ok, err := ts.RunWithMetrics(newTrend(100, 200, 300).Sink, 0, metrics)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.False(t, ts.Thresholds[0].LastFailed)
+	assert.True(t, ts.Thresholds[1].LastFailed)
+	// the threshold passes since its submetric has no samples
+	assert.False(t, ts.Thresholds[2].LastFailed)
+
+	metrics["my_trend{group:b}"] = newTrend(200, 300)
+	metrics["my_trend{group:c}"] = newTrend(100)
+	ok, err = ts.RunWithMetrics(newTrend(100, 200, 300).Sink, 0, metrics)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.False(t, ts.Thresholds[1].LastFailed)
+	assert.True(t, ts.Thresholds[2].LastFailed)
+	assert.Equal(t, map[string]float64{"avg": 200, "my_trend{group:c}.avg": 100}, ts.Thresholds[2].Values())
+}
+
+func TestThresholdsRunSeverity(t *testing.T) {
+	t.Parallel()
+
+	t.Run("warn", func(t *testing.T) {
+		t.Parallel()
+
+		ts := NewThresholds([]string{`rate<0.01`, `p(95)<200`})
+		ts.Thresholds[1].Severity = ThresholdSeverityWarn
+		require.NoError(t, ts.Parse())
+		ts.sinked = map[string]float64{"rate": 0.0001, "p(95)": 500}
+
+		// the failed thresholds with the warn severity don't fail the run, but are reported
+		succeeded, err := ts.runAll(time.Second)
+		require.NoError(t, err)
+		assert.True(t, succeeded)
+		assert.False(t, ts.Thresholds[0].LastFailed)
+		assert.True(t, ts.Thresholds[1].LastFailed)
+	})
+
+	t.Run("most severe", func(t *testing.T) {
+		t.Parallel()
+
+		ts := NewThresholds([]string{`p(95)<200`, `p(95)<300`, `p(95)<400`, `p(95)<600`})
+		ts.Thresholds[0].Severity = ThresholdSeverityWarn
+		ts.Thresholds[1].ExitCode = 42
+		ts.Thresholds[2].Severity = ThresholdSeverityCritical
+		ts.Thresholds[2].ExitCode = 43
+		ts.Thresholds[3].Severity = ThresholdSeverityCritical
+		require.NoError(t, ts.Parse())
+		ts.sinked = map[string]float64{"p(95)": 500}
+
+		succeeded, err := ts.runAll(time.Second)
+		require.NoError(t, err)
+		assert.False(t, succeeded)
+		assert.Equal(t, ts.Thresholds[2], MostSevereFailedThreshold(ts.Thresholds))
+		assert.Equal(t, ts.Thresholds[1], MostSevereFailedThreshold(ts.Thresholds[:2]))
+		assert.Nil(t, MostSevereFailedThreshold(ts.Thresholds[:1]))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		t.Parallel()
+
+		for _, data := range []string{
+			`[{"threshold":"rate<0.5","severity":"fatal"}]`,
+			`[{"threshold":"rate<0.5","severity":"warn","exitCode":42}]`,
+			`[{"threshold":"rate<0.5","severity":"warn","abortOnFail":true}]`,
+		} {
+			var ts Thresholds
+			require.NoError(t, json.Unmarshal([]byte(data), &ts))
+			assert.Error(t, ts.Parse(), data)
+		}
+	})
+}
+
 func TestThresholdsJSON(t *testing.T) {
 	t.Parallel()
 
@@ -556,6 +909,37 @@ func TestThresholdsJSON(t *testing.T) {
 			types.NullDuration{},
 			`["rate<0.01"]`,
 		},
+		{
+			`[{"threshold":"rate<0.01","window":"1m"}]`,
+			[]string{"rate<0.01"},
+			false,
+			types.NullDuration{},
+			`[{"threshold":"rate<0.01","abortOnFail":false,"delayAbortEval":null,"window":"1m0s"}]`,
+		},
+		{
+			`[{"threshold":"rate<0.01","startAfter":"30s","ignoreRampUp":true}]`,
+			[]string{"rate<0.01"},
+			false,
+			types.NullDuration{},
+			`[{"threshold":"rate<0.01","abortOnFail":false,"delayAbortEval":null,` +
+				`"startAfter":"30s","ignoreRampUp":true}]`,
+		},
+		{
+			`[{"threshold":"rate<0.01","severity":"critical","exitCode":42}]`,
+			[]string{"rate<0.01"},
+			false,
+			types.NullDuration{},
+			`[{"threshold":"rate<0.01","abortOnFail":false,"delayAbortEval":null,` +
+				`"severity":"critical","exitCode":42}]`,
+		},
+		{
+			`[{"threshold":"burn_rate<14.4","slo":{"target":0.999,"windows":["5m","1h"]}}]`,
+			[]string{"burn_rate<14.4"},
+			false,
+			types.NullDuration{},
+			`[{"threshold":"burn_rate<14.4","abortOnFail":false,"delayAbortEval":null,` +
+				`"slo":{"target":0.999,"windows":["5m0s","1h0m0s"]}}]`,
+		},
 		{
 			`[{"threshold":"rate<0.01"}, "p(95)<200"]`,
 			[]string{"rate<0.01", "p(95)<200"},
@@ -0,0 +1,143 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package stats
+
+import (
+	"sort"
+	"time"
+)
+
+// WindowSink keeps a sink per second for the samples of the last retention, so they can be aggregated over
+// a sliding window, e.g. the error rate of the last minute, instead of the whole test run.
+type WindowSink struct {
+	metric    *Metric
+	retention time.Duration
+	// trendRelativeError is the relative error of the percentiles of the Trends, like the one of their TrendSinks
+	trendRelativeError float64
+
+	first   time.Time
+	latest  int64
+	seconds map[int64]Sink
+}
+
+// NewWindowSink returns a WindowSink for the samples of the metric, whose Trends have the relative error of
+// NewTrendSink.
+func NewWindowSink(m *Metric, retention time.Duration, trendRelativeError float64) *WindowSink {
+	return &WindowSink{
+		metric: m, retention: retention, trendRelativeError: trendRelativeError, seconds: make(map[int64]Sink),
+	}
+}
+
+func newWindowSecondSink(m *Metric, trendRelativeError float64) Sink {
+	switch m.Type {
+	case Counter:
+		return &CounterSink{}
+	case Gauge:
+		return &GaugeSink{}
+	case Trend:
+		return NewTrendSink(trendRelativeError)
+	case Rate:
+		return &RateSink{}
+	case Histogram:
+		return NewHistogramSink(m.Buckets)
+	default:
+		return &DummySink{}
+	}
+}
+
+// Add adds the sample to the sink of its second. The seconds older than the retention before the latest
+// sample are dropped.
+func (w *WindowSink) Add(s Sample) {
+	second := s.Time.Unix()
+	if second > w.latest {
+		w.latest = second
+	}
+	oldest := w.latest - int64(w.retention/time.Second)
+	if second < oldest {
+		return
+	}
+	if w.first.IsZero() || s.Time.Before(w.first) {
+		w.first = s.Time
+	}
+
+	sink, ok := w.seconds[second]
+	if !ok {
+		for sec := range w.seconds {
+			if sec < oldest {
+				delete(w.seconds, sec)
+			}
+		}
+		sink = newWindowSecondSink(w.metric, w.trendRelativeError)
+		w.seconds[second] = sink
+	}
+	sink.Add(s)
+}
+
+// Aggregate returns a sink with the samples of the window before now, which is capped to the retention, and
+// the duration of the window, which starts at the first sample when it's more recent, so the rates of the
+// Counters are per second of it. False is returned if there are no samples in the window.
+func (w *WindowSink) Aggregate(now time.Time, window time.Duration) (Sink, time.Duration, bool) {
+	if window > w.retention {
+		window = w.retention
+	}
+	since := now.Add(-window)
+	if w.first.After(since) {
+		since = w.first
+	}
+
+	seconds := make([]int64, 0, len(w.seconds))
+	for second := range w.seconds {
+		if second >= since.Unix() {
+			seconds = append(seconds, second)
+		}
+	}
+	if len(seconds) == 0 {
+		return nil, 0, false
+	}
+	sort.Slice(seconds, func(i, j int) bool { return seconds[i] < seconds[j] })
+
+	result := newWindowSecondSink(w.metric, w.trendRelativeError)
+	for _, second := range seconds {
+		switch sink := w.seconds[second].(type) {
+		case *CounterSink:
+			result.(*CounterSink).Value += sink.Value
+		case *GaugeSink:
+			// the last value is added last, so it's the one of the result
+			result.Add(Sample{Value: sink.Min})
+			result.Add(Sample{Value: sink.Max})
+			result.Add(Sample{Value: sink.Value})
+		case *TrendSink:
+			result.(*TrendSink).Merge(sink)
+		case *RateSink:
+			result.(*RateSink).Trues += sink.Trues
+			result.(*RateSink).Total += sink.Total
+		case *HistogramSink:
+			result.(*HistogramSink).Merge(sink)
+		}
+	}
+	result.Calc()
+
+	elapsed := now.Sub(since)
+	if elapsed < time.Second {
+		elapsed = time.Second
+	}
+	return result, elapsed, true
+}
@@ -0,0 +1,331 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"go.k6.io/k6/lib/consts"
+	"go.k6.io/k6/stats"
+)
+
+// the sizes of the charts, in the units of their SVG view boxes
+const (
+	chartWidth  = 800
+	chartHeight = 240
+	chartLeft   = 60
+	chartRight  = 10
+	chartTop    = 10
+	chartBottom = 30
+	chartYTicks = 4
+	chartXTicks = 6
+)
+
+// chartColors are the colors of the lines of the charts.
+var chartColors = []string{"#7d64ff", "#00a3a1", "#f08c00", "#e5484d", "#3b82f6"} //nolint:gochecknoglobals
+
+// chartLine is a line of a chart.
+type chartLine struct {
+	Name   string
+	Values Series
+}
+
+// WriteHTML writes the report as a standalone HTML page, with its styles and SVG charts inline.
+func WriteHTML(w io.Writer, r *Report) error {
+	tmpl, err := template.New("report").Funcs(template.FuncMap{
+		"value":    formatValue,
+		"percent":  formatPercent,
+		"duration": func(d time.Duration) string { return d.Round(time.Second).String() },
+		"checkRate": func(c Check) float64 {
+			if c.Passes+c.Fails == 0 {
+				return 0
+			}
+			return float64(c.Passes) / float64(c.Passes+c.Fails)
+		},
+	}).Parse(reportTemplate)
+	if err != nil {
+		return err
+	}
+
+	var charts []template.HTML
+	if r.RequestRate != nil || r.FailureRate != nil {
+		var lines []chartLine
+		if r.RequestRate != nil {
+			lines = append(lines, chartLine{Name: "requests", Values: r.RequestRate})
+		}
+		if r.FailureRate != nil {
+			lines = append(lines, chartLine{Name: "failed requests", Values: r.FailureRate})
+		}
+		charts = append(charts, lineChart("Requests per second", "/s", r.Interval, lines))
+	}
+	if r.Latency != nil {
+		lines := make([]chartLine, len(r.Latency))
+		for i, s := range r.Latency {
+			lines[i] = chartLine{Name: s.Name, Values: s.Values}
+		}
+		charts = append(charts, lineChart("Request duration percentiles", "ms", r.Interval, lines))
+	}
+	if r.VUs != nil {
+		charts = append(charts, lineChart("Virtual users", "", r.Interval, []chartLine{{Name: "vus", Values: r.VUs}}))
+	}
+
+	failedThresholds := 0
+	for _, t := range r.Thresholds {
+		if !t.Passed {
+			failedThresholds++
+		}
+	}
+	return tmpl.Execute(w, struct {
+		*Report
+		Version          string
+		Duration         time.Duration
+		Charts           []template.HTML
+		FailedThresholds int
+	}{r, consts.Version, r.End.Sub(r.Start), charts, failedThresholds})
+}
+
+// formatValue formats a value of a metric with its unit, like the end-of-test summary.
+func formatValue(m Metric, v Value) string {
+	switch {
+	case m.Type == stats.Rate && v.Stat == "rate":
+		return formatPercent(v.Value)
+	case v.Stat == "rate":
+		return fmt.Sprintf("%.2f/s", v.Value)
+	case m.Contains == stats.Time && m.Type != stats.Rate:
+		return stats.ToD(v.Value).Round(time.Microsecond).String()
+	case m.Contains == stats.Data:
+		return formatBytes(v.Value)
+	default:
+		return formatNumber(v.Value)
+	}
+}
+
+func formatPercent(v float64) string {
+	return fmt.Sprintf("%.2f%%", v*100)
+}
+
+func formatBytes(v float64) string {
+	units := []string{"B", "kB", "MB", "GB", "TB"}
+	i := 0
+	for ; math.Abs(v) >= 1000 && i < len(units)-1; i++ {
+		v /= 1000
+	}
+	return formatNumber(v) + " " + units[i]
+}
+
+func formatNumber(v float64) string {
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.2f", v), "0"), ".")
+}
+
+// lineChart returns the SVG chart of the lines over time, the values of which are NaN where they have gaps.
+func lineChart(title, unit string, interval time.Duration, lines []chartLine) template.HTML {
+	points, maxValue := 0, 0.0
+	for _, line := range lines {
+		if len(line.Values) > points {
+			points = len(line.Values)
+		}
+		for _, v := range line.Values {
+			if !math.IsNaN(v) && v > maxValue {
+				maxValue = v
+			}
+		}
+	}
+	maxValue = niceCeil(maxValue)
+	plotWidth := float64(chartWidth - chartLeft - chartRight)
+	plotHeight := float64(chartHeight - chartTop - chartBottom)
+	x := func(i int) float64 {
+		if points < 2 {
+			return chartLeft + plotWidth/2
+		}
+		return chartLeft + plotWidth*float64(i)/float64(points-1)
+	}
+	y := func(v float64) float64 { return chartTop + plotHeight*(1-v/maxValue) }
+
+	var svg strings.Builder
+	fmt.Fprintf(&svg, `<figure><figcaption>%s</figcaption>`, template.HTMLEscapeString(title))
+	fmt.Fprintf(&svg, `<svg viewBox="0 0 %d %d" role="img" aria-label="%s">`,
+		chartWidth, chartHeight, template.HTMLEscapeString(title))
+	for i := 0; i <= chartYTicks; i++ {
+		v := maxValue * float64(i) / chartYTicks
+		fmt.Fprintf(&svg, `<line class="grid" x1="%d" x2="%d" y1="%.1f" y2="%.1f"/>`,
+			chartLeft, chartWidth-chartRight, y(v), y(v))
+		fmt.Fprintf(&svg, `<text class="axis" x="%d" y="%.1f" text-anchor="end">%s%s</text>`,
+			chartLeft-6, y(v)+4, formatNumber(v), template.HTMLEscapeString(unit))
+	}
+	for i := 0; i <= chartXTicks && points > 1; i++ {
+		point := (points - 1) * i / chartXTicks
+		fmt.Fprintf(&svg, `<text class="axis" x="%.1f" y="%d" text-anchor="middle">%s</text>`,
+			x(point), chartHeight-8, time.Duration(point)*interval)
+	}
+
+	for i, line := range lines {
+		color := chartColors[i%len(chartColors)]
+		// the values after the gaps start new segments, the ones which are alone are drawn as dots
+		var path strings.Builder
+		segment := 0
+		for j, v := range line.Values {
+			if math.IsNaN(v) {
+				segment = 0
+				continue
+			}
+			command := "L"
+			if segment == 0 {
+				command = "M"
+			}
+			fmt.Fprintf(&path, "%s%.1f %.1f ", command, x(j), y(v))
+			segment++
+			if next := j + 1; segment == 1 && (next == len(line.Values) || math.IsNaN(line.Values[next])) {
+				fmt.Fprintf(&path, "L%.1f %.1f ", x(j), y(v))
+			}
+		}
+		fmt.Fprintf(&svg, `<path d="%s" stroke="%s"><title>%s</title></path>`,
+			strings.TrimSpace(path.String()), color, template.HTMLEscapeString(line.Name))
+	}
+	svg.WriteString(`</svg><div class="legend">`)
+	for i, line := range lines {
+		fmt.Fprintf(&svg, `<span><i style="background:%s"></i>%s</span>`,
+			chartColors[i%len(chartColors)], template.HTMLEscapeString(line.Name))
+	}
+	svg.WriteString(`</div></figure>`)
+
+	return template.HTML(svg.String()) //nolint:gosec
+}
+
+// niceCeil returns the smallest of 1, 2, 2.5 and 5 times a power of 10 which isn't lower than v,
+// so that the ticks of the axes of the charts are round.
+func niceCeil(v float64) float64 {
+	if v <= 0 {
+		return 1
+	}
+	magnitude := math.Pow(10, math.Floor(math.Log10(v)))
+	for _, m := range []float64{1, 2, 2.5, 5, 10} {
+		if v <= m*magnitude {
+			return m * magnitude
+		}
+	}
+	return 10 * magnitude
+}
+
+const reportTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{ .Title }}</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif;
+  color: #1f2328; background: #f6f8fa; margin: 0; }
+main { max-width: 1000px; margin: 0 auto; padding: 24px; }
+h1 { margin: 0 0 4px; }
+h2 { margin: 32px 0 12px; }
+.subtitle { color: #656d76; margin: 0 0 16px; }
+.cards { display: flex; flex-wrap: wrap; gap: 12px; }
+.card { background: #fff; border: 1px solid #d0d7de; border-radius: 6px; padding: 12px 16px; min-width: 140px; }
+.card b { display: block; font-size: 1.4em; }
+figure { background: #fff; border: 1px solid #d0d7de; border-radius: 6px; margin: 0 0 16px; padding: 12px; }
+figcaption { font-weight: 600; margin-bottom: 8px; }
+svg { width: 100%; height: auto; }
+svg path { fill: none; stroke-width: 2; stroke-linecap: round; stroke-linejoin: round; }
+svg .grid { stroke: #d0d7de; stroke-width: 1; }
+svg .axis { fill: #656d76; font-size: 11px; }
+.legend span { margin-right: 16px; font-size: 0.9em; }
+.legend i { display: inline-block; width: 10px; height: 10px; margin-right: 4px; border-radius: 2px; }
+table { width: 100%; border-collapse: collapse; background: #fff; border: 1px solid #d0d7de; }
+th, td { text-align: left; padding: 6px 10px; border-bottom: 1px solid #d0d7de; font-size: 0.9em; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+.bar { background: #e5484d; height: 8px; border-radius: 4px; min-width: 80px; }
+.bar div { background: #2da44e; height: 8px; border-radius: 4px; }
+.passed { color: #1a7f37; font-weight: 600; }
+.failed { color: #cf222e; font-weight: 600; }
+footer { color: #656d76; font-size: 0.8em; margin-top: 32px; }
+</style>
+</head>
+<body>
+<main>
+<h1>{{ .Title }}</h1>
+{{ if not .Start.IsZero -}}
+<p class="subtitle">{{ .Start.UTC.Format "2006-01-02 15:04:05 MST" }}, {{ duration .Duration }}</p>
+{{- end }}
+<div class="cards">
+{{- range .Metrics }}{{ $m := . }}
+{{- if or (eq .Name "http_reqs") (eq .Name "iterations") }}
+<div class="card">{{ .Name }}<b>{{ range .Values }}{{ if eq .Stat "count" }}{{ value $m . }}{{ end }}{{ end }}</b></div>
+{{- else if or (eq .Name "http_req_failed") (eq .Name "checks") }}
+<div class="card">{{ .Name }}<b>{{ range .Values }}{{ if eq .Stat "rate" }}{{ value $m . }}{{ end }}{{ end }}</b></div>
+{{- else if eq .Name "http_req_duration" }}
+<div class="card">{{ .Name }} p(95)<b>
+{{- range .Values }}{{ if eq .Stat "p(95)" }}{{ value $m . }}{{ end }}{{ end }}</b></div>
+{{- end }}
+{{- end }}
+{{- if .Thresholds }}
+<div class="card">thresholds<b class="{{ if .FailedThresholds }}failed{{ else }}passed{{ end }}">
+{{- .FailedThresholds }} of {{ len .Thresholds }} failed</b></div>
+{{- end }}
+</div>
+
+{{- if .Charts }}
+<h2>Over time</h2>
+{{ range .Charts }}{{ . }}
+{{ end }}
+{{- end }}
+
+{{- if .Thresholds }}
+<h2>Thresholds</h2>
+<table>
+<tr><th>Metric</th><th>Threshold</th><th>Result</th></tr>
+{{- range .Thresholds }}
+<tr><td>{{ .Metric }}</td><td><code>{{ .Source }}</code></td>
+{{- if .Passed }}<td class="passed">passed</td>{{ else }}<td class="failed">failed</td>{{ end }}</tr>
+{{- end }}
+</table>
+{{- end }}
+
+{{- if .Checks }}
+<h2>Checks</h2>
+<table>
+<tr><th>Group</th><th>Check</th><th>Passes</th><th>Fails</th><th>Rate</th><th></th></tr>
+{{- range .Checks }}{{ $rate := checkRate . }}
+<tr><td>{{ .Group }}</td><td>{{ .Name }}</td><td class="num">{{ .Passes }}</td><td class="num">{{ .Fails }}</td>
+<td class="num">{{ percent $rate }}</td>
+<td><div class="bar"><div style="width:{{ percent $rate }}"></div></div></td></tr>
+{{- end }}
+</table>
+{{- end }}
+
+<h2>Metrics</h2>
+<table>
+<tr><th>Metric</th><th>Type</th><th>Values</th></tr>
+{{- range .Metrics }}{{ $m := . }}
+<tr><td>{{ .Name }}</td><td>{{ .Type }}</td>
+<td>{{ range $i, $v := .Values }}{{ if $i }}, {{ end }}{{ .Stat }}={{ value $m $v }}{{ end }}</td></tr>
+{{- end }}
+</table>
+
+<footer>Generated by k6 report v{{ .Version }}</footer>
+</main>
+</body>
+</html>
+`
@@ -0,0 +1,651 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package report makes the standalone HTML reports of the test runs, from their JSON or CSV outputs
+// or from their summaries.
+package report
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/stats"
+)
+
+// maxPoints is the maximum count of the points of the charts over time, the interval of their points
+// is the shortest one of the chartIntervals with which there aren't more of them.
+const maxPoints = 120
+
+var chartIntervals = []time.Duration{ //nolint:gochecknoglobals
+	time.Second, 2 * time.Second, 5 * time.Second, 10 * time.Second, 15 * time.Second, 30 * time.Second,
+	time.Minute, 2 * time.Minute, 5 * time.Minute, 10 * time.Minute, 15 * time.Minute, 30 * time.Minute, time.Hour,
+}
+
+// latencyPercentiles are the percentiles of the http_req_duration which are charted over time.
+var latencyPercentiles = []float64{50, 90, 95, 99} //nolint:gochecknoglobals
+
+// summaryStatsOrder is the order of the values of the metrics of the summaries, the others are after them.
+var summaryStatsOrder = []string{ //nolint:gochecknoglobals
+	"count", "rate", "passes", "fails", "value", "avg", "min", "med", "max",
+}
+
+// Report is the report of a test run.
+type Report struct {
+	Title string
+	// Start and End are the times of the first and the last samples, they're zero for the summaries
+	Start, End time.Time
+
+	Metrics    []Metric
+	Checks     []Check
+	Thresholds []Threshold
+
+	// Interval is the interval of the values of the series over time, which the summaries don't have
+	Interval    time.Duration
+	RequestRate Series
+	FailureRate Series
+	Latency     []NamedSeries
+	VUs         Series
+}
+
+// Series is a series of values over time, one per Interval, which are NaN for the intervals without samples.
+type Series []float64
+
+// NamedSeries is a Series with a name, like the one of a percentile.
+type NamedSeries struct {
+	Name   string
+	Values Series
+}
+
+// Metric is a metric of the test run with its values, in the order in which they're shown.
+type Metric struct {
+	Name     string
+	Type     stats.MetricType
+	Contains stats.ValueType
+	Values   []Value
+}
+
+// Value is an aggregated value of a metric, like its avg or its rate.
+type Value struct {
+	Stat  string
+	Value float64
+}
+
+// Check is a check of the test run, the one of the root group has an empty Group.
+type Check struct {
+	Group  string
+	Name   string
+	Passes uint64
+	Fails  uint64
+}
+
+// Threshold is a threshold of a metric of the test run, with whether it passed.
+type Threshold struct {
+	Metric string
+	Source string
+	Passed bool
+}
+
+// Read reads the report of a test run from its JSON output, its CSV output or its summary, which is
+// exported with --summary-export or is the JSON of the data of handleSummary().
+func Read(r io.Reader, title string) (*Report, error) {
+	br := bufio.NewReader(r)
+	// the lines of the JSON outputs are envelopes with a type, and the CSV outputs start with their header
+	firstLine, err := br.ReadBytes('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	rest := io.MultiReader(bytes.NewReader(firstLine), br)
+
+	var envelope struct {
+		Type string `json:"type"`
+	}
+	var report *Report
+	switch {
+	case json.Unmarshal(firstLine, &envelope) == nil && envelope.Type != "":
+		report, err = readJSONOutput(rest)
+	case bytes.HasPrefix(firstLine, []byte("metric_name,")):
+		report, err = readCSVOutput(rest)
+	default:
+		var data []byte
+		if data, err = io.ReadAll(rest); err != nil {
+			return nil, err
+		}
+		report, err = readSummary(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	report.Title = title
+	return report, nil
+}
+
+// timedValue is a sample of a metric which is charted over time.
+type timedValue struct {
+	time  time.Time
+	value float64
+}
+
+// builder builds the reports of the outputs from their samples.
+type builder struct {
+	metrics    map[string]*stats.Metric
+	thresholds map[string]json.RawMessage
+	checks     map[[2]string]*Check
+	// charted has the samples of the metrics which are charted over time
+	charted    map[string][]timedValue
+	start, end time.Time
+}
+
+func newBuilder() *builder {
+	return &builder{
+		metrics:    make(map[string]*stats.Metric),
+		thresholds: make(map[string]json.RawMessage),
+		checks:     make(map[[2]string]*Check),
+		charted:    make(map[string][]timedValue),
+	}
+}
+
+func (b *builder) addSample(metric *stats.Metric, t time.Time, value float64, tags map[string]string) {
+	metric.Sink.Add(stats.Sample{Metric: metric, Time: t, Value: value})
+	if b.start.IsZero() || t.Before(b.start) {
+		b.start = t
+	}
+	if t.After(b.end) {
+		b.end = t
+	}
+
+	switch metric.Name {
+	case metrics.HTTPReqsName, metrics.HTTPReqFailedName, metrics.HTTPReqDurationName, metrics.VUsName:
+		b.charted[metric.Name] = append(b.charted[metric.Name], timedValue{time: t, value: value})
+	case metrics.ChecksName:
+		key := [2]string{tags["group"], tags["check"]}
+		check, ok := b.checks[key]
+		if !ok {
+			check = &Check{Group: key[0], Name: key[1]}
+			b.checks[key] = check
+		}
+		if value != 0 {
+			check.Passes++
+		} else {
+			check.Fails++
+		}
+	}
+}
+
+func (b *builder) report() (*Report, error) {
+	if len(b.metrics) == 0 {
+		return nil, errors.New("the output has no metrics")
+	}
+	report := &Report{Start: b.start, End: b.end, Checks: sortedChecks(b.checks)}
+	duration := b.end.Sub(b.start)
+
+	names := make([]string, 0, len(b.metrics))
+	for name := range b.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		m := b.metrics[name]
+		m.Sink.Calc()
+		report.Metrics = append(report.Metrics, Metric{
+			Name: name, Type: m.Type, Contains: m.Contains, Values: sinkValues(m.Sink, duration),
+		})
+	}
+	// the thresholds are evaluated after the calculation of all the sinks, to which they can refer
+	for _, name := range names {
+		report.Thresholds = append(report.Thresholds, b.runThresholds(b.metrics[name], duration)...)
+	}
+
+	report.Interval = chartIntervals[len(chartIntervals)-1]
+	for _, interval := range chartIntervals {
+		if duration/interval < maxPoints {
+			report.Interval = interval
+			break
+		}
+	}
+	b.chart(report)
+	return report, nil
+}
+
+// runThresholds runs the thresholds of the metric, the ones which can't be parsed fail.
+func (b *builder) runThresholds(m *stats.Metric, duration time.Duration) []Threshold {
+	raw, ok := b.thresholds[m.Name]
+	if !ok || json.Unmarshal(raw, &m.Thresholds) != nil {
+		return nil
+	}
+	parseErr := m.Thresholds.Parse()
+	if parseErr == nil {
+		if _, err := m.Thresholds.RunWithMetrics(m.Sink, duration, b.metrics); err != nil {
+			parseErr = err
+		}
+	}
+	thresholds := make([]Threshold, 0, len(m.Thresholds.Thresholds))
+	for _, t := range m.Thresholds.Thresholds {
+		thresholds = append(thresholds, Threshold{
+			Metric: m.Name, Source: t.Source, Passed: parseErr == nil && !t.LastFailed,
+		})
+	}
+	return thresholds
+}
+
+// chart makes the series over time of the report from the samples of the charted metrics.
+func (b *builder) chart(report *Report) {
+	duration := b.end.Sub(b.start)
+	points := int(duration/report.Interval) + 1
+	index := func(t time.Time) int { return int(t.Sub(b.start) / report.Interval) }
+	// the rates of the last interval are per its duration until the last sample
+	perSecond := func(series Series) Series {
+		for i := range series {
+			seconds := report.Interval.Seconds()
+			if i == points-1 {
+				seconds = math.Max(duration.Seconds()-float64(i)*report.Interval.Seconds(), 1)
+			}
+			series[i] /= seconds
+		}
+		return series
+	}
+
+	if samples, ok := b.charted[metrics.HTTPReqsName]; ok {
+		series := make(Series, points)
+		for _, s := range samples {
+			series[index(s.time)] += s.value
+		}
+		report.RequestRate = perSecond(series)
+	}
+	if samples, ok := b.charted[metrics.HTTPReqFailedName]; ok {
+		series := make(Series, points)
+		for _, s := range samples {
+			if s.value != 0 {
+				series[index(s.time)]++
+			}
+		}
+		report.FailureRate = perSecond(series)
+	}
+	if samples, ok := b.charted[metrics.HTTPReqDurationName]; ok {
+		sinks := make([]*stats.TrendSink, points)
+		for _, s := range samples {
+			i := index(s.time)
+			if sinks[i] == nil {
+				sinks[i] = &stats.TrendSink{}
+			}
+			sinks[i].Add(stats.Sample{Time: s.time, Value: s.value})
+		}
+		for _, pct := range latencyPercentiles {
+			series := NamedSeries{Name: fmt.Sprintf("p(%g)", pct), Values: make(Series, points)}
+			for i, sink := range sinks {
+				series.Values[i] = math.NaN()
+				if sink != nil {
+					series.Values[i] = sink.P(pct / 100)
+				}
+			}
+			report.Latency = append(report.Latency, series)
+		}
+	}
+	if samples, ok := b.charted[metrics.VUsName]; ok {
+		report.VUs = make(Series, points)
+		for i := range report.VUs {
+			report.VUs[i] = math.NaN()
+		}
+		for _, s := range samples {
+			if i := index(s.time); math.IsNaN(report.VUs[i]) || s.value > report.VUs[i] {
+				report.VUs[i] = s.value
+			}
+		}
+	}
+}
+
+// sinkValues returns the values of the sink, which are the ones of the end-of-test summary.
+func sinkValues(sink stats.Sink, duration time.Duration) []Value {
+	switch sink := sink.(type) {
+	case stats.TrendStatsSink:
+		resolvers, _ := stats.GetResolversForTrendColumns(lib.DefaultSummaryTrendStats)
+		values := make([]Value, 0, len(lib.DefaultSummaryTrendStats))
+		for _, stat := range lib.DefaultSummaryTrendStats {
+			values = append(values, Value{Stat: stat, Value: resolvers[stat](sink)})
+		}
+		return values
+	case *stats.RateSink:
+		var rate float64
+		if sink.Total > 0 {
+			rate = float64(sink.Trues) / float64(sink.Total)
+		}
+		return []Value{
+			{Stat: "rate", Value: rate},
+			{Stat: "passes", Value: float64(sink.Trues)},
+			{Stat: "fails", Value: float64(sink.Total - sink.Trues)},
+		}
+	case *stats.CounterSink:
+		rate := sink.Value
+		if duration > 0 {
+			rate /= duration.Seconds()
+		}
+		return []Value{{Stat: "count", Value: sink.Value}, {Stat: "rate", Value: rate}}
+	case *stats.GaugeSink:
+		return []Value{
+			{Stat: "value", Value: sink.Value}, {Stat: "min", Value: sink.Min}, {Stat: "max", Value: sink.Max},
+		}
+	default:
+		return nil
+	}
+}
+
+func sortedChecks(checks map[[2]string]*Check) []Check {
+	sorted := make([]Check, 0, len(checks))
+	for _, check := range checks {
+		sorted = append(sorted, *check)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Group != sorted[j].Group {
+			return sorted[i].Group < sorted[j].Group
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
+
+// readJSONOutput reads the samples of a JSON output, and the thresholds of its metrics.
+func readJSONOutput(r io.Reader) (*Report, error) {
+	b := newBuilder()
+	decoder := json.NewDecoder(r)
+	for {
+		var envelope struct {
+			Type   string          `json:"type"`
+			Metric string          `json:"metric"`
+			Data   json.RawMessage `json:"data"`
+		}
+		if err := decoder.Decode(&envelope); errors.Is(err, io.EOF) {
+			return b.report()
+		} else if err != nil {
+			return nil, fmt.Errorf("couldn't read the JSON output: %w", err)
+		}
+
+		switch envelope.Type {
+		case "Metric":
+			var data struct {
+				Type       stats.MetricType `json:"type"`
+				Contains   stats.ValueType  `json:"contains"`
+				Thresholds json.RawMessage  `json:"thresholds"`
+			}
+			if err := json.Unmarshal(envelope.Data, &data); err != nil {
+				return nil, fmt.Errorf("couldn't read the metric %s: %w", envelope.Metric, err)
+			}
+			if _, ok := b.metrics[envelope.Metric]; ok {
+				continue
+			}
+			metric := stats.New(envelope.Metric, data.Type, data.Contains)
+			if metric == nil {
+				return nil, fmt.Errorf("the metric %s has an unknown type", envelope.Metric)
+			}
+			b.metrics[envelope.Metric] = metric
+			if len(data.Thresholds) > 0 && string(data.Thresholds) != "null" {
+				b.thresholds[envelope.Metric] = data.Thresholds
+			}
+		case "Point":
+			var data struct {
+				Time  time.Time         `json:"time"`
+				Value float64           `json:"value"`
+				Tags  map[string]string `json:"tags"`
+			}
+			if err := json.Unmarshal(envelope.Data, &data); err != nil {
+				return nil, fmt.Errorf("couldn't read a sample of the metric %s: %w", envelope.Metric, err)
+			}
+			metric, ok := b.metrics[envelope.Metric]
+			if !ok {
+				return nil, fmt.Errorf("the sample of the metric %s is before its definition", envelope.Metric)
+			}
+			b.addSample(metric, data.Time, data.Value, data.Tags)
+		}
+	}
+}
+
+// readCSVOutput reads the samples of a CSV output. Its metrics don't have types, the ones of the
+// builtin metrics are known and the other metrics are read as trends.
+func readCSVOutput(r io.Reader) (*Report, error) {
+	builtin := metrics.NewRegistry()
+	metrics.RegisterBuiltinMetrics(builtin)
+
+	b := newBuilder()
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read the CSV output: %w", err)
+	}
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return b.report()
+		} else if err != nil {
+			return nil, fmt.Errorf("couldn't read the CSV output: %w", err)
+		}
+		if len(row) < 3 {
+			return nil, fmt.Errorf("the CSV output has a row with %d columns", len(row))
+		}
+
+		timestamp, err := strconv.ParseInt(row[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("the CSV output has an invalid timestamp %q", row[1])
+		}
+		value, err := strconv.ParseFloat(row[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("the CSV output has an invalid value %q", row[2])
+		}
+		tags := make(map[string]string, len(header)-3)
+		for i := 3; i < len(header); i++ {
+			if header[i] != "extra_tags" {
+				tags[header[i]] = row[i]
+				continue
+			}
+			for _, tag := range strings.Split(row[i], "&") {
+				if i := strings.Index(tag, "="); i >= 0 {
+					tags[tag[:i]] = tag[i+1:]
+				}
+			}
+		}
+
+		metric, ok := b.metrics[row[0]]
+		if !ok {
+			if m := builtin.Get(row[0]); m != nil {
+				metric = stats.New(row[0], m.Type, m.Contains)
+			} else {
+				metric = stats.New(row[0], stats.Trend)
+			}
+			b.metrics[row[0]] = metric
+		}
+		b.addSample(metric, time.Unix(timestamp, 0), value, tags)
+	}
+}
+
+// summaryGroup is a group of a summary. Its groups and checks are objects by name in the summary
+// exports, and arrays in the data of handleSummary().
+type summaryGroup struct {
+	Path   string          `json:"path"`
+	Groups json.RawMessage `json:"groups"`
+	Checks json.RawMessage `json:"checks"`
+}
+
+// readSummary reads a summary, which has the values of the metrics, their thresholds and the checks.
+func readSummary(data []byte) (*Report, error) {
+	baseline, err := lib.ParseBaseline(data)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read the summary: %w", err)
+	}
+	var summary struct {
+		Metrics map[string]struct {
+			// the types are only in the data of handleSummary()
+			Type       *stats.MetricType          `json:"type"`
+			Contains   *stats.ValueType           `json:"contains"`
+			Thresholds map[string]json.RawMessage `json:"thresholds"`
+		} `json:"metrics"`
+		RootGroup summaryGroup `json:"root_group"`
+	}
+	if err = json.Unmarshal(data, &summary); err != nil {
+		return nil, fmt.Errorf("couldn't read the summary: %w", err)
+	}
+
+	builtin := metrics.NewRegistry()
+	metrics.RegisterBuiltinMetrics(builtin)
+	report := &Report{}
+	names := make([]string, 0, len(baseline))
+	for name := range baseline {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		info := summary.Metrics[name]
+		metric := Metric{Name: name, Values: summaryValues(baseline[name])}
+		parent := name
+		if i := strings.Index(name, "{"); i >= 0 {
+			parent = name[:i]
+		}
+		switch m := builtin.Get(parent); {
+		case info.Type != nil:
+			metric.Type = *info.Type
+			if info.Contains != nil {
+				metric.Contains = *info.Contains
+			}
+		case m != nil:
+			metric.Type, metric.Contains = m.Type, m.Contains
+		default:
+			metric.Type = summaryMetricType(baseline[name])
+		}
+		report.Metrics = append(report.Metrics, metric)
+		report.Thresholds = append(report.Thresholds, summaryThresholds(name, info.Thresholds)...)
+	}
+
+	checks := make(map[[2]string]*Check)
+	if err := readSummaryGroup(summary.RootGroup, checks); err != nil {
+		return nil, fmt.Errorf("couldn't read the checks of the summary: %w", err)
+	}
+	report.Checks = sortedChecks(checks)
+	return report, nil
+}
+
+// summaryMetricType infers the type of a metric of a summary export from its values.
+func summaryMetricType(values map[string]float64) stats.MetricType {
+	_, hasPasses := values["passes"]
+	_, hasCount := values["count"]
+	_, hasValue := values["value"]
+	switch {
+	case hasPasses:
+		return stats.Rate
+	case hasCount && len(values) == 2:
+		return stats.Counter
+	case hasValue:
+		return stats.Gauge
+	default:
+		return stats.Trend
+	}
+}
+
+// summaryValues returns the values of a metric of a summary in the summaryStatsOrder.
+func summaryValues(values map[string]float64) []Value {
+	order := make(map[string]int, len(summaryStatsOrder))
+	for i, stat := range summaryStatsOrder {
+		order[stat] = i + 1
+	}
+	sorted := make([]Value, 0, len(values))
+	for stat, value := range values {
+		sorted = append(sorted, Value{Stat: stat, Value: value})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		oi, oj := order[sorted[i].Stat], order[sorted[j].Stat]
+		switch {
+		case oi != 0 && oj != 0:
+			return oi < oj
+		case oi != 0 || oj != 0:
+			return oi != 0
+		default:
+			return sorted[i].Stat < sorted[j].Stat
+		}
+	})
+	return sorted
+}
+
+// summaryThresholds returns the thresholds of a metric of a summary. The summary exports have whether
+// they failed, and the data of handleSummary() whether they're ok.
+func summaryThresholds(metric string, results map[string]json.RawMessage) []Threshold {
+	sources := make([]string, 0, len(results))
+	for source := range results {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	thresholds := make([]Threshold, 0, len(sources))
+	for _, source := range sources {
+		var failed bool
+		if json.Unmarshal(results[source], &failed) != nil {
+			var result struct {
+				OK bool `json:"ok"`
+			}
+			if json.Unmarshal(results[source], &result) != nil {
+				continue
+			}
+			failed = !result.OK
+		}
+		thresholds = append(thresholds, Threshold{Metric: metric, Source: source, Passed: !failed})
+	}
+	return thresholds
+}
+
+func readSummaryGroup(group summaryGroup, checks map[[2]string]*Check) error {
+	var groupChecks []Check
+	if len(group.Checks) > 0 && json.Unmarshal(group.Checks, &groupChecks) != nil {
+		byName := make(map[string]Check)
+		if err := json.Unmarshal(group.Checks, &byName); err != nil {
+			return err
+		}
+		for _, check := range byName {
+			groupChecks = append(groupChecks, check)
+		}
+	}
+	for _, check := range groupChecks {
+		checks[[2]string{group.Path, check.Name}] = &Check{
+			Group: group.Path, Name: check.Name, Passes: check.Passes, Fails: check.Fails,
+		}
+	}
+
+	var groups []summaryGroup
+	if len(group.Groups) > 0 && json.Unmarshal(group.Groups, &groups) != nil {
+		byName := make(map[string]summaryGroup)
+		if err := json.Unmarshal(group.Groups, &byName); err != nil {
+			return err
+		}
+		for _, g := range byName {
+			groups = append(groups, g)
+		}
+	}
+	for _, g := range groups {
+		if err := readSummaryGroup(g, checks); err != nil {
+			return err
+		}
+	}
+	return nil
+}
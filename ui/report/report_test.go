@@ -0,0 +1,208 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2022 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package report
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/stats"
+)
+
+//nolint:lll
+const jsonOutput = `{"type":"Metric","data":{"name":"http_reqs","type":"counter","contains":"default","thresholds":[]},"metric":"http_reqs"}
+{"type":"Metric","data":{"name":"http_req_duration","type":"trend","contains":"time","thresholds":["p(95)<150","avg<10"]},"metric":"http_req_duration"}
+{"type":"Metric","data":{"name":"checks","type":"rate","contains":"default","thresholds":["rate>0.5"]},"metric":"checks"}
+{"type":"Metric","data":{"name":"vus","type":"gauge","contains":"default","thresholds":null},"metric":"vus"}
+{"type":"Point","data":{"time":"2022-01-01T00:00:00Z","value":1,"tags":null},"metric":"vus"}
+{"type":"Point","data":{"time":"2022-01-01T00:00:00.1Z","value":1,"tags":{"status":"200"}},"metric":"http_reqs"}
+{"type":"Point","data":{"time":"2022-01-01T00:00:00.1Z","value":100,"tags":{"status":"200"}},"metric":"http_req_duration"}
+{"type":"Point","data":{"time":"2022-01-01T00:00:00.2Z","value":1,"tags":{"check":"ok","group":""}},"metric":"checks"}
+{"type":"Point","data":{"time":"2022-01-01T00:00:02Z","value":2,"tags":null},"metric":"vus"}
+{"type":"Point","data":{"time":"2022-01-01T00:00:02.5Z","value":1,"tags":{"status":"200"}},"metric":"http_reqs"}
+{"type":"Point","data":{"time":"2022-01-01T00:00:02.5Z","value":200,"tags":{"status":"200"}},"metric":"http_req_duration"}
+{"type":"Point","data":{"time":"2022-01-01T00:00:02.6Z","value":0,"tags":{"check":"ok","group":""}},"metric":"checks"}
+{"type":"Point","data":{"time":"2022-01-01T00:00:02.6Z","value":1,"tags":{"check":"<b>","group":"::login"}},"metric":"checks"}
+{"type":"Point","data":{"time":"2022-01-01T00:00:03Z","value":0,"tags":null},"metric":"vus"}
+`
+
+func TestRead(t *testing.T) {
+	t.Parallel()
+
+	t.Run("JSON output", func(t *testing.T) {
+		t.Parallel()
+		r, err := Read(strings.NewReader(jsonOutput), "test")
+		require.NoError(t, err)
+		assert.Equal(t, "test", r.Title)
+		assert.Equal(t, 3*time.Second, r.End.Sub(r.Start))
+		assert.Equal(t, time.Second, r.Interval)
+
+		require.Len(t, r.Metrics, 4)
+		assert.Equal(t, Metric{Name: "checks", Type: stats.Rate, Values: []Value{
+			{Stat: "rate", Value: 2.0 / 3}, {Stat: "passes", Value: 2}, {Stat: "fails", Value: 1},
+		}}, r.Metrics[0])
+		assert.Equal(t, "http_req_duration", r.Metrics[1].Name)
+		assert.Equal(t, Value{Stat: "avg", Value: 150}, r.Metrics[1].Values[0])
+		assert.Equal(t, []Value{{Stat: "count", Value: 2}, {Stat: "rate", Value: 2.0 / 3}}, r.Metrics[2].Values)
+
+		assert.Equal(t, []Check{
+			{Group: "", Name: "ok", Passes: 1, Fails: 1},
+			{Group: "::login", Name: "<b>", Passes: 1},
+		}, r.Checks)
+		assert.Equal(t, []Threshold{
+			{Metric: "checks", Source: "rate>0.5", Passed: true},
+			{Metric: "http_req_duration", Source: "p(95)<150", Passed: false},
+			{Metric: "http_req_duration", Source: "avg<10", Passed: false},
+		}, r.Thresholds)
+
+		assert.Equal(t, Series{1, 0, 1, 0}, r.RequestRate)
+		assert.Nil(t, r.FailureRate)
+		require.Len(t, r.Latency, 4)
+		assert.Equal(t, "p(50)", r.Latency[0].Name)
+		assert.Equal(t, 100.0, r.Latency[0].Values[0])
+		assert.True(t, math.IsNaN(r.Latency[0].Values[1]))
+		assert.Equal(t, 200.0, r.Latency[0].Values[2])
+		assert.Equal(t, 1.0, r.VUs[0])
+		assert.True(t, math.IsNaN(r.VUs[1]))
+		assert.Equal(t, Series{2, 0}, r.VUs[2:])
+	})
+
+	t.Run("CSV output", func(t *testing.T) {
+		t.Parallel()
+		r, err := Read(strings.NewReader(`metric_name,timestamp,metric_value,check,group,extra_tags
+http_reqs,1640995200,1.000000,,,status=200
+http_req_duration,1640995200,100.000000,,,status=200&method=GET
+checks,1640995201,0.000000,ok,,
+my_metric,1640995202,3.000000,,,
+`), "test")
+		require.NoError(t, err)
+		assert.Equal(t, 2*time.Second, r.End.Sub(r.Start))
+		require.Len(t, r.Metrics, 4)
+		// the types of the builtin metrics are known, the other metrics are trends
+		assert.Equal(t, stats.Rate, r.Metrics[0].Type)
+		assert.Equal(t, stats.Trend, r.Metrics[1].Type)
+		assert.Equal(t, stats.Time, r.Metrics[1].Contains)
+		assert.Equal(t, stats.Counter, r.Metrics[2].Type)
+		assert.Equal(t, Metric{Name: "my_metric", Type: stats.Trend, Values: []Value{
+			{"avg", 3}, {"min", 3}, {"med", 3}, {"max", 3}, {"p(90)", 3}, {"p(95)", 3},
+		}}, r.Metrics[3])
+		assert.Equal(t, []Check{{Name: "ok", Fails: 1}}, r.Checks)
+		assert.Equal(t, Series{1, 0, 0}, r.RequestRate)
+	})
+
+	t.Run("summary export", func(t *testing.T) {
+		t.Parallel()
+		r, err := Read(strings.NewReader(`{
+			"root_group": {"name": "", "path": "", "groups": {
+				"login": {"name": "login", "path": "::login", "groups": {}, "checks": {
+					"ok": {"name": "ok", "path": "::login::ok", "passes": 3, "fails": 1}
+				}}
+			}, "checks": {}},
+			"metrics": {
+				"checks": {"passes": 3, "fails": 1, "value": 0.75, "thresholds": {"rate>0.9": true}},
+				"my_counter": {"count": 10, "rate": 2},
+				"http_req_duration{status:200}": {"avg": 10, "max": 20, "p(99)": 19, "thresholds": {"p(99)<100": false}}
+			}
+		}`), "test")
+		require.NoError(t, err)
+		assert.True(t, r.Start.IsZero())
+		assert.Nil(t, r.RequestRate)
+		require.Len(t, r.Metrics, 3)
+		assert.Equal(t, Metric{Name: "checks", Type: stats.Rate, Values: []Value{
+			{"rate", 0.75}, {"passes", 3}, {"fails", 1},
+		}}, r.Metrics[0])
+		assert.Equal(t, Metric{
+			Name: "http_req_duration{status:200}", Type: stats.Trend, Contains: stats.Time,
+			Values: []Value{{"avg", 10}, {"max", 20}, {"p(99)", 19}},
+		}, r.Metrics[1])
+		assert.Equal(t, stats.Counter, r.Metrics[2].Type)
+		assert.Equal(t, []Check{{Group: "::login", Name: "ok", Passes: 3, Fails: 1}}, r.Checks)
+		assert.Equal(t, []Threshold{
+			{Metric: "checks", Source: "rate>0.9", Passed: false},
+			{Metric: "http_req_duration{status:200}", Source: "p(99)<100", Passed: true},
+		}, r.Thresholds)
+	})
+
+	t.Run("handleSummary data", func(t *testing.T) {
+		t.Parallel()
+		r, err := Read(strings.NewReader(`{
+			"root_group": {"name": "", "path": "", "groups": [], "checks": [
+				{"name": "ok", "path": "::ok", "passes": 1, "fails": 0}
+			]},
+			"metrics": {
+				"my_gauge": {"type": "gauge", "contains": "data", "values": {"value": 1, "min": 1, "max": 2},
+					"thresholds": {"value<2": {"ok": true}}}
+			}
+		}`), "test")
+		require.NoError(t, err)
+		assert.Equal(t, []Metric{{Name: "my_gauge", Type: stats.Gauge, Contains: stats.Data, Values: []Value{
+			{"value", 1}, {"min", 1}, {"max", 2},
+		}}}, r.Metrics)
+		assert.Equal(t, []Check{{Name: "ok", Passes: 1}}, r.Checks)
+		assert.Equal(t, []Threshold{{Metric: "my_gauge", Source: "value<2", Passed: true}}, r.Thresholds)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		t.Parallel()
+		_, err := Read(strings.NewReader(`{"type":"Point","data":{"value":1},"metric":"vus"}`), "test")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "before its definition")
+		_, err = Read(strings.NewReader("metric_name,timestamp,metric_value\nvus,now,1\n"), "test")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid timestamp")
+		_, err = Read(strings.NewReader(`{"metrics": {}}`), "test")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no metrics")
+	})
+}
+
+func TestWriteHTML(t *testing.T) {
+	t.Parallel()
+
+	r, err := Read(strings.NewReader(jsonOutput), "<test>")
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, WriteHTML(&buf, r))
+	html := buf.String()
+
+	assert.Contains(t, html, "<title>&lt;test&gt;</title>")
+	assert.Contains(t, html, "<figcaption>Requests per second</figcaption>")
+	assert.Contains(t, html, "<figcaption>Request duration percentiles</figcaption>")
+	assert.Contains(t, html, "<figcaption>Virtual users</figcaption>")
+	// the percentiles have gaps without requests, and their values between them are dots
+	assert.Contains(t, html, `<path d="M60.0 110.0 L60.0 110.0 M546.7 10.0 L546.7 10.0" stroke="#7d64ff">`+
+		`<title>p(50)</title></path>`)
+	assert.Contains(t, html, `<td>::login</td><td>&lt;b&gt;</td><td class="num">1</td><td class="num">0</td>`)
+	assert.Contains(t, html, `<td class="failed">failed</td>`)
+	assert.Contains(t, html, `2 of 3 failed`)
+	assert.Contains(t, html, "<td>http_req_duration</td><td>trend</td>\n"+
+		`<td>avg=150ms, min=100ms, med=150ms, max=200ms, p(90)=190ms, p(95)=195ms</td>`)
+	assert.Contains(t, html, "<td>checks</td><td>rate</td>\n<td>rate=66.67%, passes=2, fails=1</td>")
+	assert.Contains(t, html, `style="width:50.00%"`)
+	assert.NotContains(t, html, "http://")
+	assert.NotContains(t, html, "ZgotmplZ")
+}